@@ -0,0 +1,695 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/gateway/v1/gateway.proto
+
+package gatewayv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ExpandRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExpandRequest) Reset() {
+	*x = ExpandRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExpandRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExpandRequest) ProtoMessage() {}
+
+func (x *ExpandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExpandRequest.ProtoReflect.Descriptor instead.
+func (*ExpandRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExpandRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ExpandResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExpandResponse) Reset() {
+	*x = ExpandResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExpandResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExpandResponse) ProtoMessage() {}
+
+func (x *ExpandResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExpandResponse.ProtoReflect.Descriptor instead.
+func (*ExpandResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExpandResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ExpandResponse) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type CompressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          []byte                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	SessionId     string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressRequest) Reset() {
+	*x = CompressRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressRequest) ProtoMessage() {}
+
+func (x *CompressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressRequest.ProtoReflect.Descriptor instead.
+func (*CompressRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CompressRequest) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *CompressRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CompressRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type CompressResponse struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	Body          []byte                   `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Compressions  []*ToolOutputCompression `protobuf:"bytes,2,rep,name=compressions,proto3" json:"compressions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressResponse) Reset() {
+	*x = CompressResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressResponse) ProtoMessage() {}
+
+func (x *CompressResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressResponse.ProtoReflect.Descriptor instead.
+func (*CompressResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CompressResponse) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *CompressResponse) GetCompressions() []*ToolOutputCompression {
+	if x != nil {
+		return x.Compressions
+	}
+	return nil
+}
+
+type ToolOutputCompression struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	ToolName         string                 `protobuf:"bytes,1,opt,name=tool_name,json=toolName,proto3" json:"tool_name,omitempty"`
+	ToolCallId       string                 `protobuf:"bytes,2,opt,name=tool_call_id,json=toolCallId,proto3" json:"tool_call_id,omitempty"`
+	ShadowId         string                 `protobuf:"bytes,3,opt,name=shadow_id,json=shadowId,proto3" json:"shadow_id,omitempty"`
+	OriginalTokens   int32                  `protobuf:"varint,4,opt,name=original_tokens,json=originalTokens,proto3" json:"original_tokens,omitempty"`
+	CompressedTokens int32                  `protobuf:"varint,5,opt,name=compressed_tokens,json=compressedTokens,proto3" json:"compressed_tokens,omitempty"`
+	CacheHit         bool                   `protobuf:"varint,6,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ToolOutputCompression) Reset() {
+	*x = ToolOutputCompression{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolOutputCompression) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolOutputCompression) ProtoMessage() {}
+
+func (x *ToolOutputCompression) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolOutputCompression.ProtoReflect.Descriptor instead.
+func (*ToolOutputCompression) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ToolOutputCompression) GetToolName() string {
+	if x != nil {
+		return x.ToolName
+	}
+	return ""
+}
+
+func (x *ToolOutputCompression) GetToolCallId() string {
+	if x != nil {
+		return x.ToolCallId
+	}
+	return ""
+}
+
+func (x *ToolOutputCompression) GetShadowId() string {
+	if x != nil {
+		return x.ShadowId
+	}
+	return ""
+}
+
+func (x *ToolOutputCompression) GetOriginalTokens() int32 {
+	if x != nil {
+		return x.OriginalTokens
+	}
+	return 0
+}
+
+func (x *ToolOutputCompression) GetCompressedTokens() int32 {
+	if x != nil {
+		return x.CompressedTokens
+	}
+	return 0
+}
+
+func (x *ToolOutputCompression) GetCacheHit() bool {
+	if x != nil {
+		return x.CacheHit
+	}
+	return false
+}
+
+type FilterToolsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          []byte                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	SessionId     string                 `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterToolsRequest) Reset() {
+	*x = FilterToolsRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterToolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterToolsRequest) ProtoMessage() {}
+
+func (x *FilterToolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterToolsRequest.ProtoReflect.Descriptor instead.
+func (*FilterToolsRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FilterToolsRequest) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *FilterToolsRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FilterToolsRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type FilterToolsResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Body              []byte                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	OriginalToolCount int32                  `protobuf:"varint,2,opt,name=original_tool_count,json=originalToolCount,proto3" json:"original_tool_count,omitempty"`
+	KeptToolCount     int32                  `protobuf:"varint,3,opt,name=kept_tool_count,json=keptToolCount,proto3" json:"kept_tool_count,omitempty"`
+	DeferredTools     []string               `protobuf:"bytes,4,rep,name=deferred_tools,json=deferredTools,proto3" json:"deferred_tools,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *FilterToolsResponse) Reset() {
+	*x = FilterToolsResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterToolsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterToolsResponse) ProtoMessage() {}
+
+func (x *FilterToolsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterToolsResponse.ProtoReflect.Descriptor instead.
+func (*FilterToolsResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FilterToolsResponse) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *FilterToolsResponse) GetOriginalToolCount() int32 {
+	if x != nil {
+		return x.OriginalToolCount
+	}
+	return 0
+}
+
+func (x *FilterToolsResponse) GetKeptToolCount() int32 {
+	if x != nil {
+		return x.KeptToolCount
+	}
+	return 0
+}
+
+func (x *FilterToolsResponse) GetDeferredTools() []string {
+	if x != nil {
+		return x.DeferredTools
+	}
+	return nil
+}
+
+type SummarizeRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Body             []byte                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Model            string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	KeepRecentTokens int32                  `protobuf:"varint,3,opt,name=keep_recent_tokens,json=keepRecentTokens,proto3" json:"keep_recent_tokens,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SummarizeRequest) Reset() {
+	*x = SummarizeRequest{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummarizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummarizeRequest) ProtoMessage() {}
+
+func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummarizeRequest.ProtoReflect.Descriptor instead.
+func (*SummarizeRequest) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SummarizeRequest) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *SummarizeRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SummarizeRequest) GetKeepRecentTokens() int32 {
+	if x != nil {
+		return x.KeepRecentTokens
+	}
+	return 0
+}
+
+type SummarizeResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Summary             string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	SummaryTokens       int32                  `protobuf:"varint,2,opt,name=summary_tokens,json=summaryTokens,proto3" json:"summary_tokens,omitempty"`
+	LastSummarizedIndex int32                  `protobuf:"varint,3,opt,name=last_summarized_index,json=lastSummarizedIndex,proto3" json:"last_summarized_index,omitempty"`
+	InputTokens         int32                  `protobuf:"varint,4,opt,name=input_tokens,json=inputTokens,proto3" json:"input_tokens,omitempty"`
+	OutputTokens        int32                  `protobuf:"varint,5,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SummarizeResponse) Reset() {
+	*x = SummarizeResponse{}
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummarizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummarizeResponse) ProtoMessage() {}
+
+func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gateway_v1_gateway_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummarizeResponse.ProtoReflect.Descriptor instead.
+func (*SummarizeResponse) Descriptor() ([]byte, []int) {
+	return file_api_gateway_v1_gateway_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SummarizeResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *SummarizeResponse) GetSummaryTokens() int32 {
+	if x != nil {
+		return x.SummaryTokens
+	}
+	return 0
+}
+
+func (x *SummarizeResponse) GetLastSummarizedIndex() int32 {
+	if x != nil {
+		return x.LastSummarizedIndex
+	}
+	return 0
+}
+
+func (x *SummarizeResponse) GetInputTokens() int32 {
+	if x != nil {
+		return x.InputTokens
+	}
+	return 0
+}
+
+func (x *SummarizeResponse) GetOutputTokens() int32 {
+	if x != nil {
+		return x.OutputTokens
+	}
+	return 0
+}
+
+var File_api_gateway_v1_gateway_proto protoreflect.FileDescriptor
+
+const file_api_gateway_v1_gateway_proto_rawDesc = "" +
+	"\n" +
+	"\x1capi/gateway/v1/gateway.proto\x12\x11contextgateway.v1\"\x1f\n" +
+	"\rExpandRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\":\n" +
+	"\x0eExpandResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"X\n" +
+	"\x0fCompressRequest\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\"t\n" +
+	"\x10CompressResponse\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12L\n" +
+	"\fcompressions\x18\x02 \x03(\v2(.contextgateway.v1.ToolOutputCompressionR\fcompressions\"\xe6\x01\n" +
+	"\x15ToolOutputCompression\x12\x1b\n" +
+	"\ttool_name\x18\x01 \x01(\tR\btoolName\x12 \n" +
+	"\ftool_call_id\x18\x02 \x01(\tR\n" +
+	"toolCallId\x12\x1b\n" +
+	"\tshadow_id\x18\x03 \x01(\tR\bshadowId\x12'\n" +
+	"\x0foriginal_tokens\x18\x04 \x01(\x05R\x0eoriginalTokens\x12+\n" +
+	"\x11compressed_tokens\x18\x05 \x01(\x05R\x10compressedTokens\x12\x1b\n" +
+	"\tcache_hit\x18\x06 \x01(\bR\bcacheHit\"[\n" +
+	"\x12FilterToolsRequest\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x03 \x01(\tR\tsessionId\"\xa8\x01\n" +
+	"\x13FilterToolsResponse\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12.\n" +
+	"\x13original_tool_count\x18\x02 \x01(\x05R\x11originalToolCount\x12&\n" +
+	"\x0fkept_tool_count\x18\x03 \x01(\x05R\rkeptToolCount\x12%\n" +
+	"\x0edeferred_tools\x18\x04 \x03(\tR\rdeferredTools\"j\n" +
+	"\x10SummarizeRequest\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12,\n" +
+	"\x12keep_recent_tokens\x18\x03 \x01(\x05R\x10keepRecentTokens\"\xd0\x01\n" +
+	"\x11SummarizeResponse\x12\x18\n" +
+	"\asummary\x18\x01 \x01(\tR\asummary\x12%\n" +
+	"\x0esummary_tokens\x18\x02 \x01(\x05R\rsummaryTokens\x122\n" +
+	"\x15last_summarized_index\x18\x03 \x01(\x05R\x13lastSummarizedIndex\x12!\n" +
+	"\finput_tokens\x18\x04 \x01(\x05R\vinputTokens\x12#\n" +
+	"\routput_tokens\x18\x05 \x01(\x05R\foutputTokens2\xea\x02\n" +
+	"\x0eContextGateway\x12M\n" +
+	"\x06Expand\x12 .contextgateway.v1.ExpandRequest\x1a!.contextgateway.v1.ExpandResponse\x12S\n" +
+	"\bCompress\x12\".contextgateway.v1.CompressRequest\x1a#.contextgateway.v1.CompressResponse\x12\\\n" +
+	"\vFilterTools\x12%.contextgateway.v1.FilterToolsRequest\x1a&.contextgateway.v1.FilterToolsResponse\x12V\n" +
+	"\tSummarize\x12#.contextgateway.v1.SummarizeRequest\x1a$.contextgateway.v1.SummarizeResponseB>Z<github.com/compresr/context-gateway/api/gateway/v1;gatewayv1b\x06proto3"
+
+var (
+	file_api_gateway_v1_gateway_proto_rawDescOnce sync.Once
+	file_api_gateway_v1_gateway_proto_rawDescData []byte
+)
+
+func file_api_gateway_v1_gateway_proto_rawDescGZIP() []byte {
+	file_api_gateway_v1_gateway_proto_rawDescOnce.Do(func() {
+		file_api_gateway_v1_gateway_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_gateway_v1_gateway_proto_rawDesc), len(file_api_gateway_v1_gateway_proto_rawDesc)))
+	})
+	return file_api_gateway_v1_gateway_proto_rawDescData
+}
+
+var file_api_gateway_v1_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_api_gateway_v1_gateway_proto_goTypes = []any{
+	(*ExpandRequest)(nil),         // 0: contextgateway.v1.ExpandRequest
+	(*ExpandResponse)(nil),        // 1: contextgateway.v1.ExpandResponse
+	(*CompressRequest)(nil),       // 2: contextgateway.v1.CompressRequest
+	(*CompressResponse)(nil),      // 3: contextgateway.v1.CompressResponse
+	(*ToolOutputCompression)(nil), // 4: contextgateway.v1.ToolOutputCompression
+	(*FilterToolsRequest)(nil),    // 5: contextgateway.v1.FilterToolsRequest
+	(*FilterToolsResponse)(nil),   // 6: contextgateway.v1.FilterToolsResponse
+	(*SummarizeRequest)(nil),      // 7: contextgateway.v1.SummarizeRequest
+	(*SummarizeResponse)(nil),     // 8: contextgateway.v1.SummarizeResponse
+}
+var file_api_gateway_v1_gateway_proto_depIdxs = []int32{
+	4, // 0: contextgateway.v1.CompressResponse.compressions:type_name -> contextgateway.v1.ToolOutputCompression
+	0, // 1: contextgateway.v1.ContextGateway.Expand:input_type -> contextgateway.v1.ExpandRequest
+	2, // 2: contextgateway.v1.ContextGateway.Compress:input_type -> contextgateway.v1.CompressRequest
+	5, // 3: contextgateway.v1.ContextGateway.FilterTools:input_type -> contextgateway.v1.FilterToolsRequest
+	7, // 4: contextgateway.v1.ContextGateway.Summarize:input_type -> contextgateway.v1.SummarizeRequest
+	1, // 5: contextgateway.v1.ContextGateway.Expand:output_type -> contextgateway.v1.ExpandResponse
+	3, // 6: contextgateway.v1.ContextGateway.Compress:output_type -> contextgateway.v1.CompressResponse
+	6, // 7: contextgateway.v1.ContextGateway.FilterTools:output_type -> contextgateway.v1.FilterToolsResponse
+	8, // 8: contextgateway.v1.ContextGateway.Summarize:output_type -> contextgateway.v1.SummarizeResponse
+	5, // [5:9] is the sub-list for method output_type
+	1, // [1:5] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_api_gateway_v1_gateway_proto_init() }
+func file_api_gateway_v1_gateway_proto_init() {
+	if File_api_gateway_v1_gateway_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_gateway_v1_gateway_proto_rawDesc), len(file_api_gateway_v1_gateway_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_gateway_v1_gateway_proto_goTypes,
+		DependencyIndexes: file_api_gateway_v1_gateway_proto_depIdxs,
+		MessageInfos:      file_api_gateway_v1_gateway_proto_msgTypes,
+	}.Build()
+	File_api_gateway_v1_gateway_proto = out.File
+	file_api_gateway_v1_gateway_proto_goTypes = nil
+	file_api_gateway_v1_gateway_proto_depIdxs = nil
+}
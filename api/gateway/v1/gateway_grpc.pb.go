@@ -0,0 +1,235 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: api/gateway/v1/gateway.proto
+
+package gatewayv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ContextGateway_Expand_FullMethodName      = "/contextgateway.v1.ContextGateway/Expand"
+	ContextGateway_Compress_FullMethodName    = "/contextgateway.v1.ContextGateway/Compress"
+	ContextGateway_FilterTools_FullMethodName = "/contextgateway.v1.ContextGateway/FilterTools"
+	ContextGateway_Summarize_FullMethodName   = "/contextgateway.v1.ContextGateway/Summarize"
+)
+
+// ContextGatewayClient is the client API for ContextGateway service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ContextGatewayClient interface {
+	Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
+	Compress(ctx context.Context, in *CompressRequest, opts ...grpc.CallOption) (*CompressResponse, error)
+	FilterTools(ctx context.Context, in *FilterToolsRequest, opts ...grpc.CallOption) (*FilterToolsResponse, error)
+	Summarize(ctx context.Context, in *SummarizeRequest, opts ...grpc.CallOption) (*SummarizeResponse, error)
+}
+
+type contextGatewayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewContextGatewayClient(cc grpc.ClientConnInterface) ContextGatewayClient {
+	return &contextGatewayClient{cc}
+}
+
+func (c *contextGatewayClient) Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExpandResponse)
+	err := c.cc.Invoke(ctx, ContextGateway_Expand_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextGatewayClient) Compress(ctx context.Context, in *CompressRequest, opts ...grpc.CallOption) (*CompressResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompressResponse)
+	err := c.cc.Invoke(ctx, ContextGateway_Compress_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextGatewayClient) FilterTools(ctx context.Context, in *FilterToolsRequest, opts ...grpc.CallOption) (*FilterToolsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FilterToolsResponse)
+	err := c.cc.Invoke(ctx, ContextGateway_FilterTools_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contextGatewayClient) Summarize(ctx context.Context, in *SummarizeRequest, opts ...grpc.CallOption) (*SummarizeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SummarizeResponse)
+	err := c.cc.Invoke(ctx, ContextGateway_Summarize_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContextGatewayServer is the server API for ContextGateway service.
+// All implementations must embed UnimplementedContextGatewayServer
+// for forward compatibility.
+type ContextGatewayServer interface {
+	Expand(context.Context, *ExpandRequest) (*ExpandResponse, error)
+	Compress(context.Context, *CompressRequest) (*CompressResponse, error)
+	FilterTools(context.Context, *FilterToolsRequest) (*FilterToolsResponse, error)
+	Summarize(context.Context, *SummarizeRequest) (*SummarizeResponse, error)
+	mustEmbedUnimplementedContextGatewayServer()
+}
+
+// UnimplementedContextGatewayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedContextGatewayServer struct{}
+
+func (UnimplementedContextGatewayServer) Expand(context.Context, *ExpandRequest) (*ExpandResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Expand not implemented")
+}
+func (UnimplementedContextGatewayServer) Compress(context.Context, *CompressRequest) (*CompressResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Compress not implemented")
+}
+func (UnimplementedContextGatewayServer) FilterTools(context.Context, *FilterToolsRequest) (*FilterToolsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FilterTools not implemented")
+}
+func (UnimplementedContextGatewayServer) Summarize(context.Context, *SummarizeRequest) (*SummarizeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Summarize not implemented")
+}
+func (UnimplementedContextGatewayServer) mustEmbedUnimplementedContextGatewayServer() {}
+func (UnimplementedContextGatewayServer) testEmbeddedByValue()                        {}
+
+// UnsafeContextGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ContextGatewayServer will
+// result in compilation errors.
+type UnsafeContextGatewayServer interface {
+	mustEmbedUnimplementedContextGatewayServer()
+}
+
+func RegisterContextGatewayServer(s grpc.ServiceRegistrar, srv ContextGatewayServer) {
+	// If the following call panics, it indicates UnimplementedContextGatewayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ContextGateway_ServiceDesc, srv)
+}
+
+func _ContextGateway_Expand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextGatewayServer).Expand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextGateway_Expand_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextGatewayServer).Expand(ctx, req.(*ExpandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextGateway_Compress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextGatewayServer).Compress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextGateway_Compress_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextGatewayServer).Compress(ctx, req.(*CompressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextGateway_FilterTools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilterToolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextGatewayServer).FilterTools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextGateway_FilterTools_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextGatewayServer).FilterTools(ctx, req.(*FilterToolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContextGateway_Summarize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SummarizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContextGatewayServer).Summarize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContextGateway_Summarize_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContextGatewayServer).Summarize(ctx, req.(*SummarizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ContextGateway_ServiceDesc is the grpc.ServiceDesc for ContextGateway service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ContextGateway_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "contextgateway.v1.ContextGateway",
+	HandlerType: (*ContextGatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Expand",
+			Handler:    _ContextGateway_Expand_Handler,
+		},
+		{
+			MethodName: "Compress",
+			Handler:    _ContextGateway_Compress_Handler,
+		},
+		{
+			MethodName: "FilterTools",
+			Handler:    _ContextGateway_FilterTools_Handler,
+		},
+		{
+			MethodName: "Summarize",
+			Handler:    _ContextGateway_Summarize_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/gateway/v1/gateway.proto",
+}
@@ -21,7 +21,6 @@ import (
 	"github.com/compresr/context-gateway/internal/postsession"
 	"github.com/compresr/context-gateway/internal/preemptive"
 	"github.com/compresr/context-gateway/internal/tui"
-	"github.com/compresr/context-gateway/internal/utils"
 )
 
 // runAgentCommand is the main entry point for the agent launcher.
@@ -43,6 +42,7 @@ func runAgentCommand(args []string) {
 		proxyMode       string
 		listFlag        bool
 		resetAPIKeyFlag bool
+		resetStateFlag  bool
 		agentArg        string
 		passthroughArgs []string
 		daemonFlag      bool
@@ -124,6 +124,12 @@ parseLoop:
 		case "--reset-api-key":
 			resetAPIKeyFlag = true
 			i++
+		case "--reset-state":
+			resetStateFlag = true
+			i++
+		case "--plain":
+			// Applied in main before any output; see plainRequested
+			i++
 		case "--":
 			passthroughArgs = args[i+1:]
 			break parseLoop
@@ -223,6 +229,9 @@ parseLoop:
 		// Continue with normal flow after reset
 	}
 
+	// Migrate persisted state (or refuse to start if it is newer than this binary)
+	prepareState(resetStateFlag)
+
 	// Find available port early so ${GATEWAY_PORT} expands correctly in agent configs
 	// Port range: 18081-18090 (max 10 concurrent terminals; 18080 reserved for UI)
 	basePort := config.DefaultGatewayBasePort
@@ -443,19 +452,19 @@ mainSelectionLoop:
 
 				if selectedValue == "__back__" {
 					agentArg = ""
-					fmt.Print("\033[1A\033[2K\r")
+					tui.ClearPreviousLine()
 					continue mainSelectionLoop
 				}
 
 				if selectedValue == "__delete__" {
 					deleteConfig()
-					fmt.Print("\033[1A\033[2K\r")
+					tui.ClearPreviousLine()
 					continue configSelectionLoop
 				}
 
 				if selectedValue == "__edit__" {
 					editConfig(agentArg)
-					fmt.Print("\033[1A\033[2K\r")
+					tui.ClearPreviousLine()
 					continue configSelectionLoop
 				}
 
@@ -463,7 +472,7 @@ mainSelectionLoop:
 					configFlag = runConfigCreationWizard(agentArg, ac)
 					if configFlag == "__back__" {
 						configFlag = ""
-						fmt.Print("\033[1A\033[2K\r")
+						tui.ClearPreviousLine()
 						continue configSelectionLoop
 					}
 					if configFlag == "" {
@@ -899,16 +908,16 @@ mainSelectionLoop:
 	printStep(fmt.Sprintf("Launching %s...", displayName))
 	fmt.Println()
 
-	// Build agent command (all args shell-quoted for bash -c safety)
+	// Build agent command (all args quoted for the platform shell)
 	agentCmd := ac.Agent.Command.Run
 	for _, arg := range ac.Agent.Command.Args {
-		agentCmd += " " + utils.ShellQuote(arg)
+		agentCmd += " " + shellQuote(arg)
 	}
 	for _, arg := range passthroughArgs {
-		agentCmd += " " + utils.ShellQuote(arg)
+		agentCmd += " " + shellQuote(arg)
 	}
 
-	cmd := exec.Command("bash", "-c", agentCmd) // #nosec G204,G702 -- user-selected agent command
+	cmd := shellCommand(agentCmd)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -1134,14 +1143,15 @@ func validateCompresrAPIKeyEarly() (*compresr.GatewayStatus, bool) {
 	status, err := client.GetGatewayStatus()
 
 	if err == nil {
-		fmt.Printf("\r\033[2K  %s✓ API key valid%s\n", tui.ColorGreen, tui.ColorReset)
+		tui.ClearLine()
+		fmt.Printf("  %s✓ API key valid%s\n", tui.ColorGreen, tui.ColorReset)
 		return status, true
 	}
 
 	// Network / transient error: warn and continue — the key may be fine and
 	// the Compresr API is temporarily unreachable.
 	if !strings.Contains(err.Error(), "invalid API key") {
-		fmt.Printf("\r\033[2K") // clear the "Validating" spinner line
+		tui.ClearLine() // clear the "Validating" spinner line
 		printWarn(fmt.Sprintf("Could not reach Compresr API: %v", err))
 		printInfo("Compression features may be unavailable this session.")
 		fmt.Println()
@@ -1149,7 +1159,7 @@ func validateCompresrAPIKeyEarly() (*compresr.GatewayStatus, bool) {
 	}
 
 	// Invalid / expired key: run the blocking re-auth flow (OAuth or paste).
-	fmt.Printf("\r\033[2K") // clear the "Validating" spinner line
+	tui.ClearLine() // clear the "Validating" spinner line
 	if !runCompresrReauth() {
 		return nil, false
 	}
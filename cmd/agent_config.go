@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/userdir"
 	"gopkg.in/yaml.v3"
 )
 
@@ -146,9 +147,9 @@ func loadAgentConfig(name string) (*AgentConfig, []byte, error) {
 	name = strings.TrimSuffix(name, ".yaml")
 
 	// Check filesystem override locations
-	homeDir, _ := os.UserHomeDir()
-	if homeDir != "" {
-		overridePath := filepath.Join(homeDir, ".config", "context-gateway", "agents", name+".yaml")
+	userDir := userdir.Path()
+	if userDir != "" {
+		overridePath := filepath.Join(userDir, "agents", name+".yaml")
 		// #nosec G304,G703 -- path is constructed from internal agent override directory and normalized name
 		if data, err := os.ReadFile(overridePath); err == nil {
 			ac, err := parseAgentConfig(data)
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/compresr/context-gateway/internal/tui"
+	"github.com/compresr/context-gateway/internal/userdir"
 
 	"gopkg.in/yaml.v3"
 )
@@ -205,9 +206,7 @@ func validateAgent(ac *AgentConfig) error {
 	if len(ac.Agent.Command.CheckCmd) == 0 {
 		return nil
 	}
-	// #nosec G204,G702 -- CheckCmd comes from embedded YAML config, not user input
-	checkCmd := exec.Command(ac.Agent.Command.CheckCmd[0], ac.Agent.Command.CheckCmd[1:]...)
-	if err := checkCmd.Run(); err == nil {
+	if agentInstalled(ac.Agent.Command.CheckCmd) {
 		printSuccess(fmt.Sprintf("%s binary found", displayName))
 		return nil // Agent is available
 	}
@@ -215,13 +214,13 @@ func validateAgent(ac *AgentConfig) error {
 	fmt.Println()
 	printWarn(fmt.Sprintf("Agent '%s' is not installed", displayName))
 	if ac.Agent.Command.FallbackMessage != "" {
-		fmt.Printf("  \033[1;33m%s\033[0m\n", ac.Agent.Command.FallbackMessage)
+		fmt.Printf("  %s%s%s\n", tui.ColorYellow, ac.Agent.Command.FallbackMessage, tui.ColorReset)
 	}
 	fmt.Println()
 
 	if len(ac.Agent.Command.InstallCmd) > 0 {
 		fmt.Printf("Would you like to install it now? [Y/n]\n")
-		fmt.Printf("  \033[2mCommand: %s\033[0m\n\n", strings.Join(ac.Agent.Command.InstallCmd, " "))
+		fmt.Printf("  %sCommand: %s%s\n\n", tui.ColorDim, strings.Join(ac.Agent.Command.InstallCmd, " "), tui.ColorReset)
 
 		reader := bufio.NewReader(os.Stdin)
 		resp, _ := reader.ReadString('\n')
@@ -244,7 +243,7 @@ func validateAgent(ac *AgentConfig) error {
 		if err := installCmd.Run(); err != nil {
 			fmt.Println()
 			printError("Installation failed")
-			fmt.Printf("  \033[1;33mYou can try manually: %s\033[0m\n", strings.Join(ac.Agent.Command.InstallCmd, " "))
+			fmt.Printf("  %sYou can try manually: %s%s\n", tui.ColorYellow, strings.Join(ac.Agent.Command.InstallCmd, " "), tui.ColorReset)
 			return fmt.Errorf("installation failed")
 		}
 
@@ -257,16 +256,28 @@ func validateAgent(ac *AgentConfig) error {
 	return fmt.Errorf("agent not installed")
 }
 
+// agentInstalled runs an agent's check_cmd. "which <name>" is answered with
+// exec.LookPath instead, which needs no which binary (Windows) and finds
+// npm's .cmd shims through PATHEXT.
+func agentInstalled(checkCmd []string) bool {
+	if len(checkCmd) == 2 && checkCmd[0] == "which" {
+		_, err := exec.LookPath(checkCmd[1])
+		return err == nil
+	}
+	// #nosec G204,G702 -- CheckCmd comes from embedded YAML config, not user input
+	return exec.Command(checkCmd[0], checkCmd[1:]...).Run() == nil
+}
+
 // discoverAgents discovers agents from filesystem locations and embedded defaults.
 // Filesystem agents take priority over embedded ones.
 // Returns a map of agent name -> raw YAML bytes.
 func discoverAgents() map[string][]byte {
 	agents := make(map[string][]byte)
 
-	homeDir, _ := os.UserHomeDir()
+	userDir := userdir.Path()
 	searchDirs := []string{}
-	if homeDir != "" {
-		searchDirs = append(searchDirs, filepath.Join(homeDir, ".config", "context-gateway", "agents"))
+	if userDir != "" {
+		searchDirs = append(searchDirs, filepath.Join(userDir, "agents"))
 	}
 	searchDirs = append(searchDirs, "agents")
 
@@ -325,9 +336,9 @@ func resolveConfig(userConfig string) ([]byte, string, error) {
 	name := strings.TrimSuffix(userConfig, ".yaml")
 
 	// Check filesystem locations
-	homeDir, _ := os.UserHomeDir()
-	if homeDir != "" {
-		path := filepath.Join(homeDir, ".config", "context-gateway", "configs", name+".yaml")
+	userDir := userdir.Path()
+	if userDir != "" {
+		path := filepath.Join(userDir, "configs", name+".yaml")
 		// #nosec G304,G703 -- trusted config path
 		if data, err := os.ReadFile(path); err == nil {
 			return data, path, nil
@@ -344,8 +355,8 @@ func resolveConfig(userConfig string) ([]byte, string, error) {
 	// Fall back to embedded config — materialize to user config dir so the
 	// global config file exists on disk and dashboard changes persist across restarts.
 	if data, err := getEmbeddedConfig(name); err == nil {
-		if homeDir != "" {
-			userConfigDir := filepath.Join(homeDir, ".config", "context-gateway", "configs")
+		if userDir != "" {
+			userConfigDir := filepath.Join(userDir, "configs")
 			if mkErr := os.MkdirAll(userConfigDir, 0750); mkErr == nil {
 				persistPath := filepath.Join(userConfigDir, name+".yaml")
 				// #nosec G306 G703 -- config file, not secret; path constructed from validated inputs
@@ -372,10 +383,10 @@ func listAvailableConfigs() []string {
 		"external_providers": true, // LLM provider definitions for TUI, not a proxy config
 	}
 
-	homeDir, _ := os.UserHomeDir()
+	userDir := userdir.Path()
 	dirs := []string{}
-	if homeDir != "" {
-		dirs = append(dirs, filepath.Join(homeDir, ".config", "context-gateway", "configs"))
+	if userDir != "" {
+		dirs = append(dirs, filepath.Join(userDir, "configs"))
 	}
 	dirs = append(dirs, "configs")
 
@@ -419,22 +430,22 @@ func listAvailableConfigs() []string {
 
 // isUserConfig checks if a config is a user-created config (in ~/.config/context-gateway/configs/).
 func isUserConfig(name string) bool {
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
+	userDir := userdir.Path()
+	if userDir == "" {
 		return false
 	}
-	path := filepath.Join(homeDir, ".config", "context-gateway", "configs", name+".yaml")
+	path := filepath.Join(userDir, "configs", name+".yaml")
 	_, err := os.Stat(path)
 	return err == nil
 }
 
 // hasUserConfigs checks if there are any user-created configs.
 func hasUserConfigs() bool {
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
+	userDir := userdir.Path()
+	if userDir == "" {
 		return false
 	}
-	dir := filepath.Join(homeDir, ".config", "context-gateway", "configs")
+	dir := filepath.Join(userDir, "configs")
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return false
@@ -449,11 +460,11 @@ func hasUserConfigs() bool {
 
 // listUserConfigs returns only user-created config names.
 func listUserConfigs() []string {
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
+	userDir := userdir.Path()
+	if userDir == "" {
 		return nil
 	}
-	dir := filepath.Join(homeDir, ".config", "context-gateway", "configs")
+	dir := filepath.Join(userDir, "configs")
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil
@@ -506,7 +517,7 @@ func listAvailableConfigsPrint() {
 
 		desc := extractConfigDescription(name)
 
-		fmt.Printf("  \033[0;32m[%d]\033[0m \033[1m%s\033[0m \033[2m%s\033[0m\n", i+1, name, source)
+		fmt.Printf("  %s[%d]%s %s%s%s %s%s%s\n", tui.ColorGreen, i+1, tui.ColorReset, tui.ColorBold, name, tui.ColorReset, tui.ColorDim, source, tui.ColorReset)
 		if desc != "" {
 			fmt.Printf("      %s\n", desc)
 		}
@@ -601,9 +612,9 @@ func listAvailableAgents() {
 			description = ac.Agent.Description
 		}
 
-		fmt.Printf("  \033[0;32m[%d]\033[0m \033[1m%s\033[0m\n", i, name)
+		fmt.Printf("  %s[%d]%s %s%s%s\n", tui.ColorGreen, i, tui.ColorReset, tui.ColorBold, name, tui.ColorReset)
 		if displayName != name {
-			fmt.Printf("      \033[0;36m%s\033[0m\n", displayName)
+			fmt.Printf("      %s%s%s\n", tui.ColorCyan, displayName, tui.ColorReset)
 		}
 		if description != "" {
 			fmt.Printf("      %s\n", description)
@@ -615,30 +626,30 @@ func listAvailableAgents() {
 
 // Print helper functions for consistent output formatting.
 func printHeader(title string) {
-	fmt.Printf("\033[1m\033[0;36m========================================\033[0m\n")
-	fmt.Printf("\033[1m\033[0;36m       %s\033[0m\n", title)
-	fmt.Printf("\033[1m\033[0;36m========================================\033[0m\n")
+	fmt.Printf("%s%s========================================%s\n", tui.ColorBold, tui.ColorCyan, tui.ColorReset)
+	fmt.Printf("%s%s       %s%s\n", tui.ColorBold, tui.ColorCyan, title, tui.ColorReset)
+	fmt.Printf("%s%s========================================%s\n", tui.ColorBold, tui.ColorCyan, tui.ColorReset)
 	fmt.Println()
 }
 
 func printSuccess(msg string) {
-	fmt.Printf("\r\033[0;32m[OK]\033[0m %s\n", msg)
+	fmt.Printf("\r%s[OK]%s %s\n", tui.ColorGreen, tui.ColorReset, msg)
 }
 
 func printInfo(msg string) {
-	fmt.Printf("  \033[2m·\033[0m %s\n", msg)
+	tui.PrintInfo(msg)
 }
 
 func printWarn(msg string) {
-	fmt.Printf("\033[1;33m[WARN]\033[0m %s\n", msg)
+	tui.PrintWarn(msg)
 }
 
 func printError(msg string) {
-	fmt.Printf("\033[0;31m[ERROR]\033[0m %s\n", msg)
+	tui.PrintError(msg)
 }
 
 func printStep(msg string) {
-	fmt.Printf("\033[0;36m>>>\033[0m %s\n", msg)
+	tui.PrintStep(msg)
 }
 
 func printAgentHelp() {
@@ -654,7 +665,9 @@ func printAgentHelp() {
 	fmt.Println("  -d, --debug          Enable debug logging")
 	fmt.Println("  --proxy MODE         auto (default), start, skip")
 	fmt.Println("  --reset-api-key      Reset Compresr API key and re-run setup")
+	fmt.Println("  --reset-state        Move persisted state aside and start fresh")
 	fmt.Println("  -l, --list           List available agents")
+	fmt.Println("  --plain              Numbered prompts, no colors or cursor control (auto when TERM is unset or dumb)")
 	fmt.Println("  -h, --help           Show this help")
 	fmt.Println()
 	fmt.Println("Pass-through Arguments:")
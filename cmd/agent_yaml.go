@@ -10,6 +10,7 @@ import (
 	"github.com/compresr/context-gateway/internal/pipes"
 	"github.com/compresr/context-gateway/internal/preemptive"
 	"github.com/compresr/context-gateway/internal/tui"
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // saveConfig saves the config to disk and returns its name
@@ -38,12 +39,11 @@ func saveConfig(state *ConfigState) string {
 		state.TelemetryEnabled,
 	)
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil || homeDir == "" {
+	configDir := userdir.Path("configs")
+	if configDir == "" {
 		printError("Failed to resolve user home directory")
 		return ""
 	}
-	configDir := filepath.Join(homeDir, ".config", "context-gateway", "configs")
 	// #nosec G301 -- config directory permissions
 	if err := os.MkdirAll(configDir, 0750); err != nil {
 		printError(fmt.Sprintf("Failed to create config directory: %v", err))
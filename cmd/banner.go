@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"golang.org/x/term"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/tui"
 )
 
 // ANSI codes for banner styling.
@@ -103,5 +106,19 @@ func colorLines(art string, termW int) string {
 
 // printBanner prints the banner sized to the current terminal width.
 func printBanner() {
+	if tui.IsPlain() {
+		fmt.Print("\nContext Gateway\n\n")
+		return
+	}
 	fmt.Print(buildBanner(terminalWidth()))
 }
+
+// printEffectiveConfig prints the active configuration summary below the banner.
+// The same data (as JSON) is served at GET /config/effective.
+func printEffectiveConfig(cfg *config.Config) {
+	fmt.Println(bold + "  Active configuration" + reset)
+	for _, line := range cfg.Effective().Summary() {
+		fmt.Println("    " + line)
+	}
+	fmt.Println()
+}
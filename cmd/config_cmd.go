@@ -31,6 +31,7 @@ func runConfigCommand(args []string) {
 
 	fs := flag.NewFlagSet("config", flag.ExitOnError)
 	browserMode := fs.Bool("browser", false, "open settings in browser")
+	_ = fs.Bool("plain", false, "numbered prompts without colors or cursor control (applied in main)")
 	_ = fs.Parse(args)
 
 	loadEnvFiles()
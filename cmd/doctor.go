@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/doctor"
+	"github.com/compresr/context-gateway/internal/tui"
+)
+
+// runDoctorCommand checks the things that commonly break a setup and prints
+// a report with a fix for each problem: config validity, the listen port,
+// provider keys, the Compresr API key, upstream reachability, Claude Code
+// hooks and whether a newer version exists. Exits 1 when a check fails.
+//
+//	context-gateway doctor [--config FILE] [--port N] [--offline] [--json]
+func runDoctorCommand(args []string) {
+	loadEnvFiles()
+
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file to check (default: the one serve would load)")
+	port := fs.Int("port", 0, "port to check (default: server.port of the config)")
+	offline := fs.Bool("offline", false, "skip the checks that need the network")
+	asJSON := fs.Bool("json", false, "print JSON instead of a report")
+	_ = fs.Parse(args) // ExitOnError handles errors
+
+	ctx := context.Background()
+	client := &http.Client{Timeout: doctor.DefaultTimeout}
+	var checks []doctor.Check
+
+	var cfg *config.Config
+	if data, source, err := resolveServeConfig(*configPath); err != nil {
+		checks = append(checks, doctor.Check{Name: "config", Status: doctor.StatusFail, Detail: err.Error(), Fix: "run `context-gateway config` to create one"})
+	} else {
+		var c doctor.Check
+		c, cfg = doctor.CheckConfig(data, source)
+		checks = append(checks, c)
+	}
+
+	if *port == 0 {
+		*port = config.DefaultGatewayBasePort
+		if cfg != nil && cfg.Server.Port != 0 {
+			*port = cfg.Server.Port
+		}
+	}
+	checks = append(checks, doctor.CheckPort(ctx, client, *port))
+
+	if cfg != nil {
+		checks = append(checks, doctor.CheckProviderKeys(cfg)...)
+		if !*offline {
+			checks = append(checks, doctor.CheckCompresr(ctx, client, cfg))
+			checks = append(checks, doctor.CheckUpstreams(ctx, client, cfg)...)
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			checks = append(checks, doctor.CheckClaudeHooks(cfg, filepath.Join(home, ".claude")))
+		}
+	}
+	if !*offline {
+		checks = append(checks, checkVersion())
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if c.Status == doctor.StatusFail {
+			failed++
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(checks)
+	} else {
+		printDoctorReport(checks)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkVersion compares this binary with the latest release.
+func checkVersion() doctor.Check {
+	current := getCurrentVersion()
+	c := doctor.Check{Name: "version", Status: doctor.StatusOK, Detail: current + " is the latest"}
+	latest, err := getLatestVersion()
+	switch {
+	case err != nil:
+		c.Status, c.Detail = doctor.StatusWarn, "could not check for updates: "+err.Error()
+	case isNewerVersion(current, latest):
+		c.Status, c.Detail = doctor.StatusWarn, fmt.Sprintf("%s is available (running %s)", latest, current)
+		c.Fix = "run `context-gateway update`"
+	}
+	return c
+}
+
+// printDoctorReport prints one line per check, with the fix under each problem.
+func printDoctorReport(checks []doctor.Check) {
+	printHeader("Context Gateway Doctor")
+	counts := make(map[string]int)
+	for _, c := range checks {
+		counts[c.Status]++
+		var mark string
+		switch c.Status {
+		case doctor.StatusOK:
+			mark = tui.ColorGreen + "[OK]  " + tui.ColorReset
+		case doctor.StatusSkip:
+			mark = tui.ColorDim + "[SKIP]" + tui.ColorReset
+		case doctor.StatusWarn:
+			mark = tui.ColorYellow + "[WARN]" + tui.ColorReset
+		default:
+			mark = tui.ColorRed + "[FAIL]" + tui.ColorReset
+		}
+		fmt.Printf("%s %s%-28s%s %s\n", mark, tui.ColorBold, c.Name, tui.ColorReset, c.Detail)
+		if c.Fix != "" {
+			fmt.Printf("       %s→ %s%s\n", tui.ColorCyan, c.Fix, tui.ColorReset)
+		}
+	}
+	fmt.Printf("\n%d ok, %d warning(s), %d failed\n", counts[doctor.StatusOK], counts[doctor.StatusWarn], counts[doctor.StatusFail])
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// runLogsCommand manages the gateway's JSONL logs.
+//
+//	context-gateway logs prune [--config FILE] [--max-files N] [--max-age DURATION]
+//
+// prune applies monitoring.retention once: it deletes rotated logs beyond
+// max_files or older than max_age, and session trajectories older than
+// max_age. It works whether or not retention is enabled in the config.
+func runLogsCommand(args []string) {
+	if len(args) == 0 || args[0] != "prune" {
+		fmt.Fprintln(os.Stderr, "Usage: context-gateway logs prune [--config FILE] [--max-files N] [--max-age DURATION]")
+		os.Exit(2)
+	}
+	loadEnvFiles()
+
+	fs := flag.NewFlagSet("logs prune", flag.ExitOnError)
+	configPath := fs.String("config", "", "config whose log paths to prune (default: the one serve would load)")
+	maxFiles := fs.Int("max-files", 0, "rotated files kept per log (default: monitoring.retention.max_files)")
+	maxAge := fs.Duration("max-age", 0, "delete rotated logs and trajectories older than this, e.g. 720h (default: monitoring.retention.max_age)")
+	_ = fs.Parse(args[1:]) // ExitOnError handles errors
+
+	data, source, err := resolveServeConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := config.LoadFromBytes(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", source, err)
+		os.Exit(1)
+	}
+
+	retention := cfg.Monitoring.Retention
+	if *maxFiles > 0 {
+		retention.MaxFiles = *maxFiles
+	}
+	if *maxAge > 0 {
+		retention.MaxAge = *maxAge
+	}
+
+	now := time.Now()
+	var removed []string
+	failed := false
+	for _, path := range cfg.Monitoring.RetainedLogPaths() {
+		files, err := monitoring.PruneRotated(path, retention, now)
+		removed = append(removed, files...)
+		if err != nil {
+			printError(fmt.Sprintf("%s: %v", path, err))
+			failed = true
+		}
+	}
+	if cfg.Monitoring.TrajectoryEnabled {
+		files, err := monitoring.PruneTrajectories(cfg.Monitoring.TrajectoryDir(), retention, now)
+		removed = append(removed, files...)
+		if err != nil {
+			printError(fmt.Sprintf("%s: %v", cfg.Monitoring.TrajectoryDir(), err))
+			failed = true
+		}
+	}
+
+	for _, f := range removed {
+		fmt.Printf("removed %s\n", f)
+	}
+	fmt.Printf("%d file(s) removed\n", len(removed))
+	if failed {
+		os.Exit(1)
+	}
+}
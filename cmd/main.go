@@ -5,6 +5,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -19,25 +20,28 @@ import (
 	"github.com/compresr/context-gateway/internal/config"
 	"github.com/compresr/context-gateway/internal/gateway"
 	"github.com/compresr/context-gateway/internal/tui"
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // loadEnvFiles loads .env from standard locations
 func loadEnvFiles() {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+	configEnv := userdir.Path(".env")
+	if configEnv == "" {
 		// If we can't resolve home, fall back to no-op to avoid
 		// accidentally loading a local .env.
 		return
 	}
 
-	// Try loading from ~/.config/context-gateway/.env first
-	configEnv := filepath.Join(homeDir, ".config", "context-gateway", ".env")
+	// Try loading from the user directory's .env (~/.config/context-gateway/.env)
 	if _, err := os.Stat(configEnv); err == nil {
 		_ = godotenv.Load(configEnv)
 	}
 }
 
 func main() {
+	// Plain mode must be set before the first banner or menu is printed
+	tui.SetPlain(plainRequested(os.Args[1:]) || tui.DetectPlain())
+
 	// Handle subcommands first (before flags)
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -53,6 +57,30 @@ func main() {
 			printBanner()
 			runConfigCommand(os.Args[2:])
 			return
+		case "tail":
+			runTailCommand(os.Args[2:])
+			return
+		case "snapshot":
+			runSnapshotCommand(os.Args[2:])
+			return
+		case "stats":
+			runStatsCommand(os.Args[2:])
+			return
+		case "validate":
+			runValidateCommand(os.Args[2:])
+			return
+		case "logs":
+			runLogsCommand(os.Args[2:])
+			return
+		case "doctor":
+			runDoctorCommand(os.Args[2:])
+			return
+		case "replay":
+			runReplayCommand(os.Args[2:])
+			return
+		case "mcp":
+			runMCPCommand(os.Args[2:])
+			return
 		case "update":
 			printBanner()
 			if err := DoUpdate(); err != nil {
@@ -80,6 +108,20 @@ func main() {
 	runAgentCommand(os.Args[1:])
 }
 
+// plainRequested reports whether --plain appears before a "--" separator
+// (arguments after it are passed through to the agent).
+func plainRequested(args []string) bool {
+	for _, a := range args {
+		switch a {
+		case "--":
+			return false
+		case "--plain", "-plain":
+			return true
+		}
+	}
+	return false
+}
+
 // resolveServeConfig resolves the config for the serve command.
 // Checks: user flag -> filesystem locations -> embedded configs.
 // Returns raw bytes and source description.
@@ -93,15 +135,15 @@ func resolveServeConfig(userConfig string) ([]byte, string, error) {
 		return data, userConfig, nil
 	}
 
-	homeDir, _ := os.UserHomeDir()
+	userDir := userdir.Path()
 
 	// Search filesystem in order of preference
 	searchPaths := []string{}
-	if homeDir != "" {
+	if userDir != "" {
 		searchPaths = append(searchPaths,
-			filepath.Join(homeDir, ".config", "context-gateway", "configs", "fast_setup.yaml"),
-			filepath.Join(homeDir, ".config", "context-gateway", "configs", "preemptive_summarization.yaml"),
-			filepath.Join(homeDir, ".config", "context-gateway", "configs", "config.yaml"),
+			filepath.Join(userDir, "configs", "fast_setup.yaml"),
+			filepath.Join(userDir, "configs", "preemptive_summarization.yaml"),
+			filepath.Join(userDir, "configs", "config.yaml"),
 		)
 	}
 	searchPaths = append(searchPaths,
@@ -120,8 +162,8 @@ func resolveServeConfig(userConfig string) ([]byte, string, error) {
 	// Fall back to embedded config — materialize to user config dir so the
 	// global config file exists on disk and dashboard changes persist across restarts.
 	if data, err := getEmbeddedConfig("fast_setup"); err == nil {
-		if homeDir != "" {
-			userConfigDir := filepath.Join(homeDir, ".config", "context-gateway", "configs")
+		if userDir != "" {
+			userConfigDir := filepath.Join(userDir, "configs")
 			if mkErr := os.MkdirAll(userConfigDir, 0750); mkErr == nil {
 				persistPath := filepath.Join(userConfigDir, "fast_setup.yaml")
 				// #nosec G306 -- config file, not secret
@@ -146,6 +188,10 @@ func runGatewayServer(args []string) {
 	configPath := fs.String("config", "", "path to config file")
 	debug := fs.Bool("debug", false, "enable debug logging")
 	noBanner := fs.Bool("no-banner", false, "suppress startup banner")
+	resetState := fs.Bool("reset-state", false, "move persisted state aside and start fresh")
+	target := fs.String("target", "", `upstream target override: "echo" answers requests with a local fake provider (no tokens, no network)`)
+	recordFixtures := fs.String("record-fixtures", "", "dev: write anonymized request/response pairs to this directory as test fixtures")
+	grpcAddr := fs.String("grpc-addr", "", "also serve the gRPC API (Expand, Compress, FilterTools, Summarize) on this address, e.g. 127.0.0.1:18090")
 	_ = fs.Parse(args) // ExitOnError handles errors
 
 	// Print banner unless suppressed
@@ -176,13 +222,26 @@ func runGatewayServer(args []string) {
 	if err != nil {
 		log.Fatal().Err(err).Str("config", configSource).Msg("failed to load configuration")
 	}
+	if *target != "" {
+		cfg.Server.Target = *target
+		if err := cfg.Validate(); err != nil {
+			log.Fatal().Err(err).Msg("invalid --target")
+		}
+	}
 
 	log.Info().
 		Int("port", cfg.Server.Port).
 		Bool("tool_output_pipe", cfg.Pipes.ToolOutput.Enabled).
 		Bool("tool_discovery_pipe", cfg.Pipes.ToolDiscovery.Enabled).
+		Bool("cost_control", cfg.CostControl.Enabled).
+		Int("providers", len(cfg.Providers)).
 		Msg("configuration loaded")
 
+	// Show what is actually loaded (secrets redacted) — also at GET /config/effective
+	if !*noBanner {
+		printEffectiveConfig(cfg)
+	}
+
 	// Warn if any API keys are stored as literal values instead of env var references.
 	// Literal keys don't update automatically when credentials rotate.
 	// Run `context-gateway config migrate` to convert them to env var references.
@@ -190,10 +249,33 @@ func runGatewayServer(args []string) {
 		log.Warn().Msg("config: " + warning)
 	}
 
+	// Migrate persisted state (or refuse to start if it is newer than this binary)
+	prepareState(*resetState)
+
 	// Create gateway (pass config source for hot-reload support)
 	gw := gateway.New(cfg, configSource)
 	gw.SetVersion(Version)
 
+	// Dev: record anonymized traffic as test fixtures. Passing the flag is the
+	// user's consent; nothing is recorded without it.
+	if *recordFixtures != "" {
+		if err := gw.RecordFixtures(*recordFixtures); err != nil {
+			log.Fatal().Err(err).Msg("invalid --record-fixtures")
+		}
+		log.Warn().
+			Str("dir", *recordFixtures).
+			Msg("recording anonymized requests and responses as test fixtures; review them before sharing or committing")
+	}
+
+	// gRPC API for services that call the shadow store and pipes directly
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal().Err(err).Str("addr", *grpcAddr).Msg("failed to start gRPC API")
+		}
+		gw.ServeGRPC(lis)
+	}
+
 	// Attach embedded React dashboard SPA
 	if dashFS, err := getDashboardFS(); err == nil {
 		gw.SetDashboardFS(dashFS)
@@ -268,6 +350,14 @@ func printHelp() {
 	fmt.Println("  (none)       Launch Claude Code with gateway proxy (default)")
 	fmt.Println("  config       Configure gateway (TUI or browser)")
 	fmt.Println("  serve        Start the gateway proxy server only")
+	fmt.Println("  tail         Follow telemetry and compression logs live")
+	fmt.Println("  snapshot     Save or restore in-memory gateway state (encrypted)")
+	fmt.Println("  stats        Show requests and savings since start and over the gateway's lifetime")
+	fmt.Println("  validate     Check a config file for typos, bad values and unset env vars")
+	fmt.Println("  logs         Prune rotated JSONL logs and old trajectories")
+	fmt.Println("  doctor       Diagnose config, port, keys, connectivity, hooks and version")
+	fmt.Println("  replay       Re-run a recorded request through the pipes and diff the result")
+	fmt.Println("  mcp          Serve the gateway's MCP tools over stdio (for MCP clients)")
 	fmt.Println("  update       Update to the latest version")
 	fmt.Println("  uninstall    Remove context-gateway")
 	fmt.Println("  version      Print version information")
@@ -280,16 +370,57 @@ func printHelp() {
 	fmt.Println("  -d, --debug          Enable debug logging")
 	fmt.Println("  --proxy MODE         auto (default), start, skip")
 	fmt.Println("  --reset-api-key      Reset Compresr API key and re-run setup")
+	fmt.Println("  --reset-state        Move persisted state aside and start fresh")
 	fmt.Println("  -l, --list           List available agents")
+	fmt.Println("  --plain              Numbered prompts, no colors or cursor control (auto when TERM is unset or dumb)")
 	fmt.Println()
 	fmt.Println("Server Options:")
-	fmt.Println("  context-gateway serve [--config FILE] [--debug] [--no-banner]")
+	fmt.Println("  context-gateway serve [--config FILE] [--debug] [--no-banner] [--reset-state] [--target echo]")
+	fmt.Println("                        [--record-fixtures DIR] [--grpc-addr HOST:PORT]")
+	fmt.Println()
+	fmt.Println("Tail Options:")
+	fmt.Println("  context-gateway tail [--session ID] [--pipe NAME] [--dir DIR] [--from-start] [--no-color]")
+	fmt.Println()
+	fmt.Println("Snapshot Options:")
+	fmt.Println("  context-gateway snapshot save|restore [--port N] [--file FILE] [--passphrase-file FILE]")
+	fmt.Println()
+	fmt.Println("Stats Options:")
+	fmt.Println("  context-gateway stats [--port N] [--json]")
+	fmt.Println()
+	fmt.Println("Validate Options:")
+	fmt.Println("  context-gateway validate [--config FILE] [--strict] [FILE]")
+	fmt.Println()
+	fmt.Println("Logs Options:")
+	fmt.Println("  context-gateway logs prune [--config FILE] [--max-files N] [--max-age DURATION]")
+	fmt.Println()
+	fmt.Println("Doctor Options:")
+	fmt.Println("  context-gateway doctor [--config FILE] [--port N] [--offline] [--json]")
+	fmt.Println()
+	fmt.Println("Replay Options:")
+	fmt.Println("  context-gateway replay [--config FILE] [--strategy NAME] [--request ID] [--all] [--path PATH]")
+	fmt.Println("                         [--no-diff] [--debug] FILE|SESSION_DIR")
+	fmt.Println()
+	fmt.Println("MCP Options:")
+	fmt.Println("  context-gateway mcp [--port N]     Token from $" + mcpTokenEnv + " when server.admin_token is set")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  context-gateway                    Launch Claude Code (default)")
 	fmt.Println("  context-gateway -d                 Launch with debug logging")
 	fmt.Println("  context-gateway serve              Start gateway server only")
+	fmt.Println("  context-gateway config --plain     Run the setup wizard over SSH or in CI")
+	fmt.Println("  context-gateway serve --target echo")
+	fmt.Println("                                     Test config against a local fake provider")
+	fmt.Println("  context-gateway serve --record-fixtures tests/recorded")
+	fmt.Println("                                     Save anonymized traffic as test fixtures")
+	fmt.Println("  context-gateway serve --grpc-addr 127.0.0.1:18090")
+	fmt.Println("                                     Also serve the gRPC API")
+	fmt.Println("  context-gateway validate configs/fast_setup.yaml")
+	fmt.Println("                                     Check a config before deploying it")
+	fmt.Println("  context-gateway replay --strategy simple logs/session_1/trajectory.json")
+	fmt.Println("                                     Compare a strategy on a recorded request")
 	fmt.Println("  context-gateway update             Update to latest version")
+	fmt.Println("  context-gateway tail --pipe tool_output")
+	fmt.Println("                                     Watch tool output compression live")
 	fmt.Println("  context-gateway claude_code -- -p \"fix the bug\"")
 	fmt.Println("                                     Pass -p flag through to Claude Code")
 	fmt.Println()
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/mcp"
+)
+
+// mcpTokenEnv supplies the gateway's server.admin_token to the MCP bridge.
+const mcpTokenEnv = "CONTEXT_GATEWAY_ADMIN_TOKEN"
+
+// runMCPCommand serves MCP over stdio by forwarding each message to the
+// running gateway's POST /mcp endpoint. MCP clients launch it as a stdio
+// server; stdout carries only protocol messages.
+//
+//	context-gateway mcp [--port N]
+func runMCPCommand(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	port := fs.Int("port", config.DefaultGatewayBasePort, "port of the running gateway")
+	_ = fs.Parse(args) // ExitOnError handles errors
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	forward := mcpForwarder(fmt.Sprintf("http://localhost:%d/mcp", *port), os.Getenv(mcpTokenEnv))
+	if err := mcp.ServeStdio(ctx, os.Stdin, os.Stdout, forward); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "context-gateway mcp: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mcpForwarder posts one JSON-RPC message to the gateway and returns its
+// response (nil for notifications).
+func mcpForwarder(url, token string) mcp.HandleFunc {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	return func(ctx context.Context, msg []byte) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(msg))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		// #nosec G704 -- localhost-only MCP endpoint
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("gateway not reachable at %s: %w", url, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, mcp.MaxMessageSize*8))
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return body, nil
+		case http.StatusAccepted:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("POST /mcp: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		}
+	}
+}
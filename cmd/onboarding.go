@@ -15,7 +15,9 @@ import (
 	"github.com/compresr/context-gateway/internal/auth"
 	"github.com/compresr/context-gateway/internal/compresr"
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/doctor"
 	"github.com/compresr/context-gateway/internal/tui"
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // CredentialScope determines where credentials are persisted.
@@ -236,14 +238,12 @@ func runCompresrReauth() bool {
 
 // resetCompresrAPIKey removes the existing API key and re-runs onboarding.
 func resetCompresrAPIKey() bool {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+	envPath := userdir.Path(".env")
+	if envPath == "" {
 		printError("Could not determine home directory")
 		return false
 	}
 
-	envPath := filepath.Join(homeDir, ".config", "context-gateway", ".env")
-
 	// Remove the key from global .env
 	removeCredentialFromEnvFile(envPath, compresrAPIKeyEnvVar)
 
@@ -544,30 +544,7 @@ func isSlackHookInstalled() bool {
 	if err != nil {
 		return false
 	}
-
-	hookScript := filepath.Join(homeDir, ".claude", "hooks", "slack-notify.sh")
-	_, statErr := os.Stat(hookScript)
-	if os.IsNotExist(statErr) {
-		return false
-	}
-
-	settingsPath := filepath.Join(homeDir, ".claude", "settings.json")
-	data, err := os.ReadFile(settingsPath) // #nosec G304 -- known settings path
-	if err != nil {
-		return false
-	}
-
-	var settings map[string]interface{}
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return false
-	}
-
-	hooks, ok := settings["hooks"].(map[string]interface{})
-	if !ok {
-		return false
-	}
-
-	return hookExists(hooks, "Stop", hookScript) && hookExists(hooks, "Notification", hookScript)
+	return doctor.SlackHookInstalled(filepath.Join(homeDir, ".claude"))
 }
 
 // =============================================================================
@@ -583,12 +560,11 @@ func persistCredential(key, value string, scope CredentialScope) {
 	case ScopeProject:
 		appendToEnvFile(".env", key, value)
 	case ScopeGlobal:
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
+		globalEnv := userdir.Path(".env")
+		if globalEnv == "" {
 			printWarn("Could not determine home directory, credential not persisted")
 			return
 		}
-		globalEnv := filepath.Join(homeDir, ".config", "context-gateway", ".env")
 		appendToEnvFile(globalEnv, key, value)
 	}
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/replay"
+)
+
+// runReplayCommand rebuilds a recorded request from session logs, runs it
+// through the compression pipes again and prints token counts and a diff of
+// the bodies. With --strategy the configured run is compared against the
+// tool_output pipe using that strategy; without it, against the original.
+//
+//	context-gateway replay [--config FILE] [--strategy NAME] [--request ID] [--all] [--path PATH] [--no-diff] FILE|DIR
+func runReplayCommand(args []string) {
+	loadEnvFiles()
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "", "gateway config (default: the one serve would load)")
+	strategy := fs.String("strategy", "", "also run tool_output with this strategy and diff against the configured run")
+	requestID := fs.String("request", "", "request to replay: a request_id, step-N, or the file name (default: the last one)")
+	all := fs.Bool("all", false, "replay every request in the file")
+	path := fs.String("path", "", "request path for captured bodies, e.g. /v1/chat/completions (default: "+replay.AnthropicPath+")")
+	noDiff := fs.Bool("no-diff", false, "print token counts only")
+	debug := fs.Bool("debug", false, "show pipe logs")
+	_ = fs.Parse(args) // ExitOnError handles errors
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: context-gateway replay [flags] trajectory.json|tool_output_compression.jsonl|request.json|SESSION_DIR")
+		os.Exit(2)
+	}
+
+	if *debug {
+		setupLogging(true, os.Stderr)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+
+	data, _, err := resolveServeConfig(*configPath)
+	if err != nil {
+		replayFail(err)
+	}
+	cfg, err := config.LoadFromBytes(data)
+	if err != nil {
+		replayFail(err)
+	}
+	var alt *config.Config
+	if *strategy != "" {
+		if alt, err = replay.WithStrategy(cfg, *strategy); err != nil {
+			replayFail(fmt.Errorf("--strategy %s: %w", *strategy, err))
+		}
+	}
+
+	reqs, err := replay.Load(fs.Arg(0))
+	if err != nil {
+		replayFail(err)
+	}
+	reqs, err = selectReplayRequests(reqs, *requestID, *all)
+	if err != nil {
+		replayFail(err)
+	}
+
+	// Strategies that call the provider with the client's key get it from the environment.
+	auth := authtypes.CapturedAuth{Token: os.Getenv("ANTHROPIC_API_KEY"), IsXAPIKey: true}
+	ctx := context.Background()
+	for i, req := range reqs {
+		if *path != "" {
+			req.Path = *path
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := replayOne(ctx, os.Stdout, cfg, alt, *strategy, req, auth, !*noDiff); err != nil {
+			replayFail(fmt.Errorf("%s: %w", req.ID, err))
+		}
+	}
+}
+
+// replayOne runs req with cfg (and alt, when set) and prints the comparison.
+func replayOne(ctx context.Context, w io.Writer, cfg, alt *config.Config, strategy string, req replay.Request, auth authtypes.CapturedAuth, showDiff bool) error {
+	original := replay.Original(req)
+	configured, err := replay.Run(ctx, cfg, req, auth)
+	if err != nil {
+		return err
+	}
+	configuredName := "configured (" + cfg.Pipes.ToolOutput.Strategy + ")"
+
+	fmt.Fprintf(w, "%s\n", req.ID)
+	fmt.Fprintf(w, "  %-32s %8d tokens\n", "original", original.Tokens)
+	fmt.Fprintf(w, "  %-32s %s\n", configuredName, replayTokens(configured, original.Tokens))
+
+	base, baseName, other, otherName := original, "original", configured, configuredName
+	if alt != nil {
+		altRes, err := replay.Run(ctx, alt, req, auth)
+		if err != nil {
+			return fmt.Errorf("--strategy %s: %w", strategy, err)
+		}
+		altName := "strategy " + strategy
+		fmt.Fprintf(w, "  %-32s %s\n", altName, replayTokens(altRes, original.Tokens))
+		base, baseName, other, otherName = configured, configuredName, altRes, altName
+	}
+
+	if !showDiff {
+		return nil
+	}
+	diff := replay.Diff(base.Body, other.Body, baseName, otherName)
+	if diff == "" {
+		fmt.Fprintf(w, "\n  %s and %s bodies are identical\n", baseName, otherName)
+		return nil
+	}
+	fmt.Fprintf(w, "\n%s", diff)
+	return nil
+}
+
+// replayTokens formats a run's token count and its change from the original.
+func replayTokens(r replay.Result, original int) string {
+	saved := 0.0
+	if original > 0 {
+		saved = 100 * float64(original-r.Tokens) / float64(original)
+	}
+	return fmt.Sprintf("%8d tokens  %5.1f%% saved  %d tool output(s) compressed", r.Tokens, saved, r.Compressed)
+}
+
+// selectReplayRequests picks the request named id, every request with all,
+// or the last one.
+func selectReplayRequests(reqs []replay.Request, id string, all bool) ([]replay.Request, error) {
+	switch {
+	case id != "":
+		for _, r := range reqs {
+			if r.ID == id {
+				return []replay.Request{r}, nil
+			}
+		}
+		ids := make([]string, 0, len(reqs))
+		for _, r := range reqs {
+			ids = append(ids, r.ID)
+		}
+		return nil, fmt.Errorf("no request %q (have: %s)", id, strings.Join(ids, ", "))
+	case all:
+		return reqs, nil
+	default:
+		return reqs[len(reqs)-1:], nil
+	}
+}
+
+func replayFail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
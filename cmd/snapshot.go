@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+// snapshotPassphraseEnv supplies the snapshot passphrase non-interactively.
+const snapshotPassphraseEnv = "CONTEXT_GATEWAY_SNAPSHOT_PASSPHRASE"
+
+// runSnapshotCommand saves a running gateway's in-memory state to an
+// encrypted file, or restores such a file into a running gateway.
+//
+//	context-gateway snapshot save    [--port N] [--file FILE] [--passphrase-file FILE]
+//	context-gateway snapshot restore [--port N] [--file FILE] [--passphrase-file FILE]
+func runSnapshotCommand(args []string) {
+	if len(args) == 0 || (args[0] != "save" && args[0] != "restore") {
+		fmt.Fprintln(os.Stderr, "Usage: context-gateway snapshot save|restore [--port N] [--file FILE] [--passphrase-file FILE]")
+		os.Exit(2)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("snapshot "+action, flag.ExitOnError)
+	port := fs.Int("port", config.DefaultGatewayBasePort, "port of the running gateway")
+	file := fs.String("file", "", "snapshot file (default: state directory/"+statedir.SnapshotFile+")")
+	passFile := fs.String("passphrase-file", "", "read the passphrase from FILE (default: $"+snapshotPassphraseEnv+" or prompt)")
+	_ = fs.Parse(args[1:]) // ExitOnError handles errors
+
+	path := *file
+	if path == "" {
+		dir, err := statedir.DefaultDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		path = filepath.Join(dir, statedir.SnapshotFile)
+	}
+
+	passphrase, err := snapshotPassphrase(*passFile, action == "save")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	stateURL := fmt.Sprintf("http://localhost:%d/admin/state", *port)
+	if action == "save" {
+		err = saveSnapshot(stateURL, path, passphrase)
+	} else {
+		err = restoreSnapshot(stateURL, path, passphrase)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// saveSnapshot fetches the gateway state and writes it encrypted to path.
+func saveSnapshot(stateURL, path, passphrase string) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	// #nosec G107,G704 -- localhost-only admin endpoint
+	resp, err := client.Get(stateURL)
+	if err != nil {
+		return fmt.Errorf("gateway not reachable at %s: %w", stateURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read state: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	sealed, err := statedir.SealSnapshot(body, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil { // #nosec G301
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Snapshot saved to %s (%d bytes)", path, len(sealed)))
+	return nil
+}
+
+// restoreSnapshot decrypts path and loads it into the running gateway.
+func restoreSnapshot(stateURL, path, passphrase string) error {
+	sealed, err := os.ReadFile(path) // #nosec G304 -- user-specified snapshot path
+	if err != nil {
+		return err
+	}
+	plaintext, err := statedir.OpenSnapshot(sealed, passphrase)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	// #nosec G107,G704 -- localhost-only admin endpoint
+	resp, err := client.Post(stateURL, "application/json", bytes.NewReader(plaintext))
+	if err != nil {
+		return fmt.Errorf("gateway not reachable at %s: %w", stateURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	printSuccess("Snapshot restored from " + path)
+	fmt.Println(strings.TrimSpace(string(body)))
+	return nil
+}
+
+// snapshotPassphrase reads the passphrase from passFile, the environment, or
+// an interactive prompt (confirmed when saving).
+func snapshotPassphrase(passFile string, confirm bool) (string, error) {
+	if passFile != "" {
+		data, err := os.ReadFile(passFile) // #nosec G304 -- user-specified passphrase file
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if p := os.Getenv(snapshotPassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	fd := int(os.Stdin.Fd()) // #nosec G115 -- fd value is always a small non-negative integer
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no passphrase: set %s or use --passphrase-file", snapshotPassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "Snapshot passphrase: ")
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(p, again) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	if len(p) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	return string(p), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+// prepareState opens (and migrates) the versioned state directory before the
+// gateway starts. With reset, the existing directory is moved aside first.
+// Exits the process when the state was written by a newer binary.
+func prepareState(reset bool) {
+	dir, err := statedir.DefaultDir()
+	if err != nil {
+		log.Warn().Err(err).Msg("state directory unavailable, persisted state disabled")
+		return
+	}
+
+	if reset {
+		backup, err := statedir.Reset(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if backup != "" {
+			printInfo(fmt.Sprintf("Previous state moved to %s", backup))
+		}
+	}
+
+	if _, err := statedir.Open(dir, Version); err != nil {
+		if errors.Is(err, statedir.ErrStateTooNew) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		log.Fatal().Err(err).Str("dir", dir).Msg("failed to prepare state directory")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+// gatewayStats is the subset of GET /stats shown by `context-gateway stats`.
+type gatewayStats struct {
+	Uptime      string                     `json:"uptime"`
+	SelfMetrics *gateway.SelfMetricsReport `json:"self_metrics,omitempty"`
+}
+
+// runStatsCommand prints since-start and lifetime numbers of a running
+// gateway, or the persisted lifetime numbers alone when none is running.
+//
+//	context-gateway stats [--port N] [--json]
+func runStatsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	port := fs.Int("port", config.DefaultGatewayBasePort, "port of the running gateway")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	_ = fs.Parse(args) // ExitOnError handles errors
+
+	live, liveErr := fetchGatewayStats(*port)
+	if live != nil && live.SelfMetrics == nil {
+		live, liveErr = nil, fmt.Errorf("gateway has no state directory")
+	}
+	var lifetime statedir.SelfMetrics
+	if live != nil {
+		lifetime = live.SelfMetrics.Lifetime
+	} else if dir, err := statedir.DefaultDir(); err == nil {
+		if lifetime, err = statedir.ReadSelfMetrics(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *asJSON {
+		out := map[string]any{"lifetime": lifetime}
+		if live != nil {
+			since := live.SelfMetrics.SinceStart
+			out["since_start"] = map[string]any{
+				"uptime":         live.Uptime,
+				"requests":       since.Requests,
+				"tokens_saved":   since.TokensSaved,
+				"cost_saved_usd": since.CostSavedUSD,
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+		return
+	}
+
+	if live == nil {
+		printInfo(fmt.Sprintf("Gateway not running on port %d (%v); showing persisted lifetime totals", *port, liveErr))
+		fmt.Println()
+	}
+	lifeHeader := "Lifetime"
+	if !lifetime.Since.IsZero() {
+		lifeHeader = fmt.Sprintf("Lifetime (since %s, %d starts)", lifetime.Since.Local().Format(time.DateOnly), lifetime.Starts)
+	}
+	if live != nil {
+		since := live.SelfMetrics.SinceStart
+		fmt.Printf("%-14s %-22s %s\n", "", "Since start ("+live.Uptime+")", lifeHeader)
+		fmt.Printf("%-14s %-22d %d\n", "Requests", since.Requests, lifetime.Requests)
+		fmt.Printf("%-14s %-22s %s\n", "Tokens saved", humanCount(since.TokensSaved), humanCount(lifetime.TokensSaved))
+		fmt.Printf("%-14s %-22s %s\n", "Cost saved", fmt.Sprintf("$%.2f", since.CostSavedUSD), fmt.Sprintf("$%.2f", lifetime.CostSavedUSD))
+		return
+	}
+	fmt.Printf("%-14s %s\n", "", lifeHeader)
+	fmt.Printf("%-14s %d\n", "Requests", lifetime.Requests)
+	fmt.Printf("%-14s %s\n", "Tokens saved", humanCount(lifetime.TokensSaved))
+	fmt.Printf("%-14s $%.2f\n", "Cost saved", lifetime.CostSavedUSD)
+}
+
+// fetchGatewayStats reads GET /stats from the gateway on port.
+func fetchGatewayStats(port int) (*gatewayStats, error) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	// #nosec G107,G704 -- localhost-only stats endpoint
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/stats", port))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /stats: HTTP %d", resp.StatusCode)
+	}
+	var st gatewayStats
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("GET /stats: %w", err)
+	}
+	return &st, nil
+}
+
+// lifetimeSavingsLine summarizes persisted lifetime savings for banners.
+// Returns "" when nothing has been saved yet or the state is unreadable.
+func lifetimeSavingsLine() string {
+	dir, err := statedir.DefaultDir()
+	if err != nil {
+		return ""
+	}
+	m, err := statedir.ReadSelfMetrics(dir)
+	if err != nil || m.TokensSaved <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Saved so far: %s tokens ($%.2f) across %d requests", humanCount(m.TokensSaved), m.CostSavedUSD, m.Requests)
+}
+
+// humanCount formats n as 950, 12.3K or 4.5M.
+func humanCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fK", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
@@ -4,7 +4,10 @@ package main
 
 import (
 	"os"
+	"os/exec"
 	"syscall"
+
+	"github.com/compresr/context-gateway/internal/utils"
 )
 
 // getSysProcAttr returns syscall attributes for detaching daemon process.
@@ -29,3 +32,18 @@ func terminateProcess(p *os.Process) error {
 func isProcessRunning(p *os.Process) bool {
 	return p.Signal(syscall.Signal(0)) == nil
 }
+
+// shellCommand runs a command line through bash.
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("bash", "-c", command) // #nosec G204,G702 -- user-selected agent command
+}
+
+// shellQuote quotes an argument for shellCommand.
+func shellQuote(arg string) string {
+	return utils.ShellQuote(arg)
+}
+
+// removeExecutable deletes the running binary; Unix allows it while running.
+func removeExecutable(path string) error {
+	return os.Remove(path)
+}
@@ -3,14 +3,28 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 	"syscall"
 )
 
+// Process creation and access flags not exported by syscall.
+const (
+	detachedProcess                = 0x00000008
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
 // getSysProcAttr returns syscall attributes for detaching daemon process.
-// On Windows, we don't need Setsid (Unix-only), return empty attributes.
+// On Windows, the daemon gets no console and its own process group, so
+// closing the launching terminal or pressing Ctrl+C there doesn't stop it.
 func getSysProcAttr() *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{}
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+		HideWindow:    true,
+	}
 }
 
 // getShutdownSignals returns signals to listen for graceful shutdown.
@@ -24,12 +38,49 @@ func terminateProcess(p *os.Process) error {
 	return p.Kill()
 }
 
-// isProcessRunning checks if a process is still alive.
-// On Windows, we try to open the process to check if it exists.
+// isProcessRunning checks if a process is still alive by asking Windows for
+// its exit code; FindProcess alone succeeds for processes that have exited.
 func isProcessRunning(p *os.Process) bool {
-	// On Windows, FindProcess always succeeds, but we can check
-	// by trying to wait with no timeout (would return immediately if dead)
-	// For simplicity, assume running if we have a valid process handle
-	_, err := os.FindProcess(p.Pid)
-	return err == nil
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(p.Pid)) // #nosec G115 -- PIDs fit in uint32
+	if err != nil {
+		return false
+	}
+	defer func() { _ = syscall.CloseHandle(h) }()
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// shellCommand runs a command line through cmd.exe, so
+// npm-installed agents (claude.cmd, codex.cmd) resolve like in a terminal.
+// The command line is passed verbatim; Go's argv quoting would mangle it.
+func shellCommand(command string) *exec.Cmd {
+	shell := os.Getenv("ComSpec")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := exec.Command(shell) // #nosec G204 -- the system command interpreter
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: fmt.Sprintf(`/d /s /c "%s"`, command)}
+	return cmd
+}
+
+// shellQuote quotes an argument for shellCommand. Inside double quotes
+// cmd.exe leaves & | < > ^ alone, and doubled quotes stay literal. It still
+// expands %VAR% there, so each % is written outside the quotes as ^%: the
+// caret makes it literal and, being part of the would-be name, keeps
+// "%PATH%" from matching a variable.
+func shellQuote(arg string) string {
+	parts := strings.Split(strings.ReplaceAll(arg, `"`, `""`), "%")
+	return `"` + strings.Join(parts, `"^%"`) + `"`
+}
+
+// removeExecutable deletes the running binary. Windows keeps a running
+// executable locked, so a detached cmd.exe deletes it once this process exits.
+func removeExecutable(path string) error {
+	cmd := shellCommand(fmt.Sprintf(`ping -n 3 127.0.0.1 >nul & del /f /q %s`, shellQuote(path)))
+	cmd.SysProcAttr.CreationFlags = syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess
+	cmd.SysProcAttr.HideWindow = true
+	return cmd.Start()
 }
@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+func TestShellQuote(t *testing.T) {
+	for _, tt := range []struct {
+		arg, want string
+	}{
+		{`plain`, `"plain"`},
+		{`C:\Program Files\agent`, `"C:\Program Files\agent"`},
+		{`a & b | c > d`, `"a & b | c > d"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{`%PATH%`, `""^%"PATH"^%""`},
+		{`100% done`, `"100"^%" done"`},
+	} {
+		if got := shellQuote(tt.arg); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// runTailCommand follows a session's telemetry and compression logs and
+// pretty-prints each entry as it is written.
+//
+//	context-gateway tail [--session ID] [--pipe NAME] [--dir DIR] [--from-start] [--no-color]
+func runTailCommand(args []string) {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	session := fs.String("session", "", "session directory name, or a session_id to filter on (default: latest session)")
+	pipe := fs.String("pipe", "", "only show one log: "+strings.Join(monitoring.TailPipeNames(), ", "))
+	logsDir := fs.String("dir", "logs", "directory holding session log directories")
+	fromStart := fs.Bool("from-start", false, "print existing entries before following")
+	noColor := fs.Bool("no-color", false, "disable colored output")
+	_ = fs.Parse(args) // ExitOnError handles errors
+
+	dir, sessionFilter := resolveTailDir(*logsDir, *session)
+
+	var pipes []string
+	if *pipe != "" {
+		pipes = []string{*pipe}
+	}
+	tailer, err := monitoring.NewTailer(dir, pipes, *fromStart)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	color := !*noColor && os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd())) // #nosec G115 -- fd value is always a small non-negative integer
+
+	fmt.Fprintf(os.Stderr, "Following %s (Ctrl+C to stop)\n", dir)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	tailer.Follow(ctx, 250*time.Millisecond, func(ev monitoring.TailEvent) {
+		if sessionFilter != "" && !strings.HasPrefix(ev.SessionID(), sessionFilter) {
+			return
+		}
+		fmt.Println(monitoring.FormatTailEvent(ev, color))
+	})
+}
+
+// resolveTailDir picks the directory to follow. session may name a directory
+// (a path, or a name under logsDir); otherwise it is returned as a session_id
+// filter for the latest session. Without any session directories the logs
+// directory itself is followed (the `serve` layout).
+func resolveTailDir(logsDir, session string) (dir, sessionFilter string) {
+	if session != "" {
+		for _, candidate := range []string{session, filepath.Join(logsDir, session)} {
+			if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+				return candidate, ""
+			}
+		}
+		sessionFilter = session
+	}
+	return latestSessionDir(logsDir), sessionFilter
+}
+
+// latestSessionDir returns the most recently modified subdirectory of logsDir,
+// or logsDir when it has none.
+func latestSessionDir(logsDir string) string {
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		return logsDir
+	}
+	latest, latestMod := logsDir, time.Time{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestMod) {
+			latest, latestMod = filepath.Join(logsDir, e.Name()), info.ModTime()
+		}
+	}
+	return latest
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // Version is read from cmd/VERSION file (single source of truth).
@@ -70,13 +71,9 @@ func getRepo() string {
 	return DefaultRepo
 }
 
-// getConfigDir returns ~/.config/context-gateway
+// getConfigDir returns the user directory (see userdir.Dir)
 func getConfigDir() string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-	return filepath.Join(homeDir, ".config", "context-gateway")
+	return userdir.Path()
 }
 
 // getVersionFile returns path to version file
@@ -123,6 +120,9 @@ func printUpdateNotification(current, latest string) {
 	fmt.Printf("%s%s━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━%s\n", colorYellow, colorBold, colorReset)
 	fmt.Printf("\n")
 	fmt.Printf("  Run: %scontext-gateway update%s\n", colorCyan, colorReset)
+	if line := lifetimeSavingsLine(); line != "" {
+		fmt.Printf("  %s\n", line)
+	}
 	fmt.Printf("\n")
 }
 
@@ -381,9 +381,8 @@ func DoUninstall() error {
 	// Remove version file
 	_ = os.Remove(getVersionFile())
 
-	// Remove binary (self-delete)
-	// On Unix, we can delete ourselves while running
-	if err := os.Remove(execPath); err != nil {
+	// Remove binary (self-delete; on Windows it goes once we exit)
+	if err := removeExecutable(execPath); err != nil {
 		return fmt.Errorf("failed to remove binary: %w", err)
 	}
 	fmt.Printf("%s[✓]%s Removed %s\n", colorGreen, colorReset, execPath)
@@ -397,10 +396,18 @@ func DoUninstall() error {
 	fmt.Printf("  Config files preserved at: %s%s%s\n", colorCyan, configDir, colorReset)
 	fmt.Printf("\n")
 	fmt.Printf("  To remove configs too:\n")
-	fmt.Printf("    %srm -rf %s%s\n", colorCyan, configDir, colorReset)
+	if runtime.GOOS == "windows" {
+		fmt.Printf("    %srmdir /s /q \"%s\"%s\n", colorCyan, configDir, colorReset)
+	} else {
+		fmt.Printf("    %srm -rf %s%s\n", colorCyan, configDir, colorReset)
+	}
 	fmt.Printf("\n")
 	fmt.Printf("  To reinstall:\n")
-	fmt.Printf("    %scurl -fsSL %s | sh%s\n", colorCyan, config.DefaultCompresrInstallURL, colorReset)
+	if runtime.GOOS == "windows" {
+		fmt.Printf("    %sirm %s | iex%s\n", colorCyan, config.DefaultCompresrWindowsInstallURL, colorReset)
+	} else {
+		fmt.Printf("    %scurl -fsSL %s | sh%s\n", colorCyan, config.DefaultCompresrInstallURL, colorReset)
+	}
 	fmt.Printf("\n")
 
 	return nil
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// runValidateCommand lints a config file and prints every issue found:
+// unset env placeholders, unknown keys, misspelled strategy and model names,
+// out-of-range thresholds and budget settings. Exits 1 on errors (or on
+// warnings with --strict), so it can gate CI.
+//
+//	context-gateway validate [--config FILE] [--strict] [FILE]
+func runValidateCommand(args []string) {
+	loadEnvFiles()
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file to check (default: the one serve would load)")
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	_ = fs.Parse(args) // ExitOnError handles errors
+	if *configPath == "" {
+		*configPath = fs.Arg(0)
+	}
+
+	data, source, err := resolveServeConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	issues := config.Lint(data)
+	errs, warns := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == config.LintError {
+			errs++
+		} else {
+			warns++
+		}
+		fmt.Printf("%s: %s: %s\n", source, issue.Severity, issue)
+	}
+
+	if len(issues) == 0 {
+		fmt.Printf("%s: OK\n", source)
+		return
+	}
+	fmt.Printf("\n%d error(s), %d warning(s)\n", errs, warns)
+	if errs > 0 || (*strict && warns > 0) {
+		os.Exit(1)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/compresr/context-gateway/internal/pipes"
 	"github.com/compresr/context-gateway/internal/preemptive"
 	"github.com/compresr/context-gateway/internal/tui"
+	"github.com/compresr/context-gateway/internal/userdir"
 	"gopkg.in/yaml.v3"
 )
 
@@ -327,8 +328,7 @@ func deleteConfig() {
 	}
 
 	// Delete the config
-	homeDir, _ := os.UserHomeDir()
-	path := filepath.Join(homeDir, ".config", "context-gateway", "configs", configName+".yaml")
+	path := userdir.Path("configs", configName+".yaml")
 	if err := os.Remove(path); err != nil {
 		fmt.Printf("%s[ERROR]%s Failed to delete: %v\n", tui.ColorRed, tui.ColorReset, err)
 	} else {
@@ -395,9 +395,9 @@ func loadConfigToState(configName string) *ConfigState {
 	var err error
 
 	// First try user config dir
-	homeDir, _ := os.UserHomeDir()
-	if homeDir != "" {
-		path := filepath.Join(homeDir, ".config", "context-gateway", "configs", configName+".yaml")
+	userDir := userdir.Path()
+	if userDir != "" {
+		path := filepath.Join(userDir, "configs", configName+".yaml")
 		data, err = os.ReadFile(path) // #nosec G304 -- trusted config path
 	}
 
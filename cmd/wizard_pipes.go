@@ -114,14 +114,17 @@ func fetchModelPricing(apiKey string, modelGroup string) *compresr.ModelPricingD
 	if err != nil {
 		errStr := err.Error()
 		if strings.Contains(errStr, "invalid API key") || strings.Contains(errStr, "401") {
-			fmt.Printf("\r\033[2K  %s✗ Invalid API key%s\n", tui.ColorRed, tui.ColorReset)
+			tui.ClearLine()
+			fmt.Printf("  %s✗ Invalid API key%s\n", tui.ColorRed, tui.ColorReset)
 		} else {
-			fmt.Printf("\r\033[2K  %s✗ Failed to fetch pricing: %s%s\n", tui.ColorRed, err.Error(), tui.ColorReset)
+			tui.ClearLine()
+			fmt.Printf("  %s✗ Failed to fetch pricing: %s%s\n", tui.ColorRed, err.Error(), tui.ColorReset)
 		}
 		return nil
 	}
 
-	fmt.Printf("\r\033[2K  %s✓%s %s%s%s tier (%.2f credits remaining)\n",
+	tui.ClearLine()
+	fmt.Printf("  %s✓%s %s%s%s tier (%.2f credits remaining)\n",
 		tui.ColorGreen, tui.ColorReset,
 		tui.ColorBold, pricing.UserTierDisplay, tui.ColorReset,
 		pricing.CreditsRemaining)
@@ -190,6 +193,7 @@ func selectToolDiscoveryStrategy(state *ConfigState) {
 		{Label: "api", Description: "Compresr API selects tools + hybrid search", Value: pipes.StrategyAPI},
 		{Label: "tool-search", Description: "LLM searches via regex pattern", Value: pipes.StrategyToolSearch},
 		{Label: "relevance", Description: "local keyword scoring", Value: pipes.StrategyRelevance},
+		{Label: "embeddings", Description: "local embedding similarity", Value: pipes.StrategyEmbeddings},
 		{Label: "passthrough", Description: "no filtering", Value: pipes.StrategyPassthrough},
 		{Label: "← Back", Value: "back"},
 	}
@@ -0,0 +1,105 @@
+# Context-Gateway Install Script for Windows
+# Usage: irm https://github.com/Compresr-ai/Context-Gateway/releases/latest/download/install.ps1 | iex
+#
+# Downloads and installs the context-gateway binary.
+# All configs and agent definitions are embedded in the binary.
+
+$ErrorActionPreference = "Stop"
+
+# Configuration
+$Repo = "Compresr-ai/Context-Gateway"
+$BinaryName = "context-gateway.exe"
+$InstallDir = if ($env:CONTEXT_GATEWAY_INSTALL_DIR) { $env:CONTEXT_GATEWAY_INSTALL_DIR } else { Join-Path $env:LOCALAPPDATA "Programs\context-gateway" }
+
+function Write-Info($msg) { Write-Host "  · $msg" -ForegroundColor Green }
+function Write-Warn($msg) { Write-Host "[WARN] $msg" -ForegroundColor Yellow }
+function Write-Err($msg) { Write-Host "[ERROR] $msg" -ForegroundColor Red; exit 1 }
+function Write-Success($msg) { Write-Host "[✓] $msg" -ForegroundColor Green }
+
+# Detect architecture
+function Get-Arch {
+    switch ($env:PROCESSOR_ARCHITECTURE) {
+        "AMD64" { return "amd64" }
+        "ARM64" { return "arm64" }
+        default { Write-Err "Unsupported architecture: $env:PROCESSOR_ARCHITECTURE" }
+    }
+}
+
+# Get latest version from GitHub
+function Get-LatestVersion {
+    $release = Invoke-RestMethod -Uri "https://api.github.com/repos/$Repo/releases/latest" -UseBasicParsing
+    if (-not $release.tag_name) { Write-Err "Failed to get latest version" }
+    return $release.tag_name
+}
+
+# Download and install binary
+function Install-Binary {
+    $arch = Get-Arch
+    $version = if ($env:VERSION) { $env:VERSION } else { Get-LatestVersion }
+
+    Write-Info "Installing Context-Gateway $version for windows/$arch..."
+    New-Item -ItemType Directory -Force -Path $InstallDir | Out-Null
+
+    $fileName = "gateway-windows-$arch.exe"
+    $url = "https://github.com/$Repo/releases/download/$version/$fileName"
+    $target = Join-Path $InstallDir $BinaryName
+    $download = "$target.download"
+
+    Write-Info "Downloading from $url..."
+    Invoke-WebRequest -Uri $url -OutFile $download -UseBasicParsing
+
+    # Verify against the release checksums
+    $sums = (Invoke-WebRequest -Uri "https://github.com/$Repo/releases/download/$version/checksums.txt" -UseBasicParsing).Content
+    $sumsText = if ($sums -is [byte[]]) { [System.Text.Encoding]::UTF8.GetString($sums) } else { $sums }
+    $expected = ($sumsText -split "`n" | Where-Object { $_ -match "\s\*?$([regex]::Escape($fileName))\s*$" } | Select-Object -First 1) -replace "\s.*$", ""
+    $actual = (Get-FileHash -Algorithm SHA256 -Path $download).Hash.ToLower()
+    if (-not $expected -or $expected.ToLower() -ne $actual) {
+        Remove-Item -Force $download
+        Write-Err "Checksum mismatch for $fileName"
+    }
+
+    # A running gateway keeps the old binary locked; move it aside first
+    if (Test-Path $target) {
+        Move-Item -Force $target "$target.old"
+    }
+    Move-Item -Force $download $target
+    Remove-Item -Force "$target.old" -ErrorAction SilentlyContinue
+
+    # compresr alias
+    Copy-Item -Force $target (Join-Path $InstallDir "compresr.exe")
+
+    Write-Success "Installed to $target"
+}
+
+# Add the install directory to the user PATH
+function Update-UserPath {
+    $userPath = [Environment]::GetEnvironmentVariable("Path", "User")
+    if (($userPath -split ";") -notcontains $InstallDir) {
+        $newPath = if ($userPath) { "$userPath;$InstallDir" } else { $InstallDir }
+        [Environment]::SetEnvironmentVariable("Path", $newPath, "User")
+        $env:Path = "$env:Path;$InstallDir"
+        Write-Info "Added $InstallDir to your PATH (open a new terminal to pick it up)"
+    }
+}
+
+# Print usage
+function Show-Usage {
+    Write-Host ""
+    Write-Host "  INSTALLATION COMPLETE" -ForegroundColor Green
+    Write-Host ""
+    Write-Host "  Run with an agent:"
+    Write-Host ""
+    Write-Host "     context-gateway -a claude_code    # Claude Code CLI" -ForegroundColor Cyan
+    Write-Host "     context-gateway -a codex          # Codex CLI" -ForegroundColor Cyan
+    Write-Host ""
+    Write-Host "  Or interactive selection:"
+    Write-Host ""
+    Write-Host "     context-gateway" -ForegroundColor Cyan
+    Write-Host ""
+    Write-Host "  Configs live in $env:APPDATA\context-gateway"
+    Write-Host ""
+}
+
+Install-Binary
+Update-UserPath
+Show-Usage
@@ -41,7 +41,7 @@ warn() { printf "${YELLOW}[WARN]${NC} %s\n" "$1"; }
 error() { printf "${RED}[ERROR]${NC} %s\n" "$1"; exit 1; }
 success() { printf "${GREEN}${BOLD}[✓]${NC} %s\n" "$1"; }
 
-# Detect OS
+# Detect OS (Git Bash/MSYS on Windows works; PowerShell users: install.ps1)
 detect_os() {
     case "$(uname -s)" in
         Linux*)     OS="linux";;
@@ -89,6 +89,7 @@ install_binary() {
     FILENAME="gateway-${OS}-${ARCH}"
     if [ "$OS" = "windows" ]; then
         FILENAME="${FILENAME}.exe"
+        BINARY_NAME="${BINARY_NAME}.exe"
     fi
     URL="https://github.com/${REPO}/releases/download/${VERSION}/${FILENAME}"
 
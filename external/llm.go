@@ -42,6 +42,11 @@ const (
 
 	// anthropicVersion is the Anthropic API version header value.
 	anthropicVersion = "2023-06-01"
+
+	// ProviderOpenAICompatible targets self-hosted OpenAI-compatible servers
+	// (Ollama, vLLM, llama.cpp, LM Studio). The API key is optional and the
+	// request uses the widely supported max_tokens field.
+	ProviderOpenAICompatible = "openai_compatible"
 )
 
 // defaultHTTPClient is shared across CallLLM calls to enable connection pooling.
@@ -56,7 +61,8 @@ var defaultHTTPClient = &http.Client{
 
 // CallLLMParams contains parameters for calling an LLM provider.
 type CallLLMParams struct {
-	// Provider overrides auto-detection. One of: "anthropic", "openai", "gemini", "bedrock".
+	// Provider overrides auto-detection. One of: "anthropic", "openai", "gemini", "bedrock",
+	// "openai_compatible".
 	// If empty, provider is detected from the Endpoint URL.
 	Provider string
 
@@ -93,7 +99,8 @@ func (p *CallLLMParams) validate() error {
 	}
 	// Bedrock uses SigV4 signing via HTTPClient transport, not an API key.
 	// OAuth uses BearerToken instead of APIKey.
-	if p.ProviderKey == "" && p.BearerAuth == "" && p.Provider != "bedrock" {
+	// Local OpenAI-compatible servers usually run without auth.
+	if p.ProviderKey == "" && p.BearerAuth == "" && p.Provider != "bedrock" && p.Provider != ProviderOpenAICompatible {
 		return fmt.Errorf("api key or bearer token required")
 	}
 	if p.Model == "" {
@@ -312,6 +319,16 @@ func buildRequestBody(provider string, params CallLLMParams) ([]byte, error) {
 				Temperature:     0.0,
 			},
 		})
+	case ProviderOpenAICompatible:
+		// Local servers predate max_completion_tokens; most only honour max_tokens.
+		return json.Marshal(&OpenAIChatRequest{
+			Model: params.Model,
+			Messages: []OpenAIMessage{
+				{Role: "system", Content: params.SystemPrompt},
+				{Role: "user", Content: params.UserPrompt},
+			},
+			MaxTokens: params.MaxTokens,
+		})
 	default: // openai — temperature omitted (o-series models reject it)
 		return json.Marshal(&OpenAIChatRequest{
 			Model: params.Model,
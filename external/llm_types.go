@@ -20,6 +20,7 @@ type OpenAIChatRequest struct {
 	Model               string          `json:"model"`
 	Messages            []OpenAIMessage `json:"messages"`
 	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"` // Legacy field, used for OpenAI-compatible servers
 	Temperature         float64         `json:"temperature,omitempty"`
 }
 
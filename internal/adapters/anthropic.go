@@ -2,6 +2,7 @@
 package adapters
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -126,18 +127,125 @@ func (a *AnthropicAdapter) ApplyToolOutput(body []byte, results []CompressedResu
 	for i := len(results) - 1; i >= 0; i-- {
 		r := results[i]
 		// Anthropic: messages[N].content[M].content where M is the tool_result block
+		// Image blocks next to the text are kept.
 		path := fmt.Sprintf("messages.%d.content.%d.content", r.MessageIndex, r.BlockIndex)
-		var err error
-		modified, err = sjson.SetBytes(modified, path, r.Compressed)
+		updated, err := setTextPreservingParts(modified, path, r.Compressed)
 		if err != nil {
 			log.Warn().Err(err).Str("path", path).Str("id", r.ID).
 				Msg("sjson set failed for tool output, skipping")
 			continue
 		}
+		modified = updated
 	}
 	return modified, nil
 }
 
+// ExtractToolImages returns base64 image blocks inside tool_result content.
+// Anthropic format: {type:"image", source:{type:"base64", media_type, data}}
+func (a *AnthropicAdapter) ExtractToolImages(body []byte) []ToolImage {
+	var images []ToolImage
+	for msgIdx, msg := range gjson.GetBytes(body, "messages").Array() {
+		if msg.Get("role").String() != "user" || !msg.Get("content").IsArray() {
+			continue
+		}
+		for blockIdx, block := range msg.Get("content").Array() {
+			if block.Get("type").String() != "tool_result" || !block.Get("content").IsArray() {
+				continue
+			}
+			for itemIdx, item := range block.Get("content").Array() {
+				if item.Get("type").String() != "image" || item.Get("source.type").String() != "base64" {
+					continue
+				}
+				data, err := base64.StdEncoding.DecodeString(item.Get("source.data").String())
+				if err != nil {
+					continue
+				}
+				source := fmt.Sprintf("messages.%d.content.%d.content.%d.source", msgIdx, blockIdx, itemIdx)
+				images = append(images, ToolImage{
+					ToolCallID:    block.Get("tool_use_id").String(),
+					MediaType:     item.Get("source.media_type").String(),
+					Data:          data,
+					dataPath:      source + ".data",
+					mediaTypePath: source + ".media_type",
+				})
+			}
+		}
+	}
+	return images
+}
+
+// ApplyToolImages writes modified tool_result images back to the request.
+func (a *AnthropicAdapter) ApplyToolImages(body []byte, images []ToolImage) ([]byte, error) {
+	return applyToolImages(body, images)
+}
+
+// ExtractMediaBlocks returns base64 image and document blocks in message
+// content and inside tool_result content.
+func (a *AnthropicAdapter) ExtractMediaBlocks(body []byte) []MediaBlock {
+	var blocks []MediaBlock
+	for msgIdx, msg := range gjson.GetBytes(body, "messages").Array() {
+		if !msg.Get("content").IsArray() {
+			continue
+		}
+		for blockIdx, block := range msg.Get("content").Array() {
+			path := fmt.Sprintf("messages.%d.content.%d", msgIdx, blockIdx)
+			if block.Get("type").String() != "tool_result" {
+				if mb, ok := anthropicMediaBlock(block, path); ok {
+					mb.MessageIndex = msgIdx
+					blocks = append(blocks, mb)
+				}
+				continue
+			}
+			for itemIdx, item := range block.Get("content").Array() {
+				if mb, ok := anthropicMediaBlock(item, fmt.Sprintf("%s.content.%d", path, itemIdx)); ok {
+					mb.MessageIndex = msgIdx
+					mb.ToolCallID = block.Get("tool_use_id").String()
+					blocks = append(blocks, mb)
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+// anthropicMediaBlock reads an image or document block with a base64 source.
+func anthropicMediaBlock(block gjson.Result, path string) (MediaBlock, bool) {
+	if t := block.Get("type").String(); t != "image" && t != "document" {
+		return MediaBlock{}, false
+	}
+	if block.Get("source.type").String() != "base64" {
+		return MediaBlock{}, false
+	}
+	data, err := base64.StdEncoding.DecodeString(block.Get("source.data").String())
+	if err != nil {
+		return MediaBlock{}, false
+	}
+	return MediaBlock{
+		MediaType:     block.Get("source.media_type").String(),
+		Data:          data,
+		Raw:           block.Raw,
+		blockPath:     path,
+		dataPath:      path + ".source.data",
+		mediaTypePath: path + ".source.media_type",
+		textType:      "text",
+	}, true
+}
+
+// ApplyMediaBlocks writes modified media blocks back to the request.
+func (a *AnthropicAdapter) ApplyMediaBlocks(body []byte, blocks []MediaBlock) ([]byte, error) {
+	return applyMediaBlocks(body, blocks)
+}
+
+// ReplaceMediaBlocks replaces media blocks with text blocks.
+func (a *AnthropicAdapter) ReplaceMediaBlocks(body []byte, blocks []MediaBlock, texts []string) ([]byte, error) {
+	return replaceMediaBlocks(body, blocks, texts)
+}
+
+// RestoreMediaBlock puts a replaced media block back in messages.
+func (a *AnthropicAdapter) RestoreMediaBlock(body []byte, marker, raw string) ([]byte, bool) {
+	return restoreMediaBlock(body, "messages", marker, raw)
+}
+
 // TOOL DISCOVERY - Extract/Apply
 
 // ExtractToolDiscovery extracts tool definitions for filtering.
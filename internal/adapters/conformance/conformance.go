@@ -0,0 +1,115 @@
+// Package conformance is the adapter conformance suite.
+//
+// Every adapter in the registry must pass Run against the canonical fixtures
+// for its provider (see Fixtures); tests/conformance enforces this, so a new
+// adapter fails CI until it ships fixtures and passes them. Authors of custom
+// adapters can call Run from their own tests with their own Cases.
+//
+// A Case pairs a canonical request with the provider's non-streaming response
+// and, optionally, the same response as an SSE stream. Run checks the
+// adapter's extractors against the expected values, checks that the
+// gateway's stream parser records the same usage as ExtractUsage, and checks
+// that every extractor tolerates empty and malformed input.
+package conformance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// Case is one canonical request/response exchange.
+type Case struct {
+	Name string
+
+	Request  []byte // Request body as the client sends it
+	Response []byte // Non-streaming response body
+	Stream   []byte // Same response as an SSE stream (nil = provider has no SSE form)
+
+	WantModel      string
+	WantUserQuery  string
+	WantUsage      adapters.UsageInfo
+	WantToolCalls  []string // Tool names in Response, in order
+	WantTurnSignal adapters.TurnSignal
+
+	// WantStreamUsage overrides WantUsage for Stream when the provider's
+	// streaming usage shape differs from its non-streaming one.
+	WantStreamUsage *adapters.UsageInfo
+	// WantStreamStopReason is the stop reason the gateway should find in Stream.
+	WantStreamStopReason string
+}
+
+// Run checks adapter against cases, then against malformed input.
+func Run(t *testing.T, adapter adapters.Adapter, cases []Case) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.WantModel, adapter.ExtractModel(tc.Request), "ExtractModel")
+			assert.Equal(t, tc.WantUserQuery, adapter.ExtractUserQuery(tc.Request), "ExtractUserQuery")
+			assert.Equal(t, tc.WantUsage, adapter.ExtractUsage(tc.Response), "ExtractUsage")
+			assert.Equal(t, tc.WantTurnSignal, adapter.ExtractTurnSignal(tc.Response, ""), "ExtractTurnSignal")
+
+			calls, err := adapter.ExtractToolCallsFromResponse(tc.Response)
+			assert.NoError(t, err, "ExtractToolCallsFromResponse")
+			names := make([]string, 0, len(calls))
+			for _, c := range calls {
+				names = append(names, c.ToolName)
+			}
+			if len(tc.WantToolCalls) == 0 {
+				assert.Empty(t, names, "ExtractToolCallsFromResponse")
+			} else {
+				assert.Equal(t, tc.WantToolCalls, names, "ExtractToolCallsFromResponse")
+			}
+
+			if tc.Stream == nil {
+				return
+			}
+			want := tc.WantUsage
+			if tc.WantStreamUsage != nil {
+				want = *tc.WantStreamUsage
+			}
+			usage, stopReason := gateway.ParseStreamUsage(tc.Stream)
+			assert.Equal(t, want, usage, "streaming usage")
+			assert.Equal(t, tc.WantStreamStopReason, stopReason, "streaming stop reason")
+		})
+	}
+
+	t.Run("malformed input", func(t *testing.T) {
+		RunInvariants(t, adapter)
+	})
+}
+
+// malformedBodies are inputs every extractor must survive.
+var malformedBodies = map[string][]byte{
+	"nil":          nil,
+	"empty":        {},
+	"not json":     []byte("not json"),
+	"array":        []byte(`[1,2,3]`),
+	"wrong types":  []byte(`{"model":42,"messages":"x","input":7,"contents":{},"usage":"none","choices":"x","content":3}`),
+	"empty object": []byte(`{}`),
+}
+
+// RunInvariants checks that extractors return zero values, without
+// panicking, for empty and malformed bodies.
+func RunInvariants(t *testing.T, adapter adapters.Adapter) {
+	t.Helper()
+	for name, body := range malformedBodies {
+		t.Run(name, func(t *testing.T) {
+			assert.NotPanics(t, func() {
+				assert.Empty(t, adapter.ExtractModel(body), "ExtractModel")
+				assert.Empty(t, adapter.ExtractUserQuery(body), "ExtractUserQuery")
+				assert.Equal(t, adapters.UsageInfo{}, adapter.ExtractUsage(body), "ExtractUsage")
+				calls, _ := adapter.ExtractToolCallsFromResponse(body)
+				assert.Empty(t, calls, "ExtractToolCallsFromResponse")
+				_, _ = adapter.ExtractToolOutput(body)
+				_, _ = adapter.ExtractToolDiscovery(body, nil)
+				_, _ = adapter.ExtractLastUserContent(body)
+				_ = adapter.ExtractAssistantIntent(body)
+				_ = adapter.ExtractTurnSignal(body, "")
+			})
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package conformance
+
+import (
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+)
+
+// Fixtures returns the canonical cases for a built-in adapter, by adapter name.
+// Returns nil for adapters without fixtures.
+func Fixtures(adapterName string) []Case {
+	return fixtures[adapterName]
+}
+
+// sse joins data payloads into an SSE stream. A payload starting with
+// "event:" is written as-is.
+func sse(events ...string) []byte {
+	var b strings.Builder
+	for _, e := range events {
+		if !strings.HasPrefix(e, "event:") {
+			e = "data: " + e
+		}
+		b.WriteString(e)
+		b.WriteString("\n\n")
+	}
+	return []byte(b.String())
+}
+
+// ANTHROPIC
+
+var anthropicText = Case{
+	Name: "text with prompt cache",
+	Request: []byte(`{"model":"claude-sonnet-4-5","max_tokens":1024,"system":"You are a coding agent.",` +
+		`"messages":[{"role":"user","content":"What does main.go do?"}]}`),
+	Response: []byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5",` +
+		`"content":[{"type":"text","text":"It starts the server."}],"stop_reason":"end_turn",` +
+		`"usage":{"input_tokens":12,"cache_creation_input_tokens":5,"cache_read_input_tokens":100,"output_tokens":30}}`),
+	Stream: sse(
+		"event: message_start\ndata: "+`{"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],`+
+			`"usage":{"input_tokens":12,"cache_creation_input_tokens":5,"cache_read_input_tokens":100,"output_tokens":1}}}`,
+		"event: content_block_start\ndata: "+`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"event: content_block_delta\ndata: "+`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"It starts the server."}}`,
+		"event: content_block_stop\ndata: "+`{"type":"content_block_stop","index":0}`,
+		"event: message_delta\ndata: "+`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":30}}`,
+		"event: message_stop\ndata: "+`{"type":"message_stop"}`,
+	),
+	WantModel:            "claude-sonnet-4-5",
+	WantUserQuery:        "What does main.go do?",
+	WantUsage:            adapters.UsageInfo{InputTokens: 12, OutputTokens: 30, TotalTokens: 147, CacheCreationInputTokens: 5, CacheReadInputTokens: 100},
+	WantTurnSignal:       adapters.TurnSignalHumanTurn,
+	WantStreamStopReason: "end_turn",
+}
+
+var anthropicToolUse = Case{
+	Name: "tool use",
+	Request: []byte(`{"model":"claude-opus-4-1","max_tokens":1024,"tools":[{"name":"read_file","input_schema":{"type":"object"}}],` +
+		`"messages":[{"role":"user","content":[{"type":"text","text":"Read the config file"}]}]}`),
+	Response: []byte(`{"id":"msg_02","type":"message","role":"assistant","model":"claude-opus-4-1",` +
+		`"content":[{"type":"text","text":"Reading it."},{"type":"tool_use","id":"toolu_01","name":"read_file","input":{"path":"config.yaml"}}],` +
+		`"stop_reason":"tool_use","usage":{"input_tokens":50,"output_tokens":20}}`),
+	WantModel:      "claude-opus-4-1",
+	WantUserQuery:  "Read the config file",
+	WantUsage:      adapters.UsageInfo{InputTokens: 50, OutputTokens: 20, TotalTokens: 70},
+	WantToolCalls:  []string{"read_file"},
+	WantTurnSignal: adapters.TurnSignalAgentWorking,
+}
+
+// BEDROCK (Anthropic Messages API; the model is in the URL, not the body)
+
+var bedrockText = Case{
+	Name: "text",
+	Request: []byte(`{"anthropic_version":"bedrock-2023-05-31","max_tokens":1024,` +
+		`"messages":[{"role":"user","content":"What does main.go do?"}]}`),
+	Response:       anthropicText.Response,
+	Stream:         anthropicText.Stream,
+	WantUserQuery:  "What does main.go do?",
+	WantUsage:      anthropicText.WantUsage,
+	WantTurnSignal: adapters.TurnSignalHumanTurn,
+	// Bedrock streams over its own event-stream framing; the gateway sees the
+	// decoded Anthropic events.
+	WantStreamStopReason: "end_turn",
+}
+
+// OPENAI
+
+var openAIChat = Case{
+	Name: "chat completions with cached prompt",
+	Request: []byte(`{"model":"gpt-4o","stream_options":{"include_usage":true},"messages":[` +
+		`{"role":"system","content":"You are a coding agent."},{"role":"user","content":"Summarize the README"}]}`),
+	Response: []byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o",` +
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"It documents setup."},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":100,"completion_tokens":20,"total_tokens":120,"prompt_tokens_details":{"cached_tokens":40}}}`),
+	Stream: sse(
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"It documents "}}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"setup."},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[],`+
+			`"usage":{"prompt_tokens":100,"completion_tokens":20,"total_tokens":120,"prompt_tokens_details":{"cached_tokens":40}}}`,
+		"[DONE]",
+	),
+	WantModel:            "gpt-4o",
+	WantUserQuery:        "Summarize the README",
+	WantUsage:            adapters.UsageInfo{InputTokens: 60, OutputTokens: 20, TotalTokens: 120, CacheReadInputTokens: 40},
+	WantTurnSignal:       adapters.TurnSignalHumanTurn,
+	WantStreamStopReason: "stop",
+}
+
+var openAIToolCalls = Case{
+	Name: "chat completions tool calls",
+	Request: []byte(`{"model":"gpt-4.1","tools":[{"type":"function","function":{"name":"read_file"}}],` +
+		`"messages":[{"role":"user","content":"Open main.go"}]}`),
+	Response: []byte(`{"id":"chatcmpl-2","object":"chat.completion","model":"gpt-4.1","choices":[{"index":0,"message":{"role":"assistant","content":null,` +
+		`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}}]},"finish_reason":"tool_calls"}],` +
+		`"usage":{"prompt_tokens":70,"completion_tokens":15,"total_tokens":85}}`),
+	WantModel:      "gpt-4.1",
+	WantUserQuery:  "Open main.go",
+	WantUsage:      adapters.UsageInfo{InputTokens: 70, OutputTokens: 15, TotalTokens: 85},
+	WantToolCalls:  []string{"read_file"},
+	WantTurnSignal: adapters.TurnSignalAgentWorking,
+}
+
+var openAIResponses = Case{
+	Name:    "responses api",
+	Request: []byte(`{"model":"gpt-5-codex","instructions":"You are Codex.","input":[{"role":"user","content":"List the files"}]}`),
+	Response: []byte(`{"id":"resp_1","object":"response","model":"gpt-5-codex","status":"completed",` +
+		`"output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"main.go, go.mod"}]}],` +
+		`"usage":{"input_tokens":80,"output_tokens":10,"total_tokens":90,"input_tokens_details":{"cached_tokens":30}}}`),
+	Stream: sse(
+		"event: response.created\ndata: "+`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`,
+		"event: response.output_text.delta\ndata: "+`{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"main.go, go.mod"}`,
+		"event: response.completed\ndata: "+`{"type":"response.completed","response":{"id":"resp_1","status":"completed",`+
+			`"output":[{"type":"message"}],"usage":{"input_tokens":80,"output_tokens":10,"total_tokens":90,"input_tokens_details":{"cached_tokens":30}}}}`,
+	),
+	WantModel:            "gpt-5-codex",
+	WantUserQuery:        "List the files",
+	WantUsage:            adapters.UsageInfo{InputTokens: 50, OutputTokens: 10, TotalTokens: 90, CacheReadInputTokens: 30},
+	WantTurnSignal:       adapters.TurnSignalUnknown,
+	WantStreamStopReason: "stop",
+}
+
+// GEMINI
+
+var geminiText = Case{
+	Name:    "generate content with context cache",
+	Request: []byte(`{"model":"models/gemini-2.5-pro","contents":[{"role":"user","parts":[{"text":"Explain this error"}]}]}`),
+	Response: []byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"The file is missing."}]},"finishReason":"STOP"}],` +
+		`"usageMetadata":{"promptTokenCount":200,"candidatesTokenCount":40,"totalTokenCount":240,"cachedContentTokenCount":50}}`),
+	Stream: sse(
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"The file "}]}}],"usageMetadata":{"promptTokenCount":200,"candidatesTokenCount":3,"cachedContentTokenCount":50}}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"is missing."}]},"finishReason":"STOP"}],`+
+			`"usageMetadata":{"promptTokenCount":200,"candidatesTokenCount":40,"totalTokenCount":240,"cachedContentTokenCount":50}}`,
+	),
+	WantModel:            "gemini-2.5-pro",
+	WantUserQuery:        "Explain this error",
+	WantUsage:            adapters.UsageInfo{InputTokens: 150, OutputTokens: 40, TotalTokens: 240, CacheReadInputTokens: 50},
+	WantTurnSignal:       adapters.TurnSignalHumanTurn,
+	WantStreamStopReason: "STOP",
+}
+
+var geminiFunctionCall = Case{
+	Name:    "function call",
+	Request: []byte(`{"contents":[{"role":"user","parts":[{"text":"What's the weather in Paris?"}]}],"tools":[{"functionDeclarations":[{"name":"get_weather"}]}]}`),
+	Response: []byte(`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],` +
+		`"usageMetadata":{"promptTokenCount":30,"candidatesTokenCount":8,"totalTokenCount":38}}`),
+	WantUserQuery: "What's the weather in Paris?",
+	WantUsage:     adapters.UsageInfo{InputTokens: 30, OutputTokens: 8, TotalTokens: 38},
+	// Phantom tools are not supported for Gemini, so no calls are extracted;
+	// the turn signal still reports the function call.
+	WantTurnSignal: adapters.TurnSignalAgentWorking,
+}
+
+// OPENAI-COMPATIBLE PROVIDERS
+
+var ollamaNative = Case{
+	Name:    "native chat",
+	Request: []byte(`{"model":"llama3.2","messages":[{"role":"user","content":"Why is the sky blue?"}]}`),
+	Response: []byte(`{"model":"llama3.2","message":{"role":"assistant","content":"Rayleigh scattering."},"done":true,` +
+		`"done_reason":"stop","prompt_eval_count":26,"eval_count":12}`),
+	// Ollama's OpenAI-compatible endpoint streams Chat Completions chunks.
+	Stream: sse(
+		`{"id":"chatcmpl-9","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Rayleigh scattering."},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-9","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":26,"completion_tokens":12,"total_tokens":38}}`,
+		"[DONE]",
+	),
+	WantModel:            "llama3.2",
+	WantUserQuery:        "Why is the sky blue?",
+	WantUsage:            adapters.UsageInfo{InputTokens: 26, OutputTokens: 12, TotalTokens: 38},
+	WantTurnSignal:       adapters.TurnSignalUnknown,
+	WantStreamStopReason: "stop",
+}
+
+var liteLLMAnthropicBackend = Case{
+	Name:    "chat completions proxying a cached Anthropic backend",
+	Request: []byte(`{"model":"anthropic/claude-sonnet-4-5","messages":[{"role":"user","content":"Refactor utils.go"}]}`),
+	Response: []byte(`{"id":"chatcmpl-3","object":"chat.completion","model":"claude-sonnet-4-5",` +
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"Done."},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":500,"completion_tokens":25,"total_tokens":525,"cache_creation_input_tokens":100,"prompt_tokens_details":{"cached_tokens":300}}}`),
+	Stream: sse(
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Done."},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[],`+
+			`"usage":{"prompt_tokens":500,"completion_tokens":25,"total_tokens":525,"cache_creation_input_tokens":100,"prompt_tokens_details":{"cached_tokens":300}}}`,
+		"[DONE]",
+	),
+	WantModel:      "claude-sonnet-4-5", // Provider prefix stripped
+	WantUserQuery:  "Refactor utils.go",
+	WantUsage:      adapters.UsageInfo{InputTokens: 200, OutputTokens: 25, TotalTokens: 525, CacheCreationInputTokens: 100, CacheReadInputTokens: 300},
+	WantTurnSignal: adapters.TurnSignalHumanTurn,
+	// The stream parser sums the parts; the provider's total_tokens counts
+	// cache writes inside prompt_tokens.
+	WantStreamUsage:      &adapters.UsageInfo{InputTokens: 200, OutputTokens: 25, TotalTokens: 625, CacheCreationInputTokens: 100, CacheReadInputTokens: 300},
+	WantStreamStopReason: "stop",
+}
+
+var miniMaxChat = Case{
+	Name:    "chat completions",
+	Request: []byte(`{"model":"MiniMax-M2","messages":[{"role":"user","content":"Write a haiku"}]}`),
+	Response: []byte(`{"id":"mm-1","object":"chat.completion","model":"MiniMax-M2",` +
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"..."},"finish_reason":"length"}],` +
+		`"usage":{"prompt_tokens":9,"completion_tokens":64,"total_tokens":73}}`),
+	WantModel:      "MiniMax-M2",
+	WantUserQuery:  "Write a haiku",
+	WantUsage:      adapters.UsageInfo{InputTokens: 9, OutputTokens: 64, TotalTokens: 73},
+	WantTurnSignal: adapters.TurnSignalTruncated,
+}
+
+var openRouterChat = Case{
+	Name:    "chat completions with credits",
+	Request: []byte(`{"model":"anthropic/claude-3.5-sonnet:beta","messages":[{"role":"user","content":"Summarize the diff"}],"usage":{"include":true}}`),
+	Response: []byte(`{"id":"gen-1","object":"chat.completion","model":"anthropic/claude-3.5-sonnet",` +
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"Two files changed."},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":1200,"completion_tokens":50,"total_tokens":1250,"cost":0.00123,"prompt_tokens_details":{"cached_tokens":1000}}}`),
+	Stream: sse(
+		`{"id":"gen-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Two files changed."},"finish_reason":"stop"}]}`,
+		`{"id":"gen-1","object":"chat.completion.chunk","choices":[],`+
+			`"usage":{"prompt_tokens":1200,"completion_tokens":50,"total_tokens":1250,"cost":0.00123,"prompt_tokens_details":{"cached_tokens":1000}}}`,
+		"[DONE]",
+	),
+	WantModel:            "claude-3-5-sonnet", // OpenRouter id mapped to the native name
+	WantUserQuery:        "Summarize the diff",
+	WantUsage:            adapters.UsageInfo{InputTokens: 200, OutputTokens: 50, TotalTokens: 1250, CacheReadInputTokens: 1000, CostUSD: 0.00123},
+	WantTurnSignal:       adapters.TurnSignalHumanTurn,
+	WantStreamStopReason: "stop",
+}
+
+var fixtures = map[string][]Case{
+	"anthropic":  {anthropicText, anthropicToolUse},
+	"bedrock":    {bedrockText},
+	"openai":     {openAIChat, openAIToolCalls, openAIResponses},
+	"gemini":     {geminiText, geminiFunctionCall},
+	"ollama":     {ollamaNative},
+	"litellm":    {liteLLMAnthropicBackend},
+	"minimax":    {miniMaxChat},
+	"openrouter": {openRouterChat},
+}
@@ -2,6 +2,7 @@
 package adapters
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -89,6 +90,7 @@ func (a *GeminiAdapter) ExtractToolOutput(body []byte) ([]ExtractedContent, erro
 					ToolName:     name,
 					MessageIndex: msgIdx,
 					BlockIndex:   partIdx,
+					Format:       DetectContentFormat(respContent),
 				})
 			}
 		}
@@ -155,7 +157,7 @@ func (a *GeminiAdapter) ExtractLastUserContent(body []byte) ([]string, bool) {
 		if !ok {
 			continue
 		}
-		if getString(content, "role") != "user" {
+		if !isGeminiUserContent(content) {
 			continue
 		}
 
@@ -189,7 +191,8 @@ func (a *GeminiAdapter) ExtractLastUserContent(body []byte) ([]string, bool) {
 // QUERY EXTRACTION
 
 // ExtractUserQuery extracts the last user message content from Gemini format.
-// Looks for contents[] with role:"user" and text parts, skipping functionResponse parts.
+// Looks for contents[] with role:"user" (or no role) and text parts, skipping
+// functionResponse parts.
 func (a *GeminiAdapter) ExtractUserQuery(body []byte) string {
 	var req map[string]any
 	if err := json.Unmarshal(body, &req); err != nil {
@@ -208,7 +211,7 @@ func (a *GeminiAdapter) ExtractUserQuery(body []byte) string {
 			continue
 		}
 
-		if getString(content, "role") != "user" {
+		if !isGeminiUserContent(content) {
 			continue
 		}
 
@@ -243,43 +246,83 @@ func (a *GeminiAdapter) ExtractUserQuery(body []byte) string {
 // Gemini format: {"usageMetadata": {"promptTokenCount": N, "candidatesTokenCount": N, "totalTokenCount": N, "cachedContentTokenCount": N}}
 // cachedContentTokenCount is a subset of promptTokenCount (tokens served from context cache).
 func (a *GeminiAdapter) ExtractUsage(responseBody []byte) UsageInfo {
-	if len(responseBody) == 0 {
+	raw := geminiUsageMetadata(responseBody)
+	if raw == "" {
 		return UsageInfo{}
 	}
 
-	var resp struct {
-		UsageMetadata struct {
-			PromptTokenCount        int `json:"promptTokenCount"`
-			CandidatesTokenCount    int `json:"candidatesTokenCount"`
-			TotalTokenCount         int `json:"totalTokenCount"`
-			CachedContentTokenCount int `json:"cachedContentTokenCount"`
-		} `json:"usageMetadata"`
+	var meta struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		TotalTokenCount         int `json:"totalTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
 	}
-	if err := json.Unmarshal(responseBody, &resp); err != nil {
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
 		return UsageInfo{}
 	}
 
 	// cachedContentTokenCount is a subset of promptTokenCount (served from cache).
 	// Subtract it so InputTokens represents only non-cached input, consistent with
 	// how Anthropic and OpenAI adapters normalize their cache fields.
-	nonCachedInput := resp.UsageMetadata.PromptTokenCount - resp.UsageMetadata.CachedContentTokenCount
+	nonCachedInput := meta.PromptTokenCount - meta.CachedContentTokenCount
 	if nonCachedInput < 0 {
 		nonCachedInput = 0
 	}
 
 	return UsageInfo{
 		InputTokens:          nonCachedInput,
-		OutputTokens:         resp.UsageMetadata.CandidatesTokenCount,
-		TotalTokens:          resp.UsageMetadata.TotalTokenCount,
-		CacheReadInputTokens: resp.UsageMetadata.CachedContentTokenCount,
+		OutputTokens:         meta.CandidatesTokenCount,
+		TotalTokens:          meta.TotalTokenCount,
+		CacheReadInputTokens: meta.CachedContentTokenCount,
 	}
 }
 
+// geminiUsageMetadata returns the raw usageMetadata object of a generateContent
+// response, or "" if there is none. streamGenerateContent responses carry no
+// "stream" flag, so they reach ExtractUsage whole: a JSON array of chunks by
+// default, or SSE "data:" lines with alt=sse. Every chunk repeats the running
+// usage, so the last one wins.
+func geminiUsageMetadata(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return ""
+	}
+	var last string
+	switch {
+	case body[0] == '[':
+		if !gjson.ValidBytes(body) {
+			return ""
+		}
+		gjson.ParseBytes(body).ForEach(func(_, chunk gjson.Result) bool {
+			if u := chunk.Get("usageMetadata"); u.IsObject() {
+				last = u.Raw
+			}
+			return true
+		})
+	case bytes.HasPrefix(body, []byte("data:")):
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+			if !ok {
+				continue
+			}
+			if u := gjson.GetBytes(payload, "usageMetadata"); u.IsObject() {
+				last = u.Raw
+			}
+		}
+	default:
+		if u := gjson.GetBytes(body, "usageMetadata"); gjson.ValidBytes(body) && u.IsObject() {
+			last = u.Raw
+		}
+	}
+	return last
+}
+
 // MODEL EXTRACTION
 
 // ExtractModel extracts the model name from Gemini request body.
 // Gemini typically puts the model in the URL path (/models/{model}:generateContent),
 // not in the request body. Some clients may include a "model" field in the body.
+// Use ExtractGeminiModelFromPath for the URL.
 func (a *GeminiAdapter) ExtractModel(requestBody []byte) string {
 	if len(requestBody) == 0 {
 		return ""
@@ -299,8 +342,38 @@ func (a *GeminiAdapter) ExtractModel(requestBody []byte) string {
 	return req.Model
 }
 
+// ExtractGeminiModelFromPath extracts the model from a native Gemini API path.
+// Path format: /v1beta/models/{model}:generateContent or :streamGenerateContent
+// Example: /v1beta/models/gemini-2.5-pro:streamGenerateContent -> gemini-2.5-pro
+func ExtractGeminiModelFromPath(path string) string {
+	const prefix = "/models/"
+	idx := strings.LastIndex(path, prefix)
+	if idx == -1 {
+		return ""
+	}
+	rest := path[idx+len(prefix):]
+	if colon := strings.Index(rest, ":"); colon != -1 {
+		return rest[:colon]
+	}
+	return ""
+}
+
+// IsGeminiPath reports whether path is a native Gemini generate endpoint,
+// /models/{model}:generateContent or /models/{model}:streamGenerateContent.
+func IsGeminiPath(path string) bool {
+	return ExtractGeminiModelFromPath(path) != "" &&
+		(strings.HasSuffix(path, ":generateContent") || strings.HasSuffix(path, ":streamGenerateContent"))
+}
+
 // HELPERS
 
+// isGeminiUserContent reports whether a contents[] entry was sent by the user.
+// role may be omitted for single-turn requests, where it defaults to "user".
+func isGeminiUserContent(content map[string]any) bool {
+	role := getString(content, "role")
+	return role == "" || role == "user"
+}
+
 // extractResponseContent extracts a string from a Gemini functionResponse.response value.
 // The response field is typically a JSON object, so we serialize it.
 //
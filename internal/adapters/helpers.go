@@ -1,7 +1,14 @@
 // helpers.go contains small shared utilities used across multiple adapters.
 package adapters
 
-import "strings"
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
 
 // getString safely extracts a string value from a map by key.
 // Returns "" if the key is missing or the value is not a string.
@@ -44,3 +51,163 @@ func extractStringContent(v any) string {
 	}
 	return b.String()
 }
+
+// setTextPreservingParts writes text to the content at path. Plain string
+// content is replaced outright; in a content-parts array the text parts
+// collapse into one part carrying the new text (at the first text part's
+// position) and other parts such as images are kept, so compressing the text
+// of a mixed tool result does not drop its images.
+func setTextPreservingParts(body []byte, path, text string) ([]byte, error) {
+	current := gjson.GetBytes(body, path)
+	if !current.IsArray() {
+		return sjson.SetBytes(body, path, text)
+	}
+
+	var parts []string
+	textAt, hasOther := -1, false
+	for _, part := range current.Array() {
+		switch typ := part.Get("type").String(); typ {
+		case "text", "input_text", "output_text":
+			if textAt >= 0 {
+				continue
+			}
+			textPart, err := sjson.Set(`{"type":""}`, "type", typ)
+			if err == nil {
+				textPart, err = sjson.Set(textPart, "text", text)
+			}
+			if err != nil {
+				return nil, err
+			}
+			textAt = len(parts)
+			parts = append(parts, textPart)
+		default:
+			hasOther = true
+			parts = append(parts, part.Raw)
+		}
+	}
+	if !hasOther {
+		return sjson.SetBytes(body, path, text)
+	}
+	if textAt < 0 {
+		return nil, fmt.Errorf("no text part at %s", path)
+	}
+	return sjson.SetRawBytes(body, path, []byte("["+strings.Join(parts, ",")+"]"))
+}
+
+// applyToolImages writes each image's data (and media type) back to the
+// locations recorded at extraction. Shared by ToolImageAdapter implementations.
+func applyToolImages(body []byte, images []ToolImage) ([]byte, error) {
+	modified := body
+	for _, img := range images {
+		if img.dataPath == "" {
+			return nil, fmt.Errorf("tool image for %q has no location", img.ToolCallID)
+		}
+		payload := base64.StdEncoding.EncodeToString(img.Data)
+		var err error
+		if img.mediaTypePath == "" {
+			payload = "data:" + img.MediaType + ";base64," + payload
+		} else if modified, err = sjson.SetBytes(modified, img.mediaTypePath, img.MediaType); err != nil {
+			return nil, err
+		}
+		if modified, err = sjson.SetBytes(modified, img.dataPath, payload); err != nil {
+			return nil, err
+		}
+	}
+	return modified, nil
+}
+
+// applyMediaBlocks writes each block's data (and media type) back to the
+// locations recorded at extraction.
+func applyMediaBlocks(body []byte, blocks []MediaBlock) ([]byte, error) {
+	images := make([]ToolImage, len(blocks))
+	for i, b := range blocks {
+		images[i] = ToolImage{
+			ToolCallID:    b.ToolCallID,
+			MediaType:     b.MediaType,
+			Data:          b.Data,
+			dataPath:      b.dataPath,
+			mediaTypePath: b.mediaTypePath,
+		}
+	}
+	return applyToolImages(body, images)
+}
+
+// replaceMediaBlocks swaps each block for a text block holding texts[i]. A
+// cache_control marker on the block is kept on its replacement.
+func replaceMediaBlocks(body []byte, blocks []MediaBlock, texts []string) ([]byte, error) {
+	if len(blocks) != len(texts) {
+		return nil, fmt.Errorf("media blocks: %d blocks but %d texts", len(blocks), len(texts))
+	}
+	modified := body
+	for i, b := range blocks {
+		if b.blockPath == "" {
+			return nil, fmt.Errorf("media block in message %d has no location", b.MessageIndex)
+		}
+		text, err := sjson.Set(`{}`, "type", b.textType)
+		if err == nil {
+			text, err = sjson.Set(text, "text", texts[i])
+		}
+		if cc := gjson.Get(b.Raw, "cache_control"); err == nil && cc.Exists() {
+			text, err = sjson.SetRaw(text, "cache_control", cc.Raw)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if modified, err = sjson.SetRawBytes(modified, b.blockPath, []byte(text)); err != nil {
+			return nil, err
+		}
+	}
+	return modified, nil
+}
+
+// restoreMediaBlock replaces the first text block under root whose text
+// contains marker with raw.
+func restoreMediaBlock(body []byte, root, marker, raw string) ([]byte, bool) {
+	path, ok := findTextBlock(gjson.GetBytes(body, root), root, marker)
+	if !ok {
+		return body, false
+	}
+	modified, err := sjson.SetRawBytes(body, path, []byte(raw))
+	if err != nil {
+		return body, false
+	}
+	return modified, true
+}
+
+// findTextBlock returns the path of the first text block in v, searching
+// nested content and output arrays, whose text contains marker.
+func findTextBlock(v gjson.Result, path, marker string) (string, bool) {
+	if v.IsArray() {
+		for i, item := range v.Array() {
+			if found, ok := findTextBlock(item, fmt.Sprintf("%s.%d", path, i), marker); ok {
+				return found, true
+			}
+		}
+		return "", false
+	}
+	if !v.IsObject() {
+		return "", false
+	}
+	if t := v.Get("type").String(); (t == "text" || t == "input_text") && strings.Contains(v.Get("text").String(), marker) {
+		return path, true
+	}
+	for _, key := range []string{"content", "output"} {
+		if child := v.Get(key); child.IsArray() {
+			if found, ok := findTextBlock(child, path+"."+key, marker); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseBase64DataURI splits "data:<type>;base64,<payload>" into its media type
+// and payload.
+func parseBase64DataURI(uri string) (mediaType, payload string, ok bool) {
+	rest, found := strings.CutPrefix(uri, "data:")
+	if !found {
+		return "", "", false
+	}
+	mediaType, payload, found = strings.Cut(rest, ";base64,")
+	return mediaType, payload, found
+}
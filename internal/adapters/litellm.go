@@ -1,6 +1,8 @@
 // litellm.go implements the LiteLLM adapter for message transformation and usage parsing.
 package adapters
 
+import "strings"
+
 // LiteLLMAdapter handles LiteLLM proxy API format requests.
 // LiteLLM exposes an OpenAI-compatible API, so this adapter embeds OpenAIAdapter
 // and delegates all methods.
@@ -52,3 +54,34 @@ func (a *LiteLLMAdapter) ExtractTurnSignal(responseBody []byte, streamStopReason
 // Ensure LiteLLMAdapter implements Adapter and ParsedRequestAdapter
 var _ Adapter = (*LiteLLMAdapter)(nil)
 var _ ParsedRequestAdapter = (*LiteLLMAdapter)(nil)
+
+// modelProviderPrefixes are the provider prefixes LiteLLM routes model names
+// by ("bedrock/anthropic.claude-...", "vertex_ai/gemini-2.5-pro"), plus the
+// vendor prefixes OpenAI-compatible clients send ("google/", "meta/").
+var modelProviderPrefixes = map[string]bool{
+	"anthropic": true, "openai": true, "google": true, "meta": true,
+	"azure": true, "azure_ai": true, "bedrock": true, "bedrock_converse": true,
+	"vertex_ai": true, "vertex_ai_beta": true, "gemini": true, "sagemaker": true,
+	"groq": true, "mistral": true, "codestral": true, "deepseek": true, "xai": true,
+	"cohere": true, "cohere_chat": true, "together_ai": true, "fireworks_ai": true,
+	"openrouter": true, "ollama": true, "ollama_chat": true, "perplexity": true,
+	"cerebras": true, "deepinfra": true, "huggingface": true, "replicate": true,
+	"databricks": true, "watsonx": true, "nvidia_nim": true, "hosted_vllm": true,
+	"lm_studio": true, "text-completion-openai": true, "litellm_proxy": true,
+	"anyscale": true, "ai21": true, "dashscope": true, "moonshot": true,
+	"sambanova": true, "nebius": true, "novita": true, "friendliai": true,
+	"cloudflare": true, "github": true, "minimax": true,
+}
+
+// SplitModelProvider splits a provider-prefixed model name, as LiteLLM uses
+// them, into its provider and model: "bedrock/anthropic.claude-3-5-sonnet"
+// gives ("bedrock", "anthropic.claude-3-5-sonnet"). A name without a known
+// prefix is returned unchanged with an empty provider. Only the first prefix
+// is split: "openrouter/anthropic/claude-3.5-sonnet" keeps its vendor part.
+func SplitModelProvider(model string) (provider, name string) {
+	prefix, rest, ok := strings.Cut(model, "/")
+	if !ok || rest == "" || !modelProviderPrefixes[strings.ToLower(prefix)] {
+		return "", model
+	}
+	return prefix, rest
+}
@@ -2,6 +2,7 @@
 package adapters
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -20,13 +21,10 @@ import (
 //   - arguments: JSON object directly (not a JSON-encoded string)
 //   - No "id" field on tool calls
 //
-// KNOWN LIMITATIONS (native /api/chat endpoint):
-//
-//  1. Streaming usage is never captured.
-//     Ollama native streaming uses NDJSON (single "\n" delimited) not SSE ("data: ...\n\n").
-//     The gateway's SSE parser never fires for native Ollama streams, so all streaming
-//     sessions report 0 tokens. Non-streaming sessions work correctly via ExtractUsage.
-//     Fix requires a custom NDJSON streaming handler for the Ollama path.
+// Native /api/chat streams NDJSON (one JSON object per line) rather than SSE,
+// and streams by default when the request has no "stream" field. The final
+// line has "done": true and carries prompt_eval_count/eval_count, so
+// ExtractUsage reads usage from the last line of a buffered NDJSON body.
 //
 // OllamaAdapter embeds both BaseAdapter and *OpenAIAdapter, which creates ambiguous
 // selectors for methods implemented on both. Any method that exists on both embedded
@@ -66,6 +64,13 @@ func (a *OllamaAdapter) ExtractUsage(responseBody []byte) UsageInfo {
 		return UsageInfo{}
 	}
 
+	// NDJSON stream: usage is on the final "done" line
+	if trimmed := bytes.TrimSpace(responseBody); !gjson.ValidBytes(trimmed) {
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			responseBody = trimmed[i+1:]
+		}
+	}
+
 	// Try Ollama-native format first
 	var resp struct {
 		PromptEvalCount int `json:"prompt_eval_count"`
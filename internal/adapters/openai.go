@@ -2,6 +2,7 @@
 package adapters
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -12,13 +13,17 @@ import (
 )
 
 // OpenAIAdapter handles OpenAI API format requests.
-// Supports both:
+// Supports:
 //   - Responses API: input[] array with function_call/function_call_output items
 //   - Chat Completions API: messages[] with role="tool" items
+//   - Assistants API: submit_tool_outputs bodies with tool_outputs[] items
 type OpenAIAdapter struct {
 	BaseAdapter
 }
 
+// fileSearchToolName names Responses API file_search results in extracted tool outputs.
+const fileSearchToolName = "file_search"
+
 // NewOpenAIAdapter creates a new OpenAI adapter.
 func NewOpenAIAdapter() *OpenAIAdapter {
 	return &OpenAIAdapter{
@@ -45,12 +50,43 @@ func (a *OpenAIAdapter) ExtractToolOutput(body []byte) ([]ExtractedContent, erro
 	if messages, ok := req["messages"].([]any); ok {
 		return a.extractChatCompletionsMessages(messages), nil
 	}
+	if outputs, ok := req["tool_outputs"].([]any); ok {
+		return extractAssistantsToolOutputs(outputs), nil
+	}
 	return nil, nil
 }
 
+// extractAssistantsToolOutputs extracts the outputs of an Assistants API
+// submit_tool_outputs request. The tool names live on the run, not in the body.
+// Format: {tool_outputs:[{tool_call_id, output}]}
+func extractAssistantsToolOutputs(outputs []any) []ExtractedContent {
+	var extracted []ExtractedContent
+	for i, item := range outputs {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		callID := getString(m, "tool_call_id")
+		content := getString(m, "output")
+		if callID != "" && content != "" {
+			extracted = append(extracted, ExtractedContent{
+				ID:           callID,
+				Content:      content,
+				ContentType:  "tool_result",
+				Format:       DetectContentFormat(content),
+				MessageIndex: i,
+			})
+		}
+	}
+	return extracted
+}
+
 // extractResponsesAPIItems extracts tool outputs from a Responses API input[] slice.
 // Shared by ExtractToolOutput and ExtractToolOutputFromParsed.
 // Format: [ {type:"function_call", call_id, name}, {type:"function_call_output", call_id, output} ]
+// Built-in file_search_call items contribute each results[].text, with
+// BlockIndex set to the result's index. web_search_call items carry only the
+// query and sources (results reach the model as message text) and are skipped.
 func (a *OpenAIAdapter) extractResponsesAPIItems(items []any) []ExtractedContent {
 	toolNames := make(map[string]string)
 	for _, item := range items {
@@ -72,7 +108,8 @@ func (a *OpenAIAdapter) extractResponsesAPIItems(items []any) []ExtractedContent
 		if !ok {
 			continue
 		}
-		if typ := getString(m, "type"); typ == "function_call_output" {
+		switch getString(m, "type") {
+		case "function_call_output":
 			callID := getString(m, "call_id")
 			content := extractStringContent(m["output"])
 			if callID != "" && content != "" {
@@ -85,7 +122,42 @@ func (a *OpenAIAdapter) extractResponsesAPIItems(items []any) []ExtractedContent
 					MessageIndex: i,
 				})
 			}
+		case "file_search_call":
+			extracted = append(extracted, extractFileSearchResults(m, i)...)
+		}
+	}
+	return extracted
+}
+
+// extractFileSearchResults returns the text of each result in a
+// file_search_call item. Results are only present when the request asked for
+// them with include: ["file_search_call.results"].
+// Format: {type:"file_search_call", id, queries, results:[{file_id, filename, score, text}]}
+func extractFileSearchResults(item map[string]any, itemIdx int) []ExtractedContent {
+	id := getString(item, "id")
+	results, _ := item["results"].([]any)
+	if id == "" {
+		return nil
+	}
+	var extracted []ExtractedContent
+	for j, r := range results {
+		result, ok := r.(map[string]any)
+		if !ok {
+			continue
 		}
+		text := getString(result, "text")
+		if text == "" {
+			continue
+		}
+		extracted = append(extracted, ExtractedContent{
+			ID:           id,
+			Content:      text,
+			ContentType:  "tool_result",
+			Format:       DetectContentFormat(text),
+			ToolName:     fileSearchToolName,
+			MessageIndex: itemIdx,
+			BlockIndex:   j,
+		})
 	}
 	return extracted
 }
@@ -155,31 +227,179 @@ func (a *OpenAIAdapter) ApplyToolOutput(body []byte, results []CompressedResult)
 	}
 
 	// Detect format: Responses API has "input" but not "messages"
-	isResponsesAPI := gjson.GetBytes(body, "input").Exists() && !gjson.GetBytes(body, "messages").Exists()
+	hasMessages := gjson.GetBytes(body, "messages").Exists()
+	isResponsesAPI := gjson.GetBytes(body, "input").Exists() && !hasMessages
+	isAssistants := !isResponsesAPI && !hasMessages && gjson.GetBytes(body, "tool_outputs").Exists()
 
 	modified := body
 	// Process in reverse order to maintain correct byte offsets
 	for i := len(results) - 1; i >= 0; i-- {
 		r := results[i]
 		var path string
-		if isResponsesAPI {
-			// Responses API: input[N].output
-			path = fmt.Sprintf("input.%d.output", r.MessageIndex)
-		} else {
+		switch {
+		case isResponsesAPI:
+			if gjson.GetBytes(modified, fmt.Sprintf("input.%d.type", r.MessageIndex)).String() == "file_search_call" {
+				// Built-in file search: input[N].results[B].text
+				path = fmt.Sprintf("input.%d.results.%d.text", r.MessageIndex, r.BlockIndex)
+			} else {
+				// Responses API: input[N].output
+				path = fmt.Sprintf("input.%d.output", r.MessageIndex)
+			}
+		case isAssistants:
+			// Assistants submit_tool_outputs: tool_outputs[N].output
+			path = fmt.Sprintf("tool_outputs.%d.output", r.MessageIndex)
+		default:
 			// Chat Completions: messages[N].content
 			path = fmt.Sprintf("messages.%d.content", r.MessageIndex)
 		}
-		var err error
-		modified, err = sjson.SetBytes(modified, path, r.Compressed)
+		// Content-part arrays keep their non-text parts (e.g. input_image).
+		updated, err := setTextPreservingParts(modified, path, r.Compressed)
 		if err != nil {
 			log.Warn().Err(err).Str("path", path).Str("id", r.ID).
 				Msg("sjson set failed for tool output, skipping")
 			continue
 		}
+		modified = updated
 	}
 	return modified, nil
 }
 
+// ExtractToolImages returns data-URI images inside Responses API tool outputs.
+// Format: {type:"function_call_output", call_id, output:[{type:"input_image", image_url:"data:..."}]}
+// and {type:"computer_call_output", call_id, output:{type:"computer_screenshot", image_url:"data:..."}}.
+// Chat Completions tool messages carry text only.
+func (a *OpenAIAdapter) ExtractToolImages(body []byte) []ToolImage {
+	if gjson.GetBytes(body, "messages").Exists() {
+		return nil
+	}
+	var images []ToolImage
+	for itemIdx, item := range gjson.GetBytes(body, "input").Array() {
+		callID := item.Get("call_id").String()
+		switch item.Get("type").String() {
+		case "function_call_output":
+			if !item.Get("output").IsArray() {
+				continue
+			}
+			for partIdx, part := range item.Get("output").Array() {
+				if part.Get("type").String() != "input_image" {
+					continue
+				}
+				if img, ok := dataURIToolImage(callID, part.Get("image_url").String(), fmt.Sprintf("input.%d.output.%d.image_url", itemIdx, partIdx)); ok {
+					images = append(images, img)
+				}
+			}
+		case "computer_call_output":
+			if item.Get("output.type").String() != "computer_screenshot" {
+				continue
+			}
+			if img, ok := dataURIToolImage(callID, item.Get("output.image_url").String(), fmt.Sprintf("input.%d.output.image_url", itemIdx)); ok {
+				images = append(images, img)
+			}
+		}
+	}
+	return images
+}
+
+// dataURIToolImage decodes a base64 data: URI found at dataPath.
+func dataURIToolImage(callID, uri, dataPath string) (ToolImage, bool) {
+	mediaType, payload, ok := parseBase64DataURI(uri)
+	if !ok {
+		return ToolImage{}, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return ToolImage{}, false
+	}
+	return ToolImage{ToolCallID: callID, MediaType: mediaType, Data: data, dataPath: dataPath}, true
+}
+
+// ApplyToolImages writes modified tool output images back to the request.
+func (a *OpenAIAdapter) ApplyToolImages(body []byte, images []ToolImage) ([]byte, error) {
+	return applyToolImages(body, images)
+}
+
+// ExtractMediaBlocks returns data-URI image and file parts.
+// Chat Completions: messages[].content[] parts of type image_url and file.
+// Responses API: input[].content[] and function_call_output input[].output[]
+// parts of type input_image and input_file.
+func (a *OpenAIAdapter) ExtractMediaBlocks(body []byte) []MediaBlock {
+	root, textType := "input", "input_text"
+	if gjson.GetBytes(body, "messages").Exists() {
+		root, textType = "messages", "text"
+	}
+	var blocks []MediaBlock
+	for itemIdx, item := range gjson.GetBytes(body, root).Array() {
+		key := "content"
+		if item.Get("type").String() == "function_call_output" {
+			key = "output"
+		}
+		for partIdx, part := range item.Get(key).Array() {
+			mb, ok := openAIMediaBlock(part, fmt.Sprintf("%s.%d.%s.%d", root, itemIdx, key, partIdx), textType)
+			if !ok {
+				continue
+			}
+			mb.MessageIndex = itemIdx
+			if key == "output" {
+				mb.ToolCallID = item.Get("call_id").String()
+			}
+			blocks = append(blocks, mb)
+		}
+	}
+	return blocks
+}
+
+// openAIMediaBlock reads an image or file part carrying a base64 data: URI.
+func openAIMediaBlock(part gjson.Result, path, textType string) (MediaBlock, bool) {
+	var uriField string
+	switch part.Get("type").String() {
+	case "image_url":
+		uriField = "image_url.url"
+	case "input_image":
+		uriField = "image_url"
+	case "file":
+		uriField = "file.file_data"
+	case "input_file":
+		uriField = "file_data"
+	default:
+		return MediaBlock{}, false
+	}
+	mediaType, payload, ok := parseBase64DataURI(part.Get(uriField).String())
+	if !ok {
+		return MediaBlock{}, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return MediaBlock{}, false
+	}
+	return MediaBlock{
+		MediaType: mediaType,
+		Data:      data,
+		Raw:       part.Raw,
+		blockPath: path,
+		dataPath:  path + "." + uriField,
+		textType:  textType,
+	}, true
+}
+
+// ApplyMediaBlocks writes modified media parts back to the request.
+func (a *OpenAIAdapter) ApplyMediaBlocks(body []byte, blocks []MediaBlock) ([]byte, error) {
+	return applyMediaBlocks(body, blocks)
+}
+
+// ReplaceMediaBlocks replaces media parts with text parts.
+func (a *OpenAIAdapter) ReplaceMediaBlocks(body []byte, blocks []MediaBlock, texts []string) ([]byte, error) {
+	return replaceMediaBlocks(body, blocks, texts)
+}
+
+// RestoreMediaBlock puts a replaced media part back in messages or input.
+func (a *OpenAIAdapter) RestoreMediaBlock(body []byte, marker, raw string) ([]byte, bool) {
+	root := "input"
+	if gjson.GetBytes(body, "messages").Exists() {
+		root = "messages"
+	}
+	return restoreMediaBlock(body, root, marker, raw)
+}
+
 // TOOL DISCOVERY - Extract/Apply
 
 // ExtractToolDiscovery extracts tool definitions for filtering.
@@ -402,7 +622,8 @@ func (a *OpenAIAdapter) ExtractUserQueryFromParsed(parsed *ParsedRequest) string
 			}
 			typ := getString(m, "type")
 			role := getString(m, "role")
-			if typ == "message" && role == "user" {
+			// type is optional on input messages ({"role":"user","content":"..."}).
+			if (typ == "message" || typ == "") && role == "user" {
 				content := extractStringContent(m["content"])
 				if content != "" {
 					return content
@@ -546,7 +767,8 @@ func (a *OpenAIAdapter) ExtractUserQuery(body []byte) string {
 				}
 				typ := getString(m, "type")
 				role := getString(m, "role")
-				if typ == "message" && role == "user" {
+				// type is optional on input messages ({"role":"user","content":"..."}).
+				if (typ == "message" || typ == "") && role == "user" {
 					content := extractUserText(m["content"])
 					if content != "" {
 						return content
@@ -0,0 +1,123 @@
+// openrouter.go implements the OpenRouter adapter for message transformation and usage parsing.
+package adapters
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// OpenRouterAdapter handles OpenRouter API format requests.
+// OpenRouter exposes an OpenAI-compatible API (https://openrouter.ai/api/v1/chat/completions),
+// so this adapter embeds OpenAIAdapter and delegates all methods except two:
+//   - ExtractModel maps OpenRouter model ids ("anthropic/claude-3.5-sonnet:beta")
+//     to the native name ("claude-3-5-sonnet") used for pricing and pipe decisions.
+//   - ExtractUsage also reads usage.cost, the credits OpenRouter charged (in USD).
+//
+// OpenRouterAdapter embeds both BaseAdapter and *OpenAIAdapter, which creates ambiguous
+// selectors for methods implemented on both. Any method that exists on both embedded
+// types MUST be explicitly delegated below (e.g. Name, Provider, ExtractAssistantIntent,
+// ExtractTurnSignal). Do not remove those delegation stubs without resolving the ambiguity.
+type OpenRouterAdapter struct {
+	BaseAdapter
+	*OpenAIAdapter
+}
+
+// NewOpenRouterAdapter creates a new OpenRouter adapter.
+func NewOpenRouterAdapter() *OpenRouterAdapter {
+	return &OpenRouterAdapter{
+		BaseAdapter: BaseAdapter{
+			name:     "openrouter",
+			provider: ProviderOpenRouter,
+		},
+		OpenAIAdapter: NewOpenAIAdapter(),
+	}
+}
+
+// Name returns the adapter name (overrides embedded OpenAIAdapter.Name).
+func (a *OpenRouterAdapter) Name() string {
+	return a.BaseAdapter.Name()
+}
+
+// Provider returns the provider type (overrides embedded OpenAIAdapter.Provider).
+func (a *OpenRouterAdapter) Provider() Provider {
+	return a.BaseAdapter.Provider()
+}
+
+// ExtractModel returns the native name of the requested model.
+func (a *OpenRouterAdapter) ExtractModel(requestBody []byte) string {
+	model := gjson.GetBytes(requestBody, "model")
+	if model.Type != gjson.String {
+		return ""
+	}
+	return OpenRouterModelName(model.String())
+}
+
+// ExtractUsage extracts token usage and the credits charged from an OpenRouter response.
+func (a *OpenRouterAdapter) ExtractUsage(responseBody []byte) UsageInfo {
+	usage := a.OpenAIAdapter.ExtractUsage(responseBody)
+	usage.CostUSD = gjson.GetBytes(responseBody, "usage.cost").Float()
+	return usage
+}
+
+// OpenRouterModelName maps an OpenRouter model id to the provider's own name:
+// the "openrouter/" and vendor prefixes and any ":variant" suffix (":free",
+// ":nitro", ":beta") are dropped, and Claude's dotted versions are dashed, so
+// "anthropic/claude-3.5-sonnet:beta" gives "claude-3-5-sonnet". Other models
+// keep their dots ("google/gemini-2.5-pro" gives "gemini-2.5-pro").
+func OpenRouterModelName(model string) string {
+	model = strings.TrimPrefix(model, "openrouter/")
+	if idx := strings.LastIndex(model, "/"); idx != -1 {
+		model = model[idx+1:]
+	}
+	if idx := strings.Index(model, ":"); idx != -1 {
+		model = model[:idx]
+	}
+	if strings.HasPrefix(model, "claude-") {
+		model = strings.ReplaceAll(model, ".", "-")
+	}
+	return model
+}
+
+// =============================================================================
+// PARSED REQUEST ADAPTER - Delegate to OpenAI
+// =============================================================================
+
+// ParseRequest parses the request body once for reuse.
+func (a *OpenRouterAdapter) ParseRequest(body []byte) (*ParsedRequest, error) {
+	return a.OpenAIAdapter.ParseRequest(body)
+}
+
+// ExtractToolDiscoveryFromParsed extracts tool definitions from a pre-parsed request.
+func (a *OpenRouterAdapter) ExtractToolDiscoveryFromParsed(parsed *ParsedRequest, opts *ToolDiscoveryOptions) ([]ExtractedContent, error) {
+	return a.OpenAIAdapter.ExtractToolDiscoveryFromParsed(parsed, opts)
+}
+
+// ExtractUserQueryFromParsed extracts the last user message from a pre-parsed request.
+func (a *OpenRouterAdapter) ExtractUserQueryFromParsed(parsed *ParsedRequest) string {
+	return a.OpenAIAdapter.ExtractUserQueryFromParsed(parsed)
+}
+
+// ExtractToolOutputFromParsed extracts tool results from a pre-parsed request.
+func (a *OpenRouterAdapter) ExtractToolOutputFromParsed(parsed *ParsedRequest) ([]ExtractedContent, error) {
+	return a.OpenAIAdapter.ExtractToolOutputFromParsed(parsed)
+}
+
+// ApplyToolDiscoveryToParsed filters tools and returns modified body.
+func (a *OpenRouterAdapter) ApplyToolDiscoveryToParsed(parsed *ParsedRequest, results []CompressedResult) ([]byte, error) {
+	return a.OpenAIAdapter.ApplyToolDiscoveryToParsed(parsed, results)
+}
+
+// ExtractAssistantIntent delegates to OpenAI (resolves ambiguity from dual embedding).
+func (a *OpenRouterAdapter) ExtractAssistantIntent(body []byte) string {
+	return a.OpenAIAdapter.ExtractAssistantIntent(body)
+}
+
+// ExtractTurnSignal delegates to OpenAI (resolves ambiguity from dual embedding).
+func (a *OpenRouterAdapter) ExtractTurnSignal(responseBody []byte, streamStopReason string) TurnSignal {
+	return a.OpenAIAdapter.ExtractTurnSignal(responseBody, streamStopReason)
+}
+
+// Ensure OpenRouterAdapter implements Adapter and ParsedRequestAdapter
+var _ Adapter = (*OpenRouterAdapter)(nil)
+var _ ParsedRequestAdapter = (*OpenRouterAdapter)(nil)
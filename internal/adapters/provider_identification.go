@@ -3,6 +3,7 @@ package adapters
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -30,9 +31,17 @@ func IdentifyAndGetAdapter(registry *Registry, path string, headers http.Header)
 //     may forward an anthropic-version header alongside Bedrock requests, causing
 //     misidentification if the header check fires first.
 //  3. anthropic-version header (definitive for direct Anthropic API)
-//  4. API key patterns (sk-ant- for Anthropic, sk- for OpenAI)
-//  5. Path patterns (/v1/messages for Anthropic, /v1/chat/completions for OpenAI)
-//  6. Default to OpenAI (most common format)
+//  4. x-api-key with an sk-ant- key (Anthropic)
+//  5. Authorization key prefix (sk-ant- for Anthropic, sk-or- for OpenRouter)
+//  6. X-Target-URL on openrouter.ai (OpenRouter)
+//  7. Path patterns (/v1/messages for Anthropic, /v1/chat/completions for OpenAI)
+//  8. Gemini API key header, host or :generateContent path
+//  9. Ollama native paths
+//  10. Default to OpenAI (most common format)
+//
+// OpenRouter is recognized only by signals that also send the request there
+// (an sk-or- key or the openrouter.ai target), so the adapter always matches
+// the upstream autoDetectTargetURL picks.
 func detectProvider(path string, headers http.Header) Provider {
 	// 1. Explicit X-Provider header (highest priority)
 	if p := headers.Get("X-Provider"); p != "" {
@@ -76,14 +85,22 @@ func detectProvider(path string, headers http.Header) Provider {
 		return ProviderAnthropic
 	}
 
-	// 5. Check Authorization header - distinguish sk-ant- (Anthropic) from sk- (OpenAI)
+	// 5. Check Authorization header - sk-ant- (Anthropic) and sk-or- (OpenRouter) keys
 	if auth := headers.Get("Authorization"); auth != "" {
 		if strings.HasPrefix(auth, "Bearer sk-ant-") {
 			return ProviderAnthropic
 		}
+		if strings.HasPrefix(auth, "Bearer sk-or-") {
+			return ProviderOpenRouter
+		}
+	}
+
+	// 6. OpenRouter target host
+	if isOpenRouterURL(headers.Get("X-Target-URL")) {
+		return ProviderOpenRouter
 	}
 
-	// 6. Path-based detection
+	// 7. Path-based detection
 	if strings.HasSuffix(path, "/v1/messages") {
 		return ProviderAnthropic
 	}
@@ -95,18 +112,33 @@ func detectProvider(path string, headers http.Header) Provider {
 		return ProviderOpenAI
 	}
 
-	// 7. Check Gemini
+	// 8. Check Gemini (API key header, API host, or the native
+	// /models/{model}:generateContent path for OAuth and ?key= clients)
 	if strings.Contains(path, "generativelanguage.googleapis.com") ||
-		headers.Get("x-goog-api-key") != "" {
+		headers.Get("x-goog-api-key") != "" ||
+		IsGeminiPath(path) {
 		return ProviderGemini
 	}
 
-	// 8. Check Ollama
+	// 9. Check Ollama
 	if strings.HasSuffix(path, "/api/chat") ||
 		strings.HasSuffix(path, "/api/generate") {
 		return ProviderOllama
 	}
 
-	// Default to OpenAI format (most common)
+	// 10. Default to OpenAI format (most common)
 	return ProviderOpenAI
 }
+
+// isOpenRouterURL reports whether target is on openrouter.ai or a subdomain.
+func isOpenRouterURL(target string) bool {
+	if target == "" {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "openrouter.ai" || strings.HasSuffix(host, ".openrouter.ai")
+}
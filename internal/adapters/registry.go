@@ -2,6 +2,7 @@
 package adapters
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -25,6 +26,7 @@ func NewRegistry() *Registry {
 	r.Register(NewLiteLLMAdapter())
 	r.Register(NewGeminiAdapter())
 	r.Register(NewMiniMaxAdapter())
+	r.Register(NewOpenRouterAdapter())
 
 	return r
 }
@@ -42,3 +44,15 @@ func (r *Registry) Get(name string) Adapter {
 	defer r.mu.RUnlock()
 	return r.adapters[name]
 }
+
+// List returns the names of all registered adapters, sorted.
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.adapters))
+	for name := range r.adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
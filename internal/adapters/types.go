@@ -98,14 +98,15 @@ type ToolFunction struct {
 type Provider string
 
 const (
-	ProviderAnthropic Provider = "anthropic"
-	ProviderOpenAI    Provider = "openai"
-	ProviderGemini    Provider = "gemini"
-	ProviderBedrock   Provider = "bedrock"
-	ProviderOllama    Provider = "ollama"
-	ProviderLiteLLM   Provider = "litellm"
-	ProviderMiniMax   Provider = "minimax"
-	ProviderUnknown   Provider = "unknown"
+	ProviderAnthropic  Provider = "anthropic"
+	ProviderOpenAI     Provider = "openai"
+	ProviderGemini     Provider = "gemini"
+	ProviderBedrock    Provider = "bedrock"
+	ProviderOllama     Provider = "ollama"
+	ProviderLiteLLM    Provider = "litellm"
+	ProviderMiniMax    Provider = "minimax"
+	ProviderOpenRouter Provider = "openrouter"
+	ProviderUnknown    Provider = "unknown"
 )
 
 // String returns the provider name.
@@ -130,6 +131,8 @@ func ProviderFromString(s string) Provider {
 		return ProviderLiteLLM
 	case "minimax":
 		return ProviderMiniMax
+	case "openrouter":
+		return ProviderOpenRouter
 	default:
 		return ProviderUnknown
 	}
@@ -142,8 +145,9 @@ type UsageInfo struct {
 	InputTokens              int
 	OutputTokens             int
 	TotalTokens              int
-	CacheCreationInputTokens int // Tokens written to cache (Anthropic: 1.25x input price)
-	CacheReadInputTokens     int // Tokens read from cache (Anthropic: 0.1x, OpenAI: 0.5x)
+	CacheCreationInputTokens int     // Tokens written to cache (Anthropic: 1.25x input price)
+	CacheReadInputTokens     int     // Tokens read from cache (Anthropic: 0.1x, OpenAI: 0.5x)
+	CostUSD                  float64 // Cost the provider reported (OpenRouter usage.cost); 0 if none
 }
 
 // PARSED REQUEST - Single-parse optimization for tool discovery
@@ -235,3 +239,61 @@ type ParsedRequestAdapter interface {
 	// ApplyToolDiscoveryToParsed filters tools and returns modified body.
 	ApplyToolDiscoveryToParsed(parsed *ParsedRequest, results []CompressedResult) ([]byte, error)
 }
+
+// ToolImage is a base64 image embedded in a tool result (e.g. a browser or
+// computer-use screenshot). Data holds the decoded bytes; the unexported
+// fields locate the image in the request body for ApplyToolImages.
+type ToolImage struct {
+	ToolCallID string
+	MediaType  string
+	Data       []byte
+
+	dataPath      string // gjson path of the base64 payload
+	mediaTypePath string // gjson path of the media type, "" when carried in a data: URI
+}
+
+// ToolImageAdapter is an optional interface for adapters whose tool results
+// can carry image blocks. The tool_output pipe uses it to downscale images
+// before forwarding.
+type ToolImageAdapter interface {
+	// ExtractToolImages returns the base64 images inside tool results.
+	ExtractToolImages(body []byte) []ToolImage
+
+	// ApplyToolImages writes modified images (Data, MediaType) back to the body.
+	ApplyToolImages(body []byte, images []ToolImage) ([]byte, error)
+}
+
+// MediaBlock is a base64 image or document block anywhere in the
+// conversation: user content, tool results, or Responses input items. Raw is
+// the block's JSON; the unexported fields locate it in the request body.
+type MediaBlock struct {
+	MessageIndex int    // Message (or Responses input item) holding the block
+	ToolCallID   string // Set when the block is inside a tool result
+	MediaType    string
+	Data         []byte
+	Raw          string
+
+	blockPath     string // gjson path of the whole block
+	dataPath      string // gjson path of the base64 payload
+	mediaTypePath string // gjson path of the media type, "" when carried in a data: URI
+	textType      string // type of the text block that can stand in for this one
+}
+
+// MediaBlockAdapter is an optional interface for adapters whose requests can
+// carry base64 image and document blocks. The media pipe uses it to downscale
+// older blocks or replace them with placeholders, and expand_context uses it
+// to put a replaced block back.
+type MediaBlockAdapter interface {
+	// ExtractMediaBlocks returns the base64 media blocks in conversation order.
+	ExtractMediaBlocks(body []byte) []MediaBlock
+
+	// ApplyMediaBlocks writes modified blocks (Data, MediaType) back to the body.
+	ApplyMediaBlocks(body []byte, blocks []MediaBlock) ([]byte, error)
+
+	// ReplaceMediaBlocks replaces each block with a text block holding texts[i].
+	ReplaceMediaBlocks(body []byte, blocks []MediaBlock, texts []string) ([]byte, error)
+
+	// RestoreMediaBlock puts raw back in place of the first text block whose
+	// text contains marker. Returns false when no such block exists.
+	RestoreMediaBlock(body []byte, marker, raw string) ([]byte, bool)
+}
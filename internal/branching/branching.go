@@ -0,0 +1,310 @@
+// Package branching detects conversation branches from message prefix hashes.
+//
+// Session state (compaction summaries, expanded tools) is keyed by a hash of
+// the first user message, so when a client edits an earlier message and
+// continues, both versions of the conversation share one session and state
+// from one leaks into the other. The Tracker records, per conversation, the
+// chain of prefix hashes each branch has sent. A request whose chain extends
+// (or is a prefix of) a known branch belongs to that branch; one that diverges
+// starts a new branch forked from the branch it shares the longest prefix with.
+//
+// The root branch keeps the conversation's session ID, so unbranched
+// conversations behave exactly as before. Child branches get a derived ID and
+// a ForkIndex telling callers which parent state still applies: anything
+// derived from messages before ForkIndex is shared ancestry.
+package branching
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+// MaxBranches caps branches per conversation; the least recently used
+// non-root branch is dropped when a new one would exceed it, and reported in
+// Resolution.EvictedSessionID so callers can free its per-branch state.
+const MaxBranches = 32
+
+// hashLen is the hex length of each prefix hash.
+const hashLen = 16
+
+// Resolution is the branch a request belongs to.
+type Resolution struct {
+	SessionID        string // Branch-scoped session ID (the conversation ID for the root branch)
+	BranchID         string // "" for the root branch
+	Forked           bool   // This request created the branch
+	ParentSessionID  string // Session ID of the branch forked from (set when Forked)
+	ForkIndex        int    // First message index that differs from the parent (set when Forked)
+	EvictedSessionID string // Session ID of the branch dropped to make room (set when Forked)
+}
+
+// branch is one line of a conversation.
+type branch struct {
+	id       string
+	parentID string
+	chain    []string // prefix hashes of the longest request seen on this branch
+	lastSeen time.Time
+}
+
+// conversation is the branch tree of one conversation.
+type conversation struct {
+	branches []*branch // branches[0] is the root
+}
+
+// Tracker maps requests to conversation branches. Thread-safe.
+type Tracker struct {
+	convs *sessionstore.Store[conversation]
+}
+
+// NewTracker creates a tracker whose conversations expire after ttl without requests.
+// Expired conversations are dropped on access; call Sweep (or register the
+// tracker with a sessionstore.Collector) to reclaim them in the background.
+func NewTracker(ttl time.Duration) *Tracker {
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	return &Tracker{convs: sessionstore.New[conversation](ttl, 0, nil)}
+}
+
+// Stop ends the background cleanup goroutine.
+func (t *Tracker) Stop() {
+	t.convs.Stop()
+}
+
+// Sweep removes idle conversations and returns how many (sessionstore.Sweeper).
+func (t *Tracker) Sweep() int {
+	return t.convs.Sweep()
+}
+
+// Expire forgets a conversation and its branches (sessionstore.Sweeper).
+func (t *Tracker) Expire(conversationID string) bool {
+	return t.convs.Delete(conversationID)
+}
+
+// Len returns the number of tracked conversations.
+func (t *Tracker) Len() int {
+	return t.convs.Len()
+}
+
+// Reset forgets every conversation.
+func (t *Tracker) Reset() {
+	t.convs.Reset()
+}
+
+// SessionID returns the session ID of branchID within conversation conversationID.
+func SessionID(conversationID, branchID string) string {
+	if branchID == "" {
+		return conversationID
+	}
+	return conversationID + "-b" + branchID
+}
+
+// Resolve records a request with the given prefix hashes against conversation
+// conversationID and returns the branch it belongs to.
+func (t *Tracker) Resolve(conversationID string, hashes []string) Resolution {
+	if t == nil || conversationID == "" || len(hashes) == 0 {
+		return Resolution{SessionID: conversationID}
+	}
+
+	var res Resolution
+	t.convs.Update(conversationID, func(c *conversation) {
+		now := time.Now()
+		if len(c.branches) == 0 {
+			c.branches = []*branch{{chain: hashes, lastSeen: now}}
+			res = Resolution{SessionID: conversationID}
+			return
+		}
+
+		match, closest, closestLen := c.find(hashes)
+		if match != nil {
+			if len(hashes) > len(match.chain) {
+				match.chain = hashes
+			}
+			match.lastSeen = now
+			res = Resolution{SessionID: SessionID(conversationID, match.id), BranchID: match.id}
+			return
+		}
+
+		// Diverged: fork from the branch sharing the longest prefix. The ID
+		// derives from the first divergent prefix, so replaying the same edit
+		// lands on the same branch.
+		child := &branch{
+			id:       hashes[closestLen][:8],
+			parentID: closest.id,
+			chain:    hashes,
+			lastSeen: now,
+		}
+		var evicted string
+		if len(c.branches) >= MaxBranches {
+			if id, ok := c.evictOldest(); ok {
+				evicted = SessionID(conversationID, id)
+			}
+		}
+		c.branches = append(c.branches, child)
+		res = Resolution{
+			SessionID:        SessionID(conversationID, child.id),
+			BranchID:         child.id,
+			Forked:           true,
+			ParentSessionID:  SessionID(conversationID, closest.id),
+			ForkIndex:        closestLen,
+			EvictedSessionID: evicted,
+		}
+	})
+	return res
+}
+
+// Lookup returns the session ID of the branch a request with the given prefix
+// hashes belongs to, without recording the request. A request that would fork
+// gets the branch it would fork from. Unknown conversations map to conversationID.
+func (t *Tracker) Lookup(conversationID string, hashes []string) string {
+	if t == nil || conversationID == "" || len(hashes) == 0 {
+		return conversationID
+	}
+	sessionID := conversationID
+	t.convs.View(conversationID, func(c *conversation) {
+		if len(c.branches) == 0 {
+			return
+		}
+		match, closest, _ := c.find(hashes)
+		if match == nil {
+			match = closest
+		}
+		sessionID = SessionID(conversationID, match.id)
+	})
+	return sessionID
+}
+
+// find returns the branch hashes belongs to (nil if it diverges from all of
+// them) and the branch sharing its longest prefix, with that prefix length.
+func (c *conversation) find(hashes []string) (match, closest *branch, closestLen int) {
+	// Same branch: the request and the branch agree on every message both have.
+	// Among several (a request shorter than a fork point), prefer the longest
+	// agreement, then the most recently used.
+	matchLen := -1
+	closestLen = -1
+	for _, b := range c.branches {
+		n := commonPrefix(b.chain, hashes)
+		if n == len(b.chain) || n == len(hashes) {
+			if n > matchLen || (n == matchLen && b.lastSeen.After(match.lastSeen)) {
+				match, matchLen = b, n
+			}
+		}
+		if n > closestLen || (n == closestLen && b.lastSeen.After(closest.lastSeen)) {
+			closest, closestLen = b, n
+		}
+	}
+	return match, closest, closestLen
+}
+
+// Branches returns the number of branches tracked for conversationID.
+func (t *Tracker) Branches(conversationID string) int {
+	n := 0
+	t.convs.View(conversationID, func(c *conversation) {
+		n = len(c.branches)
+	})
+	return n
+}
+
+// evictOldest drops the least recently used non-root branch and returns its ID.
+func (c *conversation) evictOldest() (string, bool) {
+	oldest := -1
+	for i, b := range c.branches[1:] {
+		if oldest < 0 || b.lastSeen.Before(c.branches[oldest].lastSeen) {
+			oldest = i + 1
+		}
+	}
+	if oldest <= 0 {
+		return "", false
+	}
+	id := c.branches[oldest].id
+	c.branches = append(c.branches[:oldest], c.branches[oldest+1:]...)
+	return id, true
+}
+
+func commonPrefix(a, b []string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// PREFIX HASHING
+
+// PrefixHashes returns one hash per message: hashes[i] covers messages[0..i].
+//
+// The first message contributes only its role. It already identifies the
+// conversation (the session ID is derived from it), and agents rewrite
+// injected context inside it between turns, which must not look like a fork.
+// cache_control markers are ignored for the same reason: clients move them
+// to the newest message on every turn.
+func PrefixHashes(messages []json.RawMessage) []string {
+	if len(messages) == 0 {
+		return nil
+	}
+	hashes := make([]string, len(messages))
+	h := sha256.New()
+	for i, msg := range messages {
+		if i == 0 {
+			h.Write([]byte(gjson.GetBytes(msg, "role").String()))
+		} else {
+			h.Write(canonical(msg))
+		}
+		h.Write([]byte{0})
+		hashes[i] = hex.EncodeToString(h.Sum(nil))[:hashLen]
+	}
+	return hashes
+}
+
+// HashBody returns the prefix hashes of a request body's conversation
+// (messages, Responses API input, or Gemini contents).
+func HashBody(body []byte) []string {
+	for _, field := range []string{"messages", "input", "contents"} {
+		v := gjson.GetBytes(body, field)
+		if !v.IsArray() {
+			continue
+		}
+		items := v.Array()
+		messages := make([]json.RawMessage, len(items))
+		for i, item := range items {
+			messages[i] = json.RawMessage(item.Raw)
+		}
+		return PrefixHashes(messages)
+	}
+	return nil
+}
+
+// canonical re-encodes msg with sorted keys and without cache_control.
+func canonical(msg json.RawMessage) []byte {
+	var v any
+	if err := json.Unmarshal(msg, &v); err != nil {
+		return msg
+	}
+	out, err := json.Marshal(stripCacheControl(v))
+	if err != nil {
+		return msg
+	}
+	return out
+}
+
+func stripCacheControl(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		delete(t, "cache_control")
+		for k, item := range t {
+			t[k] = stripCacheControl(item)
+		}
+	case []any:
+		for i, item := range t {
+			t[i] = stripCacheControl(item)
+		}
+	}
+	return v
+}
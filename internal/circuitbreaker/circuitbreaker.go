@@ -13,6 +13,15 @@ const DefaultMaxFailures = 5
 // DefaultOpenDuration is the default time the circuit stays open before allowing a retry.
 const DefaultOpenDuration = 30 * time.Second
 
+// State is the circuit state reported by State.
+type State string
+
+const (
+	StateClosed   State = "closed"    // Calls pass through
+	StateOpen     State = "open"      // Calls are rejected until openDuration elapses
+	StateHalfOpen State = "half_open" // Probe calls are let through to test recovery
+)
+
 // CircuitBreaker prevents repeated calls to a failing API.
 // When consecutiveFailures reaches maxFailures, the circuit opens
 // for openDuration. During that time, calls are immediately rejected.
@@ -24,6 +33,11 @@ type CircuitBreaker struct {
 	openUntil           time.Time
 	maxFailures         int
 	openDuration        time.Duration
+
+	// singleProbe: half-open admits one probe at a time and a failed probe
+	// reopens the circuit. probeUntil is when an unanswered probe expires.
+	singleProbe bool
+	probeUntil  time.Time
 }
 
 // Option configures a CircuitBreaker.
@@ -43,6 +57,15 @@ func WithOpenDuration(d time.Duration) Option {
 	}
 }
 
+// WithSingleProbe makes the half-open state admit one probe call at a time
+// instead of every call. A failed probe reopens the circuit for another
+// openDuration; a probe never recorded expires after openDuration.
+func WithSingleProbe() Option {
+	return func(cb *CircuitBreaker) {
+		cb.singleProbe = true
+	}
+}
+
 // New creates a new CircuitBreaker with the given options.
 func New(opts ...Option) *CircuitBreaker {
 	cb := &CircuitBreaker{
@@ -60,10 +83,17 @@ func (cb *CircuitBreaker) Allow() bool {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 	if cb.consecutiveFailures >= cb.maxFailures {
-		if time.Now().Before(cb.openUntil) {
+		now := time.Now()
+		if now.Before(cb.openUntil) {
 			return false // circuit open — reject immediately
 		}
 		// Half-open: allow one probe request through
+		if cb.singleProbe {
+			if now.Before(cb.probeUntil) {
+				return false // probe still in flight
+			}
+			cb.probeUntil = now.Add(cb.openDuration)
+		}
 	}
 	return true
 }
@@ -74,18 +104,26 @@ func (cb *CircuitBreaker) RecordSuccess() {
 	defer cb.mu.Unlock()
 	cb.consecutiveFailures = 0
 	cb.openUntil = time.Time{}
+	cb.probeUntil = time.Time{}
 }
 
 // RecordFailure increments the failure count and opens the circuit if threshold is reached.
 // In half-open state (openUntil already passed), a failed probe does NOT extend openUntil —
 // it keeps the circuit tripped until the next probe window expires naturally.
+// With WithSingleProbe, a failed probe reopens the circuit for openDuration.
 func (cb *CircuitBreaker) RecordFailure() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 	cb.consecutiveFailures++
+	now := time.Now()
+	if cb.singleProbe && !cb.openUntil.IsZero() && !now.Before(cb.openUntil) {
+		cb.openUntil = now.Add(cb.openDuration)
+		cb.probeUntil = time.Time{}
+		return
+	}
 	if cb.consecutiveFailures >= cb.maxFailures && cb.openUntil.IsZero() {
 		// Only set openUntil on the first time we hit the threshold, not on repeated probes.
-		cb.openUntil = time.Now().Add(cb.openDuration)
+		cb.openUntil = now.Add(cb.openDuration)
 	}
 }
 
@@ -96,10 +134,32 @@ func (cb *CircuitBreaker) IsOpen() bool {
 	return cb.consecutiveFailures >= cb.maxFailures && time.Now().Before(cb.openUntil)
 }
 
+// State returns the current circuit state.
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch {
+	case cb.consecutiveFailures < cb.maxFailures:
+		return StateClosed
+	case time.Now().Before(cb.openUntil):
+		return StateOpen
+	default:
+		return StateHalfOpen
+	}
+}
+
+// Failures returns the current count of consecutive failures.
+func (cb *CircuitBreaker) Failures() int {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.consecutiveFailures
+}
+
 // Reset manually resets the circuit breaker to closed state.
 func (cb *CircuitBreaker) Reset() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 	cb.consecutiveFailures = 0
 	cb.openUntil = time.Time{}
+	cb.probeUntil = time.Time{}
 }
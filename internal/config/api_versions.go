@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// API version enforcement modes.
+const (
+	APIVersionModeReject = "reject" // Fail the request with 400
+	APIVersionModeWarn   = "warn"   // Forward, but log the mismatch
+)
+
+// APIVersionsConfig pins and restricts the provider API version headers that
+// clients send (anthropic-version, OpenAI-Beta, ...), so the wire format the
+// gateway parses is the one it was written for.
+type APIVersionsConfig struct {
+	Enabled bool             `yaml:"enabled"`
+	Mode    string           `yaml:"mode"`  // reject (default) or warn, for client values not in allowed
+	Rules   []APIVersionRule `yaml:"rules"` // One rule per provider header
+}
+
+// APIVersionRule controls one version header for one provider.
+// Comma-separated header values (beta lists) are checked element by element.
+type APIVersionRule struct {
+	Provider string   `yaml:"provider"`          // anthropic, openai, gemini, ...
+	Header   string   `yaml:"header,omitempty"`  // Default: DefaultAPIVersionHeaders()[provider]
+	Pin      string   `yaml:"pin,omitempty"`     // Value sent upstream regardless of the client's
+	Allowed  []string `yaml:"allowed,omitempty"` // Client values accepted; empty accepts any
+}
+
+// Validate checks API version configuration.
+func (c *APIVersionsConfig) Validate() error {
+	switch c.Mode {
+	case "", APIVersionModeReject, APIVersionModeWarn:
+	default:
+		return fmt.Errorf("api_versions.mode must be %q or %q, got %q", APIVersionModeReject, APIVersionModeWarn, c.Mode)
+	}
+	seen := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if strings.TrimSpace(rule.Provider) == "" {
+			return fmt.Errorf("api_versions.rules[%d]: provider is required", i)
+		}
+		if rule.Header == "" {
+			return fmt.Errorf("api_versions.rules[%d] (%s): header is required (no default for this provider)", i, rule.Provider)
+		}
+		if rule.Pin == "" && len(rule.Allowed) == 0 {
+			return fmt.Errorf("api_versions.rules[%d] (%s %s): set pin, allowed, or both", i, rule.Provider, rule.Header)
+		}
+		key := strings.ToLower(rule.Provider) + "|" + strings.ToLower(rule.Header)
+		if seen[key] {
+			return fmt.Errorf("api_versions.rules[%d]: duplicate rule for %s %s", i, rule.Provider, rule.Header)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// UpstreamCircuitBreakerConfig trips a circuit per upstream host after
+// repeated 5xx responses, timeouts or connection failures. While a host's
+// circuit is open, requests to it fail fast with 502 instead of waiting for
+// the upstream timeout; after open_duration one probe request is let through
+// and its outcome closes or reopens the circuit.
+type UpstreamCircuitBreakerConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	MaxFailures  int           `yaml:"max_failures,omitempty"`  // Consecutive failures that open the circuit (default: 5)
+	OpenDuration time.Duration `yaml:"open_duration,omitempty"` // Fail-fast window before a probe (default: 30s)
+}
+
+// Validate checks circuit breaker configuration.
+func (c *UpstreamCircuitBreakerConfig) Validate() error {
+	if c.MaxFailures < 0 {
+		return fmt.Errorf("upstream_circuit_breaker.max_failures must not be negative, got %d", c.MaxFailures)
+	}
+	if c.OpenDuration < 0 {
+		return fmt.Errorf("upstream_circuit_breaker.open_duration must not be negative, got %s", c.OpenDuration)
+	}
+	return nil
+}
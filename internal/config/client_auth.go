@@ -0,0 +1,57 @@
+package config
+
+import "fmt"
+
+// ClientAuthConfig requires proxy clients to authenticate to the gateway
+// itself, so reaching the port is not enough to spend the configured provider
+// keys. A request passes with a configured key in X-Gateway-Key or, with
+// mtls set, a client certificate signed by client_ca_file. The key's label
+// (or the certificate's common name) is reported in telemetry and can be
+// used as a cost_control scope key.
+type ClientAuthConfig struct {
+	Enabled bool             `yaml:"enabled"`
+	APIKeys []ClientAPIKey   `yaml:"api_keys"`
+	MTLS    ClientMTLSConfig `yaml:"mtls,omitempty"`
+}
+
+// ClientAPIKey is one gateway key.
+type ClientAPIKey struct {
+	Key   string `yaml:"key"`   // Sent by clients in X-Gateway-Key
+	Label string `yaml:"label"` // Who holds the key; several keys may share one (rotation)
+}
+
+// ClientMTLSConfig accepts client certificates on the server.tls listener.
+// Read at startup only.
+type ClientMTLSConfig struct {
+	ClientCAFile string `yaml:"client_ca_file"` // CA bundle client certificates must chain to
+}
+
+// Enabled reports whether mTLS is configured.
+func (c ClientMTLSConfig) Enabled() bool {
+	return c.ClientCAFile != ""
+}
+
+// Validate checks the gateway keys.
+func (c *ClientAuthConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.APIKeys) == 0 && !c.MTLS.Enabled() {
+		return fmt.Errorf("client_auth: enabled needs api_keys or mtls")
+	}
+	seen := make(map[string]bool, len(c.APIKeys))
+	for i, k := range c.APIKeys {
+		switch {
+		case k.Key == "":
+			return fmt.Errorf("client_auth.api_keys[%d]: key is required", i)
+		case len(k.Key) < MinClientAPIKeyLen:
+			return fmt.Errorf("client_auth.api_keys[%d] (%s): key must be at least %d characters", i, k.Label, MinClientAPIKeyLen)
+		case k.Label == "":
+			return fmt.Errorf("client_auth.api_keys[%d]: label is required", i)
+		case seen[k.Key]:
+			return fmt.Errorf("client_auth.api_keys[%d] (%s): key is listed twice", i, k.Label)
+		}
+		seen[k.Key] = true
+	}
+	return nil
+}
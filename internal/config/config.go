@@ -3,6 +3,8 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -11,8 +13,16 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/compresr/context-gateway/internal/circuitbreaker"
 	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/echo"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/notify"
 	"github.com/compresr/context-gateway/internal/postsession"
+	"github.com/compresr/context-gateway/internal/priority"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/internal/tenancy"
+	"github.com/compresr/context-gateway/internal/tokenizer"
 )
 
 // PostSessionConfig is an alias for postsession.Config.
@@ -21,6 +31,18 @@ type PostSessionConfig = postsession.Config
 // CostControlConfig is an alias for costcontrol.CostControlConfig.
 type CostControlConfig = costcontrol.CostControlConfig
 
+// PriorityConfig is an alias for priority.Config.
+type PriorityConfig = priority.Config
+
+// FeatureFlagsConfig is an alias for featureflags.Config.
+type FeatureFlagsConfig = featureflags.Config
+
+// TokenizerConfig is an alias for tokenizer.Config.
+type TokenizerConfig = tokenizer.Config
+
+// TenancyConfig is an alias for tenancy.Config.
+type TenancyConfig = tenancy.Config
+
 // Config is the root configuration for the Context Gateway.
 // All fields are required - no defaults are applied.
 type Config struct {
@@ -32,12 +54,28 @@ type Config struct {
 	Monitoring    MonitoringConfig    `yaml:"monitoring"`    // Telemetry and logging
 	Preemptive    PreemptiveConfig    `yaml:"preemptive"`    // Preemptive summarization settings
 	Bedrock       BedrockConfig       `yaml:"bedrock"`       // AWS Bedrock support (opt-in)
+	LiteLLM       LiteLLMConfig       `yaml:"litellm"`       // LiteLLM proxy integration (opt-in)
 	CostControl   CostControlConfig   `yaml:"cost_control"`  // Cost control (session/global budget enforcement)
 	Notifications NotificationsConfig `yaml:"notifications"` // Notification integrations (Slack, etc.)
 	PostSession   PostSessionConfig   `yaml:"post_session"`  // Post-session CLAUDE.md updates
 	Dashboard     DashboardConfig     `yaml:"dashboard"`     // Dashboard UI settings
 	CompresrCreds CompresrCredsConfig `yaml:"compresr"`      // Centralized Compresr credentials (inherited by all pipes)
 
+	PassthroughCache       PassthroughCacheConfig       `yaml:"passthrough_cache"`        // TTL cache for idempotent passthrough endpoints
+	SessionGC              SessionGCConfig              `yaml:"session_gc"`               // Idle-session garbage collection
+	SessionPersistence     SessionPersistenceConfig     `yaml:"session_persistence"`      // Save session state across restarts
+	KeyPinning             KeyPinningConfig             `yaml:"key_pinning"`              // Provider key prefixes allowed per target host
+	APIVersions            APIVersionsConfig            `yaml:"api_versions"`             // Provider API version pins and allowlists
+	Priority               PriorityConfig               `yaml:"priority"`                 // Request priority classes and concurrency limit
+	FeatureFlags           FeatureFlagsConfig           `yaml:"feature_flags"`            // Per-session/user/percentage capability rollout
+	UpstreamCircuitBreaker UpstreamCircuitBreakerConfig `yaml:"upstream_circuit_breaker"` // Fail fast while an upstream host is down
+	UpstreamRetry          UpstreamRetryConfig          `yaml:"upstream_retry"`           // Retry transient upstream failures with backoff
+	ModelRewrite           ModelRewriteConfig           `yaml:"model_rewrite"`            // Model names rewritten before forwarding
+	Readiness              ReadinessConfig              `yaml:"readiness"`                // Dependency probes of GET /health/ready
+	Tokenizer              TokenizerConfig              `yaml:"tokenizer"`                // Token counting for telemetry and cost estimates
+	Tenancy                TenancyConfig                `yaml:"tenancy"`                  // Per-team provider keys, pipe settings and budgets
+	ClientAuth             ClientAuthConfig             `yaml:"client_auth"`              // Gateway keys or mTLS required of proxy clients
+
 	// Runtime-only fields (not loaded from YAML)
 	AgentFlags *AgentFlags `yaml:"-"` // Agent CLI flags, set at runtime by cmd/agent.go
 }
@@ -116,11 +154,104 @@ type BedrockConfig struct {
 	Enabled bool `yaml:"enabled"` // Must be true to enable Bedrock provider detection and SigV4 signing
 }
 
+// LiteLLMConfig puts the gateway in front of a LiteLLM proxy. When enabled,
+// requests without X-Target-URL go to the proxy, provider-prefixed model
+// names are forwarded unchanged for LiteLLM to route, and the cost LiteLLM
+// reports per response is what the cost tracker records.
+type LiteLLMConfig struct {
+	Enabled bool   `yaml:"enabled"` // Must be true to route to LiteLLM and use its reported costs
+	URL     string `yaml:"url"`     // Proxy base URL (default: LITELLM_PROVIDER_URL or http://localhost:4000)
+}
+
+// BaseURL returns the configured proxy URL, falling back to the
+// LITELLM_PROVIDER_URL environment variable and then LiteLLM's default port.
+func (c LiteLLMConfig) BaseURL() string {
+	if c.URL != "" {
+		return strings.TrimSuffix(c.URL, "/")
+	}
+	if v := os.Getenv("LITELLM_PROVIDER_URL"); v != "" {
+		return strings.TrimSuffix(v, "/")
+	}
+	return "http://localhost:4000"
+}
+
 // ServerConfig contains HTTP server settings.
 type ServerConfig struct {
 	Port         int           `yaml:"port"`          // Port to listen on
 	ReadTimeout  time.Duration `yaml:"read_timeout"`  // Max time to read request
 	WriteTimeout time.Duration `yaml:"write_timeout"` // Max time to write response
+
+	// Slow-client protection for streamed responses. Each relayed write gets
+	// its own deadline, and upstream data waits in a bounded per-stream
+	// buffer; when it fills, SlowClientPolicy closes the stream or drops events.
+	StreamWriteTimeout time.Duration `yaml:"stream_write_timeout,omitempty"` // Max time one client write may block (default 30s)
+	StreamBufferBytes  int           `yaml:"stream_buffer_bytes,omitempty"`  // Relay buffer per stream (default 1 MiB)
+	SlowClientPolicy   string        `yaml:"slow_client_policy,omitempty"`   // close (default) | drop
+
+	// SSEKeepaliveInterval sends ": ping" SSE comments to a streaming client
+	// while the upstream has not started its response, so idle-timeout
+	// proxies keep the connection open. Zero disables it.
+	SSEKeepaliveInterval time.Duration `yaml:"sse_keepalive_interval,omitempty"`
+
+	// StreamInterception controls how streams that may carry phantom tool
+	// calls (expand_context, tool search) are inspected: optimistic relays
+	// events as they arrive and holds back only from the first tool call;
+	// buffered holds the whole response until it is complete.
+	StreamInterception string `yaml:"stream_interception,omitempty"` // optimistic (default) | buffered
+
+	// CountTokens controls /v1/messages/count_tokens: compressed runs the body
+	// through the pipes first, so clients see the size the gateway would
+	// forward; passthrough forwards the body as sent.
+	CountTokens string `yaml:"count_tokens,omitempty"` // compressed (default) | passthrough
+
+	// MessageBatches controls /v1/messages/batches: compressed runs each
+	// item's params through the pipes before the batch is submitted;
+	// passthrough submits the items as sent.
+	MessageBatches string `yaml:"message_batches,omitempty"` // passthrough (default) | compressed
+
+	// Target replaces the upstream providers. "echo" answers every forward with
+	// the local fake provider in internal/echo (no tokens, no network); empty
+	// forwards to the real providers.
+	Target string `yaml:"target,omitempty"`
+
+	// AllowedHosts extends the SSRF allowlist for X-Target-URL: hostnames,
+	// IP addresses or CIDR ranges. Read per request, so reloads apply at once.
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty"`
+
+	// AdminToken opens the config admin API (/admin/config) to clients that
+	// send it as a bearer token. Empty keeps that API loopback-only.
+	AdminToken string `yaml:"admin_token,omitempty"`
+
+	// RateLimit sets the per-client token buckets in front of the proxy.
+	// Read per request, so reloads apply at once.
+	RateLimit RateLimitConfig `yaml:"rate_limit,omitempty"`
+
+	// TLS serves HTTPS (and HTTP/2) from the gateway itself. H2C also accepts
+	// HTTP/2 without TLS (prior knowledge), for clients behind a trusted proxy.
+	TLS TLSConfig `yaml:"tls,omitempty"`
+	H2C bool      `yaml:"h2c,omitempty"`
+}
+
+// RateLimitConfig is a token bucket per client. Zero values keep the
+// built-in limit of DefaultRateLimit requests per second per client IP.
+type RateLimitConfig struct {
+	Disabled bool     `yaml:"disabled,omitempty"` // Turn rate limiting off
+	By       []string `yaml:"by,omitempty"`       // ip and/or api_key; a request needs a token from each (default: [ip])
+	RPS      float64  `yaml:"rps,omitempty"`      // Sustained requests per second per client (default: 100)
+	Burst    int      `yaml:"burst,omitempty"`    // Bucket size: requests allowed at once (default: rps, rounded up)
+}
+
+// Validate checks the rate limit keys and rates.
+func (c RateLimitConfig) Validate() error {
+	for _, by := range c.By {
+		if by != RateLimitByIP && by != RateLimitByAPIKey {
+			return fmt.Errorf("invalid server.rate_limit.by entry: %q (must be %q or %q)", by, RateLimitByIP, RateLimitByAPIKey)
+		}
+	}
+	if c.RPS < 0 || c.Burst < 0 {
+		return fmt.Errorf("server.rate_limit: rps and burst must not be negative")
+	}
+	return nil
 }
 
 // URLsConfig contains upstream URL configuration.
@@ -128,9 +259,15 @@ type URLsConfig struct {
 	Compresr string `yaml:"compresr"` // Compresr platform URL (e.g., "https://api.compresr.ai")
 }
 
+// NotifyConfig is an alias for notify.Config.
+type NotifyConfig = notify.Config
+
 // NotificationsConfig controls notification integrations.
 type NotificationsConfig struct {
-	Slack SlackConfig `yaml:"slack"` // Slack notification settings
+	Slack SlackConfig `yaml:"slack"` // Slack notification settings (Claude Code hook)
+
+	// Gateway event webhooks (compaction, budget, outage): locale, templates, webhooks
+	NotifyConfig `yaml:",inline"`
 }
 
 // SlackConfig controls Slack notifications via Claude Code hooks.
@@ -145,10 +282,37 @@ type DashboardConfig struct {
 	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout"` // Inactivity window before heartbeat liveness check fires (default: 10m)
 }
 
-// StoreConfig contains shadow context store settings.
-type StoreConfig struct {
-	Type string        `yaml:"type"` // Store type: "memory"
-	TTL  time.Duration `yaml:"ttl"`  // Time-to-live for entries
+// StoreConfig is an alias for store.Config.
+type StoreConfig = store.Config
+
+// StoreRedisConfig is an alias for store.RedisConfig.
+type StoreRedisConfig = store.RedisConfig
+
+// PassthroughCacheConfig controls TTL caching of idempotent passthrough endpoints
+// such as token counting and model listings. Entries are keyed by target URL,
+// credential, and request body, so keys never share responses.
+type PassthroughCacheConfig struct {
+	Enabled    bool                     `yaml:"enabled"`     // Enable response caching
+	MaxEntries int                      `yaml:"max_entries"` // Max cached responses (default: 256)
+	Paths      map[string]time.Duration `yaml:"paths"`       // Request path → TTL (default: count_tokens 1m, /v1/models 10m)
+}
+
+// SessionGCConfig controls the session garbage collector, which sweeps
+// per-session state (tool sessions, auth fallback, conversation branches,
+// cost sessions, preemptive sessions) on one schedule. Preemptive sessions
+// keep their TTL in preemptive.session.summary_ttl.
+type SessionGCConfig struct {
+	Interval time.Duration            `yaml:"interval"` // Sweep period (default: 5m)
+	IdleTTL  map[string]time.Duration `yaml:"idle_ttl"` // Store name → idle TTL (defaults: DefaultSessionIdleTTLs)
+}
+
+// SessionPersistenceConfig saves tool discovery sessions, sticky auth
+// fallback and cost tracking to a SQLite file, so a restarted gateway resumes
+// the sessions of running agents.
+type SessionPersistenceConfig struct {
+	Enabled  bool          `yaml:"enabled"`            // Restore at startup, save periodically and at shutdown
+	Path     string        `yaml:"path,omitempty"`     // SQLite file (default: state directory/sessions.db)
+	Interval time.Duration `yaml:"interval,omitempty"` // Save period (default: 30s)
 }
 
 // envVarRe matches ${VAR:-default} and ${VAR} syntax.
@@ -256,6 +420,99 @@ func (c *Config) applyDefaults() {
 		c.Pipes.TaskOutput.Enabled = true
 	}
 
+	// Passthrough cache: bound memory and default to the well-known idempotent paths.
+	if c.PassthroughCache.MaxEntries <= 0 {
+		c.PassthroughCache.MaxEntries = DefaultPassthroughCacheEntries
+	}
+	if c.PassthroughCache.Enabled && len(c.PassthroughCache.Paths) == 0 {
+		c.PassthroughCache.Paths = DefaultPassthroughCachePaths()
+	}
+
+	// Session GC: sweep on the shared cleanup interval; fill in unset store TTLs.
+	if c.SessionGC.Interval <= 0 {
+		c.SessionGC.Interval = DefaultCleanupInterval
+	}
+	if c.SessionGC.IdleTTL == nil {
+		c.SessionGC.IdleTTL = make(map[string]time.Duration)
+	}
+	for name, ttl := range DefaultSessionIdleTTLs() {
+		if _, ok := c.SessionGC.IdleTTL[name]; !ok {
+			c.SessionGC.IdleTTL[name] = ttl
+		}
+	}
+
+	if c.SessionPersistence.Interval <= 0 {
+		c.SessionPersistence.Interval = DefaultSessionPersistenceInterval
+	}
+
+	// Key pinning: block by default; built-in provider pins when no rules are given.
+	if c.KeyPinning.Mode == "" {
+		c.KeyPinning.Mode = KeyPinningModeBlock
+	}
+	if c.KeyPinning.Enabled && len(c.KeyPinning.Rules) == 0 {
+		c.KeyPinning.Rules = DefaultKeyPinRules()
+	}
+
+	// API versions: reject unknown versions by default; fill the provider's standard header.
+	if c.APIVersions.Mode == "" {
+		c.APIVersions.Mode = APIVersionModeReject
+	}
+	for i := range c.APIVersions.Rules {
+		if c.APIVersions.Rules[i].Header == "" {
+			c.APIVersions.Rules[i].Header = DefaultAPIVersionHeaders()[strings.ToLower(c.APIVersions.Rules[i].Provider)]
+		}
+	}
+
+	// Upstream circuit breaker: same thresholds as the pipes' breakers.
+	if c.UpstreamCircuitBreaker.MaxFailures <= 0 {
+		c.UpstreamCircuitBreaker.MaxFailures = circuitbreaker.DefaultMaxFailures
+	}
+	if c.UpstreamCircuitBreaker.OpenDuration <= 0 {
+		c.UpstreamCircuitBreaker.OpenDuration = circuitbreaker.DefaultOpenDuration
+	}
+
+	// Upstream retries: a few quick attempts on overload and gateway errors.
+	c.UpstreamRetry = c.UpstreamRetry.WithDefaults()
+
+	// Readiness probes: bounded and cached.
+	if c.Readiness.Timeout <= 0 {
+		c.Readiness.Timeout = DefaultReadinessTimeout
+	}
+	if c.Readiness.CacheTTL <= 0 {
+		c.Readiness.CacheTTL = DefaultReadinessCacheTTL
+	}
+
+	// Slow-client protection for streamed responses.
+	if c.Server.StreamWriteTimeout <= 0 {
+		c.Server.StreamWriteTimeout = DefaultStreamWriteTimeout
+	}
+	if c.Server.StreamBufferBytes <= 0 {
+		c.Server.StreamBufferBytes = DefaultStreamBufferBytes
+	}
+	if c.Server.SlowClientPolicy == "" {
+		c.Server.SlowClientPolicy = SlowClientPolicyClose
+	}
+	if c.Server.StreamInterception == "" {
+		c.Server.StreamInterception = StreamInterceptionOptimistic
+	}
+	if c.Server.CountTokens == "" {
+		c.Server.CountTokens = CountTokensCompressed
+	}
+	if c.Server.MessageBatches == "" {
+		c.Server.MessageBatches = MessageBatchesPassthrough
+	}
+
+	// Request capture: bound by count and bytes.
+	if c.Monitoring.RequestCapture.MaxRequests <= 0 {
+		c.Monitoring.RequestCapture.MaxRequests = DefaultRequestCaptureRequests
+	}
+	if c.Monitoring.RequestCapture.MaxBytes <= 0 {
+		c.Monitoring.RequestCapture.MaxBytes = DefaultRequestCaptureBytes
+	}
+
+	// Tenant budgets: a "tenant" cost scope capped per tenant.
+	c.applyTenantScope()
+
 	// Propagate top-level compresr credentials to per-pipe sections.
 	c.applyCompresrFallbacks()
 }
@@ -342,6 +599,13 @@ func (c *Config) ApplySessionEnvOverrides() {
 		c.Monitoring.ExpandContextCallsPath = envPath
 	}
 
+	// Auto-derive ShadowEvalPath from CompressionLogPath in shadow mode, so
+	// evaluations land in the session directory next to the compression log.
+	if c.Monitoring.ShadowEvalPath == "" && c.Monitoring.CompressionLogPath != "" && c.Pipes.IsShadow() {
+		dir := filepath.Dir(c.Monitoring.CompressionLogPath)
+		c.Monitoring.ShadowEvalPath = filepath.Join(dir, "shadow_eval.jsonl")
+	}
+
 	// Auto-derive ExpandContextCallsPath from CompressionLogPath when missing.
 	// Handles stale configs that predate expand_context_calls_path.
 	if c.Monitoring.ExpandContextCallsPath == "" && c.Monitoring.CompressionLogPath != "" {
@@ -365,6 +629,26 @@ func (c *Config) ApplySessionEnvOverrides() {
 	}
 }
 
+// validHostnameRE matches valid hostnames (e.g. api.openai.com) and bare labels (e.g. localhost).
+// Allows letters, digits, hyphens, and dots. Does not allow IP literals (handled by net.ParseCIDR).
+var validHostnameRE = regexp.MustCompile(`^[a-z0-9]([a-z0-9\.\-]*[a-z0-9])?$`)
+
+// IsValidHostEntry returns true if entry is a valid hostname, IP address or
+// CIDR range. SSRF allowlist entries that fail it are rejected to prevent
+// accidental allowlist expansion via typos or injection.
+func IsValidHostEntry(entry string) bool {
+	// Accept CIDR ranges (e.g. 10.0.0.0/8)
+	if _, _, err := net.ParseCIDR(entry); err == nil {
+		return true
+	}
+	// Accept plain IP addresses
+	if net.ParseIP(entry) != nil {
+		return true
+	}
+	// Accept valid hostnames (lowercase enforced by caller)
+	return validHostnameRE.MatchString(entry)
+}
+
 // Validate checks if the configuration is valid.
 func (c *Config) Validate() error {
 	// Server validation
@@ -380,13 +664,54 @@ func (c *Config) Validate() error {
 	if c.Server.WriteTimeout <= 0 {
 		return fmt.Errorf("server.write_timeout must be positive")
 	}
+	switch c.Server.SlowClientPolicy {
+	case "", SlowClientPolicyClose, SlowClientPolicyDrop:
+	default:
+		return fmt.Errorf("invalid server.slow_client_policy: %q (must be %q or %q)", c.Server.SlowClientPolicy, SlowClientPolicyClose, SlowClientPolicyDrop)
+	}
+	if c.Server.SSEKeepaliveInterval < 0 {
+		return fmt.Errorf("server.sse_keepalive_interval must not be negative")
+	}
+	switch c.Server.StreamInterception {
+	case "", StreamInterceptionOptimistic, StreamInterceptionBuffered:
+	default:
+		return fmt.Errorf("invalid server.stream_interception: %q (must be %q or %q)", c.Server.StreamInterception, StreamInterceptionOptimistic, StreamInterceptionBuffered)
+	}
+	switch c.Server.CountTokens {
+	case "", CountTokensCompressed, CountTokensPassthrough:
+	default:
+		return fmt.Errorf("invalid server.count_tokens: %q (must be %q or %q)", c.Server.CountTokens, CountTokensCompressed, CountTokensPassthrough)
+	}
+	switch c.Server.MessageBatches {
+	case "", MessageBatchesPassthrough, MessageBatchesCompressed:
+	default:
+		return fmt.Errorf("invalid server.message_batches: %q (must be %q or %q)", c.Server.MessageBatches, MessageBatchesPassthrough, MessageBatchesCompressed)
+	}
+	if c.Server.Target != "" && c.Server.Target != echo.Target {
+		return fmt.Errorf("invalid server.target: %q (must be empty or %q)", c.Server.Target, echo.Target)
+	}
+	if err := c.Server.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if err := c.Server.TLS.Validate(); err != nil {
+		return err
+	}
+	for _, host := range c.Server.AllowedHosts {
+		if !IsValidHostEntry(strings.ToLower(host)) {
+			return fmt.Errorf("invalid server.allowed_hosts entry: %q (must be a hostname, IP or CIDR range)", host)
+		}
+	}
 
-	// Store validation
-	if c.Store.Type == "" {
-		return fmt.Errorf("store.type is required")
+	if c.LiteLLM.URL != "" {
+		u, err := url.Parse(c.LiteLLM.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid litellm.url: %q (must be an http(s) URL)", c.LiteLLM.URL)
+		}
 	}
-	if c.Store.TTL == 0 {
-		return fmt.Errorf("store.ttl is required")
+
+	// Store validation
+	if err := c.Store.Validate(); err != nil {
+		return err
 	}
 
 	// Providers validation (if defined)
@@ -406,11 +731,121 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	// Tenant registry validation (before cost control, which holds the tenant scope)
+	if err := c.validateTenancy(); err != nil {
+		return err
+	}
+
 	// Cost control validation
 	if err := c.CostControl.Validate(); err != nil {
 		return err
 	}
 
+	// Telemetry writer validation
+	if err := c.Monitoring.TelemetryWriter.Validate(); err != nil {
+		return fmt.Errorf("monitoring.telemetry_writer: %w", err)
+	}
+
+	// Log retention validation
+	if err := c.Monitoring.Retention.Validate(); err != nil {
+		return fmt.Errorf("monitoring.retention: %w", err)
+	}
+
+	// Fleet telemetry validation
+	if err := c.Monitoring.TelemetryExport.Validate(); err != nil {
+		return fmt.Errorf("monitoring: %w", err)
+	}
+	if err := c.Monitoring.TelemetryCollector.Validate(); err != nil {
+		return fmt.Errorf("monitoring: %w", err)
+	}
+
+	// Audit log validation
+	if err := c.Monitoring.Audit.Validate(); err != nil {
+		return fmt.Errorf("monitoring: %w", err)
+	}
+
+	// Tracing validation
+	if err := c.Monitoring.Tracing.Validate(); err != nil {
+		return fmt.Errorf("monitoring: %w", err)
+	}
+
+	// Log redaction validation
+	if err := c.Monitoring.Redaction.Validate(); err != nil {
+		return fmt.Errorf("monitoring.redaction: %w", err)
+	}
+
+	// Key pinning validation
+	if err := c.KeyPinning.Validate(); err != nil {
+		return err
+	}
+
+	// Client auth validation
+	if err := c.ClientAuth.Validate(); err != nil {
+		return err
+	}
+	if c.ClientAuth.Enabled && c.ClientAuth.MTLS.Enabled() && !c.Server.TLS.Enabled() {
+		return fmt.Errorf("client_auth.mtls needs server.tls")
+	}
+
+	// API version validation
+	if err := c.APIVersions.Validate(); err != nil {
+		return err
+	}
+
+	// Priority class validation
+	if err := c.Priority.Validate(); err != nil {
+		return err
+	}
+
+	// Feature flag validation
+	if err := c.FeatureFlags.Validate(); err != nil {
+		return err
+	}
+
+	// Upstream circuit breaker validation
+	if err := c.UpstreamCircuitBreaker.Validate(); err != nil {
+		return err
+	}
+	if err := c.ModelRewrite.Validate(); err != nil {
+		return err
+	}
+	if err := c.UpstreamRetry.Validate(); err != nil {
+		return err
+	}
+
+	// Readiness probe validation
+	if err := c.Readiness.Validate(); err != nil {
+		return err
+	}
+
+	// Token counter validation
+	if err := c.Tokenizer.Validate(); err != nil {
+		return err
+	}
+
+	// Notification webhook and template validation
+	if err := c.Notifications.Validate(); err != nil {
+		return err
+	}
+
+	// Passthrough cache validation
+	for path, ttl := range c.PassthroughCache.Paths {
+		if ttl <= 0 {
+			return fmt.Errorf("passthrough_cache.paths[%q]: ttl must be positive", path)
+		}
+	}
+
+	// Session GC validation
+	defaults := DefaultSessionIdleTTLs()
+	for name, ttl := range c.SessionGC.IdleTTL {
+		if _, ok := defaults[name]; !ok {
+			return fmt.Errorf("session_gc.idle_ttl[%q]: unknown store (valid: %s)", name, strings.Join(SessionStoreNames(), ", "))
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("session_gc.idle_ttl[%q]: ttl must be positive", name)
+		}
+	}
+
 	// Validate provider references
 	if err := c.ValidateUsedProviders(); err != nil {
 		return err
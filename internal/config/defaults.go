@@ -2,6 +2,7 @@
 package config
 
 import (
+	"sort"
 	"time"
 
 	"github.com/compresr/context-gateway/internal/compresr"
@@ -22,17 +23,32 @@ const DefaultCompressedTTL = 24 * time.Hour
 // DefaultCleanupInterval is the frequency for background cleanup goroutines.
 const DefaultCleanupInterval = 5 * time.Minute
 
+// DefaultSessionPersistenceInterval is how often session state is saved when
+// session_persistence is enabled.
+const DefaultSessionPersistenceInterval = 30 * time.Second
+
 // DefaultStaleTimeout is when entries are considered stale for cleanup.
 const DefaultStaleTimeout = 10 * time.Minute
 
 // RATE LIMITING
 
-// DefaultRateLimit is requests per second per IP.
+// DefaultRateLimit is requests per second per client.
 const DefaultRateLimit = 100
 
-// MaxRateLimitBuckets prevents memory exhaustion from too many IP buckets.
+// MaxRateLimitBuckets prevents memory exhaustion from too many client buckets.
 const MaxRateLimitBuckets = 10000
 
+// Rate limit keys: what identifies a client for its token bucket.
+const (
+	RateLimitByIP     = "ip"      // Client IP address
+	RateLimitByAPIKey = "api_key" // Provider credential on the request (hashed), in addition to the client IP
+)
+
+// CLIENT AUTH
+
+// MinClientAPIKeyLen is the shortest accepted client_auth key.
+const MinClientAPIKeyLen = 16
+
 // HTTP AND NETWORKING
 
 // DefaultBufferSize is the standard I/O buffer size.
@@ -51,6 +67,99 @@ const MaxResponseSize = 50 * 1024 * 1024
 // responses for expand_context detection. Prevents OOM on very large streams.
 const MaxStreamBufferSize = 50 * 1024 * 1024
 
+// PASSTHROUGH CACHE DEFAULTS
+
+// DefaultPassthroughCacheEntries caps the passthrough response cache.
+const DefaultPassthroughCacheEntries = 256
+
+// DefaultPassthroughCachePaths returns the default cacheable paths and TTLs.
+// Token counts depend only on the body; model listings change rarely.
+func DefaultPassthroughCachePaths() map[string]time.Duration {
+	return map[string]time.Duration{
+		"/v1/messages/count_tokens": time.Minute,
+		"/v1/models":                10 * time.Minute,
+	}
+}
+
+// READINESS DEFAULTS
+
+// DefaultReadinessTimeout bounds each dependency probe of GET /health/ready.
+const DefaultReadinessTimeout = 3 * time.Second
+
+// DefaultReadinessCacheTTL is how long probe results are reused, so frequent
+// readiness probes do not hit upstream providers on every call.
+const DefaultReadinessCacheTTL = 10 * time.Second
+
+// SESSION GC DEFAULTS
+
+// Session store names, as used in session_gc.idle_ttl and GC stats.
+const (
+	SessionStoreToolSessions   = "tool_sessions"
+	SessionStoreAuthFallback   = "auth_fallback"
+	SessionStoreBranches       = "branches"
+	SessionStoreCostSessions   = "cost_sessions"
+	SessionStorePreemptive     = "preemptive"
+	SessionStoreResponseChains = "response_chains" // Responses API response ID → session
+	SessionStoreAssistantRuns  = "assistant_runs"  // Assistants API runs awaiting their usage
+	SessionStoreMessageBatches = "message_batches" // Message Batches awaiting their results
+)
+
+// DefaultSessionIdleTTLs returns the default idle TTL per session store.
+// Cost sessions live longest so per-session budgets survive long breaks.
+func DefaultSessionIdleTTLs() map[string]time.Duration {
+	return map[string]time.Duration{
+		SessionStoreToolSessions:   time.Hour,
+		SessionStoreAuthFallback:   time.Hour,
+		SessionStoreBranches:       time.Hour,
+		SessionStoreCostSessions:   24 * time.Hour,
+		SessionStoreResponseChains: 24 * time.Hour,
+		SessionStoreAssistantRuns:  24 * time.Hour,
+		SessionStoreMessageBatches: 29 * 24 * time.Hour, // Results are kept 29 days
+	}
+}
+
+// SessionStoreNames returns the stores configurable in session_gc.idle_ttl, sorted.
+func SessionStoreNames() []string {
+	names := make([]string, 0, 4)
+	for name := range DefaultSessionIdleTTLs() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KEY PINNING DEFAULTS
+
+// DefaultKeyPinRules returns the built-in key pins for the major providers.
+// Longest prefix wins, so sk-ant- keys stay pinned to Anthropic even though
+// OpenAI accepts sk-.
+func DefaultKeyPinRules() []KeyPinRule {
+	return []KeyPinRule{
+		{Host: "api.anthropic.com", Prefixes: []string{"sk-ant-"}},
+		{Host: "api.openai.com", Prefixes: []string{"sk-"}},
+		{Host: "openrouter.ai", Prefixes: []string{"sk-or-"}},
+		{Host: "generativelanguage.googleapis.com", Prefixes: []string{"AIza"}},
+	}
+}
+
+// API VERSION DEFAULTS
+
+// DefaultAPIVersionHeaders maps providers to the header that carries their API version.
+func DefaultAPIVersionHeaders() map[string]string {
+	return map[string]string{
+		"anthropic": "anthropic-version",
+		"openai":    "OpenAI-Beta",
+	}
+}
+
+// REQUEST CAPTURE DEFAULTS
+
+// DefaultRequestCaptureRequests is the number of recent requests kept by request capture.
+const DefaultRequestCaptureRequests = 50
+
+// DefaultRequestCaptureBytes caps the bodies held by request capture (64MB).
+const DefaultRequestCaptureBytes = 64 * 1024 * 1024
+
 // TOOL DISCOVERY DEFAULTS
 
 // DefaultMaxSearchResults from gateway_search_tools.
@@ -69,6 +178,56 @@ const DefaultMinTokens = 512
 // Larger outputs skip compression (too expensive to process).
 const DefaultMaxTokens = 50000
 
+// UPSTREAM RETRY DEFAULTS
+
+// Upstream retry defaults (upstream_retry).
+const (
+	DefaultUpstreamRetryMaxAttempts    = 3
+	DefaultUpstreamRetryInitialBackoff = 500 * time.Millisecond
+	DefaultUpstreamRetryMaxBackoff     = 10 * time.Second
+)
+
+// DefaultModelFallbackStatus triggers upstream_retry.model_fallbacks: Anthropic's overloaded_error.
+const DefaultModelFallbackStatus = 529
+
+// DefaultUpstreamRetryStatuses returns the statuses retried by default:
+// rate limited, bad gateway, unavailable and Anthropic's overloaded.
+func DefaultUpstreamRetryStatuses() []int {
+	return []int{429, 502, 503, 529}
+}
+
+// STREAM RELAY DEFAULTS
+
+// DefaultStreamWriteTimeout bounds one write of a streamed response to the client.
+const DefaultStreamWriteTimeout = 30 * time.Second
+
+// DefaultStreamBufferBytes bounds upstream data waiting for a slow client (1 MiB per stream).
+const DefaultStreamBufferBytes = 1 << 20
+
+// Slow-client policies: what happens when a stream's relay buffer is full.
+const (
+	SlowClientPolicyClose = "close" // End the client's stream
+	SlowClientPolicyDrop  = "drop"  // Discard whole SSE events until the client catches up
+)
+
+// Stream interception modes: how responses that may contain phantom tool calls are inspected.
+const (
+	StreamInterceptionOptimistic = "optimistic" // Relay events as they arrive; hold back from the first tool call
+	StreamInterceptionBuffered   = "buffered"   // Hold the whole response until it is complete
+)
+
+// count_tokens modes: what /v1/messages/count_tokens counts.
+const (
+	CountTokensCompressed  = "compressed"  // The body after the compression pipes, as /v1/messages would forward it
+	CountTokensPassthrough = "passthrough" // The body as the client sent it
+)
+
+// message_batches modes: what /v1/messages/batches submits for each item.
+const (
+	MessageBatchesPassthrough = "passthrough" // Items as the client sent them
+	MessageBatchesCompressed  = "compressed"  // Items after the compression pipes, without phantom tools
+)
+
 // GATEWAY PORT RANGE
 
 // DefaultDashboardPort is the fixed port for the centralized dashboard.
@@ -100,5 +259,8 @@ const DefaultCompresrDocsURL = "https://docs.compresr.ai/gateway"
 // DefaultCompresrInstallURL is the gateway install script URL.
 const DefaultCompresrInstallURL = DefaultCompresrFrontendBaseURL + "/install_gateway.sh"
 
+// DefaultCompresrWindowsInstallURL is the PowerShell install script, published with each release.
+const DefaultCompresrWindowsInstallURL = "https://github.com/Compresr-ai/Context-Gateway/releases/latest/download/install.ps1"
+
 // DefaultCompresrDashboardURL is the API key dashboard URL.
 const DefaultCompresrDashboardURL = DefaultCompresrFrontendBaseURL + "/dashboard"
@@ -0,0 +1,433 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/notify"
+)
+
+// Redacted replaces secret values in the effective-config view.
+const Redacted = "[redacted]"
+
+// EffectiveConfig is a secret-free snapshot of the loaded configuration.
+// It is printed at startup and served at GET /config/effective so operators
+// can check what is actually running against what they think they configured.
+type EffectiveConfig struct {
+	Listeners        EffectiveListeners           `json:"listeners"`
+	UpstreamTarget   string                       `json:"upstream_target,omitempty"` // "echo" when upstream calls are answered locally
+	Pipes            EffectivePipes               `json:"pipes"`
+	Providers        map[string]EffectiveProvider `json:"providers"`
+	CostControl      CostControlConfig            `json:"cost_control"`
+	Priority         PriorityConfig               `json:"priority"`
+	FeatureFlags     FeatureFlagsConfig           `json:"feature_flags"`
+	Tenants          []EffectiveTenant            `json:"tenants,omitempty"`
+	ClientAuth       *EffectiveClientAuth         `json:"client_auth,omitempty"`
+	Notifications    EffectiveNotifications       `json:"notifications"`
+	Preemptive       EffectivePreemptive          `json:"preemptive"`
+	Telemetry        EffectiveTelemetry           `json:"telemetry"`
+	PassthroughCache EffectivePassthroughCache    `json:"passthrough_cache"`
+	Bedrock          bool                         `json:"bedrock_enabled"`
+	LiteLLM          string                       `json:"litellm_url,omitempty"`
+	AllowedHosts     []string                     `json:"allowed_hosts,omitempty"` // server.allowed_hosts
+	AdminToken       string                       `json:"admin_token,omitempty"`
+	CompresrAPIKey   string                       `json:"compresr_api_key,omitempty"`
+}
+
+// EffectiveListeners lists the ports the gateway binds.
+type EffectiveListeners struct {
+	Proxy        int    `json:"proxy"`
+	Dashboard    int    `json:"dashboard"`
+	ReadTimeout  string `json:"read_timeout"`
+	WriteTimeout string `json:"write_timeout"`
+
+	StreamWriteTimeout string `json:"stream_write_timeout"`
+	StreamBufferBytes  int    `json:"stream_buffer_bytes"`
+	SlowClientPolicy   string `json:"slow_client_policy"`
+	StreamInterception string `json:"stream_interception"`
+	SSEKeepalive       string `json:"sse_keepalive_interval,omitempty"` // Empty when off
+	CountTokens        string `json:"count_tokens"`
+	MessageBatches     string `json:"message_batches"`
+
+	TLS string `json:"tls,omitempty"` // cert_file | acme; empty serves plaintext
+	H2C bool   `json:"h2c,omitempty"`
+}
+
+// EffectivePipes reports enabled state and strategy per pipe.
+type EffectivePipes struct {
+	ToolOutput    EffectivePipe `json:"tool_output"`
+	ToolDiscovery EffectivePipe `json:"tool_discovery"`
+	TaskOutput    EffectivePipe `json:"task_output"`
+	CacheCompat   bool          `json:"cache_compat"`
+	ResponseCache bool          `json:"response_cache"`
+	Order         []string      `json:"order,omitempty"`     // Explicit pipe order; empty = default layout
+	Custom        []string      `json:"custom,omitempty"`    // pipes.custom as name (type), in run order
+	Injection     string        `json:"injection,omitempty"` // pipes.injection action; empty when disabled
+	Media         []string      `json:"media,omitempty"`     // pipes.media rules as "types action (keep N)"; empty when disabled
+}
+
+// EffectivePipe is the enabled/strategy pair for a single pipe.
+type EffectivePipe struct {
+	Enabled  bool   `json:"enabled"`
+	Strategy string `json:"strategy"`
+}
+
+// EffectiveProvider describes a configured LLM provider. The API key is never included.
+type EffectiveProvider struct {
+	Auth     string `json:"auth"`
+	Model    string `json:"model"`
+	Endpoint string `json:"endpoint"`
+	APIKey   string `json:"api_key,omitempty"` // Redacted when set
+}
+
+// EffectiveTenant describes a tenant without its keys.
+type EffectiveTenant struct {
+	Name         string   `json:"name"`
+	KeyPrefixes  int      `json:"key_prefixes"`            // Number of key prefixes; the prefixes are not shown
+	KeyHashes    int      `json:"key_hashes"`              // Number of key hashes
+	ProviderKeys []string `json:"provider_keys,omitempty"` // Providers the tenant has keys for
+	Budget       float64  `json:"budget_usd,omitempty"`
+	Pipes        bool     `json:"pipes_override,omitempty"` // Tenant overrides pipe settings
+}
+
+// EffectiveClientAuth describes client_auth without its keys.
+type EffectiveClientAuth struct {
+	Labels []string `json:"labels,omitempty"` // Labels of the gateway keys, sorted and deduplicated
+	MTLS   bool     `json:"mtls"`
+}
+
+// EffectiveNotifications reports notification webhooks. URLs and headers
+// often carry tokens, so only names, formats and events are shown.
+type EffectiveNotifications struct {
+	Locale   string                   `json:"locale"`
+	Webhooks []EffectiveNotifyWebhook `json:"webhooks,omitempty"`
+}
+
+// EffectiveNotifyWebhook is one webhook without its URL or headers.
+type EffectiveNotifyWebhook struct {
+	Name   string   `json:"name"`
+	Format string   `json:"format"`
+	Events []string `json:"events,omitempty"` // Empty = all events
+}
+
+// EffectivePreemptive reports preemptive summarization settings.
+type EffectivePreemptive struct {
+	Enabled          bool    `json:"enabled"`
+	TriggerThreshold float64 `json:"trigger_threshold"`
+	Strategy         string  `json:"strategy"`
+}
+
+// EffectiveTelemetry lists where telemetry and logs are written.
+type EffectiveTelemetry struct {
+	Enabled      bool              `json:"enabled"`
+	LogLevel     string            `json:"log_level"`
+	LogOutput    string            `json:"log_output"`
+	Destinations map[string]string `json:"destinations"` // name → path, only non-empty paths
+	Redaction    bool              `json:"redaction"`    // Bodies are scrubbed before logging
+	Retention    bool              `json:"retention"`    // JSONL logs are rotated and pruned
+}
+
+// EffectivePassthroughCache reports passthrough response caching.
+type EffectivePassthroughCache struct {
+	Enabled    bool              `json:"enabled"`
+	MaxEntries int               `json:"max_entries"`
+	Paths      map[string]string `json:"paths"` // path → TTL
+}
+
+// Effective builds the redacted, effective view of c.
+func (c *Config) Effective() EffectiveConfig {
+	eff := EffectiveConfig{
+		Listeners: EffectiveListeners{
+			Proxy:        c.Server.Port,
+			Dashboard:    DefaultDashboardPort,
+			ReadTimeout:  c.Server.ReadTimeout.String(),
+			WriteTimeout: c.Server.WriteTimeout.String(),
+
+			StreamWriteTimeout: c.Server.StreamWriteTimeout.String(),
+			StreamBufferBytes:  c.Server.StreamBufferBytes,
+			SlowClientPolicy:   c.Server.SlowClientPolicy,
+			StreamInterception: c.Server.StreamInterception,
+			CountTokens:        c.Server.CountTokens,
+			MessageBatches:     c.Server.MessageBatches,
+
+			H2C: c.Server.H2C,
+		},
+		UpstreamTarget: c.Server.Target,
+		Pipes: EffectivePipes{
+			ToolOutput:    EffectivePipe{Enabled: c.Pipes.ToolOutput.Enabled, Strategy: c.Pipes.ToolOutput.Strategy},
+			ToolDiscovery: EffectivePipe{Enabled: c.Pipes.ToolDiscovery.Enabled, Strategy: c.Pipes.ToolDiscovery.Strategy},
+			TaskOutput:    EffectivePipe{Enabled: c.Pipes.TaskOutput.Enabled, Strategy: c.Pipes.TaskOutput.Strategy},
+			CacheCompat:   c.Pipes.CacheCompat.Enabled,
+			ResponseCache: c.Pipes.ResponseCache.Enabled,
+			Order:         c.Pipes.Pipeline.Order,
+		},
+		Providers:    make(map[string]EffectiveProvider, len(c.Providers)),
+		CostControl:  c.CostControl,
+		Priority:     c.Priority,
+		FeatureFlags: c.FeatureFlags,
+		Notifications: EffectiveNotifications{
+			Locale: c.Notifications.Locale,
+		},
+		Preemptive: EffectivePreemptive{
+			Enabled:          c.Preemptive.Enabled,
+			TriggerThreshold: c.Preemptive.TriggerThreshold,
+			Strategy:         c.Preemptive.Summarizer.Strategy,
+		},
+		Telemetry: EffectiveTelemetry{
+			Enabled:      c.Monitoring.TelemetryEnabled,
+			LogLevel:     c.Monitoring.LogLevel,
+			LogOutput:    c.Monitoring.LogOutput,
+			Destinations: make(map[string]string),
+			Redaction:    c.Monitoring.Redaction.Enabled,
+			Retention:    c.Monitoring.Retention.Enabled,
+		},
+		PassthroughCache: EffectivePassthroughCache{
+			Enabled:    c.PassthroughCache.Enabled,
+			MaxEntries: c.PassthroughCache.MaxEntries,
+			Paths:      make(map[string]string, len(c.PassthroughCache.Paths)),
+		},
+		Bedrock:        c.Bedrock.Enabled,
+		CompresrAPIKey: redact(c.CompresrCreds.APIKey),
+		AllowedHosts:   c.Server.AllowedHosts,
+		AdminToken:     redact(c.Server.AdminToken),
+	}
+
+	if c.Pipes.Injection.Enabled {
+		eff.Pipes.Injection = c.Pipes.Injection.EffectiveAction()
+	}
+	if c.Pipes.Media.Enabled {
+		for _, rule := range c.Pipes.Media.EffectiveRules() {
+			eff.Pipes.Media = append(eff.Pipes.Media, fmt.Sprintf("%s %s (keep %d)", strings.Join(rule.Types, ","), rule.EffectiveAction(), rule.KeepRecent))
+		}
+	}
+	for i := range c.Pipes.Custom {
+		eff.Pipes.Custom = append(eff.Pipes.Custom, fmt.Sprintf("%s (%s)", c.Pipes.Custom[i].Name, c.Pipes.Custom[i].CustomType()))
+	}
+	if c.LiteLLM.Enabled {
+		eff.LiteLLM = c.LiteLLM.BaseURL()
+	}
+	if eff.Notifications.Locale == "" {
+		eff.Notifications.Locale = notify.DefaultLocale
+	}
+	for _, w := range c.Notifications.Webhooks {
+		format := w.Format
+		if format == "" {
+			format = notify.FormatGeneric
+		}
+		eff.Notifications.Webhooks = append(eff.Notifications.Webhooks, EffectiveNotifyWebhook{Name: w.Name, Format: format, Events: w.Events})
+	}
+
+	if c.Tenancy.Enabled {
+		for i := range c.Tenancy.Tenants {
+			t := &c.Tenancy.Tenants[i]
+			eff.Tenants = append(eff.Tenants, EffectiveTenant{
+				Name:         t.Name,
+				KeyPrefixes:  len(t.KeyPrefixes),
+				KeyHashes:    len(t.KeyHashes),
+				ProviderKeys: t.ProviderNames(),
+				Budget:       t.Budget,
+				Pipes:        len(t.Pipes) > 0,
+			})
+		}
+	}
+
+	switch {
+	case len(c.Server.TLS.ACME.Domains) > 0:
+		eff.Listeners.TLS = "acme"
+	case c.Server.TLS.Enabled():
+		eff.Listeners.TLS = "cert_file"
+	}
+	if c.Server.SSEKeepaliveInterval > 0 {
+		eff.Listeners.SSEKeepalive = c.Server.SSEKeepaliveInterval.String()
+	}
+	if c.ClientAuth.Enabled {
+		eff.ClientAuth = &EffectiveClientAuth{MTLS: c.ClientAuth.MTLS.Enabled()}
+		for _, k := range c.ClientAuth.APIKeys {
+			eff.ClientAuth.Labels = append(eff.ClientAuth.Labels, k.Label)
+		}
+		sort.Strings(eff.ClientAuth.Labels)
+		eff.ClientAuth.Labels = slices.Compact(eff.ClientAuth.Labels)
+	}
+
+	for name, p := range c.Providers {
+		auth := p.Auth
+		if auth == "" {
+			auth = "api_key"
+		}
+		eff.Providers[name] = EffectiveProvider{
+			Auth:     auth,
+			Model:    p.Model,
+			Endpoint: p.GetEndpoint(name),
+			APIKey:   redact(p.ProviderAuth),
+		}
+	}
+
+	for name, path := range map[string]string{
+		"telemetry":            c.Monitoring.TelemetryPath,
+		"compression":          c.Monitoring.CompressionLogPath,
+		"tool_discovery":       c.Monitoring.ToolDiscoveryLogPath,
+		"task_output":          c.Monitoring.TaskOutputLogPath,
+		"session_tools":        c.Monitoring.SessionToolsPath,
+		"session_stats":        c.Monitoring.SessionStatsPath,
+		"expand_context_calls": c.Monitoring.ExpandContextCallsPath,
+		"shadow_eval":          c.Monitoring.ShadowEvalPath,
+		"trajectory":           c.Monitoring.TrajectoryPath,
+	} {
+		if path != "" {
+			eff.Telemetry.Destinations[name] = path
+		}
+	}
+	if a := c.Monitoring.Audit; a.Enabled {
+		if a.Sink == monitoring.AuditSinkSyslog {
+			eff.Telemetry.Destinations["audit"] = monitoring.AuditSinkSyslog
+		} else {
+			eff.Telemetry.Destinations["audit"] = a.Path
+		}
+	}
+	if exp := c.Monitoring.TelemetryExport; exp.Enabled {
+		eff.Telemetry.Destinations["export"] = exp.Destination()
+	}
+	if col := c.Monitoring.TelemetryCollector; col.Enabled {
+		eff.Telemetry.Destinations["collector"] = col.OutputPath
+	}
+
+	for path, ttl := range c.PassthroughCache.Paths {
+		eff.PassthroughCache.Paths[path] = ttl.String()
+	}
+
+	return eff
+}
+
+// Summary renders the effective config as aligned "key: value" lines for the startup banner.
+func (e EffectiveConfig) Summary() []string {
+	pipe := func(p EffectivePipe) string {
+		if !p.Enabled {
+			return "disabled"
+		}
+		return p.Strategy
+	}
+	capStr := func(v float64) string {
+		if v == 0 {
+			return "unlimited"
+		}
+		return fmt.Sprintf("$%.2f", v)
+	}
+	byteCapStr := func(v int64) string {
+		if v == 0 {
+			return "unlimited"
+		}
+		return fmt.Sprintf("%d bytes", v)
+	}
+
+	proxyTLS := ""
+	if e.Listeners.TLS != "" {
+		proxyTLS = " (tls: " + e.Listeners.TLS + ")"
+	}
+
+	lines := []string{
+		fmt.Sprintf("listeners:       proxy :%d%s, dashboard :%d", e.Listeners.Proxy, proxyTLS, e.Listeners.Dashboard),
+		fmt.Sprintf("tool_output:     %s", pipe(e.Pipes.ToolOutput)),
+		fmt.Sprintf("tool_discovery:  %s", pipe(e.Pipes.ToolDiscovery)),
+		fmt.Sprintf("task_output:     %s", pipe(e.Pipes.TaskOutput)),
+		fmt.Sprintf("cache_compat:    %t", e.Pipes.CacheCompat),
+		fmt.Sprintf("response_cache:  %t", e.Pipes.ResponseCache),
+	}
+
+	if e.Pipes.Injection != "" {
+		lines = append(lines, fmt.Sprintf("injection scan:  %s", e.Pipes.Injection))
+	}
+	if len(e.Pipes.Media) > 0 {
+		lines = append(lines, fmt.Sprintf("media:           %s", strings.Join(e.Pipes.Media, ", ")))
+	}
+	if len(e.Pipes.Custom) > 0 {
+		lines = append(lines, fmt.Sprintf("custom pipes:    %s", strings.Join(e.Pipes.Custom, ", ")))
+	}
+	if e.UpstreamTarget != "" {
+		lines = append(lines, fmt.Sprintf("upstream:        %s (local fake provider, no tokens spent)", e.UpstreamTarget))
+	}
+
+	if len(e.Pipes.Order) > 0 {
+		lines = append(lines, fmt.Sprintf("pipe_order:      %s", strings.Join(e.Pipes.Order, " → ")))
+	}
+
+	if e.Preemptive.Enabled {
+		lines = append(lines, fmt.Sprintf("preemptive:      %s @ %.0f%%", e.Preemptive.Strategy, e.Preemptive.TriggerThreshold))
+	} else {
+		lines = append(lines, "preemptive:      disabled")
+	}
+
+	if e.CostControl.Enabled {
+		lines = append(lines, fmt.Sprintf("budget:          session %s, global %s",
+			capStr(e.CostControl.SessionCap), capStr(e.CostControl.GlobalCap)))
+		if e.CostControl.SessionEgressCap > 0 || e.CostControl.DailyEgressCap > 0 {
+			lines = append(lines, fmt.Sprintf("egress:          session %s, daily %s",
+				byteCapStr(e.CostControl.SessionEgressCap), byteCapStr(e.CostControl.DailyEgressCap)))
+		}
+		for _, sc := range e.CostControl.Scopes {
+			lines = append(lines, fmt.Sprintf("budget scope:    %s by %s, %s %s", sc.Name, sc.Key, capStr(sc.Cap), sc.EffectiveWindow()))
+		}
+	} else {
+		lines = append(lines, "budget:          disabled")
+	}
+
+	if e.Priority.Enabled {
+		limit := "unlimited"
+		if e.Priority.MaxConcurrent > 0 {
+			limit = fmt.Sprintf("%d (%d reserved for interactive)", e.Priority.MaxConcurrent, e.Priority.ReservedInteractive)
+		}
+		def := e.Priority.DefaultClass
+		if def == "" {
+			def = "interactive"
+		}
+		lines = append(lines, fmt.Sprintf("priority:        default %s, max_concurrent %s", def, limit))
+	}
+
+	if len(e.FeatureFlags.Flags) > 0 {
+		flags := make([]string, 0, len(e.FeatureFlags.Flags))
+		for name := range e.FeatureFlags.Flags {
+			flags = append(flags, name)
+		}
+		sort.Strings(flags)
+		lines = append(lines, "feature_flags:   "+strings.Join(flags, ", "))
+	}
+
+	if n := len(e.Notifications.Webhooks); n > 0 {
+		lines = append(lines, fmt.Sprintf("notifications:   %d webhook(s), locale %s", n, e.Notifications.Locale))
+	}
+
+	names := make([]string, 0, len(e.Providers))
+	for name := range e.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := e.Providers[name]
+		lines = append(lines, fmt.Sprintf("provider:        %s (%s, %s)", name, p.Model, p.Auth))
+	}
+
+	if e.Telemetry.Enabled && len(e.Telemetry.Destinations) > 0 {
+		dests := make([]string, 0, len(e.Telemetry.Destinations))
+		for name, path := range e.Telemetry.Destinations {
+			dests = append(dests, name+"="+path)
+		}
+		sort.Strings(dests)
+		lines = append(lines, "telemetry:       "+strings.Join(dests, ", "))
+	} else if e.Telemetry.Enabled {
+		lines = append(lines, "telemetry:       enabled (no file destinations)")
+	} else {
+		lines = append(lines, "telemetry:       disabled")
+	}
+
+	return lines
+}
+
+// redact hides a secret, keeping only whether it was set.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return Redacted
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Key pinning modes.
+const (
+	KeyPinningModeBlock = "block" // Refuse to forward and fail the request
+	KeyPinningModeAlert = "alert" // Forward, but log the violation
+)
+
+// KeyPinningConfig pins provider key prefixes to the hosts they may be sent
+// to, so a misrouted X-Target-URL cannot leak a key to an unexpected endpoint.
+// A key whose longest matching prefix is pinned to other hosts is never sent
+// elsewhere, and a host with rules only receives keys matching its prefixes.
+type KeyPinningConfig struct {
+	Enabled bool         `yaml:"enabled"`
+	Mode    string       `yaml:"mode"`  // block (default) or alert
+	Rules   []KeyPinRule `yaml:"rules"` // Default: DefaultKeyPinRules() when enabled and empty
+}
+
+// KeyPinRule lists the key prefixes allowed for one host.
+type KeyPinRule struct {
+	Host     string   `yaml:"host"`     // Exact host, or "*.example.com" for subdomains
+	Prefixes []string `yaml:"prefixes"` // e.g. ["sk-ant-"]
+}
+
+// Validate checks key pinning configuration.
+func (c *KeyPinningConfig) Validate() error {
+	switch c.Mode {
+	case "", KeyPinningModeBlock, KeyPinningModeAlert:
+	default:
+		return fmt.Errorf("key_pinning.mode must be %q or %q, got %q", KeyPinningModeBlock, KeyPinningModeAlert, c.Mode)
+	}
+	for i, rule := range c.Rules {
+		if strings.TrimSpace(rule.Host) == "" {
+			return fmt.Errorf("key_pinning.rules[%d]: host is required", i)
+		}
+		if len(rule.Prefixes) == 0 {
+			return fmt.Errorf("key_pinning.rules[%d] (%s): at least one prefix is required", i, rule.Host)
+		}
+		for _, p := range rule.Prefixes {
+			if p == "" {
+				return fmt.Errorf("key_pinning.rules[%d] (%s): empty prefix", i, rule.Host)
+			}
+		}
+	}
+	return nil
+}
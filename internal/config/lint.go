@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/compresr/context-gateway/internal/compresr"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// Lint issue severities.
+const (
+	LintError   = "error"   // The gateway refuses the config, or silently does something else
+	LintWarning = "warning" // Loads, but probably not what was meant
+)
+
+// LintIssue is one problem found by Lint.
+type LintIssue struct {
+	Severity string
+	Path     string // YAML path (pipes.tool_discovery.strategy) or "line N"
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// Valid strategy names per pipe, as accepted at runtime.
+var (
+	toolOutputStrategies    = []string{StrategyPassthrough, StrategySimple, StrategyTrimming, StrategyLocal, StrategyCompresr, pipes.StrategyAPI, StrategyExternalProvider}
+	toolDiscoveryStrategies = []string{StrategyPassthrough, StrategyRelevance, StrategyCompresr, StrategyToolSearch, StrategyEmbeddings}
+	taskOutputStrategies    = []string{StrategyPassthrough, StrategyExternalProvider}
+	tokenCounters           = []string{tokenizer.CounterTiktoken, tokenizer.CounterApprox, tokenizer.CounterAnthropicAPI}
+)
+
+// unknownFieldRe matches yaml.v3's KnownFields error for an unknown key.
+var unknownFieldRe = regexp.MustCompile(`^field (\S+) not found in type (\S+)`)
+
+// Lint checks a config file more thoroughly than LoadFromBytes and reports
+// every issue instead of the first: unset env placeholders, unknown keys,
+// strategy and model names (with suggestions for typos), threshold ranges
+// and budget settings. The last check is Validate itself, reported only when
+// nothing else is an error.
+func Lint(data []byte) []LintIssue {
+	var issues []LintIssue
+	add := func(severity, path, format string, args ...any) {
+		issues = append(issues, LintIssue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Env placeholders without a default that resolve to nothing.
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, m := range envVarRe.FindAllStringSubmatch(line, -1) {
+			if os.Getenv(m[1]) == "" && m[2] == "" && !strings.Contains(m[0], ":-") {
+				add(LintWarning, fmt.Sprintf("line %d", i+1), "environment variable %s is not set; ${%s} resolves to an empty string", m[1], m[1])
+			}
+		}
+	}
+
+	expanded := []byte(expandEnvWithDefaults(string(data)))
+	var cfg Config
+	if err := yaml.Unmarshal(expanded, &cfg); err != nil {
+		add(LintError, "", "invalid YAML: %v", err)
+		return issues
+	}
+
+	// Keys the gateway does not know are dropped without a word.
+	dec := yaml.NewDecoder(bytes.NewReader(expanded))
+	dec.KnownFields(true)
+	var strict Config
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(&strict); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			path, rest, _ := strings.Cut(msg, ": ")
+			if m := unknownFieldRe.FindStringSubmatch(rest); m != nil {
+				if m[1] == "metadata" && m[2] == "config.Config" {
+					continue // Config menu name and description, read by the CLI
+				}
+				rest = fmt.Sprintf("unknown key %q", m[1])
+			}
+			add(LintWarning, path, "%s (ignored)", rest)
+		}
+	}
+
+	lintStrategy := func(path, value string, valid []string) {
+		if value == "" || containsString(valid, value) {
+			return
+		}
+		field := strings.ReplaceAll(path[strings.LastIndex(path, ".")+1:], "_", " ")
+		add(LintError, path, "unknown %s %q%s; valid: %s", field, value, suggest(value, valid), strings.Join(valid, ", "))
+	}
+	p := &cfg.Pipes
+	lintStrategy("pipes.tool_output.strategy", p.ToolOutput.Strategy, toolOutputStrategies)
+	lintStrategy("pipes.tool_output.fallback_strategy", p.ToolOutput.FallbackStrategy, toolOutputStrategies)
+	lintStrategy("pipes.tool_discovery.strategy", p.ToolDiscovery.Strategy, toolDiscoveryStrategies)
+	lintStrategy("pipes.tool_discovery.fallback_strategy", p.ToolDiscovery.FallbackStrategy, toolDiscoveryStrategies)
+	lintStrategy("pipes.task_output.strategy", p.TaskOutput.Strategy, taskOutputStrategies)
+	lintStrategy("preemptive.summarizer.strategy", cfg.Preemptive.Summarizer.Strategy, preemptive.StrategyNames())
+	lintStrategy("tokenizer.counter", cfg.Tokenizer.Counter, tokenCounters)
+
+	// LLM models: unknown ones still work, but are costed at the default rate.
+	lintModel := func(path, model string) {
+		if model == "" || costcontrol.KnownModel(model) {
+			return
+		}
+		add(LintWarning, path, "unknown model %q%s; costs use default pricing", model, suggest(model, costcontrol.ListModels()))
+	}
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lintModel("providers."+name+".model", cfg.Providers[name].Model)
+	}
+	sum := cfg.Preemptive.Summarizer
+	if sum.Strategy == preemptive.StrategyExternalProvider || sum.Strategy == "" {
+		lintModel("preemptive.summarizer.model", sum.Model)
+	}
+	lintModel("pipes.task_output.external_provider.model", p.TaskOutput.ExternalProvider.Model)
+
+	// Compresr models are per service; the prefix says which.
+	lintCompresrModel := func(path, model, prefix, def string) {
+		if model != "" && !strings.HasPrefix(model, prefix) {
+			add(LintWarning, path, "%q is not a %s* model; the default for this pipe is %q", model, prefix, def)
+		}
+	}
+	if pipes.IsAPIStrategy(p.ToolOutput.Strategy) {
+		lintCompresrModel("pipes.tool_output.compresr.model", p.ToolOutput.Compresr.Model, "toc_", compresr.DefaultToolOutputModel)
+	}
+	if p.ToolDiscovery.Strategy == StrategyCompresr || p.ToolDiscovery.Strategy == StrategyToolSearch {
+		lintCompresrModel("pipes.tool_discovery.compresr.model", p.ToolDiscovery.Compresr.Model, "tdc_", compresr.DefaultToolDiscoveryModel)
+	}
+	if sum.Strategy == preemptive.StrategyCompresr && sum.Compresr != nil {
+		lintCompresrModel("preemptive.summarizer.compresr.model", sum.Compresr.Model, "hcc_", compresr.DefaultHistoryModel)
+	}
+
+	// Threshold ranges.
+	if t := cfg.Preemptive.TriggerThreshold; t < 0 || t > 100 {
+		add(LintError, "preemptive.trigger_threshold", "must be between 0 and 100 (percent of the context window), got %g", t)
+	}
+	to := p.ToolOutput
+	if r := to.TargetCompressionRatio; r != 0 && (r < MinTargetCompressionRatio || r > MaxTargetCompressionRatio) {
+		add(LintError, "pipes.tool_output.target_compression_ratio", "must be between %.1f and %.1f, got %g", MinTargetCompressionRatio, MaxTargetCompressionRatio, r)
+	}
+	if r := to.RefusalThreshold; r < 0 || r >= 1 {
+		add(LintError, "pipes.tool_output.refusal_threshold", "must be in [0, 1) (minimum share of tokens saved), got %g", r)
+	}
+	if to.MinTokens < 0 || to.MaxTokens < 0 {
+		add(LintError, "pipes.tool_output", "min_tokens and max_tokens must not be negative")
+	} else if to.MinTokens > 0 && to.MaxTokens > 0 && to.MinTokens >= to.MaxTokens {
+		add(LintError, "pipes.tool_output", "min_tokens (%d) must be below max_tokens (%d); nothing would be compressed", to.MinTokens, to.MaxTokens)
+	}
+
+	// Budgets.
+	cc := cfg.CostControl
+	if !cc.Enabled && (cc.SessionCap > 0 || cc.GlobalCap > 0 || cc.SessionEgressCap > 0 || cc.DailyEgressCap > 0 || len(cc.Scopes) > 0) {
+		add(LintWarning, "cost_control", "caps are set but enabled is false; no budget is enforced")
+	}
+	if cc.SessionCap > 0 && cc.GlobalCap > 0 && cc.SessionCap > cc.GlobalCap {
+		add(LintWarning, "cost_control.session_cap", "%g exceeds global_cap %g; the global cap is hit first", cc.SessionCap, cc.GlobalCap)
+	}
+
+	// Tenants: provider keys behind a bare X-Tenant header are open to any client.
+	if cfg.Tenancy.Enabled {
+		for i, t := range cfg.Tenancy.Tenants {
+			if len(t.ProviderKeys) > 0 && len(t.KeyPrefixes) == 0 {
+				add(LintWarning, fmt.Sprintf("tenancy.tenants[%d]", i), "tenant %q has provider_keys but no key_prefixes; any client sending X-Tenant: %s uses its keys", t.Name, t.Name)
+			}
+		}
+	}
+
+	// ACME validates domains with TLS-ALPN-01, which always connects to 443.
+	if len(cfg.Server.TLS.ACME.Domains) > 0 && cfg.Server.Port != 443 {
+		add(LintWarning, "server.tls.acme", "server.port is %d; ACME challenges reach the domains on port 443, so certificates are only issued if 443 is forwarded to the gateway", cfg.Server.Port)
+	}
+
+	// Everything else LoadFromBytes would reject.
+	hasError := false
+	for _, issue := range issues {
+		hasError = hasError || issue.Severity == LintError
+	}
+	if !hasError {
+		cfg.ApplySessionEnvOverrides()
+		cfg.applyDefaults()
+		if err := cfg.Validate(); err != nil {
+			add(LintError, "", "%v", err)
+		}
+	}
+	return issues
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns ` (did you mean "x"?)` for the closest candidate within a
+// small edit distance, or "".
+func suggest(value string, candidates []string) string {
+	best, bestDist := "", len(value)/3+2
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(value), strings.ToLower(c)); d < bestDist || (d == bestDist && best != "" && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ModelRewriteConfig rewrites the model named in a request body before it is
+// forwarded, e.g. to send OpenRouter-style ids to the native provider. Rules
+// are tried in order and the first match wins. Without rules, the provider
+// prefixes anthropic/, openai/, google/ and meta/ are stripped; an empty list
+// (rules: []) turns rewriting off. Bedrock requests and LiteLLM mode, which
+// routes by the prefix, are never rewritten; OpenRouter requests get configured
+// rules only, not the default prefix stripping.
+type ModelRewriteConfig struct {
+	Rules []ModelRewriteRule `yaml:"rules"`
+}
+
+// ModelRewriteRule maps one model, or the models a regex matches, to another.
+type ModelRewriteRule struct {
+	Match string `yaml:"match,omitempty"` // Exact model name
+	Regex string `yaml:"regex,omitempty"` // RE2 pattern over the whole model name; To may use $1, ${name}
+	To    string `yaml:"to"`              // Model sent instead
+}
+
+// DefaultModelRewriteRules returns the rules used when none are configured:
+// provider prefixes are stripped, e.g. "anthropic/claude-3" -> "claude-3".
+func DefaultModelRewriteRules() []ModelRewriteRule {
+	return []ModelRewriteRule{{Regex: `^(?:anthropic|openai|google|meta)/(.+)$`, To: "$1"}}
+}
+
+// modelRewriteRegexps caches compiled rule patterns; rules are read per request.
+var modelRewriteRegexps sync.Map // pattern -> *regexp.Regexp
+
+func compileModelRewrite(pattern string) (*regexp.Regexp, error) {
+	if re, ok := modelRewriteRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	modelRewriteRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// Rewrite returns the model to send for model. ok is false when no rule
+// matches or the rule leaves the name unchanged.
+func (c *ModelRewriteConfig) Rewrite(model string) (string, bool) {
+	if model == "" {
+		return "", false
+	}
+	rules := c.Rules
+	if rules == nil {
+		rules = DefaultModelRewriteRules()
+	}
+	for _, rule := range rules {
+		var to string
+		switch {
+		case rule.Match != "":
+			if rule.Match != model {
+				continue
+			}
+			to = rule.To
+		case rule.Regex != "":
+			re, err := compileModelRewrite(rule.Regex)
+			if err != nil {
+				continue
+			}
+			m := re.FindStringSubmatchIndex(model)
+			if m == nil || m[0] != 0 || m[1] != len(model) {
+				continue
+			}
+			to = string(re.ExpandString(nil, rule.To, model, m))
+		default:
+			continue
+		}
+		return to, to != model
+	}
+	return "", false
+}
+
+// Validate checks that every rule has one matcher, a target and a valid regex.
+func (c *ModelRewriteConfig) Validate() error {
+	for i, rule := range c.Rules {
+		if (rule.Match == "") == (rule.Regex == "") {
+			return fmt.Errorf("model_rewrite.rules[%d]: set exactly one of match and regex", i)
+		}
+		if rule.To == "" {
+			return fmt.Errorf("model_rewrite.rules[%d]: to is required", i)
+		}
+		if rule.Regex != "" {
+			if _, err := compileModelRewrite(rule.Regex); err != nil {
+				return fmt.Errorf("model_rewrite.rules[%d]: invalid regex %q: %w", i, rule.Regex, err)
+			}
+		}
+	}
+	return nil
+}
@@ -1,6 +1,37 @@
 // Monitoring configuration - telemetry and logging settings.
 package config
 
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/redaction"
+	"github.com/compresr/context-gateway/internal/tracing"
+)
+
+// TelemetryWriterConfig is an alias for monitoring.AsyncWriterConfig.
+type TelemetryWriterConfig = monitoring.AsyncWriterConfig
+
+// TelemetryExportConfig is an alias for fleet.ExportConfig.
+type TelemetryExportConfig = fleet.ExportConfig
+
+// TelemetryCollectorConfig is an alias for fleet.CollectorConfig.
+type TelemetryCollectorConfig = fleet.CollectorConfig
+
+// AuditLogConfig is an alias for monitoring.AuditConfig.
+type AuditLogConfig = monitoring.AuditConfig
+
+// LogRetentionConfig is an alias for monitoring.RetentionConfig.
+type LogRetentionConfig = monitoring.RetentionConfig
+
+// TracingConfig is an alias for tracing.Config.
+type TracingConfig = tracing.Config
+
+// RedactionConfig is an alias for redaction.Config.
+type RedactionConfig = redaction.Config
+
 // MonitoringConfig contains all monitoring settings.
 type MonitoringConfig struct {
 	// Logging settings
@@ -14,6 +45,33 @@ type MonitoringConfig struct {
 	LogToStdout      bool   `yaml:"log_to_stdout"`     // Also log telemetry to stdout
 	VerbosePayloads  bool   `yaml:"verbose_payloads"`  // Log full request/response payloads
 
+	// TelemetryWriter tunes the async JSONL writers (queue, batching, fsync policy).
+	// A full queue drops events and counts them instead of slowing requests.
+	TelemetryWriter TelemetryWriterConfig `yaml:"telemetry_writer"`
+
+	// Retention rotates JSONL logs by size or age, gzips rotated files and
+	// deletes them beyond max_files or max_age. Also ages out trajectories.
+	Retention LogRetentionConfig `yaml:"retention"`
+
+	// TelemetryExport ships request telemetry in batches to a central
+	// collector, S3, ClickHouse or an HTTP endpoint, retried until acknowledged.
+	TelemetryExport TelemetryExportConfig `yaml:"telemetry_export"`
+
+	// TelemetryCollector accepts batches from other replicas on POST /telemetry/ingest.
+	TelemetryCollector TelemetryCollectorConfig `yaml:"telemetry_collector"`
+
+	// Audit records every upstream forward's auth header handling, auth mode
+	// decision, allowed-host decision and target URL resolution.
+	Audit AuditLogConfig `yaml:"audit"`
+
+	// Tracing exports OpenTelemetry spans per request stage over OTLP/HTTP.
+	Tracing TracingConfig `yaml:"tracing"`
+
+	// Redaction scrubs secrets (AWS keys, bearer tokens, emails, custom
+	// regexes) from bodies before they reach telemetry, compression,
+	// trajectory and session tools logs, and the fleet collector.
+	Redaction RedactionConfig `yaml:"redaction"`
+
 	// Additional log files
 	CompressionLogPath     string `yaml:"compression_log_path"`      // Log original vs compressed
 	ToolDiscoveryLogPath   string `yaml:"tool_discovery_log_path"`   // Log tool discovery filtering details
@@ -21,9 +79,58 @@ type MonitoringConfig struct {
 	SessionToolsPath       string `yaml:"session_tools_path"`        // Human-readable JSON catalog of all tools seen in the session
 	SessionStatsPath       string `yaml:"session_stats_path"`        // Live session_stats.json snapshot (rewritten every ~3s)
 	ExpandContextCallsPath string `yaml:"expand_context_calls_path"` // JSONL log of expand_context calls (original + compressed content)
+	ShadowEvalPath         string `yaml:"shadow_eval_path"`          // JSONL log of shadow mode evaluations (pipes.mode: shadow)
 
 	// Trajectory logging (ATIF format)
 	TrajectoryEnabled bool   `yaml:"trajectory_enabled"` // Enable trajectory logging
 	TrajectoryPath    string `yaml:"trajectory_path"`    // Path to trajectory.json file
 	AgentName         string `yaml:"agent_name"`         // Agent name for trajectory metadata
+
+	// RequestCapture keeps recent request bodies in memory, as received and as
+	// forwarded upstream, so /debug/requests/{id} can show exactly what changed.
+	RequestCapture RequestCaptureConfig `yaml:"request_capture"`
+}
+
+// RequestCaptureConfig bounds the in-memory request capture. Bodies may hold
+// secrets and PII, so capture is off by default and never written to disk.
+type RequestCaptureConfig struct {
+	Enabled     bool  `yaml:"enabled"`
+	MaxRequests int   `yaml:"max_requests"` // Most recent requests kept
+	MaxBytes    int64 `yaml:"max_bytes"`    // Total body bytes kept; oldest requests are dropped first
+}
+
+// RetainedLogPaths returns the JSONL logs that monitoring.retention rotates,
+// as configured. The task output path is the base of {base}_compression.jsonl.
+func (m MonitoringConfig) RetainedLogPaths() []string {
+	var paths []string
+	for _, p := range []string{
+		m.TelemetryPath,
+		m.CompressionLogPath,
+		m.ToolDiscoveryLogPath,
+		m.ExpandContextCallsPath,
+		m.ShadowEvalPath,
+	} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if m.TaskOutputLogPath != "" {
+		paths = append(paths, filepath.Clean(strings.TrimSuffix(m.TaskOutputLogPath, ".jsonl")+"_compression.jsonl"))
+	}
+	if m.Audit.Enabled && m.Audit.Sink != monitoring.AuditSinkSyslog && m.Audit.Path != "" {
+		paths = append(paths, m.Audit.Path)
+	}
+	if m.TelemetryCollector.Enabled && m.TelemetryCollector.OutputPath != "" {
+		paths = append(paths, m.TelemetryCollector.OutputPath)
+	}
+	return paths
+}
+
+// TrajectoryDir returns the directory of per-session trajectory files.
+func (m MonitoringConfig) TrajectoryDir() string {
+	dir := m.TrajectoryPath
+	if filepath.Ext(dir) != "" {
+		dir = filepath.Dir(dir)
+	}
+	return dir
 }
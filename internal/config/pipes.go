@@ -18,11 +18,13 @@ const (
 	StrategyExternalProvider = pipes.StrategyExternalProvider
 	StrategyRelevance        = pipes.StrategyRelevance
 	StrategyToolSearch       = pipes.StrategyToolSearch
+	StrategyEmbeddings       = pipes.StrategyEmbeddings
 
 	// Tool output specific strategies
 	StrategyCompresr = pipes.StrategyCompresr
 	StrategySimple   = pipes.StrategySimple
 	StrategyTrimming = pipes.StrategyTrimming
+	StrategyLocal    = pipes.StrategyLocal
 )
 
 // TYPE ALIASES FOR YAML UNMARSHALING
@@ -38,3 +40,6 @@ type ToolDiscoveryPipeConfig = pipes.ToolDiscoveryConfig
 
 // CompresrConfig is an alias for pipes.CompresrConfig.
 type CompresrConfig = pipes.CompresrConfig
+
+// ResponseCachePipeConfig is an alias for pipes.ResponseCacheConfig.
+type ResponseCachePipeConfig = pipes.ResponseCacheConfig
@@ -23,3 +23,6 @@ type ClaudeCodeDetectorConfig = preemptive.ClaudeCodeDetectorConfig
 
 // GenericDetectorConfig is an alias for preemptive.GenericDetectorConfig.
 type GenericDetectorConfig = preemptive.GenericDetectorConfig
+
+// VerificationConfig is an alias for preemptive.VerificationConfig.
+type VerificationConfig = preemptive.VerificationConfig
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ReadinessConfig selects the dependency probes of GET /health/ready. The
+// store and summarizer auth checks always run; network probes are opt-in
+// because they send requests to upstream providers and the Compresr API.
+type ReadinessConfig struct {
+	ProbeUpstreams bool     `yaml:"probe_upstreams"`
+	Upstreams      []string `yaml:"upstreams,omitempty"` // URLs to probe (default: endpoints of the configured providers)
+	ProbeCompresr  bool     `yaml:"probe_compresr"`      // Probe urls.compresr
+
+	Timeout  time.Duration `yaml:"timeout,omitempty"`   // Per-probe timeout (default: 3s)
+	CacheTTL time.Duration `yaml:"cache_ttl,omitempty"` // Reuse probe results this long (default: 10s)
+}
+
+// Validate checks readiness configuration.
+func (c *ReadinessConfig) Validate() error {
+	for i, raw := range c.Upstreams {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("readiness.upstreams[%d]: must be an http(s) URL, got %q", i, raw)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("readiness.timeout must not be negative, got %s", c.Timeout)
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("readiness.cache_ttl must not be negative, got %s", c.CacheTTL)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,6 +20,7 @@ import (
 // ConfigPatch represents a partial configuration update.
 // Nil fields are left unchanged.
 type ConfigPatch struct {
+	Server        *ServerPatch        `json:"server,omitempty"`
 	Preemptive    *PreemptivePatch    `json:"preemptive,omitempty"`
 	Pipes         *PipesPatch         `json:"pipes,omitempty"`
 	CostControl   *CostControlPatch   `json:"cost_control,omitempty"`
@@ -28,10 +30,16 @@ type ConfigPatch struct {
 
 // IsEmpty returns true if no fields are set in the patch.
 func (p ConfigPatch) IsEmpty() bool {
-	return p.Preemptive == nil && p.Pipes == nil && p.CostControl == nil &&
+	return p.Server == nil && p.Preemptive == nil && p.Pipes == nil && p.CostControl == nil &&
 		p.Notifications == nil && p.Monitoring == nil
 }
 
+// ServerPatch is a partial update for server config. Only settings read per
+// request can change at runtime; listeners and timeouts need a restart.
+type ServerPatch struct {
+	AllowedHosts *[]string `json:"allowed_hosts,omitempty"`
+}
+
 // PreemptivePatch is a partial update for preemptive summarization config.
 type PreemptivePatch struct {
 	Enabled          *bool    `json:"enabled,omitempty"`
@@ -79,9 +87,12 @@ type SchemaCompressionPatch struct {
 
 // CostControlPatch is a partial update for cost control config.
 type CostControlPatch struct {
-	Enabled    *bool    `json:"enabled,omitempty"`
-	SessionCap *float64 `json:"session_cap,omitempty"`
-	GlobalCap  *float64 `json:"global_cap,omitempty"`
+	Enabled            *bool    `json:"enabled,omitempty"`
+	Mode               *string  `json:"mode,omitempty"`
+	SessionCap         *float64 `json:"session_cap,omitempty"`
+	GlobalCap          *float64 `json:"global_cap,omitempty"`
+	SessionEgressBytes *int64   `json:"session_egress_bytes,omitempty"`
+	DailyEgressBytes   *int64   `json:"daily_egress_bytes,omitempty"`
 }
 
 // NotificationsPatch is a partial update for notifications config.
@@ -193,13 +204,18 @@ func (r *Reloader) Update(patch ConfigPatch) (*Config, error) {
 func (r *Reloader) UpdateSession(patch ConfigPatch) (*Config, error) {
 	r.mu.Lock()
 
-	// Accumulate into session overrides
-	mergePatch(&r.sessionOverrides, patch)
+	// Accumulate into a copy of the session overrides, kept only if valid
+	prev := r.sessionOverrides
+	var overrides ConfigPatch
+	mergePatch(&overrides, prev)
+	mergePatch(&overrides, patch)
+	r.sessionOverrides = overrides
 
 	// Recompute effective = base + session overrides
 	effective := r.computeEffective()
 
 	if err := effective.Validate(); err != nil {
+		r.sessionOverrides = prev
 		r.mu.Unlock()
 		return nil, fmt.Errorf("invalid config after session patch: %w", err)
 	}
@@ -252,6 +268,10 @@ func (r *Reloader) computeEffective() *Config {
 
 // applyPatchToConfig applies a ConfigPatch to a Config in-place.
 func applyPatchToConfig(cfg *Config, patch ConfigPatch) {
+	if patch.Server != nil && patch.Server.AllowedHosts != nil {
+		cfg.Server.AllowedHosts = append([]string(nil), *patch.Server.AllowedHosts...)
+	}
+
 	if patch.Preemptive != nil {
 		if patch.Preemptive.Enabled != nil {
 			cfg.Preemptive.Enabled = *patch.Preemptive.Enabled
@@ -323,12 +343,21 @@ func applyPatchToConfig(cfg *Config, patch ConfigPatch) {
 		if patch.CostControl.Enabled != nil {
 			cfg.CostControl.Enabled = *patch.CostControl.Enabled
 		}
+		if patch.CostControl.Mode != nil {
+			cfg.CostControl.Mode = *patch.CostControl.Mode
+		}
 		if patch.CostControl.SessionCap != nil {
 			cfg.CostControl.SessionCap = *patch.CostControl.SessionCap
 		}
 		if patch.CostControl.GlobalCap != nil {
 			cfg.CostControl.GlobalCap = *patch.CostControl.GlobalCap
 		}
+		if patch.CostControl.SessionEgressBytes != nil {
+			cfg.CostControl.SessionEgressCap = *patch.CostControl.SessionEgressBytes
+		}
+		if patch.CostControl.DailyEgressBytes != nil {
+			cfg.CostControl.DailyEgressCap = *patch.CostControl.DailyEgressBytes
+		}
 	}
 
 	if patch.Notifications != nil && patch.Notifications.Slack != nil {
@@ -349,6 +378,15 @@ func applyPatchToConfig(cfg *Config, patch ConfigPatch) {
 
 // mergePatch accumulates src into dst. Non-nil fields in src overwrite dst.
 func mergePatch(dst *ConfigPatch, src ConfigPatch) {
+	if src.Server != nil {
+		if dst.Server == nil {
+			dst.Server = &ServerPatch{}
+		}
+		if src.Server.AllowedHosts != nil {
+			dst.Server.AllowedHosts = src.Server.AllowedHosts
+		}
+	}
+
 	if src.Preemptive != nil {
 		if dst.Preemptive == nil {
 			dst.Preemptive = &PreemptivePatch{}
@@ -408,12 +446,21 @@ func mergePatch(dst *ConfigPatch, src ConfigPatch) {
 		if src.CostControl.Enabled != nil {
 			dst.CostControl.Enabled = src.CostControl.Enabled
 		}
+		if src.CostControl.Mode != nil {
+			dst.CostControl.Mode = src.CostControl.Mode
+		}
 		if src.CostControl.SessionCap != nil {
 			dst.CostControl.SessionCap = src.CostControl.SessionCap
 		}
 		if src.CostControl.GlobalCap != nil {
 			dst.CostControl.GlobalCap = src.CostControl.GlobalCap
 		}
+		if src.CostControl.SessionEgressBytes != nil {
+			dst.CostControl.SessionEgressBytes = src.CostControl.SessionEgressBytes
+		}
+		if src.CostControl.DailyEgressBytes != nil {
+			dst.CostControl.DailyEgressBytes = src.CostControl.DailyEgressBytes
+		}
 	}
 
 	if src.Notifications != nil {
@@ -552,7 +599,7 @@ func (r *Reloader) WatchFile(ctx context.Context, interval time.Duration) {
 				continue
 			}
 			lastMod = mod
-			if err := r.reloadFromFile(); err != nil {
+			if _, err := r.reloadFromFile(); err != nil {
 				log.Warn().Err(err).Str("path", r.filePath).Msg("config watch: reload failed")
 			} else {
 				log.Info().Str("path", r.filePath).Msg("config reloaded from file")
@@ -561,6 +608,20 @@ func (r *Reloader) WatchFile(ctx context.Context, interval time.Duration) {
 	}
 }
 
+// ErrNoConfigFile is returned by Reload when the gateway was started without
+// a config file.
+var ErrNoConfigFile = errors.New("no config file to reload")
+
+// Reload re-reads the config file now instead of waiting for WatchFile to
+// notice a change, and returns the new effective config. An invalid file
+// leaves the current config in place.
+func (r *Reloader) Reload() (*Config, error) {
+	if r.filePath == "" {
+		return nil, ErrNoConfigFile
+	}
+	return r.reloadFromFile()
+}
+
 // fileMod returns the modification time of the config file, or zero on error.
 func (r *Reloader) fileMod() time.Time {
 	info, err := os.Stat(r.filePath)
@@ -571,15 +632,16 @@ func (r *Reloader) fileMod() time.Time {
 }
 
 // reloadFromFile reads the config file, updates baseConfig, recomputes effective
-// config (preserving session overrides), and notifies subscribers.
-func (r *Reloader) reloadFromFile() error {
+// config (preserving session overrides), notifies subscribers, and returns the
+// new effective config.
+func (r *Reloader) reloadFromFile() (*Config, error) {
 	data, err := os.ReadFile(r.filePath) //#nosec G304 -- filePath is set at startup from a trusted CLI arg, cleaned via filepath.Abs in NewReloader
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return nil, fmt.Errorf("read file: %w", err)
 	}
 	newCfg, err := LoadFromBytes(data)
 	if err != nil {
-		return fmt.Errorf("parse config: %w", err)
+		return nil, fmt.Errorf("parse config: %w", err)
 	}
 	r.mu.Lock()
 	r.baseConfig = newCfg
@@ -592,5 +654,5 @@ func (r *Reloader) reloadFromFile() error {
 	for _, fn := range subs {
 		fn(effective)
 	}
-	return nil
+	return effective, nil
 }
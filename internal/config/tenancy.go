@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// TenantScopeName names the cost_control scope holding tenant budgets.
+const TenantScopeName = "tenant"
+
+// applyTenantScope makes tenant budgets a cost_control scope keyed by
+// tenant, so they are enforced, reported and persisted like any other
+// scope. An existing scope with key "tenant" is reused; caps it sets for a
+// tenant win over the tenant's budget.
+func (c *Config) applyTenantScope() {
+	if !c.Tenancy.Enabled {
+		return
+	}
+	var scope *costcontrol.BudgetScope
+	for i := range c.CostControl.Scopes {
+		if c.CostControl.Scopes[i].Key == costcontrol.ScopeKeyTenant {
+			scope = &c.CostControl.Scopes[i]
+			break
+		}
+	}
+	if scope == nil {
+		c.CostControl.Scopes = append(c.CostControl.Scopes, costcontrol.BudgetScope{
+			Name:   TenantScopeName,
+			Key:    costcontrol.ScopeKeyTenant,
+			Window: c.Tenancy.BudgetWindow,
+		})
+		scope = &c.CostControl.Scopes[len(c.CostControl.Scopes)-1]
+	}
+	for name, budget := range c.Tenancy.Budgets() {
+		if _, ok := scope.Caps[name]; ok {
+			continue
+		}
+		if scope.Caps == nil {
+			scope.Caps = make(map[string]float64)
+		}
+		scope.Caps[name] = budget
+	}
+}
+
+// ForTenant returns the config requests of tenant t run with: c with t's
+// pipes overrides applied on top of the pipes section. Fields the override
+// leaves out keep their top-level values; lists are replaced, not merged.
+// Returns c itself when t is nil or has no overrides.
+func (c *Config) ForTenant(t *tenancy.Tenant) (*Config, error) {
+	if t == nil || len(t.Pipes) == 0 {
+		return c, nil
+	}
+	// Round-trip through YAML for a deep copy, so the overlay never writes
+	// into maps shared with c.
+	base, err := yaml.Marshal(c.Pipes)
+	if err != nil {
+		return nil, err
+	}
+	override, err := yaml.Marshal(t.Pipes)
+	if err != nil {
+		return nil, err
+	}
+	var merged PipesConfig
+	if err := yaml.Unmarshal(base, &merged); err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(override))
+	dec.KnownFields(true) // A misspelled pipe setting is an error, not a silent no-op
+	if err := dec.Decode(&merged); err != nil {
+		return nil, err
+	}
+	tc := *c
+	tc.Pipes = merged
+	return &tc, nil
+}
+
+// validateTenancy checks the tenant registry, each tenant's pipes override
+// and that budgets can be enforced.
+func (c *Config) validateTenancy() error {
+	if err := c.Tenancy.Validate(); err != nil {
+		return err
+	}
+	if !c.Tenancy.Enabled {
+		return nil
+	}
+	switch c.Tenancy.BudgetWindow {
+	case "", costcontrol.WindowDaily, costcontrol.WindowWeekly, costcontrol.WindowTotal:
+	default:
+		return fmt.Errorf("tenancy.budget_window must be %q, %q or %q, got %q",
+			costcontrol.WindowDaily, costcontrol.WindowWeekly, costcontrol.WindowTotal, c.Tenancy.BudgetWindow)
+	}
+	for i := range c.Tenancy.Tenants {
+		t := &c.Tenancy.Tenants[i]
+		if t.Budget > 0 && !c.CostControl.Enabled {
+			return fmt.Errorf("tenancy.tenants[%d].budget needs cost_control.enabled", i)
+		}
+		tc, err := c.ForTenant(t)
+		if err != nil {
+			return fmt.Errorf("tenancy.tenants[%d].pipes: %w", i, err)
+		}
+		if err := tc.Pipes.Validate(); err != nil {
+			return fmt.Errorf("tenancy.tenants[%d].pipes: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// TLSConfig terminates TLS in the gateway, so remote clients and browsers can
+// connect without a reverse proxy. Set cert_file and key_file, or acme.domains
+// to obtain certificates from Let's Encrypt. HTTP/2 is negotiated over TLS.
+// Read at startup only.
+type TLSConfig struct {
+	CertFile string     `yaml:"cert_file,omitempty"` // Server certificate chain (PEM)
+	KeyFile  string     `yaml:"key_file,omitempty"`  // Server private key (PEM)
+	ACME     ACMEConfig `yaml:"acme,omitempty"`
+}
+
+// ACMEConfig obtains and renews certificates automatically. Challenges use
+// TLS-ALPN-01, so the gateway must be reachable on port 443 for each domain.
+type ACMEConfig struct {
+	Domains      []string `yaml:"domains,omitempty"`       // Hostnames to obtain certificates for
+	Email        string   `yaml:"email,omitempty"`         // Contact for expiry notices (optional)
+	CacheDir     string   `yaml:"cache_dir,omitempty"`     // Account and certificates (default ~/.config/context-gateway/acme)
+	DirectoryURL string   `yaml:"directory_url,omitempty"` // ACME directory (default Let's Encrypt production)
+}
+
+// Enabled reports whether the gateway serves HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.ACME.Domains) > 0
+}
+
+// Validate checks that exactly one certificate source is configured.
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("server.tls: cert_file and key_file must be set together")
+	}
+	if c.CertFile != "" && len(c.ACME.Domains) > 0 {
+		return fmt.Errorf("server.tls: use cert_file/key_file or acme, not both")
+	}
+	for i, d := range c.ACME.Domains {
+		if net.ParseIP(d) != nil || !validHostnameRE.MatchString(strings.ToLower(d)) {
+			return fmt.Errorf("server.tls.acme.domains[%d]: %q must be a hostname (no IPs or wildcards)", i, d)
+		}
+	}
+	if u := c.ACME.DirectoryURL; u != "" {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("server.tls.acme.directory_url: %q must be an https URL", u)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// UpstreamRetryConfig retries upstream forwards that fail before any response
+// reached the client: connection errors and retryable statuses (429, 502, 503,
+// 529 by default). Attempts back off exponentially, and a Retry-After header
+// from the upstream sets the wait instead. Streaming requests are retried only
+// until the upstream answers; a stream that fails midway is not replayed.
+//
+// When the retries are used up and the last status is in fallback_on (529
+// overloaded by default), a request whose model matches model_fallbacks is
+// sent once more with the fallback model.
+type UpstreamRetryConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`    // Attempts including the first (default: 3)
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"` // Wait before the first retry, doubled per retry (default: 500ms)
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`     // Cap on one wait; a longer Retry-After is not waited out (default: 10s)
+	RetryOn        []int         `yaml:"retry_on,omitempty"`        // Retried statuses (default: 429, 502, 503, 529)
+
+	ModelFallbacks []ModelFallbackRule `yaml:"model_fallbacks,omitempty"` // First matching rule wins
+	FallbackOn     []int               `yaml:"fallback_on,omitempty"`     // Statuses that trigger a model fallback (default: 529)
+}
+
+// ModelFallbackRule names the model to try when the requested one is overloaded.
+type ModelFallbackRule struct {
+	Model    string `yaml:"model"`    // path.Match glob on the requested model, e.g. "claude-sonnet-*"
+	Fallback string `yaml:"fallback"` // Model sent instead, e.g. "claude-haiku-4-5"
+}
+
+// WithDefaults fills zero fields with defaults.
+func (c UpstreamRetryConfig) WithDefaults() UpstreamRetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultUpstreamRetryMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultUpstreamRetryInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultUpstreamRetryMaxBackoff
+	}
+	if len(c.RetryOn) == 0 {
+		c.RetryOn = DefaultUpstreamRetryStatuses()
+	}
+	if len(c.FallbackOn) == 0 {
+		c.FallbackOn = []int{DefaultModelFallbackStatus}
+	}
+	return c
+}
+
+// FallbackModel returns the fallback for model from the first matching rule.
+func (c *UpstreamRetryConfig) FallbackModel(model string) (string, bool) {
+	if model == "" {
+		return "", false
+	}
+	for _, rule := range c.ModelFallbacks {
+		if ok, _ := path.Match(rule.Model, model); ok && rule.Fallback != model {
+			return rule.Fallback, true
+		}
+	}
+	return "", false
+}
+
+// FallsBackOn reports whether a final response with this status triggers a model fallback.
+func (c *UpstreamRetryConfig) FallsBackOn(status int) bool {
+	for _, s := range c.FallbackOn {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// RetriesStatus reports whether a response with this status is retried.
+func (c *UpstreamRetryConfig) RetriesStatus(status int) bool {
+	for _, s := range c.RetryOn {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks retry configuration.
+func (c *UpstreamRetryConfig) Validate() error {
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("upstream_retry.max_attempts must not be negative, got %d", c.MaxAttempts)
+	}
+	if c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return fmt.Errorf("upstream_retry: initial_backoff and max_backoff must not be negative")
+	}
+	if c.InitialBackoff > 0 && c.MaxBackoff > 0 && c.InitialBackoff > c.MaxBackoff {
+		return fmt.Errorf("upstream_retry.initial_backoff (%s) exceeds max_backoff (%s)", c.InitialBackoff, c.MaxBackoff)
+	}
+	for _, s := range append(append([]int(nil), c.RetryOn...), c.FallbackOn...) {
+		if s < 400 || s > 599 {
+			return fmt.Errorf("upstream_retry: %d in retry_on or fallback_on is not an HTTP error status", s)
+		}
+	}
+	for i, rule := range c.ModelFallbacks {
+		if rule.Model == "" || rule.Fallback == "" {
+			return fmt.Errorf("upstream_retry.model_fallbacks[%d]: model and fallback are required", i)
+		}
+		if _, err := path.Match(rule.Model, ""); err != nil {
+			return fmt.Errorf("upstream_retry.model_fallbacks[%d]: invalid model pattern %q: %w", i, rule.Model, err)
+		}
+	}
+	return nil
+}
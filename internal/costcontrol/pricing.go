@@ -1,6 +1,10 @@
 package costcontrol
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+)
 
 // ModelPricing holds per-million-token pricing for a model.
 type ModelPricing struct {
@@ -10,6 +14,10 @@ type ModelPricing struct {
 	CacheReadMultiplier  float64 // Multiplier for cache read tokens (e.g., 0.1 for Anthropic, 0.5 for OpenAI). 0 = inferred from model.
 }
 
+// BatchMultiplier scales the cost of a Message Batches item: batch requests
+// are billed at half the standard rates, cache pricing included.
+const BatchMultiplier = 0.5
+
 // modelPricingTable maps model names to their pricing.
 // Sources: platform.claude.com, developers.openai.com, ai.google.dev (Feb 2026)
 var modelPricingTable = map[string]ModelPricing{
@@ -61,6 +69,12 @@ var modelPricingTable = map[string]ModelPricing{
 	"gpt-4o-mini":            {InputPerMTok: 0.15, OutputPerMTok: 0.60},
 	"gpt-4o-mini-2024-07-18": {InputPerMTok: 0.15, OutputPerMTok: 0.60},
 
+	// Realtime (text token rates; audio tokens are billed at these rates too)
+	"gpt-realtime":                 {InputPerMTok: 4, OutputPerMTok: 16},
+	"gpt-realtime-mini":            {InputPerMTok: 0.60, OutputPerMTok: 2.40},
+	"gpt-4o-realtime-preview":      {InputPerMTok: 5, OutputPerMTok: 20},
+	"gpt-4o-mini-realtime-preview": {InputPerMTok: 0.60, OutputPerMTok: 2.40},
+
 	// o-series reasoning models
 	"o1":      {InputPerMTok: 15, OutputPerMTok: 60},
 	"o1-pro":  {InputPerMTok: 150, OutputPerMTok: 600},
@@ -178,6 +192,12 @@ var modelFamilyPricing = map[string]ModelPricing{
 	"o3":                {InputPerMTok: 2, OutputPerMTok: 8},
 	"o4-mini":           {InputPerMTok: 1.10, OutputPerMTok: 4.40},
 
+	// OpenAI Realtime (dated snapshots)
+	"gpt-realtime-mini":    {InputPerMTok: 0.60, OutputPerMTok: 2.40},
+	"gpt-realtime":         {InputPerMTok: 4, OutputPerMTok: 16},
+	"gpt-4o-mini-realtime": {InputPerMTok: 0.60, OutputPerMTok: 2.40},
+	"gpt-4o-realtime":      {InputPerMTok: 5, OutputPerMTok: 20},
+
 	// Google Gemini
 	"gemini-3.1-pro":        {InputPerMTok: 2, OutputPerMTok: 12},
 	"gemini-3-pro":          {InputPerMTok: 2, OutputPerMTok: 12},
@@ -204,12 +224,46 @@ func ListModels() []string {
 	return models
 }
 
+// Bedrock model IDs carry an optional cross-region inference prefix and a
+// vendor prefix: "us.anthropic.claude-sonnet-4-5-20250929-v1:0".
+var (
+	bedrockRegionPrefixes = []string{"us.", "eu.", "apac.", "global.", "us-gov.", "jp.", "au."}
+	bedrockVendorPrefixes = []string{"anthropic.", "meta.", "amazon.", "mistral.", "cohere.", "ai21.", "deepseek.", "openai.", "qwen."}
+)
+
+// normalizeModelName strips what routing adds to a model name: LiteLLM
+// provider prefixes ("openrouter/anthropic/claude-3-5-sonnet") and Bedrock
+// region and vendor prefixes.
+func normalizeModelName(model string) string {
+	for {
+		provider, name := adapters.SplitModelProvider(model)
+		if provider == "" {
+			break
+		}
+		model = name
+	}
+	for _, prefixes := range [][]string{bedrockRegionPrefixes, bedrockVendorPrefixes} {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(model, prefix) {
+				model = strings.TrimPrefix(model, prefix)
+				break
+			}
+		}
+	}
+	return model
+}
+
 // GetModelPricing returns pricing for a model.
 // Tries exact match, then prefix/family match (longest prefix wins), then default.
+// Provider-prefixed and Bedrock model IDs are matched by their bare model name.
 // Cache multipliers are inferred from the model name if not explicitly set.
 func GetModelPricing(model string) ModelPricing {
 	var p ModelPricing
 
+	if _, ok := modelPricingTable[model]; !ok {
+		model = normalizeModelName(model)
+	}
+
 	// Exact match
 	if exact, ok := modelPricingTable[model]; ok {
 		p = exact
@@ -280,3 +334,21 @@ func CalculateCostWithCache(inputTokens, outputTokens, cacheCreationTokens, cach
 	cacheReadCost := float64(cacheReadTokens) / 1_000_000 * pricing.InputPerMTok * readMult
 	return inputCost + outputCost + cacheWriteCost + cacheReadCost
 }
+
+// KnownModel reports whether model has an exact or family entry in the
+// pricing table. Unknown models are priced at the default rate.
+func KnownModel(model string) bool {
+	if _, ok := modelPricingTable[model]; ok {
+		return true
+	}
+	model = normalizeModelName(model)
+	if _, ok := modelPricingTable[model]; ok {
+		return true
+	}
+	for prefix := range modelFamilyPricing {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,384 @@
+package costcontrol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scope key kinds (BudgetScope.Key). Header and tag keys carry a name after
+// the colon: "header:X-Team", "tag:project".
+const (
+	ScopeKeyAPIKey    = "api_key"
+	ScopeKeyTenant    = "tenant" // Tenant resolved by tenancy
+	ScopeKeyClient    = "client" // Label of the client_auth key or certificate
+	ScopeKeyHeaderPfx = "header:"
+	ScopeKeyTagPfx    = "tag:"
+)
+
+// Scope reset windows (BudgetScope.Window). Days and weeks are UTC; weeks
+// start on Monday.
+const (
+	WindowDaily  = "daily"
+	WindowWeekly = "weekly"
+	WindowTotal  = "total" // Never resets
+)
+
+// ReasonScopeCost is the denial reason when a budget scope's cap is reached.
+const ReasonScopeCost = "scope_cost"
+
+// maxScopeValues bounds the distinct values tracked per scope; spend from
+// further values is pooled under ScopeValueOther.
+const maxScopeValues = 10_000
+
+// maxScopeValueLen truncates header and tag values used as scope values.
+const maxScopeValueLen = 128
+
+// ScopeValueOther collects spend once a scope tracks maxScopeValues values.
+const ScopeValueOther = "(other)"
+
+// BudgetScope caps spend per API key, tenant, team header or project tag, for
+// gateways shared by several people or projects. Each distinct value of Key
+// (one API key, one X-Team value, one project tag) has its own spend counter,
+// reset at the start of every window.
+type BudgetScope struct {
+	Name   string             `yaml:"name" json:"name"`               // Shown in the dashboard and in rejections
+	Key    string             `yaml:"key" json:"key"`                 // api_key | tenant | header:<Name> | tag:<name>
+	Cap    float64            `yaml:"cap" json:"cap"`                 // USD per value per window. 0 = track only.
+	Caps   map[string]float64 `yaml:"caps" json:"caps,omitempty"`     // Per-value caps overriding Cap (0 = unlimited)
+	Window string             `yaml:"window" json:"window,omitempty"` // daily (default) | weekly | total
+}
+
+// ScopeKey is the value a request has for one budget scope.
+type ScopeKey struct {
+	Scope string
+	Value string
+}
+
+// ScopeUsage is one scope value's spend in the current window.
+type ScopeUsage struct {
+	Scope    string     `json:"scope"`
+	Value    string     `json:"value"`
+	Window   string     `json:"window"`
+	Period   string     `json:"period"` // 2026-10-15, 2026-W42, or "total"
+	ResetsAt *time.Time `json:"resets_at,omitempty"`
+	Cost     float64    `json:"cost_usd"`
+	Cap      float64    `json:"cap_usd"` // 0 = no cap
+	Requests int        `json:"requests"`
+}
+
+// Exceeded reports whether the value has reached its cap.
+func (u ScopeUsage) Exceeded() bool {
+	return u.Cap > 0 && u.Cost >= u.Cap
+}
+
+func (s *BudgetScope) validate(i int, seen map[string]bool) error {
+	field := fmt.Sprintf("cost_control.scopes[%d]", i)
+	if s.Name == "" {
+		return fmt.Errorf("%s.name is required", field)
+	}
+	if seen[s.Name] {
+		return fmt.Errorf("%s.name %q is used twice", field, s.Name)
+	}
+	seen[s.Name] = true
+	switch {
+	case s.Key == ScopeKeyAPIKey, s.Key == ScopeKeyTenant, s.Key == ScopeKeyClient:
+	case strings.HasPrefix(s.Key, ScopeKeyHeaderPfx) && len(s.Key) > len(ScopeKeyHeaderPfx):
+	case strings.HasPrefix(s.Key, ScopeKeyTagPfx) && len(s.Key) > len(ScopeKeyTagPfx):
+	default:
+		return fmt.Errorf("%s.key must be %q, %q, %q, \"header:<Name>\" or \"tag:<name>\", got %q", field, ScopeKeyAPIKey, ScopeKeyTenant, ScopeKeyClient, s.Key)
+	}
+	if s.Cap < 0 {
+		return fmt.Errorf("%s.cap must be >= 0, got %f", field, s.Cap)
+	}
+	for value, c := range s.Caps {
+		if c < 0 {
+			return fmt.Errorf("%s.caps[%q] must be >= 0, got %f", field, value, c)
+		}
+	}
+	switch s.Window {
+	case "", WindowDaily, WindowWeekly, WindowTotal:
+	default:
+		return fmt.Errorf("%s.window must be %q, %q or %q, got %q", field, WindowDaily, WindowWeekly, WindowTotal, s.Window)
+	}
+	return nil
+}
+
+// EffectiveWindow returns Window, defaulting to WindowDaily.
+func (s *BudgetScope) EffectiveWindow() string {
+	if s.Window == "" {
+		return WindowDaily
+	}
+	return s.Window
+}
+
+// CapFor returns the cap for one value of the scope.
+func (s *BudgetScope) CapFor(value string) float64 {
+	if c, ok := s.Caps[value]; ok {
+		return c
+	}
+	return s.Cap
+}
+
+// valueOf extracts the scope's value from a request, or "" if it has none.
+func (s *BudgetScope) valueOf(h http.Header, tags []string, tenant, client string) string {
+	var v string
+	switch {
+	case s.Key == ScopeKeyAPIKey:
+		if key := clientAPIKey(h); key != "" {
+			v = APIKeyID(key)
+		}
+	case s.Key == ScopeKeyTenant:
+		v = tenant
+	case s.Key == ScopeKeyClient:
+		v = client
+	case strings.HasPrefix(s.Key, ScopeKeyHeaderPfx):
+		v = strings.TrimSpace(h.Get(strings.TrimPrefix(s.Key, ScopeKeyHeaderPfx)))
+	case strings.HasPrefix(s.Key, ScopeKeyTagPfx):
+		name := strings.TrimPrefix(s.Key, ScopeKeyTagPfx)
+		for _, tag := range tags {
+			k, val, ok := strings.Cut(tag, "=")
+			if !ok {
+				k, val, ok = strings.Cut(tag, ":")
+			}
+			if ok && strings.EqualFold(strings.TrimSpace(k), name) {
+				v = strings.TrimSpace(val)
+				break
+			}
+		}
+	}
+	if len(v) > maxScopeValueLen {
+		v = v[:maxScopeValueLen]
+	}
+	return v
+}
+
+// clientAPIKey returns the provider credential a client sent.
+func clientAPIKey(h http.Header) string {
+	for _, name := range []string{"x-api-key", "x-goog-api-key", "api-key"} {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			return v
+		}
+	}
+	auth := strings.TrimSpace(h.Get("Authorization"))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// APIKeyID identifies an API key in budget scopes without revealing it: "key-"
+// and the first 12 hex digits of its SHA-256.
+func APIKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// ScopePeriod returns the window period containing t and when it ends
+// (zero for WindowTotal).
+func ScopePeriod(window string, t time.Time) (string, time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch window {
+	case WindowWeekly:
+		year, week := t.ISOWeek()
+		monday := day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return fmt.Sprintf("%d-W%02d", year, week), monday.AddDate(0, 0, 7)
+	case WindowTotal:
+		return WindowTotal, time.Time{}
+	default:
+		return day.Format(time.DateOnly), day.AddDate(0, 0, 1)
+	}
+}
+
+// scopeCounter is one scope value's spend (guarded by Tracker.scopeMu).
+type scopeCounter struct {
+	period   string
+	cost     float64
+	requests int
+}
+
+func scopeCounterKey(scope, value string) string {
+	return scope + "\x00" + value
+}
+
+// ScopeKeys returns the request's value for each configured scope, in config
+// order. Scopes the request has no value for are left out. tenant is the
+// request's tenant name and client its client_auth label, "" for none.
+func (t *Tracker) ScopeKeys(h http.Header, tags []string, tenant, client string) []ScopeKey {
+	cfg := t.Config()
+	var keys []ScopeKey
+	for i := range cfg.Scopes {
+		if v := cfg.Scopes[i].valueOf(h, tags, tenant, client); v != "" {
+			keys = append(keys, ScopeKey{Scope: cfg.Scopes[i].Name, Value: v})
+		}
+	}
+	return keys
+}
+
+// scopeUsageLocked returns the current-window usage of one scope value. Call with
+// t.scopeMu held.
+func (t *Tracker) scopeUsageLocked(s *BudgetScope, value string, now time.Time) ScopeUsage {
+	window := s.EffectiveWindow()
+	period, resetsAt := ScopePeriod(window, now)
+	u := ScopeUsage{Scope: s.Name, Value: value, Window: window, Period: period, Cap: s.CapFor(value)}
+	if !resetsAt.IsZero() {
+		u.ResetsAt = &resetsAt
+	}
+	if c, ok := t.scopes[scopeCounterKey(s.Name, value)]; ok && c.period == period {
+		u.Cost, u.Requests = c.cost, c.requests
+	}
+	return u
+}
+
+// checkScopes returns the usage of each of the request's scope values.
+func (t *Tracker) checkScopes(cfg CostControlConfig, keys []ScopeKey) []ScopeUsage {
+	if len(keys) == 0 {
+		return nil
+	}
+	now := time.Now()
+	t.scopeMu.Lock()
+	defer t.scopeMu.Unlock()
+	out := make([]ScopeUsage, 0, len(keys))
+	for _, k := range keys {
+		s := cfg.scope(k.Scope)
+		if s == nil {
+			continue
+		}
+		out = append(out, t.scopeUsageLocked(s, t.resolveValueLocked(k), now))
+	}
+	return out
+}
+
+// recordScopes adds cost to each of the request's scope values.
+func (t *Tracker) recordScopes(cfg CostControlConfig, keys []ScopeKey, cost float64) {
+	if len(keys) == 0 {
+		return
+	}
+	now := time.Now()
+	t.scopeMu.Lock()
+	defer t.scopeMu.Unlock()
+	for _, k := range keys {
+		s := cfg.scope(k.Scope)
+		if s == nil {
+			continue
+		}
+		value := t.resolveValueLocked(k)
+		period, _ := ScopePeriod(s.EffectiveWindow(), now)
+		key := scopeCounterKey(s.Name, value)
+		c, ok := t.scopes[key]
+		if !ok {
+			c = &scopeCounter{}
+			t.scopes[key] = c
+			t.scopeValues[s.Name]++
+		}
+		if c.period != period {
+			*c = scopeCounter{period: period}
+		}
+		c.cost += cost
+		c.requests++
+	}
+}
+
+// resolveValueLocked pools new values under ScopeValueOther once a scope
+// tracks maxScopeValues values.
+func (t *Tracker) resolveValueLocked(k ScopeKey) string {
+	if _, ok := t.scopes[scopeCounterKey(k.Scope, k.Value)]; ok || t.scopeValues[k.Scope] < maxScopeValues {
+		return k.Value
+	}
+	return ScopeValueOther
+}
+
+// sweepScopes drops counters from past windows.
+func (t *Tracker) sweepScopes() int {
+	cfg := t.Config()
+	now := time.Now()
+	t.scopeMu.Lock()
+	defer t.scopeMu.Unlock()
+	n := 0
+	for key, c := range t.scopes {
+		name, _, _ := strings.Cut(key, "\x00")
+		s := cfg.scope(name)
+		if s != nil {
+			if period, _ := ScopePeriod(s.EffectiveWindow(), now); period == c.period {
+				continue
+			}
+		}
+		delete(t.scopes, key)
+		t.scopeValues[name]--
+		n++
+	}
+	return n
+}
+
+// importScopes replaces counters with exported usages still in their window.
+func (t *Tracker) importScopes(usages []ScopeUsage) {
+	cfg := t.Config()
+	now := time.Now()
+	t.scopeMu.Lock()
+	defer t.scopeMu.Unlock()
+	for _, u := range usages {
+		s := cfg.scope(u.Scope)
+		if s == nil {
+			continue
+		}
+		if period, _ := ScopePeriod(s.EffectiveWindow(), now); period != u.Period {
+			continue
+		}
+		key := scopeCounterKey(u.Scope, u.Value)
+		if _, ok := t.scopes[key]; !ok {
+			t.scopeValues[u.Scope]++
+		}
+		t.scopes[key] = &scopeCounter{period: u.Period, cost: u.Cost, requests: u.Requests}
+	}
+}
+
+// ScopeSpend returns current-window spend for every tracked scope value, in
+// config order and by descending cost within a scope.
+func (t *Tracker) ScopeSpend() []ScopeUsage {
+	cfg := t.Config()
+	now := time.Now()
+	order := make(map[string]int, len(cfg.Scopes))
+	for i, s := range cfg.Scopes {
+		order[s.Name] = i
+	}
+
+	t.scopeMu.Lock()
+	out := make([]ScopeUsage, 0, len(t.scopes))
+	for key := range t.scopes {
+		name, value, _ := strings.Cut(key, "\x00")
+		s := cfg.scope(name)
+		if s == nil {
+			continue
+		}
+		if u := t.scopeUsageLocked(s, value, now); u.Requests > 0 {
+			out = append(out, u)
+		}
+	}
+	t.scopeMu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Scope != out[j].Scope {
+			return order[out[i].Scope] < order[out[j].Scope]
+		}
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost > out[j].Cost
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// scope returns the scope named name, or nil.
+func (c *CostControlConfig) scope(name string) *BudgetScope {
+	for i := range c.Scopes {
+		if c.Scopes[i].Name == name {
+			return &c.Scopes[i]
+		}
+	}
+	return nil
+}
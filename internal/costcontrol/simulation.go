@@ -0,0 +1,174 @@
+package costcontrol
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSimulatedRejections bounds the simulate-mode event log; the oldest events
+// are dropped first.
+const maxSimulatedRejections = 10_000
+
+// maxReportSessions caps the per-session breakdown in a SimulationReport.
+const maxReportSessions = 20
+
+// SimulatedRejection is one request that would have been blocked.
+type SimulatedRejection struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+	Reason    string    `json:"reason"`
+	Cost      float64   `json:"cost"`            // Session or global spend at the time, matching Reason
+	Cap       float64   `json:"cap"`             // Cost cap that was exceeded; 0 for egress reasons
+	Egress    int64     `json:"egress"`          // Session or daily egress at the time, matching Reason
+	EgressCap int64     `json:"egress_cap"`      // Egress cap that was exceeded; 0 for cost reasons
+	Scope     string    `json:"scope,omitempty"` // <scope>=<value> for scope_cost
+}
+
+// SimulationReport summarizes would-be rejections over a period.
+type SimulationReport struct {
+	Since           time.Time                `json:"since"`
+	Until           time.Time                `json:"until"`
+	WouldReject     int                      `json:"would_reject"`     // Requests that would have been blocked
+	SessionsBlocked int                      `json:"sessions_blocked"` // Distinct sessions that would have been cut off
+	ByReason        map[string]int           `json:"by_reason"`        // Would-be rejections per cap
+	FirstRejection  *time.Time               `json:"first_rejection,omitempty"`
+	Sessions        []SimulatedSessionReport `json:"sessions"`  // Most affected sessions first
+	Truncated       bool                     `json:"truncated"` // Oldest events were dropped; the period may be incomplete
+}
+
+// SimulatedSessionReport is the per-session part of a SimulationReport.
+type SimulatedSessionReport struct {
+	SessionID   string    `json:"session_id"`
+	WouldReject int       `json:"would_reject"`
+	FirstAt     time.Time `json:"first_at"`
+	LastAt      time.Time `json:"last_at"`
+	Reasons     []string  `json:"reasons"`
+	MaxCost     float64   `json:"max_cost"` // Highest session spend seen while over a cap
+}
+
+// SimulationLog records would-be rejections in simulate mode. Thread-safe.
+type SimulationLog struct {
+	mu      sync.Mutex
+	events  []SimulatedRejection
+	next    int  // Ring write position once full
+	dropped bool // Events have been overwritten
+}
+
+// NewSimulationLog creates an empty log.
+func NewSimulationLog() *SimulationLog {
+	return &SimulationLog{}
+}
+
+// Record adds a would-be rejection.
+func (l *SimulationLog) Record(ev SimulatedRejection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) < maxSimulatedRejections {
+		l.events = append(l.events, ev)
+		return
+	}
+	l.events[l.next] = ev
+	l.next = (l.next + 1) % maxSimulatedRejections
+	l.dropped = true
+}
+
+// Report summarizes events at or after since (zero = all retained events).
+func (l *SimulationLog) Report(since time.Time) SimulationReport {
+	l.mu.Lock()
+	events := make([]SimulatedRejection, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	events = append(events, l.events[:l.next]...)
+	dropped := l.dropped
+	l.mu.Unlock()
+
+	report := SimulationReport{
+		Since:    since,
+		Until:    time.Now(),
+		ByReason: make(map[string]int),
+		Sessions: []SimulatedSessionReport{},
+	}
+	sessions := make(map[string]*SimulatedSessionReport)
+	for _, ev := range events {
+		if ev.Time.Before(since) {
+			continue
+		}
+		report.WouldReject++
+		report.ByReason[ev.Reason]++
+		if report.FirstRejection == nil {
+			first := ev.Time
+			report.FirstRejection = &first
+		}
+
+		s, ok := sessions[ev.SessionID]
+		if !ok {
+			s = &SimulatedSessionReport{SessionID: ev.SessionID, FirstAt: ev.Time}
+			sessions[ev.SessionID] = s
+		}
+		s.WouldReject++
+		s.LastAt = ev.Time
+		if !containsString(s.Reasons, ev.Reason) {
+			s.Reasons = append(s.Reasons, ev.Reason)
+		}
+		if ev.Reason == ReasonSessionCost && ev.Cost > s.MaxCost {
+			s.MaxCost = ev.Cost
+		}
+	}
+
+	report.SessionsBlocked = len(sessions)
+	for _, s := range sessions {
+		report.Sessions = append(report.Sessions, *s)
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		if report.Sessions[i].WouldReject != report.Sessions[j].WouldReject {
+			return report.Sessions[i].WouldReject > report.Sessions[j].WouldReject
+		}
+		return report.Sessions[i].SessionID < report.Sessions[j].SessionID
+	})
+	if len(report.Sessions) > maxReportSessions {
+		report.Sessions = report.Sessions[:maxReportSessions]
+	}
+	// Only flag truncation when the dropped events could fall inside the period.
+	report.Truncated = dropped && len(events) > 0 && !events[0].Time.Before(since)
+	return report
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordSimulatedRejection logs a simulate-mode check result that would have
+// blocked the request. Results that were not simulated are ignored.
+func (t *Tracker) RecordSimulatedRejection(sessionID string, result BudgetCheckResult) {
+	if !result.Simulated {
+		return
+	}
+	ev := SimulatedRejection{Time: time.Now(), SessionID: sessionID, Reason: result.Reason}
+	switch result.Reason {
+	case ReasonGlobalCost:
+		ev.Cost, ev.Cap = result.GlobalCost, result.GlobalCap
+	case ReasonSessionCost:
+		ev.Cost, ev.Cap = result.CurrentCost, result.Cap
+	case ReasonDailyEgress:
+		ev.Egress, ev.EgressCap = result.DailyEgress, result.DailyEgressCap
+	case ReasonSessionEgress:
+		ev.Egress, ev.EgressCap = result.SessionEgress, result.SessionEgressCap
+	case ReasonScopeCost:
+		if result.Scope != nil {
+			ev.Cost, ev.Cap = result.Scope.Cost, result.Scope.Cap
+			ev.Scope = result.Scope.Scope + "=" + result.Scope.Value
+		}
+	}
+	t.simulation.Record(ev)
+}
+
+// SimulationReport summarizes simulate-mode rejections since the given time
+// (zero = everything retained).
+func (t *Tracker) SimulationReport(since time.Time) SimulationReport {
+	return t.simulation.Report(since)
+}
@@ -0,0 +1,56 @@
+package costcontrol
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// TrackerState is the serializable state of a Tracker (see Export).
+type TrackerState struct {
+	Sessions    json.RawMessage `json:"sessions"` // sessionstore records of CostSession
+	GlobalCost  float64         `json:"global_cost_usd"`
+	EgressDay   string          `json:"egress_day,omitempty"` // UTC day EgressBytes belongs to
+	EgressBytes int64           `json:"egress_bytes"`
+	Scopes      []ScopeUsage    `json:"scopes,omitempty"` // Budget scope spend, current windows only
+}
+
+// Export captures per-session costs and the global counters.
+func (t *Tracker) Export() (*TrackerState, error) {
+	sessions, err := t.sessions.Export()
+	if err != nil {
+		return nil, err
+	}
+	state := &TrackerState{
+		Sessions:   sessions,
+		GlobalCost: t.GetGlobalCost(),
+	}
+	t.egressMu.Lock()
+	state.EgressDay, state.EgressBytes = t.egressDay, t.egressBytes
+	t.egressMu.Unlock()
+	state.Scopes = t.ScopeSpend()
+	return state, nil
+}
+
+// Import restores a TrackerState. Sessions replace any with the same ID and
+// the global cost is replaced, so caps carry over to the restoring instance.
+// The daily egress total and budget scope spend are restored only while
+// their window is still current.
+// Returns the number of sessions restored.
+func (t *Tracker) Import(state *TrackerState) (int, error) {
+	if state == nil {
+		return 0, nil
+	}
+	n, err := t.sessions.Import(state.Sessions)
+	if err != nil {
+		return 0, err
+	}
+	atomic.StoreInt64(&t.globalCostNano, int64(state.GlobalCost*1e9))
+	if state.EgressDay == time.Now().UTC().Format(time.DateOnly) {
+		t.egressMu.Lock()
+		t.egressDay, t.egressBytes = state.EgressDay, state.EgressBytes
+		t.egressMu.Unlock()
+	}
+	t.importScopes(state.Scopes)
+	return n, nil
+}
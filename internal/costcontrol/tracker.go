@@ -6,35 +6,67 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
 )
 
+// sessionTTL is how long an idle session's cost is kept by default.
 const sessionTTL = 24 * time.Hour
 
 // Tracker tracks per-session API costs and enforces budget caps.
 // Cost tracking is always active. Budget enforcement only applies
 // when Enabled is true and at least one cap is configured.
 type Tracker struct {
-	config   CostControlConfig
-	sessions map[string]*CostSession
-	mu       sync.RWMutex
+	config CostControlConfig
+	mu     sync.RWMutex // guards config
+
+	// Sessions are locked individually. Expiry does NOT decrement
+	// globalCostNano — the global cap is absolute for the gateway's lifetime.
+	// Use ResetGlobalCost() for explicit resets.
+	sessions *sessionstore.Store[CostSession]
 
 	// Atomic global cost accumulator for O(1) budget checks
 	// Stored as cost * 1e9 (nano-dollars) to use atomic int64 ops
 	globalCostNano int64
 
-	stopChan  chan struct{}
-	closeOnce sync.Once
+	// Would-be rejections recorded in simulate mode.
+	simulation *SimulationLog
+
+	// Bytes forwarded upstream on the current UTC day.
+	egressMu    sync.Mutex
+	egressDay   string // "2006-01-02"
+	egressBytes int64
+
+	// Budget scope spend, keyed by scope name and value.
+	scopeMu     sync.Mutex
+	scopes      map[string]*scopeCounter
+	scopeValues map[string]int // Tracked values per scope
 }
 
-// NewTracker creates a new cost tracker. Starts a background cleanup goroutine.
+// NewTracker creates a new cost tracker with the default session TTL.
 func NewTracker(cfg CostControlConfig) *Tracker {
-	t := &Tracker{
-		config:   cfg,
-		sessions: make(map[string]*CostSession),
-		stopChan: make(chan struct{}),
+	return NewTrackerWithTTL(cfg, sessionTTL)
+}
+
+// NewTrackerWithTTL creates a cost tracker whose sessions expire after ttl
+// without usage. Expired sessions are dropped on access; call Sweep (or
+// register the tracker with a sessionstore.Collector) to reclaim them.
+func NewTrackerWithTTL(cfg CostControlConfig, ttl time.Duration) *Tracker {
+	if ttl <= 0 {
+		ttl = sessionTTL
+	}
+	return &Tracker{
+		config:      cfg,
+		sessions:    sessionstore.New(ttl, 0, newCostSession),
+		simulation:  NewSimulationLog(),
+		scopes:      make(map[string]*scopeCounter),
+		scopeValues: make(map[string]int),
 	}
-	go t.cleanup()
-	return t
+}
+
+func newCostSession(sessionID string) CostSession {
+	now := time.Now()
+	return CostSession{ID: sessionID, CreatedAt: now, LastUpdated: now}
 }
 
 // UpdateConfig swaps the cost control configuration (hot-reload).
@@ -44,48 +76,104 @@ func (t *Tracker) UpdateConfig(cfg CostControlConfig) {
 	t.config = cfg
 }
 
-// Close stops the background cleanup goroutine. Safe to call multiple times.
+// Close releases the session store. Safe to call multiple times.
 func (t *Tracker) Close() {
-	t.closeOnce.Do(func() {
-		close(t.stopChan)
-	})
+	t.sessions.Stop()
 }
 
 // CheckBudget checks whether a session can continue.
-// Enforces both per-session cap and global cap when Enabled.
+// Enforces the per-session, global and budget scope caps when Enabled;
+// scopes are the request's values from ScopeKeys.
 // Note: budget is checked before the request and cost is recorded after,
 // so a single request can overshoot the cap. This is inherent to pre-check
 // architecture and acceptable — the alternative (holding requests or estimating
 // cost up front) is complex and doesn't justify the marginal benefit.
-func (t *Tracker) CheckBudget(sessionID string) BudgetCheckResult {
-	sessionCap, globalCap := t.effectiveCaps()
-
-	t.mu.RLock()
-	s := t.sessions[sessionID]
-	sessionCost := 0.0
-	if s != nil {
+func (t *Tracker) CheckBudget(sessionID string, scopes ...ScopeKey) BudgetCheckResult {
+	cfg := t.Config()
+	var sessionCost float64
+	var sessionEgress int64
+	t.sessions.View(sessionID, func(s *CostSession) {
 		sessionCost = s.Cost
+		sessionEgress = s.EgressBytes
+	})
+	result := BudgetCheckResult{
+		Allowed:          true,
+		CurrentCost:      sessionCost,
+		GlobalCost:       float64(atomic.LoadInt64(&t.globalCostNano)) / 1e9,
+		Cap:              cfg.SessionCap,
+		GlobalCap:        cfg.GlobalCap,
+		SessionEgress:    sessionEgress,
+		SessionEgressCap: cfg.SessionEgressCap,
+		DailyEgress:      t.GetDailyEgress(),
+		DailyEgressCap:   cfg.DailyEgressCap,
+		Scopes:           t.checkScopes(cfg, scopes),
 	}
-	t.mu.RUnlock()
 
-	globalCost := float64(atomic.LoadInt64(&t.globalCostNano)) / 1e9
+	// If not enforcing, always allow (still report usage)
+	if !cfg.Enabled {
+		return result
+	}
 
-	// If not enforcing, always allow (still report costs)
-	if !t.config.Enabled {
-		return BudgetCheckResult{Allowed: true, CurrentCost: sessionCost, GlobalCost: globalCost, Cap: sessionCap, GlobalCap: globalCap}
+	// Global caps first, then scope caps, then per-session caps
+	var scope *ScopeUsage
+	for i := range result.Scopes {
+		if result.Scopes[i].Exceeded() {
+			scope = &result.Scopes[i]
+			break
+		}
 	}
+	switch {
+	case result.GlobalCap > 0 && result.GlobalCost >= result.GlobalCap:
+		result.Reason = ReasonGlobalCost
+	case result.DailyEgressCap > 0 && result.DailyEgress >= result.DailyEgressCap:
+		result.Reason = ReasonDailyEgress
+	case scope != nil:
+		result.Reason = ReasonScopeCost
+		result.Scope = scope
+	case result.Cap > 0 && result.CurrentCost >= result.Cap:
+		result.Reason = ReasonSessionCost
+	case result.SessionEgressCap > 0 && result.SessionEgress >= result.SessionEgressCap:
+		result.Reason = ReasonSessionEgress
+	}
+	result.Allowed = result.Reason == ""
+	if !result.Allowed && cfg.Simulating() {
+		result.Allowed = true
+		result.Simulated = true
+	}
+	return result
+}
 
-	// Check global cap first
-	if globalCap > 0 && globalCost >= globalCap {
-		return BudgetCheckResult{Allowed: false, CurrentCost: sessionCost, GlobalCost: globalCost, Cap: sessionCap, GlobalCap: globalCap}
+// RecordEgress adds n request bytes forwarded upstream to the session and to
+// today's total. An empty sessionID counts toward the daily total only.
+func (t *Tracker) RecordEgress(sessionID string, n int) {
+	if n <= 0 {
+		return
+	}
+	t.egressMu.Lock()
+	if day := time.Now().UTC().Format(time.DateOnly); day != t.egressDay {
+		t.egressDay = day
+		t.egressBytes = 0
 	}
+	t.egressBytes += int64(n)
+	t.egressMu.Unlock()
 
-	// Check per-session cap
-	if sessionCap > 0 && sessionCost >= sessionCap {
-		return BudgetCheckResult{Allowed: false, CurrentCost: sessionCost, GlobalCost: globalCost, Cap: sessionCap, GlobalCap: globalCap}
+	if sessionID == "" {
+		return
 	}
+	t.sessions.Update(sessionID, func(s *CostSession) {
+		s.EgressBytes += int64(n)
+		s.LastUpdated = time.Now()
+	})
+}
 
-	return BudgetCheckResult{Allowed: true, CurrentCost: sessionCost, GlobalCost: globalCost, Cap: sessionCap, GlobalCap: globalCap}
+// GetDailyEgress returns the bytes forwarded upstream on the current UTC day.
+func (t *Tracker) GetDailyEgress() int64 {
+	t.egressMu.Lock()
+	defer t.egressMu.Unlock()
+	if time.Now().UTC().Format(time.DateOnly) != t.egressDay {
+		return 0
+	}
+	return t.egressBytes
 }
 
 // GetGlobalCost returns total accumulated cost across all sessions.
@@ -97,9 +185,7 @@ func (t *Tracker) GetGlobalCost() float64 {
 // Call this when starting a new agent session to track only that session's spend.
 func (t *Tracker) ResetGlobalCost() {
 	atomic.StoreInt64(&t.globalCostNano, 0)
-	t.mu.Lock()
-	t.sessions = make(map[string]*CostSession)
-	t.mu.Unlock()
+	t.sessions.Reset()
 }
 
 // GetGlobalCap returns the effective global budget cap in USD. Returns 0 if unlimited.
@@ -110,7 +196,8 @@ func (t *Tracker) GetGlobalCap() float64 {
 
 // RecordUsage records actual cost from token counts (non-streaming).
 // cacheCreationTokens and cacheReadTokens are optional (Anthropic-specific).
-func (t *Tracker) RecordUsage(sessionID, model string, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int) {
+// The cost also counts toward each of the request's budget scopes.
+func (t *Tracker) RecordUsage(sessionID, model string, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int, scopes ...ScopeKey) {
 	pricing := GetModelPricing(model)
 	var cost float64
 	if cacheCreationTokens > 0 || cacheReadTokens > 0 {
@@ -135,99 +222,105 @@ func (t *Tracker) RecordUsage(sessionID, model string, inputTokens, outputTokens
 		Float64("global_total", newGlobal).
 		Msg("cost_tracker: RecordUsage")
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.RecordCost(sessionID, model, cost, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, scopes...)
+}
 
-	s := t.getOrCreateLocked(sessionID, model)
-	s.Cost += cost
-	s.RequestCount++
-	s.LastUpdated = time.Now()
-	if model != "" {
-		s.Model = model
-	}
+// RecordCost records a request whose cost is already known, such as the cost
+// an upstream proxy reports, instead of computing it from the pricing table.
+func (t *Tracker) RecordCost(sessionID, model string, cost float64, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int, scopes ...ScopeKey) {
+	t.sessions.Update(sessionID, func(s *CostSession) {
+		s.Cost += cost
+		s.RequestCount++
+		s.LastInputTokens = inputTokens + cacheCreationTokens + cacheReadTokens
+		s.InputTokens += s.LastInputTokens
+		s.OutputTokens += outputTokens
+		s.LastUpdated = time.Now()
+		if model != "" {
+			s.Model = model
+		}
+	})
 
 	costNano := int64(cost * 1e9)
 	atomic.AddInt64(&t.globalCostNano, costNano)
+	t.recordScopes(t.Config(), scopes, cost)
 }
 
 // GetSessionCost returns accumulated cost for a session.
 func (t *Tracker) GetSessionCost(sessionID string) float64 {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	var cost float64
+	t.sessions.View(sessionID, func(s *CostSession) { cost = s.Cost })
+	return cost
+}
 
-	if s, ok := t.sessions[sessionID]; ok {
-		return s.Cost
-	}
-	return 0
+// SessionCount returns the number of tracked sessions.
+func (t *Tracker) SessionCount() int {
+	return t.sessions.Len()
+}
+
+// Sweep removes idle sessions and returns how many (sessionstore.Sweeper).
+// The global cost is not reduced. Budget scope counters from past windows
+// are dropped too.
+func (t *Tracker) Sweep() int {
+	t.sweepScopes()
+	return t.sessions.Sweep()
+}
+
+// Expire forgets a session's cost (sessionstore.Sweeper). The global cost is not reduced.
+func (t *Tracker) Expire(sessionID string) bool {
+	return t.sessions.Delete(sessionID)
+}
+
+// Len returns the number of tracked sessions (sessionstore.Sweeper).
+func (t *Tracker) Len() int {
+	return t.sessions.Len()
 }
 
 // AllSessions returns a snapshot of all sessions for the dashboard.
 func (t *Tracker) AllSessions() []CostSessionSnapshot {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	cfg := t.Config()
 
-	sessionCap, _ := t.effectiveCaps()
-
-	snapshots := make([]CostSessionSnapshot, 0, len(t.sessions))
-	for _, s := range t.sessions {
-		snapshots = append(snapshots, CostSessionSnapshot{
-			ID:           s.ID,
-			Cost:         s.Cost,
-			Cap:          sessionCap,
-			RequestCount: s.RequestCount,
-			Model:        s.Model,
-			CreatedAt:    s.CreatedAt,
-			LastUpdated:  s.LastUpdated,
-		})
-	}
+	snapshots := make([]CostSessionSnapshot, 0, t.sessions.Len())
+	t.sessions.Range(func(_ string, s *CostSession) {
+		snapshots = append(snapshots, s.snapshot(cfg))
+	})
 	return snapshots
 }
 
+// Session returns a snapshot of one session, or false if it is not tracked.
+func (t *Tracker) Session(sessionID string) (CostSessionSnapshot, bool) {
+	cfg := t.Config()
+
+	var snap CostSessionSnapshot
+	ok := t.sessions.View(sessionID, func(s *CostSession) { snap = s.snapshot(cfg) })
+	return snap, ok
+}
+
+func (s *CostSession) snapshot(cfg CostControlConfig) CostSessionSnapshot {
+	return CostSessionSnapshot{
+		ID:              s.ID,
+		Cost:            s.Cost,
+		Cap:             cfg.SessionCap,
+		RequestCount:    s.RequestCount,
+		InputTokens:     s.InputTokens,
+		OutputTokens:    s.OutputTokens,
+		LastInputTokens: s.LastInputTokens,
+		EgressBytes:     s.EgressBytes,
+		EgressCap:       cfg.SessionEgressCap,
+		Model:           s.Model,
+		CreatedAt:       s.CreatedAt,
+		LastUpdated:     s.LastUpdated,
+	}
+}
+
 // Config returns the tracker's config (for dashboard display).
 func (t *Tracker) Config() CostControlConfig {
-	cfg := t.config
-	cfg.SessionCap, cfg.GlobalCap = t.effectiveCaps()
-	return cfg
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config
 }
 
 // effectiveCaps returns the configured session and global caps as-is.
 func (t *Tracker) effectiveCaps() (sessionCap, globalCap float64) {
-	return t.config.SessionCap, t.config.GlobalCap
-}
-
-func (t *Tracker) getOrCreateLocked(sessionID, model string) *CostSession {
-	if s, ok := t.sessions[sessionID]; ok {
-		return s
-	}
-	s := &CostSession{
-		ID:          sessionID,
-		Model:       model,
-		CreatedAt:   time.Now(),
-		LastUpdated: time.Now(),
-	}
-	t.sessions[sessionID] = s
-	return s
-}
-
-func (t *Tracker) cleanup() {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			t.mu.Lock()
-			now := time.Now()
-			for id, s := range t.sessions {
-				if now.Sub(s.LastUpdated) > sessionTTL {
-					// Note: globalCostNano is NOT decremented — global cap is absolute
-					// for the gateway's lifetime. Use ResetGlobalCost() for explicit resets.
-					delete(t.sessions, id)
-				}
-			}
-			t.mu.Unlock()
-		case <-t.stopChan:
-			return
-		}
-	}
+	cfg := t.Config()
+	return cfg.SessionCap, cfg.GlobalCap
 }
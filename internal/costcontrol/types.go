@@ -6,11 +6,31 @@ import (
 	"time"
 )
 
+// Budget enforcement modes (CostControlConfig.Mode).
+const (
+	ModeEnforce  = "enforce"  // Reject requests over a cap (default)
+	ModeSimulate = "simulate" // Run checks and record would-be rejections, never block
+)
+
 // CostControlConfig holds cost control settings.
 type CostControlConfig struct {
-	Enabled    bool    `yaml:"enabled"`     // Whether budget enforcement is active
-	SessionCap float64 `yaml:"session_cap"` // USD per session. 0 = unlimited.
-	GlobalCap  float64 `yaml:"global_cap"`  // USD across all sessions. 0 = unlimited.
+	Enabled    bool    `yaml:"enabled" json:"enabled"`         // Whether budget enforcement is active
+	Mode       string  `yaml:"mode" json:"mode,omitempty"`     // enforce (default) | simulate
+	SessionCap float64 `yaml:"session_cap" json:"session_cap"` // USD per session. 0 = unlimited.
+	GlobalCap  float64 `yaml:"global_cap" json:"global_cap"`   // USD across all sessions. 0 = unlimited.
+
+	// Egress caps limit request bytes sent upstream, including phantom-loop
+	// follow-ups, for orgs that cap data leaving the premises.
+	SessionEgressCap int64 `yaml:"session_egress_bytes" json:"session_egress_bytes"` // Bytes per session. 0 = unlimited.
+	DailyEgressCap   int64 `yaml:"daily_egress_bytes" json:"daily_egress_bytes"`     // Bytes per UTC day across all sessions. 0 = unlimited.
+
+	// Scopes cap spend per API key, header value or session tag, each with
+	// its own reset window.
+	Scopes []BudgetScope `yaml:"scopes" json:"scopes,omitempty"`
+
+	// CostHeaders adds X-Gateway-Cost-Estimate and X-Gateway-Cost-Saved to
+	// proxied responses. Independent of Enabled.
+	CostHeaders bool `yaml:"cost_headers" json:"cost_headers"`
 }
 
 // Validate checks cost control configuration.
@@ -21,35 +41,114 @@ func (c *CostControlConfig) Validate() error {
 	if c.GlobalCap < 0 {
 		return fmt.Errorf("cost_control.global_cap must be >= 0, got %f", c.GlobalCap)
 	}
+	if c.SessionEgressCap < 0 {
+		return fmt.Errorf("cost_control.session_egress_bytes must be >= 0, got %d", c.SessionEgressCap)
+	}
+	if c.DailyEgressCap < 0 {
+		return fmt.Errorf("cost_control.daily_egress_bytes must be >= 0, got %d", c.DailyEgressCap)
+	}
+	seen := make(map[string]bool, len(c.Scopes))
+	for i := range c.Scopes {
+		if err := c.Scopes[i].validate(i, seen); err != nil {
+			return err
+		}
+	}
+	if c.Mode != "" && c.Mode != ModeEnforce && c.Mode != ModeSimulate {
+		return fmt.Errorf("cost_control.mode must be %q or %q, got %q", ModeEnforce, ModeSimulate, c.Mode)
+	}
 	return nil
 }
 
+// EffectiveMode returns Mode, defaulting to ModeEnforce.
+func (c *CostControlConfig) EffectiveMode() string {
+	if c.Mode == "" {
+		return ModeEnforce
+	}
+	return c.Mode
+}
+
+// Simulating reports whether caps are checked without blocking.
+func (c *CostControlConfig) Simulating() bool {
+	return c.Mode == ModeSimulate
+}
+
 // CostSession tracks accumulated cost for a single session.
 type CostSession struct {
-	ID           string
-	Cost         float64
-	RequestCount int
-	Model        string
-	CreatedAt    time.Time
-	LastUpdated  time.Time
+	ID              string
+	Cost            float64
+	RequestCount    int
+	InputTokens     int   // Cumulative, including cache writes and reads
+	OutputTokens    int   // Cumulative
+	LastInputTokens int   // Input tokens of the most recent request (≈ current context size)
+	EgressBytes     int64 // Cumulative request bytes forwarded upstream
+	Model           string
+	CreatedAt       time.Time
+	LastUpdated     time.Time
 }
 
+// Budget check denial reasons (BudgetCheckResult.Reason).
+const (
+	ReasonGlobalCost    = "global_cost"
+	ReasonSessionCost   = "session_cost"
+	ReasonDailyEgress   = "daily_egress"
+	ReasonSessionEgress = "session_egress"
+)
+
 // BudgetCheckResult holds the result of a budget check.
 type BudgetCheckResult struct {
 	Allowed     bool
+	Simulated   bool    // A cap was exceeded but mode is simulate, so Allowed stays true
+	Reason      string  // Which cap denied (or would deny) the request; empty when within caps
 	CurrentCost float64 // Session cost
 	GlobalCost  float64 // Total across all sessions
 	Cap         float64 // Per-session cap
 	GlobalCap   float64 // Global cap
+
+	SessionEgress    int64 // Session bytes forwarded
+	SessionEgressCap int64 // Per-session egress cap
+	DailyEgress      int64 // Bytes forwarded today (UTC)
+	DailyEgressCap   int64 // Daily egress cap
+
+	Scopes []ScopeUsage // Budget scopes the request falls in
+	Scope  *ScopeUsage  // Scope that denied (or would deny) the request
 }
 
 // CostSessionSnapshot is a read-only copy of a session for the dashboard.
 type CostSessionSnapshot struct {
-	ID           string
-	Cost         float64
-	Cap          float64
-	RequestCount int
-	Model        string
-	CreatedAt    time.Time
-	LastUpdated  time.Time
+	ID              string
+	Cost            float64
+	Cap             float64
+	RequestCount    int
+	InputTokens     int
+	OutputTokens    int
+	LastInputTokens int
+	EgressBytes     int64
+	EgressCap       int64
+	Model           string
+	CreatedAt       time.Time
+	LastUpdated     time.Time
+}
+
+// Utilization returns how full the fullest enabled cap is (0 when no cap is set;
+// may exceed 1 once a cap is passed).
+func (r BudgetCheckResult) Utilization() float64 {
+	u := 0.0
+	if r.Cap > 0 {
+		u = max(u, r.CurrentCost/r.Cap)
+	}
+	if r.GlobalCap > 0 {
+		u = max(u, r.GlobalCost/r.GlobalCap)
+	}
+	if r.SessionEgressCap > 0 {
+		u = max(u, float64(r.SessionEgress)/float64(r.SessionEgressCap))
+	}
+	if r.DailyEgressCap > 0 {
+		u = max(u, float64(r.DailyEgress)/float64(r.DailyEgressCap))
+	}
+	for _, s := range r.Scopes {
+		if s.Cap > 0 {
+			u = max(u, s.Cost/s.Cap)
+		}
+	}
+	return u
 }
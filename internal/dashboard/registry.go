@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // Instance represents a running gateway process.
@@ -32,11 +34,10 @@ var registryMu sync.Mutex
 
 // registryFile returns the path to the shared instances registry.
 func registryFile() string {
-	home, err := os.UserHomeDir()
+	dir, err := userdir.Dir()
 	if err != nil {
 		return "/tmp/context-gateway-instances.json"
 	}
-	dir := filepath.Join(home, ".config", "context-gateway")
 	_ = os.MkdirAll(dir, 0750)
 	return filepath.Join(dir, "instances.json")
 }
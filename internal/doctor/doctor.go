@@ -0,0 +1,289 @@
+// Package doctor runs the self-diagnosis checks of `context-gateway doctor`.
+//
+// Each check inspects one thing that commonly breaks a setup — the config,
+// the listen port, provider keys, the Compresr API, upstream reachability,
+// the Claude Code hooks — and says what is wrong and how to fix it, so users
+// can solve most problems without filing an issue.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/compresr"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/userdir"
+)
+
+// Check statuses, from best to worst.
+const (
+	StatusOK   = "ok"
+	StatusSkip = "skip" // Not applicable to this config
+	StatusWarn = "warn" // Works, but probably not as intended
+	StatusFail = "fail" // Broken
+)
+
+// Check is the result of one diagnostic.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"` // What to do about a warn or fail
+}
+
+// DefaultTimeout bounds each network check.
+const DefaultTimeout = 5 * time.Second
+
+// SlackHookScript is the hook installed by the Slack setup, relative to the Claude directory.
+const SlackHookScript = "hooks/slack-notify.sh"
+
+// CheckConfig lints the config and loads it. The config is nil when it does
+// not load; the other config-based checks are then skipped.
+func CheckConfig(data []byte, source string) (Check, *config.Config) {
+	c := Check{Name: "config", Status: StatusOK, Detail: source}
+	var errs, warns []string
+	for _, issue := range config.Lint(data) {
+		if issue.Severity == config.LintError {
+			errs = append(errs, issue.String())
+		} else {
+			warns = append(warns, issue.String())
+		}
+	}
+	cfg, err := config.LoadFromBytes(data)
+	switch {
+	case err != nil:
+		if len(errs) == 0 {
+			errs = append(errs, err.Error())
+		}
+		c.Status, c.Detail = StatusFail, source+": "+strings.Join(errs, "; ")
+		c.Fix = "run `context-gateway validate` for every issue"
+		return c, nil
+	case len(warns) > 0:
+		c.Status, c.Detail = StatusWarn, fmt.Sprintf("%s: %d warning(s): %s", source, len(warns), warns[0])
+		c.Fix = "run `context-gateway validate` for every issue"
+	}
+	return c, cfg
+}
+
+// CheckPort reports whether the gateway can listen on port. A port held by
+// a gateway that answers /health is fine: it is the gateway agents will use.
+func CheckPort(ctx context.Context, client *http.Client, port int) Check {
+	c := Check{Name: "port", Status: StatusOK, Detail: fmt.Sprintf("%d is free", port)}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err == nil {
+		_ = ln.Close()
+		return c
+	}
+	if probe(ctx, client, http.MethodGet, "http://"+addr+"/health") == http.StatusOK {
+		c.Detail = fmt.Sprintf("%d is used by a running gateway", port)
+		return c
+	}
+	c.Status, c.Detail = StatusFail, fmt.Sprintf("%d is in use by another program", port)
+	c.Fix = "stop that program, or pick another port with --port or server.port"
+	return c
+}
+
+// CheckProviderKeys reports, per configured provider, whether the gateway
+// has its own credentials. Without them the gateway forwards the client's
+// credentials, which covers proxying but not gateway-initiated calls such as
+// summarization with an external provider.
+func CheckProviderKeys(cfg *config.Config) []Check {
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		p := cfg.Providers[name]
+		c := Check{Name: "provider " + name, Status: StatusOK}
+		switch {
+		case p.Auth == "oauth" || p.Auth == "bedrock":
+			c.Detail = "auth: " + p.Auth
+		case p.ProviderAuth != "":
+			c.Detail = "api_key set"
+		default:
+			envVar := config.ProviderEnvVar(name)
+			c.Status, c.Detail = StatusWarn, "api_key is empty; the gateway can only use credentials sent by clients"
+			c.Fix = fmt.Sprintf("set %s in your shell or in %s", envVar, envFileHint())
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// CheckCompresr probes the Compresr API and validates the API key. A missing
+// key is only a failure when the config uses a compresr strategy.
+func CheckCompresr(ctx context.Context, client *http.Client, cfg *config.Config) Check {
+	c := Check{Name: "compresr"}
+	base := cfg.URLs.Compresr
+	if base == "" {
+		base = compresr.DefaultCompresrAPIBaseURL
+	}
+	if status := probe(ctx, client, http.MethodHead, base); status == 0 || status >= 500 {
+		c.Status, c.Detail = StatusFail, base+" is unreachable"
+		c.Fix = "check your network, proxy settings and urls.compresr"
+		if !usesCompresr(cfg) {
+			c.Status = StatusWarn
+		}
+		return c
+	}
+
+	key := cfg.CompresrCreds.APIKey
+	if key == "" {
+		key = os.Getenv("COMPRESR_API_KEY")
+	}
+	if key == "" {
+		if !usesCompresr(cfg) {
+			c.Status, c.Detail = StatusSkip, "reachable; no compresr strategy configured"
+			return c
+		}
+		c.Status, c.Detail = StatusFail, "a compresr strategy is configured but COMPRESR_API_KEY is not set"
+		c.Fix = "run `context-gateway --reset-api-key`, or set COMPRESR_API_KEY in " + envFileHint()
+		return c
+	}
+
+	tier, err := compresr.NewClient(base, key, compresr.WithHTTPClient(client)).ValidateAPIKey()
+	if err != nil {
+		c.Status, c.Detail = StatusFail, "API key rejected: "+err.Error()
+		c.Fix = "run `context-gateway --reset-api-key` to enter a new key"
+		return c
+	}
+	c.Status, c.Detail = StatusOK, "API key valid ("+tier+" tier)"
+	return c
+}
+
+// CheckUpstreams probes the endpoint host of every configured provider, in
+// parallel. Any answer below 500 counts: the probe carries no credentials.
+func CheckUpstreams(ctx context.Context, client *http.Client, cfg *config.Config) []Check {
+	hosts := make(map[string]string) // host -> probe URL
+	for name, p := range cfg.Providers {
+		if u, err := url.Parse(p.GetEndpoint(name)); err == nil && u.Host != "" {
+			hosts[u.Host] = u.Scheme + "://" + u.Host + "/"
+		}
+	}
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	checks := make([]Check, len(names))
+	var wg sync.WaitGroup
+	for i, host := range names {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			start := time.Now()
+			status := probe(ctx, client, http.MethodHead, hosts[host])
+			c := Check{Name: "upstream " + host, Status: StatusOK, Detail: fmt.Sprintf("HTTP %d in %s", status, time.Since(start).Round(time.Millisecond))}
+			if status == 0 || status >= 500 {
+				c.Status, c.Detail = StatusFail, "unreachable"
+				if status != 0 {
+					c.Detail = fmt.Sprintf("HTTP %d", status)
+				}
+				c.Fix = "check your network and proxy settings (HTTPS_PROXY)"
+			}
+			checks[i] = c
+		}(i, host)
+	}
+	wg.Wait()
+	return checks
+}
+
+// CheckClaudeHooks reports whether the Slack notification hook is installed
+// in Claude Code when notifications.slack is enabled. claudeDir is ~/.claude.
+func CheckClaudeHooks(cfg *config.Config, claudeDir string) Check {
+	c := Check{Name: "claude code hooks"}
+	if !cfg.Notifications.Slack.Enabled {
+		c.Status, c.Detail = StatusSkip, "notifications.slack is disabled"
+		return c
+	}
+	if SlackHookInstalled(claudeDir) {
+		c.Status, c.Detail = StatusOK, "Slack hook installed"
+		return c
+	}
+	c.Status, c.Detail = StatusFail, "notifications.slack is enabled but the Slack hook is not installed in "+claudeDir
+	c.Fix = "run `context-gateway config` and enable Slack notifications again"
+	return c
+}
+
+// SlackHookInstalled reports whether the Slack hook script exists and is
+// registered for the Stop and Notification events in settings.json.
+func SlackHookInstalled(claudeDir string) bool {
+	script := filepath.Join(claudeDir, filepath.FromSlash(SlackHookScript))
+	if _, err := os.Stat(script); err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(claudeDir, "settings.json")) // #nosec G304 -- settings file under the Claude directory
+	if err != nil {
+		return false
+	}
+	var settings struct {
+		Hooks map[string][]struct {
+			Hooks []struct {
+				Command string `json:"command"`
+			} `json:"hooks"`
+		} `json:"hooks"`
+	}
+	if json.Unmarshal(data, &settings) != nil {
+		return false
+	}
+	registered := func(event string) bool {
+		for _, entry := range settings.Hooks[event] {
+			for _, h := range entry.Hooks {
+				if h.Command == script {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return registered("Stop") && registered("Notification")
+}
+
+// usesCompresr reports whether an enabled pipe or the summarizer calls the Compresr API.
+func usesCompresr(cfg *config.Config) bool {
+	p := &cfg.Pipes
+	return (p.ToolOutput.Enabled && p.ToolOutput.Strategy == pipes.StrategyCompresr) ||
+		(p.ToolDiscovery.Enabled && p.ToolDiscovery.Strategy == pipes.StrategyCompresr) ||
+		(cfg.Preemptive.Enabled && cfg.Preemptive.Summarizer.Strategy == preemptive.StrategyCompresr)
+}
+
+// probe sends one request and returns the status code, or 0 when there was no answer.
+func probe(ctx context.Context, client *http.Client, method, rawURL string) int {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0
+	}
+	resp, err := client.Do(req) // #nosec G704 -- URLs come from the config and built-in defaults
+	if err != nil {
+		return 0
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+// envFileHint names the user .env file read at startup.
+func envFileHint() string {
+	if path := userdir.Path(".env"); path != "" {
+		return path
+	}
+	return "the context-gateway .env file"
+}
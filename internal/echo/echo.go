@@ -0,0 +1,270 @@
+// Package echo is a deterministic local fake LLM provider.
+//
+// With server.target: echo (or `context-gateway serve --target echo`) the
+// gateway's upstream HTTP client is replaced by Transport: every forward runs
+// through the normal pipes, budgets and headers, but is answered in-process
+// instead of by the real provider. No tokens are spent and no network is used
+// for the upstream call.
+//
+// The reply echoes what the provider would have received (message count, tool
+// results, tool definitions, estimated input tokens, last user message), so a
+// config can be checked by looking at what the gateway forwarded. Magic strings
+// in the last user message change the reply:
+//
+//	ECHO_TOOL_CALL:<name>          reply with a call to tool <name> and empty input
+//	ECHO_TOOL_CALL:<name>{"k":"v"} reply with a call to tool <name> and the given input
+//	ECHO_ERROR:<status>            reply with a provider-shaped error, e.g. ECHO_ERROR:429
+//
+// Identical requests always produce identical responses.
+package echo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// Target is the server.target value that enables the echo provider.
+const Target = "echo"
+
+// Magic strings recognized in the last user message.
+const (
+	MagicToolCall = "ECHO_TOOL_CALL:"
+	MagicError    = "ECHO_ERROR:"
+)
+
+// HeaderEcho is set on every echo response so clients can tell it apart from a real provider.
+const HeaderEcho = "X-Echo-Target"
+
+// maxPreviewChars bounds the last user message quoted in the reply.
+const maxPreviewChars = 200
+
+var (
+	toolCallPattern = regexp.MustCompile(regexp.QuoteMeta(MagicToolCall) + `([A-Za-z0-9_.\-]+)`)
+	errorPattern    = regexp.MustCompile(regexp.QuoteMeta(MagicError) + `(\d{3})`)
+)
+
+// Transport answers provider API requests locally. It implements http.RoundTripper.
+type Transport struct{}
+
+// NewTransport returns an echo transport.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// RoundTrip serves req from the echo provider.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	path := req.URL.Path
+	var api api
+	switch {
+	case strings.HasSuffix(path, "/messages/count_tokens"):
+		return countTokensResponse(req, body), nil
+	case strings.HasSuffix(path, "/models") && req.Method == http.MethodGet:
+		return modelsResponse(req), nil
+	case strings.HasSuffix(path, "/messages"):
+		api = anthropicAPI{}
+	case strings.HasSuffix(path, "/chat/completions"):
+		api = chatCompletionsAPI{}
+	case strings.HasSuffix(path, "/responses"):
+		api = responsesAPI{}
+	default:
+		return errorResponse(req, anthropicAPI{}, http.StatusNotFound,
+			fmt.Sprintf("echo target does not implement %s %s", req.Method, path)), nil
+	}
+
+	in, err := api.parse(body)
+	if err != nil {
+		return errorResponse(req, api, http.StatusBadRequest, "echo target: invalid request body: "+err.Error()), nil
+	}
+	in.id = requestID(body)
+	in.inputTokens = tokenizer.CountBytesForModel(body, in.Model)
+	in.requestBytes = len(body)
+
+	if m := errorPattern.FindStringSubmatch(in.lastUser); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		if status >= 400 && status <= 599 {
+			return errorResponse(req, api, status, fmt.Sprintf("echo target: simulated %d error", status)), nil
+		}
+	}
+
+	out := reply{Text: in.summary()}
+	if call, ok := parseToolCall(in.lastUser); ok {
+		out.ToolCall = &call
+	}
+	out.OutputTokens = tokenizer.CountTokensForModel(out.Text, in.Model)
+
+	if in.Stream {
+		return newResponse(req, http.StatusOK, "text/event-stream", api.stream(in, out)), nil
+	}
+	return newResponse(req, http.StatusOK, "application/json", api.render(in, out)), nil
+}
+
+// api is one provider wire format.
+type api interface {
+	parse(body []byte) (*request, error)
+	render(in *request, out reply) []byte
+	stream(in *request, out reply) []byte
+	errorBody(status int, message string) []byte
+}
+
+// request is what the echo provider extracts from a provider request.
+type request struct {
+	Model        string
+	Stream       bool
+	includeUsage bool // Chat Completions stream_options.include_usage
+
+	messages        int
+	toolResults     int
+	toolResultChars int
+	tools           int
+	hasSystem       bool
+	lastUser        string
+
+	// Set by RoundTrip
+	id           string
+	inputTokens  int
+	requestBytes int
+}
+
+// summary is the deterministic reply text.
+func (r *request) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[echo] model=%s messages=%d tool_results=%d tool_result_chars=%d tools=%d system=%t input_tokens=%d request_bytes=%d",
+		r.Model, r.messages, r.toolResults, r.toolResultChars, r.tools, r.hasSystem, r.inputTokens, r.requestBytes)
+	if r.lastUser != "" {
+		preview := r.lastUser
+		if runes := []rune(preview); len(runes) > maxPreviewChars {
+			preview = string(runes[:maxPreviewChars]) + "..."
+		}
+		fmt.Fprintf(&b, "\nlast user message: %q", preview)
+	}
+	return b.String()
+}
+
+// reply is the provider-independent response.
+type reply struct {
+	Text         string
+	ToolCall     *toolCall
+	OutputTokens int
+}
+
+type toolCall struct {
+	Name  string
+	Input json.RawMessage
+}
+
+// parseToolCall finds ECHO_TOOL_CALL:<name> with an optional JSON object right after the name.
+func parseToolCall(text string) (toolCall, bool) {
+	loc := toolCallPattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return toolCall{}, false
+	}
+	call := toolCall{Name: text[loc[2]:loc[3]], Input: json.RawMessage(`{}`)}
+	if rest := text[loc[1]:]; strings.HasPrefix(rest, "{") {
+		var input json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(rest)).Decode(&input); err == nil {
+			var compact bytes.Buffer
+			if json.Compact(&compact, input) == nil && bytes.HasPrefix(compact.Bytes(), []byte("{")) {
+				call.Input = compact.Bytes()
+			}
+		}
+	}
+	return call, true
+}
+
+// requestID derives a stable ID from the request body.
+func requestID(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])[:24]
+}
+
+func newResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	header.Set(HeaderEcho, "true")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func errorResponse(req *http.Request, api api, status int, message string) *http.Response {
+	return newResponse(req, status, "application/json", api.errorBody(status, message))
+}
+
+// countTokensResponse answers Anthropic's /v1/messages/count_tokens.
+func countTokensResponse(req *http.Request, body []byte) *http.Response {
+	var in struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &in)
+	out, _ := json.Marshal(map[string]int{"input_tokens": tokenizer.CountBytesForModel(body, in.Model)})
+	return newResponse(req, http.StatusOK, "application/json", out)
+}
+
+// modelsResponse answers GET /v1/models with a single echo model.
+func modelsResponse(req *http.Request) *http.Response {
+	out, _ := json.Marshal(map[string]any{
+		"object": "list",
+		"data":   []map[string]any{{"id": Target, "object": "model", "created": 0, "owned_by": "context-gateway"}},
+	})
+	return newResponse(req, http.StatusOK, "application/json", out)
+}
+
+// textOf flattens a message content field: a string, or an array of parts with
+// a "text" field (Anthropic text blocks, OpenAI text / input_text parts).
+func textOf(content json.RawMessage) string {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(content, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" && (p.Type == "text" || p.Type == "input_text") {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// sseEvent formats one server-sent event; event may be empty.
+func sseEvent(buf *bytes.Buffer, event string, data any) {
+	if event != "" {
+		fmt.Fprintf(buf, "event: %s\n", event)
+	}
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(buf, "data: %s\n\n", payload)
+}
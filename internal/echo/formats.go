@@ -0,0 +1,316 @@
+// Package echo - formats.go renders echo replies in each provider's wire format.
+package echo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// =============================================================================
+// ANTHROPIC MESSAGES
+// =============================================================================
+
+type anthropicAPI struct{}
+
+func (anthropicAPI) parse(body []byte) (*request, error) {
+	var in struct {
+		Model    string            `json:"model"`
+		Stream   bool              `json:"stream"`
+		System   json.RawMessage   `json:"system"`
+		Tools    []json.RawMessage `json:"tools"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, err
+	}
+	r := &request{Model: in.Model, Stream: in.Stream, messages: len(in.Messages), tools: len(in.Tools), hasSystem: len(in.System) > 0 && string(in.System) != "null"}
+	for _, m := range in.Messages {
+		var blocks []struct {
+			Type    string          `json:"type"`
+			Content json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(m.Content, &blocks) == nil {
+			for _, b := range blocks {
+				if b.Type == "tool_result" {
+					r.toolResults++
+					r.toolResultChars += len(textOf(b.Content))
+				}
+			}
+		}
+		if m.Role == "user" {
+			r.lastUser = textOf(m.Content)
+		}
+	}
+	return r, nil
+}
+
+func (anthropicAPI) content(in *request, out reply) ([]map[string]any, string) {
+	content := []map[string]any{{"type": "text", "text": out.Text}}
+	if out.ToolCall == nil {
+		return content, "end_turn"
+	}
+	return append(content, map[string]any{
+		"type": "tool_use", "id": "toolu_echo_" + in.id, "name": out.ToolCall.Name, "input": out.ToolCall.Input,
+	}), "tool_use"
+}
+
+func (a anthropicAPI) render(in *request, out reply) []byte {
+	content, stopReason := a.content(in, out)
+	data, _ := json.Marshal(map[string]any{
+		"id": "msg_echo_" + in.id, "type": "message", "role": "assistant", "model": in.Model,
+		"content": content, "stop_reason": stopReason, "stop_sequence": nil,
+		"usage": map[string]int{"input_tokens": in.inputTokens, "output_tokens": out.OutputTokens},
+	})
+	return data
+}
+
+func (a anthropicAPI) stream(in *request, out reply) []byte {
+	var buf bytes.Buffer
+	_, stopReason := a.content(in, out)
+	sseEvent(&buf, "message_start", map[string]any{"type": "message_start", "message": map[string]any{
+		"id": "msg_echo_" + in.id, "type": "message", "role": "assistant", "model": in.Model,
+		"content": []any{}, "stop_reason": nil, "stop_sequence": nil,
+		"usage": map[string]int{"input_tokens": in.inputTokens, "output_tokens": 0},
+	}})
+	sseEvent(&buf, "content_block_start", map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}})
+	sseEvent(&buf, "content_block_delta", map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": out.Text}})
+	sseEvent(&buf, "content_block_stop", map[string]any{"type": "content_block_stop", "index": 0})
+	if out.ToolCall != nil {
+		sseEvent(&buf, "content_block_start", map[string]any{"type": "content_block_start", "index": 1, "content_block": map[string]any{
+			"type": "tool_use", "id": "toolu_echo_" + in.id, "name": out.ToolCall.Name, "input": map[string]any{},
+		}})
+		sseEvent(&buf, "content_block_delta", map[string]any{"type": "content_block_delta", "index": 1, "delta": map[string]any{"type": "input_json_delta", "partial_json": string(out.ToolCall.Input)}})
+		sseEvent(&buf, "content_block_stop", map[string]any{"type": "content_block_stop", "index": 1})
+	}
+	sseEvent(&buf, "message_delta", map[string]any{"type": "message_delta",
+		"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": out.OutputTokens}})
+	sseEvent(&buf, "message_stop", map[string]any{"type": "message_stop"})
+	return buf.Bytes()
+}
+
+func (anthropicAPI) errorBody(status int, message string) []byte {
+	errType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errType = "authentication_error"
+	case http.StatusForbidden:
+		errType = "permission_error"
+	case http.StatusNotFound:
+		errType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case 529:
+		errType = "overloaded_error"
+	}
+	data, _ := json.Marshal(map[string]any{"type": "error", "error": map[string]string{"type": errType, "message": message}})
+	return data
+}
+
+// =============================================================================
+// OPENAI CHAT COMPLETIONS
+// =============================================================================
+
+type chatCompletionsAPI struct{}
+
+func (chatCompletionsAPI) parse(body []byte) (*request, error) {
+	var in struct {
+		Model         string `json:"model"`
+		Stream        bool   `json:"stream"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+		Tools    []json.RawMessage `json:"tools"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, err
+	}
+	r := &request{Model: in.Model, Stream: in.Stream, messages: len(in.Messages), tools: len(in.Tools)}
+	for _, m := range in.Messages {
+		switch m.Role {
+		case "system", "developer":
+			r.hasSystem = true
+		case "tool":
+			r.toolResults++
+			r.toolResultChars += len(textOf(m.Content))
+		case "user":
+			r.lastUser = textOf(m.Content)
+		}
+	}
+	r.includeUsage = in.StreamOptions.IncludeUsage
+	return r, nil
+}
+
+func (chatCompletionsAPI) usage(in *request, out reply) map[string]int {
+	return map[string]int{"prompt_tokens": in.inputTokens, "completion_tokens": out.OutputTokens, "total_tokens": in.inputTokens + out.OutputTokens}
+}
+
+func (chatCompletionsAPI) toolCalls(in *request, out reply) ([]map[string]any, string) {
+	if out.ToolCall == nil {
+		return nil, "stop"
+	}
+	return []map[string]any{{
+		"index": 0, "id": "call_echo_" + in.id, "type": "function",
+		"function": map[string]string{"name": out.ToolCall.Name, "arguments": string(out.ToolCall.Input)},
+	}}, "tool_calls"
+}
+
+func (c chatCompletionsAPI) render(in *request, out reply) []byte {
+	message := map[string]any{"role": "assistant", "content": out.Text}
+	calls, finish := c.toolCalls(in, out)
+	if calls != nil {
+		message["tool_calls"] = calls
+	}
+	data, _ := json.Marshal(map[string]any{
+		"id": "chatcmpl-echo-" + in.id, "object": "chat.completion", "created": 0, "model": in.Model,
+		"choices": []map[string]any{{"index": 0, "message": message, "finish_reason": finish}},
+		"usage":   c.usage(in, out),
+	})
+	return data
+}
+
+func (c chatCompletionsAPI) stream(in *request, out reply) []byte {
+	var buf bytes.Buffer
+	chunk := func(choices []map[string]any, usage map[string]int) {
+		ev := map[string]any{"id": "chatcmpl-echo-" + in.id, "object": "chat.completion.chunk", "created": 0, "model": in.Model, "choices": choices}
+		if usage != nil {
+			ev["usage"] = usage
+		}
+		sseEvent(&buf, "", ev)
+	}
+	chunk([]map[string]any{{"index": 0, "delta": map[string]any{"role": "assistant", "content": out.Text}, "finish_reason": nil}}, nil)
+	calls, finish := c.toolCalls(in, out)
+	if calls != nil {
+		chunk([]map[string]any{{"index": 0, "delta": map[string]any{"tool_calls": calls}, "finish_reason": nil}}, nil)
+	}
+	chunk([]map[string]any{{"index": 0, "delta": map[string]any{}, "finish_reason": finish}}, nil)
+	if in.includeUsage {
+		chunk([]map[string]any{}, c.usage(in, out))
+	}
+	buf.WriteString("data: [DONE]\n\n")
+	return buf.Bytes()
+}
+
+func (chatCompletionsAPI) errorBody(status int, message string) []byte {
+	return openAIErrorBody(status, message)
+}
+
+// openAIErrorBody is the error shape shared by Chat Completions and Responses.
+func openAIErrorBody(status int, message string) []byte {
+	errType := "server_error"
+	switch {
+	case status == http.StatusTooManyRequests:
+		errType = "rate_limit_exceeded"
+	case status == http.StatusUnauthorized:
+		errType = "authentication_error"
+	case status < 500:
+		errType = "invalid_request_error"
+	}
+	data, _ := json.Marshal(map[string]any{"error": map[string]any{"message": message, "type": errType, "param": nil, "code": nil}})
+	return data
+}
+
+// =============================================================================
+// OPENAI RESPONSES
+// =============================================================================
+
+type responsesAPI struct{}
+
+func (responsesAPI) parse(body []byte) (*request, error) {
+	var in struct {
+		Model        string            `json:"model"`
+		Stream       bool              `json:"stream"`
+		Instructions string            `json:"instructions"`
+		Tools        []json.RawMessage `json:"tools"`
+		Input        json.RawMessage   `json:"input"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, err
+	}
+	r := &request{Model: in.Model, Stream: in.Stream, tools: len(in.Tools), hasSystem: in.Instructions != ""}
+
+	var text string
+	if json.Unmarshal(in.Input, &text) == nil {
+		r.messages = 1
+		r.lastUser = text
+		return r, nil
+	}
+	var items []struct {
+		Type    string          `json:"type"`
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+		Output  json.RawMessage `json:"output"`
+	}
+	if len(in.Input) > 0 {
+		if err := json.Unmarshal(in.Input, &items); err != nil {
+			return nil, err
+		}
+	}
+	r.messages = len(items)
+	for _, it := range items {
+		switch {
+		case it.Type == "function_call_output":
+			r.toolResults++
+			r.toolResultChars += len(textOf(it.Output))
+		case it.Role == "system" || it.Role == "developer":
+			r.hasSystem = true
+		case it.Role == "user":
+			r.lastUser = textOf(it.Content)
+		}
+	}
+	return r, nil
+}
+
+func (responsesAPI) response(in *request, out reply, status string) map[string]any {
+	output := []map[string]any{}
+	if status == "completed" {
+		output = append(output, map[string]any{
+			"type": "message", "id": "msg_echo_" + in.id, "status": "completed", "role": "assistant",
+			"content": []map[string]any{{"type": "output_text", "text": out.Text, "annotations": []any{}}},
+		})
+		if out.ToolCall != nil {
+			output = append(output, map[string]any{
+				"type": "function_call", "id": "fc_echo_" + in.id, "call_id": "call_echo_" + in.id,
+				"name": out.ToolCall.Name, "arguments": string(out.ToolCall.Input), "status": "completed",
+			})
+		}
+	}
+	resp := map[string]any{
+		"id": "resp_echo_" + in.id, "object": "response", "created_at": 0, "status": status, "model": in.Model, "output": output,
+	}
+	if status == "completed" {
+		resp["usage"] = map[string]int{"input_tokens": in.inputTokens, "output_tokens": out.OutputTokens, "total_tokens": in.inputTokens + out.OutputTokens}
+	}
+	return resp
+}
+
+func (r responsesAPI) render(in *request, out reply) []byte {
+	data, _ := json.Marshal(r.response(in, out, "completed"))
+	return data
+}
+
+func (r responsesAPI) stream(in *request, out reply) []byte {
+	var buf bytes.Buffer
+	sseEvent(&buf, "response.created", map[string]any{"type": "response.created", "response": r.response(in, out, "in_progress")})
+	sseEvent(&buf, "response.output_text.delta", map[string]any{
+		"type": "response.output_text.delta", "item_id": "msg_echo_" + in.id, "output_index": 0, "content_index": 0, "delta": out.Text,
+	})
+	sseEvent(&buf, "response.completed", map[string]any{"type": "response.completed", "response": r.response(in, out, "completed")})
+	return buf.Bytes()
+}
+
+func (responsesAPI) errorBody(status int, message string) []byte {
+	return openAIErrorBody(status, message)
+}
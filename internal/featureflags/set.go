@@ -0,0 +1,178 @@
+// Package featureflags - set.go evaluates configured flags and runtime overrides.
+package featureflags
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Flag sources reported by State.
+const (
+	SourceConfig   = "config"
+	SourceOverride = "override"
+)
+
+// State is a snapshot of one flag.
+type State struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"` // config | override
+	Flag    Flag   `json:"flag"`
+	On      int64  `json:"on"`                       // Requests the flag turned on
+	Off     int64  `json:"off"`                      // Requests the flag turned off
+	Shadows bool   `json:"shadows_config,omitempty"` // Override replaces a configured flag
+}
+
+// counts tallies decisions for one flag.
+type counts struct{ on, off int64 }
+
+// Set evaluates flags. Overrides set through the admin API take precedence
+// over config and survive config reloads, but not restarts. Thread-safe.
+// Safe to call on a nil receiver (no flags defined).
+type Set struct {
+	mu        sync.Mutex
+	cfg       Config
+	overrides map[string]Flag
+	counts    map[string]*counts
+}
+
+// NewSet returns a set evaluating cfg's flags.
+func NewSet(cfg Config) *Set {
+	return &Set{cfg: cfg, overrides: make(map[string]Flag), counts: make(map[string]*counts)}
+}
+
+// UpdateConfig swaps the configured flags (hot-reload). Overrides are kept.
+func (s *Set) UpdateConfig(cfg Config) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+// lookup returns the effective flag for name. Caller holds s.mu.
+func (s *Set) lookup(name string) (Flag, string, bool) {
+	if f, ok := s.overrides[name]; ok {
+		return f, SourceOverride, true
+	}
+	if f, ok := s.cfg.Flags[name]; ok {
+		return f, SourceConfig, true
+	}
+	return Flag{}, "", false
+}
+
+// Evaluate decides every defined flag for a request and counts the decisions.
+// Returns nil when no flag is defined.
+func (s *Set) Evaluate(t Target) Decisions {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cfg.Flags) == 0 && len(s.overrides) == 0 {
+		return nil
+	}
+	d := make(Decisions, len(s.cfg.Flags)+len(s.overrides))
+	for _, name := range Known {
+		f, _, ok := s.lookup(name)
+		if !ok {
+			continue
+		}
+		on, _ := f.Decide(name, t)
+		d[name] = on
+		c := s.counts[name]
+		if c == nil {
+			c = &counts{}
+			s.counts[name] = c
+		}
+		if on {
+			c.on++
+		} else {
+			c.off++
+		}
+	}
+	return d
+}
+
+// Explain returns flag name's state and its decision for t without counting it.
+// ok is false when the flag is not defined.
+func (s *Set) Explain(name string, t Target) (st State, on bool, reason string, ok bool) {
+	if s == nil {
+		return State{}, false, "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, _, ok := s.lookup(name)
+	if !ok {
+		return State{}, false, "", false
+	}
+	on, reason = f.Decide(name, t)
+	return s.stateLocked(name), on, reason, true
+}
+
+// Override replaces flag name at runtime.
+func (s *Set) Override(name string, f Flag) error {
+	if s == nil {
+		return errors.New("featureflags: no flag set")
+	}
+	if err := ValidateFlag(name, f); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[name] = f
+	return nil
+}
+
+// ClearOverride drops a runtime override, reverting to config.
+// Returns false when name had no override.
+func (s *Set) ClearOverride(name string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.overrides[name]; !ok {
+		return false
+	}
+	delete(s.overrides, name)
+	return true
+}
+
+// States returns every defined flag, sorted by name.
+func (s *Set) States() []State {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.cfg.Flags)+len(s.overrides))
+	for name := range s.cfg.Flags {
+		names = append(names, name)
+	}
+	for name := range s.overrides {
+		if _, ok := s.cfg.Flags[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	out := make([]State, 0, len(names))
+	for _, name := range names {
+		out = append(out, s.stateLocked(name))
+	}
+	return out
+}
+
+// stateLocked builds name's state. Caller holds s.mu and name is defined.
+func (s *Set) stateLocked(name string) State {
+	f, source, _ := s.lookup(name)
+	st := State{Name: name, Source: source, Flag: f}
+	if source == SourceOverride {
+		_, st.Shadows = s.cfg.Flags[name]
+	}
+	if c := s.counts[name]; c != nil {
+		st.On, st.Off = c.on, c.off
+	}
+	return st
+}
@@ -0,0 +1,312 @@
+// Package fleet - bigquery.go streams batches into BigQuery.
+//
+// Rows go through tabledata.insertAll, one call per batch, each row with an
+// insertId derived from the batch ID so BigQuery drops rows of a retried
+// batch it has already seen. Access tokens come from a service account key
+// (a self-signed JWT exchanged at the key's token_uri) or, without a key,
+// from the GCE metadata server; both with the standard library only.
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// bigQueryScope allows streaming inserts and nothing else.
+	bigQueryScope = "https://www.googleapis.com/auth/bigquery.insertdata"
+
+	// defaultMetadataHost serves instance credentials on GCE, GKE and Cloud Run.
+	// GCE_METADATA_HOST overrides it, as in Google's client libraries.
+	defaultMetadataHost = "metadata.google.internal"
+
+	// tokenRefreshMargin renews a token this long before it expires.
+	tokenRefreshMargin = time.Minute
+)
+
+// bigQuerySink calls tabledata.insertAll once per batch.
+type bigQuerySink struct {
+	cfg    BigQuerySinkConfig
+	client *http.Client
+	tokens *googleTokenSource
+}
+
+func newBigQuerySink(cfg BigQuerySinkConfig, client *http.Client) *bigQuerySink {
+	keyFile := cfg.CredentialsFile
+	if keyFile == "" {
+		keyFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	return &bigQuerySink{cfg: cfg, client: client, tokens: &googleTokenSource{keyFile: keyFile, client: client}}
+}
+
+// bigQueryRow is one insertAll row. seq is a string because INT64 values
+// above 2^53 do not survive JSON numbers.
+type bigQueryRow struct {
+	InsertID string `json:"insertId"`
+	JSON     struct {
+		InstanceID string `json:"instance_id"`
+		Seq        string `json:"seq"`
+		ReceivedAt string `json:"received_at"`
+		Event      string `json:"event"`
+	} `json:"json"`
+}
+
+// bigQueryInsertResponse lists the rows BigQuery refused.
+type bigQueryInsertResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+func (s *bigQuerySink) insertURL() string {
+	endpoint := s.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultBigQueryEndpoint
+	}
+	table := s.cfg.Table
+	if table == "" {
+		table = DefaultBigQueryTable
+	}
+	return fmt.Sprintf("%s/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll",
+		strings.TrimSuffix(endpoint, "/"), url.PathEscape(s.cfg.Project), s.cfg.Dataset, table)
+}
+
+func (s *bigQuerySink) Deliver(ctx context.Context, b *Batch) error {
+	rows := make([]bigQueryRow, len(b.Events))
+	var event bytes.Buffer
+	for i, ev := range b.Events {
+		event.Reset()
+		if err := json.Compact(&event, ev); err != nil {
+			return &PermanentError{Err: fmt.Errorf("event is not valid JSON: %w", err)}
+		}
+		rows[i].InsertID = b.ID() + "/" + strconv.Itoa(i)
+		rows[i].JSON.InstanceID = b.InstanceID
+		rows[i].JSON.Seq = strconv.FormatUint(b.Seq, 10)
+		rows[i].JSON.ReceivedAt = b.CreatedAt.UTC().Format(time.RFC3339Nano)
+		rows[i].JSON.Event = event.String()
+	}
+	body, err := json.Marshal(map[string]any{"rows": rows})
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	token, err := s.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.insertURL(), bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req) // #nosec G704 -- the endpoint comes from operator config
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+	}()
+	if err := statusError("bigquery", resp); err != nil {
+		return err
+	}
+	// A 200 can still refuse rows (schema mismatch); the whole batch is then
+	// rejected, and a retry would be refused the same way.
+	var out bigQueryInsertResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("bigquery: decode response: %w", err)
+	}
+	if len(out.InsertErrors) > 0 {
+		first := out.InsertErrors[0]
+		detail := "no detail"
+		if len(first.Errors) > 0 {
+			detail = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return &PermanentError{Err: fmt.Errorf("bigquery rejected %d of %d rows (row %d: %s)",
+			len(out.InsertErrors), len(rows), first.Index, detail)}
+	}
+	return nil
+}
+
+// googleTokenSource issues OAuth access tokens for the BigQuery scope and
+// caches them until shortly before they expire. Thread-safe.
+type googleTokenSource struct {
+	keyFile string // Service account key; empty uses the metadata server
+	client  *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// serviceAccountKey is the part of a service account key file used here.
+type serviceAccountKey struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// tokenResponse is the OAuth token endpoint answer (also the metadata server's).
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Token returns a valid access token, fetching a new one when needed.
+// Unusable credentials are a PermanentError; fetch failures are retried.
+func (t *googleTokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Add(tokenRefreshMargin).Before(t.expiry) {
+		return t.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if t.keyFile != "" {
+		req, err = t.serviceAccountRequest(ctx)
+	} else {
+		req, err = t.metadataRequest(ctx)
+	}
+	if err != nil {
+		return "", &PermanentError{Err: err}
+	}
+	resp, err := t.client.Do(req) // #nosec G704 -- token_uri comes from the operator's key file
+	if err != nil {
+		var dnsErr *net.DNSError
+		if t.keyFile == "" && errors.As(err, &dnsErr) {
+			return "", &PermanentError{Err: errors.New("bigquery: no Google credentials (set credentials_file or GOOGLE_APPLICATION_CREDENTIALS outside Google Cloud)")}
+		}
+		return "", fmt.Errorf("bigquery: fetch access token: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+	}()
+	if err := statusError("bigquery token endpoint", resp); err != nil {
+		return "", err
+	}
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("bigquery: token endpoint returned no access token")
+	}
+	t.token = tok.AccessToken
+	t.expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return t.token, nil
+}
+
+// serviceAccountRequest builds the JWT bearer grant for the key file.
+func (t *googleTokenSource) serviceAccountRequest(ctx context.Context) (*http.Request, error) {
+	raw, err := os.ReadFile(t.keyFile) // #nosec G304 -- path from operator config or GOOGLE_APPLICATION_CREDENTIALS
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: read credentials: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("bigquery: parse credentials %s: %w", t.keyFile, err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.TokenURI == "" {
+		return nil, fmt.Errorf("bigquery: %s is not a service account key", t.keyFile)
+	}
+	signer, err := parseRSAKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: %s: %w", t.keyFile, err)
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(signer, map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": bigQueryScope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// metadataRequest asks the metadata server for the instance's default service account token.
+func (t *googleTokenSource) metadataRequest(ctx context.Context) (*http.Request, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+	u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(bigQueryScope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return req, nil
+}
+
+// parseRSAKey decodes the PEM private key of a service account (PKCS#8, or PKCS#1).
+func parseRSAKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private_key: %w", err)
+	}
+	return key, nil
+}
+
+// signJWT returns an RS256-signed JWT with the given claims.
+func signJWT(key *rsa.PrivateKey, claims map[string]any) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
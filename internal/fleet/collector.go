@@ -0,0 +1,241 @@
+// Package fleet - collector.go receives telemetry batches from gateway replicas.
+//
+// Each accepted batch is appended to a JSONL file, one envelope per event, and
+// fsynced before the batch is acknowledged. Batches are de-duplicated by
+// (instance_id, seq) so a shipper retrying after a lost acknowledgement does
+// not double-count. When the writer queue is full the collector answers 503 and
+// the shipper retries later.
+package fleet
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// Collector limits.
+const (
+	DefaultMaxBatchBytes = 8 << 20  // Compressed request body
+	maxDecodedBytes      = 64 << 20 // Decompressed events per batch
+	seenBatchesCap       = 100_000  // Batch IDs remembered for de-duplication
+)
+
+// CollectorConfig enables the /telemetry/ingest endpoint on this gateway.
+type CollectorConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	OutputPath    string `yaml:"output_path"`     // JSONL file for received events
+	Token         string `yaml:"token"`           // Bearer token replicas must present
+	MaxBatchBytes int64  `yaml:"max_batch_bytes"` // Max compressed batch size (default: 8 MiB)
+}
+
+// Validate checks the collector configuration.
+func (c CollectorConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.OutputPath == "" {
+		return fmt.Errorf("telemetry_collector.output_path is required when enabled")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("telemetry_collector.token is required when enabled (the endpoint is reachable from other hosts)")
+	}
+	if c.MaxBatchBytes < 0 {
+		return fmt.Errorf("telemetry_collector.max_batch_bytes must not be negative")
+	}
+	return nil
+}
+
+// IngestResponse acknowledges a batch.
+type IngestResponse struct {
+	BatchID   string `json:"batch_id"`
+	Accepted  int    `json:"accepted"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// CollectedEvent is one line of the collector's output file.
+type CollectedEvent struct {
+	InstanceID string          `json:"instance_id"`
+	Seq        uint64          `json:"seq"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// CollectorStats is a snapshot of collector counters.
+type CollectorStats struct {
+	BatchesAccepted   int64 `json:"batches_accepted"`
+	BatchesDuplicate  int64 `json:"batches_duplicate"`
+	BatchesRejected   int64 `json:"batches_rejected"`
+	BatchesBackoff    int64 `json:"batches_backoff"` // Answered 503 because the writer was full
+	EventsAccepted    int64 `json:"events_accepted"`
+	InstancesReported int   `json:"instances_reported"`
+}
+
+// Collector is an http.Handler for telemetry batches. Thread-safe.
+type Collector struct {
+	cfg    CollectorConfig
+	writer *monitoring.AsyncWriter
+
+	mu        sync.Mutex // serializes ingest so a batch is written at most once
+	seen      map[string]struct{}
+	seenOrder []string // FIFO eviction for seen
+	instances map[string]struct{}
+
+	accepted  atomic.Int64
+	duplicate atomic.Int64
+	rejected  atomic.Int64
+	backoff   atomic.Int64
+	events    atomic.Int64
+}
+
+// NewCollector opens the output file.
+func NewCollector(cfg CollectorConfig, writerCfg monitoring.AsyncWriterConfig) (*Collector, error) {
+	if cfg.MaxBatchBytes <= 0 {
+		cfg.MaxBatchBytes = DefaultMaxBatchBytes
+	}
+	w, err := monitoring.OpenAsyncWriter(cfg.OutputPath, writerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open telemetry collector output: %w", err)
+	}
+	return &Collector{
+		cfg:       cfg,
+		writer:    w,
+		seen:      make(map[string]struct{}),
+		instances: make(map[string]struct{}),
+	}, nil
+}
+
+// ServeHTTP handles POST /telemetry/ingest.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.authorized(r) {
+		c.rejected.Add(1)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != ContentType {
+		c.rejected.Add(1)
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.cfg.MaxBatchBytes))
+	if err != nil {
+		c.rejected.Add(1)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "batch too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	batch, err := DecodeBatch(data, maxDecodedBytes)
+	if err != nil {
+		c.rejected.Add(1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, status := c.ingest(batch)
+	if status != http.StatusOK {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "collector backed up, retry later", status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("fleet: failed to encode ingest response")
+	}
+}
+
+func (c *Collector) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(c.cfg.Token)) == 1
+}
+
+// ingest writes a batch once and acknowledges only after it is synced.
+func (c *Collector) ingest(b *Batch) (IngestResponse, int) {
+	id := b.ID()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, dup := c.seen[id]; dup {
+		c.duplicate.Add(1)
+		return IngestResponse{BatchID: id, Duplicate: true}, http.StatusOK
+	}
+
+	now := time.Now().UTC()
+	for _, ev := range b.Events {
+		line, err := json.Marshal(CollectedEvent{InstanceID: b.InstanceID, Seq: b.Seq, ReceivedAt: now, Event: ev})
+		if err != nil {
+			continue
+		}
+		if !c.writer.Write(append(line, '\n')) {
+			// Lines already queued may be written; the retry can then repeat
+			// them, which at-least-once delivery allows.
+			c.backoff.Add(1)
+			return IngestResponse{}, http.StatusServiceUnavailable
+		}
+	}
+	c.writer.Flush()
+
+	c.markSeen(id)
+	c.instances[b.InstanceID] = struct{}{}
+	c.accepted.Add(1)
+	c.events.Add(int64(len(b.Events)))
+	return IngestResponse{BatchID: id, Accepted: len(b.Events)}, http.StatusOK
+}
+
+// markSeen records id, evicting the oldest IDs beyond seenBatchesCap. Caller holds mu.
+func (c *Collector) markSeen(id string) {
+	c.seen[id] = struct{}{}
+	c.seenOrder = append(c.seenOrder, id)
+	if len(c.seenOrder) > seenBatchesCap {
+		evict := len(c.seenOrder) - seenBatchesCap
+		for _, old := range c.seenOrder[:evict] {
+			delete(c.seen, old)
+		}
+		c.seenOrder = append(c.seenOrder[:0], c.seenOrder[evict:]...)
+	}
+}
+
+// Stats returns a snapshot of the collector counters. Safe to call on nil.
+func (c *Collector) Stats() CollectorStats {
+	if c == nil {
+		return CollectorStats{}
+	}
+	c.mu.Lock()
+	instances := len(c.instances)
+	c.mu.Unlock()
+	return CollectorStats{
+		BatchesAccepted:   c.accepted.Load(),
+		BatchesDuplicate:  c.duplicate.Load(),
+		BatchesRejected:   c.rejected.Load(),
+		BatchesBackoff:    c.backoff.Load(),
+		EventsAccepted:    c.events.Load(),
+		InstancesReported: instances,
+	}
+}
+
+// Close flushes and closes the output file. Safe to call on nil.
+func (c *Collector) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.writer.Close()
+}
@@ -0,0 +1,245 @@
+// Package fleet - parquet.go encodes a batch as a Parquet file.
+//
+// The file has the CollectedEvent columns (instance_id, seq, received_at,
+// event), all required, in one row group with one gzip-compressed, PLAIN
+// encoded data page per column. That is the smallest layout every reader
+// (Athena, BigQuery, Spark, DuckDB, pyarrow) accepts, and it keeps the
+// writer to the standard library: the footer is hand-encoded Thrift.
+package fleet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// parquetMagic starts and ends every Parquet file.
+const parquetMagic = "PAR1"
+
+// Parquet enums (parquet.thrift).
+const (
+	parquetInt64     = 2 // Type.INT64
+	parquetByteArray = 6 // Type.BYTE_ARRAY
+
+	parquetRequired = 0 // FieldRepetitionType.REQUIRED
+
+	parquetUTF8            = 0  // ConvertedType.UTF8
+	parquetTimestampMillis = 9  // ConvertedType.TIMESTAMP_MILLIS
+	parquetJSON            = 19 // ConvertedType.JSON
+	parquetNoConversion    = -1
+
+	parquetPlain = 0 // Encoding.PLAIN
+	parquetRLE   = 3 // Encoding.RLE
+
+	parquetGzip     = 2 // CompressionCodec.GZIP
+	parquetDataPage = 0 // PageType.DATA_PAGE
+)
+
+// parquetColumn is one column of the file with its PLAIN-encoded values.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	values    bytes.Buffer
+}
+
+func (c *parquetColumn) putBytes(v []byte) {
+	_ = binary.Write(&c.values, binary.LittleEndian, uint32(len(v))) // #nosec G115 -- events are bounded by the batch size
+	c.values.Write(v)
+}
+
+func (c *parquetColumn) putInt64(v int64) {
+	_ = binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+// EncodeParquet writes the batch as a Parquet file with one row per event.
+func EncodeParquet(b *Batch) ([]byte, error) {
+	cols := []*parquetColumn{
+		{name: "instance_id", typ: parquetByteArray, converted: parquetUTF8},
+		{name: "seq", typ: parquetInt64, converted: parquetNoConversion},
+		{name: "received_at", typ: parquetInt64, converted: parquetTimestampMillis},
+		{name: "event", typ: parquetByteArray, converted: parquetJSON},
+	}
+	var event bytes.Buffer
+	for _, ev := range b.Events {
+		event.Reset()
+		if err := json.Compact(&event, ev); err != nil {
+			return nil, &PermanentError{Err: fmt.Errorf("event is not valid JSON: %w", err)}
+		}
+		cols[0].putBytes([]byte(b.InstanceID))
+		cols[1].putInt64(int64(b.Seq)) // #nosec G115 -- sequence numbers start from the clock in nanoseconds
+		cols[2].putInt64(b.CreatedAt.UnixMilli())
+		cols[3].putBytes(event.Bytes())
+	}
+
+	rows := int64(len(b.Events))
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	meta := newThriftWriter()
+	meta.i32(1, 1) // version
+	meta.beginList(2, thriftStruct, len(cols)+1)
+	meta.beginElem()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(cols))) // #nosec G115 -- fixed column count
+	meta.endStruct()
+	for _, c := range cols {
+		meta.beginElem()
+		meta.i32(1, c.typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, c.name)
+		if c.converted != parquetNoConversion {
+			meta.i32(6, c.converted)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, rows)
+
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElem()
+	meta.beginList(1, thriftStruct, len(cols))
+	var rowGroupBytes int64
+	for _, c := range cols {
+		offset := int64(file.Len())
+		data := gzipBytes(c.values.Bytes())
+
+		page := newThriftWriter()
+		page.i32(1, parquetDataPage)
+		page.i32(2, int32(c.values.Len())) // #nosec G115 -- batches are far below 2 GiB
+		page.i32(3, int32(len(data)))      // #nosec G115 -- batches are far below 2 GiB
+		page.beginStruct(5)
+		page.i32(1, int32(rows)) // #nosec G115 -- rows are bounded by the batch size
+		page.i32(2, parquetPlain)
+		page.i32(3, parquetRLE)
+		page.i32(4, parquetRLE)
+		page.endStruct()
+		header := page.finish()
+		file.Write(header)
+		file.Write(data)
+
+		uncompressed := int64(len(header) + c.values.Len())
+		rowGroupBytes += uncompressed
+		meta.beginElem()
+		meta.i64(2, offset) // file_offset
+		meta.beginStruct(3)
+		meta.i32(1, c.typ)
+		meta.beginList(2, thriftI32, 2)
+		meta.listI32(parquetPlain)
+		meta.listI32(parquetRLE)
+		meta.beginList(3, thriftBinary, 1)
+		meta.listBinary(c.name)
+		meta.i32(4, parquetGzip)
+		meta.i64(5, rows)
+		meta.i64(6, uncompressed)
+		meta.i64(7, int64(len(header)+len(data)))
+		meta.i64(9, offset) // data_page_offset
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, rowGroupBytes)
+	meta.i64(3, rows)
+	meta.endStruct()
+	meta.binary(6, "context-gateway")
+
+	footer := meta.finish()
+	file.Write(footer)
+	_ = binary.Write(&file, binary.LittleEndian, uint32(len(footer))) // #nosec G115 -- footer of a fixed schema
+	file.WriteString(parquetMagic)
+	return file.Bytes(), nil
+}
+
+// Thrift compact protocol field types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, the encoding
+// of Parquet page headers and footers.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // last field ID written in each open struct
+}
+
+// newThriftWriter starts the top-level struct.
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+// finish closes the top-level struct and returns the encoding.
+func (w *thriftWriter) finish() []byte {
+	w.buf.WriteByte(0)
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.uvarint(uint64((v << 1) ^ (v >> 63))) // #nosec G115 -- zigzag encoding
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	top := len(w.last) - 1
+	if delta := id - w.last[top]; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	w.last[top] = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(id int16, v string) {
+	w.field(id, thriftBinary)
+	w.listBinary(v)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.beginElem()
+}
+
+// beginElem opens a struct that is a list element.
+func (w *thriftWriter) beginElem() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) beginList(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xF0 | elem)
+	w.uvarint(uint64(n)) // #nosec G115 -- list lengths are non-negative
+}
+
+func (w *thriftWriter) listI32(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) listBinary(v string) {
+	w.uvarint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
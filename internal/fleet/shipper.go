@@ -0,0 +1,346 @@
+// Package fleet - shipper.go batches local telemetry and ships it to a sink.
+//
+// Enqueue never blocks the request path. A batcher goroutine seals events into
+// batches (by size or interval) and hands them to a bounded pending queue; a
+// sender goroutine delivers one batch at a time and retries it with backoff
+// until the sink acknowledges it. When the sink is slow or down the
+// pending queue fills, the batcher stops draining, and new events are dropped
+// and counted rather than buffered without bound.
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/redaction"
+	"github.com/compresr/context-gateway/internal/retry"
+)
+
+// Export defaults (applied when ExportConfig fields are zero).
+const (
+	DefaultBatchSize         = 500
+	DefaultFlushInterval     = 5 * time.Second
+	DefaultMaxPendingBatches = 64
+	DefaultExportTimeout     = 10 * time.Second
+
+	// minEventQueue absorbs bursts between batcher wake-ups.
+	minEventQueue = 1024
+
+	// maxRetryDelay caps the backoff between delivery attempts of one batch.
+	maxRetryDelay = 30 * time.Second
+)
+
+// ExportConfig enables shipping this instance's telemetry to a collector or sink.
+type ExportConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Sink              string        `yaml:"sink"`                // collector (default), http, s3, clickhouse or bigquery
+	CollectorURL      string        `yaml:"collector_url"`       // e.g. http://collector:18080/telemetry/ingest
+	InstanceID        string        `yaml:"instance_id"`         // Identifies this replica (default: hostname)
+	Token             string        `yaml:"token"`               // Sent as Bearer token; must match the collector's token
+	BatchSize         int           `yaml:"batch_size"`          // Max events per batch (default: 500)
+	FlushInterval     time.Duration `yaml:"flush_interval"`      // Max age of a partial batch (default: 5s)
+	MaxPendingBatches int           `yaml:"max_pending_batches"` // Unacknowledged batches held before dropping events (default: 64)
+	Timeout           time.Duration `yaml:"timeout"`             // Per-attempt HTTP timeout (default: 10s)
+
+	HTTP       HTTPSinkConfig       `yaml:"http"`
+	S3         S3SinkConfig         `yaml:"s3"`
+	ClickHouse ClickHouseSinkConfig `yaml:"clickhouse"`
+	BigQuery   BigQuerySinkConfig   `yaml:"bigquery"`
+
+	// Redactor scrubs each event before it is queued. Set at runtime from
+	// monitoring.redaction; nil ships events unchanged.
+	Redactor *redaction.Redactor `yaml:"-" json:"-"`
+}
+
+// withDefaults fills zero fields with defaults.
+func (c ExportConfig) withDefaults() ExportConfig {
+	if c.Sink == "" {
+		c.Sink = SinkCollector
+	}
+	if c.InstanceID == "" {
+		if host, err := os.Hostname(); err == nil && host != "" {
+			c.InstanceID = host
+		} else {
+			c.InstanceID = "gateway"
+		}
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	if c.MaxPendingBatches <= 0 {
+		c.MaxPendingBatches = DefaultMaxPendingBatches
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultExportTimeout
+	}
+	return c
+}
+
+// Validate checks the export configuration.
+func (c ExportConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := c.validateSink(); err != nil {
+		return err
+	}
+	if c.BatchSize < 0 || c.MaxPendingBatches < 0 || c.FlushInterval < 0 || c.Timeout < 0 {
+		return fmt.Errorf("telemetry_export: batch_size, max_pending_batches, flush_interval and timeout must not be negative")
+	}
+	return nil
+}
+
+// ShipperStats is a snapshot of shipper counters.
+type ShipperStats struct {
+	InstanceID     string `json:"instance_id"`
+	Sink           string `json:"sink"`
+	EventsEnqueued int64  `json:"events_enqueued"`
+	EventsDropped  int64  `json:"events_dropped"`
+	EventsSent     int64  `json:"events_sent"`
+	BatchesSent    int64  `json:"batches_sent"`
+	BatchesFailed  int64  `json:"batches_failed"` // Rejected by the sink with a non-retryable status
+	Retries        int64  `json:"retries"`
+	PendingBatches int    `json:"pending_batches"`
+	LastError      string `json:"last_error,omitempty"`
+}
+
+// Shipper batches events and delivers them to a sink at least once.
+// Thread-safe. Safe to call on a nil receiver (disabled).
+type Shipper struct {
+	cfg  ExportConfig
+	sink Sink
+
+	events  chan json.RawMessage
+	pending chan *Batch
+	stop    chan struct{} // closed by Close when its context expires: abandon retries
+	done    chan struct{}
+	nextSeq uint64
+
+	mu     sync.RWMutex // guards closed against concurrent enqueue
+	closed bool
+
+	enqueued      atomic.Int64
+	dropped       atomic.Int64
+	sent          atomic.Int64
+	batchesSent   atomic.Int64
+	batchesFailed atomic.Int64
+	retries       atomic.Int64
+	lastErr       atomic.Value // string
+}
+
+// NewShipper starts the batcher and sender goroutines.
+func NewShipper(cfg ExportConfig) *Shipper {
+	cfg = cfg.withDefaults()
+	s := &Shipper{
+		cfg:    cfg,
+		sink:   newSink(cfg, &http.Client{Timeout: cfg.Timeout}),
+		events: make(chan json.RawMessage, max(cfg.BatchSize*2, minEventQueue)),
+		// Batches in the channel plus the one the sender holds.
+		pending: make(chan *Batch, max(cfg.MaxPendingBatches-1, 0)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		// Sequence numbers start at the wall clock so a restarted instance
+		// never reuses a (instance_id, seq) pair the collector has seen.
+		nextSeq: uint64(time.Now().UnixNano()), //nolint:gosec // G115: wall clock is positive
+	}
+	go s.batch()
+	go s.send()
+	return s
+}
+
+// Enqueue adds one event to the next batch. Never blocks.
+// Returns false when the event was dropped because the shipper is backed up or closed.
+func (s *Shipper) Enqueue(event any) bool {
+	if s == nil {
+		return false
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Debug().Err(err).Msg("fleet: failed to encode telemetry event")
+		return false
+	}
+	data = s.cfg.Redactor.JSON(data)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return false
+	}
+	select {
+	case s.events <- data:
+		s.enqueued.Add(1)
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// batch seals events into batches and blocks while the pending queue is full.
+func (s *Shipper) batch() {
+	defer close(s.pending)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var buf []json.RawMessage
+	seal := func() {
+		if len(buf) == 0 {
+			return
+		}
+		b := &Batch{InstanceID: s.cfg.InstanceID, Seq: s.nextSeq, CreatedAt: time.Now().UTC(), Events: buf}
+		s.nextSeq++
+		buf = nil
+		select {
+		case s.pending <- b:
+		case <-s.stop:
+			s.dropped.Add(int64(len(b.Events)))
+		}
+	}
+
+	for {
+		select {
+		case ev, ok := <-s.events:
+			if !ok {
+				seal()
+				return
+			}
+			buf = append(buf, ev)
+			if len(buf) >= s.cfg.BatchSize {
+				seal()
+			}
+		case <-ticker.C:
+			seal()
+		}
+	}
+}
+
+// send delivers pending batches in order, retrying each until acknowledged.
+func (s *Shipper) send() {
+	defer close(s.done)
+	for b := range s.pending {
+		s.deliver(b)
+	}
+}
+
+// deliver hands one batch to the sink until it is acknowledged, permanently
+// rejected, or the shipper is stopped.
+func (s *Shipper) deliver(b *Batch) {
+	for attempt := 0; ; attempt++ {
+		err := s.attempt(b)
+		var permanent *PermanentError
+		switch {
+		case err == nil:
+			s.batchesSent.Add(1)
+			s.sent.Add(int64(len(b.Events)))
+			return
+		case errors.As(err, &permanent):
+			s.batchesFailed.Add(1)
+			s.setLastErr(fmt.Errorf("%s rejected batch %s: %w", s.cfg.Sink, b.ID(), err))
+			log.Error().Err(err).Str("sink", s.cfg.Sink).Str("batch", b.ID()).Int("events", len(b.Events)).
+				Msg("fleet: telemetry batch rejected, dropping it")
+			return
+		}
+		s.setLastErr(err)
+
+		delay := min(retry.Backoff(attempt), maxRetryDelay)
+		log.Debug().Err(err).Str("batch", b.ID()).Dur("retry_in", delay).Msg("fleet: telemetry batch delivery failed")
+		s.retries.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-s.stop:
+			s.dropped.Add(int64(len(b.Events)))
+			return
+		}
+	}
+}
+
+// attempt makes one delivery, bounded by the timeout and cancelled by abandon.
+func (s *Shipper) attempt(b *Batch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return s.sink.Deliver(ctx, b)
+}
+
+func (s *Shipper) setLastErr(err error) {
+	s.lastErr.Store(err.Error())
+}
+
+// Stats returns a snapshot of the shipper counters.
+func (s *Shipper) Stats() ShipperStats {
+	if s == nil {
+		return ShipperStats{}
+	}
+	st := ShipperStats{
+		InstanceID:     s.cfg.InstanceID,
+		Sink:           s.cfg.Sink,
+		EventsEnqueued: s.enqueued.Load(),
+		EventsDropped:  s.dropped.Load(),
+		EventsSent:     s.sent.Load(),
+		BatchesSent:    s.batchesSent.Load(),
+		BatchesFailed:  s.batchesFailed.Load(),
+		Retries:        s.retries.Load(),
+		PendingBatches: len(s.pending),
+	}
+	if v, ok := s.lastErr.Load().(string); ok {
+		st.LastError = v
+	}
+	return st
+}
+
+// Close seals the current batch and keeps delivering until every pending batch
+// is acknowledged or ctx expires, after which undelivered events are dropped.
+// Safe to call on nil and more than once.
+func (s *Shipper) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.abandon()
+		<-s.done
+		return errors.Join(ctx.Err(), fmt.Errorf("fleet: %d telemetry events not delivered", s.undelivered()))
+	}
+}
+
+// abandon stops retries; safe to call more than once.
+func (s *Shipper) abandon() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+func (s *Shipper) undelivered() int64 {
+	return s.enqueued.Load() - s.sent.Load()
+}
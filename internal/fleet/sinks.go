@@ -0,0 +1,455 @@
+// Package fleet - sinks.go delivers telemetry batches to their destination.
+//
+// The shipper batches and retries; a Sink only delivers one batch. Besides
+// the gateway collector, batches can go to any HTTP endpoint as NDJSON, to
+// an S3-compatible bucket as gzipped JSONL or Parquet objects, to ClickHouse
+// over its HTTP interface, or to BigQuery through the streaming insert API.
+// Outside the collector, every event is written as a CollectedEvent row, so
+// all destinations hold the same rows. Deliveries are idempotent per batch
+// where the destination allows it (S3 object key, ClickHouse
+// insert_deduplication_token, BigQuery insertId), matching at-least-once retries.
+package fleet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/compresr/context-gateway/internal/retry"
+)
+
+// Export sinks (telemetry_export.sink).
+const (
+	SinkCollector  = "collector"  // Another gateway's /telemetry/ingest (default)
+	SinkHTTP       = "http"       // Any endpoint that accepts NDJSON
+	SinkS3         = "s3"         // Gzipped JSONL objects in an S3-compatible bucket
+	SinkClickHouse = "clickhouse" // INSERT ... FORMAT JSONEachRow over ClickHouse's HTTP interface
+	SinkBigQuery   = "bigquery"   // tabledata.insertAll (streaming inserts)
+)
+
+// S3 object formats (telemetry_export.s3.format).
+const (
+	S3FormatJSONL   = "jsonl"   // Gzipped JSON lines (default)
+	S3FormatParquet = "parquet" // One Parquet file per batch
+)
+
+// Sink defaults.
+const (
+	DefaultS3Prefix         = "context-gateway"
+	DefaultClickHouseTable  = "gateway_telemetry"
+	DefaultBigQueryTable    = "gateway_telemetry"
+	DefaultBigQueryEndpoint = "https://bigquery.googleapis.com"
+)
+
+// clickHouseIdentRe matches the database and table names accepted in the
+// INSERT statement, which cannot be passed as a query parameter.
+var clickHouseIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BigQuery project IDs (optionally domain-scoped) and dataset/table names,
+// which are interpolated into the API path.
+var (
+	bigQueryProjectRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:_-]*$`)
+	bigQueryIdentRe   = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// HTTPSinkConfig posts each batch as NDJSON.
+type HTTPSinkConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // e.g. Authorization
+	Gzip    bool              `yaml:"gzip"`    // Send Content-Encoding: gzip
+}
+
+// S3SinkConfig writes each batch as one gzipped JSONL object. Credentials
+// come from the standard AWS chain (env, shared config, instance role).
+type S3SinkConfig struct {
+	Bucket   string `yaml:"bucket"`
+	Prefix   string `yaml:"prefix"`   // Key prefix (default: context-gateway)
+	Region   string `yaml:"region"`   // Default: AWS_REGION, then us-east-1
+	Endpoint string `yaml:"endpoint"` // S3-compatible endpoint (MinIO, R2, GCS); uses path-style URLs
+	Format   string `yaml:"format"`   // jsonl (default) or parquet
+}
+
+// ClickHouseSinkConfig inserts each batch into a table with
+// instance_id, seq, received_at and event columns (see docs/telemetry-export.md).
+type ClickHouseSinkConfig struct {
+	URL      string `yaml:"url"`      // HTTP interface, e.g. http://clickhouse:8123
+	Database string `yaml:"database"` // Default: the user's default database
+	Table    string `yaml:"table"`    // Default: gateway_telemetry
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// BigQuerySinkConfig streams each batch into a table with instance_id, seq,
+// received_at and event columns (see docs/telemetry-export.md).
+type BigQuerySinkConfig struct {
+	Project         string `yaml:"project"`
+	Dataset         string `yaml:"dataset"`
+	Table           string `yaml:"table"`            // Default: gateway_telemetry
+	CredentialsFile string `yaml:"credentials_file"` // Service account key; default GOOGLE_APPLICATION_CREDENTIALS, then the GCE metadata server
+	Endpoint        string `yaml:"endpoint"`         // API root (default: https://bigquery.googleapis.com)
+}
+
+// validateSink checks the settings of the selected sink.
+func (c ExportConfig) validateSink() error {
+	switch c.Sink {
+	case "", SinkCollector:
+		return validateHTTPURL("telemetry_export.collector_url", c.CollectorURL)
+	case SinkHTTP:
+		return validateHTTPURL("telemetry_export.http.url", c.HTTP.URL)
+	case SinkS3:
+		if c.S3.Bucket == "" {
+			return fmt.Errorf("telemetry_export.s3.bucket is required")
+		}
+		switch c.S3.Format {
+		case "", S3FormatJSONL, S3FormatParquet:
+		default:
+			return fmt.Errorf("telemetry_export.s3.format must be %s or %s, got %q", S3FormatJSONL, S3FormatParquet, c.S3.Format)
+		}
+		if c.S3.Endpoint != "" {
+			return validateHTTPURL("telemetry_export.s3.endpoint", c.S3.Endpoint)
+		}
+		return nil
+	case SinkClickHouse:
+		for field, name := range map[string]string{"database": c.ClickHouse.Database, "table": c.ClickHouse.Table} {
+			if name != "" && !clickHouseIdentRe.MatchString(name) {
+				return fmt.Errorf("telemetry_export.clickhouse.%s must be a plain identifier, got %q", field, name)
+			}
+		}
+		return validateHTTPURL("telemetry_export.clickhouse.url", c.ClickHouse.URL)
+	case SinkBigQuery:
+		if !bigQueryProjectRe.MatchString(c.BigQuery.Project) {
+			return fmt.Errorf("telemetry_export.bigquery.project is required and must be a project ID, got %q", c.BigQuery.Project)
+		}
+		for field, name := range map[string]string{"dataset": c.BigQuery.Dataset, "table": c.BigQuery.Table} {
+			if (name != "" || field == "dataset") && !bigQueryIdentRe.MatchString(name) {
+				return fmt.Errorf("telemetry_export.bigquery.%s must be a plain identifier, got %q", field, name)
+			}
+		}
+		if c.BigQuery.Endpoint != "" {
+			return validateHTTPURL("telemetry_export.bigquery.endpoint", c.BigQuery.Endpoint)
+		}
+		return nil
+	default:
+		return fmt.Errorf("telemetry_export.sink must be one of %s, %s, %s, %s, %s; got %q", SinkCollector, SinkHTTP, SinkS3, SinkClickHouse, SinkBigQuery, c.Sink)
+	}
+}
+
+// Destination describes where the selected sink writes, for display.
+func (c ExportConfig) Destination() string {
+	switch c.Sink {
+	case SinkHTTP:
+		return c.HTTP.URL
+	case SinkS3:
+		prefix := c.S3.Prefix
+		if prefix == "" {
+			prefix = DefaultS3Prefix
+		}
+		return "s3://" + path.Join(c.S3.Bucket, prefix)
+	case SinkClickHouse:
+		table := c.ClickHouse.Table
+		if table == "" {
+			table = DefaultClickHouseTable
+		}
+		return "clickhouse:" + strings.TrimSuffix(c.ClickHouse.URL, "/") + "/" + table
+	case SinkBigQuery:
+		table := c.BigQuery.Table
+		if table == "" {
+			table = DefaultBigQueryTable
+		}
+		return "bigquery:" + c.BigQuery.Project + "." + c.BigQuery.Dataset + "." + table
+	default:
+		return c.CollectorURL
+	}
+}
+
+func validateHTTPURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http(s) URL, got %q", field, raw)
+	}
+	return nil
+}
+
+// Sink delivers one batch. Errors are retried by the shipper unless wrapped
+// in a PermanentError.
+type Sink interface {
+	Deliver(ctx context.Context, b *Batch) error
+}
+
+// PermanentError marks a delivery failure that retrying will not fix, such
+// as a 400 from the destination. The shipper drops the batch.
+type PermanentError struct{ Err error }
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// newSink builds the sink selected by cfg. cfg has defaults applied.
+func newSink(cfg ExportConfig, client *http.Client) Sink {
+	switch cfg.Sink {
+	case SinkHTTP:
+		return &httpSink{cfg: cfg.HTTP, client: client}
+	case SinkS3:
+		return &s3Sink{cfg: cfg.S3, client: client}
+	case SinkClickHouse:
+		return &clickHouseSink{cfg: cfg.ClickHouse, client: client}
+	case SinkBigQuery:
+		return newBigQuerySink(cfg.BigQuery, client)
+	default:
+		return &collectorSink{url: cfg.CollectorURL, token: cfg.Token, client: client}
+	}
+}
+
+// statusError turns an HTTP status into nil, a retryable error or a PermanentError.
+func statusError(dest string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err := fmt.Errorf("%s returned HTTP %d: %s", dest, resp.StatusCode, strings.TrimSpace(string(body)))
+	if retry.IsTransientStatus(resp.StatusCode) {
+		return err
+	}
+	return &PermanentError{Err: err}
+}
+
+// do sends req and classifies the response.
+func do(client *http.Client, dest string, req *http.Request) error {
+	resp, err := client.Do(req) // #nosec G704 -- destination URLs come from operator config
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+	}()
+	return statusError(dest, resp)
+}
+
+// encodeRows writes the batch as CollectedEvent lines.
+func encodeRows(b *Batch) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range b.Events {
+		if err := enc.Encode(CollectedEvent{InstanceID: b.InstanceID, Seq: b.Seq, ReceivedAt: b.CreatedAt, Event: ev}); err != nil {
+			return nil, &PermanentError{Err: fmt.Errorf("event is not valid JSON: %w", err)}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(data) // bytes.Buffer writes do not fail
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// collectorSink posts the binary batch envelope to a gateway collector.
+type collectorSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *collectorSink) Deliver(ctx context.Context, b *Batch) error {
+	body, err := EncodeBatch(b)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return do(s.client, "collector", req)
+}
+
+// httpSink posts NDJSON rows.
+type httpSink struct {
+	cfg    HTTPSinkConfig
+	client *http.Client
+}
+
+func (s *httpSink) Deliver(ctx context.Context, b *Batch) error {
+	body, err := encodeRows(b)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Gzip {
+		body = gzipBytes(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("X-Batch-ID", b.ID())
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	return do(s.client, "http sink", req)
+}
+
+// clickHouseSink inserts rows with INSERT ... FORMAT JSONEachRow.
+type clickHouseSink struct {
+	cfg    ClickHouseSinkConfig
+	client *http.Client
+}
+
+func (s *clickHouseSink) Deliver(ctx context.Context, b *Batch) error {
+	body, err := encodeRows(b)
+	if err != nil {
+		return err
+	}
+	table := s.cfg.Table
+	if table == "" {
+		table = DefaultClickHouseTable
+	}
+	if s.cfg.Database != "" {
+		table = s.cfg.Database + "." + table
+	}
+	q := url.Values{}
+	q.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	q.Set("input_format_json_read_objects_as_strings", "1") // event lands in a String column
+	q.Set("date_time_input_format", "best_effort")          // received_at is RFC 3339
+	q.Set("insert_deduplication_token", b.ID())             // Retried batches insert once
+	u := strings.TrimSuffix(s.cfg.URL, "/") + "/?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(gzipBytes(body)))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	if s.cfg.Username != "" {
+		req.Header.Set("X-ClickHouse-User", s.cfg.Username)
+		req.Header.Set("X-ClickHouse-Key", s.cfg.Password)
+	}
+	return do(s.client, "clickhouse", req)
+}
+
+// s3Sink PUTs one object per batch, signed with SigV4. The key is derived
+// from the batch ID, so a retried batch overwrites its own object.
+type s3Sink struct {
+	cfg    S3SinkConfig
+	client *http.Client
+
+	once   sync.Once
+	creds  aws.CredentialsProvider
+	region string
+	err    error
+}
+
+// S3ObjectKey returns the object key for a batch:
+// <prefix>/dt=<YYYY-MM-DD>/<instance>/<seq>.jsonl.gz, or <seq>.parquet in
+// the parquet format (date partitions for Athena, BigQuery and friends).
+func S3ObjectKey(prefix, format string, b *Batch) string {
+	if prefix == "" {
+		prefix = DefaultS3Prefix
+	}
+	ext := ".jsonl.gz"
+	if format == S3FormatParquet {
+		ext = ".parquet"
+	}
+	instance := strings.NewReplacer("/", "_", "\\", "_").Replace(b.InstanceID)
+	return path.Join(prefix, "dt="+b.CreatedAt.UTC().Format(time.DateOnly), instance, strconv.FormatUint(b.Seq, 10)+ext)
+}
+
+func (s *s3Sink) load(ctx context.Context) error {
+	s.once.Do(func() {
+		s.region = s.cfg.Region
+		if s.region == "" {
+			s.region = os.Getenv("AWS_REGION")
+		}
+		if s.region == "" {
+			s.region = "us-east-1"
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(s.region))
+		if err != nil {
+			s.err = fmt.Errorf("s3: load AWS config: %w", err)
+			return
+		}
+		s.creds = cfg.Credentials
+	})
+	return s.err
+}
+
+func (s *s3Sink) objectURL(key string) string {
+	if s.cfg.Endpoint != "" {
+		return strings.TrimSuffix(s.cfg.Endpoint, "/") + "/" + s.cfg.Bucket + "/" + key
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.cfg.Bucket, s.region, key)
+}
+
+// encode returns the object body in the configured format.
+func (s *s3Sink) encode(b *Batch) ([]byte, error) {
+	if s.cfg.Format == S3FormatParquet {
+		return EncodeParquet(b)
+	}
+	rows, err := encodeRows(b)
+	if err != nil {
+		return nil, err
+	}
+	return gzipBytes(rows), nil
+}
+
+func (s *s3Sink) Deliver(ctx context.Context, b *Batch) error {
+	if err := s.load(ctx); err != nil {
+		return &PermanentError{Err: err}
+	}
+	if s.creds == nil {
+		return &PermanentError{Err: errors.New("s3: no AWS credentials found")}
+	}
+	body, err := s.encode(b)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(S3ObjectKey(s.cfg.Prefix, s.cfg.Format, b)), bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	if s.cfg.Format == S3FormatParquet {
+		req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds, err := s.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("s3: retrieve credentials: %w", err)
+	}
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return &PermanentError{Err: fmt.Errorf("s3: sign request: %w", err)}
+	}
+	return do(s.client, "s3", req)
+}
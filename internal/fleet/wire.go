@@ -0,0 +1,147 @@
+// Package fleet ships telemetry between gateway instances and a central
+// collector.
+//
+// Events are grouped into batches and sent in a compact binary envelope:
+//
+//	magic "CGTB" | version (1 byte) | header length (uvarint) | header (JSON) | zstd(NDJSON events)
+//
+// The header identifies the batch (instance ID + sequence number) so a
+// collector can acknowledge and de-duplicate retried batches; the shipper
+// re-sends a batch until it is acknowledged, giving at-least-once delivery.
+package fleet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Wire format constants.
+const (
+	ContentType = "application/vnd.context-gateway.telemetry-batch"
+
+	wireMagic   = "CGTB"
+	wireVersion = 1
+
+	maxHeaderBytes = 4 << 10
+)
+
+// ErrInvalidBatch is returned by DecodeBatch for malformed or oversized input.
+var ErrInvalidBatch = errors.New("invalid telemetry batch")
+
+// zstdEncoder is safe for concurrent EncodeAll calls.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+
+// Batch is a group of telemetry events from one instance.
+type Batch struct {
+	InstanceID string
+	Seq        uint64
+	CreatedAt  time.Time
+	Events     []json.RawMessage
+}
+
+// ID is the batch's de-duplication key.
+func (b *Batch) ID() string {
+	return b.InstanceID + "/" + strconv.FormatUint(b.Seq, 10)
+}
+
+// batchHeader is the uncompressed JSON header of the envelope.
+type batchHeader struct {
+	InstanceID string    `json:"instance_id"`
+	Seq        uint64    `json:"seq"`
+	CreatedAt  time.Time `json:"created_at"`
+	Count      int       `json:"count"`
+}
+
+// EncodeBatch serializes b into the wire envelope.
+func EncodeBatch(b *Batch) ([]byte, error) {
+	header, err := json.Marshal(batchHeader{InstanceID: b.InstanceID, Seq: b.Seq, CreatedAt: b.CreatedAt, Count: len(b.Events)})
+	if err != nil {
+		return nil, err
+	}
+
+	var ndjson bytes.Buffer
+	for _, ev := range b.Events {
+		if bytes.IndexByte(ev, '\n') >= 0 {
+			// Compact to keep one event per line.
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, ev); err != nil {
+				return nil, fmt.Errorf("event is not valid JSON: %w", err)
+			}
+			ev = compacted.Bytes()
+		}
+		ndjson.Write(ev)
+		ndjson.WriteByte('\n')
+	}
+
+	out := make([]byte, 0, len(wireMagic)+1+binary.MaxVarintLen64+len(header)+ndjson.Len()/4)
+	out = append(out, wireMagic...)
+	out = append(out, wireVersion)
+	out = binary.AppendUvarint(out, uint64(len(header)))
+	out = append(out, header...)
+	return zstdEncoder.EncodeAll(ndjson.Bytes(), out), nil
+}
+
+// DecodeBatch parses a wire envelope. maxDecoded bounds the decompressed
+// event payload to protect the collector from compression bombs.
+func DecodeBatch(data []byte, maxDecoded int) (*Batch, error) {
+	if len(data) < len(wireMagic)+1 || string(data[:len(wireMagic)]) != wireMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidBatch)
+	}
+	if v := data[len(wireMagic)]; v != wireVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBatch, v)
+	}
+	rest := data[len(wireMagic)+1:]
+	headerLen, n := binary.Uvarint(rest)
+	if n <= 0 || headerLen > maxHeaderBytes || uint64(len(rest)-n) < headerLen {
+		return nil, fmt.Errorf("%w: bad header length", ErrInvalidBatch)
+	}
+	rest = rest[n:]
+
+	var h batchHeader
+	if err := json.Unmarshal(rest[:headerLen], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidBatch, err)
+	}
+	if h.InstanceID == "" {
+		return nil, fmt.Errorf("%w: missing instance_id", ErrInvalidBatch)
+	}
+
+	// Stream through a limit instead of DecodeAll so a frame that omits or
+	// lies about its content size cannot expand past maxDecoded.
+	dec, err := zstd.NewReader(bytes.NewReader(rest[headerLen:]), zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: zstd: %v", ErrInvalidBatch, err)
+	}
+	defer dec.Close()
+	payload, err := io.ReadAll(io.LimitReader(dec, int64(maxDecoded)+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: zstd: %v", ErrInvalidBatch, err)
+	}
+	if len(payload) > maxDecoded {
+		return nil, fmt.Errorf("%w: payload exceeds %d bytes", ErrInvalidBatch, maxDecoded)
+	}
+
+	b := &Batch{InstanceID: h.InstanceID, Seq: h.Seq, CreatedAt: h.CreatedAt, Events: make([]json.RawMessage, 0, h.Count)}
+	for len(payload) > 0 {
+		line := payload
+		if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+			line, payload = payload[:i], payload[i+1:]
+		} else {
+			payload = nil
+		}
+		if len(line) > 0 {
+			b.Events = append(b.Events, json.RawMessage(line))
+		}
+	}
+	if len(b.Events) != h.Count {
+		return nil, fmt.Errorf("%w: header count %d, got %d events", ErrInvalidBatch, h.Count, len(b.Events))
+	}
+	return b, nil
+}
@@ -0,0 +1,208 @@
+package formats
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// MinBlobLen is the shortest base64 run (in characters) treated as a blob.
+// Shorter runs are hashes, tokens, and IDs the model may need verbatim.
+const MinBlobLen = 1024
+
+// minDataURILen is the shortest data: URI payload treated as a blob; the
+// explicit prefix makes shorter payloads unambiguous.
+const minDataURILen = 256
+
+// Blob is a binary segment embedded in text: a data: URI, a bare base64 run,
+// or (for IsBinary content) the whole text.
+type Blob struct {
+	Start     int    // Byte offset of the blob in the text
+	End       int    // Byte offset just past the blob
+	MediaType string // From the data: URI or sniffed from the decoded bytes
+	Size      int    // Decoded size in bytes
+}
+
+// FindBlobs returns the base64 blobs in text, in order. Raw binary text is
+// reported as a single blob covering all of it.
+func FindBlobs(text string) []Blob {
+	if IsBinary(text) {
+		mediaType, _, _ := strings.Cut(http.DetectContentType([]byte(text)), ";")
+		return []Blob{{Start: 0, End: len(text), MediaType: mediaType, Size: len(text)}}
+	}
+
+	var blobs []Blob
+	for i := 0; i < len(text); {
+		if !isBase64Char(text[i]) {
+			i++
+			continue
+		}
+		start, end, payload := i, scanBase64Run(text, i), 0
+		for j := start; j < end; j++ {
+			if text[j] != '\n' && text[j] != '\r' {
+				payload++
+			}
+		}
+
+		mediaType, uriStart := dataURIPrefix(text, start)
+		minLen := MinBlobLen
+		if uriStart >= 0 {
+			minLen = minDataURILen
+		}
+		if payload >= minLen && (uriStart >= 0 || looksLikeBase64(text[start:end])) {
+			decoded, ok := decodeBase64Prefix(text[start:end])
+			if ok {
+				if mediaType == "" {
+					mediaType = http.DetectContentType(decoded)
+				}
+				mediaType, _, _ = strings.Cut(mediaType, ";") // drop charset and other parameters
+				if uriStart >= 0 {
+					start = uriStart
+				}
+				blobs = append(blobs, Blob{Start: start, End: end, MediaType: mediaType, Size: payload * 3 / 4})
+			}
+		}
+		i = end
+	}
+	return blobs
+}
+
+// IsBinary reports whether text is raw binary rather than text: invalid
+// UTF-8 or a high share of control bytes in its first 4KB.
+func IsBinary(text string) bool {
+	sample := text
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+		// Don't count a rune cut at the sample boundary as invalid.
+		for i := 0; i < utf8.UTFMax && !utf8.ValidString(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if sample == "" {
+		return false
+	}
+	if !utf8.ValidString(sample) {
+		return true
+	}
+	control := 0
+	for i := 0; i < len(sample); i++ {
+		c := sample[i]
+		if c == 0 {
+			return true
+		}
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' {
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
+
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '-' || c == '_' || c == '='
+}
+
+// scanBase64Run returns the end of the base64 run starting at i. The run
+// continues across line breaks only when they wrap base64 (MIME/PEM style):
+// fixed-width lines of 60-120 characters, no padding before the break. This
+// keeps a following line of text out of the run.
+func scanBase64Run(text string, i int) int {
+	end, lineStart, width := i, i, 0
+	for j := i; j < len(text); {
+		c := text[j]
+		if isBase64Char(c) {
+			j++
+			end = j
+			continue
+		}
+		if c != '\n' && c != '\r' {
+			break
+		}
+		line := j - lineStart
+		if line < 60 || line > 120 || (width != 0 && line != width) || text[j-1] == '=' {
+			break
+		}
+		width = line
+		if c == '\r' && j+1 < len(text) && text[j+1] == '\n' {
+			j++
+		}
+		j++
+		lineStart = j
+	}
+	return end
+}
+
+// dataURIPrefix returns the media type and start offset of a "data:<type>;base64,"
+// prefix ending at payloadStart, or ("", -1).
+func dataURIPrefix(text string, payloadStart int) (string, int) {
+	const marker = ";base64,"
+	if payloadStart < len(marker) || text[payloadStart-len(marker):payloadStart] != marker {
+		return "", -1
+	}
+	headStart := max(0, payloadStart-len(marker)-128) // media types are short
+	head := text[headStart : payloadStart-len(marker)]
+	idx := strings.LastIndex(head, "data:")
+	if idx < 0 {
+		return "", -1
+	}
+	mediaType := head[idx+len("data:"):]
+	if strings.ContainsAny(mediaType, " \t\n\"'") {
+		return "", -1
+	}
+	return mediaType, headStart + idx
+}
+
+// looksLikeBase64 filters out long runs of plain words or digits: real
+// base64 mixes upper case, lower case, and digits.
+func looksLikeBase64(run string) bool {
+	var upper, lower, digit bool
+	for i := 0; i < len(run); i++ {
+		c := run[i]
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+		if upper && lower && digit {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBase64Prefix decodes the first bytes of run (enough to sniff a
+// media type), accepting standard and URL-safe alphabets.
+func decodeBase64Prefix(run string) ([]byte, bool) {
+	clean := strings.NewReplacer("\n", "", "\r", "").Replace(run)
+	if len(clean) > 1024 {
+		clean = clean[:1024]
+	}
+	clean = strings.TrimRight(clean, "=")
+	clean = clean[:len(clean)/4*4]
+	if clean == "" {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(clean); err == nil {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+// FormatSize renders a byte count for placeholders: "512 B", "142 KB", "3.1 MB".
+func FormatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%d KB", n/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
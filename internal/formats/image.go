@@ -0,0 +1,98 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// maxThumbnailSourcePixels bounds the images decoded for thumbnailing
+	// (decompression bombs); larger images are left unchanged.
+	maxThumbnailSourcePixels = 40_000_000
+
+	// thumbnailJPEGQuality is the re-encode quality for downscaled JPEGs.
+	thumbnailJPEGQuality = 85
+)
+
+// Thumbnail downscales a PNG or JPEG so its longest side is maxDim pixels,
+// re-encoding in the source format. Returns nil when the image is already
+// small enough, is another format, or would not shrink.
+func Thumbnail(data []byte, maxDim int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format != "png" && format != "jpeg" {
+		return nil, nil
+	}
+	if max(cfg.Width, cfg.Height) <= maxDim {
+		return nil, nil
+	}
+	if cfg.Width*cfg.Height > maxThumbnailSourcePixels {
+		return nil, fmt.Errorf("image too large to thumbnail: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	dst := downscale(src, maxDim)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(data) {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale resizes src so its longest side is maxDim, averaging each
+// destination pixel's source box (box filter, one pass over the source).
+func downscale(src image.Image, maxDim int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := maxDim, maxDim
+	if sw >= sh {
+		dh = max(1, sh*maxDim/sw)
+	} else {
+		dw = max(1, sw*maxDim/sh)
+	}
+
+	// Normalize to RGBA first; draw has fast paths for the decoders' types.
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+			var r, g, bl, a, n int
+			for y := y0; y < y1; y++ {
+				row := rgba.Pix[y*rgba.Stride:]
+				for x := x0; x < x1; x++ {
+					px := row[x*4 : x*4+4]
+					r += int(px[0])
+					g += int(px[1])
+					bl += int(px[2])
+					a += int(px[3])
+					n++
+				}
+			}
+			// #nosec G115 -- averages of uint8 samples fit in uint8
+			copy(dst.Pix[dy*dst.Stride+dx*4:], []uint8{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
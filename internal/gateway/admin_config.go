@@ -0,0 +1,97 @@
+// admin_config.go - Admin API for live config changes.
+//
+// GET /admin/config returns the effective configuration; PATCH applies a
+// config.ConfigPatch (pipes, cost caps, allowed hosts, ...) the way PATCH
+// /api/config does, including ?scope=session; POST /admin/config/reload
+// re-reads the config file. Changes swap the config pointer and rebuild pipe
+// pools, so in-flight requests finish on the config they started with.
+//
+// Like every /admin endpoint, these are gated by adminAuthorized.
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// adminAuthorized reports whether r may use the /admin API (and /mcp). With
+// server.admin_token set, that bearer token is required and accepted from any
+// address; without it only loopback clients are let in.
+func (g *Gateway) adminAuthorized(r *http.Request) bool {
+	want := g.cfg().Server.AdminToken
+	if want == "" {
+		return isLoopback(r.RemoteAddr)
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// handleAdminConfig serves GET and PATCH /admin/config.
+func (g *Gateway) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminConfigJSON(w, g.cfg())
+	case http.MethodPatch:
+		updated, ok := g.applyConfigPatch(w, r)
+		if !ok {
+			return
+		}
+		writeAdminConfigJSON(w, updated)
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminConfigReload serves POST /admin/config/reload.
+func (g *Gateway) handleAdminConfigReload(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if g.configReloader == nil {
+		g.writeError(w, "config reloader not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := g.configReloader.Reload()
+	if errors.Is(err, config.ErrNoConfigFile) {
+		g.writeError(w, "gateway was started without a config file", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("admin: config reload failed")
+		g.writeError(w, "config reload failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info().Msg("admin: config reloaded from file")
+
+	if g.monitorHub != nil {
+		g.monitorHub.BroadcastEvent("config_updated", nil)
+	}
+	writeAdminConfigJSON(w, updated)
+}
+
+func writeAdminConfigJSON(w http.ResponseWriter, cfg *config.Config) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cfg.Effective()); err != nil {
+		log.Warn().Err(err).Msg("handleAdminConfig: failed to encode JSON response")
+	}
+}
@@ -0,0 +1,71 @@
+// api_versions.go - Pins and restricts provider API version headers.
+//
+// Clients send anthropic-version / OpenAI-Beta verbatim, and the gateway's
+// request parsing assumes a particular wire format. With api_versions enabled,
+// each rule for the request's provider checks the client's value against its
+// allowlist (element by element for comma-separated beta lists) and then
+// overwrites the header with the pinned value. Unknown versions fail the
+// request in reject mode; every mismatch is logged as a structured warning.
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// negotiateAPIVersions applies the api_versions rules for provider to h in
+// place. It returns an error wrapping errUnsupportedAPIVersion when a client
+// value is not allowed and the mode is reject.
+func (g *Gateway) negotiateAPIVersions(requestID string, provider adapters.Provider, h http.Header) error {
+	cfg := g.cfg().APIVersions
+	if !cfg.Enabled {
+		return nil
+	}
+	for _, rule := range cfg.Rules {
+		if !strings.EqualFold(rule.Provider, provider.String()) {
+			continue
+		}
+		got := h.Get(rule.Header)
+
+		if len(rule.Allowed) > 0 && got != "" {
+			for _, v := range strings.Split(got, ",") {
+				v = strings.TrimSpace(v)
+				if v == "" || slices.Contains(rule.Allowed, v) {
+					continue
+				}
+				log.Warn().
+					Str("request_id", requestID).
+					Str("provider", provider.String()).
+					Str("header", rule.Header).
+					Str("version", v).
+					Strs("allowed", rule.Allowed).
+					Str("mode", cfg.Mode).
+					Msg("api_versions: client sent an unknown API version")
+				if cfg.Mode != config.APIVersionModeWarn {
+					return fmt.Errorf("%w: %s %q (allowed: %s)", errUnsupportedAPIVersion, rule.Header, v, strings.Join(rule.Allowed, ", "))
+				}
+			}
+		}
+
+		if rule.Pin != "" && got != rule.Pin {
+			if got != "" {
+				log.Warn().
+					Str("request_id", requestID).
+					Str("provider", provider.String()).
+					Str("header", rule.Header).
+					Str("version", got).
+					Str("pinned", rule.Pin).
+					Msg("api_versions: client version differs from pin, overriding")
+			}
+			h.Set(rule.Header, rule.Pin)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+// assistants.go - OpenAI Assistants API (/v1/assistants, /v1/threads).
+//
+// Assistants runs execute on OpenAI's servers: a request starts or continues a
+// run, and the model's work is reported on the run object once it finishes.
+// Three POSTs put a run to work:
+//
+//	/v1/threads/runs                                     create a thread and run it
+//	/v1/threads/{thread}/runs                            run a thread
+//	/v1/threads/{thread}/runs/{run}/submit_tool_outputs  continue a run
+//
+// submit_tool_outputs bodies go through the compression pipes (tool_outputs[]
+// are tool results). No phantom tool can be added to a run, so compressed
+// outputs carry no expand_context reference. Every other Assistants request,
+// of any method, is relayed unchanged.
+//
+// Each run-starting request is recorded as one request in telemetry. A run's
+// usage is known only when it finishes: from the thread.run.* event of a
+// streamed run, or from the run object the client polls. The first finished
+// run object seen for a run started through the gateway records its usage
+// (telemetry, /stats, cost tracking), so usage is never counted twice. The
+// thread is the cost session.
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// pipeStrategyAssistants labels usage recorded from a polled run.
+const pipeStrategyAssistants = "assistants"
+
+var (
+	// assistantsRunPath matches the POSTs that start or continue a run.
+	assistantsRunPath = regexp.MustCompile(`^/v1/threads(?:/([^/]+))?/runs(?:/[^/]+/submit_tool_outputs)?$`)
+	// assistantsRunRetrievePath matches GET /v1/threads/{thread}/runs/{run}.
+	assistantsRunRetrievePath = regexp.MustCompile(`^/v1/threads/[^/]+/runs/[^/]+$`)
+)
+
+// isAssistantsPath reports whether path belongs to the Assistants API.
+func isAssistantsPath(path string) bool {
+	for _, prefix := range []string{"/v1/threads", "/v1/assistants"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// assistantRunTerminal reports whether a run in this status is done and
+// carries its final usage.
+func assistantRunTerminal(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled", "expired", "incomplete":
+		return true
+	}
+	return false
+}
+
+// assistantRun is a run started through the gateway.
+type assistantRun struct {
+	ThreadID string // Cost session
+	Recorded bool   // Its usage has been recorded
+}
+
+// assistantRunStore tracks runs started through the gateway until their usage is recorded.
+type assistantRunStore struct {
+	runs *sessionstore.Store[assistantRun]
+}
+
+func newAssistantRunStore(ttl time.Duration) *assistantRunStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &assistantRunStore{
+		runs: sessionstore.New[assistantRun](ttl, 0, nil), // swept by the session collector
+	}
+}
+
+// observe records a run object seen in a response. It returns true the first
+// time the run is seen finished; started is false for polled runs, which count
+// only when a run-starting request went through the gateway.
+func (s *assistantRunStore) observe(run gjson.Result, started bool) bool {
+	id := run.Get("id").String()
+	if id == "" {
+		return false
+	}
+	if !started && !s.runs.View(id, func(*assistantRun) {}) {
+		return false
+	}
+	threadID := run.Get("thread_id").String()
+	finished := assistantRunTerminal(run.Get("status").String())
+	first := false
+	s.runs.Update(id, func(r *assistantRun) {
+		r.ThreadID = threadID
+		if finished && !r.Recorded {
+			r.Recorded, first = true, true
+		}
+	})
+	return first
+}
+
+// Len returns the number of tracked runs (sessionstore.Sweeper).
+func (s *assistantRunStore) Len() int {
+	return s.runs.Len()
+}
+
+// Sweep removes idle runs (sessionstore.Sweeper).
+func (s *assistantRunStore) Sweep() int {
+	return s.runs.Sweep()
+}
+
+// Expire forgets every run of a thread (sessionstore.Sweeper).
+func (s *assistantRunStore) Expire(sessionID string) bool {
+	var ids []string
+	s.runs.Range(func(id string, r *assistantRun) {
+		if r.ThreadID == sessionID {
+			ids = append(ids, id)
+		}
+	})
+	for _, id := range ids {
+		s.runs.Delete(id)
+	}
+	return len(ids) > 0
+}
+
+// Stop stops the cleanup goroutine. Safe to call multiple times.
+func (s *assistantRunStore) Stop() {
+	s.runs.Stop()
+}
+
+// assistantRunWatcher keeps the last run object of a streamed run.
+type assistantRunWatcher struct {
+	buffer []byte
+	run    []byte
+}
+
+func (w *assistantRunWatcher) feed(chunk []byte, flush bool) {
+	w.buffer = append(w.buffer, chunk...)
+	if len(w.buffer) > MaxSSEParserBufferSize {
+		w.buffer = w.buffer[:0]
+		return
+	}
+	for {
+		event, rest, ok := nextSSEEvent(w.buffer, flush)
+		if !ok {
+			return
+		}
+		w.buffer = rest
+		for _, line := range strings.Split(string(event), "\n") {
+			data, found := strings.CutPrefix(strings.TrimSpace(line), "data:")
+			if !found {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if gjson.Get(data, "object").String() == "thread.run" {
+				w.run = []byte(data)
+			}
+		}
+	}
+}
+
+// handleAssistants serves the Assistants API.
+func (g *Gateway) handleAssistants(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && assistantsRunPath.MatchString(r.URL.Path):
+		g.handleAssistantsRun(w, r)
+	case r.Method == http.MethodGet && assistantsRunRetrievePath.MatchString(r.URL.Path):
+		g.handleAssistantsRunRetrieve(w, r)
+	default:
+		g.handlePassthrough(w, r)
+	}
+}
+
+// assistantsPipelineContext builds the pipeline context of an Assistants request.
+func (g *Gateway) assistantsPipelineContext(r *http.Request, body []byte, requestID, threadID string) (*PipelineContext, adapters.Adapter) {
+	adapter := g.registry.Get(adapters.ProviderOpenAI.String())
+	tenant := tenancy.FromContext(r.Context())
+	pipeCtx := NewPipelineContext(adapters.ProviderOpenAI, adapter, body, r.URL.Path)
+	pipeCtx.RequestID = requestID
+	pipeCtx.RequestCtx = r.Context()
+	pipeCtx.Tenant = tenancy.NameOf(tenant)
+	pipeCtx.Client = clientLabelFrom(r.Context())
+	pipeCtx.CostSessionID = threadID
+	pipeCtx.SessionTags = parseSessionTags(r.Header)
+	pipeCtx.CapturedAuth = authtypes.CaptureFromHeaders(r.Header)
+	pipeCtx.ClientAgent = detectClientAgent(r.Header)
+	pipeCtx.Flags = g.flags.Evaluate(featureflags.Target{
+		SessionID: threadID,
+		User:      r.Header.Get(featureflags.HeaderUser),
+		Tags:      pipeCtx.SessionTags,
+	})
+	if g.costTracker != nil {
+		pipeCtx.BudgetScopes = g.costTracker.ScopeKeys(r.Header, pipeCtx.SessionTags, pipeCtx.Tenant, pipeCtx.Client)
+	}
+	return pipeCtx, adapter
+}
+
+// handleAssistantsRun forwards a request that starts or continues a run.
+func (g *Gateway) handleAssistantsRun(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	requestID := g.getRequestID(r)
+	g.EnsureSession()
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	threadID := assistantsRunPath.FindStringSubmatch(r.URL.Path)[1]
+	pipeCtx, adapter := g.assistantsPipelineContext(r, body, requestID, threadID)
+
+	if g.costTracker != nil {
+		budget := g.costTracker.CheckBudget(threadID, pipeCtx.BudgetScopes...)
+		if budget.Simulated {
+			g.costTracker.RecordSimulatedRejection(threadID, budget)
+			w.Header().Set(HeaderBudgetSimulated, budget.Reason)
+		}
+		g.notifyBudget(threadID, budget)
+		if !budget.Allowed {
+			g.recordError(monitoring.ErrorCodeBudgetExceeded)
+			g.notifyBudgetExceeded(threadID, budget)
+			g.returnBudgetExceededResponse(w, adapter.Name(), budget, threadID)
+			return
+		}
+	}
+
+	// Only submitted tool outputs are compressed. Run creation may override
+	// the assistant's tools, which tool discovery must not filter.
+	forwardBody, pipeType, pipeStrategy, compressionUsed, compressLatency := body, PipeNone, config.StrategyPassthrough, false, time.Duration(0)
+	if strings.HasSuffix(r.URL.Path, "/submit_tool_outputs") && g.router != nil {
+		forwardBody, pipeType, pipeStrategy, compressionUsed, compressLatency = g.processCompressionPipeline(body, pipeCtx, requestID)
+		if pipeCtx.PIIError != nil {
+			g.recordError(monitoring.ErrorCodePIIMaskingFailed)
+			g.writeError(w, "pii masking failed", http.StatusServiceUnavailable)
+			return
+		}
+		if pipeCtx.PipeRejection != nil {
+			g.recordError(pipeCtx.rejectCode)
+			g.writeError(w, pipeCtx.PipeRejection.Error(), pipeCtx.rejectStatus)
+			return
+		}
+	}
+
+	params := telemetryParams{
+		requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path, clientIP: r.RemoteAddr,
+		requestBodySize: len(body), provider: adapter.Name(), pipeType: pipeType, pipeStrategy: pipeStrategy,
+		originalBodySize: len(body), compressionUsed: compressionUsed, compressLatency: compressLatency,
+		pipeCtx: pipeCtx, adapter: adapter, requestBody: body, forwardBody: forwardBody,
+		compressedBodySize: len(forwardBody), requestHeaders: r.Header,
+	}
+
+	forwardStart := time.Now()
+	resp, authMeta, err := g.forwardPassthrough(r.Context(), r, forwardBody)
+	params.forwardLatency = time.Since(forwardStart)
+	params.authModeInitial, params.authModeEffective, params.authFallbackUsed = authMeta.InitialMode, authMeta.EffectiveMode, authMeta.FallbackUsed
+	params.upstreamRetries, params.modelFallback = authMeta.Retries, authMeta.ModelFallback
+	if err != nil {
+		params.statusCode = http.StatusBadGateway
+		params.errorMsg = err.Error()
+		params.errorCode, params.retryable = ClassifyUpstreamError(err, monitoring.ErrorCodeUpstreamUnavailable)
+		g.recordRequestTelemetry(params)
+		log.Debug().Err(err).Str("request_id", requestID).Msg("assistants: upstream request failed")
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	params.statusCode = resp.StatusCode
+	params.responseHeaders = resp.Header
+	if resp.Request != nil {
+		params.upstreamURL = resp.Request.URL.String()
+	}
+
+	var run []byte
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		params.responseBodySize, run = g.relayAssistantsStream(w, resp)
+	} else {
+		responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+		copyHeaders(w, resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		_, _ = w.Write(responseBody)
+		params.responseBodySize = len(responseBody)
+		if resp.StatusCode < 400 {
+			run = responseBody
+		}
+	}
+	g.applyAssistantRun(&params, run, true)
+	g.recordRequestTelemetry(params)
+}
+
+// relayAssistantsStream relays a streamed run and returns its size and last run object.
+func (g *Gateway) relayAssistantsStream(w http.ResponseWriter, resp *http.Response) (int, []byte) {
+	writeStreamingHeaders(w, resp.Header, nil)
+	w.WriteHeader(resp.StatusCode)
+	relay := newStreamRelay(w, g.cfg().Server, g.metrics)
+	defer relay.close()
+
+	var watcher assistantRunWatcher
+	size := 0
+	buf := make([]byte, DefaultBufferSize)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			size += n
+			watcher.feed(buf[:n], false)
+			if _, writeErr := relay.Write(buf[:n]); writeErr != nil {
+				log.Debug().Err(writeErr).Msg("assistants: client disconnected")
+				break
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Debug().Err(err).Msg("assistants: error reading stream")
+				markResponseIncomplete(w)
+			}
+			break
+		}
+	}
+	watcher.feed(nil, true)
+	return size, watcher.run
+}
+
+// handleAssistantsRunRetrieve relays a polled run and records its usage the
+// first time it is seen finished.
+func (g *Gateway) handleAssistantsRunRetrieve(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	resp, _, err := g.forwardPassthrough(r.Context(), r, nil)
+	if err != nil {
+		log.Debug().Err(err).Str("path", r.URL.Path).Msg("passthrough failed")
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+	copyHeaders(w, resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(responseBody)
+
+	if resp.StatusCode != http.StatusOK || g.assistantRuns == nil || !g.assistantRuns.observe(gjson.ParseBytes(responseBody), false) {
+		return
+	}
+	requestID := g.getRequestID(r)
+	pipeCtx, adapter := g.assistantsPipelineContext(r, nil, requestID, "")
+	params := telemetryParams{
+		requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path, clientIP: r.RemoteAddr,
+		responseBodySize: len(responseBody), provider: adapter.Name(), pipeType: PipeNone, pipeStrategy: pipeStrategyAssistants,
+		statusCode: resp.StatusCode, forwardLatency: time.Since(startTime), pipeCtx: pipeCtx, adapter: adapter,
+		requestHeaders: r.Header, responseHeaders: resp.Header,
+	}
+	g.applyAssistantRun(&params, responseBody, false)
+	params.streamUsage = assistantRunUsage(adapter, responseBody)
+	g.recordRequestTelemetry(params)
+}
+
+// applyAssistantRun sets the model, session and stop reason of a run object on
+// params, and its usage when started is true and the run is seen finished for
+// the first time. Polled runs add their usage themselves.
+func (g *Gateway) applyAssistantRun(params *telemetryParams, run []byte, started bool) {
+	parsed := gjson.ParseBytes(run)
+	if parsed.Get("object").String() != "thread.run" {
+		return
+	}
+	params.model = parsed.Get("model").String()
+	params.streamStopReason = parsed.Get("status").String()
+	if params.pipeCtx.CostSessionID == "" {
+		params.pipeCtx.CostSessionID = parsed.Get("thread_id").String()
+	}
+	if started && g.assistantRuns != nil && g.assistantRuns.observe(parsed, true) {
+		params.streamUsage = assistantRunUsage(params.adapter, run)
+	}
+}
+
+// assistantRunUsage returns the usage of a finished run object.
+func assistantRunUsage(adapter adapters.Adapter, run []byte) *adapters.UsageInfo {
+	usage := adapter.ExtractUsage(run)
+	return &usage
+}
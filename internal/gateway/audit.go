@@ -0,0 +1,92 @@
+// audit.go - forwarding audit log (monitoring.audit).
+//
+// forwardUpstream fills a forwardAudit as it resolves the target, checks the
+// host and picks the auth mode; forwardPassthrough turns it into one
+// monitoring.AuditEntry per forward. Only header names are recorded.
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// Target URL sources recorded in the audit log.
+const (
+	auditTargetHeader     = "header"
+	auditTargetAutoDetect = "auto_detect"
+	auditTargetNone       = "none"
+)
+
+// auditAuthHeaders are the credential-bearing headers tracked as present,
+// stripped or overridden.
+var auditAuthHeaders = []string{
+	"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key",
+	"Chatgpt-Account-Id", "Openai-Organization", "Openai-Project",
+}
+
+// forwardAudit collects the decisions of one forwardUpstream call.
+type forwardAudit struct {
+	sessionID       string
+	provider        string
+	targetURLHeader string
+	targetURL       string
+	targetSource    string
+	host            string
+	hostAllowed     bool
+	upstreamHeader  http.Header // Headers of the last attempt sent upstream
+	sigV4Signed     bool
+	fallbackReason  string
+}
+
+// logForwardAudit writes the audit entry for a forward. No-op when auditing is off.
+func (g *Gateway) logForwardAudit(ctx context.Context, r *http.Request, a *forwardAudit, meta forwardAuthMeta, resp *http.Response, err error) {
+	if g.auditLog == nil {
+		return
+	}
+	entry := monitoring.AuditEntry{
+		RequestID:         monitoring.RequestIDFromContext(ctx),
+		SessionID:         a.sessionID,
+		Method:            r.Method,
+		Path:              r.URL.Path,
+		Provider:          a.provider,
+		TargetURLHeader:   a.targetURLHeader,
+		TargetURL:         a.targetURL,
+		TargetSource:      a.targetSource,
+		Host:              a.host,
+		HostAllowed:       a.hostAllowed,
+		SigV4Signed:       a.sigV4Signed,
+		AuthModeInitial:   meta.InitialMode,
+		AuthModeEffective: meta.EffectiveMode,
+		AuthFallback:      meta.FallbackUsed,
+		FallbackReason:    a.fallbackReason,
+	}
+	for _, h := range auditAuthHeaders {
+		sent := r.Header.Get(h)
+		if sent != "" {
+			entry.AuthHeadersPresent = append(entry.AuthHeadersPresent, h)
+		}
+		if a.upstreamHeader == nil {
+			continue // Never sent: nothing stripped or overridden
+		}
+		switch upstream := a.upstreamHeader.Get(h); {
+		case sent != "" && upstream == "":
+			entry.AuthHeadersStripped = append(entry.AuthHeadersStripped, h)
+		case upstream != "" && upstream != sent:
+			entry.AuthHeadersOverridden = append(entry.AuthHeadersOverridden, h)
+		}
+	}
+	for h := range a.upstreamHeader {
+		entry.ForwardedHeaders = append(entry.ForwardedHeaders, h)
+	}
+	slices.Sort(entry.ForwardedHeaders)
+	if resp != nil {
+		entry.Status = resp.StatusCode
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	g.auditLog.Log(entry)
+}
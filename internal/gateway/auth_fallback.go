@@ -1,8 +1,9 @@
 package gateway
 
 import (
-	"sync"
 	"time"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
 )
 
 const (
@@ -10,90 +11,62 @@ const (
 )
 
 // authFallbackStore keeps per-session auth mode for subscription->api key fallback.
+// A session stays in API-key mode until ttl passes without another fallback.
 type authFallbackStore struct {
-	mu       sync.RWMutex
-	sessions map[string]time.Time // session_id -> last fallback time
-	ttl      time.Duration
-	stopCh   chan struct{}
+	sessions *sessionstore.Store[time.Time] // session_id -> last fallback time
 }
 
 func newAuthFallbackStore(ttl time.Duration) *authFallbackStore {
 	if ttl <= 0 {
 		ttl = defaultAuthFallbackTTL
 	}
-	s := &authFallbackStore{
-		sessions: make(map[string]time.Time),
-		ttl:      ttl,
-		stopCh:   make(chan struct{}),
+	return &authFallbackStore{
+		sessions: sessionstore.New[time.Time](ttl, 0, nil), // swept by the session collector
 	}
-	go s.cleanupLoop()
-	return s
 }
 
 func (s *authFallbackStore) MarkAPIKeyMode(sessionID string) {
 	if sessionID == "" {
 		return
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions[sessionID] = time.Now()
+	s.sessions.Update(sessionID, func(t *time.Time) { *t = time.Now() })
 }
 
 func (s *authFallbackStore) ShouldUseAPIKeyMode(sessionID string) bool {
 	if sessionID == "" {
 		return false
 	}
-	s.mu.RLock()
-	t, ok := s.sessions[sessionID]
-	s.mu.RUnlock()
-	if !ok {
-		return false
-	}
-	if time.Since(t) > s.ttl {
-		s.mu.Lock()
-		delete(s.sessions, sessionID)
-		s.mu.Unlock()
-		return false
-	}
-	return true
+	return s.sessions.View(sessionID, nil)
 }
 
-func (s *authFallbackStore) cleanupLoop() {
-	ticker := time.NewTicker(DefaultCleanupInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-s.stopCh:
-			return
-		case <-ticker.C:
-			s.cleanup()
-		}
-	}
+// Since returns when a session last fell back to its API key.
+func (s *authFallbackStore) Since(sessionID string) (time.Time, bool) {
+	var at time.Time
+	ok := s.sessions.View(sessionID, func(t *time.Time) { at = *t })
+	return at, ok
+}
+
+// Len returns the number of sessions in API-key mode.
+func (s *authFallbackStore) Len() int {
+	return s.sessions.Len()
 }
 
 // Reset clears all auth fallback state for a fresh session.
 func (s *authFallbackStore) Reset() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions = make(map[string]time.Time)
+	s.sessions.Reset()
 }
 
 // Stop stops the cleanup goroutine. Safe to call multiple times.
 func (s *authFallbackStore) Stop() {
-	select {
-	case <-s.stopCh:
-	default:
-		close(s.stopCh)
-	}
+	s.sessions.Stop()
 }
 
-func (s *authFallbackStore) cleanup() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	now := time.Now()
-	for id, t := range s.sessions {
-		if now.Sub(t) > s.ttl {
-			delete(s.sessions, id)
-		}
-	}
+// Sweep removes sessions whose fallback expired (sessionstore.Sweeper).
+func (s *authFallbackStore) Sweep() int {
+	return s.sessions.Sweep()
+}
+
+// Expire drops a session back to its default auth mode (sessionstore.Sweeper).
+func (s *authFallbackStore) Expire(sessionID string) bool {
+	return s.sessions.Delete(sessionID)
 }
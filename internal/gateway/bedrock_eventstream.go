@@ -0,0 +1,326 @@
+// bedrock_eventstream.go - Decoder for Bedrock's binary event-stream responses.
+//
+// Bedrock streams (invoke-with-response-stream, converse-stream) are not SSE
+// but application/vnd.amazon.eventstream: length-prefixed binary messages with
+// typed headers and CRC32 checksums. The gateway relays the frames unchanged
+// and decodes a copy into the equivalent Anthropic SSE events, so usage
+// parsing, expand_context detection and tool-search scanning work on Bedrock
+// streams as they do on Anthropic ones.
+//
+// Message layout (all integers big-endian):
+//
+//	total length (4) | headers length (4) | prelude CRC (4) |
+//	headers | payload | message CRC (4)
+//
+// invoke-with-response-stream sends "chunk" events whose payload is
+// {"bytes":"<base64 Anthropic event JSON>"}. converse-stream sends Converse
+// events (messageStart, contentBlockDelta, metadata, ...) that are mapped to
+// their Anthropic counterparts.
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// EventStreamContentType is the media type of Bedrock streaming responses.
+const EventStreamContentType = "application/vnd.amazon.eventstream"
+
+const (
+	eventStreamPreludeLen = 12
+	eventStreamMinLen     = eventStreamPreludeLen + 4
+	// MaxEventStreamMessageSize bounds one message; Bedrock chunks are small,
+	// a larger length prefix means the stream is corrupt.
+	MaxEventStreamMessageSize = 16 << 20
+)
+
+var errEventStreamChecksum = errors.New("event stream checksum mismatch")
+
+// eventStreamMessage is one decoded event-stream message. Only string
+// headers are kept; Bedrock sends no others.
+type eventStreamMessage struct {
+	headers map[string]string
+	payload []byte
+}
+
+// decodeEventStreamMessage decodes the message at the start of buf. It
+// returns n == 0 when buf does not hold a complete message yet.
+func decodeEventStreamMessage(buf []byte) (msg eventStreamMessage, n int, err error) {
+	if len(buf) < eventStreamPreludeLen {
+		return msg, 0, nil
+	}
+	total := int(binary.BigEndian.Uint32(buf[0:4]))
+	headersLen := int(binary.BigEndian.Uint32(buf[4:8]))
+	if crc32.ChecksumIEEE(buf[:8]) != binary.BigEndian.Uint32(buf[8:12]) {
+		return msg, 0, fmt.Errorf("%w in prelude", errEventStreamChecksum)
+	}
+	if total < eventStreamMinLen || total > MaxEventStreamMessageSize || headersLen > total-eventStreamMinLen {
+		return msg, 0, fmt.Errorf("invalid event stream message: length %d, headers %d", total, headersLen)
+	}
+	if len(buf) < total {
+		return msg, 0, nil
+	}
+	if crc32.ChecksumIEEE(buf[:total-4]) != binary.BigEndian.Uint32(buf[total-4:total]) {
+		return msg, 0, fmt.Errorf("%w in message", errEventStreamChecksum)
+	}
+
+	headers, err := decodeEventStreamHeaders(buf[eventStreamPreludeLen : eventStreamPreludeLen+headersLen])
+	if err != nil {
+		return msg, 0, err
+	}
+	return eventStreamMessage{
+		headers: headers,
+		payload: buf[eventStreamPreludeLen+headersLen : total-4],
+	}, total, nil
+}
+
+// eventStreamHeaderSizes gives the value size of fixed-size header types,
+// indexed by type: bool true, bool false, byte, short, int, long, (bytes),
+// (string), timestamp, uuid.
+var eventStreamHeaderSizes = [...]int{0, 0, 1, 2, 4, 8, -1, -1, 8, 16}
+
+const eventStreamHeaderString = 7
+
+func decodeEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errors.New("truncated event stream header")
+		}
+		name := string(b[1 : 1+nameLen])
+		typ := int(b[1+nameLen])
+		b = b[2+nameLen:]
+		if typ >= len(eventStreamHeaderSizes) {
+			return nil, fmt.Errorf("unknown event stream header type %d", typ)
+		}
+		size := eventStreamHeaderSizes[typ]
+		if size < 0 { // bytes and string: 2-byte length prefix
+			if len(b) < 2 {
+				return nil, errors.New("truncated event stream header")
+			}
+			size = int(binary.BigEndian.Uint16(b[:2]))
+			b = b[2:]
+		}
+		if len(b) < size {
+			return nil, errors.New("truncated event stream header")
+		}
+		if typ == eventStreamHeaderString {
+			headers[name] = string(b[:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
+
+// encodeEventStreamMessage encodes a message with string headers, given as
+// name/value pairs.
+func encodeEventStreamMessage(headers [][2]string, payload []byte) []byte {
+	var hb bytes.Buffer
+	for _, h := range headers {
+		hb.WriteByte(byte(len(h[0])))
+		hb.WriteString(h[0])
+		hb.WriteByte(eventStreamHeaderString)
+		_ = binary.Write(&hb, binary.BigEndian, uint16(len(h[1])))
+		hb.WriteString(h[1])
+	}
+	total := eventStreamMinLen + hb.Len() + len(payload)
+	msg := make([]byte, 0, total)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(total))    // #nosec G115 -- bounded by the payload size
+	msg = binary.BigEndian.AppendUint32(msg, uint32(hb.Len())) // #nosec G115 -- headers are short
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, hb.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+// isEventStream reports whether a response is a Bedrock event stream.
+func isEventStream(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == EventStreamContentType
+}
+
+// eventStreamDecoder incrementally decodes an event stream into Anthropic
+// SSE events.
+type eventStreamDecoder struct {
+	buffer []byte
+	broken bool // A corrupt message was seen; the rest cannot be framed
+}
+
+// eventStreamDecoderFor returns a decoder for a response with header h, or
+// nil when the response is not an event stream.
+func eventStreamDecoderFor(h http.Header) *eventStreamDecoder {
+	if !isEventStream(h) {
+		return nil
+	}
+	return &eventStreamDecoder{}
+}
+
+// feed decodes the complete messages buffered after chunk and returns them
+// as SSE events. A trailing partial message is kept for the next call.
+func (d *eventStreamDecoder) feed(chunk []byte) []byte {
+	if d.broken {
+		return nil
+	}
+	d.buffer = append(d.buffer, chunk...)
+	var out []byte
+	for {
+		msg, n, err := decodeEventStreamMessage(d.buffer)
+		if err != nil {
+			log.Warn().Err(err).Msg("bedrock: undecodable event stream, usage and phantom tool detection stop here")
+			d.broken = true
+			d.buffer = nil
+			return out
+		}
+		if n == 0 {
+			return out
+		}
+		d.buffer = d.buffer[n:]
+		out = append(out, msg.toSSE()...)
+	}
+}
+
+// DecodeEventStream returns the Anthropic SSE events a complete Bedrock event
+// stream decodes to, as the gateway scans them.
+func DecodeEventStream(stream []byte) []byte {
+	return (&eventStreamDecoder{}).feed(stream)
+}
+
+// toSSE returns the Anthropic SSE events equivalent to one message.
+func (m eventStreamMessage) toSSE() []byte {
+	switch m.headers[":message-type"] {
+	case "exception", "error":
+		errType := m.headers[":exception-type"]
+		if errType == "" {
+			errType = m.headers[":error-code"]
+		}
+		message := gjson.GetBytes(m.payload, "message").String()
+		if message == "" {
+			message = m.headers[":error-message"]
+		}
+		data, _ := sjson.SetBytes([]byte(`{"type":"error","error":{}}`), "error.type", errType)
+		data, _ = sjson.SetBytes(data, "error.message", message)
+		return formatSSEEvent("error", data)
+	}
+
+	if m.headers[":event-type"] == "chunk" {
+		data, err := base64.StdEncoding.DecodeString(gjson.GetBytes(m.payload, "bytes").String())
+		if err != nil || !gjson.ValidBytes(data) {
+			return nil
+		}
+		return formatSSEEvent(gjson.GetBytes(data, "type").String(), data)
+	}
+	return converseEventToSSE(m.headers[":event-type"], m.payload)
+}
+
+// converseEventToSSE maps a converse-stream event to Anthropic SSE.
+func converseEventToSSE(eventType string, payload []byte) []byte {
+	p := gjson.ParseBytes(payload)
+	index := p.Get("contentBlockIndex").Int()
+	var data []byte
+	switch eventType {
+	case "messageStart":
+		data, _ = sjson.SetBytes([]byte(`{"type":"message_start","message":{"type":"message","content":[]}}`), "message.role", p.Get("role").String())
+	case "contentBlockStart":
+		toolUse := p.Get("start.toolUse")
+		if !toolUse.Exists() {
+			return nil
+		}
+		data, _ = sjson.SetBytes([]byte(`{"type":"content_block_start","content_block":{"type":"tool_use","input":{}}}`), "index", index)
+		data, _ = sjson.SetBytes(data, "content_block.id", toolUse.Get("toolUseId").String())
+		data, _ = sjson.SetBytes(data, "content_block.name", toolUse.Get("name").String())
+	case "contentBlockDelta":
+		data, _ = sjson.SetBytes([]byte(`{"type":"content_block_delta"}`), "index", index)
+		delta := p.Get("delta")
+		switch {
+		case delta.Get("text").Exists():
+			data, _ = sjson.SetRawBytes(data, "delta", []byte(`{"type":"text_delta"}`))
+			data, _ = sjson.SetBytes(data, "delta.text", delta.Get("text").String())
+		case delta.Get("toolUse").Exists():
+			data, _ = sjson.SetRawBytes(data, "delta", []byte(`{"type":"input_json_delta"}`))
+			data, _ = sjson.SetBytes(data, "delta.partial_json", delta.Get("toolUse.input").String())
+		case delta.Get("reasoningContent.text").Exists():
+			data, _ = sjson.SetRawBytes(data, "delta", []byte(`{"type":"thinking_delta"}`))
+			data, _ = sjson.SetBytes(data, "delta.thinking", delta.Get("reasoningContent.text").String())
+		default:
+			return nil
+		}
+	case "contentBlockStop":
+		data, _ = sjson.SetBytes([]byte(`{"type":"content_block_stop"}`), "index", index)
+	case "messageStop":
+		data, _ = sjson.SetBytes([]byte(`{"type":"message_delta","delta":{}}`), "delta.stop_reason", p.Get("stopReason").String())
+	case "metadata":
+		u := p.Get("usage")
+		data, _ = sjson.SetBytes([]byte(`{"type":"message_delta","delta":{},"usage":{}}`), "usage.input_tokens", u.Get("inputTokens").Int())
+		data, _ = sjson.SetBytes(data, "usage.output_tokens", u.Get("outputTokens").Int())
+		data, _ = sjson.SetBytes(data, "usage.cache_read_input_tokens", u.Get("cacheReadInputTokens").Int())
+		data, _ = sjson.SetBytes(data, "usage.cache_creation_input_tokens", u.Get("cacheWriteInputTokens").Int())
+	default:
+		return nil
+	}
+	return formatSSEEvent(gjson.GetBytes(data, "type").String(), data)
+}
+
+// anthropicSSEToEventStream frames Anthropic SSE events as the chunk
+// messages of an invoke-with-response-stream response.
+func anthropicSSEToEventStream(sse []byte) []byte {
+	var out []byte
+	for len(sse) > 0 {
+		event, rest, ok := nextSSEEvent(sse, true)
+		if !ok {
+			break
+		}
+		sse = rest
+		data := sseEventData(event)
+		if len(data) == 0 {
+			continue
+		}
+		payload, _ := sjson.SetBytes([]byte(`{}`), "bytes", base64.StdEncoding.EncodeToString(data))
+		out = append(out, encodeEventStreamMessage([][2]string{
+			{":event-type", "chunk"},
+			{":content-type", "application/json"},
+			{":message-type", "event"},
+		}, payload)...)
+	}
+	return out
+}
+
+// bedrockInvokePath returns the non-streaming counterpart of a Bedrock
+// invoke-with-response-stream path.
+func bedrockInvokePath(path string) (string, bool) {
+	if !strings.Contains(path, "/model/") {
+		return "", false
+	}
+	base, ok := strings.CutSuffix(path, "/invoke-with-response-stream")
+	if !ok {
+		return "", false
+	}
+	return base + "/invoke", true
+}
+
+// isBedrockStreamPath reports whether path is a Bedrock streaming endpoint.
+// Bedrock selects streaming by path, not by a "stream" field in the body.
+func isBedrockStreamPath(path string) bool {
+	return strings.Contains(path, "/model/") &&
+		(strings.HasSuffix(path, "/invoke-with-response-stream") ||
+			strings.HasSuffix(path, "/converse-stream"))
+}
+
+// isBedrockConversePath reports whether path is a Bedrock Converse endpoint.
+// Converse requests are not in Messages API format, so phantom tools cannot
+// be injected into them.
+func isBedrockConversePath(path string) bool {
+	return strings.Contains(path, "/model/") &&
+		(strings.HasSuffix(path, "/converse") || strings.HasSuffix(path, "/converse-stream"))
+}
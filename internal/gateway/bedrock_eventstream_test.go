@@ -0,0 +1,135 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func converseEvent(eventType, payload string) []byte {
+	return encodeEventStreamMessage([][2]string{
+		{":event-type", eventType},
+		{":content-type", "application/json"},
+		{":message-type", "event"},
+	}, []byte(payload))
+}
+
+func TestEventStream_RoundTrip(t *testing.T) {
+	msg := converseEvent("messageStop", `{"stopReason":"end_turn"}`)
+
+	decoded, n, err := decodeEventStreamMessage(msg)
+	require.NoError(t, err)
+	assert.Equal(t, len(msg), n)
+	assert.Equal(t, "messageStop", decoded.headers[":event-type"])
+	assert.Equal(t, "event", decoded.headers[":message-type"])
+	assert.Equal(t, `{"stopReason":"end_turn"}`, string(decoded.payload))
+
+	_, n, err = decodeEventStreamMessage(msg[:len(msg)-1])
+	require.NoError(t, err)
+	assert.Zero(t, n, "an incomplete message waits for more bytes")
+}
+
+func TestEventStream_ChecksumMismatch(t *testing.T) {
+	msg := converseEvent("messageStop", `{"stopReason":"end_turn"}`)
+	msg[len(msg)-6] ^= 0xff // Corrupt the payload
+
+	_, _, err := decodeEventStreamMessage(msg)
+	assert.ErrorIs(t, err, errEventStreamChecksum)
+
+	d := &eventStreamDecoder{}
+	assert.Empty(t, d.feed(msg))
+	assert.Empty(t, d.feed(converseEvent("messageStop", `{}`)), "framing is lost after a corrupt message")
+}
+
+func TestEventStream_SkipsNonStringHeaders(t *testing.T) {
+	// A byte header (type 2) and a timestamp header (type 8) before a string one.
+	headers := []byte{1, 'a', 2, 7, 1, 't', 8, 0, 0, 0, 0, 0, 0, 0, 1}
+	headers = append(headers, 11, ':', 'e', 'v', 'e', 'n', 't', '-', 't', 'y', 'p', 'e', 7, 0, 5, 'c', 'h', 'u', 'n', 'k')
+
+	got, err := decodeEventStreamHeaders(headers)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{":event-type": "chunk"}, got)
+
+	_, err = decodeEventStreamHeaders(headers[:len(headers)-2])
+	assert.Error(t, err)
+}
+
+func TestEventStreamDecoder_InvokeChunks(t *testing.T) {
+	sse := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":100}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"expand_context\",\"input\":{}}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":20}}\n\n"
+	stream := anthropicSSEToEventStream([]byte(sse))
+
+	// Frames split at arbitrary points decode to the original events.
+	d := &eventStreamDecoder{}
+	var out []byte
+	for _, chunk := range [][]byte{stream[:5], stream[5:100], stream[100:]} {
+		out = append(out, d.feed(chunk)...)
+	}
+	assert.Equal(t, sse, string(out))
+
+	usage, stopReason := ParseStreamUsage(out)
+	assert.Equal(t, 100, usage.InputTokens)
+	assert.Equal(t, 20, usage.OutputTokens)
+	assert.Equal(t, "tool_use", stopReason)
+}
+
+func TestEventStreamDecoder_ConverseEvents(t *testing.T) {
+	var stream []byte
+	for _, e := range [][2]string{
+		{"messageStart", `{"role":"assistant"}`},
+		{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Reading the log."}}`},
+		{"contentBlockStop", `{"contentBlockIndex":0}`},
+		{"contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tooluse_1","name":"read_file"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"path\":\"app.log\"}"}}}`},
+		{"contentBlockStop", `{"contentBlockIndex":1}`},
+		{"messageStop", `{"stopReason":"tool_use"}`},
+		{"metadata", `{"usage":{"inputTokens":120,"outputTokens":30,"totalTokens":150,"cacheReadInputTokens":40},"metrics":{"latencyMs":900}}`},
+	} {
+		stream = append(stream, converseEvent(e[0], e[1])...)
+	}
+
+	out := string((&eventStreamDecoder{}).feed(stream))
+	assert.Contains(t, out, `"delta":{"type":"text_delta","text":"Reading the log."}`)
+	assert.Contains(t, out, `"content_block":{"type":"tool_use","input":{},"id":"tooluse_1","name":"read_file"}`)
+	assert.Contains(t, out, `"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"app.log\"}"}`)
+
+	usage, stopReason := ParseStreamUsage([]byte(out))
+	assert.Equal(t, 120, usage.InputTokens)
+	assert.Equal(t, 30, usage.OutputTokens)
+	assert.Equal(t, 40, usage.CacheReadInputTokens)
+	assert.Equal(t, "tool_use", stopReason)
+}
+
+func TestEventStreamDecoder_Exception(t *testing.T) {
+	msg := encodeEventStreamMessage([][2]string{
+		{":exception-type", "throttlingException"},
+		{":message-type", "exception"},
+	}, []byte(`{"message":"Too many requests"}`))
+
+	assert.Equal(t, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"throttlingException\",\"message\":\"Too many requests\"}}\n\n",
+		string((&eventStreamDecoder{}).feed(msg)))
+}
+
+func TestEventStreamDecoderFor(t *testing.T) {
+	assert.NotNil(t, eventStreamDecoderFor(http.Header{"Content-Type": {EventStreamContentType}}))
+	assert.Nil(t, eventStreamDecoderFor(http.Header{"Content-Type": {"text/event-stream"}}))
+}
+
+func TestNonStreamingRequest_BedrockInvokePath(t *testing.T) {
+	body := []byte(`{"anthropic_version":"bedrock-2023-05-31","messages":[]}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/model/anthropic.claude-sonnet-4-5-v1:0/invoke-with-response-stream", nil)
+	nr, nb := nonStreamingRequest(r, body)
+	assert.Equal(t, "/model/anthropic.claude-sonnet-4-5-v1:0/invoke", nr.URL.Path)
+	assert.Equal(t, string(body), string(nb), "Bedrock rejects a stream field")
+	assert.Equal(t, "/model/anthropic.claude-sonnet-4-5-v1:0/invoke-with-response-stream", r.URL.Path)
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	nr, nb = nonStreamingRequest(r, []byte(`{"stream":true}`))
+	assert.Same(t, r, nr)
+	assert.JSONEq(t, `{"stream":false}`, string(nb))
+}
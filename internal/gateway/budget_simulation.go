@@ -0,0 +1,64 @@
+// budget_simulation.go - reporting for cost_control.mode: simulate.
+//
+// In simulate mode budget checks run on every request but never block: a
+// request over a cap is forwarded with X-Gateway-Budget-Simulated set to the
+// cap that would have rejected it, and the event is recorded. GET /stats/budget
+// summarizes those would-be rejections so teams can size caps before
+// switching to enforce, next to the current spend of every budget scope value.
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+// HeaderBudgetSimulated carries the budget reason (global_cost, session_cost,
+// daily_egress, session_egress, scope_cost) on requests simulate mode let through.
+const HeaderBudgetSimulated = "X-Gateway-Budget-Simulated"
+
+// BudgetSimulationResponse is the JSON response for GET /stats/budget.
+type BudgetSimulationResponse struct {
+	Mode   string                       `json:"mode"` // Configured cost_control.mode
+	Report costcontrol.SimulationReport `json:"report"`
+	Scopes []costcontrol.ScopeUsage     `json:"scopes"` // Budget scope spend in the current windows
+}
+
+// handleBudgetSimulation serves GET /stats/budget[?since=24h].
+func (g *Gateway) handleBudgetSimulation(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			g.writeError(w, "since must be a positive duration (e.g. 24h)", http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+
+	cfg := g.costTracker.Config()
+	resp := BudgetSimulationResponse{
+		Mode:   cfg.EffectiveMode(),
+		Report: g.costTracker.SimulationReport(since),
+		Scopes: g.costTracker.ScopeSpend(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleBudgetSimulation: failed to encode JSON response")
+	}
+}
@@ -0,0 +1,201 @@
+// cache_guard.go keeps pipes from invalidating provider prompt caches.
+//
+// Anthropic prompt caching hashes the request prefix in a fixed order —
+// tools[], then system, then messages[] — up to and including the last block
+// carrying a cache_control marker. Any byte change before that breakpoint is a
+// cache miss for the whole prefix, so a pipe that compresses an old tool result
+// or filters tools can silently turn every turn into a cache write.
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/featureflags"
+)
+
+// Cache breakpoint sections, in the order the provider hashes them.
+const (
+	cacheSectionTools    = "tools"
+	cacheSectionSystem   = "system"
+	cacheSectionMessages = "messages"
+)
+
+// cacheBreakpoint locates the final cache_control marker in a request.
+type cacheBreakpoint struct {
+	Section string // tools | system | messages
+	Index   int    // index within the section (message index for messages)
+}
+
+// findLastCacheBreakpoint returns the last cache_control breakpoint in hashing order.
+// Returns false when the request carries no cache_control markers.
+func findLastCacheBreakpoint(body []byte) (cacheBreakpoint, bool) {
+	var bp cacheBreakpoint
+	found := false
+
+	gjson.GetBytes(body, "tools").ForEach(func(k, v gjson.Result) bool {
+		if v.Get("cache_control").Exists() {
+			bp = cacheBreakpoint{Section: cacheSectionTools, Index: int(k.Int())}
+			found = true
+		}
+		return true
+	})
+
+	if sys := gjson.GetBytes(body, "system"); sys.IsArray() {
+		sys.ForEach(func(k, v gjson.Result) bool {
+			if v.Get("cache_control").Exists() {
+				bp = cacheBreakpoint{Section: cacheSectionSystem, Index: int(k.Int())}
+				found = true
+			}
+			return true
+		})
+	}
+
+	gjson.GetBytes(body, "messages").ForEach(func(k, msg gjson.Result) bool {
+		marked := msg.Get("cache_control").Exists()
+		if content := msg.Get("content"); content.IsArray() {
+			content.ForEach(func(_, block gjson.Result) bool {
+				if block.Get("cache_control").Exists() {
+					marked = true
+					return false
+				}
+				return true
+			})
+		}
+		if marked {
+			bp = cacheBreakpoint{Section: cacheSectionMessages, Index: int(k.Int())}
+			found = true
+		}
+		return true
+	})
+
+	return bp, found
+}
+
+// cachedPrefix serializes the part of body covered by bp, in hashing order.
+// Two bodies with equal cachedPrefix output hit the same cache entry.
+func cachedPrefix(body []byte, bp cacheBreakpoint) []byte {
+	var buf bytes.Buffer
+
+	tools := gjson.GetBytes(body, "tools").Array()
+	toolLimit := len(tools)
+	if bp.Section == cacheSectionTools {
+		toolLimit = min(bp.Index+1, len(tools))
+	}
+	for _, t := range tools[:toolLimit] {
+		buf.WriteString(t.Raw)
+	}
+	if bp.Section == cacheSectionTools {
+		return buf.Bytes()
+	}
+
+	sys := gjson.GetBytes(body, "system")
+	if sys.IsArray() && bp.Section == cacheSectionSystem {
+		blocks := sys.Array()
+		for _, b := range blocks[:min(bp.Index+1, len(blocks))] {
+			buf.WriteString(b.Raw)
+		}
+		return buf.Bytes()
+	}
+	buf.WriteString(sys.Raw)
+
+	msgs := gjson.GetBytes(body, "messages").Array()
+	for _, m := range msgs[:min(bp.Index+1, len(msgs))] {
+		buf.WriteString(m.Raw)
+	}
+	return buf.Bytes()
+}
+
+// EnforceCachePrefix compares the pipe output against the pipe input and reports
+// whether the cached prefix (everything up to the final cache_control breakpoint)
+// was modified. When restore is true the original prefix is written back into the
+// forwarded body, so pipes only ever touch content after the breakpoint.
+//
+// Returns the (possibly restored) body and whether a pipe would have invalidated the cache.
+func EnforceCachePrefix(original, forwarded []byte, restore bool) ([]byte, bool) {
+	bp, ok := findLastCacheBreakpoint(original)
+	if !ok {
+		return forwarded, false
+	}
+	if bytes.Equal(cachedPrefix(original, bp), cachedPrefix(forwarded, bp)) {
+		return forwarded, false
+	}
+	if !restore {
+		return forwarded, true
+	}
+
+	restored, err := restoreCachedPrefix(original, forwarded, bp)
+	if err != nil {
+		// Partial restore is worse than none: fall back to the unmodified input.
+		log.Warn().Err(err).Msg("cache_guard: prefix restore failed, forwarding original body")
+		return original, true
+	}
+	return restored, true
+}
+
+// restoreCachedPrefix copies the cached sections of original over forwarded.
+// tools[] and system are restored wholesale (pipes rewrite them as a unit);
+// messages are restored per index up to the breakpoint so compressed content
+// after the breakpoint is kept.
+func restoreCachedPrefix(original, forwarded []byte, bp cacheBreakpoint) ([]byte, error) {
+	out := forwarded
+	var err error
+
+	if tools := gjson.GetBytes(original, "tools"); tools.Exists() {
+		if out, err = sjson.SetRawBytes(out, "tools", []byte(tools.Raw)); err != nil {
+			return nil, err
+		}
+	}
+	if bp.Section == cacheSectionTools {
+		return out, nil
+	}
+
+	if sys := gjson.GetBytes(original, "system"); sys.Exists() {
+		if out, err = sjson.SetRawBytes(out, "system", []byte(sys.Raw)); err != nil {
+			return nil, err
+		}
+	}
+	if bp.Section == cacheSectionSystem {
+		return out, nil
+	}
+
+	origMsgs := gjson.GetBytes(original, "messages").Array()
+	if len(gjson.GetBytes(out, "messages").Array()) != len(origMsgs) {
+		return nil, fmt.Errorf("message count changed (%d → %d)",
+			len(origMsgs), len(gjson.GetBytes(out, "messages").Array()))
+	}
+	for i := 0; i <= bp.Index && i < len(origMsgs); i++ {
+		if out, err = sjson.SetRawBytes(out, fmt.Sprintf("messages.%d", i), []byte(origMsgs[i].Raw)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// guardCachePrefix applies EnforceCachePrefix to the pipeline output, recording
+// a cache invalidation metric whenever a pipe touched the cached prefix.
+// A cache_compat feature flag overrides pipes.cache_compat.enabled.
+//
+// A restore puts the original tools[] back, so tool_discovery's deferred set
+// is dropped: every tool went upstream and none may be stored for search.
+func (g *Gateway) guardCachePrefix(pipeCtx *PipelineContext, original, forwarded []byte) []byte {
+	restore := pipeCtx.Flags.On(featureflags.CacheCompat, g.cfg().Pipes.CacheCompat.Enabled)
+	result, violated := EnforceCachePrefix(original, forwarded, restore)
+	if violated && restore {
+		pipeCtx.DeferredTools = nil
+	}
+	if violated {
+		if g.metrics != nil {
+			g.metrics.RecordCacheInvalidation()
+		}
+		log.Warn().
+			Str("request_id", pipeCtx.RequestID).
+			Bool("restored", restore).
+			Msg("cache_guard: pipe modified content before the final cache_control breakpoint")
+	}
+	return result
+}
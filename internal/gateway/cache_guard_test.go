@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+const cachedToolsRequest = `{"tools":[{"name":"read"},{"name":"write"},{"name":"grep","cache_control":{"type":"ephemeral"}}],` +
+	`"messages":[{"role":"user","content":"hi"}]}`
+
+// filteredToolsRequest is cachedToolsRequest after tool_discovery deferred write and grep.
+const filteredToolsRequest = `{"tools":[{"name":"read"}],"messages":[{"role":"user","content":"hi"}]}`
+
+func newCacheGuardGateway(compat bool) *Gateway {
+	cfg := &config.Config{}
+	cfg.Pipes.CacheCompat.Enabled = compat
+	return &Gateway{configReloader: config.NewReloader(cfg, "")}
+}
+
+func newDeferredPipelineContext() *PipelineContext {
+	ctx := &PipelineContext{PipeContext: pipes.NewPipeContext(nil, []byte(cachedToolsRequest))}
+	ctx.DeferredTools = []adapters.ExtractedContent{{ToolName: "write"}, {ToolName: "grep"}}
+	return ctx
+}
+
+func TestGuardCachePrefix_CompatRestoreDropsDeferredTools(t *testing.T) {
+	g := newCacheGuardGateway(true)
+	ctx := newDeferredPipelineContext()
+
+	out := g.guardCachePrefix(ctx, []byte(cachedToolsRequest), []byte(filteredToolsRequest))
+
+	assert.Equal(t, 3, int(gjson.GetBytes(out, "tools.#").Int()), "original tools must be restored")
+	assert.Empty(t, ctx.DeferredTools, "restored tools went upstream and must not be stored as deferred")
+}
+
+func TestGuardCachePrefix_DetectOnlyKeepsDeferredTools(t *testing.T) {
+	g := newCacheGuardGateway(false)
+	ctx := newDeferredPipelineContext()
+
+	out := g.guardCachePrefix(ctx, []byte(cachedToolsRequest), []byte(filteredToolsRequest))
+
+	assert.Equal(t, 1, int(gjson.GetBytes(out, "tools.#").Int()))
+	assert.Len(t, ctx.DeferredTools, 2)
+}
@@ -0,0 +1,156 @@
+// context_estimate.go - Context window estimates for agent frameworks.
+//
+// GET or POST /context/estimate takes a request body in the provider's format
+// and reports the gateway's token estimate before and after compression, the
+// target model's window, the conversation's cumulative usage, and the headroom
+// left. Agents can use it to decide when to compact instead of duplicating
+// the gateway's token math. Loopback-only, like /stats.
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// ContextEstimate is the response for /context/estimate.
+type ContextEstimate struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+
+	Tokens struct {
+		Estimated        int  `json:"estimated"`         // Request as sent
+		AfterCompression int  `json:"after_compression"` // Request as the gateway would forward it
+		Compressed       bool `json:"compressed"`        // Whether the compression pipes ran
+	} `json:"tokens"`
+
+	Window struct {
+		MaxTokens    int `json:"max_tokens"`    // Model context window
+		OutputMax    int `json:"output_max"`    // Reserved for the response
+		EffectiveMax int `json:"effective_max"` // Input budget usage is measured against
+	} `json:"window"`
+
+	Session ContextSessionUsage `json:"session"`
+
+	HeadroomTokens int     `json:"headroom_tokens"` // EffectiveMax - AfterCompression; negative when over budget
+	UsagePercent   float64 `json:"usage_percent"`   // AfterCompression / EffectiveMax, capped at 100
+
+	// Preemptive summarization starts at CompactionThresholdPercent usage.
+	// Zero when preemptive summarization is disabled or the conversation is stored upstream.
+	CompactionThresholdPercent float64 `json:"compaction_threshold_percent"`
+}
+
+// ContextSessionUsage is the conversation's recorded usage so far.
+type ContextSessionUsage struct {
+	ID              string  `json:"id"`
+	Tracked         bool    `json:"tracked"` // False until the first response is recorded
+	Requests        int     `json:"requests"`
+	InputTokens     int     `json:"input_tokens"`
+	OutputTokens    int     `json:"output_tokens"`
+	LastInputTokens int     `json:"last_input_tokens"`
+	CostUSD         float64 `json:"cost_usd"`
+}
+
+// handleContextEstimate serves /context/estimate. The body is a sample
+// request; the path defaults to /v1/messages and can be overridden with
+// ?path=. ?compress=false skips the compression pipes (which may call the
+// compression API; results are cached for the real request).
+func (g *Gateway) handleContextEstimate(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		g.writeError(w, "request body required", http.StatusBadRequest)
+		return
+	}
+
+	samplePath := r.URL.Query().Get("path")
+	if samplePath == "" {
+		samplePath = "/v1/messages"
+	}
+	provider, adapter := adapters.IdentifyAndGetAdapter(g.registry, samplePath, r.Header)
+	if adapter == nil {
+		g.writeError(w, "unsupported request format", http.StatusBadRequest)
+		return
+	}
+
+	cfg := g.cfg()
+	model := requestModel(adapter, body, samplePath)
+
+	var est ContextEstimate
+	est.Provider = string(provider)
+	est.Model = model
+	est.Tokens.Estimated = g.tokens.CountRequest(body, model)
+	est.Tokens.AfterCompression = est.Tokens.Estimated
+
+	if r.URL.Query().Get("compress") != "false" && g.router != nil {
+		pipeCtx := NewPipelineContext(provider, adapter, body, samplePath)
+		pipeCtx.RequestCtx = r.Context()
+		pipeCtx.Model = model
+		pipeCtx.TargetModel = model
+		pipeCtx.SessionTags = parseSessionTags(r.Header)
+		pipeCtx.CapturedAuth = authtypes.CaptureFromHeaders(r.Header)
+		pipeCtx.ClientAgent = detectClientAgent(r.Header)
+		if forwardBody, _, err := g.router.ProcessAll(pipeCtx); err == nil && len(forwardBody) > 0 {
+			est.Tokens.AfterCompression = g.tokens.CountRequest(forwardBody, model)
+			est.Tokens.Compressed = true
+		}
+	}
+
+	preemptiveCfg := preemptive.WithDefaults(cfg.Preemptive)
+	window := preemptive.GetModelContextWindow(model)
+	est.Window.MaxTokens = window.MaxTokens
+	est.Window.OutputMax = window.OutputMax
+	est.Window.EffectiveMax = preemptive.EffectiveMax(model, preemptiveCfg)
+	est.HeadroomTokens = est.Window.EffectiveMax - est.Tokens.AfterCompression
+	est.UsagePercent = preemptive.CalculateUsage(est.Tokens.AfterCompression, est.Window.EffectiveMax).UsagePercent
+	if preemptiveCfg.Enabled {
+		est.CompactionThresholdPercent = preemptiveCfg.TriggerThreshold
+	}
+
+	// Same session ID the proxy uses for cost tracking.
+	est.Session.ID = preemptive.ComputeSessionID(body)
+	if g.responseChains != nil {
+		if chain, ok := g.responseChains.resolve(body); ok {
+			est.Session.ID = chain.SessionID
+			est.CompactionThresholdPercent = 0 // Stored conversations are not compacted
+		}
+	}
+	if est.Session.ID == "" {
+		est.Session.ID = g.getCurrentSessionID()
+	}
+	if g.costTracker != nil && est.Session.ID != "" {
+		if s, ok := g.costTracker.Session(est.Session.ID); ok {
+			est.Session.Tracked = true
+			est.Session.Requests = s.RequestCount
+			est.Session.InputTokens = s.InputTokens
+			est.Session.OutputTokens = s.OutputTokens
+			est.Session.LastInputTokens = s.LastInputTokens
+			est.Session.CostUSD = s.Cost
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(est); err != nil {
+		log.Warn().Err(err).Msg("handleContextEstimate: failed to encode JSON response")
+	}
+}
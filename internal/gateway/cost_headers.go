@@ -0,0 +1,54 @@
+// cost_headers.go - per-request cost estimates returned as response headers.
+//
+// With cost_control.cost_headers enabled, every proxied response carries the
+// estimated input cost of the forwarded request and the input cost the
+// pipeline saved, so client tooling can show per-call cost feedback without
+// reading telemetry files. Both are estimates: tokens are counted from the
+// request bodies with the configured token counter and priced with the model
+// pricing table; output tokens are unknown when headers are written and are
+// not included.
+package gateway
+
+import (
+	"strconv"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// Cost estimate response headers, in USD.
+const (
+	HeaderGatewayCostEstimate = "X-Gateway-Cost-Estimate"
+	HeaderGatewayCostSaved    = "X-Gateway-Cost-Saved"
+)
+
+// withCostHeaders returns headers plus the cost estimate headers for a request.
+// original is the body before the pipeline, compressed the pipeline output and
+// forwarded the body actually sent upstream (including injected phantom tools).
+// headers is not modified; a nil map is allowed.
+func withCostHeaders(headers map[string]string, counter tokenizer.TokenCounter, model string, original, compressed, forwarded []byte, compressionUsed bool) map[string]string {
+	pricing := costcontrol.GetModelPricing(model)
+	estimate := costcontrol.CalculateCost(counter.CountRequest(forwarded, model), 0, pricing)
+
+	saved := 0.0
+	if compressionUsed {
+		originalTokens := counter.CountRequest(original, model)
+		compressedTokens := counter.CountRequest(compressed, model)
+		if originalTokens > compressedTokens {
+			saved = costcontrol.CalculateCost(originalTokens-compressedTokens, 0, pricing)
+		}
+	}
+
+	out := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		out[k] = v
+	}
+	out[HeaderGatewayCostEstimate] = formatUSD(estimate)
+	out[HeaderGatewayCostSaved] = formatUSD(saved)
+	return out
+}
+
+// formatUSD renders a dollar amount with enough precision for sub-cent calls.
+func formatUSD(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
@@ -0,0 +1,126 @@
+// count_tokens.go - Anthropic /v1/messages/count_tokens interception.
+//
+// Claude clients count their request before sending it and compact their own
+// history when the count nears the context window. The gateway forwards less
+// than the client holds (compressed tool outputs, filtered tools), so counting
+// the body as sent makes clients compact early. With server.count_tokens:
+// compressed (the default) the body goes through the compression pipes and
+// gets the phantom tools, as /v1/messages would forward it, and that body is
+// what the upstream counts.
+//
+// External calls are limited to ones the following /v1/messages request
+// reuses: Compresr tool output compressions and tool embeddings are cached,
+// while the uncached Compresr tool selection is replaced by local relevance
+// (pipes.PipeContext.CountOnly). A count that no request follows still pays
+// for those compressions; count_tokens: passthrough avoids them.
+package gateway
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/branching"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/phantom_tools"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// countTokensPath is Anthropic's token counting endpoint.
+const countTokensPath = "/v1/messages/count_tokens"
+
+// HeaderCountTokensBasis tells the client which body a count_tokens answer
+// describes: compressed or passthrough (see server.count_tokens).
+const HeaderCountTokensBasis = "X-Gateway-Count-Basis"
+
+// handleCountTokens serves /v1/messages/count_tokens: the body is compressed
+// like a /v1/messages request, then counted upstream. Falls back to the body
+// as sent when the pipes fail or the format is not recognized.
+func (g *Gateway) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	basis := config.CountTokensPassthrough
+	forwardBody := body
+	if g.cfg().Server.CountTokens != config.CountTokensPassthrough && r.Method == http.MethodPost && g.router != nil {
+		compressed, ok := g.compressForCount(r, body)
+		if !ok {
+			g.recordError(monitoring.ErrorCodePIIMaskingFailed)
+			g.writeError(w, "pii masking failed", http.StatusServiceUnavailable)
+			return
+		}
+		if compressed != nil {
+			forwardBody, basis = compressed, config.CountTokensCompressed
+		}
+	}
+	w.Header().Set(HeaderCountTokensBasis, basis)
+	g.relayPassthrough(w, r, forwardBody)
+}
+
+// compressForCount returns body as /v1/messages would forward it, or nil when
+// it cannot tell. ok is false when PII masking failed: the raw body must not
+// go upstream then either.
+func (g *Gateway) compressForCount(r *http.Request, body []byte) (forward []byte, ok bool) {
+	provider, adapter := adapters.IdentifyAndGetAdapter(g.registry, "/v1/messages", r.Header)
+	if adapter == nil || provider != adapters.ProviderAnthropic {
+		return nil, true
+	}
+
+	model := requestModel(adapter, body, "/v1/messages")
+	tenant := tenancy.FromContext(r.Context())
+	pipeCtx := NewPipelineContext(provider, adapter, body, r.URL.Path)
+	pipeCtx.Tenant = tenancy.NameOf(tenant)
+	pipeCtx.RequestCtx = r.Context()
+	pipeCtx.Model = model
+	pipeCtx.TargetModel = model
+	pipeCtx.SessionTags = parseSessionTags(r.Header)
+	pipeCtx.CapturedAuth = authtypes.CaptureFromHeaders(r.Header)
+	pipeCtx.ClientAgent = detectClientAgent(r.Header)
+	pipeCtx.CountOnly = true
+	pipeCtx.Flags = g.flags.Evaluate(featureflags.Target{
+		SessionID: tenant.SessionID(preemptive.ComputeSessionID(body)),
+		User:      r.Header.Get(featureflags.HeaderUser),
+		Tags:      pipeCtx.SessionTags,
+	})
+	// Same tool session as the conversation's /v1/messages requests, so tool
+	// discovery keeps the tools it has already expanded on this branch and
+	// reuses its cached selection. The branch is looked up, not recorded: a
+	// count never forks a conversation.
+	if g.toolSessions != nil && g.cfgFor(pipeCtx.Tenant).Pipes.ToolDiscovery.Enabled {
+		if sessionID := tenant.SessionID(preemptive.ComputeSessionIDFromClean(pipeCtx.Classification.FirstUserCleanContent)); sessionID != "" {
+			sessionID = g.branches.Lookup(sessionID, branching.HashBody(body))
+			pipeCtx.ToolSessionID = sessionID
+			pipeCtx.SessionID = sessionID
+			pipeCtx.ExpandedTools = g.toolSessions.GetExpanded(sessionID)
+		}
+	}
+
+	compressed, _, err := g.routerFor(pipeCtx.Tenant).ProcessAll(pipeCtx)
+	if pipeCtx.PIIError != nil {
+		return nil, false
+	}
+	if err != nil || len(compressed) == 0 {
+		log.Debug().Err(err).Msg("count_tokens: pipes failed, counting the body as sent")
+		return nil, true
+	}
+
+	baseline := body
+	if pipeCtx.securedBody != nil {
+		baseline = pipeCtx.securedBody
+	}
+	compressed, _ = EnforceCachePrefix(baseline, compressed, pipeCtx.Flags.On(featureflags.CacheCompat, g.cfg().Pipes.CacheCompat.Enabled))
+	if injected, err := phantom_tools.InjectAll(compressed, provider); err == nil {
+		compressed = injected
+	}
+	return compressed, true
+}
@@ -0,0 +1,138 @@
+// custom_pipes.go - pipes.custom in the router.
+//
+// Each pipes.custom entry gets a pool whose workers share one pipe instance
+// (an exec plugin or a registered type). runPipe hands custom runs to
+// finishCustomPipe, which records a monitoring.CustomPipeRun, applies the
+// pipe's on_error policy and turns a refusal into ctx.PipeRejection.
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/custom"
+)
+
+// customPipe is the pipes.custom entry behind a pool.
+type customPipe struct {
+	cfg  pipes.CustomPipeConfig
+	pipe pipes.Pipe
+}
+
+// brokenPipe stands in for a custom pipe that could not be built, so its
+// on_error policy still applies to every request.
+type brokenPipe struct {
+	name string
+	err  error
+}
+
+func (b brokenPipe) Name() string                               { return b.name }
+func (b brokenPipe) Strategy() string                           { return "broken" }
+func (b brokenPipe) Enabled() bool                              { return true }
+func (b brokenPipe) Process(*pipes.PipeContext) ([]byte, error) { return nil, b.err }
+
+// buildCustomPools creates one pool per pipes.custom entry, in config order.
+func (r *Router) buildCustomPools(cfg *config.Config) []*Pool {
+	pools := make([]*Pool, 0, len(cfg.Pipes.Custom))
+	for _, pc := range cfg.Pipes.Custom {
+		pipe, err := custom.New(pc)
+		if err != nil {
+			log.Error().Err(err).Str("pipe", pc.Name).Msg("custom pipe: failed to build, every run will fail")
+			pipe = brokenPipe{name: pc.Name, err: err}
+		}
+		pool := newPool(r.poolSize, func() pipes.Pipe { return pipe })
+		pool.custom = &customPipe{cfg: pc, pipe: pipe}
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// closeCustomPools stops the pipes behind pools (exec plugins).
+func closeCustomPools(pools []*Pool) {
+	for _, pool := range pools {
+		if c, ok := pool.custom.pipe.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Warn().Err(err).Str("pipe", pool.custom.cfg.Name).Msg("custom pipe: close failed")
+			}
+		}
+	}
+}
+
+// customSnapshot returns the custom pipe pools in config order.
+func (r *Router) customSnapshot() []*Pool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.customPools
+}
+
+// CustomPipeNames returns the configured custom pipe names in run order.
+func (r *Router) CustomPipeNames() []string {
+	pools := r.customSnapshot()
+	names := make([]string, 0, len(pools))
+	for _, pool := range pools {
+		names = append(names, pool.custom.cfg.Name)
+	}
+	return names
+}
+
+// finishCustomPipe records a custom pipe run and returns the body to pass
+// on: out when the pipe succeeded with a valid JSON body, else in.
+func (ctx *PipelineContext) finishCustomPipe(c *customPipe, in, out []byte, err error, elapsed time.Duration) []byte {
+	run := monitoring.CustomPipeRun{
+		Pipe:       c.cfg.Name,
+		DurationMs: elapsed.Milliseconds(),
+		BytesIn:    len(in),
+		Metrics:    ctx.CustomMetrics,
+	}
+	ctx.CustomMetrics = nil
+	if err == nil && len(out) > 0 && !json.Valid(out) {
+		err = errors.New("pipe returned invalid JSON")
+	}
+
+	var reject *pipes.RejectError
+	result := in
+	switch {
+	case errors.As(err, &reject):
+		run.Outcome, run.Error = monitoring.CustomPipeRejected, reject.Reason
+		ctx.PipeRejection, ctx.rejectStatus, ctx.rejectCode = reject, http.StatusBadRequest, monitoring.ErrorCodePipeRejected
+	case err != nil:
+		run.Error = err.Error()
+		ctx.PipeFailed = true
+		if c.cfg.FailClosed() {
+			run.Outcome = monitoring.CustomPipeRejected
+			ctx.PipeRejection = fmt.Errorf("pipe %s failed: %w", c.cfg.Name, err)
+			ctx.rejectStatus, ctx.rejectCode = http.StatusServiceUnavailable, monitoring.ErrorCodePipeRejected
+		} else {
+			run.Outcome = monitoring.CustomPipeFailed
+		}
+		log.Error().Err(err).Str("pipe", c.cfg.Name).Str("on_error", c.cfg.OnError).Msg("custom pipe failed")
+	case len(out) == 0 || bytes.Equal(in, out):
+		run.Outcome = monitoring.CustomPipeUnchanged
+	default:
+		run.Outcome = monitoring.CustomPipeModified
+		result = out
+	}
+	run.BytesOut = len(result)
+	ctx.CustomPipes = append(ctx.CustomPipes, run)
+	return result
+}
+
+// recordCustomPipes counts the request's custom pipe runs in /stats and /metrics.
+func (g *Gateway) recordCustomPipes(ctx *PipelineContext) {
+	if g.metrics == nil {
+		return
+	}
+	for _, run := range ctx.CustomPipes {
+		g.metrics.RecordCustomPipe(run)
+	}
+}
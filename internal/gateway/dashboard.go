@@ -14,6 +14,8 @@ import (
 
 	"github.com/compresr/context-gateway/internal/config"
 	"github.com/compresr/context-gateway/internal/dashboard"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/statedir"
 )
 
 // tryStartDashboardServer attempts to bind the centralized dashboard port (18080).
@@ -155,16 +157,18 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 	}
 
 	type aggregatedResponse struct {
-		Sessions      []sessionJSON     `json:"sessions"`
-		TotalCost     float64           `json:"total_cost"`
-		TotalRequests int               `json:"total_requests"`
-		SessionCap    float64           `json:"session_cap"`
-		GlobalCap     float64           `json:"global_cap"`
-		Enabled       bool              `json:"enabled"`
-		Savings       *savingsJSON      `json:"savings,omitempty"`
-		GlobalSavings *savingsJSON      `json:"global_savings,omitempty"`
-		Gateway       *gatewayStatsJSON `json:"gateway,omitempty"`
-		ActivePorts   []int             `json:"active_ports"`
+		Sessions      []sessionJSON            `json:"sessions"`
+		TotalCost     float64                  `json:"total_cost"`
+		TotalRequests int                      `json:"total_requests"`
+		SessionCap    float64                  `json:"session_cap"`
+		GlobalCap     float64                  `json:"global_cap"`
+		Enabled       bool                     `json:"enabled"`
+		Savings       *savingsJSON             `json:"savings,omitempty"`
+		GlobalSavings *savingsJSON             `json:"global_savings,omitempty"`
+		Gateway       *gatewayStatsJSON        `json:"gateway,omitempty"`
+		ToolSavings   []monitoring.ToolSavings `json:"tool_savings,omitempty"`
+		ActivePorts   []int                    `json:"active_ports"`
+		Lifetime      *statedir.SelfMetrics    `json:"lifetime,omitempty"` // Shared state dir: all instances, across restarts
 	}
 
 	// Use instance registry for discovery — same source as handleAggregatedMonitorAPI.
@@ -192,6 +196,10 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 		Sessions:    make([]sessionJSON, 0),
 		ActivePorts: make([]int, 0),
 	}
+	if g.selfMetrics != nil {
+		lt := g.selfMetrics.Lifetime()
+		resp.Lifetime = &lt
+	}
 
 	// Aggregate savings
 	var totalSavings savingsJSON
@@ -200,6 +208,7 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 	hasSavings := false
 	hasGlobalSavings := false
 	hasGateway := false
+	var toolBoards [][]monitoring.ToolSavings
 
 	requestedSession := r.URL.Query().Get("session")
 
@@ -227,15 +236,16 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 		}
 
 		var gwData struct {
-			Sessions      []sessionJSON     `json:"sessions"`
-			TotalCost     float64           `json:"total_cost"`
-			TotalRequests int               `json:"total_requests"`
-			SessionCap    float64           `json:"session_cap"`
-			GlobalCap     float64           `json:"global_cap"`
-			Enabled       bool              `json:"enabled"`
-			Savings       *savingsJSON      `json:"savings"`
-			GlobalSavings *savingsJSON      `json:"global_savings"`
-			Gateway       *gatewayStatsJSON `json:"gateway"`
+			Sessions      []sessionJSON            `json:"sessions"`
+			TotalCost     float64                  `json:"total_cost"`
+			TotalRequests int                      `json:"total_requests"`
+			SessionCap    float64                  `json:"session_cap"`
+			GlobalCap     float64                  `json:"global_cap"`
+			Enabled       bool                     `json:"enabled"`
+			Savings       *savingsJSON             `json:"savings"`
+			GlobalSavings *savingsJSON             `json:"global_savings"`
+			Gateway       *gatewayStatsJSON        `json:"gateway"`
+			ToolSavings   []monitoring.ToolSavings `json:"tool_savings"`
 		}
 
 		body, err := io.ReadAll(gwResp.Body)
@@ -416,6 +426,11 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 			}
 		}
 
+		// Per-tool savings are in-memory per instance, so they sum.
+		if len(gwData.ToolSavings) > 0 {
+			toolBoards = append(toolBoards, gwData.ToolSavings)
+		}
+
 		// Aggregate gateway stats - take max since all read same data
 		if gwData.Gateway != nil {
 			hasGateway = true
@@ -453,6 +468,13 @@ func (g *Gateway) handleAggregatedDashboardAPI(w http.ResponseWriter, r *http.Re
 		resp.GlobalSavings = &totalGlobalSavings
 	}
 
+	if tools := monitoring.MergeToolSavings(toolBoards...); len(tools) > 0 {
+		if len(tools) > dashboardToolLeaderboardSize {
+			tools = tools[:dashboardToolLeaderboardSize]
+		}
+		resp.ToolSavings = tools
+	}
+
 	if hasGateway {
 		totalGatewayStats.Uptime = time.Since(gatewayStartTime).Truncate(time.Second).String()
 		resp.Gateway = &totalGatewayStats
@@ -0,0 +1,52 @@
+// error_codes.go maps gateway failures onto the monitoring error taxonomy.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// Sentinel errors returned by forwardPassthrough so callers can classify them.
+var (
+	errHostNotAllowed   = errors.New("target host not allowed")
+	errMissingTargetURL = errors.New("missing target URL")
+	errKeyPinViolation  = errors.New("provider key not allowed for target host")
+	errCircuitOpen      = errors.New("upstream circuit open")
+
+	errUnsupportedAPIVersion = errors.New("unsupported API version")
+)
+
+// ClassifyUpstreamError maps a forwarding error to an error code and retryable flag.
+// fallback is used when err is not a transport-level failure (e.g. phantom loop errors).
+func ClassifyUpstreamError(err error, fallback monitoring.ErrorCode) (monitoring.ErrorCode, bool) {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return "", false
+	case errors.Is(err, errHostNotAllowed):
+		return monitoring.ErrorCodeHostNotAllowed, false
+	case errors.Is(err, errKeyPinViolation):
+		return monitoring.ErrorCodeKeyPinViolation, false
+	case errors.Is(err, errCircuitOpen):
+		return monitoring.ErrorCodeCircuitOpen, true
+	case errors.Is(err, errMissingTargetURL):
+		return monitoring.ErrorCodeInvalidRequest, false
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return monitoring.ErrorCodeUpstreamTimeout, true
+	case errors.As(err, &netErr):
+		return monitoring.ErrorCodeUpstreamUnavailable, true
+	default:
+		return fallback, fallback.Retryable()
+	}
+}
+
+// recordError counts a failure that does not produce a RequestEvent
+// (requests rejected before forwarding).
+func (g *Gateway) recordError(code monitoring.ErrorCode) {
+	if g.metrics != nil {
+		g.metrics.RecordError(code)
+	}
+}
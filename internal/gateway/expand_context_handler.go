@@ -11,6 +11,7 @@ import (
 	"github.com/compresr/context-gateway/internal/adapters"
 	"github.com/compresr/context-gateway/internal/monitoring"
 	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/media"
 	"github.com/compresr/context-gateway/internal/store"
 	"github.com/compresr/context-gateway/internal/tokenizer"
 )
@@ -24,10 +25,14 @@ type ExpandContextHandler struct {
 	expandLog        *monitoring.ExpandLog
 	expandCallsLog   *monitoring.ExpandCallsLogger          // writes expand_context_calls.jsonl
 	compressionIndex map[string]pipes.ToolOutputCompression // shadow_id → compression metadata
+	toolSavings      *monitoring.ToolSavingsTracker         // per-tool expand/regret attribution
 	requestID        string
 	sessionID        string
-	mu               sync.Mutex      // Protects expandedIDs from concurrent access
+	pageBytes        int             // Max bytes per expansion (0 = whole content)
+	budgetBytes      int             // Max bytes expanded per request (0 = unlimited)
+	mu               sync.Mutex      // Protects expandedIDs and spentBytes from concurrent access
 	expandedIDs      map[string]bool // Track expanded IDs to prevent circular expansion
+	spentBytes       int             // Bytes returned so far in this request
 }
 
 // NewExpandContextHandler creates a new expand context handler.
@@ -65,11 +70,30 @@ func (h *ExpandContextHandler) WithExpandCallsLog(logger *monitoring.ExpandCalls
 	return h
 }
 
-// ResetExpandedIDs resets the tracking of expanded IDs.
+// WithToolSavings sets the tracker that attributes expands to the compressed tool.
+func (h *ExpandContextHandler) WithToolSavings(ts *monitoring.ToolSavingsTracker) *ExpandContextHandler {
+	h.mu.Lock()
+	h.toolSavings = ts
+	h.mu.Unlock()
+	return h
+}
+
+// WithPaging sets the page size and per-request budget, in bytes, for
+// expansions. Longer originals are returned a page at a time.
+func (h *ExpandContextHandler) WithPaging(pageBytes, budgetBytes int) *ExpandContextHandler {
+	h.mu.Lock()
+	h.pageBytes = pageBytes
+	h.budgetBytes = budgetBytes
+	h.mu.Unlock()
+	return h
+}
+
+// ResetExpandedIDs resets the tracking of expanded IDs and the spent budget.
 // Call this at the start of each request.
 func (h *ExpandContextHandler) ResetExpandedIDs() {
 	h.mu.Lock()
 	h.expandedIDs = make(map[string]bool)
+	h.spentBytes = 0
 	h.mu.Unlock()
 }
 
@@ -80,6 +104,7 @@ func (h *ExpandContextHandler) Name() string {
 
 // HandleCalls processes expand_context calls and returns results.
 // Supports both shadow IDs (whole content) and field refs (field-level expansion).
+// offset/limit and start_line/end_line arguments select part of the content.
 func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adapters.Adapter, requestBody []byte) *PhantomToolResult {
 	result := &PhantomToolResult{}
 
@@ -89,7 +114,7 @@ func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adap
 	filteredCalls := make([]PhantomToolCall, 0, len(calls))
 	for _, call := range calls {
 		refID, _ := call.Input["id"].(string)
-		if h.expandedIDs[refID] {
+		if h.expandedIDs[parseExpandRange(call.Input).key(refID)] {
 			log.Warn().
 				Str("ref_id", refID).
 				Msg("expand_context: skipping already-expanded ID")
@@ -107,13 +132,14 @@ func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adap
 	// Mark all filtered calls as expanded before releasing lock
 	for _, call := range filteredCalls {
 		refID, _ := call.Input["id"].(string)
-		h.expandedIDs[refID] = true
+		h.expandedIDs[parseExpandRange(call.Input).key(refID)] = true
 	}
 	h.mu.Unlock()
 
 	// Build adapter-native ToolCall slice and content per call
 	adapterCalls := make([]adapters.ToolCall, 0, len(filteredCalls))
 	contentPerCall := make([]string, 0, len(filteredCalls))
+	var restores []mediaRestore
 
 	for _, call := range filteredCalls {
 		refID, _ := call.Input["id"].(string)
@@ -129,7 +155,6 @@ func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adap
 			if ok {
 				found = true
 				content = fieldRef.Original
-				resultText = content
 				log.Debug().
 					Str("field_ref", refID).
 					Str("field", fieldRef.Field).
@@ -138,30 +163,41 @@ func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adap
 					Msg("expand_context: retrieved field ref")
 			} else {
 				found = false
-				resultText = fmt.Sprintf("[The full content for field reference '%s' is no longer available. The compressed summary is already present in your context — please continue working with that.]", refID)
+				reason := h.missReason(refID)
+				resultText = fmt.Sprintf("[The full content for field reference '%s' is no longer available%s. The compressed summary is already present in your context — please continue working with that.]", refID, missExplanation(reason))
 				log.Warn().
 					Str("field_ref", refID).
 					Str("request_id", h.requestID).
+					Str("reason", reason).
 					Msg("expand_context: field ref not found in store")
 			}
 		} else {
 			// Shadow ID: retrieve whole content
 			content, found = h.store.Get(refID)
 			if found {
-				resultText = content
 				log.Debug().
 					Str("shadow_id", refID).
 					Int("content_len", len(content)).
 					Msg("expand_context: retrieved content")
 			} else {
-				resultText = fmt.Sprintf("[The full content for shadow reference '%s' is no longer available (gateway was restarted between sessions). The compressed summary is already present in your context — please continue working with that.]", refID)
+				reason := h.missReason(refID)
+				resultText = fmt.Sprintf("[The full content for shadow reference '%s' is no longer available%s. The compressed summary is already present in your context — please continue working with that.]", refID, missExplanation(reason))
 				log.Error().
 					Str("shadow_id", refID).
 					Str("request_id", h.requestID).
-					Str("reason", "ttl_expired_or_missing").
+					Str("reason", reason).
 					Msg("expand_context: shadow ID not found in store")
 			}
 		}
+		switch {
+		case found && media.IsRef(refID):
+			// Media blocks go back where they were, not into the tool result.
+			restores = append(restores, mediaRestore{ref: refID, raw: content})
+			resultText = fmt.Sprintf("[The original block for '%s' has been restored in place of its placeholder.]", refID)
+			content = resultText
+		case found:
+			resultText, content = h.page(refID, content, parseExpandRange(call.Input))
+		}
 		h.recordExpandEntry(refID, found, content)
 
 		adapterCalls = append(adapterCalls, adapters.ToolCall{
@@ -174,16 +210,89 @@ func (h *ExpandContextHandler) HandleCalls(calls []PhantomToolCall, adapter adap
 
 	// Delegate format-specific message construction to adapter
 	result.ToolResults = adapter.BuildToolResultMessages(adapterCalls, contentPerCall, requestBody)
+	if len(restores) > 0 {
+		result.ModifyRequest = restoreMediaBlocks(adapter, restores)
+	}
 	return result
 }
 
+// mediaRestore is a dropped media block to put back: its ref and original JSON.
+type mediaRestore struct {
+	ref, raw string
+}
+
+// restoreMediaBlocks returns a request modifier that swaps each placeholder
+// left by the media pipe for its original block. Placeholders that are no
+// longer in the request are skipped.
+func restoreMediaBlocks(adapter adapters.Adapter, restores []mediaRestore) func([]byte) ([]byte, error) {
+	return func(body []byte) ([]byte, error) {
+		mediaAdapter, ok := adapter.(adapters.MediaBlockAdapter)
+		if !ok {
+			return body, nil
+		}
+		for _, r := range restores {
+			var restored bool
+			if body, restored = mediaAdapter.RestoreMediaBlock(body, media.Marker(r.ref), r.raw); !restored {
+				log.Warn().Str("shadow_id", r.ref).Msg("expand_context: media placeholder not found, block not restored")
+			}
+		}
+		return body, nil
+	}
+}
+
+// page cuts content to the requested range, the page size and the budget left
+// in this request. It returns the result text and the content it includes.
+func (h *ExpandContextHandler) page(refID, content string, r expandRange) (string, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	maxBytes := h.pageBytes
+	if h.budgetBytes > 0 {
+		left := h.budgetBytes - h.spentBytes
+		if left <= 0 {
+			log.Warn().
+				Str("ref_id", refID).
+				Int("budget_bytes", h.budgetBytes).
+				Msg("expand_context: expansion budget used up")
+			return fmt.Sprintf("[The expand_context budget for this request (%d bytes) is used up. Continue with the compressed content; '%s' can be expanded again in a later turn.]", h.budgetBytes, refID), ""
+		}
+		if maxBytes == 0 || left < maxBytes {
+			maxBytes = left
+		}
+	}
+	text, n := r.page(refID, content, maxBytes)
+	h.spentBytes += n
+	return text, text[:n]
+}
+
+// missReason records a miss with stores that track why entries are gone.
+func (h *ExpandContextHandler) missReason(refID string) string {
+	if r, ok := h.store.(store.MissRecorder); ok {
+		return r.RecordExpandMiss(refID)
+	}
+	return store.MissUnknown
+}
+
+// missExplanation is the parenthetical shown to the model for a miss reason.
+func missExplanation(reason string) string {
+	switch reason {
+	case store.MissEvicted:
+		return " (evicted to keep the shadow store within its size limit)"
+	case store.MissExpired:
+		return " (it expired)"
+	default:
+		return " (gateway was restarted between sessions)"
+	}
+}
+
 // isFieldRef checks if the ref ID is a field-level reference.
 func isFieldRef(refID string) bool {
 	return len(refID) > 6 && refID[:6] == "field_"
 }
 
 // recordExpandEntry logs an expand_context call to the in-memory expand log
-// and, if configured, to expand_context_calls.jsonl with full content.
+// and, if configured, to expand_context_calls.jsonl with full content and to
+// the per-tool savings tracker.
 func (h *ExpandContextHandler) recordExpandEntry(shadowID string, found bool, content string) {
 	now := time.Now()
 
@@ -221,4 +330,8 @@ func (h *ExpandContextHandler) recordExpandEntry(shadowID string, found bool, co
 		}
 		h.expandCallsLog.Log(entry)
 	}
+
+	if h.toolSavings != nil {
+		h.toolSavings.RecordExpand(shadowID, found, tokenizer.CountTokens(content))
+	}
 }
@@ -0,0 +1,123 @@
+// Partial expansion for expand_context: byte and line ranges, page size and
+// per-request budget.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// expandRange is the part of an original an expand_context call asks for.
+// Zero fields are unset.
+type expandRange struct {
+	Offset    int `json:"offset,omitempty"`     // Byte offset into the selected lines
+	Limit     int `json:"limit,omitempty"`      // Max bytes to return
+	StartLine int `json:"start_line,omitempty"` // First line, 1-based
+	EndLine   int `json:"end_line,omitempty"`   // Last line, inclusive
+}
+
+// parseExpandRange reads the paging arguments of an expand_context call.
+// Models send numbers as JSON numbers or occasionally as strings.
+func parseExpandRange(input map[string]any) expandRange {
+	return expandRange{
+		Offset:    intArg(input, "offset"),
+		Limit:     intArg(input, "limit"),
+		StartLine: intArg(input, "start_line"),
+		EndLine:   intArg(input, "end_line"),
+	}
+}
+
+func intArg(input map[string]any, key string) int {
+	var n int
+	switch v := input[key].(type) {
+	case float64:
+		n = int(v)
+	case int:
+		n = v
+	case json.Number:
+		i, _ := v.Int64()
+		n = int(i)
+	case string:
+		n, _ = strconv.Atoi(strings.TrimSpace(v))
+	}
+	return max(n, 0)
+}
+
+// key identifies the call for duplicate detection: the same range of the same
+// ID is expanded once per request, but different pages are not duplicates.
+func (r expandRange) key(id string) string {
+	if r == (expandRange{}) {
+		return id
+	}
+	return fmt.Sprintf("%s@%d+%d:%d-%d", id, r.Offset, r.Limit, r.StartLine, r.EndLine)
+}
+
+// page returns the part of content selected by r, at most maxBytes long
+// (0 = no cap), and the number of content bytes returned. When only part of
+// the selection is returned, a footer gives the arguments for the next page.
+func (r expandRange) page(id, content string, maxBytes int) (string, int) {
+	selection := content
+	lines := ""
+	if r.StartLine > 0 || r.EndLine > 0 {
+		all := strings.SplitAfter(content, "\n")
+		if all[len(all)-1] == "" {
+			all = all[:len(all)-1]
+		}
+		start, end := max(r.StartLine, 1), r.EndLine
+		if end == 0 || end > len(all) {
+			end = len(all)
+		}
+		if start > len(all) {
+			return fmt.Sprintf("[Line %d is past the end of the content, which has %d lines.]", start, len(all)), 0
+		}
+		if start > end {
+			return fmt.Sprintf("[end_line %d is before start_line %d.]", end, start), 0
+		}
+		selection = strings.Join(all[start-1:end], "")
+		lines = fmt.Sprintf(" of lines %d-%d", start, end)
+	}
+	if r.Offset > 0 && r.Offset >= len(selection) {
+		return fmt.Sprintf("[Offset %d is past the end of the content (%d bytes%s).]", r.Offset, len(selection), lines), 0
+	}
+
+	n := len(selection) - r.Offset
+	if r.Limit > 0 && r.Limit < n {
+		n = r.Limit
+	}
+	if maxBytes > 0 && maxBytes < n {
+		n = maxBytes
+	}
+	end := r.Offset + n
+	if end < len(selection) {
+		// Don't split a UTF-8 sequence; prefer ending the page at a line break.
+		for end > r.Offset && !utf8.RuneStart(selection[end]) {
+			end--
+		}
+		if end == r.Offset {
+			_, size := utf8.DecodeRuneInString(selection[end:])
+			end += size
+		}
+		if i := strings.LastIndexByte(selection[r.Offset:end], '\n'); i >= 0 && i+1 > n/2 {
+			end = r.Offset + i + 1
+		}
+	}
+	text := selection[r.Offset:end]
+	if r.Offset == 0 && end == len(selection) {
+		return text, len(text)
+	}
+
+	footer := fmt.Sprintf("\n[expand_context: returned bytes %d-%d of %d%s.", r.Offset, end, len(selection), lines)
+	if end < len(selection) {
+		next := r
+		next.Offset = end
+		args, _ := json.Marshal(struct {
+			ID string `json:"id"`
+			expandRange
+		}{id, next})
+		footer += fmt.Sprintf(" To read more, call expand_context with %s.", args)
+	}
+	return text + footer + "]", len(text)
+}
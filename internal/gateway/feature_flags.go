@@ -0,0 +1,124 @@
+// feature_flags.go - Admin API for feature flags.
+//
+// Flags are evaluated once per proxied request, after the session ID is known,
+// and the decisions travel in PipelineContext.Flags to the capabilities they
+// gate (PII response unmasking, the prompt-cache guard, routing rules).
+// GET /admin/flags lists defined flags with decision counts; GET
+// /admin/flags/{name}?session=&user=&tags= explains one decision; PUT
+// replaces a flag at runtime and DELETE reverts it to config. Overrides are
+// kept in memory only. All endpoints are loopback-only.
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/featureflags"
+)
+
+// maxFlagBodySize bounds PUT /admin/flags/{name} bodies.
+const maxFlagBodySize = 64 << 10
+
+// Shapes of feature flag responses.
+type (
+	flagListResponse struct {
+		Flags []featureflags.State `json:"flags"`
+		Known []string             `json:"known"` // Flag names the gateway understands
+	}
+	flagExplainResponse struct {
+		State  featureflags.State  `json:"state"`
+		Target featureflags.Target `json:"target"`
+		On     bool                `json:"on"`
+		Reason string              `json:"reason"` // enabled | session | user | tag | percent | off
+	}
+	flagClearResponse struct {
+		Name    string `json:"name"`
+		Cleared bool   `json:"cleared"`
+	}
+)
+
+// handleAdminFlags serves GET /admin/flags and GET/PUT/DELETE /admin/flags/{name}.
+func (g *Gateway) handleAdminFlags(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if g.flags == nil {
+		g.writeError(w, "feature flags unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/flags"), "/")
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		states := g.flags.States()
+		if states == nil {
+			states = []featureflags.State{}
+		}
+		writeFlagJSON(w, flagListResponse{Flags: states, Known: featureflags.Known})
+	case name == "":
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	case r.Method == http.MethodGet:
+		q := r.URL.Query()
+		target := featureflags.Target{SessionID: q.Get("session"), User: q.Get("user")}
+		for _, tag := range strings.Split(q.Get("tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				target.Tags = append(target.Tags, tag)
+			}
+		}
+		st, on, reason, ok := g.flags.Explain(name, target)
+		if !ok {
+			g.writeError(w, "flag not defined", http.StatusNotFound)
+			return
+		}
+		writeFlagJSON(w, flagExplainResponse{State: st, Target: target, On: on, Reason: reason})
+	case r.Method == http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, maxFlagBodySize)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			g.writeError(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+		var f featureflags.Flag
+		if err := json.Unmarshal(body, &f); err != nil {
+			g.writeError(w, "invalid flag", http.StatusBadRequest)
+			return
+		}
+		if err := g.flags.Override(name, f); err != nil {
+			g.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info().
+			Str("flag", name).
+			Bool("enabled", f.Enabled).
+			Int("sessions", len(f.Sessions)).
+			Int("users", len(f.Users)).
+			Int("tags", len(f.Tags)).
+			Float64("percent", f.Percent).
+			Msg("admin: feature flag overridden")
+		st, _, _, _ := g.flags.Explain(name, featureflags.Target{})
+		writeFlagJSON(w, st)
+	case r.Method == http.MethodDelete:
+		if !g.flags.ClearOverride(name) {
+			g.writeError(w, "flag has no override", http.StatusNotFound)
+			return
+		}
+		log.Info().Str("flag", name).Msg("admin: feature flag override cleared")
+		writeFlagJSON(w, flagClearResponse{Name: name, Cleared: true})
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeFlagJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("handleAdminFlags: failed to encode JSON response")
+	}
+}
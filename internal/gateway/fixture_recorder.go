@@ -0,0 +1,181 @@
+// fixture_recorder.go - Records anonymized traffic as replayable test fixtures.
+//
+// `context-gateway serve --record-fixtures <dir>` writes every successful
+// proxied LLM exchange to dir in the layout of tests/golden/testdata:
+//
+//	<adapter>_<agent>_<NNNN>.json            request, as the pipes received it
+//	<adapter>_<agent>_<NNNN>.response.json   upstream response (non-streaming)
+//	<adapter>_<agent>_<NNNN>.response.sse    upstream response (streaming)
+//
+// Copy a request into tests/golden/testdata/<pipe>/ and run the golden suite
+// with UPDATE_GOLDEN=1 to turn it into a regression test; the response files
+// seed adapter conformance cases.
+//
+// Requests are recorded after the redaction layer: the PII-masked body when
+// pipes.pii is enabled. Independently of that pipe, the recorder runs the PII
+// detectors over both request and response and replaces the user's home
+// directory with /home/user. Headers, and with them credentials, are never
+// recorded. Detectors do not catch names or proprietary code, so review
+// fixtures before committing them.
+//
+// Recording is a flag rather than a config key so that it is always an
+// explicit choice of the person running the gateway, never inherited from a
+// shared config file.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/pii"
+)
+
+// anonymizedHome replaces the recording user's home directory in fixtures.
+const anonymizedHome = "/home/user"
+
+// fixtureRecorder writes request/response pairs to dir. A nil
+// *fixtureRecorder records nothing.
+type fixtureRecorder struct {
+	dir  string
+	mask *pii.Pipe
+	home string
+
+	mu   sync.Mutex
+	next map[string]int // case prefix → next sequence number to try
+}
+
+func newFixtureRecorder(dir string, cfg *config.Config) (*fixtureRecorder, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil { // #nosec G301
+		return nil, fmt.Errorf("record fixtures: %w", err)
+	}
+	// Same detectors as the PII pipe, but always on and without a store:
+	// fixture tokens never need to be restored.
+	pc := cfg.Pipes.PII
+	mask := pii.New(&config.Config{Pipes: config.PipesConfig{PII: pipes.PIIConfig{
+		Enabled:   true,
+		Detectors: pc.Detectors,
+		API:       pc.API,
+	}}}, nil)
+	home, _ := os.UserHomeDir()
+	if home == "/" {
+		home = ""
+	}
+	return &fixtureRecorder{dir: dir, mask: mask, home: home, next: make(map[string]int)}, nil
+}
+
+// RecordFixtures writes anonymized request/response pairs to dir (see
+// fixture_recorder.go). Call before Start.
+func (g *Gateway) RecordFixtures(dir string) error {
+	fr, err := newFixtureRecorder(dir, g.cfg())
+	if err != nil {
+		return err
+	}
+	g.fixtures = fr
+	return nil
+}
+
+// recordFixture records one exchange. request is the body the pipes started
+// from; the version after PII masking and injection scanning is used when
+// those pipes ran.
+func (g *Gateway) recordFixture(adapter adapters.Adapter, pipeCtx *PipelineContext, agent string,
+	request, response []byte, statusCode int, streaming bool) {
+	if g.fixtures == nil || adapter == nil || statusCode < 200 || statusCode >= 300 || len(response) == 0 {
+		return
+	}
+	if pipeCtx.securedBody != nil {
+		request = pipeCtx.securedBody
+	}
+	if err := g.fixtures.record(adapter, agent, request, response, streaming); err != nil {
+		log.Warn().Err(err).Str("request_id", pipeCtx.RequestID).Msg("record fixtures: failed to write fixture")
+	}
+}
+
+// record anonymizes and writes one exchange under the next free case name.
+func (fr *fixtureRecorder) record(adapter adapters.Adapter, agent string, request, response []byte, streaming bool) error {
+	req, err := fr.anonymizeRequest(adapter, request)
+	if err != nil {
+		return err
+	}
+	resp, err := fr.anonymizeText(response)
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, req, "", "  "); err != nil {
+		return fmt.Errorf("request is not JSON: %w", err)
+	}
+	indented.WriteByte('\n')
+	responseExt := ".response.sse"
+	if !streaming {
+		responseExt = ".response.json"
+		var r bytes.Buffer
+		if json.Indent(&r, resp, "", "  ") == nil {
+			r.WriteByte('\n')
+			resp = r.Bytes()
+		}
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	base, err := fr.reserve(adapter.Name() + "_" + agent)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".json", indented.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(base+responseExt, resp, 0o600)
+}
+
+// reserve returns the path, without extension, of the first unused case
+// number for prefix. Numbering continues across runs into the same directory.
+// Call with fr.mu held.
+func (fr *fixtureRecorder) reserve(prefix string) (string, error) {
+	for n := max(fr.next[prefix], 1); ; n++ {
+		base := filepath.Join(fr.dir, fmt.Sprintf("%s_%04d", prefix, n))
+		if _, err := os.Stat(base + ".json"); os.IsNotExist(err) {
+			fr.next[prefix] = n + 1
+			return base, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// anonymizeRequest masks PII in the request's conversation fields, leaving
+// model names, IDs and other structural fields as they were.
+func (fr *fixtureRecorder) anonymizeRequest(adapter adapters.Adapter, body []byte) ([]byte, error) {
+	masked, err := fr.mask.Process(pipes.NewPipeContext(adapter, body))
+	if err != nil {
+		return nil, fmt.Errorf("anonymize request: %w", err)
+	}
+	return fr.scrubHome(masked), nil
+}
+
+// anonymizeText masks PII anywhere in a response body or SSE stream.
+func (fr *fixtureRecorder) anonymizeText(body []byte) ([]byte, error) {
+	masked, _, err := fr.mask.MaskText(context.Background(), string(body))
+	if err != nil {
+		return nil, fmt.Errorf("anonymize response: %w", err)
+	}
+	return fr.scrubHome([]byte(masked)), nil
+}
+
+func (fr *fixtureRecorder) scrubHome(body []byte) []byte {
+	if fr.home == "" {
+		return body
+	}
+	return bytes.ReplaceAll(body, []byte(strings.TrimSuffix(fr.home, "/")), []byte(anonymizedHome))
+}
@@ -10,24 +10,36 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	"github.com/compresr/context-gateway/internal/auth"
+	"github.com/compresr/context-gateway/internal/branching"
 	"github.com/compresr/context-gateway/internal/compresr"
 	"github.com/compresr/context-gateway/internal/config"
 	"github.com/compresr/context-gateway/internal/costcontrol"
 	"github.com/compresr/context-gateway/internal/dashboard"
+	"github.com/compresr/context-gateway/internal/echo"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/fleet"
+	"github.com/compresr/context-gateway/internal/mcp"
 	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/notify"
 	"github.com/compresr/context-gateway/internal/postsession"
 	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/priority"
 	"github.com/compresr/context-gateway/internal/prompthistory"
+	"github.com/compresr/context-gateway/internal/redaction"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+	"github.com/compresr/context-gateway/internal/statedir"
 	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+	"github.com/compresr/context-gateway/internal/tracing"
 )
 
 // Header constants for gateway requests.
@@ -44,6 +56,8 @@ const (
 	MaxStreamBufferSize    = config.MaxStreamBufferSize
 	DefaultRateLimit       = config.DefaultRateLimit
 	MaxRateLimitBuckets    = config.MaxRateLimitBuckets
+	RateLimitByIP          = config.RateLimitByIP
+	RateLimitByAPIKey      = config.RateLimitByAPIKey
 	DefaultBufferSize      = config.DefaultBufferSize
 	DefaultCleanupInterval = config.DefaultCleanupInterval
 	DefaultDialTimeout     = config.DefaultDialTimeout
@@ -105,7 +119,7 @@ func init() {
 				continue
 			}
 			// Validate entry is a proper hostname or CIDR to prevent accidental SSRF expansion.
-			if !isValidHostEntry(host) {
+			if !config.IsValidHostEntry(host) {
 				log.Warn().Str("entry", host).Msg("GATEWAY_ALLOWED_HOSTS: skipping invalid hostname/CIDR entry")
 				continue
 			}
@@ -120,26 +134,6 @@ func init() {
 	}
 }
 
-// validHostnameRE matches valid hostnames (e.g. api.openai.com) and bare labels (e.g. localhost).
-// Allows letters, digits, hyphens, and dots. Does not allow IP literals (handled by net.ParseCIDR).
-var validHostnameRE = regexp.MustCompile(`^[a-z0-9]([a-z0-9\.\-]*[a-z0-9])?$`)
-
-// isValidHostEntry returns true if entry is a valid hostname or CIDR range.
-// Invalid entries in GATEWAY_ALLOWED_HOSTS are logged and skipped to prevent
-// accidental SSRF allowlist expansion via typos or injection.
-func isValidHostEntry(entry string) bool {
-	// Accept CIDR ranges (e.g. 10.0.0.0/8)
-	if _, _, err := net.ParseCIDR(entry); err == nil {
-		return true
-	}
-	// Accept plain IP addresses
-	if net.ParseIP(entry) != nil {
-		return true
-	}
-	// Accept valid hostnames (lowercase enforced by caller)
-	return validHostnameRE.MatchString(entry)
-}
-
 // EnableLocalHostsForTesting adds localhost to the SSRF allowlist.
 // This should only be called from test setup (TestMain).
 func EnableLocalHostsForTesting() {
@@ -177,6 +171,10 @@ type Gateway struct {
 	server            *http.Server
 	dashboardServer   *http.Server // Centralized dashboard on fixed port 18080
 	dashboardStarted  bool         // Whether this instance owns the dashboard server
+	grpcMu            sync.Mutex
+	grpcServer        *grpc.Server // gRPC API (nil unless serve --grpc-addr)
+	mcp               *mcp.Server  // MCP tools (POST /mcp)
+	mcpSSE            *mcp.SSE     // MCP HTTP+SSE transport (GET /mcp/sse, POST /mcp/message)
 	rateLimiter       *rateLimiter
 
 	// Config hot-reload
@@ -186,12 +184,47 @@ type Gateway struct {
 	// Cost control
 	costTracker *costcontrol.Tracker
 
+	// Priority classes: concurrency slots and budget shedding (nil when disabled)
+	scheduler *priority.Scheduler
+
+	// Feature flags: per-request capability rollout (config plus admin overrides)
+	flags *featureflags.Set
+
+	// Tenant registry and per-tenant routers (tenancy)
+	tenants tenantRouting
+
 	// Preemptive summarization
 	preemptive *preemptive.Manager
 
+	// TTL cache for idempotent passthrough endpoints (count_tokens, model listings)
+	passthroughCache *responseCache
+
+	// Response cache pipe: replays answers to identical temperature-0 requests
+	responseCache *responseCache
+
+	// In-flight proxy requests (admin listing and cancellation)
+	inflight *inflightRegistry
+
+	// Recent request bodies as received and as forwarded (nil when disabled)
+	requestCapture *requestCapture
+
+	// Background compression runs and totals for pipes.mode: shadow
+	shadowMode *shadowEvaluator
+
+	// Anonymized request/response fixtures (--record-fixtures; nil when off)
+	fixtures *fixtureRecorder
+
 	// Tool sessions for hybrid tool discovery.
-	toolSessions *ToolSessionStore
-	authMode     *authFallbackStore
+	toolSessions   *ToolSessionStore
+	branches       *branching.Tracker // Conversation branches scoping tool sessions
+	authMode       *authFallbackStore
+	responseChains *responseChainStore     // Responses API response ID → session
+	assistantRuns  *assistantRunStore      // Assistants API runs awaiting their usage
+	messageBatches *messageBatchStore      // Message Batches awaiting their results
+	sessionGC      *sessionstore.Collector // Sweeps the stores above; metrics and force-expiry
+
+	// Conversation → IDs used in the stores above (GET /sessions)
+	sessionIndex *sessionstore.Store[sessionActivity]
 
 	// Provider-specific auth handlers (subscription/fallback)
 	authRegistry *auth.Registry
@@ -205,12 +238,46 @@ type Gateway struct {
 	// Expand context log (in-memory ring buffer for dashboard)
 	expandLog *monitoring.ExpandLog
 
+	// Per-tool compression savings (GET /stats/tools, dashboard leaderboard)
+	toolSavings *monitoring.ToolSavingsTracker
+
+	// Recent per-request compression samples (dashboard diff view)
+	comparisonLog *monitoring.ComparisonLog
+
 	// Search tool log (in-memory ring buffer for dashboard)
 	searchLog *monitoring.SearchLog
 
+	// Fleet telemetry: shipping to a central collector, and acting as one (nil when disabled)
+	telemetryShipper   *fleet.Shipper
+	telemetryCollector *fleet.Collector
+
+	// Forwarding audit log: auth header handling, auth mode, host and target decisions (nil when disabled)
+	auditLog *monitoring.AuditLogger
+
+	// OpenTelemetry span export per request stage (nil when disabled)
+	tracer *tracing.Provider
+
+	// Counts request bodies for telemetry, savings and cost estimates (tokenizer)
+	tokens tokenizer.TokenCounter
+
+	// Per-upstream-host circuit breakers (upstream_circuit_breaker)
+	circuits upstreamCircuits
+
+	// Cached network probe results of GET /health/ready
+	readiness readinessCache
+
+	// Event webhooks: compaction, budget warnings, provider outages (nil when disabled)
+	notifier *notify.Notifier
+
 	// Persistent prompt history (SQLite)
 	promptHistory prompthistory.Store
 
+	// Lifetime counters in the state directory (nil when it is unavailable)
+	selfMetrics *selfMetrics
+
+	// Session state saved across restarts (nil when session_persistence is off)
+	sessionPersistence *sessionPersistence
+
 	// Main conversation stable fingerprint — hash of clean first user message text.
 	// Used to distinguish main conversation from subagents for savings and dashboard.
 	// Stable across requests (injected XML stripped before hashing).
@@ -263,6 +330,9 @@ type Gateway struct {
 // SetVersion stores the build version for the /health endpoint.
 func (g *Gateway) SetVersion(v string) {
 	g.version = v
+	if g.mcp != nil {
+		g.mcp.SetVersion(v)
+	}
 }
 
 // getCurrentSessionID returns the current session ID (thread-safe).
@@ -332,10 +402,6 @@ type StatusReporter interface {
 // New creates a new gateway.
 // configFilePath is optional — if provided, enables hot-reload via the config API.
 func New(cfg *config.Config, configFilePath ...string) *Gateway {
-	st := store.NewMemoryStoreWithDualTTL(store.DefaultOriginalTTL, store.DefaultCompressedTTL)
-	registry := adapters.NewRegistry()
-	r := NewRouter(cfg, st)
-
 	// Initialize logging
 	loggerCfg := monitoring.LoggerConfig{
 		Level:  cfg.Monitoring.LogLevel,
@@ -345,6 +411,16 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 	logger := monitoring.New(loggerCfg)
 	monitoring.Global(loggerCfg)
 
+	// Shadow store: a persistent backend keeps shadow refs across restarts.
+	st, err := store.New(cfg.Store)
+	if err != nil {
+		log.Error().Err(err).Str("type", cfg.Store.Type).Msg("failed to open shadow store, falling back to memory")
+		originalTTL, compressedTTL := cfg.Store.TTLs()
+		st = store.NewMemoryStoreWithDualTTL(originalTTL, compressedTTL).WithLimits(cfg.Store.MaxEntries, cfg.Store.MaxBytes)
+	}
+	registry := adapters.NewRegistry()
+	r := NewRouter(cfg, st)
+
 	// Initialize monitoring components
 	requestLogger := monitoring.NewRequestLogger(logger)
 	metrics := monitoring.NewMetricsCollector()
@@ -352,6 +428,15 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		HighLatencyThreshold: 5 * time.Second,
 	})
 
+	// Build the log redactor; config validation has already checked it.
+	redactor, err := redaction.New(cfg.Monitoring.Redaction)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize log redaction")
+	}
+	writerCfg := cfg.Monitoring.TelemetryWriter
+	writerCfg.Redactor = redactor
+	writerCfg.Retention = cfg.Monitoring.Retention
+
 	// Initialize telemetry
 	tracker, err := monitoring.NewTracker(monitoring.TelemetryConfig{
 		Enabled:                cfg.Monitoring.TelemetryEnabled,
@@ -364,6 +449,8 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		SessionToolsPath:       cfg.Monitoring.SessionToolsPath,
 		SessionStatsPath:       cfg.Monitoring.SessionStatsPath,
 		ExpandContextCallsPath: cfg.Monitoring.ExpandContextCallsPath,
+		ShadowEvalPath:         cfg.Monitoring.ShadowEvalPath,
+		Writer:                 writerCfg,
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("failed to initialize telemetry")
@@ -371,17 +458,19 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 	}
 
 	// Initialize trajectory store (ATIF format) - per-session files in base directory
-	trajectoryBaseDir := cfg.Monitoring.TrajectoryPath
-	if trajectoryBaseDir != "" {
-		// If TrajectoryPath looks like a file path, use its directory
-		if filepath.Ext(trajectoryBaseDir) != "" {
-			trajectoryBaseDir = filepath.Dir(trajectoryBaseDir)
+	trajectoryBaseDir := cfg.Monitoring.TrajectoryDir()
+	if cfg.Monitoring.TrajectoryEnabled && cfg.Monitoring.Retention.Enabled {
+		if removed, err := monitoring.PruneTrajectories(trajectoryBaseDir, cfg.Monitoring.Retention, time.Now()); err != nil {
+			log.Warn().Err(err).Str("dir", trajectoryBaseDir).Msg("failed to prune old trajectories")
+		} else if len(removed) > 0 {
+			log.Info().Int("files", len(removed)).Str("dir", trajectoryBaseDir).Msg("pruned old trajectories")
 		}
 	}
 	trajectoryStore := monitoring.NewTrajectoryStore(monitoring.TrajectoryStoreConfig{
 		Enabled:   cfg.Monitoring.TrajectoryEnabled,
 		BaseDir:   trajectoryBaseDir,
 		AgentName: cfg.Monitoring.AgentName,
+		Redactor:  redactor,
 	})
 
 	// Use config write_timeout for upstream requests
@@ -408,6 +497,13 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		ResponseHeaderTimeout: headerTimeout, // 0 = no timeout (safe for LLM with extended thinking)
 	}
 
+	// server.target: echo answers upstream forwards in-process (no tokens, no network)
+	var upstream http.RoundTripper = transport
+	if cfg.Server.Target == echo.Target {
+		upstream = echo.NewTransport()
+		log.Warn().Msg("upstream target is echo: responses are synthetic and no provider is called")
+	}
+
 	// Initialize AWS Bedrock signer only when explicitly enabled
 	var bedrockSigner *BedrockSigner
 	if cfg.Bedrock.Enabled {
@@ -416,7 +512,9 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 	}
 
 	// Initialize tool session store for hybrid tool discovery
-	toolSessions := NewToolSessionStore(time.Hour) // 1 hour TTL
+	idleTTL := cfg.SessionGC.IdleTTL
+	toolSessions := NewToolSessionStore(idleTTL[config.SessionStoreToolSessions])
+	branches := branching.NewTracker(idleTTL[config.SessionStoreBranches])
 
 	// Initialize provider-specific auth handlers
 	authRegistry, err := auth.SetupRegistry(cfg)
@@ -471,17 +569,29 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		savings:           monitoring.NewSavingsTracker(),
 		aggregator:        aggregator,
 		trajectory:        trajectoryStore,
-		httpClient:        &http.Client{Timeout: clientTimeout, Transport: transport},
+		httpClient:        &http.Client{Timeout: clientTimeout, Transport: upstream},
 		peerHTTPClient:    &http.Client{Timeout: 2 * time.Second},
 		monitorHTTPClient: &http.Client{Timeout: 3 * time.Second},
-		rateLimiter:       newRateLimiter(DefaultRateLimit),
-		costTracker:       costcontrol.NewTracker(cfg.CostControl),
+		rateLimiter:       newRateLimiter(),
+		costTracker:       costcontrol.NewTrackerWithTTL(cfg.CostControl, idleTTL[config.SessionStoreCostSessions]),
+		scheduler:         priority.NewScheduler(cfg.Priority),
+		flags:             featureflags.NewSet(cfg.FeatureFlags),
 		preemptive:        preemptive.NewManager(cfg.ResolvePreemptiveProviderWithLogging(cfg.Monitoring.TelemetryEnabled)),
+		passthroughCache:  newResponseCache(cfg.PassthroughCache.MaxEntries),
+		responseCache:     newResponseCache(responseCacheEntries(cfg.Pipes.ResponseCache)),
+		inflight:          newInflightRegistry(),
 		toolSessions:      toolSessions,
-		authMode:          newAuthFallbackStore(time.Hour),
+		branches:          branches,
+		authMode:          newAuthFallbackStore(idleTTL[config.SessionStoreAuthFallback]),
+		responseChains:    newResponseChainStore(idleTTL[config.SessionStoreResponseChains]),
+		assistantRuns:     newAssistantRunStore(idleTTL[config.SessionStoreAssistantRuns]),
+		messageBatches:    newMessageBatchStore(idleTTL[config.SessionStoreMessageBatches]),
+		sessionIndex:      newSessionIndex(idleTTL[config.SessionStoreCostSessions], cfg.SessionGC.Interval),
 		authRegistry:      authRegistry,
 		bedrockSigner:     bedrockSigner,
 		expandLog:         monitoring.NewExpandLog(),
+		toolSavings:       monitoring.NewToolSavingsTracker(),
+		comparisonLog:     monitoring.NewComparisonLog(),
 		searchLog:         monitoring.NewSearchLog(),
 		promptHistory:     promptHistoryStore,
 		currentSessionID:  currentSessionID,
@@ -493,8 +603,77 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		sessionCollector:  postsession.NewSessionCollector(),
 		monitorHub:        monitorHub,
 		monitorStore:      monitorStore,
+		shadowMode:        newShadowEvaluator(cfg.Pipes.Shadow.MaxConcurrent),
+	}
+
+	g.tenants.update(cfg, st)
+
+	g.mcp = g.newMCPServer()
+	g.mcpSSE = mcp.NewSSE(g.mcp, "/mcp/message")
+
+	if rc := cfg.Monitoring.RequestCapture; rc.Enabled {
+		g.requestCapture = newRequestCapture(rc.MaxRequests, rc.MaxBytes)
+	}
+
+	if exp := cfg.Monitoring.TelemetryExport; exp.Enabled {
+		exp.Redactor = redactor
+		g.telemetryShipper = fleet.NewShipper(exp)
+	}
+	if auditLog, err := monitoring.NewAuditLogger(cfg.Monitoring.Audit, writerCfg); err != nil {
+		log.Error().Err(err).Msg("failed to initialize audit log")
+	} else {
+		g.auditLog = auditLog
+	}
+	if tc, err := tokenizer.New(cfg.Tokenizer); err != nil {
+		log.Error().Err(err).Msg("failed to initialize token counter, using tiktoken")
+		g.tokens = tokenizer.Tiktoken{}
+	} else {
+		g.tokens = tc
+	}
+	if tp, err := tracing.New(cfg.Monitoring.Tracing); err != nil {
+		log.Error().Err(err).Msg("failed to initialize tracing")
+	} else {
+		g.tracer = tp
+	}
+	if dir, err := statedir.DefaultDir(); err == nil {
+		g.selfMetrics = newSelfMetrics(dir, g.savingsTotals)
+	}
+	if n, err := notify.New(cfg.Notifications.NotifyConfig); err != nil {
+		log.Error().Err(err).Msg("failed to initialize notification webhooks")
+	} else {
+		g.notifier = n
+	}
+	if col := cfg.Monitoring.TelemetryCollector; col.Enabled {
+		collectorWriter := cfg.Monitoring.TelemetryWriter
+		collectorWriter.Retention = cfg.Monitoring.Retention
+		collector, err := fleet.NewCollector(col, collectorWriter)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to initialize telemetry collector")
+		} else {
+			g.telemetryCollector = collector
+		}
 	}
 
+	if sp := cfg.SessionPersistence; sp.Enabled {
+		if p, err := newSessionPersistence(sp, g.persistedStores()); err != nil {
+			log.Error().Err(err).Msg("failed to initialize session persistence")
+		} else {
+			g.sessionPersistence = p
+		}
+	}
+
+	// One collector sweeps all per-session stores (idle TTLs are per store)
+	g.sessionGC = sessionstore.NewCollector(cfg.SessionGC.Interval)
+	g.sessionGC.Register(config.SessionStoreToolSessions, g.toolSessions)
+	g.sessionGC.Register(config.SessionStoreBranches, g.branches)
+	g.sessionGC.Register(config.SessionStoreAuthFallback, g.authMode)
+	g.sessionGC.Register(config.SessionStoreCostSessions, g.costTracker)
+	g.sessionGC.Register(config.SessionStorePreemptive, g.preemptive)
+	g.sessionGC.Register(config.SessionStoreResponseChains, g.responseChains)
+	g.sessionGC.Register(config.SessionStoreAssistantRuns, g.assistantRuns)
+	g.sessionGC.Register(config.SessionStoreMessageBatches, g.messageBatches)
+	g.sessionGC.Start()
+
 	// Initialize config reloader (hot-reload support)
 	var cfgPath string
 	if len(configFilePath) > 0 {
@@ -518,6 +697,9 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		if g.router != nil {
 			g.router.UpdateConfig(newCfg)
 		}
+		g.tenants.update(newCfg, g.store)
+		g.scheduler.UpdateConfig(newCfg.Priority)
+		g.flags.UpdateConfig(newCfg.FeatureFlags)
 		if g.preemptive != nil {
 			g.preemptive.UpdateConfig(newCfg.ResolvePreemptiveProviderWithLogging(newCfg.Monitoring.TelemetryEnabled))
 		}
@@ -546,6 +728,7 @@ func New(cfg *config.Config, configFilePath ...string) *Gateway {
 		WriteTimeout:   serverWriteTimeout,
 		IdleTimeout:    120 * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		Protocols:      serverProtocols(cfg.Server),
 	}
 
 	// Try to start centralized dashboard server on fixed port 18080.
@@ -695,6 +878,9 @@ func (g *Gateway) resetForNewSession() {
 	g.mainConvSet = false
 	g.mainConvMu.Unlock()
 
+	// Persist lifetime counters before the in-memory ones restart at zero
+	g.selfMetrics.flush()
+
 	// Reset in-memory savings tracker
 	if g.savings != nil {
 		g.savings.Reset()
@@ -715,6 +901,16 @@ func (g *Gateway) resetForNewSession() {
 		g.expandLog.Reset()
 	}
 
+	// Reset per-tool savings
+	if g.toolSavings != nil {
+		g.toolSavings.Reset()
+	}
+
+	// Reset compression diff samples
+	if g.comparisonLog != nil {
+		g.comparisonLog.Reset()
+	}
+
 	// Reset search tool log
 	if g.searchLog != nil {
 		g.searchLog.Reset()
@@ -730,15 +926,31 @@ func (g *Gateway) resetForNewSession() {
 		ms.Reset()
 	}
 
+	// Reset passthrough response cache
+	if g.passthroughCache != nil {
+		g.passthroughCache.Reset()
+	}
+
+	// Reset response cache pipe
+	if g.responseCache != nil {
+		g.responseCache.Reset()
+	}
+
 	// Reset tool session store (deferred/expanded tools from previous sessions)
 	if g.toolSessions != nil {
 		g.toolSessions.Reset()
 	}
+	if g.branches != nil {
+		g.branches.Reset()
+	}
 
 	// Reset auth fallback state
 	if g.authMode != nil {
 		g.authMode.Reset()
 	}
+	if g.sessionIndex != nil {
+		g.sessionIndex.Reset()
+	}
 
 	log.Debug().Msg("all session variables reset to 0")
 }
@@ -746,27 +958,16 @@ func (g *Gateway) resetForNewSession() {
 // setupRoutes configures the HTTP routes for the gateway proxy server.
 // Dashboard routes are NOT registered here — they run on the dedicated dashboard port (18080).
 func (g *Gateway) setupRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/health", g.handleHealth)
-	mux.HandleFunc("/expand", g.handleExpand)
-	// API endpoints still available on proxy port for internal use (e.g., /savings slash command)
-	mux.HandleFunc("/api/dashboard", g.handleDashboardAPI)
-	mux.HandleFunc("/api/savings", g.handleSavingsAPI)
-	mux.HandleFunc("/api/account", g.handleAccountAPI)
-	mux.HandleFunc("/api/config", g.handleConfigAPI)
-	mux.HandleFunc("/api/prompts", g.handlePromptsAPI)
-	mux.HandleFunc("/api/prompts/erase", g.handleErasePrompts)
-	mux.HandleFunc("/api/prompts/", g.handleDeletePrompt)
-	mux.HandleFunc("/api/session", g.handleDeleteSession)
-	mux.HandleFunc("/api/compress/", g.handleCompressAPINotFound)
-	mux.HandleFunc("/stats", g.handleStats)
-	mux.HandleFunc("/v1/models", g.handleModels)
+	for _, rt := range g.managedRoutes() {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
 
 	// Session monitoring dashboard
 	monitorHandlers := dashboard.NewHandlers(g.monitorStore, g.monitorHub)
 	monitorHandlers.SetPort(g.config.Server.Port)
 	monitorHandlers.RegisterRoutes(mux)
 
-	mux.HandleFunc("/", g.handleProxy)
+	mux.HandleFunc("/", g.requireClientAuth(g.handleProxy))
 }
 
 // setupDashboardRoutes configures routes for the centralized dashboard server on port 18080.
@@ -783,6 +984,8 @@ func (g *Gateway) setupDashboardRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/monitor", g.handleAggregatedMonitorAPI)
 	mux.HandleFunc("/api/monitor/rename", g.handleRenameInstance)
 	mux.HandleFunc("/api/instance/config", g.handleInstanceConfigProxy)
+	mux.HandleFunc("/api/instance/compressions", g.handleInstanceCompressionsProxy)
+	mux.HandleFunc("/api/instance/sessions", g.handleInstanceSessionsProxy)
 	mux.HandleFunc("/api/focus", g.handleFocusTerminal)
 	mux.HandleFunc("/dashboard", g.handleDashboard)
 	mux.HandleFunc("/dashboard/", g.handleDashboard)
@@ -813,13 +1016,21 @@ func (g *Gateway) handleCompressAPINotFound(w http.ResponseWriter, r *http.Reque
 
 // Start starts the gateway.
 func (g *Gateway) Start() error {
-	log.Info().Int("port", g.config.Server.Port).Msg("Context Gateway starting")
+	log.Info().Int("port", g.config.Server.Port).Bool("tls", g.config.Server.TLS.Enabled()).Msg("Context Gateway starting")
 	if g.dashboardStarted {
 		log.Info().
 			Int("port", config.DefaultDashboardPort).
 			Str("dashboard", fmt.Sprintf("http://localhost:%d/dashboard/", config.DefaultDashboardPort)).
 			Msg("dashboard available")
 	}
+	tlsCfg, err := serverTLSConfig(g.config)
+	if err != nil {
+		return err
+	}
+	if tlsCfg != nil {
+		g.server.TLSConfig = tlsCfg
+		return g.server.ListenAndServeTLS("", "")
+	}
 	return g.server.ListenAndServe()
 }
 
@@ -842,13 +1053,42 @@ func (g *Gateway) Shutdown(ctx context.Context) error {
 		g.watchCancel()
 	}
 
+	// Stop the gRPC API (in-flight RPCs finish, bounded by ctx)
+	g.stopGRPC(ctx)
+
+	// End open MCP event streams so the HTTP server can drain
+	if g.mcpSSE != nil {
+		g.mcpSSE.Close()
+	}
+
 	// Stop cleanup goroutines
+	if g.sessionGC != nil {
+		g.sessionGC.Stop()
+	}
 	if g.rateLimiter != nil {
 		g.rateLimiter.Stop()
 	}
 	if g.authMode != nil {
 		g.authMode.Stop()
 	}
+	if g.sessionIndex != nil {
+		g.sessionIndex.Stop()
+	}
+	if g.toolSessions != nil {
+		g.toolSessions.Stop()
+	}
+	if g.branches != nil {
+		g.branches.Stop()
+	}
+	if g.responseChains != nil {
+		g.responseChains.Stop()
+	}
+	if g.assistantRuns != nil {
+		g.assistantRuns.Stop()
+	}
+	if g.messageBatches != nil {
+		g.messageBatches.Stop()
+	}
 	if g.authRegistry != nil {
 		g.authRegistry.Stop()
 	}
@@ -890,11 +1130,51 @@ func (g *Gateway) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	// Let shadow mode runs log their results (bounded by ctx)
+	g.shadowMode.wait(ctx)
+
+	// Stop custom pipe plugins and close pipe logs
+	if g.router != nil {
+		if err := g.router.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close router")
+		}
+	}
+	g.tenants.close()
+
 	// Close telemetry tracker
 	if g.tracker != nil {
 		_ = g.tracker.Close()
 	}
 
+	// Deliver pending telemetry batches (bounded by ctx), then close the collector output
+	if err := g.telemetryShipper.Close(ctx); err != nil {
+		log.Warn().Err(err).Msg("telemetry export incomplete at shutdown")
+	}
+	if err := g.telemetryCollector.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close telemetry collector")
+	}
+
+	// Drain the audit log
+	if err := g.auditLog.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close audit log")
+	}
+
+	// Export buffered spans (bounded by ctx)
+	if err := g.tracer.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("span export incomplete at shutdown")
+	}
+
+	// Deliver queued notifications (bounded by ctx)
+	if err := g.notifier.Close(ctx); err != nil {
+		log.Warn().Err(err).Msg("notifications not delivered at shutdown")
+	}
+
+	// Persist lifetime counters
+	g.selfMetrics.Close()
+
+	// Save session state for the next start
+	g.sessionPersistence.Close()
+
 	// Close prompt history store
 	if g.promptHistory != nil {
 		if err := g.promptHistory.Close(); err != nil {
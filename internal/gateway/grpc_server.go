@@ -0,0 +1,273 @@
+// grpc_server.go - gRPC interface to the shadow store and compression pipes.
+//
+// `serve --grpc-addr` starts a gRPC server next to the HTTP proxy so other
+// services can call Expand, Compress, FilterTools and Summarize directly
+// (api/gateway/v1/gateway.proto). Each RPC runs one component on a
+// provider-format request body and returns the result; nothing is forwarded
+// upstream. Access follows the admin API: loopback peers only, or a
+// server.admin_token sent as "authorization: Bearer <token>" metadata.
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	gatewayv1 "github.com/compresr/context-gateway/api/gateway/v1"
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// grpcCredentialHeaders are the metadata keys passed to pipes as provider
+// credentials (external_provider compression, summarizer auth).
+var grpcCredentialHeaders = []string{"x-api-key", "api-key", "authorization", "anthropic-beta", HeaderTargetURL}
+
+// ServeGRPC serves the gRPC API on lis in the background until Shutdown.
+func (g *Gateway) ServeGRPC(lis net.Listener) {
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(MaxRequestBodySize),
+		grpc.MaxSendMsgSize(MaxRequestBodySize),
+		grpc.UnaryInterceptor(g.grpcAuthInterceptor),
+	)
+	gatewayv1.RegisterContextGatewayServer(srv, &grpcService{g: g})
+
+	g.grpcMu.Lock()
+	g.grpcServer = srv
+	g.grpcMu.Unlock()
+
+	log.Info().Str("addr", lis.Addr().String()).Msg("gRPC API listening")
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Error().Err(err).Msg("gRPC server stopped")
+		}
+	}()
+}
+
+// stopGRPC lets in-flight RPCs finish, then stops the server; on ctx expiry
+// remaining RPCs are cancelled.
+func (g *Gateway) stopGRPC(ctx context.Context) {
+	g.grpcMu.Lock()
+	srv := g.grpcServer
+	g.grpcServer = nil
+	g.grpcMu.Unlock()
+	if srv == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// grpcAuthInterceptor applies the admin API's access rule to every RPC.
+func (g *Gateway) grpcAuthInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !g.grpcAuthorized(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return handler(ctx, req)
+}
+
+func (g *Gateway) grpcAuthorized(ctx context.Context) bool {
+	want := g.cfg().Server.AdminToken
+	if want == "" {
+		p, ok := peer.FromContext(ctx)
+		return ok && p.Addr != nil && isLoopback(p.Addr.String())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(v, "Bearer "); ok && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcService implements gatewayv1.ContextGatewayServer.
+type grpcService struct {
+	gatewayv1.UnimplementedContextGatewayServer
+	g *Gateway
+}
+
+func (s *grpcService) Expand(ctx context.Context, req *gatewayv1.ExpandRequest) (*gatewayv1.ExpandResponse, error) {
+	if len(req.GetId()) == 0 || len(req.GetId()) > 64 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	data, ok := s.g.expandShadow(grpcRequestID(ctx), req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &gatewayv1.ExpandResponse{Id: req.GetId(), Content: data}, nil
+}
+
+func (s *grpcService) Compress(ctx context.Context, req *gatewayv1.CompressRequest) (*gatewayv1.CompressResponse, error) {
+	if !s.g.cfg().Pipes.ToolOutput.Enabled {
+		return nil, status.Error(codes.FailedPrecondition, "tool_output pipe is disabled")
+	}
+	pipeCtx, err := s.g.grpcPipelineContext(ctx, req.GetPath(), req.GetBody(), req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.g.runGRPCPipe(pipeCtx, pipes.PipeNameToolOutput)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gatewayv1.CompressResponse{Body: body}
+	for _, tc := range pipeCtx.ToolOutputCompressions {
+		resp.Compressions = append(resp.Compressions, &gatewayv1.ToolOutputCompression{
+			ToolName:         tc.ToolName,
+			ToolCallId:       tc.ToolCallID,
+			ShadowId:         tc.ShadowID,
+			OriginalTokens:   int32(tc.OriginalTokens),   // #nosec G115 -- token counts of a bounded body
+			CompressedTokens: int32(tc.CompressedTokens), // #nosec G115 -- token counts of a bounded body
+			CacheHit:         tc.CacheHit,
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcService) FilterTools(ctx context.Context, req *gatewayv1.FilterToolsRequest) (*gatewayv1.FilterToolsResponse, error) {
+	if !s.g.cfg().Pipes.ToolDiscovery.Enabled {
+		return nil, status.Error(codes.FailedPrecondition, "tool_discovery pipe is disabled")
+	}
+	pipeCtx, err := s.g.grpcPipelineContext(ctx, req.GetPath(), req.GetBody(), req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.g.runGRPCPipe(pipeCtx, pipes.PipeNameToolDiscovery)
+	if err != nil {
+		return nil, err
+	}
+	// Deferred tools stay searchable for the session, as on the proxy path.
+	if s.g.toolSessions != nil && pipeCtx.ToolSessionID != "" && len(pipeCtx.DeferredTools) > 0 {
+		s.g.toolSessions.StoreDeferred(pipeCtx.ToolSessionID, pipeCtx.DeferredTools)
+	}
+	if s.g.toolSessions != nil && pipeCtx.ToolSessionID != "" && pipeCtx.ToolServerStats != nil {
+		s.g.toolSessions.StoreServerStats(pipeCtx.ToolSessionID, pipeCtx.ToolServerStats)
+	}
+	resp := &gatewayv1.FilterToolsResponse{
+		Body:              body,
+		OriginalToolCount: int32(pipeCtx.OriginalToolCount), // #nosec G115 -- tool count of a bounded body
+		KeptToolCount:     int32(pipeCtx.KeptToolCount),     // #nosec G115 -- tool count of a bounded body
+	}
+	for _, t := range pipeCtx.DeferredTools {
+		resp.DeferredTools = append(resp.DeferredTools, t.ToolName)
+	}
+	return resp, nil
+}
+
+func (s *grpcService) Summarize(ctx context.Context, req *gatewayv1.SummarizeRequest) (*gatewayv1.SummarizeResponse, error) {
+	if len(req.GetBody()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "body is required")
+	}
+	model := req.GetModel()
+	if model == "" {
+		model = gjson.GetBytes(req.GetBody(), "model").String()
+	}
+	out, err := s.g.preemptive.Summarize(ctx, req.GetBody(), model, int(req.GetKeepRecentTokens()), grpcCapturedAuth(ctx, s.g.cfg().Server.AdminToken))
+	switch {
+	case errors.Is(err, preemptive.ErrDisabled):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		log.Warn().Err(err).Msg("grpc: summarize failed")
+		return nil, status.Error(codes.Internal, "summarization failed")
+	}
+	return &gatewayv1.SummarizeResponse{
+		Summary:             out.Summary,
+		SummaryTokens:       int32(out.SummaryTokens),       // #nosec G115 -- bounded by the summarizer's max tokens
+		LastSummarizedIndex: int32(out.LastSummarizedIndex), // #nosec G115 -- message index of a bounded body
+		InputTokens:         int32(out.InputTokens),         // #nosec G115 -- token counts of a bounded body
+		OutputTokens:        int32(out.OutputTokens),        // #nosec G115 -- token counts of a bounded body
+	}, nil
+}
+
+// grpcPipelineContext builds the pipe context for a body sent over gRPC.
+func (g *Gateway) grpcPipelineContext(ctx context.Context, path string, body []byte, sessionID string) (*PipelineContext, error) {
+	if len(body) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "body is required")
+	}
+	if path == "" {
+		path = "/v1/messages"
+	}
+	header := grpcHeader(ctx, g.cfg().Server.AdminToken)
+	provider, adapter := adapters.IdentifyAndGetAdapter(g.registry, path, header)
+	if adapter == nil {
+		return nil, status.Error(codes.InvalidArgument, "unsupported request format")
+	}
+
+	pipeCtx := NewPipelineContext(provider, adapter, body, path)
+	pipeCtx.RequestCtx = ctx
+	pipeCtx.RequestID = grpcRequestID(ctx)
+	pipeCtx.CapturedAuth = authtypes.CaptureFromHeaders(header)
+	pipeCtx.Model = requestModel(adapter, body, path)
+	pipeCtx.TargetModel = pipeCtx.Model
+	if sessionID != "" {
+		pipeCtx.SessionID = sessionID
+		pipeCtx.ToolSessionID = sessionID
+		if g.toolSessions != nil {
+			pipeCtx.ExpandedTools = g.toolSessions.GetExpanded(sessionID)
+		}
+	}
+	return pipeCtx, nil
+}
+
+// runGRPCPipe runs one pipe and maps a pipe failure to an RPC error.
+func (g *Gateway) runGRPCPipe(pipeCtx *PipelineContext, name string) ([]byte, error) {
+	body, err := g.router.ProcessPipe(pipeCtx, name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if pipeCtx.PipeFailed {
+		return nil, status.Errorf(codes.Internal, "%s pipe failed", name)
+	}
+	return body, nil
+}
+
+// grpcHeader copies the format-detection and credential metadata of an RPC
+// into an http.Header. With an admin token configured, "authorization"
+// carries that token and is not passed on as a provider credential.
+func grpcHeader(ctx context.Context, adminToken string) http.Header {
+	header := make(http.Header)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range append([]string{HeaderProvider, "anthropic-version"}, grpcCredentialHeaders...) {
+		if key == "authorization" && adminToken != "" {
+			continue
+		}
+		for _, v := range md.Get(key) {
+			header.Add(key, v)
+		}
+	}
+	return header
+}
+
+func grpcCapturedAuth(ctx context.Context, adminToken string) authtypes.CapturedAuth {
+	return authtypes.CaptureFromHeaders(grpcHeader(ctx, adminToken))
+}
+
+// grpcRequestID returns the caller's X-Request-ID metadata, or a new ID.
+func grpcRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(HeaderRequestID); len(ids) > 0 && ids[0] != "" {
+		return ids[0]
+	}
+	return uuid.New().String()
+}
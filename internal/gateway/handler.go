@@ -16,16 +16,22 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/branching"
 	"github.com/compresr/context-gateway/internal/config"
 	"github.com/compresr/context-gateway/internal/dashboard"
+	"github.com/compresr/context-gateway/internal/featureflags"
 	"github.com/compresr/context-gateway/internal/monitoring"
 	phantom_tools "github.com/compresr/context-gateway/internal/phantom_tools"
 	"github.com/compresr/context-gateway/internal/preemptive"
 	"github.com/compresr/context-gateway/internal/prompthistory"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/internal/tenancy"
 	"github.com/compresr/context-gateway/internal/tokenizer"
+	"github.com/compresr/context-gateway/internal/tracing"
 	"github.com/compresr/context-gateway/internal/utils"
 )
 
@@ -289,6 +295,8 @@ type forwardAuthMeta struct {
 	InitialMode   string
 	EffectiveMode string
 	FallbackUsed  bool
+	Retries       int    // Attempts retried by upstream_retry
+	ModelFallback string // Model sent after the requested one was overloaded
 }
 
 func mergeForwardAuthMeta(dst *forwardAuthMeta, src forwardAuthMeta) {
@@ -304,28 +312,37 @@ func mergeForwardAuthMeta(dst *forwardAuthMeta, src forwardAuthMeta) {
 	if src.FallbackUsed {
 		dst.FallbackUsed = true
 	}
+	dst.Retries += src.Retries
+	if src.ModelFallback != "" {
+		dst.ModelFallback = src.ModelFallback
+	}
 }
 
-// sanitizeModelName strips provider prefixes from model names in request body.
-// Uses sjson for byte-level replacement to preserve JSON field ordering and KV-cache prefix.
-// Handles formats like "anthropic/claude-3" -> "claude-3", "openai/gpt-4" -> "gpt-4"
-func sanitizeModelName(body []byte) []byte {
+// rewriteModel applies model_rewrite to the model named in body, e.g.
+// "anthropic/claude-3" -> "claude-3". Bedrock model IDs have their own format
+// (e.g. "anthropic.claude-3-5-sonnet") and LiteLLM routes by the prefix, so
+// neither is rewritten. OpenRouter also routes by the prefix: its requests get
+// configured rules only, never the default prefix stripping. Uses sjson for
+// byte-level replacement to preserve JSON field ordering and KV-cache prefix.
+func (g *Gateway) rewriteModel(path string, provider adapters.Provider, body []byte) []byte {
+	cfg := g.cfg()
+	if g.isBedrockRequest(path) || cfg.LiteLLM.Enabled {
+		return body
+	}
+	if provider == adapters.ProviderOpenRouter && cfg.ModelRewrite.Rules == nil {
+		return body
+	}
 	model := gjson.GetBytes(body, "model").String()
-	if model == "" {
+	to, ok := cfg.ModelRewrite.Rewrite(model)
+	if !ok {
 		return body
 	}
-
-	for _, prefix := range []string{"anthropic/", "openai/", "google/", "meta/"} {
-		if strings.HasPrefix(model, prefix) {
-			stripped := strings.TrimPrefix(model, prefix)
-			if result, err := sjson.SetBytes(body, "model", stripped); err == nil {
-				return result
-			}
-			break
-		}
+	result, err := sjson.SetBytes(body, "model", to)
+	if err != nil {
+		return body
 	}
-
-	return body
+	log.Debug().Str("model", model).Str("rewritten", to).Msg("model_rewrite: model rewritten")
+	return result
 }
 
 // writeError writes a JSON error response.
@@ -352,6 +369,10 @@ func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
 	} else {
 		_ = g.store.Delete("_health_")
 	}
+	// Open upstream circuits are reported, not treated as gateway ill health.
+	if g.cfg().UpstreamCircuitBreaker.Enabled {
+		health["upstream_circuits"] = g.circuitHealth()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if health["status"] != "ok" {
@@ -387,9 +408,28 @@ func (g *Gateway) handleExpand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, ok := g.store.Get(req.ID)
+	data, ok := g.expandShadow(g.getRequestID(r), req.ID)
+	if !ok {
+		g.writeError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"id": req.ID, "content": data}); err != nil {
+		log.Warn().Err(err).Msg("handleExpand: failed to encode JSON response")
+	}
+}
+
+// expandShadow looks up a shadow reference and records the expand event.
+func (g *Gateway) expandShadow(requestID, id string) (string, bool) {
+	data, ok := g.store.Get(id)
+	if !ok {
+		if r, isRecorder := g.store.(store.MissRecorder); isRecorder {
+			log.Debug().Str("shadow_id", id).Str("reason", r.RecordExpandMiss(id)).Msg("expand: shadow ID not found in store")
+		}
+	}
 	g.tracker.RecordExpand(&monitoring.ExpandEvent{
-		Timestamp: time.Now(), ShadowRefID: req.ID, Found: ok, Success: ok,
+		Timestamp: time.Now(), ShadowRefID: id, Found: ok, Success: ok,
 	})
 	if g.expandLog != nil {
 		preview := data
@@ -398,24 +438,15 @@ func (g *Gateway) handleExpand(w http.ResponseWriter, r *http.Request) {
 		}
 		g.expandLog.Record(monitoring.ExpandLogEntry{
 			Timestamp:      time.Now(),
-			RequestID:      g.getRequestID(r),
-			ShadowID:       req.ID,
+			RequestID:      requestID,
+			ShadowID:       id,
 			Found:          ok,
 			ContentPreview: preview,
 			ContentLength:  len(data),
 			ContentTokens:  tokenizer.CountTokens(data),
 		})
 	}
-
-	if !ok {
-		g.writeError(w, "not found", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"id": req.ID, "content": data}); err != nil {
-		log.Warn().Err(err).Msg("handleExpand: failed to encode JSON response")
-	}
+	return data, ok
 }
 
 // detectClientAgent identifies which AI client is making a request from its
@@ -442,39 +473,57 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	requestID := g.getRequestID(r)
 
-	// Validate request
-	if r.Method != http.MethodPost {
-		g.alerts.FlagInvalidRequest(requestID, "method not allowed", nil)
-		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	// Tenancy: every path below, passthrough included, runs as the tenant.
+	r, ok := g.resolveTenant(w, r, requestID)
+	if !ok {
 		return
 	}
+	tenant := tenancy.FromContext(r.Context())
 
-	// Non-LLM endpoints (telemetry, analytics, event_logging) forward to upstream unchanged
-	// These SDK requests pass through transparently - client unaware of proxy
+	// Token counting counts the compressed body (see count_tokens.go). Other
+	// non-LLM endpoints (telemetry, analytics, event_logging) forward to upstream
+	// unchanged. These SDK requests pass through transparently - client unaware
+	// of proxy.
+	if r.URL.Path == countTokensPath {
+		g.handleCountTokens(w, r)
+		return
+	}
+	// Message Batches run offline; items are accounted from their results
+	// (see message_batches.go).
+	if isMessageBatchesPath(r.URL.Path) {
+		g.handleMessageBatches(w, r)
+		return
+	}
 	if g.isNonLLMEndpoint(r.URL.Path) {
-		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			g.writeError(w, "failed to read request", http.StatusBadRequest)
-			return
-		}
+		g.handlePassthrough(w, r)
+		return
+	}
 
-		// Forward to upstream unchanged
-		resp, _, err := g.forwardPassthrough(r.Context(), r, body)
-		if err != nil {
-			log.Debug().Err(err).Str("path", r.URL.Path).Msg("passthrough failed")
-			g.writeError(w, "upstream request failed", http.StatusBadGateway)
-			return
-		}
-		defer func() { _ = resp.Body.Close() }()
+	// OpenAI Assistants API: runs execute server-side (see assistants.go).
+	if isAssistantsPath(r.URL.Path) {
+		g.handleAssistants(w, r)
+		return
+	}
 
-		responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
-		copyHeaders(w, resp.Header)
-		w.WriteHeader(resp.StatusCode)
-		_, _ = w.Write(responseBody)
+	// OpenAI Realtime sessions arrive as WebSocket upgrades (see realtime.go).
+	if isWebSocketUpgrade(r) {
+		g.handleRealtime(w, r)
 		return
 	}
 
+	// Validate request
+	if r.Method != http.MethodPost {
+		g.alerts.FlagInvalidRequest(requestID, "method not allowed", nil)
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Root span for the request's stages; a client traceparent nests it in the agent's trace.
+	spanCtx, span := g.tracer.StartRequest(r, requestID)
+	defer span.End()
+	r = r.WithContext(spanCtx)
+
 	// Lazy session initialization: create session directory on first actual LLM request.
 	// This prevents empty session folders when gateway starts but receives no LLM traffic.
 	g.EnsureSession()
@@ -484,6 +533,7 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		g.alerts.FlagInvalidRequest(requestID, "failed to read body", nil)
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
 		g.writeError(w, "failed to read request", http.StatusBadRequest)
 		return
 	}
@@ -492,21 +542,89 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	provider, adapter := adapters.IdentifyAndGetAdapter(g.registry, r.URL.Path, r.Header)
 	if adapter == nil {
 		g.alerts.FlagInvalidRequest(requestID, "unsupported format", nil)
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
 		g.writeError(w, "unsupported request format", http.StatusBadRequest)
 		return
 	}
+	span.SetAttributes(attribute.String("gateway.provider", adapter.Name()))
+	if tenant != nil {
+		span.SetAttributes(attribute.String("gateway.tenant", tenant.Name))
+	}
+	if client := clientLabelFrom(r.Context()); client != "" {
+		span.SetAttributes(attribute.String("gateway.client", client))
+	}
+
+	// Pin/validate provider API version headers before anything parses the body.
+	if err := g.negotiateAPIVersions(requestID, provider, r.Header); err != nil {
+		g.alerts.FlagInvalidRequest(requestID, "unsupported API version", nil)
+		g.recordError(monitoring.ErrorCodeUnsupportedVersion)
+		g.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Key the response cache on the body as the client sent it.
+	responseCacheKey := g.responseCacheKey(r, body)
 
 	// Build pipeline context (no universal parsing needed)
 	pipeCtx := NewPipelineContext(provider, adapter, body, r.URL.Path)
+	pipeCtx.Tenant = tenancy.NameOf(tenant)
+	pipeCtx.Client = clientLabelFrom(r.Context())
+
+	// Track in flight so the admin API can list and cancel this request.
+	inflight, reqCtx, done := g.inflight.register(r.Context(), requestID, r.URL.Path, adapter.Name(), g.isStreamingRequest(r.URL.Path, body))
+	defer done()
+	captured := g.requestCapture.start(requestID, r.Method, r.URL.Path, adapter.Name(), body)
+	r = r.WithContext(withCapturedRequest(reqCtx, captured))
+	pipeCtx.inflight = inflight
 	pipeCtx.RequestCtx = r.Context()
 	pipeCtx.RequestID = requestID
+
+	// Responses API stored conversations carry no history: take the session
+	// from the response chain instead of hashing the first user message.
+	var chain responseChain
+	if g.responseChains != nil {
+		chain, pipeCtx.StoredConversation = g.responseChains.resolve(body)
+	}
+
 	// Initialize tool session for hybrid tool discovery
 	// Use canonical session ID from preemptive package (hash of first user message)
-	if g.toolSessions != nil && g.cfg().Pipes.ToolDiscovery.Enabled {
+	if g.toolSessions != nil && g.cfgFor(pipeCtx.Tenant).Pipes.ToolDiscovery.Enabled {
 		// Use clean first-user-message hash so session ID is stable across turns
 		// even when phantom tools are injected (injected XML changes full-body hash).
-		sessionID := preemptive.ComputeSessionIDFromClean(pipeCtx.Classification.FirstUserCleanContent)
-		if sessionID != "" {
+		sessionID := tenant.SessionID(preemptive.ComputeSessionIDFromClean(pipeCtx.Classification.FirstUserCleanContent))
+		if pipeCtx.StoredConversation {
+			sessionID = chain.ToolSessionID
+		}
+		if sessionID != "" && pipeCtx.StoredConversation {
+			// History is upstream: no prefix to branch on, no inbound rewrite needed.
+			pipeCtx.ToolSessionID = sessionID
+			pipeCtx.SessionID = sessionID
+			if cached, ok := g.toolSessions.GetIsMainAgent(sessionID); ok {
+				pipeCtx.Classification.IsMainAgent = cached
+			}
+			pipeCtx.ExpandedTools = g.toolSessions.GetExpanded(sessionID)
+		} else if sessionID != "" {
+			// Scope tool state to the conversation branch: if the client edited an
+			// earlier message, expansions made on the replaced branch must not leak.
+			hashes := branching.HashBody(body)
+			branch := g.branches.Resolve(sessionID, hashes)
+			if branch.Forked {
+				g.toolSessions.Fork(branch.ParentSessionID, branch.SessionID, branch.ForkIndex)
+				log.Info().
+					Str("request_id", requestID).
+					Str("parent_session", branch.ParentSessionID).
+					Str("session_id", branch.SessionID).
+					Int("fork_index", branch.ForkIndex).
+					Msg("Conversation branched")
+			}
+			if branch.EvictedSessionID != "" {
+				// Branches are capped per conversation; drop the displaced branch's tools now
+				// rather than holding them until the idle TTL.
+				g.toolSessions.Expire(branch.EvictedSessionID)
+			}
+			sessionID = branch.SessionID
+			g.toolSessions.SetMessageCount(sessionID, len(hashes))
+
 			pipeCtx.ToolSessionID = sessionID
 			pipeCtx.SessionID = sessionID // Also set for tool discovery pipe caching
 			// BUG-027: Cache isMainAgent per session so turn 2+ doesn't mis-classify
@@ -556,9 +674,10 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract model for preemptive summarization and cost-based compression decisions
-	model := adapter.ExtractModel(body)
+	model := requestModel(adapter, body, r.URL.Path)
 	pipeCtx.Model = model
 	pipeCtx.TargetModel = model // Also pass to pipe context for cost-based skip logic
+	pipeCtx.SessionTags = parseSessionTags(r.Header)
 
 	// Record session event for post-session CLAUDE.md updates
 	if g.sessionCollector != nil {
@@ -624,7 +743,7 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 		if report == "" {
 			report = "No savings data available"
 		}
-		streaming := g.isStreamingRequest(body)
+		streaming := g.isStreamingRequest(r.URL.Path, body)
 		syntheticResp := monitoring.BuildSavingsResponse(report, model, streaming)
 		log.Info().
 			Str("request_id", requestID).
@@ -632,13 +751,18 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 			Int("response_size", len(syntheticResp)).
 			Msg("Returning /savings report (instant!)")
 
-		if streaming {
+		if _, ok := bedrockInvokePath(r.URL.Path); ok {
+			syntheticResp = anthropicSSEToEventStream(syntheticResp)
+			w.Header().Set("Content-Type", EventStreamContentType)
+		} else if streaming {
 			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		if streaming {
 			w.Header().Set("Cache-Control", "no-cache")
 			w.Header().Set("Connection", "keep-alive")
 			w.Header().Set("X-Accel-Buffering", "no")
-		} else {
-			w.Header().Set("Content-Type", "application/json")
 		}
 		w.Header().Set("X-Synthetic-Response", "true")
 		w.Header().Set("X-Savings-Report", "true")
@@ -652,9 +776,23 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Replay an identical deterministic request without forwarding it, or
+	// record this response for the next one (see response_cache.go).
+	if responseCacheKey != "" {
+		if g.serveCachedResponse(w, responseCacheKey, requestID) {
+			return
+		}
+		rec := g.newResponseRecorder(w)
+		defer g.storeCachedResponse(r.Context(), responseCacheKey, rec)
+		w = rec
+	}
+
 	// Compute a conversation-level session ID (hash of first user message).
 	// This is the single source of truth used by cost tracker, prompt history, and trajectory.
 	conversationSessionID := preemptive.ComputeSessionID(body)
+	if pipeCtx.StoredConversation {
+		conversationSessionID = chain.SessionID
+	}
 	if conversationSessionID == "" {
 		// Fallback to folder-based session ID, then "default"
 		conversationSessionID = g.getCurrentSessionID()
@@ -663,7 +801,17 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 		// Generate a unique anonymous ID to keep sessions distinct in monitoring
 		conversationSessionID = fmt.Sprintf("anon-%s", uuid.New().String()[:8])
 	}
+	if !pipeCtx.StoredConversation {
+		// Tenants never share a session, even with identical first messages.
+		conversationSessionID = tenant.SessionID(conversationSessionID)
+	}
 	pipeCtx.CostSessionID = conversationSessionID
+	pipeCtx.inflight.setSession(conversationSessionID, model)
+	pipeCtx.Flags = g.flags.Evaluate(featureflags.Target{
+		SessionID: conversationSessionID,
+		User:      r.Header.Get(featureflags.HeaderUser),
+		Tags:      pipeCtx.SessionTags,
+	})
 
 	// Compute stable conversation fingerprint from clean first user message text.
 	// Unlike CostSessionID (which hashes the full message including injected XML),
@@ -679,14 +827,42 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	g.setMainConversationOnce(stableFingerprint)
 
 	// Cost control: budget check (before forwarding)
+	var budgetUtilization float64
 	if g.costTracker != nil {
-		budget := g.costTracker.CheckBudget(conversationSessionID)
+		pipeCtx.BudgetScopes = g.costTracker.ScopeKeys(r.Header, pipeCtx.SessionTags, pipeCtx.Tenant, pipeCtx.Client)
+		budget := g.costTracker.CheckBudget(conversationSessionID, pipeCtx.BudgetScopes...)
+		if cc := g.costTracker.Config(); cc.Enabled && !cc.Simulating() {
+			budgetUtilization = budget.Utilization()
+		}
+		if budget.Simulated {
+			g.costTracker.RecordSimulatedRejection(conversationSessionID, budget)
+			w.Header().Set(HeaderBudgetSimulated, budget.Reason)
+			log.Warn().
+				Str("request_id", requestID).
+				Str("session_id", conversationSessionID).
+				Str("reason", budget.Reason).
+				Msg("budget: request would have been rejected (simulate mode)")
+		}
+		g.notifyBudget(conversationSessionID, budget)
 		if !budget.Allowed {
+			g.recordError(monitoring.ErrorCodeBudgetExceeded)
+			g.notifyBudgetExceeded(conversationSessionID, budget)
 			g.returnBudgetExceededResponse(w, adapter.Name(), budget, conversationSessionID)
 			return
 		}
 	}
 
+	// Priority classes: shed background/batch near budget caps, then wait for a slot
+	release, admitted := g.admitByPriority(w, r, pipeCtx, requestID, budgetUtilization)
+	if !admitted {
+		return
+	}
+	defer release()
+	// Every forward of this request, phantom-loop follow-ups included, counts
+	// toward the session's egress.
+	r = r.WithContext(withEgressSession(r.Context(), conversationSessionID))
+	pipeCtx.RequestCtx = r.Context()
+
 	// Capture original body length before preemptive summarization may modify `body`
 	originalBodyLen := len(body)
 
@@ -696,7 +872,8 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	var preemptiveHeaders map[string]string
 	var isCompaction bool
 	var syntheticResponse []byte
-	if g.preemptive != nil {
+	// Stored conversations can't be compacted by rewriting history: it lives upstream.
+	if g.preemptive != nil && !pipeCtx.StoredConversation {
 		// Resolve endpoint: X-Target-URL header > autoDetect
 		xTargetURL := r.Header.Get(HeaderTargetURL)
 		targetURL := xTargetURL
@@ -706,7 +883,9 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 		// Pass full auth struct to summarizer — single call, single source of truth
 		authForSummarizer := capturedAuth
 		authForSummarizer.Endpoint = targetURL
-		if authForSummarizer.HasAuth() || authForSummarizer.Endpoint != "" {
+		// SetAuth is the summarizer's fallback for every session; a tenant's
+		// key must not become another tenant's fallback.
+		if tenant == nil && (authForSummarizer.HasAuth() || authForSummarizer.Endpoint != "") {
 			log.Debug().
 				Str("auth_type", map[bool]string{true: "x-api-key", false: "Authorization"}[capturedAuth.IsXAPIKey]).
 				Str("auth", utils.MaskKey(capturedAuth.Token)).
@@ -720,7 +899,10 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 		requestHeaders.Set("X-Request-Path", r.URL.Path)
 
 		var preemptiveBody []byte
-		preemptiveBody, isCompaction, syntheticResponse, preemptiveHeaders, _ = g.preemptive.ProcessRequest(r.Context(), requestHeaders, body, model, adapter.Name())
+		preemptiveCtx, preemptiveSpan := tracing.Start(r.Context(), tracing.SpanPreemptive)
+		preemptiveBody, isCompaction, syntheticResponse, preemptiveHeaders, _ = g.preemptive.ProcessRequest(preemptiveCtx, requestHeaders, body, model, adapter.Name())
+		preemptiveSpan.SetAttributes(attribute.Bool("gateway.compaction", isCompaction), attribute.Bool("gateway.synthetic_response", len(syntheticResponse) > 0))
+		preemptiveSpan.End()
 
 		// If we have a synthetic response (SDK compaction with cached summary),
 		// return it immediately without forwarding to Anthropic
@@ -738,6 +920,9 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("X-Synthetic-Response", "true")
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write(syntheticResponse) // #nosec G705 -- JSON API response, not HTML
+			if g.notifier != nil {
+				g.notifyCompaction(conversationSessionID, model, tokenizer.CountBytes(body), tokenizer.CountBytes(syntheticResponse), true)
+			}
 
 			// Log telemetry async to not block the response
 			go g.recordRequestTelemetry(telemetryParams{
@@ -784,6 +969,9 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 					g.savings.RecordPreemptiveSummarization(origTok, mergedTok, model, pipeCtx.CostSessionID, g.isMainConversation(pipeCtx.StableFingerprint))
 					g.tracker.RecordPreemptiveStats(origTok, mergedTok)
 				}
+				if g.notifier != nil {
+					g.notifyCompaction(conversationSessionID, model, tokenizer.CountBytes(body), tokenizer.CountBytes(merged), false)
+				}
 				body = merged
 				// Update pipeCtx with new body
 				pipeCtx.OriginalRequest = body
@@ -853,33 +1041,80 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process compression pipeline
+	g.requestCapture.setPipelineInput(captured, body, pipeCtx)
+	pipeCtx.inflight.setStage(StageCompress)
+	_, compressSpan := tracing.Start(r.Context(), tracing.SpanCompression)
 	forwardBody, pipeType, pipeStrategy, compressionUsed, compressLatency := g.processCompressionPipeline(body, pipeCtx, requestID)
+	compressSpan.SetAttributes(
+		attribute.String("gateway.pipe", string(pipeType)),
+		attribute.String("gateway.pipe_strategy", pipeStrategy),
+		attribute.Bool("gateway.compressed", compressionUsed),
+		attribute.Int("gateway.body_bytes", len(body)),
+		attribute.Int("gateway.forward_bytes", len(forwardBody)),
+	)
+	compressSpan.End()
+	g.reportInjection(w.Header(), pipeCtx)
+
+	// PII masking failed: fail closed rather than forward raw entities upstream.
+	if pipeCtx.PIIError != nil {
+		g.recordError(monitoring.ErrorCodePIIMaskingFailed)
+		g.writeError(w, "pii masking failed", http.StatusServiceUnavailable)
+		return
+	}
+
+	// A custom pipe refused the request (or failed with on_error: reject),
+	// or the injection pipe blocked it.
+	if pipeCtx.PipeRejection != nil {
+		g.recordError(pipeCtx.rejectCode)
+		g.writeError(w, pipeCtx.PipeRejection.Error(), pipeCtx.rejectStatus)
+		return
+	}
+
+	// Prompt-cache guard: detect (and in cache_compat mode, undo) pipe changes
+	// before the final cache_control breakpoint. With PII masking the baseline
+	// is the masked request, so restoring never reintroduces raw entities.
+	cacheBaseline := body
+	if pipeCtx.securedBody != nil {
+		cacheBaseline = pipeCtx.securedBody
+	}
+	forwardBody = g.guardCachePrefix(pipeCtx, cacheBaseline, forwardBody)
 
 	// Store deferred tools in session for hybrid search fallback
 	if g.toolSessions != nil && pipeCtx.ToolSessionID != "" && len(pipeCtx.DeferredTools) > 0 {
 		g.toolSessions.StoreDeferred(pipeCtx.ToolSessionID, pipeCtx.DeferredTools)
 	}
+	if g.toolSessions != nil && pipeCtx.ToolSessionID != "" && pipeCtx.ToolServerStats != nil {
+		g.toolSessions.StoreServerStats(pipeCtx.ToolSessionID, pipeCtx.ToolServerStats)
+	}
 
 	// Capture compressed body size BEFORE tool injection — this is the true
 	// post-compression size for metrics. Tool injection adds gateway overhead
 	// (expand_context definition) that shouldn't count against compression savings.
 	compressedBodySize := len(forwardBody)
+	compressedBody := forwardBody
 
 	// Always inject all phantom tools (MCP-server pattern).
 	// Both expand_context and gateway_search_tools are injected unconditionally,
 	// regardless of which pipes are enabled. Config may change mid-session, and
 	// the LLM should consistently see both tools from turn one.
 	// Dedup in InjectPhantomTool prevents double-injection if a tool already exists.
-	isStreaming := g.isStreamingRequest(body)
-	if injected, err := phantom_tools.InjectAll(forwardBody, provider); err == nil {
-		forwardBody = injected
-		pipeCtx.PhantomToolsInjected = true
+	isStreaming := g.isStreamingRequest(r.URL.Path, body)
+	// Bedrock Converse requests would reject Anthropic-format tools.
+	if !isBedrockConversePath(r.URL.Path) {
+		if injected, err := phantom_tools.InjectAll(forwardBody, provider); err == nil {
+			forwardBody = injected
+			pipeCtx.PhantomToolsInjected = true
+		}
+	}
+	if g.cfg().CostControl.CostHeaders {
+		pipeCtx.PreemptiveHeaders = withCostHeaders(pipeCtx.PreemptiveHeaders, g.tokens, model, body, compressedBody, forwardBody, compressionUsed)
 	}
 	// expandEnabled=true: phantom loop always handles calls to either tool.
 	// For streaming: needsExpandBuffer still checks compressionUsed + ShadowRefs.
 	expandEnabled := true
 
 	// Route to streaming or non-streaming handler
+	pipeCtx.inflight.setStage(StageUpstream)
 	if isStreaming {
 		g.handleStreamingWithExpand(w, r, forwardBody, pipeCtx, requestID, startTime, adapter,
 			pipeType, pipeStrategy, preCompactionBodySize, compressionUsed, compressLatency, body, expandEnabled, compressedBodySize)
@@ -889,13 +1124,75 @@ func (g *Gateway) handleProxy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handlePassthrough forwards a request to upstream unchanged and relays the response.
+// Responses for paths listed in passthrough_cache.paths are served from a TTL
+// cache keyed by target, credential, and body.
+func (g *Gateway) handlePassthrough(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	g.relayPassthrough(w, r, body)
+}
+
+// relayPassthrough forwards body to upstream and relays the response, through
+// the passthrough cache.
+func (g *Gateway) relayPassthrough(w http.ResponseWriter, r *http.Request, body []byte) {
+	ttl, cacheable := g.passthroughCacheTTL(r.URL.Path)
+	var cacheKey string
+	if cacheable {
+		cacheKey = g.requestCacheKey(r, body)
+		if cached, ok := g.passthroughCache.get(cacheKey); ok {
+			copyHeaders(w, cached.header)
+			w.Header().Set(HeaderGatewayCache, cacheStatusHit)
+			w.WriteHeader(cached.status)
+			_, _ = w.Write(cached.body)
+			return
+		}
+	}
+
+	resp, _, err := g.forwardPassthrough(r.Context(), r, body)
+	if err != nil {
+		log.Debug().Err(err).Str("path", r.URL.Path).Msg("passthrough failed")
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+	copyHeaders(w, resp.Header)
+	if cacheable {
+		// Only successful answers are cached — errors must be retried upstream.
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			g.passthroughCache.set(cacheKey, cachedResponse{
+				status:    resp.StatusCode,
+				header:    resp.Header.Clone(),
+				body:      responseBody,
+				expiresAt: time.Now().Add(ttl),
+			})
+		}
+		w.Header().Set(HeaderGatewayCache, cacheStatusMiss)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(responseBody)
+}
+
 // processCompressionPipeline routes and processes through ALL applicable compression pipes.
 // Now processes BOTH tool_output AND tool_discovery if both are present (no priority skipping).
 func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineContext, requestID string) ([]byte, PipeType, string, bool, time.Duration) {
+	if g.cfg().Pipes.IsShadow() {
+		return g.processShadowMode(body, pipeCtx, requestID), PipeNone, config.StrategyPassthrough, false, 0
+	}
+
 	compressStart := time.Now()
 
 	// Process all applicable pipes (tool_output first, then tool_discovery)
-	forwardBody, flags, _ := g.router.ProcessAll(pipeCtx)
+	router := g.routerFor(pipeCtx.Tenant)
+	cfg, _, _, _ := router.snapshot()
+	forwardBody, flags, _ := router.ProcessAll(pipeCtx)
+	g.recordCustomPipes(pipeCtx)
 
 	// Determine primary pipe type for telemetry (tool_output takes precedence)
 	var pipeType PipeType
@@ -907,7 +1204,7 @@ func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineConte
 		// only when no higher-priority pipe (tool_output) also ran.
 		if pipeType == PipeNone {
 			pipeType = PipeTaskOutput
-			pipeStrategy = g.cfg().Pipes.TaskOutput.Strategy
+			pipeStrategy = cfg.Pipes.TaskOutput.Strategy
 		}
 		g.requestLogger.LogPipelineStage(&monitoring.PipelineStageInfo{
 			RequestID: requestID, Stage: "process", Pipe: string(PipeTaskOutput),
@@ -915,7 +1212,7 @@ func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineConte
 	}
 	if flags.ToolOutput {
 		pipeType = PipeToolOutput
-		pipeStrategy = g.cfg().Pipes.ToolOutput.Strategy
+		pipeStrategy = cfg.Pipes.ToolOutput.Strategy
 		compressionUsed = pipeCtx.OutputCompressed
 		g.requestLogger.LogPipelineStage(&monitoring.PipelineStageInfo{
 			RequestID: requestID, Stage: "process", Pipe: string(PipeToolOutput),
@@ -924,7 +1221,7 @@ func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineConte
 	if flags.ToolDiscovery {
 		if pipeType == PipeNone {
 			pipeType = PipeToolDiscovery
-			pipeStrategy = g.cfg().Pipes.ToolDiscovery.Strategy
+			pipeStrategy = cfg.Pipes.ToolDiscovery.Strategy
 		}
 		if pipeCtx.ToolsFiltered {
 			compressionUsed = true
@@ -933,8 +1230,22 @@ func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineConte
 			RequestID: requestID, Stage: "process", Pipe: string(PipeToolDiscovery),
 		})
 	}
+	if len(pipeCtx.MediaRewrites) > 0 {
+		// Dropped blocks hand out expand_context refs, like compressed outputs.
+		compressionUsed = true
+		if pipeType == PipeNone {
+			pipeType = PipeMedia
+			pipeStrategy = string(PipeMedia)
+		}
+		g.requestLogger.LogPipelineStage(&monitoring.PipelineStageInfo{
+			RequestID: requestID, Stage: "process", Pipe: string(PipeMedia),
+		})
+	}
 
 	if pipeType == PipeNone {
+		if pipeCtx.securedBody != nil || len(pipeCtx.CustomPipes) > 0 {
+			return forwardBody, pipeType, config.StrategyPassthrough, false, 0
+		}
 		return body, pipeType, config.StrategyPassthrough, false, 0
 	}
 
@@ -966,10 +1277,92 @@ func (g *Gateway) processCompressionPipeline(body []byte, pipeCtx *PipelineConte
 	return forwardBody, pipeType, pipeStrategy, compressionUsed, compressLatency
 }
 
-// forwardPassthrough forwards the request body unchanged to upstream.
+type egressSessionKey struct{}
+
+// withEgressSession attributes forwards made under ctx to a cost session.
+func withEgressSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, egressSessionKey{}, sessionID)
+}
+
+// egressSessionFrom returns the cost session for forwards under ctx; empty
+// for passthrough requests, which count toward the daily total only.
+func egressSessionFrom(ctx context.Context) string {
+	id, _ := ctx.Value(egressSessionKey{}).(string)
+	return id
+}
+
+// forwardPassthrough forwards the request body to upstream, in an upstream
+// forward span that ends once the response headers arrive. The body's model
+// is rewritten by model_rewrite first. Transient failures are retried, and an
+// overloaded model may be swapped for its fallback (see upstream_retry.go).
 func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body []byte) (*http.Response, forwardAuthMeta, error) {
+	ctx, span := tracing.Start(ctx, tracing.SpanUpstreamForward, attribute.Int("gateway.forward_bytes", len(body)))
+	retryCfg := g.cfg().UpstreamRetry.WithDefaults()
+	provider, _ := adapters.IdentifyAndGetAdapter(g.registry, r.URL.Path, r.Header)
+	body = g.rewriteModel(r.URL.Path, provider, body)
+	resp, authMeta, err := g.forwardWithRetries(ctx, r, body, retryCfg)
+	if fallback, fallbackBody, ok := modelFallbackFor(ctx, retryCfg, body, resp, err); ok {
+		log.Warn().Int("status", resp.StatusCode).Str("model", gjson.GetBytes(body, "model").String()).
+			Str("fallback", fallback).Msg("upstream_retry: model overloaded, falling back")
+		_ = resp.Body.Close()
+		var fallbackMeta forwardAuthMeta
+		resp, fallbackMeta, err = g.forwardWithRetries(ctx, r, fallbackBody, retryCfg)
+		mergeForwardAuthMeta(&authMeta, fallbackMeta)
+		authMeta.ModelFallback = fallback
+		if resp != nil {
+			resp.Header.Set(HeaderModelFallback, fallback)
+		}
+		if g.metrics != nil {
+			g.metrics.RecordUpstreamRetry(upstreamRetryReasonModelFallback)
+		}
+		span.SetAttributes(attribute.String("gateway.model_fallback", fallback))
+	}
+	if authMeta.Retries > 0 {
+		span.SetAttributes(attribute.Int("gateway.upstream_retries", authMeta.Retries))
+	}
+	tracing.EndHTTP(span, resp, err)
+	return resp, authMeta, err
+}
+
+// forwardWithRetries runs forwardUpstream until it succeeds or upstream_retry gives up.
+func (g *Gateway) forwardWithRetries(ctx context.Context, r *http.Request, body []byte, retryCfg config.UpstreamRetryConfig) (*http.Response, forwardAuthMeta, error) {
+	retries := 0
+	for attempt := 1; ; attempt++ {
+		audit := &forwardAudit{}
+		resp, authMeta, err := g.forwardUpstream(ctx, r, body, audit)
+		sent := audit.upstreamHeader != nil
+		g.recordUpstreamOutcome(ctx, audit.host, sent, resp, err)
+		g.logForwardAudit(ctx, r, audit, authMeta, resp, err)
+
+		delay, reason, again := upstreamRetryDelay(ctx, retryCfg, attempt, sent, resp, err)
+		if again {
+			log.Warn().Err(err).Str("host", audit.host).Str("reason", reason).Int("attempt", attempt).
+				Dur("retry_in", delay).Msg("upstream_retry: retrying upstream forward")
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				again = false
+			}
+		}
+		if !again {
+			authMeta.Retries = retries
+			return resp, authMeta, err
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		retries++
+		if g.metrics != nil {
+			g.metrics.RecordUpstreamRetry(reason)
+		}
+	}
+}
+
+func (g *Gateway) forwardUpstream(ctx context.Context, r *http.Request, body []byte, audit *forwardAudit) (*http.Response, forwardAuthMeta, error) {
 	authMeta := forwardAuthMeta{InitialMode: "unknown", EffectiveMode: "unknown"}
 	targetURL := r.Header.Get(HeaderTargetURL)
+	audit.targetURLHeader = targetURL
+	audit.targetSource = auditTargetHeader
 	if targetURL != "" {
 		// X-Target-URL provided - append request path if not already included
 		if !strings.HasSuffix(targetURL, r.URL.Path) {
@@ -977,20 +1370,17 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 		}
 	} else {
 		targetURL = g.autoDetectTargetURL(r)
+		audit.targetSource = auditTargetAutoDetect
 		if targetURL == "" {
-			return nil, authMeta, fmt.Errorf("missing %s header", HeaderTargetURL)
+			audit.targetSource = auditTargetNone
+			return nil, authMeta, fmt.Errorf("%w: missing %s header", errMissingTargetURL, HeaderTargetURL)
 		}
 	}
+	audit.targetURL = targetURL
 
 	// Detect if this is a Bedrock request
 	isBedrock := g.isBedrockRequest(r.URL.Path)
 
-	// Sanitize model name (strip provider prefix like "anthropic/", "openai/")
-	// Skip for Bedrock since model ID format is different (e.g., "anthropic.claude-3-5-sonnet")
-	if !isBedrock {
-		body = sanitizeModelName(body)
-	}
-
 	log.Info().
 		Str("targetURL", targetURL).
 		Bool("bedrock", isBedrock).
@@ -1002,13 +1392,23 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 	if err != nil {
 		return nil, authMeta, fmt.Errorf("invalid target URL: %w", err)
 	}
-	if !g.isAllowedHost(parsedURL.Host) {
-		return nil, authMeta, fmt.Errorf("target host not allowed: %s", parsedURL.Host)
+	audit.host = parsedURL.Host
+	audit.hostAllowed = g.isAllowedHost(parsedURL.Host)
+	if !audit.hostAllowed {
+		return nil, authMeta, fmt.Errorf("%w: %s", errHostNotAllowed, parsedURL.Host)
+	}
+	if cb := g.upstreamCircuit(parsedURL.Host); cb != nil && !cb.Allow() {
+		return nil, authMeta, fmt.Errorf("%w: %s", errCircuitOpen, parsedURL.Host)
+	}
+	g.requestCapture.addForward(capturedRequestFrom(ctx), targetURL, body)
+	if g.costTracker != nil {
+		g.costTracker.RecordEgress(egressSessionFrom(ctx), len(body))
 	}
 
 	// Auth fallback context: provider-scoped subscription -> API key.
 	// IdentifyAndGetAdapter centralizes all provider detection logic; no overrides needed here.
 	provider, _ := adapters.IdentifyAndGetAdapter(g.registry, r.URL.Path, r.Header)
+	audit.provider = provider.String()
 
 	// Use provider-specific auth handler for fallback logic
 	authHandler := g.authRegistry.GetOrDefault(provider)
@@ -1016,15 +1416,25 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 	authMeta.InitialMode = initialMode
 
 	canFallbackToAPIKey := isSubscriptionAuth && authHandler.HasFallback()
-	sessionID := preemptive.ComputeSessionID(body)
+	sessionID := tenancy.FromContext(ctx).SessionID(preemptive.ComputeSessionID(body))
 	useAPIKeyForSession := canFallbackToAPIKey && g.authMode != nil && g.authMode.ShouldUseAPIKeyMode(sessionID)
+	audit.sessionID = sessionID
+	if useAPIKeyForSession {
+		audit.fallbackReason = "session already in api_key mode"
+	}
 
 	sendUpstream := func(useAPIKeyMode bool, fallbackHeaders map[string]string) (*http.Response, []byte, error) {
 		// #nosec G704 -- targetURL is from configured provider URLs, not user input
-		httpReq, reqErr := http.NewRequestWithContext(ctx, "POST", targetURL, bytes.NewReader(body))
+		httpReq, reqErr := http.NewRequestWithContext(ctx, r.Method, targetURL, bytes.NewReader(body))
 		if reqErr != nil {
 			return nil, nil, reqErr
 		}
+		// Gemini carries request options in the query: alt=sse selects SSE over
+		// a JSON array for streamGenerateContent, key= is an API key. Added here
+		// so the key stays out of logged target URLs.
+		if provider == adapters.ProviderGemini && httpReq.URL.RawQuery == "" {
+			httpReq.URL.RawQuery = r.URL.RawQuery
+		}
 
 		if isBedrock && g.bedrockSigner != nil && g.bedrockSigner.IsConfigured() {
 			// Bedrock: use AWS SigV4 signing instead of forwarding API key headers
@@ -1032,6 +1442,7 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 			if signErr := g.bedrockSigner.SignRequest(ctx, httpReq, body); signErr != nil {
 				return nil, nil, fmt.Errorf("failed to sign Bedrock request: %w", signErr)
 			}
+			audit.sigV4Signed = true
 		} else {
 			// Non-Bedrock: forward relevant headers
 			for _, h := range []string{
@@ -1040,6 +1451,8 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 				"api-key", "anthropic-version", "anthropic-beta",
 				// OpenAI headers
 				"OpenAI-Organization", "OpenAI-Project", "OpenAI-Beta",
+				// OpenRouter app attribution headers
+				"HTTP-Referer", "X-Title",
 				// Codex CLI headers (required for ChatGPT subscription)
 				"Chatgpt-Account-Id", "Originator", "Session_id", "Version",
 				"X-Codex-Turn-Metadata", "Accept",
@@ -1057,6 +1470,9 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 					httpReq.Header.Set(h, v)
 				}
 			}
+			if g.cfg().LiteLLM.Enabled {
+				copyLiteLLMHeaders(httpReq.Header, r.Header)
+			}
 
 			// Sticky/triggered fallback mode: apply fallback headers from auth handler
 			if useAPIKeyMode && fallbackHeaders != nil {
@@ -1073,6 +1489,10 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 		} else {
 			authMeta.EffectiveMode = authMeta.InitialMode
 		}
+		if pinErr := g.enforceKeyPins(httpReq); pinErr != nil {
+			return nil, nil, pinErr
+		}
+		audit.upstreamHeader = httpReq.Header
 		// #nosec G704 -- httpReq uses configured provider URLs, not user input
 		resp, doErr := g.httpClient.Do(httpReq)
 		if doErr != nil {
@@ -1114,6 +1534,8 @@ func (g *Gateway) forwardPassthrough(ctx context.Context, r *http.Request, body
 				g.authMode.MarkAPIKeyMode(sessionID)
 			}
 			authMeta.FallbackUsed = true
+			audit.fallbackReason = fallbackResult.Reason
+			g.recordAuthFallback(sessionID, fallbackResult.Reason)
 			_ = resp.Body.Close()
 			log.Info().
 				Str("session_id", sessionID).
@@ -1142,9 +1564,10 @@ func (g *Gateway) isBedrockRequest(path string) bool {
 			strings.HasSuffix(path, "/converse-stream"))
 }
 
-// isStreamingRequest checks if the request has "stream": true.
-func (g *Gateway) isStreamingRequest(body []byte) bool {
-	return gjson.GetBytes(body, "stream").Bool()
+// isStreamingRequest checks if the request has "stream": true, or is sent to
+// a Bedrock streaming endpoint.
+func (g *Gateway) isStreamingRequest(path string, body []byte) bool {
+	return gjson.GetBytes(body, "stream").Bool() || isBedrockStreamPath(path)
 }
 
 // setStreamFlag sets or clears the "stream" field in a request body.
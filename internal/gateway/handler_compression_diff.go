@@ -0,0 +1,115 @@
+// handler_compression_diff.go serves recent compression samples for the dashboard diff view.
+//
+// GET /api/compressions lists the requests sampled by the comparison log;
+// ?request_id= returns one sample with original and forwarded content for
+// every compressed tool output. The centralized dashboard reaches a specific
+// instance through /api/instance/compressions?port=.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// compressionSampleListSize is the number of samples listed by GET /api/compressions.
+const compressionSampleListSize = 25
+
+// compressionSampleList is the JSON response for GET /api/compressions.
+type compressionSampleList struct {
+	Requests []monitoring.ComparisonSampleSummary `json:"requests"`
+}
+
+// handleCompressionsAPI lists sampled requests, or returns one with ?request_id=.
+// Restricted to localhost: samples contain tool output content.
+func (g *Gateway) handleCompressionsAPI(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resp any
+	if id := r.URL.Query().Get("request_id"); id != "" {
+		sample, ok := g.comparisonLog.Get(id)
+		if !ok {
+			g.writeError(w, "no compression sample for request "+id, http.StatusNotFound)
+			return
+		}
+		resp = sample
+	} else {
+		resp = compressionSampleList{Requests: g.comparisonLog.Recent(compressionSampleListSize)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:18080")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleCompressionsAPI: failed to encode JSON response")
+	}
+}
+
+// handleInstanceCompressionsProxy proxies compression sample requests to a gateway instance.
+// GET /api/instance/compressions?port=18081&request_id=... → http://127.0.0.1:18081/api/compressions?request_id=...
+func (g *Gateway) handleInstanceCompressionsProxy(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	port, err := strconv.Atoi(r.URL.Query().Get("port"))
+	if err != nil || port <= 0 {
+		g.writeError(w, "invalid port", http.StatusBadRequest)
+		return
+	}
+	if !g.isKnownInstancePort(port) {
+		g.writeError(w, "port not found in active instances", http.StatusBadRequest)
+		return
+	}
+
+	target := &neturl.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port), Path: "/api/compressions"}
+	if id := r.URL.Query().Get("request_id"); id != "" {
+		target.RawQuery = "request_id=" + neturl.QueryEscape(id)
+	}
+	proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target.String(), nil) // #nosec G704 -- target is 127.0.0.1 on a registered instance port
+	if err != nil {
+		g.writeError(w, "failed to create proxy request", http.StatusInternalServerError)
+		return
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(proxyReq) // #nosec G704 -- request targets 127.0.0.1 only
+	if err != nil {
+		g.writeError(w, fmt.Sprintf("instance on port %d unreachable", port), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Samples cap each content side at 64KB, but a request can carry many outputs.
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		g.writeError(w, "failed to read instance response", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:18080")
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(respBody)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/userdir"
 	"github.com/compresr/context-gateway/internal/utils"
 )
 
@@ -35,6 +36,25 @@ func (g *Gateway) handleConfigAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleEffectiveConfig serves GET /config/effective: the full active configuration
+// with secrets redacted, so operators can verify what is actually loaded.
+func (g *Gateway) handleEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.cfg().Effective()); err != nil {
+		log.Warn().Err(err).Msg("handleEffectiveConfig: failed to encode JSON response")
+	}
+}
+
 // configResponse is the JSON representation of the config for the API.
 // API keys are masked for security.
 type configResponse struct {
@@ -78,9 +98,13 @@ type schemaCompressionResponse struct {
 }
 
 type costControlResponse struct {
-	Enabled    bool    `json:"enabled"`
-	SessionCap float64 `json:"session_cap"`
-	GlobalCap  float64 `json:"global_cap"`
+	Enabled            bool    `json:"enabled"`
+	Mode               string  `json:"mode"`
+	SessionCap         float64 `json:"session_cap"`
+	GlobalCap          float64 `json:"global_cap"`
+	SessionEgressBytes int64   `json:"session_egress_bytes"`
+	DailyEgressBytes   int64   `json:"daily_egress_bytes"`
+	CostHeaders        bool    `json:"cost_headers"`
 }
 
 type notificationsResponse struct {
@@ -122,9 +146,23 @@ func (g *Gateway) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (g *Gateway) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
+	updated, ok := g.applyConfigPatch(w, r)
+	if !ok {
+		return
+	}
+
+	resp := buildConfigResponse(updated)
+	resp.HasOverrides = !g.configReloader.SessionOverrides().IsEmpty()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// applyConfigPatch applies the ConfigPatch in r's body and returns the new
+// effective config. On failure it writes the error response and returns false.
+func (g *Gateway) applyConfigPatch(w http.ResponseWriter, r *http.Request) (*config.Config, bool) {
 	if g.configReloader == nil {
 		g.writeError(w, "config reloader not initialized", http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB limit (DoS prevention)
@@ -135,14 +173,14 @@ func (g *Gateway) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
 		} else {
 			g.writeError(w, "failed to read request body", http.StatusBadRequest)
 		}
-		return
+		return nil, false
 	}
 
 	var patch config.ConfigPatch
 	err = json.Unmarshal(body, &patch)
 	if err != nil {
 		g.writeError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
 	// scope=session applies changes to this session only (in-memory, not persisted).
@@ -158,7 +196,7 @@ func (g *Gateway) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error().Err(err).Str("scope", scope).Msg("config patch failed")
 		g.writeError(w, "config update failed: "+err.Error(), http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
 	log.Info().Str("scope", scope).Msg("config updated via API")
@@ -168,8 +206,7 @@ func (g *Gateway) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
 		webhookVal := *patch.Notifications.Slack.WebhookURL
 		if webhookVal != "" {
 			_ = os.Setenv("SLACK_WEBHOOK_URL", webhookVal)
-			if home, err := os.UserHomeDir(); err == nil {
-				envPath := filepath.Join(home, ".config", "context-gateway", ".env")
+			if envPath := userdir.Path(".env"); envPath != "" {
 				persistEnvVar(envPath, "SLACK_WEBHOOK_URL", webhookVal)
 			}
 		}
@@ -179,11 +216,7 @@ func (g *Gateway) handlePatchConfig(w http.ResponseWriter, r *http.Request) {
 	if g.monitorHub != nil {
 		g.monitorHub.BroadcastEvent("config_updated", nil)
 	}
-
-	resp := buildConfigResponse(updated)
-	resp.HasOverrides = !g.configReloader.SessionOverrides().IsEmpty()
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	return updated, true
 }
 
 // handleDeleteConfig resets session overrides back to global defaults.
@@ -250,9 +283,13 @@ func buildConfigResponse(cfg *config.Config) configResponse {
 			},
 		},
 		CostControl: costControlResponse{
-			Enabled:    cfg.CostControl.Enabled,
-			SessionCap: cfg.CostControl.SessionCap,
-			GlobalCap:  cfg.CostControl.GlobalCap,
+			Enabled:            cfg.CostControl.Enabled,
+			Mode:               cfg.CostControl.EffectiveMode(),
+			SessionCap:         cfg.CostControl.SessionCap,
+			GlobalCap:          cfg.CostControl.GlobalCap,
+			SessionEgressBytes: cfg.CostControl.SessionEgressCap,
+			DailyEgressBytes:   cfg.CostControl.DailyEgressCap,
+			CostHeaders:        cfg.CostControl.CostHeaders,
 		},
 		Notifications: notificationsResponse{
 			Slack: slackResponse{
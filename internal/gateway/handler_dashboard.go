@@ -19,6 +19,7 @@ import (
 	"github.com/compresr/context-gateway/internal/dashboard"
 	"github.com/compresr/context-gateway/internal/monitoring"
 	"github.com/compresr/context-gateway/internal/prompthistory"
+	"github.com/compresr/context-gateway/internal/statedir"
 )
 
 // buildUnifiedReportData gathers data from cost tracker and expand log for the /savings report.
@@ -96,6 +97,10 @@ func hasSavingsData(sr monitoring.SavingsReport) bool {
 		sr.PreemptiveSummarizationRequests > 0
 }
 
+// dashboardToolLeaderboardSize caps the per-tool savings rows sent to the dashboard.
+// The full list is at GET /stats/tools.
+const dashboardToolLeaderboardSize = 10
+
 // handleDashboardAPI returns JSON data for the React cost dashboard.
 // Restricted to localhost to prevent external access to cost/usage data.
 func (g *Gateway) handleDashboardAPI(w http.ResponseWriter, r *http.Request) {
@@ -183,19 +188,22 @@ func (g *Gateway) handleDashboardAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type dashboardResponse struct {
-		Sessions      []sessionJSON     `json:"sessions"`
-		TotalCost     float64           `json:"total_cost"`
-		TotalRequests int               `json:"total_requests"`
-		SessionCap    float64           `json:"session_cap"`
-		GlobalCap     float64           `json:"global_cap"`
-		Enabled       bool              `json:"enabled"`
-		Savings       *savingsJSON      `json:"savings,omitempty"`
-		GlobalSavings *savingsJSON      `json:"global_savings,omitempty"`
-		Expand        *expandJSON       `json:"expand,omitempty"`
-		Search        *searchJSON       `json:"search,omitempty"`
-		Gateway       *gatewayStatsJSON `json:"gateway,omitempty"`
-		HiddenTabs    []string          `json:"hidden_tabs,omitempty"`
-		ActivePorts   []int             `json:"active_ports,omitempty"`
+		Sessions      []sessionJSON            `json:"sessions"`
+		TotalCost     float64                  `json:"total_cost"`
+		TotalRequests int                      `json:"total_requests"`
+		SessionCap    float64                  `json:"session_cap"`
+		GlobalCap     float64                  `json:"global_cap"`
+		Enabled       bool                     `json:"enabled"`
+		Savings       *savingsJSON             `json:"savings,omitempty"`
+		GlobalSavings *savingsJSON             `json:"global_savings,omitempty"`
+		Expand        *expandJSON              `json:"expand,omitempty"`
+		Search        *searchJSON              `json:"search,omitempty"`
+		Gateway       *gatewayStatsJSON        `json:"gateway,omitempty"`
+		ToolSavings   []monitoring.ToolSavings `json:"tool_savings,omitempty"`
+		HiddenTabs    []string                 `json:"hidden_tabs,omitempty"`
+		ActivePorts   []int                    `json:"active_ports,omitempty"`
+		Lifetime      *statedir.SelfMetrics    `json:"lifetime,omitempty"` // Across restarts
+		BudgetScopes  []costcontrol.ScopeUsage `json:"budget_scopes,omitempty"`
 	}
 
 	resp := dashboardResponse{
@@ -207,11 +215,17 @@ func (g *Gateway) handleDashboardAPI(w http.ResponseWriter, r *http.Request) {
 	useGlobalScope := requestedSessionID == "" || requestedSessionID == "all"
 	scopedBilledSpend := 0.0
 
+	if g.selfMetrics != nil {
+		lt := g.selfMetrics.Lifetime()
+		resp.Lifetime = &lt
+	}
+
 	if g.costTracker != nil {
 		cfg := g.costTracker.Config()
 		resp.Enabled = cfg.Enabled
 		resp.SessionCap = cfg.SessionCap
 		resp.GlobalCap = cfg.GlobalCap
+		resp.BudgetScopes = g.costTracker.ScopeSpend()
 	}
 
 	// Always build the session list from disk (aggregator) so the dropdown
@@ -461,6 +475,14 @@ func (g *Gateway) handleDashboardAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Per-tool savings leaderboard (in-memory, this gateway only)
+	if tools := g.toolSavings.Leaderboard(); len(tools) > 0 {
+		if len(tools) > dashboardToolLeaderboardSize {
+			tools = tools[:dashboardToolLeaderboardSize]
+		}
+		resp.ToolSavings = tools
+	}
+
 	// Gateway operational stats
 	if g.metrics != nil {
 		stats := g.metrics.Stats()
@@ -569,11 +591,29 @@ func (g *Gateway) handleAccountAPI(w http.ResponseWriter, r *http.Request) {
 func (g *Gateway) returnBudgetExceededResponse(w http.ResponseWriter, provider string, budget costcontrol.BudgetCheckResult, sessionID string) {
 	dashboardURL := fmt.Sprintf("http://localhost:%d/dashboard", config.DefaultDashboardPort)
 	var msg string
-	if budget.GlobalCap > 0 && budget.GlobalCost >= budget.GlobalCap {
+	switch budget.Reason {
+	case costcontrol.ReasonGlobalCost:
 		msg = fmt.Sprintf("Budget exceeded for session %q. Total spend: $%.4f, limit: $%.2f. "+
 			"Increase the session cap in your monitor dashboard at %s.",
 			sessionID, budget.GlobalCost, budget.GlobalCap, dashboardURL)
-	} else {
+	case costcontrol.ReasonDailyEgress:
+		msg = fmt.Sprintf("Data egress limit reached for today (UTC). Sent upstream: %d bytes, limit: %d bytes. "+
+			"Requests resume at 00:00 UTC or when cost_control.daily_egress_bytes is raised.",
+			budget.DailyEgress, budget.DailyEgressCap)
+	case costcontrol.ReasonSessionEgress:
+		msg = fmt.Sprintf("Data egress limit reached for session %q. Sent upstream: %d bytes, limit: %d bytes. "+
+			"Start a new session or raise cost_control.session_egress_bytes.",
+			sessionID, budget.SessionEgress, budget.SessionEgressCap)
+	case costcontrol.ReasonScopeCost:
+		s := budget.Scope
+		resets := "when the cap is raised"
+		if s.ResetsAt != nil {
+			resets = "at " + s.ResetsAt.Format("2006-01-02 15:04 UTC")
+		}
+		msg = fmt.Sprintf("Budget exceeded for %s %q (%s). Spend: $%.4f, limit: $%.2f. "+
+			"Requests resume %s.",
+			s.Scope, s.Value, s.Window, s.Cost, s.Cap, resets)
+	default:
 		msg = fmt.Sprintf("Budget exceeded for session %q. Current spend: $%.4f, limit: $%.2f. "+
 			"Increase the session cap in your monitor dashboard at %s.",
 			sessionID, budget.CurrentCost, budget.Cap, dashboardURL)
@@ -607,6 +647,17 @@ func (g *Gateway) returnBudgetExceededResponse(w http.ResponseWriter, provider s
 	w.Header().Set("X-Session-Cap", fmt.Sprintf("%.4f", budget.Cap))
 	w.Header().Set("X-Global-Cost", fmt.Sprintf("%.4f", budget.GlobalCost))
 	w.Header().Set("X-Global-Cap", fmt.Sprintf("%.4f", budget.GlobalCap))
+	w.Header().Set("X-Budget-Reason", budget.Reason)
+	if budget.Scope != nil {
+		w.Header().Set("X-Budget-Scope", budget.Scope.Scope)
+		w.Header().Set("X-Budget-Scope-Value", budget.Scope.Value)
+		w.Header().Set("X-Scope-Cost", fmt.Sprintf("%.4f", budget.Scope.Cost))
+		w.Header().Set("X-Scope-Cap", fmt.Sprintf("%.4f", budget.Scope.Cap))
+	}
+	if budget.SessionEgressCap > 0 || budget.DailyEgressCap > 0 {
+		w.Header().Set("X-Session-Egress-Bytes", strconv.FormatInt(budget.SessionEgress, 10))
+		w.Header().Set("X-Daily-Egress-Bytes", strconv.FormatInt(budget.DailyEgress, 10))
+	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(resp)
 }
@@ -1021,14 +1072,7 @@ func (g *Gateway) handleInstanceConfigProxy(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Validate port against known instances to prevent local port scanning.
-	allowedPorts := make(map[int]bool)
-	for _, inst := range dashboard.DiscoverInstances() {
-		allowedPorts[inst.Port] = true
-	}
-	// Also allow the gateway's own port.
-	allowedPorts[g.cfg().Server.Port] = true
-	if !allowedPorts[port] {
+	if !g.isKnownInstancePort(port) {
 		g.writeError(w, "port not found in active instances", http.StatusBadRequest)
 		return
 	}
@@ -1079,6 +1123,20 @@ func (g *Gateway) handleInstanceConfigProxy(w http.ResponseWriter, r *http.Reque
 	_, _ = w.Write(respBody)
 }
 
+// isKnownInstancePort reports whether port belongs to a registered gateway
+// instance (or this gateway). Proxy handlers check it to prevent local port scanning.
+func (g *Gateway) isKnownInstancePort(port int) bool {
+	if port == g.cfg().Server.Port {
+		return true
+	}
+	for _, inst := range dashboard.DiscoverInstances() {
+		if inst.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
 // handleFocusTerminal brings the terminal window for a gateway instance to the foreground.
 // POST /api/focus?port=18081
 func (g *Gateway) handleFocusTerminal(w http.ResponseWriter, r *http.Request) {
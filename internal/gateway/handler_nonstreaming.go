@@ -26,11 +26,14 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 
 	providerName := adapter.Name()
 	authMeta := forwardAuthMeta{}
+	cfg := g.cfgFor(pipeCtx.Tenant)
 
 	forwardFunc := func(ctx context.Context, body []byte) (*http.Response, error) {
 		resp, meta, err := g.forwardPassthrough(ctx, r, body)
 		if err == nil {
 			mergeForwardAuthMeta(&authMeta, meta)
+		} else {
+			authMeta.Retries += meta.Retries
 		}
 		return resp, err
 	}
@@ -47,42 +50,42 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 
 		if searchFallbackEnabled {
 			searchToolName := g.searchToolName()
-			maxSearchResults := g.cfg().Pipes.ToolDiscovery.MaxSearchResults
+			maxSearchResults := cfg.Pipes.ToolDiscovery.MaxSearchResults
 			if maxSearchResults <= 0 {
 				maxSearchResults = 5
 			}
 
 			// Configure SearchToolHandler with Compresr API endpoint for search
 			opts := SearchToolHandlerOptions{
-				Strategy:   g.cfg().Pipes.ToolDiscovery.Strategy,
-				AlwaysKeep: g.cfg().Pipes.ToolDiscovery.AlwaysKeep,
+				Strategy:   cfg.Pipes.ToolDiscovery.Strategy,
+				AlwaysKeep: cfg.Pipes.ToolDiscovery.AlwaysKeep,
 			}
 
 			// Configure Stage 1: Tool Discovery API endpoint
-			apiEndpoint := g.cfg().Pipes.ToolDiscovery.Compresr.Endpoint
-			if apiEndpoint == "" && g.cfg().URLs.Compresr != "" {
+			apiEndpoint := cfg.Pipes.ToolDiscovery.Compresr.Endpoint
+			if apiEndpoint == "" && cfg.URLs.Compresr != "" {
 				// No endpoint configured, use default path with base URL
-				apiEndpoint = strings.TrimRight(g.cfg().URLs.Compresr, "/") + "/api/compress/tool-discovery/"
-			} else if strings.HasPrefix(apiEndpoint, "/") && g.cfg().URLs.Compresr != "" {
+				apiEndpoint = strings.TrimRight(cfg.URLs.Compresr, "/") + "/api/compress/tool-discovery/"
+			} else if strings.HasPrefix(apiEndpoint, "/") && cfg.URLs.Compresr != "" {
 				// Relative path configured — join with base URL
-				apiEndpoint = strings.TrimRight(g.cfg().URLs.Compresr, "/") + apiEndpoint
+				apiEndpoint = strings.TrimRight(cfg.URLs.Compresr, "/") + apiEndpoint
 			}
 			opts.APIEndpoint = apiEndpoint
-			opts.ProviderAuth = g.cfg().Pipes.ToolDiscovery.Compresr.APIKey
-			opts.APIModel = g.cfg().Pipes.ToolDiscovery.Compresr.Model
-			opts.APITimeout = g.cfg().Pipes.ToolDiscovery.Compresr.Timeout
+			opts.ProviderAuth = cfg.Pipes.ToolDiscovery.Compresr.APIKey
+			opts.APIModel = cfg.Pipes.ToolDiscovery.Compresr.Model
+			opts.APITimeout = cfg.Pipes.ToolDiscovery.Compresr.Timeout
 
 			// Configure Stage 2: Schema Compression (per-tool compression)
-			schemaCfg := g.cfg().Pipes.ToolDiscovery.SchemaCompression
+			schemaCfg := cfg.Pipes.ToolDiscovery.SchemaCompression
 			schemaEndpoint := schemaCfg.Endpoint
-			if schemaEndpoint == "" && g.cfg().URLs.Compresr != "" {
-				schemaEndpoint = strings.TrimRight(g.cfg().URLs.Compresr, "/") + "/api/compress/tool-output/"
-			} else if strings.HasPrefix(schemaEndpoint, "/") && g.cfg().URLs.Compresr != "" {
-				schemaEndpoint = strings.TrimRight(g.cfg().URLs.Compresr, "/") + schemaEndpoint
+			if schemaEndpoint == "" && cfg.URLs.Compresr != "" {
+				schemaEndpoint = strings.TrimRight(cfg.URLs.Compresr, "/") + "/api/compress/tool-output/"
+			} else if strings.HasPrefix(schemaEndpoint, "/") && cfg.URLs.Compresr != "" {
+				schemaEndpoint = strings.TrimRight(cfg.URLs.Compresr, "/") + schemaEndpoint
 			}
 			schemaAPIKey := schemaCfg.APIKey
 			if schemaAPIKey == "" {
-				schemaAPIKey = g.cfg().Pipes.ToolDiscovery.Compresr.APIKey // Fall back to Stage 1 key
+				schemaAPIKey = cfg.Pipes.ToolDiscovery.Compresr.APIKey // Fall back to Stage 1 key
 			}
 			opts.SchemaCompression = SchemaCompressionOpts{
 				Enabled:        schemaCfg.Enabled,
@@ -140,6 +143,8 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 				ecHandler.WithExpandLog(g.expandLog, requestID, pipeCtx.CostSessionID)
 			}
 			ecHandler.WithExpandCallsLog(g.tracker.ExpandCallsLogger(), pipeCtx.ToolOutputCompressions)
+			ecHandler.WithToolSavings(g.toolSavings)
+			ecHandler.WithPaging(cfg.Pipes.ToolOutput.ExpandPageBytes, cfg.Pipes.ToolOutput.ExpandBudgetBytes)
 			handlers = append(handlers, ecHandler)
 		}
 
@@ -202,14 +207,18 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 		if result != nil {
 			forwardLatency = result.ForwardLatency
 		}
+		errorCode, retryable := ClassifyUpstreamError(err, monitoring.ErrorCodePhantomLoopFailed)
+		if err == nil {
+			errorCode, retryable = monitoring.ErrorCodePhantomLoopFailed, true
+		}
 		g.recordRequestTelemetry(telemetryParams{
 			requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path,
 			clientIP: r.RemoteAddr, requestBodySize: len(originalBody), responseBodySize: 0,
 			provider: providerName, pipeType: pipeType, pipeStrategy: pipeStrategy, originalBodySize: originalBodySize,
-			compressionUsed: compressionUsed, statusCode: 502, errorMsg: "phantom loop failed",
+			compressionUsed: compressionUsed, statusCode: 502, errorMsg: "phantom loop failed", errorCode: errorCode, retryable: retryable,
 			compressLatency: compressLatency, forwardLatency: forwardLatency, pipeCtx: pipeCtx,
 			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize,
-			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 			requestHeaders: r.Header, responseHeaders: nil, upstreamURL: "", fallbackReason: "",
 		})
 		g.writeError(w, "upstream request failed", http.StatusBadGateway)
@@ -263,7 +272,7 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 		phantomLoopUsage:   phantomUsage,
 		forwardBody:        forwardBody,
 		compressedBodySize: compressedBodySize,
-		authModeInitial:    authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+		authModeInitial:    authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 		requestHeaders: r.Header, responseHeaders: result.Response.Header, upstreamURL: func() string {
 			if result.Response.Request != nil {
 				return result.Response.Request.URL.String()
@@ -291,6 +300,11 @@ func (g *Gateway) handleNonStreaming(w http.ResponseWriter, r *http.Request, for
 		g.ensureSessionToolsCatalog(pipeCtx, forwardBody)
 	}
 
+	g.recordFixture(adapter, pipeCtx, detectClientAgent(r.Header), originalBody, responseBody, result.Response.StatusCode, false)
+
+	// Restore PII tokens the model echoed back (telemetry above keeps the masked body).
+	responseBody = g.unmaskPIIResponse(responseBody, pipeCtx.Flags)
+
 	// Write response — explicitly set Content-Type to prevent browser MIME sniffing (XSS mitigation).
 	copyHeaders(w, result.Response.Header)
 	addPreemptiveHeaders(w, pipeCtx.PreemptiveHeaders)
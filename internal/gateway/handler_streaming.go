@@ -13,17 +13,20 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	"github.com/compresr/context-gateway/internal/monitoring"
 	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/tracing"
 )
 
 // handleStreamingWithExpand handles streaming requests with expand_context support.
 // When expand_context is enabled:
-//  1. Buffer the streaming response (detect expand_context calls)
+//  1. Watch the streaming response for expand_context calls, relaying events up
+//     to the first tool call (or buffering it whole, see stream_holdback.go)
 //  2. If expand_context detected -> rewrite history, re-send to LLM
-//  3. If not detected -> flush buffer to client
+//  3. If not detected -> flush the held-back rest to client
 //
 // This implements "selective replace" design: only requested tools are expanded,
 // keeping history clean and maximizing KV-cache prefix hits.
@@ -32,6 +35,24 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	pipeType PipeType, pipeStrategy string, originalBodySize int, compressionUsed bool,
 	compressLatency time.Duration, originalBody []byte, expandEnabled bool, compressedBodySize int) {
 
+	// Ping the client until the upstream answers (see sse_keepalive.go).
+	if keepalive := g.startSSEKeepalive(w, r, adapter); keepalive != nil {
+		defer keepalive.stop()
+		w = keepalive
+	}
+
+	// Measure TTFB/TTFT on everything relayed to the client below.
+	timing := newStreamTimingWriter(w)
+	pipeCtx.streamTiming = timing
+	w = timing
+
+	// Restore PII tokens in relayed events (see pii_response.go).
+	if g.piiUnmaskEnabled(pipeCtx.Flags) {
+		piiWriter := newPIIStreamWriter(w, newPIISSERewriter(g))
+		defer piiWriter.close()
+		w = piiWriter
+	}
+
 	provider := adapter.Name()
 	g.requestLogger.LogOutgoing(&monitoring.OutgoingRequestInfo{
 		RequestID: requestID, Provider: provider, TargetURL: r.Header.Get(HeaderTargetURL),
@@ -41,20 +62,24 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	forwardStart := time.Now()
 	resp, authMeta, err := g.forwardPassthrough(r.Context(), r, forwardBody)
 	if err != nil {
+		errorCode, retryable := ClassifyUpstreamError(err, monitoring.ErrorCodeUpstreamUnavailable)
 		g.recordRequestTelemetry(telemetryParams{
 			requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path,
 			clientIP: r.RemoteAddr, requestBodySize: len(originalBody), responseBodySize: 0,
 			provider: provider, pipeType: pipeType, pipeStrategy: pipeStrategy + "_streaming", originalBodySize: originalBodySize,
-			compressionUsed: compressionUsed, statusCode: 502, errorMsg: err.Error(),
+			compressionUsed: compressionUsed, statusCode: 502, errorMsg: err.Error(), errorCode: errorCode, retryable: retryable,
 			compressLatency: compressLatency, forwardLatency: time.Since(forwardStart), pipeCtx: pipeCtx,
 			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize,
-			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 			requestHeaders: r.Header, responseHeaders: nil, upstreamURL: "", fallbackReason: "",
 		})
 		log.Error().Err(err).Str("request_id", requestID).Msg("upstream streaming request failed")
 		g.writeError(w, "upstream request failed", http.StatusBadGateway)
 		return
 	}
+	pipeCtx.inflight.setStage(StageStreaming)
+	_, streamSpan := tracing.Start(r.Context(), tracing.SpanStream, attribute.Int("http.response.status_code", resp.StatusCode))
+	defer streamSpan.End()
 
 	// Buffer when phantom tools were injected (the model may call them and we must intercept),
 	// OR when tool discovery filtered tools (gateway_search_tools may be called),
@@ -70,7 +95,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 		defer func() { _ = resp.Body.Close() }()
 		writeStreamingHeaders(w, resp.Header, pipeCtx.PreemptiveHeaders)
 		w.WriteHeader(resp.StatusCode)
-		sseUsage, sseStopReason := g.streamResponse(w, resp.Body)
+		sseUsage, sseStopReason, sseResponseID := g.streamResponse(w, resp.Body, eventStreamDecoderFor(resp.Header))
 
 		upstreamURL := ""
 		if resp.Request != nil {
@@ -82,8 +107,8 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			provider: provider, pipeType: pipeType, pipeStrategy: pipeStrategy + "_streaming", originalBodySize: originalBodySize,
 			compressionUsed: compressionUsed, statusCode: resp.StatusCode,
 			compressLatency: compressLatency, forwardLatency: time.Since(forwardStart), pipeCtx: pipeCtx,
-			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &sseUsage, streamStopReason: sseStopReason,
-			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &sseUsage, streamStopReason: sseStopReason, streamResponseID: sseResponseID,
+			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 			requestHeaders: r.Header, responseHeaders: resp.Header, upstreamURL: upstreamURL, fallbackReason: "",
 		})
 		// Log for each pipe that ran; always write session tool catalog regardless of pipes.
@@ -105,6 +130,20 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	streamBuffer := tooloutput.NewStreamBuffer()
 	usageParser := newSSEUsageParser()
 	var bufferedChunks [][]byte
+	// Bedrock event streams are relayed as is and scanned decoded.
+	events := eventStreamDecoderFor(resp.Header)
+
+	// Optimistic interception: relay events until the first tool call and
+	// hold back only the rest (see stream_holdback.go).
+	var holdback *streamHoldback
+	var recorded bytes.Buffer // Whole stream for fixture recording; bufferedChunks only holds the rest
+	if format := g.streamHoldbackFormat(r, adapter, forwardBody); format != holdbackNone && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		holdback = newStreamHoldback(format, func() *streamRelay {
+			writeStreamingHeaders(w, resp.Header, pipeCtx.PreemptiveHeaders)
+			w.WriteHeader(resp.StatusCode)
+			return newStreamRelay(w, g.cfg().Server, g.metrics)
+		})
+	}
 
 	searchToolName := g.searchToolName()
 
@@ -122,13 +161,34 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	totalBuffered := 0
 	hasSearchToolCall := false
 	hasDeferredToolCall := false
+	clientGone := false
 	for {
 		if r.Context().Err() != nil {
 			log.Debug().Str("request_id", requestID).Msg("client disconnected during stream buffering")
+			clientGone = true
 			break
 		}
 		n, readErr := resp.Body.Read(buf)
-		if n > 0 {
+		if n > 0 && holdback != nil {
+			chunk := buf[:n]
+			usageParser.Feed(chunk)
+			if needsExpandBuffer {
+				_, _ = streamBuffer.ProcessChunk(chunk)
+			}
+			if g.fixtures != nil {
+				recorded.Write(chunk)
+			}
+			if err := holdback.feed(chunk); err != nil {
+				log.Debug().Err(err).Str("request_id", requestID).Msg("client write failed during optimistic relay")
+				clientGone = true
+				break
+			}
+			if holdback.heldLen > MaxStreamBufferSize {
+				log.Warn().Int("bytes", holdback.heldLen).Msg("stream buffer exceeded max size, stopping buffer")
+				pipeCtx.StreamTruncated = true
+				break
+			}
+		} else if n > 0 {
 			totalBuffered += n
 			if totalBuffered > MaxStreamBufferSize {
 				log.Warn().Int("bytes", totalBuffered).Msg("stream buffer exceeded max size, stopping buffer")
@@ -138,16 +198,20 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			chunk := make([]byte, n)
 			copy(chunk, buf[:n])
 			bufferedChunks = append(bufferedChunks, chunk)
-			usageParser.Feed(chunk)
+			scan := chunk
+			if events != nil {
+				scan = events.feed(chunk)
+			}
+			usageParser.Feed(scan)
 
 			// Process for expand_context detection
 			if needsExpandBuffer {
-				_, _ = streamBuffer.ProcessChunk(chunk)
+				_, _ = streamBuffer.ProcessChunk(scan)
 			}
 
 			// Detect gateway_search_tools calls via byte scan
 			if toolSearchActive && !hasSearchToolCall {
-				if bytes.Contains(chunk, []byte(searchToolName)) {
+				if bytes.Contains(scan, []byte(searchToolName)) {
 					hasSearchToolCall = true
 				}
 			}
@@ -158,7 +222,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			// so DeferredCallInterceptor can inject the schema and prompt a retry.
 			if !hasDeferredToolCall && len(deferredToolNames) > 0 {
 				for name := range deferredToolNames {
-					if bytes.Contains(chunk, []byte(name)) {
+					if bytes.Contains(scan, []byte(name)) {
 						hasDeferredToolCall = true
 						break
 					}
@@ -166,20 +230,41 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			}
 		}
 		if readErr != nil {
+			if readErr != io.EOF {
+				markResponseIncomplete(w) // Truncated upstream stream: don't cache it
+			}
 			break
 		}
 	}
 	_ = resp.Body.Close()
 
+	// Phantom calls are tool calls, so with optimistic relaying they can only
+	// be in the held-back part; scanning just that avoids matching tool names
+	// the model merely mentioned in text.
+	if holdback != nil {
+		holdback.finish()
+		bufferedChunks = holdback.heldBytes()
+		held := bytes.Join(bufferedChunks, nil)
+		hasSearchToolCall = toolSearchActive && bytes.Contains(held, []byte(searchToolName))
+		for name := range deferredToolNames {
+			if bytes.Contains(held, []byte(name)) {
+				hasDeferredToolCall = true
+				break
+			}
+		}
+	}
+
 	// Extract usage and stop_reason from buffered SSE chunks
 	bufferedUsage := usageParser.Usage()
 	bufferedStopReason := usageParser.StopReason()
+	bufferedResponseID := usageParser.ResponseID()
 
 	// If gateway_search_tools OR a direct deferred-tool call was detected, re-send as
 	// non-streaming through the phantom loop. The phantom loop handles both SearchToolHandler
 	// (for gateway_search_tools) and DeferredCallInterceptor (for direct stub bypasses).
 	// The phantom loop produces a non-streaming JSON response which we convert back to SSE.
-	if (hasSearchToolCall || hasDeferredToolCall) && toolSearchActive {
+	// Converse streams cannot be resent this way (see nonStreamingRequest).
+	if (hasSearchToolCall || hasDeferredToolCall) && toolSearchActive && !clientGone && !isBedrockConversePath(r.URL.Path) {
 		log.Info().
 			Str("request_id", requestID).
 			Bool("search_tool", hasSearchToolCall).
@@ -188,8 +273,8 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 
 		// Capture the non-streaming response from handleNonStreaming
 		capture := &responseCaptureWriter{header: make(http.Header)}
-		nonStreamBody := setStreamFlag(forwardBody, false)
-		g.handleNonStreaming(capture, r, nonStreamBody, pipeCtx, requestID, startTime, adapter,
+		nonStreamReq, nonStreamBody := nonStreamingRequest(r, forwardBody)
+		g.handleNonStreaming(capture, nonStreamReq, nonStreamBody, pipeCtx, requestID, startTime, adapter,
 			pipeType, pipeStrategy+"_streaming_search_fallback", originalBodySize, compressionUsed, compressLatency, originalBody, expandEnabled, &bufferedUsage, compressedBodySize)
 
 		// Convert the captured JSON response to SSE format for the streaming client
@@ -204,8 +289,29 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			sseBody = jsonToOpenAISSE(capture.body.Bytes())
 		}
 
+		// Part of the original answer is already out: continue it.
+		if holdback != nil && holdback.released() {
+			if capture.statusCode >= 300 {
+				sseBody = sseErrorEvent(holdback.format, capture.body.Bytes())
+			} else {
+				cont := holdback.continuation()
+				sseBody = append(cont.rewrite(sseBody), cont.flush()...)
+			}
+			g.writeBufferedChunks(w, [][]byte{sseBody})
+			return
+		}
+
 		writeStreamingHeaders(w, capture.header, pipeCtx.PreemptiveHeaders)
 		w.Header().Set("Content-Type", "text/event-stream")
+		if events != nil {
+			// The client reads Bedrock event stream frames, not SSE.
+			sseBody = capture.body.Bytes()
+			w.Header().Set("Content-Type", capture.header.Get("Content-Type"))
+			if capture.statusCode < 300 {
+				sseBody = anthropicSSEToEventStream(jsonToAnthropicSSE(sseBody))
+				w.Header().Set("Content-Type", EventStreamContentType)
+			}
+		}
 		w.Header().Del("Content-Length") // SSE streams have no Content-Length
 		w.WriteHeader(capture.statusCode)
 		if _, err := w.Write(sseBody); err != nil {
@@ -217,7 +323,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	// Check if expand_context was called
 	expandCalls := streamBuffer.GetSuppressedCalls()
 
-	if len(expandCalls) > 0 {
+	if len(expandCalls) > 0 && !clientGone {
 		// expand_context detected — use append-only approach (Option B).
 		// Instead of rewriting history (which breaks KV cache), we:
 		// 1. Build the expand_context tool_results with original content from store
@@ -233,7 +339,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 		for _, ec := range expandCalls {
 			phantomCalls = append(phantomCalls, PhantomToolCall{
 				ToolUseID: ec.ToolUseID,
-				Input:     map[string]any{"id": ec.ShadowID},
+				Input:     ec.Arguments(),
 			})
 		}
 
@@ -243,6 +349,9 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			ecHandler.WithExpandLog(g.expandLog, requestID, pipeCtx.CostSessionID)
 		}
 		ecHandler.WithExpandCallsLog(g.tracker.ExpandCallsLogger(), pipeCtx.ToolOutputCompressions)
+		ecHandler.WithToolSavings(g.toolSavings)
+		pipes := g.cfgFor(pipeCtx.Tenant).Pipes
+		ecHandler.WithPaging(pipes.ToolOutput.ExpandPageBytes, pipes.ToolOutput.ExpandBudgetBytes)
 		phantomResult := ecHandler.HandleCalls(phantomCalls, adapter, forwardBody)
 
 		// Build append body: original forwardBody + assistant expand_context call + tool_results
@@ -250,7 +359,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 		appendBody, err := buildExpandAppendBody(forwardBody, expandCalls, phantomResult.ToolResults, adapter)
 		if err != nil {
 			log.Error().Err(err).Msg("streaming: failed to build expand append body")
-			g.flushBufferedResponse(w, resp.Header, pipeCtx.PreemptiveHeaders, bufferedChunks, resp.StatusCode)
+			g.flushStreamRest(w, holdback, resp, pipeCtx.PreemptiveHeaders, bufferedChunks)
 			return
 		}
 
@@ -262,7 +371,7 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 		retryResp, retryMeta, err := g.forwardPassthrough(r.Context(), r, appendBody)
 		if err != nil {
 			log.Error().Err(err).Msg("streaming: failed to re-send after expansion")
-			g.flushBufferedResponse(w, resp.Header, pipeCtx.PreemptiveHeaders, bufferedChunks, resp.StatusCode)
+			g.flushStreamRest(w, holdback, resp, pipeCtx.PreemptiveHeaders, bufferedChunks)
 			return
 		}
 		mergeForwardAuthMeta(&authMeta, retryMeta)
@@ -270,10 +379,20 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 
 		// Stream the retry response (filter expand_context if it calls again)
 		// Also parse usage from the retry stream so we can track the full cost.
-		writeStreamingHeaders(w, retryResp.Header, pipeCtx.PreemptiveHeaders)
-		w.WriteHeader(retryResp.StatusCode)
-
-		retryUsage, retryStopReason := g.streamResponseWithFilterAndUsage(w, retryResp.Body)
+		// When part of the original answer is already out, the retry continues it.
+		var retryUsage adapters.UsageInfo
+		var retryStopReason, retryResponseID string
+		switch {
+		case holdback == nil || !holdback.released():
+			writeStreamingHeaders(w, retryResp.Header, pipeCtx.PreemptiveHeaders)
+			w.WriteHeader(retryResp.StatusCode)
+			retryUsage, retryStopReason, retryResponseID = g.streamResponseWithFilterAndUsage(w, retryResp.Body, nil, eventStreamDecoderFor(retryResp.Header))
+		case retryResp.StatusCode >= 300:
+			errBody, _ := io.ReadAll(io.LimitReader(retryResp.Body, MaxStreamBufferSize))
+			g.writeBufferedChunks(w, [][]byte{sseErrorEvent(holdback.format, errBody)})
+		default:
+			retryUsage, retryStopReason, retryResponseID = g.streamResponseWithFilterAndUsage(w, retryResp.Body, holdback.continuation(), nil)
+		}
 
 		// Combine usage from both streams (initial buffered + retry)
 		combinedUsage := adapters.UsageInfo{
@@ -308,8 +427,8 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			compressLatency: compressLatency, forwardLatency: time.Since(forwardStart), pipeCtx: pipeCtx,
 			expandLoops: 1, expandCallsFound: streamExpandFound, expandCallsNotFound: streamExpandNotFound,
 			expandPenaltyTokens: streamExpandPenaltyTokens,
-			adapter:             adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &combinedUsage, streamStopReason: retryStopReason,
-			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+			adapter:             adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &combinedUsage, streamStopReason: retryStopReason, streamResponseID: retryResponseID,
+			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 			requestHeaders: r.Header, responseHeaders: retryResp.Header, upstreamURL: func() string {
 				if retryResp.Request != nil {
 					return retryResp.Request.URL.String()
@@ -338,11 +457,25 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 		return
 	} else {
 		// No expand_context detected - flush buffered response
-		g.flushBufferedResponse(w, resp.Header, pipeCtx.PreemptiveHeaders, bufferedChunks, resp.StatusCode)
+		g.flushStreamRest(w, holdback, resp, pipeCtx.PreemptiveHeaders, bufferedChunks)
+		if !pipeCtx.StreamTruncated {
+			stream := bytes.Join(bufferedChunks, nil)
+			if holdback != nil {
+				stream = recorded.Bytes()
+			}
+			g.recordFixture(adapter, pipeCtx, detectClientAgent(r.Header), originalBody, stream, resp.StatusCode, true)
+		}
 
 		// If stream was truncated, inject an SSE error event so the client knows
 		if pipeCtx.StreamTruncated {
 			errorEvent := []byte("event: error\ndata: {\"type\":\"stream_truncated\",\"message\":\"Response exceeded buffer limit\"}\n\n")
+			if events != nil {
+				errorEvent = encodeEventStreamMessage([][2]string{
+					{":exception-type", "streamTruncated"},
+					{":content-type", "application/json"},
+					{":message-type", "exception"},
+				}, []byte(`{"message":"Response exceeded buffer limit"}`))
+			}
 			if _, err := w.Write(errorEvent); err != nil {
 				log.Debug().Err(err).Msg("client write failed for stream_truncated event")
 			}
@@ -357,8 +490,8 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 			provider: provider, pipeType: pipeType, pipeStrategy: pipeStrategy + "_streaming", originalBodySize: originalBodySize,
 			compressionUsed: compressionUsed, statusCode: resp.StatusCode,
 			compressLatency: compressLatency, forwardLatency: time.Since(forwardStart), pipeCtx: pipeCtx,
-			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &bufferedUsage, streamStopReason: bufferedStopReason,
-			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed,
+			adapter: adapter, requestBody: originalBody, forwardBody: forwardBody, compressedBodySize: compressedBodySize, streamUsage: &bufferedUsage, streamStopReason: bufferedStopReason, streamResponseID: bufferedResponseID,
+			authModeInitial: authMeta.InitialMode, authModeEffective: authMeta.EffectiveMode, authFallbackUsed: authMeta.FallbackUsed, upstreamRetries: authMeta.Retries, modelFallback: authMeta.ModelFallback,
 			requestHeaders: r.Header, responseHeaders: resp.Header, upstreamURL: func() string {
 				if resp.Request != nil {
 					return resp.Request.URL.String()
@@ -382,10 +515,29 @@ func (g *Gateway) handleStreamingWithExpand(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// nonStreamingRequest returns the request and body for resending a streamed
+// request without streaming. Bedrock selects streaming by path, so an
+// invoke-with-response-stream request goes to /invoke with its body
+// unchanged. Converse streams have no such counterpart: the /converse JSON
+// response cannot be turned back into Anthropic events.
+func nonStreamingRequest(r *http.Request, body []byte) (*http.Request, []byte) {
+	path, ok := bedrockInvokePath(r.URL.Path)
+	if !ok {
+		return r, setStreamFlag(body, false)
+	}
+	nr := r.Clone(r.Context())
+	nr.URL.Path = path
+	nr.URL.RawPath = ""
+	return nr, body
+}
+
 // writeStreamingHeaders sets common streaming response headers.
 func writeStreamingHeaders(w http.ResponseWriter, upstream http.Header, preemptiveHeaders map[string]string) {
 	copyHeaders(w, upstream)
 	addPreemptiveHeaders(w, preemptiveHeaders)
+	// The relayed body can differ from the upstream one (filtered phantom
+	// calls, spliced retries), so its length is not known up front.
+	w.Header().Del("Content-Length")
 	if w.Header().Get("Content-Type") == "" {
 		w.Header().Set("Content-Type", "text/event-stream")
 	}
@@ -399,29 +551,49 @@ func writeStreamingHeaders(w http.ResponseWriter, upstream http.Header, preempti
 func (g *Gateway) flushBufferedResponse(w http.ResponseWriter, headers http.Header, preemptiveHeaders map[string]string, chunks [][]byte, statusCode int) {
 	writeStreamingHeaders(w, headers, preemptiveHeaders)
 	w.WriteHeader(statusCode)
+	g.writeBufferedChunks(w, chunks)
+}
 
-	flusher, ok := w.(http.Flusher)
+// flushStreamRest writes what the client has not seen of a stream: the whole
+// buffered response, or the rest held back after optimistic relaying.
+func (g *Gateway) flushStreamRest(w http.ResponseWriter, hb *streamHoldback, resp *http.Response, preemptiveHeaders map[string]string, chunks [][]byte) {
+	if hb != nil && hb.released() {
+		g.writeBufferedChunks(w, chunks)
+		return
+	}
+	g.flushBufferedResponse(w, resp.Header, preemptiveHeaders, chunks, resp.StatusCode)
+}
+
+// writeBufferedChunks writes chunks after the response headers were sent.
+func (g *Gateway) writeBufferedChunks(w http.ResponseWriter, chunks [][]byte) {
+	cw := newClientWriter(w, g.cfg().Server, g.metrics)
+	defer cw.done()
 	for _, chunk := range chunks {
-		if _, err := w.Write(chunk); err != nil {
+		if err := cw.write(chunk); err != nil {
 			log.Debug().Err(err).Msg("client write failed during buffered flush")
 			return
 		}
-		if ok {
-			flusher.Flush()
-		}
 	}
 }
 
 // streamResponseWithFilterAndUsage is like streamResponseWithFilter but also
-// parses SSE usage from the stream. Returns the extracted usage info and stop_reason.
-func (g *Gateway) streamResponseWithFilterAndUsage(w http.ResponseWriter, reader io.Reader) (adapters.UsageInfo, string) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+// parses SSE usage from the stream. Returns the extracted usage info, stop_reason,
+// and Responses API response ID. A non-nil cont rewrites the stream as the
+// continuation of one the client already started receiving. A non-nil events
+// decodes a Bedrock event stream for usage; its frames are relayed unfiltered.
+func (g *Gateway) streamResponseWithFilterAndUsage(w http.ResponseWriter, reader io.Reader, cont *sseContinuation, events *eventStreamDecoder) (adapters.UsageInfo, string, string) {
+	if events != nil {
+		return g.streamResponse(w, reader, events)
+	}
+	if _, ok := w.(http.Flusher); !ok {
 		log.Warn().Msg("streaming not supported, falling back to buffered")
 		_, _ = io.Copy(w, reader)
-		return adapters.UsageInfo{}, ""
+		return adapters.UsageInfo{}, "", ""
 	}
 
+	relay := newStreamRelay(w, g.cfg().Server, g.metrics)
+	defer relay.close()
+
 	streamBuffer := tooloutput.NewStreamBuffer()
 	usageParser := newSSEUsageParser()
 	buf := make([]byte, DefaultBufferSize)
@@ -434,31 +606,44 @@ func (g *Gateway) streamResponseWithFilterAndUsage(w http.ResponseWriter, reader
 
 			// Filter expand_context from the stream
 			filtered, _ := streamBuffer.ProcessChunk(chunk)
+			if cont != nil {
+				filtered = cont.rewrite(filtered)
+			}
 			if len(filtered) > 0 {
-				_, _ = w.Write(filtered)
-				flusher.Flush()
+				if _, writeErr := relay.Write(filtered); writeErr != nil {
+					log.Debug().Err(writeErr).Msg("client write failed")
+					break
+				}
 			}
 		}
 		if err != nil {
 			if err != io.EOF {
 				log.Debug().Err(err).Msg("error reading stream")
+				markResponseIncomplete(w)
 			}
 			break
 		}
 	}
-	return usageParser.Usage(), usageParser.StopReason()
+	if cont != nil {
+		if rest := cont.flush(); len(rest) > 0 {
+			_, _ = relay.Write(rest)
+		}
+	}
+	return usageParser.Usage(), usageParser.StopReason(), usageParser.ResponseID()
 }
 
-// streamResponse streams data from reader to writer with flushing.
-// Returns usage and stop_reason extracted from SSE events.
-func (g *Gateway) streamResponse(w http.ResponseWriter, reader io.Reader) (adapters.UsageInfo, string) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
+// streamResponse streams data from reader to writer through a streamRelay.
+// Returns usage, stop_reason, and Responses API response ID extracted from SSE events.
+// A non-nil events decodes a Bedrock event stream before usage parsing.
+func (g *Gateway) streamResponse(w http.ResponseWriter, reader io.Reader, events *eventStreamDecoder) (adapters.UsageInfo, string, string) {
+	if _, ok := w.(http.Flusher); !ok {
 		log.Warn().Msg("streaming not supported, falling back to buffered")
 		_, _ = io.Copy(w, reader)
-		return adapters.UsageInfo{}, ""
+		return adapters.UsageInfo{}, "", ""
 	}
 
+	relay := newStreamRelay(w, g.cfg().Server, g.metrics)
+	defer relay.close()
 	usageParser := newSSEUsageParser()
 
 	buf := make([]byte, DefaultBufferSize)
@@ -466,22 +651,26 @@ func (g *Gateway) streamResponse(w http.ResponseWriter, reader io.Reader) (adapt
 		n, err := reader.Read(buf)
 		if n > 0 {
 			chunk := buf[:n]
-			usageParser.Feed(chunk)
+			if events != nil {
+				usageParser.Feed(events.feed(chunk))
+			} else {
+				usageParser.Feed(chunk)
+			}
 
-			if _, writeErr := w.Write(chunk); writeErr != nil {
+			if _, writeErr := relay.Write(chunk); writeErr != nil {
 				log.Debug().Err(writeErr).Msg("client disconnected")
 				break
 			}
-			flusher.Flush()
 		}
 		if err != nil {
 			if err != io.EOF {
 				log.Debug().Err(err).Msg("error reading stream")
+				markResponseIncomplete(w)
 			}
 			break
 		}
 	}
-	return usageParser.Usage(), usageParser.StopReason()
+	return usageParser.Usage(), usageParser.StopReason(), usageParser.ResponseID()
 }
 
 // SSE Usage Parser
@@ -495,6 +684,13 @@ type sseUsage struct {
 	// OpenAI Chat Completions fields
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
+	// OpenRouter: credits charged for the response, in USD
+	Cost float64 `json:"cost"`
+
+	// inputIncludesCache is set when the input count includes cache reads
+	// (Responses API input_tokens, Gemini promptTokenCount). Chat Completions
+	// prompt_tokens always does; Anthropic input_tokens never does.
+	inputIncludesCache bool
 }
 
 type ssePayload struct {
@@ -505,11 +701,18 @@ type ssePayload struct {
 	} `json:"message"`
 	// Responses API: response.completed wraps usage and output inside "response"
 	Response struct {
+		ID     string   `json:"id"`
 		Usage  sseUsage `json:"usage"`
 		Output []struct {
 			Type string `json:"type"`
 		} `json:"output"`
 	} `json:"response"`
+	// Gemini: every streamGenerateContent chunk carries cumulative usageMetadata
+	UsageMetadata struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
 	// Anthropic: message_delta carries stop_reason
 	Delta struct {
 		StopReason string `json:"stop_reason"`
@@ -518,15 +721,34 @@ type ssePayload struct {
 	Choices []struct {
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	// Gemini: candidates carry finishReason
+	Candidates []struct {
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	// Ollama native: the final "done" line carries counts and done_reason
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	DoneReason      string `json:"done_reason"`
 }
 
-// sseUsageParser incrementally parses Anthropic SSE events and extracts usage.
-// It only reads structured "data: {json}" events to avoid false positives from
-// arbitrary text that might contain token-like key names.
+// sseUsageParser incrementally parses SSE events (Anthropic, OpenAI chat and
+// Responses) and Ollama's NDJSON lines, and extracts usage. It also reads
+// Gemini usageMetadata chunks, but native streamGenerateContent responses carry
+// no "stream" flag: the gateway reads them whole and GeminiAdapter.ExtractUsage
+// takes their usage instead.
+// It only reads structured "data: {json}" events and whole JSON lines to avoid
+// false positives from arbitrary text that might contain token-like key names.
 type sseUsageParser struct {
 	buffer     []byte
 	usage      adapters.UsageInfo
 	stopReason string // last non-empty stop_reason / finish_reason seen
+	responseID string // Responses API response ID (response.created / response.completed)
+}
+
+// ResponseID returns the Responses API response ID seen in the stream, if any.
+func (p *sseUsageParser) ResponseID() string {
+	p.parse(true)
+	return p.responseID
 }
 
 // StopReason returns the stop/finish reason extracted from the SSE stream.
@@ -555,6 +777,15 @@ func (p *sseUsageParser) Feed(chunk []byte) {
 	p.parse(false)
 }
 
+// ParseStreamUsage returns the usage and stop reason the gateway records for a
+// complete SSE response stream. Used by the adapter conformance suite to check
+// streaming usage against each adapter's non-streaming ExtractUsage.
+func ParseStreamUsage(stream []byte) (adapters.UsageInfo, string) {
+	p := newSSEUsageParser()
+	p.Feed(stream)
+	return p.Usage(), p.StopReason()
+}
+
 func (p *sseUsageParser) Usage() adapters.UsageInfo {
 	p.parse(true)
 	return p.usage
@@ -572,6 +803,12 @@ func (p *sseUsageParser) parse(flush bool) {
 }
 
 func nextSSEEvent(buf []byte, flush bool) ([]byte, []byte, bool) {
+	// Ollama NDJSON: every line is an event
+	if len(buf) > 0 && buf[0] == '{' {
+		if idx := bytes.IndexByte(buf, '\n'); idx >= 0 {
+			return buf[:idx], buf[idx+1:], true
+		}
+	}
 	if idx := bytes.Index(buf, []byte("\r\n\r\n")); idx >= 0 {
 		return buf[:idx], buf[idx+4:], true
 	}
@@ -596,6 +833,10 @@ func (p *sseUsageParser) parseEvent(event []byte) {
 		if len(line) == 0 {
 			continue
 		}
+		if line[0] == '{' {
+			dataLines = append(dataLines, line)
+			continue
+		}
 		if !bytes.HasPrefix(line, []byte("data:")) {
 			continue
 		}
@@ -631,7 +872,7 @@ func (p *sseUsageParser) parseEvent(event []byte) {
 		}
 	}
 	if payload.Response.Usage.CacheReadInputTokens == 0 {
-		if cached := gjson.GetBytes(data, "response.usage.prompt_tokens_details.cached_tokens").Int(); cached > 0 {
+		if cached := gjson.GetBytes(data, "response.usage.input_tokens_details.cached_tokens").Int(); cached > 0 {
 			payload.Response.Usage.CacheReadInputTokens = int(cached)
 		}
 	}
@@ -639,8 +880,29 @@ func (p *sseUsageParser) parseEvent(event []byte) {
 	p.applyUsage(payload.Message.Usage)
 	p.applyUsage(payload.Usage)
 
+	if meta := payload.UsageMetadata; meta.PromptTokenCount > 0 || meta.CandidatesTokenCount > 0 {
+		p.applyUsage(sseUsage{
+			InputTokens:          meta.PromptTokenCount,
+			OutputTokens:         meta.CandidatesTokenCount,
+			CacheReadInputTokens: meta.CachedContentTokenCount,
+			inputIncludesCache:   true,
+		})
+	}
+
+	if payload.PromptEvalCount > 0 || payload.EvalCount > 0 {
+		p.applyUsage(sseUsage{InputTokens: payload.PromptEvalCount, OutputTokens: payload.EvalCount})
+	}
+	if payload.DoneReason != "" {
+		p.stopReason = payload.DoneReason
+	}
+
+	if payload.Response.ID != "" {
+		p.responseID = payload.Response.ID
+	}
+
 	// Responses API: response.completed events have usage nested under "response"
 	if payload.Type == "response.completed" {
+		payload.Response.Usage.inputIncludesCache = true
 		p.applyUsage(payload.Response.Usage)
 		// Derive a synthetic stop reason from the output array.
 		// If any output item is a function_call the agent is still working;
@@ -669,6 +931,12 @@ func (p *sseUsageParser) parseEvent(event []byte) {
 			break
 		}
 	}
+	for _, c := range payload.Candidates {
+		if c.FinishReason != "" {
+			p.stopReason = c.FinishReason
+			break
+		}
+	}
 }
 
 func (p *sseUsageParser) applyUsage(u sseUsage) {
@@ -684,9 +952,14 @@ func (p *sseUsageParser) applyUsage(u sseUsage) {
 	}
 
 	if inputTokens > 0 {
-		// Anthropic's input_tokens includes cache tokens; subtract them
-		// so InputTokens represents only non-cached input (avoids double-counting in cost calculation).
-		nonCached := inputTokens - u.CacheCreationInputTokens - u.CacheReadInputTokens
+		// InputTokens represents only non-cached input, matching the adapters'
+		// ExtractUsage (avoids double-counting in cost calculation). Anthropic's
+		// input_tokens already excludes cache; OpenAI and Gemini counts include
+		// cache reads, so subtract them.
+		nonCached := inputTokens
+		if u.PromptTokens > 0 || u.inputIncludesCache {
+			nonCached -= u.CacheReadInputTokens
+		}
 		if nonCached < 0 {
 			nonCached = 0
 		}
@@ -708,6 +981,9 @@ func (p *sseUsageParser) applyUsage(u sseUsage) {
 	if u.CacheReadInputTokens > 0 {
 		p.usage.CacheReadInputTokens = u.CacheReadInputTokens
 	}
+	if u.Cost > 0 {
+		p.usage.CostUSD = u.Cost
+	}
 
 	// TotalTokens = original input_tokens (which includes cache) + output
 	p.usage.TotalTokens = p.usage.InputTokens + p.usage.OutputTokens +
@@ -1100,6 +1376,15 @@ func getIntFromMap(m map[string]any, key string) int {
 	return 0
 }
 
+// expandCallArguments returns the JSON arguments string of an expand_context call.
+func expandCallArguments(ec tooloutput.ExpandContextCall) string {
+	args, err := json.Marshal(ec.Arguments())
+	if err != nil {
+		return fmt.Sprintf(`{"id":%q}`, ec.ShadowID)
+	}
+	return string(args)
+}
+
 // buildExpandAppendBody appends the assistant's expand_context tool call and the
 // tool results with expanded content to the request body. Uses sjson to append
 // messages at the end, preserving the entire KV-cache prefix.
@@ -1111,12 +1396,10 @@ func buildExpandAppendBody(body []byte, expandCalls []tooloutput.ExpandContextCa
 		contentBlocks := make([]any, 0, len(expandCalls))
 		for _, ec := range expandCalls {
 			contentBlocks = append(contentBlocks, map[string]any{
-				"type": "tool_use",
-				"id":   ec.ToolUseID,
-				"name": ExpandContextToolName,
-				"input": map[string]any{
-					"id": ec.ShadowID,
-				},
+				"type":  "tool_use",
+				"id":    ec.ToolUseID,
+				"name":  ExpandContextToolName,
+				"input": ec.Arguments(),
 			})
 		}
 		assistantMsg := map[string]any{
@@ -1155,7 +1438,7 @@ func buildExpandAppendBody(body []byte, expandCalls []tooloutput.ExpandContextCa
 					"type":      "function_call",
 					"call_id":   ec.ToolUseID,
 					"name":      ExpandContextToolName,
-					"arguments": fmt.Sprintf(`{"id":"%s"}`, ec.ShadowID),
+					"arguments": expandCallArguments(ec),
 				}
 				fcJSON, err := json.Marshal(funcCall)
 				if err != nil {
@@ -1203,7 +1486,7 @@ func buildExpandAppendBody(body []byte, expandCalls []tooloutput.ExpandContextCa
 					"type": "function",
 					"function": map[string]any{
 						"name":      ExpandContextToolName,
-						"arguments": fmt.Sprintf(`{"id":"%s"}`, ec.ShadowID),
+						"arguments": expandCallArguments(ec),
 					},
 				})
 			}
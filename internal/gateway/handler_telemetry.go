@@ -55,6 +55,8 @@ type telemetryParams struct {
 	compressionUsed     bool
 	statusCode          int
 	errorMsg            string
+	errorCode           monitoring.ErrorCode // Taxonomy code; derived from statusCode when empty
+	retryable           bool
 	compressLatency     time.Duration
 	forwardLatency      time.Duration
 	expandLoops         int
@@ -62,18 +64,23 @@ type telemetryParams struct {
 	expandCallsNotFound int
 	expandPenaltyTokens int // Tiktoken count for savings tracker
 	pipeCtx             *PipelineContext
+	model               string // Used when the request body names no model (Realtime sessions)
 	// For usage extraction from API response
 	adapter            adapters.Adapter
 	requestBody        []byte              // Original request from client
 	responseBody       []byte              // Response from LLM
 	streamUsage        *adapters.UsageInfo // Pre-extracted usage from SSE stream (streaming only)
 	streamStopReason   string              // stop_reason / finish_reason from SSE stream (streaming only)
+	streamResponseID   string              // Responses API response ID from SSE stream (streaming only)
 	phantomLoopUsage   *adapters.UsageInfo // Accumulated usage across all phantom loop iterations
 	forwardBody        []byte              // Compressed request sent to LLM (for proxy interaction tracking)
 	compressedBodySize int                 // Post-compression, pre-tool-injection body size (for accurate metrics)
 	authModeInitial    string
 	authModeEffective  string
 	authFallbackUsed   bool
+	upstreamRetries    int
+	modelFallback      string // Model that answered after the requested one was overloaded
+	batchItem          bool   // Message Batches item, billed at the batch discount
 	// For verbose payloads logging
 	requestHeaders  http.Header // Request headers from client
 	responseHeaders http.Header // Response headers from upstream
@@ -83,15 +90,12 @@ type telemetryParams struct {
 
 // recordRequestTelemetry records a complete request event.
 func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
-	// calculateMetrics uses tiktoken on actual bodies.
-	m := g.calculateMetrics(params.requestBody, params.forwardBody, params.originalBodySize, params.compressedBodySize)
-
 	// Extract model and usage from request/response using adapter
 	var model string
 	var usage adapters.UsageInfo
 
 	if params.adapter != nil {
-		model = params.adapter.ExtractModel(params.requestBody)
+		model = requestModel(params.adapter, params.requestBody, params.path)
 
 		// Prefer phantom loop accumulated usage (covers ALL iterations, not just the last).
 		// Fall back to adapter extraction (single response) or SSE usage (streaming).
@@ -106,6 +110,38 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 			usage = *params.streamUsage
 		}
 	}
+	if model == "" {
+		model = params.model
+	}
+	// Cost and savings follow the model that answered.
+	var modelFallbackFrom string
+	if params.modelFallback != "" {
+		modelFallbackFrom, model = model, params.modelFallback
+	}
+
+	// calculateMetrics counts the actual bodies with the configured token counter.
+	m := g.calculateMetrics(model, params.requestBody, params.forwardBody, params.originalBodySize, params.compressedBodySize)
+
+	// Classify the outcome. Explicit codes (transport failures, phantom loop) win;
+	// otherwise the upstream status decides, and a successful request whose pipe
+	// failed is recorded as degraded (compression_failed, success=true).
+	errorCode, retryable := params.errorCode, params.retryable
+	if errorCode == "" {
+		errorCode, retryable = monitoring.ClassifyStatus(params.statusCode)
+	}
+	if errorCode == "" && params.pipeCtx.PipeFailed {
+		errorCode = monitoring.ErrorCodeCompressionFailed
+	}
+	g.recordError(errorCode)
+	g.selfMetrics.RecordRequest()
+	g.recordSessionActivity(params, model)
+	if params.upstreamURL != "preemptive_summarization" {
+		g.observeUpstreamHealth(params.provider, errorCode, params.statusCode)
+		if g.metrics != nil {
+			g.metrics.RecordProviderRequest(params.provider, params.statusCode > 0 && params.statusCode < 400, time.Since(params.startTime))
+			g.metrics.RecordPhantomLoops(params.expandLoops)
+		}
+	}
 
 	// Build the RequestEvent with base fields
 	event := &monitoring.RequestEvent{
@@ -116,6 +152,8 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 		ClientIP:                 params.clientIP,
 		Provider:                 params.provider,
 		Model:                    model,
+		Tenant:                   params.pipeCtx.Tenant,
+		Client:                   params.pipeCtx.Client,
 		RequestBodySize:          params.requestBodySize,
 		ResponseBodySize:         params.responseBodySize,
 		StatusCode:               params.statusCode,
@@ -132,12 +170,16 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 		ExpandCallsNotFound:      params.expandCallsNotFound,
 		Success:                  params.statusCode < 400,
 		Error:                    params.errorMsg,
+		ErrorCode:                errorCode,
+		Retryable:                retryable,
 		CompressionLatencyMs:     params.compressLatency.Milliseconds(),
 		ForwardLatencyMs:         params.forwardLatency.Milliseconds(),
 		TotalLatencyMs:           time.Since(params.startTime).Milliseconds(),
 		AuthModeInitial:          params.authModeInitial,
 		AuthModeEffective:        params.authModeEffective,
 		AuthFallbackUsed:         params.authFallbackUsed,
+		UpstreamRetries:          params.upstreamRetries,
+		ModelFallbackFrom:        modelFallbackFrom,
 		InputTokens:              usage.InputTokens,
 		OutputTokens:             usage.OutputTokens,
 		CacheCreationInputTokens: usage.CacheCreationInputTokens,
@@ -153,8 +195,41 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 		IsMainAgent:                g.isMainConversation(params.pipeCtx.StableFingerprint),
 	}
 
-	// Calculate cost for this request (for debugging/transparency)
-	if usage.TotalTokens > 0 && model != "" {
+	if len(params.pipeCtx.PipeSLO) > 0 {
+		event.SLO = params.pipeCtx.PipeSLO
+	}
+	event.PIIMasked = params.pipeCtx.PIIMasked
+	event.CustomPipes = params.pipeCtx.CustomPipes
+	event.PromptInjection = injectionReport(params.pipeCtx)
+
+	// Streaming latency: only once bytes have reached the client. The phantom-loop
+	// fallback records telemetry from handleNonStreaming before its SSE is written.
+	if timing := params.pipeCtx.streamTiming; timing != nil {
+		firstByte, firstToken := timing.latencies(params.startTime)
+		if firstByte > 0 {
+			event.FirstByteLatencyMs = firstByte.Milliseconds()
+			event.FirstTokenLatencyMs = firstToken.Milliseconds()
+			if g.metrics != nil {
+				g.metrics.RecordStreamLatency(firstByte, firstToken, time.Since(params.startTime))
+			}
+		}
+	}
+
+	// Calculate cost for this request (for debugging/transparency).
+	// LiteLLM's and OpenRouter's reported costs cover one response, so phantom
+	// loops are priced here.
+	// Self-hosted models are free: a reported cost of 0 still records their tokens.
+	reportedCost, hasReportedCost := 0.0, false
+	if isLocalModel(params.provider, params.upstreamURL) {
+		hasReportedCost = true
+	} else if g.cfg().LiteLLM.Enabled && params.expandLoops == 0 {
+		reportedCost, hasReportedCost = liteLLMResponseCost(params.responseHeaders)
+	} else if usage.CostUSD > 0 && params.expandLoops == 0 {
+		reportedCost, hasReportedCost = usage.CostUSD, true
+	}
+	if hasReportedCost {
+		event.CostUSD = reportedCost
+	} else if usage.TotalTokens > 0 && model != "" {
 		pricing := costcontrol.GetModelPricing(model)
 		if usage.CacheCreationInputTokens > 0 || usage.CacheReadInputTokens > 0 {
 			event.CostUSD = costcontrol.CalculateCostWithCache(
@@ -163,6 +238,11 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 		} else {
 			event.CostUSD = costcontrol.CalculateCost(usage.InputTokens, usage.OutputTokens, pricing)
 		}
+		// The discounted cost is recorded as is, like a reported one.
+		if params.batchItem {
+			event.CostUSD *= costcontrol.BatchMultiplier
+			reportedCost, hasReportedCost = event.CostUSD, true
+		}
 	}
 
 	// Add verbose payloads if enabled
@@ -213,6 +293,7 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 	}
 
 	g.tracker.RecordRequest(event)
+	g.telemetryShipper.Enqueue(event)
 
 	// Record to savings tracker for /savings command
 	if g.savings != nil {
@@ -232,10 +313,28 @@ func (g *Gateway) recordRequestTelemetry(params telemetryParams) {
 	// Streaming responses have empty bodies so ExtractUsage returns zeros — skip rather
 	// than estimate, since estimation ignores caching and overestimates by 10x+.
 	// Only record for successful requests — Anthropic doesn't bill for failed requests.
-	if g.costTracker != nil && params.pipeCtx != nil && params.pipeCtx.CostSessionID != "" && usage.TotalTokens > 0 && params.statusCode < 400 {
-		g.costTracker.RecordUsage(params.pipeCtx.CostSessionID, model,
-			usage.InputTokens, usage.OutputTokens,
-			usage.CacheCreationInputTokens, usage.CacheReadInputTokens)
+	// A cost reported by LiteLLM or OpenRouter, or the zero cost of a local model,
+	// is recorded as is.
+	if g.costTracker != nil && params.pipeCtx != nil && params.pipeCtx.CostSessionID != "" && (usage.TotalTokens > 0 || hasReportedCost) && params.statusCode < 400 {
+		if hasReportedCost {
+			g.costTracker.RecordCost(params.pipeCtx.CostSessionID, model, reportedCost,
+				usage.InputTokens, usage.OutputTokens,
+				usage.CacheCreationInputTokens, usage.CacheReadInputTokens, params.pipeCtx.BudgetScopes...)
+		} else {
+			g.costTracker.RecordUsage(params.pipeCtx.CostSessionID, model,
+				usage.InputTokens, usage.OutputTokens,
+				usage.CacheCreationInputTokens, usage.CacheReadInputTokens, params.pipeCtx.BudgetScopes...)
+		}
+	}
+
+	// Responses API: link the response ID to this session so a follow-up
+	// request with previous_response_id continues it (and its cost).
+	if g.responseChains != nil && params.pipeCtx != nil && params.statusCode < 400 {
+		responseID := params.streamResponseID
+		if responseID == "" {
+			responseID = responseIDFromBody(params.responseBody)
+		}
+		g.responseChains.record(responseID, params.pipeCtx)
 	}
 
 	// Update session monitor with post-response data (tokens, cost, status)
@@ -354,7 +453,7 @@ func (g *Gateway) recordTrajectory(params telemetryParams, model string, usage a
 			PromptTokens:     usage.InputTokens,
 			CompletionTokens: usage.OutputTokens,
 		})
-		g.recordProxyInteraction(params, sessionID, usage)
+		g.recordProxyInteraction(params, sessionID, model, usage)
 	} else {
 		// Tool-loop iteration: accumulate into the existing agent step.
 		if isStreaming {
@@ -385,7 +484,7 @@ func (g *Gateway) recordTrajectory(params telemetryParams, model string, usage a
 // Does NOT store full message arrays — those duplicate the system prompt and
 // entire conversation history in every step, causing massive bloat.
 // The actual messages are already captured by the step's Message/ToolCalls fields.
-func (g *Gateway) recordProxyInteraction(params telemetryParams, sessionID string, usage adapters.UsageInfo) {
+func (g *Gateway) recordProxyInteraction(params telemetryParams, sessionID, model string, usage adapters.UsageInfo) {
 	if g.trajectory == nil || !g.trajectory.Enabled() {
 		return
 	}
@@ -422,9 +521,9 @@ func (g *Gateway) recordProxyInteraction(params telemetryParams, sessionID strin
 		}
 	}
 
-	// Count tokens using tiktoken on actual content.
-	clientTokens := tokenizer.CountBytes(params.requestBody)
-	compressedTokens := tokenizer.CountBytes(params.forwardBody)
+	// Count tokens on actual content.
+	clientTokens := g.tokens.CountRequest(params.requestBody, model)
+	compressedTokens := g.tokens.CountRequest(params.forwardBody, model)
 
 	// Count messages instead of storing them (avoids system prompt duplication)
 	clientMsgCount := countMessages(params.requestBody)
@@ -633,14 +732,14 @@ type requestMetrics struct {
 	compressionRatio                              float64
 }
 
-// calculateMetrics computes token-based compression metrics using tiktoken.
+// calculateMetrics computes token-based compression metrics with the
+// configured token counter (tiktoken by default).
 // This captures all savings sources: tool output compression, preemptive
 // summarization, and tool discovery filtering — since all reduce the forwarded body size.
 
-func (g *Gateway) calculateMetrics(requestBody, forwardBody []byte, originalBodySize, compressedBodySize int) requestMetrics {
-	// Count tokens using tiktoken on actual content.
-	originalTokens := tokenizer.CountBytes(requestBody)
-	compressedTokens := tokenizer.CountBytes(forwardBody)
+func (g *Gateway) calculateMetrics(model string, requestBody, forwardBody []byte, originalBodySize, compressedBodySize int) requestMetrics {
+	originalTokens := g.tokens.CountRequest(requestBody, model)
+	compressedTokens := g.tokens.CountRequest(forwardBody, model)
 
 	m := requestMetrics{
 		originalTokens:   originalTokens,
@@ -754,6 +853,7 @@ func (g *Gateway) logCompressionDetails(pipeCtx *PipelineContext, requestID, pip
 
 	// Record tool output compression savings to savings tracker
 	// (always, even if file logging is disabled)
+	var diffSample []monitoring.CompressionComparison
 	for _, tc := range pipeCtx.ToolOutputCompressions {
 		// Determine status from MappingStatus
 		status := tc.MappingStatus
@@ -814,6 +914,30 @@ func (g *Gateway) logCompressionDetails(pipeCtx *PipelineContext, requestID, pip
 		if g.savings != nil {
 			g.savings.RecordToolOutputCompression(comparison, costSessionID, isMainAgent)
 		}
+
+		// Per-tool leaderboard. Passthrough outputs carry no compressed content.
+		if g.toolSavings != nil {
+			compBytes := len(tc.CompressedContent)
+			if compBytes == 0 {
+				compBytes = len(tc.OriginalContent)
+			}
+			g.toolSavings.RecordOutput(tc.ToolName, tc.ShadowID, status,
+				tc.OriginalTokens, tc.CompressedTokens, len(tc.OriginalContent), compBytes)
+		}
+
+		// Keep compressed outputs for the dashboard diff view.
+		if status == "compressed" || status == "cache_hit" {
+			diffSample = append(diffSample, comparison)
+		}
+	}
+	if g.comparisonLog != nil && len(diffSample) > 0 {
+		g.comparisonLog.Record(monitoring.ComparisonSample{
+			RequestID:   requestID,
+			SessionID:   costSessionID,
+			Model:       pipeCtx.TargetModel,
+			Timestamp:   time.Now(),
+			Comparisons: diffSample,
+		})
 	}
 
 	// Record task output events to task_output_compression.jsonl (always, even passthrough).
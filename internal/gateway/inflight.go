@@ -0,0 +1,201 @@
+// inflight.go - Registry of in-flight proxy requests for the admin API.
+//
+// Every LLM request handled by handleProxy runs under its own cancellable
+// context. GET /admin/requests lists what is currently in flight and which
+// stage it is in; DELETE /admin/requests/{id} cancels that context, which
+// aborts the upstream call and releases the request's resources. Both
+// endpoints are loopback-only.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Request stages reported by GET /admin/requests.
+const (
+	StagePreprocess = "preprocess" // auth capture, sessions, preemptive summarization
+	StageCompress   = "compress"   // compression pipes
+	StageUpstream   = "upstream"   // waiting for the upstream response
+	StageStreaming  = "streaming"  // relaying the upstream stream to the client
+)
+
+// errCancelledByAdmin is the cancellation cause for DELETE /admin/requests/{id}.
+var errCancelledByAdmin = errors.New("request cancelled via admin API")
+
+// inflightRequest is one request tracked by the registry.
+type inflightRequest struct {
+	id        string
+	path      string
+	provider  string
+	stream    bool
+	startedAt time.Time
+	cancel    context.CancelCauseFunc
+
+	mu      sync.Mutex
+	session string
+	model   string
+	stage   string
+}
+
+// InflightRequest is the admin API view of an in-flight request.
+type InflightRequest struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Provider  string    `json:"provider"`
+	Path      string    `json:"path"`
+	Stream    bool      `json:"stream"`
+	Stage     string    `json:"stage"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// setStage records the stage the request has reached. Safe on a nil receiver.
+func (q *inflightRequest) setStage(stage string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.stage = stage
+	q.mu.Unlock()
+}
+
+// setSession records the request's session and model. Safe on a nil receiver.
+func (q *inflightRequest) setSession(session, model string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.session = session
+	q.model = model
+	q.mu.Unlock()
+}
+
+func (q *inflightRequest) view(now time.Time) InflightRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return InflightRequest{
+		ID:        q.id,
+		SessionID: q.session,
+		Model:     q.model,
+		Provider:  q.provider,
+		Path:      q.path,
+		Stream:    q.stream,
+		Stage:     q.stage,
+		StartedAt: q.startedAt,
+		ElapsedMs: now.Sub(q.startedAt).Milliseconds(),
+	}
+}
+
+// inflightRegistry tracks requests currently inside handleProxy.
+type inflightRegistry struct {
+	mu       sync.Mutex
+	requests map[string]*inflightRequest
+}
+
+func newInflightRegistry() *inflightRegistry {
+	return &inflightRegistry{requests: make(map[string]*inflightRequest)}
+}
+
+// register tracks a request and returns its entry, a context that the admin
+// API can cancel, and a done func that must be called when the request ends.
+// A client-supplied request ID that is already in flight gets a fresh ID so
+// entries never overwrite each other.
+func (reg *inflightRegistry) register(parent context.Context, id, path, provider string, stream bool) (*inflightRequest, context.Context, func()) {
+	if reg == nil {
+		return nil, parent, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	q := &inflightRequest{
+		id:        id,
+		path:      path,
+		provider:  provider,
+		stream:    stream,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		stage:     StagePreprocess,
+	}
+
+	reg.mu.Lock()
+	if _, taken := reg.requests[q.id]; taken || q.id == "" {
+		q.id = uuid.New().String()
+	}
+	reg.requests[q.id] = q
+	reg.mu.Unlock()
+
+	return q, ctx, func() {
+		reg.mu.Lock()
+		delete(reg.requests, q.id)
+		reg.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// list returns all in-flight requests, oldest first.
+func (reg *inflightRegistry) list() []InflightRequest {
+	now := time.Now()
+	reg.mu.Lock()
+	out := make([]InflightRequest, 0, len(reg.requests))
+	for _, q := range reg.requests {
+		out = append(out, q.view(now))
+	}
+	reg.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// cancel cancels the request with the given ID. Returns false if it is not in flight.
+func (reg *inflightRegistry) cancel(id string) bool {
+	reg.mu.Lock()
+	q, ok := reg.requests[id]
+	reg.mu.Unlock()
+	if ok {
+		q.cancel(errCancelledByAdmin)
+	}
+	return ok
+}
+
+// handleAdminRequests serves GET /admin/requests and DELETE /admin/requests/{id}.
+func (g *Gateway) handleAdminRequests(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/requests"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		requests := g.inflight.list()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"count":    len(requests),
+			"requests": requests,
+		}); err != nil {
+			log.Warn().Err(err).Msg("handleAdminRequests: failed to encode JSON response")
+		}
+	case id != "" && r.Method == http.MethodDelete:
+		if !g.inflight.cancel(id) {
+			g.writeError(w, "request not in flight", http.StatusNotFound)
+			return
+		}
+		log.Warn().Str("request_id", id).Msg("admin: cancelled in-flight request")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "cancelled": true})
+	case id == "":
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", "DELETE")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
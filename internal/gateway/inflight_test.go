@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInflightRegistry_ListAndDone(t *testing.T) {
+	reg := newInflightRegistry()
+	q, _, done := reg.register(context.Background(), "req-1", "/v1/messages", "anthropic", true)
+	q.setSession("sess-1", "claude-sonnet-4-5")
+	q.setStage(StageUpstream)
+
+	list := reg.list()
+	require.Len(t, list, 1)
+	assert.Equal(t, "req-1", list[0].ID)
+	assert.Equal(t, "sess-1", list[0].SessionID)
+	assert.Equal(t, "claude-sonnet-4-5", list[0].Model)
+	assert.Equal(t, StageUpstream, list[0].Stage)
+	assert.True(t, list[0].Stream)
+
+	done()
+	assert.Empty(t, reg.list())
+}
+
+func TestInflightRegistry_CancelCancelsContext(t *testing.T) {
+	reg := newInflightRegistry()
+	_, ctx, done := reg.register(context.Background(), "req-1", "/v1/messages", "anthropic", false)
+	defer done()
+
+	assert.False(t, reg.cancel("missing"))
+	require.True(t, reg.cancel("req-1"))
+
+	<-ctx.Done()
+	assert.ErrorIs(t, context.Cause(ctx), errCancelledByAdmin)
+}
+
+func TestInflightRegistry_DuplicateIDGetsFreshID(t *testing.T) {
+	reg := newInflightRegistry()
+	a, _, doneA := reg.register(context.Background(), "same", "/v1/messages", "anthropic", false)
+	defer doneA()
+	b, _, doneB := reg.register(context.Background(), "same", "/v1/messages", "anthropic", false)
+	defer doneB()
+
+	assert.Equal(t, "same", a.id)
+	assert.NotEqual(t, "same", b.id)
+	assert.Len(t, reg.list(), 2)
+}
+
+func TestInflightRegistry_NilIsNoop(t *testing.T) {
+	var reg *inflightRegistry
+	q, ctx, done := reg.register(context.Background(), "req-1", "/v1/messages", "anthropic", false)
+	defer done()
+
+	assert.Nil(t, q)
+	assert.NotNil(t, ctx)
+	q.setStage(StageCompress) // nil-safe
+}
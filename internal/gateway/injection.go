@@ -0,0 +1,72 @@
+// injection.go - reporting pipes.injection findings.
+//
+// The injection pipe itself runs in Router.secure; this file turns its
+// findings into the X-Gateway-Prompt-Injection response header, the request's
+// telemetry and the /stats and /metrics counters.
+package gateway
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// HeaderPromptInjection is set on responses to requests whose tool outputs
+// held a suspected prompt injection, e.g. "flagged; detectors=base64,phrase".
+const HeaderPromptInjection = "X-Gateway-Prompt-Injection"
+
+// injectionVerbs are the header's words for each action.
+var injectionVerbs = map[string]string{
+	pipes.InjectionActionFlag:  "flagged",
+	pipes.InjectionActionStrip: "stripped",
+	pipes.InjectionActionBlock: "blocked",
+}
+
+// injectionHeader returns the HeaderPromptInjection value for ctx, or "" when
+// nothing was found.
+func injectionHeader(ctx *PipelineContext) string {
+	if len(ctx.InjectionFindings) == 0 {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var detectors []string
+	for _, f := range ctx.InjectionFindings {
+		if !seen[f.Detector] {
+			seen[f.Detector] = true
+			detectors = append(detectors, f.Detector)
+		}
+	}
+	sort.Strings(detectors)
+	return injectionVerbs[ctx.injectionAction] + "; detectors=" + strings.Join(detectors, ",")
+}
+
+// injectionReport returns ctx's findings for telemetry, or nil.
+func injectionReport(ctx *PipelineContext) *monitoring.PromptInjectionReport {
+	if ctx == nil || len(ctx.InjectionFindings) == 0 {
+		return nil
+	}
+	report := &monitoring.PromptInjectionReport{
+		Action:   ctx.injectionAction,
+		Findings: make([]monitoring.PromptInjectionFinding, len(ctx.InjectionFindings)),
+	}
+	for i, f := range ctx.InjectionFindings {
+		report.Findings[i] = monitoring.PromptInjectionFinding(f)
+	}
+	return report
+}
+
+// reportInjection sets the response header and counts ctx's findings in
+// /stats and /metrics.
+func (g *Gateway) reportInjection(header http.Header, ctx *PipelineContext) {
+	value := injectionHeader(ctx)
+	if value == "" {
+		return
+	}
+	header.Set(HeaderPromptInjection, value)
+	if g.metrics != nil {
+		g.metrics.RecordPromptInjection(injectionReport(ctx))
+	}
+}
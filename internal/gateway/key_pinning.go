@@ -0,0 +1,160 @@
+// key_pinning.go - Keeps provider keys on the hosts they belong to.
+//
+// A misrouted X-Target-URL would otherwise forward the client's credentials
+// to whatever host it names. With key_pinning enabled, each outgoing
+// credential is matched against the configured prefixes: a key whose longest
+// matching prefix is pinned to other hosts, or a key sent to a pinned host
+// without one of that host's prefixes, is a violation. Block mode fails the
+// request before anything is sent; alert mode logs and forwards.
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/utils"
+)
+
+// keyPinViolation describes a credential that may not be sent to a host.
+type keyPinViolation struct {
+	Header string // Header (or "key" query param) carrying the credential
+	Key    string // Masked key
+	Host   string
+	Reason string
+}
+
+// checkKeyPins returns the first violation for the credentials on req, or nil.
+func checkKeyPins(cfg config.KeyPinningConfig, req *http.Request) *keyPinViolation {
+	if !cfg.Enabled || len(cfg.Rules) == 0 {
+		return nil
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	hostPrefixes, hostPinned := pinnedPrefixes(cfg.Rules, host)
+
+	for _, cred := range outgoingCredentials(req) {
+		pinnedHosts, prefix := keyOwners(cfg.Rules, cred.value)
+		switch {
+		case prefix != "" && !hostAllowed(pinnedHosts, host):
+			return &keyPinViolation{
+				Header: cred.source, Key: utils.MaskKey(cred.value), Host: host,
+				Reason: fmt.Sprintf("%s* keys are pinned to %s", prefix, strings.Join(pinnedHosts, ", ")),
+			}
+		case hostPinned && !hasAnyPrefix(cred.value, hostPrefixes):
+			return &keyPinViolation{
+				Header: cred.source, Key: utils.MaskKey(cred.value), Host: host,
+				Reason: fmt.Sprintf("%s only accepts %s* keys", host, strings.Join(hostPrefixes, "*, ")),
+			}
+		}
+	}
+	return nil
+}
+
+// enforceKeyPins checks req against the key pins. It returns an error wrapping
+// errKeyPinViolation in block mode; in alert mode violations are only logged.
+func (g *Gateway) enforceKeyPins(req *http.Request) error {
+	cfg := g.cfg().KeyPinning
+	v := checkKeyPins(cfg, req)
+	if v == nil {
+		return nil
+	}
+	event := log.Warn()
+	if cfg.Mode != config.KeyPinningModeAlert {
+		event = log.Error()
+	}
+	event.
+		Str("host", v.Host).
+		Str("credential", v.Header).
+		Str("key", v.Key).
+		Str("mode", cfg.Mode).
+		Str("reason", v.Reason).
+		Msg("key pinning: provider key sent to unexpected host")
+	if cfg.Mode == config.KeyPinningModeAlert {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (%s)", errKeyPinViolation, v.Host, v.Reason)
+}
+
+type outgoingCredential struct {
+	source string
+	value  string
+}
+
+// outgoingCredentials returns the provider keys present on req.
+func outgoingCredentials(req *http.Request) []outgoingCredential {
+	var creds []outgoingCredential
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			auth = strings.TrimSpace(auth[7:])
+		}
+		creds = append(creds, outgoingCredential{source: "Authorization", value: auth})
+	}
+	for _, h := range []string{"x-api-key", "x-goog-api-key", "api-key"} {
+		if v := req.Header.Get(h); v != "" {
+			creds = append(creds, outgoingCredential{source: h, value: v})
+		}
+	}
+	if v := req.URL.Query().Get("key"); v != "" {
+		creds = append(creds, outgoingCredential{source: "key", value: v})
+	}
+	return creds
+}
+
+// keyOwners returns the longest configured prefix matching key and every
+// host that prefix is pinned to. Returns an empty prefix for unknown keys.
+func keyOwners(rules []config.KeyPinRule, key string) ([]string, string) {
+	var best string
+	var hosts []string
+	for _, rule := range rules {
+		for _, p := range rule.Prefixes {
+			if !strings.HasPrefix(key, p) || len(p) < len(best) {
+				continue
+			}
+			if len(p) > len(best) {
+				best, hosts = p, nil
+			}
+			hosts = append(hosts, strings.ToLower(rule.Host))
+		}
+	}
+	return hosts, best
+}
+
+// pinnedPrefixes returns the prefixes accepted by host and whether any rule covers it.
+func pinnedPrefixes(rules []config.KeyPinRule, host string) ([]string, bool) {
+	var prefixes []string
+	for _, rule := range rules {
+		if hostMatches(strings.ToLower(rule.Host), host) {
+			prefixes = append(prefixes, rule.Prefixes...)
+		}
+	}
+	return prefixes, len(prefixes) > 0
+}
+
+func hostAllowed(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if hostMatches(p, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostMatches matches an exact host or a "*.example.com" subdomain pattern.
+func hostMatches(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix)
+	}
+	return pattern == host
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+// litellm.go - LiteLLM proxy integration mode.
+//
+// With litellm.enabled, the gateway sits in front of a LiteLLM proxy: requests
+// without X-Target-URL are sent to it, provider-prefixed model names
+// ("bedrock/anthropic.claude-...") are forwarded unchanged so LiteLLM can
+// route them, LiteLLM's own headers (virtual keys, tags) pass through, and
+// the cost LiteLLM reports in x-litellm-response-cost replaces the gateway's
+// token-based estimate.
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// liteLLMHeaderPrefix marks LiteLLM request headers: x-litellm-api-key for
+// virtual keys, x-litellm-tags, x-litellm-timeout and similar.
+const liteLLMHeaderPrefix = "X-Litellm-"
+
+// liteLLMCostHeader carries the cost LiteLLM computed for a response, in USD.
+const liteLLMCostHeader = "x-litellm-response-cost"
+
+// liteLLMURL returns the LiteLLM proxy base URL, or "" when the mode is off.
+func (g *Gateway) liteLLMURL() string {
+	if !g.cfg().LiteLLM.Enabled {
+		return ""
+	}
+	return g.cfg().LiteLLM.BaseURL()
+}
+
+// copyLiteLLMHeaders forwards the client's LiteLLM headers to the upstream request.
+func copyLiteLLMHeaders(dst, src http.Header) {
+	for k, v := range src {
+		if strings.HasPrefix(http.CanonicalHeaderKey(k), liteLLMHeaderPrefix) {
+			dst[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// liteLLMResponseCost returns the cost LiteLLM reported for a response. A
+// missing, unparsable or zero cost counts as not reported: LiteLLM sends 0
+// for models it has no price for.
+func liteLLMResponseCost(h http.Header) (float64, bool) {
+	v := h.Get(liteLLMCostHeader)
+	if v == "" {
+		return 0, false
+	}
+	cost, err := strconv.ParseFloat(v, 64)
+	if err != nil || cost <= 0 {
+		return 0, false
+	}
+	return cost, true
+}
@@ -0,0 +1,209 @@
+// mcp_server.go - MCP server exposing the shadow store, tool discovery and session costs.
+//
+// Agents that speak MCP natively can call expand_context, search_tools and
+// get_session_cost as MCP tools instead of relying on phantom tools injected
+// into their LLM requests. The server runs on the proxy port: POST /mcp takes
+// one JSON-RPC message and answers it (the `context-gateway mcp` stdio bridge
+// uses this), GET /mcp/sse plus POST /mcp/message is the HTTP+SSE transport.
+// Access follows the admin API: loopback clients only, or a
+// server.admin_token bearer.
+//
+// Sessions are the conversation IDs of GET /sessions. Tools that take a
+// session_id default to the most recently active conversation, which is the
+// calling agent's own when one agent uses the gateway.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/mcp"
+	phantom_tools "github.com/compresr/context-gateway/internal/phantom_tools"
+)
+
+// MCP tool names.
+const (
+	MCPToolExpandContext  = phantom_tools.ExpandContextToolName
+	MCPToolSearchTools    = "search_tools"
+	MCPToolGetSessionCost = "get_session_cost"
+)
+
+// mcpServerName is reported in the MCP initialize result.
+const mcpServerName = "context-gateway"
+
+// newMCPServer builds the gateway's MCP tools.
+func (g *Gateway) newMCPServer() *mcp.Server {
+	sessionArg := map[string]any{
+		"type":        "string",
+		"description": "Conversation ID from GET /sessions; defaults to the most recently active session",
+	}
+	return mcp.NewServer(mcpServerName, g.version,
+		mcp.Tool{
+			Name:        MCPToolExpandContext,
+			Description: "Return the original content behind a shadow reference left in a compressed tool output.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{"type": "string", "description": "Shadow reference ID, e.g. shadow_3f9c…"},
+				},
+				"required": []string{"id"},
+			},
+			Handler: g.mcpExpandContext,
+		},
+		mcp.Tool{
+			Name:        MCPToolSearchTools,
+			Description: "Search the tools tool discovery deferred for a session. Matches are returned with their full definitions; the model can call them directly from then on.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query":       map[string]any{"type": "string", "description": "What the tool should do"},
+					"session_id":  sessionArg,
+					"max_results": map[string]any{"type": "integer", "description": "Maximum matches (default: tool_discovery.max_search_results)"},
+				},
+				"required": []string{"query"},
+			},
+			Handler: g.mcpSearchTools,
+		},
+		mcp.Tool{
+			Name:        MCPToolGetSessionCost,
+			Description: "Return a session's spend so far: cost in USD, the session cap and token counts.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"session_id": sessionArg},
+			},
+			Handler: g.mcpGetSessionCost,
+		},
+	)
+}
+
+// handleMCP serves POST /mcp: one JSON-RPC message per request.
+func (g *Gateway) handleMCP(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	g.mcp.ServeHTTP(w, r)
+}
+
+// handleMCPStream serves GET /mcp/sse, the HTTP+SSE transport's event stream.
+func (g *Gateway) handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	g.mcpSSE.ServeStream(w, r)
+}
+
+// handleMCPMessage serves POST /mcp/message for an open event stream.
+func (g *Gateway) handleMCPMessage(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	g.mcpSSE.ServeMessage(w, r)
+}
+
+func (g *Gateway) mcpExpandContext(_ context.Context, args map[string]any) (string, error) {
+	id, err := mcp.RequiredString(args, "id")
+	if err != nil {
+		return "", err
+	}
+	if len(id) > 64 {
+		return "", errors.New("invalid id")
+	}
+	data, ok := g.expandShadow("mcp", id)
+	if !ok {
+		return "", fmt.Errorf("shadow reference %q not found or expired", id)
+	}
+	return data, nil
+}
+
+func (g *Gateway) mcpSearchTools(_ context.Context, args map[string]any) (string, error) {
+	query, err := mcp.RequiredString(args, "query")
+	if err != nil {
+		return "", err
+	}
+	if g.toolSessions == nil {
+		return "", errors.New("tool discovery is not available")
+	}
+	toolSessionID := g.mcpToolSession(mcp.ArgString(args, "session_id"))
+	if toolSessionID == "" {
+		return "", errors.New("no session with deferred tools")
+	}
+
+	matches := SearchDeferredTools(g.toolSessions.GetDeferred(toolSessionID), query,
+		mcp.ArgInt(args, "max_results", g.cfg().Pipes.ToolDiscovery.MaxSearchResults))
+	if len(matches) == 0 {
+		return fmt.Sprintf("No deferred tools match %q.", query), nil
+	}
+	g.toolSessions.MarkExpanded(toolSessionID, extractToolNames(matches))
+	return formatSearchResults(matches), nil
+}
+
+func (g *Gateway) mcpGetSessionCost(_ context.Context, args map[string]any) (string, error) {
+	id := mcp.ArgString(args, "session_id")
+	if id == "" {
+		id = g.latestSession(func(*sessionActivity) bool { return true })
+	}
+	if id == "" {
+		return "", errors.New("no active session")
+	}
+	info, ok := g.inspectSession(id)
+	if !ok {
+		return "", fmt.Errorf("session %q not found", id)
+	}
+	out, err := json.Marshal(struct {
+		SessionID string       `json:"session_id"`
+		Model     string       `json:"model,omitempty"`
+		Requests  int          `json:"requests"`
+		Cost      *SessionCost `json:"cost"`
+	}{info.ID, info.Model, info.Requests, info.Cost})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// mcpToolSession resolves a conversation ID (or a tool session ID) to the
+// tool discovery session to search; "" picks the latest conversation that
+// has one.
+func (g *Gateway) mcpToolSession(id string) string {
+	if id == "" {
+		id = g.latestSession(func(a *sessionActivity) bool { return a.ToolSessionID != "" })
+	}
+	if id == "" {
+		return ""
+	}
+	if g.sessionIndex != nil {
+		var toolSessionID string
+		g.sessionIndex.View(id, func(a *sessionActivity) { toolSessionID = a.ToolSessionID })
+		if toolSessionID != "" {
+			return toolSessionID
+		}
+	}
+	if g.toolSessions.Get(id) != nil {
+		return id
+	}
+	return ""
+}
+
+// latestSession returns the most recently active conversation matching keep.
+func (g *Gateway) latestSession(keep func(*sessionActivity) bool) string {
+	if g.sessionIndex == nil {
+		return ""
+	}
+	var (
+		latestID string
+		latestAt time.Time
+	)
+	g.sessionIndex.Range(func(id string, a *sessionActivity) {
+		if keep(a) && a.LastSeen.After(latestAt) {
+			latestID, latestAt = id, a.LastSeen
+		}
+	})
+	return latestID
+}
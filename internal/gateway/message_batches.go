@@ -0,0 +1,391 @@
+// message_batches.go - Anthropic Message Batches API (/v1/messages/batches).
+//
+// A batch carries many /v1/messages requests that Anthropic processes offline;
+// their responses come back later as a JSONL results file. The gateway never
+// sees an item's response as it is produced, so:
+//
+//   - POST /v1/messages/batches is recorded as one request. With
+//     server.message_batches: compressed, each item's params go through the
+//     compression pipes first, detached: no phantom tools, no expand_context
+//     references and no tool filtering, since no tool call can come back.
+//   - GET /v1/messages/batches/{id}/results is relayed as it arrives, and each
+//     succeeded item is recorded as its own request (telemetry, /stats, cost
+//     tracking) at the batch discount. Items are recorded the first time the
+//     results of a batch created through the gateway are fetched.
+//
+// The batch ID is the cost session. Every other batch request is relayed
+// unchanged.
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// messageBatchesPath is Anthropic's Message Batches endpoint.
+const messageBatchesPath = "/v1/messages/batches"
+
+// pipeStrategyBatch labels the items recorded from a batch's results.
+const pipeStrategyBatch = "batch"
+
+// messageBatchResultsPath matches GET /v1/messages/batches/{id}/results.
+var messageBatchResultsPath = regexp.MustCompile(`^/v1/messages/batches/([^/]+)/results$`)
+
+// isMessageBatchesPath reports whether path belongs to the Message Batches API.
+func isMessageBatchesPath(path string) bool {
+	return path == messageBatchesPath || strings.HasPrefix(path, messageBatchesPath+"/")
+}
+
+// messageBatch is a batch created through the gateway.
+type messageBatch struct {
+	Tenant   string                 // Tenant of the creating request
+	Client   string                 // Client label of the creating request
+	Scopes   []costcontrol.ScopeKey // Budget scopes its items are charged to
+	Recorded bool                   // Its items have been recorded
+}
+
+// messageBatchStore tracks batches created through the gateway until their
+// results are recorded.
+type messageBatchStore struct {
+	batches *sessionstore.Store[messageBatch]
+}
+
+func newMessageBatchStore(ttl time.Duration) *messageBatchStore {
+	if ttl <= 0 {
+		ttl = 29 * 24 * time.Hour
+	}
+	return &messageBatchStore{
+		batches: sessionstore.New[messageBatch](ttl, 0, nil), // swept by the session collector
+	}
+}
+
+// add remembers a created batch.
+func (s *messageBatchStore) add(id string, b messageBatch) {
+	s.batches.Update(id, func(v *messageBatch) { *v = b })
+}
+
+// claim returns a batch the first time its results are fetched. ok is false
+// for unknown batches and batches already recorded.
+func (s *messageBatchStore) claim(id string) (b messageBatch, ok bool) {
+	if !s.batches.View(id, func(*messageBatch) {}) {
+		return b, false
+	}
+	s.batches.Update(id, func(v *messageBatch) {
+		if !v.Recorded {
+			v.Recorded, ok = true, true
+			b = *v
+		}
+	})
+	return b, ok
+}
+
+// Len returns the number of tracked batches (sessionstore.Sweeper).
+func (s *messageBatchStore) Len() int {
+	return s.batches.Len()
+}
+
+// Sweep removes idle batches (sessionstore.Sweeper).
+func (s *messageBatchStore) Sweep() int {
+	return s.batches.Sweep()
+}
+
+// Expire forgets a batch (sessionstore.Sweeper).
+func (s *messageBatchStore) Expire(sessionID string) bool {
+	return s.batches.Delete(sessionID)
+}
+
+// Stop stops the cleanup goroutine. Safe to call multiple times.
+func (s *messageBatchStore) Stop() {
+	s.batches.Stop()
+}
+
+// handleMessageBatches serves the Message Batches API.
+func (g *Gateway) handleMessageBatches(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == messageBatchesPath:
+		g.handleMessageBatchCreate(w, r)
+	case r.Method == http.MethodGet && messageBatchResultsPath.MatchString(r.URL.Path):
+		g.handleMessageBatchResults(w, r)
+	default:
+		g.handlePassthrough(w, r)
+	}
+}
+
+// messageBatchPipelineContext builds the pipeline context of a batch request
+// or of one of its items.
+func (g *Gateway) messageBatchPipelineContext(r *http.Request, body []byte, requestID string) (*PipelineContext, adapters.Adapter) {
+	adapter := g.registry.Get(adapters.ProviderAnthropic.String())
+	pipeCtx := NewPipelineContext(adapters.ProviderAnthropic, adapter, body, r.URL.Path)
+	pipeCtx.RequestID = requestID
+	pipeCtx.RequestCtx = r.Context()
+	pipeCtx.Tenant = tenancy.NameOf(tenancy.FromContext(r.Context()))
+	pipeCtx.Client = clientLabelFrom(r.Context())
+	pipeCtx.SessionTags = parseSessionTags(r.Header)
+	pipeCtx.CapturedAuth = authtypes.CaptureFromHeaders(r.Header)
+	pipeCtx.ClientAgent = detectClientAgent(r.Header)
+	return pipeCtx, adapter
+}
+
+// handleMessageBatchCreate submits a batch, compressing its items when
+// server.message_batches is compressed.
+func (g *Gateway) handleMessageBatchCreate(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	requestID := g.getRequestID(r)
+	g.EnsureSession()
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	pipeCtx, adapter := g.messageBatchPipelineContext(r, body, requestID)
+
+	// The batch has no cost session until it is created: global and scope caps apply.
+	if g.costTracker != nil {
+		pipeCtx.BudgetScopes = g.costTracker.ScopeKeys(r.Header, pipeCtx.SessionTags, pipeCtx.Tenant, pipeCtx.Client)
+		budget := g.costTracker.CheckBudget("", pipeCtx.BudgetScopes...)
+		if budget.Simulated {
+			g.costTracker.RecordSimulatedRejection("", budget)
+			w.Header().Set(HeaderBudgetSimulated, budget.Reason)
+		}
+		g.notifyBudget("", budget)
+		if !budget.Allowed {
+			g.recordError(monitoring.ErrorCodeBudgetExceeded)
+			g.notifyBudgetExceeded("", budget)
+			g.returnBudgetExceededResponse(w, adapter.Name(), budget, "")
+			return
+		}
+	}
+
+	forwardBody, pipeType, pipeStrategy, compressionUsed, compressLatency := body, PipeNone, config.StrategyPassthrough, false, time.Duration(0)
+	if g.cfg().Server.MessageBatches == config.MessageBatchesCompressed && g.router != nil {
+		compressStart := time.Now()
+		compressed, used, status, err := g.compressMessageBatch(r, body, pipeCtx)
+		compressLatency = time.Since(compressStart)
+		if err != nil {
+			g.writeError(w, err.Error(), status)
+			return
+		}
+		forwardBody, compressionUsed = compressed, used
+		if used {
+			pipeType, pipeStrategy = PipeToolOutput, g.cfgFor(pipeCtx.Tenant).Pipes.ToolOutput.Strategy
+		}
+	}
+
+	params := telemetryParams{
+		requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path, clientIP: r.RemoteAddr,
+		requestBodySize: len(body), provider: adapter.Name(), pipeType: pipeType, pipeStrategy: pipeStrategy,
+		originalBodySize: len(body), compressionUsed: compressionUsed, compressLatency: compressLatency,
+		pipeCtx: pipeCtx, adapter: adapter, requestBody: body, forwardBody: forwardBody,
+		compressedBodySize: len(forwardBody), requestHeaders: r.Header,
+		model: gjson.GetBytes(body, "requests.0.params.model").String(),
+	}
+
+	forwardStart := time.Now()
+	resp, authMeta, err := g.forwardPassthrough(r.Context(), r, forwardBody)
+	params.forwardLatency = time.Since(forwardStart)
+	params.authModeInitial, params.authModeEffective, params.authFallbackUsed = authMeta.InitialMode, authMeta.EffectiveMode, authMeta.FallbackUsed
+	params.upstreamRetries = authMeta.Retries
+	if err != nil {
+		params.statusCode = http.StatusBadGateway
+		params.errorMsg = err.Error()
+		params.errorCode, params.retryable = ClassifyUpstreamError(err, monitoring.ErrorCodeUpstreamUnavailable)
+		g.recordRequestTelemetry(params)
+		log.Debug().Err(err).Str("request_id", requestID).Msg("message batches: upstream request failed")
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+	copyHeaders(w, resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(responseBody)
+
+	params.statusCode = resp.StatusCode
+	params.responseHeaders = resp.Header
+	params.responseBodySize = len(responseBody)
+	if resp.Request != nil {
+		params.upstreamURL = resp.Request.URL.String()
+	}
+	if batchID := gjson.GetBytes(responseBody, "id").String(); resp.StatusCode < 400 && batchID != "" {
+		pipeCtx.CostSessionID = batchID
+		if g.messageBatches != nil {
+			g.messageBatches.add(batchID, messageBatch{Tenant: pipeCtx.Tenant, Client: pipeCtx.Client, Scopes: pipeCtx.BudgetScopes})
+		}
+	}
+	g.recordRequestTelemetry(params)
+}
+
+// compressMessageBatch runs each item's params through the pipes, detached.
+// An item the pipes cannot process is submitted as sent. A PII masking failure
+// or a pipe rejection fails the whole batch with the returned status.
+func (g *Gateway) compressMessageBatch(r *http.Request, body []byte, batchCtx *PipelineContext) (forward []byte, used bool, status int, err error) {
+	forward = body
+	router := g.routerFor(batchCtx.Tenant)
+	for i, item := range gjson.GetBytes(body, "requests").Array() {
+		params := item.Get("params")
+		if !params.IsObject() {
+			continue
+		}
+		itemBody := []byte(params.Raw)
+		itemCtx, _ := g.messageBatchPipelineContext(r, itemBody, batchCtx.RequestID)
+		itemCtx.OriginalPath = "/v1/messages"
+		itemCtx.Model = params.Get("model").String()
+		itemCtx.TargetModel = itemCtx.Model
+		itemCtx.Detached = true
+		itemCtx.Flags = g.flags.Evaluate(featureflags.Target{
+			SessionID: item.Get("custom_id").String(),
+			User:      r.Header.Get(featureflags.HeaderUser),
+			Tags:      itemCtx.SessionTags,
+		})
+
+		compressed, _, pipeErr := router.ProcessAll(itemCtx)
+		batchCtx.PIIMasked += itemCtx.PIIMasked
+		batchCtx.ToolOutputCompressions = append(batchCtx.ToolOutputCompressions, itemCtx.ToolOutputCompressions...)
+		if itemCtx.PIIError != nil {
+			g.recordError(monitoring.ErrorCodePIIMaskingFailed)
+			return body, false, http.StatusServiceUnavailable, fmt.Errorf("pii masking failed")
+		}
+		if itemCtx.PipeRejection != nil {
+			g.recordError(itemCtx.rejectCode)
+			return body, false, itemCtx.rejectStatus, itemCtx.PipeRejection
+		}
+		if pipeErr != nil || len(compressed) == 0 || bytes.Equal(compressed, itemBody) {
+			continue
+		}
+
+		baseline := itemBody
+		if itemCtx.securedBody != nil {
+			baseline = itemCtx.securedBody
+		}
+		compressed, _ = EnforceCachePrefix(baseline, compressed, itemCtx.Flags.On(featureflags.CacheCompat, g.cfg().Pipes.CacheCompat.Enabled))
+		if updated, setErr := sjson.SetRawBytes(forward, fmt.Sprintf("requests.%d.params", i), compressed); setErr == nil {
+			forward = updated
+			used = used || itemCtx.OutputCompressed
+		}
+	}
+	return forward, used, 0, nil
+}
+
+// handleMessageBatchResults relays a batch's results and records each
+// succeeded item the first time the results are fetched.
+func (g *Gateway) handleMessageBatchResults(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	resp, _, err := g.forwardPassthrough(r.Context(), r, nil)
+	if err != nil {
+		log.Debug().Err(err).Str("path", r.URL.Path).Msg("passthrough failed")
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	copyHeaders(w, resp.Header)
+	w.WriteHeader(resp.StatusCode)
+
+	batchID := messageBatchResultsPath.FindStringSubmatch(r.URL.Path)[1]
+	var record func(line []byte)
+	if resp.StatusCode == http.StatusOK && g.messageBatches != nil {
+		if batch, ok := g.messageBatches.claim(batchID); ok {
+			record = func(line []byte) { g.recordMessageBatchItem(r, startTime, batchID, batch, line) }
+		}
+	}
+
+	// Once claimed, the results are read to the end even if the client leaves,
+	// so every item is recorded.
+	var lines batchResultLines
+	var writeErr error
+	buf := make([]byte, DefaultBufferSize)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if writeErr == nil {
+				_, writeErr = w.Write(buf[:n])
+			}
+			if record != nil {
+				lines.feed(buf[:n], record)
+			}
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				log.Debug().Err(readErr).Str("batch_id", batchID).Msg("message batches: error reading results")
+			}
+			break
+		}
+		if writeErr != nil && record == nil {
+			break
+		}
+	}
+	if record != nil {
+		lines.flush(record)
+	}
+}
+
+// recordMessageBatchItem records one line of a batch's results. Only
+// succeeded items were billed; errored, canceled and expired ones are skipped.
+func (g *Gateway) recordMessageBatchItem(r *http.Request, startTime time.Time, batchID string, batch messageBatch, line []byte) {
+	result := gjson.ParseBytes(line)
+	if result.Get("result.type").String() != "succeeded" {
+		return
+	}
+	message := result.Get("result.message")
+	requestID := batchID + "/" + result.Get("custom_id").String()
+	pipeCtx, adapter := g.messageBatchPipelineContext(r, nil, requestID)
+	pipeCtx.Tenant, pipeCtx.Client, pipeCtx.BudgetScopes = batch.Tenant, batch.Client, batch.Scopes
+	pipeCtx.CostSessionID = batchID
+	g.recordRequestTelemetry(telemetryParams{
+		requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path, clientIP: r.RemoteAddr,
+		responseBodySize: len(line), provider: adapter.Name(), pipeType: PipeNone, pipeStrategy: pipeStrategyBatch,
+		statusCode: http.StatusOK, pipeCtx: pipeCtx, adapter: adapter, model: message.Get("model").String(),
+		responseBody: []byte(message.Raw), streamStopReason: message.Get("stop_reason").String(), batchItem: true,
+		requestHeaders: r.Header,
+	})
+}
+
+// batchResultLines splits a JSONL results stream into lines.
+type batchResultLines struct {
+	partial []byte
+}
+
+func (l *batchResultLines) feed(chunk []byte, fn func(line []byte)) {
+	l.partial = append(l.partial, chunk...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(l.partial[:i]); len(line) > 0 {
+			fn(line)
+		}
+		l.partial = l.partial[i+1:]
+	}
+	if len(l.partial) > MaxResponseSize {
+		l.partial = l.partial[:0] // A single result this large is not accounted
+	}
+}
+
+func (l *batchResultLines) flush(fn func(line []byte)) {
+	if line := bytes.TrimSpace(l.partial); len(line) > 0 {
+		fn(line)
+	}
+	l.partial = nil
+}
@@ -3,11 +3,16 @@ package gateway
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -47,26 +52,30 @@ func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
 }
 
-// rateLimiter implements a token bucket rate limiter per IP address.
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// rateLimiter implements a token bucket rate limiter per client key.
 type rateLimiter struct {
 	requests   map[string]*bucket
-	mu         sync.RWMutex
-	rate       int
+	mu         sync.Mutex
 	maxBuckets int
 	stopCh     chan struct{}
 }
 
-// bucket holds rate limiting state for a single IP.
+// bucket holds rate limiting state for a single client key.
 type bucket struct {
-	tokens    int
+	tokens    float64
 	lastCheck time.Time
 }
 
-// newRateLimiter creates a new rate limiter with the specified rate per second.
-func newRateLimiter(rate int) *rateLimiter {
+// newRateLimiter creates a new rate limiter. Rates are passed per call so
+// config reloads apply at once.
+func newRateLimiter() *rateLimiter {
 	rl := &rateLimiter{
 		requests:   make(map[string]*bucket),
-		rate:       rate,
 		maxBuckets: MaxRateLimitBuckets,
 		stopCh:     make(chan struct{}),
 	}
@@ -75,34 +84,44 @@ func newRateLimiter(rate int) *rateLimiter {
 	return rl
 }
 
-// allow checks if the given IP is allowed to make a request.
-func (rl *rateLimiter) allow(ip string) bool {
+// allow takes one token from the bucket of every key, refilled at rps up to
+// burst. Tokens are only taken when all buckets have one; otherwise allow
+// returns how long until they do. New buckets are only stored for allowed
+// requests, so rejected ones cannot flood the map and evict live buckets.
+func (rl *rateLimiter) allow(keys []string, rps float64, burst int) (bool, time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	now := time.Now()
-	b, exists := rl.requests[ip]
-	if !exists {
-		// Enforce max buckets to prevent memory exhaustion
-		if len(rl.requests) >= rl.maxBuckets {
-			rl.evictOldest()
+	var wait time.Duration
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b, exists := rl.requests[key]
+		if !exists {
+			b = &bucket{tokens: float64(burst)}
+		} else {
+			b.tokens = min(float64(burst), b.tokens+now.Sub(b.lastCheck).Seconds()*rps)
 		}
-		rl.requests[ip] = &bucket{tokens: rl.rate - 1, lastCheck: now}
-		return true
+		b.lastCheck = now
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/rps*float64(time.Second)))
+		}
+		buckets[i] = b
 	}
-
-	elapsed := now.Sub(b.lastCheck).Seconds()
-	b.tokens += int(elapsed * float64(rl.rate))
-	if b.tokens > rl.rate {
-		b.tokens = rl.rate
+	if wait > 0 {
+		return false, wait
 	}
-	b.lastCheck = now
-
-	if b.tokens > 0 {
+	for i, b := range buckets {
 		b.tokens--
-		return true
+		if _, exists := rl.requests[keys[i]]; !exists {
+			// Enforce max buckets to prevent memory exhaustion
+			if len(rl.requests) >= rl.maxBuckets {
+				rl.evictOldest()
+			}
+			rl.requests[keys[i]] = b
+		}
 	}
-	return false
+	return true, 0
 }
 
 // evictOldest removes the oldest bucket (called with lock held).
@@ -244,15 +263,33 @@ func (g *Gateway) rateLimit(next http.Handler) http.Handler {
 			strings.HasPrefix(p, "/dashboard") ||
 			strings.HasPrefix(p, "/monitor") ||
 			p == "/health" ||
+			p == "/health/ready" ||
 			p == "/expand" ||
-			p == "/stats" {
+			p == "/stats" ||
+			p == "/stats/tools" ||
+			p == "/stats/budget" ||
+			p == "/telemetry/ingest" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg := g.cfg().Server.RateLimit
+		if cfg.Disabled {
 			next.ServeHTTP(w, r)
 			return
 		}
-		ip := g.getClientIP(r)
-		if !g.rateLimiter.allow(ip) {
-			log.Warn().Str("ip", ip).Msg("rate limit exceeded")
-			w.Header().Set("Retry-After", "1")
+		rps := cfg.RPS
+		if rps <= 0 {
+			rps = DefaultRateLimit
+		}
+		burst := cfg.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(rps))
+		}
+		keys := g.rateLimitKeys(r, cfg.By)
+		if ok, wait := g.rateLimiter.allow(keys, rps, burst); !ok {
+			retryAfter := max(1, int(math.Ceil(wait.Seconds())))
+			log.Warn().Strs("clients", keys).Int("retry_after", retryAfter).Msg("rate limit exceeded")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
 			g.writeError(w, "rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -260,6 +297,30 @@ func (g *Gateway) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// rateLimitKeys returns the bucket keys of a request: the client IP, plus the
+// hashed API key when rate_limit.by has api_key and the request carries one.
+// The IP bucket always applies, so rotating keys cannot bypass the limit.
+func (g *Gateway) rateLimitKeys(r *http.Request, by []string) []string {
+	keys := []string{"ip:" + g.getClientIP(r)}
+	if slices.Contains(by, RateLimitByAPIKey) {
+		if cred := requestCredential(r); cred != "" {
+			sum := sha256.Sum256([]byte(cred))
+			keys = append(keys, "key:"+hex.EncodeToString(sum[:8]))
+		}
+	}
+	return keys
+}
+
+// requestCredential returns the provider credential a request carries, if any.
+func requestCredential(r *http.Request) string {
+	for _, h := range []string{"x-api-key", "Authorization", "api-key", "x-goog-api-key"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return r.URL.Query().Get("key") // Gemini
+}
+
 // security middleware adds security headers and handles CORS.
 func (g *Gateway) security(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -365,19 +426,26 @@ func isLoopback(remoteAddr string) bool {
 
 // isAllowedHost checks if the host is in the allowlist for SSRF protection.
 func (g *Gateway) isAllowedHost(host string) bool {
+	if g.isConfiguredUpstreamHost(host) {
+		return true
+	}
+
 	// Strip port if present
 	if h, _, err := net.SplitHostPort(host); err == nil {
 		host = h
 	}
 	host = strings.ToLower(host)
 
+	// server.allowed_hosts can change on config reload. Like
+	// GATEWAY_ALLOWED_HOSTS it may list loopback IPs, never metadata endpoints.
+	configured := hostInList(host, g.cfg().Server.AllowedHosts)
+
 	// Block cloud metadata endpoints (SSRF target)
-	if isBlockedIP(host) {
+	if isBlockedIP(host) && !(configured && isLoopback(host)) {
 		return false
 	}
 
-	// Check static allowlist first
-	if allowedHosts[host] {
+	if allowedHosts[host] || configured {
 		return true
 	}
 
@@ -391,6 +459,25 @@ func (g *Gateway) isAllowedHost(host string) bool {
 	return false
 }
 
+// hostInList reports whether host matches an allowlist entry: a hostname or
+// IP compared case-insensitively, or a CIDR range containing the IP.
+func hostInList(host string, entries []string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == host {
+			return true
+		}
+		if ip == nil {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // isBlockedIP returns true for IPs that should never be targeted (metadata endpoints, link-local).
 func isBlockedIP(host string) bool {
 	ip := net.ParseIP(host)
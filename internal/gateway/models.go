@@ -26,11 +26,17 @@ type modelsResponse struct {
 }
 
 // handleModels serves an OpenAI-compatible model list from the pricing table.
+// When the client names an upstream via X-Target-URL, the provider's own listing
+// is proxied instead (through the passthrough cache).
 func (g *Gateway) handleModels(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		g.writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if r.Header.Get(HeaderTargetURL) != "" {
+		g.handlePassthrough(w, r)
+		return
+	}
 
 	modelIDs := costcontrol.ListModels()
 	now := time.Now().Unix()
@@ -0,0 +1,112 @@
+// notifications.go - emits notification events (see internal/notify).
+package gateway
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/notify"
+)
+
+// budgetCap is one cost_control cap as reported in budget notifications.
+// scope identifies the cap instance: the session, UTC day or budget scope
+// value; empty for the global cap.
+type budgetCap struct {
+	name        string
+	used, limit float64
+	unit, scope string
+}
+
+// budgetCaps lists the caps measured by a budget check.
+func budgetCaps(sessionID string, budget costcontrol.BudgetCheckResult) []budgetCap {
+	caps := []budgetCap{
+		{costcontrol.ReasonSessionCost, budget.CurrentCost, budget.Cap, "usd", sessionID},
+		{costcontrol.ReasonGlobalCost, budget.GlobalCost, budget.GlobalCap, "usd", ""},
+		{costcontrol.ReasonSessionEgress, float64(budget.SessionEgress), float64(budget.SessionEgressCap), "bytes", sessionID},
+		{costcontrol.ReasonDailyEgress, float64(budget.DailyEgress), float64(budget.DailyEgressCap), "bytes", time.Now().UTC().Format(time.DateOnly)},
+	}
+	for _, s := range budget.Scopes {
+		caps = append(caps, budgetCap{costcontrol.ReasonScopeCost, s.Cost, s.Cap, "usd", scopeID(s)})
+	}
+	return caps
+}
+
+func scopeID(s costcontrol.ScopeUsage) string {
+	return s.Scope + "/" + s.Value + "/" + s.Period
+}
+
+// event builds a budget event for c. Session caps carry the session ID.
+func (c budgetCap) event(t notify.EventType, sessionID string) notify.Event {
+	ev := notify.Event{
+		Type: t,
+		Data: map[string]any{"cap": c.name, "used": c.used, "limit": c.limit, "unit": c.unit},
+	}
+	if c.name == costcontrol.ReasonSessionCost || c.name == costcontrol.ReasonSessionEgress {
+		ev.SessionID = sessionID
+	}
+	if c.name == costcontrol.ReasonScopeCost {
+		ev.Data["scope"] = c.scope
+	}
+	return ev
+}
+
+// notifyBudget fires budget_warning once per cap (and per session for
+// session caps, per UTC day for the daily egress cap, per value and window
+// for budget scopes) when usage crosses notifications.budget_warning_at.
+func (g *Gateway) notifyBudget(sessionID string, budget costcontrol.BudgetCheckResult) {
+	if g.notifier == nil || !g.costTracker.Config().Enabled {
+		return
+	}
+	warnAt := g.notifier.BudgetWarningAt()
+	for _, c := range budgetCaps(sessionID, budget) {
+		if c.limit <= 0 || c.used/c.limit < warnAt {
+			continue
+		}
+		ev := c.event(notify.EventBudgetWarning, sessionID)
+		ev.Data["utilization"] = c.used / c.limit
+		g.notifier.NotifyOnce(fmt.Sprintf("budget/%s/%s", c.name, c.scope), ev)
+	}
+}
+
+// notifyBudgetExceeded fires budget_exceeded for the cap that blocked a
+// request, at most once per cap instance every
+// notifications.budget_exceeded_interval.
+func (g *Gateway) notifyBudgetExceeded(sessionID string, budget costcontrol.BudgetCheckResult) {
+	if g.notifier == nil || budget.Allowed {
+		return
+	}
+	for _, c := range budgetCaps(sessionID, budget) {
+		if c.name != budget.Reason {
+			continue
+		}
+		if c.name == costcontrol.ReasonScopeCost && (budget.Scope == nil || c.scope != scopeID(*budget.Scope)) {
+			continue
+		}
+		g.notifier.NotifyThrottled(fmt.Sprintf("budget_exceeded/%s/%s", c.name, c.scope), c.event(notify.EventBudgetExceeded, sessionID))
+		return
+	}
+}
+
+// notifyCompaction fires compaction_done after a preemptive summary replaced
+// the conversation history.
+func (g *Gateway) notifyCompaction(sessionID, model string, tokensBefore, tokensAfter int, instant bool) {
+	g.notifier.Notify(notify.Event{
+		Type:      notify.EventCompactionDone,
+		SessionID: sessionID,
+		Data:      map[string]any{"model": model, "tokens_before": tokensBefore, "tokens_after": tokensAfter, "instant": instant},
+	})
+}
+
+// observeUpstreamHealth feeds request outcomes to provider_outage detection.
+// Only failures to reach or get an answer from the provider count; 4xx means
+// the provider is up.
+func (g *Gateway) observeUpstreamHealth(provider string, code monitoring.ErrorCode, status int) {
+	switch code {
+	case monitoring.ErrorCodeUpstreamTimeout, monitoring.ErrorCodeUpstreamUnavailable, monitoring.ErrorCodeUpstream5xx:
+		g.notifier.ObserveUpstream(provider, true, string(code), status)
+	default:
+		g.notifier.ObserveUpstream(provider, false, "", status)
+	}
+}
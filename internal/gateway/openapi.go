@@ -0,0 +1,432 @@
+// openapi.go - OpenAPI 3.1 description of the gateway's own endpoints.
+//
+// GET /openapi.json serves a document built from openAPIOperations. Request
+// and response schemas are derived by reflection from the Go types the
+// handlers encode, so field names and types follow the code. Every route
+// registered by setupRoutes must be described here; the gateway unit tests
+// fail when a route is added without an operation.
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/fleet"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+// managedRoute is one route the gateway serves itself (not proxied).
+type managedRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// managedRoutes lists the gateway-managed routes of the proxy server, in
+// registration order. setupRoutes registers them; the OpenAPI document
+// describes them.
+func (g *Gateway) managedRoutes() []managedRoute {
+	return []managedRoute{
+		{"/health", g.handleHealth},
+		{"/health/ready", g.handleHealthReady},
+		{"/expand", g.handleExpand},
+		{"/mcp", g.handleMCP},
+		{"/mcp/sse", g.handleMCPStream},
+		{"/mcp/message", g.handleMCPMessage},
+		{"/openapi.json", g.handleOpenAPI},
+		// API endpoints still available on proxy port for internal use (e.g., /savings slash command)
+		{"/api/dashboard", g.handleDashboardAPI},
+		{"/api/savings", g.handleSavingsAPI},
+		{"/api/account", g.handleAccountAPI},
+		{"/api/compressions", g.handleCompressionsAPI},
+		{"/api/config", g.handleConfigAPI},
+		{"/api/prompts", g.handlePromptsAPI},
+		{"/api/prompts/erase", g.handleErasePrompts},
+		{"/api/prompts/", g.handleDeletePrompt},
+		{"/api/session", g.handleDeleteSession},
+		{"/api/compress/", g.handleCompressAPINotFound},
+		{"/stats", g.handleStats},
+		{"/stats/tools", g.handleToolStats},
+		{"/stats/budget", g.handleBudgetSimulation},
+		{"/metrics", g.handleMetrics},
+		{"/telemetry/ingest", g.handleTelemetryIngest},
+		{"/config/effective", g.handleEffectiveConfig},
+		{"/debug/route", g.handleRouteDebug},
+		{"/context/estimate", g.handleContextEstimate},
+		{"/debug/requests", g.handleDebugRequests},
+		{"/debug/requests/", g.handleDebugRequests},
+		{"/admin/requests", g.handleAdminRequests},
+		{"/admin/requests/", g.handleAdminRequests},
+		{"/sessions", g.handleSessions},
+		{"/sessions/", g.handleSessions},
+		{"/admin/sessions", g.handleAdminSessions},
+		{"/admin/sessions/", g.handleAdminSessions},
+		{"/admin/state", g.handleAdminState},
+		{"/admin/flags", g.handleAdminFlags},
+		{"/admin/flags/", g.handleAdminFlags},
+		{"/admin/config", g.handleAdminConfig},
+		{"/admin/config/reload", g.handleAdminConfigReload},
+		{"/v1/models", g.requireClientAuth(g.handleModels)},
+	}
+}
+
+// ManagedRoutePatterns returns the mux patterns of the gateway-managed routes.
+func ManagedRoutePatterns() []string {
+	routes := (&Gateway{}).managedRoutes()
+	patterns := make([]string, len(routes))
+	for i, rt := range routes {
+		patterns[i] = rt.pattern
+	}
+	return patterns
+}
+
+// apiOperation describes one method on one path.
+type apiOperation struct {
+	method   string
+	path     string // OpenAPI path; {name} segments become path parameters
+	tag      string
+	summary  string
+	loopback bool // Rejected with 403 unless the client is on loopback
+	admin    bool // Rejected with 403 unless adminAuthorized (loopback, or server.admin_token bearer)
+
+	query    []apiParam
+	request  any    // Request body (JSON); nil = none
+	response any    // 200 response body (JSON); nil = generic object
+	content  string // 200 content type when not application/json
+}
+
+// apiParam is a query parameter.
+type apiParam struct {
+	name, description string
+}
+
+// Shapes of responses encoded from maps or function-local types.
+type (
+	healthResponse struct {
+		Status  string `json:"status"` // ok | degraded (503)
+		Time    string `json:"time"`
+		Version string `json:"version"`
+
+		UpstreamCircuits map[string]circuitStatus `json:"upstream_circuits,omitempty"` // With upstream_circuit_breaker enabled
+	}
+	expandRequest struct {
+		ID string `json:"id"`
+	}
+	expandResponse struct {
+		ID      string `json:"id"`
+		Content string `json:"content"`
+	}
+	okResponse struct {
+		OK bool `json:"ok"`
+	}
+	inflightListResponse struct {
+		Count    int               `json:"count"`
+		Requests []InflightRequest `json:"requests"`
+	}
+	inflightCancelResponse struct {
+		ID        string `json:"id"`
+		Cancelled bool   `json:"cancelled"`
+	}
+	sessionStoresResponse struct {
+		Stores []sessionstore.StoreStats `json:"stores"`
+	}
+	sessionExpireResponse struct {
+		SessionID string   `json:"session_id"`
+		Stores    []string `json:"stores"`
+	}
+	capturedListResponse struct {
+		Count    int               `json:"count"`
+		Requests []CapturedRequest `json:"requests"`
+	}
+	apiErrorResponse struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+)
+
+// openAPIOperations describes every gateway-managed endpoint.
+var openAPIOperations = []apiOperation{
+	{method: "get", path: "/health", tag: "health", summary: "Gateway health (503 when the shadow store is unavailable)", response: healthResponse{}},
+	{method: "get", path: "/health/ready", tag: "health", summary: "Per-dependency readiness: store, summarizer auth, upstream and Compresr probes (503 when the store or every probed upstream is down)", response: readinessResponse{}},
+	{method: "get", path: "/openapi.json", tag: "health", summary: "This OpenAPI document"},
+	{method: "post", path: "/expand", tag: "expand", summary: "Fetch the original content behind a shadow ID", loopback: true, request: expandRequest{}, response: expandResponse{}},
+	{method: "post", path: "/mcp", tag: "expand", summary: "MCP JSON-RPC message (expand_context, search_tools, get_session_cost)", admin: true},
+	{method: "get", path: "/mcp/sse", tag: "expand", summary: "MCP HTTP+SSE event stream; the first event names the message endpoint", content: "text/event-stream"},
+	{method: "post", path: "/mcp/message", tag: "expand", summary: "MCP JSON-RPC message for an open event stream; the response arrives on the stream",
+		query: []apiParam{{"session_id", "Stream ID from the endpoint event"}}},
+
+	{method: "get", path: "/stats", tag: "stats", summary: "Request, compression, savings and store statistics", loopback: true, response: StatsResponse{}},
+	{method: "get", path: "/stats/tools", tag: "stats", summary: "Per-tool compression savings leaderboard", loopback: true, response: ToolStatsResponse{},
+		query: []apiParam{{"limit", "Return only the top N tools"}}},
+	{method: "get", path: "/stats/budget", tag: "stats", summary: "Would-be budget rejections recorded in cost_control.mode simulate", loopback: true, response: BudgetSimulationResponse{},
+		query: []apiParam{{"since", "Only include events within this duration (e.g. 24h); default all retained"}}},
+	{method: "get", path: "/metrics", tag: "stats", summary: "Prometheus metrics", loopback: true, content: "text/plain"},
+	{method: "post", path: "/telemetry/ingest", tag: "stats", summary: "Receive a zstd telemetry batch from another replica (monitoring.telemetry_collector; bearer token)", response: fleet.IngestResponse{}},
+	{method: "get", path: "/api/dashboard", tag: "stats", summary: "Dashboard data: requests, savings and costs", loopback: true,
+		query: []apiParam{{"session", "Limit to one session ID"}}},
+	{method: "get", path: "/api/savings", tag: "stats", summary: "Savings report (text)", content: "text/plain",
+		query: []apiParam{{"session", "Limit to one session ID"}}},
+	{method: "get", path: "/api/account", tag: "stats", summary: "Compresr account status", loopback: true},
+	{method: "get", path: "/api/compressions", tag: "stats", summary: "Recent compression samples; with request_id, one sample with original and forwarded content", loopback: true, response: compressionSampleList{},
+		query: []apiParam{{"request_id", "Return this request's sample (monitoring.ComparisonSample) instead of the list"}}},
+
+	{method: "get", path: "/api/config", tag: "config", summary: "Runtime-editable configuration", loopback: true, response: configResponse{}},
+	{method: "patch", path: "/api/config", tag: "config", summary: "Update runtime configuration (hot reload)", loopback: true, request: config.ConfigPatch{}, response: configResponse{}},
+	{method: "delete", path: "/api/config", tag: "config", summary: "Revert runtime configuration overrides", loopback: true},
+	{method: "get", path: "/config/effective", tag: "config", summary: "Effective configuration, secrets redacted", loopback: true, response: config.EffectiveConfig{}},
+
+	{method: "get", path: "/api/prompts", tag: "sessions", summary: "Recorded prompt history", loopback: true},
+	{method: "delete", path: "/api/prompts/erase", tag: "sessions", summary: "Erase all recorded prompts", loopback: true, response: okResponse{}},
+	{method: "delete", path: "/api/prompts/{id}", tag: "sessions", summary: "Delete one recorded prompt", loopback: true, response: okResponse{}},
+	{method: "delete", path: "/api/session", tag: "sessions", summary: "Delete a session's dashboard data", loopback: true, response: okResponse{},
+		query: []apiParam{{"id", "Session ID"}}},
+	{method: "get", path: "/sessions", tag: "sessions", summary: "Recent sessions with cost, auth mode, tools and summary state", loopback: true, response: sessionListResponse{}},
+	{method: "get", path: "/sessions/{id}", tag: "sessions", summary: "One session's cost, auth mode, tools and summary state", loopback: true, response: SessionInfo{}},
+	{method: "get", path: "/admin/sessions", tag: "sessions", summary: "Session store sizes and eviction counts", admin: true, response: sessionStoresResponse{}},
+	{method: "delete", path: "/admin/sessions/{id}", tag: "sessions", summary: "Expire a session from every store", admin: true, response: sessionExpireResponse{}},
+	{method: "get", path: "/context/estimate", tag: "sessions", summary: "Token estimate, context window and headroom for a sample request", loopback: true, response: ContextEstimate{},
+		query: []apiParam{{"path", "Request path the sample is for (default /v1/messages)"}, {"compress", "false skips the compression pipes"}}},
+	{method: "post", path: "/context/estimate", tag: "sessions", summary: "Token estimate, context window and headroom for a sample request", loopback: true, response: ContextEstimate{},
+		query: []apiParam{{"path", "Request path the sample is for (default /v1/messages)"}, {"compress", "false skips the compression pipes"}}},
+
+	{method: "get", path: "/admin/requests", tag: "admin", summary: "In-flight proxy requests", admin: true, response: inflightListResponse{}},
+	{method: "delete", path: "/admin/requests/{id}", tag: "admin", summary: "Cancel an in-flight request", admin: true, response: inflightCancelResponse{}},
+	{method: "get", path: "/admin/state", tag: "admin", summary: "Export in-memory state (sessions, costs, shadow store)", admin: true, response: StateSnapshot{}},
+	{method: "post", path: "/admin/state", tag: "admin", summary: "Restore in-memory state from an export", admin: true, request: StateSnapshot{}, response: StateRestoreResult{}},
+	{method: "get", path: "/admin/flags", tag: "admin", summary: "Feature flags with decision counts", admin: true, response: flagListResponse{}},
+	{method: "get", path: "/admin/flags/{name}", tag: "admin", summary: "Explain a feature flag's decision for a session, user or tags", admin: true, response: flagExplainResponse{},
+		query: []apiParam{{"session", "Session ID"}, {"user", "X-Gateway-User value"}, {"tags", "Comma-separated session tags"}}},
+	{method: "put", path: "/admin/flags/{name}", tag: "admin", summary: "Override a feature flag at runtime (not persisted)", admin: true, request: featureflags.Flag{}, response: featureflags.State{}},
+	{method: "delete", path: "/admin/flags/{name}", tag: "admin", summary: "Drop a feature flag override, reverting to config", admin: true, response: flagClearResponse{}},
+	{method: "get", path: "/admin/config", tag: "admin", summary: "Effective configuration, secrets redacted", admin: true, response: config.EffectiveConfig{}},
+	{method: "patch", path: "/admin/config", tag: "admin", summary: "Hot-reload pipes, cost caps and allowed hosts", admin: true, request: config.ConfigPatch{}, response: config.EffectiveConfig{},
+		query: []apiParam{{"scope", "session applies the patch in memory only; default persists it to the config file"}}},
+	{method: "post", path: "/admin/config/reload", tag: "admin", summary: "Re-read the config file without a restart", admin: true, response: config.EffectiveConfig{}},
+
+	{method: "post", path: "/debug/route", tag: "debug", summary: "Explain the routing decision for a sample request", loopback: true, response: routeDebugResponse{},
+		query: []apiParam{{"path", "Request path the sample is for"}}},
+	{method: "get", path: "/debug/requests", tag: "debug", summary: "Captured requests", loopback: true, response: capturedListResponse{}},
+	{method: "get", path: "/debug/requests/{id}", tag: "debug", summary: "One captured request and its forwards", loopback: true, response: CapturedRequest{}},
+	{method: "get", path: "/debug/requests/{id}/original", tag: "debug", summary: "Request body as received", loopback: true},
+	{method: "get", path: "/debug/requests/{id}/forwarded", tag: "debug", summary: "Request body as forwarded", loopback: true,
+		query: []apiParam{{"attempt", "Forward attempt (default: last)"}}},
+	{method: "post", path: "/debug/requests/{id}/replay", tag: "debug", summary: "Re-run the pipeline on the captured body without forwarding", loopback: true},
+
+	{method: "get", path: "/v1/models", tag: "models", summary: "OpenAI-compatible model list (proxied when X-Target-URL is set)", response: modelsResponse{}},
+	{method: "post", path: "/api/compress/{path}", tag: "models", summary: "Removed endpoint; always 404"},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPISpec builds the OpenAPI 3.1 document for the gateway-managed endpoints.
+func OpenAPISpec(version string) map[string]any {
+	if version == "" {
+		version = "dev"
+	}
+	schemas := map[string]any{}
+	errorRef := schemaFor(reflect.TypeOf(apiErrorResponse{}), schemas)
+
+	paths := map[string]any{}
+	for _, op := range openAPIOperations {
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[op.path] = item
+		}
+
+		var params []any
+		for _, m := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+			params = append(params, map[string]any{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range op.query {
+			params = append(params, map[string]any{
+				"name": q.name, "in": "query", "description": q.description, "schema": map[string]any{"type": "string"},
+			})
+		}
+
+		okContent := map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}
+		switch {
+		case op.content != "":
+			okContent = map[string]any{op.content: map[string]any{"schema": map[string]any{"type": "string"}}}
+		case op.response != nil:
+			okContent = map[string]any{"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(op.response), schemas)}}
+		}
+		errContent := map[string]any{"application/json": map[string]any{"schema": errorRef}}
+		responses := map[string]any{
+			"200":     map[string]any{"description": "OK", "content": okContent},
+			"default": map[string]any{"description": "Error", "content": errContent},
+		}
+		if op.loopback {
+			responses["403"] = map[string]any{"description": "Client is not on loopback", "content": errContent}
+		}
+		if op.admin {
+			responses["403"] = map[string]any{"description": "Client is not on loopback, or lacks the server.admin_token bearer when one is set", "content": errContent}
+		}
+
+		operation := map[string]any{
+			"operationId": operationID(op.method, op.path),
+			"summary":     op.summary,
+			"tags":        []string{op.tag},
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(op.request), schemas)}},
+			}
+		}
+		item[op.method] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Context Gateway management API",
+			"version":     version,
+			"description": "Endpoints served by the gateway itself. Provider API paths are proxied and not described here.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// operationID derives a stable camelCase ID, e.g. ("delete", "/admin/requests/{id}") → deleteAdminRequestsById.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '_' }) {
+		if strings.HasPrefix(part, "{") {
+			part = "by_" + strings.Trim(part, "{}")
+		}
+		for _, word := range strings.Split(part, "_") {
+			if word != "" {
+				b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+		}
+	}
+	return b.String()
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns a JSON Schema for t. Named struct types are added to
+// schemas and referenced; anonymous structs are inlined.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]any{} // Placeholder breaks recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema builds an object schema from t's JSON-encoded fields.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			// Embedded struct: its fields are promoted.
+			if embedded, ok := structSchema(f.Type, schemas)["properties"].(map[string]any); ok {
+				for k, v := range embedded {
+					props[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaFor(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName is the component name for a named type: the type name with an
+// upper-case first letter, prefixed by its package outside the gateway package.
+func schemaName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if pkg := t.PkgPath(); pkg != "" && !strings.HasSuffix(pkg, "/internal/gateway") {
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+// handleOpenAPI serves GET /openapi.json.
+func (g *Gateway) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(OpenAPISpec(g.version)); err != nil {
+		log.Warn().Err(err).Msg("handleOpenAPI: failed to encode JSON response")
+	}
+}
@@ -0,0 +1,151 @@
+// passthrough_cache.go - TTL cache for idempotent passthrough endpoints.
+//
+// Agents poll token-count and model-listing endpoints far more often than the
+// answers change. Responses are cached per target URL, credential, and request
+// body so one client's key never serves another client's response.
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// Cache status header values returned on cacheable passthrough responses.
+const (
+	HeaderGatewayCache = "X-Gateway-Cache"
+	cacheStatusHit     = "HIT"
+	cacheStatusMiss    = "MISS"
+)
+
+// cachedResponse is a stored upstream response.
+type cachedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+// responseCache is a bounded TTL cache of upstream responses.
+type responseCache struct {
+	mu         sync.Mutex
+	entries    map[string]cachedResponse
+	maxEntries int
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// newResponseCache creates a cache holding at most maxEntries responses.
+func newResponseCache(maxEntries int) *responseCache {
+	if maxEntries <= 0 {
+		maxEntries = config.DefaultPassthroughCacheEntries
+	}
+	return &responseCache{
+		entries:    make(map[string]cachedResponse),
+		maxEntries: maxEntries,
+	}
+}
+
+// get returns a live entry for key, dropping it if expired.
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expiresAt) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return e, ok
+}
+
+// set stores an entry. When full, expired entries are swept first and then the
+// entry closest to expiry is evicted.
+func (c *responseCache) set(key string, e cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, v := range c.entries {
+			if now.After(v.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			var soonestKey string
+			var soonest time.Time
+			for k, v := range c.entries {
+				if soonestKey == "" || v.expiresAt.Before(soonest) {
+					soonestKey, soonest = k, v.expiresAt
+				}
+			}
+			delete(c.entries, soonestKey)
+		}
+	}
+	c.entries[key] = e
+}
+
+// size returns the number of stored entries (including not-yet-swept expired ones).
+func (c *responseCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Reset drops all entries and counters.
+func (c *responseCache) Reset() {
+	c.mu.Lock()
+	c.entries = make(map[string]cachedResponse)
+	c.mu.Unlock()
+	c.hits.Store(0)
+	c.misses.Store(0)
+}
+
+// passthroughCacheTTL returns the configured TTL for path, or false when the
+// path is not cacheable (or caching is disabled).
+func (g *Gateway) passthroughCacheTTL(path string) (time.Duration, bool) {
+	pc := g.cfg().PassthroughCache
+	if !pc.Enabled || g.passthroughCache == nil {
+		return 0, false
+	}
+	ttl, ok := pc.Paths[path]
+	return ttl, ok && ttl > 0
+}
+
+// requestCacheKey derives a cache key from everything that can change the
+// upstream answer: method, resolved target, credential, API version/beta
+// headers, and request body. The credential is hashed, never stored.
+func (g *Gateway) requestCacheKey(r *http.Request, body []byte) string {
+	target := r.Header.Get(HeaderTargetURL)
+	if target == "" {
+		target = g.autoDetectTargetURL(r)
+	}
+	h := sha256.New()
+	for _, part := range []string{
+		r.Method,
+		target,
+		r.URL.Path,
+		r.URL.RawQuery,
+		r.Header.Get("x-api-key"),
+		r.Header.Get("Authorization"),
+		r.Header.Get("x-goog-api-key"),
+		r.Header.Get("api-key"),
+		r.Header.Get("anthropic-version"),
+		r.Header.Get("anthropic-beta"),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -9,9 +9,12 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/tracing"
 )
 
 // MaxPhantomLoops prevents infinite recursion.
@@ -91,14 +94,28 @@ func (p *PhantomLoop) Run(
 	}
 	currentBody := body
 
+	// One span per iteration, ended when the next one starts or the loop exits.
+	var iterSpan trace.Span
+	defer func() {
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+	}()
+
 	for {
 		if ctx.Err() != nil {
 			log.Debug().Msg("phantom_loop: context cancelled, stopping loop")
 			break
 		}
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+		var iterCtx context.Context
+		iterCtx, iterSpan = tracing.Start(ctx, tracing.SpanPhantomIteration, attribute.Int("gateway.phantom_loop.iteration", result.LoopCount))
+
 		// Forward to LLM
 		forwardStart := time.Now()
-		resp, err := forwardFunc(ctx, currentBody)
+		resp, err := forwardFunc(iterCtx, currentBody)
 		result.ForwardLatency += time.Since(forwardStart)
 
 		if err != nil {
@@ -145,6 +162,7 @@ func (p *PhantomLoop) Run(
 
 		// Check for phantom tool calls
 		allCalls := p.parsePhantomCalls(responseBody, adapter)
+		iterSpan.SetAttributes(attribute.Int("gateway.phantom_calls", len(allCalls)))
 		if len(allCalls) == 0 || result.LoopCount >= MaxPhantomLoops {
 			if result.LoopCount >= MaxPhantomLoops && len(allCalls) > 0 {
 				log.Warn().Int("max_loops", MaxPhantomLoops).Msg("phantom_loop: max loops reached")
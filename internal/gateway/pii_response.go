@@ -0,0 +1,245 @@
+// pii_response.go - Restores PII tokens the model echoes back to the client.
+//
+// The PII pipe replaces entities in the request with tokens; the model sees
+// only tokens and may repeat them (in text or tool-call arguments). Non-
+// streaming responses are unmasked in one pass. Streaming responses are
+// rewritten per SSE event: text fragments are fed through a StreamUnmasker
+// keyed by content block, which holds back a trailing partial token until the
+// next delta. Anything still held back when a block ends is emitted as one
+// extra delta event just before the block's stop event.
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/pipes/pii"
+)
+
+// piiUnmaskEnabled reports whether responses should have PII tokens restored.
+// A pii_response_unmask feature flag can turn it off per request.
+func (g *Gateway) piiUnmaskEnabled(flags featureflags.Decisions) bool {
+	pc := g.cfg().Pipes.PII
+	return pc.Enabled && !pc.MaskOnly && g.store != nil && flags.On(featureflags.PIIResponseUnmask, true)
+}
+
+// unmaskPIIResponse restores PII tokens in a complete JSON response body.
+func (g *Gateway) unmaskPIIResponse(body []byte, flags featureflags.Decisions) []byte {
+	if !g.piiUnmaskEnabled(flags) {
+		return body
+	}
+	return pii.NewUnmasker(g.store).UnmaskJSON(body)
+}
+
+// piiStreamWriter rewrites SSE events to restore PII tokens.
+type piiStreamWriter struct {
+	http.ResponseWriter
+	rw  *piiSSERewriter
+	buf []byte // bytes of the current, not yet complete, event
+}
+
+func newPIIStreamWriter(w http.ResponseWriter, rw *piiSSERewriter) *piiStreamWriter {
+	return &piiStreamWriter{ResponseWriter: w, rw: rw}
+}
+
+// Write buffers p and forwards every complete SSE event, rewritten.
+func (w *piiStreamWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		end := bytes.Index(w.buf, []byte("\n\n"))
+		if end < 0 {
+			return len(p), nil
+		}
+		event := w.rw.rewriteEvent(w.buf[:end])
+		rest := w.buf[end+2:]
+		if _, err := w.ResponseWriter.Write(append(event, '\n', '\n')); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], rest...)
+	}
+}
+
+// Flush forwards complete events; a partial event stays buffered until its terminator arrives.
+func (w *piiStreamWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *piiStreamWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// close writes any trailing bytes that never formed a complete event.
+func (w *piiStreamWriter) close() {
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.rw.rewriteEvent(w.buf))
+		w.buf = nil
+	}
+}
+
+// piiSSERewriter restores tokens inside streaming deltas for Anthropic,
+// OpenAI chat, OpenAI Responses, and Gemini event shapes.
+type piiSSERewriter struct {
+	um       *pii.StreamUnmasker
+	unmasker *pii.Unmasker
+}
+
+func newPIISSERewriter(g *Gateway) *piiSSERewriter {
+	return &piiSSERewriter{um: pii.NewStreamUnmasker(g.store), unmasker: pii.NewUnmasker(g.store)}
+}
+
+// rewriteEvent rewrites the data lines of one SSE event (without its blank-line terminator).
+// Extra events needed to flush held-back text are prepended.
+func (rw *piiSSERewriter) rewriteEvent(event []byte) []byte {
+	lines := bytes.Split(event, []byte("\n"))
+	var prefix []byte
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" "))
+		newData, pre := rw.rewriteData(data)
+		lines[i] = append([]byte("data: "), newData...)
+		prefix = append(prefix, pre...)
+	}
+	return append(prefix, bytes.Join(lines, []byte("\n"))...)
+}
+
+// rewriteData rewrites one data payload, returning it and any events to emit before it.
+func (rw *piiSSERewriter) rewriteData(data []byte) ([]byte, []byte) {
+	if !bytes.Contains(data, []byte("[")) && !rw.um.HasPending() {
+		return data, nil
+	}
+	root := gjson.ParseBytes(data)
+	switch root.Get("type").String() {
+	case "content_block_delta":
+		idx := root.Get("index").String()
+		switch root.Get("delta.type").String() {
+		case "text_delta":
+			return rw.push(data, "delta.text", "a:"+idx+":text", false), nil
+		case "input_json_delta":
+			return rw.push(data, "delta.partial_json", "a:"+idx+":json", true), nil
+		}
+		return data, nil
+	case "content_block_stop":
+		idx := root.Get("index").Int()
+		var pre []byte
+		if rest := rw.um.Flush(fmt.Sprintf("a:%d:text", idx)); rest != "" {
+			pre = append(pre, piiSSEEvent("content_block_delta", map[string]any{
+				"type": "content_block_delta", "index": idx,
+				"delta": map[string]any{"type": "text_delta", "text": rest},
+			})...)
+		}
+		if rest := rw.um.Flush(fmt.Sprintf("a:%d:json", idx)); rest != "" {
+			pre = append(pre, piiSSEEvent("content_block_delta", map[string]any{
+				"type": "content_block_delta", "index": idx,
+				"delta": map[string]any{"type": "input_json_delta", "partial_json": rest},
+			})...)
+		}
+		return data, pre
+	case "response.output_text.delta":
+		return rw.push(data, "delta", responsesKey(root), false), nil
+	case "response.output_text.done":
+		var pre []byte
+		if rest := rw.um.Flush(responsesKey(root)); rest != "" {
+			pre = piiSSEEvent("response.output_text.delta", map[string]any{
+				"type": "response.output_text.delta", "item_id": root.Get("item_id").String(),
+				"output_index": root.Get("output_index").Int(), "content_index": root.Get("content_index").Int(),
+				"delta": rest,
+			})
+		}
+		return rw.unmasker.UnmaskJSON(data), pre
+	case "":
+		if root.Get("choices").Exists() {
+			return rw.rewriteOpenAIChunk(data, root), nil
+		}
+		if root.Get("candidates").Exists() {
+			return rw.rewriteGeminiChunk(data, root), nil
+		}
+	}
+	return rw.unmasker.UnmaskJSON(data), nil
+}
+
+// push feeds the fragment at path through the stream unmasker and writes back what is safe to emit.
+func (rw *piiSSERewriter) push(data []byte, path, key string, jsonEscape bool) []byte {
+	out := rw.um.Push(key, gjson.GetBytes(data, path).String(), jsonEscape)
+	updated, err := sjson.SetBytes(data, path, out)
+	if err != nil {
+		return data
+	}
+	return updated
+}
+
+// rewriteOpenAIChunk handles chat.completion.chunk content and tool-call argument deltas.
+// Held-back content is appended to the choice's finishing chunk.
+func (rw *piiSSERewriter) rewriteOpenAIChunk(data []byte, root gjson.Result) []byte {
+	root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+		base := "choices." + i.String()
+		key := "o:" + choice.Get("index").String()
+		if choice.Get("delta.content").Type == gjson.String {
+			data = rw.push(data, base+".delta.content", key, false)
+		}
+		choice.Get("delta.tool_calls").ForEach(func(j, tc gjson.Result) bool {
+			if tc.Get("function.arguments").Exists() {
+				data = rw.push(data, base+".delta.tool_calls."+j.String()+".function.arguments",
+					key+":tc:"+tc.Get("index").String(), true)
+			}
+			return true
+		})
+		if choice.Get("finish_reason").Type == gjson.String {
+			if rest := rw.um.Flush(key); rest != "" {
+				content := gjson.GetBytes(data, base+".delta.content").String() + rest
+				if updated, err := sjson.SetBytes(data, base+".delta.content", content); err == nil {
+					data = updated
+				}
+			}
+		}
+		return true
+	})
+	return data
+}
+
+// rewriteGeminiChunk handles streamGenerateContent text parts.
+func (rw *piiSSERewriter) rewriteGeminiChunk(data []byte, root gjson.Result) []byte {
+	root.Get("candidates").ForEach(func(i, cand gjson.Result) bool {
+		finished := cand.Get("finishReason").Exists()
+		cand.Get("content.parts").ForEach(func(j, part gjson.Result) bool {
+			if part.Get("text").Type != gjson.String {
+				return true
+			}
+			path := "candidates." + i.String() + ".content.parts." + j.String() + ".text"
+			key := "g:" + i.String()
+			data = rw.push(data, path, key, false)
+			if finished {
+				if rest := rw.um.Flush(key); rest != "" {
+					if updated, err := sjson.SetBytes(data, path, gjson.GetBytes(data, path).String()+rest); err == nil {
+						data = updated
+					}
+				}
+			}
+			return true
+		})
+		return true
+	})
+	return data
+}
+
+func responsesKey(root gjson.Result) string {
+	return "r:" + root.Get("item_id").String() + ":" + strconv.FormatInt(root.Get("content_index").Int(), 10)
+}
+
+// piiSSEEvent encodes a named SSE event with a JSON payload.
+func piiSSEEvent(name string, payload map[string]any) []byte {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return []byte("event: " + name + "\ndata: " + string(data) + "\n\n")
+}
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/pipes/pii"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+func newTestPIIWriter(t *testing.T, value string) (*piiStreamWriter, *httptest.ResponseRecorder, string) {
+	t.Helper()
+	st := store.NewMemoryStore(time.Hour)
+	token := pii.Token("EMAIL", value)
+	_ = st.Set(pii.StoreKey(token), value)
+	rec := httptest.NewRecorder()
+	rw := &piiSSERewriter{um: pii.NewStreamUnmasker(st), unmasker: pii.NewUnmasker(st)}
+	return newPIIStreamWriter(rec, rw), rec, token
+}
+
+func TestPIIStreamWriter_AnthropicTokenSplitAcrossDeltas(t *testing.T) {
+	w, rec, token := newTestPIIWriter(t, "jane@example.com")
+	half := len(token) / 2
+	events := []string{
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"mail ` + token[:half] + `"}}` + "\n\n",
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + token[half:] + ` now"}}` + "\n\n",
+		`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}` + "\n\n",
+	}
+	// Write in odd-sized chunks to exercise event buffering.
+	stream := strings.Join(events, "")
+	for i := 0; i < len(stream); i += 7 {
+		_, _ = w.Write([]byte(stream[i:min(i+7, len(stream))]))
+	}
+	w.close()
+
+	out := rec.Body.String()
+	assert.NotContains(t, out, "PII_")
+	assert.Contains(t, out, `"text":"mail "`)
+	assert.Contains(t, out, `"text":"jane@example.com now"`)
+	assert.Equal(t, 3, strings.Count(out, "\n\n"))
+}
+
+func TestPIIStreamWriter_FlushesHeldTextBeforeBlockStop(t *testing.T) {
+	w, rec, _ := newTestPIIWriter(t, "x@y.io")
+	_, _ = w.Write([]byte("event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"see [[PII"}}` + "\n\n" +
+		"event: content_block_stop\n" + `data: {"type":"content_block_stop","index":1}` + "\n\n"))
+
+	out := rec.Body.String()
+	held := strings.Index(out, `"text":"[[PII"`)
+	stop := strings.Index(out, "content_block_stop")
+	assert.Greater(t, held, 0)
+	assert.Less(t, held, stop)
+}
+
+func TestPIIStreamWriter_OpenAIChunks(t *testing.T) {
+	w, rec, token := newTestPIIWriter(t, "a@b.io")
+	_, _ = w.Write([]byte(`data: {"choices":[{"index":0,"delta":{"content":"to ` + token[:5] + `"}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"content":"` + token[5:] + `"},"finish_reason":"stop"}]}` + "\n\n" +
+		"data: [DONE]\n\n"))
+
+	out := rec.Body.String()
+	assert.Contains(t, out, `"content":"to "`)
+	assert.Contains(t, out, `"content":"a@b.io"`)
+	assert.Contains(t, out, "data: [DONE]\n\n")
+}
+
+func TestPIIStreamWriter_ToolArgumentsAreJSONEscaped(t *testing.T) {
+	w, rec, token := newTestPIIWriter(t, `o"neil@example.com`)
+	_, _ = w.Write([]byte(`data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"to\":\"` + token + `\"}"}}` + "\n\n"))
+
+	assert.Contains(t, rec.Body.String(), `{\"to\":\"o\\\"neil@example.com\"}`)
+}
@@ -0,0 +1,63 @@
+// priority.go - request priority classes in the proxy path.
+//
+// Requests are classified (X-Gateway-Priority header, X-Session-Tags, default
+// class) after the budget check. Background and batch requests are shed once a
+// cost_control cap is nearly used up, then every request waits for a
+// concurrency slot; interactive requests are served first. Rejections are 429
+// with Retry-After so well-behaved clients back off and retry.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/priority"
+)
+
+// admitByPriority sheds or queues the request by class. budgetUtilization is
+// how full the fullest enforced cost_control cap is (0 when none is enforced).
+// On success the caller must call release when the request is done; on failure
+// the response has been written.
+func (g *Gateway) admitByPriority(w http.ResponseWriter, r *http.Request, pipeCtx *PipelineContext, requestID string, budgetUtilization float64) (release func(), ok bool) {
+	if g.scheduler == nil {
+		return func() {}, true
+	}
+	class := g.scheduler.Classify(r.Header, pipeCtx.SessionTags)
+	w.Header().Set(priority.HeaderPriority, class.String())
+
+	if g.scheduler.Shed(class, budgetUtilization) {
+		log.Warn().
+			Str("request_id", requestID).
+			Str("class", class.String()).
+			Float64("budget_utilization", budgetUtilization).
+			Msg("priority: shedding request near budget cap")
+		g.recordError(monitoring.ErrorCodePriorityShed)
+		w.Header().Set("Retry-After", "60")
+		g.writeError(w, fmt.Sprintf("%s request rejected: budget is %.0f%% used and reserved for interactive traffic",
+			class, budgetUtilization*100), http.StatusTooManyRequests)
+		return nil, false
+	}
+
+	release, err := g.scheduler.Acquire(r.Context(), class)
+	if err == nil {
+		return release, true
+	}
+	if errors.Is(err, context.Canceled) {
+		// Client went away while queued; nobody is left to answer.
+		return nil, false
+	}
+	log.Warn().
+		Err(err).
+		Str("request_id", requestID).
+		Str("class", class.String()).
+		Msg("priority: request not admitted")
+	g.recordError(monitoring.ErrorCodePriorityShed)
+	w.Header().Set("Retry-After", "1")
+	g.writeError(w, fmt.Sprintf("%s request rejected: %v", class, err), http.StatusTooManyRequests)
+	return nil, false
+}
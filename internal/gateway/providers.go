@@ -2,8 +2,11 @@
 package gateway
 
 import (
+	"net/url"
 	"os"
 	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
 )
 
 // ProviderConfig defines URL and detection rules for a provider.
@@ -114,3 +117,31 @@ func getProviderBaseURL(providerName string) string {
 		return Providers[providerName].BaseURL
 	}
 }
+
+// isConfiguredUpstreamHost reports whether host (host[:port], as in a URL) is
+// an upstream the operator configured rather than one a client named: the
+// LiteLLM proxy or the Ollama endpoint. Self-hosted models usually run on
+// loopback, so these pass the SSRF allowlist without GATEWAY_ALLOW_LOCAL.
+func (g *Gateway) isConfiguredUpstreamHost(host string) bool {
+	for _, base := range []string{g.liteLLMURL(), getProviderBaseURL("ollama")} {
+		if u, err := url.Parse(base); err == nil && base != "" && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalModel reports whether a request was served by a self-hosted model:
+// the Ollama adapter, or an OpenAI-compatible request to the Ollama endpoint.
+// These cost nothing; their token usage is still recorded.
+func isLocalModel(provider, upstreamURL string) bool {
+	if provider == string(adapters.ProviderOllama) {
+		return true
+	}
+	u, err := url.Parse(upstreamURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	ollama, err := url.Parse(getProviderBaseURL("ollama"))
+	return err == nil && strings.EqualFold(ollama.Host, u.Host)
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func newTestGateway(t *testing.T, mutate func(*config.Config)) *Gateway {
+	t.Helper()
+	cfg := &config.Config{
+		Server:     config.ServerConfig{Port: 18080, ReadTimeout: 30 * time.Second, WriteTimeout: 60 * time.Second},
+		Store:      config.StoreConfig{Type: "memory", TTL: time.Hour},
+		Monitoring: config.MonitoringConfig{LogLevel: "disabled", LogOutput: "discard"},
+	}
+	if mutate != nil {
+		mutate(cfg)
+	}
+	return New(cfg)
+}
+
+func TestIsAllowedHost_ConfiguredLocalUpstreams(t *testing.T) {
+	t.Setenv("OLLAMA_PROVIDER_URL", "")
+	g := newTestGateway(t, func(cfg *config.Config) {
+		cfg.LiteLLM = config.LiteLLMConfig{Enabled: true, URL: "http://127.0.0.1:4000"}
+	})
+
+	assert.True(t, g.isAllowedHost("localhost:11434"), "the Ollama endpoint")
+	assert.True(t, g.isAllowedHost("127.0.0.1:4000"), "the LiteLLM proxy")
+	assert.False(t, g.isAllowedHost("127.0.0.1:8080"), "other loopback ports stay blocked")
+	assert.False(t, g.isAllowedHost("169.254.169.254"))
+}
+
+func TestIsAllowedHost_ReloadedAllowedHosts(t *testing.T) {
+	g := newTestGateway(t, nil)
+	assert.False(t, g.isAllowedHost("models.internal"))
+	assert.False(t, g.isAllowedHost("10.20.3.4:8000"))
+
+	hosts := []string{"Models.Internal", "10.20.0.0/16", "127.0.0.1", "169.254.0.0/16"}
+	_, err := g.configReloader.UpdateSession(config.ConfigPatch{Server: &config.ServerPatch{AllowedHosts: &hosts}})
+	require.NoError(t, err)
+
+	assert.True(t, g.isAllowedHost("models.internal:443"), "hostnames match case-insensitively")
+	assert.True(t, g.isAllowedHost("10.20.3.4:8000"), "CIDR ranges match IPs")
+	assert.False(t, g.isAllowedHost("10.21.0.1"))
+	assert.True(t, g.isAllowedHost("127.0.0.1:9000"), "listed loopback IPs are allowed")
+	assert.False(t, g.isAllowedHost("169.254.169.254"), "metadata endpoints stay blocked")
+}
+
+func TestIsLocalModel(t *testing.T) {
+	t.Setenv("OLLAMA_PROVIDER_URL", "http://gpu-box:11434")
+
+	assert.True(t, isLocalModel("ollama", "http://localhost:11434/api/chat"))
+	assert.True(t, isLocalModel("openai", "http://gpu-box:11434/v1/chat/completions"), "OpenAI-compatible Ollama endpoint")
+	assert.False(t, isLocalModel("openai", "http://127.0.0.1:8080/v1/chat/completions"))
+	assert.False(t, isLocalModel("openai", "https://api.openai.com/v1/chat/completions"))
+	assert.False(t, isLocalModel("openai", ""))
+}
@@ -0,0 +1,269 @@
+// readiness.go - GET /health/ready: "gateway usable", not just "gateway up".
+//
+// /health only checks the local store. /health/ready adds the summarizer's
+// credentials and, when enabled in readiness config, reachability probes of
+// the upstream providers and the Compresr API, with per-dependency status and
+// latency. Network probe results are cached for readiness.cache_ttl; probes
+// run detached from the request, so a client that hangs up neither poisons
+// the cache nor holds up other readiness calls, which share the running probe.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/circuitbreaker"
+	"github.com/compresr/context-gateway/internal/compresr"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// Dependency and overall readiness statuses.
+const (
+	readinessOK          = "ok"
+	readinessDegraded    = "degraded"    // Usable with reduced function (no compression or summarization)
+	readinessDown        = "down"        // Dependency check failed
+	readinessUnavailable = "unavailable" // Overall: the store or every probed upstream is down (503)
+	readinessSkipped     = "skipped"     // Not configured or disabled
+)
+
+// readinessCheck is the result for one dependency.
+type readinessCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// readinessResponse is the body of GET /health/ready.
+type readinessResponse struct {
+	Status    string                    `json:"status"` // ok | degraded | unavailable (503)
+	Time      string                    `json:"time"`
+	Version   string                    `json:"version"`
+	Checks    map[string]readinessCheck `json:"checks"`              // store, summarizer, compresr
+	Upstreams map[string]readinessCheck `json:"upstreams,omitempty"` // Per upstream host
+}
+
+// readinessCache holds the last network probe results.
+type readinessCache struct {
+	mu        sync.Mutex
+	at        time.Time
+	probing   chan struct{} // Closed when the running probe finishes; nil when none runs
+	compresr  readinessCheck
+	upstreams map[string]readinessCheck
+}
+
+// handleHealthReady reports per-dependency readiness. 503 when the store or
+// every probed upstream is down; a partial upstream outage, compresr and
+// summarizer problems only degrade.
+func (g *Gateway) handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	cfg := g.cfg()
+	resp := readinessResponse{
+		Status:  readinessOK,
+		Time:    time.Now().Format(time.RFC3339),
+		Version: g.version,
+		Checks: map[string]readinessCheck{
+			"store":      g.checkStore(),
+			"summarizer": g.checkSummarizer(),
+		},
+	}
+	compresrCheck, upstreams := g.probeDependencies(r.Context(), cfg)
+	resp.Checks["compresr"] = compresrCheck
+	resp.Upstreams = upstreams
+
+	for name, c := range resp.Checks {
+		if c.Status == readinessOK || c.Status == readinessSkipped {
+			continue
+		}
+		if name == "store" {
+			resp.Status = readinessUnavailable
+		} else if resp.Status == readinessOK {
+			resp.Status = readinessDegraded
+		}
+	}
+	down := 0
+	for _, c := range upstreams {
+		if c.Status == readinessDown {
+			down++
+		}
+	}
+	switch {
+	case down > 0 && down == len(upstreams):
+		resp.Status = readinessUnavailable
+	case down > 0 && resp.Status == readinessOK:
+		resp.Status = readinessDegraded // Requests to the other upstreams still work
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status == readinessUnavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleHealthReady: failed to encode JSON response")
+	}
+}
+
+// checkStore writes and deletes a key in the shadow store, as /health does.
+func (g *Gateway) checkStore() readinessCheck {
+	start := time.Now()
+	err := g.store.Set("_ready_", "ok")
+	if err == nil {
+		_ = g.store.Delete("_ready_")
+	}
+	c := readinessCheck{Status: readinessOK, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status, c.Detail = readinessDown, err.Error()
+	}
+	return c
+}
+
+// checkSummarizer reports whether preemptive summarization has credentials.
+// No network call: a missing key is a configuration problem.
+func (g *Gateway) checkSummarizer() readinessCheck {
+	if g.preemptive == nil {
+		return readinessCheck{Status: readinessSkipped, Detail: "preemptive summarization disabled"}
+	}
+	source, enabled := g.preemptive.SummarizerAuth()
+	switch {
+	case !enabled:
+		return readinessCheck{Status: readinessSkipped, Detail: "preemptive summarization disabled"}
+	case source == "":
+		return readinessCheck{Status: readinessDegraded, Detail: "no api_key configured and no auth captured from client requests yet"}
+	case source == preemptive.AuthSourceCaptured:
+		return readinessCheck{Status: readinessOK, Detail: "using auth captured from client requests"}
+	default:
+		return readinessCheck{Status: readinessOK, Detail: source}
+	}
+}
+
+// probeDependencies returns the compresr and upstream checks, probing the
+// network at most once per readiness.cache_ttl. Concurrent callers share one
+// probe, which runs on a context detached from ctx: if ctx ends first, the
+// caller gets the previous results and the probe still fills the cache.
+func (g *Gateway) probeDependencies(ctx context.Context, cfg *config.Config) (readinessCheck, map[string]readinessCheck) {
+	rc := cfg.Readiness
+	if !rc.ProbeCompresr && !rc.ProbeUpstreams {
+		return readinessCheck{Status: readinessSkipped, Detail: "readiness.probe_compresr is off"}, nil
+	}
+	timeout := rc.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultReadinessTimeout
+	}
+	ttl := rc.CacheTTL
+	if ttl <= 0 {
+		ttl = config.DefaultReadinessCacheTTL
+	}
+
+	c := &g.readiness
+	c.mu.Lock()
+	if !c.at.IsZero() && time.Since(c.at) < ttl {
+		defer c.mu.Unlock()
+		return c.compresr, c.upstreams
+	}
+	done := c.probing
+	if done == nil {
+		done = make(chan struct{})
+		c.probing = done
+		probeCtx := context.WithoutCancel(ctx)
+		go func() {
+			compresrCheck, upstreams := g.runProbes(probeCtx, cfg, timeout)
+			c.mu.Lock()
+			c.at = time.Now()
+			c.compresr, c.upstreams = compresrCheck, upstreams
+			c.probing = nil
+			c.mu.Unlock()
+			close(done)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compresr, c.upstreams
+}
+
+// runProbes probes the upstreams and the Compresr API in parallel, each
+// bounded by timeout.
+func (g *Gateway) runProbes(ctx context.Context, cfg *config.Config, timeout time.Duration) (readinessCheck, map[string]readinessCheck) {
+	rc := cfg.Readiness
+	targets := make(map[string]string) // host -> probe URL
+	if rc.ProbeUpstreams {
+		urls := rc.Upstreams
+		if len(urls) == 0 {
+			for name, p := range cfg.Providers {
+				urls = append(urls, p.GetEndpoint(name))
+			}
+		}
+		for _, raw := range urls {
+			if u, err := url.Parse(raw); err == nil && u.Host != "" {
+				targets[u.Host] = u.Scheme + "://" + u.Host + "/"
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	upstreams := make(map[string]readinessCheck, len(targets))
+	for host, probeURL := range targets {
+		if cb := g.upstreamCircuit(host); cb != nil && cb.State() == circuitbreaker.StateOpen {
+			upstreams[host] = readinessCheck{Status: readinessDown, Detail: "circuit open"}
+			continue
+		}
+		wg.Add(1)
+		go func(host, probeURL string) {
+			defer wg.Done()
+			check := g.probeURL(ctx, probeURL, timeout)
+			mu.Lock()
+			upstreams[host] = check
+			mu.Unlock()
+		}(host, probeURL)
+	}
+
+	compresrCheck := readinessCheck{Status: readinessSkipped, Detail: "readiness.probe_compresr is off"}
+	if rc.ProbeCompresr {
+		base := cfg.URLs.Compresr
+		if base == "" {
+			base = compresr.DefaultCompresrAPIBaseURL
+		}
+		compresrCheck = g.probeURL(ctx, base, timeout)
+		if compresrCheck.Status == readinessDown {
+			compresrCheck.Status = readinessDegraded // Pipes fall back to passthrough
+		}
+	}
+	wg.Wait()
+	return compresrCheck, upstreams
+}
+
+// probeURL sends a HEAD request. Any HTTP answer below 500 counts as
+// reachable: the probe carries no credentials, so 401/404 are expected.
+func (g *Gateway) probeURL(ctx context.Context, rawURL string, timeout time.Duration) readinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return readinessCheck{Status: readinessDown, Detail: err.Error()}
+	}
+	start := time.Now()
+	// #nosec G704 -- probe URLs come from readiness config and configured providers
+	resp, err := g.httpClient.Do(req)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		return readinessCheck{Status: readinessDown, LatencyMs: latency, Detail: err.Error()}
+	}
+	_ = resp.Body.Close()
+	check := readinessCheck{Status: readinessOK, LatencyMs: latency, Detail: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+	if resp.StatusCode >= 500 {
+		check.Status = readinessDown
+	}
+	return check
+}
@@ -0,0 +1,419 @@
+// realtime.go - WebSocket proxying for the OpenAI Realtime API.
+//
+// A WebSocket upgrade to a path ending in /realtime (e.g. /v1/realtime?model=...)
+// is relayed to the upstream Realtime endpoint frame by frame; no pipe runs on
+// it. Each connection is its own cost session: the budget is checked before
+// the upgrade and before every client event, and every response.done event is
+// recorded as one request with its usage (telemetry, /stats, cost tracking).
+// Audio tokens are billed at the model's text rates. When a budget cap is hit
+// mid-session both sides are closed with 1008 (policy violation).
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// realtimeForwardHeaders are the client headers sent on the upstream handshake.
+var realtimeForwardHeaders = []string{
+	"Authorization", "api-key", "OpenAI-Beta", "OpenAI-Organization", "OpenAI-Project", "User-Agent",
+}
+
+// realtimeCloseError ends a relay with a close code sent to both sides.
+type realtimeCloseError struct {
+	code   websocket.StatusCode
+	reason string
+}
+
+func (e *realtimeCloseError) Error() string { return e.reason }
+
+// realtimeSession is one proxied Realtime connection.
+type realtimeSession struct {
+	g         *Gateway
+	id        string // Cost session ID
+	requestID string
+	path      string
+	target    string
+	clientIP  string
+	header    http.Header
+	adapter   adapters.Adapter
+	pipeCtx   *PipelineContext
+	inflight  *inflightRequest
+	client    *websocket.Conn
+	upstream  *websocket.Conn
+
+	// Touched by the upstream relay only.
+	model     string
+	responses map[string]time.Time // response.created time by response ID
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// handleRealtime proxies a Realtime WebSocket session.
+func (g *Gateway) handleRealtime(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	requestID := g.getRequestID(r)
+
+	if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/realtime") {
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
+		g.writeError(w, "websocket upgrade is only supported for the realtime API", http.StatusBadRequest)
+		return
+	}
+	// Accept skips its own origin check below; browsers must be allowed by CORS.
+	if origin := r.Header.Get("Origin"); origin != "" && !g.isAllowedOrigin(origin) {
+		g.recordError(monitoring.ErrorCodeInvalidRequest)
+		g.writeError(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	g.EnsureSession()
+
+	adapter := g.registry.Get(adapters.ProviderOpenAI.String())
+	s := &realtimeSession{
+		g:         g,
+		id:        fmt.Sprintf("realtime-%s", uuid.New().String()[:8]),
+		requestID: requestID,
+		path:      r.URL.Path,
+		clientIP:  r.RemoteAddr,
+		header:    r.Header,
+		adapter:   adapter,
+		pipeCtx:   NewPipelineContext(adapters.ProviderOpenAI, adapter, nil, r.URL.Path),
+		model:     r.URL.Query().Get("model"),
+		responses: make(map[string]time.Time),
+	}
+	s.pipeCtx.CostSessionID = s.id
+	s.pipeCtx.RequestID = requestID
+	s.pipeCtx.Tenant = tenancy.NameOf(tenancy.FromContext(r.Context()))
+	s.pipeCtx.Client = clientLabelFrom(r.Context())
+	if g.costTracker != nil {
+		s.pipeCtx.BudgetScopes = g.costTracker.ScopeKeys(r.Header, parseSessionTags(r.Header), s.pipeCtx.Tenant, s.pipeCtx.Client)
+	}
+
+	budget, ok := s.checkBudget()
+	if !ok {
+		g.recordError(monitoring.ErrorCodeBudgetExceeded)
+		w.Header().Set("X-Budget-Reason", budget.Reason)
+		g.writeError(w, "budget exceeded", http.StatusTooManyRequests)
+		return
+	}
+	if budget.Simulated {
+		w.Header().Set(HeaderBudgetSimulated, budget.Reason)
+	}
+
+	upstream, resp, err := s.dial(r)
+	if err != nil {
+		errorCode, retryable := ClassifyUpstreamError(err, monitoring.ErrorCodeUpstreamUnavailable)
+		status := http.StatusBadGateway
+		if resp != nil && resp.StatusCode >= 400 {
+			status, errorCode, retryable = resp.StatusCode, "", false
+		}
+		g.recordRequestTelemetry(telemetryParams{
+			requestID: requestID, startTime: startTime, method: r.Method, path: r.URL.Path, clientIP: r.RemoteAddr,
+			provider: adapter.Name(), pipeType: PipeNone, pipeStrategy: "realtime", statusCode: status,
+			errorMsg: err.Error(), errorCode: errorCode, retryable: retryable, forwardLatency: time.Since(startTime),
+			pipeCtx: s.pipeCtx, adapter: adapter, model: s.model, requestHeaders: r.Header, upstreamURL: s.target,
+		})
+		log.Error().Err(err).Str("request_id", requestID).Str("targetURL", s.target).Msg("realtime: upstream handshake failed")
+		if status != http.StatusBadGateway {
+			// Relay the upstream rejection (bad key, unknown model) as-is.
+			body, _ := io.ReadAll(resp.Body)
+			w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+			return
+		}
+		g.writeError(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer upstream.CloseNow()
+	s.upstream = upstream
+
+	// A session outlives the server's read/write timeouts; the hijacked
+	// connection keeps whatever deadlines were set for the handshake.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	var subprotocols []string
+	if p := upstream.Subprotocol(); p != "" {
+		subprotocols = []string{p}
+	}
+	client, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:       subprotocols,
+		InsecureSkipVerify: true, // Origin checked above
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("request_id", requestID).Msg("realtime: client handshake failed")
+		upstream.Close(websocket.StatusGoingAway, "client handshake failed")
+		return
+	}
+	defer client.CloseNow()
+	s.client = client
+	client.SetReadLimit(MaxRequestBodySize)
+	upstream.SetReadLimit(MaxResponseSize)
+
+	inflight, ctx, done := g.inflight.register(r.Context(), requestID, r.URL.Path, adapter.Name(), true)
+	defer done()
+	inflight.setSession(s.id, s.model)
+	inflight.setStage(StageStreaming)
+	s.inflight = inflight
+
+	log.Info().
+		Str("request_id", requestID).
+		Str("session_id", s.id).
+		Str("model", s.model).
+		Str("targetURL", s.target).
+		Msg("realtime: session started")
+	err = s.relay(ctx)
+	log.Info().
+		Err(err).
+		Str("request_id", requestID).
+		Str("session_id", s.id).
+		Dur("duration", time.Since(startTime)).
+		Msg("realtime: session ended")
+}
+
+// dial opens the upstream connection. resp is the upstream's handshake
+// response when it rejected the upgrade.
+func (s *realtimeSession) dial(r *http.Request) (conn *websocket.Conn, resp *http.Response, err error) {
+	audit := &forwardAudit{sessionID: s.id, provider: "openai", targetSource: auditTargetHeader}
+	defer func() {
+		s.g.logForwardAudit(r.Context(), r, audit, forwardAuthMeta{InitialMode: "unknown", EffectiveMode: "unknown"}, resp, err)
+	}()
+
+	s.target = r.Header.Get(HeaderTargetURL)
+	audit.targetURLHeader = s.target
+	if s.target != "" {
+		if !strings.HasSuffix(s.target, r.URL.Path) {
+			s.target = strings.TrimSuffix(s.target, "/") + r.URL.Path
+		}
+	} else {
+		s.target = getProviderBaseURL("openai") + normalizeOpenAIPath(r.URL.Path)
+		audit.targetSource = auditTargetAutoDetect
+	}
+	audit.targetURL = s.target
+	if r.URL.RawQuery != "" {
+		s.target += "?" + r.URL.RawQuery
+	}
+
+	u, err := url.Parse(s.target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+	audit.host = u.Host
+	audit.hostAllowed = s.g.isAllowedHost(u.Host)
+	if !audit.hostAllowed {
+		return nil, nil, fmt.Errorf("%w: %s", errHostNotAllowed, u.Host)
+	}
+
+	header := http.Header{}
+	for _, h := range realtimeForwardHeaders {
+		if v := r.Header.Get(h); v != "" {
+			header.Set(h, v)
+		}
+	}
+	// Key pinning inspects an ordinary request for the same host and credentials.
+	pinReq := &http.Request{URL: u, Header: header}
+	if err := s.g.enforceKeyPins(pinReq); err != nil {
+		return nil, nil, err
+	}
+	audit.upstreamHeader = header
+	var subprotocols []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				subprotocols = append(subprotocols, p)
+			}
+		}
+	}
+	// #nosec G704 -- target is the configured provider URL or an allowed X-Target-URL host
+	return websocket.Dial(r.Context(), s.target, &websocket.DialOptions{
+		HTTPClient:   s.g.httpClient,
+		HTTPHeader:   header,
+		Subprotocols: subprotocols,
+	})
+}
+
+// relay pumps frames both ways until either side closes or parent is
+// cancelled, then closes both sides with the same status.
+func (s *realtimeSession) relay(parent context.Context) error {
+	// Pumps don't watch parent: cancelling a pending read drops the
+	// connection without a close frame.
+	ctx := context.WithoutCancel(parent)
+	errc := make(chan error, 2)
+	go func() { errc <- s.pumpClient(ctx) }()
+	go func() { errc <- s.pumpUpstream(ctx) }()
+
+	running := 2
+	var err error
+	select {
+	case err = <-errc:
+		running--
+	case <-parent.Done():
+		err = context.Cause(parent)
+	}
+
+	// A peer that vanished without a close frame, or an admin cancel, ends
+	// the other side with 1001 (going away).
+	code, reason := websocket.StatusGoingAway, ""
+	var closeErr *realtimeCloseError
+	var ce websocket.CloseError
+	switch {
+	case errors.As(err, &closeErr):
+		code, reason = closeErr.code, closeErr.reason
+	case errors.As(err, &ce) && ce.Code != websocket.StatusNoStatusRcvd && ce.Code != websocket.StatusAbnormalClosure:
+		code, reason = ce.Code, ce.Reason
+	case errors.As(err, &ce):
+		code = websocket.StatusNormalClosure // Reserved codes can't be sent
+	case parent.Err() != nil:
+		reason = "session cancelled"
+	}
+	_ = s.client.Close(code, reason)
+	_ = s.upstream.Close(code, reason)
+	for ; running > 0; running-- {
+		<-errc
+	}
+	if code == websocket.StatusNormalClosure {
+		return nil
+	}
+	return err
+}
+
+// pumpClient forwards client events upstream, enforcing the session budget.
+func (s *realtimeSession) pumpClient(ctx context.Context) error {
+	for {
+		typ, data, err := s.client.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if budget, ok := s.checkBudget(); !ok {
+			s.g.recordError(monitoring.ErrorCodeBudgetExceeded)
+			return &realtimeCloseError{code: websocket.StatusPolicyViolation, reason: "budget exceeded: " + budget.Reason}
+		}
+		if s.g.costTracker != nil {
+			s.g.costTracker.RecordEgress(s.id, len(data))
+		}
+		if err := s.upstream.Write(ctx, typ, data); err != nil {
+			return err
+		}
+	}
+}
+
+// pumpUpstream forwards server events to the client, recording usage.
+func (s *realtimeSession) pumpUpstream(ctx context.Context) error {
+	for {
+		typ, data, err := s.upstream.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if typ == websocket.MessageText {
+			s.observe(data)
+		}
+		if err := s.client.Write(ctx, typ, data); err != nil {
+			return err
+		}
+	}
+}
+
+// checkBudget checks the session's budget and fires budget notifications.
+// Simulated rejections are recorded and allowed.
+func (s *realtimeSession) checkBudget() (budget costcontrol.BudgetCheckResult, ok bool) {
+	if s.g.costTracker == nil {
+		return costcontrol.BudgetCheckResult{Allowed: true}, true
+	}
+	budget = s.g.costTracker.CheckBudget(s.id, s.pipeCtx.BudgetScopes...)
+	if budget.Simulated {
+		s.g.costTracker.RecordSimulatedRejection(s.id, budget)
+		log.Warn().
+			Str("request_id", s.requestID).
+			Str("session_id", s.id).
+			Str("reason", budget.Reason).
+			Msg("budget: realtime event would have been rejected (simulate mode)")
+	}
+	s.g.notifyBudget(s.id, budget)
+	s.g.notifyBudgetExceeded(s.id, budget)
+	return budget, budget.Allowed
+}
+
+// observe tracks the session model and records usage from server events.
+func (s *realtimeSession) observe(data []byte) {
+	switch gjson.GetBytes(data, "type").String() {
+	case "session.created", "session.updated":
+		if model := gjson.GetBytes(data, "session.model").String(); model != "" && model != s.model {
+			s.model = model
+			s.inflight.setSession(s.id, model)
+		}
+	case "response.created":
+		if id := gjson.GetBytes(data, "response.id").String(); id != "" {
+			s.responses[id] = time.Now()
+		}
+	case "response.done":
+		s.recordResponse(data)
+	}
+}
+
+// recordResponse records a finished response as one request.
+func (s *realtimeSession) recordResponse(data []byte) {
+	resp := gjson.GetBytes(data, "response")
+	requestID := resp.Get("id").String()
+	start, ok := s.responses[requestID]
+	delete(s.responses, requestID)
+	if !ok {
+		start = time.Now()
+	}
+	if requestID == "" {
+		requestID = s.requestID
+	}
+
+	usage := realtimeUsage(resp.Get("usage"))
+	params := telemetryParams{
+		requestID: requestID, startTime: start, method: http.MethodGet, path: s.path, clientIP: s.clientIP,
+		responseBodySize: len(data), provider: s.adapter.Name(), pipeType: PipeNone, pipeStrategy: "realtime",
+		statusCode: http.StatusOK, forwardLatency: time.Since(start), pipeCtx: s.pipeCtx, adapter: s.adapter,
+		model: s.model, streamUsage: &usage, streamStopReason: resp.Get("status").String(),
+		requestHeaders: s.header, upstreamURL: s.target,
+	}
+	if params.streamStopReason == "failed" {
+		params.statusCode = http.StatusBadGateway
+		params.errorMsg = resp.Get("status_details.error.message").String()
+		if params.errorMsg == "" {
+			params.errorMsg = "realtime response failed"
+		}
+	}
+	s.g.recordRequestTelemetry(params)
+}
+
+// realtimeUsage converts a Realtime response.done usage object. Input counts
+// exclude cached tokens, as for the other OpenAI APIs.
+func realtimeUsage(u gjson.Result) adapters.UsageInfo {
+	cached := int(u.Get("input_token_details.cached_tokens").Int())
+	input := int(u.Get("input_tokens").Int()) - cached
+	if input < 0 {
+		input = 0
+	}
+	return adapters.UsageInfo{
+		InputTokens:          input,
+		OutputTokens:         int(u.Get("output_tokens").Int()),
+		TotalTokens:          int(u.Get("total_tokens").Int()),
+		CacheReadInputTokens: cached,
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"net/http"
 	"slices"
 	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
 )
 
 // normalizeOpenAIPath ensures paths are in /v1/... format for OpenAI API.
 // Handles cases where clients send /responses instead of /v1/responses.
 func normalizeOpenAIPath(path string) string {
 	// Paths that need /v1 prefix if missing
-	needsV1Prefix := []string{"/responses", "/chat/completions", "/completions", "/embeddings", "/models"}
+	needsV1Prefix := []string{"/responses", "/chat/completions", "/completions", "/embeddings", "/models", "/realtime"}
 	if slices.Contains(needsV1Prefix, path) {
 		return "/v1" + path
 	}
@@ -45,6 +47,22 @@ func isChatGPTSubscription(r *http.Request) bool {
 	return !strings.HasPrefix(token, "sk-")
 }
 
+// requestModel returns the model a request is for. Gemini and Bedrock name it
+// in the URL path (/v1beta/models/{model}:generateContent,
+// /model/{modelId}/invoke) rather than the body.
+func requestModel(adapter adapters.Adapter, body []byte, path string) string {
+	if model := adapter.ExtractModel(body); model != "" {
+		return model
+	}
+	switch adapter.Provider() {
+	case adapters.ProviderGemini:
+		return adapters.ExtractGeminiModelFromPath(path)
+	case adapters.ProviderBedrock:
+		return adapters.ExtractModelFromPath(path)
+	}
+	return ""
+}
+
 // autoDetectTargetURL determines the upstream URL based on request characteristics.
 func (g *Gateway) autoDetectTargetURL(r *http.Request) string {
 	path := r.URL.Path
@@ -54,6 +72,11 @@ func (g *Gateway) autoDetectTargetURL(r *http.Request) string {
 		return g.bedrockSigner.BuildTargetURL(path)
 	}
 
+	// LiteLLM mode: the proxy routes every other request by its model name
+	if base := g.liteLLMURL(); base != "" {
+		return base + path
+	}
+
 	// 1. Anthropic: anthropic-version header is definitive
 	if r.Header.Get("anthropic-version") != "" {
 		return getProviderBaseURL("anthropic") + path
@@ -76,6 +99,12 @@ func (g *Gateway) autoDetectTargetURL(r *http.Request) string {
 		return "https://us-central1-aiplatform.googleapis.com" + path
 	}
 
+	// Gemini native path: an OAuth Bearer token on it would otherwise be
+	// taken for a ChatGPT subscription below.
+	if adapters.IsGeminiPath(path) {
+		return getProviderBaseURL("gemini") + path
+	}
+
 	// 5. Check Authorization header - distinguish providers by API key prefix
 	if auth := r.Header.Get("Authorization"); auth != "" {
 		// Anthropic: Bearer sk-ant-xxx
@@ -131,6 +160,7 @@ func (g *Gateway) isNonLLMEndpoint(path string) bool {
 		"/api/event_logging",
 		"/api/telemetry",
 		"/api/analytics",
+		"/v1/messages/count_tokens", // Token counting: no completion, nothing to compress
 	}
 	for _, prefix := range nonLLMPaths {
 		if strings.HasPrefix(path, prefix) {
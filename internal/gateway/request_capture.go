@@ -0,0 +1,450 @@
+// request_capture.go - Byte-exact capture of forwarded requests for debugging.
+//
+// When monitoring.request_capture is enabled, every proxied request is kept in
+// memory at three points: the body as received from the client, the body the
+// compression pipes started from (after inbound rewrites and preemptive
+// summarization), and every body sent upstream. Users who report that the
+// gateway "changed my request" can then diff exactly what was forwarded:
+//
+//	GET  /debug/requests                       recent captures, newest first
+//	GET  /debug/requests/{id}                  sizes, hashes, first differing byte
+//	GET  /debug/requests/{id}/original         body as received
+//	GET  /debug/requests/{id}/forwarded        body sent upstream (?attempt=N, default last)
+//	POST /debug/requests/{id}/replay           re-run the pipes on the recorded input
+//
+// Replay is for captures whose forwarded body is gone or in question: it runs
+// the compression pipes, cache guard and phantom tool injection on the
+// recorded pipeline input and reports whether the result matches the capture.
+// All endpoints are loopback-only. Capture is bounded by request count and
+// total bytes; the oldest requests are dropped first.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/phantom_tools"
+)
+
+// HeaderReplayMatches reports whether a replayed body equals the captured
+// first forward: "true", "false", or "unknown" when nothing was forwarded.
+const HeaderReplayMatches = "X-Replay-Matches-Capture"
+
+// capturedRequest is one request's recorded bodies (guarded by requestCapture.mu).
+type capturedRequest struct {
+	id         string
+	method     string
+	path       string
+	adapter    string
+	receivedAt time.Time
+
+	sessionID     string
+	toolSessionID string
+	flags         featureflags.Decisions
+
+	original      []byte
+	pipelineInput []byte // nil when identical to original
+	forwards      []capturedForward
+}
+
+// capturedForward is one body sent upstream. Phantom tool loops and retries
+// forward more than once per request.
+type capturedForward struct {
+	targetURL string
+	at        time.Time
+	body      []byte
+}
+
+func (c *capturedRequest) size() int64 {
+	n := int64(len(c.original) + len(c.pipelineInput))
+	for _, f := range c.forwards {
+		n += int64(len(f.body))
+	}
+	return n
+}
+
+// input returns the body the compression pipes started from.
+func (c *capturedRequest) input() []byte {
+	if c.pipelineInput != nil {
+		return c.pipelineInput
+	}
+	return c.original
+}
+
+// CapturedRequest is the /debug/requests view of a capture.
+type CapturedRequest struct {
+	ID             string            `json:"id"`
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Provider       string            `json:"provider"`
+	SessionID      string            `json:"session_id,omitempty"`
+	ReceivedAt     time.Time         `json:"received_at"`
+	OriginalBytes  int               `json:"original_bytes"`
+	OriginalSHA256 string            `json:"original_sha256"`
+	InputRewritten bool              `json:"input_rewritten"` // Pipes started from a rewritten body (tool names, compaction)
+	Forwards       []CapturedForward `json:"forwards"`
+}
+
+// CapturedForward describes one upstream attempt relative to the original body.
+type CapturedForward struct {
+	Attempt         int       `json:"attempt"` // 1-based
+	TargetURL       string    `json:"target_url"`
+	At              time.Time `json:"at"`
+	Bytes           int       `json:"bytes"`
+	SHA256          string    `json:"sha256"`
+	Identical       bool      `json:"identical"`         // Byte-equal to the original
+	FirstDiffOffset int       `json:"first_diff_offset"` // -1 when identical
+}
+
+// requestCapture keeps the most recent requests, bounded by count and bytes.
+// A nil *requestCapture records nothing.
+type requestCapture struct {
+	maxRequests int
+	maxBytes    int64
+
+	mu    sync.Mutex
+	byID  map[string]*capturedRequest
+	order []string // oldest first
+	bytes int64
+}
+
+func newRequestCapture(maxRequests int, maxBytes int64) *requestCapture {
+	if maxRequests <= 0 {
+		maxRequests = config.DefaultRequestCaptureRequests
+	}
+	if maxBytes <= 0 {
+		maxBytes = config.DefaultRequestCaptureBytes
+	}
+	return &requestCapture{
+		maxRequests: maxRequests,
+		maxBytes:    maxBytes,
+		byID:        make(map[string]*capturedRequest),
+	}
+}
+
+type capturedRequestKey struct{}
+
+// withCapturedRequest attaches c so forwardPassthrough can record what it sends.
+func withCapturedRequest(ctx context.Context, c *capturedRequest) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, capturedRequestKey{}, c)
+}
+
+func capturedRequestFrom(ctx context.Context) *capturedRequest {
+	c, _ := ctx.Value(capturedRequestKey{}).(*capturedRequest)
+	return c
+}
+
+// start records a request as received. A reused request ID replaces the older capture.
+func (rc *requestCapture) start(id, method, path, adapter string, body []byte) *capturedRequest {
+	if rc == nil || id == "" {
+		return nil
+	}
+	c := &capturedRequest{
+		id:         id,
+		method:     method,
+		path:       path,
+		adapter:    adapter,
+		receivedAt: time.Now(),
+		original:   bytes.Clone(body),
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.removeLocked(id)
+	rc.byID[id] = c
+	rc.order = append(rc.order, id)
+	rc.bytes += c.size()
+	rc.evictLocked()
+	return c
+}
+
+// setPipelineInput records the body handed to the compression pipes and the
+// sessions it ran under, for replay.
+func (rc *requestCapture) setPipelineInput(c *capturedRequest, body []byte, pipeCtx *PipelineContext) {
+	if rc == nil || c == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	c.sessionID = pipeCtx.CostSessionID
+	c.toolSessionID = pipeCtx.ToolSessionID
+	c.flags = pipeCtx.Flags
+	if bytes.Equal(body, c.original) {
+		return
+	}
+	c.pipelineInput = bytes.Clone(body)
+	if rc.byID[c.id] == c {
+		rc.bytes += int64(len(c.pipelineInput))
+		rc.evictLocked()
+	}
+}
+
+// addForward records a body sent upstream.
+func (rc *requestCapture) addForward(c *capturedRequest, targetURL string, body []byte) {
+	if rc == nil || c == nil {
+		return
+	}
+	f := capturedForward{targetURL: targetURL, at: time.Now(), body: bytes.Clone(body)}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	c.forwards = append(c.forwards, f)
+	if rc.byID[c.id] == c {
+		rc.bytes += int64(len(f.body))
+		rc.evictLocked()
+	}
+}
+
+// get returns a snapshot of the capture for id. Recorded bodies are never
+// modified, so the snapshot shares them.
+func (rc *requestCapture) get(id string) (capturedRequest, bool) {
+	if rc == nil {
+		return capturedRequest{}, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	c, ok := rc.byID[id]
+	if !ok {
+		return capturedRequest{}, false
+	}
+	snap := *c
+	snap.forwards = append([]capturedForward(nil), c.forwards...)
+	return snap, true
+}
+
+// list returns all captures, newest first.
+func (rc *requestCapture) list() []CapturedRequest {
+	if rc == nil {
+		return []CapturedRequest{}
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	out := make([]CapturedRequest, 0, len(rc.order))
+	for i := len(rc.order) - 1; i >= 0; i-- {
+		out = append(out, rc.byID[rc.order[i]].view())
+	}
+	return out
+}
+
+func (rc *requestCapture) removeLocked(id string) {
+	old, ok := rc.byID[id]
+	if !ok {
+		return
+	}
+	delete(rc.byID, id)
+	rc.bytes -= old.size()
+	for i, oid := range rc.order {
+		if oid == id {
+			rc.order = append(rc.order[:i], rc.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// evictLocked drops the oldest captures until both bounds hold. The newest
+// capture is always kept, even if it alone exceeds maxBytes.
+func (rc *requestCapture) evictLocked() {
+	for len(rc.order) > 1 && (len(rc.order) > rc.maxRequests || rc.bytes > rc.maxBytes) {
+		rc.removeLocked(rc.order[0])
+	}
+}
+
+// view builds the API view. Call on a snapshot or with requestCapture.mu held.
+func (c *capturedRequest) view() CapturedRequest {
+	v := CapturedRequest{
+		ID:             c.id,
+		Method:         c.method,
+		Path:           c.path,
+		Provider:       c.adapter,
+		SessionID:      c.sessionID,
+		ReceivedAt:     c.receivedAt,
+		OriginalBytes:  len(c.original),
+		OriginalSHA256: sha256Hex(c.original),
+		InputRewritten: c.pipelineInput != nil,
+		Forwards:       make([]CapturedForward, 0, len(c.forwards)),
+	}
+	for i, f := range c.forwards {
+		diff := firstDiff(c.original, f.body)
+		v.Forwards = append(v.Forwards, CapturedForward{
+			Attempt:         i + 1,
+			TargetURL:       f.targetURL,
+			At:              f.at,
+			Bytes:           len(f.body),
+			SHA256:          sha256Hex(f.body),
+			Identical:       diff < 0,
+			FirstDiffOffset: diff,
+		})
+	}
+	return v
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// firstDiff returns the offset of the first byte where a and b differ, or -1.
+func firstDiff(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return n
+	}
+	return -1
+}
+
+// replay re-runs the request path from the recorded pipeline input to the
+// body that would be forwarded: compression pipes, cache guard, phantom tool
+// injection and model_rewrite. Tool sessions are read as they are now,
+// so expansions made since the request can make the result differ.
+func (g *Gateway) replay(ctx context.Context, c *capturedRequest) ([]byte, bool) {
+	adapter := g.registry.Get(c.adapter)
+	if adapter == nil || g.router == nil {
+		return nil, false
+	}
+	input := c.input()
+	provider := adapter.Provider()
+
+	pipeCtx := NewPipelineContext(provider, adapter, input, c.path)
+	pipeCtx.RequestCtx = ctx
+	pipeCtx.RequestID = c.id
+	pipeCtx.Model = requestModel(adapter, input, c.path)
+	pipeCtx.TargetModel = pipeCtx.Model
+	pipeCtx.CostSessionID = c.sessionID
+	pipeCtx.SessionID = c.toolSessionID
+	pipeCtx.ToolSessionID = c.toolSessionID
+	pipeCtx.Flags = c.flags
+	if g.toolSessions != nil && c.toolSessionID != "" {
+		pipeCtx.ExpandedTools = g.toolSessions.GetExpanded(c.toolSessionID)
+	}
+
+	forwardBody, _, err := g.router.ProcessAll(pipeCtx)
+	if err != nil || len(forwardBody) == 0 {
+		forwardBody = input
+	}
+	baseline := input
+	if pipeCtx.securedBody != nil {
+		baseline = pipeCtx.securedBody
+	}
+	forwardBody = g.guardCachePrefix(pipeCtx, baseline, forwardBody)
+	if injected, err := phantom_tools.InjectAll(forwardBody, provider); err == nil {
+		forwardBody = injected
+	}
+	forwardBody = g.rewriteModel(c.path, provider, forwardBody)
+	return forwardBody, true
+}
+
+// handleDebugRequests serves /debug/requests and /debug/requests/{id}[/original|/forwarded|/replay].
+func (g *Gateway) handleDebugRequests(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if g.requestCapture == nil {
+		g.writeError(w, "request capture disabled (monitoring.request_capture.enabled)", http.StatusServiceUnavailable)
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/debug/requests"), "/")
+	id, part, _ := strings.Cut(rest, "/")
+
+	if id == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		requests := g.requestCapture.list()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"count":    len(requests),
+			"requests": requests,
+		}); err != nil {
+			log.Warn().Err(err).Msg("handleDebugRequests: failed to encode JSON response")
+		}
+		return
+	}
+
+	c, ok := g.requestCapture.get(id)
+	if !ok {
+		g.writeError(w, "request not captured", http.StatusNotFound)
+		return
+	}
+
+	wantMethod := http.MethodGet
+	if part == "replay" {
+		wantMethod = http.MethodPost
+	}
+	if r.Method != wantMethod {
+		w.Header().Set("Allow", wantMethod)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch part {
+	case "":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.view()); err != nil {
+			log.Warn().Err(err).Msg("handleDebugRequests: failed to encode JSON response")
+		}
+	case "original":
+		writeCapturedBody(w, c.original)
+	case "forwarded":
+		forwards := c.forwards
+		if len(forwards) == 0 {
+			g.writeError(w, "request was not forwarded", http.StatusNotFound)
+			return
+		}
+		attempt := len(forwards)
+		if v := r.URL.Query().Get("attempt"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > len(forwards) {
+				g.writeError(w, "attempt out of range", http.StatusBadRequest)
+				return
+			}
+			attempt = n
+		}
+		writeCapturedBody(w, forwards[attempt-1].body)
+	case "replay":
+		replayed, ok := g.replay(r.Context(), &c)
+		if !ok {
+			g.writeError(w, "replay unavailable for this request", http.StatusUnprocessableEntity)
+			return
+		}
+		// Phantom tool loops rewrite later attempts; the first forward is
+		// what the pipeline produced.
+		matches := "unknown"
+		if len(c.forwards) > 0 {
+			matches = strconv.FormatBool(bytes.Equal(replayed, c.forwards[0].body))
+		}
+		w.Header().Set(HeaderReplayMatches, matches)
+		writeCapturedBody(w, replayed)
+	default:
+		g.writeError(w, "not found", http.StatusNotFound)
+	}
+}
+
+// writeCapturedBody writes body verbatim so clients can diff it byte for byte.
+func writeCapturedBody(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, _ = w.Write(body)
+}
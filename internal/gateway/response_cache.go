@@ -0,0 +1,193 @@
+// response_cache.go - Response cache pipe for deterministic requests.
+//
+// Agent frameworks retry and replay identical turns. With pipes.response_cache
+// enabled, a request that pins temperature to 0 is keyed like a passthrough
+// cache entry (target, credential, API version headers and body). An identical
+// request within the TTL is answered from the cache before the pipeline runs:
+// nothing is forwarded and no spend is recorded. Only complete 200 responses
+// the client received in full are stored.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// temperaturePaths lists where each request format sets the temperature.
+var temperaturePaths = []string{
+	"temperature",                  // Anthropic, OpenAI
+	"generationConfig.temperature", // Gemini
+	"inferenceConfig.temperature",  // Bedrock Converse
+	"options.temperature",          // Ollama
+}
+
+// uncachedHeaders are per-request headers never replayed from the cache.
+var uncachedHeaders = []string{
+	HeaderRequestID,
+	HeaderGatewayCache,
+	HeaderGatewayCostEstimate,
+	HeaderGatewayCostSaved,
+	HeaderBudgetSimulated,
+	HeaderPromptInjection,
+}
+
+// isDeterministicRequest reports whether body explicitly sets temperature 0.
+// Requests that continue server-side state (previous_response_id,
+// conversation) are excluded: their body does not determine the answer.
+func isDeterministicRequest(body []byte) bool {
+	if gjson.GetBytes(body, "previous_response_id").Exists() || gjson.GetBytes(body, "conversation").Exists() {
+		return false
+	}
+	for _, path := range temperaturePaths {
+		if t := gjson.GetBytes(body, path); t.Exists() {
+			return t.Type == gjson.Number && t.Float() == 0
+		}
+	}
+	return false
+}
+
+// responseCacheEntries returns the configured cache size or its default.
+func responseCacheEntries(cfg pipes.ResponseCacheConfig) int {
+	if cfg.MaxEntries <= 0 {
+		return pipes.DefaultResponseCacheEntries
+	}
+	return cfg.MaxEntries
+}
+
+// responseCacheKey returns the cache key for r, or "" when the response cache
+// is disabled or the request is not deterministic. body must be the request
+// as the client sent it.
+func (g *Gateway) responseCacheKey(r *http.Request, body []byte) string {
+	if g.responseCache == nil || !g.cfg().Pipes.ResponseCache.Enabled || !isDeterministicRequest(body) {
+		return ""
+	}
+	return g.requestCacheKey(r, body)
+}
+
+// serveCachedResponse replays the cached response for key. It returns false
+// on a miss.
+func (g *Gateway) serveCachedResponse(w http.ResponseWriter, key, requestID string) bool {
+	cached, ok := g.responseCache.get(key)
+	if !ok {
+		return false
+	}
+	copyHeaders(w, cached.header)
+	w.Header().Set(HeaderGatewayCache, cacheStatusHit)
+	w.WriteHeader(cached.status)
+	_, _ = w.Write(cached.body) // #nosec G705 -- replayed upstream response, not HTML
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	log.Info().
+		Str("request_id", requestID).
+		Int("response_size", len(cached.body)).
+		Msg("response_cache: replayed cached response")
+	return true
+}
+
+// newResponseRecorder wraps w to capture the response for the cache.
+func (g *Gateway) newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	limit := g.cfg().Pipes.ResponseCache.MaxBodyBytes
+	if limit <= 0 {
+		limit = pipes.DefaultResponseCacheMaxBodyBytes
+	}
+	return &responseRecorder{ResponseWriter: w, limit: limit}
+}
+
+// storeCachedResponse caches what rec captured, unless the response failed,
+// was cut short, or was not a plain upstream answer.
+func (g *Gateway) storeCachedResponse(ctx context.Context, key string, rec *responseRecorder) {
+	if rec.status != http.StatusOK || rec.incomplete || ctx.Err() != nil {
+		return
+	}
+	header := rec.Header().Clone()
+	if header.Get("X-Synthetic-Response") != "" {
+		return // Savings reports and precomputed compactions depend on gateway state
+	}
+	for _, h := range uncachedHeaders {
+		header.Del(h)
+	}
+	ttl := g.cfg().Pipes.ResponseCache.TTL
+	if ttl <= 0 {
+		ttl = pipes.DefaultResponseCacheTTL
+	}
+	g.responseCache.set(key, cachedResponse{
+		status:    rec.status,
+		header:    header,
+		body:      bytes.Clone(rec.body.Bytes()),
+		expiresAt: time.Now().Add(ttl),
+	})
+}
+
+// responseRecorder passes the response through to the client and keeps a
+// copy of what was actually delivered.
+type responseRecorder struct {
+	http.ResponseWriter
+	status     int
+	body       bytes.Buffer
+	limit      int
+	incomplete bool // Write failed, body over limit, or stream events dropped
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.Header().Set(HeaderGatewayCache, cacheStatusMiss)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	switch {
+	case err != nil:
+		w.markIncomplete()
+	case !w.incomplete && w.body.Len()+n > w.limit:
+		w.markIncomplete()
+	case !w.incomplete:
+		w.body.Write(p[:n])
+	}
+	return n, err
+}
+
+// Flush forwards to the wrapped writer so streamed chunks are not held back.
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *responseRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// markIncomplete keeps the response out of the cache.
+func (w *responseRecorder) markIncomplete() {
+	w.incomplete = true
+	w.body = bytes.Buffer{}
+}
+
+// markResponseIncomplete tells a responseRecorder anywhere in w's wrapper
+// chain that the client missed part of the response.
+func markResponseIncomplete(w http.ResponseWriter) {
+	for w != nil {
+		if rec, ok := w.(*responseRecorder); ok {
+			rec.markIncomplete()
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,118 @@
+// response_chains.go - Sessions for OpenAI Responses API stored conversations.
+//
+// With previous_response_id (or a conversation object) the client sends only
+// the new input; the history lives on OpenAI's servers. The first user message
+// is not in the request, so hash-based session IDs cannot identify the
+// conversation. Instead, every response ID is recorded against the session
+// that produced it, and a follow-up request continues that session.
+//
+// Stored conversations also cannot be compacted by rewriting history, so
+// preemptive summarization is skipped for them.
+package gateway
+
+import (
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+// responseChain is the session a stored response belongs to.
+type responseChain struct {
+	SessionID     string // Cost / conversation session
+	ToolSessionID string // Tool discovery session; empty when tool discovery was off
+}
+
+// responseChainStore maps response IDs to the session that produced them.
+type responseChainStore struct {
+	chains *sessionstore.Store[responseChain]
+}
+
+func newResponseChainStore(ttl time.Duration) *responseChainStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &responseChainStore{
+		chains: sessionstore.New[responseChain](ttl, 0, nil), // swept by the session collector
+	}
+}
+
+// storedConversationRefs returns the server-side conversation a Responses API
+// request continues. Both are empty when the client sends its full history.
+func storedConversationRefs(body []byte) (previousResponseID, conversationID string) {
+	previousResponseID = gjson.GetBytes(body, "previous_response_id").String()
+	conv := gjson.GetBytes(body, "conversation")
+	if conv.IsObject() {
+		conversationID = conv.Get("id").String()
+	} else if conv.Type == gjson.String {
+		conversationID = conv.String()
+	}
+	return previousResponseID, conversationID
+}
+
+// resolve returns the session a stored-conversation request continues, or
+// false if the request carries its own history. A conversation ID is the
+// session itself. An unknown previous_response_id (e.g. after a restart)
+// starts a session named after it, so the rest of the chain stays together.
+func (s *responseChainStore) resolve(body []byte) (responseChain, bool) {
+	previousResponseID, conversationID := storedConversationRefs(body)
+	if conversationID != "" {
+		return responseChain{SessionID: conversationID, ToolSessionID: conversationID}, true
+	}
+	if previousResponseID == "" {
+		return responseChain{}, false
+	}
+
+	var chain responseChain
+	if s.chains.View(previousResponseID, func(c *responseChain) { chain = *c }) {
+		return chain, true
+	}
+	return responseChain{SessionID: previousResponseID, ToolSessionID: previousResponseID}, true
+}
+
+// record links responseID to the request's sessions.
+func (s *responseChainStore) record(responseID string, pipeCtx *PipelineContext) {
+	if responseID == "" || pipeCtx == nil || pipeCtx.CostSessionID == "" {
+		return
+	}
+	chain := responseChain{SessionID: pipeCtx.CostSessionID, ToolSessionID: pipeCtx.ToolSessionID}
+	s.chains.Update(responseID, func(c *responseChain) { *c = chain })
+}
+
+// Len returns the number of recorded response IDs (sessionstore.Sweeper).
+func (s *responseChainStore) Len() int {
+	return s.chains.Len()
+}
+
+// Sweep removes idle response IDs (sessionstore.Sweeper).
+func (s *responseChainStore) Sweep() int {
+	return s.chains.Sweep()
+}
+
+// Expire forgets every response ID recorded for a session (sessionstore.Sweeper).
+func (s *responseChainStore) Expire(sessionID string) bool {
+	var ids []string
+	s.chains.Range(func(id string, c *responseChain) {
+		if c.SessionID == sessionID {
+			ids = append(ids, id)
+		}
+	})
+	for _, id := range ids {
+		s.chains.Delete(id)
+	}
+	return len(ids) > 0
+}
+
+// Stop stops the cleanup goroutine. Safe to call multiple times.
+func (s *responseChainStore) Stop() {
+	s.chains.Stop()
+}
+
+// responseIDFromBody returns the ID of a non-streaming Responses API response.
+func responseIDFromBody(body []byte) string {
+	if gjson.GetBytes(body, "object").String() != "response" {
+		return ""
+	}
+	return gjson.GetBytes(body, "id").String()
+}
@@ -0,0 +1,182 @@
+// route_rules.go selects the pipe chain for a request from config rules.
+//
+// Rules are evaluated in order and the first match wins. A request that
+// matches no rule runs pipes.pipeline.order when set, otherwise the default
+// layout (task_output, then tool_output + tool_discovery in parallel).
+// POST /debug/route explains the decision for a sample request.
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// HeaderSessionTags carries comma-separated client-defined session tags
+// used by routing rules (e.g. "X-Session-Tags: ci, batch").
+const HeaderSessionTags = "X-Session-Tags"
+
+// Route layouts reported by RouteDecision.
+const (
+	RouteLayoutDefault = "default" // task_output, then tool_output + tool_discovery in parallel
+	RouteLayoutOrdered = "ordered" // Chain runs sequentially
+)
+
+// RouteInput holds the request attributes routing rules match on.
+type RouteInput struct {
+	Path        string   `json:"path"`
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model"`
+	SessionTags []string `json:"session_tags,omitempty"`
+	Size        int      `json:"size"`
+}
+
+// RouteDecision describes which chain a request runs and why.
+type RouteDecision struct {
+	Rule   string   `json:"rule,omitempty"` // Matched rule name; empty when no rule matched
+	Layout string   `json:"layout"`         // default | ordered
+	Chain  []string `json:"chain"`          // Pipes in execution order
+}
+
+// parseSessionTags splits the X-Session-Tags header into trimmed, non-empty tags.
+func parseSessionTags(h http.Header) []string {
+	var tags []string
+	for _, v := range h.Values(HeaderSessionTags) {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// routeInputFromContext builds the routing input for a pipeline context.
+func routeInputFromContext(ctx *PipelineContext) RouteInput {
+	return RouteInput{
+		Path:        ctx.OriginalPath,
+		Provider:    string(ctx.Provider),
+		Model:       ctx.Model,
+		SessionTags: ctx.SessionTags,
+		Size:        len(ctx.OriginalRequest),
+	}
+}
+
+// matchRoute returns the chain for in: the first matching rule, else the
+// configured order, else the default layout.
+func matchRoute(pc pipes.PipelineConfig, in RouteInput) RouteDecision {
+	for i := range pc.Rules {
+		rule := &pc.Rules[i]
+		if ruleMatches(rule, in) {
+			return RouteDecision{Rule: rule.Name, Layout: RouteLayoutOrdered, Chain: nonNilChain(rule.Chain)}
+		}
+	}
+	if len(pc.Order) > 0 {
+		return RouteDecision{Layout: RouteLayoutOrdered, Chain: pc.Order}
+	}
+	return RouteDecision{
+		Layout: RouteLayoutDefault,
+		Chain:  []string{pipes.PipeNameTaskOutput, pipes.PipeNameToolOutput, pipes.PipeNameToolDiscovery},
+	}
+}
+
+// ruleMatches reports whether every non-empty matcher in rule accepts in.
+// Globs were validated at config load, so match errors are treated as misses.
+func ruleMatches(rule *pipes.RouteRule, in RouteInput) bool {
+	if rule.Path != "" {
+		if ok, _ := path.Match(rule.Path, in.Path); !ok {
+			return false
+		}
+	}
+	if rule.Provider != "" && !strings.EqualFold(rule.Provider, in.Provider) {
+		return false
+	}
+	if rule.Model != "" {
+		if ok, _ := path.Match(rule.Model, in.Model); !ok {
+			return false
+		}
+	}
+	if rule.SessionTag != "" && !slices.Contains(in.SessionTags, rule.SessionTag) {
+		return false
+	}
+	if in.Size < rule.MinBytes {
+		return false
+	}
+	if rule.MaxBytes > 0 && in.Size > rule.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// nonNilChain keeps an empty rule chain serialised as [] rather than null.
+func nonNilChain(chain []string) []string {
+	if chain == nil {
+		return []string{}
+	}
+	return chain
+}
+
+// routeDebugResponse is the JSON response for POST /debug/route.
+type routeDebugResponse struct {
+	Input    RouteInput    `json:"input"`
+	Decision RouteDecision `json:"decision"`
+	Applies  RouteResult   `json:"applies"` // Pipes that are enabled and have content to act on
+}
+
+// handleRouteDebug serves POST /debug/route: explains which routing rule a
+// sample request matches. The body is the sample request; the path defaults
+// to /v1/messages and can be overridden with ?path=. Provider detection and
+// X-Session-Tags use the request headers, as for a proxied request.
+func (g *Gateway) handleRouteDebug(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		g.writeError(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	samplePath := r.URL.Query().Get("path")
+	if samplePath == "" {
+		samplePath = "/v1/messages"
+	}
+	provider, adapter := adapters.IdentifyAndGetAdapter(g.registry, samplePath, r.Header)
+	if adapter == nil {
+		g.writeError(w, "unsupported request format", http.StatusBadRequest)
+		return
+	}
+
+	pipeCtx := NewPipelineContext(provider, adapter, body, samplePath)
+	pipeCtx.Model = requestModel(adapter, body, samplePath)
+	pipeCtx.SessionTags = parseSessionTags(r.Header)
+
+	cfg := g.cfg()
+	resp := routeDebugResponse{Input: routeInputFromContext(pipeCtx)}
+	resp.Decision = matchRoute(cfg.Pipes.Pipeline, resp.Input)
+	if resp.Decision.Layout == RouteLayoutDefault {
+		resp.Decision.Chain = append(g.router.CustomPipeNames(), resp.Decision.Chain...)
+	}
+	resp.Applies = g.router.RouteFlags(pipeCtx, cfg)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleRouteDebug: failed to encode JSON response")
+	}
+}
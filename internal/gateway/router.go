@@ -2,8 +2,12 @@
 package gateway
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/tidwall/gjson"
@@ -11,8 +15,12 @@ import (
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/featureflags"
 	"github.com/compresr/context-gateway/internal/monitoring"
 	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/injection"
+	"github.com/compresr/context-gateway/internal/pipes/media"
+	"github.com/compresr/context-gateway/internal/pipes/pii"
 	taskoutput "github.com/compresr/context-gateway/internal/pipes/task_output"
 	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
 	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
@@ -28,6 +36,7 @@ const (
 	PipeToolOutput    = monitoring.PipeToolOutput
 	PipeToolDiscovery = monitoring.PipeToolDiscovery
 	PipeTaskOutput    = monitoring.PipeTaskOutput
+	PipeMedia         = monitoring.PipeMedia
 )
 
 // Router routes requests to the appropriate pipe based on content analysis.
@@ -37,6 +46,10 @@ type Router struct {
 	taskOutputPool    *Pool // task output pipe (runs before tool_output)
 	toolOutputPool    *Pool
 	toolDiscoveryPool *Pool
+	piiPool           *Pool              // PII masking (runs before every other pipe)
+	injectionPool     *Pool              // Prompt-injection scanning (runs after PII masking)
+	mediaPool         *Pool              // Media block dropping/downscaling (runs after the security pipes)
+	customPools       []*Pool            // pipes.custom, in config order
 	taskOutputLogger  *taskoutput.Logger // shared logger for all task_output pool workers
 	store             store.Store        // kept for pool rebuild on config reload
 	poolSize          int
@@ -46,6 +59,8 @@ type Router struct {
 type Pool struct {
 	workers chan pipes.Pipe
 	size    int
+	slo     *sloGuard   // nil when the pipe has no SLO configured
+	custom  *customPipe // nil for built-in pipes
 }
 
 func newPool(size int, factory func() pipes.Pipe) *Pool {
@@ -59,33 +74,92 @@ func newPool(size int, factory func() pipes.Pipe) *Pool {
 func (p *Pool) acquire() pipes.Pipe     { return <-p.workers }
 func (p *Pool) release(pipe pipes.Pipe) { p.workers <- pipe }
 
+// process runs pctx on a pooled worker. Release is deferred so a panic
+// does not drain the pool.
+func (p *Pool) process(pctx *pipes.PipeContext) ([]byte, error) {
+	worker := p.acquire()
+	defer p.release(worker)
+	return worker.Process(pctx)
+}
+
+// run processes pctx, enforcing the pool's SLO when one is configured.
+// The returned PipeSLO is zero for pools without an SLO.
+func (p *Pool) run(pctx *pipes.PipeContext) ([]byte, monitoring.PipeSLO, error) {
+	if p.slo == nil {
+		body, err := p.process(pctx)
+		return body, monitoring.PipeSLO{}, err
+	}
+	return p.slo.run(p, pctx)
+}
+
 // NewRouter creates a new router with worker pools.
 func NewRouter(cfg *config.Config, st store.Store) *Router {
-	poolSize := 10
-
-	logger := taskoutput.NewLogger(cfg.Pipes.TaskOutput.LogFile)
-	return &Router{
+	r := &Router{
 		config:           cfg,
 		store:            st,
-		poolSize:         poolSize,
-		taskOutputLogger: logger,
-		taskOutputPool: newPool(poolSize, func() pipes.Pipe {
-			return taskoutput.New(cfg, logger)
-		}),
-		toolOutputPool: newPool(poolSize, func() pipes.Pipe {
-			return tooloutput.New(cfg, st)
-		}),
-		toolDiscoveryPool: newPool(poolSize, func() pipes.Pipe {
-			return tooldiscovery.New(cfg)
-		}),
+		poolSize:         10,
+		taskOutputLogger: taskoutput.NewLogger(cfg.Pipes.TaskOutput.LogFile),
+	}
+	r.taskOutputPool, r.toolOutputPool, r.toolDiscoveryPool = r.buildPools(cfg, r.taskOutputLogger)
+	r.piiPool = newPool(r.poolSize, func() pipes.Pipe { return pii.New(cfg, st) })
+	r.injectionPool = newPool(r.poolSize, func() pipes.Pipe { return injection.New(cfg) })
+	r.mediaPool = newPool(r.poolSize, func() pipes.Pipe { return media.New(cfg, st) })
+	r.customPools = r.buildCustomPools(cfg)
+	return r
+}
+
+// buildPools creates the task_output, tool_output and tool_discovery pools for cfg,
+// attaching SLO guards (and their degraded local-strategy pools) from cfg.Pipes.SLO.
+func (r *Router) buildPools(cfg *config.Config, logger *taskoutput.Logger) (ta, to, td *Pool) {
+	factories := map[string]func(*config.Config) func() pipes.Pipe{
+		pipes.PipeNameTaskOutput: func(c *config.Config) func() pipes.Pipe {
+			return func() pipes.Pipe { return taskoutput.New(c, logger) }
+		},
+		pipes.PipeNameToolOutput: func(c *config.Config) func() pipes.Pipe {
+			return func() pipes.Pipe { return tooloutput.New(c, r.store) }
+		},
+		pipes.PipeNameToolDiscovery: func(c *config.Config) func() pipes.Pipe {
+			cache := tooldiscovery.NewSessionCache() // Shared by the pool's instances
+			return func() pipes.Pipe { return tooldiscovery.NewWithCache(c, cache) }
+		},
+	}
+	build := func(name string) *Pool {
+		pool := newPool(r.poolSize, factories[name](cfg))
+		sloCfg, ok := cfg.Pipes.SLO[name]
+		if !ok {
+			return pool
+		}
+		var degraded *Pool
+		if sloCfg.DegradeTo != "" {
+			degraded = newPool(r.poolSize, factories[name](withPipeStrategy(cfg, name, sloCfg.DegradeTo)))
+		}
+		pool.slo = newSLOGuard(name, sloCfg, degraded)
+		return pool
+	}
+	return build(pipes.PipeNameTaskOutput), build(pipes.PipeNameToolOutput), build(pipes.PipeNameToolDiscovery)
+}
+
+// withPipeStrategy returns a shallow copy of cfg with the named pipe's strategy replaced.
+func withPipeStrategy(cfg *config.Config, name, strategy string) *config.Config {
+	c := *cfg
+	switch name {
+	case pipes.PipeNameTaskOutput:
+		c.Pipes.TaskOutput.Strategy = strategy
+	case pipes.PipeNameToolOutput:
+		c.Pipes.ToolOutput.Strategy = strategy
+	case pipes.PipeNameToolDiscovery:
+		c.Pipes.ToolDiscovery.Strategy = strategy
 	}
+	return &c
 }
 
-// Close releases resources held by the router (log file descriptors, etc.).
+// Close releases resources held by the router (log file descriptors, plugins, etc.).
 func (r *Router) Close() error {
 	r.mu.Lock()
 	logger := r.taskOutputLogger
+	customPools := r.customPools
 	r.mu.Unlock()
+	closeCustomPools(customPools)
 	if logger != nil {
 		return logger.Close()
 	}
@@ -100,25 +174,29 @@ func (r *Router) Close() error {
 // during I/O.
 func (r *Router) UpdateConfig(cfg *config.Config) {
 	newLogger := taskoutput.NewLogger(cfg.Pipes.TaskOutput.LogFile)
-	newTA := newPool(r.poolSize, func() pipes.Pipe {
-		return taskoutput.New(cfg, newLogger)
-	})
-	newTO := newPool(r.poolSize, func() pipes.Pipe {
-		return tooloutput.New(cfg, r.store)
-	})
-	newTD := newPool(r.poolSize, func() pipes.Pipe {
-		return tooldiscovery.New(cfg)
-	})
+	newTA, newTO, newTD := r.buildPools(cfg, newLogger)
+	newPII := newPool(r.poolSize, func() pipes.Pipe { return pii.New(cfg, r.store) })
+	newInjection := newPool(r.poolSize, func() pipes.Pipe { return injection.New(cfg) })
+	newMedia := newPool(r.poolSize, func() pipes.Pipe { return media.New(cfg, r.store) })
+	newCustom := r.buildCustomPools(cfg)
 
 	r.mu.Lock()
 	oldLogger := r.taskOutputLogger
+	oldCustom := r.customPools
 	r.config = cfg
 	r.taskOutputLogger = newLogger
 	r.taskOutputPool = newTA
 	r.toolOutputPool = newTO
 	r.toolDiscoveryPool = newTD
+	r.piiPool = newPII
+	r.injectionPool = newInjection
+	r.mediaPool = newMedia
+	r.customPools = newCustom
 	r.mu.Unlock()
 
+	// Old plugins finish the calls they have, then exit on stdin EOF.
+	go closeCustomPools(oldCustom)
+
 	// Close old logger after releasing the lock to avoid holding the lock during I/O.
 	if oldLogger != nil {
 		if err := oldLogger.Close(); err != nil {
@@ -136,11 +214,80 @@ func (r *Router) snapshot() (*config.Config, *Pool, *Pool, *Pool) {
 	return r.config, r.taskOutputPool, r.toolOutputPool, r.toolDiscoveryPool
 }
 
+// secure runs the security pipes on ctx.OriginalRequest, once per request:
+// PII masking, then prompt-injection scanning. Their output replaces
+// ctx.OriginalRequest so every later pipe only sees masked, scanned content.
+//
+// A PII failure sets ctx.PIIError and an injection block sets
+// ctx.PipeRejection; in either case the caller must not forward the request.
+// A failed injection scan is logged and the request passes through.
+func (r *Router) secure(ctx *PipelineContext, cfg *config.Config) {
+	if len(ctx.OriginalRequest) == 0 || ctx.securedBody != nil {
+		return
+	}
+	if !cfg.Pipes.PII.Enabled && !cfg.Pipes.Injection.Enabled {
+		return
+	}
+	r.mu.RLock()
+	piiPool, injectionPool := r.piiPool, r.injectionPool
+	r.mu.RUnlock()
+
+	if cfg.Pipes.PII.Enabled {
+		masked, err := runSecurityPipe(piiPool, ctx, pipes.PipeNamePII)
+		if err != nil {
+			log.Error().Err(err).Str("request_id", ctx.RequestID).Msg("pii masking failed, request will be rejected")
+			ctx.PIIError = err
+			return
+		}
+		ctx.OriginalRequest = masked
+	}
+	if cfg.Pipes.Injection.Enabled {
+		ctx.injectionAction = cfg.Pipes.Injection.EffectiveAction()
+		scanned, err := runSecurityPipe(injectionPool, ctx, pipes.PipeNameInjection)
+		var reject *pipes.RejectError
+		switch {
+		case errors.As(err, &reject):
+			log.Warn().Str("request_id", ctx.RequestID).Str("reason", reject.Reason).Msg("injection: request blocked")
+			ctx.PipeRejection, ctx.rejectStatus, ctx.rejectCode = reject, http.StatusBadRequest, monitoring.ErrorCodePromptInjection
+			return
+		case err != nil:
+			log.Warn().Err(err).Str("request_id", ctx.RequestID).Msg("injection: scan failed, forwarding unscanned")
+			ctx.PipeFailed = true
+		default:
+			ctx.OriginalRequest = scanned
+		}
+	}
+	ctx.securedBody = ctx.OriginalRequest
+}
+
+// runSecurityPipe runs a security pipe, turning a panic into an error.
+func runSecurityPipe(pool *Pool, ctx *PipelineContext, name string) (body []byte, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s pipe panic: %v", name, p)
+		}
+	}()
+	body, _, err = pool.run(ctx.PipeContext)
+	return body, err
+}
+
+// shrinkMedia runs the media pipe on ctx.OriginalRequest, so every later pipe
+// sees the request with older image and document blocks already reduced.
+func (r *Router) shrinkMedia(ctx *PipelineContext, cfg *config.Config) {
+	if !cfg.Pipes.Media.Enabled || len(ctx.OriginalRequest) == 0 {
+		return
+	}
+	r.mu.RLock()
+	mediaPool := r.mediaPool
+	r.mu.RUnlock()
+	ctx.OriginalRequest = r.runPipe(mediaPool, ctx, ctx.OriginalRequest, pipes.PipeNameMedia)
+}
+
 // RouteResult indicates which pipes should run on this request.
 type RouteResult struct {
-	TaskOutput    bool // task output pipe (runs before tool_output)
-	ToolOutput    bool
-	ToolDiscovery bool
+	TaskOutput    bool `json:"task_output"` // task output pipe (runs before tool_output)
+	ToolOutput    bool `json:"tool_output"`
+	ToolDiscovery bool `json:"tool_discovery"`
 }
 
 // RouteFlags returns which pipes should run on this request.
@@ -165,8 +312,9 @@ func (r *Router) RouteFlags(ctx *PipelineContext, cfg *config.Config) RouteResul
 	// Check for tool outputs.
 	result.ToolOutput = cfg.Pipes.ToolOutput.Enabled && len(toolOutputs) > 0
 
-	// Check for tool discovery
-	if cfg.Pipes.ToolDiscovery.Enabled {
+	// Check for tool discovery. A detached request could not search for the
+	// tools it filtered out.
+	if cfg.Pipes.ToolDiscovery.Enabled && !ctx.Detached {
 		contents, err := ctx.Adapter.ExtractToolDiscovery(ctx.OriginalRequest, nil)
 		if err == nil {
 			ctx.ToolDiscoveryToolCount = len(contents)
@@ -184,16 +332,35 @@ func (r *Router) RouteFlags(ctx *PipelineContext, cfg *config.Config) RouteResul
 
 // ProcessAll processes the request through ALL applicable pipes.
 //
-// Execution order:
+// The security pipes (PII masking, then injection scanning) always run
+// first; see secure. The media pipe runs next; see shrinkMedia.
+//
+// Execution order (default):
+//  0. pipes.custom (sequential, in config order) — a refusal stops processing.
 //  1. task_output (sequential) — claims subagent tool result IDs, optionally compresses them.
 //  2. tool_output + tool_discovery (parallel) — skips IDs claimed by task_output.
 //
+// When a pipes.pipeline rule matches or pipes.pipeline.order is set, the
+// selected chain runs sequentially instead (see matchRoute and processOrdered).
+//
 // tool_output (messages[]) and tool_discovery (tools[]) modify non-overlapping JSON
 // paths so they can run concurrently. Results are merged via sjson.
 func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 	// Take a consistent snapshot so config changes mid-request don't produce torn reads.
 	cfg, taPool, toPool, tdPool := r.snapshot()
 
+	// Security pipes always run first so no pipe (or external service) sees
+	// raw entities or unscanned tool outputs.
+	r.secure(ctx, cfg)
+	if ctx.PIIError != nil {
+		return ctx.OriginalRequest, RouteResult{}, ctx.PIIError
+	}
+	if ctx.PipeRejection != nil {
+		return ctx.OriginalRequest, RouteResult{}, ctx.PipeRejection
+	}
+	r.shrinkMedia(ctx, cfg)
+
+	customPools := r.customSnapshot()
 	flags := r.RouteFlags(ctx, cfg)
 	body := ctx.OriginalRequest
 
@@ -208,13 +375,47 @@ func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 	runTA := flags.TaskOutput &&
 		(cfg.Pipes.TaskOutput.Strategy != config.StrategyPassthrough ||
 			effectiveClient != "")
+	runTO := flags.ToolOutput && cfg.Pipes.ToolOutput.Strategy != config.StrategyPassthrough
+	runTD := flags.ToolDiscovery && cfg.Pipes.ToolDiscovery.Strategy != config.StrategyPassthrough
+
+	// Routing rules or an explicit order select a sequential chain.
+	// A route_rules feature flag that is off for this request skips the rules.
+	pipeline := cfg.Pipes.Pipeline
+	if !ctx.Flags.On(featureflags.RouteRules, true) {
+		pipeline.Rules = nil
+	}
+	route := matchRoute(pipeline, routeInputFromContext(ctx))
+	if route.Layout == RouteLayoutOrdered {
+		log.Debug().
+			Str("rule", route.Rule).
+			Strs("chain", route.Chain).
+			Msg("router: ordered pipe chain selected")
+		stages := map[string]pipelineStage{
+			pipes.PipeNameTaskOutput:    {pool: taPool, run: runTA},
+			pipes.PipeNameToolOutput:    {pool: toPool, run: runTO},
+			pipes.PipeNameToolDiscovery: {pool: tdPool, run: runTD},
+		}
+		for _, pool := range customPools {
+			stages[pool.custom.cfg.Name] = pipelineStage{pool: pool, run: true}
+		}
+		body = r.processOrdered(ctx, route.Chain, cfg.Pipes.Pipeline, stages, body)
+		if ctx.PipeRejection != nil {
+			return body, flags, ctx.PipeRejection
+		}
+		return body, flags, nil
+	}
+
+	for _, pool := range customPools {
+		body = r.runPipe(pool, ctx, body, pool.custom.cfg.Name)
+		if ctx.PipeRejection != nil {
+			return body, flags, ctx.PipeRejection
+		}
+	}
+
 	if runTA {
 		body = r.runPipe(taPool, ctx, body, "task_output")
 	}
 
-	runTO := flags.ToolOutput && cfg.Pipes.ToolOutput.Strategy != config.StrategyPassthrough
-	runTD := flags.ToolDiscovery && cfg.Pipes.ToolDiscovery.Strategy != config.StrategyPassthrough
-
 	// Fast path: only one pipe active — no parallelization overhead
 	if !runTO && !runTD {
 		return body, flags, nil
@@ -232,6 +433,7 @@ func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 	var (
 		toBody, tdBody []byte
 		toErr, tdErr   error
+		toSLO, tdSLO   monitoring.PipeSLO
 		wg             sync.WaitGroup
 	)
 
@@ -252,8 +454,6 @@ func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		worker := toPool.acquire()
-		defer toPool.release(worker) // Release even on panic
 		defer func() {
 			if r := recover(); r != nil {
 				toErr = fmt.Errorf("tool_output panic: %v", r)
@@ -261,21 +461,21 @@ func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 			}
 		}()
 		ctx.OriginalRequest = body
-		toBody, toErr = worker.Process(ctx.PipeContext)
+		toBody, toSLO, toErr = toPool.run(ctx.PipeContext)
 	}()
 	go func() {
 		defer wg.Done()
-		worker := tdPool.acquire()
-		defer tdPool.release(worker) // Release even on panic
 		defer func() {
 			if r := recover(); r != nil {
 				tdErr = fmt.Errorf("tool_discovery panic: %v", r)
 				log.Error().Interface("panic", r).Msg("tool_discovery pipe panicked")
 			}
 		}()
-		tdBody, tdErr = worker.Process(&tdCtx)
+		tdBody, tdSLO, tdErr = tdPool.run(&tdCtx)
 	}()
 	wg.Wait()
+	ctx.recordSLO(toSLO)
+	ctx.recordSLO(tdSLO)
 
 	// Merge tool_discovery metrics back into main context
 	ctx.ToolsFiltered = tdCtx.ToolsFiltered
@@ -286,25 +486,137 @@ func (r *Router) ProcessAll(ctx *PipelineContext) ([]byte, RouteResult, error) {
 	ctx.ToolDiscoverySkipReason = tdCtx.ToolDiscoverySkipReason
 
 	// Merge body modifications
+	ctx.PipeFailed = ctx.PipeFailed || toErr != nil || tdErr != nil
 	body = mergeParallelResults(body, toBody, toErr, tdBody, tdErr)
 	return body, flags, nil
 }
 
+// ProcessPipe runs one pipe (tool_output or tool_discovery) over
+// ctx.OriginalRequest, outside the proxy path: no PII masking, routing rules
+// or other pipes. The body is returned unchanged when the pipe is in
+// passthrough or the request has nothing for it; ctx.PipeFailed reports a
+// pipe error.
+func (r *Router) ProcessPipe(ctx *PipelineContext, name string) ([]byte, error) {
+	cfg, _, toPool, tdPool := r.snapshot()
+	flags := r.RouteFlags(ctx, cfg)
+
+	var pool *Pool
+	var run bool
+	switch name {
+	case pipes.PipeNameToolOutput:
+		pool, run = toPool, flags.ToolOutput && cfg.Pipes.ToolOutput.Strategy != config.StrategyPassthrough
+	case pipes.PipeNameToolDiscovery:
+		pool, run = tdPool, flags.ToolDiscovery && cfg.Pipes.ToolDiscovery.Strategy != config.StrategyPassthrough
+	default:
+		return nil, fmt.Errorf("unknown pipe %q", name)
+	}
+	if !run {
+		return ctx.OriginalRequest, nil
+	}
+	return r.runPipe(pool, ctx, ctx.OriginalRequest, name), nil
+}
+
+// pipelineStage is one pipe in an ordered chain.
+type pipelineStage struct {
+	pool *Pool
+	run  bool // pipe is enabled and applicable to this request
+}
+
+// processOrdered runs the pipes in chain one after another.
+//
+// Each pipe receives the previous pipe's output. A pipe with a budget in pc
+// runs under a context deadline; if it fails or overruns the budget its output
+// is discarded and the PipeContext is restored to its pre-pipe state. With
+// pc.ShortCircuit, the first such pipe stops the chain.
+func (r *Router) processOrdered(ctx *PipelineContext, chain []string, pc pipes.PipelineConfig, stages map[string]pipelineStage, body []byte) []byte {
+	for _, name := range chain {
+		stage, ok := stages[name]
+		if !ok || !stage.run {
+			continue
+		}
+		next, ok := r.runBudgetedPipe(stage.pool, ctx, body, name, pc.Budgets[name])
+		if ctx.PipeRejection != nil {
+			break
+		}
+		if ok {
+			body = next
+			continue
+		}
+		if pc.ShortCircuit {
+			log.Warn().Str("pipe", name).Msg("pipeline: short-circuiting remaining pipes")
+			break
+		}
+	}
+	ctx.OriginalRequest = body
+	return body
+}
+
+// runBudgetedPipe executes a single pipe with an optional latency budget.
+// Returns the input body and false when the pipe fails or overruns the budget.
+func (r *Router) runBudgetedPipe(pool *Pool, ctx *PipelineContext, body []byte, name string, budget time.Duration) ([]byte, bool) {
+	before := ctx.PipeContext.Snapshot()
+	parent := ctx.RequestCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	stepCtx, cancel := parent, context.CancelFunc(func() {})
+	if budget > 0 {
+		stepCtx, cancel = context.WithTimeout(parent, budget)
+	}
+	defer cancel()
+
+	ctx.RequestCtx = stepCtx
+	start := time.Now()
+	wasFailed := ctx.PipeFailed
+	ctx.PipeFailed = false
+	result := r.runPipe(pool, ctx, body, name)
+	failed := ctx.PipeFailed
+	elapsed := time.Since(start)
+	ctx.RequestCtx = before.RequestCtx
+
+	overrun := budget > 0 && elapsed > budget
+	if overrun {
+		log.Warn().
+			Str("pipe", name).
+			Dur("elapsed", elapsed).
+			Dur("budget", budget).
+			Msg("pipeline: pipe exceeded latency budget, discarding output")
+	}
+	if failed || overrun {
+		*ctx.PipeContext = before
+		ctx.PipeFailed = true
+		return body, false
+	}
+	ctx.PipeFailed = wasFailed
+	return result, true
+}
+
 // runPipe executes a single pipe (fast path, no parallelization overhead).
-// Uses defer for worker release to prevent pool drain on panics.
+// The pool defers worker release, so a panic does not drain it.
 func (r *Router) runPipe(pool *Pool, ctx *PipelineContext, body []byte, name string) (result []byte) {
-	worker := pool.acquire()
-	defer pool.release(worker) // Release even on panic
+	start := time.Now()
 	defer func() {
 		if r := recover(); r != nil {
 			log.Error().Interface("panic", r).Str("pipe", name).Msg("pipe panicked, using original body")
+			ctx.PipeFailed = true
 			result = body
+			if pool.custom != nil {
+				result = ctx.finishCustomPipe(pool.custom, body, nil, fmt.Errorf("panic: %v", r), time.Since(start))
+			}
 		}
 	}()
 	ctx.OriginalRequest = body
-	modifiedBody, err := worker.Process(ctx.PipeContext)
+	if pool.custom != nil {
+		ctx.CustomMetrics = nil
+	}
+	modifiedBody, slo, err := pool.run(ctx.PipeContext)
+	ctx.recordSLO(slo)
+	if pool.custom != nil {
+		return ctx.finishCustomPipe(pool.custom, body, modifiedBody, err, time.Since(start))
+	}
 	if err != nil {
 		log.Error().Err(err).Str("pipe", name).Msg("pipe failed, using original body")
+		ctx.PipeFailed = true
 		return body
 	}
 	return modifiedBody
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// fakePipe appends its name to the body and optionally sleeps or fails.
+type fakePipe struct {
+	name  string
+	delay time.Duration
+	err   error
+}
+
+func (f *fakePipe) Name() string     { return f.name }
+func (f *fakePipe) Strategy() string { return "fake" }
+func (f *fakePipe) Enabled() bool    { return true }
+func (f *fakePipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	ctx.ToolOutputCompressions = append(ctx.ToolOutputCompressions, pipes.ToolOutputCompression{ToolName: f.name})
+	return append(append([]byte{}, ctx.OriginalRequest...), "|"+f.name...), nil
+}
+
+func newTestPipelineContext() *PipelineContext {
+	return &PipelineContext{PipeContext: pipes.NewPipeContext(nil, []byte("body"))}
+}
+
+func fakeStage(p *fakePipe) pipelineStage {
+	return pipelineStage{pool: newPool(1, func() pipes.Pipe { return p }), run: true}
+}
+
+func TestProcessOrdered_RunsInConfiguredOrder(t *testing.T) {
+	ctx := newTestPipelineContext()
+	stages := map[string]pipelineStage{
+		pipes.PipeNameTaskOutput:    fakeStage(&fakePipe{name: "ta"}),
+		pipes.PipeNameToolOutput:    fakeStage(&fakePipe{name: "to"}),
+		pipes.PipeNameToolDiscovery: fakeStage(&fakePipe{name: "td"}),
+	}
+	pc := pipes.PipelineConfig{Order: []string{"tool_discovery", "tool_output"}}
+
+	out := (&Router{}).processOrdered(ctx, pc.Order, pc, stages, []byte("body"))
+
+	assert.Equal(t, "body|td|to", string(out), "unlisted pipes must not run")
+	assert.False(t, ctx.PipeFailed)
+}
+
+func TestProcessOrdered_SkipsInapplicableStages(t *testing.T) {
+	ctx := newTestPipelineContext()
+	td := fakeStage(&fakePipe{name: "td"})
+	td.run = false
+	stages := map[string]pipelineStage{
+		pipes.PipeNameToolOutput:    fakeStage(&fakePipe{name: "to"}),
+		pipes.PipeNameToolDiscovery: td,
+	}
+	pc := pipes.PipelineConfig{Order: []string{"tool_discovery", "tool_output"}}
+
+	out := (&Router{}).processOrdered(ctx, pc.Order, pc, stages, []byte("body"))
+
+	assert.Equal(t, "body|to", string(out))
+}
+
+func TestProcessOrdered_BudgetOverrunDiscardsOutput(t *testing.T) {
+	ctx := newTestPipelineContext()
+	stages := map[string]pipelineStage{
+		pipes.PipeNameToolDiscovery: fakeStage(&fakePipe{name: "td", delay: 30 * time.Millisecond}),
+		pipes.PipeNameToolOutput:    fakeStage(&fakePipe{name: "to"}),
+	}
+	pc := pipes.PipelineConfig{
+		Order:   []string{"tool_discovery", "tool_output"},
+		Budgets: map[string]time.Duration{"tool_discovery": 5 * time.Millisecond},
+	}
+
+	out := (&Router{}).processOrdered(ctx, pc.Order, pc, stages, []byte("body"))
+
+	assert.Equal(t, "body|to", string(out), "overrunning pipe's output is dropped, chain continues")
+	assert.True(t, ctx.PipeFailed)
+	assert.Len(t, ctx.ToolOutputCompressions, 1, "discarded pipe's context changes are rolled back")
+	assert.Equal(t, "to", ctx.ToolOutputCompressions[0].ToolName)
+}
+
+func TestProcessOrdered_ShortCircuitStopsChain(t *testing.T) {
+	ctx := newTestPipelineContext()
+	stages := map[string]pipelineStage{
+		pipes.PipeNameTaskOutput:    fakeStage(&fakePipe{name: "ta"}),
+		pipes.PipeNameToolDiscovery: fakeStage(&fakePipe{name: "td", err: errors.New("boom")}),
+		pipes.PipeNameToolOutput:    fakeStage(&fakePipe{name: "to"}),
+	}
+	pc := pipes.PipelineConfig{
+		Order:        []string{"task_output", "tool_discovery", "tool_output"},
+		ShortCircuit: true,
+	}
+
+	out := (&Router{}).processOrdered(ctx, pc.Order, pc, stages, []byte("body"))
+
+	assert.Equal(t, "body|ta", string(out))
+	assert.True(t, ctx.PipeFailed)
+	assert.Equal(t, "body|ta", string(ctx.OriginalRequest))
+}
+
+// mutatingPipe writes into the context's maps and slices in place, then fails.
+type mutatingPipe struct{}
+
+func (mutatingPipe) Name() string     { return "mutating" }
+func (mutatingPipe) Strategy() string { return "fake" }
+func (mutatingPipe) Enabled() bool    { return true }
+func (mutatingPipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	ctx.ShadowRefs["shadow_1"] = "original"
+	ctx.ToolOutputCompressions[0].ToolName = "overwritten"
+	ctx.TaskOutputHandledIDs["call_1"] = struct{}{}
+	return nil, errors.New("boom")
+}
+
+func TestProcessOrdered_FailedPipeInPlaceWritesRolledBack(t *testing.T) {
+	ctx := newTestPipelineContext()
+	ctx.ToolOutputCompressions = []pipes.ToolOutputCompression{{ToolName: "earlier"}}
+	ctx.TaskOutputHandledIDs = map[string]struct{}{}
+	stages := map[string]pipelineStage{
+		pipes.PipeNameToolDiscovery: {pool: newPool(1, func() pipes.Pipe { return mutatingPipe{} }), run: true},
+	}
+	pc := pipes.PipelineConfig{Order: []string{"tool_discovery"}}
+
+	out := (&Router{}).processOrdered(ctx, pc.Order, pc, stages, []byte("body"))
+
+	assert.Equal(t, "body", string(out))
+	assert.True(t, ctx.PipeFailed)
+	assert.Empty(t, ctx.ShadowRefs)
+	assert.Equal(t, "earlier", ctx.ToolOutputCompressions[0].ToolName)
+	assert.Empty(t, ctx.TaskOutputHandledIDs)
+}
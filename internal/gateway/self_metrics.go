@@ -0,0 +1,149 @@
+// self_metrics.go - lifetime counters persisted in the state directory.
+//
+// In-memory counters start at zero on every restart (savings also on a new
+// session). selfMetrics adds their growth to statedir's self_metrics.json
+// every selfMetricsFlushInterval and at shutdown, so /stats, the dashboard and
+// `context-gateway stats` can show lifetime numbers next to since-start ones.
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+const selfMetricsFlushInterval = 30 * time.Second
+
+// selfMetrics persists lifetime counters. Safe to call on a nil receiver (disabled).
+type selfMetrics struct {
+	dir      string
+	savings  func() (tokens int64, costUSD float64) // Since-start (or since-session) savings
+	requests atomic.Int64                           // Proxied requests since start
+
+	mu       sync.Mutex
+	lifetime statedir.SelfMetrics // File totals after the last flush
+	flushed  statedir.SelfMetrics // Since-start counters already added to the file
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newSelfMetrics records a gateway start in dir and begins periodic flushing.
+// Returns nil when the state directory can't be written.
+func newSelfMetrics(dir string, savings func() (int64, float64)) *selfMetrics {
+	lifetime, err := statedir.AddSelfMetrics(dir, statedir.SelfMetrics{Starts: 1})
+	if err != nil {
+		log.Warn().Err(err).Msg("lifetime metrics disabled")
+		return nil
+	}
+	sm := &selfMetrics{
+		dir:      dir,
+		savings:  savings,
+		lifetime: lifetime,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go sm.run()
+	return sm
+}
+
+func (sm *selfMetrics) run() {
+	defer close(sm.done)
+	ticker := time.NewTicker(selfMetricsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sm.stop:
+			return
+		case <-ticker.C:
+			sm.flush()
+		}
+	}
+}
+
+// growth returns how much cur grew since flushed. A counter below its flushed
+// value was reset, so all of it is new.
+func growth(cur, flushed statedir.SelfMetrics) statedir.SelfMetrics {
+	d := cur
+	if cur.Requests >= flushed.Requests {
+		d.Requests -= flushed.Requests
+	}
+	if cur.TokensSaved >= flushed.TokensSaved {
+		d.TokensSaved -= flushed.TokensSaved
+	}
+	if cur.CostSavedUSD >= flushed.CostSavedUSD {
+		d.CostSavedUSD -= flushed.CostSavedUSD
+	}
+	return d
+}
+
+// RecordRequest counts one proxied request.
+func (sm *selfMetrics) RecordRequest() {
+	if sm != nil {
+		sm.requests.Add(1)
+	}
+}
+
+// SinceStart returns the in-memory counters.
+func (sm *selfMetrics) SinceStart() statedir.SelfMetrics {
+	m := statedir.SelfMetrics{Requests: sm.requests.Load()}
+	m.TokensSaved, m.CostSavedUSD = sm.savings()
+	return m
+}
+
+// flush adds counter growth since the last flush to the state file.
+// Call it before resetting the in-memory counters so no growth is lost.
+func (sm *selfMetrics) flush() {
+	if sm == nil {
+		return
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	cur := sm.SinceStart()
+	delta := growth(cur, sm.flushed)
+	if delta.Requests == 0 && delta.TokensSaved == 0 && delta.CostSavedUSD == 0 {
+		sm.flushed = cur // A reset without new growth: compare against the reset value next time
+		return
+	}
+	lifetime, err := statedir.AddSelfMetrics(sm.dir, delta)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to persist lifetime metrics")
+		return
+	}
+	sm.lifetime, sm.flushed = lifetime, cur
+}
+
+// Lifetime returns the persisted totals plus growth not yet flushed.
+func (sm *selfMetrics) Lifetime() statedir.SelfMetrics {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.lifetime.Plus(growth(sm.SinceStart(), sm.flushed))
+}
+
+// Close stops periodic flushing and writes the final counters.
+func (sm *selfMetrics) Close() {
+	if sm == nil {
+		return
+	}
+	select {
+	case <-sm.stop:
+		return
+	default:
+		close(sm.stop)
+	}
+	<-sm.done
+	sm.flush()
+}
+
+// savingsTotals returns tokens and dollars saved as reported by /stats.
+func (g *Gateway) savingsTotals() (int64, float64) {
+	if g.savings == nil {
+		return 0, 0
+	}
+	report := g.savings.GetReport()
+	return int64(report.TotalTokensSaved), report.CostSavedUSD
+}
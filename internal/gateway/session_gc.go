@@ -0,0 +1,58 @@
+// session_gc.go - Admin API for the session garbage collector.
+//
+// Per-session state (tool sessions, conversation branches, auth fallback,
+// cost sessions, preemptive summaries) is swept by one sessionstore.Collector
+// using per-store idle TTLs from session_gc.idle_ttl. GET /admin/sessions
+// reports each store's size and eviction counts; DELETE /admin/sessions/{id}
+// drops a session from every store immediately. Both endpoints are
+// loopback-only.
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// handleAdminSessions serves GET /admin/sessions and DELETE /admin/sessions/{id}.
+func (g *Gateway) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if g.sessionGC == nil {
+		g.writeError(w, "session gc disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/sessions"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"stores": g.sessionGC.Stats(),
+		}); err != nil {
+			log.Warn().Err(err).Msg("handleAdminSessions: failed to encode JSON response")
+		}
+	case id != "" && r.Method == http.MethodDelete:
+		stores := g.sessionGC.Expire(id)
+		if g.sessionIndex != nil {
+			g.sessionIndex.Delete(id)
+		}
+		if len(stores) == 0 {
+			g.writeError(w, "session not found", http.StatusNotFound)
+			return
+		}
+		log.Info().Str("session_id", id).Strs("stores", stores).Msg("admin: force-expired session")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"session_id": id, "stores": stores})
+	case id == "":
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", "DELETE")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,327 @@
+// session_inspector.go - Per-session state for debugging (GET /sessions).
+//
+// Session state is spread over several stores, each keyed its own way: cost
+// and auth fallback by the conversation ID (hash of the first user message),
+// tool discovery by a hash of the cleaned first user message plus branch, and
+// preemptive summaries by their own session ID. The session index records,
+// per conversation, which IDs its requests used, so GET /sessions/{id} can
+// answer "why did this session switch to API key mode" in one place:
+// cost so far, auth mode and fallback reason, expanded and deferred tools,
+// summary status and last compaction, and shadow refs created. Both
+// endpoints are loopback-only; the dashboard reaches them through
+// /api/instance/sessions?port=.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+// defaultSessionIndexTTL is used when cost sessions have no idle TTL configured.
+const defaultSessionIndexTTL = time.Hour
+
+// Auth modes reported by the session inspector.
+const (
+	SessionAuthSubscription = "subscription"
+	SessionAuthAPIKey       = "api_key"
+)
+
+// sessionActivity is what the index records about one conversation.
+type sessionActivity struct {
+	Provider         string
+	Model            string
+	ToolSessionID    string // Latest tool discovery session (branch-scoped)
+	SummarySessionID string // Latest preemptive summarization session
+	AuthMode         string // Effective auth mode of the latest request
+	FallbackReason   string // Why the session last switched to its API key
+	Requests         int
+	ShadowRefs       int // Shadow refs created by the session's requests
+	FirstSeen        time.Time
+	LastSeen         time.Time
+}
+
+// SessionInfo is the JSON shape of one session in GET /sessions.
+type SessionInfo struct {
+	ID         string          `json:"id"`
+	Provider   string          `json:"provider,omitempty"`
+	Model      string          `json:"model,omitempty"`
+	Requests   int             `json:"requests"`
+	FirstSeen  time.Time       `json:"first_seen,omitzero"`
+	LastSeen   time.Time       `json:"last_seen,omitzero"`
+	Auth       SessionAuth     `json:"auth"`
+	Cost       *SessionCost    `json:"cost,omitempty"`
+	Tools      *SessionTools   `json:"tools,omitempty"`
+	Summary    *SessionSummary `json:"summary,omitempty"`
+	ShadowRefs int             `json:"shadow_refs"`
+}
+
+// SessionAuth is a session's auth mode. Sticky is set while the session is
+// pinned to the API key after a subscription fallback.
+type SessionAuth struct {
+	Mode   string     `json:"mode,omitempty"` // subscription | api_key | none
+	Sticky bool       `json:"sticky"`
+	Since  *time.Time `json:"since,omitempty"`  // Last fallback (sticky only)
+	Reason string     `json:"reason,omitempty"` // Fallback reason reported by the provider's auth handler
+}
+
+// SessionCost is the session's spend as tracked by cost control.
+type SessionCost struct {
+	USD          float64 `json:"usd"`
+	CapUSD       float64 `json:"cap_usd,omitempty"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+}
+
+// SessionTools is the session's tool discovery state.
+type SessionTools struct {
+	SessionID string                           `json:"session_id"`
+	Expanded  []string                         `json:"expanded"`
+	Deferred  []string                         `json:"deferred"`
+	Servers   map[string]pipes.ToolServerStats `json:"servers,omitempty"` // MCP servers, from the latest filtering
+}
+
+// SessionSummary is the session's preemptive summarization state.
+type SessionSummary struct {
+	SessionID      string     `json:"session_id"`
+	Status         string     `json:"status"` // idle | pending | ready | used
+	Tokens         int        `json:"tokens,omitempty"`
+	UsagePercent   float64    `json:"usage_percent"`
+	LastCompaction *time.Time `json:"last_compaction,omitempty"`
+	Compactions    int        `json:"compactions"`
+}
+
+// sessionListResponse is the JSON response for GET /sessions.
+type sessionListResponse struct {
+	Count    int           `json:"count"`
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// newSessionIndex creates the conversation index; it sweeps itself every interval.
+func newSessionIndex(ttl, interval time.Duration) *sessionstore.Store[sessionActivity] {
+	if ttl <= 0 {
+		ttl = defaultSessionIndexTTL
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return sessionstore.New[sessionActivity](ttl, interval, nil)
+}
+
+// recordSessionActivity indexes a finished request under its conversation.
+func (g *Gateway) recordSessionActivity(params telemetryParams, model string) {
+	pipeCtx := params.pipeCtx
+	if g.sessionIndex == nil || pipeCtx == nil || pipeCtx.CostSessionID == "" {
+		return
+	}
+	summaryID := pipeCtx.PreemptiveHeaders["X-Session-ID"]
+	if summaryID == "" && g.preemptive != nil && !pipeCtx.StoredConversation {
+		summaryID = preemptive.RootSessionID(g.tenants.current().Get(pipeCtx.Tenant), params.requestHeaders, params.requestBody)
+	}
+
+	g.sessionIndex.Update(pipeCtx.CostSessionID, func(a *sessionActivity) {
+		now := time.Now()
+		if a.FirstSeen.IsZero() {
+			a.FirstSeen = now
+		}
+		a.LastSeen = now
+		a.Requests++
+		a.ShadowRefs += len(pipeCtx.ShadowRefs)
+		a.Provider = params.provider
+		if model != "" {
+			a.Model = model
+		}
+		if pipeCtx.ToolSessionID != "" {
+			a.ToolSessionID = pipeCtx.ToolSessionID
+		}
+		if summaryID != "" {
+			a.SummarySessionID = summaryID
+		}
+		if mode := params.authModeEffective; mode != "" && mode != "unknown" {
+			a.AuthMode = mode
+		}
+	})
+}
+
+// recordAuthFallback notes why a session switched to its API key.
+func (g *Gateway) recordAuthFallback(sessionID, reason string) {
+	if g.sessionIndex == nil || sessionID == "" {
+		return
+	}
+	g.sessionIndex.Update(sessionID, func(a *sessionActivity) {
+		a.FallbackReason = reason
+		a.AuthMode = SessionAuthAPIKey
+		a.LastSeen = time.Now()
+	})
+}
+
+// inspectSession assembles a session's state from every store. ok is false
+// when no store knows the session.
+func (g *Gateway) inspectSession(id string) (info SessionInfo, ok bool) {
+	info.ID = id
+	var act sessionActivity
+	if g.sessionIndex != nil {
+		ok = g.sessionIndex.View(id, func(a *sessionActivity) { act = *a })
+	}
+	info.Provider, info.Model = act.Provider, act.Model
+	info.Requests, info.ShadowRefs = act.Requests, act.ShadowRefs
+	info.FirstSeen, info.LastSeen = act.FirstSeen, act.LastSeen
+	info.Auth = SessionAuth{Mode: act.AuthMode}
+
+	if g.authMode != nil {
+		if since, sticky := g.authMode.Since(id); sticky {
+			info.Auth = SessionAuth{Mode: SessionAuthAPIKey, Sticky: true, Since: &since, Reason: act.FallbackReason}
+			ok = true
+		}
+	}
+
+	if g.costTracker != nil {
+		if c, found := g.costTracker.Session(id); found {
+			info.Cost = &SessionCost{USD: c.Cost, CapUSD: c.Cap, InputTokens: c.InputTokens, OutputTokens: c.OutputTokens}
+			if info.Model == "" {
+				info.Model = c.Model
+			}
+			if info.Requests == 0 {
+				info.Requests = c.RequestCount
+			}
+			ok = true
+		}
+	}
+
+	if g.toolSessions != nil && act.ToolSessionID != "" {
+		if ts := g.toolSessions.Get(act.ToolSessionID); ts != nil {
+			tools := &SessionTools{SessionID: act.ToolSessionID, Expanded: []string{}, Deferred: []string{}, Servers: ts.ServerStats}
+			for name := range ts.ExpandedTools {
+				tools.Expanded = append(tools.Expanded, name)
+			}
+			for _, d := range ts.DeferredTools {
+				tools.Deferred = append(tools.Deferred, d.ToolName)
+			}
+			sort.Strings(tools.Expanded)
+			sort.Strings(tools.Deferred)
+			info.Tools = tools
+		}
+	}
+
+	if g.preemptive != nil && act.SummarySessionID != "" {
+		if s, found := g.preemptive.Session(act.SummarySessionID); found {
+			info.Summary = &SessionSummary{
+				SessionID:      s.ID,
+				Status:         string(s.State),
+				Tokens:         s.SummaryTokens,
+				UsagePercent:   s.UsagePercent,
+				LastCompaction: s.SummaryUsedAt,
+				Compactions:    s.CompactionUseCount,
+			}
+		}
+	}
+	return info, ok
+}
+
+// handleSessions serves GET /sessions and GET /sessions/{id}.
+func (g *Gateway) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resp any
+	if id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sessions"), "/"); id != "" {
+		info, ok := g.inspectSession(id)
+		if !ok {
+			g.writeError(w, "session not found", http.StatusNotFound)
+			return
+		}
+		resp = info
+	} else {
+		list := sessionListResponse{Sessions: []SessionInfo{}}
+		if g.sessionIndex != nil {
+			var ids []string
+			g.sessionIndex.Range(func(id string, _ *sessionActivity) { ids = append(ids, id) })
+			for _, id := range ids {
+				if info, ok := g.inspectSession(id); ok {
+					list.Sessions = append(list.Sessions, info)
+				}
+			}
+		}
+		sort.Slice(list.Sessions, func(i, j int) bool {
+			return list.Sessions[i].LastSeen.After(list.Sessions[j].LastSeen)
+		})
+		list.Count = len(list.Sessions)
+		resp = list
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleSessions: failed to encode JSON response")
+	}
+}
+
+// handleInstanceSessionsProxy proxies session inspector requests to a gateway instance.
+// GET /api/instance/sessions?port=18081&id=... → http://127.0.0.1:18081/sessions/...
+func (g *Gateway) handleInstanceSessionsProxy(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	port, err := strconv.Atoi(r.URL.Query().Get("port"))
+	if err != nil || port <= 0 {
+		g.writeError(w, "invalid port", http.StatusBadRequest)
+		return
+	}
+	if !g.isKnownInstancePort(port) {
+		g.writeError(w, "port not found in active instances", http.StatusBadRequest)
+		return
+	}
+
+	target := &neturl.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port), Path: "/sessions"}
+	if id := r.URL.Query().Get("id"); id != "" {
+		target.Path += "/" + neturl.PathEscape(id)
+	}
+	proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target.String(), nil) // #nosec G704 -- target is 127.0.0.1 on a registered instance port
+	if err != nil {
+		g.writeError(w, "failed to create proxy request", http.StatusInternalServerError)
+		return
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(proxyReq) // #nosec G704 -- request targets 127.0.0.1 only
+	if err != nil {
+		g.writeError(w, fmt.Sprintf("instance on port %d unreachable", port), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		g.writeError(w, "failed to read instance response", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:18080")
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(respBody)
+}
@@ -0,0 +1,243 @@
+// session_persistence.go - Session state saved across restarts.
+//
+// Tool discovery sessions, sticky auth fallback and cost tracking live in
+// memory, so a restart in the middle of a task loses the deferred tools the
+// agent's next gateway_search_tools call needs and resets auth mode and
+// spend. With session_persistence enabled the gateway restores them from a
+// SQLite file at startup and saves them every interval and at shutdown. Each
+// store's export is one row; a save writes the changed rows in one
+// transaction, so a crash mid-save leaves the previous save intact. Restored
+// sessions keep their last-update times and expire on their usual idle TTL.
+package gateway
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/statedir"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite".
+)
+
+// persistedStore is one session store saved by sessionPersistence.
+type persistedStore struct {
+	name    string // config.SessionStore* name, the row key
+	export  func() (json.RawMessage, error)
+	restore func(json.RawMessage) (int, error)
+}
+
+// sessionPersistence saves session stores to SQLite. Safe to call on a nil
+// receiver (disabled).
+type sessionPersistence struct {
+	db     *sql.DB
+	path   string
+	stores []persistedStore
+
+	mu    sync.Mutex
+	saved map[string]json.RawMessage // Last data written per store; unchanged stores are skipped
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// persistedStores lists the gateway's stores that session_persistence covers.
+func (g *Gateway) persistedStores() []persistedStore {
+	var stores []persistedStore
+	if g.toolSessions != nil {
+		stores = append(stores, persistedStore{
+			name:    config.SessionStoreToolSessions,
+			export:  g.toolSessions.sessions.Export,
+			restore: g.toolSessions.sessions.Import,
+		})
+	}
+	if g.authMode != nil {
+		stores = append(stores, persistedStore{
+			name:    config.SessionStoreAuthFallback,
+			export:  g.authMode.sessions.Export,
+			restore: g.authMode.sessions.Import,
+		})
+	}
+	if g.costTracker != nil {
+		stores = append(stores, persistedStore{
+			name: config.SessionStoreCostSessions,
+			export: func() (json.RawMessage, error) {
+				state, err := g.costTracker.Export()
+				if err != nil {
+					return nil, err
+				}
+				return json.Marshal(state)
+			},
+			restore: func(data json.RawMessage) (int, error) {
+				var state costcontrol.TrackerState
+				if err := json.Unmarshal(data, &state); err != nil {
+					return 0, err
+				}
+				return g.costTracker.Import(&state)
+			},
+		})
+	}
+	return stores
+}
+
+// newSessionPersistence opens the database, restores the saved sessions into
+// stores and starts saving every cfg.Interval.
+func newSessionPersistence(cfg config.SessionPersistenceConfig, stores []persistedStore) (*sessionPersistence, error) {
+	path := cfg.Path
+	if path == "" {
+		dir, err := statedir.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, statedir.SessionsDB)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil { // #nosec G301
+		return nil, fmt.Errorf("create directory for %s: %w", path, err)
+	}
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		`CREATE TABLE IF NOT EXISTS session_state (
+			store    TEXT    PRIMARY KEY,
+			data     BLOB    NOT NULL,
+			saved_at INTEGER NOT NULL
+		) WITHOUT ROWID`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("init %s: %w", path, err)
+		}
+	}
+
+	sp := &sessionPersistence{
+		db:     db,
+		path:   path,
+		stores: stores,
+		saved:  make(map[string]json.RawMessage),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	sp.restore()
+	go sp.run(cfg.Interval)
+	return sp, nil
+}
+
+// restore loads every saved store. A store that fails to load starts empty.
+func (sp *sessionPersistence) restore() {
+	for _, s := range sp.stores {
+		var (
+			data    []byte
+			savedAt int64
+		)
+		err := sp.db.QueryRow(`SELECT data, saved_at FROM session_state WHERE store = ?`, s.name).Scan(&data, &savedAt)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("store", s.name).Msg("session persistence: read failed")
+			continue
+		}
+		n, err := s.restore(data)
+		if err != nil {
+			log.Warn().Err(err).Str("store", s.name).Msg("session persistence: restore failed, starting empty")
+			continue
+		}
+		sp.saved[s.name] = data
+		log.Info().
+			Str("store", s.name).
+			Int("sessions", n).
+			Time("saved_at", time.UnixMilli(savedAt)).
+			Msg("session persistence: restored")
+	}
+}
+
+func (sp *sessionPersistence) run(interval time.Duration) {
+	defer close(sp.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sp.stop:
+			return
+		case <-ticker.C:
+			if err := sp.save(); err != nil {
+				log.Warn().Err(err).Str("path", sp.path).Msg("session persistence: save failed")
+			}
+		}
+	}
+}
+
+// save writes every store whose export changed since the last save.
+func (sp *sessionPersistence) save() error {
+	if sp == nil {
+		return nil
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	changed := make(map[string]json.RawMessage)
+	for _, s := range sp.stores {
+		data, err := s.export()
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		if !bytes.Equal(data, sp.saved[s.name]) {
+			changed[s.name] = data
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	tx, err := sp.db.Begin()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	for name, data := range changed {
+		if _, err := tx.Exec(`INSERT INTO session_state (store, data, saved_at) VALUES (?, ?, ?)
+			ON CONFLICT(store) DO UPDATE SET data = excluded.data, saved_at = excluded.saved_at`, name, data, now); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for name, data := range changed {
+		sp.saved[name] = data
+	}
+	return nil
+}
+
+// Close stops periodic saving, writes a final save and closes the database.
+func (sp *sessionPersistence) Close() {
+	if sp == nil {
+		return
+	}
+	select {
+	case <-sp.stop:
+		return
+	default:
+		close(sp.stop)
+	}
+	<-sp.done
+	if err := sp.save(); err != nil {
+		log.Warn().Err(err).Str("path", sp.path).Msg("session persistence: final save failed")
+	}
+	if err := sp.db.Close(); err != nil {
+		log.Warn().Err(err).Msg("session persistence: close failed")
+	}
+}
@@ -0,0 +1,214 @@
+// shadow_mode.go - pipes.mode: shadow.
+//
+// In shadow mode every request is forwarded uncompressed. The compression
+// pipes still run, in the background on a copy of the request, and
+// shadow_eval.jsonl records what would have been sent: token counts, ratio,
+// per-tool results and the estimated input cost saved. GET /stats sums them
+// under shadow_mode. This measures compression on production traffic before
+// it can change what the model sees.
+//
+// PII masking and injection scanning are not compression: they still apply
+// to the forwarded request.
+// Background runs share the shadow store and compression caches with live
+// traffic, so switching to enforce starts warm. At most
+// pipes.shadow.max_concurrent runs are in flight; requests arriving while
+// all slots are busy are counted as skipped and not evaluated.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// ShadowModeStats sums shadow mode evaluations since start (GET /stats).
+type ShadowModeStats struct {
+	Evaluated           int64   `json:"evaluated"`
+	Skipped             int64   `json:"skipped"`    // Not evaluated: all background slots busy
+	Compressed          int64   `json:"compressed"` // Requests the pipes would have changed
+	OriginalTokens      int64   `json:"original_tokens"`
+	CompressedTokens    int64   `json:"compressed_tokens"`
+	TokensSaved         int64   `json:"tokens_saved"`
+	TokenSavedPct       float64 `json:"token_saved_pct"`
+	EstimatedSavingsUSD float64 `json:"estimated_savings_usd"`
+}
+
+// shadowEvaluator bounds and accounts for background shadow runs.
+type shadowEvaluator struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu    sync.Mutex
+	stats ShadowModeStats
+}
+
+func newShadowEvaluator(maxConcurrent int) *shadowEvaluator {
+	if maxConcurrent <= 0 {
+		maxConcurrent = pipes.DefaultShadowMaxConcurrent
+	}
+	return &shadowEvaluator{slots: make(chan struct{}, maxConcurrent)}
+}
+
+// goRun runs fn in the background when a slot is free and reports whether it started.
+func (s *shadowEvaluator) goRun(fn func()) bool {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.mu.Lock()
+		s.stats.Skipped++
+		s.mu.Unlock()
+		return false
+	}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.slots
+			s.wg.Done()
+		}()
+		fn()
+	}()
+	return true
+}
+
+func (s *shadowEvaluator) record(e *monitoring.ShadowEvalEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Evaluated++
+	if e.TokensSaved != 0 {
+		s.stats.Compressed++
+	}
+	s.stats.OriginalTokens += int64(e.OriginalTokens)
+	s.stats.CompressedTokens += int64(e.CompressedTokens)
+	s.stats.TokensSaved += int64(e.TokensSaved)
+	s.stats.EstimatedSavingsUSD += e.EstimatedSavingsUSD
+}
+
+// Stats returns the totals so far.
+func (s *shadowEvaluator) Stats() ShadowModeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.stats
+	if out.OriginalTokens > 0 {
+		out.TokenSavedPct = 100 * float64(out.TokensSaved) / float64(out.OriginalTokens)
+	}
+	return out
+}
+
+// wait blocks until background runs finish or ctx is done.
+func (s *shadowEvaluator) wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// processShadowMode runs the security pipes on pipeCtx, starts the
+// background evaluation and returns the body to forward: the request as
+// received, or as masked and scanned.
+func (g *Gateway) processShadowMode(body []byte, pipeCtx *PipelineContext, requestID string) []byte {
+	cfg, _, _, _ := g.router.snapshot()
+	g.router.secure(pipeCtx, cfg)
+	if pipeCtx.PIIError != nil || pipeCtx.PipeRejection != nil {
+		return body
+	}
+	forward := pipeCtx.OriginalRequest
+
+	shadow := NewPipelineContext(pipeCtx.Provider, pipeCtx.Adapter, forward, pipeCtx.OriginalPath)
+	shadow.RequestCtx = context.WithoutCancel(pipeCtx.RequestCtx)
+	shadow.RequestID = requestID
+	shadow.Model = pipeCtx.Model
+	shadow.TargetModel = pipeCtx.TargetModel
+	shadow.CapturedAuth = pipeCtx.CapturedAuth
+	shadow.ClientAgent = pipeCtx.ClientAgent
+	shadow.SessionTags = pipeCtx.SessionTags
+	shadow.Flags = pipeCtx.Flags
+	shadow.SessionID = pipeCtx.SessionID
+	shadow.ToolSessionID = pipeCtx.ToolSessionID
+	shadow.CostSessionID = pipeCtx.CostSessionID
+	shadow.securedBody = pipeCtx.securedBody // Security pipes already ran: not run again
+	if pipeCtx.ExpandedTools != nil {
+		shadow.ExpandedTools = make(map[string]bool, len(pipeCtx.ExpandedTools))
+		for name, v := range pipeCtx.ExpandedTools {
+			shadow.ExpandedTools[name] = v
+		}
+	}
+
+	logBodies := g.cfg().Pipes.Shadow.LogBodies
+	g.shadowMode.goRun(func() {
+		start := time.Now()
+		compressed, _, err := g.router.ProcessAll(shadow)
+		if err != nil || len(compressed) == 0 {
+			compressed = forward
+		}
+		entry := g.shadowEvalEntry(shadow, forward, compressed, logBodies)
+		entry.DurationMs = time.Since(start).Milliseconds()
+		g.shadowMode.record(&entry)
+		g.recordCustomPipes(shadow)
+		g.tracker.LogShadowEval(entry)
+		log.Debug().
+			Str("request_id", requestID).
+			Int("original_tokens", entry.OriginalTokens).
+			Int("compressed_tokens", entry.CompressedTokens).
+			Msg("shadow mode: evaluated compression")
+	})
+	return forward
+}
+
+// shadowEvalEntry describes one background run: forward is what was sent,
+// compressed what the pipes produced.
+func (g *Gateway) shadowEvalEntry(ctx *PipelineContext, forward, compressed []byte, logBodies bool) monitoring.ShadowEvalEntry {
+	original := g.tokens.CountRequest(forward, ctx.Model)
+	after := original
+	if string(compressed) != string(forward) {
+		after = g.tokens.CountRequest(compressed, ctx.Model)
+	}
+	entry := monitoring.ShadowEvalEntry{
+		Timestamp:         time.Now().UTC(),
+		RequestID:         ctx.RequestID,
+		SessionID:         ctx.CostSessionID,
+		Provider:          string(ctx.Provider),
+		Model:             ctx.Model,
+		OriginalTokens:    original,
+		CompressedTokens:  after,
+		TokensSaved:       original - after,
+		CompressionRatio:  tokenizer.CompressionRatio(original, after),
+		PipeFailed:        ctx.PipeFailed,
+		ToolsFiltered:     ctx.ToolsFiltered,
+		OriginalToolCount: ctx.OriginalToolCount,
+		KeptToolCount:     ctx.KeptToolCount,
+		CustomPipes:       ctx.CustomPipes,
+	}
+	if entry.TokensSaved > 0 {
+		entry.EstimatedSavingsUSD = costcontrol.CalculateCost(entry.TokensSaved, 0, costcontrol.GetModelPricing(ctx.Model))
+	}
+	for _, tc := range ctx.ToolOutputCompressions {
+		tool := monitoring.ShadowEvalTool{
+			ToolName:         tc.ToolName,
+			ToolCallID:       tc.ToolCallID,
+			Status:           tc.MappingStatus,
+			OriginalTokens:   tc.OriginalTokens,
+			CompressedTokens: tc.CompressedTokens,
+		}
+		if tc.MappingStatus == "compressed" || tc.MappingStatus == "cache_hit" {
+			tool.CompressedContent = tc.CompressedContent
+		}
+		entry.ToolOutputs = append(entry.ToolOutputs, tool)
+	}
+	if logBodies && json.Valid(compressed) {
+		entry.Body = json.RawMessage(compressed)
+	}
+	return entry
+}
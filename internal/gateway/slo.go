@@ -0,0 +1,155 @@
+// Pipe latency SLOs.
+//
+// A pipe with a pipes.slo entry runs under a per-request deadline set on its
+// request context, so in-flight external compression calls are cancelled and
+// the cut-off tool outputs pass through uncompressed. The pipe's p95 over the
+// last Window runs is checked after every run; when it exceeds the target the
+// pipe switches to its degrade_to pool (a local strategy) for RecoverAfter,
+// after which the primary strategy is tried again with a fresh window.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// sloGuard enforces one pipe's latency SLO.
+type sloGuard struct {
+	name     string
+	cfg      pipes.SLOConfig
+	degraded *Pool // local-strategy pool used while degraded (nil = report only)
+
+	mu         sync.Mutex
+	window     *monitoring.LatencyWindow // primary-strategy run latencies
+	state      string
+	degradedAt time.Time
+}
+
+func newSLOGuard(name string, cfg pipes.SLOConfig, degraded *Pool) *sloGuard {
+	if cfg.Window <= 0 {
+		cfg.Window = pipes.DefaultSLOWindow
+	}
+	if cfg.RecoverAfter <= 0 {
+		cfg.RecoverAfter = pipes.DefaultSLORecoverAfter
+	}
+	return &sloGuard{
+		name:     name,
+		cfg:      cfg,
+		degraded: degraded,
+		window:   monitoring.NewLatencyWindow(cfg.Window),
+		state:    monitoring.SLOStateOK,
+	}
+}
+
+// currentState returns the guard's state, ending a degraded period once RecoverAfter has passed.
+func (g *sloGuard) currentState() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.state == monitoring.SLOStateDegraded && time.Since(g.degradedAt) >= g.cfg.RecoverAfter {
+		g.state = monitoring.SLOStateOK
+		g.window.Reset()
+		log.Info().Str("pipe", g.name).Msg("slo: recovery period over, retrying primary strategy")
+	}
+	return g.state
+}
+
+// observe records a primary-strategy run and re-evaluates the p95 once the window is full.
+func (g *sloGuard) observe(elapsed time.Duration) {
+	if g.cfg.P95 <= 0 {
+		return
+	}
+	g.window.Record(elapsed)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.state == monitoring.SLOStateDegraded || g.window.Len() < g.cfg.Window {
+		return
+	}
+	p95 := g.window.Percentile(0.95)
+	switch {
+	case p95 <= g.cfg.P95:
+		g.state = monitoring.SLOStateOK
+	case g.degraded != nil:
+		g.state = monitoring.SLOStateDegraded
+		g.degradedAt = time.Now()
+		g.window.Reset()
+		log.Warn().
+			Str("pipe", g.name).
+			Dur("p95", p95).
+			Dur("target", g.cfg.P95).
+			Str("degrade_to", g.cfg.DegradeTo).
+			Dur("recover_after", g.cfg.RecoverAfter).
+			Msg("slo: p95 over target, degrading to local strategy")
+	default:
+		if g.state != monitoring.SLOStateViolating {
+			log.Warn().Str("pipe", g.name).Dur("p95", p95).Dur("target", g.cfg.P95).Msg("slo: p95 over target")
+		}
+		g.state = monitoring.SLOStateViolating
+	}
+}
+
+// run processes pctx on the primary or degraded pool under the SLO deadline.
+func (g *sloGuard) run(primary *Pool, pctx *pipes.PipeContext) ([]byte, monitoring.PipeSLO, error) {
+	pool := primary
+	if g.currentState() == monitoring.SLOStateDegraded && g.degraded != nil {
+		pool = g.degraded
+	}
+
+	orig := pctx.RequestCtx
+	parent := orig
+	if parent == nil {
+		parent = context.Background()
+	}
+	runCtx, cancel := parent, context.CancelFunc(func() {})
+	if g.cfg.Deadline > 0 {
+		runCtx, cancel = context.WithTimeout(parent, g.cfg.Deadline)
+	}
+	defer func() {
+		cancel()
+		pctx.RequestCtx = orig
+	}()
+	pctx.RequestCtx = runCtx
+
+	start := time.Now()
+	body, err := pool.process(pctx)
+	elapsed := time.Since(start)
+
+	exceeded := errors.Is(runCtx.Err(), context.DeadlineExceeded)
+	if exceeded {
+		log.Warn().
+			Str("pipe", g.name).
+			Dur("elapsed", elapsed).
+			Dur("deadline", g.cfg.Deadline).
+			Msg("slo: deadline exceeded, cut-off content passed through")
+	}
+	if pool == primary {
+		g.observe(elapsed)
+	}
+	return body, monitoring.PipeSLO{Pipe: g.name, State: g.currentState(), DeadlineExceeded: exceeded}, err
+}
+
+// recordSLO appends a pipe's SLO outcome; zero outcomes (no SLO configured) are ignored.
+func (ctx *PipelineContext) recordSLO(slo monitoring.PipeSLO) {
+	if slo.Pipe != "" {
+		ctx.PipeSLO = append(ctx.PipeSLO, slo)
+	}
+}
+
+// SLOStates returns the current SLO state of every pipe with an SLO configured.
+func (r *Router) SLOStates() map[string]string {
+	_, taPool, toPool, tdPool := r.snapshot()
+	states := make(map[string]string)
+	for _, pool := range []*Pool{taPool, toPool, tdPool} {
+		if pool != nil && pool.slo != nil {
+			states[pool.slo.name] = pool.slo.currentState()
+		}
+	}
+	return states
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// ctxPipe waits for delay or request cancellation; on cancellation it passes the input through.
+type ctxPipe struct {
+	fakePipe
+}
+
+func (c *ctxPipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	select {
+	case <-time.After(c.delay):
+		return append(append([]byte{}, ctx.OriginalRequest...), "|"+c.name...), nil
+	case <-ctx.RequestCtx.Done():
+		return ctx.OriginalRequest, nil
+	}
+}
+
+func sloPool(p pipes.Pipe, cfg pipes.SLOConfig, degraded pipes.Pipe) *Pool {
+	pool := newPool(1, func() pipes.Pipe { return p })
+	var dp *Pool
+	if degraded != nil {
+		dp = newPool(1, func() pipes.Pipe { return degraded })
+	}
+	pool.slo = newSLOGuard(pipes.PipeNameToolOutput, cfg, dp)
+	return pool
+}
+
+func TestSLO_DeadlinePassesOriginalThrough(t *testing.T) {
+	pool := sloPool(&ctxPipe{fakePipe{name: "slow", delay: time.Second}}, pipes.SLOConfig{Deadline: 20 * time.Millisecond}, nil)
+	ctx := newTestPipelineContext()
+
+	start := time.Now()
+	out := (&Router{}).runPipe(pool, ctx, []byte("body"), "tool_output")
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "deadline must cancel the run")
+	assert.Equal(t, "body", string(out))
+	require.Len(t, ctx.PipeSLO, 1)
+	assert.Equal(t, monitoring.PipeSLO{Pipe: "tool_output", State: monitoring.SLOStateOK, DeadlineExceeded: true}, ctx.PipeSLO[0])
+	assert.Nil(t, ctx.RequestCtx, "request context must be restored")
+}
+
+func TestSLO_DegradesAndRecovers(t *testing.T) {
+	cfg := pipes.SLOConfig{P95: 5 * time.Millisecond, Window: 3, DegradeTo: "simple", RecoverAfter: 50 * time.Millisecond}
+	pool := sloPool(&fakePipe{name: "primary", delay: 10 * time.Millisecond}, cfg, &fakePipe{name: "local"})
+
+	for i := 0; i < 3; i++ {
+		ctx := newTestPipelineContext()
+		assert.Equal(t, "body|primary", string((&Router{}).runPipe(pool, ctx, []byte("body"), "tool_output")))
+	}
+	assert.Equal(t, monitoring.SLOStateDegraded, pool.slo.currentState())
+
+	ctx := newTestPipelineContext()
+	assert.Equal(t, "body|local", string((&Router{}).runPipe(pool, ctx, []byte("body"), "tool_output")))
+	assert.Equal(t, monitoring.SLOStateDegraded, ctx.PipeSLO[0].State)
+
+	time.Sleep(60 * time.Millisecond)
+	ctx = newTestPipelineContext()
+	assert.Equal(t, "body|primary", string((&Router{}).runPipe(pool, ctx, []byte("body"), "tool_output")))
+	assert.Equal(t, monitoring.SLOStateOK, ctx.PipeSLO[0].State)
+}
+
+func TestSLO_ViolatingWithoutDegradeTarget(t *testing.T) {
+	cfg := pipes.SLOConfig{P95: time.Millisecond, Window: 2}
+	pool := sloPool(&fakePipe{name: "primary", delay: 5 * time.Millisecond}, cfg, nil)
+
+	for i := 0; i < 2; i++ {
+		(&Router{}).runPipe(pool, newTestPipelineContext(), []byte("body"), "tool_output")
+	}
+	assert.Equal(t, monitoring.SLOStateViolating, pool.slo.currentState())
+}
+
+func TestSLO_PoolWithoutSLORecordsNothing(t *testing.T) {
+	pool := newPool(1, func() pipes.Pipe { return &fakePipe{name: "to"} })
+	ctx := newTestPipelineContext()
+
+	(&Router{}).runPipe(pool, ctx, []byte("body"), "tool_output")
+
+	assert.Empty(t, ctx.PipeSLO)
+}
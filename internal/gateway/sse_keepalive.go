@@ -0,0 +1,226 @@
+// sse_keepalive.go keeps a streaming client's connection busy until the
+// upstream answers.
+//
+// Some models take a minute or more before the first byte, and proxies between
+// the client and the gateway drop connections that stay idle that long. With
+// server.sse_keepalive_interval set, sseKeepaliveWriter commits a 200
+// text/event-stream response at the first tick and writes an SSE comment
+// (": ping") every interval until the handler writes real output. SSE parsers
+// ignore comments. Once a ping has committed the status, an error response
+// from the handler is sent as a stream error event instead.
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// ssePing is the keepalive comment.
+var ssePing = []byte(": ping\n\n")
+
+// maxKeepaliveErrorBody caps an error body held for the stream error event.
+const maxKeepaliveErrorBody = 64 << 10
+
+// sseKeepaliveWriter wraps the client ResponseWriter of a streaming request.
+type sseKeepaliveWriter struct {
+	http.ResponseWriter
+	header   http.Header    // The handler's headers; copied to the client writer on commit
+	format   holdbackFormat // Shape of the stream error event
+	interval time.Duration
+	timeout  time.Duration // Deadline for one ping write
+
+	mu        sync.Mutex
+	live      bool // The handler has responded; pings stop
+	committed bool // Headers are on the client writer
+	pinged    bool // A ping committed a 200 before the handler responded
+	errStatus int  // Error status the handler set after a ping
+	errBody   bytes.Buffer
+	pings     int
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// startSSEKeepalive wraps w for a streaming request when keepalives are on and
+// the client reads the response as SSE. Returns nil otherwise. Call stop
+// before the handler returns.
+func (g *Gateway) startSSEKeepalive(w http.ResponseWriter, r *http.Request, adapter adapters.Adapter) *sseKeepaliveWriter {
+	srv := g.cfg().Server
+	if srv.SSEKeepaliveInterval <= 0 || !clientReadsSSE(r, adapter) {
+		return nil
+	}
+	format := holdbackOpenAIChat
+	if adapter.Provider() == adapters.ProviderAnthropic {
+		format = holdbackAnthropic
+	}
+	return newSSEKeepaliveWriter(w, srv.SSEKeepaliveInterval, srv.StreamWriteTimeout, format)
+}
+
+// clientReadsSSE reports whether the streamed response of r is SSE, where
+// comments are safe to inject.
+func clientReadsSSE(r *http.Request, adapter adapters.Adapter) bool {
+	switch adapter.Provider() {
+	case adapters.ProviderBedrock:
+		return false // Binary event stream
+	case adapters.ProviderGemini:
+		return r.URL.Query().Get("alt") == "sse" // A JSON array otherwise
+	case adapters.ProviderOllama:
+		return strings.HasPrefix(r.URL.Path, "/v1/") // Native /api/chat streams NDJSON
+	}
+	return true
+}
+
+func newSSEKeepaliveWriter(w http.ResponseWriter, interval, timeout time.Duration, format holdbackFormat) *sseKeepaliveWriter {
+	if timeout <= 0 {
+		timeout = config.DefaultStreamWriteTimeout
+	}
+	kw := &sseKeepaliveWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		format:         format,
+		interval:       interval,
+		timeout:        timeout,
+		quit:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go kw.run()
+	return kw
+}
+
+func (w *sseKeepaliveWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			return
+		case <-ticker.C:
+			if !w.ping() {
+				return
+			}
+		}
+	}
+}
+
+// ping writes one keepalive. Returns false once pings should stop.
+func (w *sseKeepaliveWriter) ping() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.live {
+		return false
+	}
+	if !w.committed {
+		// The handler's own headers may be mid-write; send only what SSE needs.
+		h := w.ResponseWriter.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Accel-Buffering", "no")
+		w.ResponseWriter.WriteHeader(http.StatusOK)
+		w.committed, w.pinged = true, true
+	}
+	rc := http.NewResponseController(w.ResponseWriter)
+	_ = rc.SetWriteDeadline(time.Now().Add(w.timeout))
+	_, err := w.ResponseWriter.Write(ssePing)
+	if err == nil {
+		err = rc.Flush()
+	}
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.pings++
+	return err == nil
+}
+
+// commit copies the handler's headers to the client writer. Called with mu held.
+func (w *sseKeepaliveWriter) commit() {
+	if w.committed {
+		return
+	}
+	h := w.ResponseWriter.Header()
+	clear(h)
+	for k, v := range w.header {
+		h[k] = v
+	}
+	w.committed = true
+}
+
+// Header returns the handler's headers. They reach the client unless a ping
+// already committed the response.
+func (w *sseKeepaliveWriter) Header() http.Header { return w.header }
+
+func (w *sseKeepaliveWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.pinged {
+		w.live = true
+		w.commit()
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !w.live {
+		w.live = true
+		if code >= 300 {
+			w.errStatus = code
+		}
+	}
+}
+
+func (w *sseKeepaliveWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.live = true
+	if !w.pinged {
+		w.commit()
+		return w.ResponseWriter.Write(b)
+	}
+	if w.errStatus != 0 {
+		if room := maxKeepaliveErrorBody - w.errBody.Len(); room > 0 {
+			w.errBody.Write(b[:min(len(b), room)])
+		}
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the wrapped writer so SSE chunks still reach the client immediately.
+func (w *sseKeepaliveWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.errStatus != 0 {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *sseKeepaliveWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// stop ends the pings and sends a held error response as a stream error event.
+func (w *sseKeepaliveWriter) stop() {
+	close(w.quit)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.live = true
+	if w.pings > 0 {
+		log.Debug().Int("pings", w.pings).Int("error_status", w.errStatus).Msg("sse keepalive: upstream answered")
+	}
+	if w.errStatus == 0 {
+		return
+	}
+	_, _ = w.ResponseWriter.Write(sseErrorEvent(w.format, w.errBody.Bytes()))
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+)
+
+// keepaliveServer serves handler behind an sseKeepaliveWriter with a 10ms interval.
+func keepaliveServer(t *testing.T, format holdbackFormat, handler func(w http.ResponseWriter)) *http.Response {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		kw := newSSEKeepaliveWriter(w, 10*time.Millisecond, time.Second, format)
+		defer kw.stop()
+		handler(kw)
+	}))
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func readAll(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestSSEKeepalive_PingsUntilUpstreamAnswers(t *testing.T) {
+	resp := keepaliveServer(t, holdbackAnthropic, func(w http.ResponseWriter) {
+		time.Sleep(80 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("event: message_start\ndata: {}\n\n"))
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body := readAll(t, resp)
+	assert.True(t, strings.HasPrefix(body, ": ping\n\n"), body)
+	assert.True(t, strings.HasSuffix(body, "event: message_start\ndata: {}\n\n"), body)
+}
+
+func TestSSEKeepalive_FastResponseIsUntouched(t *testing.T) {
+	resp := keepaliveServer(t, holdbackAnthropic, func(w http.ResponseWriter) {
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"slow down"}`))
+		time.Sleep(40 * time.Millisecond) // Past several ticks
+	})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "yes", resp.Header.Get("X-Upstream"))
+	assert.Equal(t, `{"error":"slow down"}`, readAll(t, resp))
+}
+
+func TestSSEKeepalive_LateErrorBecomesErrorEvent(t *testing.T) {
+	resp := keepaliveServer(t, holdbackAnthropic, func(w http.ResponseWriter) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"upstream request failed"}}`))
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the first ping committed the status")
+	body := readAll(t, resp)
+	assert.Contains(t, body, ": ping\n\n")
+	assert.True(t, strings.HasSuffix(body, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"upstream request failed\"}}\n\n"), body)
+}
+
+func TestClientReadsSSE(t *testing.T) {
+	req := func(target string) *http.Request { return httptest.NewRequest(http.MethodPost, target, nil) }
+	assert.True(t, clientReadsSSE(req("/v1/messages"), adapters.NewAnthropicAdapter()))
+	assert.True(t, clientReadsSSE(req("/v1/chat/completions"), adapters.NewOpenAIAdapter()))
+	assert.False(t, clientReadsSSE(req("/model/x/invoke-with-response-stream"), adapters.NewBedrockAdapter()))
+	assert.True(t, clientReadsSSE(req("/v1beta/models/g:streamGenerateContent?alt=sse"), adapters.NewGeminiAdapter()))
+	assert.False(t, clientReadsSSE(req("/v1beta/models/g:streamGenerateContent"), adapters.NewGeminiAdapter()))
+	assert.False(t, clientReadsSSE(req("/api/chat"), adapters.NewOllamaAdapter()))
+}
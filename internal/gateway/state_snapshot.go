@@ -0,0 +1,205 @@
+// state_snapshot.go - Export and restore of in-memory gateway state.
+//
+// GET /admin/state returns everything the gateway would lose on restart:
+// preemptive and tool discovery sessions, response chains, sticky auth
+// fallback, cost counters and the shadow store. POST /admin/state loads such
+// a snapshot into a running gateway, so a standby instance can take over or a
+// restarted laptop keeps its agent sessions. `context-gateway snapshot`
+// encrypts the snapshot at rest; these endpoints are loopback-only and speak
+// plain JSON.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// StateSnapshotVersion is the snapshot format this binary writes and reads.
+const StateSnapshotVersion = 1
+
+// maxStateSnapshotSize bounds POST /admin/state bodies.
+const maxStateSnapshotSize = 1 << 30 // 1GB
+
+// StateSnapshot is the serialized in-memory state of a gateway.
+type StateSnapshot struct {
+	Version        int       `json:"version"`
+	GatewayVersion string    `json:"gateway_version,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Preemptive     []preemptive.Session      `json:"preemptive_sessions,omitempty"`
+	ToolSessions   json.RawMessage           `json:"tool_sessions,omitempty"`
+	ResponseChains json.RawMessage           `json:"response_chains,omitempty"`
+	AssistantRuns  json.RawMessage           `json:"assistant_runs,omitempty"`
+	MessageBatches json.RawMessage           `json:"message_batches,omitempty"`
+	AuthFallback   json.RawMessage           `json:"auth_fallback,omitempty"`
+	Costs          *costcontrol.TrackerState `json:"costs,omitempty"`
+	Shadow         *store.Snapshot           `json:"shadow,omitempty"`
+}
+
+// StateRestoreResult counts what a restore loaded, per store.
+type StateRestoreResult struct {
+	PreemptiveSessions int `json:"preemptive_sessions"`
+	ToolSessions       int `json:"tool_sessions"`
+	ResponseChains     int `json:"response_chains"`
+	AssistantRuns      int `json:"assistant_runs"`
+	MessageBatches     int `json:"message_batches"`
+	AuthFallback       int `json:"auth_fallback"`
+	CostSessions       int `json:"cost_sessions"`
+	ShadowEntries      int `json:"shadow_entries"`
+}
+
+// ExportState captures the gateway's in-memory state.
+func (g *Gateway) ExportState() (*StateSnapshot, error) {
+	snap := &StateSnapshot{
+		Version:        StateSnapshotVersion,
+		GatewayVersion: g.version,
+		CreatedAt:      time.Now().UTC(),
+	}
+	var err error
+	if g.preemptive != nil {
+		snap.Preemptive = g.preemptive.ExportSessions()
+	}
+	if g.toolSessions != nil {
+		if snap.ToolSessions, err = g.toolSessions.sessions.Export(); err != nil {
+			return nil, fmt.Errorf("tool sessions: %w", err)
+		}
+	}
+	if g.responseChains != nil {
+		if snap.ResponseChains, err = g.responseChains.chains.Export(); err != nil {
+			return nil, fmt.Errorf("response chains: %w", err)
+		}
+	}
+	if g.assistantRuns != nil {
+		if snap.AssistantRuns, err = g.assistantRuns.runs.Export(); err != nil {
+			return nil, fmt.Errorf("assistant runs: %w", err)
+		}
+	}
+	if g.messageBatches != nil {
+		if snap.MessageBatches, err = g.messageBatches.batches.Export(); err != nil {
+			return nil, fmt.Errorf("message batches: %w", err)
+		}
+	}
+	if g.authMode != nil {
+		if snap.AuthFallback, err = g.authMode.sessions.Export(); err != nil {
+			return nil, fmt.Errorf("auth fallback: %w", err)
+		}
+	}
+	if g.costTracker != nil {
+		if snap.Costs, err = g.costTracker.Export(); err != nil {
+			return nil, fmt.Errorf("costs: %w", err)
+		}
+	}
+	if ms, ok := g.store.(*store.MemoryStore); ok {
+		snap.Shadow = ms.Export()
+	}
+	return snap, nil
+}
+
+// RestoreState loads a snapshot from ExportState. Restored entries replace
+// live ones with the same key; everything else is kept.
+func (g *Gateway) RestoreState(snap *StateSnapshot) (StateRestoreResult, error) {
+	var res StateRestoreResult
+	if snap.Version != StateSnapshotVersion {
+		return res, fmt.Errorf("unsupported snapshot version %d (this binary reads version %d)", snap.Version, StateSnapshotVersion)
+	}
+	var err error
+	if g.preemptive != nil {
+		res.PreemptiveSessions = g.preemptive.ImportSessions(snap.Preemptive)
+	}
+	if g.toolSessions != nil {
+		if res.ToolSessions, err = g.toolSessions.sessions.Import(snap.ToolSessions); err != nil {
+			return res, fmt.Errorf("tool sessions: %w", err)
+		}
+	}
+	if g.responseChains != nil {
+		if res.ResponseChains, err = g.responseChains.chains.Import(snap.ResponseChains); err != nil {
+			return res, fmt.Errorf("response chains: %w", err)
+		}
+	}
+	if g.assistantRuns != nil {
+		if res.AssistantRuns, err = g.assistantRuns.runs.Import(snap.AssistantRuns); err != nil {
+			return res, fmt.Errorf("assistant runs: %w", err)
+		}
+	}
+	if g.messageBatches != nil {
+		if res.MessageBatches, err = g.messageBatches.batches.Import(snap.MessageBatches); err != nil {
+			return res, fmt.Errorf("message batches: %w", err)
+		}
+	}
+	if g.authMode != nil {
+		if res.AuthFallback, err = g.authMode.sessions.Import(snap.AuthFallback); err != nil {
+			return res, fmt.Errorf("auth fallback: %w", err)
+		}
+	}
+	if g.costTracker != nil {
+		if res.CostSessions, err = g.costTracker.Import(snap.Costs); err != nil {
+			return res, fmt.Errorf("costs: %w", err)
+		}
+	}
+	if ms, ok := g.store.(*store.MemoryStore); ok {
+		res.ShadowEntries = ms.Import(snap.Shadow)
+	}
+	return res, nil
+}
+
+// handleAdminState serves GET /admin/state (export) and POST /admin/state (restore).
+func (g *Gateway) handleAdminState(w http.ResponseWriter, r *http.Request) {
+	if !g.adminAuthorized(r) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snap, err := g.ExportState()
+		if err != nil {
+			log.Error().Err(err).Msg("admin: state export failed")
+			g.writeError(w, "state export failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snap); err != nil {
+			log.Warn().Err(err).Msg("handleAdminState: failed to encode JSON response")
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxStateSnapshotSize)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			g.writeError(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+		var snap StateSnapshot
+		if err := json.Unmarshal(body, &snap); err != nil {
+			g.writeError(w, "invalid snapshot", http.StatusBadRequest)
+			return
+		}
+		res, err := g.RestoreState(&snap)
+		if err != nil {
+			g.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info().
+			Time("snapshot_created_at", snap.CreatedAt).
+			Int("preemptive_sessions", res.PreemptiveSessions).
+			Int("tool_sessions", res.ToolSessions).
+			Int("cost_sessions", res.CostSessions).
+			Int("shadow_entries", res.ShadowEntries).
+			Msg("admin: state restored")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			log.Warn().Err(err).Msg("handleAdminState: failed to encode JSON response")
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -1,14 +1,32 @@
 // Package gateway - stats.go exposes aggregated metrics as JSON.
 //
 // GET /stats returns combined savings, cost, and operational metrics.
+// GET /metrics serves operational, provider, savings and budget metrics in
+// Prometheus text format.
+// GET /stats/tools ranks tools by how much compression saved on their outputs.
 package gateway
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/fleet"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/notify"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/priority"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+	"github.com/compresr/context-gateway/internal/statedir"
+	"github.com/compresr/context-gateway/internal/store"
 )
 
 // StatsResponse is the JSON response for GET /stats.
@@ -21,8 +39,27 @@ type StatsResponse struct {
 		Compressions       int64 `json:"compressions"`
 		CacheHits          int64 `json:"cache_hits"`
 		CacheMisses        int64 `json:"cache_misses"`
+		CacheInvalidations int64 `json:"cache_invalidations"` // Pipe changes before a cache_control breakpoint
+		TelemetryDropped   int64 `json:"telemetry_dropped"`   // Telemetry lines dropped under disk backpressure
+
+		// Slow streaming clients (server.slow_client_policy)
+		SlowClientWriteTimeouts int64 `json:"slow_client_write_timeouts"` // Client writes that hit stream_write_timeout
+		SlowClientClosed        int64 `json:"slow_client_closed"`         // Streams closed on a full relay buffer
+		SlowClientDroppedEvents int64 `json:"slow_client_dropped_events"` // SSE events dropped on a full relay buffer
 	} `json:"gateway"`
 
+	Errors map[string]int64 `json:"errors"` // Failures by taxonomy code (upstream_timeout, budget_exceeded, ...)
+
+	UpstreamRetries map[string]int64 `json:"upstream_retries,omitempty"` // Retried upstream forwards by reason (status code or network)
+
+	StreamLatency monitoring.StreamLatencyStats `json:"stream_latency"` // TTFB/TTFT/total percentiles for streaming responses
+
+	PipeSLO map[string]string `json:"pipe_slo,omitempty"` // SLO state per pipe (ok | violating | degraded)
+
+	CustomPipes map[string]monitoring.CustomPipeStats `json:"custom_pipes,omitempty"` // Runs by pipes.custom name
+
+	PromptInjection *monitoring.PromptInjectionStats `json:"prompt_injection,omitempty"` // Set when pipes.injection is enabled
+
 	Savings struct {
 		TokensSaved      int     `json:"tokens_saved"`
 		TokenSavedPct    float64 `json:"token_saved_pct"`
@@ -31,11 +68,106 @@ type StatsResponse struct {
 		CostSavedUSD     float64 `json:"cost_saved_usd"`
 	} `json:"savings"`
 
+	// Proxied requests and savings for this run and summed over every run
+	// that shared the state directory (omitted when it is unavailable)
+	SelfMetrics *SelfMetricsReport `json:"self_metrics,omitempty"`
+
+	PassthroughCache struct {
+		Entries int   `json:"entries"`
+		Hits    int64 `json:"hits"`
+		Misses  int64 `json:"misses"`
+	} `json:"passthrough_cache"`
+
+	ResponseCache struct {
+		Entries int   `json:"entries"`
+		Hits    int64 `json:"hits"`
+		Misses  int64 `json:"misses"`
+	} `json:"response_cache"`
+
 	ExpandContext struct {
 		Total    int `json:"total"`
 		Found    int `json:"found"`
 		NotFound int `json:"not_found"`
 	} `json:"expand_context"`
+
+	Stores StoreSizes `json:"stores"`
+
+	// Fleet telemetry (omitted when telemetry_export / telemetry_collector are disabled)
+	TelemetryExport    *fleet.ShipperStats   `json:"telemetry_export,omitempty"`
+	TelemetryCollector *fleet.CollectorStats `json:"telemetry_collector,omitempty"`
+
+	// Per-class admission counters (omitted when priority classes are disabled)
+	Priority *priority.Stats `json:"priority,omitempty"`
+
+	// Webhook delivery counters (omitted when no notification webhook is configured)
+	Notifications *notify.Stats `json:"notifications,omitempty"`
+
+	// What the pipes would have saved (omitted unless pipes.mode is shadow)
+	ShadowMode *ShadowModeStats `json:"shadow_mode,omitempty"`
+}
+
+// SelfMetricsReport pairs this run's counters with the persisted lifetime totals.
+type SelfMetricsReport struct {
+	SinceStart statedir.SelfMetrics `json:"since_start"` // Starts, Since and UpdatedAt are zero
+	Lifetime   statedir.SelfMetrics `json:"lifetime"`
+}
+
+// StoreSizes reports entry counts for the gateway's in-memory stores.
+// Used by the soak harness to detect unbounded growth.
+type StoreSizes struct {
+	Shadow             store.Sizes         `json:"shadow"`
+	ShadowBytes        store.Occupancy     `json:"shadow_bytes"`     // Logical vs physical (gzipped) bytes
+	ShadowEvictions    store.EvictionStats `json:"shadow_evictions"` // Evictions and the expand misses they caused
+	ToolSessions       int                 `json:"tool_sessions"`
+	AuthFallback       int                 `json:"auth_fallback_sessions"`
+	CostSessions       int                 `json:"cost_sessions"`
+	PreemptiveSessions int                 `json:"preemptive_sessions"`
+	PassthroughCache   int                 `json:"passthrough_cache"`
+	ResponseCache      int                 `json:"response_cache"`
+	ResponseChains     int                 `json:"response_chains"`
+	AssistantRuns      int                 `json:"assistant_runs"`
+	MessageBatches     int                 `json:"message_batches"`
+}
+
+// storeSizes collects current entry counts from every in-memory store.
+func (g *Gateway) storeSizes() StoreSizes {
+	var sizes StoreSizes
+	switch st := g.store.(type) {
+	case *store.MemoryStore:
+		sizes.Shadow = st.Sizes()
+		sizes.ShadowBytes = st.Occupancy()
+		sizes.ShadowEvictions = st.EvictionStats()
+	case *store.SQLiteStore:
+		sizes.Shadow, _ = st.Sizes()
+	}
+	if g.toolSessions != nil {
+		sizes.ToolSessions = g.toolSessions.Len()
+	}
+	if g.authMode != nil {
+		sizes.AuthFallback = g.authMode.Len()
+	}
+	if g.costTracker != nil {
+		sizes.CostSessions = g.costTracker.SessionCount()
+	}
+	if g.preemptive != nil {
+		sizes.PreemptiveSessions = g.preemptive.SessionCount()
+	}
+	if g.passthroughCache != nil {
+		sizes.PassthroughCache = g.passthroughCache.size()
+	}
+	if g.responseCache != nil {
+		sizes.ResponseCache = g.responseCache.size()
+	}
+	if g.responseChains != nil {
+		sizes.ResponseChains = g.responseChains.Len()
+	}
+	if g.assistantRuns != nil {
+		sizes.AssistantRuns = g.assistantRuns.Len()
+	}
+	if g.messageBatches != nil {
+		sizes.MessageBatches = g.messageBatches.Len()
+	}
+	return sizes
 }
 
 var gatewayStartTime = time.Now()
@@ -59,6 +191,28 @@ func (g *Gateway) handleStats(w http.ResponseWriter, r *http.Request) {
 		resp.Gateway.Compressions = stats["compressions"]
 		resp.Gateway.CacheHits = stats["cache_hits"]
 		resp.Gateway.CacheMisses = stats["cache_misses"]
+		resp.Gateway.CacheInvalidations = stats["cache_invalidations"]
+		resp.Gateway.SlowClientWriteTimeouts = stats["slow_client_write_timeouts"]
+		resp.Gateway.SlowClientClosed = stats["slow_client_closed"]
+		resp.Gateway.SlowClientDroppedEvents = stats["slow_client_dropped_events"]
+	}
+	if g.tracker != nil {
+		resp.Gateway.TelemetryDropped = g.tracker.Dropped()
+	}
+	resp.Errors = map[string]int64{}
+	if g.metrics != nil {
+		resp.Errors = g.metrics.ErrorCounts()
+		if retries := g.metrics.UpstreamRetryCounts(); len(retries) > 0 {
+			resp.UpstreamRetries = retries
+		}
+		resp.StreamLatency = g.metrics.StreamLatency()
+		if custom := g.metrics.CustomPipeStats(); len(custom) > 0 {
+			resp.CustomPipes = custom
+		}
+		if g.cfg().Pipes.Injection.Enabled {
+			pi := g.metrics.PromptInjectionStats()
+			resp.PromptInjection = &pi
+		}
 	}
 
 	// Savings
@@ -71,6 +225,20 @@ func (g *Gateway) handleStats(w http.ResponseWriter, r *http.Request) {
 		resp.Savings.CostSavedUSD = report.CostSavedUSD
 	}
 
+	// Passthrough cache
+	if g.passthroughCache != nil {
+		resp.PassthroughCache.Entries = g.passthroughCache.size()
+		resp.PassthroughCache.Hits = g.passthroughCache.hits.Load()
+		resp.PassthroughCache.Misses = g.passthroughCache.misses.Load()
+	}
+
+	// Response cache pipe
+	if g.responseCache != nil {
+		resp.ResponseCache.Entries = g.responseCache.size()
+		resp.ResponseCache.Hits = g.responseCache.hits.Load()
+		resp.ResponseCache.Misses = g.responseCache.misses.Load()
+	}
+
 	// Expand context
 	if g.expandLog != nil {
 		summary := g.expandLog.Summary()
@@ -79,8 +247,317 @@ func (g *Gateway) handleStats(w http.ResponseWriter, r *http.Request) {
 		resp.ExpandContext.NotFound = summary.NotFound
 	}
 
+	if g.router != nil {
+		resp.PipeSLO = g.router.SLOStates()
+	}
+
+	resp.Stores = g.storeSizes()
+
+	if g.telemetryShipper != nil {
+		st := g.telemetryShipper.Stats()
+		resp.TelemetryExport = &st
+	}
+	if g.telemetryCollector != nil {
+		st := g.telemetryCollector.Stats()
+		resp.TelemetryCollector = &st
+	}
+	if g.scheduler != nil {
+		st := g.scheduler.Stats()
+		resp.Priority = &st
+	}
+	if g.selfMetrics != nil {
+		resp.SelfMetrics = &SelfMetricsReport{
+			SinceStart: g.selfMetrics.SinceStart(),
+			Lifetime:   g.selfMetrics.Lifetime(),
+		}
+	}
+	if g.notifier != nil {
+		st := g.notifier.Stats()
+		resp.Notifications = &st
+	}
+	if g.cfg().Pipes.IsShadow() {
+		st := g.shadowMode.Stats()
+		resp.ShadowMode = &st
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Warn().Err(err).Msg("handleStats: failed to encode JSON response")
 	}
 }
+
+// ToolStatsResponse is the JSON response for GET /stats/tools.
+type ToolStatsResponse struct {
+	Tools      []monitoring.ToolSavings `json:"tools"`       // Best net savings first
+	TotalTools int                      `json:"total_tools"` // Tools tracked, before ?limit
+}
+
+// handleToolStats returns the per-tool savings leaderboard.
+// Optional ?limit=N keeps the top N tools. Restricted to localhost, like /stats.
+func (g *Gateway) handleToolStats(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		g.writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tools := g.toolSavings.Leaderboard()
+	if tools == nil {
+		tools = []monitoring.ToolSavings{}
+	}
+	resp := ToolStatsResponse{Tools: tools, TotalTools: len(tools)}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			g.writeError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit < len(resp.Tools) {
+			resp.Tools = resp.Tools[:limit]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warn().Err(err).Msg("handleToolStats: failed to encode JSON response")
+	}
+}
+
+// handleMetrics serves operational metrics in the Prometheus text exposition format.
+// Restricted to localhost, like /stats.
+func (g *Gateway) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) {
+		g.writeError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if g.metrics == nil {
+		g.writeError(w, "metrics disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var b strings.Builder
+	stats := g.metrics.Stats()
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("context_gateway_requests_total", "Requests handled.", stats["requests"])
+	counter("context_gateway_requests_successful_total", "Requests that completed with status < 400.", stats["successes"])
+	counter("context_gateway_compressions_total", "Compression operations.", stats["compressions"])
+	counter("context_gateway_cache_invalidations_total", "Pipe changes before a cache_control breakpoint.", stats["cache_invalidations"])
+	counter("context_gateway_phantom_loops_total", "Phantom tool loop iterations (extra upstream calls for expand_context and tool search).", stats["phantom_loops"])
+	counter("context_gateway_phantom_loop_requests_total", "Requests that ran at least one phantom tool loop iteration.", stats["phantom_loop_requests"])
+
+	providers := g.metrics.ProviderStats()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString("# HELP context_gateway_provider_requests_total Requests by upstream provider and outcome.\n# TYPE context_gateway_provider_requests_total counter\n")
+	for _, name := range names {
+		p := providers[name]
+		fmt.Fprintf(&b, "context_gateway_provider_requests_total{provider=%q,outcome=\"success\"} %d\n", name, p.Requests-p.Failures)
+		fmt.Fprintf(&b, "context_gateway_provider_requests_total{provider=%q,outcome=\"failure\"} %d\n", name, p.Failures)
+	}
+	b.WriteString("# HELP context_gateway_provider_request_duration_seconds Request latency by upstream provider, from arrival to completion.\n# TYPE context_gateway_provider_request_duration_seconds histogram\n")
+	for _, name := range names {
+		h := providers[name].Latency
+		for i, le := range h.Bounds {
+			fmt.Fprintf(&b, "context_gateway_provider_request_duration_seconds_bucket{provider=%q,le=\"%g\"} %d\n", name, le, h.Counts[i])
+		}
+		fmt.Fprintf(&b, "context_gateway_provider_request_duration_seconds_bucket{provider=%q,le=\"+Inf\"} %d\n", name, h.Count)
+		fmt.Fprintf(&b, "context_gateway_provider_request_duration_seconds_sum{provider=%q} %g\n", name, h.Sum)
+		fmt.Fprintf(&b, "context_gateway_provider_request_duration_seconds_count{provider=%q} %d\n", name, h.Count)
+	}
+
+	b.WriteString("# HELP context_gateway_slow_client_events_total Slow streaming client events: write timeouts, streams closed and SSE events dropped on a full relay buffer.\n# TYPE context_gateway_slow_client_events_total counter\n")
+	for _, ev := range []struct {
+		name string
+		stat string
+	}{
+		{string(monitoring.SlowClientWriteTimeout), "slow_client_write_timeouts"},
+		{string(monitoring.SlowClientClosed), "slow_client_closed"},
+		{string(monitoring.SlowClientDropped), "slow_client_dropped_events"},
+	} {
+		fmt.Fprintf(&b, "context_gateway_slow_client_events_total{event=%q} %d\n", ev.name, stats[ev.stat])
+	}
+
+	custom := g.metrics.CustomPipeStats()
+	if len(custom) > 0 {
+		pipeNames := make([]string, 0, len(custom))
+		for name := range custom {
+			pipeNames = append(pipeNames, name)
+		}
+		sort.Strings(pipeNames)
+		outcomes := []string{monitoring.CustomPipeUnchanged, monitoring.CustomPipeModified, monitoring.CustomPipeFailed, monitoring.CustomPipeRejected}
+		b.WriteString("# HELP context_gateway_custom_pipe_runs_total Custom pipe runs by pipe and outcome.\n# TYPE context_gateway_custom_pipe_runs_total counter\n")
+		for _, name := range pipeNames {
+			for _, outcome := range outcomes {
+				fmt.Fprintf(&b, "context_gateway_custom_pipe_runs_total{pipe=%q,outcome=%q} %d\n", name, outcome, custom[name].Outcomes[outcome])
+			}
+		}
+		b.WriteString("# HELP context_gateway_custom_pipe_duration_seconds Custom pipe run latency.\n# TYPE context_gateway_custom_pipe_duration_seconds histogram\n")
+		for _, name := range pipeNames {
+			h := custom[name].Latency
+			for i, le := range h.Bounds {
+				fmt.Fprintf(&b, "context_gateway_custom_pipe_duration_seconds_bucket{pipe=%q,le=\"%g\"} %d\n", name, le, h.Counts[i])
+			}
+			fmt.Fprintf(&b, "context_gateway_custom_pipe_duration_seconds_bucket{pipe=%q,le=\"+Inf\"} %d\n", name, h.Count)
+			fmt.Fprintf(&b, "context_gateway_custom_pipe_duration_seconds_sum{pipe=%q} %g\n", name, h.Sum)
+			fmt.Fprintf(&b, "context_gateway_custom_pipe_duration_seconds_count{pipe=%q} %d\n", name, h.Count)
+		}
+	}
+
+	if g.cfg().Pipes.Injection.Enabled {
+		pi := g.metrics.PromptInjectionStats()
+		b.WriteString("# HELP context_gateway_prompt_injection_requests_total Requests with suspected prompt injection in tool outputs, by action.\n# TYPE context_gateway_prompt_injection_requests_total counter\n")
+		for _, action := range []string{pipes.InjectionActionFlag, pipes.InjectionActionStrip, pipes.InjectionActionBlock} {
+			fmt.Fprintf(&b, "context_gateway_prompt_injection_requests_total{action=%q} %d\n", action, pi.Requests[action])
+		}
+		b.WriteString("# HELP context_gateway_prompt_injection_detections_total Suspected prompt injections in tool outputs, by detector.\n# TYPE context_gateway_prompt_injection_detections_total counter\n")
+		for _, detector := range append(append([]string(nil), pipes.BuiltinInjectionDetectors...), pipes.InjectionDetectorPattern) {
+			fmt.Fprintf(&b, "context_gateway_prompt_injection_detections_total{detector=%q} %d\n", detector, pi.Detections[detector])
+		}
+	}
+
+	errors := g.metrics.ErrorCounts()
+	codes := make([]string, 0, len(errors))
+	for code := range errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	b.WriteString("# HELP context_gateway_errors_total Failures by error code.\n# TYPE context_gateway_errors_total counter\n")
+	for _, code := range codes {
+		fmt.Fprintf(&b, "context_gateway_errors_total{code=%q} %d\n", code, errors[code])
+	}
+
+	retries := g.metrics.UpstreamRetryCounts()
+	reasons := make([]string, 0, len(retries))
+	for reason := range retries {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	b.WriteString("# HELP context_gateway_upstream_retries_total Upstream forwards retried by upstream_retry, by reason (status code or network).\n# TYPE context_gateway_upstream_retries_total counter\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "context_gateway_upstream_retries_total{reason=%q} %d\n", reason, retries[reason])
+	}
+
+	latency := g.metrics.StreamLatency()
+	summary := func(name, help string, s monitoring.LatencySummary) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s summary\n", name, help, name)
+		fmt.Fprintf(&b, "%s{quantile=\"0.5\"} %g\n", name, s.P50Ms/1000)
+		fmt.Fprintf(&b, "%s{quantile=\"0.9\"} %g\n", name, s.P90Ms/1000)
+		fmt.Fprintf(&b, "%s{quantile=\"0.99\"} %g\n", name, s.P99Ms/1000)
+		fmt.Fprintf(&b, "%s_sum %g\n%s_count %d\n", name, s.SumMs/1000, name, s.Count)
+	}
+	summary("context_gateway_stream_first_byte_seconds", "Time from request arrival to first byte relayed to the client.", latency.FirstByte)
+	summary("context_gateway_stream_first_token_seconds", "Time from request arrival to first content delta relayed to the client.", latency.FirstToken)
+	summary("context_gateway_stream_duration_seconds", "Time from request arrival to end of the relayed stream.", latency.Total)
+
+	if g.savings != nil {
+		report := g.savings.GetReport()
+		counter("context_gateway_tokens_original_total", "Input tokens before compression.", int64(report.OriginalTokens))
+		counter("context_gateway_tokens_compressed_total", "Input tokens after compression.", int64(report.CompressedTokens))
+		counter("context_gateway_tokens_saved_total", "Input tokens removed by all pipes.", int64(report.TotalTokensSaved))
+		fmt.Fprintf(&b, "# HELP context_gateway_cost_saved_usd_total Estimated spend avoided by compression, USD.\n# TYPE context_gateway_cost_saved_usd_total counter\ncontext_gateway_cost_saved_usd_total %g\n", report.CostSavedUSD)
+	}
+
+	if g.costTracker != nil {
+		cc := g.costTracker.Config()
+		gauge := func(name, help string, v float64) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+		}
+		enforcing := 0.0
+		if cc.Enabled && cc.Mode != costcontrol.ModeSimulate {
+			enforcing = 1
+		}
+		overCap := 0
+		if cc.SessionCap > 0 || cc.SessionEgressCap > 0 {
+			for _, s := range g.costTracker.AllSessions() {
+				if (s.Cap > 0 && s.Cost >= s.Cap) || (s.EgressCap > 0 && s.EgressBytes >= s.EgressCap) {
+					overCap++
+				}
+			}
+		}
+		gauge("context_gateway_budget_enforced", "1 when cost control rejects requests over a cap, 0 when disabled or simulating.", enforcing)
+		gauge("context_gateway_budget_global_spend_usd", "Spend recorded across all sessions, USD.", g.costTracker.GetGlobalCost())
+		gauge("context_gateway_budget_global_cap_usd", "Global spend cap, USD (0 = unlimited).", cc.GlobalCap)
+		gauge("context_gateway_budget_session_cap_usd", "Per-session spend cap, USD (0 = unlimited).", cc.SessionCap)
+		gauge("context_gateway_budget_daily_egress_bytes", "Request bytes forwarded upstream on the current UTC day.", float64(g.costTracker.GetDailyEgress()))
+		gauge("context_gateway_budget_daily_egress_cap_bytes", "Daily egress cap in bytes (0 = unlimited).", float64(cc.DailyEgressCap))
+		gauge("context_gateway_budget_sessions", "Sessions tracked by cost control.", float64(g.costTracker.SessionCount()))
+		gauge("context_gateway_budget_sessions_over_cap", "Sessions at or over their spend or egress cap.", float64(overCap))
+	}
+
+	if ms, ok := g.store.(*store.MemoryStore); ok {
+		occ := ms.Occupancy()
+		b.WriteString("# HELP context_gateway_shadow_store_bytes Bytes held by the shadow store; logical is before gzip, physical after.\n# TYPE context_gateway_shadow_store_bytes gauge\n")
+		for _, c := range []struct {
+			name  string
+			usage store.ByteUsage
+		}{{"original", occ.Original}, {"compressed", occ.Compressed}} {
+			fmt.Fprintf(&b, "context_gateway_shadow_store_bytes{cache=%q,kind=\"logical\"} %d\n", c.name, c.usage.LogicalBytes)
+			fmt.Fprintf(&b, "context_gateway_shadow_store_bytes{cache=%q,kind=\"physical\"} %d\n", c.name, c.usage.PhysicalBytes)
+		}
+
+		ev := ms.EvictionStats()
+		b.WriteString("# HELP context_gateway_shadow_store_evictions_total Entries removed from the shadow store; reason is size (caps) or ttl.\n# TYPE context_gateway_shadow_store_evictions_total counter\n")
+		fmt.Fprintf(&b, "context_gateway_shadow_store_evictions_total{cache=\"original\",reason=\"size\"} %d\n", ev.OriginalEvicted)
+		fmt.Fprintf(&b, "context_gateway_shadow_store_evictions_total{cache=\"original\",reason=\"ttl\"} %d\n", ev.OriginalExpired)
+		fmt.Fprintf(&b, "context_gateway_shadow_store_evictions_total{cache=\"compressed\",reason=\"size\"} %d\n", ev.CompressedEvicted)
+		b.WriteString("# HELP context_gateway_expand_misses_total expand_context calls whose ID was not in the shadow store, by cause.\n# TYPE context_gateway_expand_misses_total counter\n")
+		for _, m := range []struct {
+			reason string
+			n      int64
+		}{{store.MissEvicted, ev.MissEvicted}, {store.MissExpired, ev.MissExpired}, {store.MissUnknown, ev.MissUnknown}} {
+			fmt.Fprintf(&b, "context_gateway_expand_misses_total{reason=%q} %d\n", m.reason, m.n)
+		}
+	}
+
+	if g.sessionGC != nil {
+		gcStats := g.sessionGC.Stats()
+		labeled := func(name, help, kind string, value func(sessionstore.StoreStats) int64) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+			for _, s := range gcStats {
+				fmt.Fprintf(&b, "%s{store=%q} %d\n", name, s.Name, value(s))
+			}
+		}
+		labeled("context_gateway_sessions", "Live sessions by store.", "gauge",
+			func(s sessionstore.StoreStats) int64 { return int64(s.Sessions) })
+		labeled("context_gateway_sessions_evicted_total", "Sessions removed by the session GC after their idle TTL.", "counter",
+			func(s sessionstore.StoreStats) int64 { return s.Evicted })
+		labeled("context_gateway_sessions_force_expired_total", "Sessions removed via DELETE /admin/sessions/{id}.", "counter",
+			func(s sessionstore.StoreStats) int64 { return s.ForceExpired })
+	}
+
+	if g.scheduler != nil {
+		ps := g.scheduler.Stats()
+		labeled := func(name, help, kind string, value func(priority.ClassStats) int64) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+			for _, cl := range priority.Classes {
+				fmt.Fprintf(&b, "%s{class=%q} %d\n", name, cl, value(ps.Classes[cl.String()]))
+			}
+		}
+		labeled("context_gateway_priority_admitted_total", "Requests admitted by priority class.", "counter",
+			func(s priority.ClassStats) int64 { return s.Admitted })
+		labeled("context_gateway_priority_queued_total", "Admitted requests that waited for a slot, by priority class.", "counter",
+			func(s priority.ClassStats) int64 { return s.Queued })
+		labeled("context_gateway_priority_in_flight", "Requests holding a slot, by priority class.", "gauge",
+			func(s priority.ClassStats) int64 { return int64(s.InFlight) })
+		labeled("context_gateway_priority_waiting", "Requests queued for a slot, by priority class.", "gauge",
+			func(s priority.ClassStats) int64 { return int64(s.Waiting) })
+		b.WriteString("# HELP context_gateway_priority_rejected_total Requests rejected by priority class and reason.\n# TYPE context_gateway_priority_rejected_total counter\n")
+		for _, cl := range priority.Classes {
+			for _, reason := range []string{priority.ReasonBudget, priority.ReasonQueueFull, priority.ReasonQueueTimeout, priority.ReasonCanceled} {
+				fmt.Fprintf(&b, "context_gateway_priority_rejected_total{class=%q,reason=%q} %d\n", cl, reason, ps.Classes[cl.String()].Rejected[reason])
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = io.WriteString(w, b.String())
+}
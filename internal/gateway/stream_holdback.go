@@ -0,0 +1,274 @@
+// stream_holdback.go - Optimistic relay for streams that may carry phantom tool calls.
+//
+// Phantom tool calls (expand_context, gateway_search_tools, direct calls to
+// deferred tool stubs) must be intercepted before the client sees them.
+// Buffering the whole response for that delays the first token until the
+// model has finished. With server.stream_interception: optimistic (the
+// default) the gateway relays SSE events as they arrive and starts holding
+// back only at the first tool call: an Anthropic tool_use content block or an
+// OpenAI tool_calls delta. Tool calls end a turn, so text and thinking reach
+// the client as fast as without the gateway.
+//
+// At the end of the stream the held-back events are released when they hold
+// no phantom call. Otherwise the gateway answers the call and splices the
+// follow-up response onto what the client already has (sseContinuation).
+//
+// Streams whose format has no continuation splice (Responses API, Gemini,
+// Bedrock), error responses, and requests sent with
+// X-Gateway-Stream-Interception: buffered are buffered whole as before.
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// HeaderStreamInterception overrides server.stream_interception for one
+// request: optimistic or buffered.
+const HeaderStreamInterception = "X-Gateway-Stream-Interception"
+
+// holdbackFormat is the SSE dialect a streamHoldback understands.
+type holdbackFormat int
+
+const (
+	holdbackNone       holdbackFormat = iota // Buffer the whole response
+	holdbackAnthropic                        // Anthropic Messages events
+	holdbackOpenAIChat                       // OpenAI Chat Completions chunks
+)
+
+// streamHoldbackFormat returns the format for optimistic relaying of a
+// streamed response to body, or holdbackNone when it must be buffered whole.
+func (g *Gateway) streamHoldbackFormat(r *http.Request, adapter adapters.Adapter, body []byte) holdbackFormat {
+	mode := g.cfg().Server.StreamInterception
+	if v := strings.ToLower(strings.TrimSpace(r.Header.Get(HeaderStreamInterception))); v != "" {
+		mode = v
+	}
+	if mode == config.StreamInterceptionBuffered {
+		return holdbackNone
+	}
+	switch adapter.Provider() {
+	case adapters.ProviderAnthropic:
+		return holdbackAnthropic
+	case adapters.ProviderGemini, adapters.ProviderBedrock:
+		return holdbackNone
+	}
+	if isResponsesAPI(body) {
+		return holdbackNone
+	}
+	return holdbackOpenAIChat
+}
+
+// streamHoldback relays complete SSE events to the client until the first
+// tool call, then keeps the rest in held.
+type streamHoldback struct {
+	format holdbackFormat
+	open   func() *streamRelay // Writes response headers and starts the relay
+
+	relay   *streamRelay // nil until the first event is released
+	pending []byte       // Incomplete event
+	holding bool
+	held    [][]byte
+	heldLen int
+	blocks  int // Anthropic content blocks released
+}
+
+func newStreamHoldback(format holdbackFormat, open func() *streamRelay) *streamHoldback {
+	return &streamHoldback{format: format, open: open}
+}
+
+// feed processes one upstream chunk. It fails once the client is gone.
+func (hb *streamHoldback) feed(chunk []byte) error {
+	hb.pending = append(hb.pending, chunk...)
+	for {
+		end := sseEventEnd(hb.pending)
+		if end < 0 {
+			break
+		}
+		event := bytes.Clone(hb.pending[:end])
+		hb.pending = hb.pending[end:]
+		if !hb.holding && hb.startsToolCall(event) {
+			hb.holding = true
+		}
+		if hb.holding {
+			hb.held = append(hb.held, event)
+			hb.heldLen += len(event)
+			continue
+		}
+		if err := hb.release(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hb *streamHoldback) release(event []byte) error {
+	if hb.relay == nil {
+		hb.relay = hb.open()
+	}
+	if hb.format == holdbackAnthropic && gjson.GetBytes(sseEventData(event), "type").String() == "content_block_start" {
+		hb.blocks++
+	}
+	_, err := hb.relay.Write(event)
+	return err
+}
+
+// startsToolCall reports whether event opens a client tool call.
+func (hb *streamHoldback) startsToolCall(event []byte) bool {
+	data := sseEventData(event)
+	switch hb.format {
+	case holdbackAnthropic:
+		return gjson.GetBytes(data, "type").String() == "content_block_start" &&
+			gjson.GetBytes(data, "content_block.type").String() == "tool_use"
+	case holdbackOpenAIChat:
+		found := false
+		gjson.GetBytes(data, "choices").ForEach(func(_, choice gjson.Result) bool {
+			found = len(choice.Get("delta.tool_calls").Array()) > 0
+			return !found
+		})
+		return found
+	}
+	return false
+}
+
+// released reports whether part of the response already reached the client.
+// Headers and status are then committed.
+func (hb *streamHoldback) released() bool {
+	return hb.relay != nil
+}
+
+// heldBytes returns the unreleased rest of the stream: the held-back events
+// and an unterminated trailing event.
+func (hb *streamHoldback) heldBytes() [][]byte {
+	if len(hb.pending) > 0 {
+		return append(hb.held, hb.pending)
+	}
+	return hb.held
+}
+
+// finish waits until released events are written. Everything written to the
+// client afterwards bypasses the relay.
+func (hb *streamHoldback) finish() {
+	if hb.relay != nil {
+		hb.relay.close()
+	}
+}
+
+// continuation returns the rewriter for a follow-up response that continues
+// the released part of this one.
+func (hb *streamHoldback) continuation() *sseContinuation {
+	return &sseContinuation{format: hb.format, offset: hb.blocks}
+}
+
+// sseContinuation rewrites a follow-up stream so it reads as the rest of a
+// response the client already started receiving. For Anthropic the follow-up's
+// message_start is dropped and content block indices move past the blocks
+// already sent. OpenAI chat chunks carry no block indices and pass unchanged.
+type sseContinuation struct {
+	format  holdbackFormat
+	offset  int
+	pending []byte
+}
+
+// rewrite returns the rewritten complete events in p. A trailing partial
+// event is kept for the next call.
+func (c *sseContinuation) rewrite(p []byte) []byte {
+	if c.format != holdbackAnthropic {
+		return p
+	}
+	c.pending = append(c.pending, p...)
+	var out []byte
+	for {
+		end := sseEventEnd(c.pending)
+		if end < 0 {
+			break
+		}
+		out = append(out, c.rewriteEvent(c.pending[:end])...)
+		c.pending = c.pending[end:]
+	}
+	return out
+}
+
+// flush returns a trailing unterminated event, rewritten.
+func (c *sseContinuation) flush() []byte {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	out := c.rewriteEvent(append(c.pending, '\n', '\n'))
+	c.pending = nil
+	return out
+}
+
+func (c *sseContinuation) rewriteEvent(event []byte) []byte {
+	data := sseEventData(event)
+	switch gjson.GetBytes(data, "type").String() {
+	case "message_start":
+		return nil
+	case "content_block_start", "content_block_delta", "content_block_stop":
+		idx := gjson.GetBytes(data, "index")
+		if !idx.Exists() || c.offset == 0 {
+			return event
+		}
+		shifted, err := sjson.SetBytes(data, "index", idx.Int()+int64(c.offset))
+		if err != nil {
+			return event
+		}
+		return formatSSEEvent(sseEventName(event), shifted)
+	}
+	return event
+}
+
+// sseEventData returns the joined data lines of one SSE event.
+func sseEventData(event []byte) []byte {
+	var data []byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if v, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(v, []byte(" "))...)
+		}
+	}
+	return data
+}
+
+// sseEventName returns the event field of one SSE event.
+func sseEventName(event []byte) string {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if v, ok := bytes.CutPrefix(bytes.TrimSuffix(line, []byte("\r")), []byte("event:")); ok {
+			return string(bytes.TrimSpace(v))
+		}
+	}
+	return ""
+}
+
+func formatSSEEvent(name string, data []byte) []byte {
+	var b bytes.Buffer
+	if name != "" {
+		b.WriteString("event: " + name + "\n")
+	}
+	b.WriteString("data: ")
+	b.Write(data)
+	b.WriteString("\n\n")
+	return b.Bytes()
+}
+
+// sseErrorEvent turns an error response body into a stream error event, for
+// failures after part of a stream was sent and the status is committed.
+func sseErrorEvent(format holdbackFormat, body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if !gjson.ValidBytes(body) {
+		body, _ = sjson.SetBytes([]byte(`{"type":"error","error":{"type":"api_error"}}`), "error.message", string(body))
+	}
+	if format == holdbackAnthropic {
+		return formatSSEEvent("error", body)
+	}
+	return formatSSEEvent("", body)
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+const (
+	holdbackTextEvents = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Reading.\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"
+	holdbackToolEvents = "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"expand_context\",\"input\":{}}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n"
+)
+
+func newTestHoldback(format holdbackFormat, rec *httptest.ResponseRecorder) *streamHoldback {
+	return newStreamHoldback(format, func() *streamRelay {
+		return newStreamRelay(rec, config.ServerConfig{}, nil)
+	})
+}
+
+func TestStreamHoldback_RelaysUntilToolCall(t *testing.T) {
+	rec := httptest.NewRecorder()
+	hb := newTestHoldback(holdbackAnthropic, rec)
+
+	// Split mid-event to check that only complete events are relayed.
+	stream := holdbackTextEvents + holdbackToolEvents
+	for _, chunk := range []string{stream[:37], stream[37:200], stream[200:]} {
+		require.NoError(t, hb.feed([]byte(chunk)))
+	}
+	hb.finish()
+
+	assert.True(t, hb.released())
+	assert.Equal(t, holdbackTextEvents, rec.Body.String())
+	assert.Equal(t, holdbackToolEvents, string(bytes.Join(hb.heldBytes(), nil)))
+	assert.Equal(t, 1, hb.blocks)
+}
+
+func TestStreamHoldback_ToolCallFirstHoldsEverything(t *testing.T) {
+	rec := httptest.NewRecorder()
+	hb := newTestHoldback(holdbackAnthropic, rec)
+	require.NoError(t, hb.feed([]byte(holdbackToolEvents)))
+	hb.finish()
+
+	assert.False(t, hb.released(), "headers stay uncommitted until something is relayed")
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, holdbackToolEvents, string(bytes.Join(hb.heldBytes(), nil)))
+}
+
+func TestStreamHoldback_OpenAIToolCallsDelta(t *testing.T) {
+	text := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"
+	tool := "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"name\":\"expand_context\"}}]}}]}\n\n"
+	rec := httptest.NewRecorder()
+	hb := newTestHoldback(holdbackOpenAIChat, rec)
+	require.NoError(t, hb.feed([]byte(text+tool+"data: [DONE]\n\n")))
+	hb.finish()
+
+	assert.Equal(t, text, rec.Body.String())
+	assert.Equal(t, tool+"data: [DONE]\n\n", string(bytes.Join(hb.heldBytes(), nil)))
+}
+
+func TestSSEContinuation_ShiftsBlockIndices(t *testing.T) {
+	cont := (&streamHoldback{format: holdbackAnthropic, blocks: 2}).continuation()
+	retry := holdbackTextEvents + "event: message_stop\ndata: {\"type\":\"message_stop\"}"
+
+	out := string(cont.rewrite([]byte(retry[:90]))) + string(cont.rewrite([]byte(retry[90:]))) + string(cont.flush())
+
+	assert.NotContains(t, out, "message_start")
+	assert.Equal(t, 3, strings.Count(out, `"index":2`))
+	assert.NotContains(t, out, `"index":0`)
+	assert.True(t, strings.HasSuffix(out, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+}
+
+func TestSSEErrorEvent_WrapsPlainBody(t *testing.T) {
+	assert.Equal(t, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"bad gateway\"}}\n\n",
+		string(sseErrorEvent(holdbackAnthropic, []byte("bad gateway\n"))))
+	assert.Equal(t, "data: {\"error\":{\"message\":\"x\"}}\n\n",
+		string(sseErrorEvent(holdbackOpenAIChat, []byte(`{"error":{"message":"x"}}`))))
+}
@@ -0,0 +1,237 @@
+// stream_relay.go - Backpressure-aware relay for streamed responses.
+//
+// Writing each upstream chunk to the client before reading the next one lets a
+// stalled client hold the upstream read, and the upstream connection, until
+// the server's write timeout. streamRelay decouples the two: the handler keeps
+// reading upstream (and parsing usage) while a writer goroutine drains a
+// bounded buffer to the client, each write under its own deadline
+// (server.stream_write_timeout). When the buffer (server.stream_buffer_bytes)
+// is full, server.slow_client_policy decides: close ends the client's stream,
+// which also aborts the upstream request; drop discards whole SSE events until
+// the client catches up, so usage is still read to the end. Slow-client events
+// are counted in /stats and /metrics.
+//
+// Responses that were buffered whole (phantom tool filtering, expand_context
+// detection) are already in memory, bounded by MaxStreamBufferSize; they are
+// written synchronously through a clientWriter, which applies the same
+// per-write deadline.
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// errSlowClient ends a stream whose client fell a full relay buffer behind.
+var errSlowClient = errors.New("client too slow: stream relay buffer full")
+
+// streamRelay writes to the client from its own goroutine. Write never blocks
+// on the client. close must be called before the handler returns.
+type streamRelay struct {
+	cw    *clientWriter
+	limit int
+	drop  bool
+
+	mu      sync.Mutex
+	ready   *sync.Cond
+	queue   [][]byte
+	queued  int    // Bytes queued or being written
+	partial []byte // Unterminated SSE event (drop policy)
+	err     error  // Sticky: client write failed or stream closed as too slow
+	closing bool
+	dropped int
+	done    chan struct{}
+}
+
+// newStreamRelay starts relaying to w with srv's slow-client settings.
+func newStreamRelay(w http.ResponseWriter, srv config.ServerConfig, metrics *monitoring.MetricsCollector) *streamRelay {
+	rl := &streamRelay{
+		cw:    newClientWriter(w, srv, metrics),
+		limit: srv.StreamBufferBytes,
+		drop:  srv.SlowClientPolicy == config.SlowClientPolicyDrop,
+		done:  make(chan struct{}),
+	}
+	if rl.limit <= 0 {
+		rl.limit = config.DefaultStreamBufferBytes
+	}
+	rl.ready = sync.NewCond(&rl.mu)
+	go rl.run()
+	return rl
+}
+
+// Write queues p for the client. It fails once the client write failed or,
+// under the close policy, when p doesn't fit in the buffer.
+func (rl *streamRelay) Write(p []byte) (int, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.err != nil {
+		return 0, rl.err
+	}
+	if !rl.drop {
+		if rl.queued > 0 && rl.queued+len(p) > rl.limit {
+			rl.err = errSlowClient
+			rl.cw.count(monitoring.SlowClientClosed)
+			rl.ready.Signal()
+			return 0, rl.err
+		}
+		rl.enqueue(p)
+		return len(p), nil
+	}
+
+	// Drop policy: only whole events are forwarded or dropped, so the client
+	// never sees half an event. An unterminated run longer than the buffer is
+	// treated as one event to keep memory bounded.
+	rl.partial = append(rl.partial, p...)
+	for {
+		end := sseEventEnd(rl.partial)
+		if end < 0 {
+			if len(rl.partial) <= rl.limit {
+				break
+			}
+			end = len(rl.partial)
+		}
+		if rl.queued > 0 && rl.queued+end > rl.limit {
+			rl.dropped++
+			rl.cw.count(monitoring.SlowClientDropped)
+		} else {
+			rl.enqueue(rl.partial[:end])
+		}
+		rl.partial = append(rl.partial[:0], rl.partial[end:]...)
+	}
+	return len(p), nil
+}
+
+// Flush is a no-op: the writer goroutine flushes after every write.
+func (rl *streamRelay) Flush() {}
+
+// close forwards what is left and waits for the writer goroutine, so nothing
+// touches the ResponseWriter after the handler returns.
+func (rl *streamRelay) close() {
+	rl.mu.Lock()
+	if len(rl.partial) > 0 && rl.err == nil {
+		rl.enqueue(rl.partial)
+		rl.partial = nil
+	}
+	rl.closing = true
+	rl.ready.Signal()
+	rl.mu.Unlock()
+	<-rl.done
+	rl.cw.done()
+
+	if rl.dropped > 0 {
+		markResponseIncomplete(rl.cw.w)
+		log.Warn().Int("dropped_events", rl.dropped).Msg("streaming: slow client, events dropped")
+	}
+	if rl.err != nil {
+		log.Warn().Err(rl.err).Msg("streaming: slow client, stream ended")
+	}
+}
+
+// enqueue copies p into the queue. Caller holds rl.mu.
+func (rl *streamRelay) enqueue(p []byte) {
+	rl.queue = append(rl.queue, bytes.Clone(p))
+	rl.queued += len(p)
+	rl.ready.Signal()
+}
+
+func (rl *streamRelay) run() {
+	defer close(rl.done)
+	for {
+		rl.mu.Lock()
+		for len(rl.queue) == 0 && rl.err == nil && !rl.closing {
+			rl.ready.Wait()
+		}
+		if rl.err != nil || len(rl.queue) == 0 {
+			rl.mu.Unlock()
+			return
+		}
+		chunk := rl.queue[0]
+		rl.queue[0] = nil
+		rl.queue = rl.queue[1:]
+		rl.mu.Unlock()
+
+		err := rl.cw.write(chunk)
+
+		rl.mu.Lock()
+		rl.queued -= len(chunk)
+		if err != nil && rl.err == nil {
+			rl.err = err
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// clientWriter writes and flushes chunks to the client, each write under the
+// stream write deadline.
+type clientWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	flusher http.Flusher
+	timeout time.Duration
+	metrics *monitoring.MetricsCollector
+}
+
+func newClientWriter(w http.ResponseWriter, srv config.ServerConfig, metrics *monitoring.MetricsCollector) *clientWriter {
+	cw := &clientWriter{
+		w:       w,
+		rc:      http.NewResponseController(w),
+		timeout: srv.StreamWriteTimeout,
+		metrics: metrics,
+	}
+	cw.flusher, _ = w.(http.Flusher)
+	if cw.timeout <= 0 {
+		cw.timeout = config.DefaultStreamWriteTimeout
+	}
+	return cw
+}
+
+// write sends one chunk. A write blocked past the deadline fails this write or
+// the next one (flushes don't report errors).
+func (cw *clientWriter) write(chunk []byte) error {
+	_ = cw.rc.SetWriteDeadline(time.Now().Add(cw.timeout))
+	if _, err := cw.w.Write(chunk); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			cw.count(monitoring.SlowClientWriteTimeout)
+		}
+		return err
+	}
+	if cw.flusher != nil {
+		cw.flusher.Flush()
+	}
+	return nil
+}
+
+// done clears the deadline so it can't fail a later response on the same
+// keep-alive connection.
+func (cw *clientWriter) done() {
+	_ = cw.rc.SetWriteDeadline(time.Time{})
+}
+
+func (cw *clientWriter) count(ev monitoring.SlowClientEvent) {
+	if cw.metrics != nil {
+		cw.metrics.RecordSlowClient(ev, 1)
+	}
+}
+
+// sseEventEnd returns the end of the first complete SSE event in b, including
+// its blank-line terminator, or -1.
+func sseEventEnd(b []byte) int {
+	lf := bytes.Index(b, []byte("\n\n"))
+	crlf := bytes.Index(b, []byte("\r\n\r\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf + 4
+	case lf >= 0:
+		return lf + 2
+	}
+	return -1
+}
@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// stalledWriter is a client that takes no data until released.
+type stalledWriter struct {
+	header  http.Header
+	release chan struct{}
+	err     error // Returned by every write once released
+
+	mu   sync.Mutex
+	body bytes.Buffer
+}
+
+func newStalledWriter() *stalledWriter {
+	return &stalledWriter{header: make(http.Header), release: make(chan struct{})}
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Flush()              {}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	<-w.release
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Write(p)
+}
+
+func (w *stalledWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.String()
+}
+
+// waitQueued waits until the writer goroutine has taken the first chunk.
+func waitQueued(t *testing.T, rl *streamRelay) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+		return len(rl.queue) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestStreamRelay_ClosePolicyEndsStreamWhenBufferFull(t *testing.T) {
+	w := newStalledWriter()
+	metrics := monitoring.NewMetricsCollector()
+	rl := newStreamRelay(w, config.ServerConfig{StreamBufferBytes: 20, SlowClientPolicy: config.SlowClientPolicyClose}, metrics)
+
+	_, err := rl.Write([]byte("data: 1\n\n"))
+	require.NoError(t, err)
+	waitQueued(t, rl)
+	_, err = rl.Write([]byte("data: 2\n\n"))
+	require.NoError(t, err)
+	_, err = rl.Write([]byte("data: 3\n\n"))
+	require.ErrorIs(t, err, errSlowClient)
+	_, err = rl.Write([]byte("data: 4\n\n"))
+	require.ErrorIs(t, err, errSlowClient, "the error is sticky")
+
+	close(w.release)
+	rl.close()
+	assert.Equal(t, "data: 1\n\n", w.String(), "queued data is discarded once the stream is closed")
+	assert.Equal(t, int64(1), metrics.Stats()["slow_client_closed"])
+}
+
+func TestStreamRelay_DropPolicyDropsWholeEvents(t *testing.T) {
+	w := newStalledWriter()
+	metrics := monitoring.NewMetricsCollector()
+	rl := newStreamRelay(w, config.ServerConfig{StreamBufferBytes: 20, SlowClientPolicy: config.SlowClientPolicyDrop}, metrics)
+
+	_, err := rl.Write([]byte("data: 1\n\n"))
+	require.NoError(t, err)
+	waitQueued(t, rl)
+	_, err = rl.Write([]byte("data: 2\n\ndata: 3\n\n")) // 3 doesn't fit
+	require.NoError(t, err)
+	_, err = rl.Write([]byte("data: 4\n\ndata: 5")) // Neither fits; 5 is incomplete
+	require.NoError(t, err)
+
+	close(w.release)
+	require.Eventually(t, func() bool { return w.String() == "data: 1\n\ndata: 2\n\n" }, time.Second, time.Millisecond)
+	_, err = rl.Write([]byte("\n\n")) // Completes 5, which fits again
+	require.NoError(t, err)
+	rl.close()
+
+	assert.Equal(t, "data: 1\n\ndata: 2\n\ndata: 5\n\n", w.String())
+	assert.Equal(t, int64(2), metrics.Stats()["slow_client_dropped_events"])
+}
+
+func TestStreamRelay_WriteTimeoutCounted(t *testing.T) {
+	w := newStalledWriter()
+	w.err = os.ErrDeadlineExceeded
+	metrics := monitoring.NewMetricsCollector()
+	rl := newStreamRelay(w, config.ServerConfig{}, metrics)
+
+	close(w.release)
+	_, err := rl.Write([]byte("data: 1\n\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := rl.Write([]byte("data: 2\n\n"))
+		return err != nil
+	}, time.Second, time.Millisecond)
+	rl.close()
+
+	assert.Equal(t, int64(1), metrics.Stats()["slow_client_write_timeouts"])
+}
+
+func TestSSEEventEnd(t *testing.T) {
+	assert.Equal(t, -1, sseEventEnd([]byte("data: 1\n")))
+	assert.Equal(t, 9, sseEventEnd([]byte("data: 1\n\ndata: 2\n\n")))
+	assert.Equal(t, 11, sseEventEnd([]byte("data: 1\r\n\r\ndata: 2\n\n")))
+}
@@ -0,0 +1,111 @@
+// stream_timing.go measures time-to-first-byte and time-to-first-token for
+// streaming responses, as seen by the client.
+//
+// forwardLatency covers the whole upstream exchange, so a regression that only
+// delays the first relayed byte (e.g. buffering for phantom tool detection) is
+// invisible in it. streamTimingWriter wraps the client ResponseWriter and stamps
+// the first write and the first write carrying a content delta.
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// streamTimingWriter records when the first byte and first content delta reach the client.
+type streamTimingWriter struct {
+	http.ResponseWriter
+	firstByteNs  atomic.Int64 // UnixNano of first Write; 0 = nothing written
+	firstTokenNs atomic.Int64 // UnixNano of first Write containing a content delta
+}
+
+func newStreamTimingWriter(w http.ResponseWriter) *streamTimingWriter {
+	return &streamTimingWriter{ResponseWriter: w}
+}
+
+func (w *streamTimingWriter) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		now := time.Now().UnixNano()
+		w.firstByteNs.CompareAndSwap(0, now)
+		if w.firstTokenNs.Load() == 0 && containsContentDelta(b) {
+			w.firstTokenNs.CompareAndSwap(0, now)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the wrapped writer so SSE chunks still reach the client immediately.
+func (w *streamTimingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *streamTimingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// latencies returns TTFB and TTFT relative to start. Zero means not observed.
+func (w *streamTimingWriter) latencies(start time.Time) (firstByte, firstToken time.Duration) {
+	if ns := w.firstByteNs.Load(); ns != 0 {
+		firstByte = time.Unix(0, ns).Sub(start)
+	}
+	if ns := w.firstTokenNs.Load(); ns != 0 {
+		firstToken = time.Unix(0, ns).Sub(start)
+	}
+	return firstByte, firstToken
+}
+
+// SSE markers for content deltas across provider stream formats.
+var (
+	sseAnthropicDelta = []byte("content_block_delta") // Anthropic
+	// Bedrock event streams: invoke-with-response-stream chunks carry base64
+	// Anthropic events, which begin with {"type":"content_block_delta"; the
+	// converse-stream event type is a plain-text frame header.
+	bedrockChunkDelta    = []byte("eyJ0eXBlIjoiY29udGVudF9ibG9ja19kZWx0")
+	bedrockConverseDelta = []byte("contentBlockDelta")
+	sseResponsesDelta    = []byte(`.delta"`)      // OpenAI Responses API: response.output_text.delta, ...
+	sseChatDelta         = []byte(`"delta"`)      // OpenAI chat completions chunk
+	sseChatContent       = []byte(`"content":"`)  // ...with non-empty content
+	sseChatToolCalls     = []byte(`"tool_calls"`) // ...or a tool call fragment
+	sseGeminiParts       = []byte(`"candidates"`) // Gemini streamGenerateContent
+	sseGeminiText        = []byte(`"text":"`)     // ...with a text part
+)
+
+// containsContentDelta reports whether an SSE chunk carries model output.
+// Chunks are not aligned to events, so this is a byte-level heuristic; a delta
+// split across two writes is counted on the write that completes the marker.
+func containsContentDelta(b []byte) bool {
+	if bytes.Contains(b, sseAnthropicDelta) || bytes.Contains(b, sseResponsesDelta) {
+		return true
+	}
+	if bytes.Contains(b, bedrockChunkDelta) || bytes.Contains(b, bedrockConverseDelta) {
+		return true
+	}
+	if bytes.Contains(b, sseChatDelta) {
+		if bytes.Contains(b, sseChatToolCalls) {
+			return true
+		}
+		return hasNonEmpty(b, sseChatContent)
+	}
+	if bytes.Contains(b, sseGeminiParts) {
+		return hasNonEmpty(b, sseGeminiText)
+	}
+	return false
+}
+
+// hasNonEmpty reports whether any occurrence of key ("field":") is followed by
+// at least one character before the closing quote.
+func hasNonEmpty(b, key []byte) bool {
+	for {
+		i := bytes.Index(b, key)
+		if i < 0 {
+			return false
+		}
+		b = b[i+len(key):]
+		if len(b) > 0 && b[0] != '"' {
+			return true
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainsContentDelta(t *testing.T) {
+	cases := []struct {
+		name  string
+		chunk string
+		want  bool
+	}{
+		{"anthropic message_start", "event: message_start\ndata: {\"type\":\"message_start\"}\n\n", false},
+		{"anthropic delta", "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"Hi\"}}\n\n", true},
+		{"openai role chunk", `data: {"choices":[{"delta":{"role":"assistant","content":""}}]}` + "\n\n", false},
+		{"openai content chunk", `data: {"choices":[{"delta":{"content":"Hi"}}]}` + "\n\n", true},
+		{"openai tool call chunk", `data: {"choices":[{"delta":{"tool_calls":[{"index":0}]}}]}` + "\n\n", true},
+		{"responses api delta", `data: {"type":"response.output_text.delta","delta":"Hi"}` + "\n\n", true},
+		{"responses api created", `data: {"type":"response.created"}` + "\n\n", false},
+		{"gemini text", `data: {"candidates":[{"content":{"parts":[{"text":"Hi"}]}}]}` + "\n\n", true},
+		{"ping", "event: ping\ndata: {\"type\":\"ping\"}\n\n", false},
+		{"bedrock invoke delta", string(anthropicSSEToEventStream([]byte("data: {\"type\":\"content_block_delta\",\"index\":0}\n\n"))), true},
+		{"bedrock invoke message_start", string(anthropicSSEToEventStream([]byte("data: {\"type\":\"message_start\"}\n\n"))), false},
+		{"bedrock converse delta", string(encodeEventStreamMessage([][2]string{{":event-type", "contentBlockDelta"}}, []byte(`{}`))), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, containsContentDelta([]byte(tc.chunk)))
+		})
+	}
+}
+
+func TestStreamTimingWriter_StampsFirstByteAndToken(t *testing.T) {
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	w := newStreamTimingWriter(rec)
+
+	firstByte, firstToken := w.latencies(start)
+	assert.Zero(t, firstByte)
+	assert.Zero(t, firstToken)
+
+	_, _ = w.Write([]byte("event: message_start\ndata: {}\n\n"))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = w.Write([]byte("event: content_block_delta\ndata: {}\n\n"))
+	w.Flush()
+
+	firstByte, firstToken = w.latencies(start)
+	assert.Positive(t, firstByte)
+	assert.GreaterOrEqual(t, firstToken-firstByte, 5*time.Millisecond)
+	assert.True(t, rec.Flushed)
+	assert.Contains(t, rec.Body.String(), "content_block_delta")
+}
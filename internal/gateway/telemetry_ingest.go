@@ -0,0 +1,18 @@
+// telemetry_ingest.go - POST /telemetry/ingest for monitoring.telemetry_collector.
+//
+// Replicas with monitoring.telemetry_export ship their request telemetry here
+// in zstd-compressed batches (see internal/fleet). The endpoint is reachable
+// from other hosts and authenticated by the collector's bearer token.
+package gateway
+
+import "net/http"
+
+// handleTelemetryIngest receives a telemetry batch, or 404s when this gateway
+// is not configured as a collector.
+func (g *Gateway) handleTelemetryIngest(w http.ResponseWriter, r *http.Request) {
+	if g.telemetryCollector == nil {
+		g.writeError(w, "telemetry collector not enabled", http.StatusNotFound)
+		return
+	}
+	g.telemetryCollector.ServeHTTP(w, r)
+}
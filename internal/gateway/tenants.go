@@ -0,0 +1,160 @@
+// tenants.go - Multi-tenant requests (tenancy).
+//
+// handleProxy resolves each request's tenant before anything else. A request
+// naming an unknown tenant, a key of one tenant with another's X-Tenant, a
+// key that shares a tenant's prefix but matches none of its key hashes, or
+// no tenant when tenancy.required is set is rejected without being read.
+// The tenant's provider key replaces the client credential on a copy of the
+// request headers, so pipes, summarization, passthrough and the upstream
+// forward all use it. Tenants with a pipes override run on their own
+// router; config reloads rebuild the registry and update those routers.
+package gateway
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// tenantRouting holds the tenant registry and the routers of tenants with a
+// pipes override.
+type tenantRouting struct {
+	mu       sync.RWMutex
+	registry *tenancy.Registry
+	routers  map[string]*Router
+}
+
+// update rebuilds the registry for cfg. Routers of tenants that still
+// override pipes are updated in place, new ones are built, and the rest are
+// closed.
+func (t *tenantRouting) update(cfg *config.Config, st store.Store) {
+	registry := tenancy.NewRegistry(cfg.Tenancy)
+
+	t.mu.RLock()
+	prev := t.routers
+	t.mu.RUnlock()
+
+	routers := make(map[string]*Router)
+	for _, name := range registry.Names() {
+		tenant := registry.Get(name)
+		if len(tenant.Pipes) == 0 {
+			continue
+		}
+		tc, err := cfg.ForTenant(tenant)
+		if err != nil {
+			log.Error().Err(err).Str("tenant", name).Msg("tenancy: invalid pipes override, using the top-level pipes")
+			continue
+		}
+		if r, ok := prev[name]; ok {
+			r.UpdateConfig(tc)
+			routers[name] = r
+		} else {
+			routers[name] = NewRouter(tc, st)
+		}
+	}
+
+	t.mu.Lock()
+	t.registry = registry
+	t.routers = routers
+	t.mu.Unlock()
+
+	for name, r := range prev {
+		if routers[name] != r {
+			t.closeRouter(name, r)
+		}
+	}
+}
+
+// current returns the tenant registry (nil when tenancy is off).
+func (t *tenantRouting) current() *tenancy.Registry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.registry
+}
+
+// router returns the router of tenant name, or nil when it has no override.
+func (t *tenantRouting) router(name string) *Router {
+	if name == "" {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.routers[name]
+}
+
+// close closes every tenant router (shutdown).
+func (t *tenantRouting) close() {
+	t.mu.Lock()
+	routers := t.routers
+	t.routers = nil
+	t.mu.Unlock()
+	for name, r := range routers {
+		t.closeRouter(name, r)
+	}
+}
+
+func (t *tenantRouting) closeRouter(name string, r *Router) {
+	if err := r.Close(); err != nil {
+		log.Warn().Err(err).Str("tenant", name).Msg("tenancy: failed to close router")
+	}
+}
+
+// routerFor returns the router for requests of tenant: the tenant's own when
+// it overrides pipes, else the gateway's.
+func (g *Gateway) routerFor(tenant string) *Router {
+	if r := g.tenants.router(tenant); r != nil {
+		return r
+	}
+	return g.router
+}
+
+// cfgFor returns the config requests of tenant run with.
+func (g *Gateway) cfgFor(tenant string) *config.Config {
+	if r := g.tenants.router(tenant); r != nil {
+		cfg, _, _, _ := r.snapshot()
+		return cfg
+	}
+	return g.cfg()
+}
+
+// resolveTenant attaches r's tenant to its context and swaps in the tenant's
+// provider key. It writes the error response and returns false when the
+// request has no acceptable tenant.
+func (g *Gateway) resolveTenant(w http.ResponseWriter, r *http.Request, requestID string) (*http.Request, bool) {
+	registry := g.tenants.current()
+	if registry == nil {
+		return r, true
+	}
+	tenant, err := registry.Resolve(r.Header)
+	if err != nil {
+		log.Warn().Err(err).Str("request_id", requestID).Str("path", r.URL.Path).Msg("tenancy: request rejected")
+		g.recordError(monitoring.ErrorCodeTenantRejected)
+		status := http.StatusForbidden
+		if errors.Is(err, tenancy.ErrTenantRequired) || errors.Is(err, tenancy.ErrTenantKey) {
+			status = http.StatusUnauthorized
+		}
+		g.writeError(w, err.Error(), status)
+		return nil, false
+	}
+	if tenant == nil {
+		return r, true
+	}
+
+	r = r.WithContext(tenancy.WithTenant(r.Context(), tenant))
+	if len(tenant.ProviderKeys) > 0 {
+		provider, _ := adapters.IdentifyAndGetAdapter(g.registry, r.URL.Path, r.Header)
+		header := r.Header.Clone()
+		if tenant.ApplyProviderKey(header, provider) {
+			r.Header = header
+		}
+	}
+	return r, true
+}
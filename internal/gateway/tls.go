@@ -0,0 +1,139 @@
+// tls.go - TLS termination and HTTP/2 for the proxy listener (server.tls).
+//
+// With server.tls set the gateway serves HTTPS itself, from certificate files
+// or from certificates it obtains and renews over ACME (Let's Encrypt), so
+// remote clients and browsers need no reverse proxy in front. HTTP/2 is
+// offered over TLS; server.h2c also accepts cleartext HTTP/2 from clients
+// that speak it with prior knowledge.
+package gateway
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+// serverProtocols returns the HTTP versions the proxy listener accepts.
+func serverProtocols(cfg config.ServerConfig) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(cfg.H2C && !cfg.TLS.Enabled())
+	return p
+}
+
+// serverTLSConfig builds the listener's TLS config, including client
+// certificates for client_auth.mtls, or returns nil to serve plaintext.
+func serverTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	t := cfg.Server.TLS
+	if !t.Enabled() {
+		return nil, nil
+	}
+	var tlsCfg *tls.Config
+	if len(t.ACME.Domains) > 0 {
+		m, err := acmeManager(t.ACME)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg = m.TLSConfig()
+	} else {
+		certs, err := newCertReloader(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg = &tls.Config{GetCertificate: certs.get}
+	}
+	tlsCfg.MinVersion = tls.VersionTLS12
+	if err := applyClientMTLS(tlsCfg, cfg.ClientAuth); err != nil {
+		return nil, err
+	}
+	return tlsCfg, nil
+}
+
+// acmeManager obtains certificates for the configured domains on first use
+// and renews them before they expire.
+func acmeManager(cfg config.ACMEConfig) (*autocert.Manager, error) {
+	dir := cfg.CacheDir
+	if dir == "" {
+		state, err := statedir.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		// Next to the state directory, so --reset-state keeps the certificates.
+		dir = filepath.Join(filepath.Dir(state), "acme")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Cache:      autocert.DirCache(dir),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return m, nil
+}
+
+// certReloader serves a certificate from files and reloads it when they
+// change, so renewed certificates apply without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("server.tls: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	return nil
+}
+
+// latestModTime is the newer modification time of the two files.
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (r *certReloader) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.latestModTime().After(r.modTime) {
+		// Keep serving the previous certificate if the new pair is incomplete.
+		if err := r.load(); err != nil {
+			log.Warn().Err(err).Msg("server.tls: reload failed, keeping the previous certificate")
+		} else {
+			log.Info().Str("cert_file", r.certFile).Msg("server.tls: certificate reloaded")
+		}
+	}
+	return r.cert, nil
+}
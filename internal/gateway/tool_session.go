@@ -2,12 +2,14 @@
 package gateway
 
 import (
+	"maps"
 	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/sessionstore"
 )
 
 // ToolCallMapping stores the bidirectional ID mapping for a single tool call
@@ -22,8 +24,11 @@ type ToolCallMapping struct {
 // ToolSession stores deferred and expanded tools for a single session.
 type ToolSession struct {
 	SessionID      string
-	DeferredTools  []adapters.ExtractedContent // Tools filtered out by relevance scoring
-	ExpandedTools  map[string]bool             // Tool names that were searched and found
+	DeferredTools  []adapters.ExtractedContent      // Tools filtered out by relevance scoring
+	ServerStats    map[string]pipes.ToolServerStats // Per-MCP-server counts from the latest filtering
+	ExpandedTools  map[string]bool                  // Tool names that were searched and found
+	ExpandedAt     map[string]int                   // Message count of the request that expanded each tool
+	MessageCount   int                              // Message count of the latest request
 	CreatedAt      time.Time
 	LastAccessedAt time.Time
 
@@ -40,11 +45,12 @@ type ToolSession struct {
 	isMainAgentCached *bool
 }
 
-// ToolSessionStore manages tool sessions with automatic TTL cleanup.
+// ToolSessionStore manages tool sessions with an idle TTL. Expired sessions
+// are dropped on access and swept by the gateway's session collector.
+// Each session is locked independently; accessors return copies so callers
+// never share mutable session state across requests.
 type ToolSessionStore struct {
-	sessions map[string]*ToolSession
-	mu       sync.RWMutex
-	ttl      time.Duration
+	sessions *sessionstore.Store[ToolSession]
 }
 
 // NewToolSessionStore creates a new tool session store.
@@ -52,131 +58,151 @@ func NewToolSessionStore(ttl time.Duration) *ToolSessionStore {
 	if ttl == 0 {
 		ttl = time.Hour // Default 1 hour TTL
 	}
-	store := &ToolSessionStore{
-		sessions: make(map[string]*ToolSession),
-		ttl:      ttl,
+	return &ToolSessionStore{
+		sessions: sessionstore.New(ttl, 0, newToolSession), // swept by the session collector
+	}
+}
+
+// newToolSession builds an empty session.
+func newToolSession(sessionID string) ToolSession {
+	now := time.Now()
+	return ToolSession{
+		SessionID:      sessionID,
+		ExpandedTools:  make(map[string]bool),
+		ExpandedAt:     make(map[string]int),
+		RewriteMap:     make(map[string]*ToolCallMapping),
+		CreatedAt:      now,
+		LastAccessedAt: now,
 	}
-	// Start background cleanup
-	go store.cleanupLoop()
-	return store
 }
 
 // Reset clears all tool sessions for a fresh start.
 func (s *ToolSessionStore) Reset() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions = make(map[string]*ToolSession)
+	s.sessions.Reset()
 }
 
-// Get retrieves a session by ID (returns nil if not found).
-func (s *ToolSessionStore) Get(sessionID string) *ToolSession {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Len returns the number of stored sessions.
+func (s *ToolSessionStore) Len() int {
+	return s.sessions.Len()
+}
 
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		return nil
-	}
-	return session
+// Stop ends the background cleanup goroutine. Safe to call multiple times.
+func (s *ToolSessionStore) Stop() {
+	s.sessions.Stop()
+}
+
+// Sweep removes idle sessions and returns how many (sessionstore.Sweeper).
+func (s *ToolSessionStore) Sweep() int {
+	return s.sessions.Sweep()
+}
+
+// Expire removes a session immediately (sessionstore.Sweeper).
+func (s *ToolSessionStore) Expire(sessionID string) bool {
+	return s.sessions.Delete(sessionID)
+}
+
+// update mutates a session under its lock, creating it if needed.
+func (s *ToolSessionStore) update(sessionID string, fn func(session *ToolSession)) {
+	s.sessions.Update(sessionID, func(session *ToolSession) {
+		fn(session)
+		session.LastAccessedAt = time.Now()
+	})
+}
+
+// Get returns a snapshot of a session (nil if not found).
+// The snapshot is a deep copy and safe to read without locking.
+func (s *ToolSessionStore) Get(sessionID string) *ToolSession {
+	var snapshot *ToolSession
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		cp := *session
+		cp.DeferredTools = append([]adapters.ExtractedContent(nil), session.DeferredTools...)
+		cp.ServerStats = maps.Clone(session.ServerStats)
+		cp.DiscoveredToolNames = append([]string(nil), session.DiscoveredToolNames...)
+		cp.ExpandedTools = copyExpanded(session.ExpandedTools)
+		cp.ExpandedAt = copyExpandedAt(session.ExpandedAt)
+		cp.RewriteMap = copyRewriteMap(session.RewriteMap)
+		snapshot = &cp
+	})
+	return snapshot
 }
 
 // StoreDeferred stores deferred tools for a session.
 func (s *ToolSessionStore) StoreDeferred(sessionID string, deferred []adapters.ExtractedContent) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		session = &ToolSession{
-			SessionID:      sessionID,
-			ExpandedTools:  make(map[string]bool),
-			RewriteMap:     make(map[string]*ToolCallMapping),
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
-		}
-		s.sessions[sessionID] = session
-	}
-	session.DeferredTools = deferred
-	session.LastAccessedAt = time.Now()
+	s.update(sessionID, func(session *ToolSession) {
+		session.DeferredTools = deferred
+	})
+}
+
+// StoreServerStats stores the per-MCP-server counts of the latest filtering.
+func (s *ToolSessionStore) StoreServerStats(sessionID string, stats map[string]pipes.ToolServerStats) {
+	s.update(sessionID, func(session *ToolSession) {
+		session.ServerStats = stats
+	})
 }
 
 // GetDeferred retrieves deferred tools for a session.
-// Note: Does not update LastAccessedAt to avoid write under read lock.
-// The session will be refreshed when StoreDeferred or MarkExpanded is called.
+// Does not refresh the session TTL; StoreDeferred and MarkExpanded do.
 func (s *ToolSessionStore) GetDeferred(sessionID string) []adapters.ExtractedContent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		return nil
-	}
-	// Return a copy to avoid races
-	result := make([]adapters.ExtractedContent, len(session.DeferredTools))
-	copy(result, session.DeferredTools)
+	var result []adapters.ExtractedContent
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		result = make([]adapters.ExtractedContent, len(session.DeferredTools))
+		copy(result, session.DeferredTools)
+	})
 	return result
 }
 
 // MarkExpanded marks tools as expanded (found via search).
 func (s *ToolSessionStore) MarkExpanded(sessionID string, toolNames []string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		session = &ToolSession{
-			SessionID:      sessionID,
-			ExpandedTools:  make(map[string]bool),
-			RewriteMap:     make(map[string]*ToolCallMapping),
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
+	s.update(sessionID, func(session *ToolSession) {
+		for _, name := range toolNames {
+			session.ExpandedTools[name] = true
+			session.ExpandedAt[name] = session.MessageCount
 		}
-		s.sessions[sessionID] = session
-	}
-	for _, name := range toolNames {
-		session.ExpandedTools[name] = true
-	}
-	session.LastAccessedAt = time.Now()
+	})
 }
 
-// GetExpanded retrieves expanded tool names for a session.
-func (s *ToolSessionStore) GetExpanded(sessionID string) map[string]bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		return nil
-	}
-	// Return a copy to avoid races
-	result := make(map[string]bool, len(session.ExpandedTools))
-	for k, v := range session.ExpandedTools {
-		result[k] = v
-	}
-	return result
+// SetMessageCount records the message count of the session's current request.
+// Expansions made while serving it are stamped with this count (see Fork).
+func (s *ToolSessionStore) SetMessageCount(sessionID string, n int) {
+	s.update(sessionID, func(session *ToolSession) {
+		session.MessageCount = n
+	})
 }
 
-// cleanupLoop periodically removes expired sessions.
-func (s *ToolSessionStore) cleanupLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.cleanup()
+// Fork seeds childID from parentID for a conversation branch that diverges
+// from the parent at message forkIndex. Tools expanded by requests that ended
+// before the fork are shared ancestry and carry over; later expansions were
+// driven by messages the branch replaced, so they stay with the parent.
+func (s *ToolSessionStore) Fork(parentID, childID string, forkIndex int) {
+	parent := s.Get(parentID)
+	if parent == nil {
+		return
 	}
+	s.update(childID, func(session *ToolSession) {
+		for name := range parent.ExpandedTools {
+			at := parent.ExpandedAt[name]
+			if at <= forkIndex {
+				session.ExpandedTools[name] = true
+				session.ExpandedAt[name] = at
+			}
+		}
+		for id, mapping := range parent.RewriteMap {
+			session.RewriteMap[id] = mapping
+		}
+		session.DeferredTools = parent.DeferredTools
+		session.ServerStats = parent.ServerStats
+		session.DiscoveredToolNames = parent.DiscoveredToolNames
+		session.isMainAgentCached = parent.isMainAgentCached
+	})
 }
 
-// cleanup removes expired sessions.
-func (s *ToolSessionStore) cleanup() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	cutoff := time.Now().Add(-s.ttl)
-	for id, session := range s.sessions {
-		if session.LastAccessedAt.Before(cutoff) {
-			delete(s.sessions, id)
-		}
-	}
+// GetExpanded retrieves expanded tool names for a session.
+func (s *ToolSessionStore) GetExpanded(sessionID string) map[string]bool {
+	var result map[string]bool
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		result = copyExpanded(session.ExpandedTools)
+	})
+	return result
 }
 
 // MAIN AGENT CLASSIFICATION CACHE (BUG-027)
@@ -189,13 +215,12 @@ func (s *ToolSessionStore) StoreIsMainAgent(sessionID string, isMainAgent bool)
 	if sessionID == "" {
 		return
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	session := s.getOrCreate(sessionID)
-	if session.isMainAgentCached == nil {
-		v := isMainAgent
-		session.isMainAgentCached = &v
-	}
+	s.sessions.Update(sessionID, func(session *ToolSession) {
+		if session.isMainAgentCached == nil {
+			v := isMainAgent
+			session.isMainAgentCached = &v
+		}
+	})
 }
 
 // GetIsMainAgent returns (cachedValue, true) if the classification is cached,
@@ -204,13 +229,13 @@ func (s *ToolSessionStore) GetIsMainAgent(sessionID string) (bool, bool) {
 	if sessionID == "" {
 		return false, false
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	session, ok := s.sessions[sessionID]
-	if !ok || session.isMainAgentCached == nil {
-		return false, false
-	}
-	return *session.isMainAgentCached, true
+	var value, cached bool
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		if session.isMainAgentCached != nil {
+			value, cached = *session.isMainAgentCached, true
+		}
+	})
+	return value, cached
 }
 
 // REWRITE MAP (Universal Dispatcher)
@@ -218,91 +243,92 @@ func (s *ToolSessionStore) GetIsMainAgent(sessionID string) (bool, bool) {
 // RecordCallRewrite stores a mapping for bidirectional rewriting.
 // Called when the proxy rewrites a gateway_search_tool call to a real tool call.
 func (s *ToolSessionStore) RecordCallRewrite(sessionID string, mapping *ToolCallMapping) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	session := s.getOrCreate(sessionID)
-	session.RewriteMap[mapping.ClientToolUseID] = mapping
-	session.LastAccessedAt = time.Now()
+	s.update(sessionID, func(session *ToolSession) {
+		session.RewriteMap[mapping.ClientToolUseID] = mapping
+	})
 }
 
 // GetRewriteMapping looks up a mapping by the client-facing tool_use_id.
 func (s *ToolSessionStore) GetRewriteMapping(sessionID, clientToolUseID string) *ToolCallMapping {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	session, ok := s.sessions[sessionID]
-	if !ok || session.RewriteMap == nil {
-		return nil
-	}
-	return session.RewriteMap[clientToolUseID]
+	var mapping *ToolCallMapping
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		mapping = session.RewriteMap[clientToolUseID]
+	})
+	return mapping
 }
 
 // GetAllRewriteMappings returns a copy of all mappings for a session.
 // Used by inbound rewriting to transform the full message history.
 func (s *ToolSessionStore) GetAllRewriteMappings(sessionID string) map[string]*ToolCallMapping {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	session, ok := s.sessions[sessionID]
-	if !ok || session.RewriteMap == nil {
-		return nil
-	}
-	result := make(map[string]*ToolCallMapping, len(session.RewriteMap))
-	for k, v := range session.RewriteMap {
-		result[k] = v
-	}
+	var result map[string]*ToolCallMapping
+	s.sessions.View(sessionID, func(session *ToolSession) {
+		result = copyRewriteMap(session.RewriteMap)
+	})
 	return result
 }
 
 // IncrementSearchCount increments the search call counter and returns the new count.
 func (s *ToolSessionStore) IncrementSearchCount(sessionID string) int {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	session := s.getOrCreate(sessionID)
-	session.SearchCallCount++
-	session.LastAccessedAt = time.Now()
-	return session.SearchCallCount
+	var count int
+	s.update(sessionID, func(session *ToolSession) {
+		session.SearchCallCount++
+		count = session.SearchCallCount
+	})
+	return count
 }
 
 // ResetSearchCount resets the search call counter (called on successful tool execution).
 func (s *ToolSessionStore) ResetSearchCount(sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if session, ok := s.sessions[sessionID]; ok {
-		session.SearchCallCount = 0
-		session.LastAccessedAt = time.Now()
+	if !s.sessions.View(sessionID, nil) {
+		return
 	}
+	s.update(sessionID, func(session *ToolSession) {
+		session.SearchCallCount = 0
+	})
 }
 
 // AddDiscoveredToolNames appends tool names to the session's discovered list.
 func (s *ToolSessionStore) AddDiscoveredToolNames(sessionID string, names []string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	session := s.getOrCreate(sessionID)
-	session.DiscoveredToolNames = append(session.DiscoveredToolNames, names...)
-	session.LastAccessedAt = time.Now()
-}
-
-// getOrCreate returns an existing session or creates a new one.
-// Must be called under write lock.
-func (s *ToolSessionStore) getOrCreate(sessionID string) *ToolSession {
-	session, ok := s.sessions[sessionID]
-	if !ok {
-		session = &ToolSession{
-			SessionID:      sessionID,
-			ExpandedTools:  make(map[string]bool),
-			RewriteMap:     make(map[string]*ToolCallMapping),
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
-		}
-		s.sessions[sessionID] = session
+	s.update(sessionID, func(session *ToolSession) {
+		session.DiscoveredToolNames = append(session.DiscoveredToolNames, names...)
+	})
+}
+
+// copyExpanded returns a copy of an expanded-tools set (nil stays nil).
+func copyExpanded(src map[string]bool) map[string]bool {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]bool, len(src))
+	for k, v := range src {
+		dst[k] = v
 	}
-	// Ensure maps are initialized (session may have been created by StoreDeferred/MarkExpanded)
-	if session.RewriteMap == nil {
-		session.RewriteMap = make(map[string]*ToolCallMapping)
+	return dst
+}
+
+// copyExpandedAt returns a copy of an expansion-index map (nil stays nil).
+func copyExpandedAt(src map[string]int) map[string]int {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]int, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// copyRewriteMap returns a shallow copy of a rewrite map (nil stays nil).
+// Mappings themselves are immutable once recorded.
+func copyRewriteMap(src map[string]*ToolCallMapping) map[string]*ToolCallMapping {
+	if src == nil {
+		return nil
 	}
-	if session.ExpandedTools == nil {
-		session.ExpandedTools = make(map[string]bool)
+	dst := make(map[string]*ToolCallMapping, len(src))
+	for k, v := range src {
+		dst[k] = v
 	}
-	return session
+	return dst
 }
 
 // TOOL SEARCH
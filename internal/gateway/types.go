@@ -7,6 +7,9 @@ import (
 	"github.com/compresr/context-gateway/internal/adapters"
 	authtypes "github.com/compresr/context-gateway/internal/auth/types"
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/monitoring"
 	"github.com/compresr/context-gateway/internal/pipes"
 	"github.com/tidwall/gjson"
 )
@@ -32,17 +35,56 @@ type PipelineContext struct {
 	*pipes.PipeContext
 
 	// Gateway-specific fields (not used by pipes)
-	OriginalPath string // Original request path (e.g., /v1/messages)
-	Model        string // Model being used
-	Stream       bool   // Is this a streaming request?
+	OriginalPath string   // Original request path (e.g., /v1/messages)
+	Model        string   // Model being used
+	Stream       bool     // Is this a streaming request?
+	SessionTags  []string // Client-defined tags from X-Session-Tags (used by routing rules)
+	Tenant       string   // Tenant name (tenancy); empty when tenancy is off or no tenant matched
+	Client       string   // client_auth label of the caller; empty when client_auth is off
 	ReceivedAt   time.Time
 
+	// Feature flags defined for this request (nil when none; see feature_flags.go)
+	Flags featureflags.Decisions
+
 	// Expand context usage tracking
 	ExpandLoopCount int  // How many times LLM called expand_context
 	StreamTruncated bool // True if streaming response exceeded buffer limit
 
+	// Client-side stream timing (nil for non-streaming requests)
+	streamTiming *streamTimingWriter
+
+	// In-flight registry entry (admin API stage reporting; nil outside handleProxy)
+	inflight *inflightRequest
+
+	// Pipe failures (pipe errored or panicked; its input was forwarded unchanged)
+	PipeFailed bool
+
+	// Pipe latency SLO state, one entry per pipe with an SLO that ran
+	PipeSLO []monitoring.PipeSLO
+
+	// Security pipes (PII masking, injection scanning): a PII error blocks
+	// forwarding; securedBody is the request after them and before
+	// compression (baseline for the cache-prefix guard), nil when neither ran
+	PIIError        error
+	securedBody     []byte
+	injectionAction string // pipes.injection.action applied to InjectionFindings
+
+	// Custom pipes: one run record per pipe, and a refusal to forward the
+	// request by a custom or the injection pipe (answered with rejectStatus
+	// and recorded as rejectCode)
+	CustomPipes   []monitoring.CustomPipeRun
+	PipeRejection error
+	rejectStatus  int
+	rejectCode    monitoring.ErrorCode
+
 	// Cost control
-	CostSessionID string // Session ID for cost tracking (hash-based, may vary between requests)
+	CostSessionID string                 // Session ID for cost tracking (hash-based, may vary between requests)
+	BudgetScopes  []costcontrol.ScopeKey // Budget scope values (API key, team header, project tag)
+
+	// Responses API stored conversation (previous_response_id / conversation):
+	// history lives upstream, so the session comes from the response chain and
+	// compaction is skipped.
+	StoredConversation bool
 
 	// Stable conversation fingerprint — hash of clean first user message text (injected XML stripped).
 	// Unlike CostSessionID, this is stable across all requests in the same conversation.
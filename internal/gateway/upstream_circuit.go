@@ -0,0 +1,106 @@
+// upstream_circuit.go - per-host circuit breaker for upstream forwards
+// (upstream_circuit_breaker).
+//
+// forwardUpstream asks the host's breaker before sending; forwardPassthrough
+// records the outcome once the forward (including an auth fallback retry)
+// is done. 5xx responses, timeouts and connection failures count as failures;
+// requests that never left the gateway and client cancellations do not.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/circuitbreaker"
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// upstreamCircuits holds one breaker per upstream host. Breakers are rebuilt
+// when a config reload changes the thresholds.
+type upstreamCircuits struct {
+	mu    sync.Mutex
+	cfg   config.UpstreamCircuitBreakerConfig
+	hosts map[string]*circuitbreaker.CircuitBreaker
+}
+
+// circuitStatus is one host's entry in /health.
+type circuitStatus struct {
+	State    circuitbreaker.State `json:"state"`
+	Failures int                  `json:"consecutive_failures"`
+}
+
+// upstreamCircuit returns the breaker for host, or nil when the circuit
+// breaker is disabled.
+func (g *Gateway) upstreamCircuit(host string) *circuitbreaker.CircuitBreaker {
+	cfg := g.cfg().UpstreamCircuitBreaker
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = circuitbreaker.DefaultMaxFailures
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = circuitbreaker.DefaultOpenDuration
+	}
+
+	c := &g.circuits
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hosts == nil || c.cfg != cfg {
+		c.cfg = cfg
+		c.hosts = make(map[string]*circuitbreaker.CircuitBreaker)
+	}
+	cb, ok := c.hosts[host]
+	if !ok {
+		cb = circuitbreaker.New(
+			circuitbreaker.WithMaxFailures(cfg.MaxFailures),
+			circuitbreaker.WithOpenDuration(cfg.OpenDuration),
+			circuitbreaker.WithSingleProbe(),
+		)
+		c.hosts[host] = cb
+	}
+	return cb
+}
+
+// recordUpstreamOutcome feeds one forward's result into the host's breaker.
+// sent is false when the request was rejected before reaching the upstream.
+func (g *Gateway) recordUpstreamOutcome(ctx context.Context, host string, sent bool, resp *http.Response, err error) {
+	if !sent || host == "" {
+		return
+	}
+	cb := g.upstreamCircuit(host)
+	if cb == nil {
+		return
+	}
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		return // Client went away; says nothing about the upstream
+	case err != nil, resp != nil && resp.StatusCode >= 500:
+		wasOpen := cb.State() != circuitbreaker.StateClosed
+		cb.RecordFailure()
+		if !wasOpen && cb.State() == circuitbreaker.StateOpen {
+			log.Warn().Str("host", host).Int("failures", cb.Failures()).Msg("upstream circuit opened")
+		}
+	default:
+		if cb.State() != circuitbreaker.StateClosed {
+			log.Info().Str("host", host).Msg("upstream circuit closed")
+		}
+		cb.RecordSuccess()
+	}
+}
+
+// circuitHealth reports each known host's circuit for /health.
+func (g *Gateway) circuitHealth() map[string]circuitStatus {
+	c := &g.circuits
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]circuitStatus, len(c.hosts))
+	for host, cb := range c.hosts {
+		out[host] = circuitStatus{State: cb.State(), Failures: cb.Failures()}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+// upstream_retry.go - retries of transient upstream failures (upstream_retry).
+//
+// forwardPassthrough returns once the upstream's response headers arrive, so
+// every retry here happens before the client has seen a byte, for streaming
+// and non-streaming requests alike. Only requests that reached the upstream
+// are retried: connection errors, and responses whose status is in retry_on.
+// Requests the gateway rejected itself (host allowlist, open circuit, key
+// pins) and client cancellations are not.
+//
+// When the retries end on a fallback_on status (529 overloaded), a request
+// whose model has a model_fallbacks rule is sent once more with the fallback
+// model, and the response carries X-Model-Fallback.
+package gateway
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/retry"
+)
+
+// HeaderModelFallback names the model that answered instead of the requested one.
+const HeaderModelFallback = "X-Model-Fallback"
+
+// Retry reasons besides status codes.
+const (
+	upstreamRetryReasonNetwork       = "network"        // A connection error
+	upstreamRetryReasonModelFallback = "model_fallback" // The request went to the fallback model
+)
+
+// upstreamRetryDelay decides whether to retry after attempt (1-based) of a
+// forward, and how long to wait first. reason is the status code or "network".
+func upstreamRetryDelay(ctx context.Context, cfg config.UpstreamRetryConfig, attempt int, sent bool, resp *http.Response, err error) (delay time.Duration, reason string, ok bool) {
+	if !cfg.Enabled || attempt >= cfg.MaxAttempts || !sent || ctx.Err() != nil {
+		return 0, "", false
+	}
+	switch {
+	case err != nil:
+		if !retry.IsTransientErr(err) { // Cancellations and upstream timeouts
+			return 0, "", false
+		}
+		reason = upstreamRetryReasonNetwork
+	case resp != nil && cfg.RetriesStatus(resp.StatusCode):
+		reason = strconv.Itoa(resp.StatusCode)
+		if after, found := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); found {
+			if after > cfg.MaxBackoff {
+				return 0, "", false // Longer than we hold a client; let it decide
+			}
+			return after, reason, true
+		}
+	default:
+		return 0, "", false
+	}
+	return upstreamBackoff(cfg, attempt), reason, true
+}
+
+// modelFallbackFor returns the fallback model and the request body rewritten
+// for it when the final response is a fallback_on status and the requested
+// model has a model_fallbacks rule.
+func modelFallbackFor(ctx context.Context, cfg config.UpstreamRetryConfig, body []byte, resp *http.Response, err error) (string, []byte, bool) {
+	if !cfg.Enabled || err != nil || resp == nil || ctx.Err() != nil || !cfg.FallsBackOn(resp.StatusCode) {
+		return "", nil, false
+	}
+	fallback, ok := cfg.FallbackModel(gjson.GetBytes(body, "model").String())
+	if !ok {
+		return "", nil, false
+	}
+	// sjson keeps field order, so the prompt-cache prefix is unchanged.
+	rewritten, setErr := sjson.SetBytes(body, "model", fallback)
+	if setErr != nil {
+		return "", nil, false
+	}
+	return fallback, rewritten, true
+}
+
+// upstreamBackoff returns the wait before retry number attempt (1-based):
+// initial_backoff doubled per retry, capped at max_backoff, with the upper
+// half jittered so clients that failed together do not retry together.
+func upstreamBackoff(cfg config.UpstreamRetryConfig, attempt int) time.Duration {
+	d := cfg.InitialBackoff
+	for i := 1; i < attempt && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, cfg.MaxBackoff)
+	if half := d / 2; half > 0 {
+		d = half + rand.N(half+1) // #nosec G404 -- jitter, not security
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After header: delay seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
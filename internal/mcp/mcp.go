@@ -0,0 +1,222 @@
+// Package mcp serves tools over the Model Context Protocol.
+//
+// DESIGN: Only the tools capability is implemented — initialize, ping,
+// tools/list and tools/call over JSON-RPC 2.0 — which is all an agent needs
+// to call gateway features directly instead of through phantom tools
+// injected into its LLM requests. The protocol core (Server.Handle) is
+// transport-agnostic; transports are HTTP POST (one message in, one response
+// out), the HTTP+SSE transport (sse.go) and newline-delimited stdio
+// (stdio.go).
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ProtocolVersion is the MCP revision answered when the client asks for one
+// the server does not know.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the revisions whose tools methods match this server.
+var supportedVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// MaxMessageSize caps one JSON-RPC message read from a transport.
+const MaxMessageSize = 1 << 20
+
+// JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
+
+// Tool is one callable tool. Handler returns the text result; an error is
+// reported to the model as a tool error (isError), not a protocol error.
+type Tool struct {
+	Name        string                                                         `json:"name"`
+	Description string                                                         `json:"description"`
+	InputSchema map[string]any                                                 `json:"inputSchema"`
+	Handler     func(ctx context.Context, args map[string]any) (string, error) `json:"-"`
+}
+
+// Server answers MCP requests for a fixed set of tools.
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+}
+
+// NewServer creates a server that reports itself as name/version.
+func NewServer(name, version string, tools ...Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+// SetVersion sets the version reported in serverInfo. Call before serving.
+func (s *Server) SetVersion(version string) { s.version = version }
+
+// Tools returns the server's tools.
+func (s *Server) Tools() []Tool { return s.tools }
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// textContent is an MCP text content item.
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Handle processes one JSON-RPC message and returns the encoded response, or
+// nil for notifications.
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return encode(response{ID: json.RawMessage("null"), Error: &rpcError{CodeParseError, "parse error"}})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return encode(response{ID: idOrNull(req.ID), Error: &rpcError{CodeInvalidRequest, "invalid request"}})
+	}
+	if len(req.ID) == 0 {
+		// Notifications (notifications/initialized, cancellations) need no answer.
+		return nil
+	}
+
+	result, rerr := s.dispatch(ctx, req)
+	return encode(response{ID: req.ID, Result: result, Error: rerr})
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	default:
+		return nil, &rpcError{CodeMethodNotFound, "method not found: " + req.Method}
+	}
+}
+
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || params.Name == "" {
+		return nil, &rpcError{CodeInvalidParams, "invalid params"}
+	}
+	idx := slices.IndexFunc(s.tools, func(t Tool) bool { return t.Name == params.Name })
+	if idx < 0 {
+		return nil, &rpcError{CodeInvalidParams, "unknown tool: " + params.Name}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]any{}
+	}
+
+	text, err := s.tools[idx].Handler(ctx, params.Arguments)
+	if err != nil {
+		return map[string]any{"content": []textContent{{"text", err.Error()}}, "isError": true}, nil
+	}
+	return map[string]any{"content": []textContent{{"text", text}}}, nil
+}
+
+// ServeHTTP answers one JSON-RPC message per POST with a JSON response, or
+// 202 Accepted for notifications.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	out := s.Handle(r.Context(), msg)
+	if out == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(out); err != nil {
+		log.Debug().Err(err).Msg("mcp: failed to write response")
+	}
+}
+
+// ArgString returns a string argument, or "" when missing or not a string.
+func ArgString(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// ArgInt returns a numeric argument as an int, or def when missing.
+func ArgInt(args map[string]any, name string, def int) int {
+	if f, ok := args[name].(float64); ok {
+		return int(f)
+	}
+	return def
+}
+
+// RequiredString returns a non-empty string argument or an error naming it.
+func RequiredString(args map[string]any, name string) (string, error) {
+	if s := ArgString(args, name); s != "" {
+		return s, nil
+	}
+	return "", fmt.Errorf("missing required argument %q", name)
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func encode(resp response) []byte {
+	resp.JSONRPC = "2.0"
+	out, err := json.Marshal(resp)
+	if err != nil {
+		out, _ = json.Marshal(response{JSONRPC: "2.0", ID: idOrNull(resp.ID), Error: &rpcError{-32603, "internal error"}})
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sseKeepAlive is how often an idle stream gets a comment line, so proxies
+// and clients don't drop it.
+const sseKeepAlive = 30 * time.Second
+
+// sseQueueSize bounds responses waiting for a slow SSE stream; further
+// responses are dropped and the client times out on them.
+const sseQueueSize = 64
+
+// SSE serves the HTTP+SSE transport. A GET on the stream URL opens an event
+// stream whose first "endpoint" event names the URL to POST messages to
+// (with a session_id query parameter); responses come back on the stream as
+// "message" events.
+type SSE struct {
+	server   *Server
+	endpoint string
+
+	mu       sync.Mutex
+	sessions map[string]chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewSSE creates the transport; endpoint is the path messages are POSTed to.
+func NewSSE(server *Server, endpoint string) *SSE {
+	return &SSE{
+		server:   server,
+		endpoint: endpoint,
+		sessions: make(map[string]chan []byte),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Sessions returns the number of open streams.
+func (t *SSE) Sessions() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sessions)
+}
+
+// Close ends every open stream.
+func (t *SSE) Close() {
+	t.once.Do(func() { close(t.closed) })
+}
+
+// ServeStream holds a GET request open as the session's event stream.
+func (t *SSE) ServeStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	id := uuid.NewString()
+	queue := make(chan []byte, sseQueueSize)
+	t.mu.Lock()
+	t.sessions[id] = queue
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.sessions, id)
+		t.mu.Unlock()
+	}()
+
+	// The stream outlives the server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, "endpoint", []byte(t.endpoint+"?session_id="+url.QueryEscape(id)))
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-queue:
+			writeEvent(w, "message", msg)
+			flusher.Flush()
+		case <-ticker.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+// ServeMessage handles a POSTed message for the stream named by session_id
+// and queues the response on that stream.
+func (t *SSE) ServeMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	t.mu.Lock()
+	queue, ok := t.sessions[r.URL.Query().Get("session_id")]
+	t.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if out := t.server.Handle(r.Context(), msg); out != nil {
+		select {
+		case queue <- out:
+		default:
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event string, data []byte) {
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,40 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// HandleFunc processes one JSON-RPC message and returns the response, or nil
+// when there is none.
+type HandleFunc func(ctx context.Context, msg []byte) ([]byte, error)
+
+// ServeStdio reads newline-delimited JSON-RPC messages from r and writes
+// each response to w on its own line, until r is exhausted or ctx ends. An
+// error from handle stops the loop.
+func ServeStdio(ctx context.Context, r io.Reader, w io.Writer, handle HandleFunc) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxMessageSize)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		out, err := handle(ctx, line)
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			continue
+		}
+		if _, err := w.Write(append(bytes.TrimSpace(out), '\n')); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
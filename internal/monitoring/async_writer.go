@@ -0,0 +1,402 @@
+// Package monitoring - async_writer.go moves JSONL file I/O off the request path.
+//
+// Callers encode a line and enqueue it without blocking; a single goroutine per
+// file drains the queue in batches (one write syscall per batch) and fsyncs per
+// the configured policy. When the queue is full the line is dropped and counted
+// instead of stalling the proxied request on a slow disk. With retention, the
+// same goroutine rotates the file (see retention.go).
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/redaction"
+)
+
+// ErrDropped is returned by WriteJSONL when the line was dropped (queue full
+// or writer closed). The drop is already counted in Dropped; callers only use
+// it to keep their own counts to lines that were accepted.
+var ErrDropped = errors.New("telemetry line dropped")
+
+// SyncPolicy controls how often an AsyncWriter fsyncs its file.
+type SyncPolicy string
+
+const (
+	SyncNever    SyncPolicy = "never"    // fsync only on Flush/Close
+	SyncInterval SyncPolicy = "interval" // fsync at most once per FlushInterval when dirty (default)
+	SyncAlways   SyncPolicy = "always"   // fsync after every batch
+)
+
+// Async writer defaults (applied when AsyncWriterConfig fields are zero).
+const (
+	DefaultWriterQueueSize     = 4096
+	DefaultWriterBatchSize     = 256
+	DefaultWriterFlushInterval = time.Second
+)
+
+// AsyncWriterConfig tunes queueing and durability for an AsyncWriter.
+type AsyncWriterConfig struct {
+	QueueSize     int           `yaml:"queue_size"`     // Max queued lines before dropping (default: 4096)
+	BatchSize     int           `yaml:"batch_size"`     // Max lines per write syscall (default: 256)
+	FlushInterval time.Duration `yaml:"flush_interval"` // fsync period for SyncInterval (default: 1s)
+	SyncPolicy    SyncPolicy    `yaml:"sync_policy"`    // never | interval | always (default: interval)
+
+	// Redactor scrubs each JSONL line before it is queued. Set at runtime
+	// from monitoring.redaction; nil writes lines unchanged.
+	Redactor *redaction.Redactor `yaml:"-" json:"-"`
+
+	// Retention rotates and prunes the file. Set at runtime from
+	// monitoring.retention; disabled leaves the file growing.
+	Retention RetentionConfig `yaml:"-" json:"-"`
+}
+
+// withDefaults fills zero fields with defaults.
+func (c AsyncWriterConfig) withDefaults() AsyncWriterConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultWriterQueueSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultWriterBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultWriterFlushInterval
+	}
+	if c.SyncPolicy == "" {
+		c.SyncPolicy = SyncInterval
+	}
+	if c.Retention.Enabled {
+		c.Retention = c.Retention.WithDefaults()
+	}
+	return c
+}
+
+// Validate checks the sync policy.
+func (c AsyncWriterConfig) Validate() error {
+	switch c.SyncPolicy {
+	case "", SyncNever, SyncInterval, SyncAlways:
+		return nil
+	default:
+		return fmt.Errorf("invalid sync_policy %q (must be never, interval, or always)", c.SyncPolicy)
+	}
+}
+
+// AsyncWriter appends lines to a file from a background goroutine.
+// Thread-safe. Safe to call on a nil receiver (disabled).
+type AsyncWriter struct {
+	path     string
+	file     *os.File
+	cfg      AsyncWriterConfig
+	queue    chan []byte
+	flushReq chan chan struct{}
+	done     chan struct{}
+
+	mu     sync.RWMutex // guards closed against concurrent enqueue
+	closed bool
+
+	size     int64          // Bytes in the current file (writer goroutine only)
+	openedAt time.Time      // When the current file was started (writer goroutine only)
+	pruning  sync.WaitGroup // Compression and pruning of rotated files
+	pruneMu  sync.Mutex     // One pruneRotated at a time
+
+	written atomic.Int64
+	dropped atomic.Int64
+}
+
+// OpenAsyncWriter opens (or creates) path for append and starts the writer goroutine.
+func OpenAsyncWriter(path string, cfg AsyncWriterConfig) (*AsyncWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 -- path is from config
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	w := &AsyncWriter{
+		path:     path,
+		file:     f,
+		cfg:      cfg,
+		queue:    make(chan []byte, cfg.QueueSize),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+		openedAt: time.Now(),
+	}
+	if info, err := f.Stat(); err == nil {
+		w.size = info.Size()
+	}
+	if cfg.Retention.Enabled {
+		// Finish what a previous run left: uncompressed or excess rotated files.
+		w.pruneInBackground()
+	}
+	go w.run()
+	return w, nil
+}
+
+// Write enqueues one line (a trailing newline is expected). Never blocks.
+// Returns false when the line was dropped because the queue is full or closed.
+func (w *AsyncWriter) Write(line []byte) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return false
+	}
+	select {
+	case w.queue <- line:
+		return true
+	default:
+		w.dropped.Add(1)
+		return false
+	}
+}
+
+// WriteJSONL encodes event as a single JSON line and enqueues it.
+// Returns the encoding error, or ErrDropped when the line was not enqueued.
+func (w *AsyncWriter) WriteJSONL(event any) error {
+	if w == nil {
+		return nil
+	}
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
+	if err := json.NewEncoder(buf).Encode(event); err != nil {
+		return err
+	}
+	// Copy out of the pooled buffer: the queue owns the line until written.
+	line := bytes.Clone(buf.Bytes())
+	if !w.Write(w.cfg.Redactor.JSON(line)) {
+		return ErrDropped
+	}
+	return nil
+}
+
+// Flush blocks until every line enqueued before the call is written and synced.
+func (w *AsyncWriter) Flush() {
+	if w == nil {
+		return
+	}
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return
+	}
+	ack := make(chan struct{})
+	w.flushReq <- ack
+	w.mu.RUnlock()
+	<-ack
+}
+
+// Close drains the queue, syncs, and closes the file. Safe to call on nil and more than once.
+func (w *AsyncWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		<-w.done
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	w.pruning.Wait()
+	return w.file.Close()
+}
+
+// Written returns the number of lines written to disk.
+func (w *AsyncWriter) Written() int64 {
+	if w == nil {
+		return 0
+	}
+	return w.written.Load()
+}
+
+// Dropped returns the number of lines dropped under backpressure.
+func (w *AsyncWriter) Dropped() int64 {
+	if w == nil {
+		return 0
+	}
+	return w.dropped.Load()
+}
+
+// run is the writer goroutine: batch, write, sync.
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	lines := 0
+	dirty := false
+
+	write := func() {
+		if lines == 0 {
+			return
+		}
+		n, err := w.file.Write(batch.Bytes())
+		w.size += int64(n)
+		if err != nil {
+			log.Error().Err(err).Str("path", w.path).Int("lines", lines).Msg("telemetry: batch write failed")
+		} else {
+			w.written.Add(int64(lines))
+			dirty = true
+		}
+		batch.Reset()
+		lines = 0
+		if w.rotationDue(time.Now()) {
+			dirty = false // rotate syncs the old file
+			w.rotate()
+		}
+	}
+	fsync := func() {
+		if dirty {
+			_ = w.file.Sync()
+			dirty = false
+		}
+	}
+	// fill moves queued lines into the batch until it holds BatchSize lines or
+	// the queue is empty. Returns false once the queue is closed and empty.
+	fill := func() bool {
+		for lines < w.cfg.BatchSize {
+			select {
+			case line, ok := <-w.queue:
+				if !ok {
+					return false
+				}
+				batch.Write(line)
+				lines++
+			default:
+				return true
+			}
+		}
+		return true
+	}
+
+	for {
+		select {
+		case line, ok := <-w.queue:
+			if !ok {
+				fsync()
+				return
+			}
+			batch.Write(line)
+			lines++
+			open := fill()
+			write()
+			if !open {
+				fsync()
+				return
+			}
+			if w.cfg.SyncPolicy == SyncAlways {
+				fsync()
+			}
+
+		case ack := <-w.flushReq:
+			open := true
+			for open && len(w.queue) > 0 {
+				open = fill()
+				write()
+			}
+			fsync()
+			close(ack)
+			if !open {
+				return
+			}
+
+		case <-ticker.C:
+			if w.cfg.SyncPolicy == SyncInterval {
+				fsync()
+			}
+			if w.rotationDue(time.Now()) {
+				dirty = false
+				w.rotate()
+			}
+		}
+	}
+}
+
+// rotationDue reports whether the current file has reached the retention
+// size or age. An empty file is never rotated.
+func (w *AsyncWriter) rotationDue(now time.Time) bool {
+	r := w.cfg.Retention
+	if !r.Enabled || w.size == 0 {
+		return false
+	}
+	return w.size >= r.maxSizeBytes() || (r.RotateEvery > 0 && now.Sub(w.openedAt) >= r.RotateEvery)
+}
+
+// rotate renames the current file aside and starts a new one, then
+// compresses and prunes rotated files in the background. The file is closed
+// before the rename because Windows cannot rename open files. On failure the
+// writer keeps appending to the current path.
+func (w *AsyncWriter) rotate() {
+	now := time.Now()
+	_ = w.file.Sync()
+	_ = w.file.Close()
+	rotated := RotatedName(w.path, now)
+	renameErr := os.Rename(w.path, rotated)
+	if renameErr != nil {
+		log.Error().Err(renameErr).Str("path", w.path).Msg("telemetry: log rotation failed")
+	}
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 -- path is from config
+	if err != nil {
+		log.Error().Err(err).Str("path", w.path).Msg("telemetry: reopen after rotation failed")
+		return
+	}
+	w.file = f
+	if renameErr != nil {
+		return
+	}
+	w.size, w.openedAt = 0, now
+	log.Debug().Str("path", w.path).Str("rotated", rotated).Msg("telemetry: log rotated")
+	w.pruneInBackground()
+}
+
+// pruneInBackground runs pruneRotated on its own goroutine; Close waits for it.
+func (w *AsyncWriter) pruneInBackground() {
+	w.pruning.Add(1)
+	go func() {
+		defer w.pruning.Done()
+		w.pruneRotated()
+	}()
+}
+
+// pruneRotated gzips uncompressed rotated files, then applies max_files and max_age.
+func (w *AsyncWriter) pruneRotated() {
+	w.pruneMu.Lock()
+	defer w.pruneMu.Unlock()
+	files, err := RotatedFiles(w.path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", w.path).Msg("telemetry: listing rotated logs failed")
+		return
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, ".gz") {
+			continue
+		}
+		if err := gzipFile(f); err != nil {
+			log.Warn().Err(err).Str("path", f).Msg("telemetry: compressing rotated log failed")
+		}
+	}
+	if _, err := PruneRotated(w.path, w.cfg.Retention, time.Now()); err != nil {
+		log.Warn().Err(err).Str("path", w.path).Msg("telemetry: pruning rotated logs failed")
+	}
+}
@@ -0,0 +1,229 @@
+// Package monitoring - audit_log.go records upstream forwarding decisions.
+//
+// One entry per upstream forward: which auth headers the client sent, which
+// the gateway stripped or overrode, the auth mode it chose (including a
+// subscription -> API key fallback), the allowed-host decision and how the
+// target URL was resolved. Header values are never recorded, only names.
+// Entries go to a JSONL file or to syslog, separate from request telemetry.
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Audit sinks.
+const (
+	AuditSinkFile   = "file"
+	AuditSinkSyslog = "syslog"
+)
+
+// DefaultAuditSyslogTag is the syslog tag when audit.syslog_tag is unset.
+const DefaultAuditSyslogTag = "context-gateway"
+
+// auditSyslogQueue bounds entries waiting for a slow syslog daemon.
+const auditSyslogQueue = 1024
+
+// AuditConfig enables the forwarding audit log.
+type AuditConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Sink          string `yaml:"sink"`           // file | syslog (default: file)
+	Path          string `yaml:"path"`           // JSONL file, required for the file sink
+	SyslogNetwork string `yaml:"syslog_network"` // "" (local daemon), udp, tcp or unix
+	SyslogAddress string `yaml:"syslog_address"` // e.g. logs.internal:514; required with syslog_network
+	SyslogTag     string `yaml:"syslog_tag"`     // default: context-gateway
+}
+
+// Validate checks the audit configuration.
+func (c AuditConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Sink {
+	case "", AuditSinkFile:
+		if c.Path == "" {
+			return fmt.Errorf("audit.path is required for the file sink")
+		}
+	case AuditSinkSyslog:
+		switch c.SyslogNetwork {
+		case "":
+		case "udp", "tcp", "unix":
+			if c.SyslogAddress == "" {
+				return fmt.Errorf("audit.syslog_address is required with syslog_network %q", c.SyslogNetwork)
+			}
+		default:
+			return fmt.Errorf("invalid audit.syslog_network %q (must be udp, tcp, unix, or empty)", c.SyslogNetwork)
+		}
+	default:
+		return fmt.Errorf("invalid audit.sink %q (must be file or syslog)", c.Sink)
+	}
+	return nil
+}
+
+// AuditEntry records the decisions made for one upstream forward.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"request_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Provider  string    `json:"provider,omitempty"`
+
+	// Target resolution: X-Target-URL as sent, and the URL it resolved to.
+	TargetURLHeader string `json:"target_url_header,omitempty"`
+	TargetURL       string `json:"target_url,omitempty"`
+	TargetSource    string `json:"target_source"` // header | auto_detect | none
+	Host            string `json:"host,omitempty"`
+	HostAllowed     bool   `json:"host_allowed"`
+
+	// Header names only; values are never recorded.
+	AuthHeadersPresent    []string `json:"auth_headers_present,omitempty"`    // Auth headers on the client request
+	AuthHeadersStripped   []string `json:"auth_headers_stripped,omitempty"`   // Present, but not sent upstream
+	AuthHeadersOverridden []string `json:"auth_headers_overridden,omitempty"` // Sent upstream with a gateway-set value
+	ForwardedHeaders      []string `json:"forwarded_headers,omitempty"`       // All headers sent upstream
+	SigV4Signed           bool     `json:"sigv4_signed,omitempty"`            // Bedrock request signed by the gateway
+
+	AuthModeInitial   string `json:"auth_mode_initial"`
+	AuthModeEffective string `json:"auth_mode_effective"`
+	AuthFallback      bool   `json:"auth_fallback"`
+	FallbackReason    string `json:"fallback_reason,omitempty"`
+
+	Status int    `json:"status,omitempty"` // Upstream status; 0 when nothing was received
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditLogger writes AuditEntry records to the configured sink.
+// Thread-safe. Safe to call on a nil receiver (disabled).
+type AuditLogger struct {
+	file   *AsyncWriter
+	syslog *auditSyslogWriter
+}
+
+// NewAuditLogger opens the audit sink. Returns nil when auditing is disabled.
+func NewAuditLogger(cfg AuditConfig, writer AsyncWriterConfig) (*AuditLogger, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Sink == AuditSinkSyslog {
+		tag := cfg.SyslogTag
+		if tag == "" {
+			tag = DefaultAuditSyslogTag
+		}
+		w, err := openAuditSyslog(cfg.SyslogNetwork, cfg.SyslogAddress, tag)
+		if err != nil {
+			return nil, fmt.Errorf("audit: open syslog: %w", err)
+		}
+		return &AuditLogger{syslog: newAuditSyslogWriter(w)}, nil
+	}
+	w, err := OpenAsyncWriter(cfg.Path, writer)
+	if err != nil {
+		return nil, fmt.Errorf("audit: open %s: %w", cfg.Path, err)
+	}
+	return &AuditLogger{file: w}, nil
+}
+
+// Log enqueues an entry. Never blocks; a full queue drops the entry and counts it.
+func (l *AuditLogger) Log(entry AuditEntry) {
+	if l == nil {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	if l.syslog != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Error().Err(err).Msg("audit: marshal failed")
+			return
+		}
+		l.syslog.write(line)
+		return
+	}
+	if err := l.file.WriteJSONL(entry); err != nil && !errors.Is(err, ErrDropped) {
+		log.Error().Err(err).Msg("audit: marshal failed")
+	}
+}
+
+// Dropped returns the number of entries dropped under backpressure.
+func (l *AuditLogger) Dropped() int64 {
+	if l == nil {
+		return 0
+	}
+	if l.syslog != nil {
+		return l.syslog.dropped.Load()
+	}
+	return l.file.Dropped()
+}
+
+// Close drains queued entries and closes the sink. Safe to call on nil.
+func (l *AuditLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.syslog != nil {
+		return l.syslog.close()
+	}
+	return l.file.Close()
+}
+
+// auditSyslogWriter sends one syslog message per entry from a background
+// goroutine, so a slow daemon never stalls a request.
+type auditSyslogWriter struct {
+	w     io.WriteCloser
+	queue chan []byte
+	done  chan struct{}
+
+	mu     sync.RWMutex // guards closed against concurrent enqueue
+	closed bool
+
+	dropped atomic.Int64
+}
+
+func newAuditSyslogWriter(w io.WriteCloser) *auditSyslogWriter {
+	s := &auditSyslogWriter{w: w, queue: make(chan []byte, auditSyslogQueue), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		for line := range s.queue {
+			if _, err := s.w.Write(line); err != nil {
+				log.Error().Err(err).Msg("audit: syslog write failed")
+			}
+		}
+	}()
+	return s
+}
+
+func (s *auditSyslogWriter) write(line []byte) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.queue <- line:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *auditSyslogWriter) close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		<-s.done
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	<-s.done
+	return s.w.Close()
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package monitoring
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// openAuditSyslog connects to syslog with the auth facility. An empty
+// network uses the local daemon.
+func openAuditSyslog(network, addr, tag string) (io.WriteCloser, error) {
+	return syslog.Dial(network, addr, syslog.LOG_AUTH|syslog.LOG_INFO, tag)
+}
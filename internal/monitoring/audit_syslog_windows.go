@@ -0,0 +1,13 @@
+//go:build windows
+
+package monitoring
+
+import (
+	"errors"
+	"io"
+)
+
+// openAuditSyslog fails: Windows has no syslog. Use the file sink.
+func openAuditSyslog(_, _, _ string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog sink is not supported on windows")
+}
@@ -0,0 +1,104 @@
+// Package monitoring - comparison_log.go keeps recent per-request compression samples.
+//
+// Each sample is the set of CompressionComparison records for one request
+// that compressed at least one tool output, with original and forwarded
+// content side by side. The dashboard's diff view reads these so users can
+// judge compression quality without digging through tool_output_compression.jsonl.
+package monitoring
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxComparisonSamples = 25
+
+// maxComparisonContentBytes caps each stored side of a comparison; a sample can
+// hold dozens of large tool outputs.
+const maxComparisonContentBytes = 64 * 1024
+
+// ComparisonSample is the compression record for one request.
+type ComparisonSample struct {
+	RequestID   string                  `json:"request_id"`
+	SessionID   string                  `json:"session_id,omitempty"`
+	Model       string                  `json:"model,omitempty"`
+	Timestamp   time.Time               `json:"timestamp"`
+	Comparisons []CompressionComparison `json:"comparisons"`
+}
+
+// ComparisonSampleSummary lists a sample without its content.
+type ComparisonSampleSummary struct {
+	RequestID        string    `json:"request_id"`
+	SessionID        string    `json:"session_id,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+	Outputs          int       `json:"outputs"`
+	OriginalTokens   int       `json:"original_tokens"`
+	CompressedTokens int       `json:"compressed_tokens"`
+}
+
+// ComparisonLog keeps a ring buffer of recent comparison samples.
+type ComparisonLog struct {
+	buf *RingBuffer[ComparisonSample]
+}
+
+// NewComparisonLog creates a new comparison log.
+func NewComparisonLog() *ComparisonLog {
+	return &ComparisonLog{buf: NewRingBuffer[ComparisonSample](maxComparisonSamples)}
+}
+
+// Reset clears all samples so the log starts fresh for a new session.
+func (l *ComparisonLog) Reset() { l.buf.Reset() }
+
+// Record stores a sample, truncating oversized content. Empty samples are dropped.
+func (l *ComparisonLog) Record(sample ComparisonSample) {
+	if len(sample.Comparisons) == 0 {
+		return
+	}
+	comps := make([]CompressionComparison, len(sample.Comparisons))
+	for i, c := range sample.Comparisons {
+		c.OriginalContent = truncateContent(c.OriginalContent)
+		c.CompressedContent = truncateContent(c.CompressedContent)
+		c.AllTools, c.SelectedTools = nil, nil
+		comps[i] = c
+	}
+	sample.Comparisons = comps
+	l.buf.Record(sample)
+}
+
+// Recent returns summaries of the most recent N samples (newest first).
+func (l *ComparisonLog) Recent(n int) []ComparisonSampleSummary {
+	samples := l.buf.Recent(n)
+	out := make([]ComparisonSampleSummary, len(samples))
+	for i, s := range samples {
+		sum := ComparisonSampleSummary{
+			RequestID: s.RequestID,
+			SessionID: s.SessionID,
+			Model:     s.Model,
+			Timestamp: s.Timestamp,
+			Outputs:   len(s.Comparisons),
+		}
+		for _, c := range s.Comparisons {
+			sum.OriginalTokens += c.OriginalTokens
+			sum.CompressedTokens += c.CompressedTokens
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+// Get returns the sample for requestID.
+func (l *ComparisonLog) Get(requestID string) (ComparisonSample, bool) {
+	matches := l.buf.RecentWhere(1, func(s ComparisonSample) bool { return s.RequestID == requestID })
+	if len(matches) == 0 {
+		return ComparisonSample{}, false
+	}
+	return matches[0], true
+}
+
+func truncateContent(s string) string {
+	if len(s) <= maxComparisonContentBytes {
+		return s
+	}
+	return s[:maxComparisonContentBytes] + fmt.Sprintf("\n… [truncated %d bytes]", len(s)-maxComparisonContentBytes)
+}
@@ -0,0 +1,58 @@
+// Package monitoring - errors.go defines the request error taxonomy.
+//
+// RequestEvent.Error stays a free-form message for humans; ErrorCode is the
+// stable, low-cardinality classification used for aggregation in /stats and
+// telemetry analysis.
+package monitoring
+
+import "net/http"
+
+// ErrorCode classifies why a request failed or degraded.
+type ErrorCode string
+
+const (
+	ErrorCodeUpstreamTimeout     ErrorCode = "upstream_timeout"     // Upstream did not answer in time
+	ErrorCodeUpstreamUnavailable ErrorCode = "upstream_unavailable" // Connection refused, DNS, TLS, reset
+	ErrorCodeUpstream4xx         ErrorCode = "upstream_4xx"         // Upstream rejected the request
+	ErrorCodeUpstream5xx         ErrorCode = "upstream_5xx"         // Upstream server error
+	ErrorCodeCompressionFailed   ErrorCode = "compression_failed"   // A pipe failed; request forwarded uncompressed
+	ErrorCodeBudgetExceeded      ErrorCode = "budget_exceeded"      // Cost control blocked the request
+	ErrorCodeInvalidRequest      ErrorCode = "invalid_request"      // Malformed or unsupported client request
+	ErrorCodePhantomLoopFailed   ErrorCode = "phantom_loop_failed"  // Phantom tool loop could not complete
+	ErrorCodeHostNotAllowed      ErrorCode = "host_not_allowed"     // Target host rejected by SSRF allowlist
+	ErrorCodePIIMaskingFailed    ErrorCode = "pii_masking_failed"   // PII detector failed; request not forwarded
+	ErrorCodeKeyPinViolation     ErrorCode = "key_pin_violation"    // Provider key sent to a host it is not pinned to
+	ErrorCodeUnsupportedVersion  ErrorCode = "unsupported_version"  // Client API version rejected by api_versions
+	ErrorCodePriorityShed        ErrorCode = "priority_shed"        // Lower-priority request queued too long or shed near a budget cap
+	ErrorCodeCircuitOpen         ErrorCode = "circuit_open"         // Upstream host's circuit breaker is open; not forwarded
+	ErrorCodePipeRejected        ErrorCode = "pipe_rejected"        // A custom pipe refused the request; not forwarded
+	ErrorCodePromptInjection     ErrorCode = "prompt_injection"     // Injection pipe blocked a tool output; not forwarded
+	ErrorCodeTenantRejected      ErrorCode = "tenant_rejected"      // No acceptable tenant for the request (tenancy); not forwarded
+	ErrorCodeClientUnauthorized  ErrorCode = "client_unauthorized"  // No valid gateway key or client certificate (client_auth); not forwarded
+)
+
+// Retryable reports whether a client retrying the same request may succeed.
+// Status-dependent codes (upstream_4xx) should use ClassifyStatus instead.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrorCodeUpstreamTimeout, ErrorCodeUpstreamUnavailable, ErrorCodeUpstream5xx, ErrorCodePhantomLoopFailed, ErrorCodePIIMaskingFailed, ErrorCodePriorityShed, ErrorCodeCircuitOpen:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClassifyStatus maps an upstream HTTP status to an error code and retryable flag.
+// Returns an empty code for non-error statuses. 408 and 429 are retryable 4xx.
+func ClassifyStatus(status int) (ErrorCode, bool) {
+	switch {
+	case status < 400:
+		return "", false
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests:
+		return ErrorCodeUpstream4xx, true
+	case status < 500:
+		return ErrorCodeUpstream4xx, false
+	default:
+		return ErrorCodeUpstream5xx, true
+	}
+}
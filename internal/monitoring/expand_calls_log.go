@@ -2,10 +2,7 @@
 package monitoring
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sync"
+	"errors"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -29,49 +26,44 @@ type ExpandContextCallEntry struct {
 // ExpandCallsLogger appends ExpandContextCallEntry records to a JSONL file.
 // Thread-safe. Safe to call on a nil receiver (disabled).
 type ExpandCallsLogger struct {
-	mu   sync.Mutex
-	file *os.File
+	w *AsyncWriter
 }
 
 // NewExpandCallsLogger opens (or creates) the JSONL file for append.
 // Returns nil if path is empty (feature disabled).
-func NewExpandCallsLogger(path string) (*ExpandCallsLogger, error) {
+func NewExpandCallsLogger(path string, cfg AsyncWriterConfig) (*ExpandCallsLogger, error) {
 	if path == "" {
 		return nil, nil
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
-		return nil, err
-	}
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304
+	w, err := OpenAsyncWriter(path, cfg)
 	if err != nil {
 		return nil, err
 	}
-	return &ExpandCallsLogger{file: f}, nil
+	return &ExpandCallsLogger{w: w}, nil
 }
 
-// Log appends an entry to the JSONL file. Safe to call on nil.
+// Log enqueues an entry for the JSONL file. Safe to call on nil.
 func (l *ExpandCallsLogger) Log(entry ExpandContextCallEntry) {
 	if l == nil {
 		return
 	}
-	data, err := json.Marshal(entry)
-	if err != nil {
+	if err := l.w.WriteJSONL(entry); err != nil && !errors.Is(err, ErrDropped) {
 		log.Error().Err(err).Msg("expand_calls: marshal failed")
-		return
-	}
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if _, err := l.file.Write(append(data, '\n')); err != nil {
-		log.Error().Err(err).Msg("expand_calls: write failed")
 	}
 }
 
-// Close flushes and closes the file. Safe to call on nil.
+// Close drains and closes the file. Safe to call on nil.
 func (l *ExpandCallsLogger) Close() {
 	if l == nil {
 		return
 	}
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	_ = l.file.Close()
+	_ = l.w.Close()
+}
+
+// writer returns the underlying writer (nil when disabled).
+func (l *ExpandCallsLogger) writer() *AsyncWriter {
+	if l == nil {
+		return nil
+	}
+	return l.w
 }
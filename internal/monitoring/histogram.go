@@ -0,0 +1,61 @@
+// Package monitoring - histogram.go tracks latency in fixed buckets (for Prometheus histograms).
+package monitoring
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are upper bounds in seconds, sized for LLM calls:
+// sub-second cache hits up to multi-minute agent turns.
+var DefaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Histogram counts observations into fixed buckets, like a Prometheus histogram.
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []int64 // Per bucket, not cumulative; last is +Inf
+	count   int64
+	sum     float64
+}
+
+// NewHistogram creates a histogram with the given ascending upper bounds (seconds).
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, buckets: make([]int64, len(bounds)+1)}
+}
+
+// Observe adds one sample.
+func (h *Histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.buckets[i]++
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// HistogramSnapshot is a point-in-time view of a Histogram. Counts are
+// cumulative and align with Bounds; Count includes the +Inf bucket.
+type HistogramSnapshot struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int64   `json:"counts"`
+	Count  int64     `json:"count"`
+	Sum    float64   `json:"sum"`
+}
+
+// Snapshot returns cumulative bucket counts.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HistogramSnapshot{Bounds: h.bounds, Counts: make([]int64, len(h.bounds)), Count: h.count, Sum: h.sum}
+	var cum int64
+	for i := range h.bounds {
+		cum += h.buckets[i]
+		s.Counts[i] = cum
+	}
+	return s
+}
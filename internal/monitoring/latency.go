@@ -0,0 +1,96 @@
+// Package monitoring - latency.go tracks latency percentiles over a sliding window.
+package monitoring
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyWindow is how many recent samples percentiles are computed over.
+const DefaultLatencyWindow = 1024
+
+// LatencyWindow keeps recent latency samples for percentiles plus lifetime
+// count and sum (for Prometheus summaries).
+type LatencyWindow struct {
+	samples *RingBuffer[time.Duration]
+	count   atomic.Int64
+	sumNs   atomic.Int64
+}
+
+// NewLatencyWindow creates a window holding the last size samples.
+func NewLatencyWindow(size int) *LatencyWindow {
+	return &LatencyWindow{samples: NewRingBuffer[time.Duration](size)}
+}
+
+// Record adds a sample.
+func (lw *LatencyWindow) Record(d time.Duration) {
+	lw.samples.Record(d)
+	lw.count.Add(1)
+	lw.sumNs.Add(int64(d))
+}
+
+// Reset clears all samples and totals.
+func (lw *LatencyWindow) Reset() {
+	lw.samples.Reset()
+	lw.count.Store(0)
+	lw.sumNs.Store(0)
+}
+
+// LatencySummary is a point-in-time view of a LatencyWindow.
+// Percentiles cover the recent window; Count and SumMs are lifetime totals.
+type LatencySummary struct {
+	Count int64   `json:"count"`
+	SumMs float64 `json:"sum_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// Summary computes percentiles over the current window.
+func (lw *LatencyWindow) Summary() LatencySummary {
+	s := LatencySummary{
+		Count: lw.count.Load(),
+		SumMs: durationMs(time.Duration(lw.sumNs.Load())),
+	}
+	samples := lw.samples.All()
+	if len(samples) == 0 {
+		return s
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	s.P50Ms = durationMs(percentile(samples, 0.50))
+	s.P90Ms = durationMs(percentile(samples, 0.90))
+	s.P99Ms = durationMs(percentile(samples, 0.99))
+	return s
+}
+
+// Len returns the number of samples currently in the window.
+func (lw *LatencyWindow) Len() int {
+	return lw.samples.Count()
+}
+
+// Percentile returns the q-th percentile (0..1) of the current window, or 0 when empty.
+func (lw *LatencyWindow) Percentile(q float64) time.Duration {
+	samples := lw.samples.All()
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return percentile(samples, q)
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	idx := int(q*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -2,6 +2,7 @@
 package monitoring
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,11 +15,53 @@ type MetricsCollector struct {
 	compressions atomic.Int64
 	cacheHits    atomic.Int64
 	cacheMisses  atomic.Int64
+
+	cacheInvalidations atomic.Int64 // Pipe output changed the prompt-cache prefix
+
+	// Slow streaming clients
+	slowWriteTimeouts atomic.Int64 // Client writes that hit the stream write deadline
+	slowClosed        atomic.Int64 // Streams closed because the relay buffer filled up
+	slowDropped       atomic.Int64 // SSE events dropped because the relay buffer was full
+
+	// Phantom tool loops (expand_context, tool search follow-ups)
+	phantomLoops        atomic.Int64 // Loop iterations, i.e. extra upstream calls
+	phantomLoopRequests atomic.Int64 // Requests that ran at least one iteration
+
+	providerMu sync.Mutex
+	providers  map[string]*providerMetrics // Upstream requests by provider
+
+	errMu  sync.Mutex
+	errors map[ErrorCode]int64 // Failures by taxonomy code
+
+	retryMu sync.Mutex
+	retries map[string]int64 // Upstream retries by reason (status code or "network")
+
+	customMu    sync.Mutex
+	customPipes map[string]*customPipeMetrics // Custom pipe runs by pipe name
+
+	injectionMu         sync.Mutex
+	injectionRequests   map[string]int64 // Requests with prompt-injection findings, by action
+	injectionDetections map[string]int64 // Prompt-injection findings by detector
+
+	// Streaming latency, measured from request arrival to what the client sees.
+	firstByte  *LatencyWindow // First byte relayed to the client (TTFB)
+	firstToken *LatencyWindow // First content delta relayed to the client (TTFT)
+	streamTime *LatencyWindow // Full stream duration
 }
 
 // NewMetricsCollector creates a new metrics collector.
 func NewMetricsCollector() *MetricsCollector {
-	return &MetricsCollector{}
+	return &MetricsCollector{
+		errors:              make(map[ErrorCode]int64),
+		retries:             make(map[string]int64),
+		providers:           make(map[string]*providerMetrics),
+		customPipes:         make(map[string]*customPipeMetrics),
+		injectionRequests:   make(map[string]int64),
+		injectionDetections: make(map[string]int64),
+		firstByte:           NewLatencyWindow(DefaultLatencyWindow),
+		firstToken:          NewLatencyWindow(DefaultLatencyWindow),
+		streamTime:          NewLatencyWindow(DefaultLatencyWindow),
+	}
 }
 
 // RecordRequest records a request.
@@ -43,6 +86,230 @@ func (mc *MetricsCollector) RecordCacheHit() { mc.cacheHits.Add(1) }
 // RecordCacheMiss records a cache miss.
 func (mc *MetricsCollector) RecordCacheMiss() { mc.cacheMisses.Add(1) }
 
+// RecordCacheInvalidation records a pipe modifying content before the final
+// cache_control breakpoint (a provider prompt-cache miss).
+func (mc *MetricsCollector) RecordCacheInvalidation() { mc.cacheInvalidations.Add(1) }
+
+// SlowClientEvent is a slow streaming client outcome counted by RecordSlowClient.
+type SlowClientEvent string
+
+const (
+	SlowClientWriteTimeout SlowClientEvent = "write_timeout" // A client write hit its deadline
+	SlowClientClosed       SlowClientEvent = "closed"        // A stream was closed on a full relay buffer
+	SlowClientDropped      SlowClientEvent = "dropped_event" // An SSE event was dropped on a full relay buffer
+)
+
+// RecordSlowClient counts n slow-client events of one kind.
+func (mc *MetricsCollector) RecordSlowClient(ev SlowClientEvent, n int64) {
+	switch ev {
+	case SlowClientWriteTimeout:
+		mc.slowWriteTimeouts.Add(n)
+	case SlowClientClosed:
+		mc.slowClosed.Add(n)
+	case SlowClientDropped:
+		mc.slowDropped.Add(n)
+	}
+}
+
+// RecordPhantomLoops records the phantom loop iterations one request ran.
+func (mc *MetricsCollector) RecordPhantomLoops(n int) {
+	if n <= 0 {
+		return
+	}
+	mc.phantomLoops.Add(int64(n))
+	mc.phantomLoopRequests.Add(1)
+}
+
+// providerMetrics counts one provider's requests.
+type providerMetrics struct {
+	requests int64
+	failures int64
+	latency  *Histogram
+}
+
+// ProviderStats is a point-in-time view of one provider's requests.
+type ProviderStats struct {
+	Requests int64             `json:"requests"`
+	Failures int64             `json:"failures"` // Status >= 400 or transport error
+	Latency  HistogramSnapshot `json:"latency"`  // Arrival to completion, seconds
+}
+
+// RecordProviderRequest records a completed request to an upstream provider.
+func (mc *MetricsCollector) RecordProviderRequest(provider string, success bool, latency time.Duration) {
+	if provider == "" {
+		provider = "unknown"
+	}
+	mc.providerMu.Lock()
+	pm := mc.providers[provider]
+	if pm == nil {
+		pm = &providerMetrics{latency: NewHistogram(DefaultLatencyBuckets)}
+		mc.providers[provider] = pm
+	}
+	pm.requests++
+	if !success {
+		pm.failures++
+	}
+	mc.providerMu.Unlock()
+	pm.latency.Observe(latency)
+}
+
+// ProviderStats returns request counts and latency histograms by provider.
+func (mc *MetricsCollector) ProviderStats() map[string]ProviderStats {
+	mc.providerMu.Lock()
+	defer mc.providerMu.Unlock()
+	out := make(map[string]ProviderStats, len(mc.providers))
+	for name, pm := range mc.providers {
+		out[name] = ProviderStats{Requests: pm.requests, Failures: pm.failures, Latency: pm.latency.Snapshot()}
+	}
+	return out
+}
+
+// customPipeMetrics counts one custom pipe's runs.
+type customPipeMetrics struct {
+	outcomes map[string]int64
+	latency  *Histogram
+}
+
+// CustomPipeStats is a point-in-time view of one custom pipe's runs.
+type CustomPipeStats struct {
+	Runs     int64             `json:"runs"`
+	Outcomes map[string]int64  `json:"outcomes"` // unchanged, modified, failed, rejected
+	Latency  HistogramSnapshot `json:"latency"`  // Seconds
+}
+
+// RecordCustomPipe records a custom pipe run.
+func (mc *MetricsCollector) RecordCustomPipe(run CustomPipeRun) {
+	mc.customMu.Lock()
+	cm := mc.customPipes[run.Pipe]
+	if cm == nil {
+		cm = &customPipeMetrics{outcomes: make(map[string]int64), latency: NewHistogram(DefaultLatencyBuckets)}
+		mc.customPipes[run.Pipe] = cm
+	}
+	cm.outcomes[run.Outcome]++
+	mc.customMu.Unlock()
+	cm.latency.Observe(time.Duration(run.DurationMs) * time.Millisecond)
+}
+
+// CustomPipeStats returns run counts and latency histograms by custom pipe.
+func (mc *MetricsCollector) CustomPipeStats() map[string]CustomPipeStats {
+	mc.customMu.Lock()
+	defer mc.customMu.Unlock()
+	out := make(map[string]CustomPipeStats, len(mc.customPipes))
+	for name, cm := range mc.customPipes {
+		st := CustomPipeStats{Outcomes: make(map[string]int64, len(cm.outcomes)), Latency: cm.latency.Snapshot()}
+		for outcome, n := range cm.outcomes {
+			st.Outcomes[outcome] = n
+			st.Runs += n
+		}
+		out[name] = st
+	}
+	return out
+}
+
+// PromptInjectionStats counts prompt-injection findings.
+type PromptInjectionStats struct {
+	Requests   map[string]int64 `json:"requests"`   // Requests with findings, by action (flag, strip, block)
+	Detections map[string]int64 `json:"detections"` // Findings by detector
+}
+
+// RecordPromptInjection records one request's prompt-injection findings.
+func (mc *MetricsCollector) RecordPromptInjection(report *PromptInjectionReport) {
+	if report == nil || len(report.Findings) == 0 {
+		return
+	}
+	mc.injectionMu.Lock()
+	defer mc.injectionMu.Unlock()
+	mc.injectionRequests[report.Action]++
+	for _, f := range report.Findings {
+		mc.injectionDetections[f.Detector]++
+	}
+}
+
+// PromptInjectionStats returns prompt-injection counts.
+func (mc *MetricsCollector) PromptInjectionStats() PromptInjectionStats {
+	mc.injectionMu.Lock()
+	defer mc.injectionMu.Unlock()
+	out := PromptInjectionStats{
+		Requests:   make(map[string]int64, len(mc.injectionRequests)),
+		Detections: make(map[string]int64, len(mc.injectionDetections)),
+	}
+	for k, v := range mc.injectionRequests {
+		out.Requests[k] = v
+	}
+	for k, v := range mc.injectionDetections {
+		out.Detections[k] = v
+	}
+	return out
+}
+
+// RecordError records a failure by taxonomy code.
+func (mc *MetricsCollector) RecordError(code ErrorCode) {
+	if code == "" {
+		return
+	}
+	mc.errMu.Lock()
+	mc.errors[code]++
+	mc.errMu.Unlock()
+}
+
+// ErrorCounts returns a snapshot of failure counts by code.
+func (mc *MetricsCollector) ErrorCounts() map[string]int64 {
+	mc.errMu.Lock()
+	defer mc.errMu.Unlock()
+	out := make(map[string]int64, len(mc.errors))
+	for code, n := range mc.errors {
+		out[string(code)] = n
+	}
+	return out
+}
+
+// RecordUpstreamRetry counts one retried upstream forward.
+func (mc *MetricsCollector) RecordUpstreamRetry(reason string) {
+	mc.retryMu.Lock()
+	mc.retries[reason]++
+	mc.retryMu.Unlock()
+}
+
+// UpstreamRetryCounts returns a snapshot of upstream retries by reason.
+func (mc *MetricsCollector) UpstreamRetryCounts() map[string]int64 {
+	mc.retryMu.Lock()
+	defer mc.retryMu.Unlock()
+	out := make(map[string]int64, len(mc.retries))
+	for reason, n := range mc.retries {
+		out[reason] = n
+	}
+	return out
+}
+
+// RecordStreamLatency records time-to-first-byte, time-to-first-token, and total
+// duration for a streaming response. Zero TTFB/TTFT values are skipped (nothing
+// was relayed, or the stream carried no content delta).
+func (mc *MetricsCollector) RecordStreamLatency(firstByte, firstToken, total time.Duration) {
+	if firstByte > 0 {
+		mc.firstByte.Record(firstByte)
+	}
+	if firstToken > 0 {
+		mc.firstToken.Record(firstToken)
+	}
+	mc.streamTime.Record(total)
+}
+
+// StreamLatencyStats groups streaming latency summaries.
+type StreamLatencyStats struct {
+	FirstByte  LatencySummary `json:"first_byte"`
+	FirstToken LatencySummary `json:"first_token"`
+	Total      LatencySummary `json:"total"`
+}
+
+// StreamLatency returns percentiles for streaming responses.
+func (mc *MetricsCollector) StreamLatency() StreamLatencyStats {
+	return StreamLatencyStats{
+		FirstByte:  mc.firstByte.Summary(),
+		FirstToken: mc.firstToken.Summary(),
+		Total:      mc.streamTime.Summary(),
+	}
+}
+
 // Stats returns current metrics.
 func (mc *MetricsCollector) Stats() map[string]int64 {
 	return map[string]int64{
@@ -52,6 +319,15 @@ func (mc *MetricsCollector) Stats() map[string]int64 {
 		"compressions": mc.compressions.Load(),
 		"cache_hits":   mc.cacheHits.Load(),
 		"cache_misses": mc.cacheMisses.Load(),
+
+		"cache_invalidations": mc.cacheInvalidations.Load(),
+
+		"slow_client_write_timeouts": mc.slowWriteTimeouts.Load(),
+		"slow_client_closed":         mc.slowClosed.Load(),
+		"slow_client_dropped_events": mc.slowDropped.Load(),
+
+		"phantom_loops":         mc.phantomLoops.Load(),
+		"phantom_loop_requests": mc.phantomLoopRequests.Load(),
 	}
 }
 
@@ -63,6 +339,31 @@ func (mc *MetricsCollector) Reset() {
 	mc.compressions.Store(0)
 	mc.cacheHits.Store(0)
 	mc.cacheMisses.Store(0)
+	mc.cacheInvalidations.Store(0)
+	mc.slowWriteTimeouts.Store(0)
+	mc.slowClosed.Store(0)
+	mc.slowDropped.Store(0)
+	mc.phantomLoops.Store(0)
+	mc.phantomLoopRequests.Store(0)
+	mc.providerMu.Lock()
+	mc.providers = make(map[string]*providerMetrics)
+	mc.providerMu.Unlock()
+	mc.errMu.Lock()
+	mc.errors = make(map[ErrorCode]int64)
+	mc.errMu.Unlock()
+	mc.retryMu.Lock()
+	mc.retries = make(map[string]int64)
+	mc.retryMu.Unlock()
+	mc.customMu.Lock()
+	mc.customPipes = make(map[string]*customPipeMetrics)
+	mc.customMu.Unlock()
+	mc.injectionMu.Lock()
+	mc.injectionRequests = make(map[string]int64)
+	mc.injectionDetections = make(map[string]int64)
+	mc.injectionMu.Unlock()
+	mc.firstByte.Reset()
+	mc.firstToken.Reset()
+	mc.streamTime.Reset()
 }
 
 // Stop is a no-op for compatibility.
@@ -0,0 +1,192 @@
+// Package monitoring - retention.go rotates and prunes JSONL logs.
+//
+// An AsyncWriter with retention enabled renames its file aside when it
+// reaches max_size_mb or rotate_every, then gzips the rotated file and
+// deletes rotated files beyond max_files or older than max_age. Rotated
+// files sit next to the log as <name>-<timestamp><ext>.gz, so a sorted
+// directory listing is also chronological.
+package monitoring
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Retention defaults (applied when RetentionConfig fields are zero).
+const (
+	DefaultRetentionMaxSizeMB = 100
+	DefaultRetentionMaxFiles  = 10
+)
+
+// rotatedTimeFormat is the timestamp in rotated file names. Sorts lexically.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// RetentionConfig bounds the disk used by JSONL logs (monitoring.retention).
+type RetentionConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	MaxSizeMB   int           `yaml:"max_size_mb"`  // Rotate a log when it reaches this size (default: 100)
+	RotateEvery time.Duration `yaml:"rotate_every"` // Also rotate logs older than this (default: size only)
+	MaxFiles    int           `yaml:"max_files"`    // Rotated files kept per log (default: 10)
+	MaxAge      time.Duration `yaml:"max_age"`      // Delete rotated logs and session trajectories older than this (default: no age limit)
+}
+
+// WithDefaults fills zero fields with defaults.
+func (c RetentionConfig) WithDefaults() RetentionConfig {
+	if c.MaxSizeMB <= 0 {
+		c.MaxSizeMB = DefaultRetentionMaxSizeMB
+	}
+	if c.MaxFiles <= 0 {
+		c.MaxFiles = DefaultRetentionMaxFiles
+	}
+	return c
+}
+
+// Validate checks retention limits.
+func (c RetentionConfig) Validate() error {
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("max_size_mb must not be negative, got %d", c.MaxSizeMB)
+	}
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("max_files must not be negative, got %d", c.MaxFiles)
+	}
+	if c.RotateEvery < 0 {
+		return fmt.Errorf("rotate_every must not be negative, got %s", c.RotateEvery)
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative, got %s", c.MaxAge)
+	}
+	return nil
+}
+
+// maxSizeBytes returns the rotation size in bytes.
+func (c RetentionConfig) maxSizeBytes() int64 {
+	return int64(c.MaxSizeMB) * 1024 * 1024
+}
+
+// RotatedName returns the name a log at path is rotated to at t, before compression.
+func RotatedName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + t.UTC().Format(rotatedTimeFormat) + ext
+}
+
+// RotatedFiles returns the rotated files of the log at path, oldest first.
+// Files whose compression was interrupted (no .gz suffix) are included.
+func RotatedFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	prefix := filepath.Base(strings.TrimSuffix(path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if _, ok := rotatedTime(e.Name(), prefix, ext); ok && !e.IsDir() {
+			files = append(files, filepath.Join(filepath.Dir(path), e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// rotatedTime parses the timestamp of a rotated file name.
+func rotatedTime(name, prefix, ext string) (time.Time, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+	t, err := time.Parse(rotatedTimeFormat, stamp)
+	return t, err == nil
+}
+
+// PruneRotated deletes rotated files of the log at path beyond cfg.MaxFiles
+// (oldest first) or older than cfg.MaxAge. It returns the deleted files.
+func PruneRotated(path string, cfg RetentionConfig, now time.Time) ([]string, error) {
+	cfg = cfg.WithDefaults()
+	files, err := RotatedFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	prefix := filepath.Base(strings.TrimSuffix(path, ext)) + "-"
+	var removed []string
+	for i, f := range files {
+		excess := i < len(files)-cfg.MaxFiles
+		rotated, _ := rotatedTime(filepath.Base(f), prefix, ext)
+		expired := cfg.MaxAge > 0 && now.Sub(rotated) > cfg.MaxAge
+		if !excess && !expired {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, f)
+	}
+	return removed, nil
+}
+
+// PruneTrajectories deletes per-session trajectory files in dir whose last
+// write is older than cfg.MaxAge. Without max_age nothing is deleted: each
+// file is one session, so a count limit would drop recent sessions.
+func PruneTrajectories(dir string, cfg RetentionConfig, now time.Time) ([]string, error) {
+	if cfg.MaxAge <= 0 || dir == "" {
+		return nil, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "trajectory_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, f := range matches {
+		info, err := os.Stat(f)
+		if err != nil || now.Sub(info.ModTime()) <= cfg.MaxAge {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, f)
+	}
+	return removed, nil
+}
+
+// gzipFile compresses src to src.gz and removes src. The .gz file only
+// appears once complete, so an interrupted run leaves src to retry.
+func gzipFile(src string) error {
+	in, err := os.Open(src) // #nosec G304 -- rotated log path
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	tmp := src + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) // #nosec G304 -- rotated log path
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, src+".gz")
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_ = in.Close()
+	return os.Remove(src)
+}
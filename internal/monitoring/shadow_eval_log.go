@@ -0,0 +1,80 @@
+// Package monitoring - shadow_eval_log.go writes shadow_eval.jsonl.
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ShadowEvalEntry records what the pipes would have sent for one request in
+// shadow mode (pipes.mode: shadow), where the original request is forwarded.
+type ShadowEvalEntry struct {
+	Timestamp           time.Time        `json:"timestamp"`
+	RequestID           string           `json:"request_id"`
+	SessionID           string           `json:"session_id,omitempty"`
+	Provider            string           `json:"provider"`
+	Model               string           `json:"model,omitempty"`
+	OriginalTokens      int              `json:"original_tokens"`   // Request as forwarded
+	CompressedTokens    int              `json:"compressed_tokens"` // Request the pipes produced
+	TokensSaved         int              `json:"tokens_saved"`
+	CompressionRatio    float64          `json:"compression_ratio"`     // Removed fraction: 1 - compressed/original
+	EstimatedSavingsUSD float64          `json:"estimated_savings_usd"` // Input cost of the saved tokens
+	DurationMs          int64            `json:"duration_ms"`           // Background pipe run time
+	PipeFailed          bool             `json:"pipe_failed,omitempty"` // A pipe errored; its input passed through
+	ToolOutputs         []ShadowEvalTool `json:"tool_outputs,omitempty"`
+	ToolsFiltered       bool             `json:"tools_filtered,omitempty"`
+	OriginalToolCount   int              `json:"original_tool_count,omitempty"`
+	KeptToolCount       int              `json:"kept_tool_count,omitempty"`
+	CustomPipes         []CustomPipeRun  `json:"custom_pipes,omitempty"`
+	Body                json.RawMessage  `json:"body,omitempty"` // Would-be forwarded body (pipes.shadow.log_bodies)
+}
+
+// ShadowEvalTool is one tool output the tool_output pipe saw in shadow mode.
+type ShadowEvalTool struct {
+	ToolName          string `json:"tool_name"`
+	ToolCallID        string `json:"tool_call_id,omitempty"`
+	Status            string `json:"status"` // compressed, cache_hit, passthrough_small, ...
+	OriginalTokens    int    `json:"original_tokens"`
+	CompressedTokens  int    `json:"compressed_tokens"`
+	CompressedContent string `json:"compressed_content,omitempty"` // What the model would have seen
+}
+
+// ShadowEvalLogger appends ShadowEvalEntry records to a JSONL file.
+// Thread-safe. Safe to call on a nil receiver (disabled).
+type ShadowEvalLogger struct {
+	w *AsyncWriter
+}
+
+// NewShadowEvalLogger opens (or creates) the JSONL file for append.
+// Returns nil if path is empty (feature disabled).
+func NewShadowEvalLogger(path string, cfg AsyncWriterConfig) (*ShadowEvalLogger, error) {
+	if path == "" {
+		return nil, nil
+	}
+	w, err := OpenAsyncWriter(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ShadowEvalLogger{w: w}, nil
+}
+
+// Log enqueues an entry for the JSONL file. Safe to call on nil.
+func (l *ShadowEvalLogger) Log(entry ShadowEvalEntry) {
+	if l == nil {
+		return
+	}
+	if err := l.w.WriteJSONL(entry); err != nil && !errors.Is(err, ErrDropped) {
+		log.Error().Err(err).Msg("shadow_eval: marshal failed")
+	}
+}
+
+// writer returns the underlying writer (nil when disabled).
+func (l *ShadowEvalLogger) writer() *AsyncWriter {
+	if l == nil {
+		return nil
+	}
+	return l.w
+}
@@ -0,0 +1,390 @@
+// Package monitoring - tail.go follows the JSONL logs and formats entries for `context-gateway tail`.
+package monitoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TailSource is one JSONL log the tail command can follow.
+type TailSource struct {
+	Pipe string // Name used by --pipe and shown in the output
+	File string // File name within a session directory
+}
+
+// TailSources lists the followable logs, in display order.
+var TailSources = []TailSource{
+	{Pipe: "requests", File: "telemetry.jsonl"},
+	{Pipe: "tool_output", File: "tool_output_compression.jsonl"},
+	{Pipe: "tool_discovery", File: "tool_discovery.jsonl"},
+	{Pipe: "task_output", File: "task_output_compression.jsonl"},
+	{Pipe: "compaction", File: "history_compaction.jsonl"},
+}
+
+// TailEvent is one decoded log line.
+type TailEvent struct {
+	Pipe   string
+	Time   time.Time // Zero when the entry has no parseable timestamp
+	Fields map[string]any
+}
+
+// SessionID returns the entry's session_id ("" when absent).
+func (e TailEvent) SessionID() string {
+	return tailString(e.Fields, "session_id")
+}
+
+// tailFile tracks the read position in one followed file.
+type tailFile struct {
+	pipe    string
+	path    string
+	offset  int64
+	partial []byte // trailing bytes of a line still being written
+}
+
+// Tailer follows the JSONL logs of one session directory. Files that do not
+// exist yet are picked up when the gateway creates them.
+type Tailer struct {
+	files []*tailFile
+}
+
+// NewTailer follows the logs of pipes (all when empty) in dir. Unless
+// fromStart is set, existing content is skipped.
+func NewTailer(dir string, pipes []string, fromStart bool) (*Tailer, error) {
+	for _, p := range pipes {
+		if !isTailPipe(p) {
+			return nil, fmt.Errorf("unknown pipe %q (valid: %s)", p, strings.Join(TailPipeNames(), ", "))
+		}
+	}
+	t := &Tailer{}
+	for _, src := range TailSources {
+		if len(pipes) > 0 && !containsString(pipes, src.Pipe) {
+			continue
+		}
+		f := &tailFile{pipe: src.Pipe, path: filepath.Join(dir, src.File)}
+		if !fromStart {
+			if info, err := os.Stat(f.path); err == nil {
+				f.offset = info.Size()
+			}
+		}
+		t.files = append(t.files, f)
+	}
+	return t, nil
+}
+
+// TailPipeNames returns the valid --pipe values.
+func TailPipeNames() []string {
+	names := make([]string, len(TailSources))
+	for i, src := range TailSources {
+		names[i] = src.Pipe
+	}
+	return names
+}
+
+func isTailPipe(name string) bool {
+	return containsString(TailPipeNames(), name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Poll reads the lines appended since the last call, ordered by timestamp.
+// Malformed lines are skipped.
+func (t *Tailer) Poll() []TailEvent {
+	var events []TailEvent
+	for _, f := range t.files {
+		events = append(events, f.read()...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// Follow polls every interval until ctx is done, passing each event to fn.
+func (t *Tailer) Follow(ctx context.Context, interval time.Duration, fn func(TailEvent)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, ev := range t.Poll() {
+			fn(ev)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *tailFile) read() []TailEvent {
+	file, err := os.Open(f.path) // #nosec G304 -- session log path
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	if info.Size() < f.offset {
+		// Truncated or replaced: start over.
+		f.offset, f.partial = 0, nil
+	}
+	if info.Size() == f.offset {
+		return nil
+	}
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil
+	}
+	f.offset += int64(len(data))
+
+	data = append(f.partial, data...)
+	last := bytes.LastIndexByte(data, '\n')
+	if last < 0 {
+		f.partial = data
+		return nil
+	}
+	f.partial = append([]byte(nil), data[last+1:]...)
+
+	var events []TailEvent
+	for _, line := range bytes.Split(data[:last], []byte{'\n'}) {
+		if ev, ok := decodeTailLine(f.pipe, line); ok {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func decodeTailLine(pipe string, line []byte) (TailEvent, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return TailEvent{}, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return TailEvent{}, false
+	}
+	ev := TailEvent{Pipe: pipe, Fields: fields}
+	if ts := tailString(fields, "timestamp"); ts != "" {
+		if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			ev.Time = parsed
+		}
+	}
+	return ev, true
+}
+
+// FORMATTING
+
+const (
+	tailReset  = "\033[0m"
+	tailDim    = "\033[2m"
+	tailRed    = "\033[0;31m"
+	tailGreen  = "\033[0;32m"
+	tailYellow = "\033[1;33m"
+	tailBlue   = "\033[0;34m"
+	tailCyan   = "\033[0;36m"
+)
+
+// tailPipeColors gives each pipe a stable column color.
+var tailPipeColors = map[string]string{
+	"requests":       tailBlue,
+	"tool_output":    tailGreen,
+	"tool_discovery": tailCyan,
+	"task_output":    tailYellow,
+	"compaction":     tailYellow,
+}
+
+// FormatTailEvent renders ev as one line: time, pipe, then pipe-specific columns.
+func FormatTailEvent(ev TailEvent, color bool) string {
+	paint := func(code, s string) string {
+		if !color || s == "" {
+			return s
+		}
+		return code + s + tailReset
+	}
+
+	ts := "--:--:--"
+	if !ev.Time.IsZero() {
+		ts = ev.Time.Local().Format("15:04:05")
+	}
+
+	var cols []string
+	switch ev.Pipe {
+	case "requests":
+		cols = formatTailRequest(ev.Fields, paint)
+	case "compaction":
+		cols = formatTailCompaction(ev.Fields, paint)
+	default:
+		cols = formatTailCompression(ev.Fields, paint)
+	}
+
+	return fmt.Sprintf("%s %s %s", paint(tailDim, ts), paint(tailPipeColors[ev.Pipe], fmt.Sprintf("%-14s", ev.Pipe)),
+		strings.Join(nonEmpty(cols), "  "))
+}
+
+func formatTailRequest(f map[string]any, paint func(string, string) string) []string {
+	status := tailInt(f, "status_code")
+	statusCol := fmt.Sprintf("%d", status)
+	switch {
+	case status >= 400 || tailString(f, "error") != "":
+		statusCol = paint(tailRed, statusCol)
+	case status >= 200 && status < 300:
+		statusCol = paint(tailGreen, statusCol)
+	}
+
+	cols := []string{statusCol, tailString(f, "model")}
+	if tailBool(f, "compression_used") {
+		cols = append(cols, tokenChange(tailInt(f, "original_tokens"), tailInt(f, "compressed_tokens")))
+	}
+	if in, out := tailInt(f, "input_tokens"), tailInt(f, "output_tokens"); in > 0 || out > 0 {
+		cols = append(cols, paint(tailDim, fmt.Sprintf("in=%s out=%s", compactTokens(in), compactTokens(out))))
+	}
+	if ms := tailInt(f, "total_latency_ms"); ms > 0 {
+		cols = append(cols, formatTailLatency(ms))
+	}
+	if n := tailInt(f, "pii_masked"); n > 0 {
+		cols = append(cols, fmt.Sprintf("pii=%d", n))
+	}
+	if errMsg := tailString(f, "error"); errMsg != "" {
+		cols = append(cols, paint(tailRed, truncateTail(errMsg, 80)))
+	}
+	return cols
+}
+
+func formatTailCompression(f map[string]any, paint func(string, string) string) []string {
+	status := tailString(f, "status")
+	statusCol := status
+	switch {
+	case status == "compressed":
+		statusCol = paint(tailGreen, status)
+	case strings.HasPrefix(status, "passthrough"):
+		statusCol = paint(tailDim, status)
+	case strings.Contains(status, "error") || strings.Contains(status, "fail"):
+		statusCol = paint(tailRed, status)
+	}
+
+	cols := []string{tailString(f, "event_type"), tailString(f, "tool_name"), statusCol}
+	if orig := tailInt(f, "original_tokens"); orig > 0 {
+		cols = append(cols, tokenChange(orig, tailInt(f, "compressed_tokens")))
+	}
+	if n := tailInt(f, "tool_count"); n > 0 {
+		cols = append(cols, fmt.Sprintf("tools=%d stubs=%d", n, tailInt(f, "stub_count")))
+	}
+	if tailBool(f, "cache_hit") {
+		cols = append(cols, paint(tailCyan, "cache_hit"))
+	}
+	if q := tailString(f, "query"); q != "" {
+		cols = append(cols, paint(tailDim, fmt.Sprintf("%q", truncateTail(q, 60))))
+	}
+	return cols
+}
+
+func formatTailCompaction(f map[string]any, paint func(string, string) string) []string {
+	cols := []string{tailString(f, "event"), shortSessionID(tailString(f, "session_id")), tailString(f, "model")}
+	if n := tailInt(f, "messages_summarized"); n > 0 {
+		cols = append(cols, fmt.Sprintf("msgs=%d", n))
+	}
+	if n := tailInt(f, "summary_tokens"); n > 0 {
+		cols = append(cols, fmt.Sprintf("summary=%s", compactTokens(n)))
+	}
+	if pct := tailFloat(f, "usage_percent"); pct > 0 {
+		cols = append(cols, fmt.Sprintf("usage=%.0f%%", pct))
+	}
+	if ms := tailInt(f, "duration_ms"); ms > 0 {
+		cols = append(cols, formatTailLatency(ms))
+	}
+	if errMsg := tailString(f, "error"); errMsg != "" {
+		cols = append(cols, paint(tailRed, truncateTail(errMsg, 80)))
+	}
+	return cols
+}
+
+// tokenChange renders "12.3k→4.1k (-67%)".
+func tokenChange(original, compressed int) string {
+	s := compactTokens(original) + "→" + compactTokens(compressed)
+	if original > 0 {
+		s += fmt.Sprintf(" (-%.0f%%)", 100*(1-float64(compressed)/float64(original)))
+	}
+	return s
+}
+
+func compactTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+func formatTailLatency(ms int) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+func shortSessionID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func truncateTail(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func nonEmpty(cols []string) []string {
+	out := cols[:0]
+	for _, c := range cols {
+		if c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func tailString(f map[string]any, key string) string {
+	s, _ := f[key].(string)
+	return s
+}
+
+func tailInt(f map[string]any, key string) int {
+	return int(tailFloat(f, key))
+}
+
+func tailFloat(f map[string]any, key string) float64 {
+	v, _ := f[key].(float64)
+	return v
+}
+
+func tailBool(f map[string]any, key string) bool {
+	b, _ := f[key].(bool)
+	return b
+}
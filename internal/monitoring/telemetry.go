@@ -4,6 +4,7 @@ package monitoring
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,7 +15,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// bufPool is a package-level pool of *bytes.Buffer reused across JSONL write calls
+// bufPool is a package-level pool of *bytes.Buffer reused across JSONL encode calls
 // to reduce allocations on the hot write path.
 var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
 
@@ -24,12 +25,12 @@ type Tracker struct {
 	requestLogPath       string
 	compressionLogPath   string
 	toolDiscoveryLogPath string
-	taskOutputLogPath    string // unified task output compression log
-	sessionToolsPath     string // path for session_tools.json (pretty-printed catalog)
-	requestLogFile       *os.File
-	compressionLogFile   *os.File
-	toolDiscoveryLogFile *os.File
-	taskOutputLogFile    *os.File
+	taskOutputLogPath    string       // unified task output compression log
+	sessionToolsPath     string       // path for session_tools.json (pretty-printed catalog)
+	requestLog           *AsyncWriter // async JSONL writers: file I/O stays off the request path
+	compressionLog       *AsyncWriter
+	toolDiscoveryLog     *AsyncWriter
+	taskOutputLog        *AsyncWriter
 	requestCount         int
 	compressionCount     int
 	toolDiscoveryCount   int
@@ -37,11 +38,12 @@ type Tracker struct {
 	seenSessionTools     map[string]map[string]bool // sessionID → tool names already in session_tools.json
 	statsTracker         *SessionStatsTracker       // live session_stats.json writer
 	expandCallsLogger    *ExpandCallsLogger         // expand_context_calls.jsonl writer
+	shadowEvalLogger     *ShadowEvalLogger          // shadow_eval.jsonl writer
 	// Per-file mutexes allow concurrent writes to different log files (P7).
-	muRequest       sync.Mutex // guards requestLogFile
-	muCompression   sync.Mutex // guards compressionLogFile
-	muToolDiscovery sync.Mutex // guards toolDiscoveryLogFile
-	muTaskOutput    sync.Mutex // guards taskOutputLogFile
+	muRequest       sync.Mutex // guards requestLog
+	muCompression   sync.Mutex // guards compressionLog
+	muToolDiscovery sync.Mutex // guards toolDiscoveryLog
+	muTaskOutput    sync.Mutex // guards taskOutputLog
 	muSessionTools  sync.Mutex // guards seenSessionTools + sessionToolsPath
 }
 
@@ -62,11 +64,11 @@ func NewTracker(cfg TelemetryConfig) (*Tracker, error) {
 			return nil, err
 		}
 		t.requestLogPath = cfg.LogPath
-		f, err := os.OpenFile(cfg.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		w, err := OpenAsyncWriter(cfg.LogPath, cfg.Writer)
 		if err != nil {
 			return nil, fmt.Errorf("open request log: %w", err)
 		}
-		t.requestLogFile = f
+		t.requestLog = w
 	}
 
 	if cfg.CompressionLogPath != "" {
@@ -74,11 +76,11 @@ func NewTracker(cfg TelemetryConfig) (*Tracker, error) {
 			return nil, err
 		}
 		t.compressionLogPath = cfg.CompressionLogPath
-		f, err := os.OpenFile(cfg.CompressionLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		w, err := OpenAsyncWriter(cfg.CompressionLogPath, cfg.Writer)
 		if err != nil {
 			return nil, fmt.Errorf("open compression log: %w", err)
 		}
-		t.compressionLogFile = f
+		t.compressionLog = w
 	}
 
 	if cfg.ToolDiscoveryLogPath != "" {
@@ -86,11 +88,11 @@ func NewTracker(cfg TelemetryConfig) (*Tracker, error) {
 			return nil, err
 		}
 		t.toolDiscoveryLogPath = cfg.ToolDiscoveryLogPath
-		f, err := os.OpenFile(cfg.ToolDiscoveryLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		w, err := OpenAsyncWriter(cfg.ToolDiscoveryLogPath, cfg.Writer)
 		if err != nil {
 			return nil, fmt.Errorf("open tool discovery log: %w", err)
 		}
-		t.toolDiscoveryLogFile = f
+		t.toolDiscoveryLog = w
 	}
 
 	// Task output unified compression log: {base}_compression.jsonl
@@ -100,11 +102,11 @@ func NewTracker(cfg TelemetryConfig) (*Tracker, error) {
 			return nil, err
 		}
 		t.taskOutputLogPath = taskOutputCompLog
-		f, err := os.OpenFile(taskOutputCompLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 -- path is from config, cleaned with filepath.Clean
+		w, err := OpenAsyncWriter(taskOutputCompLog, cfg.Writer)
 		if err != nil {
 			return nil, fmt.Errorf("open task output log: %w", err)
 		}
-		t.taskOutputLogFile = f
+		t.taskOutputLog = w
 	}
 
 	if cfg.SessionToolsPath != "" {
@@ -120,28 +122,22 @@ func NewTracker(cfg TelemetryConfig) (*Tracker, error) {
 	}
 
 	if cfg.ExpandContextCallsPath != "" {
-		el, err := NewExpandCallsLogger(cfg.ExpandContextCallsPath)
+		el, err := NewExpandCallsLogger(cfg.ExpandContextCallsPath, cfg.Writer)
 		if err != nil {
 			return nil, fmt.Errorf("open expand_context_calls log: %w", err)
 		}
 		t.expandCallsLogger = el
 	}
 
-	return t, nil
-}
+	if cfg.ShadowEvalPath != "" {
+		sl, err := NewShadowEvalLogger(cfg.ShadowEvalPath, cfg.Writer)
+		if err != nil {
+			return nil, fmt.Errorf("open shadow_eval log: %w", err)
+		}
+		t.shadowEvalLogger = sl
+	}
 
-// writeJSONL writes a single JSON object as a line to an open file handle.
-// Uses bufPool to reuse buffer allocations on the hot write path.
-func writeJSONL(f *os.File, event any) error {
-	buf := bufPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	if err := json.NewEncoder(buf).Encode(event); err != nil {
-		bufPool.Put(buf)
-		return err
-	}
-	_, err := f.Write(buf.Bytes())
-	bufPool.Put(buf)
-	return err
+	return t, nil
 }
 
 // RecordRequest records a request event.
@@ -171,11 +167,11 @@ func (t *Tracker) RecordRequest(event *RequestEvent) {
 	}
 
 	// Append to JSONL file
-	if t.requestLogFile != nil {
-		if err := writeJSONL(t.requestLogFile, event); err != nil {
-			log.Error().Err(err).Str("path", t.requestLogPath).Msg("telemetry: failed to write request event")
-		} else {
+	if t.requestLog != nil {
+		if err := t.requestLog.WriteJSONL(event); err == nil {
 			t.requestCount++
+		} else if !errors.Is(err, ErrDropped) {
+			log.Error().Err(err).Str("path", t.requestLogPath).Msg("telemetry: failed to encode request event")
 		}
 	}
 }
@@ -190,11 +186,11 @@ func (t *Tracker) RecordExpand(event *ExpandEvent) {
 	defer t.muRequest.Unlock()
 
 	// Append to JSONL file
-	if t.requestLogFile != nil {
-		if err := writeJSONL(t.requestLogFile, event); err != nil {
-			log.Error().Err(err).Str("path", t.requestLogPath).Msg("telemetry: failed to write expand event")
-		} else {
+	if t.requestLog != nil {
+		if err := t.requestLog.WriteJSONL(event); err == nil {
 			t.requestCount++
+		} else if !errors.Is(err, ErrDropped) {
+			log.Error().Err(err).Str("path", t.requestLogPath).Msg("telemetry: failed to encode expand event")
 		}
 	}
 }
@@ -251,13 +247,13 @@ func (t *Tracker) LogCompressionComparison(c CompressionComparison) {
 	t.muCompression.Lock()
 	defer t.muCompression.Unlock()
 
-	if t.compressionLogFile == nil {
+	if t.compressionLog == nil {
 		return
 	}
-	if err := writeJSONL(t.compressionLogFile, entry); err != nil {
-		log.Error().Err(err).Str("path", t.compressionLogPath).Msg("telemetry: failed to write compression event")
-	} else {
+	if err := t.compressionLog.WriteJSONL(entry); err == nil {
 		t.compressionCount++
+	} else if !errors.Is(err, ErrDropped) {
+		log.Error().Err(err).Str("path", t.compressionLogPath).Msg("telemetry: failed to encode compression event")
 	}
 }
 
@@ -266,13 +262,13 @@ func (t *Tracker) LogCompressionComparison(c CompressionComparison) {
 func (t *Tracker) writeToolDiscovery(entry any) {
 	t.muToolDiscovery.Lock()
 	defer t.muToolDiscovery.Unlock()
-	if t.toolDiscoveryLogFile == nil {
+	if t.toolDiscoveryLog == nil {
 		return
 	}
-	if err := writeJSONL(t.toolDiscoveryLogFile, entry); err != nil {
-		log.Error().Err(err).Str("path", t.toolDiscoveryLogPath).Msg("telemetry: failed to write tool discovery event")
-	} else {
+	if err := t.toolDiscoveryLog.WriteJSONL(entry); err == nil {
 		t.toolDiscoveryCount++
+	} else if !errors.Is(err, ErrDropped) {
+		log.Error().Err(err).Str("path", t.toolDiscoveryLogPath).Msg("telemetry: failed to encode tool discovery event")
 	}
 }
 
@@ -336,13 +332,13 @@ func (t *Tracker) LogTaskOutputComparison(c CompressionComparison) {
 
 	t.muTaskOutput.Lock()
 	defer t.muTaskOutput.Unlock()
-	if t.taskOutputLogFile == nil {
+	if t.taskOutputLog == nil {
 		return
 	}
-	if err := writeJSONL(t.taskOutputLogFile, entry); err != nil {
-		log.Error().Err(err).Str("path", t.taskOutputLogPath).Msg("telemetry: failed to write task output event")
-	} else {
+	if err := t.taskOutputLog.WriteJSONL(entry); err == nil {
 		t.taskOutputCount++
+	} else if !errors.Is(err, ErrDropped) {
+		log.Error().Err(err).Str("path", t.taskOutputLogPath).Msg("telemetry: failed to encode task output event")
 	}
 }
 
@@ -418,6 +414,7 @@ func (t *Tracker) WriteSessionToolsCatalog(sessionID string, tools []SessionTool
 		log.Error().Err(err).Msg("session_tools: marshal failed")
 		return
 	}
+	data = t.config.Writer.Redactor.JSON(data)
 	if err := os.WriteFile(t.sessionToolsPath, data, 0600); err != nil {
 		log.Error().Err(err).Str("path", t.sessionToolsPath).Msg("session_tools: write failed")
 	}
@@ -435,7 +432,52 @@ func (t *Tracker) RecordPreemptiveStats(origTokens, summarizedTokens int) {
 	t.statsTracker.RecordPreemptive(origTokens, summarizedTokens)
 }
 
-// Close syncs and closes all open file handles.
+// writers returns the open JSONL writers. Caller must hold the per-file locks
+// or otherwise ensure the writers are not being swapped.
+func (t *Tracker) writers() []*AsyncWriter {
+	var out []*AsyncWriter
+	for _, w := range []*AsyncWriter{t.requestLog, t.compressionLog, t.toolDiscoveryLog, t.taskOutputLog, t.expandCallsLogger.writer(), t.shadowEvalLogger.writer()} {
+		if w != nil {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// Flush blocks until all queued telemetry has been written and synced.
+func (t *Tracker) Flush() {
+	t.muRequest.Lock()
+	defer t.muRequest.Unlock()
+	t.muCompression.Lock()
+	defer t.muCompression.Unlock()
+	t.muToolDiscovery.Lock()
+	defer t.muToolDiscovery.Unlock()
+	t.muTaskOutput.Lock()
+	defer t.muTaskOutput.Unlock()
+	for _, w := range t.writers() {
+		w.Flush()
+	}
+}
+
+// Dropped returns the total number of telemetry lines dropped because a
+// writer queue was full (slow disk). Dropping keeps request latency flat.
+func (t *Tracker) Dropped() int64 {
+	t.muRequest.Lock()
+	defer t.muRequest.Unlock()
+	t.muCompression.Lock()
+	defer t.muCompression.Unlock()
+	t.muToolDiscovery.Lock()
+	defer t.muToolDiscovery.Unlock()
+	t.muTaskOutput.Lock()
+	defer t.muTaskOutput.Unlock()
+	var total int64
+	for _, w := range t.writers() {
+		total += w.Dropped()
+	}
+	return total
+}
+
+// Close drains, syncs, and closes all writers.
 func (t *Tracker) Close() error {
 	// Acquire all per-file locks in deterministic order to avoid deadlock.
 	t.muRequest.Lock()
@@ -457,18 +499,17 @@ func (t *Tracker) Close() error {
 	}
 
 	t.statsTracker.Stop()
-	t.expandCallsLogger.Close()
 
-	for _, f := range []*os.File{t.requestLogFile, t.compressionLogFile, t.toolDiscoveryLogFile, t.taskOutputLogFile} {
-		if f != nil {
-			_ = f.Sync()
-			_ = f.Close()
+	for _, w := range t.writers() {
+		if dropped := w.Dropped(); dropped > 0 {
+			log.Warn().Str("path", w.path).Int64("dropped", dropped).Msg("telemetry: events dropped under backpressure")
 		}
+		_ = w.Close()
 	}
-	t.requestLogFile = nil
-	t.compressionLogFile = nil
-	t.toolDiscoveryLogFile = nil
-	t.taskOutputLogFile = nil
+	t.requestLog = nil
+	t.compressionLog = nil
+	t.toolDiscoveryLog = nil
+	t.taskOutputLog = nil
 
 	return nil
 }
@@ -488,6 +529,14 @@ func (t *Tracker) ExpandCallsLogger() *ExpandCallsLogger {
 	return t.expandCallsLogger
 }
 
+// LogShadowEval appends a shadow mode evaluation to shadow_eval.jsonl.
+func (t *Tracker) LogShadowEval(entry ShadowEvalEntry) {
+	if t == nil || !t.config.Enabled {
+		return
+	}
+	t.shadowEvalLogger.Log(entry)
+}
+
 // HELPERS FOR VERBOSE PAYLOADS
 
 // SanitizeHeaders removes sensitive headers and returns a safe copy.
@@ -502,6 +551,7 @@ func SanitizeHeaders(headers map[string]string) map[string]string {
 		"x-api-key":        true,
 		"api-key":          true,
 		"x-auth-token":     true,
+		"x-gateway-key":    true,
 		"cookie":           true,
 		"set-cookie":       true,
 		"x-amzn-requestid": false, // Safe
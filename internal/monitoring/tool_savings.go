@@ -0,0 +1,225 @@
+// Package monitoring - tool_savings.go aggregates compression outcomes per tool.
+//
+// Answers "which tools benefit most from compression?": for each tool name it
+// accumulates original vs compressed bytes and tokens over every forwarded
+// output, how often the model expanded the tool's compressed outputs, and
+// regrets — expand_context calls whose original was no longer available, so
+// compression cost the model information it asked for. Shadow IDs are mapped
+// back to tools so expands on earlier turns are attributed correctly.
+package monitoring
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// maxTrackedShadowIDs bounds the shadow ID → tool map used to attribute expands.
+const maxTrackedShadowIDs = 10_000
+
+// ToolSavings is the cumulative compression outcome for one tool.
+// Byte and token totals count every forwarded output (history is re-sent each
+// turn, so an output counts once per request it appears in).
+type ToolSavings struct {
+	ToolName string `json:"tool_name"`
+
+	OutputsSent       int64 `json:"outputs_sent"`       // Outputs forwarded, any status
+	CompressedSent    int64 `json:"compressed_sent"`    // Outputs forwarded compressed (incl. cache hits)
+	CompressedOutputs int64 `json:"compressed_outputs"` // Distinct compressed outputs (shadow IDs)
+	CacheHits         int64 `json:"cache_hits"`
+
+	OriginalBytes    int64   `json:"original_bytes"`
+	CompressedBytes  int64   `json:"compressed_bytes"`
+	OriginalTokens   int64   `json:"original_tokens"`
+	CompressedTokens int64   `json:"compressed_tokens"`
+	TokensSaved      int64   `json:"tokens_saved"`
+	SavedPct         float64 `json:"saved_pct"` // TokensSaved / OriginalTokens × 100
+
+	Expands        int64   `json:"expands"`         // expand_context calls on this tool's outputs
+	ExpandRate     float64 `json:"expand_rate"`     // Expands / CompressedOutputs
+	ExpandedTokens int64   `json:"expanded_tokens"` // Original tokens re-sent by expands
+	Regrets        int64   `json:"regrets"`         // Expands whose original had expired or been evicted
+
+	NetTokensSaved int64 `json:"net_tokens_saved"` // TokensSaved - ExpandedTokens
+}
+
+// ToolSavingsTracker accumulates ToolSavings per tool. Thread-safe.
+type ToolSavingsTracker struct {
+	mu    sync.Mutex
+	tools map[string]*ToolSavings
+
+	shadowTool  map[string]*list.Element // shadow ID → element holding shadowToolEntry
+	shadowOrder *list.List               // insertion order for O(1) eviction
+}
+
+type shadowToolEntry struct {
+	shadowID string
+	toolName string
+}
+
+// NewToolSavingsTracker creates an empty tracker.
+func NewToolSavingsTracker() *ToolSavingsTracker {
+	return &ToolSavingsTracker{
+		tools:       make(map[string]*ToolSavings),
+		shadowTool:  make(map[string]*list.Element),
+		shadowOrder: list.New(),
+	}
+}
+
+// RecordOutput records one forwarded tool output. status is the tool-output
+// pipe's mapping status; "compressed" and "cache_hit" count as compressed.
+func (t *ToolSavingsTracker) RecordOutput(toolName, shadowID, status string, origTokens, compTokens, origBytes, compBytes int) {
+	if t == nil || toolName == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.toolLocked(toolName)
+	s.OutputsSent++
+	s.OriginalBytes += int64(origBytes)
+	s.CompressedBytes += int64(compBytes)
+	s.OriginalTokens += int64(origTokens)
+	s.CompressedTokens += int64(compTokens)
+	if compTokens < origTokens {
+		s.TokensSaved += int64(origTokens - compTokens)
+	}
+
+	if status != "compressed" && status != "cache_hit" {
+		return
+	}
+	s.CompressedSent++
+	if status == "cache_hit" {
+		s.CacheHits++
+	}
+	if shadowID != "" {
+		if _, seen := t.shadowTool[shadowID]; !seen {
+			s.CompressedOutputs++
+			t.shadowTool[shadowID] = t.shadowOrder.PushBack(shadowToolEntry{shadowID: shadowID, toolName: toolName})
+			if t.shadowOrder.Len() > maxTrackedShadowIDs {
+				oldest := t.shadowOrder.Front()
+				t.shadowOrder.Remove(oldest)
+				delete(t.shadowTool, oldest.Value.(shadowToolEntry).shadowID)
+			}
+		}
+	}
+}
+
+// RecordExpand records an expand_context call for shadowID. found reports
+// whether the original was returned; tokens is the size of what was re-sent.
+// Expands of shadow IDs this tracker has not seen are ignored.
+func (t *ToolSavingsTracker) RecordExpand(shadowID string, found bool, tokens int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.shadowTool[shadowID]
+	if !ok {
+		return
+	}
+	s := t.toolLocked(el.Value.(shadowToolEntry).toolName)
+	s.Expands++
+	if found {
+		s.ExpandedTokens += int64(tokens)
+	} else {
+		s.Regrets++
+	}
+}
+
+// Leaderboard returns every tool, best net savings first (ties by name).
+func (t *ToolSavingsTracker) Leaderboard() []ToolSavings {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	out := make([]ToolSavings, 0, len(t.tools))
+	for _, s := range t.tools {
+		v := *s
+		v.derive()
+		out = append(out, v)
+	}
+	t.mu.Unlock()
+
+	sortLeaderboard(out)
+	return out
+}
+
+// MergeToolSavings sums leaderboards from several gateway instances into one,
+// recomputing the derived ratios. Used by the aggregated dashboard.
+func MergeToolSavings(boards ...[]ToolSavings) []ToolSavings {
+	byTool := make(map[string]*ToolSavings)
+	for _, board := range boards {
+		for _, s := range board {
+			m, ok := byTool[s.ToolName]
+			if !ok {
+				m = &ToolSavings{ToolName: s.ToolName}
+				byTool[s.ToolName] = m
+			}
+			m.OutputsSent += s.OutputsSent
+			m.CompressedSent += s.CompressedSent
+			m.CompressedOutputs += s.CompressedOutputs
+			m.CacheHits += s.CacheHits
+			m.OriginalBytes += s.OriginalBytes
+			m.CompressedBytes += s.CompressedBytes
+			m.OriginalTokens += s.OriginalTokens
+			m.CompressedTokens += s.CompressedTokens
+			m.TokensSaved += s.TokensSaved
+			m.Expands += s.Expands
+			m.ExpandedTokens += s.ExpandedTokens
+			m.Regrets += s.Regrets
+		}
+	}
+	out := make([]ToolSavings, 0, len(byTool))
+	for _, m := range byTool {
+		m.derive()
+		out = append(out, *m)
+	}
+	sortLeaderboard(out)
+	return out
+}
+
+// derive fills the fields computed from the counters.
+func (s *ToolSavings) derive() {
+	s.NetTokensSaved = s.TokensSaved - s.ExpandedTokens
+	s.SavedPct, s.ExpandRate = 0, 0
+	if s.OriginalTokens > 0 {
+		s.SavedPct = float64(s.TokensSaved) / float64(s.OriginalTokens) * 100
+	}
+	if s.CompressedOutputs > 0 {
+		s.ExpandRate = float64(s.Expands) / float64(s.CompressedOutputs)
+	}
+}
+
+// sortLeaderboard orders by net savings, best first (ties by name).
+func sortLeaderboard(board []ToolSavings) {
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].NetTokensSaved != board[j].NetTokensSaved {
+			return board[i].NetTokensSaved > board[j].NetTokensSaved
+		}
+		return board[i].ToolName < board[j].ToolName
+	})
+}
+
+// Reset clears all per-tool totals.
+func (t *ToolSavingsTracker) Reset() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tools = make(map[string]*ToolSavings)
+	t.shadowTool = make(map[string]*list.Element)
+	t.shadowOrder.Init()
+}
+
+// toolLocked returns the totals for toolName, creating them. Caller holds t.mu.
+func (t *ToolSavingsTracker) toolLocked(toolName string) *ToolSavings {
+	s, ok := t.tools[toolName]
+	if !ok {
+		s = &ToolSavings{ToolName: toolName}
+		t.tools[toolName] = s
+	}
+	return s
+}
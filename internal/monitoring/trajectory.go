@@ -12,6 +12,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/redaction"
 )
 
 // TrajectoryRecorder is a simplified trajectory recorder.
@@ -21,6 +23,7 @@ type TrajectoryRecorder struct {
 	mu         sync.Mutex
 	trajectory *Trajectory
 	logPath    string
+	redactor   *redaction.Redactor
 	closed     bool
 	dirty      int // steps added since last flush; flushed when >= flushBatchSize
 }
@@ -34,6 +37,8 @@ type TrajectoryRecorderConfig struct {
 	SessionID string // Unique session identifier (generates UUID if empty)
 	AgentName string // Agent name (e.g., "claude-code")
 	Version   string // Agent version (defaults to "1.0.0")
+
+	Redactor *redaction.Redactor // Scrubs the file before each write; nil disables
 }
 
 // NewTrajectoryRecorder creates a new trajectory recorder.
@@ -75,6 +80,7 @@ func NewTrajectoryRecorder(cfg TrajectoryRecorderConfig) (*TrajectoryRecorder, e
 	return &TrajectoryRecorder{
 		trajectory: traj,
 		logPath:    cfg.LogPath,
+		redactor:   cfg.Redactor,
 	}, nil
 }
 
@@ -397,6 +403,7 @@ func (r *TrajectoryRecorder) flushLocked() {
 		return
 	}
 
+	data = r.redactor.JSON(data)
 	if err := os.WriteFile(r.logPath, data, 0600); err != nil {
 		log.Error().Err(err).Str("path", r.logPath).Msg("trajectory: write failed")
 	}
@@ -487,6 +494,7 @@ type TrajectoryStore struct {
 	baseDir      string
 	agentName    string
 	version      string
+	redactor     *redaction.Redactor
 	enabled      bool
 	mainSessions map[string]bool // Track main sessions (vs subagents)
 	stopCh       chan struct{}   // Signal cleanup goroutine to stop
@@ -498,6 +506,7 @@ type TrajectoryStoreConfig struct {
 	BaseDir   string // Directory for trajectory files
 	AgentName string
 	Version   string
+	Redactor  *redaction.Redactor // Scrubs trajectory files before each write
 }
 
 // NewTrajectoryStore creates a new trajectory store.
@@ -517,6 +526,7 @@ func NewTrajectoryStore(cfg TrajectoryStoreConfig) *TrajectoryStore {
 		baseDir:      cfg.BaseDir,
 		agentName:    agentName,
 		version:      cfg.Version,
+		redactor:     cfg.Redactor,
 		enabled:      true,
 		mainSessions: make(map[string]bool),
 		stopCh:       make(chan struct{}),
@@ -595,6 +605,7 @@ func (s *TrajectoryStore) getOrCreate(sessionID string) *TrajectoryRecorder {
 		SessionID: sessionID,
 		AgentName: s.agentName,
 		Version:   s.version,
+		Redactor:  s.redactor,
 	}
 
 	recorder, err := NewTrajectoryRecorder(cfg)
@@ -17,6 +17,7 @@ const (
 	PipeToolOutput    PipeType = "tool_output"
 	PipeToolDiscovery PipeType = "tool_discovery"
 	PipeTaskOutput    PipeType = "task_output"
+	PipeMedia         PipeType = "media"
 )
 
 // EVENT TYPES - Structured data for telemetry recording
@@ -30,6 +31,8 @@ type RequestEvent struct {
 	ClientIP         string    `json:"client_ip"`
 	Provider         string    `json:"provider"`
 	Model            string    `json:"model,omitempty"`
+	Tenant           string    `json:"tenant,omitempty"` // Set in multi-tenant mode
+	Client           string    `json:"client,omitempty"` // client_auth label of the caller
 	RequestBodySize  int       `json:"request_body_size"`
 	ResponseBodySize int       `json:"response_body_size"`
 	StatusCode       int       `json:"status_code"`
@@ -59,18 +62,38 @@ type RequestEvent struct {
 	ExpandPenaltyTokens int `json:"expand_penalty_tokens,omitempty"`
 
 	// Request result
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode ErrorCode `json:"error_code,omitempty"` // Taxonomy code for aggregation (see errors.go)
+	Retryable bool      `json:"retryable,omitempty"`  // Whether retrying the request may succeed
 
 	// Latency
 	CompressionLatencyMs int64 `json:"compression_latency_ms"`
 	ForwardLatencyMs     int64 `json:"forward_latency_ms"`
 	TotalLatencyMs       int64 `json:"total_latency_ms"`
 
+	// Streaming latency (0 for non-streaming requests)
+	FirstByteLatencyMs  int64 `json:"first_byte_latency_ms,omitempty"`  // Request arrival → first byte relayed to client
+	FirstTokenLatencyMs int64 `json:"first_token_latency_ms,omitempty"` // Request arrival → first content delta relayed to client
+
+	// Pipe latency SLOs (one entry per pipe with an SLO that ran)
+	SLO []PipeSLO `json:"slo,omitempty"`
+
+	// PII masking (entities replaced with tokens before forwarding)
+	PIIMasked int `json:"pii_masked,omitempty"`
+
+	// Custom pipes (pipes.custom), one entry per run
+	CustomPipes []CustomPipeRun `json:"custom_pipes,omitempty"`
+
+	// Prompt-injection findings in tool outputs (nil when there were none)
+	PromptInjection *PromptInjectionReport `json:"prompt_injection,omitempty"`
+
 	// Auth
 	AuthModeInitial   string `json:"auth_mode_initial,omitempty"`   // subscription, api_key, bearer, oauth, none, unknown
 	AuthModeEffective string `json:"auth_mode_effective,omitempty"` // Actual auth sent upstream
 	AuthFallbackUsed  bool   `json:"auth_fallback_used,omitempty"`  // True when subscription->api_key fallback happened
+	UpstreamRetries   int    `json:"upstream_retries,omitempty"`    // Forwards retried by upstream_retry
+	ModelFallbackFrom string `json:"model_fallback_from,omitempty"` // Requested model when a fallback answered
 
 	// Preemptive summarization
 	HistoryCompactionTriggered bool `json:"history_compaction_triggered,omitempty"` // Whether preemptive summarization ran
@@ -319,6 +342,11 @@ type TelemetryConfig struct {
 	// Each entry contains the original + compressed content that triggered the call —
 	// a training signal for compressions the model found too aggressive.
 	ExpandContextCallsPath string `yaml:"expand_context_calls_path"`
+	// ShadowEvalPath is the JSONL log of shadow mode evaluations: per request,
+	// what the pipes would have sent and the estimated savings.
+	ShadowEvalPath string `yaml:"shadow_eval_path"`
+	// Writer tunes the async JSONL writers (queue size, batching, fsync policy).
+	Writer AsyncWriterConfig `yaml:"writer"`
 }
 
 // LoggerConfig contains logging configuration.
@@ -332,3 +360,50 @@ type LoggerConfig struct {
 type AlertConfig struct {
 	HighLatencyThreshold time.Duration `yaml:"high_latency_threshold"`
 }
+
+// SLO states for a pipe.
+const (
+	SLOStateOK        = "ok"        // p95 within target
+	SLOStateViolating = "violating" // p95 over target, no degrade_to configured
+	SLOStateDegraded  = "degraded"  // running the local degrade_to strategy
+)
+
+// Custom pipe run outcomes (CustomPipeRun.Outcome).
+const (
+	CustomPipeUnchanged = "unchanged" // Body forwarded as the pipe received it
+	CustomPipeModified  = "modified"  // Pipe returned a different body
+	CustomPipeFailed    = "failed"    // Pipe errored; its input was forwarded
+	CustomPipeRejected  = "rejected"  // Pipe refused the request, or failed with on_error: reject
+)
+
+// CustomPipeRun records one custom pipe run for a request.
+type CustomPipeRun struct {
+	Pipe       string             `json:"pipe"`
+	Outcome    string             `json:"outcome"` // unchanged | modified | failed | rejected
+	DurationMs int64              `json:"duration_ms"`
+	BytesIn    int                `json:"bytes_in"`
+	BytesOut   int                `json:"bytes_out"`
+	Error      string             `json:"error,omitempty"`
+	Metrics    map[string]float64 `json:"metrics,omitempty"` // Reported by the pipe
+}
+
+// PromptInjectionReport records the injection pipe's findings for a request.
+type PromptInjectionReport struct {
+	Action   string                   `json:"action"` // flag | strip | block
+	Findings []PromptInjectionFinding `json:"findings"`
+}
+
+// PromptInjectionFinding is one suspected prompt injection in a tool output.
+type PromptInjectionFinding struct {
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id"`
+	Detector   string `json:"detector"` // phrase | hidden_unicode | base64 | pattern
+	Excerpt    string `json:"excerpt"`
+}
+
+// PipeSLO records a pipe's SLO state for one request.
+type PipeSLO struct {
+	Pipe             string `json:"pipe"`
+	State            string `json:"state"`                       // ok | violating | degraded
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"` // Run hit the deadline; cut-off calls passed through
+}
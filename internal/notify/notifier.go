@@ -0,0 +1,401 @@
+// Package notify - notifier.go queues events and delivers them to webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/retry"
+)
+
+// Schema is the JSON Schema of the generic webhook payload (Payload).
+//
+//go:embed schema.json
+var Schema []byte
+
+const (
+	queueSize     = 256    // Events waiting for delivery
+	maxOnceKeys   = 10_000 // Remembered Once and Throttled keys
+	maxRetryDelay = 5 * time.Second
+)
+
+// Notifier delivers events to the configured webhooks in the background.
+// Thread-safe. Safe to call on a nil receiver (disabled).
+type Notifier struct {
+	cfg      Config
+	render   *renderer
+	bodies   []*template.Template // body_template per webhook (nil unless format: template)
+	client   *http.Client
+	events   chan Event
+	done     chan struct{}
+	stopping chan struct{}
+
+	mu            sync.Mutex // guards closed, once keys, throttle and outage state
+	closed        bool
+	once          map[string]struct{}
+	onceOrder     []string
+	throttle      map[string]*throttleState
+	throttleOrder []string
+	failures      map[string]int // provider -> consecutive upstream failures
+
+	accepted  atomic.Int64
+	delivered atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+	lastErr   atomic.Value // string
+}
+
+// New starts a notifier, or returns nil when no webhook is configured.
+func New(cfg Config) (*Notifier, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	render, err := newRenderer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	n := &Notifier{
+		cfg:      cfg,
+		render:   render,
+		bodies:   make([]*template.Template, len(cfg.Webhooks)),
+		client:   &http.Client{},
+		events:   make(chan Event, queueSize),
+		done:     make(chan struct{}),
+		stopping: make(chan struct{}),
+		once:     make(map[string]struct{}),
+		throttle: make(map[string]*throttleState),
+		failures: make(map[string]int),
+	}
+	for i, w := range cfg.Webhooks {
+		if w.format() == FormatTemplate {
+			if n.bodies[i], err = parseTemplate(w.Name, w.BodyTemplate); err != nil {
+				return nil, err
+			}
+		}
+	}
+	go n.run()
+	return n, nil
+}
+
+// BudgetWarningAt returns the cap fraction that fires budget_warning.
+func (n *Notifier) BudgetWarningAt() float64 {
+	if n == nil {
+		return 0
+	}
+	return n.cfg.budgetWarningAt()
+}
+
+// throttleState tracks one NotifyThrottled key.
+type throttleState struct {
+	last       time.Time
+	suppressed int // Events dropped since last
+}
+
+// Notify queues ev for delivery. Fills ID, Time and Severity when empty. Never blocks.
+func (n *Notifier) Notify(ev Event) {
+	if n == nil {
+		return
+	}
+	if ev.ID == "" {
+		ev.ID = uuid.NewString()
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if ev.Severity == "" {
+		ev.Severity = defaultSeverity(ev.Type)
+	}
+	if ev.Data == nil {
+		ev.Data = map[string]any{}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		n.dropped.Add(1)
+		return
+	}
+	select {
+	case n.events <- ev:
+		n.accepted.Add(1)
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// NotifyOnce queues ev unless an event with the same key was already sent,
+// e.g. one budget_warning per cap and session.
+func (n *Notifier) NotifyOnce(key string, ev Event) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if _, seen := n.once[key]; seen {
+		n.mu.Unlock()
+		return
+	}
+	n.once[key] = struct{}{}
+	n.onceOrder = append(n.onceOrder, key)
+	if len(n.onceOrder) > maxOnceKeys {
+		delete(n.once, n.onceOrder[0])
+		n.onceOrder = n.onceOrder[1:]
+	}
+	n.mu.Unlock()
+	n.Notify(ev)
+}
+
+// NotifyThrottled queues ev unless an event with the same key was sent less
+// than budget_exceeded_interval ago, e.g. one budget_exceeded per cap every
+// 15 minutes. Events dropped in between are counted in the next event's
+// data.suppressed.
+func (n *Notifier) NotifyThrottled(key string, ev Event) {
+	if n == nil {
+		return
+	}
+	now := time.Now()
+	n.mu.Lock()
+	st, ok := n.throttle[key]
+	if !ok {
+		st = &throttleState{}
+		n.throttle[key] = st
+		n.throttleOrder = append(n.throttleOrder, key)
+		if len(n.throttleOrder) > maxOnceKeys {
+			delete(n.throttle, n.throttleOrder[0])
+			n.throttleOrder = n.throttleOrder[1:]
+		}
+	} else if now.Sub(st.last) < n.cfg.budgetExceededInterval() {
+		st.suppressed++
+		n.mu.Unlock()
+		return
+	}
+	suppressed := st.suppressed
+	st.last, st.suppressed = now, 0
+	n.mu.Unlock()
+
+	if ev.Data == nil {
+		ev.Data = map[string]any{}
+	}
+	ev.Data["suppressed"] = suppressed
+	n.Notify(ev)
+}
+
+// ObserveUpstream tracks upstream results per provider and fires
+// provider_outage when outage_threshold consecutive requests fail. A success
+// re-arms the event for the next outage.
+func (n *Notifier) ObserveUpstream(provider string, failed bool, errorCode string, status int) {
+	if n == nil || provider == "" {
+		return
+	}
+	n.mu.Lock()
+	if !failed {
+		delete(n.failures, provider)
+		n.mu.Unlock()
+		return
+	}
+	n.failures[provider]++
+	count := n.failures[provider]
+	n.mu.Unlock()
+
+	if count != n.cfg.outageThreshold() {
+		return
+	}
+	n.Notify(Event{
+		Type: EventProviderOutage,
+		Data: map[string]any{
+			"provider":             provider,
+			"consecutive_failures": count,
+			"error_code":           errorCode,
+			"last_status":          status,
+		},
+	})
+}
+
+func defaultSeverity(t EventType) string {
+	switch t {
+	case EventProviderOutage, EventBudgetExceeded:
+		return SeverityCritical
+	case EventBudgetWarning:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+	for ev := range n.events {
+		for i, w := range n.cfg.Webhooks {
+			if w.subscribed(ev.Type) {
+				n.deliver(i, w, ev)
+			}
+		}
+	}
+}
+
+// deliver sends ev to one webhook, retrying transient failures.
+func (n *Notifier) deliver(i int, w WebhookConfig, ev Event) {
+	body, contentType, err := n.body(i, w, ev)
+	if err != nil {
+		n.fail(w, ev, err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		status, err := n.post(w, body, contentType)
+		if err == nil && status >= 200 && status < 300 {
+			n.delivered.Add(1)
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("HTTP %d", status)
+		}
+		if attempt+1 >= retry.MaxAttempts || (status != 0 && !retry.IsTransientStatus(status)) {
+			n.fail(w, ev, err)
+			return
+		}
+		select {
+		case <-time.After(min(retry.Backoff(attempt), maxRetryDelay)):
+		case <-n.stopping:
+			n.fail(w, ev, err)
+			return
+		}
+	}
+}
+
+func (n *Notifier) fail(w WebhookConfig, ev Event, err error) {
+	n.failed.Add(1)
+	n.lastErr.Store(fmt.Sprintf("%s: %v", w.Name, err))
+	log.Warn().Err(err).Str("webhook", w.Name).Str("event", string(ev.Type)).Msg("notify: webhook delivery failed")
+}
+
+// body renders the request body for w.
+func (n *Notifier) body(i int, w WebhookConfig, ev Event) ([]byte, string, error) {
+	locale := w.Locale
+	if locale == "" {
+		locale = n.cfg.locale()
+	}
+	p := Payload{Schema: PayloadSchemaID, Locale: locale, Message: n.render.message(ev, locale), Event: ev}
+
+	switch w.format() {
+	case FormatSlack:
+		b, err := json.Marshal(map[string]any{
+			"text": p.Message,
+			"blocks": []map[string]any{
+				{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": p.Message}},
+			},
+		})
+		return b, "application/json", err
+	case FormatTemplate:
+		var buf bytes.Buffer
+		if err := n.bodies[i].Execute(&buf, p); err != nil {
+			return nil, "", err
+		}
+		contentType := w.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		return buf.Bytes(), contentType, nil
+	default:
+		b, err := json.Marshal(p)
+		return b, "application/json", err
+	}
+}
+
+func (n *Notifier) post(w WebhookConfig, body []byte, contentType string) (int, error) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Stats returns a snapshot of delivery counters. Safe to call on nil.
+func (n *Notifier) Stats() Stats {
+	if n == nil {
+		return Stats{}
+	}
+	st := Stats{
+		Events:    n.accepted.Load(),
+		Delivered: n.delivered.Load(),
+		Failed:    n.failed.Load(),
+		Dropped:   n.dropped.Load(),
+	}
+	if v, ok := n.lastErr.Load().(string); ok {
+		st.LastError = v
+	}
+	return st
+}
+
+// Close delivers queued events until ctx expires, then abandons retries.
+// Safe to call on nil and more than once.
+func (n *Notifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.events)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		n.mu.Lock()
+		select {
+		case <-n.stopping:
+		default:
+			close(n.stopping)
+		}
+		n.mu.Unlock()
+		<-n.done
+		return ctx.Err()
+	}
+}
+
+// RenderMessage renders the message for ev in locale using cfg's templates.
+// Used to preview templates without sending anything.
+func RenderMessage(cfg Config, ev Event, locale string) (string, error) {
+	r, err := newRenderer(cfg)
+	if err != nil {
+		return "", err
+	}
+	if locale == "" {
+		locale = cfg.locale()
+	}
+	return strings.TrimSpace(r.message(ev, locale)), nil
+}
@@ -0,0 +1,154 @@
+// Package notify - templates.go renders localized event messages.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// builtinMessages are the default message templates: locale -> event -> template.
+// Templates see an Event (.Type, .Severity, .SessionID, .Data) plus the funcs
+// in templateFuncs.
+var builtinMessages = map[string]map[EventType]string{
+	"en": {
+		EventCompactionDone: `Context compacted{{with .SessionID}} for session {{.}}{{end}}: {{.Data.tokens_before}} → {{.Data.tokens_after}} tokens.`,
+		EventBudgetWarning:  `Budget warning: {{.Data.cap}} is {{percent .Data.utilization}} used ({{amount .Data.used .Data.unit}} of {{amount .Data.limit .Data.unit}}).`,
+		EventBudgetExceeded: `Budget exceeded: {{.Data.cap}}{{with .Data.scope}} ({{.}}){{end}} reached {{amount .Data.used .Data.unit}} of {{amount .Data.limit .Data.unit}}; requests are being blocked.{{with .Data.suppressed}} {{.}} more blocked since the last alert.{{end}}`,
+		EventProviderOutage: `Provider outage: {{.Data.provider}} failed {{.Data.consecutive_failures}} requests in a row (last error: {{.Data.error_code}}).`,
+	},
+	"de": {
+		EventCompactionDone: `Kontext komprimiert{{with .SessionID}} für Sitzung {{.}}{{end}}: {{.Data.tokens_before}} → {{.Data.tokens_after}} Tokens.`,
+		EventBudgetWarning:  `Budgetwarnung: {{.Data.cap}} ist zu {{percent .Data.utilization}} ausgeschöpft ({{amount .Data.used .Data.unit}} von {{amount .Data.limit .Data.unit}}).`,
+		EventBudgetExceeded: `Budget überschritten: {{.Data.cap}}{{with .Data.scope}} ({{.}}){{end}} hat {{amount .Data.used .Data.unit}} von {{amount .Data.limit .Data.unit}} erreicht; Anfragen werden blockiert.{{with .Data.suppressed}} Seit der letzten Meldung {{.}} weitere blockiert.{{end}}`,
+		EventProviderOutage: `Anbieterausfall: {{.Data.provider}} hat {{.Data.consecutive_failures}} Anfragen in Folge nicht beantwortet (letzter Fehler: {{.Data.error_code}}).`,
+	},
+	"es": {
+		EventCompactionDone: `Contexto compactado{{with .SessionID}} en la sesión {{.}}{{end}}: {{.Data.tokens_before}} → {{.Data.tokens_after}} tokens.`,
+		EventBudgetWarning:  `Aviso de presupuesto: {{.Data.cap}} está al {{percent .Data.utilization}} ({{amount .Data.used .Data.unit}} de {{amount .Data.limit .Data.unit}}).`,
+		EventBudgetExceeded: `Presupuesto superado: {{.Data.cap}}{{with .Data.scope}} ({{.}}){{end}} llegó a {{amount .Data.used .Data.unit}} de {{amount .Data.limit .Data.unit}}; se están bloqueando solicitudes.{{with .Data.suppressed}} {{.}} bloqueadas más desde el último aviso.{{end}}`,
+		EventProviderOutage: `Caída del proveedor: {{.Data.provider}} falló {{.Data.consecutive_failures}} solicitudes seguidas (último error: {{.Data.error_code}}).`,
+	},
+	"fr": {
+		EventCompactionDone: `Contexte compacté{{with .SessionID}} pour la session {{.}}{{end}} : {{.Data.tokens_before}} → {{.Data.tokens_after}} tokens.`,
+		EventBudgetWarning:  `Alerte budget : {{.Data.cap}} est utilisé à {{percent .Data.utilization}} ({{amount .Data.used .Data.unit}} sur {{amount .Data.limit .Data.unit}}).`,
+		EventBudgetExceeded: `Budget dépassé : {{.Data.cap}}{{with .Data.scope}} ({{.}}){{end}} a atteint {{amount .Data.used .Data.unit}} sur {{amount .Data.limit .Data.unit}} ; les requêtes sont bloquées.{{with .Data.suppressed}} {{.}} de plus bloquées depuis la dernière alerte.{{end}}`,
+		EventProviderOutage: `Panne du fournisseur : {{.Data.provider}} a échoué {{.Data.consecutive_failures}} requêtes d'affilée (dernière erreur : {{.Data.error_code}}).`,
+	},
+	"ja": {
+		EventCompactionDone: `コンテキストを圧縮しました{{with .SessionID}}（セッション {{.}}）{{end}}: {{.Data.tokens_before}} → {{.Data.tokens_after}} トークン。`,
+		EventBudgetWarning:  `予算警告: {{.Data.cap}} の使用率が {{percent .Data.utilization}} に達しました（{{amount .Data.limit .Data.unit}} 中 {{amount .Data.used .Data.unit}}）。`,
+		EventBudgetExceeded: `予算超過: {{.Data.cap}}{{with .Data.scope}}（{{.}}）{{end}} が {{amount .Data.limit .Data.unit}} 中 {{amount .Data.used .Data.unit}} に達したため、リクエストをブロックしています。{{with .Data.suppressed}}前回の通知以降さらに {{.}} 件をブロックしました。{{end}}`,
+		EventProviderOutage: `プロバイダー障害: {{.Data.provider}} へのリクエストが {{.Data.consecutive_failures}} 回連続で失敗しました（最後のエラー: {{.Data.error_code}}）。`,
+	},
+}
+
+// Locales lists the built-in message locales.
+func Locales() []string {
+	return []string{"en", "de", "es", "fr", "ja"}
+}
+
+var templateFuncs = template.FuncMap{
+	// percent formats a 0-1 fraction: 0.83 -> "83%".
+	"percent": func(v any) string {
+		f, _ := toFloat(v)
+		return fmt.Sprintf("%.0f%%", f*100)
+	},
+	// amount formats a value in unit "usd" or "bytes".
+	"amount": func(v, unit any) string {
+		f, _ := toFloat(v)
+		if unit == "bytes" {
+			return formatBytes(f)
+		}
+		return fmt.Sprintf("$%.2f", f)
+	},
+	// json encodes a value for embedding in a JSON body_template.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func formatBytes(b float64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%.0f B", b)
+	}
+	exp, div := 0, float64(unit)
+	for n := b / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", b/div, "KMGT"[exp])
+}
+
+// renderer holds parsed message templates.
+type renderer struct {
+	// messages maps locale -> event -> template; user overrides replace built-ins.
+	messages map[string]map[EventType]*template.Template
+}
+
+func newRenderer(cfg Config) (*renderer, error) {
+	r := &renderer{messages: make(map[string]map[EventType]*template.Template)}
+	add := func(locale string, event EventType, text string) error {
+		t, err := parseTemplate(string(event)+"."+locale, text)
+		if err != nil {
+			return err
+		}
+		if r.messages[locale] == nil {
+			r.messages[locale] = make(map[EventType]*template.Template)
+		}
+		r.messages[locale][event] = t
+		return nil
+	}
+	for locale, byEvent := range builtinMessages {
+		for event, text := range byEvent {
+			if err := add(locale, event, text); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for event, byLocale := range cfg.Templates {
+		for locale, text := range byLocale {
+			if err := add(strings.ToLower(locale), EventType(event), text); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// message renders the text for ev in locale, falling back to English.
+func (r *renderer) message(ev Event, locale string) string {
+	t := r.messages[strings.ToLower(locale)][ev.Type]
+	if t == nil {
+		t = r.messages[DefaultLocale][ev.Type]
+	}
+	if t == nil {
+		return string(ev.Type)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, ev); err != nil {
+		return fmt.Sprintf("%s (template error: %v)", ev.Type, err)
+	}
+	return b.String()
+}
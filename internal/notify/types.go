@@ -0,0 +1,232 @@
+// Package notify sends gateway events to webhooks.
+//
+// Four events are emitted: compaction_done (a preemptive summary replaced the
+// conversation history), budget_warning (a cost_control cap crossed
+// budget_warning_at), budget_exceeded (a cap was reached and requests are
+// being blocked) and provider_outage (an upstream failed outage_threshold
+// requests in a row). Each event is rendered into a short
+// localized message from a text/template, then delivered to every webhook
+// subscribed to it in one of three formats:
+//
+//	generic   the documented JSON envelope (see Schema)
+//	slack     Slack incoming-webhook payload (text + mrkdwn block)
+//	template  body_template rendered with the event, for PagerDuty, Teams, etc.
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EventType names a notification event.
+type EventType string
+
+// Events emitted by the gateway.
+const (
+	EventCompactionDone EventType = "compaction_done"
+	EventBudgetWarning  EventType = "budget_warning"
+	EventBudgetExceeded EventType = "budget_exceeded"
+	EventProviderOutage EventType = "provider_outage"
+)
+
+// EventTypes lists all events.
+var EventTypes = []EventType{EventCompactionDone, EventBudgetWarning, EventBudgetExceeded, EventProviderOutage}
+
+// Severity of an event.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Webhook payload formats.
+const (
+	FormatGeneric  = "generic"
+	FormatSlack    = "slack"
+	FormatTemplate = "template"
+)
+
+// Defaults (applied when Config fields are zero).
+const (
+	DefaultLocale                 = "en"
+	DefaultBudgetWarningAt        = 0.8
+	DefaultBudgetExceededInterval = 15 * time.Minute
+	DefaultOutageThreshold        = 5
+	DefaultWebhookTimeout         = 5 * time.Second
+)
+
+// Config configures event notifications. It is inlined into the
+// notifications section next to the Slack hook settings.
+type Config struct {
+	Locale          string  `yaml:"locale,omitempty"`            // Message language (default: en; built in: en, de, es, fr, ja)
+	BudgetWarningAt float64 `yaml:"budget_warning_at,omitempty"` // Cap fraction (0-1) that fires budget_warning (default: 0.8)
+	OutageThreshold int     `yaml:"outage_threshold,omitempty"`  // Consecutive upstream failures that fire provider_outage (default: 5)
+
+	// BudgetExceededInterval is the minimum time between two budget_exceeded
+	// events for the same cap (default: 15m).
+	BudgetExceededInterval time.Duration `yaml:"budget_exceeded_interval,omitempty"`
+
+	// Templates overrides message text: event -> locale -> text/template.
+	Templates map[string]map[string]string `yaml:"templates,omitempty"`
+
+	Webhooks []WebhookConfig `yaml:"webhooks,omitempty"`
+}
+
+// WebhookConfig is one notification destination.
+type WebhookConfig struct {
+	Name         string            `yaml:"name"`          // Used in logs and stats
+	URL          string            `yaml:"url"`           // http(s) endpoint
+	Format       string            `yaml:"format"`        // generic (default), slack, or template
+	Events       []string          `yaml:"events"`        // Subscribed events (default: all)
+	Locale       string            `yaml:"locale"`        // Overrides the notifications locale for this webhook
+	Headers      map[string]string `yaml:"headers"`       // Extra request headers (e.g. Authorization)
+	BodyTemplate string            `yaml:"body_template"` // Request body for format: template
+	ContentType  string            `yaml:"content_type"`  // For format: template (default: application/json)
+	Timeout      time.Duration     `yaml:"timeout"`       // Per-attempt timeout (default: 5s)
+}
+
+// subscribed reports whether the webhook wants events of type t.
+func (w WebhookConfig) subscribed(t EventType) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, string(t))
+}
+
+func (w WebhookConfig) format() string {
+	if w.Format == "" {
+		return FormatGeneric
+	}
+	return w.Format
+}
+
+// Enabled reports whether any webhook is configured.
+func (c Config) Enabled() bool {
+	return len(c.Webhooks) > 0
+}
+
+func (c Config) locale() string {
+	if c.Locale == "" {
+		return DefaultLocale
+	}
+	return c.Locale
+}
+
+func (c Config) budgetWarningAt() float64 {
+	if c.BudgetWarningAt <= 0 {
+		return DefaultBudgetWarningAt
+	}
+	return c.BudgetWarningAt
+}
+
+func (c Config) budgetExceededInterval() time.Duration {
+	if c.BudgetExceededInterval <= 0 {
+		return DefaultBudgetExceededInterval
+	}
+	return c.BudgetExceededInterval
+}
+
+func (c Config) outageThreshold() int {
+	if c.OutageThreshold <= 0 {
+		return DefaultOutageThreshold
+	}
+	return c.OutageThreshold
+}
+
+// Validate checks the notification configuration, including that every
+// template parses.
+func (c Config) Validate() error {
+	if c.BudgetWarningAt < 0 || c.BudgetWarningAt > 1 {
+		return fmt.Errorf("notifications.budget_warning_at must be between 0 and 1, got %g", c.BudgetWarningAt)
+	}
+	if c.OutageThreshold < 0 {
+		return fmt.Errorf("notifications.outage_threshold must be >= 0, got %d", c.OutageThreshold)
+	}
+	if c.BudgetExceededInterval < 0 {
+		return fmt.Errorf("notifications.budget_exceeded_interval must not be negative")
+	}
+	for event, byLocale := range c.Templates {
+		if !knownEvent(event) {
+			return fmt.Errorf("notifications.templates: unknown event %q (valid: %s)", event, eventNames())
+		}
+		for locale, text := range byLocale {
+			if _, err := parseTemplate(event+"."+locale, text); err != nil {
+				return fmt.Errorf("notifications.templates.%s.%s: %w", event, locale, err)
+			}
+		}
+	}
+	for i, w := range c.Webhooks {
+		name := w.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notifications.webhooks[%s].url must be an http(s) URL", name)
+		}
+		switch w.format() {
+		case FormatGeneric, FormatSlack:
+		case FormatTemplate:
+			if strings.TrimSpace(w.BodyTemplate) == "" {
+				return fmt.Errorf("notifications.webhooks[%s].body_template is required for format %q", name, FormatTemplate)
+			}
+			if _, err := parseTemplate(name, w.BodyTemplate); err != nil {
+				return fmt.Errorf("notifications.webhooks[%s].body_template: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("notifications.webhooks[%s].format must be %s, %s or %s, got %q",
+				name, FormatGeneric, FormatSlack, FormatTemplate, w.Format)
+		}
+		for _, e := range w.Events {
+			if !knownEvent(e) {
+				return fmt.Errorf("notifications.webhooks[%s].events: unknown event %q (valid: %s)", name, e, eventNames())
+			}
+		}
+		if w.Timeout < 0 {
+			return fmt.Errorf("notifications.webhooks[%s].timeout must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func knownEvent(name string) bool {
+	return slices.Contains(EventTypes, EventType(name))
+}
+
+func eventNames() string {
+	names := make([]string, len(EventTypes))
+	for i, e := range EventTypes {
+		names[i] = string(e)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Event is one notification. Data keys per event type are documented in Schema.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      EventType      `json:"event"`
+	Severity  string         `json:"severity"`
+	Time      time.Time      `json:"time"`
+	SessionID string         `json:"session_id,omitempty"`
+	Data      map[string]any `json:"data"`
+}
+
+// Payload is the generic webhook body. Its JSON schema is Schema.
+type Payload struct {
+	Schema  string `json:"schema"` // Always PayloadSchemaID
+	Locale  string `json:"locale"`
+	Message string `json:"message"` // Rendered, localized text
+	Event
+}
+
+// PayloadSchemaID identifies the generic payload version.
+const PayloadSchemaID = "context-gateway.notification.v1"
+
+// Stats is a snapshot of delivery counters.
+type Stats struct {
+	Events    int64  `json:"events"`    // Events accepted for delivery
+	Delivered int64  `json:"delivered"` // Webhook deliveries acknowledged with 2xx
+	Failed    int64  `json:"failed"`    // Deliveries abandoned after retries
+	Dropped   int64  `json:"dropped"`   // Events dropped because the queue was full
+	LastError string `json:"last_error,omitempty"`
+}
@@ -3,16 +3,22 @@ package phantom_tools
 // ExpandContextToolName is the phantom tool name for context expansion.
 const ExpandContextToolName = "expand_context"
 
-const expandContextDescription = "Expand a [REF:id] reference to retrieve the full uncompressed content."
+const expandContextDescription = "Expand a [REF:id] reference to retrieve the full uncompressed content. Long content is returned in pages: pass offset (and optionally limit) in bytes, or start_line and end_line, to read a specific part."
 
-// idSchema is the shared JSON schema bytes for the expand_context tool.
-const idSchema = `{"type":"object","properties":{"id":{"type":"string","description":"The shadow ID (e.g., shadow_abc123)"}},"required":["id"]}`
+// expandContextSchema is the shared JSON schema bytes for the expand_context tool.
+const expandContextSchema = `{"type":"object","properties":{` +
+	`"id":{"type":"string","description":"The shadow ID (e.g., shadow_abc123)"},` +
+	`"offset":{"type":"integer","description":"Byte offset to start reading from (default 0)"},` +
+	`"limit":{"type":"integer","description":"Maximum number of bytes to return"},` +
+	`"start_line":{"type":"integer","description":"First line to return (1-based)"},` +
+	`"end_line":{"type":"integer","description":"Last line to return (inclusive)"}},` +
+	`"required":["id"]}`
 
 func init() {
 	precomputed := map[ProviderFormat][]byte{
-		FormatAnthropic:       []byte(`{"name":"expand_context","description":"` + expandContextDescription + `","input_schema":` + idSchema + `}`),
-		FormatOpenAIChat:      []byte(`{"type":"function","function":{"name":"expand_context","description":"` + expandContextDescription + `","parameters":` + idSchema + `}}`),
-		FormatOpenAIResponses: []byte(`{"type":"function","name":"expand_context","description":"` + expandContextDescription + `","parameters":` + idSchema + `}`),
+		FormatAnthropic:       []byte(`{"name":"expand_context","description":"` + expandContextDescription + `","input_schema":` + expandContextSchema + `}`),
+		FormatOpenAIChat:      []byte(`{"type":"function","function":{"name":"expand_context","description":"` + expandContextDescription + `","parameters":` + expandContextSchema + `}}`),
+		FormatOpenAIResponses: []byte(`{"type":"function","name":"expand_context","description":"` + expandContextDescription + `","parameters":` + expandContextSchema + `}`),
 	}
 
 	Register(PhantomTool{
@@ -68,12 +68,15 @@ func DetectFormat(body []byte, provider adapters.Provider) ProviderFormat {
 	if provider == adapters.ProviderGemini {
 		return FormatGemini
 	}
-	if provider == adapters.ProviderOpenAI || provider == adapters.ProviderOllama || provider == adapters.ProviderLiteLLM || provider == adapters.ProviderMiniMax {
+	if provider == adapters.ProviderOpenAI || provider == adapters.ProviderOllama || provider == adapters.ProviderLiteLLM || provider == adapters.ProviderMiniMax || provider == adapters.ProviderOpenRouter {
 		hasInput := gjson.GetBytes(body, "input").Exists()
 		hasMessages := gjson.GetBytes(body, "messages").Exists()
 		if hasInput && !hasMessages {
 			return FormatOpenAIResponses
 		}
+		if !hasMessages && gjson.GetBytes(body, "tool_outputs").Exists() {
+			return FormatOpenAIAssistants
+		}
 		return FormatOpenAIChat
 	}
 	return FormatAnthropic
@@ -103,6 +106,13 @@ func InjectAll(body []byte, provider adapters.Provider) ([]byte, error) {
 	return body, nil
 }
 
+// Injectable reports whether the named phantom tool has a definition in the
+// format of body, so InjectAll adds it. Gemini has none yet.
+func Injectable(name string, body []byte, provider adapters.Provider) bool {
+	tool := GetByName(name)
+	return tool != nil && tool.GetJSON(DetectFormat(body, provider)) != nil
+}
+
 // BuildStub generates a minimal tool stub for the given tool name and provider.
 func BuildStub(toolName string, provider adapters.Provider, body []byte) []byte {
 	format := DetectFormat(body, provider)
@@ -18,6 +18,10 @@ const (
 
 	// FormatGemini is the Google Gemini format (kept separate for future Gemini-specific schemas).
 	FormatGemini
+
+	// FormatOpenAIAssistants is an Assistants API submit_tool_outputs body.
+	// The assistant fixes the run's tools, so no phantom tool is defined for it.
+	FormatOpenAIAssistants
 )
 
 // PhantomTool represents a single phantom tool with pre-computed JSON for each provider format.
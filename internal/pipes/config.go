@@ -3,6 +3,10 @@ package pipes
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -33,12 +37,14 @@ const (
 	StrategyExternalProvider = "external_provider" // Call external LLM provider (OpenAI/Anthropic) directly
 	StrategyRelevance        = "relevance"         // Local relevance-based tool filtering (no external API)
 	StrategyToolSearch       = "tool-search"       // Universal dispatcher: defers all tools, uses Compresr API for search
+	StrategyEmbeddings       = "embeddings"        // Rank tools by embedding similarity to the query (local or embeddings endpoint)
 
 	// Tool output specific strategies (not used for tool discovery)
 	StrategyAPI      = "api"      // Call Compresr API (tool output compression)
 	StrategyCompresr = "compresr" // Alias for StrategyAPI (backward compat)
 	StrategySimple   = "simple"   // Simple compression (first N words)
 	StrategyTrimming = "trimming" // Tail-keep compression: discard head, keep only tail based on target_compression_ratio
+	StrategyLocal    = "local"    // Deterministic compression by content shape: JSON summary, diff hunks, log dedup, head/tail
 )
 
 // IsAPIStrategy returns true if the strategy is API-based (tool output only).
@@ -50,13 +56,599 @@ func IsAPIStrategy(strategy string) bool {
 
 // Config contains configuration for all compression pipes.
 type Config struct {
-	ToolOutput    ToolOutputConfig    `yaml:"tool_output"`    // Tool output compression
-	ToolDiscovery ToolDiscoveryConfig `yaml:"tool_discovery"` // Tool filtering
-	TaskOutput    TaskOutputConfig    `yaml:"task_output"`    // Task/subagent output handling
+	Mode          string               `yaml:"mode"`             // enforce (default) | shadow
+	Shadow        ShadowModeConfig     `yaml:"shadow"`           // Background evaluation in shadow mode
+	ToolOutput    ToolOutputConfig     `yaml:"tool_output"`      // Tool output compression
+	ToolDiscovery ToolDiscoveryConfig  `yaml:"tool_discovery"`   // Tool filtering
+	TaskOutput    TaskOutputConfig     `yaml:"task_output"`      // Task/subagent output handling
+	CacheCompat   CacheCompatConfig    `yaml:"cache_compat"`     // Prompt-cache (cache_control) compatibility
+	Pipeline      PipelineConfig       `yaml:"pipeline"`         // Explicit pipe ordering and latency budgets
+	SLO           map[string]SLOConfig `yaml:"slo,omitempty"`    // Per-pipe latency SLOs, keyed by pipe name
+	PII           PIIConfig            `yaml:"pii"`              // PII masking (runs before all other pipes)
+	Injection     InjectionConfig      `yaml:"injection"`        // Prompt-injection scanning of tool outputs (runs after PII)
+	Media         MediaConfig          `yaml:"media"`            // Drop or downscale older image and document blocks
+	ResponseCache ResponseCacheConfig  `yaml:"response_cache"`   // Replay responses to identical deterministic requests
+	Custom        []CustomPipeConfig   `yaml:"custom,omitempty"` // User-supplied pipes (exec plugins, registered types)
+}
+
+// SLOConfig sets a latency SLO for one pipe.
+//
+// Deadline bounds every run: it is set on the request context, so in-flight
+// external compression calls are cancelled and their original content passes
+// through. P95 is evaluated over the last Window runs; when it is exceeded the
+// pipe switches to DegradeTo (a local strategy) for RecoverAfter, then the
+// primary strategy is tried again.
+type SLOConfig struct {
+	Deadline     time.Duration `yaml:"deadline"`      // Per-request cap (0 = none)
+	P95          time.Duration `yaml:"p95"`           // Target p95 latency (0 = no degradation)
+	Window       int           `yaml:"window"`        // Runs per p95 evaluation (default: 50)
+	DegradeTo    string        `yaml:"degrade_to"`    // Local strategy while degraded (empty = report only)
+	RecoverAfter time.Duration `yaml:"recover_after"` // Time degraded before retrying primary (default: 5m)
+}
+
+// SLO defaults.
+const (
+	DefaultSLOWindow       = 50
+	DefaultSLORecoverAfter = 5 * time.Minute
+)
+
+// localStrategies lists the strategies each pipe can degrade to without external calls.
+var localStrategies = map[string][]string{
+	PipeNameToolOutput:    {StrategySimple, StrategyTrimming, StrategyLocal, StrategyPassthrough},
+	PipeNameToolDiscovery: {StrategyRelevance, StrategyPassthrough},
+	PipeNameTaskOutput:    {StrategyPassthrough},
+}
+
+// Validate validates an SLO for the named pipe.
+func (s *SLOConfig) Validate(pipe string) error {
+	if !isPipeName(pipe) {
+		return fmt.Errorf("slo: unknown pipe %q", pipe)
+	}
+	if s.Deadline < 0 || s.P95 < 0 || s.RecoverAfter < 0 || s.Window < 0 {
+		return fmt.Errorf("slo: %s: durations and window must be >= 0", pipe)
+	}
+	if s.DegradeTo == "" {
+		return nil
+	}
+	if s.P95 == 0 {
+		return fmt.Errorf("slo: %s: degrade_to requires p95", pipe)
+	}
+	for _, local := range localStrategies[pipe] {
+		if s.DegradeTo == local {
+			return nil
+		}
+	}
+	return fmt.Errorf("slo: %s: degrade_to %q is not a local strategy, must be one of %v", pipe, s.DegradeTo, localStrategies[pipe])
+}
+
+// Pipe names accepted in pipeline.order and pipeline.budgets, along with
+// the names of pipes.custom entries.
+const (
+	PipeNameTaskOutput    = "task_output"
+	PipeNameToolOutput    = "tool_output"
+	PipeNameToolDiscovery = "tool_discovery"
+
+	// PipeNamePII and PipeNameInjection always run first, in that order,
+	// and cannot appear in pipeline order or rules.
+	PipeNamePII       = "pii"
+	PipeNameInjection = "injection"
+
+	// PipeNameMedia runs after the security pipes, before every other pipe,
+	// and cannot appear in pipeline order or rules.
+	PipeNameMedia = "media"
+)
+
+// PipelineConfig composes the enabled pipes into an explicit sequential chain.
+//
+// With Order empty the router uses its default layout: task_output first, then
+// tool_output and tool_discovery in parallel. With Order set, only the listed
+// pipes run, one after another, each seeing the previous pipe's output.
+// Budgets and ShortCircuit apply to the ordered chain only.
+type PipelineConfig struct {
+	// Order lists pipe names in execution order,
+	// e.g. [task_output, tool_discovery, tool_output].
+	Order []string `yaml:"order,omitempty"`
+
+	// Budgets caps each pipe's latency. The budget is applied as a deadline on
+	// the request context; a pipe that overruns it has its output discarded.
+	Budgets map[string]time.Duration `yaml:"budgets,omitempty"`
+
+	// ShortCircuit stops the chain after the first pipe that fails or overruns
+	// its budget. Remaining pipes are skipped and the last good body is forwarded.
+	ShortCircuit bool `yaml:"short_circuit"`
+
+	// Rules select a chain per request. The first matching rule wins;
+	// requests that match no rule use Order (or the default layout).
+	Rules []RouteRule `yaml:"rules,omitempty"`
+}
+
+// RouteRule maps requests to a pipe chain. Every non-empty matcher must match.
+// Path and Model are path.Match globs (e.g. "/v1/messages*", "claude-haiku-*").
+type RouteRule struct {
+	Name       string   `yaml:"name"`                  // Reported by the route debug endpoint
+	Path       string   `yaml:"path,omitempty"`        // Glob on the request path
+	Provider   string   `yaml:"provider,omitempty"`    // Exact provider name (anthropic, openai, ...)
+	Model      string   `yaml:"model,omitempty"`       // Glob on the request model
+	SessionTag string   `yaml:"session_tag,omitempty"` // Tag that must be present in X-Session-Tags
+	MinBytes   int      `yaml:"min_bytes,omitempty"`   // Request body must be at least this large
+	MaxBytes   int      `yaml:"max_bytes,omitempty"`   // Request body must be at most this large (0 = no limit)
+	Chain      []string `yaml:"chain"`                 // Pipes to run in order; empty = forward unmodified
+}
+
+// Validate validates a routing rule.
+func (r *RouteRule) Validate() error {
+	return r.validate(nil)
+}
+
+// validate validates a routing rule whose chain may also name custom pipes.
+func (r *RouteRule) validate(custom map[string]bool) error {
+	if r.Name == "" {
+		return fmt.Errorf("pipeline: rule name is required")
+	}
+	for _, pattern := range []string{r.Path, r.Model} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pipeline: rule %q has invalid glob %q: %w", r.Name, pattern, err)
+		}
+	}
+	if r.MinBytes < 0 || r.MaxBytes < 0 {
+		return fmt.Errorf("pipeline: rule %q min_bytes/max_bytes must be >= 0", r.Name)
+	}
+	if r.MaxBytes > 0 && r.MinBytes > r.MaxBytes {
+		return fmt.Errorf("pipeline: rule %q min_bytes (%d) exceeds max_bytes (%d)", r.Name, r.MinBytes, r.MaxBytes)
+	}
+	if err := validateChain(r.Chain, custom); err != nil {
+		return fmt.Errorf("pipeline: rule %q: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Validate validates pipeline composition config.
+func (p *PipelineConfig) Validate() error {
+	return p.validate(nil)
+}
+
+// validate validates pipeline composition config; custom holds the names of
+// pipes.custom entries, which may appear alongside the built-in pipes.
+func (p *PipelineConfig) validate(custom map[string]bool) error {
+	if err := validateChain(p.Order, custom); err != nil {
+		return fmt.Errorf("pipeline: order: %w", err)
+	}
+	for name, budget := range p.Budgets {
+		if !isPipeName(name) && !custom[name] {
+			return fmt.Errorf("pipeline: unknown pipe %q in budgets", name)
+		}
+		if budget <= 0 {
+			return fmt.Errorf("pipeline: budget for %q must be > 0, got %s", name, budget)
+		}
+	}
+	names := make(map[string]bool, len(p.Rules))
+	for i := range p.Rules {
+		if err := p.Rules[i].validate(custom); err != nil {
+			return err
+		}
+		if names[p.Rules[i].Name] {
+			return fmt.Errorf("pipeline: duplicate rule name %q", p.Rules[i].Name)
+		}
+		names[p.Rules[i].Name] = true
+	}
+	return nil
+}
+
+// validateChain checks that a chain lists only known pipes, each at most once.
+func validateChain(chain []string, custom map[string]bool) error {
+	seen := make(map[string]bool, len(chain))
+	for _, name := range chain {
+		if !isPipeName(name) && !custom[name] {
+			return fmt.Errorf("unknown pipe %q, must be 'task_output', 'tool_output', 'tool_discovery' or a pipes.custom name", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("pipe %q listed more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func isPipeName(name string) bool {
+	switch name {
+	case PipeNameTaskOutput, PipeNameToolOutput, PipeNameToolDiscovery:
+		return true
+	}
+	return false
+}
+
+// CacheCompatConfig controls how pipes interact with provider prompt caching.
+//
+// Requests carrying cache_control blocks are always checked after the pipes run;
+// a prefix change is logged and counted as a cache invalidation. With Enabled,
+// the gateway additionally restores everything before the final cache_control
+// breakpoint, so compression only ever applies to uncached content.
+type CacheCompatConfig struct {
+	Enabled bool `yaml:"enabled"` // Never rewrite content before the final cache breakpoint
+}
+
+// SHADOW MODE CONFIG
+
+// Pipeline modes (Config.Mode).
+const (
+	ModeEnforce = "enforce" // Forward the compressed request (default)
+	ModeShadow  = "shadow"  // Forward the original request; compress in the background and log the result
+)
+
+// ShadowModeConfig tunes shadow mode. Every request is forwarded uncompressed
+// while the pipes run on a copy in the background, so compression can be
+// measured before it is allowed to change what the model sees.
+type ShadowModeConfig struct {
+	MaxConcurrent int  `yaml:"max_concurrent"` // Background runs at once; requests over it are not evaluated (default: 4)
+	LogBodies     bool `yaml:"log_bodies"`     // Also log the body that would have been sent
+}
+
+// DefaultShadowMaxConcurrent is the default ShadowModeConfig.MaxConcurrent.
+const DefaultShadowMaxConcurrent = 4
+
+// IsShadow reports whether the pipes run in shadow mode.
+func (p *Config) IsShadow() bool {
+	return p.Mode == ModeShadow
+}
+
+// CUSTOM PIPE CONFIG
+
+// CustomTypeExec runs a custom pipe as a subprocess speaking JSON-RPC over
+// stdio. Other custom pipe types are registered with RegisterCustom.
+const CustomTypeExec = "exec"
+
+// Custom pipe failure policies (CustomPipeConfig.OnError).
+const (
+	OnErrorPassthrough = "passthrough" // Forward the pipe's input unchanged (default)
+	OnErrorReject      = "reject"      // Fail closed: do not forward the request
+)
+
+// DefaultCustomPipeTimeout bounds one custom pipe call.
+const DefaultCustomPipeTimeout = 5 * time.Second
+
+// CustomPipeConfig declares a user-supplied pipe.
+//
+// Custom pipes run after PII masking and before the built-in pipes, in the
+// order listed. With pipeline.order or a routing rule they run where their
+// name appears in the chain, and only there.
+type CustomPipeConfig struct {
+	Name    string            `yaml:"name"`              // Used in pipeline chains, budgets, telemetry and metrics
+	Type    string            `yaml:"type"`              // exec (default) or a registered type
+	Command []string          `yaml:"command,omitempty"` // exec: program and arguments
+	Env     map[string]string `yaml:"env,omitempty"`     // exec: extra environment variables
+	Timeout time.Duration     `yaml:"timeout"`           // Per-request call timeout (default: 5s)
+	OnError string            `yaml:"on_error"`          // passthrough (default) | reject
+	Options map[string]any    `yaml:"options,omitempty"` // Passed to the pipe as is
+}
+
+// Validate validates a custom pipe declaration.
+func (c *CustomPipeConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("custom: pipe name is required")
+	}
+	for _, r := range c.Name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' {
+			return fmt.Errorf("custom: pipe name %q may only contain a-z, 0-9, _ and -", c.Name)
+		}
+	}
+	if isPipeName(c.Name) || c.Name == PipeNamePII || c.Name == PipeNameInjection || c.Name == PipeNameMedia {
+		return fmt.Errorf("custom: pipe name %q is a built-in pipe", c.Name)
+	}
+	switch typ := c.CustomType(); {
+	case typ == CustomTypeExec:
+		if len(c.Command) == 0 || c.Command[0] == "" {
+			return fmt.Errorf("custom: %s: exec pipes need a command", c.Name)
+		}
+	case !IsCustomRegistered(typ):
+		return fmt.Errorf("custom: %s: unknown type %q, must be %q or one of %v", c.Name, typ, CustomTypeExec, CustomTypes())
+	}
+	if c.OnError != "" && c.OnError != OnErrorPassthrough && c.OnError != OnErrorReject {
+		return fmt.Errorf("custom: %s: on_error must be %q or %q, got %q", c.Name, OnErrorPassthrough, OnErrorReject, c.OnError)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("custom: %s: timeout must be >= 0", c.Name)
+	}
+	return nil
+}
+
+// CustomType returns the pipe type, defaulting to exec.
+func (c *CustomPipeConfig) CustomType() string {
+	if c.Type == "" {
+		return CustomTypeExec
+	}
+	return c.Type
+}
+
+// CallTimeout returns the per-request call timeout, defaulting to DefaultCustomPipeTimeout.
+func (c *CustomPipeConfig) CallTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultCustomPipeTimeout
+	}
+	return c.Timeout
+}
+
+// FailClosed reports whether a failure of this pipe blocks the request.
+func (c *CustomPipeConfig) FailClosed() bool { return c.OnError == OnErrorReject }
+
+// RESPONSE CACHE PIPE CONFIG
+
+// ResponseCacheConfig configures the response cache pipe.
+//
+// A request that pins temperature to 0 is keyed by its full body, path, target
+// and credential. An identical request within TTL gets the stored response
+// back without being forwarded, so retried or replayed agent turns cost nothing.
+type ResponseCacheConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	TTL          time.Duration `yaml:"ttl"`            // How long a response is replayed (default: 10m)
+	MaxEntries   int           `yaml:"max_entries"`    // Max cached responses (default: 256)
+	MaxBodyBytes int           `yaml:"max_body_bytes"` // Larger responses are not cached (default: 4 MiB)
+}
+
+// Response cache defaults.
+const (
+	DefaultResponseCacheTTL          = 10 * time.Minute
+	DefaultResponseCacheEntries      = 256
+	DefaultResponseCacheMaxBodyBytes = 4 << 20
+)
+
+// Validate validates the response cache config.
+func (c *ResponseCacheConfig) Validate() error {
+	if c.TTL < 0 {
+		return fmt.Errorf("response_cache: ttl must be >= 0")
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("response_cache: max_entries must be >= 0")
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("response_cache: max_body_bytes must be >= 0")
+	}
+	return nil
+}
+
+// PII PIPE CONFIG
+
+// Built-in PII detector names.
+const (
+	PIIDetectorEmail      = "email"
+	PIIDetectorPhone      = "phone"
+	PIIDetectorSSN        = "ssn"
+	PIIDetectorCreditCard = "credit_card" // Luhn-validated
+	PIIDetectorIBAN       = "iban"        // mod-97 validated
+	PIIDetectorIPv4       = "ipv4"
+)
+
+// BuiltinPIIDetectors lists every built-in detector; the default when none are configured.
+var BuiltinPIIDetectors = []string{
+	PIIDetectorEmail, PIIDetectorPhone, PIIDetectorSSN,
+	PIIDetectorCreditCard, PIIDetectorIBAN, PIIDetectorIPv4,
+}
+
+// PIIConfig configures the PII masking pipe.
+//
+// Detected entities in outbound requests are replaced with stable tokens
+// (e.g. [[PII_EMAIL_3f9a2c1b0d]]); the token → value map is kept in the shadow
+// store so tokens the model echoes back are restored in the response.
+type PIIConfig struct {
+	Enabled   bool         `yaml:"enabled"`
+	Detectors []string     `yaml:"detectors"` // Built-in detectors to run (default: all)
+	API       PIIAPIConfig `yaml:"api"`       // Optional external detection service
+	MaskOnly  bool         `yaml:"mask_only"` // Don't restore originals in responses
+}
+
+// PIIAPIConfig configures an external PII detection endpoint.
+// The gateway POSTs {"text": "..."} and expects
+// {"entities": [{"start": 0, "end": 5, "type": "NAME"}]} with byte offsets.
+type PIIAPIConfig struct {
+	URL     string        `yaml:"url"`
+	APIKey  string        `yaml:"api_key"` // Sent as Authorization: Bearer
+	Timeout time.Duration `yaml:"timeout"` // Default: 2s
+}
+
+// Validate validates the PII config.
+func (p *PIIConfig) Validate() error {
+	if !p.Enabled {
+		return nil
+	}
+	for _, name := range p.Detectors {
+		known := false
+		for _, builtin := range BuiltinPIIDetectors {
+			if name == builtin {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("pii: unknown detector %q, must be one of %v", name, BuiltinPIIDetectors)
+		}
+	}
+	if p.API.URL != "" && !strings.HasPrefix(p.API.URL, "http://") && !strings.HasPrefix(p.API.URL, "https://") {
+		return fmt.Errorf("pii: api.url must be an http(s) URL")
+	}
+	if p.API.Timeout < 0 {
+		return fmt.Errorf("pii: api.timeout must be >= 0")
+	}
+	return nil
+}
+
+// PROMPT INJECTION PIPE CONFIG
+
+// Built-in prompt-injection detector names.
+const (
+	InjectionDetectorPhrase        = "phrase"         // Directives such as "ignore previous instructions"
+	InjectionDetectorHiddenUnicode = "hidden_unicode" // Zero-width, bidi-override and tag characters
+	InjectionDetectorBase64        = "base64"         // Base64 blobs that decode to a directive
+
+	// InjectionDetectorPattern reports matches of InjectionConfig.Patterns.
+	InjectionDetectorPattern = "pattern"
+)
+
+// BuiltinInjectionDetectors lists every built-in detector; the default when none are configured.
+var BuiltinInjectionDetectors = []string{
+	InjectionDetectorPhrase, InjectionDetectorHiddenUnicode, InjectionDetectorBase64,
+}
+
+// Prompt-injection actions (InjectionConfig.Action).
+const (
+	InjectionActionFlag  = "flag"  // Forward unchanged, report in X-Gateway-Prompt-Injection (default)
+	InjectionActionStrip = "strip" // Remove the matches from the tool output, then forward
+	InjectionActionBlock = "block" // Refuse the request with 400
+)
+
+// InjectionConfig configures prompt-injection scanning of tool outputs.
+//
+// Tool results (web fetches, file contents, command output) are scanned
+// before any compression pipe sees them; user and system messages are not.
+type InjectionConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Action    string   `yaml:"action"`    // flag (default) | strip | block
+	Detectors []string `yaml:"detectors"` // Built-in detectors to run (default: all)
+	Patterns  []string `yaml:"patterns"`  // Extra case-insensitive regexes (detector "pattern")
+}
+
+// EffectiveAction returns the configured action, defaulting to flag.
+func (c *InjectionConfig) EffectiveAction() string {
+	if c.Action == "" {
+		return InjectionActionFlag
+	}
+	return c.Action
+}
+
+// Validate validates the prompt-injection config.
+func (c *InjectionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Action {
+	case "", InjectionActionFlag, InjectionActionStrip, InjectionActionBlock:
+	default:
+		return fmt.Errorf("injection: action must be %q, %q or %q, got %q",
+			InjectionActionFlag, InjectionActionStrip, InjectionActionBlock, c.Action)
+	}
+	for _, name := range c.Detectors {
+		known := false
+		for _, builtin := range BuiltinInjectionDetectors {
+			if name == builtin {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("injection: unknown detector %q, must be one of %v", name, BuiltinInjectionDetectors)
+		}
+	}
+	for _, pattern := range c.Patterns {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return fmt.Errorf("injection: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Media actions (MediaRule.Action).
+const (
+	MediaActionDrop      = "drop"      // Replace the block with a placeholder naming an expand_context ref
+	MediaActionDownscale = "downscale" // Re-encode images at MaxDimension; other media is left as is
+)
+
+// Media defaults.
+const (
+	DefaultMediaKeepRecent   = 3
+	DefaultMediaMaxDimension = 768
+)
+
+// MediaConfig configures the media pipe, which shrinks base64 image and
+// document blocks (user content and tool results) other than the most recent.
+//
+// Each block is handled by the first rule whose Types match its media type;
+// blocks matching no rule are forwarded unchanged. Without rules, every media
+// type is dropped beyond the DefaultMediaKeepRecent most recent blocks.
+type MediaConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Rules   []MediaRule `yaml:"rules,omitempty"`
+}
+
+// MediaRule sets the handling for a group of media types.
+type MediaRule struct {
+	Types        []string `yaml:"types"`         // path.Match globs on the media type, e.g. "image/*", "application/pdf"
+	Action       string   `yaml:"action"`        // drop (default) | downscale
+	KeepRecent   int      `yaml:"keep_recent"`   // Blocks matching this rule kept as is, counted from the end (0 = none)
+	MaxDimension int      `yaml:"max_dimension"` // Longest side in pixels for downscale (default: 768)
+}
+
+// DefaultMediaRules apply when MediaConfig.Rules is empty.
+var DefaultMediaRules = []MediaRule{
+	{Types: []string{"*/*"}, Action: MediaActionDrop, KeepRecent: DefaultMediaKeepRecent},
+}
+
+// EffectiveRules returns the configured rules, or DefaultMediaRules.
+func (c *MediaConfig) EffectiveRules() []MediaRule {
+	if len(c.Rules) == 0 {
+		return DefaultMediaRules
+	}
+	return c.Rules
+}
+
+// EffectiveAction returns the rule's action, defaulting to drop.
+func (r *MediaRule) EffectiveAction() string {
+	if r.Action == "" {
+		return MediaActionDrop
+	}
+	return r.Action
+}
+
+// EffectiveMaxDimension returns the rule's max dimension, defaulting to
+// DefaultMediaMaxDimension.
+func (r *MediaRule) EffectiveMaxDimension() int {
+	if r.MaxDimension == 0 {
+		return DefaultMediaMaxDimension
+	}
+	return r.MaxDimension
+}
+
+// Matches reports whether the rule applies to mediaType.
+func (r *MediaRule) Matches(mediaType string) bool {
+	for _, pattern := range r.Types {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the media pipe config.
+func (c *MediaConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for i, r := range c.Rules {
+		if len(r.Types) == 0 {
+			return fmt.Errorf("media: rule %d: types is required", i)
+		}
+		for _, pattern := range r.Types {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("media: rule %d: invalid type glob %q: %w", i, pattern, err)
+			}
+		}
+		switch r.Action {
+		case "", MediaActionDrop, MediaActionDownscale:
+		default:
+			return fmt.Errorf("media: rule %d: action must be %q or %q, got %q", i, MediaActionDrop, MediaActionDownscale, r.Action)
+		}
+		if r.KeepRecent < 0 || r.MaxDimension < 0 {
+			return fmt.Errorf("media: rule %d: keep_recent and max_dimension must be >= 0", i)
+		}
+	}
+	return nil
 }
 
 // Validate validates pipe configurations.
 func (p *Config) Validate() error {
+	if p.Mode != "" && p.Mode != ModeEnforce && p.Mode != ModeShadow {
+		return fmt.Errorf("pipes.mode must be %q or %q, got %q", ModeEnforce, ModeShadow, p.Mode)
+	}
+	if p.Shadow.MaxConcurrent < 0 {
+		return fmt.Errorf("pipes.shadow.max_concurrent must be >= 0")
+	}
 	if err := p.ToolOutput.Validate(); err != nil {
 		return err
 	}
@@ -66,6 +658,37 @@ func (p *Config) Validate() error {
 	if err := p.TaskOutput.Validate(); err != nil {
 		return err
 	}
+	custom := make(map[string]bool, len(p.Custom))
+	for i := range p.Custom {
+		c := &p.Custom[i]
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if custom[c.Name] {
+			return fmt.Errorf("custom: duplicate pipe name %q", c.Name)
+		}
+		custom[c.Name] = true
+	}
+	if err := p.Pipeline.validate(custom); err != nil {
+		return err
+	}
+	for name, slo := range p.SLO {
+		if err := slo.Validate(name); err != nil {
+			return err
+		}
+	}
+	if err := p.PII.Validate(); err != nil {
+		return err
+	}
+	if err := p.Injection.Validate(); err != nil {
+		return err
+	}
+	if err := p.Media.Validate(); err != nil {
+		return err
+	}
+	if err := p.ResponseCache.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -91,10 +714,20 @@ type ToolOutputConfig struct {
 	TargetCompressionRatio float64 `yaml:"target_compression_ratio"` // Sent to API: 0.1 = least aggressive, 0.9 = most aggressive. 0 = API default.
 	RefusalThreshold       float64 `yaml:"refusal_threshold"`        // Reject compression if token savings < this ratio (default: 0.05 = must save at least 5%)
 
+	// PromptTemplate overrides the built-in prompts for strategy=external_provider.
+	PromptTemplate PromptTemplateConfig `yaml:"prompt_template,omitempty"`
+
 	// Expand context feature
 	EnableExpandContext bool `yaml:"enable_expand_context"` // Inject expand_context tool
 	IncludeExpandHint   bool `yaml:"include_expand_hint"`   // Add hint to compressed content
 
+	// Expansion paging. An original longer than ExpandPageBytes is returned one
+	// page at a time, with a footer telling the model how to read the next one.
+	// ExpandBudgetBytes caps the total bytes expand_context returns in one
+	// request. 0 = unlimited.
+	ExpandPageBytes   int `yaml:"expand_page_bytes,omitempty"`
+	ExpandBudgetBytes int `yaml:"expand_budget_bytes,omitempty"`
+
 	// BypassCostCheck disables the automatic cost-based skip (useful for testing/benchmarking).
 	// When false (default), cheap models (e.g. gpt-4o-mini) are skipped automatically.
 	BypassCostCheck bool `yaml:"bypass_cost_check"`
@@ -105,6 +738,17 @@ type ToolOutputConfig struct {
 	// ContentFormats controls which detected text formats are eligible for compression.
 	// Default: all text-based formats (text, json, markdown) are compressed.
 	ContentFormats ContentFormatsConfig `yaml:"content_formats,omitempty"`
+
+	// ImageMaxDimension downscales base64 images in tool results (e.g. browser
+	// screenshots) so their longest side is at most this many pixels before
+	// forwarding. 0 = forward images unchanged.
+	ImageMaxDimension int `yaml:"image_max_dimension,omitempty"`
+
+	// Dedup replaces earlier copies of a tool output that is repeated later
+	// in the conversation (same content hash) with a short stub naming the
+	// latest copy. Outputs shorter than DedupMinBytes (default: 256) are kept.
+	Dedup         bool `yaml:"dedup,omitempty"`
+	DedupMinBytes int  `yaml:"dedup_min_bytes,omitempty"`
 }
 
 // ContentFormatsConfig narrows which text formats are eligible for compression.
@@ -130,10 +774,27 @@ func (t *ToolOutputConfig) Validate() error {
 		return fmt.Errorf("tool_output: target_compression_ratio must be between %.1f (least aggressive) and %.1f (most aggressive), got %.2f",
 			MinTargetCompressionRatio, MaxTargetCompressionRatio, t.TargetCompressionRatio)
 	}
+	if t.ImageMaxDimension < 0 {
+		return fmt.Errorf("tool_output: image_max_dimension must be >= 0, got %d", t.ImageMaxDimension)
+	}
+	if t.DedupMinBytes < 0 {
+		return fmt.Errorf("tool_output: dedup_min_bytes must be >= 0, got %d", t.DedupMinBytes)
+	}
+	if t.ExpandPageBytes < 0 {
+		return fmt.Errorf("tool_output: expand_page_bytes must be >= 0, got %d", t.ExpandPageBytes)
+	}
+	if t.ExpandBudgetBytes < 0 {
+		return fmt.Errorf("tool_output: expand_budget_bytes must be >= 0, got %d", t.ExpandBudgetBytes)
+	}
+	if t.PromptTemplate.IsSet() {
+		if _, err := t.PromptTemplate.Compile(); err != nil {
+			return fmt.Errorf("tool_output: %w", err)
+		}
+	}
 	if t.Strategy == "" || t.Strategy == StrategyPassthrough {
 		return nil
 	}
-	if t.Strategy == StrategySimple || t.Strategy == StrategyTrimming {
+	if t.Strategy == StrategySimple || t.Strategy == StrategyTrimming || t.Strategy == StrategyLocal {
 		return nil
 	}
 	if IsAPIStrategy(t.Strategy) {
@@ -150,7 +811,7 @@ func (t *ToolOutputConfig) Validate() error {
 		}
 		return nil
 	}
-	return fmt.Errorf("tool_output: unknown strategy %q, must be 'passthrough', 'simple', 'trimming', 'compresr', or 'external_provider'", t.Strategy)
+	return fmt.Errorf("tool_output: unknown strategy %q, must be 'passthrough', 'simple', 'trimming', 'local', 'compresr', or 'external_provider'", t.Strategy)
 }
 
 // TOOL DISCOVERY PIPE CONFIG
@@ -175,6 +836,10 @@ type ToolDiscoveryConfig struct {
 	AlwaysKeep     []string `yaml:"always_keep"`     // Tool names to never filter out
 	TokenThreshold int      `yaml:"token_threshold"` // Trigger filtering when total tool definition tokens > this (default: 512)
 
+	// MCP servers (tools named mcp__<server>__<tool>)
+	KeepServers []string `yaml:"keep_servers,omitempty"` // Servers whose tools are never filtered out
+	DropServers []string `yaml:"drop_servers,omitempty"` // Servers whose tools are always deferred (still searchable)
+
 	// Lazy loading settings (when enabled, tools become [deferred] stubs)
 	EnableSearchFallback bool   `yaml:"enable_search_fallback"` // Inject gateway_search_tools (default: true)
 	SearchToolName       string `yaml:"search_tool_name"`       // Name of the search tool (default: "gateway_search_tools")
@@ -187,6 +852,9 @@ type ToolDiscoveryConfig struct {
 	// ═══════════════════════════════════════════════════════════════════
 	SchemaCompression SchemaCompressionConfig `yaml:"schema_compression"`
 
+	// Embeddings strategy settings (strategy: embeddings)
+	Embeddings EmbeddingsConfig `yaml:"embeddings,omitempty"`
+
 	// DEPRECATED: Use schema_compression instead
 	SearchResultCompression          SearchResultCompressionConfig `yaml:"search_result_compression"`
 	EnableToolDescriptionCompression bool                          `yaml:"enable_tool_description_compression"`
@@ -197,6 +865,11 @@ func (d *ToolDiscoveryConfig) Validate() error {
 	if !d.Enabled {
 		return nil
 	}
+	for _, server := range d.DropServers {
+		if slices.Contains(d.KeepServers, server) {
+			return fmt.Errorf("tool_discovery: server %q is in both keep_servers and drop_servers", server)
+		}
+	}
 	switch d.Strategy {
 	case "", StrategyPassthrough:
 		return nil
@@ -206,9 +879,38 @@ func (d *ToolDiscoveryConfig) Validate() error {
 		return nil // Compresr API-backed filtering, falls back to local relevance if unavailable
 	case StrategyToolSearch:
 		return nil // Universal dispatcher: defers all tools, uses Compresr API for search
+	case StrategyEmbeddings:
+		return d.Embeddings.Validate()
 	default:
-		return fmt.Errorf("tool_discovery: unknown strategy %q, must be 'passthrough', 'relevance', 'compresr', or 'tool-search'", d.Strategy)
+		return fmt.Errorf("tool_discovery: unknown strategy %q, must be 'passthrough', 'relevance', 'compresr', 'tool-search', or 'embeddings'", d.Strategy)
+	}
+}
+
+// EmbeddingsConfig configures the embeddings tool discovery strategy.
+//
+// With no endpoint, tool descriptions and the query are embedded locally by
+// feature hashing of words and character trigrams. With an endpoint, vectors
+// come from an OpenAI-compatible /v1/embeddings API (OpenAI, Ollama, vLLM, ...).
+// Tool vectors are cached per session; only the query is embedded each turn.
+type EmbeddingsConfig struct {
+	Endpoint string        `yaml:"endpoint,omitempty"` // Embeddings URL, e.g. http://localhost:11434/v1/embeddings (empty = local)
+	APIKey   string        `yaml:"api_key,omitempty"`  // Sent as Authorization: Bearer
+	Model    string        `yaml:"model,omitempty"`    // Embedding model sent to the endpoint
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // Endpoint timeout (default: 5s)
+}
+
+// Validate validates the embeddings config.
+func (e *EmbeddingsConfig) Validate() error {
+	if e.Endpoint != "" && !strings.HasPrefix(e.Endpoint, "http://") && !strings.HasPrefix(e.Endpoint, "https://") {
+		return fmt.Errorf("tool_discovery: embeddings.endpoint must be an http(s) URL")
 	}
+	if e.Endpoint != "" && e.Model == "" {
+		return fmt.Errorf("tool_discovery: embeddings.model is required with embeddings.endpoint")
+	}
+	if e.Timeout < 0 {
+		return fmt.Errorf("tool_discovery: embeddings.timeout must be >= 0")
+	}
+	return nil
 }
 
 // STRATEGY-SPECIFIC CONFIGS
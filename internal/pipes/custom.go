@@ -0,0 +1,68 @@
+// Custom pipes - registration API for user-supplied pipe types.
+package pipes
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// CustomFactory builds a pipe for one pipes.custom entry. The router calls it
+// once per config load and shares the pipe between workers, so Process must
+// be safe for concurrent use. A pipe that also implements io.Closer is
+// closed when the config is reloaded or the gateway shuts down.
+type CustomFactory func(cfg CustomPipeConfig) (Pipe, error)
+
+var (
+	customMu        sync.RWMutex
+	customFactories = map[string]CustomFactory{}
+)
+
+// RegisterCustom makes a pipe type available as pipes.custom[].type: name.
+// Call before the config is loaded; the exec type cannot be replaced.
+func RegisterCustom(name string, factory CustomFactory) {
+	if name == "" || name == CustomTypeExec {
+		panic(fmt.Sprintf("pipes: cannot register custom pipe type %q", name))
+	}
+	customMu.Lock()
+	defer customMu.Unlock()
+	customFactories[name] = factory
+}
+
+// IsCustomRegistered reports whether a custom pipe type has been registered.
+func IsCustomRegistered(name string) bool {
+	_, ok := CustomFactoryFor(name)
+	return ok
+}
+
+// CustomFactoryFor returns the factory registered for a custom pipe type.
+func CustomFactoryFor(name string) (CustomFactory, bool) {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	f, ok := customFactories[name]
+	return f, ok
+}
+
+// CustomTypes returns the registered custom pipe types, sorted.
+func CustomTypes() []string {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	names := make([]string, 0, len(customFactories))
+	for name := range customFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RejectError is returned by a pipe that refuses to let a request through,
+// e.g. a prompt injection filter. The gateway answers the client with 400
+// and does not forward the request.
+type RejectError struct {
+	Pipe   string
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("request rejected by pipe %s: %s", e.Pipe, e.Reason)
+}
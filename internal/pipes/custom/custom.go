@@ -0,0 +1,31 @@
+// Package custom builds user-supplied pipes declared under pipes.custom.
+//
+// DESIGN: A custom pipe is either an exec plugin - any program that speaks
+// newline-delimited JSON-RPC 2.0 on stdin/stdout, see ExecPipe - or a type
+// registered in-process with pipes.RegisterCustom. Both implement pipes.Pipe
+// and run in the router like the built-in pipes: after PII masking, before
+// the built-in pipes unless pipeline.order places them elsewhere.
+package custom
+
+import (
+	"fmt"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// New builds the pipe for one pipes.custom entry.
+func New(cfg pipes.CustomPipeConfig) (pipes.Pipe, error) {
+	typ := cfg.CustomType()
+	if typ == pipes.CustomTypeExec {
+		return NewExec(cfg), nil
+	}
+	factory, ok := pipes.CustomFactoryFor(typ)
+	if !ok {
+		return nil, fmt.Errorf("custom pipe %s: unknown type %q", cfg.Name, typ)
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("custom pipe %s: %w", cfg.Name, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,329 @@
+package custom
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// Environment variables set for exec plugins.
+const (
+	EnvPipeName    = "CONTEXT_GATEWAY_PIPE_NAME"
+	EnvPipeOptions = "CONTEXT_GATEWAY_PIPE_OPTIONS" // pipes.custom[].options as JSON
+)
+
+// MethodProcess is the JSON-RPC method called once per request.
+const MethodProcess = "process"
+
+// restartDelay is how long a plugin that exited stays down before the next
+// call starts it again, so a crashing plugin does not fork on every request.
+const restartDelay = time.Second
+
+// ProcessParams are the params of a process call.
+type ProcessParams struct {
+	RequestID string          `json:"request_id"`
+	Provider  string          `json:"provider"`
+	Model     string          `json:"model,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	UserQuery string          `json:"user_query,omitempty"`
+	Body      json.RawMessage `json:"body"` // Request body as forwarded so far
+}
+
+// ProcessResult is the result of a process call.
+type ProcessResult struct {
+	Body    json.RawMessage    `json:"body,omitempty"`    // Replacement body; absent or null = unchanged
+	Reject  string             `json:"reject,omitempty"`  // Non-empty: refuse the request with this reason
+	Metrics map[string]float64 `json:"metrics,omitempty"` // Reported in the pipe's telemetry
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ExecPipe runs a plugin program and calls it once per request:
+//
+//	-> {"jsonrpc":"2.0","id":7,"method":"process","params":{"request_id":"...","provider":"anthropic","body":{...}}}
+//	<- {"jsonrpc":"2.0","id":7,"result":{"body":{...},"metrics":{"blocks_pruned":3}}}
+//
+// One message per line. The plugin is started on the first request and kept
+// running; concurrent requests are multiplexed by id, so responses may come
+// back in any order. Its stderr goes to the gateway log. A plugin that exits
+// is restarted on a later request.
+type ExecPipe struct {
+	cfg    pipes.CustomPipeConfig
+	nextID atomic.Int64
+
+	mu           sync.Mutex
+	proc         *process
+	restartAfter time.Time
+	closed       bool
+}
+
+// NewExec returns an exec pipe. The plugin is started on first use.
+func NewExec(cfg pipes.CustomPipeConfig) *ExecPipe {
+	return &ExecPipe{cfg: cfg}
+}
+
+// Name returns the configured pipe name.
+func (e *ExecPipe) Name() string { return e.cfg.Name }
+
+// Strategy returns the pipe type.
+func (e *ExecPipe) Strategy() string { return pipes.CustomTypeExec }
+
+// Enabled returns true: declared custom pipes always run.
+func (e *ExecPipe) Enabled() bool { return true }
+
+// Process sends the request to the plugin and applies its result.
+func (e *ExecPipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	parent := ctx.RequestCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	callCtx, cancel := context.WithTimeout(parent, e.cfg.CallTimeout())
+	defer cancel()
+
+	raw, err := e.call(callCtx, MethodProcess, ProcessParams{
+		RequestID: ctx.RequestID,
+		Provider:  string(ctx.Provider),
+		Model:     ctx.TargetModel,
+		SessionID: ctx.SessionID,
+		UserQuery: ctx.UserQuery,
+		Body:      json.RawMessage(ctx.OriginalRequest),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res ProcessResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("invalid process result: %w", err)
+	}
+	ctx.CustomMetrics = res.Metrics
+	if res.Reject != "" {
+		return nil, &pipes.RejectError{Pipe: e.cfg.Name, Reason: res.Reject}
+	}
+	if len(res.Body) == 0 || string(res.Body) == "null" {
+		return ctx.OriginalRequest, nil
+	}
+	return res.Body, nil
+}
+
+// Close stops the plugin.
+func (e *ExecPipe) Close() error {
+	e.mu.Lock()
+	proc := e.proc
+	e.proc = nil
+	e.closed = true
+	e.mu.Unlock()
+	if proc != nil {
+		proc.stop()
+	}
+	return nil
+}
+
+// call sends one request and waits for its response.
+func (e *ExecPipe) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	proc, err := e.running()
+	if err != nil {
+		return nil, err
+	}
+	id := e.nextID.Add(1)
+	line, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	ch := proc.register(id)
+	defer proc.unregister(id)
+	if err := proc.send(line); err != nil {
+		return nil, fmt.Errorf("plugin write: %w", err)
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("plugin error %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	case <-proc.done:
+		return nil, fmt.Errorf("plugin exited: %w", proc.err)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("plugin call: %w", ctx.Err())
+	}
+}
+
+// running returns the live plugin process, starting one if needed.
+func (e *ExecPipe) running() (*process, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil, errors.New("pipe closed")
+	}
+	if e.proc != nil {
+		select {
+		case <-e.proc.done:
+			e.proc = nil
+			e.restartAfter = time.Now().Add(restartDelay)
+		default:
+			return e.proc, nil
+		}
+	}
+	if time.Now().Before(e.restartAfter) {
+		return nil, errors.New("plugin exited, waiting to restart")
+	}
+	proc, err := startProcess(e.cfg)
+	if err != nil {
+		e.restartAfter = time.Now().Add(restartDelay)
+		return nil, err
+	}
+	e.proc = proc
+	return proc, nil
+}
+
+// process is one running plugin.
+type process struct {
+	cmd  *exec.Cmd
+	name string
+
+	writeMu sync.Mutex
+	stdin   io.WriteCloser
+
+	mu      sync.Mutex
+	pending map[int64]chan rpcResponse
+
+	done chan struct{} // Closed when stdout ends
+	err  error         // Why it ended; read after done is closed
+}
+
+func startProcess(cfg pipes.CustomPipeConfig) (*process, error) {
+	options, err := json.Marshal(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("options: %w", err)
+	}
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...) // #nosec G204 -- command comes from the operator's config
+	cmd.Env = append(os.Environ(), EnvPipeName+"="+cfg.Name, EnvPipeOptions+"="+string(options))
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin: %w", err)
+	}
+	p := &process{
+		cmd:     cmd,
+		name:    cfg.Name,
+		stdin:   stdin,
+		pending: make(map[int64]chan rpcResponse),
+		done:    make(chan struct{}),
+	}
+	log.Info().Str("pipe", cfg.Name).Int("pid", cmd.Process.Pid).Msg("custom pipe: plugin started")
+	go p.logStderr(stderr)
+	go p.readLoop(stdout)
+	return p, nil
+}
+
+func (p *process) register(id int64) chan rpcResponse {
+	ch := make(chan rpcResponse, 1)
+	p.mu.Lock()
+	p.pending[id] = ch
+	p.mu.Unlock()
+	return ch
+}
+
+func (p *process) unregister(id int64) {
+	p.mu.Lock()
+	delete(p.pending, id)
+	p.mu.Unlock()
+}
+
+func (p *process) send(line []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err := p.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// readLoop dispatches responses until stdout closes, then reaps the process.
+func (p *process) readLoop(stdout io.Reader) {
+	r := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var resp rpcResponse
+			if jerr := json.Unmarshal(line, &resp); jerr != nil {
+				log.Warn().Str("pipe", p.name).Err(jerr).Msg("custom pipe: ignoring invalid plugin output")
+			} else {
+				p.mu.Lock()
+				ch := p.pending[resp.ID]
+				p.mu.Unlock()
+				if ch != nil {
+					ch <- resp
+				}
+			}
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	if waitErr := p.cmd.Wait(); waitErr != nil {
+		p.err = waitErr
+		log.Warn().Str("pipe", p.name).Err(waitErr).Msg("custom pipe: plugin exited")
+	} else {
+		p.err = fmt.Errorf("stdout closed: %w", readErr)
+		log.Info().Str("pipe", p.name).Msg("custom pipe: plugin exited")
+	}
+	close(p.done)
+}
+
+func (p *process) logStderr(stderr io.Reader) {
+	sc := bufio.NewScanner(stderr)
+	for sc.Scan() {
+		log.Info().Str("pipe", p.name).Msg(sc.Text())
+	}
+}
+
+// stop closes stdin so the plugin can exit cleanly, then kills it if it has
+// not exited within restartDelay.
+func (p *process) stop() {
+	_ = p.stdin.Close()
+	select {
+	case <-p.done:
+	case <-time.After(restartDelay):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+}
@@ -0,0 +1,183 @@
+package injection
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// match is one suspected injection: text[start:end], found by detector.
+type match struct {
+	start, end int
+	detector   string
+	excerpt    string // Shown in findings; the matched text unless decoded
+}
+
+// detector finds suspected injections in text.
+type detector struct {
+	name string
+	find func(text string) []match
+}
+
+// phraseRe matches directives aimed at the model rather than at the reader.
+var phraseRe = regexp.MustCompile(`(?i)` + strings.Join([]string{
+	`\bignore\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding)\s+(?:instructions?|prompts?|directions?|rules|context)`,
+	`\bdisregard\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous\s+|prior\s+|above\s+|earlier\s+)?(?:instructions?|prompts?|directions?|rules|guidelines)`,
+	`\bforget\s+(?:all\s+|everything\s+)?(?:you\s+(?:were|have\s+been)\s+told|(?:your|the)\s+(?:previous\s+|prior\s+)?instructions)`,
+	`\b(?:reveal|print|output|repeat|show)\s+(?:me\s+)?(?:your|the)\s+(?:system\s+prompt|initial\s+instructions|hidden\s+instructions)`,
+	`\byou\s+are\s+now\s+(?:in\s+)?(?:developer\s+mode|DAN\b|jailbroken|an?\s+unrestricted)`,
+	`\b(?:new|updated)\s+(?:system\s+)?instructions\s*:`,
+	`\bdo\s+not\s+(?:tell|inform|alert)\s+the\s+user\b`,
+	`<\|im_start\|>\s*system`,
+	`<<SYS>>`,
+	`\[INST\]`,
+}, "|"))
+
+func findPhrases(text string) []match {
+	var out []match
+	for _, loc := range phraseRe.FindAllStringIndex(text, -1) {
+		out = append(out, match{start: loc[0], end: loc[1], detector: pipes.InjectionDetectorPhrase, excerpt: text[loc[0]:loc[1]]})
+	}
+	return out
+}
+
+// isHidden reports whether r is invisible and can smuggle or reorder text:
+// zero-width spaces and joiners used to split keywords, bidi overrides and
+// isolates (trojan source), and Unicode tag characters (ASCII smuggling).
+// ZWNJ/ZWJ and LRM/RLM are left alone; they are common in emoji and
+// right-to-left text.
+func isHidden(r rune) bool {
+	switch {
+	case r == 0x200B, r == 0xFEFF:
+		return true
+	case r >= 0x2060 && r <= 0x2064:
+		return true
+	case r >= 0x202A && r <= 0x202E, r >= 0x2066 && r <= 0x2069:
+		return true
+	case r >= 0xE0000 && r <= 0xE007F:
+		return true
+	}
+	return false
+}
+
+// findHidden returns each run of hidden characters. A byte order mark at the
+// very start of the text is ignored. Tag characters are decoded to the ASCII
+// they smuggle in the excerpt.
+func findHidden(text string) []match {
+	var out []match
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		out = append(out, match{start: start, end: end, detector: pipes.InjectionDetectorHiddenUnicode, excerpt: describeHidden(text[start:end])})
+		start = -1
+	}
+	for i, r := range text {
+		if !isHidden(r) || (i == 0 && r == 0xFEFF) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	flush(len(text))
+	return out
+}
+
+func describeHidden(run string) string {
+	var tags strings.Builder
+	for _, r := range run {
+		if r >= 0xE0020 && r <= 0xE007E {
+			tags.WriteRune(r - 0xE0000)
+		}
+	}
+	if tags.Len() > 0 {
+		return tags.String()
+	}
+	return run
+}
+
+// base64Re matches base64 runs long enough to carry a directive.
+var base64Re = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+
+// maxBase64Candidate skips blobs (images, archives) too large to be a directive.
+const maxBase64Candidate = 16 * 1024
+
+// findBase64 decodes base64 runs and reports those whose text holds a directive.
+func findBase64(text string) []match {
+	var out []match
+	for _, loc := range base64Re.FindAllStringIndex(text, -1) {
+		if loc[1]-loc[0] > maxBase64Candidate {
+			continue
+		}
+		decoded, ok := decodeBase64Text(text[loc[0]:loc[1]])
+		if !ok {
+			continue
+		}
+		if phrase := phraseRe.FindString(decoded); phrase != "" {
+			out = append(out, match{start: loc[0], end: loc[1], detector: pipes.InjectionDetectorBase64, excerpt: phrase})
+		}
+	}
+	return out
+}
+
+// decodeBase64Text decodes s in any common base64 alphabet and returns it if
+// it is printable UTF-8 text.
+func decodeBase64Text(s string) (string, bool) {
+	trimmed := strings.TrimRight(s, "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		b, err := enc.DecodeString(trimmed)
+		if err != nil || !utf8.Valid(b) {
+			continue
+		}
+		text := string(b)
+		printable := 0
+		for _, r := range text {
+			if unicode.IsPrint(r) || unicode.IsSpace(r) {
+				printable++
+			}
+		}
+		if printable*10 >= utf8.RuneCountInString(text)*9 {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// builtins maps detector names to their implementations.
+var builtins = map[string]detector{
+	pipes.InjectionDetectorPhrase:        {name: pipes.InjectionDetectorPhrase, find: findPhrases},
+	pipes.InjectionDetectorHiddenUnicode: {name: pipes.InjectionDetectorHiddenUnicode, find: findHidden},
+	pipes.InjectionDetectorBase64:        {name: pipes.InjectionDetectorBase64, find: findBase64},
+}
+
+// patternDetector matches the configured extra patterns, case-insensitively.
+// Patterns that do not compile are skipped; config validation reports them.
+func patternDetector(patterns []string) (detector, bool) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		if re, err := regexp.Compile("(?i)" + p); err == nil {
+			res = append(res, re)
+		}
+	}
+	if len(res) == 0 {
+		return detector{}, false
+	}
+	return detector{name: pipes.InjectionDetectorPattern, find: func(text string) []match {
+		var out []match
+		for _, re := range res {
+			for _, loc := range re.FindAllStringIndex(text, -1) {
+				if loc[0] < loc[1] {
+					out = append(out, match{start: loc[0], end: loc[1], detector: pipes.InjectionDetectorPattern, excerpt: text[loc[0]:loc[1]]})
+				}
+			}
+		}
+		return out
+	}}, true
+}
@@ -0,0 +1,180 @@
+// Package injection scans tool outputs for prompt-injection attempts.
+//
+// DESIGN: Tool results carry text the user never wrote - fetched web pages,
+// file contents, command output - and are the usual carrier for indirect
+// prompt injection. The pipe extracts every tool result through the adapter,
+// runs the configured detectors, and depending on pipes.injection.action
+// records the findings (flag), removes the matched text (strip), or refuses
+// the request (block). It runs right after PII masking, before any
+// compression pipe, so compressed and shadow-stored originals are already
+// clean.
+package injection
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// StripMarker replaces text removed by the strip action. Hidden characters
+// are removed without a marker.
+const StripMarker = "[removed by gateway: suspected prompt injection]"
+
+// maxExcerpt caps the length of InjectionFinding.Excerpt.
+const maxExcerpt = 80
+
+// Pipe scans tool outputs for prompt injection.
+type Pipe struct {
+	enabled   bool
+	action    string
+	detectors []detector
+}
+
+// New creates a prompt-injection pipe from config.
+func New(cfg *config.Config) *Pipe {
+	ic := cfg.Pipes.Injection
+	names := ic.Detectors
+	if len(names) == 0 {
+		names = pipes.BuiltinInjectionDetectors
+	}
+	detectors := make([]detector, 0, len(names)+1)
+	for _, name := range names {
+		if d, ok := builtins[name]; ok {
+			detectors = append(detectors, d)
+		}
+	}
+	if d, ok := patternDetector(ic.Patterns); ok {
+		detectors = append(detectors, d)
+	}
+	return &Pipe{enabled: ic.Enabled, action: ic.EffectiveAction(), detectors: detectors}
+}
+
+// Name returns the pipe name.
+func (p *Pipe) Name() string { return pipes.PipeNameInjection }
+
+// Strategy returns the configured action.
+func (p *Pipe) Strategy() string { return p.action }
+
+// Enabled returns whether the pipe is active.
+func (p *Pipe) Enabled() bool { return p.enabled }
+
+// Process scans every tool output and appends what it finds to
+// ctx.InjectionFindings. With action block it returns a *pipes.RejectError
+// when anything was found; with strip it returns the body with the matches
+// removed; otherwise the body is returned unchanged.
+func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	body := ctx.OriginalRequest
+	if !p.enabled || ctx.Adapter == nil {
+		return body, nil
+	}
+	outputs, err := ctx.Adapter.ExtractToolOutput(body)
+	if err != nil {
+		return nil, fmt.Errorf("injection: extract tool outputs: %w", err)
+	}
+
+	var stripped []adapters.CompressedResult
+	found := len(ctx.InjectionFindings)
+	for _, out := range outputs {
+		matches := p.scan(out.Content)
+		if len(matches) == 0 {
+			continue
+		}
+		for _, m := range matches {
+			ctx.InjectionFindings = append(ctx.InjectionFindings, pipes.InjectionFinding{
+				ToolName:   out.ToolName,
+				ToolCallID: out.ID,
+				Detector:   m.detector,
+				Excerpt:    excerpt(m.excerpt),
+			})
+		}
+		if p.action == pipes.InjectionActionStrip {
+			stripped = append(stripped, adapters.CompressedResult{
+				ID:           out.ID,
+				Compressed:   strip(out.Content, matches),
+				MessageIndex: out.MessageIndex,
+				BlockIndex:   out.BlockIndex,
+			})
+		}
+	}
+	if len(ctx.InjectionFindings) == found {
+		return body, nil
+	}
+
+	switch p.action {
+	case pipes.InjectionActionBlock:
+		first := ctx.InjectionFindings[found]
+		tool := first.ToolName
+		if tool == "" {
+			tool = "tool"
+		}
+		return nil, &pipes.RejectError{
+			Pipe:   pipes.PipeNameInjection,
+			Reason: fmt.Sprintf("suspected prompt injection in %s output (%s)", tool, first.Detector),
+		}
+	case pipes.InjectionActionStrip:
+		return ctx.Adapter.ApplyToolOutput(body, stripped)
+	}
+	return body, nil
+}
+
+// scan returns the matches of every detector in text, ordered by position,
+// with overlapping matches merged into the earliest.
+func (p *Pipe) scan(text string) []match {
+	var all []match
+	for _, d := range p.detectors {
+		all = append(all, d.find(text)...)
+	}
+	if len(all) < 2 {
+		return all
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].start != all[j].start {
+			return all[i].start < all[j].start
+		}
+		return all[i].end > all[j].end
+	})
+	merged := all[:1]
+	for _, m := range all[1:] {
+		last := &merged[len(merged)-1]
+		if m.start < last.end {
+			if m.end > last.end {
+				last.end = m.end
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// strip removes matches from text. Visible matches are replaced with
+// StripMarker; hidden characters are dropped.
+func strip(text string, matches []match) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(text[prev:m.start])
+		if m.detector != pipes.InjectionDetectorHiddenUnicode {
+			b.WriteString(StripMarker)
+		}
+		prev = m.end
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
+
+// excerpt quotes s as ASCII, so hidden characters are visible, and truncates it.
+func excerpt(s string) string {
+	q := strconv.QuoteToASCII(s)
+	q = q[1 : len(q)-1]
+	if len(q) > maxExcerpt {
+		q = q[:maxExcerpt] + "..."
+	}
+	return q
+}
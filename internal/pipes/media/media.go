@@ -0,0 +1,222 @@
+// Package media drops or downscales older image and document blocks.
+//
+// DESIGN: Base64 screenshots and PDFs are resent on every turn and often
+// dominate a request's tokens long after the model has looked at them. The
+// pipe extracts every media block through the adapter, keeps the most recent
+// ones per pipes.media rule, and for the older ones either re-encodes images
+// at a smaller size (downscale) or replaces the block with a text placeholder
+// (drop). A dropped block is stored under a content-hash ref that
+// expand_context restores in place. Output depends only on the blocks and the
+// config, so a block is rewritten the same way on every turn (KV-cache safe).
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/phantom_tools"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+const (
+	// RefPrefix is the prefix of expand_context refs for dropped blocks.
+	RefPrefix = "media_"
+
+	// PlaceholderFormat replaces a dropped block (kind, media type, size, Marker).
+	PlaceholderFormat = "[%s omitted: %s, %s — call %s to restore it]"
+
+	// PlaceholderNoRefFormat replaces a dropped block when expand_context is
+	// unavailable for the request (kind, media type, size).
+	PlaceholderNoRefFormat = "[%s omitted: %s, %s]"
+
+	// thumbnailCachePrefix keys cached downscaled images in the compressed store.
+	thumbnailCachePrefix = "thumb_"
+)
+
+// Pipe drops or downscales older media blocks.
+type Pipe struct {
+	enabled bool
+	rules   []pipes.MediaRule
+	store   store.Store
+}
+
+// New creates a media pipe from config.
+func New(cfg *config.Config, st store.Store) *Pipe {
+	mc := cfg.Pipes.Media
+	return &Pipe{enabled: mc.Enabled, rules: mc.EffectiveRules(), store: st}
+}
+
+// Name returns the pipe name.
+func (p *Pipe) Name() string { return pipes.PipeNameMedia }
+
+// Strategy returns "rules"; each rule sets its own action.
+func (p *Pipe) Strategy() string { return "rules" }
+
+// Enabled returns whether the pipe is active.
+func (p *Pipe) Enabled() bool { return p.enabled }
+
+// Process rewrites media blocks older than their rule's keep_recent and
+// appends each change to ctx.MediaRewrites.
+func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	body := ctx.OriginalRequest
+	if !p.enabled || ctx.Adapter == nil {
+		return body, nil
+	}
+	mediaAdapter, ok := ctx.Adapter.(adapters.MediaBlockAdapter)
+	if !ok {
+		return body, nil
+	}
+	blocks := mediaAdapter.ExtractMediaBlocks(body)
+	if len(blocks) == 0 {
+		return body, nil
+	}
+
+	var (
+		downscaled, dropped []adapters.MediaBlock
+		placeholders        []string
+		rewrites            []pipes.MediaRewrite
+		savedBytes          int
+		seen                = make([]int, len(p.rules))
+		expandContext       = p.store != nil && !ctx.Detached && phantom_tools.Injectable(phantom_tools.ExpandContextToolName, body, ctx.Provider)
+	)
+	// Walk newest first so keep_recent counts from the end of the conversation.
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+		ruleIdx := p.ruleFor(block.MediaType)
+		if ruleIdx < 0 {
+			continue
+		}
+		rule := &p.rules[ruleIdx]
+		seen[ruleIdx]++
+		if seen[ruleIdx] <= rule.KeepRecent {
+			continue
+		}
+
+		rewrite := pipes.MediaRewrite{
+			MediaType:     block.MediaType,
+			Action:        rule.EffectiveAction(),
+			ToolCallID:    block.ToolCallID,
+			OriginalBytes: len(block.Data),
+		}
+		if rewrite.Action == pipes.MediaActionDownscale {
+			thumb, ok := p.cachedThumbnail(block, rule.EffectiveMaxDimension())
+			if !ok {
+				continue
+			}
+			block.Data = thumb
+			rewrite.Bytes = len(thumb)
+			downscaled = append(downscaled, block)
+		} else {
+			kind := kindOf(block.MediaType)
+			size := formats.FormatSize(len(block.Data))
+			if expandContext {
+				rewrite.ShadowID = refFor(block.Raw)
+				_ = p.store.Set(rewrite.ShadowID, block.Raw)
+				if ctx.ShadowRefs == nil {
+					ctx.ShadowRefs = make(map[string]string)
+				}
+				ctx.ShadowRefs[rewrite.ShadowID] = block.Raw
+				placeholders = append(placeholders, fmt.Sprintf(PlaceholderFormat, kind, block.MediaType, size, Marker(rewrite.ShadowID)))
+			} else {
+				placeholders = append(placeholders, fmt.Sprintf(PlaceholderNoRefFormat, kind, block.MediaType, size))
+			}
+			dropped = append(dropped, block)
+		}
+		savedBytes += rewrite.OriginalBytes - rewrite.Bytes
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(rewrites) == 0 {
+		return body, nil
+	}
+
+	modified := body
+	var err error
+	if len(downscaled) > 0 {
+		if modified, err = mediaAdapter.ApplyMediaBlocks(modified, downscaled); err != nil {
+			return nil, fmt.Errorf("media: apply downscaled blocks: %w", err)
+		}
+	}
+	if len(dropped) > 0 {
+		if modified, err = mediaAdapter.ReplaceMediaBlocks(modified, dropped, placeholders); err != nil {
+			return nil, fmt.Errorf("media: replace dropped blocks: %w", err)
+		}
+	}
+	// Report in conversation order.
+	for i, j := 0, len(rewrites)-1; i < j; i, j = i+1, j-1 {
+		rewrites[i], rewrites[j] = rewrites[j], rewrites[i]
+	}
+	ctx.MediaRewrites = append(ctx.MediaRewrites, rewrites...)
+
+	log.Info().
+		Str("request_id", ctx.RequestID).
+		Int("dropped", len(dropped)).
+		Int("downscaled", len(downscaled)).
+		Int("saved_bytes", savedBytes).
+		Msg("media: rewrote older media blocks")
+	return modified, nil
+}
+
+// ruleFor returns the index of the first rule matching mediaType, or -1.
+func (p *Pipe) ruleFor(mediaType string) int {
+	for i := range p.rules {
+		if p.rules[i].Matches(mediaType) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cachedThumbnail returns the downscaled image, or false when the block is
+// kept as is (not a PNG or JPEG, already small, or no size gain).
+func (p *Pipe) cachedThumbnail(block adapters.MediaBlock, maxDim int) ([]byte, bool) {
+	hash := sha256.Sum256(block.Data)
+	key := fmt.Sprintf("%s%s_%d", thumbnailCachePrefix, hex.EncodeToString(hash[:16]), maxDim)
+	if p.store != nil {
+		if cached, ok := p.store.GetCompressed(key); ok {
+			return []byte(cached), cached != ""
+		}
+	}
+
+	thumb, err := formats.Thumbnail(block.Data, maxDim)
+	if err != nil {
+		log.Debug().Err(err).Str("media_type", block.MediaType).Msg("media: block not downscaled")
+	}
+	if p.store != nil {
+		// An empty entry records "keep original" so the block is not decoded again.
+		_ = p.store.SetCompressed(key, string(thumb))
+	}
+	return thumb, thumb != nil
+}
+
+// refFor derives a dropped block's expand_context ref from its JSON.
+func refFor(raw string) string {
+	hash := sha256.Sum256([]byte(raw))
+	return RefPrefix + hex.EncodeToString(hash[:16])
+}
+
+// kindOf names a media type in placeholders.
+func kindOf(mediaType string) string {
+	if strings.HasPrefix(mediaType, "image/") {
+		return "image"
+	}
+	return "document"
+}
+
+// Marker is the text in a placeholder that names ref; expand_context looks
+// for it to find where the block goes back.
+func Marker(ref string) string {
+	return fmt.Sprintf("expand_context(id=%q)", ref)
+}
+
+// IsRef reports whether id is a media ref created by the pipe.
+func IsRef(id string) bool {
+	return strings.HasPrefix(id, RefPrefix)
+}
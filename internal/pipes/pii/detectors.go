@@ -0,0 +1,248 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// Match is one detected entity: text[Start:End] is of kind Kind.
+type Match struct {
+	Start int
+	End   int
+	Kind  string // Upper-case entity kind used in tokens (EMAIL, CREDIT_CARD, ...)
+}
+
+// Detector finds PII entities in text.
+type Detector interface {
+	Name() string
+	Detect(ctx context.Context, text string) ([]Match, error)
+}
+
+// custom holds detectors added with Register; they run in every PII pipe.
+var (
+	customMu sync.RWMutex
+	custom   []Detector
+)
+
+// Register adds a detector that runs alongside the configured ones.
+// Call before the gateway starts.
+func Register(d Detector) {
+	customMu.Lock()
+	defer customMu.Unlock()
+	custom = append(custom, d)
+}
+
+func registered() []Detector {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	return append([]Detector(nil), custom...)
+}
+
+// regexDetector matches a pattern and optionally validates each candidate.
+type regexDetector struct {
+	name     string
+	kind     string
+	re       *regexp.Regexp
+	validate func(string) bool
+}
+
+func (d *regexDetector) Name() string { return d.name }
+
+func (d *regexDetector) Detect(_ context.Context, text string) ([]Match, error) {
+	var out []Match
+	for _, loc := range d.re.FindAllStringIndex(text, -1) {
+		if d.validate != nil && !d.validate(text[loc[0]:loc[1]]) {
+			continue
+		}
+		out = append(out, Match{Start: loc[0], End: loc[1], Kind: d.kind})
+	}
+	return out, nil
+}
+
+// builtins maps detector names to their implementations.
+var builtins = map[string]Detector{
+	pipes.PIIDetectorEmail: &regexDetector{
+		name: pipes.PIIDetectorEmail, kind: "EMAIL",
+		re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	pipes.PIIDetectorPhone: &regexDetector{
+		name: pipes.PIIDetectorPhone, kind: "PHONE",
+		re:       regexp.MustCompile(`(?:\+\d{1,3}[\s.\-]?)?\(?\d{3}\)?[\s.\-]\d{3}[\s.\-]\d{4}\b`),
+		validate: func(s string) bool { return digitCount(s) >= 10 },
+	},
+	pipes.PIIDetectorSSN: &regexDetector{
+		name: pipes.PIIDetectorSSN, kind: "SSN",
+		re:       regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		validate: validSSN,
+	},
+	pipes.PIIDetectorCreditCard: &regexDetector{
+		name: pipes.PIIDetectorCreditCard, kind: "CREDIT_CARD",
+		re:       regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: validLuhn,
+	},
+	pipes.PIIDetectorIBAN: &regexDetector{
+		name: pipes.PIIDetectorIBAN, kind: "IBAN",
+		re:       regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`),
+		validate: validIBAN,
+	},
+	pipes.PIIDetectorIPv4: &regexDetector{
+		name: pipes.PIIDetectorIPv4, kind: "IPV4",
+		re:       regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+		validate: func(s string) bool { return !strings.HasPrefix(s, "127.") && s != "0.0.0.0" },
+	},
+}
+
+func digitCount(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validSSN rejects area/group/serial numbers the SSA never issues.
+func validSSN(s string) bool {
+	d := digitsOnly(s)
+	return d[:3] != "000" && d[:3] != "666" && d[0] != '9' && d[3:5] != "00" && d[5:] != "0000"
+}
+
+// validLuhn reports whether the digits in s pass the Luhn checksum.
+func validLuhn(s string) bool {
+	d := digitsOnly(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum, double := 0, false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether s passes the ISO 13616 mod-97 check.
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	var b strings.Builder
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&b, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(b.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// apiDetector calls an external detection service.
+type apiDetector struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func newAPIDetector(cfg pipes.PIIAPIConfig) *apiDetector {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &apiDetector{url: cfg.URL, apiKey: cfg.APIKey, client: &http.Client{Timeout: timeout}}
+}
+
+func (d *apiDetector) Name() string { return "api" }
+
+func (d *apiDetector) Detect(ctx context.Context, text string) ([]Match, error) {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	}
+	resp, err := d.client.Do(req) // #nosec G704 -- URL from operator config
+	if err != nil {
+		return nil, fmt.Errorf("pii api: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pii api: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Entities []struct {
+			Start int    `json:"start"`
+			End   int    `json:"end"`
+			Type  string `json:"type"`
+		} `json:"entities"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("pii api: decode: %w", err)
+	}
+	out := make([]Match, 0, len(result.Entities))
+	for _, e := range result.Entities {
+		if e.Start < 0 || e.End > len(text) || e.Start >= e.End {
+			continue
+		}
+		out = append(out, Match{Start: e.Start, End: e.End, Kind: tokenKind(e.Type)})
+	}
+	return out, nil
+}
+
+// tokenKind normalizes an entity type for use inside a token.
+func tokenKind(t string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(t) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "ENTITY"
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// Tokens look like [[PII_EMAIL_3f9a2c1b0d]]. The suffix is an HMAC of the
+// value under a per-process key: the same value always maps to the same token
+// (so re-masked history stays byte-identical and KV-cache prefixes survive),
+// but the provider cannot brute-force small value spaces like SSNs.
+const (
+	tokenPrefix  = "[[PII_"
+	tokenSuffix  = "]]"
+	maxKindLen   = 24
+	hashHexLen   = 10
+	maxTokenLen  = len(tokenPrefix) + maxKindLen + 1 + hashHexLen + len(tokenSuffix)
+	storeKeyPref = "pii:"
+)
+
+var tokenRe = regexp.MustCompile(`\[\[PII_[A-Z0-9_]+_[0-9a-f]{10}\]\]`)
+
+var tokenKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("pii: cannot generate token key: " + err.Error())
+	}
+	return key
+}()
+
+// Token returns the mask token for value of the given kind.
+func Token(kind, value string) string {
+	if len(kind) > maxKindLen {
+		kind = kind[:maxKindLen]
+	}
+	mac := hmac.New(sha256.New, tokenKey)
+	mac.Write([]byte(value))
+	return tokenPrefix + kind + "_" + hex.EncodeToString(mac.Sum(nil))[:hashHexLen] + tokenSuffix
+}
+
+// StoreKey returns the shadow store key holding token's original value.
+func StoreKey(token string) string {
+	return storeKeyPref + token
+}
+
+// applyMatches replaces non-overlapping matches in text with tokens and
+// records each token → value in st. Overlaps keep the earliest, then longest, match.
+func applyMatches(text string, matches []Match, st store.Store) (string, int) {
+	if len(matches) == 0 {
+		return text, 0
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		if m.Start < last {
+			continue
+		}
+		value := text[m.Start:m.End]
+		token := Token(m.Kind, value)
+		if st != nil {
+			_ = st.Set(StoreKey(token), value)
+		}
+		b.WriteString(text[last:m.Start])
+		b.WriteString(token)
+		last = m.End
+		n++
+	}
+	b.WriteString(text[last:])
+	return b.String(), n
+}
+
+// Unmasker restores tokens to their original values from the shadow store.
+// Tokens with no stored value (expired or foreign) are left as-is.
+type Unmasker struct {
+	store store.Store
+}
+
+// NewUnmasker creates an unmasker backed by st.
+func NewUnmasker(st store.Store) *Unmasker {
+	return &Unmasker{store: st}
+}
+
+// Unmask replaces every known token in text. With jsonEscape, values are
+// escaped for insertion inside a JSON string literal.
+func (u *Unmasker) Unmask(text string, jsonEscape bool) string {
+	if !strings.Contains(text, tokenPrefix) {
+		return text
+	}
+	return tokenRe.ReplaceAllStringFunc(text, func(token string) string {
+		value, ok := u.store.Get(StoreKey(token))
+		if !ok {
+			return token
+		}
+		if jsonEscape {
+			return escapeJSONString(value)
+		}
+		return value
+	})
+}
+
+// UnmaskJSON restores tokens inside a JSON document's string values.
+func (u *Unmasker) UnmaskJSON(body []byte) []byte {
+	if !strings.Contains(string(body), tokenPrefix) {
+		return body
+	}
+	return []byte(u.Unmask(string(body), true))
+}
+
+func escapeJSONString(s string) string {
+	quoted, err := json.Marshal(s)
+	if err != nil {
+		return s
+	}
+	return string(quoted[1 : len(quoted)-1])
+}
+
+// StreamUnmasker unmasks text that arrives in fragments (streaming deltas).
+// A trailing fragment that could be the start of a token is held back until
+// the next fragment for the same key completes or rules it out.
+type StreamUnmasker struct {
+	u       *Unmasker
+	pending map[string]string
+}
+
+// NewStreamUnmasker creates a stream unmasker backed by st.
+func NewStreamUnmasker(st store.Store) *StreamUnmasker {
+	return &StreamUnmasker{u: NewUnmasker(st), pending: make(map[string]string)}
+}
+
+// Push appends fragment to key's stream and returns the text safe to emit.
+func (s *StreamUnmasker) Push(key, fragment string, jsonEscape bool) string {
+	text := s.pending[key] + fragment
+	delete(s.pending, key)
+	if cut := holdbackIndex(text); cut < len(text) {
+		s.pending[key] = text[cut:]
+		text = text[:cut]
+	}
+	return s.u.Unmask(text, jsonEscape)
+}
+
+// HasPending reports whether any text is held back.
+func (s *StreamUnmasker) HasPending() bool {
+	return len(s.pending) > 0
+}
+
+// Flush returns and clears any text held back for key.
+func (s *StreamUnmasker) Flush(key string) string {
+	text := s.pending[key]
+	delete(s.pending, key)
+	return text
+}
+
+// holdbackIndex returns where a trailing possible-token prefix starts in text,
+// or len(text) when nothing needs holding back.
+func holdbackIndex(text string) int {
+	start := strings.LastIndex(text, "[[")
+	if start < 0 {
+		if strings.HasSuffix(text, "[") {
+			return len(text) - 1
+		}
+		return len(text)
+	}
+	tail := text[start:]
+	if len(tail) >= maxTokenLen || strings.Contains(tail, tokenSuffix) || !isTokenPrefix(tail) {
+		if strings.HasSuffix(text, "[") && !strings.HasSuffix(text, "[[") {
+			return len(text) - 1
+		}
+		return len(text)
+	}
+	return start
+}
+
+// isTokenPrefix reports whether s could still grow into a complete token.
+func isTokenPrefix(s string) bool {
+	n := min(len(s), len(tokenPrefix))
+	if s[:n] != tokenPrefix[:n] {
+		return false
+	}
+	rest := strings.TrimSuffix(s[n:], "]")
+	for _, r := range rest {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
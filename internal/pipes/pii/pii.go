@@ -0,0 +1,191 @@
+// Package pii masks personally identifiable information in outbound requests.
+//
+// DESIGN: The pipe walks every string in the conversation fields of the
+// request (messages, system, input, contents, ...), runs the configured
+// detectors, and replaces each entity with a stable token. The token → value
+// map lives in the shadow store, so the gateway can restore tokens the model
+// echoes back (see Unmasker / StreamUnmasker). It runs before every other
+// pipe so external compression services never see raw entities.
+//
+// Only /v1/messages-style LLM requests pass through pipes, plus count_tokens
+// in its default compressed mode; other passthrough endpoints are forwarded
+// unmodified.
+package pii
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// conversationFields are the top-level request fields that carry user content
+// across provider formats.
+var conversationFields = []string{"system", "messages", "input", "instructions", "contents", "systemInstruction", "prompt"}
+
+// structuralKeys hold identifiers and metadata, never user content.
+var structuralKeys = map[string]bool{
+	"type": true, "role": true, "id": true, "tool_use_id": true, "tool_call_id": true,
+	"call_id": true, "model": true, "name": true, "signature": true, "data": true,
+	"media_type": true, "mime_type": true, "mimeType": true, "url": true, "image_url": true,
+	"cache_control": true, "status": true,
+}
+
+// minTextLen skips strings too short to hold any supported entity.
+const minTextLen = 6
+
+// Pipe masks PII in requests.
+type Pipe struct {
+	enabled   bool
+	detectors []Detector
+	store     store.Store
+}
+
+// New creates a PII pipe from config.
+func New(cfg *config.Config, st store.Store) *Pipe {
+	pc := cfg.Pipes.PII
+	names := pc.Detectors
+	if len(names) == 0 {
+		names = pipes.BuiltinPIIDetectors
+	}
+	detectors := make([]Detector, 0, len(names)+1)
+	for _, name := range names {
+		if d, ok := builtins[name]; ok {
+			detectors = append(detectors, d)
+		}
+	}
+	if pc.API.URL != "" {
+		detectors = append(detectors, newAPIDetector(pc.API))
+	}
+	detectors = append(detectors, registered()...)
+
+	return &Pipe{enabled: pc.Enabled, detectors: detectors, store: st}
+}
+
+// Name returns the pipe name.
+func (p *Pipe) Name() string { return pipes.PipeNamePII }
+
+// Strategy returns the processing strategy.
+func (p *Pipe) Strategy() string { return "mask" }
+
+// Enabled returns whether the pipe is active.
+func (p *Pipe) Enabled() bool { return p.enabled }
+
+// Process masks every detected entity in the request's conversation fields.
+// Returns an error if any detector fails, so the gateway can refuse to
+// forward a request that may still contain raw entities.
+func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
+	if !p.enabled {
+		return ctx.OriginalRequest, nil
+	}
+	reqCtx := ctx.RequestCtx
+	if reqCtx == nil {
+		reqCtx = context.Background()
+	}
+
+	body := ctx.OriginalRequest
+	var edits []stringEdit
+	for _, field := range conversationFields {
+		v := gjson.GetBytes(body, field)
+		if !v.Exists() {
+			continue
+		}
+		if err := p.walk(reqCtx, v, escapePathKey(field), &edits); err != nil {
+			return nil, err
+		}
+	}
+
+	total := 0
+	for _, e := range edits {
+		updated, err := sjson.SetBytes(body, e.path, e.value)
+		if err != nil {
+			log.Warn().Err(err).Str("path", e.path).Msg("pii: failed to apply mask")
+			return nil, err
+		}
+		body = updated
+		total += e.count
+	}
+	ctx.PIIMasked += total
+	return body, nil
+}
+
+// stringEdit replaces the string at path.
+type stringEdit struct {
+	path  string
+	value string
+	count int
+}
+
+// walk collects masked replacements for every string leaf under v.
+func (p *Pipe) walk(ctx context.Context, v gjson.Result, path string, edits *[]stringEdit) error {
+	switch {
+	case v.IsArray():
+		i := 0
+		var err error
+		v.ForEach(func(_, item gjson.Result) bool {
+			err = p.walk(ctx, item, path+"."+strconv.Itoa(i), edits)
+			i++
+			return err == nil
+		})
+		return err
+	case v.IsObject():
+		var err error
+		v.ForEach(func(key, item gjson.Result) bool {
+			if structuralKeys[key.String()] {
+				return true
+			}
+			err = p.walk(ctx, item, path+"."+escapePathKey(key.String()), edits)
+			return err == nil
+		})
+		return err
+	case v.Type == gjson.String:
+		text := v.String()
+		if len(text) < minTextLen {
+			return nil
+		}
+		masked, n, err := p.MaskText(ctx, text)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			*edits = append(*edits, stringEdit{path: path, value: masked, count: n})
+		}
+	}
+	return nil
+}
+
+// MaskText runs all detectors on text and replaces matches with tokens.
+func (p *Pipe) MaskText(ctx context.Context, text string) (string, int, error) {
+	var matches []Match
+	for _, d := range p.detectors {
+		found, err := d.Detect(ctx, text)
+		if err != nil {
+			return "", 0, err
+		}
+		matches = append(matches, found...)
+	}
+	masked, n := applyMatches(text, matches, p.store)
+	return masked, n, nil
+}
+
+// escapePathKey escapes gjson/sjson path metacharacters in an object key.
+func escapePathKey(key string) string {
+	if !strings.ContainsAny(key, `.*?|#@\`) {
+		return key
+	}
+	var b strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`.*?|#@\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
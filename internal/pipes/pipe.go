@@ -3,6 +3,8 @@ package pipes
 
 import (
 	"context"
+	"maps"
+	"slices"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	authtypes "github.com/compresr/context-gateway/internal/auth/types"
@@ -42,6 +44,19 @@ type PipeContext struct {
 	// Set once by gateway classification; used by pipes for compression context.
 	UserQuery string
 
+	// PIIMasked counts entities replaced by the PII pipe
+	PIIMasked int
+
+	// InjectionFindings are the prompt-injection matches in tool outputs
+	InjectionFindings []InjectionFinding
+
+	// MediaRewrites are the image and document blocks the media pipe changed
+	MediaRewrites []MediaRewrite
+
+	// CustomMetrics are set by a custom pipe's Process and reported in its
+	// telemetry. The gateway clears them before each custom pipe runs.
+	CustomMetrics map[string]float64
+
 	// Flags set by pipes
 	OutputCompressed     bool
 	ToolsFiltered        bool
@@ -60,8 +75,9 @@ type PipeContext struct {
 	ToolDiscoveryToolCount  int    // Number of tools in request when skipped
 
 	// Tool discovery counts for telemetry
-	OriginalToolCount int // Tools before filtering
-	KeptToolCount     int // Tools after filtering (kept)
+	OriginalToolCount int                        // Tools before filtering
+	KeptToolCount     int                        // Tools after filtering (kept)
+	ToolServerStats   map[string]ToolServerStats // Per-MCP-server counts, keyed by server name
 
 	// CacheHit indicates if the tool discovery result was served from cache.
 	// Used for lazy_loading telemetry to track cache effectiveness.
@@ -79,6 +95,16 @@ type PipeContext struct {
 	// Set by the gateway handler via detectClientAgent() before pipes run.
 	// Used by the task_output pipe to select the appropriate ClientSchema.
 	ClientAgent string
+
+	// Detached marks a request whose response never passes through the
+	// gateway (Message Batches items). No phantom tool call can be answered,
+	// so pipes hand out no expand_context references and tools are not filtered.
+	Detached bool
+
+	// CountOnly marks a body compressed only to be counted (count_tokens).
+	// Pipes skip external calls whose result the following request cannot
+	// reuse and use their local strategy instead.
+	CountOnly bool
 }
 
 // ToolOutputCompression tracks individual tool output compression.
@@ -90,7 +116,7 @@ type ToolOutputCompression struct {
 	CompressedTokens  int    `json:"compressed_tokens"`
 	CacheHit          bool   `json:"cache_hit"`
 	IsLastTool        bool   `json:"is_last_tool"`
-	MappingStatus     string `json:"mapping_status"` // "hit", "miss", "compressed", "passthrough_small", "passthrough_large"
+	MappingStatus     string `json:"mapping_status"` // "hit", "miss", "compressed", "passthrough_small", "passthrough_large", "deduplicated"
 	MinThreshold      int    `json:"min_threshold"`  // Min token threshold used
 	MaxThreshold      int    `json:"max_threshold"`  // Max token threshold used
 	Model             string `json:"model"`          // Compression model used (e.g., "toc_latte_v1")
@@ -100,6 +126,33 @@ type ToolOutputCompression struct {
 	CompressedContent string `json:"compressed_content"`
 }
 
+// ToolServerStats counts one MCP server's tools in a tool discovery pass.
+// Tools named mcp__<server>__<tool> belong to <server>.
+type ToolServerStats struct {
+	Tools          int `json:"tools"`
+	Kept           int `json:"kept"`            // Sent with full definitions
+	OriginalTokens int `json:"original_tokens"` // Full definitions of all tools
+	KeptTokens     int `json:"kept_tokens"`     // Full definitions of kept tools
+}
+
+// InjectionFinding is one suspected prompt injection in a tool output.
+type InjectionFinding struct {
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id"`
+	Detector   string `json:"detector"` // phrase | hidden_unicode | base64 | pattern
+	Excerpt    string `json:"excerpt"`  // Matched (or decoded) text, ASCII-quoted and truncated
+}
+
+// MediaRewrite is one image or document block dropped or downscaled by the media pipe.
+type MediaRewrite struct {
+	MediaType     string `json:"media_type"`
+	Action        string `json:"action"` // drop | downscale
+	ToolCallID    string `json:"tool_call_id,omitempty"`
+	ShadowID      string `json:"shadow_id,omitempty"` // expand_context ref for a dropped block
+	OriginalBytes int    `json:"original_bytes"`      // Decoded size before
+	Bytes         int    `json:"bytes"`               // Decoded size after (0 when dropped)
+}
+
 // NewPipeContext creates a new pipe context.
 func NewPipeContext(adapter adapters.Adapter, body []byte) *PipeContext {
 	return &PipeContext{
@@ -109,6 +162,23 @@ func NewPipeContext(adapter adapters.Adapter, body []byte) *PipeContext {
 	}
 }
 
+// Snapshot returns a copy of the context that shares no maps or slices with it,
+// so restoring it after a failed pipe also undoes the pipe's in-place writes.
+func (c *PipeContext) Snapshot() PipeContext {
+	s := *c
+	s.ShadowRefs = maps.Clone(c.ShadowRefs)
+	s.ToolOutputCompressions = slices.Clone(c.ToolOutputCompressions)
+	s.TaskOutputCompressions = slices.Clone(c.TaskOutputCompressions)
+	s.InjectionFindings = slices.Clone(c.InjectionFindings)
+	s.MediaRewrites = slices.Clone(c.MediaRewrites)
+	s.CustomMetrics = maps.Clone(c.CustomMetrics)
+	s.ExpandedTools = maps.Clone(c.ExpandedTools)
+	s.DeferredTools = slices.Clone(c.DeferredTools)
+	s.ToolServerStats = maps.Clone(c.ToolServerStats)
+	s.TaskOutputHandledIDs = maps.Clone(c.TaskOutputHandledIDs)
+	return s
+}
+
 // Pipe defines the interface for a processing pipe.
 // All pipes are independent and can run in parallel.
 // Pipes must NOT contain provider-specific logic - they use adapters for that.
@@ -0,0 +1,111 @@
+// Prompt template overrides for LLM-based compression strategies.
+package pipes
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// PromptTemplateConfig overrides the built-in compression prompts used by the
+// external_provider strategy. Both fields are Go text/template strings; an
+// empty field keeps the built-in prompt. Available variables are the fields of
+// PromptVars, e.g.:
+//
+//	user: |
+//	  Tool {{.ToolName}} returned the output below. Always keep stack traces verbatim.
+//	  {{if .Query}}The user asked: {{.Query}}{{end}}
+//	  Remove about {{percent .TargetRatio}} of it.
+//	  {{.Content}}
+type PromptTemplateConfig struct {
+	System string `yaml:"system,omitempty"`
+	User   string `yaml:"user,omitempty"`
+}
+
+// PromptVars are the variables available to prompt templates.
+type PromptVars struct {
+	ToolName    string  // Name of the tool that produced the output
+	Query       string  // Latest user query; empty when unknown or query_agnostic is set
+	TargetRatio float64 // Fraction of tokens to remove (target_compression_ratio, default 0.5)
+	Format      string  // Structured format ("json", "yaml", "xml") when only the tail is sent; empty otherwise
+	Content     string  // Tool output to compress
+}
+
+// promptFuncs are helpers available inside prompt templates.
+var promptFuncs = template.FuncMap{
+	"percent": func(ratio float64) string { return fmt.Sprintf("%.0f%%", ratio*100) },
+}
+
+// PromptTemplates are parsed prompt templates. A nil field keeps the built-in prompt.
+type PromptTemplates struct {
+	System *template.Template
+	User   *template.Template
+}
+
+// IsSet reports whether any template is configured.
+func (c PromptTemplateConfig) IsSet() bool {
+	return c.System != "" || c.User != ""
+}
+
+// Compile parses the templates and test-renders them so that syntax errors,
+// unknown variables, and a user template that drops the tool output are
+// reported at load time rather than on the first compression.
+func (c PromptTemplateConfig) Compile() (*PromptTemplates, error) {
+	var pt PromptTemplates
+	sample := PromptVars{ToolName: "read_file", Query: "why does the build fail?", TargetRatio: DefaultTargetCompressionRatio, Content: "\x00content\x00"}
+
+	if c.System != "" {
+		t, err := template.New("system").Funcs(promptFuncs).Parse(c.System)
+		if err != nil {
+			return nil, fmt.Errorf("prompt_template.system: %w", err)
+		}
+		if _, err := render(t, sample); err != nil {
+			return nil, fmt.Errorf("prompt_template.system: %w", err)
+		}
+		pt.System = t
+	}
+	if c.User != "" {
+		t, err := template.New("user").Funcs(promptFuncs).Parse(c.User)
+		if err != nil {
+			return nil, fmt.Errorf("prompt_template.user: %w", err)
+		}
+		out, err := render(t, sample)
+		if err != nil {
+			return nil, fmt.Errorf("prompt_template.user: %w", err)
+		}
+		if !strings.Contains(out, sample.Content) {
+			return nil, fmt.Errorf("prompt_template.user must include {{.Content}}")
+		}
+		pt.User = t
+	}
+	return &pt, nil
+}
+
+// Render executes the configured templates, falling back to the given built-in
+// prompts for any template that is not set.
+func (pt *PromptTemplates) Render(vars PromptVars, defaultSystem, defaultUser string) (system, user string, err error) {
+	system, user = defaultSystem, defaultUser
+	if pt == nil {
+		return system, user, nil
+	}
+	if pt.System != nil {
+		if system, err = render(pt.System, vars); err != nil {
+			return "", "", fmt.Errorf("prompt_template.system: %w", err)
+		}
+	}
+	if pt.User != nil {
+		if user, err = render(pt.User, vars); err != nil {
+			return "", "", fmt.Errorf("prompt_template.user: %w", err)
+		}
+	}
+	return system, user, nil
+}
+
+func render(t *template.Template, vars PromptVars) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,332 @@
+// embeddings.go - Embedding-similarity ranking for the embeddings strategy.
+//
+// Keyword scoring misses tools described in different words than the user's
+// ("fetch this page" vs "http_get: download a URL"). The embeddings strategy
+// embeds each tool's name and description and the user query, and ranks
+// candidates by cosine similarity. Budgeting, always_keep, expanded tools and
+// tool_choice targets are handled by the shared relevance path
+// (scoreAndFilterTools); only the score changes.
+//
+// Vectors come from a local feature-hashing embedder or, when configured, an
+// OpenAI-compatible /v1/embeddings endpoint. Tool vectors are cached per
+// session, so after the first turn only the query is embedded.
+package tooldiscovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+const (
+	localEmbeddingDims       = 512
+	defaultEmbeddingsTimeout = 5 * time.Second
+	maxEmbeddingSessions     = 256      // Sessions whose tool vectors are kept
+	maxEmbeddingsResponse    = 64 << 20 // Cap on an embeddings endpoint response
+)
+
+// embedder turns texts into vectors, one per text.
+type embedder interface {
+	embed(ctx context.Context, texts []string) ([][]float32, error)
+	model() string // Model name for telemetry; empty for the local embedder
+}
+
+// newEmbedder returns the endpoint embedder when one is configured, else the local one.
+func newEmbedder(cfg pipes.EmbeddingsConfig) embedder {
+	if cfg.Endpoint == "" {
+		return localEmbedder{}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmbeddingsTimeout
+	}
+	return &remoteEmbedder{
+		endpoint:  cfg.Endpoint,
+		apiKey:    cfg.APIKey,
+		modelName: cfg.Model,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// localEmbedder hashes words and character trigrams into a fixed-size vector.
+// Trigrams let "files" match "file" and "reading" match "read" without a model.
+type localEmbedder struct{}
+
+func (localEmbedder) model() string { return "" }
+
+func (localEmbedder) embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = hashEmbedding(text)
+	}
+	return out, nil
+}
+
+func hashEmbedding(text string) []float32 {
+	vec := make([]float32, localEmbeddingDims)
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum32()
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vec[sum%localEmbeddingDims] += weight
+	}
+	for _, word := range tokenize(splitCamelCase(text)) {
+		add(word, 1)
+		padded := "^" + word + "$"
+		for j := 0; j+3 <= len(padded); j++ {
+			add(padded[j:j+3], 0.5)
+		}
+	}
+	normalize(vec)
+	return vec
+}
+
+// splitCamelCase lowercases s, breaking camelCase words apart ("readFile" -> "read file").
+func splitCamelCase(s string) string {
+	out := make([]rune, 0, len(s)+8)
+	var prev rune
+	for _, r := range s {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			out = append(out, ' ')
+		}
+		out = append(out, unicode.ToLower(r))
+		prev = r
+	}
+	return string(out)
+}
+
+// remoteEmbedder calls an OpenAI-compatible embeddings endpoint.
+type remoteEmbedder struct {
+	endpoint  string
+	apiKey    string
+	modelName string
+	client    *http.Client
+}
+
+func (e *remoteEmbedder) model() string { return e.modelName }
+
+func (e *remoteEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.modelName, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings endpoint returned %d", resp.StatusCode)
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEmbeddingsResponse)).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embeddings response index %d out of range", d.Index)
+		}
+		normalize(d.Embedding)
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings response missing input %d", i)
+		}
+	}
+	return out, nil
+}
+
+// embeddingCache keeps tool vectors per session, keyed by tool content.
+type embeddingCache struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionVectors
+}
+
+type sessionVectors struct {
+	vectors  map[string][]float32 // toolKey -> unit vector
+	lastUsed time.Time
+}
+
+func newEmbeddingCache() *embeddingCache {
+	return &embeddingCache{sessions: make(map[string]*sessionVectors)}
+}
+
+// lookup returns the cached vectors for keys; missing keys are absent from the result.
+func (c *embeddingCache) lookup(sessionID string, keys []string) map[string][]float32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found := make(map[string][]float32, len(keys))
+	sv, ok := c.sessions[sessionID]
+	if !ok {
+		return found
+	}
+	sv.lastUsed = time.Now()
+	for _, k := range keys {
+		if v, ok := sv.vectors[k]; ok {
+			found[k] = v
+		}
+	}
+	return found
+}
+
+// store adds vectors for a session, evicting the least recently used session when full.
+func (c *embeddingCache) store(sessionID string, vectors map[string][]float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sv, ok := c.sessions[sessionID]
+	if !ok {
+		if len(c.sessions) >= maxEmbeddingSessions {
+			var oldestID string
+			var oldest time.Time
+			for id, s := range c.sessions {
+				if oldestID == "" || s.lastUsed.Before(oldest) {
+					oldestID, oldest = id, s.lastUsed
+				}
+			}
+			delete(c.sessions, oldestID)
+		}
+		sv = &sessionVectors{vectors: make(map[string][]float32, len(vectors))}
+		c.sessions[sessionID] = sv
+	}
+	sv.lastUsed = time.Now()
+	for k, v := range vectors {
+		sv.vectors[k] = v
+	}
+}
+
+// clear drops one session's vectors.
+func (c *embeddingCache) clear(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, sessionID)
+}
+
+// toolEmbeddingText is what gets embedded for a tool.
+func toolEmbeddingText(t adapters.ExtractedContent) string {
+	return t.ToolName + ": " + t.Content
+}
+
+// toolEmbeddingKey identifies a tool's text, so an edited description is re-embedded.
+func toolEmbeddingKey(t adapters.ExtractedContent) string {
+	h := sha256.Sum256([]byte(toolEmbeddingText(t)))
+	return hex.EncodeToString(h[:16])
+}
+
+// embeddingSimilarity returns each tool's cosine similarity to query, by tool
+// name. It returns nil when there is no query or embedding fails, so the
+// caller falls back to keyword scoring.
+func (p *Pipe) embeddingSimilarity(ctx *pipes.PipeContext, tools []adapters.ExtractedContent, query string) map[string]float64 {
+	if p.embedder == nil || query == "" {
+		return nil
+	}
+	reqCtx := ctx.RequestCtx
+	if reqCtx == nil {
+		reqCtx = context.Background()
+	}
+	sessionID := ctx.ToolSessionID
+	if sessionID == "" {
+		sessionID = ctx.SessionID
+	}
+
+	keys := make([]string, len(tools))
+	for i, t := range tools {
+		keys[i] = toolEmbeddingKey(t)
+	}
+	vectors := p.embeddings.lookup(sessionID, keys)
+
+	// Embed the query and every uncached tool in one call.
+	texts := []string{query}
+	var missing []int
+	for i, t := range tools {
+		if _, ok := vectors[keys[i]]; !ok {
+			texts = append(texts, toolEmbeddingText(t))
+			missing = append(missing, i)
+		}
+	}
+	embedded, err := p.embedder.embed(reqCtx, texts)
+	if err != nil {
+		log.Warn().Err(err).Msg("tool_discovery(embeddings): embedding failed, falling back to keyword relevance")
+		return nil
+	}
+	queryVec := embedded[0]
+	fresh := make(map[string][]float32, len(missing))
+	for j, i := range missing {
+		fresh[keys[i]] = embedded[j+1]
+		vectors[keys[i]] = embedded[j+1]
+	}
+	if len(fresh) > 0 {
+		p.embeddings.store(sessionID, fresh)
+	}
+
+	sims := make(map[string]float64, len(tools))
+	for i, t := range tools {
+		sims[t.ToolName] = cosine(queryVec, vectors[keys[i]])
+	}
+	ctx.ToolDiscoveryModel = p.embedder.model()
+	log.Debug().
+		Int("tools", len(tools)).
+		Int("embedded", len(missing)).
+		Msg("tool_discovery(embeddings): ranked tools by similarity")
+	return sims
+}
+
+// normalize scales v to unit length in place.
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	n := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= n
+	}
+}
+
+// cosine returns the dot product of two unit vectors (0 on a dimension mismatch).
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
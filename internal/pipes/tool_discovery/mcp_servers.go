@@ -0,0 +1,107 @@
+// mcp_servers.go - MCP server grouping for tool discovery.
+//
+// MCP clients such as Claude Code name tools mcp__<server>__<tool>. Scored one
+// by one, the tools of a large server (GitHub ships ~200) end up as a scattered
+// subset; a server-level signal moves a server's tools up or down together,
+// and keep_servers / drop_servers apply to every tool of a server.
+package tooldiscovery
+
+import (
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// mcpToolPrefix starts every MCP tool name.
+const mcpToolPrefix = "mcp__"
+
+// Score weights for MCP server signals.
+const (
+	scoreServerName   = 30 // Query mentions the tool's server
+	scoreServerRecent = 30 // Another tool of the same server was used in conversation history
+)
+
+// MCPServer returns the server of a tool named mcp__<server>__<tool>, or ""
+// when the name has no MCP prefix.
+func MCPServer(toolName string) string {
+	rest, ok := strings.CutPrefix(toolName, mcpToolPrefix)
+	if !ok {
+		return ""
+	}
+	server, tool, ok := strings.Cut(rest, "__")
+	if !ok || server == "" || tool == "" {
+		return ""
+	}
+	return server
+}
+
+// bareToolName strips the mcp__<server>__ prefix, so name signals score
+// create_issue rather than mcp__github__create_issue.
+func bareToolName(toolName string) string {
+	server := MCPServer(toolName)
+	if server == "" {
+		return toolName
+	}
+	return toolName[len(mcpToolPrefix)+len(server)+len("__"):]
+}
+
+// mcpServers returns the servers of the given tool names.
+func mcpServers(toolNames map[string]bool) map[string]bool {
+	servers := make(map[string]bool)
+	for name := range toolNames {
+		if server := MCPServer(name); server != "" {
+			servers[server] = true
+		}
+	}
+	return servers
+}
+
+// serverScore is the server-level signal shared by all tools of a server.
+// Server names like google-drive match on any of their words.
+func serverScore(server string, queryWords, recentServers map[string]bool) int {
+	if server == "" {
+		return 0
+	}
+	score := 0
+	if recentServers[server] {
+		score += scoreServerRecent
+	}
+	for _, w := range tokenize(strings.ToLower(server)) {
+		if queryWords[w] {
+			score += scoreServerName
+			break
+		}
+	}
+	return score
+}
+
+// serverStats counts each MCP server's tools and how many were kept in full.
+// Returns nil when no tool belongs to an MCP server.
+func serverStats(tools, deferred []adapters.ExtractedContent) map[string]pipes.ToolServerStats {
+	deferredNames := make(map[string]bool, len(deferred))
+	for _, t := range deferred {
+		deferredNames[t.ToolName] = true
+	}
+
+	var stats map[string]pipes.ToolServerStats
+	for _, t := range tools {
+		server := MCPServer(t.ToolName)
+		if server == "" {
+			continue
+		}
+		if stats == nil {
+			stats = make(map[string]pipes.ToolServerStats)
+		}
+		tokens := toolTokens(t)
+		s := stats[server]
+		s.Tools++
+		s.OriginalTokens += tokens
+		if !deferredNames[t.ToolName] {
+			s.Kept++
+			s.KeptTokens += tokens
+		}
+		stats[server] = s
+	}
+	return stats
+}
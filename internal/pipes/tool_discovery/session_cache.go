@@ -0,0 +1,82 @@
+// session_cache.go - Per-session cache of tool-search stubbing results.
+package tooldiscovery
+
+import (
+	"sync"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// MaxCachedSessions caps the sessions whose tool-search results are cached;
+// every conversation branch is its own session, so the cache must be bounded.
+const MaxCachedSessions = 256
+
+// cachedResult stores a previously filtered result for a session. It keeps the
+// per-tool decisions rather than the filtered body: the body carries the whole
+// conversation, so the decisions are reapplied to each request's own messages.
+type cachedResult struct {
+	hash           string // hash of sorted tool names
+	results        []adapters.CompressedResult
+	deferredTools  []adapters.ExtractedContent
+	serverStats    map[string]pipes.ToolServerStats
+	originalTokens int
+	filteredTokens int
+	lastUsed       time.Time
+}
+
+// SessionCache holds tool-search results per session, evicting the least
+// recently used session when full. Thread-safe.
+type SessionCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResult // sessionID -> cached result
+}
+
+// NewSessionCache creates an empty cache.
+func NewSessionCache() *SessionCache {
+	return &SessionCache{entries: make(map[string]*cachedResult)}
+}
+
+// Len returns the number of cached sessions.
+func (c *SessionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the session's result if it was computed for the same tool set.
+func (c *SessionCache) get(sessionID, hash string) *cachedResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.entries[sessionID]; ok && cached.hash == hash {
+		cached.lastUsed = time.Now()
+		return cached
+	}
+	return nil
+}
+
+// set stores a session's result.
+func (c *SessionCache) set(sessionID string, result *cachedResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[sessionID]; !ok && len(c.entries) >= MaxCachedSessions {
+		var oldestID string
+		var oldest time.Time
+		for id, e := range c.entries {
+			if oldestID == "" || e.lastUsed.Before(oldest) {
+				oldestID, oldest = id, e.lastUsed
+			}
+		}
+		delete(c.entries, oldestID)
+	}
+	result.lastUsed = time.Now()
+	c.entries[sessionID] = result
+}
+
+// clear drops one session's result.
+func (c *SessionCache) clear(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, sessionID)
+}
@@ -0,0 +1,71 @@
+package tooldiscovery
+
+import (
+	"sort"
+
+	"github.com/tidwall/gjson"
+)
+
+// toolChoiceTargets returns the tools the request's tool_choice forces the
+// model to call. Deferring one of them leaves tool_choice pointing at a stub
+// (or, upstream, at a missing tool) and the provider rejects the request, so
+// every strategy keeps them with full definitions.
+//
+// Recognized forms:
+//
+//	Anthropic:          {"tool_choice": {"type": "tool", "name": "X"}}
+//	OpenAI Chat:        {"tool_choice": {"type": "function", "function": {"name": "X"}}}
+//	OpenAI Responses:   {"tool_choice": {"type": "function", "name": "X"}}
+//	OpenAI allowed set: {"tool_choice": {"type": "allowed_tools", "tools": [...]}}
+//	Gemini:             {"toolConfig": {"functionCallingConfig": {"allowedFunctionNames": [...]}}}
+//
+// "auto", "any", "required" and "none" name no tool and return nil.
+// disable_parallel_tool_use and parallel_tool_calls are left untouched.
+func toolChoiceTargets(body []byte) map[string]bool {
+	targets := make(map[string]bool)
+	addChoice(targets, gjson.GetBytes(body, "tool_choice"))
+	for _, name := range gjson.GetBytes(body, "toolConfig.functionCallingConfig.allowedFunctionNames").Array() {
+		if name.String() != "" {
+			targets[name.String()] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return targets
+}
+
+// addChoice adds the tool named by one tool_choice object.
+func addChoice(targets map[string]bool, choice gjson.Result) {
+	if !choice.IsObject() {
+		return
+	}
+	switch choice.Get("type").String() {
+	case "tool", "function":
+		name := choice.Get("name").String()
+		if name == "" {
+			name = choice.Get("function.name").String()
+		}
+		if name != "" {
+			targets[name] = true
+		}
+	case "allowed_tools":
+		tools := choice.Get("tools")
+		if !tools.Exists() {
+			tools = choice.Get("allowed_tools.tools")
+		}
+		for _, t := range tools.Array() {
+			addChoice(targets, t)
+		}
+	}
+}
+
+// sortedNames returns the keys of names in sorted order (for logs and cache keys).
+func sortedNames(names map[string]bool) []string {
+	out := make([]string, 0, len(names))
+	for name := range names {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
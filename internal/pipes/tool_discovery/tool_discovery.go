@@ -5,9 +5,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"math"
 	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -36,17 +36,9 @@ const (
 	scoreRecentlyUsed = 100 // Tool was used in conversation history
 	scoreExactName    = 50  // Query contains exact tool name
 	scoreWordMatch    = 10  // Per-word overlap between query and tool name/description
+	scoreSimilarity   = 100 // Cosine similarity (0..1) to the query, embeddings strategy
 )
 
-// cachedResult stores a previously filtered result for a session.
-type cachedResult struct {
-	hash           string // hash of sorted tool names
-	filteredBody   []byte
-	deferredTools  []adapters.ExtractedContent
-	originalTokens int
-	filteredTokens int
-}
-
 // Pipe filters tools dynamically based on relevance to the current query.
 type Pipe struct {
 	enabled          bool
@@ -54,6 +46,8 @@ type Pipe struct {
 	tokenThreshold   int // trigger discovery when total tool tokens > this value
 	alwaysKeep       map[string]bool
 	alwaysKeepList   []string // For API payload
+	keepServers      map[string]bool
+	dropServers      map[string]bool
 	searchToolName   string
 	maxSearchResults int
 
@@ -66,17 +60,35 @@ type Pipe struct {
 	compresrModel    string // Model name for compresr strategy (e.g., "tdc_coldbrew_v1")
 	compresrTimeout  time.Duration
 
+	// Embeddings strategy: embedder and per-session tool vectors
+	embedder   embedder
+	embeddings *embeddingCache
+
 	// Session-scoped cache for lazy loading (tool stubbing)
-	cacheMu sync.RWMutex
-	cache   map[string]*cachedResult // sessionID -> cached result
+	cache *SessionCache
 }
 
-// New creates a new tool discovery pipe.
+// New creates a new tool discovery pipe with its own session cache.
 func New(cfg *config.Config) *Pipe {
+	return NewWithCache(cfg, NewSessionCache())
+}
+
+// NewWithCache creates a tool discovery pipe that caches tool-search results
+// in cache. Pipes pooled for one config share a cache, so each session's
+// result is computed and held once rather than once per pool instance.
+func NewWithCache(cfg *config.Config, cache *SessionCache) *Pipe {
 	alwaysKeep := make(map[string]bool)
 	for _, name := range cfg.Pipes.ToolDiscovery.AlwaysKeep {
 		alwaysKeep[name] = true
 	}
+	keepServers := make(map[string]bool)
+	for _, server := range cfg.Pipes.ToolDiscovery.KeepServers {
+		keepServers[server] = true
+	}
+	dropServers := make(map[string]bool)
+	for _, server := range cfg.Pipes.ToolDiscovery.DropServers {
+		dropServers[server] = true
+	}
 
 	// NOTE: gateway_search_tools injection is handled by phantom_tools.InjectAll in handler.go.
 	// The pipe does not inject it — single injection path keeps dedup logic in one place.
@@ -123,6 +135,11 @@ func New(cfg *config.Config) *Pipe {
 		}
 	}
 
+	var embed embedder
+	if tdStrategy == config.StrategyEmbeddings {
+		embed = newEmbedder(cfg.Pipes.ToolDiscovery.Embeddings)
+	}
+
 	tokenThreshold := cfg.Pipes.ToolDiscovery.TokenThreshold
 	if tokenThreshold <= 0 {
 		tokenThreshold = DefaultTokenThreshold
@@ -134,6 +151,8 @@ func New(cfg *config.Config) *Pipe {
 		tokenThreshold:   tokenThreshold,
 		alwaysKeep:       alwaysKeep,
 		alwaysKeepList:   cfg.Pipes.ToolDiscovery.AlwaysKeep,
+		keepServers:      keepServers,
+		dropServers:      dropServers,
 		searchToolName:   searchToolName,
 		maxSearchResults: maxSearchResults,
 		compresrClient:   compresrClient,
@@ -141,7 +160,9 @@ func New(cfg *config.Config) *Pipe {
 		compresrKey:      cfg.Pipes.ToolDiscovery.Compresr.APIKey,
 		compresrTimeout:  compresrTimeout,
 		compresrModel:    cfg.Pipes.ToolDiscovery.Compresr.Model,
-		cache:            make(map[string]*cachedResult),
+		embedder:         embed,
+		embeddings:       newEmbeddingCache(),
+		cache:            cache,
 	}
 }
 
@@ -191,28 +212,10 @@ func computeToolHash(tools []adapters.ExtractedContent) string {
 	return hex.EncodeToString(h[:])
 }
 
-// getCache retrieves cached result for a session.
-func (p *Pipe) getCache(sessionID, hash string) *cachedResult {
-	p.cacheMu.RLock()
-	defer p.cacheMu.RUnlock()
-	if cached, ok := p.cache[sessionID]; ok && cached.hash == hash {
-		return cached
-	}
-	return nil
-}
-
-// setCache stores filtered result in cache.
-func (p *Pipe) setCache(sessionID string, result *cachedResult) {
-	p.cacheMu.Lock()
-	defer p.cacheMu.Unlock()
-	p.cache[sessionID] = result
-}
-
 // ClearSessionCache removes cache for a specific session.
 func (p *Pipe) ClearSessionCache(sessionID string) {
-	p.cacheMu.Lock()
-	defer p.cacheMu.Unlock()
-	delete(p.cache, sessionID)
+	p.cache.clear(sessionID)
+	p.embeddings.clear(sessionID)
 }
 
 // Process filters tools before sending to LLM.
@@ -225,7 +228,7 @@ func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
 	ctx.ToolDiscoveryModel = p.getEffectiveModel()
 
 	switch p.strategy {
-	case config.StrategyRelevance:
+	case config.StrategyRelevance, config.StrategyEmbeddings:
 		return p.filterByRelevance(ctx)
 	case config.StrategyCompresr:
 		return p.filterViaCompresr(ctx)
@@ -237,7 +240,8 @@ func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
 }
 
 // filterByRelevance scores and filters tools based on multi-signal relevance.
-// This is heuristic-based filtering (no external model).
+// This is heuristic-based filtering (no external model); the embeddings
+// strategy replaces the keyword signal with embedding similarity.
 func (p *Pipe) filterByRelevance(ctx *pipes.PipeContext) ([]byte, error) {
 	// Clear model since this is heuristic filtering, not API-based
 	ctx.ToolDiscoveryModel = ""
@@ -294,14 +298,27 @@ func (p *Pipe) prepareToolSearch(ctx *pipes.PipeContext) ([]byte, error) {
 		return ctx.OriginalRequest, nil
 	}
 
-	// Check cache for this session + tool set
+	// tool_choice targets keep full definitions; the cache key includes them
+	// because the same tool set can be sent with a different tool_choice.
+	forced := toolChoiceTargets(ctx.OriginalRequest)
 	toolHash := computeToolHash(tools)
-	if cached := p.getCache(ctx.SessionID, toolHash); cached != nil {
-		// Cache hit - reuse cached result
+	if len(forced) > 0 {
+		toolHash += ":" + strings.Join(sortedNames(forced), ",")
+	}
+
+	// Check cache for this session + tool set
+	if cached := p.cache.get(ctx.SessionID, toolHash); cached != nil {
+		// Cache hit - reapply the cached stubbing to this request's body
+		modified, err := parsedAdapter.ApplyToolDiscoveryToParsed(parsed, cached.results)
+		if err != nil {
+			log.Warn().Err(err).Msg("tool_discovery(tool-search): failed to apply cached stubs")
+			return ctx.OriginalRequest, nil
+		}
 		ctx.DeferredTools = cached.deferredTools
 		ctx.ToolsFiltered = true
 		ctx.OriginalToolCount = len(tools)
-		ctx.KeptToolCount = 0
+		ctx.KeptToolCount = len(tools) - len(cached.deferredTools)
+		ctx.ToolServerStats = cached.serverStats
 		ctx.CacheHit = true // Set cache hit flag for telemetry
 
 		log.Info().
@@ -311,14 +328,20 @@ func (p *Pipe) prepareToolSearch(ctx *pipes.PipeContext) ([]byte, error) {
 			Int("cached_tokens", cached.filteredTokens).
 			Msg("tool_discovery(tool-search): cache HIT, using cached stubs")
 
-		return cached.filteredBody, nil
+		return modified, nil
 	}
 
 	// Cache miss - process and cache
-	// Mark ALL tools as deferred — ApplyToolDiscoveryToParsed emits stubs for Keep=false.
+	// Mark all tools except tool_choice targets as deferred — ApplyToolDiscoveryToParsed
+	// emits stubs for Keep=false.
 	results := make([]adapters.CompressedResult, 0, len(tools))
+	deferred := make([]adapters.ExtractedContent, 0, len(tools))
 	for _, t := range tools {
-		results = append(results, adapters.CompressedResult{ID: t.ID, Keep: false})
+		keep := forced[t.ToolName]
+		results = append(results, adapters.CompressedResult{ID: t.ID, Keep: keep})
+		if !keep {
+			deferred = append(deferred, t)
+		}
 	}
 
 	modified, err := parsedAdapter.ApplyToolDiscoveryToParsed(parsed, results)
@@ -327,16 +350,20 @@ func (p *Pipe) prepareToolSearch(ctx *pipes.PipeContext) ([]byte, error) {
 		return ctx.OriginalRequest, nil
 	}
 
-	// Store all original tools for search and eventual re-injection.
-	ctx.DeferredTools = tools
+	// Store the stubbed tools for search and eventual re-injection.
+	ctx.DeferredTools = deferred
 	ctx.ToolsFiltered = true
 	ctx.OriginalToolCount = len(tools)
-	ctx.KeptToolCount = 0 // 0 tools with full definitions (all stubbed)
-	ctx.CacheHit = false  // Explicit cache miss
+	ctx.KeptToolCount = len(tools) - len(deferred) // Only tool_choice targets keep full definitions
+	ctx.CacheHit = false                           // Explicit cache miss
+	ctx.ToolServerStats = serverStats(tools, deferred)
 
 	origTokens := estimateToolTokens(tools)
 	// Each stub is ~50 tokens (name + "[deferred]" + minimal schema)
-	stubTokens := len(tools) * 50
+	stubTokens := len(deferred) * 50
+	if len(deferred) < len(tools) {
+		stubTokens += origTokens - estimateToolTokens(deferred) // Forced tools are sent in full
+	}
 	ratio := tokenizer.CompressionRatio(origTokens, stubTokens)
 	toolNames := make([]string, len(tools))
 	for i, t := range tools {
@@ -345,10 +372,11 @@ func (p *Pipe) prepareToolSearch(ctx *pipes.PipeContext) ([]byte, error) {
 
 	// Cache the result
 	if ctx.SessionID != "" {
-		p.setCache(ctx.SessionID, &cachedResult{
+		p.cache.set(ctx.SessionID, &cachedResult{
 			hash:           toolHash,
-			filteredBody:   modified,
-			deferredTools:  tools,
+			results:        results,
+			deferredTools:  deferred,
+			serverStats:    ctx.ToolServerStats,
 			originalTokens: origTokens,
 			filteredTokens: stubTokens,
 		})
@@ -356,10 +384,12 @@ func (p *Pipe) prepareToolSearch(ctx *pipes.PipeContext) ([]byte, error) {
 
 	log.Info().
 		Int("total", len(tools)).
+		Strs("tool_choice_kept", sortedNames(forced)).
 		Int("original_tokens", origTokens).
 		Int("stub_tokens", stubTokens).
 		Float64("compression_ratio", ratio).
 		Strs("tool_names", toolNames).
+		Interface("mcp_servers", ctx.ToolServerStats).
 		Bool("cache_hit", false).
 		Str("event_type", "init_agent_tools").
 		Str("search_tool", p.searchToolName).
@@ -377,6 +407,12 @@ func (p *Pipe) filterViaCompresr(ctx *pipes.PipeContext) ([]byte, error) {
 		return p.filterByRelevance(ctx)
 	}
 
+	if ctx.CountOnly {
+		// Selections are not cached, so a count would pay for a call the
+		// request that follows makes again.
+		return p.filterByRelevance(ctx)
+	}
+
 	query := ctx.UserQuery
 	if query == "" {
 		log.Debug().Msg("tool_discovery(compresr): no query available, falling back to local relevance")
@@ -461,6 +497,17 @@ func (p *Pipe) filterViaCompresr(ctx *pipes.PipeContext) ([]byte, error) {
 	for _, name := range p.alwaysKeepList {
 		keepSet[name] = true
 	}
+	for _, t := range tools {
+		server := MCPServer(t.ToolName)
+		if p.keepServers[server] {
+			keepSet[t.ToolName] = true
+		} else if p.dropServers[server] && !p.alwaysKeep[t.ToolName] {
+			delete(keepSet, t.ToolName)
+		}
+	}
+	for name := range toolChoiceTargets(ctx.OriginalRequest) {
+		keepSet[name] = true
+	}
 
 	results := make([]adapters.CompressedResult, 0, len(tools))
 	keptNames := make([]string, 0, len(filterResp.RelevantTools))
@@ -488,6 +535,7 @@ func (p *Pipe) filterViaCompresr(ctx *pipes.PipeContext) ([]byte, error) {
 	ctx.ToolsFiltered = true
 	ctx.OriginalToolCount = totalTools
 	ctx.KeptToolCount = len(keptNames)
+	ctx.ToolServerStats = serverStats(tools, deferred)
 
 	// gateway_search_tools is injected unconditionally by phantom_tools.InjectAll in handler.go.
 
@@ -499,6 +547,7 @@ func (p *Pipe) filterViaCompresr(ctx *pipes.PipeContext) ([]byte, error) {
 		Int("deferred", len(deferred)).
 		Strs("deferred_tools", deferredNames).
 		Bool("tools_deferred", len(deferred) > 0).
+		Interface("mcp_servers", ctx.ToolServerStats).
 		Msg("tool_discovery(compresr): filtered tools via Compresr API")
 
 	return modified, nil
@@ -533,6 +582,8 @@ type filterInput struct {
 	query         string
 	recentTools   map[string]bool
 	expandedTools map[string]bool
+	forcedTools   map[string]bool    // tool_choice targets; never deferred
+	similarity    map[string]float64 // tool name -> embedding similarity; nil = keyword scoring
 }
 
 // filterOutput contains the filtering results.
@@ -553,30 +604,47 @@ type scoredTool struct {
 // scoreAndFilterTools scores tools and determines which to keep.
 //
 // Two-phase approach:
-//  1. Protected tools (always_keep + expanded) are separated upfront — they are
-//     always kept regardless of the token budget, so their guarantee is explicit
-//     and does not depend on sort position or score equality.
+//  1. Protected tools (always_keep + expanded + tool_choice targets + keep_servers) are separated
+//     upfront — they are always kept regardless of the token budget, so their guarantee is explicit
+//     and does not depend on sort position or score equality. Tools of drop_servers that are not
+//     otherwise protected are always deferred.
 //  2. The remaining candidate tools are scored, sorted by relevance descending,
 //     then greedily admitted until their accumulated token count reaches the
 //     tokenThreshold budget.
 func (p *Pipe) scoreAndFilterTools(input *filterInput) *filterOutput {
 	totalTools := len(input.tools)
 
-	// Phase 1: separate protected tools from candidates.
+	// Phase 1: separate protected and dropped tools from candidates.
 	protected := make([]adapters.ExtractedContent, 0)
+	dropped := make([]adapters.ExtractedContent, 0)
 	candidates := make([]adapters.ExtractedContent, 0, totalTools)
 	for _, tool := range input.tools {
-		if p.alwaysKeep[tool.ToolName] || input.expandedTools[tool.ToolName] {
+		server := MCPServer(tool.ToolName)
+		switch {
+		case p.alwaysKeep[tool.ToolName] || input.expandedTools[tool.ToolName] || input.forcedTools[tool.ToolName]:
 			protected = append(protected, tool)
-		} else {
+		case p.dropServers[server]:
+			dropped = append(dropped, tool)
+		case p.keepServers[server]:
+			protected = append(protected, tool)
+		default:
 			candidates = append(candidates, tool)
 		}
 	}
 
-	// Phase 2: score and sort candidates by relevance.
+	// Phase 2: score and sort candidates by relevance, plus the MCP server signal.
+	queryWords := make(map[string]bool)
+	for _, w := range tokenize(strings.ToLower(input.query)) {
+		queryWords[w] = true
+	}
+	recentServers := mcpServers(input.recentTools)
 	scored := make([]scoredTool, 0, len(candidates))
 	for _, tool := range candidates {
 		score := p.scoreTool(tool, input.query, input.recentTools)
+		if input.similarity != nil {
+			score = p.scoreToolBySimilarity(tool, input.query, input.recentTools, input.similarity[tool.ToolName])
+		}
+		score += serverScore(MCPServer(tool.ToolName), queryWords, recentServers)
 		scored = append(scored, scoredTool{tool: tool, score: score})
 	}
 
@@ -592,16 +660,11 @@ func (p *Pipe) scoreAndFilterTools(input *filterInput) *filterOutput {
 	budget := p.tokenThreshold
 	admittedCount := 0
 	for _, s := range scored {
-		var toolTokens int
-		if raw, ok := s.tool.Metadata["raw_json"].(string); ok && raw != "" {
-			toolTokens = tokenizer.CountTokens(raw)
-		} else {
-			toolTokens = tokenizer.CountTokens(s.tool.Content)
-		}
-		if admittedCount > 0 && budget-toolTokens < 0 {
+		tokens := toolTokens(s.tool)
+		if admittedCount > 0 && budget-tokens < 0 {
 			break
 		}
-		budget -= toolTokens
+		budget -= tokens
 		admittedCount++
 	}
 	if admittedCount == 0 && len(scored) > 0 {
@@ -630,6 +693,12 @@ func (p *Pipe) scoreAndFilterTools(input *filterInput) *filterOutput {
 		}
 	}
 
+	for _, tool := range dropped {
+		results = append(results, adapters.CompressedResult{ID: tool.ID, Keep: false})
+		deferred = append(deferred, tool)
+		deferredNames = append(deferredNames, tool.ToolName)
+	}
+
 	return &filterOutput{
 		results:       results,
 		deferred:      deferred,
@@ -640,7 +709,9 @@ func (p *Pipe) scoreAndFilterTools(input *filterInput) *filterOutput {
 }
 
 // applyFilterResults applies filtering output to context and logs.
-func (p *Pipe) applyFilterResults(ctx *pipes.PipeContext, output *filterOutput, query string, totalTools int, modified []byte) []byte {
+func (p *Pipe) applyFilterResults(ctx *pipes.PipeContext, tools []adapters.ExtractedContent, output *filterOutput, query string, modified []byte) []byte {
+	totalTools := len(tools)
+
 	// Store deferred tools in context for session storage
 	ctx.DeferredTools = output.deferred
 	ctx.ToolsFiltered = true
@@ -648,6 +719,7 @@ func (p *Pipe) applyFilterResults(ctx *pipes.PipeContext, output *filterOutput,
 	// Set counts for telemetry
 	ctx.OriginalToolCount = totalTools
 	ctx.KeptToolCount = output.keptCount
+	ctx.ToolServerStats = serverStats(tools, output.deferred)
 
 	// gateway_search_tools is injected unconditionally by phantom_tools.InjectAll in handler.go.
 
@@ -660,6 +732,7 @@ func (p *Pipe) applyFilterResults(ctx *pipes.PipeContext, output *filterOutput,
 		Int("deferred", len(output.deferred)).
 		Strs("deferred_tools", output.deferredNames).
 		Bool("tools_deferred", len(output.deferred) > 0).
+		Interface("mcp_servers", ctx.ToolServerStats).
 		Msg("tool_discovery: filtered tools by relevance")
 
 	return modified
@@ -731,12 +804,17 @@ func (p *Pipe) filterByRelevanceParsed(ctx *pipes.PipeContext, parsedAdapter ada
 	}
 
 	// Score and filter tools using shared logic
-	output := p.scoreAndFilterTools(&filterInput{
+	input := &filterInput{
 		tools:         tools,
 		query:         query,
 		recentTools:   recentTools,
 		expandedTools: expandedTools,
-	})
+		forcedTools:   toolChoiceTargets(ctx.OriginalRequest),
+	}
+	if p.strategy == config.StrategyEmbeddings {
+		input.similarity = p.embeddingSimilarity(ctx, tools, query)
+	}
+	output := p.scoreAndFilterTools(input)
 
 	// Apply filtered tools using parsed structure (single marshal at end)
 	modified, err := parsedAdapter.ApplyToolDiscoveryToParsed(parsed, output.results)
@@ -746,7 +824,7 @@ func (p *Pipe) filterByRelevanceParsed(ctx *pipes.PipeContext, parsedAdapter ada
 	}
 
 	// Apply results, inject search tool, and log
-	modified = p.applyFilterResults(ctx, output, query, totalTools, modified)
+	modified = p.applyFilterResults(ctx, tools, output, query, modified)
 
 	return modified, nil
 }
@@ -759,16 +837,11 @@ func (p *Pipe) calculateTokenBudgetKeepCount(tools []adapters.ExtractedContent)
 	budget := p.tokenThreshold
 	kept := 0
 	for _, t := range tools {
-		var toolTokens int
-		if raw, ok := t.Metadata["raw_json"].(string); ok && raw != "" {
-			toolTokens = tokenizer.CountTokens(raw)
-		} else {
-			toolTokens = tokenizer.CountTokens(t.Content)
-		}
-		if kept > 0 && budget-toolTokens < 0 {
+		tokens := toolTokens(t)
+		if kept > 0 && budget-tokens < 0 {
 			break
 		}
-		budget -= toolTokens
+		budget -= tokens
 		kept++
 	}
 	if kept == 0 && len(tools) > 0 {
@@ -791,7 +864,7 @@ func (p *Pipe) scoreTool(tool adapters.ExtractedContent, query string, recentToo
 	}
 
 	queryLower := strings.ToLower(query)
-	toolNameLower := strings.ToLower(tool.ToolName)
+	toolNameLower := strings.ToLower(bareToolName(tool.ToolName))
 
 	// Signal 1: Exact tool name appears in query
 	if strings.Contains(queryLower, toolNameLower) {
@@ -816,6 +889,19 @@ func (p *Pipe) scoreTool(tool adapters.ExtractedContent, query string, recentToo
 	return score
 }
 
+// scoreToolBySimilarity is scoreTool with the word-overlap signal replaced by
+// embedding similarity.
+func (p *Pipe) scoreToolBySimilarity(tool adapters.ExtractedContent, query string, recentTools map[string]bool, similarity float64) int {
+	score := int(math.Round(similarity * scoreSimilarity))
+	if recentTools[tool.ToolName] {
+		score += scoreRecentlyUsed
+	}
+	if query != "" && strings.Contains(strings.ToLower(query), strings.ToLower(bareToolName(tool.ToolName))) {
+		score += scoreExactName
+	}
+	return score
+}
+
 // SEARCH TOOL INJECTION
 
 // extractRecentlyUsedToolsParsed gets tool names from a pre-parsed request.
@@ -842,15 +928,19 @@ func (p *Pipe) extractRecentlyUsedToolsParsed(parsedAdapter adapters.ParsedReque
 func estimateToolTokens(tools []adapters.ExtractedContent) int {
 	total := 0
 	for _, t := range tools {
-		if raw, ok := t.Metadata["raw_json"].(string); ok && raw != "" {
-			total += tokenizer.CountTokens(raw)
-		} else {
-			total += tokenizer.CountTokens(t.Content)
-		}
+		total += toolTokens(t)
 	}
 	return total
 }
 
+// toolTokens returns the tiktoken count of one tool definition.
+func toolTokens(t adapters.ExtractedContent) int {
+	if raw, ok := t.Metadata["raw_json"].(string); ok && raw != "" {
+		return tokenizer.CountTokens(raw)
+	}
+	return tokenizer.CountTokens(t.Content)
+}
+
 // tokenize splits text into lowercase words, filtering short ones and stop words.
 func tokenize(text string) []string {
 	words := strings.FieldsFunc(text, func(r rune) bool {
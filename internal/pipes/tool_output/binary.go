@@ -0,0 +1,49 @@
+package tooloutput
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// routeBlobs replaces binary blobs in tool output (base64 screenshots, data:
+// URIs, raw bytes) with short placeholders so they never reach the text
+// compressor. Each blob is stored under its own shadow ID; with expand_context
+// enabled the placeholder names that ID so the model can retrieve the blob.
+// Placeholders are deterministic, keeping the rewritten output KV-cache stable.
+// Returns the rewritten content and the number of blobs routed.
+func (p *Pipe) routeBlobs(ctx *pipes.PipeContext, content string) (string, int) {
+	blobs := formats.FindBlobs(content)
+	if len(blobs) == 0 {
+		return content, 0
+	}
+
+	expandContext := p.expandContextFor(ctx)
+	var b strings.Builder
+	last := 0
+	for _, blob := range blobs {
+		raw := content[blob.Start:blob.End]
+		shadowID := p.contentHash(raw)
+		if p.store != nil {
+			_ = p.store.Set(shadowID, raw)
+		}
+
+		b.WriteString(content[last:blob.Start])
+		if expandContext {
+			fmt.Fprintf(&b, BinaryPlaceholderWithRefFormat, blob.MediaType, formats.FormatSize(blob.Size), shadowID)
+			ctx.ShadowRefs[shadowID] = raw
+		} else {
+			fmt.Fprintf(&b, BinaryPlaceholderFormat, blob.MediaType, formats.FormatSize(blob.Size))
+		}
+		last = blob.End
+	}
+	b.WriteString(content[last:])
+
+	p.mu.Lock()
+	p.metrics.BlobsRouted += int64(len(blobs))
+	p.mu.Unlock()
+
+	return b.String(), len(blobs)
+}
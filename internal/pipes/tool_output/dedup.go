@@ -0,0 +1,86 @@
+package tooloutput
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// latestCopies maps each repeated tool output's content hash to the index of
+// its last copy in extracted. Outputs seen once, outputs shorter than
+// dedup_min_bytes, and outputs the loop never rewrites (claimed by
+// task_output, already compressed, skip_tools) are left out.
+func (p *Pipe) latestCopies(ctx *pipes.PipeContext, extracted []adapters.ExtractedContent, skipSet map[string]bool) map[string]int {
+	if !p.dedup {
+		return nil
+	}
+	latest := make(map[string]int)
+	counts := make(map[string]int)
+	for i, ext := range extracted {
+		if len(ext.Content) < p.dedupMinBytes || strings.HasPrefix(ext.Content, ShadowPrefixMarker) || skipSet[ext.ToolName] {
+			continue
+		}
+		if _, claimed := ctx.TaskOutputHandledIDs[ext.ID]; claimed {
+			continue
+		}
+		hash := p.contentHash(ext.Content)
+		latest[hash] = i
+		counts[hash]++
+	}
+	for hash, n := range counts {
+		if n < 2 {
+			delete(latest, hash)
+		}
+	}
+	return latest
+}
+
+// dedupStub replaces an earlier copy of a repeated output with a stub naming
+// the latest copy. With expand_context, the stub carries the shared shadow ID
+// so the model can restore this copy. The stub depends only on the content
+// and the later call ID, keeping it stable until the output is repeated again.
+func (p *Pipe) dedupStub(ctx *pipes.PipeContext, ext adapters.ExtractedContent, shadowID string, later adapters.ExtractedContent, expandContext bool) adapters.CompressedResult {
+	tool := later.ToolName
+	if tool == "" {
+		tool = "tool"
+	}
+	var stub, shadowRef string
+	if expandContext && p.store != nil {
+		_ = p.store.Set(shadowID, ext.Content)
+		ctx.ShadowRefs[shadowID] = ext.Content
+		shadowRef = shadowID
+		stub = fmt.Sprintf(DedupStubWithRefFormat, tool, later.ID, shadowID)
+	} else {
+		stub = fmt.Sprintf(DedupStubFormat, tool, later.ID)
+	}
+
+	ctx.ToolOutputCompressions = append(ctx.ToolOutputCompressions, pipes.ToolOutputCompression{
+		ToolName:          ext.ToolName,
+		ToolCallID:        ext.ID,
+		ShadowID:          shadowRef,
+		OriginalContent:   ext.Content,
+		CompressedContent: stub,
+		OriginalTokens:    tokenizer.CountTokens(ext.Content),
+		CompressedTokens:  tokenizer.CountTokens(stub),
+		MappingStatus:     "deduplicated",
+		MinThreshold:      p.minTokens,
+		MaxThreshold:      p.maxTokens,
+		Model:             p.getEffectiveModel(),
+	})
+	ctx.OutputCompressed = true
+
+	p.mu.Lock()
+	p.metrics.Deduplicated++
+	p.mu.Unlock()
+
+	return adapters.CompressedResult{
+		ID:           ext.ID,
+		Compressed:   stub,
+		ShadowRef:    shadowRef,
+		MessageIndex: ext.MessageIndex,
+		BlockIndex:   ext.BlockIndex,
+	}
+}
@@ -0,0 +1,313 @@
+// Local compressor: deterministic, LLM-free tool output compression.
+//
+// Strategy: pick a reduction by content shape, then cut to the byte budget
+// derived from target_compression_ratio (default: keep half).
+//   - JSON: structure summary — long arrays keep their first items and a count,
+//     long strings are clipped, deep nesting is elided.
+//   - Unified diffs: file and hunk headers plus changed lines with one line of
+//     context; hunks past the budget are listed by header with +/- counts.
+//   - Everything else: runs of near-identical lines (differing only in numbers,
+//     timestamps or hex IDs) collapse to one line with a repeat count, then
+//     head/tail truncation keeps the start and end with an omitted-line count.
+//
+// Nothing leaves the process, so this is the strategy for privacy-sensitive
+// or air-gapped deployments.
+package tooloutput
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	localDefaultKeepRatio = 0.5
+	localMinBudget        = 256 // Never cut below this many bytes
+	localJSONArrayItems   = 3   // Array items kept before "… N more items"
+	localJSONStringChars  = 200 // Longer strings are clipped
+	localJSONMaxDepth     = 6   // Deeper values are elided
+	localDiffContext      = 1   // Context lines kept around each change
+)
+
+// compressLocal compresses content without any external call.
+func (p *Pipe) compressLocal(content string) string {
+	keepRatio := localDefaultKeepRatio
+	if r := p.targetCompressionRatio; r > 0 && r < 1 {
+		keepRatio = 1 - r
+	}
+	budget := int(float64(len(content)) * keepRatio)
+	if budget < localMinBudget {
+		budget = localMinBudget
+	}
+	if len(content) <= budget {
+		return content
+	}
+
+	trimmed := strings.TrimSpace(content)
+	switch {
+	case (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)):
+		if summary, ok := summarizeJSONContent(trimmed); ok {
+			return headTail(summary, budget)
+		}
+	case isUnifiedDiff(content):
+		return selectDiffHunks(content, budget)
+	}
+	return headTail(dedupeLines(content), budget)
+}
+
+// JSON STRUCTURE SUMMARY
+
+// summarizeJSONContent returns a compact JSON summary of data.
+func summarizeJSONContent(data string) (string, bool) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(summarizeJSON(v, 0)); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+func summarizeJSON(v any, depth int) any {
+	switch t := v.(type) {
+	case map[string]any:
+		if depth >= localJSONMaxDepth {
+			return fmt.Sprintf("{… %d keys}", len(t))
+		}
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = summarizeJSON(val, depth+1)
+		}
+		return out
+	case []any:
+		if depth >= localJSONMaxDepth {
+			return fmt.Sprintf("[… %d items]", len(t))
+		}
+		n := len(t)
+		if n > localJSONArrayItems {
+			n = localJSONArrayItems
+		}
+		out := make([]any, 0, n+1)
+		for _, item := range t[:n] {
+			out = append(out, summarizeJSON(item, depth+1))
+		}
+		if len(t) > n {
+			out = append(out, fmt.Sprintf("… %d more items", len(t)-n))
+		}
+		return out
+	case string:
+		if len(t) > localJSONStringChars {
+			return clipUTF8(t, localJSONStringChars) + fmt.Sprintf("… (%d chars)", len(t))
+		}
+		return t
+	default:
+		return t
+	}
+}
+
+// clipUTF8 cuts s to at most n bytes without splitting a rune.
+func clipUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && (s[n]&0xC0) == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// DIFF HUNK SELECTION
+
+// isUnifiedDiff reports whether content looks like a unified diff.
+func isUnifiedDiff(content string) bool {
+	return strings.Contains(content, "\n@@ ") &&
+		(strings.HasPrefix(content, "diff --git ") || strings.HasPrefix(content, "--- ") || strings.Contains(content, "\ndiff --git "))
+}
+
+type diffHunk struct {
+	header string   // @@ line
+	lines  []string // Kept lines, context already reduced
+	added  int
+	gone   int
+}
+
+type diffFile struct {
+	header []string // diff --git / index / --- / +++ lines
+	hunks  []diffHunk
+}
+
+// selectDiffHunks keeps headers and changed lines, reducing context, and
+// drops whole hunks past budget.
+func selectDiffHunks(content string, budget int) string {
+	var files []diffFile
+	var cur *diffFile
+	var hunk *diffHunk
+	var context []string // Unchanged lines since the last change
+
+	flush := func() {
+		if hunk == nil {
+			return
+		}
+		hunk.lines = append(hunk.lines, trailingContext(context)...)
+		cur.hunks = append(cur.hunks, *hunk)
+		hunk, context = nil, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		// A new file starts at "diff --git", or at a "---"/"+++" pair in plain
+		// diff -u output; a lone "---" inside a hunk is a removed line.
+		fileStart := strings.HasPrefix(line, "diff --git ") ||
+			(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") && (cur == nil || hunk != nil))
+		switch {
+		case fileStart || cur == nil:
+			flush()
+			files = append(files, diffFile{})
+			cur = &files[len(files)-1]
+			cur.header = append(cur.header, line)
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			hunk = &diffHunk{header: line}
+		case hunk == nil:
+			cur.header = append(cur.header, line)
+		case strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-"):
+			hunk.lines = append(hunk.lines, leadingContext(context)...)
+			context = nil
+			hunk.lines = append(hunk.lines, line)
+			if line[0] == '+' {
+				hunk.added++
+			} else {
+				hunk.gone++
+			}
+		default:
+			context = append(context, line)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	var omitted []string
+	for _, f := range files {
+		for _, h := range f.header {
+			b.WriteString(h)
+			b.WriteByte('\n')
+		}
+		for _, h := range f.hunks {
+			size := len(h.header) + 1
+			for _, l := range h.lines {
+				size += len(l) + 1
+			}
+			if b.Len()+size > budget {
+				omitted = append(omitted, fmt.Sprintf("%s (+%d/-%d)", h.header, h.added, h.gone))
+				continue
+			}
+			b.WriteString(h.header)
+			b.WriteByte('\n')
+			for _, l := range h.lines {
+				b.WriteString(l)
+				b.WriteByte('\n')
+			}
+		}
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&b, "[… %d hunks omitted:\n%s\n]", len(omitted), strings.Join(omitted, "\n"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// leadingContext reduces the context run before a change. The run follows the
+// hunk header or the previous change, so one line is kept on each side.
+func leadingContext(context []string) []string {
+	if len(context) <= 2*localDiffContext {
+		return context
+	}
+	kept := append([]string{}, context[:localDiffContext]...)
+	kept = append(kept, fmt.Sprintf(" … %d unchanged lines", len(context)-2*localDiffContext))
+	return append(kept, context[len(context)-localDiffContext:]...)
+}
+
+// trailingContext keeps the context lines just after the last change in a hunk.
+func trailingContext(context []string) []string {
+	if len(context) <= localDiffContext {
+		return context
+	}
+	kept := append([]string{}, context[:localDiffContext]...)
+	return append(kept, fmt.Sprintf(" … %d unchanged lines", len(context)-localDiffContext))
+}
+
+// LOG DEDUPLICATION
+
+// volatileRE matches the parts of log lines that change between repeats:
+// hex IDs, then numbers (timestamps, counters, durations).
+var volatileRE = regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b|\d+`)
+
+// dedupeLines collapses runs of lines that differ only in volatile parts.
+func dedupeLines(content string) string {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); {
+		key := volatileRE.ReplaceAllString(lines[i], "#")
+		j := i + 1
+		for j < len(lines) && volatileRE.ReplaceAllString(lines[j], "#") == key {
+			j++
+		}
+		switch n := j - i; {
+		case n == 1 || strings.TrimSpace(lines[i]) == "":
+			out = append(out, lines[i:j]...)
+		default:
+			out = append(out, fmt.Sprintf("%s [×%d similar lines]", lines[i], n))
+		}
+		i = j
+	}
+	return strings.Join(out, "\n")
+}
+
+// HEAD/TAIL TRUNCATION
+
+// headTail keeps whole lines from the start and end of content within budget.
+func headTail(content string, budget int) string {
+	if len(content) <= budget {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	if len(lines) < 3 {
+		head := clipUTF8(content, budget/2)
+		tailStart := len(content) - budget/2
+		for tailStart < len(content) && (content[tailStart]&0xC0) == 0x80 {
+			tailStart++
+		}
+		return head + fmt.Sprintf("\n[… %d chars omitted …]\n", tailStart-len(head)) + content[tailStart:]
+	}
+
+	headBudget, tailBudget := budget*3/5, budget*2/5
+	head, used := 0, 0
+	for head < len(lines) && used+len(lines[head])+1 <= headBudget {
+		used += len(lines[head]) + 1
+		head++
+	}
+	tail, used := 0, 0
+	for tail < len(lines)-head && used+len(lines[len(lines)-1-tail])+1 <= tailBudget {
+		used += len(lines[len(lines)-1-tail]) + 1
+		tail++
+	}
+	if head == 0 && tail == 0 {
+		head = 1
+	}
+	omitted := len(lines) - head - tail
+	if omitted <= 0 {
+		return content
+	}
+	var b strings.Builder
+	b.WriteString(strings.Join(lines[:head], "\n"))
+	fmt.Fprintf(&b, "\n[… %d of %d lines omitted …]\n", omitted, len(lines))
+	b.WriteString(strings.Join(lines[len(lines)-tail:], "\n"))
+	return b.String()
+}
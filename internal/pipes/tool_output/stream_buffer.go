@@ -4,7 +4,6 @@ package tooloutput
 import (
 	"bytes"
 	"encoding/json"
-	"strings"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -196,9 +195,10 @@ func (sb *StreamBuffer) extractShadowID(partialJSON string) {
 	var input map[string]any
 	if err := json.Unmarshal(sb.buffer.Bytes(), &input); err == nil {
 		if id, ok := input["id"].(string); ok {
-			// Update the last suppressed call with the shadow ID
+			// Update the last suppressed call with the shadow ID and paging arguments
 			if len(sb.suppressedCalls) > 0 {
 				sb.suppressedCalls[len(sb.suppressedCalls)-1].ShadowID = id
+				sb.suppressedCalls[len(sb.suppressedCalls)-1].Input = input
 			}
 		}
 	}
@@ -251,6 +251,9 @@ func (sb *StreamBuffer) filterOpenAIToolCalls(choices []any) bool {
 					ToolUseID: id,
 					ShadowID:  "",
 				})
+				if args, ok := fn["arguments"].(string); ok && args != "" {
+					sb.extractShadowID(args)
+				}
 				log.Debug().
 					Str("tool_id", id).
 					Msg("stream_buffer: suppressing expand_context tool (OpenAI)")
@@ -264,13 +267,8 @@ func (sb *StreamBuffer) filterOpenAIToolCalls(choices []any) bool {
 
 			// Check if this is an arguments delta for a suppressed expand_context
 			if sb.openAIInToolUse {
-				if args, ok := fn["arguments"].(string); ok && strings.Contains(args, "shadow_") {
-					var input map[string]string
-					if err := json.Unmarshal([]byte(args), &input); err == nil {
-						if shadowID, ok := input["id"]; ok && len(sb.suppressedCalls) > 0 {
-							sb.suppressedCalls[len(sb.suppressedCalls)-1].ShadowID = shadowID
-						}
-					}
+				if args, ok := fn["arguments"].(string); ok {
+					sb.extractShadowID(args)
 				}
 				return true // Suppress this chunk
 			}
@@ -0,0 +1,77 @@
+package tooloutput
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+// thumbnailImages downscales base64 images in tool results so their longest
+// side is at most imageMaxDimension. Results are cached by image hash, so the
+// same screenshot is rewritten to the same bytes on every turn (KV-cache safe).
+func (p *Pipe) thumbnailImages(ctx *pipes.PipeContext, body []byte) []byte {
+	imageAdapter, ok := ctx.Adapter.(adapters.ToolImageAdapter)
+	if !ok {
+		return body
+	}
+
+	var resized []adapters.ToolImage
+	savedBytes := 0
+	for _, img := range imageAdapter.ExtractToolImages(body) {
+		thumb, ok := p.cachedThumbnail(img)
+		if !ok {
+			continue
+		}
+		savedBytes += len(img.Data) - len(thumb)
+		img.Data = thumb
+		resized = append(resized, img)
+	}
+	if len(resized) == 0 {
+		return body
+	}
+
+	modified, err := imageAdapter.ApplyToolImages(body, resized)
+	if err != nil {
+		log.Warn().Err(err).Msg("tool_output: failed to apply thumbnails, forwarding original images")
+		return body
+	}
+
+	p.mu.Lock()
+	p.metrics.ImagesResized += int64(len(resized))
+	p.mu.Unlock()
+
+	log.Info().
+		Int("images", len(resized)).
+		Int("max_dimension", p.imageMaxDimension).
+		Int("saved_bytes", savedBytes).
+		Msg("tool_output: downscaled tool result images")
+	return modified
+}
+
+// cachedThumbnail returns the downscaled image, or false when the image is
+// kept as is (already small, unsupported format, or no size gain).
+func (p *Pipe) cachedThumbnail(img adapters.ToolImage) ([]byte, bool) {
+	hash := sha256.Sum256(img.Data)
+	key := fmt.Sprintf("%s%s_%d", ImageCachePrefix, hex.EncodeToString(hash[:16]), p.imageMaxDimension)
+	if p.store != nil {
+		if cached, ok := p.store.GetCompressed(key); ok {
+			return []byte(cached), cached != ""
+		}
+	}
+
+	thumb, err := formats.Thumbnail(img.Data, p.imageMaxDimension)
+	if err != nil {
+		log.Debug().Err(err).Str("tool_call_id", img.ToolCallID).Msg("tool_output: image not thumbnailed")
+	}
+	if p.store != nil {
+		// An empty entry records "keep original" so the image is not decoded again.
+		_ = p.store.SetCompressed(key, string(thumb))
+	}
+	return thumb, thumb != nil
+}
@@ -16,6 +16,7 @@ import (
 	authtypes "github.com/compresr/context-gateway/internal/auth/types"
 	"github.com/compresr/context-gateway/internal/compresr"
 	"github.com/compresr/context-gateway/internal/config"
+	phantom_tools "github.com/compresr/context-gateway/internal/phantom_tools"
 	"github.com/compresr/context-gateway/internal/pipes"
 	"github.com/compresr/context-gateway/internal/tokenizer"
 )
@@ -46,7 +47,13 @@ func (p *Pipe) Process(ctx *pipes.PipeContext) ([]byte, error) {
 		return ctx.OriginalRequest, nil
 	}
 
-	return p.compressAllTools(ctx)
+	body, err := p.compressAllTools(ctx)
+	if err != nil || p.imageMaxDimension <= 0 {
+		return body, err
+	}
+	// Downscale tool-result images last: applying compressed text may
+	// reshape content arrays, so image locations come from the final body.
+	return p.thumbnailImages(ctx, body), nil
 }
 
 // compressAllTools compresses new tool outputs in the request.
@@ -74,6 +81,8 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 		return ctx.OriginalRequest, nil
 	}
 
+	expandContext := p.expandContextFor(ctx)
+
 	// Determine query for compression context:
 	// - Query-agnostic models (LLM/cmprsr): don't need user query, use empty string
 	// - Query-dependent models (reranker): need query for relevance scoring
@@ -120,10 +129,17 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 	tasks := make([]compressionTask, 0, len(extracted))
 	var results []adapters.CompressedResult
 
+	// Outputs with binary blobs routed out; these are rewritten even when the
+	// remaining text is not compressed.
+	var routed []adapters.CompressedResult
+
 	// Resolve skip_tools categories to provider-specific tool names
 	skipSet := BuildSkipSet(p.skipCategories, ctx.Provider)
 
-	for _, ext := range extracted {
+	// Repeated outputs: only the latest copy goes through compression.
+	latest := p.latestCopies(ctx, extracted, skipSet)
+
+	for i, ext := range extracted {
 		// Skip items already claimed by the task_output pipe.
 		// task_output runs before tool_output and populates TaskOutputHandledIDs
 		// so subagent results are not double-processed.
@@ -181,6 +197,34 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 			continue
 		}
 
+		// Replace earlier copies of a repeated output with a stub.
+		if latest != nil {
+			hash := p.contentHash(ext.Content)
+			if last, ok := latest[hash]; ok && last != i {
+				results = append(results, p.dedupStub(ctx, ext, hash, extracted[last], expandContext))
+				continue
+			}
+		}
+
+		// Route embedded binary (base64 images, data: URIs) to shadow refs.
+		// Only the surrounding text continues to the compressor.
+		if stripped, n := p.routeBlobs(ctx, ext.Content); n > 0 {
+			log.Debug().
+				Str("tool", ext.ToolName).
+				Int("blobs", n).
+				Int("original_bytes", len(ext.Content)).
+				Int("stripped_bytes", len(stripped)).
+				Msg("tool_output: routed binary blobs to shadow refs")
+			ext.Content = stripped
+			ext.Format = adapters.DetectContentFormat(stripped)
+			routed = append(routed, adapters.CompressedResult{
+				ID:           ext.ID,
+				Compressed:   stripped,
+				MessageIndex: ext.MessageIndex,
+				BlockIndex:   ext.BlockIndex,
+			})
+		}
+
 		// Skip if content format is not in the effective compressible set.
 		// Format is detected by the adapter during extraction (DetectContentFormat).
 		// FormatUnknown (empty/unclassifiable content) always passthroughs.
@@ -254,13 +298,13 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 				log.Info().
 					Str("shadow_id", shadowID[:min(16, len(shadowID))]).
 					Str("tool", ext.ToolName).
-					Bool("expand_context_enabled", p.enableExpandContext).
+					Bool("expand_context_enabled", expandContext).
 					Msg("tool_output: cache HIT, using compressed")
 
 				// Build content: prefixed with shadow ID if expand_context enabled, raw otherwise
 				var cachedFinalContent string
 				var cachedShadowRef string
-				if p.enableExpandContext {
+				if expandContext {
 					// Full expand_context mode: prefix with shadow ID for retrieval
 					if p.includeExpandHint {
 						cachedFinalContent = fmt.Sprintf(PrefixFormatWithHint, shadowID, shadowID, cachedCompressed)
@@ -418,7 +462,7 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 			// Build content: prefixed with shadow ID if expand_context enabled, raw otherwise
 			var finalContent string
 			var shadowRef string
-			if p.enableExpandContext {
+			if expandContext {
 				// Full expand_context mode: prefix with shadow ID for retrieval
 				if p.includeExpandHint {
 					finalContent = fmt.Sprintf(PrefixFormatWithHint, result.shadowID, result.shadowID, result.compressedContent)
@@ -464,7 +508,7 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 				Str("strategy", p.strategy).
 				Int("original_tokens", origTokens).
 				Int("compressed_tokens", compTokens).
-				Bool("expand_context_enabled", p.enableExpandContext).
+				Bool("expand_context_enabled", expandContext).
 				Str("shadow_id", shadowRef).
 				Str("tool", result.toolName).
 				Msg("tool_output: compressed successfully")
@@ -478,6 +522,13 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 		ctx.ToolOutputCompressions[i].QueryAgnostic = isQueryAgnostic
 	}
 
+	// Routed outputs that were not compressed still go out without their blobs.
+	for _, r := range routed {
+		if !hasResultAt(results, r.MessageIndex, r.BlockIndex) {
+			results = append(results, r)
+		}
+	}
+
 	// Apply all compressed results back to the request body
 	if len(results) > 0 {
 		modifiedBody, err := ctx.Adapter.ApplyToolOutput(ctx.OriginalRequest, results)
@@ -491,6 +542,16 @@ func (p *Pipe) compressAllTools(ctx *pipes.PipeContext) ([]byte, error) {
 	return ctx.OriginalRequest, nil
 }
 
+// hasResultAt reports whether results already rewrites the tool output at the given position.
+func hasResultAt(results []adapters.CompressedResult, messageIndex, blockIndex int) bool {
+	for _, r := range results {
+		if r.MessageIndex == messageIndex && r.BlockIndex == blockIndex {
+			return true
+		}
+	}
+	return false
+}
+
 // compressBatch processes compression tasks with rate limiting (V2: C11).
 func (p *Pipe) compressBatch(reqCtx context.Context, query, provider string, auth authtypes.CapturedAuth, tasks []compressionTask) <-chan compressionResult {
 	results := make(chan compressionResult, len(tasks))
@@ -597,6 +658,10 @@ func (p *Pipe) compressOne(reqCtx context.Context, query, provider string, auth
 		// Tail-keep compression: discard head, keep only tail based on target_compression_ratio
 		compressed = p.compressTrimming(t.original)
 		err = nil
+	case config.StrategyLocal:
+		// Shape-aware compression without any external call
+		compressed = p.compressLocal(t.original)
+		err = nil
 	default:
 		return compressionResult{index: t.index, success: false, err: fmt.Errorf("unknown strategy: %s", p.strategy), messageIndex: t.messageIndex, blockIndex: t.blockIndex}
 	}
@@ -645,6 +710,15 @@ func (p *Pipe) compressOne(reqCtx context.Context, query, provider string, auth
 	}
 }
 
+// expandContextFor reports whether compressed outputs of this request get
+// shadow references. Without an expand_context definition for the request's
+// format (Gemini), or for a detached request, the model can't expand them,
+// so none are handed out.
+func (p *Pipe) expandContextFor(ctx *pipes.PipeContext) bool {
+	return p.enableExpandContext && !ctx.Detached &&
+		phantom_tools.Injectable(phantom_tools.ExpandContextToolName, ctx.OriginalRequest, ctx.Provider)
+}
+
 // contentHash generates a deterministic shadow ID from content.
 // V2: SHA256(normalize(original)) for consistency (E22)
 func (p *Pipe) contentHash(content string) string {
@@ -777,6 +851,25 @@ func (p *Pipe) compressViaExternalProvider(reqCtx context.Context, query, conten
 		userPrompt = external.UserPromptQuerySpecific(query, toolName, content)
 	}
 
+	if p.promptTemplates != nil {
+		vars := pipes.PromptVars{
+			ToolName:    toolName,
+			TargetRatio: p.targetCompressionRatio,
+			Format:      structuredFormat,
+			Content:     content,
+		}
+		if !p.compresrQueryAgnostic {
+			vars.Query = query
+		}
+		if vars.TargetRatio == 0 {
+			vars.TargetRatio = config.DefaultTargetCompressionRatio
+		}
+		var err error
+		if systemPrompt, userPrompt, err = p.promptTemplates.Render(vars, systemPrompt, userPrompt); err != nil {
+			return "", err
+		}
+	}
+
 	// Auto-calculate max tokens: allow at most half the input token count as output
 	maxTokens := tokenizer.CountTokens(content) / 2
 	if maxTokens < 256 {
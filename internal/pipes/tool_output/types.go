@@ -54,6 +54,25 @@ const (
 
 	// StructuredSeparator separates verbatim prefix from compressed tail.
 	StructuredSeparator = "--- COMPRESSED SUMMARY (above is verbatim) ---"
+
+	// BinaryPlaceholderFormat replaces a binary blob in tool output (media type, size).
+	BinaryPlaceholderFormat = "[binary omitted: %s, %s]"
+
+	// BinaryPlaceholderWithRefFormat replaces a binary blob retrievable via expand_context.
+	BinaryPlaceholderWithRefFormat = "[binary omitted: %s, %s — call expand_context(id=\"%s\") to retrieve]"
+
+	// ImageCachePrefix keys cached thumbnails in the compressed store.
+	ImageCachePrefix = "image_"
+
+	// DedupStubFormat replaces an earlier copy of a repeated output (tool, call ID).
+	DedupStubFormat = "[identical to later %s result %s]"
+
+	// DedupStubWithRefFormat replaces an earlier copy retrievable via expand_context.
+	DedupStubWithRefFormat = "[identical to later %s result %s — call expand_context(id=\"%s\") to restore this copy]"
+
+	// DefaultDedupMinBytes is the shortest output deduplicated; stubs for
+	// shorter outputs would save little.
+	DefaultDedupMinBytes = 256
 )
 
 // Pipe compresses tool outputs dynamically and stores raw data for retrieval.
@@ -68,6 +87,9 @@ type Pipe struct {
 	includeExpandHint      bool
 	enableExpandContext    bool
 	bypassCostCheck        bool
+	imageMaxDimension      int
+	dedup                  bool
+	dedupMinBytes          int
 	store                  store.Store
 
 	compresrClient *compresr.Client
@@ -77,6 +99,7 @@ type Pipe struct {
 	compresrModel         string
 	compresrTimeout       time.Duration
 	compresrQueryAgnostic bool
+	promptTemplates       *pipes.PromptTemplates // nil = built-in prompts
 
 	maxConcurrent int
 	maxPerSecond  int
@@ -104,6 +127,9 @@ type Metrics struct {
 	ExpandCacheMiss int64
 	RateLimited     int64
 	TokensSaved     int64
+	BlobsRouted     int64
+	ImagesResized   int64
+	Deduplicated    int64
 }
 
 // RateLimiter implements token bucket rate limiting.
@@ -232,6 +258,11 @@ func New(cfg *config.Config, st store.Store) *Pipe {
 		cfg.Pipes.ToolOutput.ContentFormats.Forbidden,
 	)
 
+	dedupMinBytes := cfg.Pipes.ToolOutput.DedupMinBytes
+	if dedupMinBytes == 0 {
+		dedupMinBytes = DefaultDedupMinBytes
+	}
+
 	compresrTimeout := cfg.Pipes.ToolOutput.Compresr.Timeout
 	if compresrTimeout == 0 {
 		compresrTimeout = 30 * time.Second
@@ -248,6 +279,9 @@ func New(cfg *config.Config, st store.Store) *Pipe {
 		includeExpandHint:      cfg.Pipes.ToolOutput.IncludeExpandHint || cfg.Pipes.ToolOutput.EnableExpandContext,
 		enableExpandContext:    cfg.Pipes.ToolOutput.EnableExpandContext,
 		bypassCostCheck:        cfg.Pipes.ToolOutput.BypassCostCheck,
+		imageMaxDimension:      cfg.Pipes.ToolOutput.ImageMaxDimension,
+		dedup:                  cfg.Pipes.ToolOutput.Dedup,
+		dedupMinBytes:          dedupMinBytes,
 		store:                  st,
 
 		compresrEndpoint:      compresrEndpoint,
@@ -272,6 +306,15 @@ func New(cfg *config.Config, st store.Store) *Pipe {
 		log.Info().Str("base_url", baseURL).Str("model", compresrModel).Dur("timeout", compresrTimeout).Msg("tool_output: initialized Compresr client for compresr strategy")
 	}
 
+	if tmpl := cfg.Pipes.ToolOutput.PromptTemplate; tmpl.IsSet() {
+		// Validated at load time; a failure here means the config bypassed Validate.
+		if compiled, err := tmpl.Compile(); err != nil {
+			log.Error().Err(err).Msg("tool_output: invalid prompt_template, using built-in prompts")
+		} else {
+			p.promptTemplates = compiled
+		}
+	}
+
 	if p.compresrKey == "" && cfg.Pipes.ToolOutput.Strategy == config.StrategyExternalProvider {
 		log.Info().Msg("tool_output: no API key configured, will use captured Bearer token from incoming requests")
 	}
@@ -354,4 +397,14 @@ type compressionResult struct {
 type ExpandContextCall struct {
 	ToolUseID string
 	ShadowID  string
+	Input     map[string]any // Full tool input, including paging arguments
+}
+
+// Arguments returns the call's tool input, or just the ID when the input
+// was not captured.
+func (c ExpandContextCall) Arguments() map[string]any {
+	if c.Input != nil {
+		return c.Input
+	}
+	return map[string]any{"id": c.ShadowID}
 }
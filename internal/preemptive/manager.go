@@ -3,13 +3,18 @@ package preemptive
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/compresr/context-gateway/internal/adapters"
 	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/branching"
+	"github.com/compresr/context-gateway/internal/tenancy"
 	"github.com/compresr/context-gateway/internal/tokenizer"
 
 	"github.com/rs/zerolog/log"
@@ -36,6 +41,7 @@ type Manager struct {
 	mu       sync.RWMutex
 	config   Config
 	sessions *SessionManager
+	branches *branching.Tracker
 	summary  *Summarizer
 	worker   *Worker
 	enabled  bool
@@ -52,6 +58,7 @@ func NewManager(cfg Config) *Manager {
 	}
 
 	m.sessions = NewSessionManager(cfg.Session)
+	m.branches = branching.NewTracker(cfg.Session.SummaryTTL)
 	m.summary = NewSummarizer(cfg.Summarizer)
 	m.worker = NewWorker(m.summary, m.sessions, cfg.Summarizer, cfg.TriggerThreshold)
 	m.worker.Start()
@@ -70,6 +77,7 @@ func (m *Manager) UpdateConfig(cfg Config) {
 	m.mu.RLock()
 	oldWorker := m.worker
 	existingSessions := m.sessions
+	existingBranches := m.branches
 	m.mu.RUnlock()
 
 	if oldWorker != nil {
@@ -84,6 +92,9 @@ func (m *Manager) UpdateConfig(cfg Config) {
 		if existingSessions == nil {
 			existingSessions = NewSessionManager(cfg.Session)
 		}
+		if existingBranches == nil {
+			existingBranches = branching.NewTracker(cfg.Session.SummaryTTL)
+		}
 		newSummary := NewSummarizer(cfg.Summarizer)
 		newWorker = NewWorker(newSummary, existingSessions, cfg.Summarizer, cfg.TriggerThreshold)
 		newWorker.Start()
@@ -102,6 +113,7 @@ func (m *Manager) UpdateConfig(cfg Config) {
 	m.enabled = cfg.Enabled
 	if newWorker != nil {
 		m.sessions = newWorker.sessions
+		m.branches = existingBranches
 		m.summary = newWorker.summarizer
 	} else {
 		m.summary = nil // clear stale summarizer reference when disabling
@@ -113,10 +125,14 @@ func (m *Manager) UpdateConfig(cfg Config) {
 func (m *Manager) Stop() {
 	m.mu.RLock()
 	worker := m.worker
+	branches := m.branches // snapshot branch tracker alongside sessions
 	m.mu.RUnlock()
 	if worker != nil {
 		worker.Stop()
 	}
+	if branches != nil {
+		branches.Stop()
+	}
 }
 
 // SetAuth passes captured auth credentials to the summarizer.
@@ -130,6 +146,56 @@ func (m *Manager) SetAuth(auth authtypes.CapturedAuth) {
 	}
 }
 
+// SummarizerAuth returns the summarizer's credential source (see
+// Summarizer.AuthSource). enabled is false when summarization is off.
+func (m *Manager) SummarizerAuth() (source string, enabled bool) {
+	m.mu.RLock()
+	summary := m.summary
+	enabled = m.enabled
+	m.mu.RUnlock()
+	if !enabled || summary == nil {
+		return "", false
+	}
+	return summary.AuthSource(), true
+}
+
+// ErrDisabled is returned by Summarize when preemptive summarization is off.
+var ErrDisabled = errors.New("preemptive summarization is disabled")
+
+// Summarize summarizes the older part of a request body's messages on demand,
+// using the configured summarizer and keep-recent settings. keepRecentTokens
+// overrides the configured value when > 0. The result is not cached in any
+// session.
+func (m *Manager) Summarize(ctx context.Context, body []byte, model string, keepRecentTokens int, auth authtypes.CapturedAuth) (*SummarizeOutput, error) {
+	m.mu.RLock()
+	enabled := m.enabled
+	cfg := m.config
+	summary := m.summary
+	m.mu.RUnlock()
+	if !enabled || summary == nil {
+		return nil, ErrDisabled
+	}
+
+	messages, err := ParseMessages(body)
+	if err != nil || len(messages) == 0 {
+		return nil, fmt.Errorf("no messages")
+	}
+	if keepRecentTokens <= 0 {
+		keepRecentTokens = cfg.Summarizer.KeepRecentTokens
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.SyncTimeout)
+	defer cancel()
+	return summary.Summarize(ctx, SummarizeInput{
+		Messages:         messages,
+		TriggerThreshold: cfg.TriggerThreshold,
+		KeepRecentTokens: keepRecentTokens,
+		KeepRecentCount:  cfg.Summarizer.KeepRecentCount,
+		Model:            model,
+		Auth:             auth,
+	})
+}
+
 // ProcessRequest handles an incoming request.
 // Returns: (modifiedBody, isCompaction, syntheticResponse, headers, error)
 func (m *Manager) ProcessRequest(ctx context.Context, headers http.Header, body []byte, model, provider string) ([]byte, bool, []byte, map[string]string, error) {
@@ -137,6 +203,7 @@ func (m *Manager) ProcessRequest(ctx context.Context, headers http.Header, body
 	enabled := m.enabled
 	cfg := m.config        // snapshot config while holding lock — avoids races in sub-functions
 	sessions := m.sessions // snapshot sessions pointer — UpdateConfig may replace it
+	branches := m.branches // snapshot branch tracker alongside sessions
 	summary := m.summary   // snapshot summarizer to avoid race with UpdateConfig
 	worker := m.worker     // snapshot worker to avoid race with UpdateConfig
 	m.mu.RUnlock()
@@ -144,7 +211,7 @@ func (m *Manager) ProcessRequest(ctx context.Context, headers http.Header, body
 		return body, false, nil, nil, nil
 	}
 
-	req, err := m.parseRequest(headers, body, model, provider, cfg, sessions)
+	req, err := m.parseRequest(tenancy.FromContext(ctx), headers, body, model, provider, cfg, sessions, branches)
 	if err != nil {
 		return body, false, nil, nil, nil
 	}
@@ -156,8 +223,9 @@ func (m *Manager) ProcessRequest(ctx context.Context, headers http.Header, body
 	return m.handleNormalRequest(req, body, cfg, sessions)
 }
 
-// parseRequest parses and validates the incoming request.
-func (m *Manager) parseRequest(headers http.Header, body []byte, model, providerName string, cfg Config, sessions *SessionManager) (*request, error) {
+// parseRequest parses and validates the incoming request. Session IDs are
+// scoped to tenant, so tenants never share or fuzzy-match sessions.
+func (m *Manager) parseRequest(tenant *tenancy.Tenant, headers http.Header, body []byte, model, providerName string, cfg Config, sessions *SessionManager, branches *branching.Tracker) (*request, error) {
 	messages, err := ParseMessages(body)
 	if err != nil || len(messages) == 0 {
 		return nil, fmt.Errorf("no messages")
@@ -174,9 +242,10 @@ func (m *Manager) parseRequest(headers http.Header, body []byte, model, provider
 
 	// LEVEL 0: Explicit X-Session-ID header (most reliable - client provides)
 	if rawID := headers.Get("X-Session-ID"); rawID != "" {
-		sanitized := sanitizeSessionID(rawID)
+		// Clients echo the X-Session-ID response header, which carries the tenant prefix
+		sanitized := sanitizeSessionID(strings.TrimPrefix(rawID, tenant.SessionPrefix()))
 		if sanitized != "" {
-			sessionID = sanitized
+			sessionID = tenant.SessionID(sanitized)
 			sessionSource = "explicit_header"
 			log.Debug().Str("session_id", sessionID).Msg("Session ID from X-Session-ID header")
 		} else {
@@ -186,18 +255,36 @@ func (m *Manager) parseRequest(headers http.Header, body []byte, model, provider
 
 	// LEVEL 1: Hash first USER message (most stable approach)
 	if sessionID == "" {
-		sessionID = sessions.GenerateSessionID(messages)
+		sessionID = tenant.SessionID(sessions.GenerateSessionID(messages))
 		if sessionID != "" {
 			sessionSource = "first_user_message_hash"
 			log.Debug().Str("session_id", sessionID).Msg("Session ID from first user message hash")
 		}
 	}
 
+	// BRANCHING: an edited earlier message starts a branch with its own session,
+	// inheriting the parent's summary only if it covers shared ancestry.
+	if sessionID != "" && !cfg.Session.DisableBranching {
+		branch := branches.Resolve(sessionID, branching.PrefixHashes(messages))
+		if branch.Forked {
+			sessions.ForkSession(branch.ParentSessionID, branch.SessionID, branch.ForkIndex)
+			log.Info().
+				Str("parent_session", branch.ParentSessionID).
+				Str("session_id", branch.SessionID).
+				Int("fork_index", branch.ForkIndex).
+				Msg("Conversation branched")
+		}
+		if branch.EvictedSessionID != "" {
+			sessions.Expire(branch.EvictedSessionID)
+		}
+		sessionID = branch.SessionID
+	}
+
 	// LEVEL 2: Fuzzy matching (for subagents or when user message not found)
 	if sessionID == "" && !cfg.Session.DisableFuzzyMatching {
 		log.Info().Int("message_count", len(messages)).Msg("No user message found, attempting fuzzy match")
 
-		if match := sessions.FindBestMatchingSession(len(messages), model, ""); match != nil {
+		if match := sessions.FindBestMatchingSession(len(messages), model, "", tenancy.NameOf(tenant)); match != nil {
 			sessionID = match.Session.ID
 			sessionSource = "fuzzy_match"
 			log.Info().
@@ -210,7 +297,7 @@ func (m *Manager) parseRequest(headers http.Header, body []byte, model, provider
 
 	// LEVEL 3: Legacy hash fallback
 	if sessionID == "" {
-		sessionID = sessions.GenerateSessionIDLegacy(messages)
+		sessionID = tenant.SessionID(sessions.GenerateSessionIDLegacy(messages))
 		sessionSource = "legacy_hash"
 		log.Debug().Str("session_id", sessionID).Msg("Fallback to legacy hash")
 	}
@@ -254,7 +341,7 @@ func (m *Manager) parseRequest(headers http.Header, body []byte, model, provider
 				Str("source", sessionSource).
 				Msg("Compaction request: session has no ready summary, trying fuzzy match")
 
-			if match := sessions.FindBestMatchingSession(len(messages), model, sessionID); match != nil {
+			if match := sessions.FindBestMatchingSession(len(messages), model, sessionID, tenancy.NameOf(tenant)); match != nil {
 				log.Info().
 					Str("original_id", sessionID).
 					Str("matched_id", match.Session.ID).
@@ -283,7 +370,7 @@ func (m *Manager) parseRequest(headers http.Header, body []byte, model, provider
 
 // handleNormalRequest processes a non-compaction request.
 func (m *Manager) handleNormalRequest(req *request, body []byte, cfg Config, sessions *SessionManager) ([]byte, bool, []byte, map[string]string, error) {
-	effectiveMax := getEffectiveMax(req.model, cfg)
+	effectiveMax := EffectiveMax(req.model, cfg)
 	session := sessions.GetOrCreateSession(req.sessionID, req.model, effectiveMax)
 
 	// Update usage tracking
@@ -393,14 +480,22 @@ func (m *Manager) doSynchronous(ctx context.Context, req *request, cfg Config, s
 	ctx, cancel := context.WithTimeout(ctx, cfg.SyncTimeout)
 	defer cancel()
 
-	result, err := summary.Summarize(ctx, SummarizeInput{
+	input := SummarizeInput{
 		Messages:         req.messages,
 		TriggerThreshold: cfg.TriggerThreshold,
 		KeepRecentTokens: cfg.Summarizer.KeepRecentTokens,
 		KeepRecentCount:  cfg.Summarizer.KeepRecentCount,
 		Model:            req.model,
 		Auth:             req.auth,
-	})
+	}
+	// A summary left by a timed-out background job still covers the start
+	// of the conversation; extend it instead of starting over.
+	if !cfg.Summarizer.DisableRolling {
+		if prev, prevIndex, ok := rollingBase(sessions.Get(req.sessionID), req.messages); ok {
+			input.PreviousSummary, input.PreviousIndex = prev, prevIndex
+		}
+	}
+	result, err := summary.Summarize(ctx, input)
 	if err != nil {
 		logError(req.sessionID, err)
 		return nil, fmt.Errorf("summarization failed: %w", err)
@@ -463,15 +558,30 @@ func (m *Manager) triggerIfNeeded(session *Session, req *request, usage float64)
 		return
 	}
 
-	// Only trigger if idle (no summary exists or summary was already used)
-	// - StatePending: already summarizing, wait
-	// - StateReady: summary exists and hasn't been used yet, keep it
-	// - StateIdle: no summary, trigger one
-	if session.State != StateIdle {
+	if worker == nil {
 		return
 	}
 
-	if worker == nil {
+	// - StatePending: already summarizing, wait
+	// - StateReady/StateUsed: keep the summary unless compacting with it would
+	//   still be over the threshold; then roll it forward
+	// - StateIdle: no summary, trigger one
+	switch session.State {
+	case StateIdle:
+	case StateReady, StateUsed:
+		if summarizerCfg.DisableRolling {
+			return
+		}
+		prev, prevIndex, ok := rollingBase(session, req.messages)
+		if !ok || CalculateUsage(compactedTokens(session.SummaryTokens, req.messages[prevIndex+1:]), session.MaxContextTokens).UsagePercent < threshold {
+			return
+		}
+		log.Info().Str("session", req.sessionID).Float64("usage", usage).Int("summary_covers", prevIndex+1).Int("messages", len(req.messages)).Msg("Triggering rolling summarization")
+		summModel, summProvider := summarizerCfg.EffectiveModelAndProvider()
+		logPreemptiveTrigger(req.sessionID, req.model, len(req.messages), usage, threshold, summProvider, summModel)
+		worker.SubmitRolling(req.sessionID, req.messages, req.model, req.auth, prev, prevIndex)
+		return
+	default:
 		return
 	}
 
@@ -482,7 +592,31 @@ func (m *Manager) triggerIfNeeded(session *Session, req *request, usage float64)
 	worker.Submit(req.sessionID, req.messages, req.model, req.auth)
 }
 
-func getEffectiveMax(model string, cfg Config) int {
+// rollingBase returns the session's summary to extend with newer messages,
+// if it covers a strict prefix of messages.
+func rollingBase(session *Session, messages []json.RawMessage) (summary string, lastIndex int, ok bool) {
+	if session == nil || session.Summary == "" || (session.State != StateReady && session.State != StateUsed && session.State != StatePending) {
+		return "", 0, false
+	}
+	if session.SummaryMessageIndex >= len(messages)-1 {
+		return "", 0, false
+	}
+	return session.Summary, session.SummaryMessageIndex, true
+}
+
+// compactedTokens estimates a compacted request: the summary plus the
+// messages after it.
+func compactedTokens(summaryTokens int, tail []json.RawMessage) int {
+	total := summaryTokens
+	for _, m := range tail {
+		total += tokenizer.CountBytes(m)
+	}
+	return total
+}
+
+// EffectiveMax returns the input-token budget usage is measured against:
+// the model's window minus its output reservation, or the test override.
+func EffectiveMax(model string, cfg Config) int {
 	if cfg.TestContextWindowOverride > 0 {
 		return cfg.TestContextWindowOverride
 	}
@@ -510,6 +644,86 @@ func buildHeaders(session *Session, usage TokenUsage, cfg Config) map[string]str
 	return headers
 }
 
+// SessionCount returns the number of tracked sessions (0 when disabled).
+func (m *Manager) SessionCount() int {
+	m.mu.RLock()
+	sessions := m.sessions
+	m.mu.RUnlock()
+	if sessions == nil {
+		return 0
+	}
+	return sessions.Len()
+}
+
+// Session returns a copy of a tracked session (false when unknown or disabled).
+func (m *Manager) Session(sessionID string) (Session, bool) {
+	m.mu.RLock()
+	sessions := m.sessions
+	m.mu.RUnlock()
+	if sessions == nil {
+		return Session{}, false
+	}
+	sessions.mu.RLock()
+	defer sessions.mu.RUnlock()
+	s, ok := sessions.sessions[sessionID]
+	if !ok {
+		return Session{}, false
+	}
+	snap := *s
+	snap.element = nil
+	return snap, true
+}
+
+// RootSessionID returns the session ID a request starts from: the X-Session-ID
+// header, else the hash of its first user message, scoped to tenant.
+// Branches and fuzzy matches are not resolved.
+func RootSessionID(tenant *tenancy.Tenant, headers http.Header, body []byte) string {
+	if id := sanitizeSessionID(strings.TrimPrefix(headers.Get("X-Session-ID"), tenant.SessionPrefix())); id != "" {
+		return tenant.SessionID(id)
+	}
+	messages, err := ParseMessages(body)
+	if err != nil {
+		return ""
+	}
+	return tenant.SessionID(firstUserMessageSessionID(messages))
+}
+
+// Sweep removes idle sessions and branch trees and returns how many sessions
+// were removed (sessionstore.Sweeper). The session manager also sweeps on its
+// own schedule; this lets the gateway's collector count evictions.
+func (m *Manager) Sweep() int {
+	m.mu.RLock()
+	sessions, branches := m.sessions, m.branches
+	m.mu.RUnlock()
+	if sessions == nil {
+		return 0
+	}
+	if branches != nil {
+		branches.Sweep()
+	}
+	return sessions.Sweep()
+}
+
+// Expire drops a session and its branch tree (sessionstore.Sweeper).
+func (m *Manager) Expire(sessionID string) bool {
+	m.mu.RLock()
+	sessions, branches := m.sessions, m.branches
+	m.mu.RUnlock()
+	if sessions == nil {
+		return false
+	}
+	expired := sessions.Expire(sessionID)
+	if branches != nil && branches.Expire(sessionID) {
+		expired = true
+	}
+	return expired
+}
+
+// Len returns the number of tracked sessions (sessionstore.Sweeper).
+func (m *Manager) Len() int {
+	return m.SessionCount()
+}
+
 func (m *Manager) Stats() map[string]any {
 	// snapshot fields under lock to avoid races with UpdateConfig
 	m.mu.RLock()
@@ -0,0 +1,149 @@
+// Prompt templates for LLM-based summarization strategies.
+package preemptive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// PromptVars are the variables available to summarizer.prompt_template, a Go
+// text/template string, e.g.:
+//
+//	prompt_template: |
+//	  The user's task: {{.Task}}
+//	  Tools used so far: {{join .ToolsUsed ", "}}
+//	  Keep file paths and failing test names verbatim.
+//	  {{.Conversation}}
+//	  For context, the most recent messages (kept as is):
+//	  {{.RecentMessages}}
+//
+// The legacy placeholder {{conversation}} is accepted as {{.Conversation}}.
+type PromptVars struct {
+	Task           string   // Text of the first user message
+	ToolsUsed      []string // Tools called in the summarized messages, in first-use order
+	Conversation   string   // Formatted messages to summarize
+	RecentMessages string   // Formatted messages kept after the summary
+	Model          string   // Model of the conversation being summarized
+
+	// PreviousSummary is set for rolling summarization: it covers the messages
+	// before Conversation, which then starts with it so templates that ignore
+	// PreviousSummary still merge it.
+	PreviousSummary string
+}
+
+// promptFuncs are helpers available inside summarizer prompt templates.
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// CompilePromptTemplate parses a summarizer prompt template and test-renders
+// it, so syntax errors, unknown variables and a template that drops the
+// conversation are reported at load time. An empty template returns nil.
+func CompilePromptTemplate(tmpl string) (*template.Template, error) {
+	if tmpl == "" {
+		return nil, nil
+	}
+	t, err := template.New("summarizer").Funcs(promptFuncs).
+		Parse(strings.ReplaceAll(tmpl, ConversationPlaceholder, "{{.Conversation}}"))
+	if err != nil {
+		return nil, err
+	}
+	sample := PromptVars{Task: "fix the build", ToolsUsed: []string{"read_file"}, Conversation: "\x00conversation\x00", Model: "claude-sonnet-4-5"}
+	out, err := renderPrompt(t, sample)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(out, sample.Conversation) {
+		return nil, fmt.Errorf("must include {{.Conversation}}")
+	}
+	return t, nil
+}
+
+// RenderPromptTemplate builds the summarization user prompt. An empty template
+// uses the default wording; otherwise every ConversationPlaceholder is
+// replaced with the formatted conversation.
+func RenderPromptTemplate(template, conversation string) string {
+	if template == "" {
+		return defaultUserPrompt(conversation)
+	}
+	return strings.ReplaceAll(template, ConversationPlaceholder, conversation)
+}
+
+func defaultUserPrompt(conversation string) string {
+	return fmt.Sprintf("Please summarize the following conversation:\n\n%s", conversation)
+}
+
+// defaultRollingPrompt asks for the previous summary and the newer messages
+// to be merged into one summary.
+func defaultRollingPrompt(conversation string) string {
+	return fmt.Sprintf("The earlier part of this conversation is already summarized; newer messages follow the summary. "+
+		"Write one updated summary that replaces the previous one, keeping what still matters from it:\n\n%s", conversation)
+}
+
+// rollingSummaryHeader introduces the previous summary in Conversation.
+const rollingSummaryHeader = "[Summary of the earlier conversation]\n"
+
+func renderPrompt(t *template.Template, vars PromptVars) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// buildPromptVars collects the template variables for summarizing
+// messages[first:lastIndex+1] and keeping the rest. first > 0 is a rolling
+// update of previousSummary, which covers messages[:first].
+func buildPromptVars(messages []json.RawMessage, first, lastIndex int, model, previousSummary string) PromptVars {
+	vars := PromptVars{
+		Conversation:    FormatMessages(messages[first : lastIndex+1]),
+		RecentMessages:  FormatMessages(messages[lastIndex+1:]),
+		Model:           model,
+		PreviousSummary: previousSummary,
+	}
+	if previousSummary != "" {
+		vars.Conversation = rollingSummaryHeader + previousSummary + "\n\n" + vars.Conversation
+	}
+	seen := make(map[string]bool)
+	addTool := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			vars.ToolsUsed = append(vars.ToolsUsed, name)
+		}
+	}
+	for i, raw := range messages[:lastIndex+1] {
+		var msg struct {
+			Role      string `json:"role"`
+			Content   any    `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		}
+		if json.Unmarshal(raw, &msg) != nil {
+			continue
+		}
+		if vars.Task == "" && msg.Role == "user" {
+			vars.Task = ExtractText(msg.Content)
+		}
+		if i < first {
+			continue // tools of already-summarized messages
+		}
+		for _, tc := range msg.ToolCalls { // OpenAI
+			addTool(tc.Function.Name)
+		}
+		if blocks, ok := msg.Content.([]any); ok { // Anthropic tool_use blocks
+			for _, b := range blocks {
+				if block, ok := b.(map[string]any); ok && block["type"] == "tool_use" {
+					name, _ := block["name"].(string)
+					addTool(name)
+				}
+			}
+		}
+	}
+	return vars
+}
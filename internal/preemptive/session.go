@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -88,6 +89,11 @@ func (sm *SessionManager) Close() {
 //
 // For subagents or edge cases without user messages, returns "" and caller should use fuzzy matching.
 func (sm *SessionManager) GenerateSessionID(messages []json.RawMessage) string {
+	return firstUserMessageSessionID(messages)
+}
+
+// firstUserMessageSessionID hashes the first user message of messages.
+func firstUserMessageSessionID(messages []json.RawMessage) string {
 	if len(messages) == 0 {
 		return ""
 	}
@@ -175,6 +181,48 @@ func (sm *SessionManager) GetOrCreateSession(sessionID, model string, maxTokens
 	return s
 }
 
+// ForkSession creates childID for a conversation branch that diverges from
+// parentID at message forkIndex. The parent's summary carries over only when
+// it covers messages strictly before the fork, i.e. shared ancestry; a summary
+// that includes edited-away messages would compact the branch incorrectly.
+func (sm *SessionManager) ForkSession(parentID, childID string, forkIndex int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	parent, ok := sm.sessions[parentID]
+	if !ok {
+		return
+	}
+	if _, exists := sm.sessions[childID]; exists {
+		return
+	}
+	if len(sm.sessions) >= sm.maxSessions {
+		sm.evictOldestSessionLocked()
+	}
+
+	now := time.Now()
+	s := &Session{
+		ID:               childID,
+		State:            StateIdle,
+		CreatedAt:        now,
+		LastUpdated:      now,
+		MaxContextTokens: parent.MaxContextTokens,
+		Model:            parent.Model,
+	}
+	hasSummary := parent.Summary != "" && (parent.State == StateReady || parent.State == StateUsed)
+	if hasSummary && parent.SummaryMessageIndex < forkIndex {
+		s.State = StateReady
+		s.Summary = parent.Summary
+		s.SummaryTokens = parent.SummaryTokens
+		s.SummaryMessageIndex = parent.SummaryMessageIndex
+		s.SummaryMessageCount = parent.SummaryMessageCount
+		s.SummaryTriggeredAt = parent.SummaryTriggeredAt
+		s.SummaryCompletedAt = parent.SummaryCompletedAt
+	}
+	s.element = sm.sessionOrder.PushBack(childID)
+	sm.sessions[childID] = s
+}
+
 // evictOldestSessionLocked removes the LRU session in O(1) via sessionOrder list (called with lock held).
 func (sm *SessionManager) evictOldestSessionLocked() {
 	front := sm.sessionOrder.Front()
@@ -277,6 +325,13 @@ func (sm *SessionManager) resetSessionLocked(s *Session) {
 	s.LastUpdated = time.Now()
 }
 
+// Len returns the number of tracked sessions.
+func (sm *SessionManager) Len() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.sessions)
+}
+
 // Stats returns session statistics.
 func (sm *SessionManager) Stats() map[string]any {
 	sm.mu.RLock()
@@ -310,8 +365,10 @@ type FuzzyMatchResult struct {
 // 2. Recent session with ready summary + similar message count + same model
 // 3. Most recent session with ready summary for the same model
 //
+// Only sessions of tenant ("" for requests without one) are considered.
+//
 // Returns nil if no suitable match is found.
-func (sm *SessionManager) FindBestMatchingSession(messageCount int, model string, excludeSessionID string) *FuzzyMatchResult {
+func (sm *SessionManager) FindBestMatchingSession(messageCount int, model string, excludeSessionID string, tenant string) *FuzzyMatchResult {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
@@ -327,6 +384,11 @@ func (sm *SessionManager) FindBestMatchingSession(messageCount int, model string
 			continue
 		}
 
+		// Never match across tenants
+		if sessionTenant(s.ID) != tenant {
+			continue
+		}
+
 		// Only consider sessions with ready or pending summaries
 		if s.State != StateReady && s.State != StatePending {
 			continue
@@ -449,6 +511,15 @@ func extractModelFamily(model string) string {
 	return model // Return full name if no family detected
 }
 
+// sessionTenant returns the tenant prefix of a session ID, or "". Untenanted
+// IDs never contain ':' (hashes and sanitized X-Session-ID values).
+func sessionTenant(id string) string {
+	if i := strings.IndexByte(id, ':'); i >= 0 {
+		return id[:i]
+	}
+	return ""
+}
+
 // absInt returns absolute value of an integer.
 func absInt(x int) int {
 	if x < 0 {
@@ -481,13 +552,7 @@ func (sm *SessionManager) MarkSummaryUsed(sessionID string) error {
 
 // DeleteSession removes a session completely.
 func (sm *SessionManager) DeleteSession(sessionID string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	if s, ok := sm.sessions[sessionID]; ok && s.element != nil {
-		sm.sessionOrder.Remove(s.element)
-		s.element = nil
-	}
-	delete(sm.sessions, sessionID)
+	sm.Expire(sessionID)
 }
 
 // IsSummaryValidForMessages checks if the summary is valid for the given message count.
@@ -530,6 +595,41 @@ func (sm *SessionManager) InvalidateSummaryIfNewMessages(sessionID string, messa
 	return false
 }
 
+// Sweep removes sessions idle longer than SummaryTTL and returns how many.
+func (sm *SessionManager) Sweep() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	now := time.Now()
+	removed := 0
+	for id, s := range sm.sessions {
+		if now.Sub(s.LastUpdated) > sm.config.SummaryTTL {
+			if s.element != nil {
+				sm.sessionOrder.Remove(s.element)
+				s.element = nil
+			}
+			delete(sm.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Expire removes a session immediately. Returns false if it was not tracked.
+func (sm *SessionManager) Expire(sessionID string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	s, ok := sm.sessions[sessionID]
+	if !ok {
+		return false
+	}
+	if s.element != nil {
+		sm.sessionOrder.Remove(s.element)
+		s.element = nil
+	}
+	delete(sm.sessions, sessionID)
+	return true
+}
+
 // cleanup periodically removes expired sessions.
 // OPTIMIZED: Runs every 10 minutes (was 5) to reduce CPU overhead.
 func (sm *SessionManager) cleanup() {
@@ -542,18 +642,7 @@ func (sm *SessionManager) cleanup() {
 		case <-sm.stopChan:
 			return
 		case <-ticker.C:
-			sm.mu.Lock()
-			now := time.Now()
-			for id, s := range sm.sessions {
-				if now.Sub(s.LastUpdated) > sm.config.SummaryTTL {
-					if s.element != nil {
-						sm.sessionOrder.Remove(s.element)
-						s.element = nil
-					}
-					delete(sm.sessions, id)
-				}
-			}
-			sm.mu.Unlock()
+			sm.Sweep()
 		}
 	}
 }
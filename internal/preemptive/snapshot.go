@@ -0,0 +1,72 @@
+package preemptive
+
+import "time"
+
+// Export returns a copy of every session, oldest first.
+func (sm *SessionManager) Export() []Session {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	out := make([]Session, 0, len(sm.sessions))
+	for el := sm.sessionOrder.Front(); el != nil; el = el.Next() {
+		if s, ok := sm.sessions[el.Value.(string)]; ok {
+			cp := *s
+			cp.element = nil
+			out = append(out, cp)
+		}
+	}
+	return out
+}
+
+// Import restores sessions from Export, replacing sessions with the same ID.
+// Sessions idle longer than SummaryTTL are skipped. A summary that was still
+// being computed cannot be resumed, so pending sessions come back idle.
+// Returns the number restored.
+func (sm *SessionManager) Import(sessions []Session) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	restored := 0
+	for i := range sessions {
+		s := sessions[i]
+		if s.ID == "" || (sm.config.SummaryTTL > 0 && now.Sub(s.LastUpdated) > sm.config.SummaryTTL) {
+			continue
+		}
+		if s.State == StatePending {
+			s.State = StateIdle
+			s.SummaryTriggeredAt = nil
+		}
+		if old, ok := sm.sessions[s.ID]; ok && old.element != nil {
+			sm.sessionOrder.Remove(old.element)
+		} else if len(sm.sessions) >= sm.maxSessions {
+			sm.evictOldestSessionLocked()
+		}
+		s.element = sm.sessionOrder.PushBack(s.ID)
+		sm.sessions[s.ID] = &s
+		restored++
+	}
+	return restored
+}
+
+// ExportSessions returns a copy of every tracked session (nil when disabled).
+func (m *Manager) ExportSessions() []Session {
+	m.mu.RLock()
+	sessions := m.sessions
+	m.mu.RUnlock()
+	if sessions == nil {
+		return nil
+	}
+	return sessions.Export()
+}
+
+// ImportSessions restores sessions from ExportSessions. Returns 0 when disabled.
+func (m *Manager) ImportSessions(sessions []Session) int {
+	m.mu.RLock()
+	sm := m.sessions
+	m.mu.RUnlock()
+	if sm == nil {
+		return 0
+	}
+	return sm.Import(sessions)
+}
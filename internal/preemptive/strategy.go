@@ -0,0 +1,189 @@
+// Pluggable summarization strategies.
+//
+// Besides the built-in strategies (external_provider, openai_compatible,
+// compresr), summarizer.strategy may name a SummaryStrategy registered with
+// RegisterStrategy. The summarizer picks the messages to summarize and renders
+// the prompts (summarizer.system_prompt and summarizer.prompt_template) before
+// calling it. The custom_endpoint strategy is registered this way: it posts
+// the rendered prompts to summarizer.endpoint.
+package preemptive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+)
+
+// StrategyCustomEndpoint posts summarization requests to summarizer.endpoint.
+const StrategyCustomEndpoint = "custom_endpoint"
+
+// SummaryRequest is one summarization job handed to a SummaryStrategy.
+type SummaryRequest struct {
+	Messages []json.RawMessage // Messages to summarize
+	Recent   []json.RawMessage // Messages kept after the summary (context only)
+
+	// PreviousSummary is set for a rolling update: it covers the messages
+	// before Messages, and the result should replace it.
+	PreviousSummary string
+
+	Model        string // Model of the conversation being summarized
+	SystemPrompt string // summarizer.system_prompt, or the built-in prompt
+	UserPrompt   string // summarizer.prompt_template rendered with Vars
+	Vars         PromptVars
+	Auth         authtypes.CapturedAuth // Credentials of the request that triggered the job
+}
+
+// SummaryResult is a strategy's summary. Token counts are optional; the
+// summary is counted locally when OutputTokens is zero.
+type SummaryResult struct {
+	Summary      string
+	InputTokens  int
+	OutputTokens int
+}
+
+// SummaryStrategy produces conversation summaries. Implementations must be
+// safe for concurrent use.
+type SummaryStrategy interface {
+	Summarize(ctx context.Context, req SummaryRequest) (SummaryResult, error)
+}
+
+// StrategyFactory builds a strategy from the summarizer configuration.
+type StrategyFactory func(cfg SummarizerConfig) (SummaryStrategy, error)
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]StrategyFactory{}
+)
+
+// RegisterStrategy makes a strategy available as summarizer.strategy: name.
+// Call before the gateway starts; built-in strategy names cannot be replaced.
+func RegisterStrategy(name string, factory StrategyFactory) {
+	if isBuiltinStrategy(name) {
+		panic(fmt.Sprintf("preemptive: cannot replace built-in strategy %q", name))
+	}
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = factory
+}
+
+// StrategyNames returns the built-in and registered strategy names.
+func StrategyNames() []string {
+	strategiesMu.RLock()
+	registered := make([]string, 0, len(strategies))
+	for name := range strategies {
+		registered = append(registered, name)
+	}
+	strategiesMu.RUnlock()
+	sort.Strings(registered)
+	return append([]string{StrategyExternalProvider, StrategyCompresr, StrategyOpenAICompatible}, registered...)
+}
+
+func isBuiltinStrategy(name string) bool {
+	return name == StrategyExternalProvider || name == StrategyCompresr || name == StrategyOpenAICompatible
+}
+
+func strategyFactory(name string) (StrategyFactory, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	f, ok := strategies[name]
+	return f, ok
+}
+
+func init() {
+	RegisterStrategy(StrategyCustomEndpoint, newEndpointStrategy)
+}
+
+// endpointStrategy posts each job to a team-run summarization service:
+//
+//	POST <endpoint>  {"model", "system_prompt", "prompt", "task", "tools_used", "messages", "recent_messages", "previous_summary"}
+//	200              {"summary": "...", "input_tokens": 0, "output_tokens": 0}
+//
+// The configured api_key is sent as a bearer token; captured client
+// credentials are never forwarded.
+type endpointStrategy struct {
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+func newEndpointStrategy(cfg SummarizerConfig) (SummaryStrategy, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("summarizer.endpoint is required when strategy is '%s'", StrategyCustomEndpoint)
+	}
+	if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("summarizer.endpoint must be an http(s) URL, got %q", cfg.Endpoint)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &endpointStrategy{
+		endpoint: cfg.Endpoint,
+		apiKey:   cfg.ProviderKey,
+		model:    cfg.Model,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (e *endpointStrategy) Summarize(ctx context.Context, req SummaryRequest) (SummaryResult, error) {
+	model := e.model
+	if model == "" {
+		model = req.Model
+	}
+	body := map[string]any{
+		"model":           model,
+		"system_prompt":   req.SystemPrompt,
+		"prompt":          req.UserPrompt,
+		"task":            req.Vars.Task,
+		"tools_used":      req.Vars.ToolsUsed,
+		"messages":        req.Messages,
+		"recent_messages": req.Recent,
+	}
+	if req.PreviousSummary != "" {
+		body["previous_summary"] = req.PreviousSummary
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return SummaryResult{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return SummaryResult{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(httpReq) // #nosec G107,G704 -- endpoint from config
+	if err != nil {
+		return SummaryResult{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return SummaryResult{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SummaryResult{}, fmt.Errorf("summary endpoint: status %d", resp.StatusCode)
+	}
+	var out struct {
+		Summary      string `json:"summary"`
+		InputTokens  int    `json:"input_tokens"`
+		OutputTokens int    `json:"output_tokens"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return SummaryResult{}, fmt.Errorf("summary endpoint: %w", err)
+	}
+	return SummaryResult{Summary: out.Summary, InputTokens: out.InputTokens, OutputTokens: out.OutputTokens}, nil
+}
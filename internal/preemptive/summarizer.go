@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -31,6 +33,14 @@ type Summarizer struct {
 	// bedrockClient is the cached HTTP client with SigV4 signing for Bedrock.
 	// Initialized once in NewSummarizer to avoid per-call transport creation.
 	bedrockClient *http.Client
+
+	// promptTemplate is the compiled summarizer.prompt_template (nil = default prompt).
+	promptTemplate *template.Template
+
+	// strategy is the registered SummaryStrategy for custom strategy names;
+	// strategyErr is its construction error, reported on Summarize.
+	strategy    SummaryStrategy
+	strategyErr error
 }
 
 // NewSummarizer creates a new summarizer.
@@ -47,6 +57,17 @@ func NewSummarizer(cfg SummarizerConfig) *Summarizer {
 		}
 		// If construction fails here, callAPI will retry and surface the error.
 	}
+	if cfg.Strategy != StrategyCompresr {
+		// Validate rejects bad templates; a nil template falls back to the default prompt.
+		s.promptTemplate, _ = CompilePromptTemplate(cfg.PromptTemplate)
+	}
+	if cfg.Strategy != "" && !isBuiltinStrategy(cfg.Strategy) {
+		if factory, ok := strategyFactory(cfg.Strategy); ok {
+			s.strategy, s.strategyErr = factory(cfg)
+		} else {
+			s.strategyErr = fmt.Errorf("unknown summarizer strategy %q", cfg.Strategy)
+		}
+	}
 	return s
 }
 
@@ -73,6 +94,38 @@ func (s *Summarizer) getAuthValue() authtypes.CapturedAuth {
 	return s.capturedAuth
 }
 
+// Summarizer credential sources reported by AuthSource.
+const (
+	AuthSourceAPIKey      = "api_key"      // Configured api_key (or summarizer.compresr.api_key)
+	AuthSourceSigV4       = "sigv4"        // Bedrock, signed with AWS credentials
+	AuthSourceCaptured    = "captured"     // Captured from an incoming client request
+	AuthSourceNotRequired = "not_required" // openai_compatible server without api_key
+)
+
+// AuthSource reports where the summarizer's credentials come from, or ""
+// when none is available yet (no api_key and nothing captured).
+func (s *Summarizer) AuthSource() string {
+	switch {
+	case s.config.Strategy == StrategyCompresr:
+		if s.config.Compresr != nil && s.config.Compresr.APIKey != "" {
+			return AuthSourceAPIKey
+		}
+		return ""
+	case s.config.ProviderKey != "":
+		return AuthSourceAPIKey
+	case s.config.Strategy == StrategyOpenAICompatible:
+		return AuthSourceNotRequired
+	case s.config.Provider == "bedrock":
+		return AuthSourceSigV4
+	}
+	s.authMutex.RLock()
+	defer s.authMutex.RUnlock()
+	if s.capturedAuth.HasAuth() {
+		return AuthSourceCaptured
+	}
+	return ""
+}
+
 // getEndpoint returns the endpoint URL to use for API calls.
 func (s *Summarizer) getEndpoint() string {
 	// When using captured auth (no configured API key), prefer captured endpoint
@@ -111,6 +164,19 @@ type SummarizeInput struct {
 	// Per-job auth credentials for session isolation
 	// When set, these override global captured auth to prevent cross-session leakage
 	Auth authtypes.CapturedAuth
+
+	// Rolling summarization: PreviousSummary covers Messages[:PreviousIndex+1].
+	// Only the messages after it are summarized and merged into it.
+	PreviousSummary string
+	PreviousIndex   int
+}
+
+// firstNew returns the index of the first message not covered by PreviousSummary.
+func (in SummarizeInput) firstNew() int {
+	if in.PreviousSummary == "" {
+		return 0
+	}
+	return in.PreviousIndex + 1
 }
 
 // SummarizeOutput contains the result.
@@ -121,16 +187,128 @@ type SummarizeOutput struct {
 	Duration            time.Duration
 	InputTokens         int
 	OutputTokens        int
+
+	// Missing lists facts the summary left out (summarizer.verification);
+	// LastSummarizedIndex was moved back to keep their messages.
+	Missing []MissingFact
 }
 
-// Summarize generates a summary based on the configured strategy.
+// Summarize generates a summary based on the configured strategy, then
+// verifies it when summarizer.verification is enabled.
 func (s *Summarizer) Summarize(ctx context.Context, input SummarizeInput) (*SummarizeOutput, error) {
+	out, err := s.summarize(ctx, input)
+	if err != nil || !s.config.Verification.Enabled {
+		return out, err
+	}
+	return s.verify(input, out)
+}
+
+// verify moves the cutoff back to keep the messages holding facts the summary
+// missed. It fails when that would leave nothing to summarize.
+func (s *Summarizer) verify(input SummarizeInput, out *SummarizeOutput) (*SummarizeOutput, error) {
+	first := input.firstNew()
+	missing, safe := verifySummary(out.Summary, input.Messages, first, out.LastSummarizedIndex, s.config.Verification)
+	if len(missing) == 0 {
+		return out, nil
+	}
+	if safe < first {
+		return nil, fmt.Errorf("summary verification failed: summary misses %s", missing[0])
+	}
+	log.Warn().Int("missing", len(missing)).Str("first_missing", missing[0].String()).
+		Int("summarized", out.LastSummarizedIndex+1).Int("now_summarized", safe+1).
+		Msg("Summary verification: keeping more recent messages")
+	out.LastSummarizedIndex = safe
+	out.Missing = missing
+	return out, nil
+}
+
+func (s *Summarizer) summarize(ctx context.Context, input SummarizeInput) (*SummarizeOutput, error) {
 	switch s.config.Strategy {
 	case StrategyCompresr:
 		return s.summarizeViaAPI(ctx, input)
-	default:
+	case StrategyOpenAICompatible:
+		return s.summarizeViaLocal(ctx, input)
+	case StrategyExternalProvider, "":
 		return s.summarizeViaLLM(ctx, input)
+	default:
+		return s.summarizeViaStrategy(ctx, input)
+	}
+}
+
+// cutoff returns the range of messages to summarize: [first, lastIndex].
+// For a rolling update first follows the previous summary, and there must be
+// new messages to merge into it.
+func (s *Summarizer) cutoff(input SummarizeInput) (first, lastIndex int, err error) {
+	lastIndex, err = s.findSummarizationCutoff(input)
+	if err != nil {
+		return 0, 0, err
+	}
+	first = input.firstNew()
+	if lastIndex < first {
+		return 0, 0, fmt.Errorf("not enough content to summarize: no messages older than the kept ones since the previous summary")
+	}
+	return first, lastIndex, nil
+}
+
+// userPrompt renders the user prompt for summarizing messages[first:lastIndex+1].
+func (s *Summarizer) userPrompt(input SummarizeInput, first, lastIndex int) (string, PromptVars) {
+	vars := buildPromptVars(input.Messages, first, lastIndex, input.Model, input.PreviousSummary)
+	if s.promptTemplate != nil {
+		out, err := renderPrompt(s.promptTemplate, vars)
+		if err == nil {
+			return out, vars
+		}
+		log.Warn().Err(err).Msg("summarizer: prompt_template failed, using default prompt")
+	}
+	if vars.PreviousSummary != "" {
+		return defaultRollingPrompt(vars.Conversation), vars
+	}
+	return defaultUserPrompt(vars.Conversation), vars
+}
+
+// systemPrompt returns summarizer.system_prompt or the built-in prompt.
+func (s *Summarizer) systemPrompt() string {
+	if s.config.SystemPrompt != "" {
+		return s.config.SystemPrompt
+	}
+	return DefaultClaudeSystemPrompt
+}
+
+// summarizeViaStrategy hands the rendered prompts to a registered SummaryStrategy.
+func (s *Summarizer) summarizeViaStrategy(ctx context.Context, input SummarizeInput) (*SummarizeOutput, error) {
+	startTime := time.Now()
+	if s.strategyErr != nil {
+		return nil, s.strategyErr
 	}
+	if len(input.Messages) == 0 {
+		return nil, fmt.Errorf("no messages to summarize")
+	}
+
+	first, lastIndex, err := s.cutoff(input)
+	if err != nil {
+		return nil, err
+	}
+
+	userContent, vars := s.userPrompt(input, first, lastIndex)
+	result, err := s.strategy.Summarize(ctx, SummaryRequest{
+		Messages:        input.Messages[first : lastIndex+1],
+		Recent:          input.Messages[lastIndex+1:],
+		PreviousSummary: input.PreviousSummary,
+		Model:           input.Model,
+		SystemPrompt:    s.systemPrompt(),
+		UserPrompt:      userContent,
+		Vars:            vars,
+		Auth:            input.Auth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s summarizer failed: %w", s.config.Strategy, err)
+	}
+
+	return buildLLMOutput(&external.CallLLMResult{
+		Content:      result.Summary,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+	}, lastIndex, startTime)
 }
 
 // summarizeViaLLM uses LLM provider for summarization (original behavior).
@@ -142,25 +320,58 @@ func (s *Summarizer) summarizeViaLLM(ctx context.Context, input SummarizeInput)
 	}
 
 	// Determine cutoff point using token-based or message-based approach
-	lastIndex, err := s.findSummarizationCutoff(input)
+	first, lastIndex, err := s.cutoff(input)
 	if err != nil {
 		return nil, err
 	}
 
-	toSummarize := input.Messages[:lastIndex+1]
+	userContent, _ := s.userPrompt(input, first, lastIndex)
+	result, err := s.callAPI(ctx, s.systemPrompt(), userContent, input)
+	if err != nil {
+		return nil, fmt.Errorf("API call failed: %w", err)
+	}
+
+	return buildLLMOutput(result, lastIndex, startTime)
+}
 
-	// Build request
-	prompt := s.config.SystemPrompt
-	if prompt == "" {
-		prompt = DefaultClaudeSystemPrompt
+// summarizeViaLocal summarizes with a self-hosted OpenAI-compatible server.
+// Captured client credentials are never forwarded: only the configured api_key
+// (if any) is sent, so compaction can run fully locally.
+func (s *Summarizer) summarizeViaLocal(ctx context.Context, input SummarizeInput) (*SummarizeOutput, error) {
+	startTime := time.Now()
+	if len(input.Messages) == 0 {
+		return nil, fmt.Errorf("no messages to summarize")
 	}
 
-	formatted := FormatMessages(toSummarize)
-	result, err := s.callAPI(ctx, prompt, fmt.Sprintf("Please summarize the following conversation:\n\n%s", formatted), input)
+	first, lastIndex, err := s.cutoff(input)
 	if err != nil {
-		return nil, fmt.Errorf("API call failed: %w", err)
+		return nil, err
+	}
+
+	userContent, _ := s.userPrompt(input, first, lastIndex)
+
+	endpoint := strings.TrimRight(s.config.BaseURL, "/") + "/chat/completions"
+	log.Debug().Str("model", s.config.Model).Str("endpoint", endpoint).Int("max_tokens", s.config.MaxTokens).Msg("Calling local summarization server")
+
+	result, err := external.CallLLM(ctx, external.CallLLMParams{
+		Provider:     external.ProviderOpenAICompatible,
+		Endpoint:     endpoint,
+		ProviderKey:  s.config.ProviderKey,
+		Model:        s.config.Model,
+		SystemPrompt: s.systemPrompt(),
+		UserPrompt:   userContent,
+		MaxTokens:    s.config.MaxTokens,
+		Timeout:      s.config.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local summarizer call failed: %w", err)
 	}
 
+	return buildLLMOutput(result, lastIndex, startTime)
+}
+
+// buildLLMOutput converts an LLM result into a SummarizeOutput.
+func buildLLMOutput(result *external.CallLLMResult, lastIndex int, startTime time.Time) (*SummarizeOutput, error) {
 	summary := result.Content
 	if summary == "" {
 		return nil, fmt.Errorf("empty summary returned")
@@ -202,9 +413,17 @@ func (s *Summarizer) summarizeViaAPI(ctx context.Context, input SummarizeInput)
 		keepRecent = 3 // default
 	}
 
-	// Convert messages to Compresr format
-	historyMessages := make([]compresr.HistoryMessage, 0, total)
-	for _, msg := range input.Messages {
+	// Convert messages to Compresr format. A rolling update sends the previous
+	// summary in place of the messages it covers.
+	first := input.firstNew()
+	historyMessages := make([]compresr.HistoryMessage, 0, total-first+1)
+	if first > 0 {
+		historyMessages = append(historyMessages, compresr.HistoryMessage{
+			Role:    "user",
+			Content: rollingSummaryHeader + input.PreviousSummary,
+		})
+	}
+	for _, msg := range input.Messages[first:] {
 		// Use any for Content to handle both string and array (Anthropic content blocks)
 		var parsedMsg struct {
 			Role    string `json:"role"`
@@ -237,8 +456,15 @@ func (s *Summarizer) summarizeViaAPI(ctx context.Context, input SummarizeInput)
 		return nil, fmt.Errorf("compresr API call failed: %w", err)
 	}
 
-	// Calculate last summarized index (all messages except the kept ones)
-	lastIndex := total - response.MessagesKept - 1
+	// Calculate last summarized index (all messages except the kept ones).
+	// historyMessages[i] is input.Messages[first-1+i] for a rolling update.
+	lastIndex := len(historyMessages) - response.MessagesKept - 1
+	if first > 0 {
+		lastIndex += first - 1
+		if lastIndex < first {
+			return nil, fmt.Errorf("not enough content to summarize: no messages older than the kept ones since the previous summary")
+		}
+	}
 	if lastIndex < 0 {
 		lastIndex = 0
 	}
@@ -4,6 +4,9 @@ package preemptive
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/compresr/context-gateway/internal/adapters"
@@ -14,8 +17,13 @@ import (
 const (
 	StrategyExternalProvider = "external_provider" // Use LLM provider for summarization
 	StrategyCompresr         = "compresr"          // Use Compresr API for history compression
+	StrategyOpenAICompatible = "openai_compatible" // Use a self-hosted OpenAI-compatible server (Ollama, vLLM, ...)
 )
 
+// ConversationPlaceholder marks where the formatted conversation is inserted
+// into SummarizerConfig.PromptTemplate.
+const ConversationPlaceholder = "{{conversation}}"
+
 // CodexDetectorConfig for Codex detection.
 type CodexDetectorConfig struct {
 	Enabled        bool     `yaml:"enabled"`
@@ -52,7 +60,9 @@ type Config struct {
 
 // SummarizerConfig configures the summarization service.
 type SummarizerConfig struct {
-	// Strategy: "external_provider" (LLM) or "compresr" (Compresr API with hcc_espresso_v1)
+	// Strategy: "external_provider" (LLM), "compresr" (Compresr API with hcc_espresso_v1),
+	// "openai_compatible" (local OpenAI-compatible server), "custom_endpoint"
+	// (team-run summarization service at Endpoint) or a name passed to RegisterStrategy.
 	Strategy string `yaml:"strategy"`
 
 	// Provider reference (for strategy: "external_provider")
@@ -70,6 +80,25 @@ type SummarizerConfig struct {
 	KeepRecentCount  int           `yaml:"keep_recent"`        // Message-based (legacy fallback)
 	SystemPrompt     string        `yaml:"system_prompt,omitempty"`
 
+	// DisableRolling turns off rolling summarization. By default, once a
+	// session has a summary, later runs summarize only the messages added
+	// since and merge them into it instead of re-summarizing the whole history.
+	DisableRolling bool `yaml:"disable_rolling,omitempty"`
+
+	// Verification checks each summary for open tasks, recently touched files
+	// and unresolved tool errors from the messages it replaces.
+	Verification VerificationConfig `yaml:"verification,omitempty"`
+
+	// OpenAI-compatible server settings (for strategy: "openai_compatible").
+	// BaseURL is the API root, e.g. "http://localhost:11434/v1"; requests go to
+	// BaseURL + "/chat/completions". api_key is optional.
+	BaseURL string `yaml:"base_url,omitempty"`
+
+	// PromptTemplate replaces the default user prompt for every strategy except
+	// "compresr". It is a Go text/template over PromptVars and must include
+	// {{.Conversation}} (or the legacy ConversationPlaceholder).
+	PromptTemplate string `yaml:"prompt_template,omitempty"`
+
 	// Compresr config (for strategy: "compresr")
 	Compresr *CompresrConfig `yaml:"compresr,omitempty"`
 
@@ -91,6 +120,7 @@ type SessionConfig struct {
 	SummaryTTL           time.Duration `yaml:"summary_ttl"`
 	HashMessageCount     int           `yaml:"hash_message_count"`
 	DisableFuzzyMatching bool          `yaml:"disable_fuzzy_matching"` // Opt-out of fuzzy matching
+	DisableBranching     bool          `yaml:"disable_branching"`      // Share one session across edited branches of a conversation
 }
 
 // DetectorsConfig contains agent-specific compaction detectors.
@@ -126,8 +156,16 @@ func (c *Config) Validate() error {
 	if c.Summarizer.Strategy == "" {
 		c.Summarizer.Strategy = StrategyExternalProvider // default to provider (backward compat)
 	}
-	if c.Summarizer.Strategy != StrategyExternalProvider && c.Summarizer.Strategy != StrategyCompresr {
-		return fmt.Errorf("summarizer.strategy must be 'external_provider' or 'compresr'")
+	if !slices.Contains(StrategyNames(), c.Summarizer.Strategy) {
+		return fmt.Errorf("summarizer.strategy must be one of %s", strings.Join(StrategyNames(), ", "))
+	}
+	if c.Summarizer.Verification.MaxFiles < 0 {
+		return fmt.Errorf("summarizer.verification.max_files must not be negative")
+	}
+	if c.Summarizer.Strategy != StrategyCompresr {
+		if _, err := CompilePromptTemplate(c.Summarizer.PromptTemplate); err != nil {
+			return fmt.Errorf("summarizer.prompt_template: %w", err)
+		}
 	}
 
 	// Strategy-specific validation
@@ -146,6 +184,23 @@ func (c *Config) Validate() error {
 		if c.Summarizer.Timeout <= 0 {
 			return fmt.Errorf("summarizer.timeout must be positive")
 		}
+	case StrategyOpenAICompatible:
+		if c.Summarizer.BaseURL == "" {
+			return fmt.Errorf("summarizer.base_url is required when strategy is 'openai_compatible'")
+		}
+		u, err := url.Parse(c.Summarizer.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("summarizer.base_url must be an http(s) URL, got %q", c.Summarizer.BaseURL)
+		}
+		if c.Summarizer.Model == "" {
+			return fmt.Errorf("summarizer.model is required when strategy is 'openai_compatible'")
+		}
+		if c.Summarizer.MaxTokens <= 0 {
+			return fmt.Errorf("summarizer.max_tokens must be positive")
+		}
+		if c.Summarizer.Timeout <= 0 {
+			return fmt.Errorf("summarizer.timeout must be positive")
+		}
 	case StrategyCompresr:
 		// API config validation
 		if c.Summarizer.Compresr == nil {
@@ -163,6 +218,12 @@ func (c *Config) Validate() error {
 		if c.Summarizer.Compresr.Timeout <= 0 {
 			return fmt.Errorf("summarizer.compresr.timeout must be positive")
 		}
+	default:
+		// Registered strategy: its factory validates the settings it uses.
+		factory, _ := strategyFactory(c.Summarizer.Strategy)
+		if _, err := factory(c.Summarizer); err != nil {
+			return err
+		}
 	}
 
 	if c.Session.SummaryTTL <= 0 {
@@ -176,16 +237,22 @@ func (c *Config) Validate() error {
 
 // EffectiveModelAndProvider returns the model and provider names based on the active strategy.
 // For "compresr" strategy, model comes from API.Model and provider is "compresr_api".
+// For "openai_compatible" strategy, provider is "openai_compatible".
 // For "external_provider" strategy, model and provider come from the inline fields.
+// For registered strategies, provider is the strategy name.
 func (sc *SummarizerConfig) EffectiveModelAndProvider() (model, provider string) {
 	switch sc.Strategy {
+	case StrategyOpenAICompatible:
+		return sc.Model, StrategyOpenAICompatible
 	case StrategyCompresr:
 		if sc.Compresr != nil {
 			return sc.Compresr.Model, "compresr_api"
 		}
 		return "", "compresr_api"
-	default:
+	case StrategyExternalProvider, "":
 		return sc.Model, sc.Provider
+	default:
+		return sc.Model, sc.Strategy
 	}
 }
 
@@ -0,0 +1,270 @@
+// Compaction quality guardrails.
+//
+// With summarizer.verification enabled, each summary is checked against facts
+// extracted heuristically from the messages it replaces: open tasks (the
+// latest TodoWrite list and unchecked "- [ ]" items), recently touched file
+// paths and tool errors that were never followed by a successful call of the
+// same tool. Facts still visible in the kept messages are skipped. When the
+// summary misses a fact, the cutoff moves back so the message holding it is
+// kept verbatim; the summary then overlaps the kept messages, which is
+// redundant but safe.
+package preemptive
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// VerificationConfig configures the summary verification pass.
+type VerificationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MaxFiles is how many of the most recently touched file paths the
+	// summary must mention (default: 10).
+	MaxFiles int `yaml:"max_files,omitempty"`
+}
+
+const defaultVerifyMaxFiles = 10
+
+// Fact kinds checked by the verification pass.
+const (
+	FactOpenTask  = "open_task"
+	FactFile      = "file"
+	FactToolError = "tool_error"
+)
+
+// MissingFact is a fact from a summarized message that the summary left out.
+type MissingFact struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Index int    `json:"index"` // Message holding the fact
+}
+
+func (f MissingFact) String() string {
+	return fmt.Sprintf("%s %q (message %d)", f.Kind, f.Text, f.Index+1)
+}
+
+// fact is something the summary of messages must carry forward.
+type fact struct {
+	kind  string
+	text  string
+	index int
+	// terms: the summary must contain one of them (case-insensitive); nil
+	// means keyword overlap with text is checked instead.
+	terms []string
+}
+
+// pathKeys are tool input fields holding a file path.
+var pathKeys = []string{"file_path", "path", "filePath", "filename", "notebook_path"}
+
+// checkboxRe matches an unchecked markdown task list item.
+var checkboxRe = regexp.MustCompile(`(?m)^\s*[-*] \[ \] (.+)$`)
+
+// wordRe splits text into keywords for overlap matching.
+var wordRe = regexp.MustCompile(`[A-Za-z0-9_]{4,}`)
+
+// toolEvent is a tool call or result found in a message.
+type toolEvent struct {
+	index  int
+	name   string
+	input  map[string]any
+	result string // set for results
+	isErr  bool
+	isCall bool
+}
+
+// parseToolEvents extracts tool calls and results from Anthropic and OpenAI messages.
+func parseToolEvents(messages []json.RawMessage) []toolEvent {
+	var events []toolEvent
+	names := make(map[string]string) // tool_use_id / tool_call_id -> tool name
+	for i, raw := range messages {
+		var msg struct {
+			Role       string `json:"role"`
+			Content    any    `json:"content"`
+			ToolCallID string `json:"tool_call_id"`
+			ToolCalls  []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		}
+		if json.Unmarshal(raw, &msg) != nil {
+			continue
+		}
+		for _, tc := range msg.ToolCalls { // OpenAI calls
+			var input map[string]any
+			_ = json.Unmarshal([]byte(tc.Function.Arguments), &input)
+			names[tc.ID] = tc.Function.Name
+			events = append(events, toolEvent{index: i, name: tc.Function.Name, input: input, isCall: true})
+		}
+		if msg.Role == "tool" { // OpenAI results
+			text := ExtractText(msg.Content)
+			events = append(events, toolEvent{index: i, name: names[msg.ToolCallID], result: text, isErr: looksLikeError(text)})
+			continue
+		}
+		blocks, _ := msg.Content.([]any)
+		for _, b := range blocks { // Anthropic blocks
+			block, ok := b.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "tool_use":
+				name, _ := block["name"].(string)
+				id, _ := block["id"].(string)
+				input, _ := block["input"].(map[string]any)
+				names[id] = name
+				events = append(events, toolEvent{index: i, name: name, input: input, isCall: true})
+			case "tool_result":
+				id, _ := block["tool_use_id"].(string)
+				isErr, _ := block["is_error"].(bool)
+				events = append(events, toolEvent{index: i, name: names[id], result: ExtractContentString(block["content"]), isErr: isErr})
+			}
+		}
+	}
+	return events
+}
+
+// looksLikeError reports whether an OpenAI tool result (no is_error flag) is an error.
+func looksLikeError(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	return strings.HasPrefix(lower, "error") || strings.Contains(lower, "\nerror:") || strings.Contains(lower, "traceback (most recent call last)")
+}
+
+// extractFacts returns the facts from messages[first:lastIndex+1] that the
+// summary must carry, skipping those still visible after lastIndex.
+func extractFacts(messages []json.RawMessage, first, lastIndex, maxFiles int) []fact {
+	events := parseToolEvents(messages)
+	var kept strings.Builder
+	for _, m := range messages[lastIndex+1:] {
+		kept.Write(m)
+	}
+	keptText := kept.String()
+	var facts []fact
+
+	// Open tasks: the latest TodoWrite list, unless the kept messages have a newer one.
+	var todos *toolEvent
+	for i := range events {
+		e := &events[i]
+		if e.isCall && e.name == "TodoWrite" {
+			todos = e
+		}
+	}
+	if todos != nil && todos.index >= first && todos.index <= lastIndex {
+		items, _ := todos.input["todos"].([]any)
+		for _, it := range items {
+			item, _ := it.(map[string]any)
+			status, _ := item["status"].(string)
+			content, _ := item["content"].(string)
+			if content != "" && status != "completed" {
+				facts = append(facts, fact{kind: FactOpenTask, text: content, index: todos.index})
+			}
+		}
+	}
+	for i := first; i <= lastIndex; i++ {
+		var msg struct {
+			Content any `json:"content"`
+		}
+		if json.Unmarshal(messages[i], &msg) != nil {
+			continue
+		}
+		for _, m := range checkboxRe.FindAllStringSubmatch(ExtractText(msg.Content), -1) {
+			if task := strings.TrimSpace(m[1]); !strings.Contains(keptText, task) {
+				facts = append(facts, fact{kind: FactOpenTask, text: task, index: i})
+			}
+		}
+	}
+
+	// Recently touched files, most recent first.
+	if maxFiles <= 0 {
+		maxFiles = defaultVerifyMaxFiles
+	}
+	seen := make(map[string]bool)
+	var files []fact
+	for i := len(events) - 1; i >= 0 && len(files) < maxFiles; i-- {
+		e := events[i]
+		if !e.isCall || e.index < first || e.index > lastIndex {
+			continue
+		}
+		for _, key := range pathKeys {
+			p, _ := e.input[key].(string)
+			if p == "" || seen[p] || strings.Contains(keptText, p) {
+				continue
+			}
+			seen[p] = true
+			files = append(files, fact{kind: FactFile, text: p, index: e.index, terms: []string{p, path.Base(p)}})
+		}
+	}
+	facts = append(facts, files...)
+
+	// Tool errors with no later successful call of the same tool.
+	for i, e := range events {
+		if e.isCall || !e.isErr || e.index < first || e.index > lastIndex {
+			continue
+		}
+		resolved := false
+		for _, later := range events[i+1:] {
+			if !later.isCall && !later.isErr && later.name == e.name {
+				resolved = true
+				break
+			}
+		}
+		if !resolved {
+			line, _, _ := strings.Cut(strings.TrimSpace(e.result), "\n")
+			f := fact{kind: FactToolError, text: truncate(line, 120), index: e.index}
+			if e.name != "" {
+				f.terms = []string{e.name}
+			}
+			facts = append(facts, f)
+		}
+	}
+	return facts
+}
+
+// mentionedIn reports whether summary carries f: one of its terms, or at least
+// half of the keywords of its text.
+func (f fact) mentionedIn(summary string) bool {
+	lower := strings.ToLower(summary)
+	if f.terms != nil {
+		for _, t := range f.terms {
+			if t != "" && strings.Contains(lower, strings.ToLower(t)) {
+				return true
+			}
+		}
+		return false
+	}
+	words := wordRe.FindAllString(strings.ToLower(f.text), -1)
+	if len(words) == 0 {
+		return true
+	}
+	hits := 0
+	for _, w := range words {
+		if strings.Contains(lower, w) {
+			hits++
+		}
+	}
+	return hits*2 >= len(words)
+}
+
+// verifySummary checks summary against the facts of messages[first:lastIndex+1].
+// It returns the facts the summary misses and the last index the summary can
+// safely replace: just before the earliest message holding a missing fact.
+func verifySummary(summary string, messages []json.RawMessage, first, lastIndex int, cfg VerificationConfig) ([]MissingFact, int) {
+	var missing []MissingFact
+	safe := lastIndex
+	for _, f := range extractFacts(messages, first, lastIndex, cfg.MaxFiles) {
+		if f.mentionedIn(summary) {
+			continue
+		}
+		missing = append(missing, MissingFact{Kind: f.kind, Text: f.text, Index: f.index})
+		if f.index-1 < safe {
+			safe = f.index - 1
+		}
+	}
+	return missing, safe
+}
@@ -51,6 +51,10 @@ type Job struct {
 
 	// Per-job auth credentials for session isolation
 	Auth authtypes.CapturedAuth
+
+	// Rolling update of a ready summary covering Messages[:PreviousIndex+1].
+	PreviousSummary string
+	PreviousIndex   int
 }
 
 // SummarizationJob is an alias for backward compatibility.
@@ -138,6 +142,23 @@ type JobAuthParams = authtypes.CapturedAuth
 // The auth params are captured from the request that triggers this job,
 // ensuring session isolation.
 func (w *Worker) Submit(sessionID string, messages []json.RawMessage, model string, auth JobAuthParams) *Job {
+	return w.submit(&Job{Messages: messages, Model: model, Auth: auth}, sessionID)
+}
+
+// SubmitRolling submits a rolling update: only the messages after
+// previousIndex are summarized and merged into previousSummary. The session
+// keeps its current summary if the job fails.
+func (w *Worker) SubmitRolling(sessionID string, messages []json.RawMessage, model string, auth JobAuthParams, previousSummary string, previousIndex int) *Job {
+	return w.submit(&Job{
+		Messages:        messages,
+		Model:           model,
+		Auth:            auth,
+		PreviousSummary: previousSummary,
+		PreviousIndex:   previousIndex,
+	}, sessionID)
+}
+
+func (w *Worker) submit(job *Job, sessionID string) *Job {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -148,23 +169,18 @@ func (w *Worker) Submit(sessionID string, messages []json.RawMessage, model stri
 		}
 	}
 
-	job := &Job{
-		ID:           sessionID,
-		SessionID:    sessionID,
-		Status:       JobQueued,
-		CreatedAt:    time.Now(),
-		Messages:     messages,
-		MessageCount: len(messages),
-		Model:        model,
-		done:         make(chan struct{}),
-		Auth:         auth,
-	}
+	job.ID = sessionID
+	job.SessionID = sessionID
+	job.Status = JobQueued
+	job.CreatedAt = time.Now()
+	job.MessageCount = len(job.Messages)
+	job.done = make(chan struct{})
 
 	w.jobs[sessionID] = job
 
 	select {
 	case w.jobQueue <- job:
-		log.Info().Str("session_id", sessionID).Int("messages", len(messages)).Msg("Summarization job queued")
+		log.Info().Str("session_id", sessionID).Int("messages", job.MessageCount).Bool("rolling", job.PreviousSummary != "").Msg("Summarization job queued")
 	default:
 		job.Status = JobFailed
 		job.Error = "queue full"
@@ -242,6 +258,8 @@ func (w *Worker) processJob(workerID int, job *Job) {
 		KeepRecentCount:  w.summarizerCfg.KeepRecentCount,
 		Model:            job.Model,
 		Auth:             job.Auth,
+		PreviousSummary:  job.PreviousSummary,
+		PreviousIndex:    job.PreviousIndex,
 	})
 
 	now := time.Now()
@@ -253,7 +271,14 @@ func (w *Worker) processJob(workerID int, job *Job) {
 		job.Status = JobFailed
 		job.Error = err.Error()
 		job.CompletedAt = &now
-		_ = w.sessions.Update(job.SessionID, func(s *Session) { s.State = StateIdle })
+		_ = w.sessions.Update(job.SessionID, func(s *Session) {
+			// A failed rolling update leaves the previous summary usable.
+			if job.PreviousSummary != "" && s.Summary != "" {
+				s.State = StateReady
+				return
+			}
+			s.State = StateIdle
+		})
 
 		// Log skip (not an error) for "not enough content" cases
 		if logger := GetCompactionLogger(); logger != nil {
@@ -271,7 +296,8 @@ func (w *Worker) processJob(workerID int, job *Job) {
 		job.Summary = result.Summary
 		job.SummaryTokens = result.SummaryTokens
 		job.LastIndex = result.LastSummarizedIndex
-		log.Info().Str("session_id", job.SessionID).Int("summary_tokens", result.SummaryTokens).Dur("duration", result.Duration).Msg("Summarization job completed")
+		log.Info().Str("session_id", job.SessionID).Int("summary_tokens", result.SummaryTokens).Int("input_tokens", result.InputTokens).
+			Bool("rolling", job.PreviousSummary != "").Dur("duration", result.Duration).Msg("Summarization job completed")
 		_ = w.sessions.SetSummaryReady(job.SessionID, result.Summary, result.SummaryTokens, result.LastSummarizedIndex, job.MessageCount)
 		// Log preemptive complete with original and compressed content
 		if logger := GetCompactionLogger(); logger != nil {
@@ -0,0 +1,252 @@
+// Package priority - scheduler.go admits requests by class.
+package priority
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Rejection reasons (used as metric labels).
+const (
+	ReasonQueueFull    = "queue_full"
+	ReasonQueueTimeout = "queue_timeout"
+	ReasonBudget       = "budget"
+	ReasonCanceled     = "canceled"
+)
+
+// Rejection errors returned by Acquire.
+var (
+	ErrQueueFull    = errors.New("priority: queue full")
+	ErrQueueTimeout = errors.New("priority: timed out waiting for a slot")
+)
+
+// ClassStats is a snapshot of one class's counters.
+type ClassStats struct {
+	Admitted int64            `json:"admitted"`
+	Queued   int64            `json:"queued"` // Admissions that had to wait
+	Rejected map[string]int64 `json:"rejected,omitempty"`
+	InFlight int              `json:"in_flight"`
+	Waiting  int              `json:"waiting"`
+	WaitMs   int64            `json:"wait_ms_total"`
+}
+
+// Stats is a snapshot of the scheduler.
+type Stats struct {
+	MaxConcurrent int                   `json:"max_concurrent"`
+	InFlight      int                   `json:"in_flight"`
+	Classes       map[string]ClassStats `json:"classes"`
+}
+
+// waiter is a queued request. granted is guarded by Scheduler.mu.
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Scheduler limits concurrent requests and hands free slots to the highest
+// waiting class. Thread-safe. Safe to call on a nil receiver (disabled).
+type Scheduler struct {
+	mu       sync.Mutex
+	cfg      Config
+	inFlight int
+	queues   [numClasses]*list.List // of *waiter, FIFO
+
+	inFlightBy [numClasses]int
+	admitted   [numClasses]int64
+	queued     [numClasses]int64
+	waitMs     [numClasses]int64
+	rejected   [numClasses]map[string]int64
+}
+
+// NewScheduler returns a scheduler, or nil when priority classes are disabled.
+func NewScheduler(cfg Config) *Scheduler {
+	if !cfg.Enabled {
+		return nil
+	}
+	s := &Scheduler{cfg: cfg}
+	for i := range s.queues {
+		s.queues[i] = list.New()
+		s.rejected[i] = make(map[string]int64)
+	}
+	return s
+}
+
+// UpdateConfig swaps limits (hot-reload). Raising max_concurrent (or disabling
+// priority) admits waiters immediately; lowering it lets in-flight requests finish.
+func (s *Scheduler) UpdateConfig(cfg Config) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.dispatch()
+}
+
+// Classify picks the class of a request. Safe to call on nil (returns Interactive).
+func (s *Scheduler) Classify(h http.Header, sessionTags []string) Class {
+	if s == nil {
+		return Interactive
+	}
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	return cfg.Classify(h, sessionTags)
+}
+
+// Shed reports whether a request of class cl should be rejected because the
+// budget is utilization full (0-1, the fullest enabled cap). Counts the rejection.
+func (s *Scheduler) Shed(cl Class, utilization float64) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := s.cfg.class(cl).BudgetShedAt
+	if !s.cfg.Enabled || at <= 0 || utilization < at {
+		return false
+	}
+	s.rejected[cl][ReasonBudget]++
+	return true
+}
+
+// Acquire waits for a slot for class cl. On success the caller must call
+// release exactly once when the upstream request is done. Returns ErrQueueFull,
+// ErrQueueTimeout or the context error on rejection.
+func (s *Scheduler) Acquire(ctx context.Context, cl Class) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	if s.ahead(cl) == 0 && s.canAdmit(cl) {
+		s.admit(cl)
+		s.mu.Unlock()
+		return s.releaser(cl), nil
+	}
+	cc := s.cfg.class(cl)
+	q := s.queues[cl]
+	if q.Len() >= cc.MaxQueued {
+		s.rejected[cl][ReasonQueueFull]++
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	elem := q.PushBack(w)
+	s.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(cc.QueueTimeout)
+	defer timer.Stop()
+
+	var reason string
+	select {
+	case <-w.ready:
+	case <-timer.C:
+		reason, err = ReasonQueueTimeout, ErrQueueTimeout
+	case <-ctx.Done():
+		reason, err = ReasonCanceled, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !w.granted {
+		q.Remove(elem)
+		s.rejected[cl][reason]++
+		return nil, err
+	}
+	// Granted, possibly racing the timeout: keep the slot.
+	s.queued[cl]++
+	s.waitMs[cl] += time.Since(start).Milliseconds()
+	return s.releaser(cl), nil
+}
+
+// ahead counts waiters that must be served before a new request of class cl. Caller holds mu.
+func (s *Scheduler) ahead(cl Class) int {
+	n := 0
+	for c := Interactive; c <= cl; c++ {
+		n += s.queues[c].Len()
+	}
+	return n
+}
+
+// canAdmit reports whether a request of class cl fits now. Lower classes may
+// not use the reserved interactive slots. Caller holds mu.
+func (s *Scheduler) canAdmit(cl Class) bool {
+	if !s.cfg.Enabled || s.cfg.MaxConcurrent <= 0 {
+		return true
+	}
+	limit := s.cfg.MaxConcurrent
+	if cl != Interactive {
+		limit -= s.cfg.ReservedInteractive
+	}
+	return s.inFlight < limit
+}
+
+// admit takes a slot. Caller holds mu.
+func (s *Scheduler) admit(cl Class) {
+	s.inFlight++
+	s.inFlightBy[cl]++
+	s.admitted[cl]++
+}
+
+// dispatch hands free slots to waiters, highest class first. A waiting
+// higher class blocks lower ones, so interactive work is never overtaken.
+// Caller holds mu.
+func (s *Scheduler) dispatch() {
+	for _, cl := range Classes {
+		q := s.queues[cl]
+		for q.Len() > 0 {
+			if !s.canAdmit(cl) {
+				return
+			}
+			w := q.Remove(q.Front()).(*waiter)
+			w.granted = true
+			s.admit(cl)
+			close(w.ready)
+		}
+	}
+}
+
+func (s *Scheduler) releaser(cl Class) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.inFlight--
+			s.inFlightBy[cl]--
+			s.dispatch()
+		})
+	}
+}
+
+// Stats returns a snapshot of per-class counters. Safe to call on nil.
+func (s *Scheduler) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Stats{MaxConcurrent: s.cfg.MaxConcurrent, InFlight: s.inFlight, Classes: make(map[string]ClassStats, numClasses)}
+	for _, cl := range Classes {
+		cs := ClassStats{
+			Admitted: s.admitted[cl],
+			Queued:   s.queued[cl],
+			InFlight: s.inFlightBy[cl],
+			Waiting:  s.queues[cl].Len(),
+			WaitMs:   s.waitMs[cl],
+		}
+		if len(s.rejected[cl]) > 0 {
+			cs.Rejected = make(map[string]int64, len(s.rejected[cl]))
+			for k, v := range s.rejected[cl] {
+				cs.Rejected[k] = v
+			}
+		}
+		st.Classes[cl.String()] = cs
+	}
+	return st
+}
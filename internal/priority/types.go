@@ -0,0 +1,157 @@
+// Package priority implements request priority classes.
+//
+// Every proxied request is classified as interactive, background or batch
+// (X-Gateway-Priority header, else a matching X-Session-Tags tag, else the
+// default class). When max_concurrent upstream requests are in flight, new
+// requests wait in per-class queues and freed slots go to the highest class
+// first, so a queued interactive request always overtakes queued background
+// and batch work. reserved_interactive slots are never given to lower classes.
+// As cost_control caps fill up, lower classes are shed before the cap is hit
+// so the remaining budget goes to interactive traffic.
+package priority
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HeaderPriority selects the priority class of a request.
+const HeaderPriority = "X-Gateway-Priority"
+
+// Class is a request priority class. Lower values are served first.
+type Class int
+
+// Priority classes, highest first.
+const (
+	Interactive Class = iota
+	Background
+	Batch
+
+	numClasses = 3
+)
+
+// Classes lists all classes, highest priority first.
+var Classes = []Class{Interactive, Background, Batch}
+
+var classNames = [numClasses]string{"interactive", "background", "batch"}
+
+// String returns the class name used in config, headers and metrics.
+func (c Class) String() string {
+	if c < 0 || int(c) >= numClasses {
+		return "unknown"
+	}
+	return classNames[c]
+}
+
+// ParseClass parses a class name (case-insensitive).
+func ParseClass(s string) (Class, bool) {
+	i := slices.Index(classNames[:], strings.ToLower(strings.TrimSpace(s)))
+	if i < 0 {
+		return 0, false
+	}
+	return Class(i), true
+}
+
+// Per-class defaults (applied when ClassConfig fields are zero).
+var defaultClasses = [numClasses]ClassConfig{
+	Interactive: {QueueTimeout: 60 * time.Second, MaxQueued: 1000},
+	Background:  {QueueTimeout: 30 * time.Second, MaxQueued: 200, BudgetShedAt: 0.9},
+	Batch:       {QueueTimeout: 10 * time.Second, MaxQueued: 100, BudgetShedAt: 0.8},
+}
+
+// Config enables priority classes.
+type Config struct {
+	Enabled             bool                   `yaml:"enabled"`
+	DefaultClass        string                 `yaml:"default_class"`        // Class for unlabeled requests (default: interactive)
+	MaxConcurrent       int                    `yaml:"max_concurrent"`       // Requests in flight upstream; 0 = unlimited (no queueing)
+	ReservedInteractive int                    `yaml:"reserved_interactive"` // Slots only interactive requests may use
+	Classes             map[string]ClassConfig `yaml:"classes"`              // Per-class overrides keyed by class name
+}
+
+// ClassConfig tunes one class.
+type ClassConfig struct {
+	QueueTimeout time.Duration `yaml:"queue_timeout"` // Max wait for a slot before rejecting
+	MaxQueued    int           `yaml:"max_queued"`    // Requests waiting before new ones are rejected
+	// BudgetShedAt rejects this class once any enabled cost_control cap is this
+	// full (0-1). 0 uses the default (interactive never, background 0.9, batch 0.8);
+	// 1 sheds only at the cap itself.
+	BudgetShedAt float64 `yaml:"budget_shed_at"`
+}
+
+// Validate checks the priority configuration.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.DefaultClass != "" {
+		if _, ok := ParseClass(c.DefaultClass); !ok {
+			return fmt.Errorf("priority.default_class: unknown class %q (must be interactive, background or batch)", c.DefaultClass)
+		}
+	}
+	if c.MaxConcurrent < 0 {
+		return fmt.Errorf("priority.max_concurrent must be >= 0, got %d", c.MaxConcurrent)
+	}
+	if c.ReservedInteractive < 0 {
+		return fmt.Errorf("priority.reserved_interactive must be >= 0, got %d", c.ReservedInteractive)
+	}
+	if c.MaxConcurrent > 0 && c.ReservedInteractive >= c.MaxConcurrent {
+		return fmt.Errorf("priority.reserved_interactive (%d) must be less than max_concurrent (%d)", c.ReservedInteractive, c.MaxConcurrent)
+	}
+	for name, cc := range c.Classes {
+		if _, ok := ParseClass(name); !ok {
+			return fmt.Errorf("priority.classes: unknown class %q", name)
+		}
+		if cc.QueueTimeout < 0 || cc.MaxQueued < 0 {
+			return fmt.Errorf("priority.classes.%s: queue_timeout and max_queued must be >= 0", name)
+		}
+		if cc.BudgetShedAt < 0 || cc.BudgetShedAt > 1 {
+			return fmt.Errorf("priority.classes.%s.budget_shed_at must be between 0 and 1, got %g", name, cc.BudgetShedAt)
+		}
+	}
+	return nil
+}
+
+// class returns the effective settings for cl.
+func (c Config) class(cl Class) ClassConfig {
+	out := defaultClasses[cl]
+	for name, cc := range c.Classes {
+		if parsed, ok := ParseClass(name); !ok || parsed != cl {
+			continue
+		}
+		if cc.QueueTimeout > 0 {
+			out.QueueTimeout = cc.QueueTimeout
+		}
+		if cc.MaxQueued > 0 {
+			out.MaxQueued = cc.MaxQueued
+		}
+		if cc.BudgetShedAt > 0 {
+			out.BudgetShedAt = cc.BudgetShedAt
+		}
+	}
+	return out
+}
+
+// defaultClass returns the class for unlabeled requests.
+func (c Config) defaultClass() Class {
+	if cl, ok := ParseClass(c.DefaultClass); ok {
+		return cl
+	}
+	return Interactive
+}
+
+// Classify picks the class of a request: the X-Gateway-Priority header, else
+// the first session tag naming a class, else the configured default.
+func (c Config) Classify(h http.Header, sessionTags []string) Class {
+	if cl, ok := ParseClass(h.Get(HeaderPriority)); ok {
+		return cl
+	}
+	for _, tag := range sessionTags {
+		if cl, ok := ParseClass(tag); ok {
+			return cl
+		}
+	}
+	return c.defaultClass()
+}
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite".
+
+	"github.com/compresr/context-gateway/internal/statedir"
 )
 
 // PromptRecord represents a single recorded prompt.
@@ -70,14 +72,14 @@ type SQLiteStore struct {
 	mu sync.Mutex // serializes writes
 }
 
-// DefaultDBPath returns the default database file path:
-// ~/.config/context-gateway/prompt_history.db
+// DefaultDBPath returns the default database file path inside the state directory:
+// ~/.config/context-gateway/state/prompt_history.db
 func DefaultDBPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := statedir.DefaultDir()
 	if err != nil {
-		return "", fmt.Errorf("prompthistory: unable to determine home directory: %w", err)
+		return "", fmt.Errorf("prompthistory: %w", err)
 	}
-	return filepath.Join(home, ".config", "context-gateway", "prompt_history.db"), nil
+	return filepath.Join(dir, statedir.PromptHistoryDB), nil
 }
 
 // NewDefault opens (or creates) the prompt history database at the default path.
@@ -0,0 +1,211 @@
+// Package redaction scrubs secrets and personal data from bodies before they
+// are written to logs.
+//
+// DESIGN: Telemetry, compression comparison and trajectory logs store request
+// and response content verbatim. A Redactor runs built-in detectors (AWS keys,
+// bearer tokens, emails, ...) and operator-supplied regexes over that content
+// and replaces each match with [REDACTED:NAME]. Unlike the PII pipe, the
+// replacement is one-way: nothing is restored, and the request sent upstream
+// is unchanged. Only what reaches disk or the fleet collector is scrubbed.
+package redaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in detector names, as used in monitoring.redaction.detectors.
+const (
+	DetectorAWSAccessKey = "aws_access_key"
+	DetectorAWSSecretKey = "aws_secret_key"
+	DetectorBearerToken  = "bearer_token"
+	DetectorEmail        = "email"
+	DetectorAPIKey       = "api_key"
+	DetectorPrivateKey   = "private_key"
+)
+
+// BuiltinDetectors lists every built-in detector; it is the default set.
+var BuiltinDetectors = []string{
+	DetectorAWSAccessKey, DetectorAWSSecretKey, DetectorBearerToken,
+	DetectorEmail, DetectorAPIKey, DetectorPrivateKey,
+}
+
+// builtins maps detector names to their patterns. When a pattern has a
+// capture group, only the group is replaced, so "Bearer abc" keeps "Bearer ".
+var builtins = map[string]*regexp.Regexp{
+	DetectorAWSAccessKey: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	DetectorAWSSecretKey: regexp.MustCompile(`(?i)aws_?secret_?access_?key["'\s:=]+([A-Za-z0-9/+=]{40})`),
+	DetectorBearerToken:  regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{8,}=*)`),
+	DetectorEmail:        regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	DetectorAPIKey:       regexp.MustCompile(`\b(?:sk-(?:ant-)?[A-Za-z0-9_\-]{20,}|AIza[0-9A-Za-z_\-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abposr]-[A-Za-z0-9\-]{10,})`),
+	DetectorPrivateKey:   regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// Config configures log redaction.
+type Config struct {
+	Enabled   bool      `yaml:"enabled"`
+	Detectors []string  `yaml:"detectors"` // Built-in detectors to run (default: all)
+	Patterns  []Pattern `yaml:"patterns"`  // Additional regexes
+}
+
+// Pattern is an operator-supplied regex. Name labels the replacement.
+type Pattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// Validate reports unknown detectors and patterns that do not compile.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	for _, name := range c.Detectors {
+		if _, ok := builtins[name]; !ok {
+			return fmt.Errorf("unknown detector %q (valid: %s)", name, strings.Join(BuiltinDetectors, ", "))
+		}
+	}
+	for i, p := range c.Patterns {
+		if p.Name == "" {
+			return fmt.Errorf("patterns[%d]: name is required", i)
+		}
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			return fmt.Errorf("patterns[%d] (%s): %w", i, p.Name, err)
+		}
+	}
+	return nil
+}
+
+type rule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Redactor replaces sensitive matches in log content. A nil Redactor is
+// valid and leaves content unchanged.
+type Redactor struct {
+	rules []rule
+}
+
+// New builds a Redactor from config. It returns nil when redaction is disabled.
+func New(cfg Config) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = BuiltinDetectors
+	}
+	r := &Redactor{rules: make([]rule, 0, len(names)+len(cfg.Patterns))}
+	for _, name := range names {
+		r.rules = append(r.rules, rule{re: builtins[name], replacement: replacement(name)})
+	}
+	for _, p := range cfg.Patterns {
+		r.rules = append(r.rules, rule{re: regexp.MustCompile(p.Regex), replacement: replacement(p.Name)})
+	}
+	return r, nil
+}
+
+func replacement(name string) string {
+	return "[REDACTED:" + strings.ToUpper(name) + "]"
+}
+
+// String returns s with every match replaced.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, ru := range r.rules {
+		s = ru.apply(s)
+	}
+	return s
+}
+
+func (ru rule) apply(s string) string {
+	locs := ru.re.FindAllStringSubmatchIndex(s, -1)
+	if len(locs) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		start, end := loc[0], loc[1]
+		if len(loc) >= 4 && loc[2] >= 0 {
+			start, end = loc[2], loc[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(ru.replacement)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// JSON redacts every string literal in an encoded JSON document, keeping it
+// valid: matches never span a quote, and escaped content is matched in its
+// decoded form. Input that is not JSON is returned unchanged.
+func (r *Redactor) JSON(data []byte) []byte {
+	if r == nil {
+		return data
+	}
+	var out []byte
+	last := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			continue
+		}
+		end := stringEnd(data, i)
+		if end < 0 {
+			return data
+		}
+		lit := data[i : end+1]
+		if redacted, changed := r.literal(lit); changed {
+			out = append(out, data[last:i]...)
+			out = append(out, redacted...)
+			last = end + 1
+		}
+		i = end
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[last:]...)
+}
+
+// literal redacts one quoted JSON string and re-encodes it if it changed.
+func (r *Redactor) literal(lit []byte) ([]byte, bool) {
+	var s string
+	if err := json.Unmarshal(lit, &s); err != nil {
+		return nil, false
+	}
+	redacted := r.String(s)
+	if redacted == s {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redacted); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// stringEnd returns the index of the quote closing the string that opens at
+// start, or -1 if it is unterminated.
+func stringEnd(data []byte, start int) int {
+	for j := start + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j
+		}
+	}
+	return -1
+}
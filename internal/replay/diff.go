@@ -0,0 +1,213 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	diffContext  = 3   // Unchanged lines shown around each change
+	maxLineRunes = 200 // Longer rendered lines are cut
+	maxLCSCells  = 4_000_000
+)
+
+// Diff returns a unified line diff of two request bodies, or "" when they
+// render the same. JSON bodies are rendered one value per line with
+// multi-line strings (tool outputs) split into their lines, so a change to a
+// tool result shows as changed lines of that result.
+func Diff(a, b []byte, nameA, nameB string) string {
+	la, lb := renderLines(a), renderLines(b)
+
+	// Trim the common prefix and suffix; only the middle needs an LCS.
+	pre := 0
+	for pre < len(la) && pre < len(lb) && la[pre] == lb[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(la)-pre && suf < len(lb)-pre && la[len(la)-1-suf] == lb[len(lb)-1-suf] {
+		suf++
+	}
+	if pre == len(la) && pre == len(lb) {
+		return ""
+	}
+
+	ops := make([]diffOp, 0, len(la)+len(lb))
+	for _, l := range la[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, lcsDiff(la[pre:len(la)-suf], lb[pre:len(lb)-suf])...)
+	for _, l := range la[len(la)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+	writeHunks(&sb, ops)
+	return sb.String()
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// lcsDiff diffs a and b by longest common subsequence. Inputs too large for
+// the table are shown as one replaced block.
+func lcsDiff(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	if len(a)*len(b) > maxLCSCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{'+', l})
+		}
+		return ops
+	}
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// writeHunks writes the changed ops with diffContext lines around them.
+func writeHunks(sb *strings.Builder, ops []diffOp) {
+	lineA, lineB := 1, 1
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			lineA++
+			lineB++
+			start++
+			continue
+		}
+		// Extend the hunk until diffContext*2 unchanged lines separate changes.
+		end, same := start, 0
+		for i := start; i < len(ops) && same <= diffContext*2; i++ {
+			if ops[i].kind == ' ' {
+				same++
+			} else {
+				same, end = 0, i+1
+			}
+		}
+		from := max(start-diffContext, 0)
+		lineA -= start - from // Leading context was counted as unchanged
+		lineB -= start - from
+		to := min(end+diffContext, len(ops))
+		countA, countB := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[from:to] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		lineA += countA
+		lineB += countB
+		start = to
+	}
+}
+
+// renderLines renders a JSON body as indented "key: value" lines, with
+// multi-line strings split into one line each. Non-JSON bodies are split on
+// newlines.
+func renderLines(body []byte) []string {
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return clipLines(strings.Split(string(body), "\n"))
+	}
+	var lines []string
+	renderValue(&lines, "", "", v)
+	return clipLines(lines)
+}
+
+func renderValue(lines *[]string, indent, label string, v any) {
+	switch val := v.(type) {
+	case map[string]any:
+		*lines = append(*lines, indent+label+"{")
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			renderValue(lines, indent+"  ", k+": ", val[k])
+		}
+		*lines = append(*lines, indent+"}")
+	case []any:
+		*lines = append(*lines, indent+label+"[")
+		for _, item := range val {
+			renderValue(lines, indent+"  ", "", item)
+		}
+		*lines = append(*lines, indent+"]")
+	case string:
+		if !strings.Contains(val, "\n") {
+			*lines = append(*lines, indent+label+quote(val))
+			return
+		}
+		*lines = append(*lines, indent+label+"|")
+		for _, l := range strings.Split(val, "\n") {
+			*lines = append(*lines, indent+"  "+l)
+		}
+	default:
+		raw, _ := json.Marshal(val) // decoded JSON always marshals
+		*lines = append(*lines, indent+label+string(raw))
+	}
+}
+
+func quote(s string) string {
+	raw, _ := json.Marshal(s) // strings always marshal
+	return string(raw)
+}
+
+func clipLines(lines []string) []string {
+	for i, l := range lines {
+		if r := []rune(l); len(r) > maxLineRunes {
+			lines[i] = string(r[:maxLineRunes]) + fmt.Sprintf("… (+%d chars)", len(r)-maxLineRunes)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,319 @@
+// Package replay rebuilds recorded requests from session logs and runs them
+// through the compression pipes again, so a config or strategy change can be
+// compared with what the gateway forwarded at the time.
+//
+// Sources, in order of fidelity:
+//   - a captured request body (serve --record-fixtures, /debug/requests/{id}/original)
+//   - trajectory.json: one request per agent step, rebuilt from the steps before it
+//   - tool_output_compression.jsonl: one request per request_id, holding only the
+//     logged tool outputs (the rest of the conversation is not recorded)
+//
+// telemetry.jsonl records request metadata only and cannot be replayed.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// Rebuilt requests use the Anthropic Messages format.
+const (
+	AnthropicPath = "/v1/messages"
+	defaultModel  = "claude-sonnet-4-5"
+	maxTokens     = 4096
+)
+
+// Session log files Load looks for in a directory, best source first.
+const (
+	trajectoryFile  = "trajectory.json"
+	compressionFile = "tool_output_compression.jsonl"
+)
+
+// Request is a replayable request.
+type Request struct {
+	ID   string // "step-N", the logged request_id, or the file name
+	Path string // Request path; selects the adapter
+	Body []byte
+}
+
+// Load reads the requests recorded at path: a session log directory, a
+// trajectory.json, a tool_output_compression.jsonl or a captured request body.
+func Load(path string) ([]Request, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		for _, name := range []string{trajectoryFile, compressionFile} {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				return Load(filepath.Join(path, name))
+			}
+		}
+		return nil, fmt.Errorf("%s has no %s or %s", path, trajectoryFile, compressionFile)
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- user-specified log file
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Steps    json.RawMessage `json:"steps"`
+		Messages json.RawMessage `json:"messages"`
+		Input    json.RawMessage `json:"input"`
+		Contents json.RawMessage `json:"contents"`
+	}
+	if json.Unmarshal(data, &probe) == nil {
+		switch {
+		case probe.Steps != nil:
+			var t monitoring.Trajectory
+			if err := json.Unmarshal(data, &t); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if reqs := FromTrajectory(&t); len(reqs) > 0 {
+				return reqs, nil
+			}
+			return nil, fmt.Errorf("%s: no agent steps to replay", path)
+		case probe.Input != nil:
+			return []Request{{ID: filepath.Base(path), Path: "/v1/responses", Body: data}}, nil
+		case probe.Contents != nil:
+			return []Request{{ID: filepath.Base(path), Path: "/v1beta/models/gemini-2.5-flash:generateContent", Body: data}}, nil
+		case probe.Messages != nil:
+			// Recorded fixtures are named <adapter>_<agent>_NNNN.json.
+			reqPath := AnthropicPath
+			if strings.HasPrefix(filepath.Base(path), "openai_") {
+				reqPath = "/v1/chat/completions"
+			}
+			return []Request{{ID: filepath.Base(path), Path: reqPath, Body: data}}, nil
+		}
+	}
+
+	reqs, err := FromCompressionLog(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return reqs, nil
+}
+
+// =============================================================================
+// TRAJECTORY
+// =============================================================================
+
+// FromTrajectory returns one request per agent step: the conversation of the
+// steps before it. Tool results use the original content from the proxy's
+// compression records when present, else the step's observation.
+func FromTrajectory(t *monitoring.Trajectory) []Request {
+	originals := make(map[string]string)
+	for _, step := range t.Steps {
+		if step.ProxyInteraction == nil || step.ProxyInteraction.Compression == nil {
+			continue
+		}
+		for _, tc := range step.ProxyInteraction.Compression.ToolCompressions {
+			if tc.ToolCallID != "" && tc.OriginalContent != "" {
+				originals[tc.ToolCallID] = tc.OriginalContent
+			}
+		}
+	}
+	tools := make([]map[string]any, 0, len(t.Agent.ToolDefinitions))
+	for _, def := range t.Agent.ToolDefinitions {
+		schema := def.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		tools = append(tools, map[string]any{
+			"name":         def.Function.Name,
+			"description":  def.Function.Description,
+			"input_schema": schema,
+		})
+	}
+
+	var (
+		conv   conversation
+		system []string
+		reqs   []Request
+	)
+	for _, step := range t.Steps {
+		switch step.Source {
+		case monitoring.StepSourceSystem:
+			system = append(system, step.Message)
+		case monitoring.StepSourceUser:
+			conv.add("user", textBlock(step.Message))
+		case monitoring.StepSourceAgent:
+			if len(conv) > 0 {
+				model := firstNonEmpty(step.ModelName, t.Agent.ModelName, defaultModel)
+				reqs = append(reqs, Request{
+					ID:   "step-" + strconv.Itoa(step.StepID),
+					Path: AnthropicPath,
+					Body: conv.body(model, strings.Join(system, "\n\n"), tools),
+				})
+			}
+			conv.add("assistant", textBlock(step.Message))
+			for _, call := range step.ToolCalls {
+				conv.add("assistant", map[string]any{
+					"type":  "tool_use",
+					"id":    call.ToolCallID,
+					"name":  call.FunctionName,
+					"input": toolInput(call.Arguments),
+				})
+			}
+			if step.Observation != nil {
+				for _, res := range step.Observation.Results {
+					content, ok := originals[res.SourceCallID]
+					if !ok {
+						content = res.Content
+					}
+					conv.add("user", map[string]any{
+						"type":        "tool_result",
+						"tool_use_id": res.SourceCallID,
+						"content":     content,
+					})
+				}
+			}
+		}
+	}
+	return reqs
+}
+
+// toolInput returns a tool call's arguments as a JSON object.
+func toolInput(args any) any {
+	if s, ok := args.(string); ok {
+		var obj map[string]any
+		if json.Unmarshal([]byte(s), &obj) == nil {
+			return obj
+		}
+		return map[string]any{"arguments": s}
+	}
+	if args == nil {
+		return map[string]any{}
+	}
+	return args
+}
+
+// =============================================================================
+// COMPRESSION LOG
+// =============================================================================
+
+// FromCompressionLog returns one request per request_id in a
+// tool_output_compression.jsonl, in order of first appearance. Each request
+// holds the user query and the logged tool outputs as tool results.
+func FromCompressionLog(data []byte) ([]Request, error) {
+	type entry struct {
+		RequestID       string `json:"request_id"`
+		Model           string `json:"model"`
+		ToolName        string `json:"tool_name"`
+		Query           string `json:"query"`
+		OriginalContent string `json:"original_content"`
+	}
+	var order []string
+	groups := make(map[string][]entry)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e entry
+		if json.Unmarshal(line, &e) != nil || e.OriginalContent == "" {
+			continue
+		}
+		if _, ok := groups[e.RequestID]; !ok {
+			order = append(order, e.RequestID)
+		}
+		groups[e.RequestID] = append(groups[e.RequestID], e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(order) == 0 {
+		return nil, errors.New("no replayable requests: expected a request body, trajectory.json or tool_output_compression.jsonl (telemetry.jsonl has no request bodies)")
+	}
+
+	reqs := make([]Request, 0, len(order))
+	for _, id := range order {
+		entries := groups[id]
+		var conv conversation
+		conv.add("user", textBlock(firstNonEmpty(entries[0].Query, "Continue.")))
+		for i, e := range entries {
+			callID := fmt.Sprintf("toolu_replay_%02d", i+1)
+			conv.add("assistant", map[string]any{"type": "tool_use", "id": callID, "name": e.ToolName, "input": map[string]any{}})
+		}
+		for i, e := range entries {
+			callID := fmt.Sprintf("toolu_replay_%02d", i+1)
+			conv.add("user", map[string]any{"type": "tool_result", "tool_use_id": callID, "content": e.OriginalContent})
+		}
+		reqs = append(reqs, Request{
+			ID:   firstNonEmpty(id, "request-"+strconv.Itoa(len(reqs)+1)),
+			Path: AnthropicPath,
+			Body: conv.body(firstNonEmpty(entries[0].Model, defaultModel), "", nil),
+		})
+	}
+	return reqs, nil
+}
+
+// =============================================================================
+// MESSAGES
+// =============================================================================
+
+// message is an Anthropic message with block content.
+type message struct {
+	Role    string           `json:"role"`
+	Content []map[string]any `json:"content"`
+}
+
+// conversation builds alternating Anthropic messages.
+type conversation []message
+
+// add appends block to the last message when it has role, else starts a new
+// message. Nil blocks are ignored.
+func (c *conversation) add(role string, block map[string]any) {
+	if block == nil {
+		return
+	}
+	if n := len(*c); n > 0 && (*c)[n-1].Role == role {
+		(*c)[n-1].Content = append((*c)[n-1].Content, block)
+		return
+	}
+	*c = append(*c, message{Role: role, Content: []map[string]any{block}})
+}
+
+// body returns a Messages API request for the conversation so far.
+func (c conversation) body(model, system string, tools []map[string]any) []byte {
+	req := map[string]any{
+		"model":      model,
+		"max_tokens": maxTokens,
+		"messages":   c,
+	}
+	if system != "" {
+		req["system"] = system
+	}
+	if len(tools) > 0 {
+		req["tools"] = tools
+	}
+	body, _ := json.Marshal(req) // plain maps and strings always marshal
+	return body
+}
+
+func textBlock(text string) map[string]any {
+	if text == "" {
+		return nil
+	}
+	return map[string]any{"type": "text", "text": text}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// Result is one run of a request through the pipes.
+type Result struct {
+	Body       []byte
+	Tokens     int
+	Compressed int // Tool outputs the run compressed
+}
+
+// Original returns the request as recorded, before any pipe ran.
+func Original(req Request) Result {
+	return Result{Body: req.Body, Tokens: tokenizer.CountBytes(req.Body)}
+}
+
+// Run sends req through the pipes configured in cfg, the way the gateway does
+// for a live request, with a fresh in-memory shadow store. auth is passed to
+// strategies that call an LLM with the client's credentials.
+func Run(ctx context.Context, cfg *config.Config, req Request, auth authtypes.CapturedAuth) (Result, error) {
+	provider, adapter := adapters.IdentifyAndGetAdapter(adapters.NewRegistry(), req.Path, http.Header{})
+	if adapter == nil {
+		return Result{}, fmt.Errorf("no adapter for %s", req.Path)
+	}
+
+	st := store.NewMemoryStore(time.Hour)
+	defer func() { _ = st.Close() }()
+	router := gateway.NewRouter(cfg, st)
+	defer func() { _ = router.Close() }()
+
+	pipeCtx := gateway.NewPipelineContext(provider, adapter, req.Body, req.Path)
+	pipeCtx.RequestCtx = ctx
+	pipeCtx.RequestID = "replay-" + req.ID
+	pipeCtx.CapturedAuth = auth
+	pipeCtx.Model = adapter.ExtractModel(req.Body)
+	pipeCtx.TargetModel = pipeCtx.Model
+
+	body, _, err := router.ProcessAll(pipeCtx)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Body: body, Tokens: tokenizer.CountBytes(body)}
+	for _, c := range pipeCtx.ToolOutputCompressions {
+		if c.MappingStatus == "compressed" || c.MappingStatus == "cache_hit" {
+			res.Compressed++
+		}
+	}
+	return res, nil
+}
+
+// WithStrategy returns a copy of cfg with the tool_output pipe enabled and
+// set to strategy.
+func WithStrategy(cfg *config.Config, strategy string) (*config.Config, error) {
+	alt := *cfg
+	alt.Pipes.ToolOutput.Enabled = true
+	alt.Pipes.ToolOutput.Strategy = strategy
+	if err := alt.Validate(); err != nil {
+		return nil, err
+	}
+	return &alt, nil
+}
@@ -0,0 +1,175 @@
+package sessionstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sweeper is per-session state managed by a Collector. *Store implements it
+// through its wrappers; stores with their own layout (e.g. preemptive
+// sessions) implement it directly.
+type Sweeper interface {
+	// Sweep removes sessions idle past the store's TTL and returns how many.
+	Sweep() int
+
+	// Expire removes one session immediately. Returns false if it was not held.
+	Expire(sessionID string) bool
+
+	// Len returns the number of stored sessions.
+	Len() int
+}
+
+// StoreStats is one registered store's GC activity.
+type StoreStats struct {
+	Name         string    `json:"name"`
+	Sessions     int       `json:"sessions"`
+	Sweeps       int64     `json:"sweeps"`
+	Evicted      int64     `json:"evicted_total"`       // Removed by sweeps (idle TTL)
+	ForceExpired int64     `json:"force_expired_total"` // Removed by Expire
+	LastSweep    time.Time `json:"last_sweep"`
+	LastSweepMs  float64   `json:"last_sweep_ms"`
+	LastEvicted  int       `json:"last_evicted"`
+}
+
+// Collector sweeps every registered store on one schedule, concurrently,
+// and keeps eviction counts per store. It replaces per-store cleanup
+// goroutines so idle session state has one owner and one set of metrics.
+type Collector struct {
+	interval time.Duration
+
+	mu     sync.RWMutex
+	stores []*managed
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// managed is a registered store plus its stats.
+type managed struct {
+	name  string
+	store Sweeper
+
+	mu    sync.Mutex // serializes sweeps of this store; guards stats
+	stats StoreStats
+}
+
+// NewCollector creates a collector that sweeps every interval once started.
+func NewCollector(interval time.Duration) *Collector {
+	return &Collector{interval: interval, stopCh: make(chan struct{})}
+}
+
+// Register adds a store under name (used in stats and logs).
+func (c *Collector) Register(name string, s Sweeper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stores = append(c.stores, &managed{name: name, store: s, stats: StoreStats{Name: name}})
+}
+
+// Start begins periodic sweeping. No-op when interval <= 0 or already started.
+func (c *Collector) Start() {
+	if c.interval <= 0 {
+		return
+	}
+	c.startOnce.Do(func() {
+		c.wg.Add(1)
+		go c.loop()
+	})
+}
+
+// Stop ends periodic sweeping and waits for an in-progress sweep. Safe to call multiple times.
+func (c *Collector) Stop() {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+	c.wg.Wait()
+}
+
+// Sweep sweeps all stores concurrently and returns the total sessions evicted.
+func (c *Collector) Sweep() int {
+	stores := c.snapshot()
+
+	var wg sync.WaitGroup
+	evicted := make([]int, len(stores))
+	for i, m := range stores {
+		wg.Add(1)
+		go func(i int, m *managed) {
+			defer wg.Done()
+			evicted[i] = m.sweep()
+		}(i, m)
+	}
+	wg.Wait()
+
+	total := 0
+	for i, n := range evicted {
+		total += n
+		if n > 0 {
+			log.Debug().Str("store", stores[i].name).Int("evicted", n).Msg("session gc: swept idle sessions")
+		}
+	}
+	return total
+}
+
+// Expire removes sessionID from every store and returns the names of the
+// stores that held it.
+func (c *Collector) Expire(sessionID string) []string {
+	var expired []string
+	for _, m := range c.snapshot() {
+		m.mu.Lock()
+		if m.store.Expire(sessionID) {
+			m.stats.ForceExpired++
+			expired = append(expired, m.name)
+		}
+		m.mu.Unlock()
+	}
+	return expired
+}
+
+// Stats returns per-store GC stats in registration order.
+func (c *Collector) Stats() []StoreStats {
+	stores := c.snapshot()
+	stats := make([]StoreStats, 0, len(stores))
+	for _, m := range stores {
+		m.mu.Lock()
+		s := m.stats
+		m.mu.Unlock()
+		s.Sessions = m.store.Len()
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func (c *Collector) snapshot() []*managed {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]*managed(nil), c.stores...)
+}
+
+func (c *Collector) loop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			c.Sweep()
+		}
+	}
+}
+
+// sweep runs one sweep of the store and records it.
+func (m *managed) sweep() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := time.Now()
+	n := m.store.Sweep()
+	m.stats.Sweeps++
+	m.stats.Evicted += int64(n)
+	m.stats.LastEvicted = n
+	m.stats.LastSweep = start
+	m.stats.LastSweepMs = float64(time.Since(start).Microseconds()) / 1000
+	return n
+}
@@ -0,0 +1,84 @@
+// Snapshot export and import.
+//
+// Export encodes every live session as JSON, each value under its own lock so
+// no half-written update is captured. Import restores those records with their
+// original last-update times, so a restored session expires when it would
+// have on the instance that exported it.
+package sessionstore
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Record is one exported session.
+type Record[T any] struct {
+	ID      string    `json:"id"`
+	Value   T         `json:"value"`
+	Touched time.Time `json:"touched"`
+}
+
+// Export returns a JSON array of Records for every live session, sorted by
+// ID so an unchanged store exports identical bytes. Only exported fields of T
+// are kept.
+func (s *Store[T]) Export() (json.RawMessage, error) {
+	type encoded struct {
+		id   string
+		data json.RawMessage
+	}
+	var encodedRecords []encoded
+	var encErr error
+	s.rangeEntries(func(id string, e *entry[T]) {
+		if encErr != nil {
+			return
+		}
+		b, err := json.Marshal(Record[T]{ID: id, Value: e.value, Touched: e.touched})
+		if err != nil {
+			encErr = err
+			return
+		}
+		encodedRecords = append(encodedRecords, encoded{id, b})
+	})
+	if encErr != nil {
+		return nil, encErr
+	}
+	sort.Slice(encodedRecords, func(i, j int) bool { return encodedRecords[i].id < encodedRecords[j].id })
+	records := make([]json.RawMessage, len(encodedRecords))
+	for i, r := range encodedRecords {
+		records[i] = r.data
+	}
+	return json.Marshal(records)
+}
+
+// Import restores sessions from an Export, replacing sessions with the same
+// ID. Records already past the TTL are skipped. Returns how many were restored.
+func (s *Store[T]) Import(data json.RawMessage) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	var records []Record[T]
+	if err := json.Unmarshal(data, &records); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, rec := range records {
+		if rec.ID == "" || (s.ttl > 0 && now.Sub(rec.Touched) > s.ttl) {
+			continue
+		}
+		e := &entry[T]{value: rec.Value, touched: rec.Touched}
+		s.mu.Lock()
+		old, ok := s.entries[rec.ID]
+		s.entries[rec.ID] = e
+		s.mu.Unlock()
+		if ok {
+			old.mu.Lock()
+			old.removed = true
+			old.mu.Unlock()
+		}
+		restored++
+	}
+	return restored, nil
+}
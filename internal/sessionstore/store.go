@@ -0,0 +1,259 @@
+// Package sessionstore provides a concurrent, TTL-bounded map of per-session state.
+//
+// The gateway keeps several maps keyed by session ID (tool discovery state,
+// sticky auth fallback, cost tracking). They all follow the same locking rules
+// through this package:
+//
+//   - The store lock is held only to find, create, or remove an entry.
+//   - Each entry has its own mutex guarding its value, so requests for
+//     different sessions never contend and requests for the same session
+//     are serialized.
+//   - Values are only reachable inside a callback that runs under the entry
+//     lock. Callers copy out what they need; no pointer into session state
+//     escapes, so one request can never observe another's half-written update.
+package sessionstore
+
+import (
+	"sync"
+	"time"
+)
+
+// Store holds one value of type T per session ID.
+// Entries not updated within the TTL are expired. Thread-safe.
+type Store[T any] struct {
+	mu      sync.RWMutex
+	entries map[string]*entry[T]
+	ttl     time.Duration
+	newFn   func(sessionID string) T
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// entry is a single session's value plus its lock.
+type entry[T any] struct {
+	mu      sync.Mutex
+	value   T
+	touched time.Time // last Update; guarded by mu
+	removed bool      // set under mu when the entry leaves the map
+}
+
+// New creates a store whose entries expire ttl after their last Update.
+// newFn builds the initial value for a session on first Update (nil = zero value).
+// When cleanupInterval > 0 a background goroutine sweeps expired entries;
+// call Stop to end it.
+func New[T any](ttl, cleanupInterval time.Duration, newFn func(sessionID string) T) *Store[T] {
+	s := &Store[T]{
+		entries: make(map[string]*entry[T]),
+		ttl:     ttl,
+		newFn:   newFn,
+		stopCh:  make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go s.cleanupLoop(cleanupInterval)
+	}
+	return s
+}
+
+// Update runs fn on the session's value under the session lock, creating the
+// session if needed, and refreshes its TTL. An expired session not yet swept
+// starts over from a fresh value, as if it had been removed.
+func (s *Store[T]) Update(sessionID string, fn func(v *T)) {
+	for {
+		e := s.getOrCreate(sessionID)
+		e.mu.Lock()
+		if e.removed {
+			// Swept or reset between lookup and lock: retry on the live entry.
+			e.mu.Unlock()
+			continue
+		}
+		if s.expiredLocked(e, time.Now()) {
+			e.value = s.newValue(sessionID)
+		}
+		fn(&e.value)
+		e.touched = time.Now()
+		e.mu.Unlock()
+		return
+	}
+}
+
+// View runs fn on the session's value under the session lock without creating
+// it or refreshing its TTL. Returns false (and does not call fn) when the
+// session does not exist or has expired. fn may be nil to test for presence.
+func (s *Store[T]) View(sessionID string, fn func(v *T)) bool {
+	s.mu.RLock()
+	e, ok := s.entries[sessionID]
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	e.mu.Lock()
+	if e.removed {
+		e.mu.Unlock()
+		return false
+	}
+	if s.expiredLocked(e, time.Now()) {
+		e.mu.Unlock()
+		s.deleteExpired(sessionID, e)
+		return false
+	}
+	if fn != nil {
+		fn(&e.value)
+	}
+	e.mu.Unlock()
+	return true
+}
+
+// Range calls fn for every live session, each under its own lock.
+// Sessions created during the call may or may not be visited.
+func (s *Store[T]) Range(fn func(sessionID string, v *T)) {
+	s.rangeEntries(func(id string, e *entry[T]) { fn(id, &e.value) })
+}
+
+// rangeEntries is Range with access to the entry (and its touched time).
+func (s *Store[T]) rangeEntries(fn func(sessionID string, e *entry[T])) {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.entries))
+	entries := make([]*entry[T], 0, len(s.entries))
+	for id, e := range s.entries {
+		ids = append(ids, id)
+		entries = append(entries, e)
+	}
+	s.mu.RUnlock()
+
+	now := time.Now()
+	for i, e := range entries {
+		e.mu.Lock()
+		if !e.removed && !s.expiredLocked(e, now) {
+			fn(ids[i], e)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Delete removes a session. Returns false if it did not exist.
+func (s *Store[T]) Delete(sessionID string) bool {
+	s.mu.Lock()
+	e, ok := s.entries[sessionID]
+	if ok {
+		delete(s.entries, sessionID)
+	}
+	s.mu.Unlock()
+	if ok {
+		e.mu.Lock()
+		e.removed = true
+		e.mu.Unlock()
+	}
+	return ok
+}
+
+// deleteExpired removes e if it is still the live entry for sessionID and
+// still expired. View finds expiry under the entry lock alone, so an Update
+// may refresh the entry, or a Sweep replace it, before the store lock is held.
+func (s *Store[T]) deleteExpired(sessionID string, e *entry[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[sessionID] != e {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s.expiredLocked(e, time.Now()) {
+		e.removed = true
+		delete(s.entries, sessionID)
+	}
+}
+
+// Len returns the number of stored sessions (including not-yet-swept expired ones).
+func (s *Store[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Reset removes all sessions.
+func (s *Store[T]) Reset() {
+	s.mu.Lock()
+	old := s.entries
+	s.entries = make(map[string]*entry[T])
+	s.mu.Unlock()
+	for _, e := range old {
+		e.mu.Lock()
+		e.removed = true
+		e.mu.Unlock()
+	}
+}
+
+// Sweep removes every expired session and returns how many it removed.
+// Called periodically by the cleanup loop or a Collector.
+func (s *Store[T]) Sweep() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, e := range s.entries {
+		e.mu.Lock()
+		if s.expiredLocked(e, now) {
+			e.removed = true
+			delete(s.entries, id)
+			removed++
+		}
+		e.mu.Unlock()
+	}
+	return removed
+}
+
+// Stop ends the cleanup goroutine. Safe to call multiple times.
+func (s *Store[T]) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// getOrCreate returns the live entry for sessionID, creating it if missing.
+func (s *Store[T]) getOrCreate(sessionID string) *entry[T] {
+	s.mu.RLock()
+	e, ok := s.entries[sessionID]
+	s.mu.RUnlock()
+	if ok {
+		return e
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[sessionID]; ok {
+		return e
+	}
+	e = &entry[T]{value: s.newValue(sessionID), touched: time.Now()}
+	s.entries[sessionID] = e
+	return e
+}
+
+// newValue builds the initial value for sessionID.
+func (s *Store[T]) newValue(sessionID string) T {
+	var v T
+	if s.newFn != nil {
+		v = s.newFn(sessionID)
+	}
+	return v
+}
+
+// expiredLocked reports whether e is past its TTL. Caller holds e.mu.
+func (s *Store[T]) expiredLocked(e *entry[T], now time.Time) bool {
+	return s.ttl > 0 && now.Sub(e.touched) > s.ttl
+}
+
+func (s *Store[T]) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package statedir
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SelfMetricsFile holds the gateway's lifetime counters inside the state directory.
+const SelfMetricsFile = "self_metrics.json"
+
+// SelfMetrics are cumulative gateway counters. Several gateways may share one
+// state directory, so each adds its own increments with AddSelfMetrics rather
+// than overwriting the totals.
+type SelfMetrics struct {
+	Requests     int64     `json:"requests"`
+	TokensSaved  int64     `json:"tokens_saved"`
+	CostSavedUSD float64   `json:"cost_saved_usd"`
+	Starts       int64     `json:"starts"`     // Gateway processes started
+	Since        time.Time `json:"since"`      // First recorded start
+	UpdatedAt    time.Time `json:"updated_at"` // Last write
+}
+
+// Plus returns m with d's counters added. Since and UpdatedAt keep m's values.
+func (m SelfMetrics) Plus(d SelfMetrics) SelfMetrics {
+	m.Requests += d.Requests
+	m.TokensSaved += d.TokensSaved
+	m.CostSavedUSD += d.CostSavedUSD
+	m.Starts += d.Starts
+	return m
+}
+
+// selfMetricsMu serializes writers in this process; the lock file covers others.
+var selfMetricsMu sync.Mutex
+
+// ReadSelfMetrics returns the lifetime counters in dir. A missing file is all zeros.
+func ReadSelfMetrics(dir string) (SelfMetrics, error) {
+	var m SelfMetrics
+	data, err := os.ReadFile(filepath.Join(dir, SelfMetricsFile)) // #nosec G304 -- fixed name under state dir
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("statedir: read self metrics: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return SelfMetrics{}, fmt.Errorf("statedir: parse %s: %w", filepath.Join(dir, SelfMetricsFile), err)
+	}
+	return m, nil
+}
+
+// AddSelfMetrics adds delta to the lifetime counters in dir and returns the
+// new totals. A corrupt file is replaced rather than blocking the gateway.
+func AddSelfMetrics(dir string, delta SelfMetrics) (SelfMetrics, error) {
+	selfMetricsMu.Lock()
+	defer selfMetricsMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o750); err != nil { // #nosec G301
+		return SelfMetrics{}, fmt.Errorf("statedir: create %s: %w", dir, err)
+	}
+	var (
+		total SelfMetrics
+		err   error
+	)
+	withSelfMetricsLock(dir, func() {
+		total, err = ReadSelfMetrics(dir)
+		if err != nil {
+			total = SelfMetrics{}
+		}
+		total = total.Plus(delta)
+		now := time.Now().UTC()
+		if total.Since.IsZero() {
+			total.Since = now
+		}
+		total.UpdatedAt = now
+		err = writeSelfMetrics(dir, total)
+	})
+	return total, err
+}
+
+// writeSelfMetrics atomically replaces dir's self metrics file.
+func writeSelfMetrics(dir string, m SelfMetrics) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("statedir: encode self metrics: %w", err)
+	}
+	tmp := filepath.Join(dir, fmt.Sprintf("%s.%d.tmp", SelfMetricsFile, os.Getpid()))
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("statedir: write self metrics: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, SelfMetricsFile)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("statedir: write self metrics: %w", err)
+	}
+	return nil
+}
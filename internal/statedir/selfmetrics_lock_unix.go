@@ -0,0 +1,38 @@
+//go:build !windows
+
+package statedir
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// withSelfMetricsLock runs fn while holding an exclusive lock on the self
+// metrics lock file, so gateways sharing dir don't lose each other's updates.
+func withSelfMetricsLock(dir string, fn func()) {
+	lf, err := os.OpenFile(filepath.Join(dir, SelfMetricsFile+".lock"), os.O_CREATE|os.O_RDWR, 0o600) // #nosec G304 -- fixed name under state dir
+	if err != nil {
+		log.Warn().Err(err).Msg("statedir: failed to open self metrics lock, proceeding without lock")
+		fn()
+		return
+	}
+	defer lf.Close()
+
+	fd := lf.Fd()
+	const maxIntVal = uintptr(^uint(0) >> 1)
+	if fd > maxIntVal {
+		fn()
+		return
+	}
+	if err := syscall.Flock(int(fd), syscall.LOCK_EX); err != nil {
+		log.Warn().Err(err).Msg("statedir: failed to lock self metrics, proceeding without lock")
+		fn()
+		return
+	}
+	defer syscall.Flock(int(fd), syscall.LOCK_UN) //nolint:errcheck
+
+	fn()
+}
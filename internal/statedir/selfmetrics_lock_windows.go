@@ -0,0 +1,9 @@
+//go:build windows
+
+package statedir
+
+// withSelfMetricsLock runs fn. Windows does not support syscall.Flock, so
+// cross-process locking is skipped; the in-process mutex still applies.
+func withSelfMetricsLock(_ string, fn func()) {
+	fn()
+}
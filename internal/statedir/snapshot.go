@@ -0,0 +1,113 @@
+package statedir
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SnapshotFile is the default snapshot file name inside the state directory.
+const SnapshotFile = "snapshot.enc"
+
+// snapshotMagic prefixes every encrypted snapshot (format version 1).
+var snapshotMagic = []byte("CGSNAP1\n")
+
+const (
+	snapshotSaltSize   = 16
+	snapshotKDFRounds  = 600_000 // PBKDF2-HMAC-SHA256, OWASP 2023 recommendation
+	snapshotKeySize    = 32      // AES-256
+	maxSnapshotInflate = 4 << 30 // Refuse to inflate past 4GB
+)
+
+// ErrSnapshotDecrypt is returned by OpenSnapshot for a wrong passphrase or a
+// corrupted file.
+var ErrSnapshotDecrypt = errors.New("snapshot: wrong passphrase or corrupted file")
+
+// SealSnapshot gzips plaintext and encrypts it with AES-256-GCM under a key
+// derived from passphrase. The output is magic | salt | nonce | ciphertext.
+func SealSnapshot(plaintext []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("snapshot: passphrase required")
+	}
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	if _, err := zw.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("snapshot: compress: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("snapshot: compress: %w", err)
+	}
+
+	salt := make([]byte, snapshotSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("snapshot: salt: %w", err)
+	}
+	aead, err := snapshotAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("snapshot: nonce: %w", err)
+	}
+
+	header := make([]byte, 0, len(snapshotMagic)+len(salt)+len(nonce))
+	header = append(append(append(header, snapshotMagic...), salt...), nonce...)
+	// The header is authenticated as additional data.
+	return aead.Seal(header, nonce, zipped.Bytes(), header), nil
+}
+
+// OpenSnapshot reverses SealSnapshot. It returns ErrSnapshotDecrypt when the
+// passphrase is wrong or the data was modified.
+func OpenSnapshot(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, snapshotMagic) {
+		return nil, errors.New("snapshot: not a context-gateway snapshot")
+	}
+	rest := data[len(snapshotMagic):]
+	if len(rest) < snapshotSaltSize {
+		return nil, ErrSnapshotDecrypt
+	}
+	salt := rest[:snapshotSaltSize]
+	aead, err := snapshotAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(snapshotMagic) + snapshotSaltSize + aead.NonceSize()
+	if len(data) < headerLen {
+		return nil, ErrSnapshotDecrypt
+	}
+	header, nonce := data[:headerLen], data[headerLen-aead.NonceSize():headerLen]
+	zipped, err := aead.Open(nil, nonce, data[headerLen:], header)
+	if err != nil {
+		return nil, ErrSnapshotDecrypt
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: decompress: %w", err)
+	}
+	plaintext, err := io.ReadAll(io.LimitReader(zr, maxSnapshotInflate))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: decompress: %w", err)
+	}
+	return plaintext, nil
+}
+
+func snapshotAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, snapshotKDFRounds, snapshotKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,193 @@
+// Package statedir manages the gateway's versioned state directory.
+//
+// Persisted state (prompt history and lifetime self-metrics today; sessions,
+// costs and shadow refs as they gain persistence) lives under one directory
+// with a state.json manifest recording its layout version. Open brings an
+// older directory up to CurrentVersion by running migrations in order, and
+// refuses to touch a directory written by a newer binary — reading a layout we
+// don't understand risks corrupting it. Reset moves the directory aside so the
+// gateway can start fresh.
+package statedir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/userdir"
+)
+
+// CurrentVersion is the state layout version this binary reads and writes.
+const CurrentVersion = 1
+
+// ManifestFile is the manifest file name inside the state directory.
+const ManifestFile = "state.json"
+
+// PromptHistoryDB is the prompt history database file name inside the state directory.
+const PromptHistoryDB = "prompt_history.db"
+
+// ShadowStoreDB is the sqlite shadow store database file name inside the state directory.
+const ShadowStoreDB = "shadow_store.db"
+
+// SessionsDB is the session persistence database file name inside the state directory.
+const SessionsDB = "sessions.db"
+
+// ErrStateTooNew is returned by Open when the directory was written by a newer binary.
+var ErrStateTooNew = errors.New("state directory is newer than this binary")
+
+// Manifest is the content of state.json.
+type Manifest struct {
+	Version   int       `json:"version"`
+	WrittenBy string    `json:"written_by,omitempty"` // Binary version that last wrote the manifest
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Migration upgrades a state directory from version To-1 to To.
+type Migration struct {
+	To          int
+	Description string
+	Apply       func(dir string) error
+}
+
+// migrations lists every migration in order; migrations[i].To == i+1.
+var migrations = []Migration{
+	{To: 1, Description: "adopt legacy prompt history database", Apply: adoptLegacyPromptHistory},
+}
+
+// DefaultDir returns the state directory inside userdir.Dir, e.g.
+// ~/.config/context-gateway/state.
+func DefaultDir() (string, error) {
+	dir, err := userdir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("statedir: %w", err)
+	}
+	return filepath.Join(dir, "state"), nil
+}
+
+// Open prepares dir for use by a binary of version binaryVersion: it creates
+// the directory if missing, runs pending migrations, and returns the final
+// manifest. It returns an error wrapping ErrStateTooNew, with guidance, if
+// the directory's version is greater than CurrentVersion.
+func Open(dir, binaryVersion string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil { // #nosec G301
+		return nil, fmt.Errorf("statedir: create %s: %w", dir, err)
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Version > CurrentVersion {
+		return nil, fmt.Errorf("%w: %s has state version %d (written by %s), this binary supports up to %d. "+
+			"Upgrade context-gateway, or start with --reset-state to move the existing state aside",
+			ErrStateTooNew, dir, m.Version, orUnknown(m.WrittenBy), CurrentVersion)
+	}
+
+	for _, mig := range migrations {
+		if mig.To <= m.Version {
+			continue
+		}
+		log.Info().Int("from", m.Version).Int("to", mig.To).Str("migration", mig.Description).Msg("statedir: migrating")
+		if err := mig.Apply(dir); err != nil {
+			return nil, fmt.Errorf("statedir: migration to v%d (%s): %w", mig.To, mig.Description, err)
+		}
+		m.Version = mig.To
+		m.WrittenBy = binaryVersion
+		// Persist after every step so a failed later migration resumes from here.
+		if err := writeManifest(dir, m); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.WrittenBy == "" {
+		m.WrittenBy = binaryVersion
+		if err := writeManifest(dir, m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Reset moves dir aside to dir.reset-<timestamp> and returns the new path.
+// Returns "" if dir does not exist.
+func Reset(dir string) (string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", nil
+	}
+	backup := fmt.Sprintf("%s.reset-%s", dir, time.Now().Format("20060102-150405"))
+	if err := os.Rename(dir, backup); err != nil {
+		return "", fmt.Errorf("statedir: move %s aside: %w", dir, err)
+	}
+	return backup, nil
+}
+
+// readManifest reads dir's manifest. A missing manifest is version 0 (legacy or fresh).
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile)) // #nosec G304 -- fixed name under state dir
+	if os.IsNotExist(err) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("statedir: read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("statedir: parse %s: %w (start with --reset-state to move the directory aside)",
+			filepath.Join(dir, ManifestFile), err)
+	}
+	return &m, nil
+}
+
+// writeManifest atomically replaces dir's manifest.
+func writeManifest(dir string, m *Manifest) error {
+	m.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("statedir: encode manifest: %w", err)
+	}
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("statedir: write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, ManifestFile)); err != nil {
+		return fmt.Errorf("statedir: write manifest: %w", err)
+	}
+	return nil
+}
+
+// adoptLegacyPromptHistory moves prompt_history.db (and its WAL files) from the
+// pre-statedir location in the parent config directory into dir.
+func adoptLegacyPromptHistory(dir string) error {
+	legacy := filepath.Join(filepath.Dir(dir), PromptHistoryDB)
+	target := filepath.Join(dir, PromptHistoryDB)
+	if _, err := os.Stat(legacy); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(target); err == nil {
+		log.Warn().Str("legacy", legacy).Msg("statedir: prompt history already present in state dir, leaving legacy copy")
+		return nil
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		src := legacy + suffix
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(src, target+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown version"
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+// Backend selection for the shadow store.
+//
+// memory (default) keeps everything in process and loses it on restart.
+// sqlite and redis persist shadow refs, compressed cache entries, expansion
+// records and field refs with their TTLs, so expand_context keeps resolving
+// refs handed out before a restart. redis also lets several gateway instances
+// share one store.
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+// Backend types for Config.Type.
+const (
+	TypeMemory = "memory"
+	TypeSQLite = "sqlite"
+	TypeRedis  = "redis"
+)
+
+// Redis defaults.
+const (
+	DefaultRedisAddr      = "localhost:6379"
+	DefaultRedisKeyPrefix = "context-gateway:shadow:"
+	DefaultRedisTimeout   = 5 * time.Second
+)
+
+// Config contains shadow context store settings.
+type Config struct {
+	Type  string        `yaml:"type"`            // memory | sqlite | redis
+	TTL   time.Duration `yaml:"ttl"`             // Time-to-live for entries
+	Path  string        `yaml:"path,omitempty"`  // sqlite: database file (default: <state dir>/shadow_store.db)
+	Redis RedisConfig   `yaml:"redis,omitempty"` // redis: server connection
+
+	OriginalTTL   time.Duration `yaml:"original_ttl,omitempty"`   // Originals and field refs (default: 5h)
+	CompressedTTL time.Duration `yaml:"compressed_ttl,omitempty"` // Compressed versions and expansion records (default: 24h)
+
+	// memory only: caps on the original cache. The least recently used
+	// originals are evicted first.
+	MaxEntries int   `yaml:"max_entries,omitempty"` // Default: 1000
+	MaxBytes   int64 `yaml:"max_bytes,omitempty"`   // Bytes held after gzip (0 = unlimited)
+}
+
+// RedisConfig locates the Redis server used by the redis backend.
+type RedisConfig struct {
+	Addr      string        `yaml:"addr,omitempty"`       // host:port (default: localhost:6379)
+	Username  string        `yaml:"username,omitempty"`   // ACL user (Redis 6+); empty uses the default user
+	Password  string        `yaml:"password,omitempty"`   // AUTH password
+	DB        int           `yaml:"db,omitempty"`         // Database number for SELECT
+	KeyPrefix string        `yaml:"key_prefix,omitempty"` // Prefix for every key (default: context-gateway:shadow:)
+	Timeout   time.Duration `yaml:"timeout,omitempty"`    // Dial and per-command timeout (default: 5s)
+}
+
+// Validate checks the store configuration.
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeMemory, TypeSQLite, TypeRedis:
+	case "":
+		return fmt.Errorf("store.type is required")
+	default:
+		return fmt.Errorf("invalid store.type: %q (must be %q, %q or %q)", c.Type, TypeMemory, TypeSQLite, TypeRedis)
+	}
+	if c.TTL == 0 {
+		return fmt.Errorf("store.ttl is required")
+	}
+	if c.OriginalTTL < 0 || c.CompressedTTL < 0 {
+		return fmt.Errorf("store.original_ttl and store.compressed_ttl must be >= 0")
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("store.max_entries must be >= 0, got %d", c.MaxEntries)
+	}
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("store.max_bytes must be >= 0, got %d", c.MaxBytes)
+	}
+	if c.Redis.DB < 0 {
+		return fmt.Errorf("store.redis.db must be >= 0, got %d", c.Redis.DB)
+	}
+	if c.Redis.Timeout < 0 {
+		return fmt.Errorf("store.redis.timeout must be >= 0, got %s", c.Redis.Timeout)
+	}
+	return nil
+}
+
+// TTLs returns the original and compressed TTLs, defaulting to the V2 dual
+// TTLs (DefaultOriginalTTL, DefaultCompressedTTL).
+func (c Config) TTLs() (original, compressed time.Duration) {
+	original, compressed = c.OriginalTTL, c.CompressedTTL
+	if original == 0 {
+		original = DefaultOriginalTTL
+	}
+	if compressed == 0 {
+		compressed = DefaultCompressedTTL
+	}
+	return original, compressed
+}
+
+// New opens the backend c selects with its TTLs and, for memory, size caps.
+func New(c Config) (Store, error) {
+	originalTTL, compressedTTL := c.TTLs()
+	switch c.Type {
+	case TypeSQLite:
+		path := c.Path
+		if path == "" {
+			dir, err := statedir.DefaultDir()
+			if err != nil {
+				return nil, fmt.Errorf("store: %w", err)
+			}
+			path = filepath.Join(dir, statedir.ShadowStoreDB)
+		}
+		return NewSQLiteStore(path, originalTTL, compressedTTL)
+	case TypeRedis:
+		return NewRedisStore(c.Redis, originalTTL, compressedTTL)
+	default:
+		return NewMemoryStoreWithDualTTL(originalTTL, compressedTTL).WithLimits(c.MaxEntries, c.MaxBytes), nil
+	}
+}
@@ -0,0 +1,102 @@
+// Transparent gzip compression of stored values, with byte accounting.
+//
+// Original tool outputs are the bulk of the store's memory and compress well
+// (logs, JSON, source). Values of MinCompressSize or more are gzipped on write
+// and inflated on read; callers always see the original string. Each cache
+// tracks logical (caller-visible) and physical (held) bytes so occupancy
+// reflects real memory use.
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+)
+
+// MinCompressSize is the smallest value the store gzips. Shorter values are
+// kept verbatim: header overhead and CPU cost outweigh the savings.
+const MinCompressSize = 1024
+
+// gzipWriters pools writers; a gzip.Writer allocates ~800KB of state.
+var gzipWriters = sync.Pool{
+	New: func() any {
+		zw, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return zw
+	},
+}
+
+// ByteUsage is one cache's footprint. LogicalBytes is what callers stored;
+// PhysicalBytes is what the store holds after compression.
+type ByteUsage struct {
+	Entries       int   `json:"entries"`
+	LogicalBytes  int64 `json:"logical_bytes"`
+	PhysicalBytes int64 `json:"physical_bytes"`
+}
+
+// Occupancy reports the byte footprint of the value caches.
+type Occupancy struct {
+	Original   ByteUsage `json:"original"`
+	Compressed ByteUsage `json:"compressed"`
+}
+
+// byteCounter accumulates logical and physical bytes (guarded by MemoryStore.mu).
+type byteCounter struct {
+	logical  int64
+	physical int64
+}
+
+func (c *byteCounter) add(e entry) {
+	c.logical += int64(e.size)
+	c.physical += int64(len(e.value))
+}
+
+func (c *byteCounter) remove(e entry) {
+	c.logical -= int64(e.size)
+	c.physical -= int64(len(e.value))
+}
+
+// encodeEntry builds an entry for value, gzipped when that saves at least 1/8
+// of its size. Already-compressed or random data is kept verbatim.
+func encodeEntry(value string) entry {
+	e := entry{value: value, size: len(value)}
+	if len(value) < MinCompressSize {
+		return e
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(value) / 4)
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := io.WriteString(zw, value); err != nil {
+		return e
+	}
+	if err := zw.Close(); err != nil {
+		return e
+	}
+	if buf.Len() > len(value)-len(value)/8 {
+		return e
+	}
+	e.value = buf.String()
+	e.gzipped = true
+	return e
+}
+
+// decodeEntry returns the entry's original value.
+func decodeEntry(e entry) (string, bool) {
+	if !e.gzipped {
+		return e.value, true
+	}
+	zr, err := gzip.NewReader(strings.NewReader(e.value))
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	b.Grow(e.size)
+	if _, err := io.Copy(&b, zr); err != nil { // #nosec G110 -- input was gzipped by this process
+		return "", false
+	}
+	return b.String(), true
+}
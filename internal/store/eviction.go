@@ -0,0 +1,141 @@
+// Size caps, LRU eviction and miss accounting for MemoryStore.
+//
+// Originals are kept in least-recently-used order: Get moves an entry to the
+// back, and Set evicts from the front while the store is over max_entries or
+// max_bytes. The keys of evicted and expired originals and field refs are
+// remembered (up to maxTombstones), so an expand_context miss can be
+// attributed to eviction, TTL expiry or an unknown ID.
+package store
+
+import "container/list"
+
+// maxTombstones caps how many removed keys are remembered for miss accounting.
+// Keys are short (~50 bytes), so 10K tombstones ≈ 500KB.
+const maxTombstones = 10_000
+
+// Miss reasons reported by RecordExpandMiss.
+const (
+	MissEvicted = "evicted" // Removed to stay under the size caps
+	MissExpired = "expired" // Outlived its TTL
+	MissUnknown = "unknown" // Never stored, deleted, or removed too long ago to tell
+)
+
+// MissRecorder is implemented by stores that can tell why a key is missing.
+type MissRecorder interface {
+	// RecordExpandMiss counts an expand_context miss for key and returns its reason.
+	RecordExpandMiss(key string) string
+}
+
+// EvictionStats reports removals from the original cache and the expand misses they caused.
+type EvictionStats struct {
+	OriginalEvicted   int64 `json:"original_evicted"`   // Removed by max_entries / max_bytes
+	OriginalExpired   int64 `json:"original_expired"`   // Removed after original_ttl
+	CompressedEvicted int64 `json:"compressed_evicted"` // Removed by the compressed cache cap
+	MissEvicted       int64 `json:"expand_miss_evicted"`
+	MissExpired       int64 `json:"expand_miss_expired"`
+	MissUnknown       int64 `json:"expand_miss_unknown"`
+}
+
+// tombstones remembers why recently removed keys are gone, oldest first
+// (guarded by MemoryStore.mu).
+type tombstones struct {
+	byKey map[string]*list.Element // key → element holding a tombstone
+	order *list.List
+}
+
+type tombstone struct {
+	key    string
+	reason string
+}
+
+func newTombstones() tombstones {
+	return tombstones{byKey: make(map[string]*list.Element), order: list.New()}
+}
+
+func (t *tombstones) add(key, reason string) {
+	t.forget(key)
+	if t.order.Len() >= maxTombstones {
+		t.forget(t.order.Front().Value.(tombstone).key)
+	}
+	t.byKey[key] = t.order.PushBack(tombstone{key: key, reason: reason})
+}
+
+// forget drops key's tombstone, e.g. when the key is stored again.
+func (t *tombstones) forget(key string) {
+	if el, ok := t.byKey[key]; ok {
+		t.order.Remove(el)
+		delete(t.byKey, key)
+	}
+}
+
+func (t *tombstones) reason(key string) (string, bool) {
+	el, ok := t.byKey[key]
+	if !ok {
+		return "", false
+	}
+	return el.Value.(tombstone).reason, true
+}
+
+// WithLimits caps the original cache at maxEntries entries (0 = MaxOriginalEntries)
+// and maxBytes bytes held in memory after gzip (0 = unlimited).
+func (s *MemoryStore) WithLimits(maxEntries int, maxBytes int64) *MemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if maxEntries > 0 {
+		s.maxOriginals = maxEntries
+	}
+	s.maxOriginalBytes = maxBytes
+	s.enforceOriginalLimits()
+	return s
+}
+
+// enforceOriginalLimits evicts least recently used originals until the cache
+// is within its caps. The most recent entry is always kept, even if it alone
+// exceeds max_bytes (called with lock held).
+func (s *MemoryStore) enforceOriginalLimits() {
+	for s.dataOrder.Len() > 1 &&
+		(len(s.data) > s.maxOriginals || (s.maxOriginalBytes > 0 && s.dataBytes.physical > s.maxOriginalBytes)) {
+		s.evictOldestData()
+	}
+}
+
+// RecordExpandMiss counts an expand_context miss for key and returns why the
+// key is missing: MissEvicted, MissExpired or MissUnknown.
+func (s *MemoryStore) RecordExpandMiss(key string) string {
+	s.mu.RLock()
+	reason, ok := s.gone.reason(key)
+	if !ok {
+		if _, present := s.data[key]; present {
+			reason = MissExpired // Expired but not swept yet
+		} else if _, present := s.fieldRefs[key]; present {
+			reason = MissExpired
+		} else {
+			reason = MissUnknown
+		}
+	}
+	s.mu.RUnlock()
+
+	switch reason {
+	case MissEvicted:
+		s.Metrics.ExpandMissEvicted.Add(1)
+	case MissExpired:
+		s.Metrics.ExpandMissExpired.Add(1)
+	default:
+		s.Metrics.ExpandMissUnknown.Add(1)
+	}
+	return reason
+}
+
+// EvictionStats returns eviction and expand miss counters.
+func (s *MemoryStore) EvictionStats() EvictionStats {
+	return EvictionStats{
+		OriginalEvicted:   s.Metrics.OriginalEvictions.Load(),
+		OriginalExpired:   s.Metrics.OriginalExpirations.Load(),
+		CompressedEvicted: s.Metrics.CompressedEvictions.Load(),
+		MissEvicted:       s.Metrics.ExpandMissEvicted.Load(),
+		MissExpired:       s.Metrics.ExpandMissExpired.Load(),
+		MissUnknown:       s.Metrics.ExpandMissUnknown.Load(),
+	}
+}
+
+var _ MissRecorder = (*MemoryStore)(nil)
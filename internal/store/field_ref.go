@@ -34,6 +34,8 @@ func (s *MemoryStore) SetFieldRef(ref *formats.FieldRef) error {
 		s.fieldRefs = make(map[string]fieldRefEntry)
 	}
 
+	s.gone.forget(ref.ID)
+
 	// If key exists: refresh TTL and move to back — no new list node needed.
 	if existing, ok := s.fieldRefs[ref.ID]; ok {
 		s.fieldRefOrder.MoveToBack(existing.element)
@@ -108,6 +110,7 @@ func (s *MemoryStore) SetFieldRefs(refs []*formats.FieldRef) error {
 		if ref == nil || ref.ID == "" {
 			continue
 		}
+		s.gone.forget(ref.ID)
 		// If key exists: refresh and move to back — no new list node needed.
 		if existing, ok := s.fieldRefs[ref.ID]; ok {
 			s.fieldRefOrder.MoveToBack(existing.element)
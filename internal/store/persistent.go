@@ -0,0 +1,189 @@
+// Store implementation shared by the persistent backends.
+//
+// persistentStore maps the Store interface onto a key-value backend with
+// per-entry expiry. Values are framed with one flag byte (raw or gzip, see
+// codec.go); expansion records and field refs are stored as JSON. Backend
+// errors are logged and reported as misses, like an expired entry: a failed
+// lookup degrades expand_context instead of failing the request.
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/formats"
+)
+
+// Entry kinds; each is its own keyspace in the backend.
+const (
+	kindOriginal   = "original"
+	kindCompressed = "compressed"
+	kindExpansion  = "expansion"
+	kindFieldRef   = "field_ref"
+)
+
+// Value frame flags.
+const (
+	frameRaw  byte = 0
+	frameGzip byte = 1
+)
+
+// kvBackend is the storage a persistentStore runs on. get reports a missing
+// or expired entry as (nil, false, nil).
+type kvBackend interface {
+	put(kind, key string, value []byte, ttl time.Duration) error
+	get(kind, key string) ([]byte, bool, error)
+	del(kind, key string) error
+	close() error
+}
+
+// persistentStore implements Store on a kvBackend.
+type persistentStore struct {
+	kv            kvBackend
+	name          string // Backend name for logs
+	originalTTL   time.Duration
+	compressedTTL time.Duration
+	Metrics       CacheMetrics
+}
+
+func (s *persistentStore) putValue(kind, key, value string, ttl time.Duration) error {
+	e := encodeEntry(value)
+	frame := make([]byte, 0, len(e.value)+1)
+	if e.gzipped {
+		frame = append(frame, frameGzip)
+	} else {
+		frame = append(frame, frameRaw)
+	}
+	frame = append(frame, e.value...)
+	return s.kv.put(kind, key, frame, ttl)
+}
+
+func (s *persistentStore) getValue(kind, key string) (string, bool) {
+	frame, ok := s.lookup(kind, key)
+	if !ok || len(frame) == 0 {
+		return "", false
+	}
+	return decodeEntry(entry{value: string(frame[1:]), gzipped: frame[0] == frameGzip})
+}
+
+func (s *persistentStore) putJSON(kind, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.kv.put(kind, key, data, ttl)
+}
+
+func (s *persistentStore) lookup(kind, key string) ([]byte, bool) {
+	data, ok, err := s.kv.get(kind, key)
+	if err != nil {
+		log.Warn().Err(err).Str("backend", s.name).Str("kind", kind).Msg("shadow store: lookup failed")
+		return nil, false
+	}
+	return data, ok
+}
+
+// Set stores original content with short TTL.
+func (s *persistentStore) Set(key, value string) error {
+	return s.putValue(kindOriginal, key, value, s.originalTTL)
+}
+
+// Get retrieves original content by key.
+func (s *persistentStore) Get(key string) (string, bool) {
+	return s.getValue(kindOriginal, key)
+}
+
+// Delete removes the original and compressed content for key.
+func (s *persistentStore) Delete(key string) error {
+	if err := s.kv.del(kindOriginal, key); err != nil {
+		return err
+	}
+	return s.kv.del(kindCompressed, key)
+}
+
+// SetCompressed stores compressed content with long TTL.
+func (s *persistentStore) SetCompressed(key, compressed string) error {
+	return s.putValue(kindCompressed, key, compressed, s.compressedTTL)
+}
+
+// GetCompressed retrieves the cached compressed version.
+func (s *persistentStore) GetCompressed(key string) (string, bool) {
+	value, ok := s.getValue(kindCompressed, key)
+	if ok {
+		s.Metrics.CompressedHits.Add(1)
+	} else {
+		s.Metrics.CompressedMisses.Add(1)
+	}
+	return value, ok
+}
+
+// DeleteCompressed removes only the compressed version.
+func (s *persistentStore) DeleteCompressed(key string) error {
+	return s.kv.del(kindCompressed, key)
+}
+
+// SetExpansion stores an expansion record for a shadow ID.
+func (s *persistentStore) SetExpansion(key string, expansion *ExpansionRecord) error {
+	return s.putJSON(kindExpansion, key, expansion, s.compressedTTL)
+}
+
+// GetExpansion retrieves the expansion record for a shadow ID.
+func (s *persistentStore) GetExpansion(key string) (*ExpansionRecord, bool) {
+	data, ok := s.lookup(kindExpansion, key)
+	if !ok {
+		return nil, false
+	}
+	var rec ExpansionRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false
+	}
+	return &rec, true
+}
+
+// DeleteExpansion removes the expansion record.
+func (s *persistentStore) DeleteExpansion(key string) error {
+	return s.kv.del(kindExpansion, key)
+}
+
+// SetFieldRef stores a field reference for expansion.
+func (s *persistentStore) SetFieldRef(ref *formats.FieldRef) error {
+	if ref == nil || ref.ID == "" {
+		return nil
+	}
+	return s.putJSON(kindFieldRef, ref.ID, ref, s.originalTTL)
+}
+
+// GetFieldRef retrieves a field reference by ID.
+func (s *persistentStore) GetFieldRef(refID string) (*formats.FieldRef, bool) {
+	data, ok := s.lookup(kindFieldRef, refID)
+	if !ok {
+		return nil, false
+	}
+	var ref formats.FieldRef
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return nil, false
+	}
+	return &ref, true
+}
+
+// DeleteFieldRef removes a field reference.
+func (s *persistentStore) DeleteFieldRef(refID string) error {
+	return s.kv.del(kindFieldRef, refID)
+}
+
+// SetFieldRefs stores multiple field references at once.
+func (s *persistentStore) SetFieldRefs(refs []*formats.FieldRef) error {
+	for _, ref := range refs {
+		if err := s.SetFieldRef(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the backend.
+func (s *persistentStore) Close() error {
+	return s.kv.close()
+}
@@ -0,0 +1,260 @@
+// Redis backend for the shadow store (store.type: redis).
+//
+// Entries are plain string keys, <key_prefix><kind>:<key>, written with
+// SET ... PX so Redis expires them; several gateway instances can share one
+// store. The client speaks RESP2 over a small connection pool and only uses
+// AUTH, SELECT, PING, SET, GET and DEL.
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisPoolSize caps idle connections kept for reuse.
+const redisPoolSize = 8
+
+// RedisStore is a Store kept in Redis.
+type RedisStore struct {
+	*persistentStore
+}
+
+// NewRedisStore connects to the server in cfg and checks it with PING.
+func NewRedisStore(cfg RedisConfig, originalTTL, compressedTTL time.Duration) (*RedisStore, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultRedisAddr
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultRedisKeyPrefix
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultRedisTimeout
+	}
+	kv := &redisKV{cfg: cfg, idle: make(chan *redisConn, redisPoolSize)}
+	if _, err := kv.do("PING"); err != nil {
+		_ = kv.close()
+		return nil, fmt.Errorf("store: redis %s: %w", cfg.Addr, err)
+	}
+	return &RedisStore{&persistentStore{kv: kv, name: TypeRedis, originalTTL: originalTTL, compressedTTL: compressedTTL}}, nil
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisKV is the kvBackend for RedisStore.
+type redisKV struct {
+	cfg    RedisConfig
+	idle   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (kv *redisKV) key(kind, key string) string {
+	return kv.cfg.KeyPrefix + kind + ":" + key
+}
+
+func (kv *redisKV) put(kind, key string, value []byte, ttl time.Duration) error {
+	_, err := kv.do("SET", kv.key(kind, key), value, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (kv *redisKV) get(kind, key string) ([]byte, bool, error) {
+	reply, err := kv.do("GET", kv.key(kind, key))
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (kv *redisKV) del(kind, key string) error {
+	_, err := kv.do("DEL", kv.key(kind, key))
+	return err
+}
+
+func (kv *redisKV) close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.closed {
+		return nil
+	}
+	kv.closed = true
+	for {
+		select {
+		case c := <-kv.idle:
+			_ = c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and returns its reply: nil, int64, string (status),
+// []byte (bulk) or []any (array). Error replies are returned as redisError.
+func (kv *redisKV) do(args ...any) (any, error) {
+	c, err := kv.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(kv.cfg.Timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// Transport failure: the connection state is unknown.
+		_ = c.Close()
+		return nil, err
+	}
+	kv.release(c)
+	return reply, err
+}
+
+func (kv *redisKV) conn() (*redisConn, error) {
+	kv.mu.Lock()
+	closed := kv.closed
+	kv.mu.Unlock()
+	if closed {
+		return nil, errors.New("redis: store closed")
+	}
+	select {
+	case c := <-kv.idle:
+		return c, nil
+	default:
+	}
+
+	nc, err := net.DialTimeout("tcp", kv.cfg.Addr, kv.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if kv.cfg.Password != "" {
+		auth := []any{"AUTH", kv.cfg.Password}
+		if kv.cfg.Username != "" {
+			auth = []any{"AUTH", kv.cfg.Username, kv.cfg.Password}
+		}
+		if _, err := c.roundTrip(kv.cfg.Timeout, auth...); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	if kv.cfg.DB > 0 {
+		if _, err := c.roundTrip(kv.cfg.Timeout, "SELECT", strconv.Itoa(kv.cfg.DB)); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (kv *redisKV) release(c *redisConn) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.closed {
+		_ = c.Close()
+		return
+	}
+	select {
+	case kv.idle <- c:
+	default:
+		_ = c.Close()
+	}
+}
+
+func (c *redisConn) roundTrip(timeout time.Duration, args ...any) (any, error) {
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return nil, fmt.Errorf("redis: unsupported argument %T", arg)
+		}
+		fmt.Fprintf(c.w, "$%d\r\n", len(b))
+		_, _ = c.w.Write(b)
+		_, _ = c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readRESP(c.r)
+}
+
+// readRESP reads one RESP2 reply.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]any, n)
+		for i := range out {
+			v, err := readRESP(r)
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				out[i] = replyErr // Keep reading: the rest of the array is still on the wire
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// Ensure RedisStore implements Store
+var _ Store = (*RedisStore)(nil)
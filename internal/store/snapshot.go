@@ -0,0 +1,151 @@
+// Snapshot export and import for MemoryStore.
+//
+// Export copies every unexpired entry with its expiry time; values are
+// inflated so a snapshot does not depend on this process's gzip framing.
+// Import re-inserts them through the normal caps, keeping the original
+// expiry, so shadow refs and expand_context keep working after a restore.
+package store
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/formats"
+)
+
+// SnapshotValue is one exported original or compressed value.
+type SnapshotValue struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SnapshotExpansion is one exported expansion record.
+type SnapshotExpansion struct {
+	Key       string           `json:"key"`
+	Record    *ExpansionRecord `json:"record"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// SnapshotFieldRef is one exported field reference.
+type SnapshotFieldRef struct {
+	Ref       *formats.FieldRef `json:"ref"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Snapshot is the serializable content of a MemoryStore.
+// Entries are listed oldest first so a restore preserves eviction order.
+type Snapshot struct {
+	Original   []SnapshotValue     `json:"original"`
+	Compressed []SnapshotValue     `json:"compressed"`
+	Expansions []SnapshotExpansion `json:"expansions"`
+	FieldRefs  []SnapshotFieldRef  `json:"field_refs"`
+}
+
+// Export returns every unexpired entry.
+func (s *MemoryStore) Export() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	snap := &Snapshot{}
+	exportValues := func(m map[string]entry, order *list.List) []SnapshotValue {
+		var out []SnapshotValue
+		for _, key := range orderedKeys(order) {
+			e, ok := m[key]
+			if !ok || now.After(e.expiresAt) {
+				continue
+			}
+			if v, ok := decodeEntry(e); ok {
+				out = append(out, SnapshotValue{Key: key, Value: v, ExpiresAt: e.expiresAt})
+			}
+		}
+		return out
+	}
+	snap.Original = exportValues(s.data, s.dataOrder)
+	snap.Compressed = exportValues(s.compressed, s.compOrder)
+
+	for _, key := range orderedKeys(s.expansOrder) {
+		if e, ok := s.expansions[key]; ok && !now.After(e.expiresAt) {
+			snap.Expansions = append(snap.Expansions, SnapshotExpansion{Key: key, Record: e.record, ExpiresAt: e.expiresAt})
+		}
+	}
+	for _, key := range orderedKeys(s.fieldRefOrder) {
+		if e, ok := s.fieldRefs[key]; ok && !now.After(e.expiresAt) {
+			snap.FieldRefs = append(snap.FieldRefs, SnapshotFieldRef{Ref: e.ref, ExpiresAt: e.expiresAt})
+		}
+	}
+	return snap
+}
+
+// Import restores a Snapshot, replacing entries with the same key. Entries
+// that have expired since the export are skipped. Returns the number restored.
+func (s *MemoryStore) Import(snap *Snapshot) int {
+	if snap == nil {
+		return 0
+	}
+	now := time.Now()
+	restored := 0
+
+	for _, v := range snap.Original {
+		if v.ExpiresAt.After(now) && s.Set(v.Key, v.Value) == nil && s.setExpiry(v.Key, v.ExpiresAt, false) {
+			restored++
+		}
+	}
+	for _, v := range snap.Compressed {
+		if v.ExpiresAt.After(now) && s.SetCompressed(v.Key, v.Value) == nil && s.setExpiry(v.Key, v.ExpiresAt, true) {
+			restored++
+		}
+	}
+	for _, x := range snap.Expansions {
+		if x.Record == nil || !x.ExpiresAt.After(now) || s.SetExpansion(x.Key, x.Record) != nil {
+			continue
+		}
+		s.mu.Lock()
+		if e, ok := s.expansions[x.Key]; ok {
+			e.expiresAt = x.ExpiresAt
+			s.expansions[x.Key] = e
+			restored++
+		}
+		s.mu.Unlock()
+	}
+	for _, f := range snap.FieldRefs {
+		if f.Ref == nil || f.Ref.ID == "" || !f.ExpiresAt.After(now) || s.SetFieldRef(f.Ref) != nil {
+			continue
+		}
+		s.mu.Lock()
+		if e, ok := s.fieldRefs[f.Ref.ID]; ok {
+			e.expiresAt = f.ExpiresAt
+			s.fieldRefs[f.Ref.ID] = e
+			restored++
+		}
+		s.mu.Unlock()
+	}
+	return restored
+}
+
+// setExpiry overrides the expiry of an original (or compressed) entry.
+// Returns false if the entry is not present.
+func (s *MemoryStore) setExpiry(key string, expiresAt time.Time, compressed bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.data
+	if compressed {
+		m = s.compressed
+	}
+	e, ok := m[key]
+	if ok {
+		e.expiresAt = expiresAt
+		m[key] = e
+	}
+	return ok
+}
+
+// orderedKeys returns the keys of an insertion order list, oldest first.
+func orderedKeys(order *list.List) []string {
+	keys := make([]string, 0, order.Len())
+	for el := order.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(string))
+	}
+	return keys
+}
@@ -0,0 +1,175 @@
+// SQLite backend for the shadow store (store.type: sqlite).
+//
+// One table holds every entry kind with its expiry; reads skip expired rows
+// and a background sweep deletes them. The database lives in the state
+// directory by default, so a restarted gateway resolves shadow refs handed
+// out by the previous process.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite".
+)
+
+// sqliteSweepInterval is how often expired rows are deleted.
+const sqliteSweepInterval = 10 * time.Minute
+
+// SQLiteStore is a Store persisted in a local SQLite database.
+type SQLiteStore struct {
+	*persistentStore
+	kv *sqliteKV
+}
+
+// NewSQLiteStore opens (or creates) the shadow store database at path.
+func NewSQLiteStore(path string, originalTTL, compressedTTL time.Duration) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil { // #nosec G301
+		return nil, fmt.Errorf("store: create directory for %s: %w", path, err)
+	}
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		`CREATE TABLE IF NOT EXISTS shadow_entries (
+			kind       TEXT    NOT NULL,
+			key        TEXT    NOT NULL,
+			value      BLOB    NOT NULL,
+			expires_at INTEGER NOT NULL,
+			PRIMARY KEY (kind, key)
+		) WITHOUT ROWID`,
+		`CREATE INDEX IF NOT EXISTS idx_shadow_entries_expires_at ON shadow_entries(expires_at)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("store: init %s: %w", path, err)
+		}
+	}
+
+	kv := &sqliteKV{db: db, stop: make(chan struct{})}
+	kv.wg.Add(1)
+	go kv.sweepLoop()
+	return &SQLiteStore{
+		persistentStore: &persistentStore{kv: kv, name: TypeSQLite, originalTTL: originalTTL, compressedTTL: compressedTTL},
+		kv:              kv,
+	}, nil
+}
+
+// Sweep deletes expired entries and returns how many were removed.
+func (s *SQLiteStore) Sweep() (int, error) {
+	return s.kv.sweep()
+}
+
+// Sizes returns the number of unexpired entries of each kind.
+func (s *SQLiteStore) Sizes() (Sizes, error) {
+	rows, err := s.kv.db.Query(`SELECT kind, COUNT(*) FROM shadow_entries WHERE expires_at > ? GROUP BY kind`, time.Now().UnixMilli())
+	if err != nil {
+		return Sizes{}, err
+	}
+	defer rows.Close()
+	var sizes Sizes
+	for rows.Next() {
+		var kind string
+		var n int
+		if err := rows.Scan(&kind, &n); err != nil {
+			return Sizes{}, err
+		}
+		switch kind {
+		case kindOriginal:
+			sizes.Original = n
+		case kindCompressed:
+			sizes.Compressed = n
+		case kindExpansion:
+			sizes.Expansions = n
+		case kindFieldRef:
+			sizes.FieldRefs = n
+		}
+	}
+	return sizes, rows.Err()
+}
+
+// sqliteKV is the kvBackend for SQLiteStore.
+type sqliteKV struct {
+	db   *sql.DB
+	mu   sync.Mutex // Serializes writes
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+func (kv *sqliteKV) put(kind, key string, value []byte, ttl time.Duration) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	_, err := kv.db.Exec(`INSERT INTO shadow_entries (kind, key, value, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(kind, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		kind, key, value, time.Now().Add(ttl).UnixMilli())
+	return err
+}
+
+func (kv *sqliteKV) get(kind, key string) ([]byte, bool, error) {
+	var value []byte
+	err := kv.db.QueryRow(`SELECT value FROM shadow_entries WHERE kind = ? AND key = ? AND expires_at > ?`,
+		kind, key, time.Now().UnixMilli()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (kv *sqliteKV) del(kind, key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	_, err := kv.db.Exec(`DELETE FROM shadow_entries WHERE kind = ? AND key = ?`, kind, key)
+	return err
+}
+
+func (kv *sqliteKV) sweep() (int, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	res, err := kv.db.Exec(`DELETE FROM shadow_entries WHERE expires_at <= ?`, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (kv *sqliteKV) sweepLoop() {
+	defer kv.wg.Done()
+	ticker := time.NewTicker(sqliteSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-kv.stop:
+			return
+		case <-ticker.C:
+			if _, err := kv.sweep(); err != nil {
+				log.Warn().Err(err).Msg("shadow store: sqlite sweep failed")
+			}
+		}
+	}
+}
+
+func (kv *sqliteKV) close() error {
+	var err error
+	kv.once.Do(func() {
+		close(kv.stop)
+		kv.wg.Wait()
+		err = kv.db.Close()
+	})
+	return err
+}
+
+// Ensure SQLiteStore implements Store
+var _ Store = (*SQLiteStore)(nil)
@@ -6,8 +6,8 @@
 //
 // This optimizes memory while maintaining KV-cache consistency.
 //
-// Currently only MemoryStore is implemented. For multi-instance deployments,
-// implement Store interface with Redis or similar.
+// MemoryStore is the default. SQLiteStore and RedisStore persist entries
+// across restarts (store.type: sqlite | redis); see backend.go.
 package store
 
 import (
@@ -30,7 +30,7 @@ const (
 	// At ~2KB avg per entry, 2K entries ≈ 4MB (was 20MB).
 	MaxCompressedEntries = 2_000
 
-	// MaxOriginalEntries caps the original content map.
+	// MaxOriginalEntries caps the original content map unless store.max_entries is set.
 	// OPTIMIZED: Reduced from 5K to 1K to lower memory footprint.
 	// Original content is larger (~5KB avg), so 1K entries ≈ 5MB (was 25MB).
 	MaxOriginalEntries = 1_000
@@ -111,6 +111,12 @@ type CacheMetrics struct {
 	CompressedHits      atomic.Int64
 	CompressedMisses    atomic.Int64
 	CompressedEvictions atomic.Int64
+
+	OriginalEvictions   atomic.Int64 // Originals removed by the size caps
+	OriginalExpirations atomic.Int64 // Originals swept after their TTL
+	ExpandMissEvicted   atomic.Int64 // expand_context misses for evicted IDs
+	ExpandMissExpired   atomic.Int64 // expand_context misses for expired IDs
+	ExpandMissUnknown   atomic.Int64 // expand_context misses with no known cause
 }
 
 // MemoryStore is a simple in-memory implementation of Store.
@@ -132,14 +138,22 @@ type MemoryStore struct {
 	stopped       bool
 	wg            sync.WaitGroup // Waits for cleanup goroutine to exit
 
-	maxCompressed int          // Max entries in compressed cache (0 = unlimited)
-	maxExpansions int          // Max entries in expansions cache
-	maxFieldRefs  int          // Max entries in fieldRefs cache
-	Metrics       CacheMetrics // Observable cache statistics
+	dataBytes byteCounter // Logical/physical bytes held in data
+	compBytes byteCounter // Logical/physical bytes held in compressed
+
+	maxOriginals     int          // Max entries in data (LRU eviction)
+	maxOriginalBytes int64        // Max physical bytes in data (0 = unlimited)
+	maxCompressed    int          // Max entries in compressed cache (0 = unlimited)
+	maxExpansions    int          // Max entries in expansions cache
+	maxFieldRefs     int          // Max entries in fieldRefs cache
+	gone             tombstones   // Why recently removed originals and field refs are gone
+	Metrics          CacheMetrics // Observable cache statistics
 }
 
 type entry struct {
-	value     string
+	value     string // Stored form; gzip data when gzipped (see codec.go)
+	gzipped   bool
+	size      int // Logical (uncompressed) size in bytes
 	expiresAt time.Time
 	element   *list.Element // pointer into order list for O(1) MoveToBack/Remove
 }
@@ -170,9 +184,11 @@ func NewMemoryStoreWithDualTTL(originalTTL, compressedTTL time.Duration) *Memory
 		originalTTL:   originalTTL,
 		compressedTTL: compressedTTL,
 		stopChan:      make(chan struct{}),
+		maxOriginals:  MaxOriginalEntries,
 		maxCompressed: MaxCompressedEntries,
 		maxExpansions: MaxExpansionEntries,
 		maxFieldRefs:  MaxFieldRefEntries,
+		gone:          newTombstones(),
 	}
 
 	// Start cleanup goroutine
@@ -183,51 +199,57 @@ func NewMemoryStoreWithDualTTL(originalTTL, compressedTTL time.Duration) *Memory
 }
 
 // Set stores original content with short TTL (V2).
+// Large values are gzipped before taking the lock.
 func (s *MemoryStore) Set(key, value string) error {
+	e := encodeEntry(value)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.stopped {
 		return nil
 	}
+	e.expiresAt = time.Now().Add(s.originalTTL)
+	s.gone.forget(key)
 
 	// If key exists: refresh TTL and move to back — no new list node needed.
 	if existing, ok := s.data[key]; ok {
 		s.dataOrder.MoveToBack(existing.element)
-		s.data[key] = entry{value: value, expiresAt: time.Now().Add(s.originalTTL), element: existing.element}
+		s.dataBytes.remove(existing)
+		e.element = existing.element
+		s.data[key] = e
+		s.dataBytes.add(e)
+		s.enforceOriginalLimits()
 		return nil
 	}
 
-	// Cap original entries to prevent unbounded growth — O(1) eviction via insertion order list.
-	if len(s.data) >= MaxOriginalEntries {
-		s.evictOldestData()
-	}
+	e.element = s.dataOrder.PushBack(key)
+	s.data[key] = e
+	s.dataBytes.add(e)
 
-	elem := s.dataOrder.PushBack(key)
-	s.data[key] = entry{value: value, expiresAt: time.Now().Add(s.originalTTL), element: elem}
+	// Cap original entries and bytes to prevent unbounded growth — O(1) LRU eviction via the order list.
+	s.enforceOriginalLimits()
 	return nil
 }
 
-// Get retrieves a value if it exists and hasn't expired.
+// Get retrieves a value if it exists and hasn't expired, marking it as
+// recently used. Compressed values are inflated after releasing the lock.
 func (s *MemoryStore) Get(key string) (string, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
+	s.mu.Lock()
 	// enforce "no access after close" contract consistently with Set/Delete
-	if s.stopped {
-		return "", false
-	}
-
+	stopped := s.stopped
 	e, exists := s.data[key]
-	if !exists {
-		return "", false
+	live := exists && !time.Now().After(e.expiresAt)
+	if !stopped && live {
+		s.dataOrder.MoveToBack(e.element)
 	}
+	s.mu.Unlock()
 
-	if time.Now().After(e.expiresAt) {
+	if stopped || !live {
 		return "", false
 	}
 
-	return e.value, true
+	return decodeEntry(e)
 }
 
 // Delete removes a value.
@@ -240,10 +262,12 @@ func (s *MemoryStore) Delete(key string) error {
 	}
 	if e, ok := s.data[key]; ok {
 		s.dataOrder.Remove(e.element)
+		s.dataBytes.remove(e)
 		delete(s.data, key)
 	}
 	if e, ok := s.compressed[key]; ok {
 		s.compOrder.Remove(e.element)
+		s.compBytes.remove(e)
 		delete(s.compressed, key)
 	}
 	return nil
@@ -251,17 +275,23 @@ func (s *MemoryStore) Delete(key string) error {
 
 // SetCompressed stores compressed content with long TTL (V2: KV-cache preservation).
 func (s *MemoryStore) SetCompressed(key, compressed string) error {
+	e := encodeEntry(compressed)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.stopped {
 		return nil
 	}
+	e.expiresAt = time.Now().Add(s.compressedTTL)
 
 	// If key exists: refresh TTL and move to back — no new list node needed.
 	if existing, ok := s.compressed[key]; ok {
 		s.compOrder.MoveToBack(existing.element)
-		s.compressed[key] = entry{value: compressed, expiresAt: time.Now().Add(s.compressedTTL), element: existing.element}
+		s.compBytes.remove(existing)
+		e.element = existing.element
+		s.compressed[key] = e
+		s.compBytes.add(e)
 		return nil
 	}
 
@@ -270,17 +300,18 @@ func (s *MemoryStore) SetCompressed(key, compressed string) error {
 		s.evictOldestCompressed()
 	}
 
-	elem := s.compOrder.PushBack(key)
-	s.compressed[key] = entry{value: compressed, expiresAt: time.Now().Add(s.compressedTTL), element: elem}
+	e.element = s.compOrder.PushBack(key)
+	s.compressed[key] = e
+	s.compBytes.add(e)
 	return nil
 }
 
 // GetCompressed retrieves the cached compressed version.
 func (s *MemoryStore) GetCompressed(key string) (string, bool) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	e, exists := s.compressed[key]
+	s.mu.RUnlock()
+
 	if !exists {
 		s.Metrics.CompressedMisses.Add(1)
 		return "", false
@@ -291,8 +322,13 @@ func (s *MemoryStore) GetCompressed(key string) (string, bool) {
 		return "", false
 	}
 
+	value, ok := decodeEntry(e)
+	if !ok {
+		s.Metrics.CompressedMisses.Add(1)
+		return "", false
+	}
 	s.Metrics.CompressedHits.Add(1)
-	return e.value, true
+	return value, true
 }
 
 // DeleteCompressed removes only the compressed version cache entry.
@@ -305,6 +341,7 @@ func (s *MemoryStore) DeleteCompressed(key string) error {
 	}
 	if e, ok := s.compressed[key]; ok {
 		s.compOrder.Remove(e.element)
+		s.compBytes.remove(e)
 		delete(s.compressed, key)
 	}
 	return nil
@@ -368,14 +405,17 @@ func (s *MemoryStore) DeleteExpansion(key string) error {
 	return nil
 }
 
-// evictOldestData removes the oldest data entry (called with lock held).
+// evictOldestData removes the least recently used data entry (called with lock held).
 func (s *MemoryStore) evictOldestData() {
 	for s.dataOrder.Len() > 0 {
 		front := s.dataOrder.Front()
 		k := front.Value.(string)
 		s.dataOrder.Remove(front)
-		if _, exists := s.data[k]; exists {
+		if e, exists := s.data[k]; exists {
+			s.dataBytes.remove(e)
 			delete(s.data, k)
+			s.gone.add(k, MissEvicted)
+			s.Metrics.OriginalEvictions.Add(1)
 			return
 		}
 	}
@@ -387,7 +427,8 @@ func (s *MemoryStore) evictOldestCompressed() {
 		front := s.compOrder.Front()
 		k := front.Value.(string)
 		s.compOrder.Remove(front)
-		if _, exists := s.compressed[k]; exists {
+		if e, exists := s.compressed[k]; exists {
+			s.compBytes.remove(e)
 			delete(s.compressed, k)
 			s.Metrics.CompressedEvictions.Add(1)
 			return
@@ -416,6 +457,7 @@ func (s *MemoryStore) evictOldestFieldRef() {
 		s.fieldRefOrder.Remove(front)
 		if _, exists := s.fieldRefs[k]; exists {
 			delete(s.fieldRefs, k)
+			s.gone.add(k, MissEvicted)
 			return
 		}
 	}
@@ -428,6 +470,37 @@ func (s *MemoryStore) CompressedSize() int {
 	return len(s.compressed)
 }
 
+// Sizes reports how many entries each cache holds.
+type Sizes struct {
+	Original   int `json:"original"`
+	Compressed int `json:"compressed"`
+	Expansions int `json:"expansions"`
+	FieldRefs  int `json:"field_refs"`
+}
+
+// Sizes returns the number of entries in every cache (including not-yet-swept expired ones).
+func (s *MemoryStore) Sizes() Sizes {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Sizes{
+		Original:   len(s.data),
+		Compressed: len(s.compressed),
+		Expansions: len(s.expansions),
+		FieldRefs:  len(s.fieldRefs),
+	}
+}
+
+// Occupancy returns the logical and physical bytes held by the value caches
+// (including not-yet-swept expired entries).
+func (s *MemoryStore) Occupancy() Occupancy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Occupancy{
+		Original:   ByteUsage{Entries: len(s.data), LogicalBytes: s.dataBytes.logical, PhysicalBytes: s.dataBytes.physical},
+		Compressed: ByteUsage{Entries: len(s.compressed), LogicalBytes: s.compBytes.logical, PhysicalBytes: s.compBytes.physical},
+	}
+}
+
 // Reset clears all cached data without stopping the cleanup goroutine.
 // Call this when starting a new session to ensure a clean slate.
 func (s *MemoryStore) Reset() {
@@ -436,12 +509,15 @@ func (s *MemoryStore) Reset() {
 
 	s.data = make(map[string]entry)
 	s.dataOrder.Init()
+	s.dataBytes = byteCounter{}
 	s.compressed = make(map[string]entry)
 	s.compOrder.Init()
+	s.compBytes = byteCounter{}
 	s.expansions = make(map[string]expansionEntry)
 	s.expansOrder.Init()
 	s.fieldRefs = make(map[string]fieldRefEntry)
 	s.fieldRefOrder.Init()
+	s.gone = newTombstones()
 }
 
 // Close stops the cleanup goroutine and clears data.
@@ -458,7 +534,9 @@ func (s *MemoryStore) Close() error {
 
 	s.mu.Lock()
 	s.data = nil
+	s.dataBytes = byteCounter{}
 	s.compressed = nil
+	s.compBytes = byteCounter{}
 	s.expansions = nil
 	s.fieldRefs = nil
 	s.mu.Unlock()
@@ -506,7 +584,10 @@ func (s *MemoryStore) cleanupBatch() {
 		}
 		if now.After(e.expiresAt) {
 			s.dataOrder.Remove(e.element)
+			s.dataBytes.remove(e)
 			delete(s.data, key)
+			s.gone.add(key, MissExpired)
+			s.Metrics.OriginalExpirations.Add(1)
 			deleteCount++
 		}
 	}
@@ -519,6 +600,7 @@ func (s *MemoryStore) cleanupBatch() {
 		}
 		if now.After(e.expiresAt) {
 			s.compOrder.Remove(e.element)
+			s.compBytes.remove(e)
 			delete(s.compressed, key)
 			deleteCount++
 		}
@@ -546,6 +628,7 @@ func (s *MemoryStore) cleanupBatch() {
 		if now.After(e.expiresAt) {
 			s.fieldRefOrder.Remove(e.element)
 			delete(s.fieldRefs, key)
+			s.gone.add(key, MissExpired)
 			deleteCount++
 		}
 	}
@@ -0,0 +1,228 @@
+// Package tenancy - registry.go resolves requests to tenants.
+package tenancy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+)
+
+// Resolution errors. Requests failing resolution are not forwarded.
+var (
+	ErrUnknownTenant  = errors.New("unknown tenant")
+	ErrTenantRequired = errors.New("tenant required")
+	ErrTenantMismatch = errors.New("tenant does not match API key")
+	ErrTenantKey      = errors.New("invalid tenant API key")
+)
+
+// Registry maps requests to tenants. It is immutable, so it is safe for
+// concurrent use; config reloads build a new one. Safe to call on a nil
+// receiver (tenancy disabled).
+type Registry struct {
+	required bool
+	byName   map[string]*Tenant
+	prefixes []keyPrefix // Longest first
+}
+
+// keyPrefix is one tenant key prefix.
+type keyPrefix struct {
+	prefix string
+	tenant *Tenant
+}
+
+// NewRegistry builds the registry for cfg. Returns nil when tenancy is
+// disabled. cfg must be valid.
+func NewRegistry(cfg Config) *Registry {
+	if !cfg.Enabled {
+		return nil
+	}
+	r := &Registry{required: cfg.Required, byName: make(map[string]*Tenant, len(cfg.Tenants))}
+	for i := range cfg.Tenants {
+		t := &cfg.Tenants[i]
+		r.byName[t.Name] = t
+		for _, p := range t.KeyPrefixes {
+			r.prefixes = append(r.prefixes, keyPrefix{prefix: p, tenant: t})
+		}
+	}
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+	return r
+}
+
+// Get returns the tenant named name, or nil.
+func (r *Registry) Get(name string) *Tenant {
+	if r == nil {
+		return nil
+	}
+	return r.byName[name]
+}
+
+// Names returns the tenant names, sorted.
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the tenant of a request: the longest key prefix matching
+// the client credential, else the X-Tenant header. When the matching tenant
+// lists key hashes, the full credential must hash to one of them. It returns
+// nil without an error for requests that match no tenant when tenants are
+// not required.
+func (r *Registry) Resolve(h http.Header) (*Tenant, error) {
+	if r == nil {
+		return nil, nil
+	}
+	named := strings.TrimSpace(h.Get(HeaderTenant))
+	if _, key := clientCredential(h); key != "" {
+		for _, p := range r.prefixes {
+			if !strings.HasPrefix(key, p.prefix) {
+				continue
+			}
+			if named != "" && named != p.tenant.Name {
+				return nil, fmt.Errorf("%w: key belongs to %q, %s is %q", ErrTenantMismatch, p.tenant.Name, HeaderTenant, named)
+			}
+			if len(p.tenant.KeyHashes) > 0 && !p.tenant.hasKey(key) {
+				return nil, fmt.Errorf("%w for tenant %q", ErrTenantKey, p.tenant.Name)
+			}
+			return p.tenant, nil
+		}
+	}
+	if named != "" {
+		t := r.byName[named]
+		if t == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTenant, named)
+		}
+		if len(t.KeyPrefixes) > 0 {
+			return nil, fmt.Errorf("%w: %q requires one of its keys", ErrTenantMismatch, named)
+		}
+		return t, nil
+	}
+	if r.required {
+		return nil, fmt.Errorf("%w: send an %s header or a tenant API key", ErrTenantRequired, HeaderTenant)
+	}
+	return nil, nil
+}
+
+// hasKey reports whether key hashes to one of t's key hashes. Every hash is
+// compared in constant time.
+func (t *Tenant) hasKey(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	digest := []byte(hex.EncodeToString(sum[:]))
+	found := false
+	for _, h := range t.KeyHashes {
+		if subtle.ConstantTimeCompare(digest, []byte(strings.ToLower(h))) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+// Credential headers, in the order clients are checked for a key.
+const (
+	headerXAPIKey     = "x-api-key"
+	headerGoogAPIKey  = "x-goog-api-key"
+	headerAzureAPIKey = "api-key"
+	headerAuth        = "Authorization"
+)
+
+// clientCredential returns the header carrying the client's key and the key.
+func clientCredential(h http.Header) (string, string) {
+	for _, name := range []string{headerXAPIKey, headerGoogAPIKey, headerAzureAPIKey} {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			return name, v
+		}
+	}
+	auth := strings.TrimSpace(h.Get(headerAuth))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return headerAuth, strings.TrimSpace(auth[7:])
+	}
+	return "", ""
+}
+
+// ApplyProviderKey replaces the client's credential in h with t's key for
+// provider, so the tenant's own key (often a gateway-issued one) never goes
+// upstream. Anthropic keys go in x-api-key and Gemini keys in
+// x-goog-api-key; others use a Bearer token, or api-key when the client
+// sent one (Azure OpenAI). Reports whether t has a key for provider. Safe to
+// call on a nil receiver.
+func (t *Tenant) ApplyProviderKey(h http.Header, provider adapters.Provider) bool {
+	if t == nil {
+		return false
+	}
+	key := t.ProviderKeys[string(provider)]
+	if key == "" {
+		return false
+	}
+	used, _ := clientCredential(h)
+	for _, name := range []string{headerXAPIKey, headerGoogAPIKey, headerAzureAPIKey, headerAuth} {
+		h.Del(name)
+	}
+	switch {
+	case provider == adapters.ProviderAnthropic:
+		h.Set(headerXAPIKey, key)
+	case provider == adapters.ProviderGemini:
+		h.Set(headerGoogAPIKey, key)
+	case used == headerAzureAPIKey:
+		h.Set(headerAzureAPIKey, key)
+	default:
+		h.Set(headerAuth, "Bearer "+key)
+	}
+	return true
+}
+
+// SessionID scopes a session ID to t: "<tenant>:<id>". Returns id unchanged
+// for a nil tenant or an empty id.
+func (t *Tenant) SessionID(id string) string {
+	if t == nil || id == "" {
+		return id
+	}
+	return t.Name + ":" + id
+}
+
+// SessionPrefix returns the prefix SessionID adds, or "" for a nil tenant.
+func (t *Tenant) SessionPrefix() string {
+	if t == nil {
+		return ""
+	}
+	return t.Name + ":"
+}
+
+// NameOf returns t's name, or "" for a nil tenant.
+func NameOf(t *Tenant) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+type contextKey struct{}
+
+// WithTenant returns ctx carrying t.
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant carried by ctx, or nil.
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(contextKey{}).(*Tenant)
+	return t
+}
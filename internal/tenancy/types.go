@@ -0,0 +1,145 @@
+// Package tenancy lets one gateway serve several teams.
+//
+// Each tenant has its own provider keys, pipe settings and budget. A request
+// belongs to the tenant whose key prefix matches the credential it carries,
+// or else to the tenant named by its X-Tenant header. Tenants that list key
+// prefixes can only be selected with one of their keys, so a header alone
+// never unlocks another team's provider keys. A tenant with key hashes only
+// accepts full keys matching one of them, so a made-up key that merely shares
+// the prefix is rejected; tenants with both key prefixes and provider keys
+// must list key hashes. The tenant travels with the
+// request context; session IDs are prefixed with the tenant name so tenants
+// never share tool, summary or cost sessions.
+package tenancy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+)
+
+// HeaderTenant names the tenant of a request.
+const HeaderTenant = "X-Tenant"
+
+// sha256Size is the length of a key_hashes digest, in bytes.
+const sha256Size = 32
+
+// maxNameLen bounds tenant names, which appear in session IDs and metrics.
+const maxNameLen = 64
+
+// validName matches tenant names: letters, digits, hyphen and underscore.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Config is the tenancy config section.
+type Config struct {
+	Enabled      bool     `yaml:"enabled"`                 // Resolve a tenant for every proxied request
+	Required     bool     `yaml:"required"`                // Reject requests that match no tenant
+	BudgetWindow string   `yaml:"budget_window,omitempty"` // daily (default) | weekly | total
+	Tenants      []Tenant `yaml:"tenants"`
+}
+
+// Tenant is one team sharing the gateway.
+type Tenant struct {
+	Name         string            `yaml:"name"`                    // X-Tenant value; prefixes session IDs
+	KeyPrefixes  []string          `yaml:"key_prefixes,omitempty"`  // Client credentials starting with one of these select the tenant
+	KeyHashes    []string          `yaml:"key_hashes,omitempty"`    // Hex SHA-256 of the tenant's full client keys; a prefix match must be one of them
+	ProviderKeys map[string]string `yaml:"provider_keys,omitempty"` // Provider name → key sent upstream instead of the client's credential
+	Budget       float64           `yaml:"budget,omitempty"`        // USD per budget window (0 = track only)
+	Pipes        map[string]any    `yaml:"pipes,omitempty"`         // Overrides of the top-level pipes section
+}
+
+// providerKeyNames lists the providers provider_keys accepts. Bedrock is
+// signed with AWS credentials, so it has no key to replace.
+var providerKeyNames = []string{
+	string(adapters.ProviderAnthropic),
+	string(adapters.ProviderOpenAI),
+	string(adapters.ProviderGemini),
+	string(adapters.ProviderOllama),
+	string(adapters.ProviderLiteLLM),
+	string(adapters.ProviderMiniMax),
+	string(adapters.ProviderOpenRouter),
+}
+
+// Validate checks the tenant registry. Budget windows and pipe overrides are
+// checked by the config package, which knows their schemas.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Tenants) == 0 {
+		return fmt.Errorf("tenancy.tenants must list at least one tenant")
+	}
+	names := make(map[string]bool, len(c.Tenants))
+	prefixes := make(map[string]string)
+	for i, t := range c.Tenants {
+		field := fmt.Sprintf("tenancy.tenants[%d]", i)
+		switch {
+		case t.Name == "":
+			return fmt.Errorf("%s.name is required", field)
+		case len(t.Name) > maxNameLen || !validName.MatchString(t.Name):
+			return fmt.Errorf("%s.name %q must be 1-%d letters, digits, '-' or '_'", field, t.Name, maxNameLen)
+		case names[t.Name]:
+			return fmt.Errorf("%s.name %q is used twice", field, t.Name)
+		}
+		names[t.Name] = true
+		for _, p := range t.KeyPrefixes {
+			if strings.TrimSpace(p) == "" {
+				return fmt.Errorf("%s.key_prefixes must not contain empty values", field)
+			}
+			if other, ok := prefixes[p]; ok {
+				return fmt.Errorf("%s.key_prefixes: %q is also a prefix of tenant %q", field, p, other)
+			}
+			prefixes[p] = t.Name
+		}
+		for _, h := range t.KeyHashes {
+			if b, err := hex.DecodeString(h); err != nil || len(b) != sha256Size {
+				return fmt.Errorf("%s.key_hashes: %q is not a hex SHA-256 digest", field, h)
+			}
+		}
+		if len(t.KeyPrefixes) > 0 && len(t.ProviderKeys) > 0 && len(t.KeyHashes) == 0 {
+			return fmt.Errorf("%s: key_hashes is required with key_prefixes and provider_keys, so a key that only shares the prefix cannot use the provider keys", field)
+		}
+		for provider, key := range t.ProviderKeys {
+			if !slices.Contains(providerKeyNames, provider) {
+				return fmt.Errorf("%s.provider_keys: unknown provider %q (valid: %s)", field, provider, strings.Join(providerKeyNames, ", "))
+			}
+			if key == "" {
+				return fmt.Errorf("%s.provider_keys.%s is empty", field, provider)
+			}
+		}
+		if t.Budget < 0 {
+			return fmt.Errorf("%s.budget must be >= 0, got %f", field, t.Budget)
+		}
+	}
+	return nil
+}
+
+// Budgets returns the budget of every tenant that has one, by name.
+func (c Config) Budgets() map[string]float64 {
+	var budgets map[string]float64
+	for _, t := range c.Tenants {
+		if t.Budget <= 0 {
+			continue
+		}
+		if budgets == nil {
+			budgets = make(map[string]float64)
+		}
+		budgets[t.Name] = t.Budget
+	}
+	return budgets
+}
+
+// ProviderNames returns the providers t has keys for, sorted.
+func (t *Tenant) ProviderNames() []string {
+	names := make([]string, 0, len(t.ProviderKeys))
+	for name := range t.ProviderKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,270 @@
+// counter.go - pluggable token counting (tokenizer config).
+//
+// The package functions (CountTokens, CountBytesForModel, ...) always use
+// tiktoken. A TokenCounter lets the gateway pick how it counts the request
+// bodies behind telemetry, savings and cost estimates:
+//
+//   - tiktoken: local BPE, o200k_base or cl100k_base by model (default)
+//   - approx: per-model character ratios; no encoder files, for air-gapped hosts
+//   - anthropic_api: Anthropic's count_tokens endpoint for Claude request
+//     bodies, tiktoken for everything else and on any failure
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Counter names.
+const (
+	CounterTiktoken     = "tiktoken"
+	CounterApprox       = "approx"
+	CounterAnthropicAPI = "anthropic_api"
+)
+
+// Defaults for the anthropic_api counter.
+const (
+	DefaultAnthropicCountEndpoint = "https://api.anthropic.com/v1/messages/count_tokens"
+	DefaultAnthropicCountTimeout  = 2 * time.Second
+	DefaultAnthropicCountCache    = 1024
+)
+
+// TokenCounter counts tokens for a model. CountRequest counts a whole
+// provider request body; CountText a piece of content.
+type TokenCounter interface {
+	CountText(text, model string) int
+	CountRequest(body []byte, model string) int
+}
+
+// Config selects the token counter.
+type Config struct {
+	Counter string `yaml:"counter,omitempty"` // tiktoken (default) | approx | anthropic_api
+
+	// anthropic_api only
+	APIKey    string        `yaml:"api_key,omitempty"`    // Anthropic API key, e.g. ${ANTHROPIC_API_KEY}
+	Endpoint  string        `yaml:"endpoint,omitempty"`   // default: https://api.anthropic.com/v1/messages/count_tokens
+	Timeout   time.Duration `yaml:"timeout,omitempty"`    // Per-call timeout (default: 2s)
+	CacheSize int           `yaml:"cache_size,omitempty"` // Counts cached by body hash (default: 1024)
+}
+
+// Validate checks the tokenizer configuration.
+func (c Config) Validate() error {
+	switch c.Counter {
+	case "", CounterTiktoken, CounterApprox:
+	case CounterAnthropicAPI:
+		if c.APIKey == "" {
+			return fmt.Errorf("tokenizer.api_key is required for the anthropic_api counter")
+		}
+	default:
+		return fmt.Errorf("invalid tokenizer.counter %q (must be tiktoken, approx or anthropic_api)", c.Counter)
+	}
+	if c.Timeout < 0 || c.CacheSize < 0 {
+		return fmt.Errorf("tokenizer.timeout and tokenizer.cache_size must not be negative")
+	}
+	return nil
+}
+
+// New returns the counter selected by cfg.
+func New(cfg Config) (TokenCounter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Counter {
+	case CounterApprox:
+		return Approx{}, nil
+	case CounterAnthropicAPI:
+		return NewAnthropicCounter(cfg), nil
+	default:
+		return Tiktoken{}, nil
+	}
+}
+
+// Tiktoken counts with the model's tiktoken encoding.
+type Tiktoken struct{}
+
+func (Tiktoken) CountText(text, model string) int { return CountTokensForModel(text, model) }
+
+func (Tiktoken) CountRequest(body []byte, model string) int { return CountBytesForModel(body, model) }
+
+// approxRatios maps model prefixes to letters and digits per token. Symbols
+// (JSON and code punctuation) rarely merge with neighbours and are counted
+// separately, which is where a flat bytes/4 undercounts code.
+var approxRatios = []struct {
+	prefix        string
+	charsPerToken float64
+}{
+	{"claude", 3.5},
+	{"gemini", 4.0},
+	{"gpt", 4.0},
+	{"o1", 4.0},
+	{"o3", 4.0},
+	{"o4", 4.0},
+}
+
+// approxDefaultRatio is used for models missing from approxRatios.
+const approxDefaultRatio = 4.0
+
+// approxSymbolTokens is the token cost of one punctuation or symbol character.
+const approxSymbolTokens = 0.5
+
+// Approx estimates tokens from character classes, without encoder files.
+type Approx struct{}
+
+func (Approx) CountText(text, model string) int {
+	ratio := approxDefaultRatio
+	m := strings.ToLower(model)
+	for _, r := range approxRatios {
+		if strings.HasPrefix(m, r.prefix) {
+			ratio = r.charsPerToken
+			break
+		}
+	}
+	var word, symbol, other int
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word++
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol++
+		default:
+			other++ // Non-ASCII letters: roughly a token each
+		}
+	}
+	return int(math.Ceil(float64(word)/ratio + float64(symbol)*approxSymbolTokens + float64(other)))
+}
+
+func (a Approx) CountRequest(body []byte, model string) int { return a.CountText(string(body), model) }
+
+// anthropicCountFields are the request fields count_tokens accepts.
+var anthropicCountFields = []string{"messages", "system", "tools", "tool_choice", "thinking"}
+
+// AnthropicCounter counts Claude request bodies with Anthropic's count_tokens
+// endpoint and caches the result by body hash. Text, non-Claude models and
+// failed calls fall back to tiktoken. Thread-safe.
+type AnthropicCounter struct {
+	endpoint  string
+	apiKey    string
+	client    *http.Client
+	fallback  TokenCounter
+	cacheSize int
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]int
+}
+
+// NewAnthropicCounter creates the anthropic_api counter.
+func NewAnthropicCounter(cfg Config) *AnthropicCounter {
+	c := &AnthropicCounter{
+		endpoint:  cfg.Endpoint,
+		apiKey:    cfg.APIKey,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		fallback:  Tiktoken{},
+		cacheSize: cfg.CacheSize,
+		cache:     make(map[[sha256.Size]byte]int),
+	}
+	if c.endpoint == "" {
+		c.endpoint = DefaultAnthropicCountEndpoint
+	}
+	if c.client.Timeout <= 0 {
+		c.client.Timeout = DefaultAnthropicCountTimeout
+	}
+	if c.cacheSize <= 0 {
+		c.cacheSize = DefaultAnthropicCountCache
+	}
+	return c
+}
+
+func (c *AnthropicCounter) CountText(text, model string) int {
+	return c.fallback.CountText(text, model)
+}
+
+func (c *AnthropicCounter) CountRequest(body []byte, model string) int {
+	if !strings.HasPrefix(strings.ToLower(model), "claude") {
+		return c.fallback.CountRequest(body, model)
+	}
+	key := sha256.Sum256(append([]byte(model+"\x00"), body...))
+	c.mu.Lock()
+	n, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n, err := c.count(body, model)
+	if err != nil {
+		log.Debug().Err(err).Str("model", model).Msg("tokenizer: count_tokens failed, using tiktoken")
+		return c.fallback.CountRequest(body, model)
+	}
+	c.mu.Lock()
+	if len(c.cache) >= c.cacheSize {
+		clear(c.cache) // Bodies grow turn by turn; old entries are rarely hit again
+	}
+	c.cache[key] = n
+	c.mu.Unlock()
+	return n
+}
+
+// count calls count_tokens with the countable fields of body.
+func (c *AnthropicCounter) count(body []byte, model string) (int, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return 0, fmt.Errorf("parse body: %w", err)
+	}
+	if _, ok := fields["messages"]; !ok {
+		return 0, fmt.Errorf("not a messages request")
+	}
+	req := map[string]json.RawMessage{}
+	for _, f := range anthropicCountFields {
+		if v, ok := fields[f]; ok {
+			req[f] = v
+		}
+	}
+	req["model"], _ = json.Marshal(model)
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.client.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := c.client.Do(httpReq) // #nosec G107,G704 -- endpoint from config
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count_tokens: status %d", resp.StatusCode)
+	}
+	var out struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return 0, fmt.Errorf("count_tokens: %w", err)
+	}
+	return out.InputTokens, nil
+}
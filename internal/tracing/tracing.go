@@ -0,0 +1,158 @@
+// Package tracing exports OpenTelemetry spans for each stage of a proxied
+// request over OTLP/HTTP.
+//
+// The gateway starts one server span per LLM request. A W3C traceparent header
+// from the client makes it a child of the agent's own trace, so gateway stages
+// (preemptive summarization, compression, upstream forwards, phantom loop
+// iterations, stream relay) show up inside the agent's trace. Stage spans are
+// started with Start, which takes the tracer from the span already in ctx:
+// nothing needs to be threaded through, and with tracing off every span is a
+// no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName is the service.name resource attribute when unset.
+const DefaultServiceName = "context-gateway"
+
+// instrumentationName identifies the gateway's tracer.
+const instrumentationName = "github.com/compresr/context-gateway"
+
+// Span names, one per request stage.
+const (
+	SpanRequest          = "gateway.request"
+	SpanPreemptive       = "gateway.preemptive_summarization"
+	SpanCompression      = "gateway.compression"
+	SpanUpstreamForward  = "gateway.upstream_forward"
+	SpanPhantomIteration = "gateway.phantom_loop.iteration"
+	SpanStream           = "gateway.stream"
+)
+
+// Config enables span export.
+type Config struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint"`     // OTLP/HTTP collector URL, e.g. http://localhost:4318 (default: OTEL_EXPORTER_OTLP_* env, then localhost:4318)
+	Headers     map[string]string `yaml:"headers"`      // Sent with every export, e.g. an auth header
+	ServiceName string            `yaml:"service_name"` // service.name resource attribute (default: context-gateway)
+	SampleRatio float64           `yaml:"sample_ratio"` // Share of new traces sampled (default: 1); sampled parents are always followed
+}
+
+// Validate checks the tracing configuration.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("tracing.endpoint must be an http(s) URL, got %q", c.Endpoint)
+		}
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.SampleRatio)
+	}
+	return nil
+}
+
+// Provider owns the span exporter. Safe to use on a nil receiver (disabled).
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// New creates a Provider that batches spans to the configured collector.
+// Returns nil when tracing is disabled.
+func New(cfg Config) (*Provider, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	opts := []otlptracehttp.Option{}
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		if u, _ := url.Parse(cfg.Endpoint); strings.Trim(u.Path, "/") == "" {
+			opts = append(opts, otlptracehttp.WithURLPath("/v1/traces"))
+		}
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	// The exporter connects lazily: an unreachable collector only fails exports.
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	return &Provider{tp: tp}, nil
+}
+
+// StartRequest starts the server span for an incoming request, continuing
+// the client's trace when r carries a traceparent header.
+func (p *Provider) StartRequest(r *http.Request, requestID string) (context.Context, trace.Span) {
+	ctx := r.Context()
+	if p == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	return p.tp.Tracer(instrumentationName).Start(ctx, SpanRequest,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("gateway.request_id", requestID),
+		))
+}
+
+// Shutdown exports buffered spans, bounded by ctx.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+// Start starts a child span of the span in ctx. Without a recording span in
+// ctx the returned span is a no-op.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndHTTP ends an upstream call span with the response status or error.
+func EndHTTP(span trace.Span, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp != nil:
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	span.End()
+}
@@ -2,11 +2,11 @@ package tui
 
 import (
 	"os"
-	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/userdir"
 )
 
 // PROVIDER DEFINITIONS
@@ -90,8 +90,7 @@ func loadProviders() []ProviderInfo {
 		return providers
 	}
 
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		userPath := filepath.Join(homeDir, ".config", "context-gateway", "external_providers.yaml")
+	if userPath := userdir.Path("external_providers.yaml"); userPath != "" {
 		if providers := tryLoad(userPath); len(providers) > 0 {
 			return providers
 		}
@@ -252,8 +251,7 @@ func loadCompresrModels() CompresrConfig {
 		return &result
 	}
 
-	if homeDir, err := os.UserHomeDir(); err == nil {
-		userPath := filepath.Join(homeDir, ".config", "context-gateway", "compresr_models.yaml")
+	if userPath := userdir.Path("compresr_models.yaml"); userPath != "" {
 		if cfg := tryLoad(userPath); cfg != nil {
 			return *cfg
 		}
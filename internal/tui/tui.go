@@ -9,6 +9,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"runtime"
 	"strings"
 
 	"golang.org/x/term"
@@ -16,7 +17,8 @@ import (
 
 // COLORS
 
-const (
+// ANSI color codes. Empty in plain mode (see SetPlain).
+var (
 	ColorReset  = "\033[0m"
 	ColorBold   = "\033[1m"
 	ColorDim    = "\033[2m"
@@ -28,6 +30,68 @@ const (
 	ColorBrand  = "\033[38;2;23;128;68m" // Compresr brand green
 )
 
+// PLAIN MODE
+
+// plain disables ANSI control: menus become numbered prompts and colors,
+// cursor movement and terminal titles are suppressed.
+var plain bool
+
+// colorVars lists the color variables cleared in plain mode, with their ANSI values.
+var colorVars = []struct {
+	v    *string
+	ansi string
+}{
+	{&ColorReset, ColorReset}, {&ColorBold, ColorBold}, {&ColorDim, ColorDim},
+	{&ColorGreen, ColorGreen}, {&ColorBlue, ColorBlue}, {&ColorCyan, ColorCyan},
+	{&ColorYellow, ColorYellow}, {&ColorRed, ColorRed}, {&ColorBrand, ColorBrand},
+}
+
+// DetectPlain reports whether TERM says the terminal cannot handle cursor
+// control: unset (CI runners, captured shells) or "dumb". Windows consoles
+// leave TERM unset, so it is not used there.
+func DetectPlain() bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	t := os.Getenv("TERM")
+	return t == "" || t == "dumb"
+}
+
+// SetPlain switches plain mode on or off. Call before printing anything.
+func SetPlain(on bool) {
+	plain = on
+	for _, c := range colorVars {
+		if on {
+			*c.v = ""
+		} else {
+			*c.v = c.ansi
+		}
+	}
+}
+
+// IsPlain reports whether plain mode is on.
+func IsPlain() bool {
+	return plain
+}
+
+// ClearLine replaces a pending status line (printed without a newline), such
+// as "Validating...". In plain mode the status line is ended instead.
+func ClearLine() {
+	if plain {
+		fmt.Println()
+		return
+	}
+	fmt.Print("\r\033[2K")
+}
+
+// ClearPreviousLine erases the line above the cursor. No-op in plain mode.
+func ClearPreviousLine() {
+	if plain {
+		return
+	}
+	fmt.Print("\033[1A\033[2K\r")
+}
+
 // PRINT FUNCTIONS
 
 // PrintBanner displays the Context Gateway ASCII banner.
@@ -78,11 +142,17 @@ func PrintStep(msg string) {
 // SetTerminalTitle sets the terminal window/tab title using OSC escape sequence.
 // This persists across scrolling, keeping status info always visible.
 func SetTerminalTitle(title string) {
+	if plain {
+		return
+	}
 	fmt.Printf("\033]0;%s\007", title)
 }
 
 // ClearTerminalTitle resets the terminal title to default.
 func ClearTerminalTitle() {
+	if plain {
+		return
+	}
 	fmt.Print("\033]0;\007")
 }
 
@@ -103,7 +173,7 @@ var menuLines int
 
 // ClearLastMenu clears the lines used by the previous menu
 func ClearLastMenu() {
-	if menuLines > 0 {
+	if menuLines > 0 && !plain {
 		// Move up and clear each line
 		for i := 0; i < menuLines; i++ {
 			fmt.Print("\033[A\033[2K") // Move up, clear line
@@ -121,7 +191,7 @@ func SelectMenu(prompt string, items []MenuItem) (int, error) {
 	}
 
 	stdinFd := int(os.Stdin.Fd()) // #nosec G115 -- fd fits in int on all supported platforms
-	if !term.IsTerminal(stdinFd) {
+	if plain || !term.IsTerminal(stdinFd) {
 		return selectNumberedMenu(prompt, items)
 	}
 
@@ -363,34 +433,66 @@ func SelectMenu(prompt string, items []MenuItem) (int, error) {
 	}
 }
 
-// selectNumberedMenu is a fallback for non-interactive terminals.
+// selectNumberedMenu is the menu for plain mode and non-interactive terminals.
+// Editable items prompt for a new value and show the menu again, like inline
+// editing in the arrow-key menu.
 func selectNumberedMenu(prompt string, items []MenuItem) (int, error) {
-	fmt.Printf("\n%s%s%s%s\n\n", ColorBold, ColorCyan, prompt, ColorReset)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\n%s%s%s%s\n\n", ColorBold, ColorCyan, prompt, ColorReset)
 
-	for i, item := range items {
-		fmt.Printf("  %s[%d]%s %s", ColorGreen, i+1, ColorReset, item.Label)
-		if item.Description != "" {
-			fmt.Printf(" %s- %s%s", ColorDim, item.Description, ColorReset)
+		for i, item := range items {
+			fmt.Printf("  %s[%d]%s %s", ColorGreen, i+1, ColorReset, item.Label)
+			desc := item.Description
+			if item.Locked && item.LockedReason != "" {
+				desc = "[" + item.LockedReason + "]"
+			}
+			if desc != "" {
+				fmt.Printf(" %s- %s%s", ColorDim, desc, ColorReset)
+			}
+			if item.Locked {
+				fmt.Print(" (locked)")
+			}
+			fmt.Println()
+		}
+		fmt.Printf("  %s[0]%s Cancel\n\n", ColorYellow, ColorReset)
+
+		selected, err := readMenuNumber(reader, items)
+		if err != nil {
+			return -1, err
+		}
+		if !items[selected].Editable {
+			return selected, nil
+		}
+		fmt.Printf("%s [%s]: ", items[selected].Label, items[selected].Description)
+		input, _ := reader.ReadString('\n')
+		if input = strings.TrimSpace(input); input != "" {
+			items[selected].Description = input
 		}
-		fmt.Println()
 	}
-	fmt.Printf("  %s[0]%s Cancel\n\n", ColorYellow, ColorReset)
+}
 
-	reader := bufio.NewReader(os.Stdin)
+// readMenuNumber reads choices until one names a selectable item.
+func readMenuNumber(reader *bufio.Reader, items []MenuItem) (int, error) {
 	for {
 		fmt.Print("Enter number: ")
-		input, _ := reader.ReadString('\n')
+		input, err := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
+		if input == "" && err != nil {
+			return -1, fmt.Errorf("cancelled")
+		}
 
 		if input == "0" || input == "q" {
 			return -1, fmt.Errorf("cancelled")
 		}
 
 		var num int
-		if _, err := fmt.Sscanf(input, "%d", &num); err == nil {
-			if num >= 1 && num <= len(items) {
+		if _, err := fmt.Sscanf(input, "%d", &num); err == nil && num >= 1 && num <= len(items) {
+			if !items[num-1].Locked {
 				return num - 1, nil
 			}
+			fmt.Printf("%s is locked.\n", items[num-1].Label)
+			continue
 		}
 		fmt.Printf("Invalid choice. Enter 1-%d or 0 to cancel.\n", len(items))
 	}
@@ -58,7 +58,7 @@ func RunWizard(title string, fields []WizardField) (*WizardResult, error) {
 	}
 
 	stdinFd := int(os.Stdin.Fd()) // #nosec G115 -- fd fits in int on all supported platforms
-	if !term.IsTerminal(stdinFd) {
+	if plain || !term.IsTerminal(stdinFd) {
 		return runWizardFallback(title, activeFields)
 	}
 
@@ -0,0 +1,52 @@
+// Package userdir locates the per-user context-gateway directory that holds
+// configs, agent definitions, the .env file and persisted state.
+package userdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AppName is the directory name under the platform config root.
+const AppName = "context-gateway"
+
+// Dir returns the per-user directory: ~/.config/context-gateway on Unix and
+// %APPDATA%\context-gateway on Windows.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("userdir: unable to determine home directory: %w", err)
+	}
+	return Resolve(runtime.GOOS, home, os.Getenv("APPDATA"), exists), nil
+}
+
+// Path joins elem onto Dir. It returns "" if the home directory is unknown.
+func Path(elem ...string) string {
+	dir, err := Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// Resolve picks the directory for goos. Windows installs that already use
+// ~/.config/context-gateway keep it until %APPDATA%\context-gateway exists,
+// so upgrading does not hide existing configs and state.
+func Resolve(goos, home, appData string, exists func(string) bool) string {
+	legacy := filepath.Join(home, ".config", AppName)
+	if goos != "windows" || appData == "" {
+		return legacy
+	}
+	dir := filepath.Join(appData, AppName)
+	if !exists(dir) && exists(legacy) {
+		return legacy
+	}
+	return dir
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
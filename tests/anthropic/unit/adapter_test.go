@@ -7,6 +7,7 @@ import (
 	"github.com/compresr/context-gateway/internal/adapters"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 )
 
 // =============================================================================
@@ -85,6 +86,46 @@ func TestAnthropic_ExtractToolOutput_ArrayContent(t *testing.T) {
 	assert.Equal(t, "first part second part", extracted[0].Content)
 }
 
+func TestAnthropic_ApplyToolOutput_KeepsImageBlocks(t *testing.T) {
+	adapter := adapters.NewAnthropicAdapter()
+
+	body := []byte(`{"model":"claude-3","messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":[{"type":"text","text":"page loaded"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}},{"type":"text","text":" and scrolled"}]}]}]}`)
+
+	modified, err := adapter.ApplyToolOutput(body, []adapters.CompressedResult{
+		{ID: "toolu_001", Compressed: "compressed summary", MessageIndex: 0, BlockIndex: 0},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `[{"type":"text","text":"compressed summary"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}]`,
+		gjson.GetBytes(modified, "messages.0.content.0.content").Raw)
+}
+
+func TestAnthropic_ExtractToolImages(t *testing.T) {
+	adapter := adapters.NewAnthropicAdapter()
+
+	body := []byte(`{"model":"claude-3","messages":[
+		{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAEC"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_001","content":[
+			{"type":"text","text":"screenshot"},
+			{"type":"image","source":{"type":"base64","media_type":"image/jpeg","data":"AQID"}}
+		]}]}
+	]}`)
+
+	images := adapter.ExtractToolImages(body)
+	require.Len(t, images, 1, "only tool_result images are extracted")
+	assert.Equal(t, "toolu_001", images[0].ToolCallID)
+	assert.Equal(t, "image/jpeg", images[0].MediaType)
+	assert.Equal(t, []byte{1, 2, 3}, images[0].Data)
+
+	images[0].Data = []byte{9}
+	images[0].MediaType = "image/png"
+	modified, err := adapter.ApplyToolImages(body, images)
+	require.NoError(t, err)
+	assert.Equal(t, "CQ==", gjson.GetBytes(modified, "messages.1.content.0.content.1.source.data").String())
+	assert.Equal(t, "image/png", gjson.GetBytes(modified, "messages.1.content.0.content.1.source.media_type").String())
+	assert.Equal(t, "AAEC", gjson.GetBytes(modified, "messages.0.content.0.source.data").String())
+}
+
 // =============================================================================
 // ANTHROPIC TOOL DISCOVERY TESTS (Stub - Not Yet Implemented)
 // =============================================================================
@@ -14,6 +14,7 @@ package unit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -283,6 +284,9 @@ func TestGateway_AuthFallback_TelemetryAndInitLogs(t *testing.T) {
 	resp.Body.Close()
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 
+	// Telemetry writes are async; shutdown drains and syncs the writers.
+	require.NoError(t, gw.Shutdown(context.Background()))
+
 	telemetryBytes, err := os.ReadFile(telemetryPath)
 	require.NoError(t, err)
 	lines := strings.Split(strings.TrimSpace(string(telemetryBytes)), "\n")
@@ -0,0 +1,242 @@
+// Bedrock Event Stream Integration Tests - Mock Upstream
+//
+// invoke-with-response-stream answers with binary application/vnd.amazon.eventstream
+// frames instead of SSE. These tests check that the gateway treats such
+// requests as streaming, reads usage from the frames and intercepts an
+// expand_context call inside them, while the client receives event-stream frames.
+
+package integration
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/tests/testkit"
+)
+
+const bedrockStreamPath = "/model/anthropic.claude-sonnet-4-5-20250929-v1:0/invoke-with-response-stream"
+
+var shadowIDPattern = regexp.MustCompile(`shadow_[0-9a-f]+`)
+
+// eventStreamMessage frames one event-stream message with string headers.
+func eventStreamMessage(eventType string, payload []byte) []byte {
+	var headers bytes.Buffer
+	for _, h := range [][2]string{{":event-type", eventType}, {":content-type", "application/json"}, {":message-type", "event"}} {
+		headers.WriteByte(byte(len(h[0])))
+		headers.WriteString(h[0])
+		headers.WriteByte(7) // string
+		_ = binary.Write(&headers, binary.BigEndian, uint16(len(h[1])))
+		headers.WriteString(h[1])
+	}
+	msg := binary.BigEndian.AppendUint32(nil, uint32(16+headers.Len()+len(payload)))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(headers.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, headers.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+// eventStreamChunk frames one Anthropic event the way Bedrock does: a "chunk"
+// event whose payload holds the event JSON base64-encoded.
+func eventStreamChunk(event string) []byte {
+	payload, _ := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(event))})
+	return eventStreamMessage("chunk", payload)
+}
+
+func eventStream(events ...string) []byte {
+	var out []byte
+	for _, e := range events {
+		out = append(out, eventStreamChunk(e)...)
+	}
+	return out
+}
+
+// bedrockStreamUpstream serves invoke-with-response-stream: the first request
+// is answered with respond(body), later ones with plain text.
+type bedrockStreamUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	auths  []string
+	bodies [][]byte
+}
+
+func newBedrockStreamUpstream(t *testing.T, respond func(body []byte) []byte) *bedrockStreamUpstream {
+	t.Helper()
+	u := &bedrockStreamUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.paths = append(u.paths, r.URL.Path)
+		u.auths = append(u.auths, r.Header.Get("Authorization"))
+		u.bodies = append(u.bodies, body)
+		call := len(u.bodies)
+		u.mu.Unlock()
+
+		w.Header().Set("Content-Type", gateway.EventStreamContentType)
+		if call == 1 {
+			_, _ = w.Write(respond(body))
+			return
+		}
+		_, _ = w.Write(eventStream(
+			`{"type":"message_start","message":{"id":"msg_2","usage":{"input_tokens":150}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The log shows three errors."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":8}}`,
+			`{"type":"message_stop"}`,
+		))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *bedrockStreamUpstream) requests() [][]byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([][]byte(nil), u.bodies...)
+}
+
+func sendBedrockStreamRequest(t *testing.T, gwURL, targetURL, toolOutput string) (*http.Response, []byte) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"anthropic_version": "bedrock-2023-05-31",
+		"max_tokens":        500,
+		"messages": []map[string]any{
+			{"role": "user", "content": "What are the key points?"},
+			{"role": "assistant", "content": []map[string]any{
+				{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": map[string]string{"path": "system.log"}},
+			}},
+			{"role": "user", "content": []map[string]any{
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": toolOutput},
+			}},
+		},
+	})
+	return postBedrock(t, gwURL+bedrockStreamPath, targetURL, body)
+}
+
+func postBedrock(t *testing.T, url, targetURL string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Target-URL", targetURL)
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, respBody
+}
+
+// newBedrockStreamGateway enables Bedrock with static test credentials, so
+// requests are SigV4-signed without probing for instance credentials.
+func newBedrockStreamGateway(t *testing.T) *httptest.Server {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	cfg := compressionConfig()
+	cfg.Bedrock.Enabled = true
+	cfg.Pipes.ToolOutput.TargetCompressionRatio = 0.1
+	gw := httptest.NewServer(gateway.New(cfg).Handler())
+	t.Cleanup(gw.Close)
+	return gw
+}
+
+func TestIntegration_BedrockEventStream_ExpandContextIntercepted(t *testing.T) {
+	upstream := newBedrockStreamUpstream(t, func(body []byte) []byte {
+		input, _ := json.Marshal(map[string]string{"id": shadowIDPattern.FindString(string(body))})
+		partial, _ := json.Marshal(string(input))
+		return eventStream(
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":100}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_expand_1","name":"expand_context","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":`+string(partial)+`}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+	gw := newBedrockStreamGateway(t)
+
+	resp, stream := sendBedrockStreamRequest(t, gw.URL, upstream.URL, testkit.LargeToolOutput(4000))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(stream))
+	assert.Equal(t, gateway.EventStreamContentType, resp.Header.Get("Content-Type"))
+
+	requests := upstream.requests()
+	require.Len(t, requests, 2, "expand_context must be answered with a retry")
+	assert.Contains(t, string(requests[0]), "[REF:", "the tool output is compressed")
+	assert.NotContains(t, string(requests[0]), `"stream"`, "Bedrock streams by path")
+	assert.Contains(t, string(requests[1]), "Line 3:", "the retry carries the expanded tool output")
+	for i, p := range upstream.paths {
+		assert.Equal(t, bedrockStreamPath, p)
+		assert.True(t, strings.HasPrefix(upstream.auths[i], "AWS4-HMAC-SHA256 "), "requests are SigV4-signed")
+	}
+
+	decoded := string(gateway.DecodeEventStream(stream))
+	assert.Contains(t, decoded, "The log shows three errors.")
+	assert.NotContains(t, decoded, "expand_context")
+	assert.Equal(t, 6, strings.Count(decoded, "data: "), "the client gets the retry's frames unchanged")
+}
+
+func TestIntegration_BedrockEventStream_RelayedWithUsage(t *testing.T) {
+	frames := eventStream(
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":42}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Nothing to expand."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	)
+	upstream := newBedrockStreamUpstream(t, func([]byte) []byte { return frames })
+	gw := newBedrockStreamGateway(t)
+
+	resp, stream := sendBedrockStreamRequest(t, gw.URL, upstream.URL, testkit.LargeToolOutput(4000))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(stream))
+	assert.Equal(t, frames, stream, "frames reach the client byte for byte")
+	assert.Len(t, upstream.requests(), 1)
+
+	usage, stopReason := gateway.ParseStreamUsage(gateway.DecodeEventStream(stream))
+	assert.Equal(t, 42, usage.InputTokens)
+	assert.Equal(t, 5, usage.OutputTokens)
+	assert.Equal(t, "end_turn", stopReason)
+}
+
+func TestIntegration_BedrockEventStream_Converse(t *testing.T) {
+	frames := eventStreamMessage("messageStart", []byte(`{"role":"assistant"}`))
+	frames = append(frames, eventStreamMessage("contentBlockDelta", []byte(`{"contentBlockIndex":0,"delta":{"text":"Hello."}}`))...)
+	frames = append(frames, eventStreamMessage("messageStop", []byte(`{"stopReason":"end_turn"}`))...)
+	frames = append(frames, eventStreamMessage("metadata", []byte(`{"usage":{"inputTokens":12,"outputTokens":3,"totalTokens":15}}`))...)
+	upstream := newBedrockStreamUpstream(t, func([]byte) []byte { return frames })
+	gw := newBedrockStreamGateway(t)
+
+	const path = "/model/anthropic.claude-sonnet-4-5-20250929-v1:0/converse-stream"
+	resp, stream := postBedrock(t, gw.URL+path, upstream.URL,
+		[]byte(`{"messages":[{"role":"user","content":[{"text":"Say hello."}]}],"inferenceConfig":{"maxTokens":50}}`))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(stream))
+	assert.Equal(t, frames, stream)
+
+	requests := upstream.requests()
+	require.Len(t, requests, 1)
+	assert.NotContains(t, string(requests[0]), `"tools"`, "Converse requests get no Anthropic-format phantom tools")
+
+	usage, stopReason := gateway.ParseStreamUsage(gateway.DecodeEventStream(stream))
+	assert.Equal(t, 12, usage.InputTokens)
+	assert.Equal(t, 3, usage.OutputTokens)
+	assert.Equal(t, "end_turn", stopReason)
+}
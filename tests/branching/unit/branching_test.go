@@ -0,0 +1,162 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/branching"
+)
+
+func msgs(contents ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(contents))
+	for i, c := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		out[i] = json.RawMessage(fmt.Sprintf(`{"role":%q,"content":%q}`, role, c))
+	}
+	return out
+}
+
+func TestPrefixHashes_StableAcrossCacheControlAndFirstMessage(t *testing.T) {
+	a := branching.PrefixHashes([]json.RawMessage{
+		json.RawMessage(`{"role":"user","content":"task <system-reminder>v1</system-reminder>"}`),
+		json.RawMessage(`{"role":"assistant","content":[{"type":"text","text":"ok","cache_control":{"type":"ephemeral"}}]}`),
+	})
+	b := branching.PrefixHashes([]json.RawMessage{
+		json.RawMessage(`{"role":"user","content":"task <system-reminder>v2</system-reminder>"}`),
+		json.RawMessage(`{"content":[{"text":"ok","type":"text"}],"role":"assistant"}`),
+	})
+	require.Len(t, a, 2)
+	assert.Equal(t, a, b)
+
+	c := branching.PrefixHashes(msgs("task", "other"))
+	assert.Equal(t, a[0], c[0])
+	assert.NotEqual(t, a[1], c[1])
+}
+
+func TestHashBody_ProviderFields(t *testing.T) {
+	anthropic := branching.HashBody([]byte(`{"messages":[{"role":"user","content":"a"},{"role":"assistant","content":"b"}]}`))
+	responses := branching.HashBody([]byte(`{"input":[{"role":"user","content":"a"}]}`))
+	gemini := branching.HashBody([]byte(`{"contents":[{"role":"user","parts":[{"text":"a"}]}]}`))
+	assert.Len(t, anthropic, 2)
+	assert.Len(t, responses, 1)
+	assert.Len(t, gemini, 1)
+	assert.Nil(t, branching.HashBody([]byte(`{"input":"plain string"}`)))
+}
+
+func TestTracker_ContinuationStaysOnRoot(t *testing.T) {
+	tr := branching.NewTracker(time.Hour)
+	defer tr.Stop()
+
+	r1 := tr.Resolve("conv", branching.PrefixHashes(msgs("task")))
+	r2 := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "answer", "more")))
+	// Retry of an earlier turn (shorter history) is not a fork.
+	r3 := tr.Resolve("conv", branching.PrefixHashes(msgs("task")))
+
+	for _, r := range []branching.Resolution{r1, r2, r3} {
+		assert.Equal(t, "conv", r.SessionID)
+		assert.False(t, r.Forked)
+	}
+	assert.Equal(t, 1, tr.Branches("conv"))
+}
+
+func TestTracker_EditForksWithSharedAncestry(t *testing.T) {
+	tr := branching.NewTracker(time.Hour)
+	defer tr.Stop()
+
+	tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2", "a2", "q3")))
+
+	fork := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited")))
+	require.True(t, fork.Forked)
+	assert.Equal(t, "conv", fork.ParentSessionID)
+	assert.Equal(t, 2, fork.ForkIndex)
+	assert.Equal(t, branching.SessionID("conv", fork.BranchID), fork.SessionID)
+
+	// Continuing the edited branch stays on it; going back to the original line returns to root.
+	next := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited", "a2'", "q3'")))
+	assert.False(t, next.Forked)
+	assert.Equal(t, fork.SessionID, next.SessionID)
+
+	root := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2", "a2", "q3", "a3", "q4")))
+	assert.Equal(t, "conv", root.SessionID)
+
+	// A branch of the branch records the child as its parent.
+	nested := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited", "a2'", "q3 again")))
+	require.True(t, nested.Forked)
+	assert.Equal(t, fork.SessionID, nested.ParentSessionID)
+	assert.Equal(t, 4, nested.ForkIndex)
+	assert.Equal(t, 3, tr.Branches("conv"))
+}
+
+func TestTracker_SameEditMapsToSameBranchID(t *testing.T) {
+	hashes := branching.PrefixHashes(msgs("task", "a1", "q2 edited"))
+
+	tr1 := branching.NewTracker(time.Hour)
+	defer tr1.Stop()
+	tr1.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2")))
+	first := tr1.Resolve("conv", hashes)
+
+	tr2 := branching.NewTracker(time.Hour)
+	defer tr2.Stop()
+	tr2.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2")))
+	second := tr2.Resolve("conv", hashes)
+
+	assert.Equal(t, first.SessionID, second.SessionID)
+}
+
+func TestTracker_EvictsOldestBranch(t *testing.T) {
+	tr := branching.NewTracker(time.Hour)
+	defer tr.Stop()
+
+	tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2")))
+	var children, evicted []string
+	for i := 0; i < branching.MaxBranches+5; i++ {
+		res := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", fmt.Sprintf("edit %d", i))))
+		children = append(children, res.SessionID)
+		if res.EvictedSessionID != "" {
+			evicted = append(evicted, res.EvictedSessionID)
+		}
+	}
+	assert.Equal(t, branching.MaxBranches, tr.Branches("conv"))
+
+	// Each dropped branch is reported once, oldest first, so callers can free its state.
+	assert.Equal(t, children[:6], evicted)
+
+	// The root branch is never evicted.
+	assert.Equal(t, "conv", tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2"))).SessionID)
+}
+
+func TestTracker_NilAndEmpty(t *testing.T) {
+	var tr *branching.Tracker
+	assert.Equal(t, "conv", tr.Resolve("conv", []string{"x"}).SessionID)
+
+	tr = branching.NewTracker(time.Hour)
+	defer tr.Stop()
+	assert.Equal(t, "", tr.Resolve("", []string{"x"}).SessionID)
+	assert.Equal(t, "conv", tr.Resolve("conv", nil).SessionID)
+}
+
+func TestTracker_LookupDoesNotRecord(t *testing.T) {
+	tr := branching.NewTracker(time.Hour)
+	defer tr.Stop()
+
+	assert.Equal(t, "conv", tr.Lookup("conv", branching.PrefixHashes(msgs("task"))), "unknown conversation")
+	assert.Equal(t, 0, tr.Branches("conv"))
+
+	tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2")))
+	fork := tr.Resolve("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited")))
+	require.True(t, fork.Forked)
+
+	assert.Equal(t, fork.SessionID, tr.Lookup("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited", "a2"))))
+	assert.Equal(t, "conv", tr.Lookup("conv", branching.PrefixHashes(msgs("task", "a1", "q2", "a2"))))
+	// A request that would fork maps to the branch it would fork from.
+	assert.Equal(t, fork.SessionID, tr.Lookup("conv", branching.PrefixHashes(msgs("task", "a1", "q2 edited", "a2", "q3 other"))))
+	assert.Equal(t, 2, tr.Branches("conv"), "lookups never create branches")
+}
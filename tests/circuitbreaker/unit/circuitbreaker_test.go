@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/circuitbreaker"
+)
+
+func TestCircuitBreaker_OpensAfterMaxFailures(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.WithMaxFailures(2), circuitbreaker.WithOpenDuration(time.Hour))
+	assert.Equal(t, circuitbreaker.StateClosed, cb.State())
+
+	cb.RecordFailure()
+	assert.True(t, cb.Allow())
+	cb.RecordFailure()
+	assert.False(t, cb.Allow())
+	assert.Equal(t, circuitbreaker.StateOpen, cb.State())
+	assert.Equal(t, 2, cb.Failures())
+
+	cb.Reset()
+	assert.True(t, cb.Allow())
+	assert.Equal(t, circuitbreaker.StateClosed, cb.State())
+}
+
+func TestCircuitBreaker_HalfOpenAdmitsAllByDefault(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.WithMaxFailures(1), circuitbreaker.WithOpenDuration(10*time.Millisecond))
+	cb.RecordFailure()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, circuitbreaker.StateHalfOpen, cb.State())
+	assert.True(t, cb.Allow())
+	assert.True(t, cb.Allow())
+	cb.RecordFailure()
+	assert.True(t, cb.Allow(), "a failed probe does not reopen")
+}
+
+func TestCircuitBreaker_SingleProbe(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.WithMaxFailures(1), circuitbreaker.WithOpenDuration(20*time.Millisecond),
+		circuitbreaker.WithSingleProbe())
+	cb.RecordFailure()
+	time.Sleep(30 * time.Millisecond)
+
+	assert.True(t, cb.Allow(), "probe")
+	assert.False(t, cb.Allow(), "one probe at a time")
+
+	cb.RecordFailure()
+	assert.Equal(t, circuitbreaker.StateOpen, cb.State(), "failed probe reopens")
+	assert.False(t, cb.Allow())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, cb.Allow())
+	cb.RecordSuccess()
+	assert.Equal(t, circuitbreaker.StateClosed, cb.State())
+	assert.True(t, cb.Allow())
+	assert.True(t, cb.Allow())
+}
+
+func TestCircuitBreaker_SingleProbeExpires(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.WithMaxFailures(1), circuitbreaker.WithOpenDuration(20*time.Millisecond),
+		circuitbreaker.WithSingleProbe())
+	cb.RecordFailure()
+	time.Sleep(30 * time.Millisecond)
+
+	assert.True(t, cb.Allow())
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, cb.Allow(), "an unanswered probe expires after the open duration")
+}
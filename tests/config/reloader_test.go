@@ -1,6 +1,7 @@
 package config_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -265,3 +266,74 @@ func TestToYAML(t *testing.T) {
 		t.Fatalf("port mismatch: %d vs %d", reloaded.Server.Port, cfg.Server.Port)
 	}
 }
+
+func TestReloaderReload(t *testing.T) {
+	cfg := minimalConfig()
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	initial, _ := config.ToYAML(cfg)
+	if err := os.WriteFile(filePath, initial, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := config.NewReloader(cfg, filePath)
+
+	edited := *cfg
+	edited.CostControl.GlobalCap = 25
+	edited.Server.AllowedHosts = []string{"models.internal"}
+	data, _ := config.ToYAML(&edited)
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got.CostControl.GlobalCap != 25 || r.Current().CostControl.GlobalCap != 25 {
+		t.Fatalf("expected global_cap=25 after reload, got %f", r.Current().CostControl.GlobalCap)
+	}
+	if len(got.Server.AllowedHosts) != 1 || got.Server.AllowedHosts[0] != "models.internal" {
+		t.Fatalf("expected allowed_hosts from file, got %v", got.Server.AllowedHosts)
+	}
+
+	// An invalid file leaves the current config in place.
+	if err := os.WriteFile(filePath, []byte("server:\n  port: 0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("expected an invalid file to fail")
+	}
+	if r.Current().CostControl.GlobalCap != 25 {
+		t.Fatal("a failed reload must not change the config")
+	}
+
+	if _, err := config.NewReloader(cfg, "").Reload(); !errors.Is(err, config.ErrNoConfigFile) {
+		t.Fatalf("expected ErrNoConfigFile without a file, got %v", err)
+	}
+}
+
+func TestReloaderUpdatePatchesAllowedHosts(t *testing.T) {
+	r := config.NewReloader(minimalConfig(), "")
+
+	hosts := []string{"models.internal", "10.20.0.0/16"}
+	updated, err := r.UpdateSession(config.ConfigPatch{Server: &config.ServerPatch{AllowedHosts: &hosts}})
+	if err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if len(updated.Server.AllowedHosts) != 2 {
+		t.Fatalf("expected 2 allowed hosts, got %v", updated.Server.AllowedHosts)
+	}
+
+	bad := []string{"http://evil.example.com/"}
+	if _, err := r.UpdateSession(config.ConfigPatch{Server: &config.ServerPatch{AllowedHosts: &bad}}); err == nil {
+		t.Fatal("expected an invalid allowed_hosts entry to be rejected")
+	}
+	if got := r.Current().Server.AllowedHosts; len(got) != 2 {
+		t.Fatalf("a rejected patch must not change the config, got %v", got)
+	}
+
+	// The rejected patch is not kept in the session overrides.
+	enabled := false
+	if _, err := r.UpdateSession(config.ConfigPatch{Preemptive: &config.PreemptivePatch{Enabled: &enabled}}); err != nil {
+		t.Fatalf("a valid patch after a rejected one failed: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestAPIVersions_Defaults(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+api_versions:
+  enabled: true
+  rules:
+    - provider: anthropic
+      pin: "2023-06-01"
+    - provider: openai
+      allowed: ["responses=v1"]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.APIVersionModeReject, cfg.APIVersions.Mode)
+	require.Len(t, cfg.APIVersions.Rules, 2)
+	assert.Equal(t, "anthropic-version", cfg.APIVersions.Rules[0].Header)
+	assert.Equal(t, "OpenAI-Beta", cfg.APIVersions.Rules[1].Header)
+}
+
+func TestAPIVersions_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "unknown mode",
+			yaml:    "api_versions:\n  mode: block\n",
+			wantErr: "api_versions.mode",
+		},
+		{
+			name:    "missing provider",
+			yaml:    "api_versions:\n  rules:\n    - header: x-version\n      pin: v1\n",
+			wantErr: "provider is required",
+		},
+		{
+			name:    "no default header",
+			yaml:    "api_versions:\n  rules:\n    - provider: gemini\n      pin: v1\n",
+			wantErr: "header is required",
+		},
+		{
+			name:    "nothing to enforce",
+			yaml:    "api_versions:\n  rules:\n    - provider: anthropic\n",
+			wantErr: "set pin, allowed, or both",
+		},
+		{
+			name:    "duplicate rule",
+			yaml:    "api_versions:\n  rules:\n    - provider: anthropic\n      pin: a\n    - provider: Anthropic\n      header: Anthropic-Version\n      pin: b\n",
+			wantErr: "duplicate rule",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
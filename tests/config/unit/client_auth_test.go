@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestClientAuth_Loads(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+client_auth:
+  enabled: true
+  api_keys:
+    - key: gw-alice-0123456789
+      label: alice
+    - key: gw-alice-9876543210
+      label: alice
+`))
+	require.NoError(t, err)
+	assert.True(t, cfg.ClientAuth.Enabled)
+	assert.Len(t, cfg.ClientAuth.APIKeys, 2)
+	assert.False(t, cfg.ClientAuth.MTLS.Enabled())
+
+	eff := cfg.Effective()
+	require.NotNil(t, eff.ClientAuth)
+	assert.Equal(t, []string{"alice"}, eff.ClientAuth.Labels, "labels only, never keys")
+}
+
+func TestClientAuth_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"nothing configured", `
+client_auth:
+  enabled: true
+`, "needs api_keys or mtls"},
+		{"missing key", `
+client_auth:
+  enabled: true
+  api_keys:
+    - label: alice
+`, "key is required"},
+		{"short key", `
+client_auth:
+  enabled: true
+  api_keys:
+    - key: short
+      label: alice
+`, "at least 16 characters"},
+		{"missing label", `
+client_auth:
+  enabled: true
+  api_keys:
+    - key: gw-alice-0123456789
+`, "label is required"},
+		{"duplicate key", `
+client_auth:
+  enabled: true
+  api_keys:
+    - key: gw-alice-0123456789
+      label: alice
+    - key: gw-alice-0123456789
+      label: bob
+`, "listed twice"},
+		{"mtls without tls", `
+client_auth:
+  enabled: true
+  mtls:
+    client_ca_file: /tmp/clients-ca.pem
+`, "needs server.tls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestClientAuth_DisabledSkipsValidation(t *testing.T) {
+	_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+client_auth:
+  enabled: false
+  api_keys:
+    - key: short
+`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+func effectiveTestConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{Port: 18081, ReadTimeout: 30 * time.Second, WriteTimeout: 2 * time.Minute},
+		Providers: config.ProvidersConfig{
+			"anthropic": {ProviderAuth: "sk-ant-secret-value-1234", Model: "claude-haiku-4-5"},
+			"gemini":    {Auth: "oauth", Model: "gemini-2.0-flash"},
+		},
+		Pipes: config.PipesConfig{
+			ToolOutput:    config.ToolOutputPipeConfig{Enabled: true, Strategy: "compresr"},
+			ToolDiscovery: config.ToolDiscoveryPipeConfig{Enabled: false, Strategy: "tool-search"},
+		},
+		CostControl:   config.CostControlConfig{Enabled: true, SessionCap: 5},
+		Monitoring:    config.MonitoringConfig{TelemetryEnabled: true, TelemetryPath: "logs/telemetry.jsonl"},
+		CompresrCreds: config.CompresrCredsConfig{APIKey: "cmp_secret_abcdef"},
+	}
+}
+
+func TestEffective_RedactsSecrets(t *testing.T) {
+	eff := effectiveTestConfig().Effective()
+
+	data, err := json.Marshal(eff)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-ant-secret-value-1234")
+	assert.NotContains(t, string(data), "cmp_secret_abcdef")
+
+	assert.Equal(t, config.Redacted, eff.Providers["anthropic"].APIKey)
+	assert.Empty(t, eff.Providers["gemini"].APIKey, "unset keys stay empty")
+	assert.Equal(t, config.Redacted, eff.CompresrAPIKey)
+}
+
+func TestEffective_ReportsActiveSettings(t *testing.T) {
+	eff := effectiveTestConfig().Effective()
+
+	assert.Equal(t, 18081, eff.Listeners.Proxy)
+	assert.Equal(t, "2m0s", eff.Listeners.WriteTimeout)
+	assert.Equal(t, config.EffectivePipe{Enabled: true, Strategy: "compresr"}, eff.Pipes.ToolOutput)
+	assert.False(t, eff.Pipes.ToolDiscovery.Enabled)
+	assert.Equal(t, "api_key", eff.Providers["anthropic"].Auth, "auth defaults to api_key")
+	assert.Equal(t, "https://api.anthropic.com/v1/messages", eff.Providers["anthropic"].Endpoint)
+	assert.Equal(t, 5.0, eff.CostControl.SessionCap)
+	assert.Equal(t, map[string]string{"telemetry": "logs/telemetry.jsonl"}, eff.Telemetry.Destinations)
+}
+
+func TestEffective_CostControlJSONKeys(t *testing.T) {
+	cfg := effectiveTestConfig()
+	cfg.CostControl.DailyEgressCap = 1 << 30
+	cfg.CostControl.Scopes = []costcontrol.BudgetScope{{Name: "per-key", Key: "api_key", Cap: 10, Window: "weekly"}}
+
+	data, err := json.Marshal(cfg.Effective())
+	require.NoError(t, err)
+
+	var out struct {
+		CostControl map[string]any `json:"cost_control"`
+	}
+	require.NoError(t, json.Unmarshal(data, &out))
+	for _, key := range []string{"enabled", "session_cap", "global_cap", "session_egress_bytes", "daily_egress_bytes", "scopes", "cost_headers"} {
+		assert.Contains(t, out.CostControl, key, "cost_control keys match the YAML names")
+	}
+	assert.NotContains(t, out.CostControl, "SessionCap")
+	assert.Equal(t, 5.0, out.CostControl["session_cap"])
+	assert.Equal(t, []any{map[string]any{"name": "per-key", "key": "api_key", "cap": 10.0, "window": "weekly"}}, out.CostControl["scopes"])
+}
+
+func TestEffective_Summary(t *testing.T) {
+	summary := strings.Join(effectiveTestConfig().Effective().Summary(), "\n")
+
+	assert.Contains(t, summary, "proxy :18081")
+	assert.Contains(t, summary, "tool_output:     compresr")
+	assert.Contains(t, summary, "tool_discovery:  disabled")
+	assert.Contains(t, summary, "session $5.00, global unlimited")
+	assert.Contains(t, summary, "anthropic (claude-haiku-4-5, api_key)")
+	assert.Contains(t, summary, "telemetry=logs/telemetry.jsonl")
+	assert.NotContains(t, summary, "secret")
+}
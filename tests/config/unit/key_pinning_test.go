@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestKeyPinning_DefaultsWhenEnabled(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + "key_pinning:\n  enabled: true\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.KeyPinningModeBlock, cfg.KeyPinning.Mode)
+	assert.Equal(t, config.DefaultKeyPinRules(), cfg.KeyPinning.Rules)
+}
+
+func TestKeyPinning_ExplicitRulesReplaceDefaults(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+key_pinning:
+  enabled: true
+  mode: alert
+  rules:
+    - host: "*.example.com"
+      prefixes: ["ex-"]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.KeyPinningModeAlert, cfg.KeyPinning.Mode)
+	require.Len(t, cfg.KeyPinning.Rules, 1)
+	assert.Equal(t, "*.example.com", cfg.KeyPinning.Rules[0].Host)
+}
+
+func TestKeyPinning_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "unknown mode",
+			yaml:    "key_pinning:\n  mode: warn\n",
+			wantErr: "key_pinning.mode",
+		},
+		{
+			name:    "missing host",
+			yaml:    "key_pinning:\n  rules:\n    - prefixes: [\"sk-\"]\n",
+			wantErr: "host is required",
+		},
+		{
+			name:    "missing prefixes",
+			yaml:    "key_pinning:\n  rules:\n    - host: api.openai.com\n",
+			wantErr: "at least one prefix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
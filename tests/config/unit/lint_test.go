@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// lintIssue returns the first issue at path, failing the test if none.
+func lintIssue(t *testing.T, issues []config.LintIssue, path string) config.LintIssue {
+	t.Helper()
+	for _, issue := range issues {
+		if issue.Path == path {
+			return issue
+		}
+	}
+	require.Failf(t, "no issue", "path %q in %v", path, issues)
+	return config.LintIssue{}
+}
+
+func TestLint_CleanConfig(t *testing.T) {
+	assert.Empty(t, config.Lint([]byte(sessionGCBaseYAML)))
+}
+
+func TestLint_ShippedConfigs(t *testing.T) {
+	paths, err := filepath.Glob("../../../cmd/configs/*.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		for _, issue := range config.Lint(data) {
+			assert.NotEqual(t, config.LintError, issue.Severity, "%s: %s", path, issue)
+		}
+	}
+}
+
+func TestLint_StrategyTypo(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+pipes:
+  tool_discovery:
+    enabled: true
+    strategy: relevence
+`))
+	issue := lintIssue(t, issues, "pipes.tool_discovery.strategy")
+	assert.Equal(t, config.LintError, issue.Severity)
+	assert.Contains(t, issue.Message, `did you mean "relevance"?`)
+}
+
+func TestLint_UnknownKey(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+pipes:
+  tool_output:
+    colour: red
+`))
+	issue := lintIssue(t, issues, "line 12")
+	assert.Equal(t, config.LintWarning, issue.Severity)
+	assert.Contains(t, issue.Message, `unknown key "colour"`)
+}
+
+func TestLint_UnsetEnvVar(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+providers:
+  main:
+    api_key: ${LINT_TEST_UNSET_KEY}
+    model: claude-sonnet-4-5
+  backup:
+    api_key: ${LINT_TEST_UNSET_KEY:-none}
+    model: claude-sonnet-4-5
+`))
+	issue := lintIssue(t, issues, "line 12")
+	assert.Contains(t, issue.Message, "LINT_TEST_UNSET_KEY is not set")
+	for _, issue := range issues {
+		assert.NotEqual(t, "line 15", issue.Path, "placeholders with a default are fine")
+	}
+}
+
+func TestLint_UnknownModel(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+providers:
+  main:
+    api_key: sk-test
+    model: claude-sonet-4-5
+`))
+	issue := lintIssue(t, issues, "providers.main.model")
+	assert.Equal(t, config.LintWarning, issue.Severity)
+	assert.Contains(t, issue.Message, `did you mean "claude-sonnet-4-5"?`)
+}
+
+func TestLint_ThresholdRanges(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+preemptive:
+  trigger_threshold: 150
+pipes:
+  tool_output:
+    refusal_threshold: 1.5
+    min_tokens: 5000
+    max_tokens: 1000
+`))
+	for _, path := range []string{"preemptive.trigger_threshold", "pipes.tool_output.refusal_threshold", "pipes.tool_output"} {
+		assert.Equal(t, config.LintError, lintIssue(t, issues, path).Severity, path)
+	}
+}
+
+func TestLint_BudgetSettings(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+cost_control:
+  session_cap: 5
+`))
+	assert.Contains(t, lintIssue(t, issues, "cost_control").Message, "enabled is false")
+
+	issues = config.Lint([]byte(sessionGCBaseYAML + `
+cost_control:
+  enabled: true
+  session_cap: 50
+  global_cap: 10
+`))
+	assert.Contains(t, lintIssue(t, issues, "cost_control.session_cap").Message, "exceeds global_cap")
+}
+
+func TestLint_ReportsValidateError(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+readiness:
+  upstreams: ["ftp://example.com"]
+`))
+	require.NotEmpty(t, issues)
+	assert.Equal(t, config.LintError, issues[len(issues)-1].Severity)
+}
+
+func TestLint_InvalidYAML(t *testing.T) {
+	issues := config.Lint([]byte("server: [unclosed"))
+	require.Len(t, issues, 1)
+	assert.Equal(t, config.LintError, issues[0].Severity)
+}
@@ -0,0 +1,30 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestLiteLLM_BaseURL(t *testing.T) {
+	t.Setenv("LITELLM_PROVIDER_URL", "")
+	assert.Equal(t, "http://localhost:4000", config.LiteLLMConfig{}.BaseURL())
+
+	t.Setenv("LITELLM_PROVIDER_URL", "http://litellm.internal:4000/")
+	assert.Equal(t, "http://litellm.internal:4000", config.LiteLLMConfig{}.BaseURL())
+	assert.Equal(t, "https://llm.example.com", config.LiteLLMConfig{URL: "https://llm.example.com/"}.BaseURL(), "the configured URL wins")
+}
+
+func TestLiteLLM_Validation(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + "litellm:\n  enabled: true\n  url: http://litellm:4000\n"))
+	require.NoError(t, err)
+	assert.True(t, cfg.LiteLLM.Enabled)
+
+	for _, url := range []string{"litellm:4000", "ftp://litellm", "http://"} {
+		_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + "litellm:\n  url: " + url + "\n"))
+		assert.ErrorContains(t, err, "invalid litellm.url", url)
+	}
+}
@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestModelRewrite_DefaultStripsProviderPrefixes(t *testing.T) {
+	var rewrite config.ModelRewriteConfig
+
+	for model, want := range map[string]string{
+		"anthropic/claude-3-5-haiku-20241022": "claude-3-5-haiku-20241022",
+		"openai/gpt-4o":                       "gpt-4o",
+		"google/gemini-2.5-pro":               "gemini-2.5-pro",
+		"meta/llama-3-70b":                    "llama-3-70b",
+	} {
+		got, ok := rewrite.Rewrite(model)
+		assert.True(t, ok, model)
+		assert.Equal(t, want, got)
+	}
+	for _, model := range []string{"claude-sonnet-4-5", "openrouter/anthropic/claude-3.5-sonnet", ""} {
+		_, ok := rewrite.Rewrite(model)
+		assert.False(t, ok, model)
+	}
+}
+
+func TestModelRewrite_RulesFromYAML(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+model_rewrite:
+  rules:
+    - match: "openrouter/anthropic/claude-3.5-sonnet"
+      to: "claude-3-5-sonnet-latest"
+    - regex: "^openrouter/(?:anthropic|openai)/(.+)$"
+      to: "$1"
+`))
+	require.NoError(t, err)
+	rewrite := cfg.ModelRewrite
+
+	got, ok := rewrite.Rewrite("openrouter/anthropic/claude-3.5-sonnet")
+	assert.True(t, ok)
+	assert.Equal(t, "claude-3-5-sonnet-latest", got, "first matching rule wins")
+	got, ok = rewrite.Rewrite("openrouter/openai/gpt-4o")
+	assert.True(t, ok)
+	assert.Equal(t, "gpt-4o", got)
+
+	_, ok = rewrite.Rewrite("anthropic/claude-sonnet-4-5")
+	assert.False(t, ok, "configured rules replace the default prefix stripping")
+	_, ok = rewrite.Rewrite("xopenrouter/openai/gpt-4o")
+	assert.False(t, ok, "a regex must match the whole name")
+}
+
+func TestModelRewrite_EmptyRulesDisable(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+model_rewrite:
+  rules: []
+`))
+	require.NoError(t, err)
+	_, ok := cfg.ModelRewrite.Rewrite("anthropic/claude-sonnet-4-5")
+	assert.False(t, ok)
+}
+
+func TestModelRewrite_Validate(t *testing.T) {
+	for name, rule := range map[string]config.ModelRewriteRule{
+		"no matcher":    {To: "gpt-4o"},
+		"both matchers": {Match: "a", Regex: "^a$", To: "gpt-4o"},
+		"no target":     {Match: "openai/gpt-4o"},
+		"bad regex":     {Regex: "^(open", To: "gpt-4o"},
+	} {
+		rewrite := config.ModelRewriteConfig{Rules: []config.ModelRewriteRule{rule}}
+		assert.ErrorContains(t, rewrite.Validate(), "model_rewrite.rules[0]", name)
+	}
+
+	_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+model_rewrite:
+  rules:
+    - regex: "^(open"
+      to: "gpt-4o"
+`))
+	assert.ErrorContains(t, err, "model_rewrite.rules[0]")
+}
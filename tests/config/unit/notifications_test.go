@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+const notificationsYAML = `
+notifications:
+  slack:
+    enabled: false
+  locale: fr
+  budget_warning_at: 0.9
+  templates:
+    budget_warning:
+      pt: "{{.Data.cap}} {{percent .Data.utilization}}"
+  webhooks:
+    - name: ops
+      url: "https://hooks.example.com/secret-token"
+      headers: { Authorization: "Bearer hunter2" }
+    - name: pd
+      url: "https://events.example.com/enqueue"
+      format: template
+      events: [provider_outage]
+      body_template: '{"summary": {{json .Message}}}'
+`
+
+func TestNotifications_ParsesInlineWithSlack(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + notificationsYAML))
+	require.NoError(t, err)
+
+	n := cfg.Notifications
+	assert.False(t, n.Slack.Enabled)
+	assert.Equal(t, "fr", n.Locale)
+	assert.Equal(t, 0.9, n.BudgetWarningAt)
+	require.Len(t, n.Webhooks, 2)
+	assert.Equal(t, "Bearer hunter2", n.Webhooks[0].Headers["Authorization"])
+	assert.Equal(t, []string{"provider_outage"}, n.Webhooks[1].Events)
+}
+
+func TestNotifications_InvalidRejected(t *testing.T) {
+	_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+notifications:
+  webhooks:
+    - name: pd
+      url: "https://events.example.com"
+      format: template
+      body_template: "{{.Message"
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "notifications.webhooks[pd].body_template")
+}
+
+func TestNotifications_EffectiveHidesURLsAndHeaders(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + notificationsYAML))
+	require.NoError(t, err)
+	eff := cfg.Effective()
+
+	data, err := json.Marshal(eff)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+	assert.NotContains(t, string(data), "hunter2")
+
+	require.Len(t, eff.Notifications.Webhooks, 2)
+	assert.Equal(t, config.EffectiveNotifyWebhook{Name: "ops", Format: "generic"}, eff.Notifications.Webhooks[0])
+	assert.Contains(t, strings.Join(eff.Summary(), "\n"), "notifications:   2 webhook(s), locale fr")
+}
@@ -0,0 +1,140 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func TestPipelineConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     pipes.PipelineConfig
+		wantErr string
+	}{
+		{name: "empty is default layout", cfg: pipes.PipelineConfig{}},
+		{
+			name: "full order with budgets",
+			cfg: pipes.PipelineConfig{
+				Order:   []string{"task_output", "tool_discovery", "tool_output"},
+				Budgets: map[string]time.Duration{"tool_output": 500 * time.Millisecond},
+			},
+		},
+		{name: "unknown pipe", cfg: pipes.PipelineConfig{Order: []string{"guard"}}, wantErr: `unknown pipe "guard"`},
+		{name: "duplicate pipe", cfg: pipes.PipelineConfig{Order: []string{"tool_output", "tool_output"}}, wantErr: "listed more than once"},
+		{
+			name:    "unknown budget key",
+			cfg:     pipes.PipelineConfig{Budgets: map[string]time.Duration{"guard": time.Second}},
+			wantErr: `unknown pipe "guard" in budgets`,
+		},
+		{
+			name: "valid rules",
+			cfg: pipes.PipelineConfig{Rules: []pipes.RouteRule{
+				{Name: "bypass-ci", SessionTag: "ci"},
+				{Name: "haiku", Model: "claude-haiku-*", MaxBytes: 1 << 20, Chain: []string{"tool_output"}},
+			}},
+		},
+		{name: "rule without name", cfg: pipes.PipelineConfig{Rules: []pipes.RouteRule{{Model: "gpt-*"}}}, wantErr: "rule name is required"},
+		{
+			name:    "duplicate rule name",
+			cfg:     pipes.PipelineConfig{Rules: []pipes.RouteRule{{Name: "a"}, {Name: "a"}}},
+			wantErr: `duplicate rule name "a"`,
+		},
+		{name: "bad glob", cfg: pipes.PipelineConfig{Rules: []pipes.RouteRule{{Name: "a", Model: "claude-["}}}, wantErr: "invalid glob"},
+		{
+			name:    "min above max",
+			cfg:     pipes.PipelineConfig{Rules: []pipes.RouteRule{{Name: "a", MinBytes: 10, MaxBytes: 5}}},
+			wantErr: "exceeds max_bytes",
+		},
+		{
+			name:    "unknown pipe in rule chain",
+			cfg:     pipes.PipelineConfig{Rules: []pipes.RouteRule{{Name: "a", Chain: []string{"guard"}}}},
+			wantErr: `rule "a": unknown pipe "guard"`,
+		},
+		{
+			name:    "non-positive budget",
+			cfg:     pipes.PipelineConfig{Budgets: map[string]time.Duration{"tool_output": 0}},
+			wantErr: "must be > 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSLOConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pipe    string
+		cfg     pipes.SLOConfig
+		wantErr string
+	}{
+		{name: "deadline only", pipe: "tool_output", cfg: pipes.SLOConfig{Deadline: 800 * time.Millisecond}},
+		{name: "degrade to local", pipe: "tool_output", cfg: pipes.SLOConfig{P95: time.Second, DegradeTo: "simple"}},
+		{name: "discovery degrades to relevance", pipe: "tool_discovery", cfg: pipes.SLOConfig{P95: time.Second, DegradeTo: "relevance"}},
+		{name: "unknown pipe", pipe: "guard", cfg: pipes.SLOConfig{Deadline: time.Second}, wantErr: `unknown pipe "guard"`},
+		{name: "negative deadline", pipe: "tool_output", cfg: pipes.SLOConfig{Deadline: -time.Second}, wantErr: "must be >= 0"},
+		{name: "degrade without p95", pipe: "tool_output", cfg: pipes.SLOConfig{DegradeTo: "simple"}, wantErr: "degrade_to requires p95"},
+		{
+			name:    "external degrade target",
+			pipe:    "tool_output",
+			cfg:     pipes.SLOConfig{P95: time.Second, DegradeTo: "compresr"},
+			wantErr: "not a local strategy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate(tt.pipe)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPIIConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     pipes.PIIConfig
+		wantErr string
+	}{
+		{name: "disabled ignores detectors", cfg: pipes.PIIConfig{Detectors: []string{"passport"}}},
+		{name: "defaults", cfg: pipes.PIIConfig{Enabled: true}},
+		{name: "subset with api", cfg: pipes.PIIConfig{Enabled: true, Detectors: []string{"email", "iban"}, API: pipes.PIIAPIConfig{URL: "https://pii.internal/detect"}}},
+		{name: "unknown detector", cfg: pipes.PIIConfig{Enabled: true, Detectors: []string{"passport"}}, wantErr: `unknown detector "passport"`},
+		{name: "non-http api url", cfg: pipes.PIIConfig{Enabled: true, API: pipes.PIIAPIConfig{URL: "grpc://pii:9000"}}, wantErr: "api.url"},
+		{name: "negative timeout", cfg: pipes.PIIConfig{Enabled: true, API: pipes.PIIAPIConfig{Timeout: -time.Second}}, wantErr: "api.timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEffective_ReportsPipeOrder(t *testing.T) {
+	cfg := effectiveTestConfig()
+	assert.NotContains(t, cfg.Effective().Summary(), "pipe_order")
+
+	cfg.Pipes.Pipeline.Order = []string{"tool_discovery", "tool_output"}
+	eff := cfg.Effective()
+	assert.Equal(t, []string{"tool_discovery", "tool_output"}, eff.Pipes.Order)
+	assert.Contains(t, eff.Summary(), "pipe_order:      tool_discovery → tool_output")
+}
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func TestPromptTemplateConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    pipes.PromptTemplateConfig
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "system only", tmpl: pipes.PromptTemplateConfig{System: "Compress {{.ToolName}} output. Keep stack traces verbatim."}},
+		{
+			name: "user with all variables",
+			tmpl: pipes.PromptTemplateConfig{User: "{{.ToolName}} {{.Query}} {{percent .TargetRatio}} {{.Format}}\n{{.Content}}"},
+		},
+		{name: "syntax error", tmpl: pipes.PromptTemplateConfig{System: "{{.ToolName"}, wantErr: "prompt_template.system"},
+		{name: "unknown variable", tmpl: pipes.PromptTemplateConfig{User: "{{.Tool}} {{.Content}}"}, wantErr: "prompt_template.user"},
+		{name: "user drops content", tmpl: pipes.PromptTemplateConfig{User: "Compress {{.ToolName}}"}, wantErr: "must include {{.Content}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := pipes.ToolOutputConfig{
+				Enabled:        true,
+				Strategy:       pipes.StrategyExternalProvider,
+				Compresr:       pipes.CompresrConfig{Endpoint: "https://api.openai.com/v1/chat/completions"},
+				PromptTemplate: tt.tmpl,
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestPromptTemplates_Render(t *testing.T) {
+	compiled, err := pipes.PromptTemplateConfig{
+		User: "[{{.ToolName}}] remove {{percent .TargetRatio}}{{if .Query}} for: {{.Query}}{{end}}\n{{.Content}}",
+	}.Compile()
+	require.NoError(t, err)
+
+	system, user, err := compiled.Render(pipes.PromptVars{
+		ToolName: "bash", Query: "why did it fail", TargetRatio: 0.7, Content: "panic: boom",
+	}, "built-in system", "built-in user")
+	require.NoError(t, err)
+	assert.Equal(t, "built-in system", system, "unset system template keeps the built-in prompt")
+	assert.Equal(t, "[bash] remove 70% for: why did it fail\npanic: boom", user)
+
+	var none *pipes.PromptTemplates
+	system, user, err = none.Render(pipes.PromptVars{}, "s", "u")
+	require.NoError(t, err)
+	assert.Equal(t, "s", system)
+	assert.Equal(t, "u", user)
+}
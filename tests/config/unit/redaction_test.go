@@ -0,0 +1,27 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestRedaction_Validation(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `monitoring:
+  redaction:
+    enabled: true
+    detectors: [email, bearer_token]
+    patterns:
+      - { name: employee_id, regex: "EMP-\\d{6}" }
+`))
+	require.NoError(t, err)
+	assert.True(t, cfg.Monitoring.Redaction.Enabled)
+	assert.Equal(t, []string{"email", "bearer_token"}, cfg.Monitoring.Redaction.Detectors)
+	assert.True(t, cfg.Effective().Telemetry.Redaction)
+
+	_, err = config.LoadFromBytes([]byte(sessionGCBaseYAML + "monitoring:\n  redaction:\n    enabled: true\n    detectors: [ssn]\n"))
+	assert.ErrorContains(t, err, `monitoring.redaction: unknown detector "ssn"`)
+}
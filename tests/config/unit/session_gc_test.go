@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+const sessionGCBaseYAML = `
+server:
+  port: 18081
+  read_timeout: 30s
+  write_timeout: 60s
+store:
+  type: memory
+  ttl: 1h
+`
+
+func TestSessionGC_Defaults(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.DefaultCleanupInterval, cfg.SessionGC.Interval)
+	assert.Equal(t, config.DefaultSessionIdleTTLs(), cfg.SessionGC.IdleTTL)
+}
+
+func TestSessionGC_OverridesMergeWithDefaults(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+session_gc:
+  interval: 30s
+  idle_ttl:
+    tool_sessions: 10m
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 30*time.Second, cfg.SessionGC.Interval)
+	assert.Equal(t, 10*time.Minute, cfg.SessionGC.IdleTTL[config.SessionStoreToolSessions])
+	assert.Equal(t, 24*time.Hour, cfg.SessionGC.IdleTTL[config.SessionStoreCostSessions], "unset stores keep defaults")
+}
+
+func TestSessionGC_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "unknown store",
+			yaml:    "session_gc:\n  idle_ttl:\n    tool_session: 10m\n",
+			wantErr: `unknown store`,
+		},
+		{
+			name:    "non-positive ttl",
+			yaml:    "session_gc:\n  idle_ttl:\n    branches: 0s\n",
+			wantErr: "ttl must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSessionPersistence_Config(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML))
+	require.NoError(t, err)
+	assert.False(t, cfg.SessionPersistence.Enabled)
+	assert.Equal(t, config.DefaultSessionPersistenceInterval, cfg.SessionPersistence.Interval)
+
+	cfg, err = config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+session_persistence:
+  enabled: true
+  path: /tmp/sessions.db
+  interval: 5s
+`))
+	require.NoError(t, err)
+	assert.True(t, cfg.SessionPersistence.Enabled)
+	assert.Equal(t, "/tmp/sessions.db", cfg.SessionPersistence.Path)
+	assert.Equal(t, 5*time.Second, cfg.SessionPersistence.Interval)
+}
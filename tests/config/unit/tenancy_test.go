@@ -0,0 +1,155 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+const tenancyYAML = sessionGCBaseYAML + `
+pipes:
+  tool_output:
+    enabled: true
+    strategy: simple
+    min_tokens: 512
+    max_tokens: 40000
+cost_control:
+  enabled: true
+tenancy:
+  enabled: true
+  budget_window: weekly
+  tenants:
+    - name: research
+      key_prefixes: ["gw-research-"]
+      budget: 200
+      pipes:
+        tool_output:
+          min_tokens: 2000
+    - name: support
+      budget: 50
+    - name: ops
+`
+
+func TestTenancy_ForTenantOverlaysPipes(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(tenancyYAML))
+	require.NoError(t, err)
+
+	research, err := cfg.ForTenant(&cfg.Tenancy.Tenants[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2000, research.Pipes.ToolOutput.MinTokens)
+	assert.Equal(t, 40000, research.Pipes.ToolOutput.MaxTokens, "fields left out keep top-level values")
+	assert.Equal(t, config.StrategySimple, research.Pipes.ToolOutput.Strategy)
+	assert.Equal(t, 512, cfg.Pipes.ToolOutput.MinTokens, "top-level pipes are untouched")
+
+	support, err := cfg.ForTenant(&cfg.Tenancy.Tenants[1])
+	require.NoError(t, err)
+	assert.Same(t, cfg, support, "no override: same config")
+}
+
+func TestTenancy_BudgetsBecomeScope(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(tenancyYAML))
+	require.NoError(t, err)
+
+	var scope *costcontrol.BudgetScope
+	for i := range cfg.CostControl.Scopes {
+		if cfg.CostControl.Scopes[i].Name == config.TenantScopeName {
+			scope = &cfg.CostControl.Scopes[i]
+		}
+	}
+	require.NotNil(t, scope)
+	assert.Equal(t, costcontrol.ScopeKeyTenant, scope.Key)
+	assert.Equal(t, costcontrol.WindowWeekly, scope.Window)
+	assert.Equal(t, map[string]float64{"research": 200, "support": 50}, scope.Caps)
+}
+
+func TestTenancy_ExplicitScopeCapsWin(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+cost_control:
+  enabled: true
+  scopes:
+    - name: teams
+      key: tenant
+      cap: 25
+      caps: { support: 80 }
+tenancy:
+  enabled: true
+  tenants:
+    - name: support
+      budget: 50
+    - name: ops
+      budget: 10
+`))
+	require.NoError(t, err)
+	require.Len(t, cfg.CostControl.Scopes, 1, "the declared tenant scope is reused")
+	scope := cfg.CostControl.Scopes[0]
+	assert.Equal(t, 25.0, scope.Cap)
+	assert.Equal(t, map[string]float64{"support": 80, "ops": 10}, scope.Caps)
+}
+
+func TestTenancy_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"budget without cost control", `
+tenancy:
+  enabled: true
+  tenants:
+    - name: research
+      budget: 10
+`, "needs cost_control.enabled"},
+		{"unknown pipe field", `
+tenancy:
+  enabled: true
+  tenants:
+    - name: research
+      pipes:
+        tool_output:
+          min_tokenz: 10
+`, "tenancy.tenants[0].pipes"},
+		{"bad budget window", `
+tenancy:
+  enabled: true
+  budget_window: monthly
+  tenants:
+    - name: research
+`, "budget_window"},
+		{"invalid tenant", `
+tenancy:
+  enabled: true
+  tenants:
+    - name: ""
+`, "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLint_TenantProviderKeysWithoutPrefixes(t *testing.T) {
+	issues := config.Lint([]byte(sessionGCBaseYAML + `
+tenancy:
+  enabled: true
+  tenants:
+    - name: research
+      provider_keys: { anthropic: sk-ant-research }
+    - name: support
+      key_prefixes: ["gw-support-"]
+      provider_keys: { anthropic: sk-ant-support }
+`))
+	issue := lintIssue(t, issues, "tenancy.tenants[0]")
+	assert.Equal(t, config.LintWarning, issue.Severity)
+	assert.Contains(t, issue.Message, "no key_prefixes")
+	for _, issue := range issues {
+		assert.NotEqual(t, "tenancy.tenants[1]", issue.Path)
+	}
+}
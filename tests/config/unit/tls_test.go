@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestTLS_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		tls  config.TLSConfig
+		want string
+	}{
+		{"cert without key", config.TLSConfig{CertFile: "server.pem"}, "set together"},
+		{"files and acme", config.TLSConfig{CertFile: "server.pem", KeyFile: "key.pem", ACME: config.ACMEConfig{Domains: []string{"gw.example.com"}}}, "not both"},
+		{"wildcard domain", config.TLSConfig{ACME: config.ACMEConfig{Domains: []string{"*.example.com"}}}, "must be a hostname"},
+		{"ip domain", config.TLSConfig{ACME: config.ACMEConfig{Domains: []string{"10.0.0.1"}}}, "must be a hostname"},
+		{"plain directory", config.TLSConfig{ACME: config.ACMEConfig{Domains: []string{"gw.example.com"}, DirectoryURL: "http://acme.local/dir"}}, "https URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tls.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	assert.NoError(t, config.TLSConfig{}.Validate())
+	assert.NoError(t, config.TLSConfig{ACME: config.ACMEConfig{Domains: []string{"gw.example.com"}}}.Validate())
+}
+
+func TestTLS_EffectiveConfig(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Effective().Listeners.TLS)
+
+	cfg.Server.TLS = config.TLSConfig{ACME: config.ACMEConfig{Domains: []string{"gw.example.com"}}}
+	assert.Equal(t, "acme", cfg.Effective().Listeners.TLS)
+	cfg.Server.TLS = config.TLSConfig{CertFile: "server.pem", KeyFile: "key.pem"}
+	assert.Equal(t, "cert_file", cfg.Effective().Listeners.TLS)
+}
+
+func TestLint_ACMEOffPort443(t *testing.T) {
+	issues := config.Lint([]byte(`
+server:
+  port: 8443
+  read_timeout: 30s
+  write_timeout: 1000s
+  tls:
+    acme:
+      domains: [gw.example.com]
+`))
+	issue := lintIssue(t, issues, "server.tls.acme")
+	assert.Equal(t, config.LintWarning, issue.Severity)
+	assert.Contains(t, issue.Message, "port 443")
+}
@@ -0,0 +1,47 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func TestUpstreamRetry_ModelFallbacksFromYAML(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte(sessionGCBaseYAML + `
+upstream_retry:
+  enabled: true
+  model_fallbacks:
+    - model: "claude-sonnet-*"
+      fallback: "claude-haiku-4-5"
+    - model: "*"
+      fallback: "claude-sonnet-4-5"
+`))
+	require.NoError(t, err)
+	retry := cfg.UpstreamRetry
+
+	assert.Equal(t, []int{config.DefaultModelFallbackStatus}, retry.FallbackOn)
+	assert.True(t, retry.FallsBackOn(529))
+	assert.False(t, retry.FallsBackOn(503))
+
+	fallback, ok := retry.FallbackModel("claude-sonnet-4-5")
+	assert.True(t, ok)
+	assert.Equal(t, "claude-haiku-4-5", fallback, "first matching rule wins")
+	fallback, ok = retry.FallbackModel("claude-opus-4-1")
+	assert.True(t, ok)
+	assert.Equal(t, "claude-sonnet-4-5", fallback)
+	_, ok = retry.FallbackModel("")
+	assert.False(t, ok)
+}
+
+func TestUpstreamRetry_ValidateModelFallbacks(t *testing.T) {
+	for name, retry := range map[string]config.UpstreamRetryConfig{
+		"missing fallback": {ModelFallbacks: []config.ModelFallbackRule{{Model: "claude-*"}}},
+		"bad pattern":      {ModelFallbacks: []config.ModelFallbackRule{{Model: "claude-[", Fallback: "x"}}},
+		"bad status":       {FallbackOn: []int{200}},
+	} {
+		assert.Error(t, retry.Validate(), name)
+	}
+}
@@ -0,0 +1,23 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/adapters/conformance"
+)
+
+// TestAdapterConformance runs the conformance suite for every registered
+// adapter. A new adapter fails here until it has fixtures.
+func TestAdapterConformance(t *testing.T) {
+	registry := adapters.NewRegistry()
+	for _, name := range registry.List() {
+		t.Run(name, func(t *testing.T) {
+			cases := conformance.Fixtures(name)
+			require.NotEmpty(t, cases, "adapter %q has no conformance fixtures", name)
+			conformance.Run(t, registry.Get(name), cases)
+		})
+	}
+}
@@ -167,3 +167,29 @@ func TestCalculateCostWithCache_OnlyCacheWrite(t *testing.T) {
 	expected := inputCost + outputCost + cacheWriteCost
 	assert.InDelta(t, expected, cost, 0.0000001)
 }
+
+func TestGetModelPricing_RealtimeSnapshot(t *testing.T) {
+	// Dated Realtime snapshots match their realtime family, not gpt-4o
+	p := costcontrol.GetModelPricing("gpt-4o-realtime-preview-2024-12-17")
+	assert.Equal(t, 5.0, p.InputPerMTok)
+	assert.Equal(t, 20.0, p.OutputPerMTok)
+
+	p2 := costcontrol.GetModelPricing("gpt-realtime-2025-08-28")
+	assert.Equal(t, 4.0, p2.InputPerMTok)
+	assert.Equal(t, 16.0, p2.OutputPerMTok)
+}
+
+func TestGetModelPricing_ProviderPrefixedModels(t *testing.T) {
+	sonnet := costcontrol.GetModelPricing("claude-sonnet-4-5")
+	for _, model := range []string{
+		"anthropic/claude-sonnet-4-5",
+		"bedrock/anthropic.claude-sonnet-4-5-20250929-v1:0",
+		"bedrock/us.anthropic.claude-sonnet-4-5-20250929-v1:0",
+		"anthropic.claude-sonnet-4-5-20250929-v1:0",
+		"openrouter/anthropic/claude-sonnet-4-5",
+	} {
+		assert.Equal(t, sonnet, costcontrol.GetModelPricing(model), model)
+	}
+	assert.Equal(t, costcontrol.GetModelPricing("gemini-2.5-pro"), costcontrol.GetModelPricing("vertex_ai/gemini-2.5-pro"))
+	assert.Equal(t, costcontrol.GetModelPricing("gpt-4o"), costcontrol.GetModelPricing("azure/gpt-4o"))
+}
@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+func scopedConfig() costcontrol.CostControlConfig {
+	return costcontrol.CostControlConfig{
+		Enabled: true,
+		Scopes: []costcontrol.BudgetScope{
+			{Name: "team", Key: "header:X-Team", Cap: 0.01, Caps: map[string]float64{"platform": 1000}},
+			{Name: "project", Key: "tag:project", Window: costcontrol.WindowWeekly},
+			{Name: "key", Key: costcontrol.ScopeKeyAPIKey, Window: costcontrol.WindowTotal},
+		},
+	}
+}
+
+func TestScopeKeys_FromHeadersAndTags(t *testing.T) {
+	tracker := costcontrol.NewTracker(scopedConfig())
+	h := http.Header{}
+	h.Set("X-Team", " search ")
+	h.Set("Authorization", "Bearer sk-test-123")
+
+	keys := tracker.ScopeKeys(h, []string{"ci", "project=billing"}, "", "")
+	assert.Equal(t, []costcontrol.ScopeKey{
+		{Scope: "team", Value: "search"},
+		{Scope: "project", Value: "billing"},
+		{Scope: "key", Value: costcontrol.APIKeyID("sk-test-123")},
+	}, keys)
+
+	assert.Empty(t, tracker.ScopeKeys(http.Header{}, []string{"ci"}, "", ""), "requests without a value are not scoped")
+	assert.Equal(t, []costcontrol.ScopeKey{{Scope: "project", Value: "web"}}, tracker.ScopeKeys(http.Header{}, []string{"Project:web"}, "", ""))
+}
+
+func TestScopeKeys_Tenant(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled: true,
+		Scopes:  []costcontrol.BudgetScope{{Name: "tenant", Key: costcontrol.ScopeKeyTenant, Caps: map[string]float64{"search": 5}}},
+	})
+
+	assert.Equal(t, []costcontrol.ScopeKey{{Scope: "tenant", Value: "search"}}, tracker.ScopeKeys(http.Header{}, nil, "search", ""))
+	assert.Empty(t, tracker.ScopeKeys(http.Header{}, nil, "", ""), "requests without a tenant are not scoped")
+}
+
+func TestScopeKeys_Client(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled: true,
+		Scopes:  []costcontrol.BudgetScope{{Name: "client", Key: costcontrol.ScopeKeyClient, Cap: 5}},
+	})
+
+	assert.Equal(t, []costcontrol.ScopeKey{{Scope: "client", Value: "ci-runner"}}, tracker.ScopeKeys(http.Header{}, nil, "", "ci-runner"))
+	assert.Empty(t, tracker.ScopeKeys(http.Header{}, nil, "search", ""), "unauthenticated requests are not scoped")
+}
+
+func TestAPIKeyID_HidesKey(t *testing.T) {
+	id := costcontrol.APIKeyID("sk-ant-api03-secret")
+	assert.Regexp(t, `^key-[0-9a-f]{12}$`, id)
+	assert.NotEqual(t, id, costcontrol.APIKeyID("sk-ant-api03-other"))
+}
+
+func TestCheckBudget_ScopeCapIndependentPerValue(t *testing.T) {
+	tracker := costcontrol.NewTracker(scopedConfig())
+	search := costcontrol.ScopeKey{Scope: "team", Value: "search"}
+	platform := costcontrol.ScopeKey{Scope: "team", Value: "platform"}
+
+	tracker.RecordUsage("s1", "claude-opus-4-6", 1_000_000, 100_000, 0, 0, search)
+	tracker.RecordUsage("s2", "claude-opus-4-6", 1_000_000, 100_000, 0, 0, platform)
+
+	result := tracker.CheckBudget("s3", search)
+	assert.False(t, result.Allowed, "a new session of an over-cap team is still blocked")
+	assert.Equal(t, costcontrol.ReasonScopeCost, result.Reason)
+	require.NotNil(t, result.Scope)
+	assert.Equal(t, "search", result.Scope.Value)
+	assert.Equal(t, 0.01, result.Scope.Cap)
+	assert.GreaterOrEqual(t, result.Utilization(), 1.0)
+
+	result = tracker.CheckBudget("s4", platform)
+	assert.True(t, result.Allowed, "per-value cap override")
+	assert.Equal(t, 1000.0, result.Scopes[0].Cap)
+
+	assert.True(t, tracker.CheckBudget("s5").Allowed, "unscoped requests only see session and global caps")
+}
+
+func TestCheckBudget_ScopeSimulated(t *testing.T) {
+	cfg := scopedConfig()
+	cfg.Mode = costcontrol.ModeSimulate
+	tracker := costcontrol.NewTracker(cfg)
+	search := costcontrol.ScopeKey{Scope: "team", Value: "search"}
+	tracker.RecordUsage("s1", "claude-opus-4-6", 1_000_000, 100_000, 0, 0, search)
+
+	result := tracker.CheckBudget("s1", search)
+	assert.True(t, result.Allowed)
+	assert.True(t, result.Simulated)
+	tracker.RecordSimulatedRejection("s1", result)
+
+	report := tracker.SimulationReport(time.Time{})
+	assert.Equal(t, 1, report.ByReason[costcontrol.ReasonScopeCost])
+}
+
+func TestScopeSpend_Breakdown(t *testing.T) {
+	tracker := costcontrol.NewTracker(scopedConfig())
+	tracker.RecordUsage("s1", "claude-haiku-4-5", 1000, 100, 0, 0,
+		costcontrol.ScopeKey{Scope: "team", Value: "search"}, costcontrol.ScopeKey{Scope: "project", Value: "billing"})
+	tracker.RecordUsage("s2", "claude-opus-4-6", 1000, 100, 0, 0,
+		costcontrol.ScopeKey{Scope: "team", Value: "platform"})
+	tracker.RecordUsage("s2", "claude-opus-4-6", 1000, 100, 0, 0,
+		costcontrol.ScopeKey{Scope: "team", Value: "platform"})
+
+	spend := tracker.ScopeSpend()
+	require.Len(t, spend, 3)
+	assert.Equal(t, "team", spend[0].Scope)
+	assert.Equal(t, "platform", spend[0].Value, "highest spend first within a scope")
+	assert.Equal(t, 2, spend[0].Requests)
+	assert.Equal(t, "search", spend[1].Value)
+	assert.Equal(t, "project", spend[2].Scope)
+	assert.Equal(t, costcontrol.WindowWeekly, spend[2].Window)
+	require.NotNil(t, spend[2].ResetsAt)
+	assert.Equal(t, time.Monday, spend[2].ResetsAt.Weekday())
+}
+
+func TestScopePeriod(t *testing.T) {
+	sunday := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+
+	period, resets := costcontrol.ScopePeriod(costcontrol.WindowDaily, sunday)
+	assert.Equal(t, "2026-10-18", period)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), resets)
+
+	period, resets = costcontrol.ScopePeriod(costcontrol.WindowWeekly, sunday)
+	assert.Equal(t, "2026-W42", period)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), resets, "weeks end on Sunday night")
+
+	period, resets = costcontrol.ScopePeriod(costcontrol.WindowTotal, sunday)
+	assert.Equal(t, costcontrol.WindowTotal, period)
+	assert.True(t, resets.IsZero())
+}
+
+func TestScopes_ImportDropsPastWindows(t *testing.T) {
+	tracker := costcontrol.NewTracker(scopedConfig())
+	today, _ := costcontrol.ScopePeriod(costcontrol.WindowDaily, time.Now())
+	_, err := tracker.Import(&costcontrol.TrackerState{
+		Sessions: []byte("[]"),
+		Scopes: []costcontrol.ScopeUsage{
+			{Scope: "team", Value: "search", Period: today, Cost: 5, Requests: 3},
+			{Scope: "team", Value: "infra", Period: "2020-01-01", Cost: 9, Requests: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	spend := tracker.ScopeSpend()
+	require.Len(t, spend, 1)
+	assert.Equal(t, "search", spend[0].Value)
+	assert.Equal(t, 5.0, spend[0].Cost)
+	assert.False(t, tracker.CheckBudget("s1", costcontrol.ScopeKey{Scope: "team", Value: "search"}).Allowed)
+}
+
+func TestBudgetScope_Validate(t *testing.T) {
+	valid := scopedConfig()
+	assert.NoError(t, valid.Validate())
+
+	for name, scope := range map[string]costcontrol.BudgetScope{
+		"name is required": {Key: "api_key"},
+		"key must be":      {Name: "x", Key: "cookie:id"},
+		"cap must be":      {Name: "x", Key: "api_key", Cap: -1},
+		"caps[":            {Name: "x", Key: "api_key", Caps: map[string]float64{"a": -1}},
+		"window must be":   {Name: "x", Key: "api_key", Window: "monthly"},
+	} {
+		cfg := costcontrol.CostControlConfig{Scopes: []costcontrol.BudgetScope{scope}}
+		assert.ErrorContains(t, cfg.Validate(), name)
+	}
+
+	dup := costcontrol.CostControlConfig{Scopes: []costcontrol.BudgetScope{
+		{Name: "team", Key: "header:X-Team"}, {Name: "team", Key: "api_key"},
+	}}
+	assert.ErrorContains(t, dup.Validate(), "used twice")
+}
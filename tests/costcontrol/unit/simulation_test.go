@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+func TestTracker_SimulateModeNeverBlocks(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:    true,
+		Mode:       costcontrol.ModeSimulate,
+		SessionCap: 0.01,
+	})
+	tracker.RecordUsage("s1", "claude-opus-4-6", 1_000_000, 100_000, 0, 0)
+
+	result := tracker.CheckBudget("s1")
+	assert.True(t, result.Allowed)
+	assert.True(t, result.Simulated)
+	assert.Equal(t, costcontrol.ReasonSessionCost, result.Reason)
+
+	under := tracker.CheckBudget("s2")
+	assert.True(t, under.Allowed)
+	assert.False(t, under.Simulated)
+	assert.Empty(t, under.Reason)
+}
+
+func TestTracker_EnforceModeStillBlocks(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:    true,
+		Mode:       costcontrol.ModeEnforce,
+		SessionCap: 0.01,
+	})
+	tracker.RecordUsage("s1", "claude-opus-4-6", 1_000_000, 100_000, 0, 0)
+
+	result := tracker.CheckBudget("s1")
+	assert.False(t, result.Allowed)
+	assert.False(t, result.Simulated)
+}
+
+func TestTracker_SimulationReport(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:    true,
+		Mode:       costcontrol.ModeSimulate,
+		SessionCap: 0.01,
+	})
+	tracker.RecordUsage("s1", "claude-opus-4-6", 1_000_000, 100_000, 0, 0)
+	tracker.RecordUsage("s2", "claude-opus-4-6", 1_000_000, 100_000, 0, 0)
+
+	for i := 0; i < 3; i++ {
+		tracker.RecordSimulatedRejection("s1", tracker.CheckBudget("s1"))
+	}
+	tracker.RecordSimulatedRejection("s2", tracker.CheckBudget("s2"))
+	tracker.RecordSimulatedRejection("s3", tracker.CheckBudget("s3")) // Within caps: ignored
+
+	report := tracker.SimulationReport(time.Time{})
+	assert.Equal(t, 4, report.WouldReject)
+	assert.Equal(t, 2, report.SessionsBlocked)
+	assert.Equal(t, map[string]int{costcontrol.ReasonSessionCost: 4}, report.ByReason)
+	require.NotNil(t, report.FirstRejection)
+	assert.False(t, report.Truncated)
+
+	require.Len(t, report.Sessions, 2)
+	assert.Equal(t, "s1", report.Sessions[0].SessionID)
+	assert.Equal(t, 3, report.Sessions[0].WouldReject)
+	assert.Equal(t, []string{costcontrol.ReasonSessionCost}, report.Sessions[0].Reasons)
+	assert.Greater(t, report.Sessions[0].MaxCost, 0.01)
+
+	future := tracker.SimulationReport(time.Now().Add(time.Minute))
+	assert.Zero(t, future.WouldReject)
+	assert.Empty(t, future.Sessions)
+}
+
+func TestCostControlConfig_ValidateMode(t *testing.T) {
+	for _, mode := range []string{"", costcontrol.ModeEnforce, costcontrol.ModeSimulate} {
+		cfg := costcontrol.CostControlConfig{Mode: mode}
+		assert.NoError(t, cfg.Validate(), mode)
+	}
+	cfg := costcontrol.CostControlConfig{Mode: "dry-run"}
+	assert.ErrorContains(t, cfg.Validate(), "cost_control.mode")
+}
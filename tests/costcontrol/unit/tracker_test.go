@@ -1,6 +1,7 @@
 package unit
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 
@@ -102,6 +103,24 @@ func TestTracker_AllSessions(t *testing.T) {
 	assert.True(t, ids["session2"])
 }
 
+func TestTracker_SessionTokenUsage(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{SessionCap: 5.0})
+
+	tracker.RecordUsage("session1", "claude-sonnet-4-5", 1000, 200, 0, 0)
+	tracker.RecordUsage("session1", "claude-sonnet-4-5", 100, 300, 50, 1500)
+
+	s, ok := tracker.Session("session1")
+	require.True(t, ok)
+	assert.Equal(t, 2, s.RequestCount)
+	assert.Equal(t, 2650, s.InputTokens, "input includes cache writes and reads")
+	assert.Equal(t, 500, s.OutputTokens)
+	assert.Equal(t, 1650, s.LastInputTokens)
+	assert.Equal(t, 5.0, s.Cap)
+
+	_, ok = tracker.Session("missing")
+	assert.False(t, ok)
+}
+
 func TestTracker_SessionCapEnforcedPerSession(t *testing.T) {
 	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
 		Enabled:    true,
@@ -310,3 +329,123 @@ func TestTracker_ConcurrentAccess(t *testing.T) {
 	require.Len(t, sessions, 1)
 	assert.Equal(t, 100, sessions[0].RequestCount)
 }
+
+// TestTracker_ConcurrentSessionsIsolated records usage for many sessions in
+// parallel while the config is hot-reloaded and checks per-session totals.
+// Run with -race.
+func TestTracker_ConcurrentSessionsIsolated(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{Enabled: true, SessionCap: 1000.0})
+	defer tracker.Close()
+
+	const sessions, perSession = 20, 25
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < perSession; n++ {
+				tracker.RecordUsage(id, "claude-sonnet-4-5", 100, 50, 0, 0)
+				tracker.CheckBudget(id)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 50; n++ {
+			tracker.UpdateConfig(costcontrol.CostControlConfig{Enabled: n%2 == 0, SessionCap: 1000.0})
+			tracker.AllSessions()
+			tracker.Config()
+		}
+	}()
+	wg.Wait()
+
+	snapshots := tracker.AllSessions()
+	require.Len(t, snapshots, sessions)
+	perRequest := tracker.GetSessionCost("session-0") / perSession
+	for _, s := range snapshots {
+		assert.Equal(t, perSession, s.RequestCount, "session %s", s.ID)
+		assert.InDelta(t, perRequest*perSession, s.Cost, 1e-9, "session %s", s.ID)
+	}
+}
+
+func TestTracker_SessionEgressCap(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:          true,
+		SessionEgressCap: 1000,
+	})
+
+	tracker.RecordEgress("session1", 600)
+	result := tracker.CheckBudget("session1")
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(600), result.SessionEgress)
+
+	// A phantom-loop follow-up is a second forward of the same request.
+	tracker.RecordEgress("session1", 400)
+	result = tracker.CheckBudget("session1")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, costcontrol.ReasonSessionEgress, result.Reason)
+	assert.Equal(t, int64(1000), result.SessionEgressCap)
+
+	// Other sessions are unaffected.
+	assert.True(t, tracker.CheckBudget("session2").Allowed)
+
+	snap, ok := tracker.Session("session1")
+	require.True(t, ok)
+	assert.Equal(t, int64(1000), snap.EgressBytes)
+	assert.Equal(t, int64(1000), snap.EgressCap)
+}
+
+func TestTracker_DailyEgressCap(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:        true,
+		DailyEgressCap: 1000,
+	})
+
+	tracker.RecordEgress("session1", 500)
+	tracker.RecordEgress("", 300) // Passthrough traffic counts toward the day only
+	assert.Equal(t, int64(800), tracker.GetDailyEgress())
+	assert.True(t, tracker.CheckBudget("session2").Allowed)
+
+	tracker.RecordEgress("session2", 200)
+	result := tracker.CheckBudget("session3")
+	assert.False(t, result.Allowed, "daily cap applies across sessions")
+	assert.Equal(t, costcontrol.ReasonDailyEgress, result.Reason)
+	assert.Equal(t, int64(1000), result.DailyEgress)
+}
+
+func TestTracker_EgressCapNotEnforcedWhenDisabled(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{
+		Enabled:          false,
+		SessionEgressCap: 10,
+		DailyEgressCap:   10,
+	})
+
+	tracker.RecordEgress("session1", 100)
+	result := tracker.CheckBudget("session1")
+	assert.True(t, result.Allowed)
+	assert.Empty(t, result.Reason)
+	assert.Equal(t, int64(100), result.SessionEgress)
+}
+
+func TestCostControlConfig_ValidateEgressCaps(t *testing.T) {
+	cfg := costcontrol.CostControlConfig{SessionEgressCap: -1}
+	assert.Error(t, cfg.Validate())
+
+	cfg = costcontrol.CostControlConfig{DailyEgressCap: -1}
+	assert.Error(t, cfg.Validate())
+
+	cfg = costcontrol.CostControlConfig{SessionEgressCap: 1 << 20, DailyEgressCap: 1 << 30}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestTracker_RecordCost(t *testing.T) {
+	tracker := costcontrol.NewTracker(costcontrol.CostControlConfig{Enabled: true, SessionCap: 0.01})
+
+	tracker.RecordCost("session1", "bedrock/anthropic.claude-sonnet-4-5-20250929-v1:0", 0.0123, 1000, 100, 0, 0)
+
+	assert.InDelta(t, 0.0123, tracker.GetSessionCost("session1"), 1e-9, "the given cost is recorded as is")
+	assert.InDelta(t, 0.0123, tracker.GetGlobalCost(), 1e-9)
+	assert.False(t, tracker.CheckBudget("session1").Allowed)
+}
@@ -0,0 +1,202 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/doctor"
+)
+
+const baseYAML = `
+server:
+  port: 18081
+  read_timeout: 30s
+  write_timeout: 60s
+store:
+  type: memory
+  ttl: 1h
+`
+
+func loadConfig(t *testing.T, yaml string) *config.Config {
+	t.Helper()
+	c, cfg := doctor.CheckConfig([]byte(yaml), "test.yaml")
+	require.NotNil(t, cfg, c.Detail)
+	return cfg
+}
+
+func TestCheckConfig(t *testing.T) {
+	c, cfg := doctor.CheckConfig([]byte(baseYAML), "test.yaml")
+	assert.Equal(t, doctor.StatusOK, c.Status)
+	assert.NotNil(t, cfg)
+
+	c, _ = doctor.CheckConfig([]byte(baseYAML+"unknown_section: true\n"), "test.yaml")
+	assert.Equal(t, doctor.StatusWarn, c.Status)
+	assert.Contains(t, c.Detail, "unknown_section")
+
+	c, cfg = doctor.CheckConfig([]byte("server: [\n"), "broken.yaml")
+	assert.Equal(t, doctor.StatusFail, c.Status)
+	assert.Nil(t, cfg)
+	assert.NotEmpty(t, c.Fix)
+}
+
+func TestCheckPort(t *testing.T) {
+	ctx := context.Background()
+	client := http.DefaultClient
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// Held by something that is not a gateway.
+	other := &http.Server{Handler: http.NotFoundHandler()} // #nosec G112 -- test server
+	go func() { _ = other.Serve(ln) }()
+	c := doctor.CheckPort(ctx, client, port)
+	assert.Equal(t, doctor.StatusFail, c.Status)
+	require.NoError(t, other.Close())
+
+	// Free again.
+	c = doctor.CheckPort(ctx, client, port)
+	assert.Equal(t, doctor.StatusOK, c.Status)
+	assert.Contains(t, c.Detail, "free")
+
+	// Held by a running gateway.
+	ln, err = net.Listen("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	gw := &http.Server{Handler: mux} // #nosec G112 -- test server
+	go func() { _ = gw.Serve(ln) }()
+	defer gw.Close()
+	c = doctor.CheckPort(ctx, client, port)
+	assert.Equal(t, doctor.StatusOK, c.Status)
+	assert.Contains(t, c.Detail, "running gateway")
+}
+
+func TestCheckProviderKeys(t *testing.T) {
+	t.Setenv("DOCTOR_TEST_KEY", "sk-ant-test")
+	cfg := loadConfig(t, baseYAML+`
+providers:
+  anthropic:
+    api_key: "${DOCTOR_TEST_KEY}"
+    model: "claude-haiku-4-5"
+  openai:
+    api_key: "${DOCTOR_TEST_UNSET:-}"
+    model: "gpt-4o-mini"
+`)
+	checks := doctor.CheckProviderKeys(cfg)
+	require.Len(t, checks, 2)
+	assert.Equal(t, "provider anthropic", checks[0].Name)
+	assert.Equal(t, doctor.StatusOK, checks[0].Status)
+	assert.Equal(t, "provider openai", checks[1].Name)
+	assert.Equal(t, doctor.StatusWarn, checks[1].Status)
+	assert.Contains(t, checks[1].Fix, "OPENAI_API_KEY")
+}
+
+// compresrAPI answers the subscription endpoint for one valid key.
+func compresrAPI(t *testing.T, validKey string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/pricing/subscription" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-API-Key") != validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "data": map[string]any{"tier": "pro"}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckCompresr(t *testing.T) {
+	ctx := context.Background()
+	srv := compresrAPI(t, "cmp_valid")
+	t.Setenv("COMPRESR_API_KEY", "")
+
+	withKey := func(key string) *config.Config {
+		return loadConfig(t, baseYAML+"urls:\n  compresr: \""+srv.URL+"\"\ncompresr:\n  api_key: \""+key+"\"\n")
+	}
+
+	c := doctor.CheckCompresr(ctx, srv.Client(), withKey("cmp_valid"))
+	assert.Equal(t, doctor.StatusOK, c.Status)
+	assert.Contains(t, c.Detail, "pro")
+
+	c = doctor.CheckCompresr(ctx, srv.Client(), withKey("cmp_wrong"))
+	assert.Equal(t, doctor.StatusFail, c.Status)
+	assert.Contains(t, c.Detail, "invalid API key")
+
+	c = doctor.CheckCompresr(ctx, srv.Client(), withKey(""))
+	assert.Equal(t, doctor.StatusSkip, c.Status, "no key is fine without compresr strategies")
+
+	cfg := withKey("")
+	cfg.Pipes.ToolOutput.Enabled = true
+	cfg.Pipes.ToolOutput.Strategy = config.StrategyCompresr
+	c = doctor.CheckCompresr(ctx, srv.Client(), cfg)
+	assert.Equal(t, doctor.StatusFail, c.Status)
+	assert.Contains(t, c.Detail, "COMPRESR_API_KEY")
+}
+
+func TestCheckUpstreams(t *testing.T) {
+	ctx := context.Background()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized) // No credentials on the probe
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	cfg := loadConfig(t, baseYAML)
+	cfg.Providers = config.ProvidersConfig{
+		"anthropic": {Model: "claude-haiku-4-5", Endpoint: up.URL + "/v1/messages"},
+		"openai":    {Model: "gpt-4o-mini", Endpoint: down.URL + "/v1/chat/completions"},
+	}
+	checks := doctor.CheckUpstreams(ctx, http.DefaultClient, cfg)
+	require.Len(t, checks, 2)
+	statuses := map[string]string{}
+	for _, c := range checks {
+		statuses[c.Name] = c.Status
+	}
+	assert.Equal(t, doctor.StatusOK, statuses["upstream "+up.Listener.Addr().String()])
+	assert.Equal(t, doctor.StatusFail, statuses["upstream "+down.Listener.Addr().String()])
+}
+
+func TestCheckClaudeHooks(t *testing.T) {
+	claudeDir := t.TempDir()
+	cfg := loadConfig(t, baseYAML)
+
+	c := doctor.CheckClaudeHooks(cfg, claudeDir)
+	assert.Equal(t, doctor.StatusSkip, c.Status)
+
+	cfg.Notifications.Slack.Enabled = true
+	c = doctor.CheckClaudeHooks(cfg, claudeDir)
+	assert.Equal(t, doctor.StatusFail, c.Status)
+
+	script := filepath.Join(claudeDir, filepath.FromSlash(doctor.SlackHookScript))
+	require.NoError(t, os.MkdirAll(filepath.Dir(script), 0o750))
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"), 0o600))
+	entry := []map[string]any{{"matcher": "", "hooks": []map[string]string{{"type": "command", "command": script}}}}
+	settings, err := json.Marshal(map[string]any{"hooks": map[string]any{"Stop": entry}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(claudeDir, "settings.json"), settings, 0o600))
+	assert.False(t, doctor.SlackHookInstalled(claudeDir), "both Stop and Notification are needed")
+
+	settings, err = json.Marshal(map[string]any{"hooks": map[string]any{"Stop": entry, "Notification": entry}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(claudeDir, "settings.json"), settings, 0o600))
+	c = doctor.CheckClaudeHooks(cfg, claudeDir)
+	assert.Equal(t, doctor.StatusOK, c.Status)
+}
@@ -0,0 +1,193 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/echo"
+)
+
+func roundTrip(t *testing.T, path string, body any) (*http.Response, []byte) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com"+path, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp, err := echo.NewTransport().RoundTrip(req)
+	require.NoError(t, err)
+	out, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "true", resp.Header.Get(echo.HeaderEcho))
+	return resp, out
+}
+
+func anthropicBody(lastUser string) map[string]any {
+	return map[string]any{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"system":     "You are helpful",
+		"tools":      []map[string]any{{"name": "read_file"}, {"name": "bash"}},
+		"messages": []map[string]any{
+			{"role": "user", "content": "Read the log"},
+			{"role": "assistant", "content": []map[string]any{{"type": "tool_use", "id": "t1", "name": "read_file", "input": map[string]any{}}}},
+			{"role": "user", "content": []map[string]any{
+				{"type": "tool_result", "tool_use_id": "t1", "content": "0123456789"},
+				{"type": "text", "text": lastUser},
+			}},
+		},
+	}
+}
+
+func TestEcho_Anthropic_ReportsRequestStats(t *testing.T) {
+	resp, body := roundTrip(t, "/v1/messages", anthropicBody("what failed?"))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var msg struct {
+		Type       string `json:"type"`
+		Model      string `json:"model"`
+		StopReason string `json:"stop_reason"`
+		Content    []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(body, &msg))
+	assert.Equal(t, "message", msg.Type)
+	assert.Equal(t, "claude-sonnet-4-5", msg.Model)
+	assert.Equal(t, "end_turn", msg.StopReason)
+	require.Len(t, msg.Content, 1)
+	text := msg.Content[0].Text
+	for _, want := range []string{"model=claude-sonnet-4-5", "messages=3", "tool_results=1", "tool_result_chars=10", "tools=2", "system=true", `last user message: "what failed?"`} {
+		assert.Contains(t, text, want)
+	}
+	assert.Positive(t, msg.Usage.InputTokens)
+	assert.Positive(t, msg.Usage.OutputTokens)
+
+	_, again := roundTrip(t, "/v1/messages", anthropicBody("what failed?"))
+	assert.Equal(t, string(body), string(again), "identical requests give identical responses")
+}
+
+func TestEcho_Anthropic_MagicToolCall(t *testing.T) {
+	_, body := roundTrip(t, "/v1/messages", anthropicBody(`please ECHO_TOOL_CALL:read_file{"path": "app.log"} now`))
+	var msg struct {
+		StopReason string `json:"stop_reason"`
+		Content    []struct {
+			Type  string          `json:"type"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal(body, &msg))
+	assert.Equal(t, "tool_use", msg.StopReason)
+	require.Len(t, msg.Content, 2)
+	assert.Equal(t, "tool_use", msg.Content[1].Type)
+	assert.Equal(t, "read_file", msg.Content[1].Name)
+	assert.JSONEq(t, `{"path":"app.log"}`, string(msg.Content[1].Input))
+}
+
+func TestEcho_MagicError(t *testing.T) {
+	resp, body := roundTrip(t, "/v1/messages", anthropicBody("ECHO_ERROR:529"))
+	assert.Equal(t, 529, resp.StatusCode)
+	assert.Contains(t, string(body), "overloaded_error")
+
+	resp, body = roundTrip(t, "/v1/chat/completions", map[string]any{
+		"model": "gpt-4o", "messages": []map[string]any{{"role": "user", "content": "ECHO_ERROR:429"}},
+	})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Contains(t, string(body), "rate_limit_exceeded")
+}
+
+func TestEcho_Anthropic_Stream(t *testing.T) {
+	req := anthropicBody("ECHO_TOOL_CALL:bash")
+	req["stream"] = true
+	resp, body := roundTrip(t, "/v1/messages", req)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	stream := string(body)
+	for _, ev := range []string{"event: message_start", "event: content_block_delta", `"name":"bash"`, `"stop_reason":"tool_use"`, "event: message_stop"} {
+		assert.Contains(t, stream, ev)
+	}
+}
+
+func TestEcho_ChatCompletions(t *testing.T) {
+	req := map[string]any{
+		"model": "gpt-4o",
+		"messages": []map[string]any{
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": "ECHO_TOOL_CALL:search"},
+			{"role": "tool", "tool_call_id": "c1", "content": "result"},
+		},
+	}
+	_, body := roundTrip(t, "/v1/chat/completions", req)
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(body, &out))
+	require.Len(t, out.Choices, 1)
+	assert.Contains(t, out.Choices[0].Message.Content, "tool_results=1")
+	assert.Equal(t, "tool_calls", out.Choices[0].FinishReason)
+	require.Len(t, out.Choices[0].Message.ToolCalls, 1)
+	assert.Equal(t, "search", out.Choices[0].Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, "{}", out.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	assert.Positive(t, out.Usage.PromptTokens)
+
+	req["stream"] = true
+	req["stream_options"] = map[string]any{"include_usage": true}
+	_, body = roundTrip(t, "/v1/chat/completions", req)
+	stream := string(body)
+	assert.Contains(t, stream, `"prompt_tokens"`)
+	assert.True(t, strings.HasSuffix(stream, "data: [DONE]\n\n"))
+}
+
+func TestEcho_Responses(t *testing.T) {
+	_, body := roundTrip(t, "/v1/responses", map[string]any{
+		"model": "gpt-5", "instructions": "be brief",
+		"input": []map[string]any{
+			{"role": "user", "content": []map[string]any{{"type": "input_text", "text": "hello"}}},
+			{"type": "function_call_output", "call_id": "c1", "output": "done"},
+		},
+	})
+	var out struct {
+		Status string `json:"status"`
+		Output []struct {
+			Type    string `json:"type"`
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"output"`
+	}
+	require.NoError(t, json.Unmarshal(body, &out))
+	assert.Equal(t, "completed", out.Status)
+	require.Len(t, out.Output, 1)
+	assert.Contains(t, out.Output[0].Content[0].Text, "tool_results=1")
+	assert.Contains(t, out.Output[0].Content[0].Text, `"hello"`)
+}
+
+func TestEcho_UnknownPath(t *testing.T) {
+	resp, _ := roundTrip(t, "/v1/embeddings", map[string]any{"model": "x"})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -765,3 +765,44 @@ func cfgHighMinTokens(endpoint string) *config.Config {
 		},
 	}
 }
+
+// TestExternalProvider_PromptTemplate verifies configured prompt templates
+// replace the built-in compression prompts.
+func TestExternalProvider_PromptTemplate(t *testing.T) {
+	var receivedReq external.OpenAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &receivedReq)
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	c := cfg(server.URL)
+	c.Pipes.ToolOutput.TargetCompressionRatio = 0.8
+	c.Pipes.ToolOutput.PromptTemplate = pipes.PromptTemplateConfig{
+		System: "You compress {{.ToolName}} output. Always preserve stack traces verbatim.",
+		User:   "Query: {{.Query}} | remove {{percent .TargetRatio}}\n{{.Content}}",
+	}
+	require.NoError(t, c.Pipes.ToolOutput.Validate())
+	pipe := tooloutput.New(c, store.NewMemoryStore(time.Hour))
+
+	toolOutput := "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:12 +0x1d\nexit status 2\nmore log lines follow here"
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"model": "gpt-5",
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "why did it crash"},
+			{"role": "assistant", "content": nil, "tool_calls": []map[string]interface{}{
+				{"id": "call_1", "type": "function", "function": map[string]interface{}{"name": "bash", "arguments": "{}"}},
+			}},
+			{"role": "tool", "tool_call_id": "call_1", "content": toolOutput},
+		},
+	})
+
+	_, err := pipe.Process(pipes.NewPipeContext(adapters.NewOpenAIAdapter(), reqBody))
+	require.NoError(t, err)
+
+	require.Len(t, receivedReq.Messages, 2)
+	assert.Equal(t, "You compress bash output. Always preserve stack traces verbatim.", receivedReq.Messages[0].Content)
+	assert.True(t, strings.HasPrefix(receivedReq.Messages[1].Content, "Query: "))
+	assert.True(t, strings.HasSuffix(receivedReq.Messages[1].Content, " | remove 80%\n"+toolOutput))
+}
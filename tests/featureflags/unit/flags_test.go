@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/featureflags"
+)
+
+func TestDecide_Targeting(t *testing.T) {
+	f := featureflags.Flag{
+		Sessions: []string{"sess-a"},
+		Users:    []string{"alice"},
+		Tags:     []string{"canary"},
+	}
+	cases := []struct {
+		name   string
+		target featureflags.Target
+		on     bool
+		reason string
+	}{
+		{"session", featureflags.Target{SessionID: "sess-a"}, true, featureflags.ReasonSession},
+		{"user", featureflags.Target{SessionID: "sess-b", User: "alice"}, true, featureflags.ReasonUser},
+		{"tag", featureflags.Target{SessionID: "sess-b", Tags: []string{"ci", "canary"}}, true, featureflags.ReasonTag},
+		{"no match", featureflags.Target{SessionID: "sess-b", User: "bob", Tags: []string{"ci"}}, false, featureflags.ReasonOff},
+		{"empty target", featureflags.Target{}, false, featureflags.ReasonOff},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			on, reason := f.Decide(featureflags.RouteRules, tc.target)
+			assert.Equal(t, tc.on, on)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+
+	on, reason := featureflags.Flag{Enabled: true}.Decide(featureflags.RouteRules, featureflags.Target{})
+	assert.True(t, on)
+	assert.Equal(t, featureflags.ReasonEnabled, reason)
+}
+
+func TestDecide_PercentIsStickyAndProportional(t *testing.T) {
+	f := featureflags.Flag{Percent: 25}
+	on := 0
+	for i := 0; i < 4000; i++ {
+		target := featureflags.Target{SessionID: fmt.Sprintf("session-%d", i)}
+		first, _ := f.Decide(featureflags.CacheCompat, target)
+		again, _ := f.Decide(featureflags.CacheCompat, target)
+		require.Equal(t, first, again, "decision must be sticky per session")
+		if first {
+			on++
+		}
+	}
+	assert.InDelta(t, 1000, on, 150, "about 25%% of sessions")
+
+	none, _ := featureflags.Flag{Percent: 0}.Decide(featureflags.CacheCompat, featureflags.Target{SessionID: "session-1"})
+	all, _ := featureflags.Flag{Percent: 100}.Decide(featureflags.CacheCompat, featureflags.Target{SessionID: "session-1"})
+	assert.False(t, none)
+	assert.True(t, all)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, featureflags.Config{}.Validate())
+	assert.NoError(t, featureflags.Config{Flags: map[string]featureflags.Flag{
+		featureflags.PIIResponseUnmask: {Percent: 10, Users: []string{"alice"}},
+	}}.Validate())
+
+	err := featureflags.Config{Flags: map[string]featureflags.Flag{"streaming_magic": {Enabled: true}}}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown flag")
+
+	assert.Error(t, featureflags.ValidateFlag(featureflags.RouteRules, featureflags.Flag{Percent: 101}))
+	assert.Error(t, featureflags.ValidateFlag(featureflags.RouteRules, featureflags.Flag{Sessions: []string{""}}))
+}
+
+func TestDecisions_On(t *testing.T) {
+	var none featureflags.Decisions
+	assert.True(t, none.On(featureflags.RouteRules, true), "undefined flag falls back")
+	assert.False(t, none.On(featureflags.CacheCompat, false))
+
+	d := featureflags.Decisions{featureflags.CacheCompat: true, featureflags.RouteRules: false}
+	assert.True(t, d.On(featureflags.CacheCompat, false))
+	assert.False(t, d.On(featureflags.RouteRules, true))
+}
+
+func TestSet_OverridesAndCounts(t *testing.T) {
+	s := featureflags.NewSet(featureflags.Config{Flags: map[string]featureflags.Flag{
+		featureflags.RouteRules: {Sessions: []string{"sess-a"}},
+	}})
+
+	d := s.Evaluate(featureflags.Target{SessionID: "sess-a"})
+	assert.Equal(t, featureflags.Decisions{featureflags.RouteRules: true}, d)
+	d = s.Evaluate(featureflags.Target{SessionID: "sess-b"})
+	assert.Equal(t, featureflags.Decisions{featureflags.RouteRules: false}, d)
+
+	require.NoError(t, s.Override(featureflags.RouteRules, featureflags.Flag{Enabled: true}))
+	require.NoError(t, s.Override(featureflags.CacheCompat, featureflags.Flag{}))
+	assert.Error(t, s.Override("nope", featureflags.Flag{}))
+
+	d = s.Evaluate(featureflags.Target{SessionID: "sess-b"})
+	assert.Equal(t, featureflags.Decisions{featureflags.RouteRules: true, featureflags.CacheCompat: false}, d)
+
+	states := s.States()
+	require.Len(t, states, 2)
+	assert.Equal(t, featureflags.CacheCompat, states[0].Name)
+	assert.Equal(t, featureflags.SourceOverride, states[0].Source)
+	assert.False(t, states[0].Shadows)
+	assert.Equal(t, featureflags.RouteRules, states[1].Name)
+	assert.True(t, states[1].Shadows)
+	assert.Equal(t, int64(2), states[1].On)
+	assert.Equal(t, int64(1), states[1].Off)
+
+	// Overrides survive config reloads; clearing one reverts to config.
+	s.UpdateConfig(featureflags.Config{Flags: map[string]featureflags.Flag{
+		featureflags.RouteRules: {Users: []string{"alice"}},
+	}})
+	_, on, _, ok := s.Explain(featureflags.RouteRules, featureflags.Target{})
+	require.True(t, ok)
+	assert.True(t, on, "override still applies")
+
+	assert.True(t, s.ClearOverride(featureflags.RouteRules))
+	assert.False(t, s.ClearOverride(featureflags.RouteRules))
+	st, on, reason, ok := s.Explain(featureflags.RouteRules, featureflags.Target{User: "alice"})
+	require.True(t, ok)
+	assert.Equal(t, featureflags.SourceConfig, st.Source)
+	assert.True(t, on)
+	assert.Equal(t, featureflags.ReasonUser, reason)
+
+	assert.Nil(t, featureflags.NewSet(featureflags.Config{}).Evaluate(featureflags.Target{SessionID: "x"}))
+}
+
+func TestSet_NilSafe(t *testing.T) {
+	var s *featureflags.Set
+	assert.Nil(t, s.Evaluate(featureflags.Target{SessionID: "x"}))
+	assert.Nil(t, s.States())
+	assert.False(t, s.ClearOverride(featureflags.RouteRules))
+	assert.Error(t, s.Override(featureflags.RouteRules, featureflags.Flag{}))
+	s.UpdateConfig(featureflags.Config{})
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+)
+
+// insertAllRequest is one tabledata.insertAll call received by fakeBigQuery.
+type insertAllRequest struct {
+	Path          string
+	Authorization string
+	Rows          []struct {
+		InsertID string `json:"insertId"`
+		JSON     struct {
+			InstanceID string `json:"instance_id"`
+			Seq        string `json:"seq"`
+			ReceivedAt string `json:"received_at"`
+			Event      string `json:"event"`
+		} `json:"json"`
+	} `json:"rows"`
+}
+
+// fakeBigQuery serves a token endpoint (/token, or the metadata server path)
+// and insertAll, answering inserts with insertResponse.
+func fakeBigQuery(t *testing.T, pub *rsa.PublicKey, insertResponse string) (*httptest.Server, func() []insertAllRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var inserts []insertAllRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			if assert.Len(t, parts, 3) {
+				sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+				sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+				assert.NoError(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig), "assertion signed with the key")
+				claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+				assert.Contains(t, string(claims), `"scope":"https://www.googleapis.com/auth/bigquery.insertdata"`)
+			}
+			_, _ = w.Write([]byte(`{"access_token":"sa-token","expires_in":3600}`))
+		case strings.HasPrefix(r.URL.Path, "/computeMetadata/"):
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token":"gce-token","expires_in":3600}`))
+		default:
+			req := insertAllRequest{Path: r.URL.Path, Authorization: r.Header.Get("Authorization")}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			mu.Lock()
+			inserts = append(inserts, req)
+			mu.Unlock()
+			_, _ = w.Write([]byte(insertResponse))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []insertAllRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]insertAllRequest(nil), inserts...)
+	}
+}
+
+// serviceAccountFile writes a service account key whose token_uri is tokenURI.
+func serviceAccountFile(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	raw, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "gateway@analytics.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, os.WriteFile(path, raw, 0600))
+	return path
+}
+
+func TestBigQuerySink_StreamsRowsWithServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv, received := fakeBigQuery(t, &key.PublicKey, `{"kind":"bigquery#tableDataInsertAllResponse"}`)
+
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkBigQuery, BatchSize: 10,
+		BigQuery: fleet.BigQuerySinkConfig{
+			Project: "analytics", Dataset: "telemetry", Endpoint: srv.URL,
+			CredentialsFile: serviceAccountFile(t, key, srv.URL+"/token"),
+		},
+	}, 3)
+	assert.EqualValues(t, 3, st.EventsSent, st.LastError)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	req := reqs[0]
+	assert.Equal(t, "/bigquery/v2/projects/analytics/datasets/telemetry/tables/gateway_telemetry/insertAll", req.Path)
+	assert.Equal(t, "Bearer sa-token", req.Authorization)
+	require.Len(t, req.Rows, 3)
+	row := req.Rows[1]
+	assert.True(t, strings.HasPrefix(row.InsertID, "gw-a/"), row.InsertID)
+	assert.NotEqual(t, req.Rows[0].InsertID, row.InsertID)
+	assert.Equal(t, "gw-a", row.JSON.InstanceID)
+	assert.NotEmpty(t, row.JSON.Seq)
+	assert.NotEmpty(t, row.JSON.ReceivedAt)
+	assert.JSONEq(t, `{"n":1}`, row.JSON.Event)
+}
+
+func TestBigQuerySink_MetadataServerCredentials(t *testing.T) {
+	srv, received := fakeBigQuery(t, nil, `{}`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkBigQuery, BatchSize: 10,
+		BigQuery: fleet.BigQuerySinkConfig{Project: "analytics", Dataset: "telemetry", Table: "events", Endpoint: srv.URL},
+	}, 2)
+	assert.EqualValues(t, 2, st.EventsSent, st.LastError)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "Bearer gce-token", reqs[0].Authorization)
+	assert.True(t, strings.HasSuffix(reqs[0].Path, "/tables/events/insertAll"), reqs[0].Path)
+}
+
+func TestBigQuerySink_DropsBatchWithInsertErrors(t *testing.T) {
+	srv, received := fakeBigQuery(t, nil,
+		`{"insertErrors":[{"index":0,"errors":[{"reason":"invalid","message":"no such field: seq"}]}]}`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	st := shipAll(t, fleet.ExportConfig{
+		Sink:     fleet.SinkBigQuery,
+		BigQuery: fleet.BigQuerySinkConfig{Project: "analytics", Dataset: "telemetry", Endpoint: srv.URL},
+	}, 2)
+
+	assert.Len(t, received(), 1, "refused rows are not retried")
+	assert.EqualValues(t, 1, st.BatchesFailed)
+	assert.Zero(t, st.EventsSent)
+	assert.Contains(t, st.LastError, "no such field: seq")
+}
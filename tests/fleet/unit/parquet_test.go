@@ -0,0 +1,181 @@
+package unit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps keyed by
+// field ID: integers as int64, binaries as []byte, lists as []any.
+type thriftReader struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (tr *thriftReader) uvarint() uint64 {
+	v, err := binary.ReadUvarint(tr.r)
+	require.NoError(tr.t, err)
+	return v
+}
+
+func (tr *thriftReader) zigzag() int64 {
+	v := tr.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (tr *thriftReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for {
+		b, err := tr.r.ReadByte()
+		require.NoError(tr.t, err)
+		if b == 0 {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			id = int16(tr.zigzag())
+		}
+		last = id
+		fields[id] = tr.readValue(b & 0x0F)
+	}
+}
+
+func (tr *thriftReader) readValue(typ byte) any {
+	switch typ {
+	case 1, 2: // bool true/false
+		return typ == 1
+	case 4, 5, 6: // i16, i32, i64
+		return tr.zigzag()
+	case 8: // binary
+		buf := make([]byte, tr.uvarint())
+		_, err := io.ReadFull(tr.r, buf)
+		require.NoError(tr.t, err)
+		return buf
+	case 9: // list
+		hdr, err := tr.r.ReadByte()
+		require.NoError(tr.t, err)
+		n := uint64(hdr >> 4)
+		if n == 15 {
+			n = tr.uvarint()
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = tr.readValue(hdr & 0x0F)
+		}
+		return items
+	case 12: // struct
+		return tr.readStruct()
+	default:
+		tr.t.Fatalf("unexpected thrift type %d", typ)
+		return nil
+	}
+}
+
+// readParquet decodes a file written by fleet.EncodeParquet, following the
+// Parquet layout (footer, column chunk offsets, page headers) rather than
+// the writer's assumptions.
+func readParquet(t *testing.T, data []byte) []fleet.CollectedEvent {
+	t.Helper()
+	require.Greater(t, len(data), 12)
+	require.Equal(t, "PAR1", string(data[:4]))
+	require.Equal(t, "PAR1", string(data[len(data)-4:]))
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerLen : len(data)-8]
+	meta := (&thriftReader{t: t, r: bytes.NewReader(footer)}).readStruct()
+
+	numRows := int(meta[3].(int64))
+	schema := meta[2].([]any)
+	require.Len(t, schema, 5, "root and four columns")
+	assert.EqualValues(t, 4, schema[0].(map[int16]any)[5], "root num_children")
+
+	rows := make([]fleet.CollectedEvent, numRows)
+	groups := meta[4].([]any)
+	require.Len(t, groups, 1)
+	for i, chunk := range groups[0].(map[int16]any)[1].([]any) {
+		md := chunk.(map[int16]any)[3].(map[int16]any)
+		name := string(md[3].([]any)[0].([]byte))
+		assert.Equal(t, string(schema[i+1].(map[int16]any)[4].([]byte)), name)
+		assert.EqualValues(t, 2, md[4], "gzip codec")
+		assert.EqualValues(t, numRows, md[5])
+
+		offset := md[9].(int64)
+		pr := bytes.NewReader(data[offset:])
+		header := (&thriftReader{t: t, r: pr}).readStruct()
+		headerLen := len(data[offset:]) - pr.Len()
+		compressed := int(header[3].(int64))
+		assert.EqualValues(t, headerLen+compressed, md[7], "total_compressed_size")
+		assert.EqualValues(t, numRows, header[5].(map[int16]any)[1], "page num_values")
+
+		zr, err := gzip.NewReader(bytes.NewReader(data[int(offset)+headerLen : int(offset)+headerLen+compressed]))
+		require.NoError(t, err)
+		values, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.EqualValues(t, header[2], len(values), "uncompressed_page_size")
+
+		vr := bytes.NewReader(values)
+		for r := range rows {
+			if md[1].(int64) == 2 { // INT64
+				var v int64
+				require.NoError(t, binary.Read(vr, binary.LittleEndian, &v))
+				switch name {
+				case "seq":
+					rows[r].Seq = uint64(v)
+				case "received_at":
+					rows[r].ReceivedAt = time.UnixMilli(v).UTC()
+				}
+				continue
+			}
+			var n uint32
+			require.NoError(t, binary.Read(vr, binary.LittleEndian, &n))
+			v := make([]byte, n)
+			_, err := io.ReadFull(vr, v)
+			require.NoError(t, err)
+			switch name {
+			case "instance_id":
+				rows[r].InstanceID = string(v)
+			case "event":
+				rows[r].Event = v
+			}
+		}
+		assert.Zero(t, vr.Len(), "column %s fully consumed", name)
+	}
+	return rows
+}
+
+func TestEncodeParquet_RoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 15, 9, 30, 12, 417_000_000, time.UTC)
+	b := &fleet.Batch{InstanceID: "alice-laptop", Seq: 1760520000000000000, CreatedAt: created}
+	for i := 0; i < 20; i++ {
+		b.Events = append(b.Events, json.RawMessage(`{"request_id": "r`+string(rune('a'+i))+`", "tokens": {"in": 10}}`))
+	}
+
+	data, err := fleet.EncodeParquet(b)
+	require.NoError(t, err)
+	rows := readParquet(t, data)
+
+	require.Len(t, rows, 20)
+	for i, row := range rows {
+		assert.Equal(t, "alice-laptop", row.InstanceID)
+		assert.Equal(t, b.Seq, row.Seq)
+		assert.Equal(t, created, row.ReceivedAt)
+		assert.JSONEq(t, string(b.Events[i]), string(row.Event))
+	}
+	assert.Equal(t, `{"request_id":"ra","tokens":{"in":10}}`, string(rows[0].Event), "events are compacted")
+}
+
+func TestEncodeParquet_RejectsInvalidEvent(t *testing.T) {
+	_, err := fleet.EncodeParquet(&fleet.Batch{InstanceID: "a", Events: []json.RawMessage{json.RawMessage(`{"x":`)}})
+	var perm *fleet.PermanentError
+	assert.ErrorAs(t, err, &perm)
+}
@@ -0,0 +1,186 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+const testToken = "fleet-secret"
+
+func newCollector(t *testing.T) (*fleet.Collector, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fleet.jsonl")
+	c, err := fleet.NewCollector(fleet.CollectorConfig{Enabled: true, OutputPath: path, Token: testToken}, monitoring.AsyncWriterConfig{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, path
+}
+
+func readCollected(t *testing.T, path string) []fleet.CollectedEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var out []fleet.CollectedEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev fleet.CollectedEvent
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev))
+		out = append(out, ev)
+	}
+	return out
+}
+
+func postBatch(t *testing.T, url, token string, b *fleet.Batch) *http.Response {
+	t.Helper()
+	data, err := fleet.EncodeBatch(b)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", fleet.ContentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestShipper_RetriesUntilAcknowledged(t *testing.T) {
+	collector, path := newCollector(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "restarting", http.StatusServiceUnavailable)
+			return
+		}
+		collector.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	s := fleet.NewShipper(fleet.ExportConfig{
+		Enabled: true, CollectorURL: srv.URL, InstanceID: "gw-a", Token: testToken,
+		BatchSize: 10, FlushInterval: 50 * time.Millisecond,
+	})
+	for i := 0; i < 25; i++ {
+		require.True(t, s.Enqueue(map[string]int{"n": i}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Close(ctx))
+
+	st := s.Stats()
+	assert.EqualValues(t, 25, st.EventsSent)
+	assert.EqualValues(t, 3, st.BatchesSent)
+	assert.GreaterOrEqual(t, st.Retries, int64(2))
+	assert.Zero(t, st.EventsDropped)
+
+	events := readCollected(t, path)
+	require.Len(t, events, 25)
+	seen := map[int]bool{}
+	for _, ev := range events {
+		assert.Equal(t, "gw-a", ev.InstanceID)
+		var payload map[string]int
+		require.NoError(t, json.Unmarshal(ev.Event, &payload))
+		seen[payload["n"]] = true
+	}
+	assert.Len(t, seen, 25, "every event delivered exactly once")
+}
+
+func TestShipper_DropsWhenCollectorBacksUp(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := fleet.NewShipper(fleet.ExportConfig{
+		Enabled: true, CollectorURL: srv.URL, BatchSize: 2, MaxPendingBatches: 1,
+		FlushInterval: time.Hour, Timeout: time.Minute,
+	})
+	dropped := 0
+	for i := 0; i < 5000; i++ {
+		if !s.Enqueue(i) {
+			dropped++
+		}
+	}
+	assert.Positive(t, dropped, "Enqueue must not block or buffer without bound")
+	assert.EqualValues(t, dropped, s.Stats().EventsDropped)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, s.Close(ctx), "undelivered events are reported at shutdown")
+}
+
+func TestCollector_DeduplicatesRetriedBatches(t *testing.T) {
+	collector, path := newCollector(t)
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	batch := sampleBatch(3)
+	for i := 0; i < 2; i++ {
+		resp := postBatch(t, srv.URL, testToken, batch)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var ack fleet.IngestResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&ack))
+		assert.Equal(t, "gw-a/42", ack.BatchID)
+		assert.Equal(t, i == 1, ack.Duplicate)
+	}
+
+	assert.Len(t, readCollected(t, path), 3)
+	st := collector.Stats()
+	assert.EqualValues(t, 1, st.BatchesAccepted)
+	assert.EqualValues(t, 1, st.BatchesDuplicate)
+	assert.Equal(t, 1, st.InstancesReported)
+}
+
+func TestCollector_RejectsBadRequests(t *testing.T) {
+	collector, _ := newCollector(t)
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, postBatch(t, srv.URL, "wrong", sampleBatch(1)).StatusCode)
+
+	resp, err := http.Post(srv.URL, "application/json", bytes.NewReader([]byte(`{}`)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader([]byte("not a batch")))
+	req.Header.Set("Content-Type", fleet.ContentType)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestFleetConfig_Validate(t *testing.T) {
+	assert.NoError(t, fleet.ExportConfig{}.Validate(), "disabled")
+	assert.NoError(t, fleet.ExportConfig{Enabled: true, CollectorURL: "https://collector:18080/telemetry/ingest"}.Validate())
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, CollectorURL: "collector:18080"}.Validate(), "collector_url")
+
+	assert.NoError(t, fleet.CollectorConfig{}.Validate(), "disabled")
+	assert.ErrorContains(t, fleet.CollectorConfig{Enabled: true, Token: "t"}.Validate(), "output_path")
+	assert.ErrorContains(t, fleet.CollectorConfig{Enabled: true, OutputPath: "x.jsonl"}.Validate(), "token")
+}
@@ -0,0 +1,225 @@
+package unit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+)
+
+// sinkRequest is one request received by a fake sink.
+type sinkRequest struct {
+	Method string
+	Path   string
+	Query  map[string][]string
+	Header http.Header
+	Rows   []fleet.CollectedEvent
+	Body   []byte // Raw body of a Parquet upload (Rows is empty then)
+}
+
+// fakeSink records requests and answers them with the next status (200 once exhausted).
+func fakeSink(t *testing.T, statuses ...int) (*httptest.Server, func() []sinkRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []sinkRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body = zr
+		}
+		req := sinkRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone()}
+		if r.Header.Get("Content-Type") == "application/vnd.apache.parquet" {
+			req.Body, _ = io.ReadAll(body)
+			body = strings.NewReader("")
+		}
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			var row fleet.CollectedEvent
+			assert.NoError(t, json.Unmarshal(sc.Bytes(), &row))
+			req.Rows = append(req.Rows, row)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []sinkRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]sinkRequest(nil), reqs...)
+	}
+}
+
+// shipAll enqueues n events and closes the shipper.
+func shipAll(t *testing.T, cfg fleet.ExportConfig, n int) fleet.ShipperStats {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.InstanceID = "gw-a"
+	cfg.FlushInterval = 50 * time.Millisecond
+	s := fleet.NewShipper(cfg)
+	for i := 0; i < n; i++ {
+		require.True(t, s.Enqueue(map[string]int{"n": i}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Close(ctx))
+	return s.Stats()
+}
+
+func TestHTTPSink_PostsNDJSON(t *testing.T) {
+	srv, received := fakeSink(t, http.StatusServiceUnavailable)
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkHTTP, BatchSize: 10,
+		HTTP: fleet.HTTPSinkConfig{URL: srv.URL + "/ingest", Gzip: true, Headers: map[string]string{"Authorization": "Bearer abc"}},
+	}, 5)
+
+	assert.Equal(t, fleet.SinkHTTP, st.Sink)
+	assert.EqualValues(t, 5, st.EventsSent)
+	assert.EqualValues(t, 1, st.Retries)
+
+	reqs := received()
+	require.Len(t, reqs, 2, "the 503 is retried")
+	last := reqs[1]
+	assert.Equal(t, http.MethodPost, last.Method)
+	assert.Equal(t, "/ingest", last.Path)
+	assert.Equal(t, "application/x-ndjson", last.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer abc", last.Header.Get("Authorization"))
+	assert.NotEmpty(t, last.Header.Get("X-Batch-ID"))
+	require.Len(t, last.Rows, 5)
+	assert.Equal(t, "gw-a", last.Rows[0].InstanceID)
+	assert.JSONEq(t, `{"n":0}`, string(last.Rows[0].Event))
+}
+
+func TestHTTPSink_DropsRejectedBatch(t *testing.T) {
+	srv, received := fakeSink(t, http.StatusBadRequest)
+	st := shipAll(t, fleet.ExportConfig{Sink: fleet.SinkHTTP, HTTP: fleet.HTTPSinkConfig{URL: srv.URL}}, 3)
+
+	assert.Len(t, received(), 1, "a 400 is not retried")
+	assert.EqualValues(t, 1, st.BatchesFailed)
+	assert.Zero(t, st.EventsSent)
+	assert.Contains(t, st.LastError, "HTTP 400")
+}
+
+func TestClickHouseSink_InsertsJSONEachRow(t *testing.T) {
+	srv, received := fakeSink(t)
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkClickHouse, BatchSize: 10,
+		ClickHouse: fleet.ClickHouseSinkConfig{URL: srv.URL, Database: "analytics", Username: "gateway", Password: "pw"},
+	}, 4)
+	assert.EqualValues(t, 4, st.EventsSent)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	req := reqs[0]
+	assert.Equal(t, "INSERT INTO analytics.gateway_telemetry FORMAT JSONEachRow", req.Query["query"][0])
+	assert.NotEmpty(t, req.Query["insert_deduplication_token"])
+	assert.Equal(t, "gateway", req.Header.Get("X-ClickHouse-User"))
+	assert.Equal(t, "pw", req.Header.Get("X-ClickHouse-Key"))
+	assert.Len(t, req.Rows, 4)
+}
+
+func TestS3Sink_PutsSignedObject(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/none")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/none")
+
+	srv, received := fakeSink(t)
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkS3, BatchSize: 10,
+		S3: fleet.S3SinkConfig{Bucket: "telemetry", Prefix: "cg", Region: "eu-west-1", Endpoint: srv.URL},
+	}, 3)
+	assert.EqualValues(t, 3, st.EventsSent, st.LastError)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	req := reqs[0]
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.True(t, strings.HasPrefix(req.Path, "/telemetry/cg/dt="), req.Path)
+	assert.True(t, strings.HasSuffix(req.Path, ".jsonl.gz"), req.Path)
+	assert.Contains(t, req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/")
+	assert.Contains(t, req.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+	assert.NotEmpty(t, req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Len(t, req.Rows, 3)
+}
+
+func TestS3Sink_PutsParquetObject(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/none")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/none")
+
+	srv, received := fakeSink(t)
+	st := shipAll(t, fleet.ExportConfig{
+		Sink: fleet.SinkS3, BatchSize: 10,
+		S3: fleet.S3SinkConfig{Bucket: "telemetry", Region: "eu-west-1", Endpoint: srv.URL, Format: fleet.S3FormatParquet},
+	}, 3)
+	assert.EqualValues(t, 3, st.EventsSent, st.LastError)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	req := reqs[0]
+	assert.True(t, strings.HasSuffix(req.Path, ".parquet"), req.Path)
+	assert.Empty(t, req.Header.Get("Content-Encoding"))
+	rows := readParquet(t, req.Body)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "gw-a", rows[0].InstanceID)
+	assert.JSONEq(t, `{"n":2}`, string(rows[2].Event))
+}
+
+func TestS3ObjectKey(t *testing.T) {
+	b := &fleet.Batch{InstanceID: "team/alice", Seq: 7, CreatedAt: time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC)}
+	assert.Equal(t, "context-gateway/dt=2026-10-15/team_alice/7.jsonl.gz", fleet.S3ObjectKey("", "", b))
+	assert.Equal(t, "a/b/dt=2026-10-15/team_alice/7.jsonl.gz", fleet.S3ObjectKey("a/b/", fleet.S3FormatJSONL, b))
+	assert.Equal(t, "a/b/dt=2026-10-15/team_alice/7.parquet", fleet.S3ObjectKey("a/b/", fleet.S3FormatParquet, b))
+}
+
+func TestExportConfig_ValidateSinks(t *testing.T) {
+	valid := []fleet.ExportConfig{
+		{Enabled: true, Sink: fleet.SinkHTTP, HTTP: fleet.HTTPSinkConfig{URL: "https://example.com/ingest"}},
+		{Enabled: true, Sink: fleet.SinkS3, S3: fleet.S3SinkConfig{Bucket: "b"}},
+		{Enabled: true, Sink: fleet.SinkClickHouse, ClickHouse: fleet.ClickHouseSinkConfig{URL: "http://ch:8123", Table: "events"}},
+		{Enabled: true, Sink: fleet.SinkS3, S3: fleet.S3SinkConfig{Bucket: "b", Format: fleet.S3FormatParquet}},
+		{Enabled: true, Sink: fleet.SinkBigQuery, BigQuery: fleet.BigQuerySinkConfig{Project: "example.com:analytics", Dataset: "telemetry"}},
+	}
+	for _, c := range valid {
+		assert.NoError(t, c.Validate(), c.Sink)
+	}
+
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: "kafka"}.Validate(), "telemetry_export.sink")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkHTTP}.Validate(), "http.url")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkS3}.Validate(), "s3.bucket")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkS3, S3: fleet.S3SinkConfig{Bucket: "b", Endpoint: "minio:9000"}}.Validate(), "s3.endpoint")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkClickHouse,
+		ClickHouse: fleet.ClickHouseSinkConfig{URL: "http://ch:8123", Table: "events; DROP TABLE x"}}.Validate(), "clickhouse.table")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkS3, S3: fleet.S3SinkConfig{Bucket: "b", Format: "csv"}}.Validate(), "s3.format")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkBigQuery, BigQuery: fleet.BigQuerySinkConfig{Dataset: "d"}}.Validate(), "bigquery.project")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkBigQuery, BigQuery: fleet.BigQuerySinkConfig{Project: "p"}}.Validate(), "bigquery.dataset")
+	assert.ErrorContains(t, fleet.ExportConfig{Enabled: true, Sink: fleet.SinkBigQuery,
+		BigQuery: fleet.BigQuerySinkConfig{Project: "p", Dataset: "d", Table: "t/../x"}}.Validate(), "bigquery.table")
+}
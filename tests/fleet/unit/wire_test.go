@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/fleet"
+)
+
+func sampleBatch(n int) *fleet.Batch {
+	b := &fleet.Batch{InstanceID: "gw-a", Seq: 42, CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	for i := 0; i < n; i++ {
+		ev, _ := json.Marshal(map[string]any{"request_id": i, "model": "claude-sonnet-4-5", "preview": strings.Repeat("tool output ", 20)})
+		b.Events = append(b.Events, ev)
+	}
+	return b
+}
+
+func TestBatch_RoundTrip(t *testing.T) {
+	in := sampleBatch(200)
+	data, err := fleet.EncodeBatch(in)
+	require.NoError(t, err)
+
+	var raw int
+	for _, ev := range in.Events {
+		raw += len(ev) + 1
+	}
+	assert.Less(t, len(data), raw/5, "repetitive telemetry should compress well")
+
+	out, err := fleet.DecodeBatch(data, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, "gw-a/42", out.ID())
+	assert.True(t, in.CreatedAt.Equal(out.CreatedAt))
+	require.Len(t, out.Events, 200)
+	for i := range in.Events {
+		assert.JSONEq(t, string(in.Events[i]), string(out.Events[i]))
+	}
+}
+
+func TestBatch_MultilineEventsAreCompacted(t *testing.T) {
+	in := &fleet.Batch{InstanceID: "gw-a", Seq: 1, Events: []json.RawMessage{json.RawMessage("{\n  \"a\": 1\n}")}}
+	data, err := fleet.EncodeBatch(in)
+	require.NoError(t, err)
+	out, err := fleet.DecodeBatch(data, 1<<20)
+	require.NoError(t, err)
+	require.Len(t, out.Events, 1)
+	assert.Equal(t, `{"a":1}`, string(out.Events[0]))
+}
+
+func TestDecodeBatch_Rejects(t *testing.T) {
+	valid, err := fleet.EncodeBatch(sampleBatch(50))
+	require.NoError(t, err)
+
+	badVersion := bytes.Clone(valid)
+	badVersion[4] = 9
+
+	tests := []struct {
+		name       string
+		data       []byte
+		maxDecoded int
+		errMsg     string
+	}{
+		{"empty", nil, 1 << 20, "bad magic"},
+		{"json body", []byte(`{"events":[]}`), 1 << 20, "bad magic"},
+		{"unknown version", badVersion, 1 << 20, "unsupported version"},
+		{"truncated payload", valid[:len(valid)-10], 1 << 20, "zstd"},
+		{"decoded size over limit", valid, 1024, "exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fleet.DecodeBatch(tt.data, tt.maxDecoded)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, fleet.ErrInvalidBatch)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Admin Config API Integration Tests
+//
+// POST /admin/config/reload re-reads the config file and PATCH /admin/config
+// applies a patch without a restart. Requests already in flight finish
+// normally, and server.admin_token gates the endpoints when set.
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+const adminConfigYAML = `
+server:
+  port: 18080
+  read_timeout: 30s
+  write_timeout: 120s
+store:
+  type: memory
+  ttl: 1h
+monitoring:
+  log_level: disabled
+  log_output: discard
+cost_control:
+  enabled: true
+  global_cap: %CAP%
+`
+
+func writeAdminConfig(t *testing.T, path, globalCap string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(strings.ReplaceAll(adminConfigYAML, "%CAP%", globalCap)), 0600))
+}
+
+func adminCall(t *testing.T, method, url, token, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, out
+}
+
+func TestIntegration_AdminConfig_ReloadKeepsInFlightRequests(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(anthropicTextResponse("done"))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeAdminConfig(t, path, "5")
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.GlobalCap = 5
+	gw := gateway.New(cfg, path)
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	type result struct {
+		status int
+		body   []byte
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, body, err := sendAnthropicRequest(srv.URL, upstream.URL+"/v1/messages", map[string]interface{}{
+			"model": "claude-sonnet-4-5", "max_tokens": 16,
+			"messages": []map[string]interface{}{{"role": "user", "content": "hi"}},
+		})
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		inFlight <- result{status: resp.StatusCode, body: body}
+	}()
+	time.Sleep(100 * time.Millisecond) // let the request reach the upstream
+
+	writeAdminConfig(t, path, "7.5")
+	status, body := adminCall(t, http.MethodPost, srv.URL+"/admin/config/reload", "", "")
+	require.Equal(t, http.StatusOK, status, string(body))
+	assert.Equal(t, 7.5, gjson.GetBytes(body, "cost_control.global_cap").Float())
+	assert.Equal(t, 7.5, gw.ConfigReloader().Current().CostControl.GlobalCap)
+
+	close(release)
+	select {
+	case res := <-inFlight:
+		require.NoError(t, res.err)
+		assert.Equal(t, http.StatusOK, res.status, string(res.body))
+		assert.Equal(t, "done", gjson.GetBytes(res.body, "content.0.text").String(), "the in-flight request completes")
+	case <-time.After(10 * time.Second):
+		t.Fatal("in-flight request did not complete after reload")
+	}
+
+	// An invalid file is rejected and the live config is kept.
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: 0\n"), 0600))
+	status, body = adminCall(t, http.MethodPost, srv.URL+"/admin/config/reload", "", "")
+	assert.Equal(t, http.StatusBadRequest, status, string(body))
+	assert.Equal(t, 7.5, gw.ConfigReloader().Current().CostControl.GlobalCap)
+}
+
+func TestIntegration_AdminConfig_PatchAllowedHostsAndCaps(t *testing.T) {
+	gw := gateway.New(passthroughConfig())
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	status, body := adminCall(t, http.MethodPatch, srv.URL+"/admin/config?scope=session", "",
+		`{"server":{"allowed_hosts":["models.internal"]},"cost_control":{"enabled":true,"global_cap":3},"pipes":{"tool_output":{"min_tokens":2048}}}`)
+	require.Equal(t, http.StatusOK, status, string(body))
+	assert.Equal(t, []interface{}{"models.internal"}, gjson.GetBytes(body, "allowed_hosts").Value())
+	assert.Equal(t, 3.0, gjson.GetBytes(body, "cost_control.global_cap").Float())
+
+	cur := gw.ConfigReloader().Current()
+	assert.Equal(t, []string{"models.internal"}, cur.Server.AllowedHosts)
+	assert.Equal(t, 2048, cur.Pipes.ToolOutput.MinTokens)
+
+	status, body = adminCall(t, http.MethodPatch, srv.URL+"/admin/config?scope=session", "", `{"server":{"allowed_hosts":["http://bad/"]}}`)
+	assert.Equal(t, http.StatusBadRequest, status, string(body))
+	assert.Equal(t, []string{"models.internal"}, gw.ConfigReloader().Current().Server.AllowedHosts)
+
+	status, body = adminCall(t, http.MethodPost, srv.URL+"/admin/config/reload", "", "")
+	assert.Equal(t, http.StatusConflict, status, "no config file to reload: %s", body)
+}
+
+func TestIntegration_AdminConfig_Token(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.Server.AdminToken = "s3cret"
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	status, _ := adminCall(t, http.MethodGet, srv.URL+"/admin/config", "", "")
+	assert.Equal(t, http.StatusForbidden, status, "with a token set, loopback alone is not enough")
+	status, _ = adminCall(t, http.MethodGet, srv.URL+"/admin/config", "wrong", "")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body := adminCall(t, http.MethodGet, srv.URL+"/admin/config", "s3cret", "")
+	require.Equal(t, http.StatusOK, status, string(body))
+	assert.Equal(t, "[redacted]", gjson.GetBytes(body, "admin_token").String())
+}
+
+func TestIntegration_AdminToken_RemoteClientOnEveryAdminRoute(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.Server.AdminToken = "s3cret"
+	handler := gateway.New(cfg).Handler()
+
+	for _, path := range []string{"/admin/requests", "/admin/sessions", "/admin/state", "/admin/flags", "/admin/config"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil) // RemoteAddr 192.0.2.1, not loopback
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "%s without the token", path)
+
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, "%s with the token: %s", path, rec.Body.String())
+	}
+}
@@ -0,0 +1,138 @@
+// API Version Pinning Integration Tests
+//
+// With api_versions enabled, the gateway overwrites pinned version headers
+// before forwarding and refuses client versions outside the allowlist (or
+// logs them, in warn mode).
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// versionRecorder is an upstream that records the anthropic-version header it receives.
+type versionRecorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	versions []string
+}
+
+func newVersionRecorder() *versionRecorder {
+	vr := &versionRecorder{}
+	vr.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vr.mu.Lock()
+		vr.versions = append(vr.versions, r.Header.Get("anthropic-version"))
+		vr.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	return vr
+}
+
+func (vr *versionRecorder) received() []string {
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+	return append([]string(nil), vr.versions...)
+}
+
+func sendWithVersion(t *testing.T, gatewayURL, targetURL, version string) *http.Response {
+	t.Helper()
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"hello"}]}`
+	req, err := http.NewRequest(http.MethodPost, gatewayURL+"/v1/messages", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set("anthropic-version", version)
+	req.Header.Set("X-Target-URL", targetURL+"/v1/messages")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestIntegration_APIVersions_PinOverridesClient(t *testing.T) {
+	upstream := newVersionRecorder()
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.APIVersions = config.APIVersionsConfig{
+		Enabled: true,
+		Mode:    config.APIVersionModeReject,
+		Rules: []config.APIVersionRule{
+			{Provider: "anthropic", Header: "anthropic-version", Pin: "2023-06-01", Allowed: []string{"2023-01-01", "2023-06-01"}},
+		},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithVersion(t, gw.URL, upstream.URL, "2023-01-01")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"2023-06-01"}, upstream.received())
+}
+
+func TestIntegration_APIVersions_RejectsUnknownVersion(t *testing.T) {
+	upstream := newVersionRecorder()
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.APIVersions = config.APIVersionsConfig{
+		Enabled: true,
+		Mode:    config.APIVersionModeReject,
+		Rules:   []config.APIVersionRule{{Provider: "anthropic", Header: "anthropic-version", Allowed: []string{"2023-06-01"}}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithVersion(t, gw.URL, upstream.URL, "2099-01-01")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Empty(t, upstream.received(), "unknown version must not be forwarded")
+
+	resp = sendWithVersion(t, gw.URL, upstream.URL, "2023-06-01")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"2023-06-01"}, upstream.received())
+}
+
+func TestIntegration_APIVersions_WarnModeForwards(t *testing.T) {
+	upstream := newVersionRecorder()
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.APIVersions = config.APIVersionsConfig{
+		Enabled: true,
+		Mode:    config.APIVersionModeWarn,
+		Rules:   []config.APIVersionRule{{Provider: "anthropic", Header: "anthropic-version", Allowed: []string{"2023-06-01"}}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithVersion(t, gw.URL, upstream.URL, "2099-01-01")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"2099-01-01"}, upstream.received())
+}
+
+func TestIntegration_APIVersions_OtherProviderUnaffected(t *testing.T) {
+	upstream := newVersionRecorder()
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.APIVersions = config.APIVersionsConfig{
+		Enabled: true,
+		Mode:    config.APIVersionModeReject,
+		Rules:   []config.APIVersionRule{{Provider: "openai", Header: "OpenAI-Beta", Allowed: []string{"responses=v1"}}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithVersion(t, gw.URL, upstream.URL, "2099-01-01")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
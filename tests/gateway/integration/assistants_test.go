@@ -0,0 +1,207 @@
+// Assistants API Integration Tests
+//
+// Runs of the OpenAI Assistants API execute upstream. Requests that start or
+// continue a run are recorded; a run's usage counts once, when it is first
+// seen finished in a stream or a polled run object. Outputs sent with
+// submit_tool_outputs are compressed without expand_context references.
+// Other Assistants requests, GETs included, pass through.
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// assistantsRun is a run object; usage is set once the run has finished.
+func assistantsRun(status string, promptTokens int) string {
+	usage := "null"
+	if promptTokens > 0 {
+		usage = fmt.Sprintf(`{"prompt_tokens":%d,"completion_tokens":100,"total_tokens":%d}`, promptTokens, promptTokens+100)
+	}
+	return fmt.Sprintf(`{"id":"run_1","object":"thread.run","thread_id":"thread_1","status":%q,"model":"gpt-4o","usage":%s}`, status, usage)
+}
+
+// assistantsUpstream fakes the Assistants API: run creation answers a queued
+// run, polling a completed one, and a streamed submit_tool_outputs ends with
+// thread.run.completed. Request bodies are recorded by path.
+type assistantsUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+func newAssistantsUpstream(t *testing.T) *assistantsUpstream {
+	t.Helper()
+	u := &assistantsUpstream{bodies: make(map[string][]byte)}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies[r.Method+" "+r.URL.Path] = body
+		u.mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/submit_tool_outputs"):
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: thread.run.step.completed\ndata: {\"id\":\"step_1\",\"object\":\"thread.run.step\",\"usage\":{\"prompt_tokens\":5}}\n\n")
+			fmt.Fprintf(w, "event: thread.run.completed\ndata: %s\n\nevent: done\ndata: [DONE]\n\n", assistantsRun("completed", 100000))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/runs"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(assistantsRun("queued", 0)))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/runs/run_1"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(assistantsRun("completed", 100000)))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"thread_1","object":"thread"}`))
+		}
+	}))
+	return u
+}
+
+func (u *assistantsUpstream) body(key string) []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.bodies[key]
+}
+
+func assistantsRequest(t *testing.T, gwURL, target, method, path, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, gwURL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	req.Header.Set("OpenAI-Beta", "assistants=v2")
+	req.Header.Set(gateway.HeaderTargetURL, target+path)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+// threadSession returns the session inspector's view of thread_1, the cost session.
+func threadSession(t *testing.T, gwURL string) (requests int, model string, inputTokens int) {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/sessions/thread_1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, "", 0
+	}
+	var session struct {
+		Requests int    `json:"requests"`
+		Model    string `json:"model"`
+		Cost     struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"cost"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	return session.Requests, session.Model, session.Cost.InputTokens
+}
+
+func TestIntegration_Assistants_OtherRequestsPassThrough(t *testing.T) {
+	upstream := newAssistantsUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	resp, body := assistantsRequest(t, gw.URL, upstream.URL, http.MethodPost, "/v1/threads", `{"messages":[]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"thread_1","object":"thread"}`, body)
+
+	resp, _ = assistantsRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/threads/thread_1/messages", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "GETs are relayed, not rejected as non-POST")
+	resp, _ = assistantsRequest(t, gw.URL, upstream.URL, http.MethodDelete, "/v1/assistants/asst_1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	requests, _, _ := threadSession(t, gw.URL)
+	assert.Zero(t, requests)
+}
+
+func TestIntegration_Assistants_PolledRunUsageCountsOnce(t *testing.T) {
+	upstream := newAssistantsUpstream(t)
+	defer upstream.Close()
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.SessionCap = 0.01 // One finished run (~$0.25 at gpt-4o rates) exceeds it
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, body := assistantsRequest(t, gw.URL, upstream.URL, http.MethodPost, "/v1/threads/thread_1/runs", `{"assistant_id":"asst_1"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "queued", gjson.Get(body, "status").String())
+	requests, model, inputTokens := threadSession(t, gw.URL)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, "gpt-4o", model, "the model comes from the run")
+	assert.Zero(t, inputTokens, "a queued run has no usage yet")
+
+	for range 2 {
+		resp, body = assistantsRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/threads/thread_1/runs/run_1", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "completed", gjson.Get(body, "status").String())
+	}
+	requests, _, inputTokens = threadSession(t, gw.URL)
+	assert.Equal(t, 2, requests, "the finished run is recorded on the first poll only")
+	assert.Equal(t, 100000, inputTokens)
+
+	// The usage went to the thread's cost session: its next run is over budget.
+	resp, _ = assistantsRequest(t, gw.URL, upstream.URL, http.MethodPost, "/v1/threads/thread_1/runs", `{"assistant_id":"asst_1"}`)
+	assert.Equal(t, "true", resp.Header.Get("X-Budget-Exceeded"))
+	assert.Equal(t, "session_cost", resp.Header.Get("X-Budget-Reason"))
+}
+
+func TestIntegration_Assistants_UnknownRunPollIsNotRecorded(t *testing.T) {
+	upstream := newAssistantsUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	resp, _ := assistantsRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/threads/thread_1/runs/run_1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	requests, _, _ := threadSession(t, gw.URL)
+	assert.Zero(t, requests, "runs not started through the gateway are not billed")
+}
+
+func TestIntegration_Assistants_SubmitToolOutputsCompressedAndStreamed(t *testing.T) {
+	upstream := newAssistantsUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	output := largeToolOutput(4000)
+	reqBody, err := json.Marshal(map[string]any{
+		"stream":       true,
+		"tool_outputs": []map[string]string{{"tool_call_id": "call_1", "output": output}},
+	})
+	require.NoError(t, err)
+	path := "/v1/threads/thread_1/runs/run_1/submit_tool_outputs"
+	resp, body := assistantsRequest(t, gw.URL, upstream.URL, http.MethodPost, path, string(reqBody))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "event: thread.run.completed")
+	assert.Contains(t, body, "data: [DONE]")
+
+	forwarded := gjson.GetBytes(upstream.body(http.MethodPost+" "+path), "tool_outputs.0.output").String()
+	assert.NotEmpty(t, forwarded)
+	assert.Less(t, len(forwarded), len(output), "the tool output was compressed")
+	assert.NotContains(t, forwarded, "shadow_", "a run cannot call expand_context")
+	requests, _, inputTokens := threadSession(t, gw.URL)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 100000, inputTokens, "usage from thread.run.completed, not the step")
+
+	// The streamed run already counted; polling it adds nothing.
+	assistantsRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/threads/thread_1/runs/run_1", "")
+	requests, _, inputTokens = threadSession(t, gw.URL)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 100000, inputTokens)
+}
@@ -0,0 +1,169 @@
+// Audit Log Integration Tests
+//
+// monitoring.audit writes one JSONL entry per upstream forward: the auth
+// headers present, stripped and overridden (names only), the auth mode and
+// fallback decision, the allowed-host decision and the target URL source.
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+const auditRequestBody = `{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"hello"}]}`
+
+// auditGateway starts a gateway that audits to a temp file. stop shuts it
+// down and returns the entries written.
+func auditGateway(t *testing.T, cfg *config.Config) (url string, stop func() []monitoring.AuditEntry) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	cfg.Monitoring.Audit = config.AuditLogConfig{Enabled: true, Path: path}
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	return srv.URL, func() []monitoring.AuditEntry {
+		srv.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, gw.Shutdown(ctx))
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		var entries []monitoring.AuditEntry
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var e monitoring.AuditEntry
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+			entries = append(entries, e)
+		}
+		return entries
+	}
+}
+
+func postAudited(t *testing.T, gwURL string, header map[string]string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", strings.NewReader(auditRequestBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestIntegration_AuditLog_RecordsForwardDecisions(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	gwURL, stop := auditGateway(t, passthroughConfig())
+	status := postAudited(t, gwURL, map[string]string{
+		"x-api-key":             "sk-ant-api03-secret-value",
+		gateway.HeaderTargetURL: upstream.url(),
+		gateway.HeaderRequestID: "req-audit-1",
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	entries := stop()
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "req-audit-1", e.RequestID)
+	assert.NotEmpty(t, e.SessionID)
+	assert.Equal(t, "anthropic", e.Provider)
+	assert.Equal(t, "header", e.TargetSource)
+	assert.Equal(t, upstream.url(), e.TargetURLHeader)
+	assert.Equal(t, upstream.url()+"/v1/messages", e.TargetURL, "request path appended to X-Target-URL")
+	assert.True(t, e.HostAllowed)
+	assert.Equal(t, []string{"X-Api-Key"}, e.AuthHeadersPresent)
+	assert.Empty(t, e.AuthHeadersStripped)
+	assert.Empty(t, e.AuthHeadersOverridden)
+	assert.Contains(t, e.ForwardedHeaders, "X-Api-Key")
+	assert.Equal(t, "api_key", e.AuthModeInitial)
+	assert.Equal(t, "api_key", e.AuthModeEffective)
+	assert.Equal(t, http.StatusOK, e.Status)
+}
+
+func TestIntegration_AuditLog_DisallowedHost(t *testing.T) {
+	gwURL, stop := auditGateway(t, passthroughConfig())
+	status := postAudited(t, gwURL, map[string]string{
+		"x-api-key":             "sk-ant-api03-secret-value",
+		gateway.HeaderTargetURL: "http://upstream.example.invalid",
+	})
+	assert.NotEqual(t, http.StatusOK, status)
+
+	entries := stop()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "upstream.example.invalid", entries[0].Host)
+	assert.False(t, entries[0].HostAllowed)
+	assert.Empty(t, entries[0].ForwardedHeaders, "nothing was sent")
+	assert.Contains(t, entries[0].Error, "upstream.example.invalid")
+}
+
+func TestIntegration_AuditLog_SubscriptionFallback(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"subscription rate limit exceeded"}}`))
+			return
+		}
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.Providers = config.ProvidersConfig{
+		"anthropic": {ProviderAuth: "sk-ant-api03-fallback-key", Model: "claude-sonnet-4-5"},
+	}
+	gwURL, stop := auditGateway(t, cfg)
+	status := postAudited(t, gwURL, map[string]string{
+		"Authorization":         "Bearer sk-ant-REDACTED",
+		gateway.HeaderTargetURL: upstream.URL + "/v1/messages",
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	entries := stop()
+	require.Len(t, entries, 1, "one entry per forward, covering the retry")
+	e := entries[0]
+	assert.Equal(t, "subscription", e.AuthModeInitial)
+	assert.Equal(t, "api_key", e.AuthModeEffective)
+	assert.True(t, e.AuthFallback)
+	assert.NotEmpty(t, e.FallbackReason)
+	assert.Equal(t, []string{"Authorization"}, e.AuthHeadersPresent)
+	assert.Equal(t, []string{"Authorization"}, e.AuthHeadersStripped)
+	assert.Equal(t, []string{"X-Api-Key"}, e.AuthHeadersOverridden)
+	assert.Equal(t, http.StatusOK, e.Status)
+
+	raw, err := json.Marshal(entries)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "sk-ant-", "header values are never recorded")
+}
+
+func TestAuditLogConfig_Validate(t *testing.T) {
+	assert.NoError(t, config.AuditLogConfig{}.Validate(), "disabled")
+	assert.Error(t, config.AuditLogConfig{Enabled: true}.Validate(), "file sink needs a path")
+	assert.NoError(t, config.AuditLogConfig{Enabled: true, Path: "logs/audit.jsonl"}.Validate())
+	assert.NoError(t, config.AuditLogConfig{Enabled: true, Sink: "syslog"}.Validate(), "local daemon")
+	assert.Error(t, config.AuditLogConfig{Enabled: true, Sink: "syslog", SyslogNetwork: "udp"}.Validate(), "remote needs an address")
+	assert.Error(t, config.AuditLogConfig{Enabled: true, Sink: "kafka"}.Validate())
+}
@@ -0,0 +1,88 @@
+// Budget Scope Integration Tests
+//
+// cost_control.scopes caps spend per API key, team header or project tag. A
+// team over its cap is answered with the synthetic budget response while other
+// teams keep going, and the per-scope spend is reported on /stats/budget.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestIntegration_BudgetScopes_TeamCapBlocksOnlyThatTeam(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.Scopes = []costcontrol.BudgetScope{
+		{Name: "team", Key: "header:X-Team", Cap: 0.0001}, // Smaller than one request
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	send := func(team, prompt string) (*http.Response, []byte) {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"` + prompt + `"}]}`
+		req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("X-Team", team)
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, respBody
+	}
+	budget := func() gateway.BudgetSimulationResponse {
+		resp, err := http.Get(gw.URL + "/stats/budget")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var report gateway.BudgetSimulationResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return report
+	}
+
+	resp, _ := send("search", "summarize the release notes")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"))
+	require.Eventually(t, func() bool { return len(budget().Scopes) == 1 }, 2*time.Second, 20*time.Millisecond)
+
+	// A different conversation from the same team: the team cap still applies.
+	resp, body := send("search", "draft the changelog")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("X-Budget-Exceeded"))
+	assert.Equal(t, costcontrol.ReasonScopeCost, resp.Header.Get("X-Budget-Reason"))
+	assert.Equal(t, "team", resp.Header.Get("X-Budget-Scope"))
+	assert.Equal(t, "search", resp.Header.Get("X-Budget-Scope-Value"))
+	assert.Contains(t, string(body), `team \"search\"`)
+	assert.Len(t, upstream.getRequests(), 1, "blocked request must not be forwarded")
+
+	resp, _ = send("platform", "draft the changelog")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"), "other teams are unaffected")
+	assert.Len(t, upstream.getRequests(), 2)
+
+	require.Eventually(t, func() bool { return len(budget().Scopes) == 2 }, 2*time.Second, 20*time.Millisecond)
+	byTeam := map[string]costcontrol.ScopeUsage{}
+	for _, s := range budget().Scopes {
+		byTeam[s.Value] = s
+	}
+	assert.True(t, byTeam["search"].Exceeded())
+	assert.Equal(t, costcontrol.WindowDaily, byTeam["search"].Window)
+	assert.Equal(t, 1, byTeam["search"].Requests, "blocked requests are not counted")
+	assert.Equal(t, 1, byTeam["platform"].Requests)
+}
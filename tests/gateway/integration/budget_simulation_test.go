@@ -0,0 +1,76 @@
+// Budget Simulation Integration Tests
+//
+// In cost_control.mode simulate, requests over a cap are forwarded with a
+// simulation header and recorded for GET /stats/budget instead of blocked.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestIntegration_BudgetSimulation_ForwardsAndReports(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.Mode = costcontrol.ModeSimulate
+	cfg.CostControl.SessionEgressCap = 256 // Smaller than one forwarded request
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	send := func() *http.Response {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"summarize the release notes"}]}`
+		req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := send()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(gateway.HeaderBudgetSimulated))
+
+	// Over the egress cap: enforce mode would block, simulate forwards.
+	for i := 0; i < 2; i++ {
+		resp = send()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"))
+		assert.Equal(t, costcontrol.ReasonSessionEgress, resp.Header.Get(gateway.HeaderBudgetSimulated))
+	}
+	assert.Len(t, upstream.getRequests(), 3, "simulate mode must forward every request")
+
+	reportResp, err := http.Get(gw.URL + "/stats/budget?since=1h")
+	require.NoError(t, err)
+	defer reportResp.Body.Close()
+	require.Equal(t, http.StatusOK, reportResp.StatusCode)
+
+	var report gateway.BudgetSimulationResponse
+	require.NoError(t, json.NewDecoder(reportResp.Body).Decode(&report))
+	assert.Equal(t, costcontrol.ModeSimulate, report.Mode)
+	assert.Equal(t, 2, report.Report.WouldReject)
+	assert.Equal(t, 1, report.Report.SessionsBlocked)
+	assert.Equal(t, 2, report.Report.ByReason[costcontrol.ReasonSessionEgress])
+
+	bad, err := http.Get(gw.URL + "/stats/budget?since=yesterday")
+	require.NoError(t, err)
+	bad.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, bad.StatusCode)
+}
@@ -0,0 +1,100 @@
+// Client Authentication Integration Tests
+//
+// With client_auth enabled, proxy requests need a configured X-Gateway-Key.
+// Rejected requests are never forwarded, the key is stripped before the
+// request goes upstream, and the key's label can be used as a budget scope.
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+const (
+	aliceGatewayKey = "gw-alice-0123456789"
+	bobGatewayKey   = "gw-bob-0123456789"
+)
+
+func clientAuthConfig() *config.Config {
+	cfg := passthroughConfig()
+	cfg.ClientAuth = config.ClientAuthConfig{
+		Enabled: true,
+		APIKeys: []config.ClientAPIKey{
+			{Key: aliceGatewayKey, Label: "alice"},
+			{Key: bobGatewayKey, Label: "bob"},
+		},
+	}
+	return cfg
+}
+
+func TestIntegration_ClientAuth_RequiresKey(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	gw := createGateway(clientAuthConfig())
+	defer gw.Close()
+
+	rejected := []struct {
+		name   string
+		header map[string]string
+	}{
+		{"no key", map[string]string{"x-api-key": "sk-ant-own"}},
+		{"wrong key", map[string]string{"x-api-key": "sk-ant-own", "X-Gateway-Key": "gw-mallory-0123456789"}},
+		{"provider key is not a gateway key", map[string]string{"x-api-key": aliceGatewayKey}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), tenantPrompt, tt.header)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+		})
+	}
+	assert.Empty(t, upstream.getRequests(), "rejected requests are not forwarded")
+
+	resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), tenantPrompt, map[string]string{"x-api-key": "sk-ant-own", "X-Gateway-Key": aliceGatewayKey})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reqs := upstream.getRequests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "sk-ant-own", reqs[0].Headers.Get("x-api-key"))
+	assert.Empty(t, reqs[0].Headers.Get("X-Gateway-Key"), "the gateway key is not forwarded")
+
+	// Health checks stay open.
+	health, err := http.Get(gw.URL + "/health")
+	require.NoError(t, err)
+	health.Body.Close()
+	assert.Equal(t, http.StatusOK, health.StatusCode)
+}
+
+func TestIntegration_ClientAuth_BudgetPerClient(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := clientAuthConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.Scopes = []costcontrol.BudgetScope{
+		{Name: "caller", Key: costcontrol.ScopeKeyClient, Caps: map[string]float64{"alice": 0.0001}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	send := func(key, prompt string) *http.Response {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"` + prompt + `"}]}`
+		resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), body, map[string]string{"x-api-key": "sk-ant-own", "X-Gateway-Key": key})
+		return resp
+	}
+
+	require.Equal(t, http.StatusOK, send(aliceGatewayKey, "summarize the release notes").StatusCode)
+	require.Eventually(t, func() bool {
+		resp := send(aliceGatewayKey, "draft the changelog")
+		return resp.Header.Get("X-Budget-Exceeded") == "true" && resp.Header.Get("X-Budget-Scope-Value") == "alice"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	resp := send(bobGatewayKey, "draft the changelog")
+	assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"), "other clients are unaffected")
+}
@@ -0,0 +1,100 @@
+// Compression Diff Integration Tests
+//
+// Requests that compress a tool output are sampled for the dashboard diff
+// view: GET /api/compressions lists them and ?request_id= returns the
+// original and forwarded content side by side.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+func getCompressions(t *testing.T, url string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&raw))
+	return resp.StatusCode, raw
+}
+
+func TestIntegration_CompressionDiff_SamplesCompressedRequest(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	output := largeToolOutput(1000)
+	reqBody := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "Summarize the log"},
+			{"role": "assistant", "content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_diff_001", "name": "read_file", "input": map[string]string{"path": "app.log"}},
+			}},
+			{"role": "user", "content": []map[string]interface{}{
+				{"type": "tool_result", "tool_use_id": "toolu_diff_001", "content": output},
+			}},
+		},
+	}
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), reqBody)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list struct {
+		Requests []monitoring.ComparisonSampleSummary `json:"requests"`
+	}
+	require.Eventually(t, func() bool {
+		status, raw := getCompressions(t, gw.URL+"/api/compressions")
+		return status == http.StatusOK && json.Unmarshal(raw, &list) == nil && len(list.Requests) == 1
+	}, 2*time.Second, 20*time.Millisecond, "compressed request should be sampled")
+
+	summary := list.Requests[0]
+	assert.Equal(t, 1, summary.Outputs)
+	assert.Less(t, summary.CompressedTokens, summary.OriginalTokens)
+
+	status, raw := getCompressions(t, gw.URL+"/api/compressions?request_id="+summary.RequestID)
+	require.Equal(t, http.StatusOK, status)
+	var sample monitoring.ComparisonSample
+	require.NoError(t, json.Unmarshal(raw, &sample))
+	require.Len(t, sample.Comparisons, 1)
+	c := sample.Comparisons[0]
+	assert.Equal(t, "read_file", c.ToolName)
+	assert.Equal(t, output, c.OriginalContent)
+	assert.NotEmpty(t, c.CompressedContent)
+	assert.NotEqual(t, c.OriginalContent, c.CompressedContent)
+
+	status, _ = getCompressions(t, gw.URL+"/api/compressions?request_id=unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestIntegration_CompressionDiff_PassthroughNotSampled(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "hello"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	status, raw := getCompressions(t, gw.URL+"/api/compressions")
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"requests":[]}`, string(raw))
+}
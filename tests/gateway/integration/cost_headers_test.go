@@ -0,0 +1,118 @@
+// Cost Header Integration Tests
+//
+// With cost_control.cost_headers enabled, proxied responses carry the
+// estimated input cost of the forwarded request and the cost saved by
+// compression. Tokens are counted with the configured token counter.
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+func costHeaderRequest(output string) map[string]interface{} {
+	return map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "Summarize the log"},
+			{"role": "assistant", "content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_cost_001", "name": "read_file", "input": map[string]string{"path": "app.log"}},
+			}},
+			{"role": "user", "content": []map[string]interface{}{
+				{"type": "tool_result", "tool_use_id": "toolu_cost_001", "content": output},
+			}},
+		},
+	}
+}
+
+func parseUSD(t *testing.T, resp *http.Response, header string) float64 {
+	t.Helper()
+	raw := resp.Header.Get(header)
+	require.NotEmpty(t, raw, "%s header missing", header)
+	v, err := strconv.ParseFloat(raw, 64)
+	require.NoError(t, err)
+	return v
+}
+
+func TestIntegration_CostHeaders_ReportSavings(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	cfg := expandContextConfig()
+	cfg.CostControl.CostHeaders = true
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), costHeaderRequest(largeToolOutput(1000)))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	estimate := parseUSD(t, resp, gateway.HeaderGatewayCostEstimate)
+	saved := parseUSD(t, resp, gateway.HeaderGatewayCostSaved)
+	assert.Greater(t, estimate, 0.0)
+	assert.Greater(t, saved, 0.0, "compressed tool output should report a saving")
+}
+
+func TestIntegration_CostHeaders_NoSavingsWithoutCompression(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.CostHeaders = true
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), costHeaderRequest(largeToolOutput(1000)))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Greater(t, parseUSD(t, resp, gateway.HeaderGatewayCostEstimate), 0.0)
+	assert.Equal(t, 0.0, parseUSD(t, resp, gateway.HeaderGatewayCostSaved))
+}
+
+func TestIntegration_CostHeaders_DisabledByDefault(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), costHeaderRequest(largeToolOutput(1000)))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCostEstimate))
+	assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCostSaved))
+}
+
+func TestIntegration_CostHeaders_UseConfiguredTokenCounter(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+	counter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"input_tokens":1000000}`))
+	}))
+	defer counter.Close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.CostHeaders = true
+	cfg.Tokenizer = config.TokenizerConfig{Counter: tokenizer.CounterAnthropicAPI, APIKey: "sk-ant-test", Endpoint: counter.URL}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), costHeaderRequest("short output"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	perMTok := costcontrol.GetModelPricing("claude-sonnet-4-5").InputPerMTok
+	assert.InDelta(t, perMTok, parseUSD(t, resp, gateway.HeaderGatewayCostEstimate), 1e-6,
+		"one million tokens from count_tokens, priced at the input rate")
+}
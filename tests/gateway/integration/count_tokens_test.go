@@ -0,0 +1,77 @@
+// count_tokens Integration Tests
+//
+// /v1/messages/count_tokens counts the body the gateway would forward for
+// /v1/messages: compressed tool outputs and phantom tools included. With
+// server.count_tokens: passthrough the body is forwarded as sent.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// countTokensBody is a count_tokens request (no max_tokens) with a large tool output.
+func countTokensBody(t *testing.T) string {
+	t.Helper()
+	req := costHeaderRequest(largeToolOutput(4000))
+	delete(req, "max_tokens")
+	raw, err := json.Marshal(req)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestIntegration_CountTokens_CountsCompressedBody(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return []byte(`{"input_tokens":123}`) })
+	defer upstream.close()
+
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	body := countTokensBody(t)
+	resp, got := countTokens(t, gw.URL, upstream.url(), "sk-ant-a", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"input_tokens":123}`, got, "upstream answer relayed as is")
+	assert.Equal(t, config.CountTokensCompressed, resp.Header.Get(gateway.HeaderCountTokensBasis))
+
+	reqs := upstream.getRequests()
+	require.Len(t, reqs, 1)
+	counted := reqs[0].Body
+	assert.Less(t, len(counted), len(body), "tool output compressed before counting")
+	assert.Contains(t, string(counted), "expand_context", "phantom tools are counted too")
+	assert.NotContains(t, string(counted), "max_tokens")
+}
+
+func TestIntegration_CountTokens_PassthroughMode(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return []byte(`{"input_tokens":456}`) })
+	defer upstream.close()
+
+	cfg := expandContextConfig()
+	cfg.Server.CountTokens = config.CountTokensPassthrough
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	body := countTokensBody(t)
+	resp, _ := countTokens(t, gw.URL, upstream.url(), "sk-ant-a", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, config.CountTokensPassthrough, resp.Header.Get(gateway.HeaderCountTokensBasis))
+
+	reqs := upstream.getRequests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, body, string(reqs[0].Body), "forwarded unchanged")
+}
+
+func TestServerConfig_CountTokensValidation(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte("server:\n  port: 18081\n  read_timeout: 30s\n  write_timeout: 60s\nstore:\n  type: memory\n  ttl: 1h\n"))
+	require.NoError(t, err)
+	assert.Equal(t, config.CountTokensCompressed, cfg.Server.CountTokens, "default")
+
+	_, err = config.LoadFromBytes([]byte("server:\n  port: 18081\n  read_timeout: 30s\n  write_timeout: 60s\n  count_tokens: exact\nstore:\n  type: memory\n  ttl: 1h\n"))
+	assert.ErrorContains(t, err, "server.count_tokens")
+}
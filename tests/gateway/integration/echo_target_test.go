@@ -0,0 +1,88 @@
+// Echo Target Integration Tests
+//
+// With server.target: echo the gateway runs its pipes and budgets as usual but
+// answers upstream forwards with the local fake provider, so configs can be
+// checked without provider keys or network access.
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/echo"
+)
+
+// sendEchoRequest posts an Anthropic request without X-Target-URL or API key,
+// as a client pointed at an echo gateway would.
+func sendEchoRequest(t *testing.T, gwURL string, body map[string]interface{}) (*http.Response, string) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(out)
+}
+
+func TestIntegration_EchoTarget_ShowsForwardedRequest(t *testing.T) {
+	cfg := expandContextConfig()
+	cfg.Server.Target = echo.Target
+	require.NoError(t, cfg.Validate())
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	output := largeToolOutput(1000)
+	resp, body := sendEchoRequest(t, gw.URL, costHeaderRequest(output))
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "true", resp.Header.Get(echo.HeaderEcho))
+
+	var msg struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &msg))
+	require.NotEmpty(t, msg.Content)
+	text := msg.Content[0].Text
+	assert.Contains(t, text, "[echo] model=claude-sonnet-4-5")
+	assert.Contains(t, text, "tool_results=1")
+	m := regexp.MustCompile(`tool_result_chars=(\d+)`).FindStringSubmatch(text)
+	require.NotNil(t, m, text)
+	forwarded, _ := strconv.Atoi(m[1])
+	assert.Less(t, forwarded, len(output), "the echo reply reflects the compressed tool output the gateway forwarded")
+}
+
+func TestIntegration_EchoTarget_MagicError(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.Server.Target = echo.Target
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, body := sendEchoRequest(t, gw.URL, map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "ECHO_ERROR:429"}},
+	})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Contains(t, body, "rate_limit_error")
+}
+
+func TestIntegration_EchoTarget_InvalidTarget(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.Server.Target = "mirror"
+	assert.ErrorContains(t, cfg.Validate(), "server.target")
+}
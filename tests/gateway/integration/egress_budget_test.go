@@ -0,0 +1,75 @@
+// Egress Budget Integration Tests
+//
+// Forwarded request bytes count toward cost_control egress caps; once a cap is
+// reached the gateway answers with the synthetic budget response instead of
+// forwarding.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+)
+
+func TestIntegration_EgressBudget_SessionCapBlocksForwarding(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.SessionEgressCap = 256 // Smaller than one forwarded request
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	send := func() (*http.Response, []byte) {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"summarize the release notes"}]}`
+		req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, respBody
+	}
+
+	resp, _ := send()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"))
+	require.Len(t, upstream.getRequests(), 1)
+
+	// Same conversation: the first forward used up the session's egress.
+	resp, body := send()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("X-Budget-Exceeded"))
+	assert.Equal(t, costcontrol.ReasonSessionEgress, resp.Header.Get("X-Budget-Reason"))
+	assert.Equal(t, len(upstream.getRequests()[0].Body), atoiHeader(t, resp, "X-Session-Egress-Bytes"))
+	assert.Len(t, upstream.getRequests(), 1, "blocked request must not be forwarded")
+
+	var msg struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal(body, &msg))
+	require.NotEmpty(t, msg.Content)
+	assert.Contains(t, msg.Content[0].Text, "egress limit")
+}
+
+func atoiHeader(t *testing.T, resp *http.Response, name string) int {
+	t.Helper()
+	n, err := strconv.Atoi(resp.Header.Get(name))
+	require.NoError(t, err, "header %s", name)
+	return n
+}
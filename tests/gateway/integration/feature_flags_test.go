@@ -0,0 +1,139 @@
+// Feature Flag Integration Tests
+//
+// A pii_response_unmask flag restores PII tokens only for targeted users, and
+// runtime overrides through /admin/flags change decisions without a restart.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/featureflags"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func TestIntegration_FeatureFlags_TargetPIIResponseUnmask(t *testing.T) {
+	// The upstream echoes the (masked) user message back as its answer.
+	upstream := newMockLLM(func(body []byte, _ int) []byte {
+		return anthropicTextResponse(gjson.GetBytes(body, "messages.0.content").String())
+	})
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Pipes.PII = pipes.PIIConfig{Enabled: true}
+	cfg.FeatureFlags.Flags = map[string]featureflags.Flag{
+		featureflags.PIIResponseUnmask: {Users: []string{"alice"}},
+	}
+	require.NoError(t, cfg.FeatureFlags.Validate())
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	const email = "jane.doe@example.com"
+	answer := func(user string) string {
+		body := `{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"write to ` + email + `"}]}`
+		req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		req.Header.Set(featureflags.HeaderUser, user)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(out))
+		return gjson.GetBytes(out, "content.0.text").String()
+	}
+
+	assert.Contains(t, answer("alice"), email, "targeted user gets tokens restored")
+	for _, req := range upstream.getRequests() {
+		assert.NotContains(t, string(req.Body), email, "upstream only sees the masked request")
+	}
+	bobAnswer := answer("bob")
+	assert.NotContains(t, bobAnswer, email, "untargeted user sees the masked answer")
+	assert.NotEmpty(t, bobAnswer)
+
+	// Override at runtime: on for everyone.
+	req, err := http.NewRequest(http.MethodPut, gw.URL+"/admin/flags/"+featureflags.PIIResponseUnmask, strings.NewReader(`{"enabled":true}`))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var st featureflags.State
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, featureflags.SourceOverride, st.Source)
+	assert.True(t, st.Shadows)
+
+	assert.Contains(t, answer("bob"), email)
+
+	resp, err = http.Get(gw.URL + "/admin/flags")
+	require.NoError(t, err)
+	var list struct {
+		Flags []featureflags.State `json:"flags"`
+		Known []string             `json:"known"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	require.Len(t, list.Flags, 1)
+	assert.Equal(t, int64(2), list.Flags[0].On)
+	assert.Equal(t, int64(1), list.Flags[0].Off)
+	assert.Equal(t, featureflags.Known, list.Known)
+
+	// Drop the override: back to the configured targeting.
+	req, err = http.NewRequest(http.MethodDelete, gw.URL+"/admin/flags/"+featureflags.PIIResponseUnmask, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(gw.URL + "/admin/flags/" + featureflags.PIIResponseUnmask + "?user=bob")
+	require.NoError(t, err)
+	var explain struct {
+		On     bool   `json:"on"`
+		Reason string `json:"reason"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&explain))
+	resp.Body.Close()
+	assert.False(t, explain.On)
+	assert.Equal(t, featureflags.ReasonOff, explain.Reason)
+	assert.NotContains(t, answer("bob"), email)
+}
+
+func TestIntegration_FeatureFlags_AdminRejectsBadInput(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	put := func(name, body string) int {
+		req, err := http.NewRequest(http.MethodPut, gw.URL+"/admin/flags/"+name, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusBadRequest, put("no_such_flag", `{"enabled":true}`))
+	assert.Equal(t, http.StatusBadRequest, put(featureflags.RouteRules, `{"percent":150}`))
+	assert.Equal(t, http.StatusBadRequest, put(featureflags.RouteRules, `not json`))
+
+	resp, err := http.Get(gw.URL + "/admin/flags/" + featureflags.RouteRules)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "undefined flag")
+
+	req, err := http.NewRequest(http.MethodDelete, gw.URL+"/admin/flags/"+featureflags.RouteRules, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "no override to clear")
+}
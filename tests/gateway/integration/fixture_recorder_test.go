@@ -0,0 +1,98 @@
+// Fixture Recorder Integration Tests
+//
+// With RecordFixtures (serve --record-fixtures), successful exchanges land in
+// the fixture directory in the golden testdata layout, with PII masked and
+// credentials left out.
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func newRecordingGateway(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	gw := gateway.New(passthroughConfig())
+	require.NoError(t, gw.RecordFixtures(dir))
+	srv := httptest.NewServer(gw.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func postMessages(t *testing.T, gwURL, targetURL, userAgent, body string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "sk-ant-secret-key")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Target-URL", targetURL+"/v1/messages")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestIntegration_FixtureRecorder_NonStreaming(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte {
+		return anthropicTextResponse("I will email jane.doe@example.com now")
+	})
+	defer upstream.close()
+
+	dir := t.TempDir()
+	gw := newRecordingGateway(t, dir)
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":64,"messages":[{"role":"user","content":"Email jane.doe@example.com the report"}]}`
+	postMessages(t, gw.URL, upstream.url(), "claude-code/2.0.1", body)
+	postMessages(t, gw.URL, upstream.url(), "claude-code/2.0.1", body)
+
+	request, err := os.ReadFile(filepath.Join(dir, "anthropic_claude_code_0001.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "claude-3-5-sonnet-20241022", gjson.GetBytes(request, "model").String())
+	content := gjson.GetBytes(request, "messages.0.content").String()
+	assert.NotContains(t, content, "jane.doe@example.com")
+	assert.Contains(t, content, "[[PII_EMAIL_")
+	assert.NotContains(t, string(request), "sk-ant-secret-key")
+
+	response, err := os.ReadFile(filepath.Join(dir, "anthropic_claude_code_0001.response.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(response), "jane.doe@example.com")
+	assert.True(t, gjson.ValidBytes(response))
+
+	_, err = os.Stat(filepath.Join(dir, "anthropic_claude_code_0002.json"))
+	assert.NoError(t, err, "each exchange gets its own case number")
+}
+
+func TestIntegration_FixtureRecorder_Streaming(t *testing.T) {
+	stream := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":10}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"call 555-123-4567\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return []byte(stream) })
+	defer upstream.close()
+
+	dir := t.TempDir()
+	// A case left by an earlier run is not overwritten.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "anthropic_codex_0001.json"), []byte("{}\n"), 0o600))
+
+	gw := newRecordingGateway(t, dir)
+	postMessages(t, gw.URL, upstream.url(), "codex_cli_rs/0.40",
+		`{"model":"claude-3-5-sonnet-20241022","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	sse, err := os.ReadFile(filepath.Join(dir, "anthropic_codex_0002.response.sse"))
+	require.NoError(t, err)
+	assert.Contains(t, string(sse), "event: message_stop")
+	assert.NotContains(t, string(sse), "555-123-4567")
+	assert.Contains(t, string(sse), "[[PII_PHONE_")
+}
@@ -0,0 +1,122 @@
+// gRPC API Integration Tests
+//
+// serve --grpc-addr exposes Expand, Compress, FilterTools and Summarize over
+// gRPC. Each RPC runs one component on a request body without forwarding it,
+// and access follows the admin API rule (loopback, or server.admin_token).
+package integration
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	gatewayv1 "github.com/compresr/context-gateway/api/gateway/v1"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func startGRPC(t *testing.T, cfg *config.Config) gatewayv1.ContextGatewayClient {
+	t.Helper()
+	gw := gateway.New(cfg)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	gw.ServeGRPC(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = gw.Shutdown(ctx)
+	})
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return gatewayv1.NewContextGatewayClient(conn)
+}
+
+func toolResultBody(output string) []byte {
+	return []byte(`{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[` +
+		`{"role":"user","content":"Read the file"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"read_file","input":{"path":"main.go"}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":` + jsonString(output) + `}]}]}`)
+}
+
+func jsonString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func TestIntegration_GRPC_CompressThenExpand(t *testing.T) {
+	client := startGRPC(t, bothPipesConfig())
+	ctx := context.Background()
+	original := largeToolOutput(4000)
+
+	resp, err := client.Compress(ctx, &gatewayv1.CompressRequest{Body: toolResultBody(original)})
+	require.NoError(t, err)
+	require.Len(t, resp.GetCompressions(), 1)
+	tc := resp.GetCompressions()[0]
+	assert.Equal(t, "read_file", tc.GetToolName())
+	assert.Equal(t, "toolu_1", tc.GetToolCallId())
+	assert.Less(t, tc.GetCompressedTokens(), tc.GetOriginalTokens())
+	require.NotEmpty(t, tc.GetShadowId())
+
+	compressed := gjson.GetBytes(resp.GetBody(), "messages.2.content.0.content").String()
+	assert.Less(t, len(compressed), len(original))
+
+	expanded, err := client.Expand(ctx, &gatewayv1.ExpandRequest{Id: tc.GetShadowId()})
+	require.NoError(t, err)
+	assert.Equal(t, original, expanded.GetContent())
+
+	_, err = client.Expand(ctx, &gatewayv1.ExpandRequest{Id: "shadow_missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Compress(ctx, &gatewayv1.CompressRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestIntegration_GRPC_FilterTools(t *testing.T) {
+	client := startGRPC(t, bothPipesConfig())
+
+	body := []byte(`{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[{"role":"user","content":"hi"}],"tools":[` +
+		`{"name":"read_file","description":"Read a file","input_schema":{"type":"object"}},` +
+		`{"name":"write_file","description":"Write a file","input_schema":{"type":"object"}},` +
+		`{"name":"run_tests","description":"Run the test suite","input_schema":{"type":"object"}}]}`)
+	resp, err := client.FilterTools(context.Background(), &gatewayv1.FilterToolsRequest{Body: body, SessionId: "grpc-session"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), resp.GetOriginalToolCount())
+	assert.ElementsMatch(t, []string{"read_file", "write_file", "run_tests"}, resp.GetDeferredTools())
+	assert.Equal(t, "[deferred]", gjson.GetBytes(resp.GetBody(), "tools.0.description").String())
+}
+
+func TestIntegration_GRPC_DisabledComponents(t *testing.T) {
+	client := startGRPC(t, passthroughConfig())
+	ctx := context.Background()
+
+	_, err := client.Compress(ctx, &gatewayv1.CompressRequest{Body: toolResultBody("x")})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.FilterTools(ctx, &gatewayv1.FilterToolsRequest{Body: toolResultBody("x")})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.Summarize(ctx, &gatewayv1.SummarizeRequest{Body: toolResultBody("x")})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "preemptive summarization is off")
+}
+
+func TestIntegration_GRPC_AdminToken(t *testing.T) {
+	cfg := bothPipesConfig()
+	cfg.Server.AdminToken = "s3cret"
+	client := startGRPC(t, cfg)
+
+	_, err := client.Expand(context.Background(), &gatewayv1.ExpandRequest{Id: "shadow_x"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "with a token set, loopback alone is not enough")
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	_, err = client.Expand(ctx, &gatewayv1.ExpandRequest{Id: "shadow_x"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,97 @@
+// Key Pinning Integration Tests
+//
+// With key_pinning enabled, a provider key is only forwarded to the hosts its
+// prefix is pinned to; a misrouted X-Target-URL is refused before any bytes
+// reach the upstream (or logged, in alert mode).
+package integration
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func sendWithKey(t *testing.T, gatewayURL, targetURL, key string) *http.Response {
+	t.Helper()
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"hello"}]}`
+	req, err := http.NewRequest(http.MethodPost, gatewayURL+"/v1/messages", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("X-Target-URL", targetURL+"/v1/messages")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+func TestIntegration_KeyPinning_BlocksMisroutedKey(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.KeyPinning = config.KeyPinningConfig{
+		Enabled: true,
+		Mode:    config.KeyPinningModeBlock,
+		Rules:   config.DefaultKeyPinRules(),
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithKey(t, gw.URL, upstream.url(), "sk-ant-api03-secret")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Empty(t, upstream.getRequests(), "pinned key must not reach an unexpected host")
+
+	// Keys no rule claims are forwarded to unpinned hosts.
+	resp = sendWithKey(t, gw.URL, upstream.url(), "local-test-key")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, upstream.getRequests(), 1)
+}
+
+func TestIntegration_KeyPinning_PinnedHostRequiresPrefix(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.KeyPinning = config.KeyPinningConfig{
+		Enabled: true,
+		Mode:    config.KeyPinningModeBlock,
+		Rules:   []config.KeyPinRule{{Host: "127.0.0.1", Prefixes: []string{"sk-local-"}}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithKey(t, gw.URL, upstream.url(), "sk-other-secret")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Empty(t, upstream.getRequests())
+
+	resp = sendWithKey(t, gw.URL, upstream.url(), "sk-local-secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, upstream.getRequests(), 1)
+}
+
+func TestIntegration_KeyPinning_AlertModeForwards(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.KeyPinning = config.KeyPinningConfig{
+		Enabled: true,
+		Mode:    config.KeyPinningModeAlert,
+		Rules:   config.DefaultKeyPinRules(),
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := sendWithKey(t, gw.URL, upstream.url(), "sk-ant-api03-secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, upstream.getRequests(), 1)
+}
@@ -0,0 +1,206 @@
+// MCP Server Integration Tests
+//
+// POST /mcp answers MCP JSON-RPC messages with the gateway's tools:
+// expand_context reads the shadow store, search_tools searches a session's
+// deferred tools, get_session_cost reports a session's spend. GET /mcp/sse
+// plus POST /mcp/message is the HTTP+SSE transport for the same tools.
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// mcpCall sends one JSON-RPC request to POST /mcp and returns the response.
+func mcpCall(t *testing.T, gwURL, method string, params any) gjson.Result {
+	t.Helper()
+	msg, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	resp, err := http.Post(gwURL+"/mcp", "application/json", bytes.NewReader(msg))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	return gjson.ParseBytes(buf.Bytes())
+}
+
+func mcpToolCall(t *testing.T, gwURL, tool string, args map[string]any) (string, bool) {
+	t.Helper()
+	res := mcpCall(t, gwURL, "tools/call", map[string]any{"name": tool, "arguments": args})
+	require.False(t, res.Get("error").Exists(), res.Raw)
+	return res.Get("result.content.0.text").String(), res.Get("result.isError").Bool()
+}
+
+func TestIntegration_MCP_ListsTools(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	init := mcpCall(t, gw.URL, "initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}})
+	assert.Equal(t, "2025-03-26", init.Get("result.protocolVersion").String())
+	assert.Equal(t, "context-gateway", init.Get("result.serverInfo.name").String())
+
+	var names []string
+	for _, tool := range mcpCall(t, gw.URL, "tools/list", nil).Get("result.tools").Array() {
+		names = append(names, tool.Get("name").String())
+	}
+	assert.Equal(t, []string{"expand_context", "search_tools", "get_session_cost"}, names)
+}
+
+func TestIntegration_MCP_ExpandContextAndSessionCost(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	output := largeToolOutput(2000)
+	body := costHeaderRequest(output)
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	forwarded := mock.getRequests()[0].Body
+	ref := regexp.MustCompile(`shadow_[0-9a-f]{32}`).Find(forwarded)
+	require.NotNil(t, ref, "tool output was compressed with a shadow ref")
+
+	text, isErr := mcpToolCall(t, gw.URL, "expand_context", map[string]any{"id": string(ref)})
+	assert.False(t, isErr)
+	assert.Equal(t, output, text)
+
+	text, isErr = mcpToolCall(t, gw.URL, "expand_context", map[string]any{"id": "shadow_missing"})
+	assert.True(t, isErr)
+	assert.Contains(t, text, "not found")
+
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	var cost gjson.Result
+	require.Eventually(t, func() bool {
+		text, isErr := mcpToolCall(t, gw.URL, "get_session_cost", map[string]any{})
+		cost = gjson.Parse(text)
+		return !isErr && cost.Get("cost").IsObject()
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, preemptive.ComputeSessionID(raw), cost.Get("session_id").String())
+	assert.Equal(t, int64(1), cost.Get("requests").Int())
+	assert.Greater(t, cost.Get("cost.input_tokens").Int(), int64(0))
+}
+
+func TestIntegration_MCP_SearchTools(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+	gw := createGateway(toolSearchConfig())
+	defer gw.Close()
+
+	tools := makeAnthropicToolDefs(12)
+	tools = append(tools, map[string]interface{}{
+		"name":         "deploy_service",
+		"description":  "Deploy a service to the production cluster",
+		"input_schema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+	})
+	request := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"tools":      tools,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "Ship the release"}},
+	}
+	resp, _, err := sendAnthropicRequest(gw.URL, mock.url(), request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotContains(t, string(mock.getRequests()[0].Body), "production cluster", "tool was deferred to a stub")
+
+	var text string
+	require.Eventually(t, func() bool {
+		var isErr bool
+		text, isErr = mcpToolCall(t, gw.URL, "search_tools", map[string]any{"query": "deploy production"})
+		return !isErr
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Contains(t, text, "deploy_service")
+	assert.Contains(t, text, "production cluster", "matches carry their full definition")
+
+	// Found tools count as expanded: the model's calls to them pass through.
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+	info := getSession(t, gw.URL, preemptive.ComputeSessionID(raw))
+	require.NotNil(t, info)
+	require.NotNil(t, info.Tools)
+	assert.Contains(t, info.Tools.Expanded, "deploy_service")
+}
+
+func TestIntegration_MCP_SSETransport(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	stream, err := http.Get(gw.URL + "/mcp/sse")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	require.Equal(t, http.StatusOK, stream.StatusCode)
+	events := bufio.NewReader(stream.Body)
+
+	readEvent := func() (string, string) {
+		var event, data string
+		for {
+			line, err := events.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+	}
+
+	event, endpoint := readEvent()
+	require.Equal(t, "endpoint", event)
+	require.True(t, strings.HasPrefix(endpoint, "/mcp/message?session_id="), endpoint)
+
+	resp, err := http.Post(gw.URL+endpoint, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":"a","method":"ping"}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	event, data := readEvent()
+	assert.Equal(t, "message", event)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"a","result":{}}`, data)
+
+	resp, err = http.Post(gw.URL+"/mcp/message?session_id=unknown", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestIntegration_MCP_RequiresAdminToken(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.Server.AdminToken = "secret"
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	msg := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	resp, err := http.Post(gw.URL+"/mcp", "application/json", strings.NewReader(msg))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodPost, gw.URL+"/mcp", strings.NewReader(msg))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
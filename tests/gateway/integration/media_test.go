@@ -0,0 +1,82 @@
+// Media Pipe Integration Tests
+//
+// pipes.media replaces image and document blocks older than keep_recent with
+// placeholders before forwarding; expand_context puts a block back.
+package integration
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func screenshotRequest() map[string]interface{} {
+	image := func(payload string) map[string]interface{} {
+		return map[string]interface{}{"type": "image", "source": map[string]interface{}{
+			"type": "base64", "media_type": "image/png", "data": base64.StdEncoding.EncodeToString([]byte(payload)),
+		}}
+	}
+	return map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": []map[string]interface{}{image("first screenshot"), {"type": "text", "text": "Before"}}},
+			{"role": "assistant", "content": "Noted."},
+			{"role": "user", "content": []map[string]interface{}{image("second screenshot"), {"type": "text", "text": "After"}}},
+		},
+	}
+}
+
+func TestIntegration_Media_DropsOlderBlocksAndExpands(t *testing.T) {
+	mock := newMockLLM(func(reqBody []byte, callNum int) []byte {
+		if callNum > 1 {
+			return anthropicTextResponse("They differ.")
+		}
+		// Ask for the dropped screenshot using the ref in its placeholder.
+		placeholder := gjson.GetBytes(reqBody, "messages.0.content.0.text").String()
+		start := strings.Index(placeholder, `id="`) + len(`id="`)
+		ref := placeholder[start : start+strings.Index(placeholder[start:], `"`)]
+		resp, _ := json.Marshal(map[string]interface{}{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+			"content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_expand", "name": "expand_context", "input": map[string]string{"id": ref}},
+			},
+			"stop_reason": "tool_use",
+			"usage":       map[string]int{"input_tokens": 10, "output_tokens": 5},
+		})
+		return resp
+	})
+	defer mock.close()
+	cfg := passthroughConfig()
+	cfg.Pipes.Media = pipes.MediaConfig{Enabled: true, Rules: []pipes.MediaRule{{Types: []string{"image/*"}, KeepRecent: 1}}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, body, err := sendAnthropicRequest(gw.URL, mock.url(), screenshotRequest())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, string(body), "They differ.")
+
+	reqs := mock.getRequests()
+	require.Len(t, reqs, 2)
+
+	// First upstream call: the older screenshot is a placeholder, the newer one is kept.
+	first := reqs[0].Body
+	assert.Equal(t, "text", gjson.GetBytes(first, "messages.0.content.0.type").String())
+	assert.Contains(t, gjson.GetBytes(first, "messages.0.content.0.text").String(), "[image omitted: image/png, 16 B")
+	assert.Equal(t, "image", gjson.GetBytes(first, "messages.2.content.0.type").String())
+
+	// After expand_context the original block is back in place.
+	second := reqs[1].Body
+	assert.Equal(t, "image", gjson.GetBytes(second, "messages.0.content.0.type").String())
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("first screenshot")),
+		gjson.GetBytes(second, "messages.0.content.0.source.data").String())
+}
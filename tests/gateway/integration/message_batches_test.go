@@ -0,0 +1,205 @@
+// Message Batches Integration Tests
+//
+// Batches run offline. Creating one is recorded as one request; each succeeded
+// item is recorded from the results file, at the batch discount, the first
+// time the results of a batch created through the gateway are fetched. With
+// server.message_batches: compressed, items are compressed without
+// expand_context references or tool filtering.
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// batchResultLine is one line of a results file.
+func batchResultLine(customID, resultType string) string {
+	if resultType != "succeeded" {
+		return fmt.Sprintf(`{"custom_id":%q,"result":{"type":%q}}`, customID, resultType)
+	}
+	return fmt.Sprintf(`{"custom_id":%q,"result":{"type":"succeeded","message":{"id":"msg_%s","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"done"}],"stop_reason":"end_turn","usage":{"input_tokens":100000,"output_tokens":1000}}}}`, customID, customID)
+}
+
+// batchesUpstream fakes the Message Batches API. Created batches are named
+// msgbatch_1; their results hold two succeeded items and one errored item.
+type batchesUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+func newBatchesUpstream(t *testing.T) *batchesUpstream {
+	t.Helper()
+	u := &batchesUpstream{bodies: make(map[string][]byte)}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies[r.Method+" "+r.URL.Path] = body
+		u.mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/results"):
+			w.Header().Set("Content-Type", "application/binary")
+			for _, line := range []string{batchResultLine("a", "succeeded"), batchResultLine("b", "errored"), batchResultLine("c", "succeeded")} {
+				_, _ = fmt.Fprintln(w, line)
+			}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msgbatch_1","type":"message_batch","processing_status":"in_progress"}`))
+		}
+	}))
+	return u
+}
+
+func (u *batchesUpstream) body(key string) []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.bodies[key]
+}
+
+func batchesRequest(t *testing.T, gwURL, target, method, path, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, gwURL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "sk-ant-test")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set(gateway.HeaderTargetURL, target+path)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+// batchBody is a batch of two items, each with a large tool output and two tools.
+func batchBody(t *testing.T) string {
+	t.Helper()
+	var requests []map[string]any
+	for _, id := range []string{"a", "c"} {
+		params := costHeaderRequest(largeToolOutput(4000))
+		params["tools"] = []map[string]any{
+			{"name": "read_file", "description": "Read a file", "input_schema": map[string]any{"type": "object"}},
+			{"name": "write_file", "description": "Write a file", "input_schema": map[string]any{"type": "object"}},
+		}
+		requests = append(requests, map[string]any{"custom_id": id, "params": params})
+	}
+	raw, err := json.Marshal(map[string]any{"requests": requests})
+	require.NoError(t, err)
+	return string(raw)
+}
+
+// batchSession returns the session inspector's view of msgbatch_1, the cost session.
+func batchSession(t *testing.T, gwURL string) (requests int, usd float64) {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/sessions/msgbatch_1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, 0
+	}
+	var session struct {
+		Requests int `json:"requests"`
+		Cost     struct {
+			USD float64 `json:"usd"`
+		} `json:"cost"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	return session.Requests, session.Cost.USD
+}
+
+func TestIntegration_MessageBatches_ItemsRecordedFromResults(t *testing.T) {
+	upstream := newBatchesUpstream(t)
+	defer upstream.Close()
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	body := batchBody(t)
+	resp, got := batchesRequest(t, gw.URL, upstream.URL, http.MethodPost, "/v1/messages/batches", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "msgbatch_1", gjson.Get(got, "id").String())
+	assert.Equal(t, body, string(upstream.body("POST /v1/messages/batches")), "passthrough submits the items as sent")
+	requests, usd := batchSession(t, gw.URL)
+	assert.Equal(t, 1, requests, "the create request")
+	assert.Zero(t, usd)
+
+	resp, got = batchesRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/messages/batches/msgbatch_1/results", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, strings.Count(got, "\n"), "results relayed unchanged")
+	requests, usd = batchSession(t, gw.URL)
+	assert.Equal(t, 3, requests, "the two succeeded items; the errored one was not billed")
+	// 100k input + 1k output at $3/$15 per MTok is $0.315 per item, halved.
+	assert.InDelta(t, 0.315, usd, 1e-9)
+
+	// Fetching the results again records nothing.
+	batchesRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/messages/batches/msgbatch_1/results", "")
+	requests, usd = batchSession(t, gw.URL)
+	assert.Equal(t, 3, requests)
+	assert.InDelta(t, 0.315, usd, 1e-9)
+}
+
+func TestIntegration_MessageBatches_UnknownBatchNotRecorded(t *testing.T) {
+	upstream := newBatchesUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	resp, got := batchesRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/messages/batches/msgbatch_1/results", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, strings.Count(got, "\n"))
+	requests, _ := batchSession(t, gw.URL)
+	assert.Zero(t, requests, "batches not created through the gateway are not billed")
+
+	resp, _ = batchesRequest(t, gw.URL, upstream.URL, http.MethodGet, "/v1/messages/batches/msgbatch_1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "other batch requests are relayed, GETs included")
+}
+
+func TestIntegration_MessageBatches_CompressedItems(t *testing.T) {
+	upstream := newBatchesUpstream(t)
+	defer upstream.Close()
+	cfg := bothPipesConfig()
+	cfg.Server.MessageBatches = config.MessageBatchesCompressed
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	body := batchBody(t)
+	resp, _ := batchesRequest(t, gw.URL, upstream.URL, http.MethodPost, "/v1/messages/batches", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	forwarded := upstream.body("POST /v1/messages/batches")
+	items := gjson.GetBytes(forwarded, "requests").Array()
+	require.Len(t, items, 2)
+	for _, item := range items {
+		output := item.Get("params.messages.2.content.0.content").String()
+		assert.NotEmpty(t, output)
+		assert.Less(t, len(output), len(largeToolOutput(4000)), "the tool output was compressed")
+		assert.NotContains(t, output, "shadow_", "a batch item cannot call expand_context")
+		assert.Len(t, item.Get("params.tools").Array(), 2, "tools are not filtered or added")
+		assert.NotContains(t, item.Get("params.tools").Raw, "expand_context")
+	}
+	assert.Equal(t, "a", items[0].Get("custom_id").String())
+}
+
+func TestServerConfig_MessageBatchesValidation(t *testing.T) {
+	cfg, err := config.LoadFromBytes([]byte("server:\n  port: 18081\n  read_timeout: 30s\n  write_timeout: 60s\nstore:\n  type: memory\n  ttl: 1h\n"))
+	require.NoError(t, err)
+	assert.Equal(t, config.MessageBatchesPassthrough, cfg.Server.MessageBatches, "default")
+
+	_, err = config.LoadFromBytes([]byte("server:\n  port: 18081\n  read_timeout: 30s\n  write_timeout: 60s\n  message_batches: always\nstore:\n  type: memory\n  ttl: 1h\n"))
+	assert.ErrorContains(t, err, "server.message_batches")
+}
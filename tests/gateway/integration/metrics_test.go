@@ -0,0 +1,66 @@
+// Prometheus Metrics Integration Tests
+//
+// GET /metrics exposes per-provider request counts and latency histograms,
+// phantom loop counters, compression savings and cost-control budget state.
+package integration
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrapeMetrics(t *testing.T, gwURL string) string {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestIntegration_Metrics_ProviderHistogramAndBudget(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.GlobalCap = 25
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, _, err := sendAnthropicRequest(gw.URL, upstream.url(), map[string]interface{}{
+		"model":      "claude-3-5-sonnet-20241022",
+		"max_tokens": 16,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "hello"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	text := scrapeMetrics(t, gw.URL)
+	assert.Contains(t, text, "# TYPE context_gateway_provider_request_duration_seconds histogram")
+	assert.Contains(t, text, `context_gateway_provider_requests_total{provider="anthropic",outcome="success"} 1`)
+	assert.Contains(t, text, `context_gateway_provider_requests_total{provider="anthropic",outcome="failure"} 0`)
+	assert.Contains(t, text, `context_gateway_provider_request_duration_seconds_bucket{provider="anthropic",le="+Inf"} 1`)
+	assert.Contains(t, text, `context_gateway_provider_request_duration_seconds_count{provider="anthropic"} 1`)
+	assert.Contains(t, text, "context_gateway_phantom_loops_total 0")
+	assert.Contains(t, text, "# TYPE context_gateway_tokens_saved_total counter")
+	assert.Contains(t, text, "context_gateway_budget_enforced 1")
+	assert.Contains(t, text, "context_gateway_budget_global_cap_usd 25")
+	assert.Contains(t, text, "context_gateway_budget_sessions 1")
+}
+
+func TestIntegration_Metrics_CostControlOff(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	text := scrapeMetrics(t, gw.URL)
+	assert.Contains(t, text, "context_gateway_budget_enforced 0")
+	assert.Contains(t, text, "context_gateway_budget_global_cap_usd 0")
+	assert.NotContains(t, text, "context_gateway_provider_requests_total{", "no provider series before the first request")
+}
@@ -0,0 +1,50 @@
+// Model Rewrite Integration Tests
+//
+// model_rewrite changes the model named in the forwarded body. Without rules
+// the provider prefixes are stripped, as before.
+package integration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+func forwardedModel(t *testing.T, cfg *config.Config, model string) string {
+	t.Helper()
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	postMessages(t, gw.URL, upstream.url(), "test-client/1.0",
+		`{"model":"`+model+`","max_tokens":64,"messages":[{"role":"user","content":"hello"}]}`)
+	received := upstream.getRequests()
+	require.Len(t, received, 1)
+	return gjson.GetBytes(received[0].Body, "model").String()
+}
+
+func TestIntegration_ModelRewrite_DefaultStripsPrefix(t *testing.T) {
+	assert.Equal(t, "claude-3-5-sonnet-20241022", forwardedModel(t, passthroughConfig(), "anthropic/claude-3-5-sonnet-20241022"))
+}
+
+func TestIntegration_ModelRewrite_Rules(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.ModelRewrite.Rules = []config.ModelRewriteRule{
+		{Match: "openrouter/anthropic/claude-3.5-sonnet", To: "claude-3-5-sonnet-latest"},
+		{Regex: `^openrouter/anthropic/(.+)$`, To: "$1"},
+	}
+	assert.Equal(t, "claude-3-5-sonnet-latest", forwardedModel(t, cfg, "openrouter/anthropic/claude-3.5-sonnet"))
+	assert.Equal(t, "claude-sonnet-4-5", forwardedModel(t, cfg, "openrouter/anthropic/claude-sonnet-4-5"))
+	assert.Equal(t, "anthropic/claude-sonnet-4-5", forwardedModel(t, cfg, "anthropic/claude-sonnet-4-5"), "rules replace the default")
+}
+
+func TestIntegration_ModelRewrite_SkippedInLiteLLMMode(t *testing.T) {
+	cfg := passthroughConfig()
+	cfg.LiteLLM.Enabled = true
+	assert.Equal(t, "anthropic/claude-sonnet-4-5", forwardedModel(t, cfg, "anthropic/claude-sonnet-4-5"))
+}
@@ -0,0 +1,155 @@
+// Notification Integration Tests
+//
+// budget_warning, budget_exceeded and provider_outage events reach configured
+// webhooks.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/notify"
+)
+
+// webhookSink collects notification payloads.
+type webhookSink struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads []notify.Payload
+}
+
+func newWebhookSink(t *testing.T) *webhookSink {
+	t.Helper()
+	s := &webhookSink{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p notify.Payload
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &p); err == nil {
+			s.mu.Lock()
+			s.payloads = append(s.payloads, p)
+			s.mu.Unlock()
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookSink) events() []notify.Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]notify.Payload(nil), s.payloads...)
+}
+
+func sendNotifyTestRequest(t *testing.T, gatewayURL, upstreamURL string) int {
+	t.Helper()
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"summarize the release notes"}]}`
+	req, err := http.NewRequest(http.MethodPost, gatewayURL+"/v1/messages", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "sk-ant-test-key")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("X-Target-URL", upstreamURL+"/v1/messages")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestIntegration_Notifications_BudgetWarningOncePerSession(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	sink := newWebhookSink(t)
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.SessionEgressCap = 256 // Smaller than one forwarded request
+	cfg.Notifications.Locale = "de"
+	cfg.Notifications.Webhooks = []notify.WebhookConfig{{Name: "sink", URL: sink.URL, Events: []string{"budget_warning"}}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for range 3 {
+		sendNotifyTestRequest(t, gw.URL, upstream.url())
+	}
+
+	require.Eventually(t, func() bool { return len(sink.events()) > 0 }, 3*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond) // A duplicate would arrive by now
+	events := sink.events()
+	require.Len(t, events, 1)
+	ev := events[0]
+	assert.Equal(t, notify.EventBudgetWarning, ev.Type)
+	assert.Equal(t, "de", ev.Locale)
+	assert.Contains(t, ev.Message, "Budgetwarnung")
+	assert.Equal(t, "session_egress", ev.Data["cap"])
+	assert.Equal(t, "bytes", ev.Data["unit"])
+	assert.NotEmpty(t, ev.SessionID)
+}
+
+func TestIntegration_Notifications_BudgetExceededThrottled(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	sink := newWebhookSink(t)
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.SessionEgressCap = 256 // Smaller than one forwarded request
+	cfg.Notifications.Webhooks = []notify.WebhookConfig{{Name: "sink", URL: sink.URL, Events: []string{"budget_exceeded"}}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	// The first request is forwarded; the rest are blocked by the session cap.
+	for range 4 {
+		sendNotifyTestRequest(t, gw.URL, upstream.url())
+	}
+	require.Len(t, upstream.getRequests(), 1)
+
+	require.Eventually(t, func() bool { return len(sink.events()) > 0 }, 3*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	events := sink.events()
+	require.Len(t, events, 1, "further blocked requests are throttled")
+	ev := events[0]
+	assert.Equal(t, notify.EventBudgetExceeded, ev.Type)
+	assert.Equal(t, "critical", ev.Severity)
+	assert.Equal(t, "session_egress", ev.Data["cap"])
+	assert.EqualValues(t, 256, ev.Data["limit"])
+	assert.EqualValues(t, 0, ev.Data["suppressed"])
+	assert.NotEmpty(t, ev.SessionID)
+	assert.Contains(t, ev.Message, "requests are being blocked")
+}
+
+func TestIntegration_Notifications_ProviderOutage(t *testing.T) {
+	upstream := newMockLLMWithStatus(http.StatusServiceUnavailable, func(_ []byte, _ int) []byte {
+		return []byte(`{"type":"error","error":{"type":"api_error","message":"down"}}`)
+	})
+	defer upstream.close()
+	sink := newWebhookSink(t)
+
+	cfg := passthroughConfig()
+	cfg.Notifications.OutageThreshold = 2
+	cfg.Notifications.Webhooks = []notify.WebhookConfig{{Name: "sink", URL: sink.URL}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for range 3 {
+		assert.Equal(t, http.StatusServiceUnavailable, sendNotifyTestRequest(t, gw.URL, upstream.url()))
+	}
+
+	require.Eventually(t, func() bool { return len(sink.events()) > 0 }, 10*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	events := sink.events()
+	require.Len(t, events, 1, "outage fires once until the provider recovers")
+	assert.Equal(t, notify.EventProviderOutage, events[0].Type)
+	assert.Equal(t, "critical", events[0].Severity)
+	assert.Equal(t, "upstream_5xx", events[0].Data["error_code"])
+	assert.EqualValues(t, 503, events[0].Data["last_status"])
+}
@@ -0,0 +1,127 @@
+// Passthrough Cache Integration Tests
+//
+// Verifies TTL caching of count_tokens responses: hits on identical requests,
+// isolation by credential and body, no caching of errors, and expiry.
+package integration
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func passthroughCacheConfig() *config.Config {
+	cfg := passthroughConfig()
+	cfg.PassthroughCache = config.PassthroughCacheConfig{
+		Enabled: true,
+		Paths:   config.DefaultPassthroughCachePaths(),
+	}
+	return cfg
+}
+
+func countTokens(t *testing.T, gwURL, upstream, apiKey, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages/count_tokens", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Target-URL", upstream+"/v1/messages/count_tokens")
+	req.Header.Set("x-api-key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp, string(respBody)
+}
+
+func TestIntegration_PassthroughCache_CountTokens(t *testing.T) {
+	var calls atomic.Int32
+	upstream := newMockLLM(func(_ []byte, _ int) []byte {
+		calls.Add(1)
+		return []byte(`{"input_tokens":42}`)
+	})
+	defer upstream.close()
+
+	gw := createGateway(passthroughCacheConfig())
+	defer gw.Close()
+
+	body := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`
+
+	resp, got := countTokens(t, gw.URL, upstream.url(), "sk-ant-a", body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.JSONEq(t, `{"input_tokens":42}`, got)
+
+	resp, got = countTokens(t, gw.URL, upstream.url(), "sk-ant-a", body)
+	assert.Equal(t, "HIT", resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.JSONEq(t, `{"input_tokens":42}`, got)
+	assert.Equal(t, int32(1), calls.Load(), "second identical request must be served from cache")
+
+	// Different credential or body never shares an entry.
+	resp, _ = countTokens(t, gw.URL, upstream.url(), "sk-ant-b", body)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	resp, _ = countTokens(t, gw.URL, upstream.url(), "sk-ant-a", `{"model":"claude-sonnet-4-5","messages":[]}`)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestIntegration_PassthroughCache_ErrorsNotCached(t *testing.T) {
+	upstream := newMockLLMWithStatus(http.StatusTooManyRequests, func(_ []byte, _ int) []byte {
+		return anthropicErrorResponse()
+	})
+	defer upstream.close()
+
+	gw := createGateway(passthroughCacheConfig())
+	defer gw.Close()
+
+	body := `{"model":"claude-sonnet-4-5","messages":[]}`
+	for i := 0; i < 2; i++ {
+		resp, _ := countTokens(t, gw.URL, upstream.url(), "sk-ant-a", body)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	}
+	assert.Len(t, upstream.getRequests(), 2)
+}
+
+func TestIntegration_PassthroughCache_Expires(t *testing.T) {
+	var calls atomic.Int32
+	upstream := newMockLLM(func(_ []byte, _ int) []byte {
+		calls.Add(1)
+		return []byte(`{"input_tokens":1}`)
+	})
+	defer upstream.close()
+
+	cfg := passthroughCacheConfig()
+	cfg.PassthroughCache.Paths = map[string]time.Duration{"/v1/messages/count_tokens": 50 * time.Millisecond}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	body := `{"messages":[]}`
+	countTokens(t, gw.URL, upstream.url(), "k", body)
+	time.Sleep(80 * time.Millisecond)
+	resp, _ := countTokens(t, gw.URL, upstream.url(), "k", body)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIntegration_PassthroughCache_Disabled(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return []byte(`{"input_tokens":1}`) })
+	defer upstream.close()
+
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, _ := countTokens(t, gw.URL, upstream.url(), "k", `{}`)
+		assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCache))
+	}
+	assert.Len(t, upstream.getRequests(), 2)
+}
@@ -0,0 +1,44 @@
+// Persistent Shadow Store Integration Tests
+//
+// With store.type sqlite, shadow refs written before a restart still resolve
+// through /expand on the new gateway process.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+func TestIntegration_PersistentStore_ShadowRefsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shadow_store.db")
+
+	// The previous gateway process left a shadow ref behind.
+	prev, err := store.NewSQLiteStore(path, time.Hour, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, prev.Set("shadow_restart01", "full tool output from before the restart"))
+	require.NoError(t, prev.Close())
+
+	cfg := passthroughConfig()
+	cfg.Store = store.Config{Type: store.TypeSQLite, TTL: time.Hour, Path: path}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp, err := http.Post(gw.URL+"/expand", "application/json", strings.NewReader(`{"id":"shadow_restart01"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Content string `json:"content"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "full tool output from before the restart", body.Content)
+}
@@ -0,0 +1,80 @@
+// Priority Class Integration Tests
+//
+// Background and batch requests are shed before interactive ones as a
+// cost_control cap fills up; classes come from X-Gateway-Priority or
+// X-Session-Tags and are counted per class in /stats.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/priority"
+)
+
+func TestIntegration_Priority_ShedsBatchNearBudgetCap(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	const body = `{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"triage the nightly failures"}]}`
+	send := func(gwURL string, header, value string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Measure one forward so the daily egress cap can be set to leave it 85% used.
+	probe := createGateway(passthroughConfig())
+	require.Equal(t, http.StatusOK, send(probe.URL, "", "").StatusCode)
+	probe.Close()
+	forwarded := len(upstream.getRequests()[0].Body)
+
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.DailyEgressCap = int64(forwarded) * 100 / 85
+	cfg.Priority.Enabled = true
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := send(gw.URL, "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "interactive", resp.Header.Get(priority.HeaderPriority))
+
+	resp = send(gw.URL, priority.HeaderPriority, "batch")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "batch is shed at 80%")
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Len(t, upstream.getRequests(), 2, "shed request must not be forwarded")
+
+	resp = send(gw.URL, "X-Session-Tags", "nightly,background")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "background is shed only at 90%")
+	assert.Equal(t, "background", resp.Header.Get(priority.HeaderPriority))
+	assert.Len(t, upstream.getRequests(), 3)
+
+	statsResp, err := http.Get(gw.URL + "/stats")
+	require.NoError(t, err)
+	defer statsResp.Body.Close()
+	var stats gateway.StatsResponse
+	require.NoError(t, json.NewDecoder(statsResp.Body).Decode(&stats))
+	require.NotNil(t, stats.Priority)
+	assert.Equal(t, int64(1), stats.Priority.Classes["interactive"].Admitted)
+	assert.Equal(t, int64(1), stats.Priority.Classes["background"].Admitted)
+	assert.Equal(t, int64(1), stats.Priority.Classes["batch"].Rejected[priority.ReasonBudget])
+}
@@ -0,0 +1,139 @@
+// Rate Limit Integration Tests
+//
+// server.rate_limit puts a token bucket per client in front of the proxy:
+// per client IP and, with api_key, per API key as well. An empty bucket
+// answers 429 with a Retry-After header, without touching other clients'
+// buckets.
+package integration
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// rateLimitedPost sends a minimal Anthropic request with the given headers
+// and returns the response (body closed).
+func rateLimitedPost(t *testing.T, gwURL, target string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages",
+		strings.NewReader(`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set(gateway.HeaderTargetURL, target)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestIntegration_RateLimit_PerIPBurstThen429(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{RPS: 0.2, Burst: 2}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	agent := map[string]string{"x-api-key": "sk-a", "X-Forwarded-For": "10.0.0.1"}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(), agent).StatusCode, "within burst")
+	}
+	resp := rateLimitedPost(t, gw.URL, upstream.url(), agent)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 5, retryAfter, 1, "one token refills in 1/rps seconds")
+
+	other := map[string]string{"x-api-key": "sk-a", "X-Forwarded-For": "10.0.0.2"}
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(), other).StatusCode, "other IPs keep their own bucket")
+	assert.Len(t, upstream.getRequests(), 3, "rejected requests are not forwarded")
+}
+
+func TestIntegration_RateLimit_PerAPIKey(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{By: []string{"api_key"}, RPS: 0.1, Burst: 1}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(), map[string]string{"x-api-key": "sk-runaway"}).StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-runaway", "X-Forwarded-For": "10.0.0.9"}).StatusCode, "same key from another IP")
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-other", "X-Forwarded-For": "10.0.0.8"}).StatusCode, "a runaway key does not block other keys")
+}
+
+func TestIntegration_RateLimit_RotatingAPIKeysKeepIPLimit(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{By: []string{"api_key"}, RPS: 0.1, Burst: 2}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	ok := 0
+	for i := 0; i < 10; i++ {
+		key := map[string]string{"x-api-key": "sk-junk-" + strconv.Itoa(i), "X-Forwarded-For": "10.0.0.1"}
+		if rateLimitedPost(t, gw.URL, upstream.url(), key).StatusCode == http.StatusOK {
+			ok++
+		}
+	}
+	assert.Equal(t, 2, ok, "a fresh key per request still drains the IP bucket")
+	assert.Len(t, upstream.getRequests(), 2)
+}
+
+func TestIntegration_RateLimit_IPAndAPIKey(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{By: []string{"ip", "api_key"}, RPS: 0.1, Burst: 1}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-a", "X-Forwarded-For": "10.0.0.1"}).StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-b", "X-Forwarded-For": "10.0.0.1"}).StatusCode, "IP bucket empty")
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-a", "X-Forwarded-For": "10.0.0.2"}).StatusCode, "key bucket empty")
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(),
+		map[string]string{"x-api-key": "sk-c", "X-Forwarded-For": "10.0.0.3"}).StatusCode)
+}
+
+func TestIntegration_RateLimit_Disabled(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{Disabled: true, RPS: 0.1, Burst: 1}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.url(), map[string]string{"x-api-key": "sk-a"}).StatusCode)
+	}
+}
+
+func TestRateLimitConfig_Validate(t *testing.T) {
+	assert.NoError(t, config.RateLimitConfig{}.Validate())
+	assert.NoError(t, config.RateLimitConfig{By: []string{"ip", "api_key"}, RPS: 5, Burst: 20}.Validate())
+	assert.Error(t, config.RateLimitConfig{By: []string{"user"}}.Validate())
+	assert.Error(t, config.RateLimitConfig{RPS: -1}.Validate())
+}
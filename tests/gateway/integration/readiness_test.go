@@ -0,0 +1,190 @@
+// Readiness Integration Tests
+//
+// GET /health/ready reports per-dependency status and latency: the shadow
+// store, summarizer credentials and, when enabled in the readiness config,
+// probes of upstream providers and the Compresr API. The gateway is unready
+// (503) when every probed upstream is down; a partial upstream outage,
+// Compresr and summarizer problems only degrade it.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+type readyCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail"`
+}
+
+type readyResponse struct {
+	Status    string                `json:"status"`
+	Checks    map[string]readyCheck `json:"checks"`
+	Upstreams map[string]readyCheck `json:"upstreams"`
+}
+
+func getReady(t *testing.T, gwURL string) (int, readyResponse) {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/health/ready")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var body readyResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+// countingServer answers every request with status and counts them.
+func countingServer(status int) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+	}))
+	return srv, &hits
+}
+
+func TestIntegration_Readiness_DefaultChecksOnlyLocal(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body.Status)
+	assert.Equal(t, "ok", body.Checks["store"].Status)
+	assert.Equal(t, "skipped", body.Checks["summarizer"].Status)
+	assert.Equal(t, "skipped", body.Checks["compresr"].Status)
+	assert.Empty(t, body.Upstreams)
+}
+
+func TestIntegration_Readiness_SomeUpstreamsDownDegrades(t *testing.T) {
+	up, _ := countingServer(http.StatusNotFound) // Unauthenticated probes rarely get 200
+	defer up.Close()
+	down, _ := countingServer(http.StatusServiceUnavailable)
+	defer down.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	cfg := passthroughConfig()
+	cfg.Readiness = config.ReadinessConfig{
+		ProbeUpstreams: true,
+		Upstreams:      []string{up.URL + "/v1/messages", down.URL, gone.URL},
+		Timeout:        time.Second,
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "degraded", body.Status)
+	require.Len(t, body.Upstreams, 3)
+	assert.Equal(t, "ok", body.Upstreams[mustHost(t, up.URL)].Status)
+	assert.Equal(t, "HTTP 404", body.Upstreams[mustHost(t, up.URL)].Detail)
+	assert.Equal(t, "down", body.Upstreams[mustHost(t, down.URL)].Status)
+	assert.Equal(t, "down", body.Upstreams[mustHost(t, gone.URL)].Status)
+	assert.NotEmpty(t, body.Upstreams[mustHost(t, gone.URL)].Detail)
+}
+
+func TestIntegration_Readiness_AllUpstreamsDownIsUnready(t *testing.T) {
+	down, _ := countingServer(http.StatusServiceUnavailable)
+	defer down.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	cfg := passthroughConfig()
+	cfg.Readiness = config.ReadinessConfig{ProbeUpstreams: true, Upstreams: []string{down.URL, gone.URL}, Timeout: time.Second}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", body.Status)
+}
+
+func TestIntegration_Readiness_ClientDisconnectDoesNotPoisonCache(t *testing.T) {
+	var hits atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer slow.Close()
+
+	cfg := passthroughConfig()
+	cfg.Readiness = config.ReadinessConfig{ProbeUpstreams: true, Upstreams: []string{slow.URL}, Timeout: 5 * time.Second, CacheTTL: time.Minute}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	impatient := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err := impatient.Get(gw.URL + "/health/ready")
+	require.Error(t, err, "client gives up before the probe answers")
+
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body.Upstreams[mustHost(t, slow.URL)].Status)
+	assert.EqualValues(t, 1, hits.Load(), "the second call joins or reuses the first probe")
+}
+
+func TestIntegration_Readiness_ProbesAreCached(t *testing.T) {
+	up, hits := countingServer(http.StatusOK)
+	defer up.Close()
+
+	cfg := passthroughConfig()
+	cfg.Readiness = config.ReadinessConfig{ProbeUpstreams: true, Upstreams: []string{up.URL}, CacheTTL: time.Minute}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for i := 0; i < 3; i++ {
+		status, _ := getReady(t, gw.URL)
+		assert.Equal(t, http.StatusOK, status)
+	}
+	assert.EqualValues(t, 1, hits.Load(), "one probe per cache_ttl")
+}
+
+func TestIntegration_Readiness_CompresrDownOnlyDegrades(t *testing.T) {
+	compresrAPI, _ := countingServer(http.StatusBadGateway)
+	defer compresrAPI.Close()
+
+	cfg := passthroughConfig()
+	cfg.URLs.Compresr = compresrAPI.URL
+	cfg.Readiness = config.ReadinessConfig{ProbeCompresr: true}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "degraded", body.Status)
+	assert.Equal(t, "degraded", body.Checks["compresr"].Status)
+}
+
+func TestIntegration_Readiness_OpenCircuitSkipsProbe(t *testing.T) {
+	upstream := newFlakyUpstream()
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.UpstreamCircuitBreaker = config.UpstreamCircuitBreakerConfig{Enabled: true, MaxFailures: 1, OpenDuration: time.Minute}
+	cfg.Readiness = config.ReadinessConfig{ProbeUpstreams: true, Upstreams: []string{upstream.URL}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	rateLimitedPost(t, gw.URL, upstream.URL, nil)
+	status, body := getReady(t, gw.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, readyCheck{Status: "down", Detail: "circuit open"}, body.Upstreams[mustHost(t, upstream.URL)])
+	assert.EqualValues(t, 1, upstream.hits.Load(), "no probe while the circuit is open")
+}
+
+func TestReadinessConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&config.ReadinessConfig{Upstreams: []string{"https://api.anthropic.com"}}).Validate())
+	assert.Error(t, (&config.ReadinessConfig{Upstreams: []string{"api.anthropic.com"}}).Validate())
+	assert.Error(t, (&config.ReadinessConfig{Timeout: -time.Second}).Validate())
+}
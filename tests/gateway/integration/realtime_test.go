@@ -0,0 +1,180 @@
+// Realtime WebSocket Integration Tests
+//
+// OpenAI Realtime sessions are WebSocket upgrades relayed to the upstream
+// frame by frame. Usage from response.done events counts toward the
+// connection's cost session, and a session over budget is closed with 1008.
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// realtimeUpstream is a fake Realtime server. It answers each response.create
+// with response.created and a response.done carrying usage, and echoes other
+// frames back unchanged.
+type realtimeUpstream struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers http.Header
+}
+
+func newRealtimeUpstream(t *testing.T) *realtimeUpstream {
+	t.Helper()
+	u := &realtimeUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test-key" {
+			http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+			return
+		}
+		u.mu.Lock()
+		u.headers = r.Header.Clone()
+		u.mu.Unlock()
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"realtime"}})
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		ctx := r.Context()
+		_ = conn.Write(ctx, websocket.MessageText, []byte(`{"type":"session.created","session":{"model":"gpt-realtime-2025-08-28"}}`))
+		for {
+			typ, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if typ == websocket.MessageText && strings.Contains(string(data), `"response.create"`) {
+				_ = conn.Write(ctx, websocket.MessageText, []byte(`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`))
+				_ = conn.Write(ctx, websocket.MessageText, []byte(`{"type":"response.done","response":{"id":"resp_1","status":"completed","usage":{"total_tokens":1500,"input_tokens":1000,"output_tokens":500,"input_token_details":{"cached_tokens":200}}}}`))
+				continue
+			}
+			if err := conn.Write(ctx, typ, data); err != nil {
+				return
+			}
+		}
+	}))
+	return u
+}
+
+func (u *realtimeUpstream) header(name string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.headers.Get(name)
+}
+
+func dialRealtime(t *testing.T, gwURL, targetURL, apiKey string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	header.Set("OpenAI-Beta", "realtime=v1")
+	header.Set("X-Target-URL", targetURL)
+	return websocket.Dial(ctx, gwURL+"/v1/realtime?model=gpt-realtime", &websocket.DialOptions{
+		HTTPHeader:   header,
+		Subprotocols: []string{"realtime"},
+	})
+}
+
+func readRealtimeEvent(t *testing.T, conn *websocket.Conn) (websocket.MessageType, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	typ, data, err := conn.Read(ctx)
+	require.NoError(t, err)
+	return typ, string(data)
+}
+
+func TestIntegration_Realtime_RelaysFramesBothWays(t *testing.T) {
+	upstream := newRealtimeUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	conn, _, err := dialRealtime(t, gw.URL, upstream.URL, "sk-test-key")
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	assert.Equal(t, "realtime", conn.Subprotocol(), "upstream subprotocol is negotiated with the client")
+	assert.Equal(t, "realtime=v1", upstream.header("OpenAI-Beta"))
+	assert.Empty(t, upstream.header("X-Target-URL"), "gateway headers are not forwarded")
+
+	_, event := readRealtimeEvent(t, conn)
+	assert.Contains(t, event, "session.created")
+
+	ctx := context.Background()
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(`{"type":"input_audio_buffer.commit"}`)))
+	typ, event := readRealtimeEvent(t, conn)
+	assert.Equal(t, websocket.MessageText, typ)
+	assert.JSONEq(t, `{"type":"input_audio_buffer.commit"}`, event)
+
+	require.NoError(t, conn.Write(ctx, websocket.MessageBinary, []byte{0x01, 0x02, 0x03}))
+	typ, event = readRealtimeEvent(t, conn)
+	assert.Equal(t, websocket.MessageBinary, typ)
+	assert.Equal(t, "\x01\x02\x03", event)
+
+	require.NoError(t, conn.Close(websocket.StatusNormalClosure, ""))
+}
+
+func TestIntegration_Realtime_BudgetClosesSession(t *testing.T) {
+	upstream := newRealtimeUpstream(t)
+	defer upstream.Close()
+	cfg := passthroughConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.SessionCap = 0.001 // One response (~$0.011 at gpt-realtime rates) exceeds it
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	conn, _, err := dialRealtime(t, gw.URL, upstream.URL, "sk-test-key")
+	require.NoError(t, err)
+	defer conn.CloseNow()
+	readRealtimeEvent(t, conn) // session.created
+
+	ctx := context.Background()
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(`{"type":"response.create"}`)))
+	_, event := readRealtimeEvent(t, conn)
+	assert.Contains(t, event, "response.created")
+	_, event = readRealtimeEvent(t, conn)
+	assert.Contains(t, event, "response.done")
+
+	// The recorded usage put the session over its cap: the next event closes it.
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(`{"type":"response.create"}`)))
+	readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, _, err = conn.Read(readCtx)
+	require.Error(t, err)
+	assert.Equal(t, websocket.StatusPolicyViolation, websocket.CloseStatus(err))
+	assert.Contains(t, err.Error(), "budget exceeded")
+}
+
+func TestIntegration_Realtime_UpstreamRejectionRelayed(t *testing.T) {
+	upstream := newRealtimeUpstream(t)
+	defer upstream.Close()
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	_, resp, err := dialRealtime(t, gw.URL, upstream.URL, "sk-wrong-key")
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestIntegration_Realtime_OtherUpgradesRejected(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, resp, err := websocket.Dial(ctx, gw.URL+"/v1/chat/completions", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,138 @@
+// Request Capture Integration Tests
+//
+// /debug/requests/{id} must return the exact bytes the gateway received and
+// forwarded, and replay must reproduce the forwarded body.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestIntegration_RequestCapture_ForwardedBytes(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Monitoring.RequestCapture.Enabled = true
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	original := `{"model":"anthropic/claude-3-5-sonnet-20241022","max_tokens":64,"messages":[{"role":"user","content":"hello"}]}`
+	req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(original))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "sk-ant-test-key")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+	req.Header.Set("X-Request-ID", "req-capture-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	received := upstream.getRequests()
+	require.Len(t, received, 1)
+
+	// Summary: one forward, changed by model_rewrite and phantom tools.
+	var view gateway.CapturedRequest
+	status, body := debugGet(t, gw.URL+"/debug/requests/req-capture-1")
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal(body, &view))
+	assert.Equal(t, "/v1/messages", view.Path)
+	assert.Equal(t, len(original), view.OriginalBytes)
+	require.Len(t, view.Forwards, 1)
+	assert.False(t, view.Forwards[0].Identical)
+	assert.GreaterOrEqual(t, view.Forwards[0].FirstDiffOffset, 0)
+	assert.Equal(t, len(received[0].Body), view.Forwards[0].Bytes)
+
+	// Raw bodies are byte-exact.
+	status, body = debugGet(t, gw.URL+"/debug/requests/req-capture-1/original")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, original, string(body))
+
+	status, body = debugGet(t, gw.URL+"/debug/requests/req-capture-1/forwarded")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, received[0].Body, body)
+
+	status, _ = debugGet(t, gw.URL+"/debug/requests/req-capture-1/forwarded?attempt=2")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Replay re-runs the pipeline on the recorded input and reproduces the forward.
+	replayResp, err := http.Post(gw.URL+"/debug/requests/req-capture-1/replay", "application/json", nil)
+	require.NoError(t, err)
+	replayed, _ := io.ReadAll(replayResp.Body)
+	replayResp.Body.Close()
+	require.Equal(t, http.StatusOK, replayResp.StatusCode)
+	assert.Equal(t, "true", replayResp.Header.Get(gateway.HeaderReplayMatches))
+	assert.Equal(t, received[0].Body, replayed)
+
+	// Listing and unknown IDs.
+	var list struct {
+		Count    int                       `json:"count"`
+		Requests []gateway.CapturedRequest `json:"requests"`
+	}
+	status, body = debugGet(t, gw.URL+"/debug/requests")
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal(body, &list))
+	assert.Equal(t, 1, list.Count)
+
+	status, _ = debugGet(t, gw.URL+"/debug/requests/missing")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestIntegration_RequestCapture_KeepsMostRecent(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Monitoring.RequestCapture.Enabled = true
+	cfg.Monitoring.RequestCapture.MaxRequests = 1
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for _, id := range []string{"req-old", "req-new"} {
+		req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages",
+			strings.NewReader(`{"model":"claude-3-5-sonnet-20241022","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("x-api-key", "sk-ant-test-key")
+		req.Header.Set("X-Target-URL", upstream.url()+"/v1/messages")
+		req.Header.Set("X-Request-ID", id)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	status, _ := debugGet(t, gw.URL+"/debug/requests/req-old")
+	assert.Equal(t, http.StatusNotFound, status, "oldest capture is evicted")
+	status, _ = debugGet(t, gw.URL+"/debug/requests/req-new")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestIntegration_RequestCapture_Disabled(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	status, _ := debugGet(t, gw.URL+"/debug/requests")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func debugGet(t *testing.T, url string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, body
+}
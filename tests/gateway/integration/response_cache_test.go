@@ -0,0 +1,135 @@
+// Response Cache Pipe Integration Tests
+//
+// Identical temperature-0 requests within the TTL are answered from the cache
+// without reaching the upstream; other requests and failed responses are not
+// cached.
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func responseCacheConfig() *config.Config {
+	cfg := passthroughConfig()
+	cfg.Pipes.ResponseCache = config.ResponseCachePipeConfig{Enabled: true, TTL: time.Minute}
+	return cfg
+}
+
+func deterministicRequest(content string, temperature float64) map[string]interface{} {
+	return map[string]interface{}{
+		"model": "claude-sonnet-4-5", "max_tokens": 16, "temperature": temperature,
+		"messages": []map[string]interface{}{{"role": "user", "content": content}},
+	}
+}
+
+func TestIntegration_ResponseCache_ReplaysDeterministicRequests(t *testing.T) {
+	var calls atomic.Int32
+	upstream := newMockLLM(func(_ []byte, _ int) []byte {
+		calls.Add(1)
+		return anthropicTextResponse("answer")
+	})
+	defer upstream.close()
+
+	gw := createGateway(responseCacheConfig())
+	defer gw.Close()
+
+	resp, first, err := sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("hi", 0))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+
+	resp, second, err := sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("hi", 0))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HIT", resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load(), "replayed request must not be forwarded")
+
+	// A different conversation is a different entry.
+	resp, _, err = sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("bye", 0))
+	require.NoError(t, err)
+	assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+
+	// Sampled or unspecified temperatures are never cached.
+	for i := 0; i < 2; i++ {
+		resp, _, err = sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("hi", 0.7))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCache))
+	}
+	req := deterministicRequest("hi", 0)
+	delete(req, "temperature")
+	resp, _, err = sendAnthropicRequest(gw.URL, upstream.url(), req)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCache))
+	assert.Equal(t, int32(5), calls.Load())
+}
+
+func TestIntegration_ResponseCache_Streaming(t *testing.T) {
+	stream := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":10}}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"cached\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(stream))
+	}))
+	defer upstream.Close()
+
+	gw := createGateway(responseCacheConfig())
+	defer gw.Close()
+
+	req := deterministicRequest("hi", 0)
+	req["stream"] = true
+	for _, want := range []string{"MISS", "HIT"} {
+		resp, body, err := sendAnthropicRequest(gw.URL, upstream.URL, req)
+		require.NoError(t, err)
+		assert.Equal(t, want, resp.Header.Get(gateway.HeaderGatewayCache))
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, stream, string(body))
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIntegration_ResponseCache_ErrorsNotCached(t *testing.T) {
+	upstream := newMockLLMWithStatus(http.StatusTooManyRequests, func(_ []byte, _ int) []byte {
+		return anthropicErrorResponse()
+	})
+	defer upstream.close()
+
+	gw := createGateway(responseCacheConfig())
+	defer gw.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, _, err := sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("hi", 0))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "MISS", resp.Header.Get(gateway.HeaderGatewayCache))
+	}
+	assert.Len(t, upstream.getRequests(), 2)
+}
+
+func TestIntegration_ResponseCache_Disabled(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("answer") })
+	defer upstream.close()
+
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, _, err := sendAnthropicRequest(gw.URL, upstream.url(), deterministicRequest("hi", 0))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Header.Get(gateway.HeaderGatewayCache))
+	}
+	assert.Len(t, upstream.getRequests(), 2)
+}
@@ -0,0 +1,77 @@
+// Responses API Stored Conversation Integration Tests
+//
+// A request with previous_response_id carries no history; the gateway must
+// attribute it to the session of the response it continues.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestIntegration_ResponsesChain_ContinuesSession(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte {
+		return []byte(`{"id":"resp_first","object":"response","status":"completed",` +
+			`"output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Hi"}]}],` +
+			`"usage":{"input_tokens":1200,"output_tokens":30,"total_tokens":1230}}`)
+	})
+	defer upstream.close()
+
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	first := `{"model":"gpt-4o","input":[{"role":"user","content":"Plan the migration"}]}`
+	req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/responses", strings.NewReader(first))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-test")
+	req.Header.Set("X-Target-URL", upstream.url()+"/v1/responses")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	followUp := `{"model":"gpt-4o","previous_response_id":"resp_first","input":[{"role":"user","content":"Go ahead"}]}`
+	var est gateway.ContextEstimate
+	require.Eventually(t, func() bool {
+		est = estimateContext(t, gw.URL, followUp)
+		return est.Session.Tracked
+	}, 2*time.Second, 20*time.Millisecond, "follow-up must continue the first response's session")
+	assert.Equal(t, 1, est.Session.Requests)
+	assert.Equal(t, 1200, est.Session.InputTokens)
+	assert.Zero(t, est.CompactionThresholdPercent, "stored conversations are never compacted")
+
+	// A chain the gateway has not seen is keyed by its previous response ID.
+	unknown := estimateContext(t, gw.URL, `{"model":"gpt-4o","previous_response_id":"resp_elsewhere","input":"hi"}`)
+	assert.Equal(t, "resp_elsewhere", unknown.Session.ID)
+	assert.False(t, unknown.Session.Tracked)
+
+	// A server-side conversation object is the session itself.
+	conv := estimateContext(t, gw.URL, `{"model":"gpt-4o","conversation":{"id":"conv_42"},"input":"hi"}`)
+	assert.Equal(t, "conv_42", conv.Session.ID)
+}
+
+func estimateContext(t *testing.T, gwURL, body string) gateway.ContextEstimate {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/context/estimate?path=/v1/responses&compress=false", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer sk-test")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var est gateway.ContextEstimate
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&est))
+	return est
+}
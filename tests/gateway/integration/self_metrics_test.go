@@ -0,0 +1,74 @@
+// Lifetime Self-Metrics Integration Tests
+//
+// Request and savings counters persist in the state directory across restarts.
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+func lifetimeFromStats(t *testing.T, url string) statedir.SelfMetrics {
+	t.Helper()
+	resp, err := http.Get(url + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var st gateway.StatsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+	require.NotNil(t, st.SelfMetrics, "self_metrics missing from /stats")
+	return st.SelfMetrics.Lifetime
+}
+
+func TestGateway_SelfMetrics_PersistAcrossRestarts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	// First run: two requests
+	gw := gateway.New(passthroughConfig())
+	srv := httptest.NewServer(gw.Handler())
+	for range 2 {
+		resp, _, err := sendAnthropicRequest(srv.URL, upstream.url()+"/v1/messages", map[string]interface{}{
+			"model": "claude-3-5-sonnet-20241022", "max_tokens": 16,
+			"messages": []map[string]interface{}{{"role": "user", "content": "hi"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	// Telemetry (which counts the request) may finish after the response
+	require.Eventually(t, func() bool { return lifetimeFromStats(t, srv.URL).Requests == 2 }, 2*time.Second, 10*time.Millisecond,
+		"unflushed growth is included")
+	lt := lifetimeFromStats(t, srv.URL)
+	assert.EqualValues(t, 1, lt.Starts)
+	srv.Close()
+	require.NoError(t, gw.Shutdown(context.Background()))
+
+	dir, err := statedir.DefaultDir()
+	require.NoError(t, err)
+	persisted, err := statedir.ReadSelfMetrics(dir)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, persisted.Requests)
+
+	// Second run starts from the persisted totals
+	gw2 := gateway.New(passthroughConfig())
+	defer gw2.Shutdown(context.Background())
+	srv2 := httptest.NewServer(gw2.Handler())
+	defer srv2.Close()
+
+	lt = lifetimeFromStats(t, srv2.URL)
+	assert.EqualValues(t, 2, lt.Requests)
+	assert.EqualValues(t, 2, lt.Starts)
+	assert.Equal(t, persisted.Since, lt.Since, "first start is kept")
+}
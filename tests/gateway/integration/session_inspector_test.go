@@ -0,0 +1,133 @@
+// Session Inspector Integration Tests
+//
+// GET /sessions and GET /sessions/{id} report per-session state gathered from
+// every store: cost so far, auth mode (sticky API key after a subscription
+// fallback, with the reason), shadow refs created, tools and summary state.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// getSession fetches GET /sessions/{id}; it returns nil on 404.
+func getSession(t *testing.T, gwURL, id string) *gateway.SessionInfo {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/sessions/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var info gateway.SessionInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	return &info
+}
+
+func TestIntegration_Sessions_ReportsSubscriptionFallback(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"subscription rate limit exceeded"}}`))
+			return
+		}
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	defer upstream.Close()
+
+	cfg := passthroughConfig()
+	cfg.Providers = config.ProvidersConfig{
+		"anthropic": {ProviderAuth: "sk-ant-api03-fallback-key", Model: "claude-sonnet-4-5"},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/messages", strings.NewReader(auditRequestBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Authorization", "Bearer sk-ant-REDACTED")
+	req.Header.Set(gateway.HeaderTargetURL, upstream.URL+"/v1/messages")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	id := preemptive.ComputeSessionID([]byte(auditRequestBody))
+	var info *gateway.SessionInfo
+	require.Eventually(t, func() bool {
+		info = getSession(t, gw.URL, id)
+		return info != nil && info.Requests > 0
+	}, 2*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, gateway.SessionAuthAPIKey, info.Auth.Mode)
+	assert.True(t, info.Auth.Sticky, "session stays on the API key")
+	require.NotNil(t, info.Auth.Since)
+	assert.NotEmpty(t, info.Auth.Reason, "fallback reason is reported")
+	assert.Equal(t, "claude-sonnet-4-5", info.Model)
+	require.NotNil(t, info.Cost)
+	assert.Greater(t, info.Cost.InputTokens, 0)
+}
+
+func TestIntegration_Sessions_ListsSessionsWithShadowRefs(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	body := costHeaderRequest(largeToolOutput(2000))
+	resp, _, err := sendAnthropicRequest(gw.URL, upstream.url(), body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list struct {
+		Count    int                   `json:"count"`
+		Sessions []gateway.SessionInfo `json:"sessions"`
+	}
+	require.Eventually(t, func() bool {
+		r, err := http.Get(gw.URL + "/sessions")
+		require.NoError(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&list))
+		return list.Count == 1
+	}, 2*time.Second, 20*time.Millisecond)
+
+	s := list.Sessions[0]
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	assert.Equal(t, preemptive.ComputeSessionID(raw), s.ID)
+	assert.Equal(t, 1, s.Requests)
+	assert.Greater(t, s.ShadowRefs, 0, "compressed tool output left a shadow ref")
+	assert.Equal(t, gateway.SessionAuthAPIKey, s.Auth.Mode)
+	assert.False(t, s.Auth.Sticky)
+}
+
+func TestIntegration_Sessions_UnknownAndMethods(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	assert.Nil(t, getSession(t, gw.URL, "does-not-exist"))
+
+	resp, err := http.Post(gw.URL+"/sessions", "application/json", nil)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET", resp.Header.Get("Allow"))
+}
@@ -0,0 +1,103 @@
+// Session Persistence Integration Tests
+//
+// With session_persistence enabled, tool discovery sessions and cost tracking
+// are saved to SQLite at shutdown and restored by the next gateway, so a
+// restart mid-task keeps deferred tools searchable.
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+func persistentToolSearchConfig(path string) *config.Config {
+	cfg := toolSearchConfig()
+	cfg.SessionPersistence = config.SessionPersistenceConfig{Enabled: true, Path: path, Interval: time.Hour}
+	return cfg
+}
+
+func TestIntegration_SessionPersistence_RestoresToolSessionsAndCosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	tools := append(makeAnthropicToolDefs(12), map[string]interface{}{
+		"name":         "deploy_service",
+		"description":  "Deploy a service to the production cluster",
+		"input_schema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+	})
+	request := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"tools":      tools,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "Ship the release"}},
+	}
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+	sessionID := preemptive.ComputeSessionID(raw)
+
+	// First run: the request defers the tools.
+	gw := gateway.New(persistentToolSearchConfig(path))
+	srv := httptest.NewServer(gw.Handler())
+	resp, _, err := sendAnthropicRequest(srv.URL, mock.url(), request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var before *gateway.SessionInfo
+	require.Eventually(t, func() bool {
+		before = getSession(t, srv.URL, sessionID)
+		return before != nil && before.Tools != nil && before.Cost != nil
+	}, 2*time.Second, 20*time.Millisecond)
+	require.Contains(t, before.Tools.Deferred, "deploy_service")
+	srv.Close()
+	require.NoError(t, gw.Shutdown(context.Background()))
+
+	// Second run: the deferred tools are still searchable and spend carries over.
+	gw2 := gateway.New(persistentToolSearchConfig(path))
+	defer gw2.Shutdown(context.Background())
+	srv2 := httptest.NewServer(gw2.Handler())
+	defer srv2.Close()
+
+	text, isErr := mcpToolCall(t, srv2.URL, "search_tools", map[string]any{"query": "deploy production", "session_id": before.Tools.SessionID})
+	assert.False(t, isErr, text)
+	assert.Contains(t, text, "deploy_service")
+
+	after := getSession(t, srv2.URL, sessionID)
+	require.NotNil(t, after)
+	require.NotNil(t, after.Cost)
+	assert.Equal(t, before.Cost.InputTokens, after.Cost.InputTokens)
+}
+
+func TestIntegration_SessionPersistence_DefaultPathAndDisabled(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir, err := statedir.DefaultDir()
+	require.NoError(t, err)
+	dbPath := filepath.Join(dir, statedir.SessionsDB)
+
+	// Disabled: no database is created.
+	gw := gateway.New(toolSearchConfig())
+	require.NoError(t, gw.Shutdown(context.Background()))
+	_, err = os.Stat(dbPath)
+	assert.True(t, os.IsNotExist(err), "no sessions.db without session_persistence")
+
+	// Enabled without a path: the state directory is used.
+	gw = gateway.New(persistentToolSearchConfig(""))
+	require.NoError(t, gw.Shutdown(context.Background()))
+	_, err = os.Stat(dbPath)
+	assert.NoError(t, err)
+}
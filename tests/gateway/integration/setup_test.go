@@ -28,6 +28,7 @@ func init() {
 }
 
 func TestMain(m *testing.M) {
+	testkit.ServeTestPluginIfRequested()
 	godotenv.Load("../../../.env")
 	gateway.EnableLocalHostsForTesting()
 	os.Exit(m.Run())
@@ -0,0 +1,125 @@
+// Shadow Mode Integration Tests
+//
+// With pipes.mode shadow the upstream receives the request uncompressed,
+// while the pipes run in the background and shadow_eval.jsonl and GET /stats
+// record what they would have saved.
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func TestIntegration_ShadowMode_ForwardsOriginalAndLogsSavings(t *testing.T) {
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer mock.close()
+
+	path := filepath.Join(t.TempDir(), "shadow_eval.jsonl")
+	cfg := expandContextConfig()
+	cfg.Pipes.Mode = pipes.ModeShadow
+	cfg.Pipes.Shadow.LogBodies = true
+	cfg.Monitoring.TelemetryEnabled = true
+	cfg.Monitoring.ShadowEvalPath = path
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	output := largeToolOutput(1000)
+	reqBody := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "Summarize the log"},
+			{"role": "assistant", "content": []map[string]interface{}{
+				{"type": "tool_use", "id": "toolu_shadow_001", "name": "read_file", "input": map[string]string{"path": "app.log"}},
+			}},
+			{"role": "user", "content": []map[string]interface{}{
+				{"type": "tool_result", "tool_use_id": "toolu_shadow_001", "content": output},
+			}},
+		},
+	}
+	resp, _, err := sendAnthropicRequest(srv.URL, mock.url(), reqBody)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	reqs := mock.getRequests()
+	require.Len(t, reqs, 1)
+	var forwarded struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &forwarded))
+	require.Len(t, forwarded.Messages, 3)
+	var results []map[string]any
+	require.NoError(t, json.Unmarshal(forwarded.Messages[2].Content, &results))
+	assert.Equal(t, output, results[0]["content"], "upstream gets the original tool output")
+
+	var stats gateway.StatsResponse
+	require.Eventually(t, func() bool {
+		r, err := http.Get(srv.URL + "/stats")
+		if err != nil {
+			return false
+		}
+		defer r.Body.Close()
+		return json.NewDecoder(r.Body).Decode(&stats) == nil && stats.ShadowMode != nil && stats.ShadowMode.Evaluated == 1
+	}, 5*time.Second, 20*time.Millisecond, "background run should be counted")
+	assert.Equal(t, int64(1), stats.ShadowMode.Compressed)
+	assert.Positive(t, stats.ShadowMode.TokensSaved)
+	assert.Positive(t, stats.ShadowMode.EstimatedSavingsUSD)
+	assert.Zero(t, stats.Savings.TokensSaved, "nothing was saved for real")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, gw.Shutdown(ctx))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []monitoring.ShadowEvalEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e monitoring.ShadowEvalEntry
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "anthropic", e.Provider)
+	assert.Less(t, e.CompressedTokens, e.OriginalTokens)
+	assert.Equal(t, e.OriginalTokens-e.CompressedTokens, e.TokensSaved)
+	assert.Greater(t, e.CompressionRatio, 0.0)
+	require.Len(t, e.ToolOutputs, 1)
+	assert.Equal(t, "read_file", e.ToolOutputs[0].ToolName)
+	assert.Equal(t, "compressed", e.ToolOutputs[0].Status)
+	assert.NotEmpty(t, e.ToolOutputs[0].CompressedContent)
+	assert.NotContains(t, string(e.Body), strings.ReplaceAll(output[:200], "\n", `\n`), "logged body is the compressed request")
+}
+
+func TestIntegration_ShadowMode_OmittedFromStatsWhenEnforcing(t *testing.T) {
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	r, err := http.Get(gw.URL + "/stats")
+	require.NoError(t, err)
+	defer r.Body.Close()
+	var raw map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&raw))
+	assert.NotContains(t, raw, "shadow_mode")
+}
@@ -0,0 +1,103 @@
+// Slow Client Integration Tests
+//
+// A client that stops reading an SSE stream must not hold the gateway: its
+// writes are cut off at the per-write deadline and counted in /stats.
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// largeSSEStream is a ~32 MB Anthropic stream, more than loopback socket
+// buffers absorb.
+func largeSSEStream() []byte {
+	var b strings.Builder
+	b.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":10}}}\n\n")
+	delta := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"" + strings.Repeat("x", 1000) + "\"}}\n\n"
+	for b.Len() < 32<<20 {
+		b.WriteString(delta)
+	}
+	b.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	return []byte(b.String())
+}
+
+// startStalledStream sends a streaming request and never reads the response.
+func startStalledStream(t *testing.T, gwURL, targetURL string) net.Conn {
+	t.Helper()
+	body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"write a long story"}]}`
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gwURL, "http://"))
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(conn, "POST /v1/messages HTTP/1.1\r\nHost: gateway\r\nContent-Type: application/json\r\n"+
+		"x-api-key: sk-ant-test-key\r\nanthropic-version: 2023-06-01\r\nX-Target-URL: %s/v1/messages\r\n"+
+		"Content-Length: %d\r\n\r\n%s", targetURL, len(body), body)
+	require.NoError(t, err)
+	return conn
+}
+
+func waitSlowClientWriteTimeout(t *testing.T, gw *httptest.Server) {
+	t.Helper()
+	waitGatewayStats(t, gw, func(stats gateway.StatsResponse) bool {
+		return stats.Gateway.SlowClientWriteTimeouts > 0
+	}, "slow client write timeout not recorded")
+}
+
+func waitGatewayStats(t *testing.T, gw *httptest.Server, cond func(gateway.StatsResponse) bool, msg string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(gw.URL + "/stats")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		var stats gateway.StatsResponse
+		if json.NewDecoder(resp.Body).Decode(&stats) != nil {
+			return false
+		}
+		return cond(stats)
+	}, 15*time.Second, 50*time.Millisecond, msg)
+}
+
+func TestIntegration_SlowClient_WriteDeadline(t *testing.T) {
+	stream := largeSSEStream()
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return stream })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.StreamWriteTimeout = 200 * time.Millisecond
+	cfg.Server.StreamInterception = config.StreamInterceptionBuffered // Whole response flushed synchronously
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	conn := startStalledStream(t, gw.URL, upstream.url())
+	defer conn.Close()
+	waitSlowClientWriteTimeout(t, gw)
+}
+
+func TestIntegration_SlowClient_OptimisticRelayCloses(t *testing.T) {
+	stream := largeSSEStream()
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return stream })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Server.StreamWriteTimeout = 200 * time.Millisecond
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	conn := startStalledStream(t, gw.URL, upstream.url())
+	defer conn.Close()
+	waitGatewayStats(t, gw, func(stats gateway.StatsResponse) bool {
+		return stats.Gateway.SlowClientClosed > 0 || stats.Gateway.SlowClientWriteTimeouts > 0
+	}, "stalled client not cut off")
+}
@@ -0,0 +1,78 @@
+// State Snapshot Integration Tests
+//
+// GET /admin/state on one gateway and POST /admin/state on another moves
+// cost sessions, counters and shadow content to the standby instance.
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func exportState(t *testing.T, gatewayURL string) []byte {
+	t.Helper()
+	resp, err := http.Get(gatewayURL + "/admin/state")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return body
+}
+
+func TestIntegration_StateSnapshot_RestoreOnStandby(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	primary := createGateway(passthroughConfig())
+	defer primary.Close()
+	standby := createGateway(passthroughConfig())
+	defer standby.Close()
+
+	body := map[string]interface{}{
+		"model":      "claude-3-5-sonnet-20241022",
+		"max_tokens": 16,
+		"messages":   []map[string]interface{}{{"role": "user", "content": "snapshot me"}},
+	}
+	resp, _, err := sendAnthropicRequest(primary.URL, upstream.url(), body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var before gateway.StateSnapshot
+	raw := exportState(t, primary.URL)
+	require.NoError(t, json.Unmarshal(raw, &before))
+	assert.Equal(t, gateway.StateSnapshotVersion, before.Version)
+	require.NotNil(t, before.Costs)
+	assert.Greater(t, before.Costs.GlobalCost, 0.0)
+
+	resp, err = http.Post(standby.URL+"/admin/state", "application/json", bytes.NewReader(raw))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var res gateway.StateRestoreResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	assert.Equal(t, 1, res.CostSessions)
+
+	var after gateway.StateSnapshot
+	require.NoError(t, json.Unmarshal(exportState(t, standby.URL), &after))
+	assert.InDelta(t, before.Costs.GlobalCost, after.Costs.GlobalCost, 1e-9)
+	assert.JSONEq(t, string(before.Costs.Sessions), string(after.Costs.Sessions))
+}
+
+func TestIntegration_StateSnapshot_RejectsUnknownVersion(t *testing.T) {
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	resp, err := http.Post(gw.URL+"/admin/state", "application/json", bytes.NewReader([]byte(`{"version":99}`)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
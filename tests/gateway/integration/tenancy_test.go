@@ -0,0 +1,177 @@
+// Multi-Tenant Integration Tests
+//
+// With tenancy enabled, each request runs as the tenant matched by its key
+// prefix or X-Tenant header: the tenant's provider key goes upstream, its
+// pipe overrides apply, its budget is enforced and its sessions are kept
+// apart from other tenants'. Requests without an acceptable tenant are
+// rejected before they are forwarded.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// researchKeyHash is the SHA-256 of gw-research-alice, the research tenant's key.
+const researchKeyHash = "43dc4555e6738470684e96b74b4b7c264f78f5f8dd18d0220c40f4ece7ee111c"
+
+func tenancyConfig() *config.Config {
+	cfg := passthroughConfig()
+	cfg.Tenancy = config.TenancyConfig{
+		Enabled:  true,
+		Required: true,
+		Tenants: []tenancy.Tenant{
+			{
+				Name:         "research",
+				KeyPrefixes:  []string{"gw-research-"},
+				KeyHashes:    []string{researchKeyHash},
+				ProviderKeys: map[string]string{"anthropic": "sk-ant-research"},
+				Pipes: map[string]any{"tool_discovery": map[string]any{
+					"enabled":                true,
+					"strategy":               "tool-search",
+					"fallback_strategy":      "passthrough",
+					"enable_search_fallback": true,
+					"max_search_results":     5,
+				}},
+			},
+			{Name: "support"},
+		},
+	}
+	return cfg
+}
+
+// sendTenantRequest posts body to /v1/messages with the given headers.
+func sendTenantRequest(t *testing.T, gwURL, upstreamURL, body string, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("X-Target-URL", upstreamURL+"/v1/messages")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, respBody
+}
+
+const tenantPrompt = `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"summarize the release notes"}]}`
+
+func TestIntegration_Tenancy_ProviderKeyAndRejections(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	gw := createGateway(tenancyConfig())
+	defer gw.Close()
+
+	// Key prefix: the tenant's provider key replaces the client's.
+	resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), tenantPrompt, map[string]string{"x-api-key": "gw-research-alice"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reqs := upstream.getRequests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "sk-ant-research", reqs[0].Headers.Get("x-api-key"))
+
+	// Header-selected tenant without provider keys: the client's key is kept.
+	resp, _ = sendTenantRequest(t, gw.URL, upstream.url(), tenantPrompt, map[string]string{"x-api-key": "sk-ant-own", tenancy.HeaderTenant: "support"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reqs = upstream.getRequests()
+	require.Len(t, reqs, 2)
+	assert.Equal(t, "sk-ant-own", reqs[1].Headers.Get("x-api-key"))
+
+	rejected := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"no tenant", map[string]string{"x-api-key": "sk-ant-own"}, http.StatusUnauthorized},
+		{"unknown tenant", map[string]string{"x-api-key": "sk-ant-own", tenancy.HeaderTenant: "nope"}, http.StatusForbidden},
+		{"key-only tenant by header", map[string]string{"x-api-key": "sk-ant-own", tenancy.HeaderTenant: "research"}, http.StatusForbidden},
+		{"key of another tenant", map[string]string{"x-api-key": "gw-research-alice", tenancy.HeaderTenant: "support"}, http.StatusForbidden},
+		{"made-up key with the tenant prefix", map[string]string{"x-api-key": "gw-research-anything"}, http.StatusUnauthorized},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), tenantPrompt, tt.header)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+	assert.Len(t, upstream.getRequests(), 2, "rejected requests are not forwarded")
+}
+
+func TestIntegration_Tenancy_PipesOverrideAndSeparateSessions(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+	gw := createGateway(tenancyConfig())
+	defer gw.Close()
+
+	request := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 100,
+		"tools":      makeAnthropicToolDefs(13),
+		"messages":   []map[string]interface{}{{"role": "user", "content": "Ship the release"}},
+	}
+	raw, err := json.Marshal(request)
+	require.NoError(t, err)
+	sessionID := preemptive.ComputeSessionID(raw)
+
+	resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), string(raw), map[string]string{"x-api-key": "gw-research-alice"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = sendTenantRequest(t, gw.URL, upstream.url(), string(raw), map[string]string{"x-api-key": "sk-ant-own", tenancy.HeaderTenant: "support"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The same conversation is two sessions, one per tenant.
+	require.Eventually(t, func() bool {
+		return getSession(t, gw.URL, "research:"+sessionID) != nil && getSession(t, gw.URL, "support:"+sessionID) != nil
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Nil(t, getSession(t, gw.URL, sessionID))
+
+	// Only research enables tool discovery.
+	research := getSession(t, gw.URL, "research:"+sessionID)
+	require.NotNil(t, research.Tools)
+	assert.True(t, strings.HasPrefix(research.Tools.SessionID, "research:"))
+	assert.NotEmpty(t, research.Tools.Deferred)
+	assert.Nil(t, getSession(t, gw.URL, "support:"+sessionID).Tools)
+}
+
+func TestIntegration_Tenancy_BudgetPerTenant(t *testing.T) {
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer upstream.close()
+
+	cfg := tenancyConfig()
+	cfg.CostControl.Enabled = true
+	cfg.CostControl.Scopes = []costcontrol.BudgetScope{
+		{Name: config.TenantScopeName, Key: costcontrol.ScopeKeyTenant, Caps: map[string]float64{"support": 0.0001}},
+	}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	send := func(header map[string]string, prompt string) *http.Response {
+		body := `{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"` + prompt + `"}]}`
+		resp, _ := sendTenantRequest(t, gw.URL, upstream.url(), body, header)
+		return resp
+	}
+	supportHeader := map[string]string{"x-api-key": "sk-ant-own", tenancy.HeaderTenant: "support"}
+
+	require.Equal(t, http.StatusOK, send(supportHeader, "summarize the release notes").StatusCode)
+	require.Eventually(t, func() bool {
+		resp := send(supportHeader, "draft the changelog")
+		return resp.Header.Get("X-Budget-Exceeded") == "true" && resp.Header.Get("X-Budget-Scope-Value") == "support"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	resp := send(map[string]string{"x-api-key": "gw-research-alice"}, "draft the changelog")
+	assert.Empty(t, resp.Header.Get("X-Budget-Exceeded"), "other tenants are unaffected")
+}
@@ -0,0 +1,150 @@
+// Tracing Integration Tests
+//
+// monitoring.tracing exports one span per request stage over OTLP/HTTP. A
+// client traceparent makes the gateway's request span a child of the agent's
+// span, so both end up in one trace.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// otlpReceiver collects spans posted to /v1/traces.
+type otlpReceiver struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (o *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	o.mu.Lock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			o.spans = append(o.spans, ss.GetSpans()...)
+		}
+	}
+	o.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(nil)
+}
+
+func (o *otlpReceiver) byName() map[string]*tracepb.Span {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make(map[string]*tracepb.Span, len(o.spans))
+	for _, s := range o.spans {
+		out[s.GetName()] = s
+	}
+	return out
+}
+
+func TestIntegration_Tracing_SpansJoinClientTrace(t *testing.T) {
+	receiver := &otlpReceiver{}
+	collector := httptest.NewServer(receiver)
+	defer collector.Close()
+
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("done") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Monitoring.Tracing = config.TracingConfig{Enabled: true, Endpoint: collector.URL}
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", bytes.NewReader([]byte(
+		`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set(gateway.HeaderTargetURL, upstream.url()+"/v1/messages")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Shutdown exports the batched spans.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, gw.Shutdown(ctx))
+
+	spans := receiver.byName()
+	root := spans["gateway.request"]
+	require.NotNil(t, root, "request span exported")
+	assert.Equal(t, traceID, hex.EncodeToString(root.GetTraceId()))
+	assert.Equal(t, parentID, hex.EncodeToString(root.GetParentSpanId()), "request span is a child of the client's span")
+
+	for _, name := range []string{"gateway.compression", "gateway.phantom_loop.iteration", "gateway.upstream_forward"} {
+		s := spans[name]
+		require.NotNil(t, s, name)
+		assert.Equal(t, traceID, hex.EncodeToString(s.GetTraceId()), name)
+	}
+	assert.Equal(t, root.GetSpanId(), spans["gateway.compression"].GetParentSpanId())
+	assert.Equal(t, spans["gateway.phantom_loop.iteration"].GetSpanId(), spans["gateway.upstream_forward"].GetParentSpanId(),
+		"each forward nests under its phantom loop iteration")
+}
+
+func TestIntegration_Tracing_DisabledExportsNothing(t *testing.T) {
+	receiver := &otlpReceiver{}
+	collector := httptest.NewServer(receiver)
+	defer collector.Close()
+
+	upstream := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("done") })
+	defer upstream.close()
+
+	cfg := passthroughConfig()
+	cfg.Monitoring.Tracing = config.TracingConfig{Enabled: false, Endpoint: collector.URL}
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, _, err := sendAnthropicRequest(srv.URL, upstream.url()+"/v1/messages", map[string]interface{}{
+		"model": "claude-sonnet-4-5", "max_tokens": 16,
+		"messages": []map[string]interface{}{{"role": "user", "content": "hi"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, gw.Shutdown(ctx))
+	assert.Empty(t, receiver.byName())
+}
+
+func TestTracingConfig_Validate(t *testing.T) {
+	assert.NoError(t, config.TracingConfig{Enabled: true}.Validate(), "endpoint defaults from the environment")
+	assert.Error(t, config.TracingConfig{Enabled: true, Endpoint: "localhost:4318"}.Validate())
+	assert.Error(t, config.TracingConfig{Enabled: true, SampleRatio: 1.5}.Validate())
+	assert.NoError(t, config.TracingConfig{Endpoint: "bad", SampleRatio: 7}.Validate(), "ignored while disabled")
+}
@@ -0,0 +1,156 @@
+// Upstream Circuit Breaker Integration Tests
+//
+// upstream_circuit_breaker opens a circuit per upstream host after repeated
+// 5xx responses or connection failures. While open, requests to that host
+// fail fast with 502 without reaching it; after open_duration a single probe
+// decides whether the circuit closes or reopens. /health reports each host.
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+)
+
+// flakyUpstream answers 500 while failing is set, and counts the requests it sees.
+type flakyUpstream struct {
+	*httptest.Server
+	failing atomic.Bool
+	hits    atomic.Int32
+}
+
+func newFlakyUpstream() *flakyUpstream {
+	u := &flakyUpstream{}
+	u.failing.Store(true)
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		u.hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if u.failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"outage"}}`))
+			return
+		}
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	return u
+}
+
+func circuitHealth(t *testing.T, gwURL string) map[string]struct {
+	State    string `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+} {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "open circuits do not fail the gateway health check")
+	var health struct {
+		Circuits map[string]struct {
+			State    string `json:"state"`
+			Failures int    `json:"consecutive_failures"`
+		} `json:"upstream_circuits"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	return health.Circuits
+}
+
+func TestIntegration_UpstreamCircuit_OpensFailsFastAndRecovers(t *testing.T) {
+	upstream := newFlakyUpstream()
+	defer upstream.Close()
+	host := mustHost(t, upstream.URL)
+
+	cfg := passthroughConfig()
+	cfg.UpstreamCircuitBreaker = config.UpstreamCircuitBreakerConfig{Enabled: true, MaxFailures: 3, OpenDuration: 300 * time.Millisecond}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusInternalServerError, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	}
+	assert.Equal(t, "open", circuitHealth(t, gw.URL)[host].State)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusBadGateway, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "open circuit fails fast")
+	assert.EqualValues(t, 3, upstream.hits.Load(), "open circuit does not reach the upstream")
+
+	upstream.failing.Store(false)
+	time.Sleep(350 * time.Millisecond)
+	assert.Equal(t, "half_open", circuitHealth(t, gw.URL)[host].State)
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode, "probe succeeds")
+
+	status := circuitHealth(t, gw.URL)[host]
+	assert.Equal(t, "closed", status.State)
+	assert.Zero(t, status.Failures)
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+}
+
+func TestIntegration_UpstreamCircuit_FailedProbeReopens(t *testing.T) {
+	upstream := newFlakyUpstream()
+	defer upstream.Close()
+	host := mustHost(t, upstream.URL)
+
+	cfg := passthroughConfig()
+	cfg.UpstreamCircuitBreaker = config.UpstreamCircuitBreakerConfig{Enabled: true, MaxFailures: 1, OpenDuration: 200 * time.Millisecond}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, http.StatusInternalServerError, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode, "probe reaches the upstream")
+	assert.Equal(t, "open", circuitHealth(t, gw.URL)[host].State, "failed probe reopens")
+	assert.Equal(t, http.StatusBadGateway, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	assert.EqualValues(t, 2, upstream.hits.Load())
+}
+
+func TestIntegration_UpstreamCircuit_PerHost(t *testing.T) {
+	down := newFlakyUpstream()
+	defer down.Close()
+	up := newMockLLM(func(_ []byte, _ int) []byte { return anthropicTextResponse("ok") })
+	defer up.close()
+
+	cfg := passthroughConfig()
+	cfg.UpstreamCircuitBreaker = config.UpstreamCircuitBreakerConfig{Enabled: true, MaxFailures: 1, OpenDuration: time.Minute}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	rateLimitedPost(t, gw.URL, down.URL, nil)
+	assert.Equal(t, http.StatusBadGateway, rateLimitedPost(t, gw.URL, down.URL, nil).StatusCode)
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, up.url(), nil).StatusCode, "other hosts are unaffected")
+}
+
+func TestIntegration_UpstreamCircuit_DisabledByDefault(t *testing.T) {
+	upstream := newFlakyUpstream()
+	defer upstream.Close()
+
+	gw := createGateway(passthroughConfig())
+	defer gw.Close()
+
+	for i := 0; i < 7; i++ {
+		assert.Equal(t, http.StatusInternalServerError, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	}
+	assert.EqualValues(t, 7, upstream.hits.Load())
+	assert.Nil(t, circuitHealth(t, gw.URL))
+}
+
+func TestUpstreamCircuitBreakerConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&config.UpstreamCircuitBreakerConfig{Enabled: true}).Validate())
+	assert.Error(t, (&config.UpstreamCircuitBreakerConfig{MaxFailures: -1}).Validate())
+	assert.Error(t, (&config.UpstreamCircuitBreakerConfig{OpenDuration: -time.Second}).Validate())
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u.Host
+}
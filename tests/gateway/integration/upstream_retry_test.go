@@ -0,0 +1,206 @@
+// Upstream Retry Integration Tests
+//
+// upstream_retry retries forwards that fail with a retry_on status or a
+// connection error, before anything reached the client. Retry-After sets the
+// wait, and one longer than max_backoff returns the response unretried.
+// Retries are counted in /stats. A model still overloaded after the retries
+// is swapped for its model_fallbacks entry, announced by X-Model-Fallback.
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// overloadedUpstream answers status for the first failures requests, then succeeds.
+func overloadedUpstream(failures int32, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if hits.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	return srv, &hits
+}
+
+func retryConfig() *config.Config {
+	cfg := passthroughConfig()
+	cfg.UpstreamRetry = config.UpstreamRetryConfig{Enabled: true, MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}
+	return cfg
+}
+
+func upstreamRetryStats(t *testing.T, gwURL string) map[string]int64 {
+	t.Helper()
+	resp, err := http.Get(gwURL + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var stats struct {
+		UpstreamRetries map[string]int64 `json:"upstream_retries"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	return stats.UpstreamRetries
+}
+
+func TestIntegration_UpstreamRetry_RetriesOverloaded(t *testing.T) {
+	upstream, hits := overloadedUpstream(2, 529, "")
+	defer upstream.Close()
+	gw := createGateway(retryConfig())
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	assert.EqualValues(t, 3, hits.Load())
+	assert.Equal(t, map[string]int64{"529": 2}, upstreamRetryStats(t, gw.URL))
+}
+
+func TestIntegration_UpstreamRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	upstream, hits := overloadedUpstream(10, http.StatusServiceUnavailable, "")
+	defer upstream.Close()
+	gw := createGateway(retryConfig())
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	assert.EqualValues(t, 3, hits.Load())
+}
+
+func TestIntegration_UpstreamRetry_HonorsRetryAfter(t *testing.T) {
+	upstream, hits := overloadedUpstream(1, http.StatusTooManyRequests, "1")
+	defer upstream.Close()
+	cfg := retryConfig()
+	cfg.UpstreamRetry.MaxBackoff = 2 * time.Second
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	start := time.Now()
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "waits out Retry-After")
+	assert.EqualValues(t, 2, hits.Load())
+}
+
+func TestIntegration_UpstreamRetry_LongRetryAfterIsReturned(t *testing.T) {
+	upstream, hits := overloadedUpstream(1, http.StatusTooManyRequests, "60")
+	defer upstream.Close()
+	gw := createGateway(retryConfig())
+	defer gw.Close()
+
+	resp := rateLimitedPost(t, gw.URL, upstream.URL, nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.EqualValues(t, 1, hits.Load())
+}
+
+func TestIntegration_UpstreamRetry_SkipsOtherStatusesAndDisabled(t *testing.T) {
+	upstream, hits := overloadedUpstream(1, http.StatusInternalServerError, "")
+	defer upstream.Close()
+	gw := createGateway(retryConfig())
+	defer gw.Close()
+	assert.Equal(t, http.StatusInternalServerError, rateLimitedPost(t, gw.URL, upstream.URL, nil).StatusCode, "500 is not in retry_on")
+	assert.EqualValues(t, 1, hits.Load())
+
+	overloaded, overloadedHits := overloadedUpstream(1, 529, "")
+	defer overloaded.Close()
+	off := createGateway(passthroughConfig())
+	defer off.Close()
+	assert.Equal(t, 529, rateLimitedPost(t, off.URL, overloaded.URL, nil).StatusCode)
+	assert.EqualValues(t, 1, overloadedHits.Load())
+}
+
+func TestIntegration_UpstreamRetry_ConnectionError(t *testing.T) {
+	upstream, hits := overloadedUpstream(0, 0, "")
+	defer upstream.Close()
+	var drops atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if drops.Add(1) == 1 {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close() // Reset before any response
+			return
+		}
+		upstream.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer flaky.Close()
+	gw := createGateway(retryConfig())
+	defer gw.Close()
+
+	assert.Equal(t, http.StatusOK, rateLimitedPost(t, gw.URL, flaky.URL, nil).StatusCode)
+	assert.EqualValues(t, 1, hits.Load())
+	assert.Equal(t, map[string]int64{"network": 1}, upstreamRetryStats(t, gw.URL))
+}
+
+// modelOverloadedUpstream answers 529 for overloaded and 200 for any other
+// model, recording the model of each request.
+func modelOverloadedUpstream(overloaded string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var models []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.Unmarshal(body, &req)
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if req.Model == overloaded {
+			w.WriteHeader(529)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		_, _ = w.Write(anthropicTextResponse("ok"))
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), models...)
+	}
+}
+
+func TestIntegration_UpstreamRetry_ModelFallback(t *testing.T) {
+	upstream, models := modelOverloadedUpstream("claude-sonnet-4-5")
+	defer upstream.Close()
+	cfg := retryConfig()
+	cfg.UpstreamRetry.MaxAttempts = 2
+	cfg.UpstreamRetry.ModelFallbacks = []config.ModelFallbackRule{{Model: "claude-sonnet-*", Fallback: "claude-haiku-4-5"}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := rateLimitedPost(t, gw.URL, upstream.URL, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "claude-haiku-4-5", resp.Header.Get(gateway.HeaderModelFallback))
+	assert.Equal(t, []string{"claude-sonnet-4-5", "claude-sonnet-4-5", "claude-haiku-4-5"}, models())
+	assert.Equal(t, map[string]int64{"529": 1, "model_fallback": 1}, upstreamRetryStats(t, gw.URL))
+}
+
+func TestIntegration_UpstreamRetry_NoFallbackWithoutRule(t *testing.T) {
+	upstream, models := modelOverloadedUpstream("claude-sonnet-4-5")
+	defer upstream.Close()
+	cfg := retryConfig()
+	cfg.UpstreamRetry.MaxAttempts = 1
+	cfg.UpstreamRetry.ModelFallbacks = []config.ModelFallbackRule{{Model: "claude-opus-*", Fallback: "claude-sonnet-4-5"}}
+	gw := createGateway(cfg)
+	defer gw.Close()
+
+	resp := rateLimitedPost(t, gw.URL, upstream.URL, nil)
+	assert.Equal(t, 529, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(gateway.HeaderModelFallback))
+	assert.Equal(t, []string{"claude-sonnet-4-5"}, models())
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+type refusedErr struct{}
+
+func (refusedErr) Error() string   { return "connection refused" }
+func (refusedErr) Timeout() bool   { return false }
+func (refusedErr) Temporary() bool { return false }
+
+func TestClassifyUpstreamError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      monitoring.ErrorCode
+		retryable bool
+	}{
+		{"nil", nil, "", false},
+		{"deadline", fmt.Errorf("upstream: %w", context.DeadlineExceeded), monitoring.ErrorCodeUpstreamTimeout, true},
+		{"net timeout", fmt.Errorf("dial: %w", timeoutErr{}), monitoring.ErrorCodeUpstreamTimeout, true},
+		{"net refused", fmt.Errorf("dial: %w", refusedErr{}), monitoring.ErrorCodeUpstreamUnavailable, true},
+		{"other", errors.New("boom"), monitoring.ErrorCodePhantomLoopFailed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, retryable := gateway.ClassifyUpstreamError(tt.err, monitoring.ErrorCodePhantomLoopFailed)
+			assert.Equal(t, tt.code, code)
+			assert.Equal(t, tt.retryable, retryable)
+		})
+	}
+}
+
+func TestGateway_Stats_CountsInvalidRequests(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	// GET on the proxy route is rejected before forwarding.
+	resp, err := http.Get(srv.URL + "/v1/messages")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Unsupported request format.
+	resp, err = http.Post(srv.URL+"/v1/unknown", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var stats gateway.StatsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.GreaterOrEqual(t, stats.Errors["invalid_request"], int64(1))
+}
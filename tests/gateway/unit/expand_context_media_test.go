@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/pipes/media"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+func TestExpandContext_MediaRef_RestoresBlockInPlace(t *testing.T) {
+	const ref = "media_0123456789abcdef0123456789abcdef"
+	raw := `{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}`
+	st := store.NewMemoryStore(time.Minute)
+	t.Cleanup(func() { st.Close() })
+	require.NoError(t, st.Set(ref, raw))
+
+	placeholder, err := json.Marshal("[image omitted: image/png, 8 B — call " + media.Marker(ref) + " to restore it]")
+	require.NoError(t, err)
+	body := []byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":[` +
+		`{"type":"text","text":` + string(placeholder) + `},` +
+		`{"type":"text","text":"What changed?"}]}]}`)
+
+	h := gateway.NewExpandContextHandler(st)
+	result := h.HandleCalls([]gateway.PhantomToolCall{{
+		ToolUseID: "toolu_1",
+		ToolName:  gateway.ExpandContextToolName,
+		Input:     map[string]any{"id": ref},
+	}}, adapters.NewAnthropicAdapter(), body)
+
+	require.Len(t, result.ToolResults, 1)
+	text := result.ToolResults[0]["content"].([]any)[0].(map[string]any)["content"].(string)
+	assert.Contains(t, text, "restored in place")
+	assert.NotContains(t, text, "iVBORw0KGgo=", "the block is not pasted into the tool result")
+
+	require.NotNil(t, result.ModifyRequest)
+	modified, err := result.ModifyRequest(body)
+	require.NoError(t, err)
+	assert.JSONEq(t, raw, gjson.GetBytes(modified, "messages.0.content.0").Raw)
+	assert.Equal(t, "What changed?", gjson.GetBytes(modified, "messages.0.content.1.text").String())
+}
@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/gateway"
+	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// numberedLines returns n lines "line 001\n", "line 002\n", ... (9 bytes each).
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %03d\n", i)
+	}
+	return b.String()
+}
+
+func pagingStore(t *testing.T, content string) store.Store {
+	t.Helper()
+	st := store.NewMemoryStore(time.Minute)
+	t.Cleanup(func() { st.Close() })
+	require.NoError(t, st.Set("shadow_big", content))
+	return st
+}
+
+// expand runs one expand_context call and returns the tool_result text.
+func expand(t *testing.T, h *gateway.ExpandContextHandler, input map[string]any) string {
+	t.Helper()
+	result := h.HandleCalls([]gateway.PhantomToolCall{{
+		ToolUseID: "toolu_1",
+		ToolName:  gateway.ExpandContextToolName,
+		Input:     input,
+	}}, adapters.NewAnthropicAdapter(), nil)
+	require.Len(t, result.ToolResults, 1)
+	blocks := result.ToolResults[0]["content"].([]any)
+	return blocks[0].(map[string]any)["content"].(string)
+}
+
+// =============================================================================
+// PAGING
+// =============================================================================
+
+func TestExpandContext_NoPaging_ReturnsWholeContent(t *testing.T) {
+	content := numberedLines(100)
+	h := gateway.NewExpandContextHandler(pagingStore(t, content))
+	assert.Equal(t, content, expand(t, h, map[string]any{"id": "shadow_big"}))
+}
+
+func TestExpandContext_PageSize_ReturnsFirstPageWithNextCall(t *testing.T) {
+	content := numberedLines(100) // 900 bytes
+	h := gateway.NewExpandContextHandler(pagingStore(t, content)).WithPaging(95, 0)
+
+	text := expand(t, h, map[string]any{"id": "shadow_big"})
+	assert.True(t, strings.HasPrefix(text, numberedLines(10)+"\n[expand_context: returned bytes 0-90 of 900."), "page ends at a line break: %s", text)
+	assert.Contains(t, text, `call expand_context with {"id":"shadow_big","offset":90}`)
+
+	// Next page, as instructed by the footer.
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": float64(90)})
+	assert.True(t, strings.HasPrefix(text, "line 011\n"), text)
+	assert.Contains(t, text, "returned bytes 90-180 of 900")
+
+	// Last page has no continuation.
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": "850"})
+	assert.Contains(t, text, "returned bytes 850-900 of 900.]")
+	assert.NotContains(t, text, "To read more")
+}
+
+func TestExpandContext_LineRangeAndLimit(t *testing.T) {
+	h := gateway.NewExpandContextHandler(pagingStore(t, numberedLines(100)))
+
+	text := expand(t, h, map[string]any{"id": "shadow_big", "start_line": float64(5), "end_line": float64(7)})
+	assert.Equal(t, "line 005\nline 006\nline 007\n", text, "a whole range needs no footer")
+
+	text = expand(t, h, map[string]any{"id": "shadow_big", "start_line": float64(5), "end_line": float64(20), "limit": float64(18)})
+	assert.True(t, strings.HasPrefix(text, "line 005\nline 006\n\n"), text)
+	assert.Contains(t, text, "of lines 5-20")
+	assert.Contains(t, text, `{"id":"shadow_big","offset":18,"limit":18,"start_line":5,"end_line":20}`)
+
+	text = expand(t, h, map[string]any{"id": "shadow_big", "start_line": float64(200)})
+	assert.Contains(t, text, "past the end of the content, which has 100 lines")
+
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": float64(5000)})
+	assert.Contains(t, text, "past the end of the content (900 bytes)")
+}
+
+func TestExpandContext_Budget(t *testing.T) {
+	h := gateway.NewExpandContextHandler(pagingStore(t, numberedLines(100))).WithPaging(0, 150)
+
+	text := expand(t, h, map[string]any{"id": "shadow_big"})
+	assert.Contains(t, text, "returned bytes 0-144 of 900")
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": float64(144)})
+	assert.True(t, strings.HasPrefix(text, "line 0\n[expand_context: returned bytes 144-150 of 900."), "only 6 bytes left: %s", text)
+
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": float64(150)})
+	assert.Contains(t, text, "budget for this request (150 bytes) is used up")
+
+	h.ResetExpandedIDs()
+	text = expand(t, h, map[string]any{"id": "shadow_big", "offset": float64(150)})
+	assert.True(t, strings.HasPrefix(text, "17\nline 018\n"), "a new request has a new budget: %s", text)
+}
+
+func TestExpandContext_DuplicatePageSkipped(t *testing.T) {
+	h := gateway.NewExpandContextHandler(pagingStore(t, numberedLines(100))).WithPaging(90, 0)
+	expand(t, h, map[string]any{"id": "shadow_big"})
+
+	result := h.HandleCalls([]gateway.PhantomToolCall{{
+		ToolUseID: "toolu_2",
+		ToolName:  gateway.ExpandContextToolName,
+		Input:     map[string]any{"id": "shadow_big"},
+	}}, adapters.NewAnthropicAdapter(), nil)
+	assert.True(t, result.StopLoop, "same page twice is a loop")
+}
+
+func TestExpandContext_UTF8Boundary(t *testing.T) {
+	h := gateway.NewExpandContextHandler(pagingStore(t, strings.Repeat("é", 50))).WithPaging(7, 0)
+	text := expand(t, h, map[string]any{"id": "shadow_big"})
+	assert.True(t, strings.HasPrefix(text, "ééé\n"), text)
+	assert.Contains(t, text, `"offset":6`)
+}
+
+// =============================================================================
+// STREAMING
+// =============================================================================
+
+func TestStreamBuffer_CapturesPagingArguments(t *testing.T) {
+	buffer := tooloutput.NewStreamBuffer()
+	for _, chunk := range []string{
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"expand_context","arguments":""}}]}}]}` + "\n\n",
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"id\":\"shadow_big\","}}]}}]}` + "\n\n",
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"offset\":90}"}}]}}]}` + "\n\n",
+	} {
+		_, err := buffer.ProcessChunk([]byte(chunk))
+		require.NoError(t, err)
+	}
+
+	calls := buffer.GetSuppressedCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "shadow_big", calls[0].ShadowID)
+	assert.Equal(t, map[string]any{"id": "shadow_big", "offset": float64(90)}, calls[0].Arguments())
+	assert.Equal(t, map[string]any{"id": "shadow_x"}, tooloutput.ExpandContextCall{ShadowID: "shadow_x"}.Arguments())
+}
+
+// =============================================================================
+// MISSES
+// =============================================================================
+
+func TestExpandContext_MissReportsEviction(t *testing.T) {
+	st := store.NewMemoryStoreWithDualTTL(time.Hour, time.Hour).WithLimits(1, 0)
+	defer st.Close()
+	require.NoError(t, st.Set("shadow_old", "old output"))
+	require.NoError(t, st.Set("shadow_new", "new output"))
+
+	h := gateway.NewExpandContextHandler(st)
+	text := expand(t, h, map[string]any{"id": "shadow_old"})
+	assert.Contains(t, text, "evicted to keep the shadow store within its size limit")
+	assert.Equal(t, int64(1), st.EvictionStats().MissEvicted)
+}
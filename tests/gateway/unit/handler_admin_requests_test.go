@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestGateway_AdminRequests_ListEmpty(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/admin/requests")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got struct {
+		Count    int                       `json:"count"`
+		Requests []gateway.InflightRequest `json:"requests"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Zero(t, got.Count)
+	assert.Empty(t, got.Requests)
+}
+
+func TestGateway_AdminRequests_CancelUnknown(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/admin/requests/nope", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGateway_AdminRequests_MethodNotAllowed(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/admin/requests", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET", resp.Header.Get("Allow"))
+
+	resp, err = http.Get(srv.URL + "/admin/requests/abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "DELETE", resp.Header.Get("Allow"))
+}
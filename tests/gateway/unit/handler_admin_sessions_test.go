@@ -0,0 +1,66 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+func TestGateway_AdminSessions_ListsStores(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/admin/sessions")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got struct {
+		Stores []sessionstore.StoreStats `json:"stores"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	names := make([]string, 0, len(got.Stores))
+	for _, s := range got.Stores {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"tool_sessions", "branches", "auth_fallback", "cost_sessions", "preemptive", "response_chains", "assistant_runs", "message_batches"}, names)
+}
+
+func TestGateway_AdminSessions_ExpireUnknown(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/admin/sessions/nope", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGateway_AdminSessions_MethodNotAllowed(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/admin/sessions", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET", resp.Header.Get("Allow"))
+}
@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestGateway_ContextEstimate(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	body := []byte(`{"model":"claude-sonnet-4-5","max_tokens":1024,"messages":[{"role":"user","content":"Summarize the repository layout"}]}`)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/context/estimate", bytes.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var est gateway.ContextEstimate
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&est))
+	assert.Equal(t, "anthropic", est.Provider)
+	assert.Equal(t, "claude-sonnet-4-5", est.Model)
+	assert.Positive(t, est.Tokens.Estimated)
+	assert.LessOrEqual(t, est.Tokens.AfterCompression, est.Tokens.Estimated)
+	assert.Equal(t, 200000, est.Window.MaxTokens)
+	assert.Equal(t, 136000, est.Window.EffectiveMax)
+	assert.Equal(t, est.Window.EffectiveMax-est.Tokens.AfterCompression, est.HeadroomTokens)
+	assert.NotEmpty(t, est.Session.ID)
+	assert.False(t, est.Session.Tracked, "no usage recorded yet")
+}
+
+func TestGateway_ContextEstimate_RequiresBody(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/context/estimate")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/context/estimate", nil)
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestGateway_EffectiveConfig_GET(t *testing.T) {
+	cfg := edgeCaseConfig()
+	cfg.Providers = config.ProvidersConfig{
+		"anthropic": {ProviderAuth: "sk-ant-REDACTED", Model: "claude-haiku-4-5"},
+	}
+	gw := gateway.New(cfg)
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/config/effective")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), "sk-ant-REDACTED")
+
+	var eff config.EffectiveConfig
+	require.NoError(t, json.Unmarshal(body, &eff))
+	assert.Equal(t, 18080, eff.Listeners.Proxy)
+	assert.Equal(t, config.Redacted, eff.Providers["anthropic"].APIKey)
+}
+
+func TestGateway_EffectiveConfig_MethodNotAllowed(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/config/effective", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,35 @@
+package unit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestGateway_Metrics_PrometheusFormat(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+	assert.Contains(t, text, "# TYPE context_gateway_requests_total counter")
+	assert.Contains(t, text, "# TYPE context_gateway_stream_first_token_seconds summary")
+	assert.Contains(t, text, `context_gateway_stream_first_byte_seconds{quantile="0.99"} 0`)
+	assert.Contains(t, text, "context_gateway_stream_duration_seconds_count 0")
+}
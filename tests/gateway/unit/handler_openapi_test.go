@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func openAPIPaths(t *testing.T) map[string]map[string]any {
+	t.Helper()
+	raw, err := json.Marshal(gateway.OpenAPISpec("test"))
+	require.NoError(t, err)
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &doc))
+	return doc.Paths
+}
+
+// Every route the gateway registers must be described, so clients generated
+// from /openapi.json never miss an endpoint.
+func TestOpenAPI_CoversEveryManagedRoute(t *testing.T) {
+	paths := openAPIPaths(t)
+	for _, pattern := range gateway.ManagedRoutePatterns() {
+		if !strings.HasSuffix(pattern, "/") {
+			assert.Contains(t, paths, pattern, "route %s is not in the OpenAPI spec", pattern)
+			continue
+		}
+		found := false
+		for p := range paths {
+			if strings.HasPrefix(p, pattern) {
+				found = true
+				break
+			}
+		}
+		assert.True(t, found, "subtree route %s has no operation in the OpenAPI spec", pattern)
+	}
+}
+
+// Every documented path must be served by a managed route.
+func TestOpenAPI_DescribesOnlyManagedRoutes(t *testing.T) {
+	patterns := gateway.ManagedRoutePatterns()
+	for p := range openAPIPaths(t) {
+		served := false
+		for _, pattern := range patterns {
+			if p == pattern || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(p, pattern)) {
+				served = true
+				break
+			}
+		}
+		assert.True(t, served, "OpenAPI path %s is not registered", p)
+	}
+}
+
+func TestOpenAPI_RefsResolve(t *testing.T) {
+	raw, err := json.Marshal(gateway.OpenAPISpec("test"))
+	require.NoError(t, err)
+	var doc struct {
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &doc))
+
+	for _, part := range strings.Split(string(raw), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+	assert.Contains(t, doc.Components.Schemas, "StatsResponse")
+}
+
+func TestGateway_OpenAPI_GET(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+	gw.SetVersion("1.2.3")
+
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "1.2.3", doc.Info.Version)
+	assert.Contains(t, doc.Paths["/admin/state"], "post")
+}
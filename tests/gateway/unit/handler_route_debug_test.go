@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+type routeDebugResult struct {
+	Input    gateway.RouteInput    `json:"input"`
+	Decision gateway.RouteDecision `json:"decision"`
+}
+
+func routingConfig() *config.Config {
+	cfg := edgeCaseConfig()
+	cfg.Pipes.Pipeline = pipes.PipelineConfig{
+		Order: []string{"tool_discovery", "tool_output"},
+		Rules: []pipes.RouteRule{
+			{Name: "ci-bypass", SessionTag: "ci", Chain: []string{}},
+			{Name: "haiku-light", Provider: "anthropic", Model: "claude-haiku-*", Chain: []string{"tool_output"}},
+			{Name: "large-openai", Path: "/v1/chat/*", MinBytes: 200, Chain: []string{"tool_output", "tool_discovery"}},
+		},
+	}
+	return cfg
+}
+
+func postRouteDebug(t *testing.T, srvURL, query, body string, headers map[string]string) routeDebugResult {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srvURL+"/debug/route"+query, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out routeDebugResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	return out
+}
+
+func TestGateway_RouteDebug_ExplainsMatchedRule(t *testing.T) {
+	gw := gateway.New(routingConfig())
+	defer gw.Shutdown(context.Background())
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	anthropicHeaders := map[string]string{"x-api-key": "sk-ant-test", "anthropic-version": "2023-06-01"}
+	haiku := `{"model":"claude-haiku-4-5","messages":[{"role":"user","content":"hi"}]}`
+	sonnet := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`
+
+	out := postRouteDebug(t, srv.URL, "", haiku, anthropicHeaders)
+	assert.Equal(t, "claude-haiku-4-5", out.Input.Model)
+	assert.Equal(t, "anthropic", out.Input.Provider)
+	assert.Equal(t, "haiku-light", out.Decision.Rule)
+	assert.Equal(t, []string{"tool_output"}, out.Decision.Chain)
+
+	// Earlier rule wins: the ci tag bypasses all pipes even for haiku.
+	tagged := map[string]string{"x-api-key": "sk-ant-test", gateway.HeaderSessionTags: "nightly, ci"}
+	out = postRouteDebug(t, srv.URL, "", haiku, tagged)
+	assert.Equal(t, []string{"nightly", "ci"}, out.Input.SessionTags)
+	assert.Equal(t, "ci-bypass", out.Decision.Rule)
+	assert.Empty(t, out.Decision.Chain)
+
+	// No rule matches: falls back to the configured order.
+	out = postRouteDebug(t, srv.URL, "", sonnet, anthropicHeaders)
+	assert.Empty(t, out.Decision.Rule)
+	assert.Equal(t, gateway.RouteLayoutOrdered, out.Decision.Layout)
+	assert.Equal(t, []string{"tool_discovery", "tool_output"}, out.Decision.Chain)
+
+	// Path and size matchers.
+	openaiBody := `{"model":"gpt-4o","messages":[{"role":"user","content":"` + strings.Repeat("x", 300) + `"}]}`
+	openaiHeaders := map[string]string{"Authorization": "Bearer sk-test"}
+	out = postRouteDebug(t, srv.URL, "?path=/v1/chat/completions", openaiBody, openaiHeaders)
+	assert.Equal(t, "/v1/chat/completions", out.Input.Path)
+	assert.Equal(t, "large-openai", out.Decision.Rule)
+	out = postRouteDebug(t, srv.URL, "?path=/v1/chat/completions", `{"model":"gpt-4o","messages":[]}`, openaiHeaders)
+	assert.Empty(t, out.Decision.Rule, "request below min_bytes must not match")
+}
+
+func TestGateway_RouteDebug_DefaultLayout(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	out := postRouteDebug(t, srv.URL, "", `{"model":"claude-sonnet-4-5","messages":[]}`, map[string]string{"x-api-key": "sk-ant-test"})
+	assert.Equal(t, gateway.RouteLayoutDefault, out.Decision.Layout)
+	assert.Equal(t, []string{"task_output", "tool_output", "tool_discovery"}, out.Decision.Chain)
+}
+
+func TestGateway_RouteDebug_MethodNotAllowed(t *testing.T) {
+	gw := gateway.New(edgeCaseConfig())
+	defer gw.Shutdown(context.Background())
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/route")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
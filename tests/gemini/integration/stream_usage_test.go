@@ -0,0 +1,80 @@
+// Gemini Stream Usage Integration Tests - Mock Upstream
+//
+// streamGenerateContent responses carry no "stream" flag, so the gateway reads
+// them whole and GeminiAdapter.ExtractUsage takes the last chunk's cumulative
+// usageMetadata. These tests check the cost tracker records it for both
+// response forms: a JSON array of chunks (default) and SSE lines (alt=sse).
+
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+const (
+	geminiFirstChunk = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Three "}]}}],"usageMetadata":{"promptTokenCount":1000,"candidatesTokenCount":2}}`
+	geminiLastChunk  = `{"candidates":[{"content":{"role":"model","parts":[{"text":"services failed."}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":1000,"candidatesTokenCount":100,"totalTokenCount":1100}}`
+)
+
+func streamGeminiThroughGateway(t *testing.T, query, contentType, response string) *gateway.Gateway {
+	t.Helper()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(mock.Close)
+
+	cfg := passthroughConfigGemini()
+	cfg.CostControl = config.CostControlConfig{Enabled: true}
+	gw := gateway.New(cfg)
+	srv := httptest.NewServer(gw.Handler())
+	t.Cleanup(srv.Close)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"Why is the deploy failing?"}]}]}`
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1beta/models/gemini-2.5-pro:streamGenerateContent"+query, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", "AIza-test")
+	req.Header.Set("X-Target-URL", mock.URL)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	assert.Contains(t, string(respBody), "services failed.")
+	return gw
+}
+
+func wantGeminiStreamCost() float64 {
+	return costcontrol.CalculateCost(1000, 100, costcontrol.GetModelPricing("gemini-2.5-pro"))
+}
+
+func TestIntegration_Gemini_StreamUsage_SSE(t *testing.T) {
+	gw := streamGeminiThroughGateway(t, "?alt=sse", "text/event-stream",
+		"data: "+geminiFirstChunk+"\r\n\r\ndata: "+geminiLastChunk+"\r\n\r\n")
+
+	require.Eventually(t, func() bool { return gw.CostTracker().GetGlobalCost() > 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, wantGeminiStreamCost(), gw.CostTracker().GetGlobalCost(), 1e-12, "last chunk's usage, not the sum")
+}
+
+func TestIntegration_Gemini_StreamUsage_JSONArray(t *testing.T) {
+	gw := streamGeminiThroughGateway(t, "", "application/json",
+		"["+geminiFirstChunk+",\n"+geminiLastChunk+"]")
+
+	require.Eventually(t, func() bool { return gw.CostTracker().GetGlobalCost() > 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, wantGeminiStreamCost(), gw.CostTracker().GetGlobalCost(), 1e-12, "last chunk's usage, not the sum")
+}
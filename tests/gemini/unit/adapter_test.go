@@ -49,6 +49,7 @@ func TestGemini_ExtractToolOutput(t *testing.T) {
 	assert.Equal(t, "read_file", extracted[0].ToolName)
 	assert.Equal(t, 2, extracted[0].MessageIndex)
 	assert.Equal(t, 0, extracted[0].BlockIndex)
+	assert.Equal(t, adapters.DetectContentFormat(extracted[0].Content), extracted[0].Format, "format is needed for the compressible check")
 }
 
 func TestGemini_ExtractToolOutput_ObjectResponse(t *testing.T) {
@@ -226,6 +227,18 @@ func TestGemini_ExtractUserQuery(t *testing.T) {
 	assert.Equal(t, "What is the capital of France?", query)
 }
 
+func TestGemini_ExtractUserQuery_OmittedRole(t *testing.T) {
+	adapter := adapters.NewGeminiAdapter()
+
+	// Single-turn requests may omit role; it defaults to "user".
+	body := []byte(`{"contents": [{"parts": [{"text": "Summarize this repo"}]}]}`)
+
+	assert.Equal(t, "Summarize this repo", adapter.ExtractUserQuery(body))
+	texts, hasToolResults := adapter.ExtractLastUserContent(body)
+	assert.Equal(t, []string{"Summarize this repo"}, texts)
+	assert.False(t, hasToolResults)
+}
+
 func TestGemini_ExtractUserQuery_MultipleMessages(t *testing.T) {
 	adapter := adapters.NewGeminiAdapter()
 
@@ -335,6 +348,43 @@ func TestGemini_ExtractUsage_InvalidJSON(t *testing.T) {
 	assert.Equal(t, 0, usage.InputTokens)
 }
 
+func TestGemini_ExtractUsage_StreamArray(t *testing.T) {
+	adapter := adapters.NewGeminiAdapter()
+
+	// streamGenerateContent returns a JSON array; usage is cumulative, last chunk wins.
+	body := []byte(`[
+		{"candidates": [{"content": {"parts": [{"text": "Hel"}]}}], "usageMetadata": {"promptTokenCount": 120, "totalTokenCount": 120}},
+		{"candidates": [{"content": {"parts": [{"text": "lo"}]}}]},
+		{"candidates": [{"content": {"parts": [{"text": "!"}]}, "finishReason": "STOP"}],
+		 "usageMetadata": {"promptTokenCount": 120, "candidatesTokenCount": 30, "totalTokenCount": 150, "cachedContentTokenCount": 20}}
+	]`)
+
+	usage := adapter.ExtractUsage(body)
+	assert.Equal(t, 100, usage.InputTokens)
+	assert.Equal(t, 30, usage.OutputTokens)
+	assert.Equal(t, 150, usage.TotalTokens)
+	assert.Equal(t, 20, usage.CacheReadInputTokens)
+}
+
+func TestGemini_ExtractUsage_StreamSSE(t *testing.T) {
+	adapter := adapters.NewGeminiAdapter()
+
+	body := []byte("data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hi\"}]}}], \"usageMetadata\": {\"promptTokenCount\": 40, \"totalTokenCount\": 40}}\r\n\r\n" +
+		"data: {\"candidates\": [{\"finishReason\": \"STOP\"}], \"usageMetadata\": {\"promptTokenCount\": 40, \"candidatesTokenCount\": 7, \"totalTokenCount\": 47}}\r\n\r\n")
+
+	usage := adapter.ExtractUsage(body)
+	assert.Equal(t, 40, usage.InputTokens)
+	assert.Equal(t, 7, usage.OutputTokens)
+	assert.Equal(t, 47, usage.TotalTokens)
+}
+
+func TestGemini_ExtractUsage_StreamWithoutUsage(t *testing.T) {
+	adapter := adapters.NewGeminiAdapter()
+
+	usage := adapter.ExtractUsage([]byte(`[{"candidates": []}]`))
+	assert.Equal(t, 0, usage.TotalTokens)
+}
+
 // =============================================================================
 // MODEL EXTRACTION
 // =============================================================================
@@ -365,6 +415,20 @@ func TestGemini_ExtractModel_Empty(t *testing.T) {
 	assert.Empty(t, model)
 }
 
+func TestGemini_ExtractModelFromPath(t *testing.T) {
+	assert.Equal(t, "gemini-2.5-pro", adapters.ExtractGeminiModelFromPath("/v1beta/models/gemini-2.5-pro:streamGenerateContent"))
+	assert.Equal(t, "gemini-2.5-flash", adapters.ExtractGeminiModelFromPath("/v1/models/gemini-2.5-flash:generateContent"))
+	assert.Empty(t, adapters.ExtractGeminiModelFromPath("/v1beta/models"))
+	assert.Empty(t, adapters.ExtractGeminiModelFromPath("/v1/messages"))
+}
+
+func TestGemini_IsGeminiPath(t *testing.T) {
+	assert.True(t, adapters.IsGeminiPath("/v1beta/models/gemini-2.5-pro:generateContent"))
+	assert.True(t, adapters.IsGeminiPath("/v1beta/models/gemini-2.5-pro:streamGenerateContent"))
+	assert.False(t, adapters.IsGeminiPath("/v1beta/models/gemini-2.5-pro:countTokens"))
+	assert.False(t, adapters.IsGeminiPath("/v1internal:streamGenerateContent"), "Code Assist wraps requests differently")
+}
+
 // =============================================================================
 // PROVIDER DETECTION
 // =============================================================================
@@ -404,6 +468,18 @@ func TestGemini_ProviderDetection_PathBased(t *testing.T) {
 	assert.Equal(t, "gemini", adapter.Name())
 }
 
+func TestGemini_ProviderDetection_NativePathWithOAuth(t *testing.T) {
+	registry := adapters.NewRegistry()
+
+	// Gemini CLI with Google login: Bearer OAuth token, no API key header.
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer ya29.a0-test-token")
+
+	provider, adapter := adapters.IdentifyAndGetAdapter(registry, "/v1beta/models/gemini-2.5-pro:streamGenerateContent", headers)
+	assert.Equal(t, adapters.ProviderGemini, provider)
+	assert.Equal(t, "gemini", adapter.Name())
+}
+
 // =============================================================================
 // INTERFACE COMPLIANCE
 // =============================================================================
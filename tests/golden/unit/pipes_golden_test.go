@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	piipipe "github.com/compresr/context-gateway/internal/pipes/pii"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/compresr/context-gateway/tests/testkit"
+)
+
+// testdataDir holds one directory per pipe. Each <case>.json is a captured
+// request; <case>.golden.json is the body the pipe must produce for it. The
+// adapter is chosen by the case name's prefix (anthropic_, openai_, gemini_...).
+// <case>.response.json files from `serve --record-fixtures` are not cases.
+const testdataDir = "../testdata"
+
+// goldenPipes builds each pipe under test with a fixed config. Thresholds are
+// chosen far from the fixtures' sizes so results do not depend on exact
+// token counts.
+var goldenPipes = map[string]func(st store.Store) pipes.Pipe{
+	"tool_output": func(st store.Store) pipes.Pipe {
+		return tooloutput.New(&config.Config{Pipes: config.PipesConfig{
+			ToolOutput: config.ToolOutputPipeConfig{
+				Enabled:             true,
+				Strategy:            config.StrategySimple,
+				FallbackStrategy:    config.StrategyPassthrough,
+				MinTokens:           50,
+				MaxTokens:           16384,
+				EnableExpandContext: true,
+				IncludeExpandHint:   true,
+				BypassCostCheck:     true,
+			},
+		}}, st)
+	},
+	"tool_discovery": func(_ store.Store) pipes.Pipe {
+		return tooldiscovery.New(&config.Config{Pipes: config.PipesConfig{
+			ToolDiscovery: config.ToolDiscoveryPipeConfig{
+				Enabled:        true,
+				Strategy:       config.StrategyRelevance,
+				AlwaysKeep:     []string{"Bash"},
+				TokenThreshold: 1, // Always filter; keep the most relevant tool plus AlwaysKeep
+			},
+		}})
+	},
+	"pii": func(st store.Store) pipes.Pipe {
+		return piipipe.New(&config.Config{Pipes: config.PipesConfig{
+			PII: pipes.PIIConfig{Enabled: true},
+		}}, st)
+	},
+}
+
+func TestPipesGolden(t *testing.T) {
+	registry := adapters.NewRegistry()
+
+	for pipeName, newPipe := range goldenPipes {
+		requests, err := filepath.Glob(filepath.Join(testdataDir, pipeName, "*.json"))
+		require.NoError(t, err)
+
+		for _, reqPath := range requests {
+			if strings.HasSuffix(reqPath, ".golden.json") || strings.HasSuffix(reqPath, ".response.json") {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(reqPath), ".json")
+
+			t.Run(pipeName+"/"+name, func(t *testing.T) {
+				adapterName, _, _ := strings.Cut(name, "_")
+				adapter := registry.Get(adapterName)
+				require.NotNil(t, adapter, "no adapter %q for case %s", adapterName, name)
+
+				body, err := os.ReadFile(reqPath) // #nosec G304 -- test fixture
+				require.NoError(t, err)
+
+				st := store.NewMemoryStore(time.Hour)
+				defer st.Close()
+
+				ctx := pipes.NewPipeContext(adapter, body)
+				ctx.Provider = adapter.Provider()
+				ctx.TargetModel = adapter.ExtractModel(body)
+				ctx.UserQuery = adapter.ExtractUserQuery(body)
+
+				got, err := newPipe(st).Process(ctx)
+				require.NoError(t, err)
+
+				testkit.AssertGoldenJSON(t, strings.TrimSuffix(reqPath, ".json")+".golden.json", normalizePIITokens(got))
+			})
+		}
+	}
+}
+
+var piiTokenRe = regexp.MustCompile(`\[\[PII_([A-Z0-9_]+)_[0-9a-f]{10}\]\]`)
+
+// normalizePIITokens replaces PII token hashes, which are keyed per process,
+// with ordinals in order of first appearance. Equal values still share a token.
+func normalizePIITokens(body []byte) []byte {
+	seen := map[string]string{}
+	return piiTokenRe.ReplaceAllFunc(body, func(tok []byte) []byte {
+		if n, ok := seen[string(tok)]; ok {
+			return []byte(n)
+		}
+		kind := piiTokenRe.FindSubmatch(tok)[1]
+		n := fmt.Sprintf("[[PII_%s_#%d]]", kind, len(seen)+1)
+		seen[string(tok)] = n
+		return []byte(n)
+	})
+}
@@ -0,0 +1,112 @@
+// Prompt Caching + Compression Interaction Tests
+//
+// Verifies that EnforceCachePrefix detects pipe changes before the final
+// cache_control breakpoint and, in compatibility mode, restores the cached
+// prefix while keeping compression applied after the breakpoint.
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// cachedConversation has a cache breakpoint on message 2 (the first tool_result)
+// and an uncached tool_result in message 4.
+const cachedConversation = `{
+	"model":"claude-sonnet-4-5",
+	"system":[{"type":"text","text":"You are helpful.","cache_control":{"type":"ephemeral"}}],
+	"tools":[{"name":"read_file","description":"Read a file","input_schema":{"type":"object"}},{"name":"grep","description":"Search","input_schema":{"type":"object"}}],
+	"messages":[
+		{"role":"user","content":"read both files"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read_file","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"OLD OUTPUT","cache_control":{"type":"ephemeral"}}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"read_file","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"NEW OUTPUT"}]}
+	]
+}`
+
+func TestCacheCompat_NoCacheControl_NoViolation(t *testing.T) {
+	original := []byte(`{"messages":[{"role":"user","content":"hi"}],"tools":[{"name":"a"}]}`)
+	forwarded, err := sjson.DeleteBytes(original, "tools")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.False(t, violated)
+	assert.Equal(t, forwarded, result)
+}
+
+func TestCacheCompat_ChangeAfterBreakpoint_Allowed(t *testing.T) {
+	original := []byte(cachedConversation)
+	forwarded, err := sjson.SetBytes(original, "messages.4.content.0.content", "compressed")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.False(t, violated, "message after the breakpoint may be compressed")
+	assert.Equal(t, "compressed", gjson.GetBytes(result, "messages.4.content.0.content").String())
+}
+
+func TestCacheCompat_ChangeBeforeBreakpoint_DetectedNotRestored(t *testing.T) {
+	original := []byte(cachedConversation)
+	forwarded, err := sjson.SetBytes(original, "messages.2.content.0.content", "compressed")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, false)
+	assert.True(t, violated)
+	assert.Equal(t, "compressed", gjson.GetBytes(result, "messages.2.content.0.content").String(),
+		"detection-only mode must not rewrite the pipe output")
+}
+
+func TestCacheCompat_ChangeBeforeBreakpoint_Restored(t *testing.T) {
+	original := []byte(cachedConversation)
+	forwarded, err := sjson.SetBytes(original, "messages.2.content.0.content", "compressed-old")
+	require.NoError(t, err)
+	forwarded, err = sjson.SetBytes(forwarded, "messages.4.content.0.content", "compressed-new")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.True(t, violated)
+	assert.Equal(t, "OLD OUTPUT", gjson.GetBytes(result, "messages.2.content.0.content").String(),
+		"cached prefix must be restored")
+	assert.Equal(t, "compressed-new", gjson.GetBytes(result, "messages.4.content.0.content").String(),
+		"compression after the breakpoint must be kept")
+}
+
+func TestCacheCompat_ToolFiltering_RestoresToolOrder(t *testing.T) {
+	original := []byte(cachedConversation)
+	// Simulate tool_discovery dropping and reordering tools.
+	forwarded, err := sjson.SetRawBytes(original, "tools", []byte(`[{"name":"grep","description":"Search","input_schema":{"type":"object"}}]`))
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.True(t, violated)
+	assert.Equal(t, gjson.Get(cachedConversation, "tools").Raw, gjson.GetBytes(result, "tools").Raw)
+}
+
+func TestCacheCompat_SystemBreakpoint_MessagesFree(t *testing.T) {
+	original := []byte(`{
+		"system":[{"type":"text","text":"sys","cache_control":{"type":"ephemeral"}}],
+		"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"LONG"}]}]
+	}`)
+	forwarded, err := sjson.SetBytes(original, "messages.0.content.0.content", "short")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.False(t, violated, "messages are after a system breakpoint")
+	assert.Equal(t, "short", gjson.GetBytes(result, "messages.0.content.0.content").String())
+}
+
+func TestCacheCompat_MessageCountChanged_FallsBackToOriginal(t *testing.T) {
+	original := []byte(cachedConversation)
+	forwarded, err := sjson.DeleteBytes(original, "messages.0")
+	require.NoError(t, err)
+
+	result, violated := gateway.EnforceCachePrefix(original, forwarded, true)
+	assert.True(t, violated)
+	assert.Equal(t, original, result)
+}
@@ -374,3 +374,28 @@ func TestLiteLLM_ProviderFromString(t *testing.T) {
 	assert.Equal(t, adapters.ProviderLiteLLM, adapters.ProviderFromString("litellm"))
 	assert.Equal(t, adapters.ProviderUnknown, adapters.ProviderFromString("invalid"))
 }
+
+// =============================================================================
+// PROVIDER-PREFIXED MODEL NAMES
+// =============================================================================
+
+func TestSplitModelProvider(t *testing.T) {
+	tests := []struct {
+		model, provider, name string
+	}{
+		{"bedrock/anthropic.claude-3-5-sonnet-20240620-v1:0", "bedrock", "anthropic.claude-3-5-sonnet-20240620-v1:0"},
+		{"vertex_ai/gemini-2.5-pro", "vertex_ai", "gemini-2.5-pro"},
+		{"azure/gpt-4o", "azure", "gpt-4o"},
+		{"openrouter/anthropic/claude-3.5-sonnet", "openrouter", "anthropic/claude-3.5-sonnet"},
+		{"anthropic/claude-sonnet-4-5", "anthropic", "claude-sonnet-4-5"},
+		{"claude-sonnet-4-5", "", "claude-sonnet-4-5"},
+		{"local-model", "", "local-model"},
+		{"my-team/custom-alias", "", "my-team/custom-alias"},
+		{"bedrock/", "", "bedrock/"},
+	}
+	for _, tt := range tests {
+		provider, name := adapters.SplitModelProvider(tt.model)
+		assert.Equal(t, tt.provider, provider, tt.model)
+		assert.Equal(t, tt.name, name, tt.model)
+	}
+}
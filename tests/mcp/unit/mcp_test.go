@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/mcp"
+)
+
+func newEchoServer() *mcp.Server {
+	return mcp.NewServer("test", "1.0",
+		mcp.Tool{
+			Name:        "echo",
+			InputSchema: map[string]any{"type": "object"},
+			Handler: func(_ context.Context, args map[string]any) (string, error) {
+				return mcp.RequiredString(args, "text")
+			},
+		},
+		mcp.Tool{
+			Name:        "fail",
+			InputSchema: map[string]any{"type": "object"},
+			Handler: func(context.Context, map[string]any) (string, error) {
+				return "", errors.New("boom")
+			},
+		},
+	)
+}
+
+func handle(t *testing.T, s *mcp.Server, msg string) gjson.Result {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(msg))
+	require.NotNil(t, out)
+	return gjson.ParseBytes(out)
+}
+
+func TestServer_InitializeNegotiatesVersion(t *testing.T) {
+	s := newEchoServer()
+
+	res := handle(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	assert.Equal(t, "2024-11-05", res.Get("result.protocolVersion").String())
+	assert.Equal(t, "test", res.Get("result.serverInfo.name").String())
+	assert.True(t, res.Get("result.capabilities.tools").Exists())
+
+	res = handle(t, s, `{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	assert.Equal(t, mcp.ProtocolVersion, res.Get("result.protocolVersion").String())
+}
+
+func TestServer_ToolsListAndCall(t *testing.T) {
+	s := newEchoServer()
+
+	res := handle(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	assert.Equal(t, "echo", res.Get("result.tools.0.name").String())
+	assert.Equal(t, "object", res.Get("result.tools.0.inputSchema.type").String())
+
+	res = handle(t, s, `{"jsonrpc":"2.0","id":"x","method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`)
+	assert.Equal(t, "x", res.Get("id").String())
+	assert.Equal(t, "hi", res.Get("result.content.0.text").String())
+	assert.False(t, res.Get("result.isError").Exists())
+}
+
+func TestServer_ToolErrorsAreResults(t *testing.T) {
+	s := newEchoServer()
+
+	res := handle(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fail"}}`)
+	assert.False(t, res.Get("error").Exists())
+	assert.True(t, res.Get("result.isError").Bool())
+	assert.Equal(t, "boom", res.Get("result.content.0.text").String())
+
+	res = handle(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo"}}`)
+	assert.True(t, res.Get("result.isError").Bool())
+	assert.Contains(t, res.Get("result.content.0.text").String(), `"text"`)
+}
+
+func TestServer_ProtocolErrors(t *testing.T) {
+	s := newEchoServer()
+
+	res := handle(t, s, `{not json`)
+	assert.Equal(t, int64(mcp.CodeParseError), res.Get("error.code").Int())
+
+	res = handle(t, s, `{"id":1,"method":"ping"}`)
+	assert.Equal(t, int64(mcp.CodeInvalidRequest), res.Get("error.code").Int())
+
+	res = handle(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)
+	assert.Equal(t, int64(mcp.CodeMethodNotFound), res.Get("error.code").Int())
+
+	res = handle(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"missing"}}`)
+	assert.Equal(t, int64(mcp.CodeInvalidParams), res.Get("error.code").Int())
+}
+
+func TestServer_NotificationsGetNoResponse(t *testing.T) {
+	s := newEchoServer()
+	assert.Nil(t, s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	s := newEchoServer()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServeStdio(t *testing.T) {
+	s := newEchoServer()
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		``,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+	}, "\n"))
+	var out bytes.Buffer
+
+	err := mcp.ServeStdio(context.Background(), in, &out, func(ctx context.Context, msg []byte) ([]byte, error) {
+		return s.Handle(ctx, msg), nil
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, lines[0])
+	assert.Equal(t, "hi", gjson.Get(lines[1], "result.content.0.text").String())
+}
+
+func TestServeStdio_HandlerErrorStops(t *testing.T) {
+	in := strings.NewReader("{\"a\":1}\n{\"b\":2}\n")
+	calls := 0
+	err := mcp.ServeStdio(context.Background(), in, &bytes.Buffer{}, func(context.Context, []byte) ([]byte, error) {
+		calls++
+		return nil, errors.New("gateway down")
+	})
+	assert.EqualError(t, err, "gateway down")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,315 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/media"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+func newMediaPipe(t *testing.T, rules ...pipes.MediaRule) (*media.Pipe, store.Store) {
+	t.Helper()
+	st := store.NewMemoryStore(time.Minute)
+	t.Cleanup(func() { st.Close() })
+	cfg := &config.Config{Pipes: pipes.Config{Media: pipes.MediaConfig{Enabled: true, Rules: rules}}}
+	return media.New(cfg, st), st
+}
+
+// pngBytes encodes a w x h gradient PNG.
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func b64(data []byte) string { return base64.StdEncoding.EncodeToString(data) }
+
+func anthropicImage(data []byte) map[string]any {
+	return map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": b64(data)}}
+}
+
+func anthropicPDF(data []byte) map[string]any {
+	return map[string]any{"type": "document", "source": map[string]any{"type": "base64", "media_type": "application/pdf", "data": b64(data)}}
+}
+
+// anthropicRequest has a user screenshot, a PDF, a tool_result screenshot and
+// a final user screenshot, in that order.
+func anthropicRequest(t *testing.T, img []byte) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"model": "claude-sonnet-4-5",
+		"messages": []map[string]any{
+			{"role": "user", "content": []map[string]any{{"type": "text", "text": "What is this?"}, anthropicImage(img)}},
+			{"role": "assistant", "content": "A chart."},
+			{"role": "user", "content": []map[string]any{anthropicPDF([]byte("%PDF-1.7 report")), {"type": "text", "text": "And this?"}}},
+			{"role": "assistant", "content": []map[string]any{
+				{"type": "tool_use", "id": "toolu_1", "name": "screenshot", "input": map[string]any{}},
+			}},
+			{"role": "user", "content": []map[string]any{
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": []map[string]any{anthropicImage(img)}},
+			}},
+			{"role": "assistant", "content": "Done."},
+			{"role": "user", "content": []map[string]any{anthropicImage(img), {"type": "text", "text": "Compare."}}},
+		},
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func process(t *testing.T, p *media.Pipe, adapter adapters.Adapter, body []byte) (*pipes.PipeContext, []byte) {
+	t.Helper()
+	ctx := pipes.NewPipeContext(adapter, body)
+	out, err := p.Process(ctx)
+	require.NoError(t, err)
+	return ctx, out
+}
+
+// =============================================================================
+// DROP
+// =============================================================================
+
+func TestProcess_DefaultRule_DropsAllButThreeMostRecent(t *testing.T) {
+	p, st := newMediaPipe(t)
+	img := pngBytes(t, 64, 64)
+	ctx, out := process(t, p, adapters.NewAnthropicAdapter(), anthropicRequest(t, img))
+
+	require.Len(t, ctx.MediaRewrites, 1)
+	rw := ctx.MediaRewrites[0]
+	assert.Equal(t, "image/png", rw.MediaType)
+	assert.Equal(t, pipes.MediaActionDrop, rw.Action)
+	assert.Equal(t, len(img), rw.OriginalBytes)
+	assert.Zero(t, rw.Bytes)
+	require.True(t, media.IsRef(rw.ShadowID))
+
+	// The oldest block is now a text placeholder naming the ref.
+	first := gjson.GetBytes(out, "messages.0.content.1")
+	assert.Equal(t, "text", first.Get("type").String())
+	assert.Contains(t, first.Get("text").String(), "[image omitted: image/png, ")
+	assert.Contains(t, first.Get("text").String(), media.Marker(rw.ShadowID))
+
+	// The original block is stored under the ref and registered for expansion.
+	raw, ok := st.Get(rw.ShadowID)
+	require.True(t, ok)
+	assert.JSONEq(t, gjson.GetBytes(anthropicRequest(t, img), "messages.0.content.1").Raw, raw)
+	assert.Equal(t, raw, ctx.ShadowRefs[rw.ShadowID])
+
+	// The three most recent blocks are untouched.
+	assert.Equal(t, "document", gjson.GetBytes(out, "messages.2.content.0.type").String())
+	assert.Equal(t, "image", gjson.GetBytes(out, "messages.4.content.0.content.0.type").String())
+	assert.Equal(t, "image", gjson.GetBytes(out, "messages.6.content.0.type").String())
+}
+
+func TestProcess_KeepRecentZero_DropsEveryBlock(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"*/*"}})
+	ctx, out := process(t, p, adapters.NewAnthropicAdapter(), anthropicRequest(t, pngBytes(t, 8, 8)))
+
+	require.Len(t, ctx.MediaRewrites, 4)
+	assert.Equal(t, "application/pdf", ctx.MediaRewrites[1].MediaType, "rewrites are reported in conversation order")
+	assert.Equal(t, "toolu_1", ctx.MediaRewrites[2].ToolCallID)
+	assert.Contains(t, gjson.GetBytes(out, "messages.2.content.0.text").String(), "[document omitted: application/pdf, 15 B")
+	assert.Equal(t, "text", gjson.GetBytes(out, "messages.4.content.0.content.0.type").String())
+	assert.NotContains(t, string(out), `"source"`)
+}
+
+func TestProcess_IsDeterministic(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"image/*"}, KeepRecent: 1})
+	body := anthropicRequest(t, pngBytes(t, 16, 16))
+	_, first := process(t, p, adapters.NewAnthropicAdapter(), body)
+	_, second := process(t, p, adapters.NewAnthropicAdapter(), body)
+	assert.Equal(t, first, second, "same request must be rewritten to the same bytes (KV-cache)")
+}
+
+func TestProcess_KeepsCacheControlOnPlaceholder(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"image/*"}})
+	block := anthropicImage(pngBytes(t, 8, 8))
+	block["cache_control"] = map[string]any{"type": "ephemeral"}
+	body, err := json.Marshal(map[string]any{
+		"model":    "claude-sonnet-4-5",
+		"messages": []map[string]any{{"role": "user", "content": []map[string]any{block}}},
+	})
+	require.NoError(t, err)
+
+	_, out := process(t, p, adapters.NewAnthropicAdapter(), body)
+	assert.Equal(t, "text", gjson.GetBytes(out, "messages.0.content.0.type").String())
+	assert.Equal(t, "ephemeral", gjson.GetBytes(out, "messages.0.content.0.cache_control.type").String())
+}
+
+func TestProcess_WithoutStore_PlaceholderHasNoRef(t *testing.T) {
+	cfg := &config.Config{Pipes: pipes.Config{Media: pipes.MediaConfig{Enabled: true, Rules: []pipes.MediaRule{{Types: []string{"image/*"}}}}}}
+	p := media.New(cfg, nil)
+	ctx, out := process(t, p, adapters.NewAnthropicAdapter(), anthropicRequest(t, pngBytes(t, 8, 8)))
+
+	require.Len(t, ctx.MediaRewrites, 3)
+	assert.Empty(t, ctx.MediaRewrites[0].ShadowID)
+	assert.NotContains(t, string(out), "expand_context")
+	assert.Empty(t, ctx.ShadowRefs)
+}
+
+// =============================================================================
+// PER-TYPE RULES AND DOWNSCALE
+// =============================================================================
+
+func TestProcess_PerTypeRules(t *testing.T) {
+	p, _ := newMediaPipe(t,
+		pipes.MediaRule{Types: []string{"image/png", "image/jpeg"}, Action: pipes.MediaActionDownscale, KeepRecent: 1, MaxDimension: 100},
+	)
+	img := pngBytes(t, 400, 200)
+	ctx, out := process(t, p, adapters.NewAnthropicAdapter(), anthropicRequest(t, img))
+
+	// PDFs match no rule; the two older images are downscaled in place.
+	require.Len(t, ctx.MediaRewrites, 2)
+	for _, rw := range ctx.MediaRewrites {
+		assert.Equal(t, pipes.MediaActionDownscale, rw.Action)
+		assert.Empty(t, rw.ShadowID)
+		assert.Less(t, rw.Bytes, rw.OriginalBytes)
+	}
+	assert.Equal(t, "document", gjson.GetBytes(out, "messages.2.content.0.type").String())
+
+	for _, path := range []string{"messages.0.content.1", "messages.4.content.0.content.0"} {
+		data, err := base64.StdEncoding.DecodeString(gjson.GetBytes(out, path+".source.data").String())
+		require.NoError(t, err)
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err, path)
+		assert.Equal(t, 100, cfg.Width, path)
+		assert.Equal(t, 50, cfg.Height, path)
+	}
+	// The most recent image keeps its full resolution.
+	assert.Equal(t, b64(img), gjson.GetBytes(out, "messages.6.content.0.source.data").String())
+}
+
+func TestProcess_Downscale_SmallImageIsKept(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"image/*"}, Action: pipes.MediaActionDownscale})
+	body := anthropicRequest(t, pngBytes(t, 32, 32))
+	ctx, out := process(t, p, adapters.NewAnthropicAdapter(), body)
+
+	assert.Empty(t, ctx.MediaRewrites)
+	assert.Equal(t, body, out)
+}
+
+// =============================================================================
+// OPENAI
+// =============================================================================
+
+func TestProcess_OpenAIChat(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"*/*"}, KeepRecent: 1})
+	uri := "data:image/png;base64," + b64(pngBytes(t, 8, 8))
+	body, err := json.Marshal(map[string]any{
+		"model": "gpt-4o",
+		"messages": []map[string]any{
+			{"role": "user", "content": []map[string]any{
+				{"type": "image_url", "image_url": map[string]any{"url": uri}},
+				{"type": "file", "file": map[string]any{"filename": "a.pdf", "file_data": "data:application/pdf;base64," + b64([]byte("%PDF-1.7"))}},
+				{"type": "image_url", "image_url": map[string]any{"url": "https://example.com/remote.png"}},
+			}},
+			{"role": "user", "content": []map[string]any{{"type": "image_url", "image_url": map[string]any{"url": uri}}}},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, out := process(t, p, adapters.NewOpenAIAdapter(), body)
+	require.Len(t, ctx.MediaRewrites, 2)
+	assert.Equal(t, "text", gjson.GetBytes(out, "messages.0.content.0.type").String())
+	assert.Contains(t, gjson.GetBytes(out, "messages.0.content.1.text").String(), "[document omitted: application/pdf")
+	assert.Equal(t, "https://example.com/remote.png", gjson.GetBytes(out, "messages.0.content.2.image_url.url").String(), "URL images carry no tokens to save")
+	assert.Equal(t, uri, gjson.GetBytes(out, "messages.1.content.0.image_url.url").String())
+}
+
+func TestProcess_OpenAIResponses(t *testing.T) {
+	p, _ := newMediaPipe(t, pipes.MediaRule{Types: []string{"image/*"}, KeepRecent: 1})
+	uri := "data:image/png;base64," + b64(pngBytes(t, 8, 8))
+	body, err := json.Marshal(map[string]any{
+		"model": "gpt-4o",
+		"input": []map[string]any{
+			{"role": "user", "content": []map[string]any{{"type": "input_image", "image_url": uri}}},
+			{"type": "function_call", "call_id": "call_1", "name": "screenshot", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": []map[string]any{{"type": "input_image", "image_url": uri}}},
+			{"role": "user", "content": []map[string]any{{"type": "input_image", "image_url": uri}}},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, out := process(t, p, adapters.NewOpenAIAdapter(), body)
+	require.Len(t, ctx.MediaRewrites, 2)
+	assert.Equal(t, "call_1", ctx.MediaRewrites[1].ToolCallID)
+	assert.Equal(t, "input_text", gjson.GetBytes(out, "input.0.content.0.type").String())
+	assert.Equal(t, "input_text", gjson.GetBytes(out, "input.2.output.0.type").String())
+	assert.Equal(t, "input_image", gjson.GetBytes(out, "input.3.content.0.type").String())
+}
+
+// =============================================================================
+// RESTORE
+// =============================================================================
+
+func TestRestoreMediaBlock_PutsOriginalBack(t *testing.T) {
+	p, st := newMediaPipe(t, pipes.MediaRule{Types: []string{"image/*"}, KeepRecent: 2})
+	adapter := adapters.NewAnthropicAdapter()
+	original := anthropicRequest(t, pngBytes(t, 8, 8))
+	ctx, out := process(t, p, adapter, original)
+	require.Len(t, ctx.MediaRewrites, 1)
+	ref := ctx.MediaRewrites[0].ShadowID
+	raw, ok := st.Get(ref)
+	require.True(t, ok)
+
+	restored, ok := adapter.RestoreMediaBlock(out, media.Marker(ref), raw)
+	require.True(t, ok)
+	assert.JSONEq(t, string(original), string(restored))
+
+	_, ok = adapter.RestoreMediaBlock(restored, media.Marker(ref), raw)
+	assert.False(t, ok, "placeholder is gone once restored")
+}
+
+// =============================================================================
+// CONFIG
+// =============================================================================
+
+func TestMediaConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    pipes.MediaRule
+		wantErr string
+	}{
+		{name: "valid", rule: pipes.MediaRule{Types: []string{"image/*"}, Action: pipes.MediaActionDownscale, KeepRecent: 2, MaxDimension: 512}},
+		{name: "no types", rule: pipes.MediaRule{}, wantErr: "types is required"},
+		{name: "bad glob", rule: pipes.MediaRule{Types: []string{"image/["}}, wantErr: "invalid type glob"},
+		{name: "bad action", rule: pipes.MediaRule{Types: []string{"*/*"}, Action: "shrink"}, wantErr: "action must be"},
+		{name: "negative", rule: pipes.MediaRule{Types: []string{"*/*"}, KeepRecent: -1}, wantErr: "must be >= 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := pipes.MediaConfig{Enabled: true, Rules: []pipes.MediaRule{tt.rule}}
+			err := c.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+		})
+	}
+}
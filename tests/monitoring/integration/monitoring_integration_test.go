@@ -244,6 +244,7 @@ func TestIntegration_Monitoring_TelemetryTracker(t *testing.T) {
 		Success:     true,
 	}
 	tracker.RecordRequest(event)
+	tracker.Flush() // writes are async; wait for the writer goroutine
 
 	// Read the log file and verify the event was written
 	data, err := os.ReadFile(logPath)
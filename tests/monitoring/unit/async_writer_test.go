@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/redaction"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		require.True(t, json.Valid(sc.Bytes()), "line %d is not valid JSON", n)
+		n++
+	}
+	return n
+}
+
+func TestAsyncWriter_FlushWritesAllLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{BatchSize: 7})
+	require.NoError(t, err)
+	defer w.Close()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, w.WriteJSONL(map[string]int{"i": i}))
+	}
+	w.Flush()
+
+	assert.Equal(t, 100, countLines(t, path))
+	assert.Equal(t, int64(100), w.Written())
+	assert.Zero(t, w.Dropped())
+}
+
+func TestAsyncWriter_CloseDrainsQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{SyncPolicy: monitoring.SyncNever})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_ = w.WriteJSONL(map[string]int{"i": i})
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close(), "close is idempotent")
+
+	assert.Equal(t, 400, countLines(t, path))
+	assert.False(t, w.Write([]byte("{}\n")), "writes after close are dropped")
+	assert.Equal(t, int64(1), w.Dropped())
+	assert.ErrorIs(t, w.WriteJSONL(map[string]int{"i": 0}), monitoring.ErrDropped, "callers can tell a dropped line from a written one")
+	assert.Equal(t, int64(2), w.Dropped())
+}
+
+func TestAsyncWriter_FullQueueDropsWithoutBlocking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{QueueSize: 1})
+	require.NoError(t, err)
+	defer w.Close()
+
+	start := time.Now()
+	accepted := 0
+	for i := 0; i < 10000; i++ {
+		if w.Write([]byte("{}\n")) {
+			accepted++
+		}
+	}
+	assert.Less(t, time.Since(start), time.Second, "enqueue must never block on disk")
+	w.Flush()
+
+	assert.Equal(t, int64(10000), int64(accepted)+w.Dropped(), "every line is either accepted or counted as dropped")
+	assert.Equal(t, accepted, countLines(t, path))
+}
+
+func TestAsyncWriterConfig_Validate(t *testing.T) {
+	for _, p := range []monitoring.SyncPolicy{"", monitoring.SyncNever, monitoring.SyncInterval, monitoring.SyncAlways} {
+		assert.NoError(t, monitoring.AsyncWriterConfig{SyncPolicy: p}.Validate(), p)
+	}
+	assert.Error(t, monitoring.AsyncWriterConfig{SyncPolicy: "sometimes"}.Validate())
+}
+
+func TestTracker_FlushAndDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	tracker, err := monitoring.NewTracker(monitoring.TelemetryConfig{Enabled: true, LogPath: path})
+	require.NoError(t, err)
+	defer tracker.Close()
+
+	for i := 0; i < 25; i++ {
+		tracker.RecordRequest(&monitoring.RequestEvent{RequestID: "req", Success: true})
+	}
+	tracker.Flush()
+
+	assert.Equal(t, 25, countLines(t, path))
+	assert.Zero(t, tracker.Dropped())
+}
+
+func TestAsyncWriter_RedactsLines(t *testing.T) {
+	redactor, err := redaction.New(redaction.Config{Enabled: true})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "compression.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{Redactor: redactor})
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteJSONL(map[string]string{"original_content": "token: Bearer abcdef123456\nowner ops@example.com"}))
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countLines(t, path))
+	assert.NotContains(t, string(data), "abcdef123456")
+	assert.NotContains(t, string(data), "ops@example.com")
+	assert.Contains(t, string(data), `Bearer [REDACTED:BEARER_TOKEN]\nowner [REDACTED:EMAIL]`)
+}
@@ -0,0 +1,44 @@
+package unit
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogger_SyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	l, err := monitoring.NewAuditLogger(monitoring.AuditConfig{
+		Enabled: true, Sink: monitoring.AuditSinkSyslog,
+		SyslogNetwork: "udp", SyslogAddress: conn.LocalAddr().String(), SyslogTag: "gw-audit",
+	}, monitoring.AsyncWriterConfig{})
+	require.NoError(t, err)
+	l.Log(monitoring.AuditEntry{RequestID: "req-1", Host: "api.anthropic.com", HostAllowed: true})
+	require.NoError(t, l.Close())
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<38>"), "auth facility, info severity: %s", msg)
+	assert.Contains(t, msg, "gw-audit")
+	assert.Contains(t, msg, `"request_id":"req-1"`)
+	assert.Contains(t, msg, `"host_allowed":true`)
+}
+
+func TestAuditLogger_DisabledIsNil(t *testing.T) {
+	l, err := monitoring.NewAuditLogger(monitoring.AuditConfig{}, monitoring.AsyncWriterConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, l)
+	l.Log(monitoring.AuditEntry{}) // safe on nil
+	assert.NoError(t, l.Close())
+}
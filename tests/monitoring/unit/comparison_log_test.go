@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparisonLog_RecentAndGet(t *testing.T) {
+	l := monitoring.NewComparisonLog()
+	l.Record(monitoring.ComparisonSample{RequestID: "empty", Timestamp: time.Now()})
+	l.Record(monitoring.ComparisonSample{
+		RequestID: "req_1",
+		Timestamp: time.Now(),
+		Comparisons: []monitoring.CompressionComparison{
+			{ToolName: "Read", OriginalTokens: 1000, CompressedTokens: 100, OriginalContent: "a", CompressedContent: "b"},
+			{ToolName: "Bash", OriginalTokens: 500, CompressedTokens: 50, AllTools: []string{"x"}},
+		},
+	})
+
+	recent := l.Recent(10)
+	require.Len(t, recent, 1, "samples without comparisons are dropped")
+	assert.Equal(t, "req_1", recent[0].RequestID)
+	assert.Equal(t, 2, recent[0].Outputs)
+	assert.Equal(t, 1500, recent[0].OriginalTokens)
+	assert.Equal(t, 150, recent[0].CompressedTokens)
+
+	sample, ok := l.Get("req_1")
+	require.True(t, ok)
+	require.Len(t, sample.Comparisons, 2)
+	assert.Equal(t, "a", sample.Comparisons[0].OriginalContent)
+	assert.Nil(t, sample.Comparisons[1].AllTools)
+
+	_, ok = l.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestComparisonLog_TruncatesContent(t *testing.T) {
+	l := monitoring.NewComparisonLog()
+	big := strings.Repeat("x", 100*1024)
+	l.Record(monitoring.ComparisonSample{
+		RequestID:   "req_big",
+		Comparisons: []monitoring.CompressionComparison{{OriginalContent: big, CompressedContent: "short"}},
+	})
+
+	sample, ok := l.Get("req_big")
+	require.True(t, ok)
+	orig := sample.Comparisons[0].OriginalContent
+	assert.Less(t, len(orig), len(big))
+	assert.Contains(t, orig, "[truncated")
+	assert.Equal(t, "short", sample.Comparisons[0].CompressedContent)
+}
+
+func TestComparisonLog_BoundedAndReset(t *testing.T) {
+	l := monitoring.NewComparisonLog()
+	for i := 0; i < 40; i++ {
+		l.Record(monitoring.ComparisonSample{
+			RequestID:   fmt.Sprintf("req_%d", i),
+			Comparisons: []monitoring.CompressionComparison{{ToolName: "Read"}},
+		})
+	}
+	recent := l.Recent(100)
+	assert.Len(t, recent, 25)
+	assert.Equal(t, "req_39", recent[0].RequestID, "newest first")
+
+	_, ok := l.Get("req_0")
+	assert.False(t, ok, "oldest samples are evicted")
+
+	l.Reset()
+	assert.Empty(t, l.Recent(10))
+}
@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		code      monitoring.ErrorCode
+		retryable bool
+	}{
+		{http.StatusOK, "", false},
+		{http.StatusBadRequest, monitoring.ErrorCodeUpstream4xx, false},
+		{http.StatusUnauthorized, monitoring.ErrorCodeUpstream4xx, false},
+		{http.StatusRequestTimeout, monitoring.ErrorCodeUpstream4xx, true},
+		{http.StatusTooManyRequests, monitoring.ErrorCodeUpstream4xx, true},
+		{http.StatusInternalServerError, monitoring.ErrorCodeUpstream5xx, true},
+		{529, monitoring.ErrorCodeUpstream5xx, true}, // Anthropic overloaded
+	}
+	for _, tt := range tests {
+		code, retryable := monitoring.ClassifyStatus(tt.status)
+		assert.Equal(t, tt.code, code, "status %d", tt.status)
+		assert.Equal(t, tt.retryable, retryable, "status %d", tt.status)
+	}
+}
+
+func TestErrorCode_Retryable(t *testing.T) {
+	assert.True(t, monitoring.ErrorCodeUpstreamTimeout.Retryable())
+	assert.True(t, monitoring.ErrorCodeUpstreamUnavailable.Retryable())
+	assert.True(t, monitoring.ErrorCodeCircuitOpen.Retryable())
+	assert.False(t, monitoring.ErrorCodeBudgetExceeded.Retryable())
+	assert.False(t, monitoring.ErrorCodeInvalidRequest.Retryable())
+	assert.False(t, monitoring.ErrorCodeHostNotAllowed.Retryable())
+	assert.False(t, monitoring.ErrorCodeCompressionFailed.Retryable())
+}
+
+func TestMetricsCollector_ErrorCounts(t *testing.T) {
+	mc := monitoring.NewMetricsCollector()
+	mc.RecordError(monitoring.ErrorCodeUpstreamTimeout)
+	mc.RecordError(monitoring.ErrorCodeUpstreamTimeout)
+	mc.RecordError(monitoring.ErrorCodeBudgetExceeded)
+	mc.RecordError("") // ignored
+
+	assert.Equal(t, map[string]int64{"upstream_timeout": 2, "budget_exceeded": 1}, mc.ErrorCounts())
+
+	mc.Reset()
+	assert.Empty(t, mc.ErrorCounts())
+}
@@ -39,3 +39,68 @@ func TestMetricsCollector_Stop(t *testing.T) {
 	mc.RecordRequest(true, 10*time.Millisecond)
 	mc.Stop() // Should be a no-op but shouldn't panic
 }
+
+func TestStreamLatency_Percentiles(t *testing.T) {
+	mc := monitoring.NewMetricsCollector()
+	for i := 1; i <= 100; i++ {
+		mc.RecordStreamLatency(time.Duration(i)*time.Millisecond, time.Duration(2*i)*time.Millisecond, time.Second)
+	}
+
+	stats := mc.StreamLatency()
+	assert.Equal(t, int64(100), stats.FirstByte.Count)
+	assert.Equal(t, 50.0, stats.FirstByte.P50Ms)
+	assert.Equal(t, 90.0, stats.FirstByte.P90Ms)
+	assert.Equal(t, 99.0, stats.FirstByte.P99Ms)
+	assert.Equal(t, 100.0, stats.FirstToken.P50Ms)
+	assert.Equal(t, 1000.0, stats.Total.P99Ms)
+	assert.Equal(t, 5050.0, stats.FirstByte.SumMs)
+}
+
+func TestStreamLatency_SkipsUnobservedFirstToken(t *testing.T) {
+	mc := monitoring.NewMetricsCollector()
+	mc.RecordStreamLatency(10*time.Millisecond, 0, 50*time.Millisecond)
+
+	stats := mc.StreamLatency()
+	assert.Equal(t, int64(1), stats.FirstByte.Count)
+	assert.Equal(t, int64(0), stats.FirstToken.Count)
+	assert.Equal(t, int64(1), stats.Total.Count)
+
+	mc.Reset()
+	assert.Equal(t, int64(0), mc.StreamLatency().FirstByte.Count)
+}
+
+func TestProviderStats_HistogramBuckets(t *testing.T) {
+	mc := monitoring.NewMetricsCollector()
+	mc.RecordProviderRequest("anthropic", true, 200*time.Millisecond)
+	mc.RecordProviderRequest("anthropic", false, 3*time.Second)
+	mc.RecordProviderRequest("anthropic", true, 10*time.Minute)
+	mc.RecordProviderRequest("", true, time.Millisecond)
+
+	stats := mc.ProviderStats()
+	a := stats["anthropic"]
+	assert.Equal(t, int64(3), a.Requests)
+	assert.Equal(t, int64(1), a.Failures)
+	assert.Equal(t, int64(3), a.Latency.Count)
+	assert.InDelta(t, 603.2, a.Latency.Sum, 1e-9)
+	// Cumulative: 0.1s bucket empty, 0.25s holds the 200ms sample, 5s adds the 3s one;
+	// the 10m sample only lands in +Inf (Count).
+	assert.Equal(t, int64(0), a.Latency.Counts[0])
+	assert.Equal(t, int64(1), a.Latency.Counts[1])
+	assert.Equal(t, int64(2), a.Latency.Counts[5])
+	assert.Equal(t, int64(2), a.Latency.Counts[len(a.Latency.Counts)-1])
+	assert.Equal(t, int64(1), stats["unknown"].Requests, "empty provider is reported as unknown")
+
+	mc.Reset()
+	assert.Empty(t, mc.ProviderStats())
+}
+
+func TestRecordPhantomLoops(t *testing.T) {
+	mc := monitoring.NewMetricsCollector()
+	mc.RecordPhantomLoops(0)
+	mc.RecordPhantomLoops(2)
+	mc.RecordPhantomLoops(1)
+
+	stats := mc.Stats()
+	assert.Equal(t, int64(3), stats["phantom_loops"])
+	assert.Equal(t, int64(2), stats["phantom_loop_requests"])
+}
@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+// gzipLines counts the lines of a gzipped file.
+func gzipLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	n := 0
+	sc := bufio.NewScanner(zr)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		n++
+	}
+	require.NoError(t, sc.Err())
+	return n
+}
+
+// writeKB writes n lines of about 1 KB each.
+func writeKB(t *testing.T, w *monitoring.AsyncWriter, n int) {
+	t.Helper()
+	pad := strings.Repeat("x", 1000)
+	for i := 0; i < n; i++ {
+		require.NoError(t, w.WriteJSONL(map[string]any{"i": i, "pad": pad}))
+		if i%500 == 0 {
+			w.Flush() // Stay under the queue size
+		}
+	}
+	w.Flush()
+}
+
+func TestRetention_RotatesBySizeAndCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{
+		Retention: monitoring.RetentionConfig{Enabled: true, MaxSizeMB: 1},
+	})
+	require.NoError(t, err)
+
+	writeKB(t, w, 1500)
+	require.NoError(t, w.Close())
+
+	rotated, err := monitoring.RotatedFiles(path)
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+	assert.True(t, strings.HasSuffix(rotated[0], ".jsonl.gz"), rotated[0])
+	assert.True(t, strings.HasPrefix(filepath.Base(rotated[0]), "telemetry-"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(1<<20), "the active file starts over")
+	assert.Equal(t, 1500, gzipLines(t, rotated[0])+countLines(t, path), "no line is lost")
+}
+
+func TestRetention_KeepsMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{
+		Retention: monitoring.RetentionConfig{Enabled: true, MaxSizeMB: 1, MaxFiles: 2},
+	})
+	require.NoError(t, err)
+
+	writeKB(t, w, 4500)
+	require.NoError(t, w.Close())
+
+	rotated, err := monitoring.RotatedFiles(path)
+	require.NoError(t, err)
+	assert.Len(t, rotated, 2)
+}
+
+func TestRetention_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{
+		FlushInterval: 10 * time.Millisecond,
+		Retention:     monitoring.RetentionConfig{Enabled: true, RotateEvery: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.WriteJSONL(map[string]int{"i": 1}))
+	w.Flush()
+	require.Eventually(t, func() bool {
+		files, _ := monitoring.RotatedFiles(path)
+		return len(files) == 1 && strings.HasSuffix(files[0], ".gz")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// An empty file is not rotated again.
+	time.Sleep(100 * time.Millisecond)
+	files, err := monitoring.RotatedFiles(path)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestRetention_DisabledNeverRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := monitoring.OpenAsyncWriter(path, monitoring.AsyncWriterConfig{})
+	require.NoError(t, err)
+	writeKB(t, w, 1200)
+	require.NoError(t, w.Close())
+
+	files, err := monitoring.RotatedFiles(path)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, 1200, countLines(t, path))
+}
+
+func TestPruneRotated_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "telemetry.jsonl")
+	now := time.Now()
+	old := monitoring.RotatedName(path, now.Add(-48*time.Hour)) + ".gz"
+	recent := monitoring.RotatedName(path, now.Add(-time.Hour)) + ".gz"
+	other := filepath.Join(dir, "tool_discovery-20200101T000000.000.jsonl.gz")
+	for _, f := range []string{old, recent, other, path} {
+		require.NoError(t, os.WriteFile(f, []byte("x"), 0o600))
+	}
+
+	removed, err := monitoring.PruneRotated(path, monitoring.RetentionConfig{MaxAge: 24 * time.Hour}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{old}, removed)
+	for _, f := range []string{recent, other, path} {
+		assert.FileExists(t, f, "only expired rotated files of this log are removed")
+	}
+}
+
+func TestPruneTrajectories(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "trajectory_old.json")
+	recent := filepath.Join(dir, "trajectory_recent.json")
+	for _, f := range []string{old, recent} {
+		require.NoError(t, os.WriteFile(f, []byte("{}"), 0o600))
+	}
+	past := time.Now().Add(-72 * time.Hour)
+	require.NoError(t, os.Chtimes(old, past, past))
+
+	removed, err := monitoring.PruneTrajectories(dir, monitoring.RetentionConfig{}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, removed, "no max_age keeps every session")
+
+	removed, err = monitoring.PruneTrajectories(dir, monitoring.RetentionConfig{MaxAge: 24 * time.Hour}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{old}, removed)
+	assert.FileExists(t, recent)
+}
+
+func TestRetentionConfig_Validate(t *testing.T) {
+	assert.NoError(t, monitoring.RetentionConfig{Enabled: true, MaxSizeMB: 50, MaxAge: time.Hour}.Validate())
+	assert.Error(t, monitoring.RetentionConfig{MaxSizeMB: -1}.Validate())
+	assert.Error(t, monitoring.RetentionConfig{MaxFiles: -1}.Validate())
+	assert.Error(t, monitoring.RetentionConfig{MaxAge: -time.Hour}.Validate())
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+)
+
+func appendLine(t *testing.T, path, line string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(line)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestTailer_SkipsExistingThenFollows(t *testing.T) {
+	dir := t.TempDir()
+	telemetry := filepath.Join(dir, "telemetry.jsonl")
+	appendLine(t, telemetry, `{"request_id":"old","timestamp":"2026-01-01T10:00:00Z","status_code":200}`+"\n")
+
+	tailer, err := monitoring.NewTailer(dir, nil, false)
+	require.NoError(t, err)
+	assert.Empty(t, tailer.Poll())
+
+	appendLine(t, telemetry, `{"request_id":"new","timestamp":"2026-01-01T10:00:02Z","status_code":200}`+"\n")
+	// Created after the tailer started: read from the beginning.
+	appendLine(t, filepath.Join(dir, "tool_output_compression.jsonl"),
+		`{"request_id":"new","event_type":"tool_output","timestamp":"2026-01-01T10:00:01Z","status":"compressed"}`+"\n")
+
+	events := tailer.Poll()
+	require.Len(t, events, 2)
+	assert.Equal(t, "tool_output", events[0].Pipe, "events are ordered by timestamp")
+	assert.Equal(t, "requests", events[1].Pipe)
+	assert.Empty(t, tailer.Poll())
+}
+
+func TestTailer_FromStartAndPipeFilter(t *testing.T) {
+	dir := t.TempDir()
+	appendLine(t, filepath.Join(dir, "telemetry.jsonl"), `{"request_id":"r1","status_code":200}`+"\n")
+	appendLine(t, filepath.Join(dir, "tool_output_compression.jsonl"), `{"request_id":"r1","status":"compressed"}`+"\n")
+
+	tailer, err := monitoring.NewTailer(dir, []string{"tool_output"}, true)
+	require.NoError(t, err)
+	events := tailer.Poll()
+	require.Len(t, events, 1)
+	assert.Equal(t, "tool_output", events[0].Pipe)
+}
+
+func TestTailer_PartialLineAndTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "telemetry.jsonl")
+
+	tailer, err := monitoring.NewTailer(dir, nil, false)
+	require.NoError(t, err)
+
+	appendLine(t, path, `{"request_id":"r1",`)
+	assert.Empty(t, tailer.Poll(), "incomplete line is held back")
+	appendLine(t, path, `"status_code":200}`+"\nnot json\n")
+	events := tailer.Poll()
+	require.Len(t, events, 1, "malformed lines are skipped")
+	assert.Equal(t, "r1", events[0].Fields["request_id"])
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"request_id":"r2"}`+"\n"), 0600))
+	events = tailer.Poll()
+	require.Len(t, events, 1, "truncated file is re-read from the start")
+	assert.Equal(t, "r2", events[0].Fields["request_id"])
+}
+
+func TestNewTailer_UnknownPipe(t *testing.T) {
+	_, err := monitoring.NewTailer(t.TempDir(), []string{"nope"}, false)
+	assert.ErrorContains(t, err, "unknown pipe")
+}
+
+func TestFormatTailEvent(t *testing.T) {
+	req := monitoring.TailEvent{Pipe: "requests", Fields: map[string]any{
+		"status_code": 200.0, "model": "claude-sonnet-4-5", "compression_used": true,
+		"original_tokens": 12000.0, "compressed_tokens": 3000.0, "total_latency_ms": 1500.0,
+	}}
+	line := monitoring.FormatTailEvent(req, false)
+	assert.Contains(t, line, "requests")
+	assert.Contains(t, line, "200  claude-sonnet-4-5  12.0k→3.0k (-75%)  1.5s")
+	assert.NotContains(t, line, "\033[")
+
+	tool := monitoring.TailEvent{Pipe: "tool_output", Fields: map[string]any{
+		"event_type": "tool_output", "tool_name": "Read", "status": "compressed",
+		"original_tokens": 800.0, "compressed_tokens": 200.0, "cache_hit": true,
+	}}
+	line = monitoring.FormatTailEvent(tool, false)
+	assert.Contains(t, line, "tool_output  Read  compressed  800→200 (-75%)  cache_hit")
+
+	colored := monitoring.FormatTailEvent(tool, true)
+	assert.True(t, strings.Contains(colored, "\033["))
+
+	compaction := monitoring.TailEvent{Pipe: "compaction", Fields: map[string]any{
+		"event": "preemptive_complete", "session_id": "abc", "messages_summarized": 40.0, "duration_ms": 900.0,
+	}}
+	assert.Contains(t, monitoring.FormatTailEvent(compaction, false), "preemptive_complete  abc  msgs=40  900ms")
+}
@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolSavings_AccumulatesPerTool(t *testing.T) {
+	ts := monitoring.NewToolSavingsTracker()
+
+	// Same output re-sent on a later turn counts twice but is one distinct output.
+	ts.RecordOutput("Read", "shadow_a", "compressed", 1000, 200, 4000, 800)
+	ts.RecordOutput("Read", "shadow_a", "cache_hit", 1000, 200, 4000, 800)
+	ts.RecordOutput("Read", "", "passthrough_small", 50, 50, 200, 200)
+	ts.RecordOutput("Bash", "shadow_b", "compressed", 400, 300, 1600, 1200)
+
+	board := ts.Leaderboard()
+	require.Len(t, board, 2)
+
+	read := board[0]
+	assert.Equal(t, "Read", read.ToolName)
+	assert.Equal(t, int64(3), read.OutputsSent)
+	assert.Equal(t, int64(2), read.CompressedSent)
+	assert.Equal(t, int64(1), read.CompressedOutputs)
+	assert.Equal(t, int64(1), read.CacheHits)
+	assert.Equal(t, int64(8200), read.OriginalBytes)
+	assert.Equal(t, int64(1800), read.CompressedBytes)
+	assert.Equal(t, int64(1600), read.TokensSaved)
+	assert.InDelta(t, 1600.0/2050.0*100, read.SavedPct, 0.001)
+
+	assert.Equal(t, "Bash", board[1].ToolName)
+	assert.Equal(t, int64(100), board[1].NetTokensSaved)
+}
+
+func TestToolSavings_ExpandsAndRegrets(t *testing.T) {
+	ts := monitoring.NewToolSavingsTracker()
+	ts.RecordOutput("Read", "shadow_a", "compressed", 1000, 200, 4000, 800)
+	ts.RecordOutput("Read", "shadow_b", "compressed", 1000, 200, 4000, 800)
+	ts.RecordOutput("Grep", "shadow_c", "compressed", 600, 100, 2400, 400)
+
+	ts.RecordExpand("shadow_a", true, 1000)
+	ts.RecordExpand("shadow_b", false, 0)
+	ts.RecordExpand("shadow_unknown", true, 5000) // not attributable, ignored
+
+	board := ts.Leaderboard()
+	require.Len(t, board, 2)
+
+	// Read saved 1600 but an expand re-sent 1000: net 600, still ahead of Grep at 500.
+	read, grep := board[0], board[1]
+	assert.Equal(t, "Read", read.ToolName)
+	assert.Equal(t, int64(2), read.Expands)
+	assert.Equal(t, int64(1), read.Regrets)
+	assert.Equal(t, int64(1000), read.ExpandedTokens)
+	assert.Equal(t, int64(600), read.NetTokensSaved)
+	assert.InDelta(t, 1.0, read.ExpandRate, 0.001)
+
+	assert.Equal(t, "Grep", grep.ToolName)
+	assert.Equal(t, int64(0), grep.Expands)
+	assert.Equal(t, int64(500), grep.NetTokensSaved)
+}
+
+func TestToolSavings_ShadowMapIsBounded(t *testing.T) {
+	ts := monitoring.NewToolSavingsTracker()
+	ts.RecordOutput("Read", "shadow_first", "compressed", 100, 10, 400, 40)
+	for i := 0; i < 10_000; i++ {
+		ts.RecordOutput("Read", fmt.Sprintf("shadow_%d", i), "compressed", 100, 10, 400, 40)
+	}
+
+	// The oldest mapping was evicted, so its expand can no longer be attributed.
+	ts.RecordExpand("shadow_first", true, 100)
+	ts.RecordExpand("shadow_9999", true, 100)
+
+	board := ts.Leaderboard()
+	require.Len(t, board, 1)
+	assert.Equal(t, int64(1), board[0].Expands)
+}
+
+func TestToolSavings_ResetAndNil(t *testing.T) {
+	ts := monitoring.NewToolSavingsTracker()
+	ts.RecordOutput("Read", "shadow_a", "compressed", 1000, 200, 4000, 800)
+	ts.Reset()
+	assert.Empty(t, ts.Leaderboard())
+
+	ts.RecordExpand("shadow_a", true, 1000)
+	assert.Empty(t, ts.Leaderboard(), "reset also forgets shadow attribution")
+
+	var nilTracker *monitoring.ToolSavingsTracker
+	nilTracker.RecordOutput("Read", "shadow_a", "compressed", 1, 1, 1, 1)
+	nilTracker.RecordExpand("shadow_a", true, 1)
+	assert.Nil(t, nilTracker.Leaderboard())
+}
+
+func TestToolSavings_MergeAcrossInstances(t *testing.T) {
+	a := monitoring.NewToolSavingsTracker()
+	a.RecordOutput("Read", "shadow_a", "compressed", 1000, 200, 4000, 800)
+	a.RecordExpand("shadow_a", true, 1000)
+
+	b := monitoring.NewToolSavingsTracker()
+	b.RecordOutput("Read", "shadow_b", "compressed", 1000, 200, 4000, 800)
+	b.RecordOutput("Grep", "shadow_c", "compressed", 600, 100, 2400, 400)
+
+	merged := monitoring.MergeToolSavings(a.Leaderboard(), b.Leaderboard())
+	require.Len(t, merged, 2)
+
+	read := merged[0]
+	assert.Equal(t, "Read", read.ToolName)
+	assert.Equal(t, int64(2), read.CompressedOutputs)
+	assert.Equal(t, int64(1600), read.TokensSaved)
+	assert.Equal(t, int64(600), read.NetTokensSaved)
+	assert.InDelta(t, 0.5, read.ExpandRate, 0.001)
+	assert.InDelta(t, 80.0, read.SavedPct, 0.001)
+	assert.Equal(t, "Grep", merged[1].ToolName)
+}
@@ -0,0 +1,357 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/notify"
+)
+
+// receiver records webhook requests.
+type receiver struct {
+	*httptest.Server
+	mu      sync.Mutex
+	bodies  [][]byte
+	headers []http.Header
+	status  []int // Responses to return in order; 200 once exhausted
+}
+
+func newReceiver(t *testing.T, status ...int) *receiver {
+	t.Helper()
+	rc := &receiver{status: status}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.bodies = append(rc.bodies, b)
+		rc.headers = append(rc.headers, r.Header.Clone())
+		code := http.StatusOK
+		if len(rc.status) > 0 {
+			code, rc.status = rc.status[0], rc.status[1:]
+		}
+		rc.mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) requests() ([][]byte, []http.Header) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([][]byte(nil), rc.bodies...), append([]http.Header(nil), rc.headers...)
+}
+
+// flush closes n, waiting for queued deliveries.
+func flush(t *testing.T, n *notify.Notifier) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, n.Close(ctx))
+}
+
+func newNotifier(t *testing.T, cfg notify.Config) *notify.Notifier {
+	t.Helper()
+	n, err := notify.New(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, n)
+	return n
+}
+
+func budgetEvent() notify.Event {
+	return notify.Event{
+		Type:      notify.EventBudgetWarning,
+		SessionID: "s1",
+		Data:      map[string]any{"cap": "session_cost", "used": 4.1, "limit": 5.0, "unit": "usd", "utilization": 0.82},
+	}
+}
+
+func TestNew_DisabledWithoutWebhooks(t *testing.T) {
+	n, err := notify.New(notify.Config{Locale: "de"})
+	require.NoError(t, err)
+	assert.Nil(t, n)
+
+	// nil notifier is a no-op
+	n.Notify(budgetEvent())
+	n.ObserveUpstream("anthropic", true, "upstream_5xx", 502)
+	assert.Equal(t, notify.Stats{}, n.Stats())
+	assert.NoError(t, n.Close(context.Background()))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  notify.Config
+		want string
+	}{
+		{"bad threshold", notify.Config{BudgetWarningAt: 1.5}, "budget_warning_at"},
+		{"negative interval", notify.Config{BudgetExceededInterval: -time.Second}, "budget_exceeded_interval"},
+		{"unknown template event", notify.Config{Templates: map[string]map[string]string{"nope": {"en": "x"}}}, "unknown event"},
+		{"bad template", notify.Config{Templates: map[string]map[string]string{"budget_warning": {"en": "{{.Data"}}}, "templates.budget_warning.en"},
+		{"bad url", notify.Config{Webhooks: []notify.WebhookConfig{{Name: "a", URL: "ftp://x"}}}, "http(s) URL"},
+		{"bad format", notify.Config{Webhooks: []notify.WebhookConfig{{Name: "a", URL: "http://x", Format: "xml"}}}, "format"},
+		{"template without body", notify.Config{Webhooks: []notify.WebhookConfig{{Name: "a", URL: "http://x", Format: "template"}}}, "body_template is required"},
+		{"unknown event", notify.Config{Webhooks: []notify.WebhookConfig{{Name: "a", URL: "http://x", Events: []string{"deploy"}}}}, "unknown event"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+	assert.NoError(t, notify.Config{Webhooks: []notify.WebhookConfig{{URL: "https://x.example"}}}.Validate())
+}
+
+func TestRenderMessage_Locales(t *testing.T) {
+	ev := budgetEvent()
+	for _, locale := range notify.Locales() {
+		msg, err := notify.RenderMessage(notify.Config{}, ev, locale)
+		require.NoError(t, err)
+		assert.Contains(t, msg, "82%", locale)
+		assert.Contains(t, msg, "$4.10", locale)
+		assert.NotContains(t, msg, "no value", locale)
+	}
+
+	de, _ := notify.RenderMessage(notify.Config{}, ev, "de")
+	assert.Contains(t, de, "Budgetwarnung")
+
+	// Unknown locale falls back to English
+	xx, _ := notify.RenderMessage(notify.Config{}, ev, "xx")
+	en, _ := notify.RenderMessage(notify.Config{}, ev, "en")
+	assert.Equal(t, en, xx)
+}
+
+func TestRenderMessage_UserTemplates(t *testing.T) {
+	cfg := notify.Config{Templates: map[string]map[string]string{
+		"budget_warning": {"pt": "Orçamento {{.Data.cap}} em {{percent .Data.utilization}}", "en": "custom {{amount .Data.limit .Data.unit}}"},
+	}}
+	pt, err := notify.RenderMessage(cfg, budgetEvent(), "pt")
+	require.NoError(t, err)
+	assert.Equal(t, "Orçamento session_cost em 82%", pt)
+
+	en, _ := notify.RenderMessage(cfg, budgetEvent(), "")
+	assert.Equal(t, "custom $5.00", en)
+
+	egress := notify.Event{Type: notify.EventBudgetWarning, Data: map[string]any{"limit": 1.5 * 1024 * 1024, "unit": "bytes"}}
+	msg, _ := notify.RenderMessage(cfg, egress, "en")
+	assert.Equal(t, "custom 1.5 MiB", msg)
+}
+
+func TestGenericPayload_MatchesSchema(t *testing.T) {
+	rc := newReceiver(t)
+	n := newNotifier(t, notify.Config{Locale: "fr", Webhooks: []notify.WebhookConfig{{Name: "ops", URL: rc.URL, Headers: map[string]string{"Authorization": "Bearer t"}}}})
+	n.Notify(budgetEvent())
+	flush(t, n)
+
+	bodies, headers := rc.requests()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "Bearer t", headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+
+	var schema struct {
+		Required []string `json:"required"`
+		AllOf    []struct {
+			If struct {
+				Properties struct {
+					Event struct {
+						Const string `json:"const"`
+					} `json:"event"`
+				} `json:"properties"`
+			} `json:"if"`
+			Then struct {
+				Properties struct {
+					Data struct {
+						Required []string `json:"required"`
+					} `json:"data"`
+				} `json:"properties"`
+			} `json:"then"`
+		} `json:"allOf"`
+	}
+	require.NoError(t, json.Unmarshal(notify.Schema, &schema))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(bodies[0], &got))
+	for _, key := range schema.Required {
+		assert.Contains(t, got, key)
+	}
+	assert.Equal(t, notify.PayloadSchemaID, got["schema"])
+	assert.Equal(t, "budget_warning", got["event"])
+	assert.Equal(t, "warning", got["severity"])
+	assert.Equal(t, "fr", got["locale"])
+	assert.Contains(t, got["message"], "Alerte budget")
+	assert.NotEmpty(t, got["id"])
+
+	data := got["data"].(map[string]any)
+	var checked bool
+	for _, rule := range schema.AllOf {
+		if rule.If.Properties.Event.Const == "budget_warning" {
+			for _, key := range rule.Then.Properties.Data.Required {
+				assert.Contains(t, data, key)
+			}
+			checked = true
+		}
+	}
+	assert.True(t, checked, "schema has no budget_warning data rule")
+}
+
+func TestSlackAndTemplateFormats(t *testing.T) {
+	slack := newReceiver(t)
+	pd := newReceiver(t)
+	n := newNotifier(t, notify.Config{Webhooks: []notify.WebhookConfig{
+		{Name: "chat", URL: slack.URL, Format: notify.FormatSlack, Locale: "es"},
+		{
+			Name: "pd", URL: pd.URL, Format: notify.FormatTemplate, ContentType: "application/vnd.test+json",
+			Events:       []string{"provider_outage"},
+			BodyTemplate: `{"summary": {{json .Message}}, "severity": "{{.Severity}}", "provider": "{{.Data.provider}}"}`,
+		},
+	}})
+	n.Notify(budgetEvent())
+	n.Notify(notify.Event{Type: notify.EventProviderOutage, Data: map[string]any{
+		"provider": "openai", "consecutive_failures": 5, "error_code": "upstream_5xx", "last_status": 503,
+	}})
+	flush(t, n)
+
+	bodies, _ := slack.requests()
+	require.Len(t, bodies, 2)
+	var msg struct {
+		Text   string           `json:"text"`
+		Blocks []map[string]any `json:"blocks"`
+	}
+	require.NoError(t, json.Unmarshal(bodies[0], &msg))
+	assert.Contains(t, msg.Text, "Aviso de presupuesto")
+	require.Len(t, msg.Blocks, 1)
+
+	// Template webhook only subscribes to provider_outage
+	bodies, headers := pd.requests()
+	require.Len(t, bodies, 1)
+	assert.Equal(t, "application/vnd.test+json", headers[0].Get("Content-Type"))
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(bodies[0], &body), string(bodies[0]))
+	assert.Equal(t, "critical", body["severity"])
+	assert.Equal(t, "openai", body["provider"])
+	assert.Contains(t, body["summary"], "openai failed 5 requests")
+}
+
+func TestNotifyOnce(t *testing.T) {
+	rc := newReceiver(t)
+	n := newNotifier(t, notify.Config{Webhooks: []notify.WebhookConfig{{URL: rc.URL}}})
+	n.NotifyOnce("budget/session_cost/s1", budgetEvent())
+	n.NotifyOnce("budget/session_cost/s1", budgetEvent())
+	n.NotifyOnce("budget/session_cost/s2", budgetEvent())
+	flush(t, n)
+
+	bodies, _ := rc.requests()
+	assert.Len(t, bodies, 2)
+}
+
+func TestNotifyThrottled(t *testing.T) {
+	rc := newReceiver(t)
+	n := newNotifier(t, notify.Config{BudgetExceededInterval: 50 * time.Millisecond, Webhooks: []notify.WebhookConfig{{URL: rc.URL}}})
+	exceeded := func() notify.Event {
+		return notify.Event{Type: notify.EventBudgetExceeded, Data: map[string]any{"cap": "global_cost", "used": 10.2, "limit": 10.0, "unit": "usd"}}
+	}
+	for range 3 {
+		n.NotifyThrottled("budget_exceeded/global_cost/", exceeded())
+	}
+	n.NotifyThrottled("budget_exceeded/session_cost/s1", exceeded())
+	time.Sleep(60 * time.Millisecond)
+	n.NotifyThrottled("budget_exceeded/global_cost/", exceeded())
+	flush(t, n)
+
+	bodies, _ := rc.requests()
+	require.Len(t, bodies, 3)
+	var first, last notify.Payload
+	require.NoError(t, json.Unmarshal(bodies[0], &first))
+	require.NoError(t, json.Unmarshal(bodies[2], &last))
+	assert.Equal(t, "critical", first.Severity)
+	assert.EqualValues(t, 0, first.Data["suppressed"])
+	assert.Equal(t, "Budget exceeded: global_cost reached $10.20 of $10.00; requests are being blocked.", first.Message)
+	assert.EqualValues(t, 2, last.Data["suppressed"], "requests blocked during the interval are counted")
+	assert.Contains(t, last.Message, "2 more blocked since the last alert")
+}
+
+func TestRenderMessage_BudgetExceededLocales(t *testing.T) {
+	ev := notify.Event{Type: notify.EventBudgetExceeded, Data: map[string]any{
+		"cap": "scope_cost", "scope": "team/search/2026-10-15", "used": 50.5, "limit": 50.0, "unit": "usd", "suppressed": 4,
+	}}
+	for _, locale := range notify.Locales() {
+		msg, err := notify.RenderMessage(notify.Config{}, ev, locale)
+		require.NoError(t, err)
+		assert.Contains(t, msg, "team/search/2026-10-15", locale)
+		assert.Contains(t, msg, "$50.50", locale)
+		assert.Contains(t, msg, "4", locale)
+		assert.NotContains(t, msg, "no value", locale)
+	}
+}
+
+func TestObserveUpstream_OutageFiresOncePerOutage(t *testing.T) {
+	rc := newReceiver(t)
+	n := newNotifier(t, notify.Config{OutageThreshold: 3, Webhooks: []notify.WebhookConfig{{URL: rc.URL}}})
+
+	for range 5 {
+		n.ObserveUpstream("anthropic", true, "upstream_5xx", 502)
+	}
+	n.ObserveUpstream("openai", true, "upstream_timeout", 0) // Other provider, below threshold
+	n.ObserveUpstream("anthropic", false, "", 200)           // Recovered: re-arm
+	for range 3 {
+		n.ObserveUpstream("anthropic", true, "upstream_unavailable", 0)
+	}
+	flush(t, n)
+
+	bodies, _ := rc.requests()
+	require.Len(t, bodies, 2)
+	var first, second notify.Payload
+	require.NoError(t, json.Unmarshal(bodies[0], &first))
+	require.NoError(t, json.Unmarshal(bodies[1], &second))
+	assert.Equal(t, notify.EventProviderOutage, first.Type)
+	assert.Equal(t, "critical", first.Severity)
+	assert.Equal(t, "upstream_5xx", first.Data["error_code"])
+	assert.EqualValues(t, 3, first.Data["consecutive_failures"])
+	assert.Equal(t, "upstream_unavailable", second.Data["error_code"])
+}
+
+func TestDelivery_Retries(t *testing.T) {
+	rc := newReceiver(t, http.StatusServiceUnavailable)
+	n := newNotifier(t, notify.Config{Webhooks: []notify.WebhookConfig{{URL: rc.URL}}})
+	n.Notify(budgetEvent())
+	flush(t, n)
+
+	bodies, _ := rc.requests()
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1], "retry must resend the same body (same id)")
+	st := n.Stats()
+	assert.EqualValues(t, 1, st.Events)
+	assert.EqualValues(t, 1, st.Delivered)
+	assert.EqualValues(t, 0, st.Failed)
+}
+
+func TestDelivery_PermanentFailureNotRetried(t *testing.T) {
+	rc := newReceiver(t, http.StatusBadRequest)
+	n := newNotifier(t, notify.Config{Webhooks: []notify.WebhookConfig{{Name: "ops", URL: rc.URL}}})
+	n.Notify(budgetEvent())
+	flush(t, n)
+
+	bodies, _ := rc.requests()
+	assert.Len(t, bodies, 1)
+	st := n.Stats()
+	assert.EqualValues(t, 1, st.Failed)
+	assert.Contains(t, st.LastError, "ops")
+	assert.Contains(t, st.LastError, "400")
+}
+
+func TestNotify_AfterCloseDropped(t *testing.T) {
+	rc := newReceiver(t)
+	n := newNotifier(t, notify.Config{Webhooks: []notify.WebhookConfig{{URL: rc.URL}}})
+	flush(t, n)
+	n.Notify(budgetEvent())
+	assert.EqualValues(t, 1, n.Stats().Dropped)
+}
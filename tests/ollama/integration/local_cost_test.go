@@ -0,0 +1,111 @@
+// Ollama Local Model Cost Integration Tests - Mock Upstream
+//
+// Self-hosted models cost nothing. These tests check that requests to a mock
+// Ollama server, native or OpenAI-compatible, are recorded by the cost tracker
+// with their token usage and a cost of $0, including native NDJSON streams.
+// The mock stands in for OLLAMA_PROVIDER_URL.
+
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func newLocalModelMock(t *testing.T) *httptest.Server {
+	t.Helper()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			_, _ = io.WriteString(w, `{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hel"},"done":false}`+"\n"+
+				`{"model":"llama3.1:8b","message":{"role":"assistant","content":"lo."},"done":false}`+"\n"+
+				`{"model":"llama3.1:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":120,"eval_count":30}`+"\n")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"qwen2.5-coder:7b",`+
+				`"choices":[{"index":0,"message":{"role":"assistant","content":"Hello."},"finish_reason":"stop"}],`+
+				`"usage":{"prompt_tokens":80,"completion_tokens":20,"total_tokens":100}}`)
+		}
+	}))
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+func postLocalModel(t *testing.T, gwURL, targetURL, path, body string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, gwURL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Target-URL", targetURL)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+	return string(respBody)
+}
+
+func onlyCostSession(t *testing.T, gw *gateway.Gateway) costcontrol.CostSessionSnapshot {
+	t.Helper()
+	sessions := gw.CostTracker().AllSessions()
+	require.Len(t, sessions, 1)
+	return sessions[0]
+}
+
+func TestIntegration_Ollama_LocalModelsCostNothing(t *testing.T) {
+	tests := []struct {
+		name, path, body    string
+		wantIn, wantOut     int
+		wantResponseContent string
+	}{
+		{
+			name:   "native NDJSON stream (stream omitted)",
+			path:   "/api/chat",
+			body:   `{"model":"llama3.1:8b","messages":[{"role":"user","content":"Say hello."}]}`,
+			wantIn: 120, wantOut: 30, wantResponseContent: `"lo."`,
+		},
+		{
+			name:   "native NDJSON stream",
+			path:   "/api/chat",
+			body:   `{"model":"llama3.1:8b","stream":true,"messages":[{"role":"user","content":"Say hello."}]}`,
+			wantIn: 120, wantOut: 30, wantResponseContent: `"lo."`,
+		},
+		{
+			name:   "OpenAI-compatible Ollama endpoint",
+			path:   "/v1/chat/completions",
+			body:   `{"model":"qwen2.5-coder:7b","messages":[{"role":"user","content":"Say hello."}]}`,
+			wantIn: 80, wantOut: 20, wantResponseContent: `"Hello."`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newLocalModelMock(t)
+			t.Setenv("OLLAMA_PROVIDER_URL", mock.URL)
+			cfg := passthroughConfig()
+			cfg.CostControl.Enabled = true
+			gw := gateway.New(cfg)
+			srv := httptest.NewServer(gw.Handler())
+			defer srv.Close()
+
+			assert.Contains(t, postLocalModel(t, srv.URL, mock.URL, tt.path, tt.body), tt.wantResponseContent)
+
+			session := onlyCostSession(t, gw)
+			assert.Equal(t, tt.wantIn, session.InputTokens, "token usage is still recorded")
+			assert.Equal(t, tt.wantOut, session.OutputTokens)
+			assert.Equal(t, 1, session.RequestCount)
+			assert.Zero(t, session.Cost, "local models cost nothing")
+			assert.Zero(t, gw.CostTracker().GetGlobalCost())
+		})
+	}
+}
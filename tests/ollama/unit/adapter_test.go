@@ -141,6 +141,22 @@ func TestOllama_ExtractUsage(t *testing.T) {
 	assert.Equal(t, 150, usage.TotalTokens)
 }
 
+func TestOllama_ExtractUsage_NDJSONStream(t *testing.T) {
+	adapter := adapters.NewOllamaAdapter()
+
+	// /api/chat streams by default: one object per line, usage on the "done" line
+	responseBody := []byte(`{"model":"llama3.1","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":"lo!"},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":100,"eval_count":50}
+`)
+
+	usage := adapter.ExtractUsage(responseBody)
+
+	assert.Equal(t, 100, usage.InputTokens)
+	assert.Equal(t, 50, usage.OutputTokens)
+	assert.Equal(t, 150, usage.TotalTokens)
+}
+
 func TestOllama_ExtractUsage_Empty(t *testing.T) {
 	adapter := adapters.NewOllamaAdapter()
 
@@ -0,0 +1,21 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestOllama_ParseStreamUsage_NDJSON(t *testing.T) {
+	stream := []byte(`{"model":"llama3.1","message":{"role":"assistant","content":"Hi"},"done":false}
+{"model":"llama3.1","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":42,"eval_count":7}
+`)
+
+	usage, stopReason := gateway.ParseStreamUsage(stream)
+	assert.Equal(t, 42, usage.InputTokens)
+	assert.Equal(t, 7, usage.OutputTokens)
+	assert.Equal(t, 49, usage.TotalTokens)
+	assert.Equal(t, "stop", stopReason)
+}
@@ -0,0 +1,66 @@
+// OpenAI Stream Usage Integration Tests - Mock Upstream
+//
+// With stream_options.include_usage, OpenAI sends usage in a final chunk with
+// no choices. These tests check the cost tracker records it without calling
+// the real API.
+
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func TestIntegration_OpenAI_StreamUsageRecorded(t *testing.T) {
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hello."},"finish_reason":"stop"}]}`+"\n\n")
+		_, _ = io.WriteString(w, `data: {"id":"c1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100}}`+"\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mock.Close()
+
+	gw := gateway.New(&config.Config{
+		Server: config.ServerConfig{
+			Port:         18080,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 120 * time.Second,
+		},
+		Pipes: config.PipesConfig{
+			ToolOutput: config.ToolOutputPipeConfig{Strategy: "passthrough", FallbackStrategy: "passthrough"},
+		},
+		Store:       config.StoreConfig{Type: "memory", TTL: time.Hour},
+		CostControl: config.CostControlConfig{Enabled: true},
+		Monitoring:  config.MonitoringConfig{LogLevel: "disabled", LogFormat: "json", LogOutput: "discard"},
+	})
+	srv := httptest.NewServer(gw.Handler())
+	defer srv.Close()
+
+	body := `{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Say hello."}]}`
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-test")
+	req.Header.Set(gateway.HeaderTargetURL, mock.URL+"/v1/chat/completions")
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+
+	want := costcontrol.CalculateCost(1000, 100, costcontrol.GetModelPricing("gpt-4o"))
+	require.Eventually(t, func() bool { return gw.CostTracker().GetGlobalCost() > 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, want, gw.CostTracker().GetGlobalCost(), 1e-12, "usage from the final chunk")
+}
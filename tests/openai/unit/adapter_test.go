@@ -115,6 +115,126 @@ func TestOpenAI_ApplyToolOutput_Multiple(t *testing.T) {
 	assert.Equal(t, "compressed2", items[3].(map[string]any)["output"])
 }
 
+// =============================================================================
+// OPENAI RESPONSES API - BUILT-IN TOOL ITEMS
+// =============================================================================
+
+const builtinToolItemsBody = `{
+	"model": "gpt-5",
+	"include": ["file_search_call.results"],
+	"input": [
+		{"type": "message", "role": "user", "content": "What is our refund policy?"},
+		{"type": "file_search_call", "id": "fs_001", "status": "completed", "queries": ["refund policy"], "results": [
+			{"file_id": "file-1", "filename": "policy.md", "score": 0.92, "text": "Refunds are issued within 30 days."},
+			{"file_id": "file-2", "filename": "faq.md", "score": 0.71, "text": ""},
+			{"file_id": "file-3", "filename": "terms.md", "score": 0.64, "text": "Digital goods are non-refundable."}
+		]},
+		{"type": "web_search_call", "id": "ws_001", "status": "completed", "action": {"type": "search", "query": "refund law"}},
+		{"type": "computer_call", "id": "cu_001", "call_id": "call_cu", "action": {"type": "screenshot"}},
+		{"type": "computer_call_output", "call_id": "call_cu", "output": {"type": "computer_screenshot", "image_url": "data:image/png;base64,iVBORw0KGgo="}}
+	]
+}`
+
+func TestOpenAI_ExtractToolOutput_FileSearchResults(t *testing.T) {
+	adapter := adapters.NewOpenAIAdapter()
+
+	extracted, err := adapter.ExtractToolOutput([]byte(builtinToolItemsBody))
+
+	require.NoError(t, err)
+	require.Len(t, extracted, 2, "empty results and web_search_call carry no text")
+	assert.Equal(t, "fs_001", extracted[0].ID)
+	assert.Equal(t, "file_search", extracted[0].ToolName)
+	assert.Equal(t, "Refunds are issued within 30 days.", extracted[0].Content)
+	assert.Equal(t, 1, extracted[0].MessageIndex)
+	assert.Equal(t, 0, extracted[0].BlockIndex)
+	assert.Equal(t, "Digital goods are non-refundable.", extracted[1].Content)
+	assert.Equal(t, 2, extracted[1].BlockIndex)
+}
+
+func TestOpenAI_ApplyToolOutput_FileSearchResults(t *testing.T) {
+	adapter := adapters.NewOpenAIAdapter()
+
+	modified, err := adapter.ApplyToolOutput([]byte(builtinToolItemsBody), []adapters.CompressedResult{
+		{ID: "fs_001", Compressed: "Refunds: 30 days.", MessageIndex: 1, BlockIndex: 0},
+		{ID: "fs_001", Compressed: "Digital: no refunds.", MessageIndex: 1, BlockIndex: 2},
+	})
+
+	require.NoError(t, err)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(modified, &req))
+
+	item := req["input"].([]any)[1].(map[string]any)
+	results := item["results"].([]any)
+	assert.Equal(t, "Refunds: 30 days.", results[0].(map[string]any)["text"])
+	assert.Equal(t, "Digital: no refunds.", results[2].(map[string]any)["text"])
+	assert.Equal(t, "policy.md", results[0].(map[string]any)["filename"], "other result fields are kept")
+	assert.NotContains(t, item, "output")
+}
+
+const submitToolOutputsBody = `{
+	"stream": true,
+	"tool_outputs": [
+		{"tool_call_id": "call_001", "output": "first output"},
+		{"tool_call_id": "call_002", "output": ""},
+		{"tool_call_id": "call_003", "output": "third output"}
+	]
+}`
+
+func TestOpenAI_ExtractToolOutput_AssistantsToolOutputs(t *testing.T) {
+	adapter := adapters.NewOpenAIAdapter()
+
+	extracted, err := adapter.ExtractToolOutput([]byte(submitToolOutputsBody))
+
+	require.NoError(t, err)
+	require.Len(t, extracted, 2, "empty outputs are skipped")
+	assert.Equal(t, "call_001", extracted[0].ID)
+	assert.Equal(t, "first output", extracted[0].Content)
+	assert.Equal(t, 0, extracted[0].MessageIndex)
+	assert.Empty(t, extracted[0].ToolName, "submitted outputs do not name the tool")
+	assert.Equal(t, "call_003", extracted[1].ID)
+	assert.Equal(t, 2, extracted[1].MessageIndex)
+}
+
+func TestOpenAI_ApplyToolOutput_AssistantsToolOutputs(t *testing.T) {
+	adapter := adapters.NewOpenAIAdapter()
+
+	modified, err := adapter.ApplyToolOutput([]byte(submitToolOutputsBody), []adapters.CompressedResult{
+		{ID: "call_003", Compressed: "third, compressed", MessageIndex: 2},
+	})
+
+	require.NoError(t, err)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(modified, &req))
+
+	outputs := req["tool_outputs"].([]any)
+	assert.Equal(t, "first output", outputs[0].(map[string]any)["output"])
+	assert.Equal(t, "third, compressed", outputs[2].(map[string]any)["output"])
+	assert.Equal(t, "call_003", outputs[2].(map[string]any)["tool_call_id"])
+	assert.Equal(t, true, req["stream"])
+}
+
+func TestOpenAI_ExtractToolImages_ComputerScreenshot(t *testing.T) {
+	adapter := adapters.NewOpenAIAdapter()
+
+	images := adapter.ExtractToolImages([]byte(builtinToolItemsBody))
+
+	require.Len(t, images, 1)
+	assert.Equal(t, "call_cu", images[0].ToolCallID)
+	assert.Equal(t, "image/png", images[0].MediaType)
+
+	images[0].Data = []byte("thumb")
+	modified, err := adapter.ApplyToolImages([]byte(builtinToolItemsBody), images)
+	require.NoError(t, err)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(modified, &req))
+	output := req["input"].([]any)[4].(map[string]any)["output"].(map[string]any)
+	assert.Equal(t, "computer_screenshot", output["type"])
+	assert.Equal(t, "data:image/png;base64,dGh1bWI=", output["image_url"])
+}
+
 // =============================================================================
 // OPENAI TOOL DISCOVERY TESTS
 // =============================================================================
@@ -0,0 +1,155 @@
+// OpenRouter Integration Tests - Mock Upstream
+//
+// Requests with an sk-or- key are handled by the OpenRouter adapter. These
+// tests check that the model id and app attribution headers reach OpenRouter
+// unchanged, and that the credits OpenRouter reports in usage.cost are what
+// the cost tracker records, for JSON and streamed responses.
+
+package integration
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+const openRouterUsage = `"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,"cost":0.0123}`
+
+type openRouterRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newOpenRouterMock(t *testing.T) (*httptest.Server, func() []openRouterRequest) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		captured []openRouterRequest
+	)
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		captured = append(captured, openRouterRequest{r.Header.Clone(), body})
+		mu.Unlock()
+		if gjson.GetBytes(body, "stream").Bool() {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, `data: {"id":"gen-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hello."},"finish_reason":"stop"}]}`+"\n\n")
+			_, _ = io.WriteString(w, `data: {"id":"gen-1","object":"chat.completion.chunk","choices":[],`+openRouterUsage+"}\n\n")
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"gen-1","object":"chat.completion","model":"anthropic/claude-3.5-sonnet",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Hello."},"finish_reason":"stop"}],`+openRouterUsage+`}`)
+	}))
+	t.Cleanup(mock.Close)
+	return mock, func() []openRouterRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]openRouterRequest(nil), captured...)
+	}
+}
+
+func newOpenRouterGateway(t *testing.T) (*gateway.Gateway, *httptest.Server) {
+	t.Helper()
+	return newOpenRouterGatewayWith(t, config.ModelRewriteConfig{})
+}
+
+func newOpenRouterGatewayWith(t *testing.T, rewrite config.ModelRewriteConfig) (*gateway.Gateway, *httptest.Server) {
+	t.Helper()
+	gw := gateway.New(&config.Config{
+		Server: config.ServerConfig{
+			Port:         18080,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 120 * time.Second,
+		},
+		Pipes: config.PipesConfig{
+			ToolOutput: config.ToolOutputPipeConfig{Strategy: "passthrough", FallbackStrategy: "passthrough"},
+		},
+		Store:        config.StoreConfig{Type: "memory", TTL: time.Hour},
+		CostControl:  config.CostControlConfig{Enabled: true},
+		Monitoring:   config.MonitoringConfig{LogLevel: "disabled", LogFormat: "json", LogOutput: "discard"},
+		ModelRewrite: rewrite,
+	})
+	srv := httptest.NewServer(gw.Handler())
+	t.Cleanup(srv.Close)
+	return gw, srv
+}
+
+func postOpenRouterChat(t *testing.T, gwURL, targetURL string, stream bool) {
+	t.Helper()
+	body := `{"model":"anthropic/claude-3.5-sonnet","messages":[{"role":"user","content":"Say hello."}]`
+	if stream {
+		body += `,"stream":true`
+	}
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/chat/completions", strings.NewReader(body+"}"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-or-v1-test")
+	req.Header.Set("HTTP-Referer", "https://myapp.example")
+	req.Header.Set("X-Title", "My App")
+	req.Header.Set(gateway.HeaderTargetURL, targetURL+"/api/v1/chat/completions")
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(respBody))
+}
+
+func TestIntegration_OpenRouter_ForwardsModelAndAttribution(t *testing.T) {
+	mock, requests := newOpenRouterMock(t)
+	_, srv := newOpenRouterGateway(t)
+
+	postOpenRouterChat(t, srv.URL, mock.URL, false)
+
+	got := requests()
+	require.Len(t, got, 1)
+	assert.Equal(t, "anthropic/claude-3.5-sonnet", gjson.GetBytes(got[0].body, "model").String(),
+		"OpenRouter routes by the vendor prefix, so the default stripping is skipped")
+	assert.Equal(t, "https://myapp.example", got[0].header.Get("HTTP-Referer"))
+	assert.Equal(t, "My App", got[0].header.Get("X-Title"))
+}
+
+func TestIntegration_OpenRouter_CreditsRecorded(t *testing.T) {
+	mock, _ := newOpenRouterMock(t)
+	gw, srv := newOpenRouterGateway(t)
+
+	postOpenRouterChat(t, srv.URL, mock.URL, false)
+
+	// The token estimate for claude-3-5-sonnet would be 1000*$3/M + 100*$15/M = $0.0045
+	assert.InDelta(t, 0.0123, gw.CostTracker().GetGlobalCost(), 1e-9, "OpenRouter's credits replace the token estimate")
+}
+
+func TestIntegration_OpenRouter_StreamCreditsRecorded(t *testing.T) {
+	mock, _ := newOpenRouterMock(t)
+	gw, srv := newOpenRouterGateway(t)
+
+	postOpenRouterChat(t, srv.URL, mock.URL, true)
+
+	require.Eventually(t, func() bool { return gw.CostTracker().GetGlobalCost() > 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, 0.0123, gw.CostTracker().GetGlobalCost(), 1e-9, "usage.cost from the final chunk")
+}
+
+func TestIntegration_OpenRouter_ConfiguredRewriteRulesApply(t *testing.T) {
+	mock, requests := newOpenRouterMock(t)
+	_, srv := newOpenRouterGatewayWith(t, config.ModelRewriteConfig{Rules: []config.ModelRewriteRule{
+		{Match: "anthropic/claude-3.5-sonnet", To: "anthropic/claude-sonnet-4.5"},
+	}})
+
+	postOpenRouterChat(t, srv.URL, mock.URL, false)
+
+	got := requests()
+	require.Len(t, got, 1)
+	assert.Equal(t, "anthropic/claude-sonnet-4.5", gjson.GetBytes(got[0].body, "model").String())
+}
@@ -0,0 +1,23 @@
+package integration
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	log.Logger = zerolog.New(io.Discard)
+}
+
+func TestMain(m *testing.M) {
+	godotenv.Load("../../../.env")
+	gateway.EnableLocalHostsForTesting()
+	os.Exit(m.Run())
+}
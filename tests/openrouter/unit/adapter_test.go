@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/costcontrol"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// BASIC ADAPTER PROPERTIES
+// =============================================================================
+
+func TestOpenRouter_Name(t *testing.T) {
+	adapter := adapters.NewOpenRouterAdapter()
+	assert.Equal(t, "openrouter", adapter.Name())
+}
+
+func TestOpenRouter_Provider(t *testing.T) {
+	adapter := adapters.NewOpenRouterAdapter()
+	assert.Equal(t, adapters.ProviderOpenRouter, adapter.Provider())
+}
+
+// =============================================================================
+// USAGE EXTRACTION - OpenAI format plus usage.cost credits
+// =============================================================================
+
+func TestOpenRouter_ExtractUsage_Credits(t *testing.T) {
+	adapter := adapters.NewOpenRouterAdapter()
+
+	responseBody := []byte(`{
+		"id": "gen-123",
+		"object": "chat.completion",
+		"model": "anthropic/claude-3.5-sonnet",
+		"choices": [{"message": {"role": "assistant", "content": "Hello!"}}],
+		"usage": {
+			"prompt_tokens": 150,
+			"completion_tokens": 60,
+			"total_tokens": 210,
+			"cost": 0.00135,
+			"prompt_tokens_details": {"cached_tokens": 100}
+		}
+	}`)
+
+	usage := adapter.ExtractUsage(responseBody)
+
+	assert.Equal(t, 50, usage.InputTokens)
+	assert.Equal(t, 100, usage.CacheReadInputTokens)
+	assert.Equal(t, 60, usage.OutputTokens)
+	assert.Equal(t, 210, usage.TotalTokens)
+	assert.InDelta(t, 0.00135, usage.CostUSD, 1e-12)
+}
+
+func TestOpenRouter_ExtractUsage_NoCost(t *testing.T) {
+	adapter := adapters.NewOpenRouterAdapter()
+
+	usage := adapter.ExtractUsage([]byte(`{"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}}`))
+	assert.Equal(t, 15, usage.TotalTokens)
+	assert.Zero(t, usage.CostUSD)
+
+	assert.Equal(t, adapters.UsageInfo{}, adapter.ExtractUsage([]byte{}))
+}
+
+// =============================================================================
+// MODEL NAMING
+// =============================================================================
+
+func TestOpenRouter_ModelName(t *testing.T) {
+	for id, want := range map[string]string{
+		"anthropic/claude-3.5-sonnet":            "claude-3-5-sonnet",
+		"anthropic/claude-sonnet-4.5":            "claude-sonnet-4-5",
+		"anthropic/claude-3.7-sonnet:thinking":   "claude-3-7-sonnet",
+		"openrouter/anthropic/claude-3.5-sonnet": "claude-3-5-sonnet",
+		"openai/gpt-4o":                          "gpt-4o",
+		"openai/gpt-4.1-mini":                    "gpt-4.1-mini",
+		"google/gemini-2.5-pro":                  "gemini-2.5-pro",
+		"meta-llama/llama-3.1-70b-instruct:free": "llama-3.1-70b-instruct",
+		"gpt-4o":                                 "gpt-4o",
+	} {
+		assert.Equal(t, want, adapters.OpenRouterModelName(id), id)
+	}
+}
+
+func TestOpenRouter_ExtractModel(t *testing.T) {
+	adapter := adapters.NewOpenRouterAdapter()
+
+	assert.Equal(t, "claude-sonnet-4-5", adapter.ExtractModel([]byte(`{"model": "anthropic/claude-sonnet-4.5", "messages": []}`)))
+	assert.Empty(t, adapter.ExtractModel([]byte{}))
+	assert.Empty(t, adapter.ExtractModel([]byte(`{}`)))
+}
+
+func TestOpenRouter_ModelNamePricing(t *testing.T) {
+	// Mapped names are priced as the native model
+	for id, input := range map[string]float64{
+		"anthropic/claude-3.5-sonnet": 3,
+		"anthropic/claude-sonnet-4.5": 3,
+		"openai/gpt-4o":               2.5,
+	} {
+		pricing := costcontrol.GetModelPricing(adapters.OpenRouterModelName(id))
+		assert.Equal(t, input, pricing.InputPerMTok, id)
+	}
+}
+
+// =============================================================================
+// PROVIDER DETECTION
+// =============================================================================
+
+func TestOpenRouter_ProviderDetection(t *testing.T) {
+	registry := adapters.NewRegistry()
+
+	for name, headers := range map[string]map[string]string{
+		"sk-or- key": {"Authorization": "Bearer sk-or-v1-abc"},
+		"target URL": {"X-Target-URL": "https://openrouter.ai/api/v1/chat/completions"},
+	} {
+		h := http.Header{}
+		for k, v := range headers {
+			h.Set(k, v)
+		}
+		provider, adapter := adapters.IdentifyAndGetAdapter(registry, "/v1/chat/completions", h)
+		assert.Equal(t, adapters.ProviderOpenRouter, provider, name)
+		assert.Equal(t, "openrouter", adapter.Name(), name)
+	}
+}
+
+func TestOpenRouter_ProviderDetection_NotOpenRouter(t *testing.T) {
+	registry := adapters.NewRegistry()
+
+	// Attribution headers go to other providers too; an sk- key is sent to OpenAI
+	h := http.Header{}
+	h.Set("Authorization", "Bearer sk-proj-abc")
+	h.Set("HTTP-Referer", "https://myapp.example")
+	h.Set("X-Title", "My App")
+	provider, _ := adapters.IdentifyAndGetAdapter(registry, "/v1/chat/completions", h)
+	assert.Equal(t, adapters.ProviderOpenAI, provider)
+
+	// Only the openrouter.ai host counts, not a lookalike
+	h = http.Header{}
+	h.Set("X-Target-URL", "https://openrouter.ai.example.com/v1/chat/completions")
+	provider, _ = adapters.IdentifyAndGetAdapter(registry, "/v1/chat/completions", h)
+	assert.Equal(t, adapters.ProviderOpenAI, provider)
+
+	// Anthropic-format requests stay Anthropic
+	h = http.Header{}
+	h.Set("anthropic-version", "2023-06-01")
+	h.Set("X-Title", "My App")
+	h.Set("HTTP-Referer", "https://myapp.example")
+	provider, _ = adapters.IdentifyAndGetAdapter(registry, "/v1/messages", h)
+	assert.Equal(t, adapters.ProviderAnthropic, provider)
+}
+
+// =============================================================================
+// PROVIDER FROM STRING
+// =============================================================================
+
+func TestOpenRouter_ProviderFromString(t *testing.T) {
+	assert.Equal(t, adapters.ProviderOpenRouter, adapters.ProviderFromString("openrouter"))
+}
@@ -0,0 +1,190 @@
+// Streaming expand_context Integration Tests
+//
+// With server.stream_interception: optimistic, text streams to the client
+// before the model finishes; an expand_context call at the end of the turn is
+// still intercepted and the follow-up response continues the stream.
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+var shadowIDPattern = regexp.MustCompile(`shadow_[0-9a-f]+`)
+
+func sseEvent(data string) string {
+	return "event: " + gjson.Get(data, "type").String() + "\ndata: " + data + "\n\n"
+}
+
+// streamingExpandUpstream answers the first request with text followed by an
+// expand_context call, sent only after release is closed, and the retry with
+// plain text.
+type streamingExpandUpstream struct {
+	*httptest.Server
+	release chan struct{}
+
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func newStreamingExpandUpstream(t *testing.T) *streamingExpandUpstream {
+	t.Helper()
+	u := &streamingExpandUpstream{release: make(chan struct{})}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies = append(u.bodies, body)
+		call := len(u.bodies)
+		u.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		if call > 1 {
+			fmt.Fprint(w, sseEvent(`{"type":"message_start","message":{"id":"msg_2","usage":{"input_tokens":120}}}`)+
+				sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)+
+				sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The log shows three errors."}}`)+
+				sseEvent(`{"type":"content_block_stop","index":0}`)+
+				sseEvent(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":8}}`)+
+				sseEvent(`{"type":"message_stop"}`))
+			return
+		}
+
+		shadowID := shadowIDPattern.FindString(string(body))
+		fmt.Fprint(w, sseEvent(`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":100}}}`)+
+			sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)+
+			sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me read the full log."}}`)+
+			sseEvent(`{"type":"content_block_stop","index":0}`))
+		flusher.Flush()
+
+		select {
+		case <-u.release:
+		case <-time.After(5 * time.Second):
+		}
+		input, _ := json.Marshal(map[string]string{"id": shadowID})
+		partial, _ := json.Marshal(string(input))
+		fmt.Fprint(w, sseEvent(`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_expand_1","name":"expand_context","input":{}}}`)+
+			sseEvent(`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":`+string(partial)+`}}`)+
+			sseEvent(`{"type":"content_block_stop","index":1}`)+
+			sseEvent(`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`)+
+			sseEvent(`{"type":"message_stop"}`))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *streamingExpandUpstream) requests() [][]byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([][]byte(nil), u.bodies...)
+}
+
+func sendStreamingExpandRequest(t *testing.T, gwURL, targetURL, interception string) *http.Response {
+	t.Helper()
+	body := anthropicRequestWithToolResult(largeToolOutput(1000))
+	body["model"] = "claude-sonnet-4-5" // Compression is skipped for budget models
+	body["stream"] = true
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, gwURL+"/v1/messages", bytes.NewReader(raw))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", "sk-ant-test-key")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("X-Target-URL", targetURL+"/v1/messages")
+	if interception != "" {
+		req.Header.Set(gateway.HeaderStreamInterception, interception)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestIntegration_StreamingExpand_Optimistic(t *testing.T) {
+	upstream := newStreamingExpandUpstream(t)
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	resp := sendStreamingExpandRequest(t, gw.URL, upstream.URL, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The text arrives while the model is still generating.
+	reader := bufio.NewReader(resp.Body)
+	var early strings.Builder
+	for !strings.Contains(early.String(), "Let me read the full log.") {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err, "text was not streamed before the turn ended")
+		early.WriteString(line)
+	}
+	close(upstream.release)
+
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	stream := early.String() + string(rest)
+
+	requests := upstream.requests()
+	require.Len(t, requests, 2, "expand_context must be answered with a retry")
+	assert.NotContains(t, string(requests[0]), "Line 3:")
+	assert.Contains(t, string(requests[1]), "Line 3:", "the retry carries the expanded tool output")
+	assert.NotContains(t, stream, "expand_context")
+	assert.Contains(t, stream, "The log shows three errors.")
+	assert.Equal(t, 1, strings.Count(stream, "event: message_start"), "the retry continues the same message")
+	assert.Equal(t, 1, strings.Count(stream, "event: message_stop"))
+	assert.Contains(t, stream, `"index":1,"content_block":{"type":"text"`, "retry blocks follow the streamed ones")
+	assert.Contains(t, stream, `"stop_reason":"end_turn"`)
+}
+
+func TestIntegration_StreamingExpand_BufferedPerRequest(t *testing.T) {
+	upstream := newStreamingExpandUpstream(t)
+	close(upstream.release)
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	resp := sendStreamingExpandRequest(t, gw.URL, upstream.URL, config.StreamInterceptionBuffered)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	stream, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Len(t, upstream.requests(), 2)
+	assert.NotContains(t, string(stream), "Let me read the full log.", "buffered mode replaces the intercepted response")
+	assert.NotContains(t, string(stream), "expand_context")
+	assert.Contains(t, string(stream), "The log shows three errors.")
+}
+
+func TestIntegration_StreamingExpand_NoPhantomCallReleasesHeldEvents(t *testing.T) {
+	stream := sseEvent(`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":100}}}`) +
+		sseEvent(`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`) +
+		sseEvent(`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"app.log\"}"}}`) +
+		sseEvent(`{"type":"content_block_stop","index":0}`) +
+		sseEvent(`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`) +
+		sseEvent(`{"type":"message_stop"}`)
+	mock := newMockLLM(func(_ []byte, _ int) []byte { return []byte(stream) })
+	defer mock.close()
+	gw := createGateway(expandContextConfig())
+	defer gw.Close()
+
+	resp := sendStreamingExpandRequest(t, gw.URL, mock.url(), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, stream, string(got))
+	assert.Equal(t, 1, mock.requestCount())
+}
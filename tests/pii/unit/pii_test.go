@@ -0,0 +1,174 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	"github.com/compresr/context-gateway/internal/pipes/pii"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+func newPIIPipe(t *testing.T, pc pipes.PIIConfig) (*pii.Pipe, store.Store) {
+	t.Helper()
+	pc.Enabled = true
+	st := store.NewMemoryStore(time.Hour)
+	return pii.New(&config.Config{Pipes: pipes.Config{PII: pc}}, st), st
+}
+
+func TestMaskText_BuiltinDetectors(t *testing.T) {
+	p, _ := newPIIPipe(t, pipes.PIIConfig{})
+	tests := []struct {
+		name   string
+		text   string
+		kind   string // expected token kind; "" = nothing masked
+		secret string
+	}{
+		{name: "email", text: "mail jane.doe@example.com now", kind: "EMAIL", secret: "jane.doe@example.com"},
+		{name: "phone", text: "call +1 415-555-0132 today", kind: "PHONE", secret: "415-555-0132"},
+		{name: "ssn", text: "ssn is 123-45-6789.", kind: "SSN", secret: "123-45-6789"},
+		{name: "invalid ssn area", text: "ref 000-12-3456 only", kind: ""},
+		{name: "luhn card", text: "card 4111 1111 1111 1111 ok", kind: "CREDIT_CARD", secret: "4111 1111 1111 1111"},
+		{name: "non-luhn number", text: "order 4111111111111112 ok", kind: ""},
+		{name: "iban", text: "pay GB82 WEST 1234 5698 7654 32 now", kind: "IBAN", secret: "GB82 WEST 1234 5698 7654 32"},
+		{name: "bad iban checksum", text: "pay GB00 WEST 1234 5698 7654 32 now", kind: ""},
+		{name: "ipv4", text: "host 10.20.30.40 down", kind: "IPV4", secret: "10.20.30.40"},
+		{name: "loopback ignored", text: "host 127.0.0.1 up", kind: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masked, n, err := p.MaskText(context.Background(), tt.text)
+			require.NoError(t, err)
+			if tt.kind == "" {
+				assert.Equal(t, 0, n)
+				assert.Equal(t, tt.text, masked)
+				return
+			}
+			assert.Equal(t, 1, n)
+			assert.NotContains(t, masked, tt.secret)
+			assert.Contains(t, masked, "[[PII_"+tt.kind+"_")
+		})
+	}
+}
+
+func TestMaskText_OnlyConfiguredDetectors(t *testing.T) {
+	p, _ := newPIIPipe(t, pipes.PIIConfig{Detectors: []string{pipes.PIIDetectorEmail}})
+	masked, n, err := p.MaskText(context.Background(), "a@b.io and 123-45-6789")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Contains(t, masked, "123-45-6789")
+}
+
+func TestProcess_MasksConversationNotStructure(t *testing.T) {
+	p, st := newPIIPipe(t, pipes.PIIConfig{})
+	body := []byte(`{"model":"claude-sonnet-4","system":"Operator: ops@corp.example",` +
+		`"messages":[{"role":"user","content":[{"type":"text","text":"email me at jane@example.com"}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"owner jane@example.com"}]}]}`)
+
+	ctx := pipes.NewPipeContext(nil, body)
+	out, err := p.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, ctx.PIIMasked)
+	assert.NotContains(t, string(out), "@example")
+	assert.Equal(t, "claude-sonnet-4", gjson.GetBytes(out, "model").String())
+	assert.Equal(t, "toolu_1", gjson.GetBytes(out, "messages.1.content.0.tool_use_id").String())
+
+	// Same value → same token, so history stays byte-stable across turns.
+	first := gjson.GetBytes(out, "messages.0.content.0.text").String()
+	second := gjson.GetBytes(out, "messages.1.content.0.content").String()
+	token := strings.TrimPrefix(first, "email me at ")
+	assert.Equal(t, "owner "+token, second)
+
+	value, ok := st.Get(pii.StoreKey(token))
+	require.True(t, ok)
+	assert.Equal(t, "jane@example.com", value)
+
+	again, err := p.Process(pipes.NewPipeContext(nil, body))
+	require.NoError(t, err)
+	assert.Equal(t, out, again)
+}
+
+func TestProcess_DetectorErrorFailsClosed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p, _ := newPIIPipe(t, pipes.PIIConfig{API: pipes.PIIAPIConfig{URL: srv.URL}})
+	_, err := p.Process(pipes.NewPipeContext(nil, []byte(`{"messages":[{"role":"user","content":"hello there"}]}`)))
+	assert.ErrorContains(t, err, "status 500")
+}
+
+func TestProcess_APIDetector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer k", r.Header.Get("Authorization"))
+		var req struct{ Text string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		start := strings.Index(req.Text, "Alice")
+		if start < 0 {
+			_, _ = w.Write([]byte(`{"entities":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"entities": []map[string]any{{"start": start, "end": start + 5, "type": "person name"}},
+		})
+	}))
+	defer srv.Close()
+
+	p, _ := newPIIPipe(t, pipes.PIIConfig{
+		Detectors: []string{pipes.PIIDetectorEmail},
+		API:       pipes.PIIAPIConfig{URL: srv.URL, APIKey: "k"},
+	})
+	masked, n, err := p.MaskText(context.Background(), "ask Alice about it")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Contains(t, masked, "[[PII_PERSON_NAME_")
+}
+
+func TestUnmasker_RestoresKnownTokens(t *testing.T) {
+	p, st := newPIIPipe(t, pipes.PIIConfig{})
+	masked, _, err := p.MaskText(context.Background(), `say "x@y.io"`)
+	require.NoError(t, err)
+
+	um := pii.NewUnmasker(st)
+	assert.Equal(t, `say "x@y.io"`, um.Unmask(masked, false))
+
+	foreign := pii.Token("EMAIL", "never-stored@x.io")
+	assert.Equal(t, foreign, um.Unmask(foreign, false))
+
+	body, _ := json.Marshal(map[string]string{"text": masked})
+	assert.Equal(t, `say "x@y.io"`, gjson.GetBytes(um.UnmaskJSON(body), "text").String())
+}
+
+func TestStreamUnmasker_HoldsBackSplitToken(t *testing.T) {
+	p, st := newPIIPipe(t, pipes.PIIConfig{})
+	masked, _, err := p.MaskText(context.Background(), "hi a@b.io!")
+	require.NoError(t, err)
+
+	su := pii.NewStreamUnmasker(st)
+	var out strings.Builder
+	for i := 0; i < len(masked); i += 4 {
+		out.WriteString(su.Push("k", masked[i:min(i+4, len(masked))], false))
+	}
+	out.WriteString(su.Flush("k"))
+	assert.Equal(t, "hi a@b.io!", out.String())
+	assert.False(t, su.HasPending())
+}
+
+func TestStreamUnmasker_ReleasesNonTokenBrackets(t *testing.T) {
+	su := pii.NewStreamUnmasker(store.NewMemoryStore(time.Hour))
+	assert.Equal(t, "a", su.Push("k", "a[", false))
+	assert.Equal(t, "[x] b", su.Push("k", "x] b", false))
+	assert.Equal(t, "", su.Push("k", "[[PI", false))
+	assert.Equal(t, "[[PIZZA]]", su.Push("k", "ZZA]]", false))
+}
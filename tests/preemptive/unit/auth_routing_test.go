@@ -481,3 +481,34 @@ func TestCapturedAuth_HasAuth_PriorityRules(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// AuthSource (reported by GET /health/ready)
+// ---------------------------------------------------------------------------
+
+func TestSummarizer_AuthSource(t *testing.T) {
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{Strategy: preemptive.StrategyExternalProvider, Model: "claude-haiku-4-5"})
+	assert.Empty(t, s.AuthSource(), "no key and nothing captured")
+	s.SetAuth(authtypes.CapturedAuth{Token: "sk-ant-oat01-captured"})
+	assert.Equal(t, preemptive.AuthSourceCaptured, s.AuthSource())
+
+	s = preemptive.NewSummarizer(preemptive.SummarizerConfig{Strategy: preemptive.StrategyExternalProvider, ProviderKey: "sk-ant-key"})
+	assert.Equal(t, preemptive.AuthSourceAPIKey, s.AuthSource())
+
+	s = preemptive.NewSummarizer(preemptive.SummarizerConfig{Strategy: preemptive.StrategyOpenAICompatible, BaseURL: "http://localhost:11434/v1"})
+	assert.Equal(t, preemptive.AuthSourceNotRequired, s.AuthSource())
+
+	s = preemptive.NewSummarizer(preemptive.SummarizerConfig{Strategy: preemptive.StrategyCompresr, Compresr: &preemptive.CompresrConfig{}})
+	assert.Empty(t, s.AuthSource(), "compresr strategy needs its own api_key")
+}
+
+func TestManager_SummarizerAuth(t *testing.T) {
+	source, enabled := preemptive.NewManager(createTestConfig()).SummarizerAuth()
+	assert.True(t, enabled)
+	assert.Equal(t, preemptive.AuthSourceAPIKey, source)
+
+	cfg := createTestConfig()
+	cfg.Enabled = false
+	_, enabled = preemptive.NewManager(cfg).SummarizerAuth()
+	assert.False(t, enabled)
+}
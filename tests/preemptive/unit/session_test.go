@@ -413,3 +413,71 @@ func TestSessionManager_MultipleCompactionsWithoutNewMessages(t *testing.T) {
 	assert.Equal(t, 5, session.CompactionUseCount)
 	assert.Equal(t, "Summary", session.Summary) // Summary still available!
 }
+
+// =============================================================================
+// BRANCH FORK TESTS
+// =============================================================================
+
+func TestSessionManager_ForkSession_InheritsSummaryOfSharedAncestry(t *testing.T) {
+	sm := preemptive.NewSessionManager(preemptive.SessionConfig{SummaryTTL: 2 * time.Hour})
+	defer sm.Close()
+
+	sm.GetOrCreateSession("root", "claude-sonnet-4-5", 100000)
+	require.NoError(t, sm.SetSummaryReady("root", "summary of 0..4", 50, 4, 10))
+
+	// Fork after the summarized range: the summary describes shared history.
+	sm.ForkSession("root", "root-bafter", 6)
+	child := sm.Get("root-bafter")
+	require.NotNil(t, child)
+	assert.Equal(t, preemptive.StateReady, child.State)
+	assert.Equal(t, "summary of 0..4", child.Summary)
+	assert.Equal(t, 4, child.SummaryMessageIndex)
+	assert.Equal(t, 100000, child.MaxContextTokens)
+
+	// Fork inside the summarized range: the summary covers replaced messages.
+	sm.ForkSession("root", "root-binside", 3)
+	child = sm.Get("root-binside")
+	require.NotNil(t, child)
+	assert.Equal(t, preemptive.StateIdle, child.State)
+	assert.Empty(t, child.Summary)
+
+	// The parent keeps its own summary.
+	assert.Equal(t, "summary of 0..4", sm.Get("root").Summary)
+}
+
+func TestSessionManager_ForkSession_KeepsExistingChild(t *testing.T) {
+	sm := preemptive.NewSessionManager(preemptive.SessionConfig{SummaryTTL: 2 * time.Hour})
+	defer sm.Close()
+
+	sm.GetOrCreateSession("root", "m", 1000)
+	require.NoError(t, sm.SetSummaryReady("root", "root summary", 5, 1, 4))
+	sm.GetOrCreateSession("root-bx", "m", 1000)
+	require.NoError(t, sm.SetSummaryReady("root-bx", "branch summary", 5, 3, 6))
+
+	sm.ForkSession("root", "root-bx", 5)
+	assert.Equal(t, "branch summary", sm.Get("root-bx").Summary)
+
+	sm.ForkSession("missing", "orphan", 2)
+	assert.Nil(t, sm.Get("orphan"))
+}
+
+func TestSessionManager_FindBestMatchingSession_StaysWithinTenant(t *testing.T) {
+	sm := preemptive.NewSessionManager(preemptive.SessionConfig{
+		SummaryTTL:       2 * time.Hour,
+		HashMessageCount: 3,
+	})
+	for _, id := range []string{"research:abc", "plain"} {
+		sm.GetOrCreateSession(id, "claude-sonnet-4-5", 200000)
+		require.NoError(t, sm.SetSummaryReady(id, "summary", 100, 10, 10))
+	}
+
+	match := sm.FindBestMatchingSession(12, "claude-sonnet-4-5", "", "research")
+	require.NotNil(t, match)
+	assert.Equal(t, "research:abc", match.Session.ID)
+
+	match = sm.FindBestMatchingSession(12, "claude-sonnet-4-5", "", "")
+	require.NotNil(t, match)
+	assert.Equal(t, "plain", match.Session.ID, "requests without a tenant never match tenant sessions")
+
+	assert.Nil(t, sm.FindBestMatchingSession(12, "claude-sonnet-4-5", "", "support"))
+}
@@ -0,0 +1,179 @@
+package preemptive_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// localRequest is what the mock OpenAI-compatible server received.
+type localRequest struct {
+	Path          string
+	Authorization string
+	Body          map[string]any
+}
+
+// mockLocalServer mimics an OpenAI-compatible /v1/chat/completions endpoint.
+func mockLocalServer(t *testing.T, got *localRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Path = r.URL.Path
+		got.Authorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got.Body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":     "chatcmpl-local",
+			"object": "chat.completion",
+			"model":  "llama3.1:8b",
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": "Local summary of the session."},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]any{"prompt_tokens": 120, "completion_tokens": 7, "total_tokens": 127},
+		})
+	}))
+}
+
+func localInput() preemptive.SummarizeInput {
+	return preemptive.SummarizeInput{
+		Messages: []json.RawMessage{
+			makeMessage("user", "Refactor the parser"),
+			makeMessage("assistant", "Done, split into lexer and parser"),
+			makeMessage("user", "Now add tests"),
+			makeMessage("assistant", "Added table-driven tests"),
+		},
+		KeepRecentCount: 1,
+		// Captured client credentials must never reach the local server.
+		Auth: authtypes.CapturedAuth{Token: "sk-ant-client-key", IsXAPIKey: true},
+	}
+}
+
+// =============================================================================
+// OPENAI-COMPATIBLE STRATEGY
+// =============================================================================
+
+func TestSummarizer_OpenAICompatible_CallsLocalServer(t *testing.T) {
+	var got localRequest
+	server := mockLocalServer(t, &got)
+	defer server.Close()
+
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:         preemptive.StrategyOpenAICompatible,
+		BaseURL:          server.URL + "/v1/",
+		Model:            "llama3.1:8b",
+		MaxTokens:        512,
+		Timeout:          5 * time.Second,
+		KeepRecentTokens: 1,
+		PromptTemplate:   "Summarize for handoff:\n{{conversation}}\nEnd.",
+	})
+
+	out, err := s.Summarize(context.Background(), localInput())
+	require.NoError(t, err)
+
+	assert.Equal(t, "Local summary of the session.", out.Summary)
+	assert.Equal(t, 7, out.SummaryTokens)
+	assert.Equal(t, 120, out.InputTokens)
+
+	assert.Equal(t, "/v1/chat/completions", got.Path)
+	assert.Empty(t, got.Authorization, "no api_key configured: no auth header, captured auth not forwarded")
+	assert.Equal(t, "llama3.1:8b", got.Body["model"])
+	assert.EqualValues(t, 512, got.Body["max_tokens"])
+	assert.NotContains(t, got.Body, "max_completion_tokens")
+
+	messages := got.Body["messages"].([]any)
+	require.Len(t, messages, 2)
+	user := messages[1].(map[string]any)["content"].(string)
+	assert.True(t, strings.HasPrefix(user, "Summarize for handoff:\n"), user)
+	assert.True(t, strings.HasSuffix(user, "\nEnd."), user)
+	assert.Contains(t, user, "Refactor the parser")
+	assert.NotContains(t, user, preemptive.ConversationPlaceholder)
+}
+
+func TestSummarizer_OpenAICompatible_SendsConfiguredKey(t *testing.T) {
+	var got localRequest
+	server := mockLocalServer(t, &got)
+	defer server.Close()
+
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:         preemptive.StrategyOpenAICompatible,
+		BaseURL:          server.URL + "/v1",
+		Model:            "qwen2.5",
+		ProviderKey:      "local-secret",
+		MaxTokens:        256,
+		Timeout:          5 * time.Second,
+		KeepRecentTokens: 1,
+	})
+
+	_, err := s.Summarize(context.Background(), localInput())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer local-secret", got.Authorization)
+}
+
+func TestRenderPromptTemplate(t *testing.T) {
+	assert.Equal(t, "Please summarize the following conversation:\n\nCONV",
+		preemptive.RenderPromptTemplate("", "CONV"))
+	assert.Equal(t, "A CONV B CONV",
+		preemptive.RenderPromptTemplate("A {{conversation}} B {{conversation}}", "CONV"))
+}
+
+func TestConfig_Validate_OpenAICompatible(t *testing.T) {
+	base := func() preemptive.Config {
+		return preemptive.Config{
+			Enabled:          true,
+			TriggerThreshold: 80.0,
+			Summarizer: preemptive.SummarizerConfig{
+				Strategy:  preemptive.StrategyOpenAICompatible,
+				BaseURL:   "http://localhost:11434/v1",
+				Model:     "llama3.1:8b",
+				MaxTokens: 4096,
+				Timeout:   60 * time.Second,
+			},
+			Session: preemptive.SessionConfig{SummaryTTL: time.Hour, HashMessageCount: 3},
+		}
+	}
+
+	cfg := base()
+	require.NoError(t, cfg.Validate(), "api_key is optional for local servers")
+
+	tests := []struct {
+		name   string
+		mutate func(*preemptive.SummarizerConfig)
+		errMsg string
+	}{
+		{"missing base_url", func(s *preemptive.SummarizerConfig) { s.BaseURL = "" }, "base_url is required"},
+		{"non-http base_url", func(s *preemptive.SummarizerConfig) { s.BaseURL = "file:///tmp/x" }, "base_url must be"},
+		{"missing model", func(s *preemptive.SummarizerConfig) { s.Model = "" }, "model is required"},
+		{"template without placeholder", func(s *preemptive.SummarizerConfig) { s.PromptTemplate = "Summarize this" }, "prompt_template"},
+		{"zero max_tokens", func(s *preemptive.SummarizerConfig) { s.MaxTokens = 0 }, "max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.mutate(&cfg.Summarizer)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	cfg = base()
+	model, provider := cfg.Summarizer.EffectiveModelAndProvider()
+	assert.Equal(t, "llama3.1:8b", model)
+	assert.Equal(t, preemptive.StrategyOpenAICompatible, provider)
+}
@@ -0,0 +1,209 @@
+package preemptive_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/preemptive"
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// sequenceStrategy records every request and answers with "summary-N".
+type sequenceStrategy struct {
+	mu   sync.Mutex
+	reqs []preemptive.SummaryRequest
+}
+
+func (q *sequenceStrategy) Summarize(_ context.Context, req preemptive.SummaryRequest) (preemptive.SummaryResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reqs = append(q.reqs, req)
+	return preemptive.SummaryResult{Summary: fmt.Sprintf("summary-%d", len(q.reqs)), OutputTokens: 10}, nil
+}
+
+func (q *sequenceStrategy) calls() []preemptive.SummaryRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]preemptive.SummaryRequest(nil), q.reqs...)
+}
+
+var (
+	rollingOnce     sync.Once
+	rollingStrategy = &sequenceStrategy{}
+)
+
+// rollingSummarizerConfig uses a strategy registered once for all rolling tests.
+func rollingSummarizerConfig() preemptive.SummarizerConfig {
+	rollingOnce.Do(func() {
+		preemptive.RegisterStrategy("test_rolling", func(preemptive.SummarizerConfig) (preemptive.SummaryStrategy, error) {
+			return rollingStrategy, nil
+		})
+	})
+	return preemptive.SummarizerConfig{Strategy: "test_rolling", KeepRecentCount: 1}
+}
+
+// turns returns n user/assistant messages, each padded to roughly size tokens.
+func turns(from, n, size int) []json.RawMessage {
+	msgs := make([]json.RawMessage, 0, n)
+	for i := from; i < from+n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		msgs = append(msgs, makeMessage(role, fmt.Sprintf("turn %d %s", i, strings.Repeat("word ", size))))
+	}
+	return msgs
+}
+
+// rollingConfig sizes the context window to 8 padded turns, so 6 turns are
+// over the 50% threshold and a summary plus 2 turns is under it.
+func rollingConfig() preemptive.Config {
+	cfg := createTestConfig()
+	cfg.TriggerThreshold = 50
+	cfg.TestContextWindowOverride = 8 * tokenizer.CountBytes(turns(0, 1, 200)[0])
+	cfg.Summarizer = rollingSummarizerConfig()
+	return cfg
+}
+
+func requestBody(t *testing.T, messages []json.RawMessage) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"model": "claude-sonnet-4-5", "messages": messages})
+	require.NoError(t, err)
+	return body
+}
+
+// =============================================================================
+// SUMMARIZER
+// =============================================================================
+
+func TestSummarizer_Rolling_SummarizesOnlyNewMessages(t *testing.T) {
+	rec := &recordingStrategy{}
+	preemptive.RegisterStrategy("test_rolling_summarizer", func(preemptive.SummarizerConfig) (preemptive.SummaryStrategy, error) {
+		return rec, nil
+	})
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{Strategy: "test_rolling_summarizer", KeepRecentCount: 1})
+
+	messages := turns(0, 8, 5)
+	out, err := s.Summarize(context.Background(), preemptive.SummarizeInput{
+		Messages:        messages,
+		KeepRecentCount: 1,
+		PreviousSummary: "Earlier: set up the repo.",
+		PreviousIndex:   3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 6, out.LastSummarizedIndex, "summary now covers everything but the kept message")
+
+	got := rec.got
+	assert.Equal(t, "Earlier: set up the repo.", got.PreviousSummary)
+	require.Len(t, got.Messages, 3, "only messages 4..6 are sent")
+	assert.Equal(t, string(messages[4]), string(got.Messages[0]))
+	assert.Equal(t, "Earlier: set up the repo.", got.Vars.PreviousSummary)
+	assert.Contains(t, got.Vars.Conversation, "Earlier: set up the repo.", "conversation starts with the previous summary")
+	assert.NotContains(t, got.Vars.Conversation, "turn 3 ")
+	assert.Contains(t, got.Vars.Conversation, "turn 4 ")
+	assert.Contains(t, got.UserPrompt, "updated summary")
+	assert.True(t, strings.HasPrefix(got.Vars.Task, "turn 0 "), "task still comes from the first user message")
+}
+
+func TestSummarizer_Rolling_NothingNew(t *testing.T) {
+	s := preemptive.NewSummarizer(rollingSummarizerConfig())
+	_, err := s.Summarize(context.Background(), preemptive.SummarizeInput{
+		Messages:        turns(0, 4, 5),
+		KeepRecentCount: 1,
+		PreviousSummary: "Earlier.",
+		PreviousIndex:   2,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not enough content to summarize")
+}
+
+// =============================================================================
+// MANAGER
+// =============================================================================
+
+func TestManager_RollingSummarization(t *testing.T) {
+	cfg := rollingConfig()
+	m := preemptive.NewManager(cfg)
+	defer m.Stop()
+
+	start := len(rollingStrategy.calls())
+	history := turns(0, 6, 200)
+	body := requestBody(t, history)
+	id := preemptive.RootSessionID(nil, http.Header{}, body)
+
+	waitSummary := func(want string) preemptive.Session {
+		var s preemptive.Session
+		require.Eventually(t, func() bool {
+			var ok bool
+			s, ok = m.Session(id)
+			return ok && s.State == preemptive.StateReady && s.Summary == want
+		}, 2*time.Second, 10*time.Millisecond)
+		return s
+	}
+
+	_, _, _, _, err := m.ProcessRequest(context.Background(), http.Header{}, body, "claude-sonnet-4-5", "anthropic")
+	require.NoError(t, err)
+	first := waitSummary(fmt.Sprintf("summary-%d", start+1))
+	assert.Equal(t, 4, first.SummaryMessageIndex)
+	calls := rollingStrategy.calls()[start:]
+	require.Len(t, calls, 1)
+	assert.Empty(t, calls[0].PreviousSummary, "first summary covers the history from the start")
+	assert.Len(t, calls[0].Messages, 5)
+
+	// The conversation grows: summary + unsummarized tail is over the threshold again.
+	history = append(history, turns(6, 6, 200)...)
+	_, _, _, _, err = m.ProcessRequest(context.Background(), http.Header{}, requestBody(t, history), "claude-sonnet-4-5", "anthropic")
+	require.NoError(t, err)
+	second := waitSummary(fmt.Sprintf("summary-%d", start+2))
+	assert.Equal(t, 10, second.SummaryMessageIndex)
+	calls = rollingStrategy.calls()[start:]
+	require.Len(t, calls, 2)
+	assert.Equal(t, first.Summary, calls[1].PreviousSummary)
+	require.Len(t, calls[1].Messages, 6, "only messages 5..10 are summarized")
+	assert.Equal(t, string(history[5]), string(calls[1].Messages[0]))
+
+	// A small addition keeps the compacted request under the threshold: no new job.
+	history = append(history, turns(12, 1, 5)...)
+	_, _, _, _, err = m.ProcessRequest(context.Background(), http.Header{}, requestBody(t, history), "claude-sonnet-4-5", "anthropic")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, rollingStrategy.calls()[start:], 2)
+}
+
+func TestManager_RollingSummarization_Disabled(t *testing.T) {
+	cfg := rollingConfig()
+	cfg.Summarizer.DisableRolling = true
+	m := preemptive.NewManager(cfg)
+	defer m.Stop()
+
+	start := len(rollingStrategy.calls())
+	history := turns(100, 6, 200)
+	body := requestBody(t, history)
+	id := preemptive.RootSessionID(nil, http.Header{}, body)
+
+	_, _, _, _, err := m.ProcessRequest(context.Background(), http.Header{}, body, "claude-sonnet-4-5", "anthropic")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		s, ok := m.Session(id)
+		return ok && s.State == preemptive.StateReady
+	}, 2*time.Second, 10*time.Millisecond)
+
+	history = append(history, turns(106, 6, 200)...)
+	_, _, _, _, err = m.ProcessRequest(context.Background(), http.Header{}, requestBody(t, history), "claude-sonnet-4-5", "anthropic")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, rollingStrategy.calls()[start:], 1, "the ready summary is kept as is")
+}
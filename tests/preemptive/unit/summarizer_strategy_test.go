@@ -0,0 +1,225 @@
+package preemptive_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// recordingStrategy captures the last request and returns a fixed summary.
+type recordingStrategy struct {
+	mu  sync.Mutex
+	got preemptive.SummaryRequest
+}
+
+func (r *recordingStrategy) Summarize(_ context.Context, req preemptive.SummaryRequest) (preemptive.SummaryResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = req
+	return preemptive.SummaryResult{Summary: "Domain summary.", InputTokens: 90, OutputTokens: 4}, nil
+}
+
+// toolInput is a conversation with an Anthropic tool_use and an OpenAI tool call.
+func toolInput() preemptive.SummarizeInput {
+	return preemptive.SummarizeInput{
+		Messages: []json.RawMessage{
+			makeMessage("user", "Fix the flaky checkout test"),
+			json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read_file","input":{"path":"checkout_test.go"}}]}`),
+			json.RawMessage(`{"role":"assistant","content":"","tool_calls":[{"id":"c1","type":"function","function":{"name":"run_tests","arguments":"{}"}}]}`),
+			json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"read_file","input":{"path":"cart.go"}}]}`),
+			makeMessage("assistant", "The race is in cart.go"),
+			makeMessage("user", "Apply the fix"),
+		},
+		KeepRecentCount: 1,
+		Model:           "claude-sonnet-4-5",
+	}
+}
+
+func strategyConfig(strategy string) preemptive.Config {
+	return preemptive.Config{
+		Enabled:          true,
+		TriggerThreshold: 80.0,
+		Summarizer: preemptive.SummarizerConfig{
+			Strategy:  strategy,
+			Endpoint:  "https://summaries.internal.example/v1/summarize",
+			Model:     "domain-summarizer",
+			MaxTokens: 4096,
+			Timeout:   30 * time.Second,
+		},
+		Session: preemptive.SessionConfig{SummaryTTL: time.Hour, HashMessageCount: 3},
+	}
+}
+
+// =============================================================================
+// REGISTERED STRATEGIES
+// =============================================================================
+
+func TestSummarizer_RegisteredStrategy_GetsRenderedPrompt(t *testing.T) {
+	rec := &recordingStrategy{}
+	preemptive.RegisterStrategy("test_recording", func(preemptive.SummarizerConfig) (preemptive.SummaryStrategy, error) {
+		return rec, nil
+	})
+	assert.Contains(t, preemptive.StrategyNames(), "test_recording")
+
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:         "test_recording",
+		SystemPrompt:     "You summarize payment-service sessions.",
+		KeepRecentTokens: 1,
+		PromptTemplate:   "Task: {{.Task}}\nTools: {{join .ToolsUsed \", \"}}\n{{.Conversation}}\nRecent:\n{{.RecentMessages}}",
+	})
+
+	out, err := s.Summarize(context.Background(), toolInput())
+	require.NoError(t, err)
+	assert.Equal(t, "Domain summary.", out.Summary)
+	assert.Equal(t, 4, out.SummaryTokens)
+	assert.Equal(t, 90, out.InputTokens)
+	assert.Equal(t, 4, out.LastSummarizedIndex)
+
+	got := rec.got
+	assert.Equal(t, "You summarize payment-service sessions.", got.SystemPrompt)
+	assert.Equal(t, "claude-sonnet-4-5", got.Model)
+	assert.Len(t, got.Messages, 5)
+	assert.Len(t, got.Recent, 1)
+	assert.Equal(t, "Fix the flaky checkout test", got.Vars.Task)
+	assert.Equal(t, []string{"read_file", "run_tests"}, got.Vars.ToolsUsed)
+	assert.True(t, strings.HasPrefix(got.UserPrompt, "Task: Fix the flaky checkout test\nTools: read_file, run_tests\n"), got.UserPrompt)
+	assert.Contains(t, got.UserPrompt, "The race is in cart.go")
+	assert.True(t, strings.HasSuffix(got.UserPrompt, "Recent:\n"+got.Vars.RecentMessages), got.UserPrompt)
+	assert.Contains(t, got.Vars.RecentMessages, "Apply the fix")
+}
+
+func TestRegisterStrategy_RejectsBuiltinNames(t *testing.T) {
+	for _, name := range []string{preemptive.StrategyExternalProvider, preemptive.StrategyCompresr, preemptive.StrategyOpenAICompatible} {
+		assert.Panics(t, func() {
+			preemptive.RegisterStrategy(name, func(preemptive.SummarizerConfig) (preemptive.SummaryStrategy, error) { return nil, nil })
+		}, name)
+	}
+}
+
+// =============================================================================
+// CUSTOM ENDPOINT STRATEGY
+// =============================================================================
+
+func TestSummarizer_CustomEndpoint_PostsPromptAndVars(t *testing.T) {
+	var auth string
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"summary":"Endpoint summary.","input_tokens":300,"output_tokens":12}`))
+	}))
+	defer server.Close()
+
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:         preemptive.StrategyCustomEndpoint,
+		Endpoint:         server.URL + "/summarize",
+		ProviderKey:      "team-secret",
+		Timeout:          5 * time.Second,
+		KeepRecentTokens: 1,
+		PromptTemplate:   "Keep ticket IDs verbatim.\n{{.Conversation}}",
+	})
+
+	input := toolInput()
+	input.Auth.Token = "sk-ant-client-key"
+	out, err := s.Summarize(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, "Endpoint summary.", out.Summary)
+	assert.Equal(t, 12, out.SummaryTokens)
+	assert.Equal(t, 300, out.InputTokens)
+
+	assert.Equal(t, "Bearer team-secret", auth, "configured key sent, captured auth not forwarded")
+	assert.Equal(t, "claude-sonnet-4-5", body["model"], "conversation model used when summarizer.model is unset")
+	assert.Equal(t, "Fix the flaky checkout test", body["task"])
+	assert.Equal(t, []any{"read_file", "run_tests"}, body["tools_used"])
+	assert.True(t, strings.HasPrefix(body["prompt"].(string), "Keep ticket IDs verbatim.\n"))
+	assert.Equal(t, preemptive.DefaultClaudeSystemPrompt, body["system_prompt"])
+	assert.Len(t, body["messages"], 5)
+	assert.Len(t, body["recent_messages"], 1)
+}
+
+func TestSummarizer_CustomEndpoint_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	s := preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:         preemptive.StrategyCustomEndpoint,
+		Endpoint:         server.URL,
+		Timeout:          5 * time.Second,
+		KeepRecentTokens: 1,
+	})
+	_, err := s.Summarize(context.Background(), toolInput())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "502")
+}
+
+// =============================================================================
+// PROMPT TEMPLATES AND VALIDATION
+// =============================================================================
+
+func TestCompilePromptTemplate(t *testing.T) {
+	tmpl, err := preemptive.CompilePromptTemplate("")
+	require.NoError(t, err)
+	assert.Nil(t, tmpl, "empty template uses the default prompt")
+
+	_, err = preemptive.CompilePromptTemplate("Legacy: {{conversation}}")
+	assert.NoError(t, err)
+	_, err = preemptive.CompilePromptTemplate("{{.Task}} {{join .ToolsUsed \",\"}} {{.Model}} {{.Conversation}}")
+	assert.NoError(t, err)
+
+	_, err = preemptive.CompilePromptTemplate("{{.Task}} only")
+	assert.ErrorContains(t, err, "{{.Conversation}}")
+	_, err = preemptive.CompilePromptTemplate("{{.Conversation}} {{.Unknown}}")
+	assert.Error(t, err)
+	_, err = preemptive.CompilePromptTemplate("{{.Conversation")
+	assert.Error(t, err)
+}
+
+func TestConfig_Validate_CustomStrategies(t *testing.T) {
+	cfg := strategyConfig(preemptive.StrategyCustomEndpoint)
+	require.NoError(t, cfg.Validate())
+	model, provider := cfg.Summarizer.EffectiveModelAndProvider()
+	assert.Equal(t, "domain-summarizer", model)
+	assert.Equal(t, preemptive.StrategyCustomEndpoint, provider)
+
+	tests := []struct {
+		name   string
+		mutate func(*preemptive.SummarizerConfig)
+		errMsg string
+	}{
+		{"unknown strategy", func(s *preemptive.SummarizerConfig) { s.Strategy = "magic" }, "summarizer.strategy must be one of"},
+		{"missing endpoint", func(s *preemptive.SummarizerConfig) { s.Endpoint = "" }, "summarizer.endpoint is required"},
+		{"non-http endpoint", func(s *preemptive.SummarizerConfig) { s.Endpoint = "file:///tmp/x" }, "summarizer.endpoint must be"},
+		{"bad template", func(s *preemptive.SummarizerConfig) { s.PromptTemplate = "{{.Nope}} {{.Conversation}}" }, "summarizer.prompt_template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := strategyConfig(preemptive.StrategyCustomEndpoint)
+			tt.mutate(&cfg.Summarizer)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	// Templates apply to external_provider too.
+	cfg = strategyConfig(preemptive.StrategyExternalProvider)
+	cfg.Summarizer.PromptTemplate = "no conversation here"
+	assert.ErrorContains(t, cfg.Validate(), "summarizer.prompt_template")
+}
@@ -0,0 +1,156 @@
+package preemptive_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/preemptive"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// fixedStrategy returns its own text as the summary.
+type fixedStrategy string
+
+func (f fixedStrategy) Summarize(context.Context, preemptive.SummaryRequest) (preemptive.SummaryResult, error) {
+	return preemptive.SummaryResult{Summary: string(f), OutputTokens: 20}, nil
+}
+
+// verifyingSummarizer returns a summarizer whose summaries are always summary.
+func verifyingSummarizer(t *testing.T, summary string, enabled bool) *preemptive.Summarizer {
+	t.Helper()
+	name := "test_verify_" + t.Name()
+	preemptive.RegisterStrategy(name, func(preemptive.SummarizerConfig) (preemptive.SummaryStrategy, error) {
+		return fixedStrategy(summary), nil
+	})
+	return preemptive.NewSummarizer(preemptive.SummarizerConfig{
+		Strategy:        name,
+		KeepRecentCount: 1,
+		Verification:    preemptive.VerificationConfig{Enabled: enabled},
+	})
+}
+
+// agentSession has an open todo (message 1), an edited file (3) and a failing
+// test run (6). The last message is kept, so messages 0..7 are summarized.
+func agentSession() []json.RawMessage {
+	return []json.RawMessage{
+		makeMessage("user", "Refactor the payment module"),
+		json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"TodoWrite","input":{"todos":[{"content":"Add retry to charge()","status":"in_progress"},{"content":"Write changelog","status":"completed"}]}}]}`),
+		json.RawMessage(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}`),
+		json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"internal/pay/charge.go"}}]}`),
+		json.RawMessage(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"edited"}]}`),
+		json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"Bash","input":{"command":"go test ./internal/pay/"}}]}`),
+		json.RawMessage(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","is_error":true,"content":"FAIL TestChargeRetry"}]}`),
+		makeMessage("assistant", "Looking into the failure"),
+		makeMessage("user", "continue"),
+	}
+}
+
+func summarizeSession(t *testing.T, s *preemptive.Summarizer, messages []json.RawMessage) (*preemptive.SummarizeOutput, error) {
+	t.Helper()
+	return s.Summarize(context.Background(), preemptive.SummarizeInput{Messages: messages, KeepRecentCount: 1})
+}
+
+// =============================================================================
+// VERIFICATION
+// =============================================================================
+
+func TestVerification_CompleteSummaryPasses(t *testing.T) {
+	s := verifyingSummarizer(t, "Adding retry to charge() in charge.go; the Bash test run still fails.", true)
+	out, err := summarizeSession(t, s, agentSession())
+	require.NoError(t, err)
+	assert.Equal(t, 7, out.LastSummarizedIndex)
+	assert.Empty(t, out.Missing)
+}
+
+func TestVerification_MissingFactsKeepMoreMessages(t *testing.T) {
+	s := verifyingSummarizer(t, "Refactored the payments code.", true)
+	out, err := summarizeSession(t, s, agentSession())
+	require.NoError(t, err)
+	assert.Equal(t, 0, out.LastSummarizedIndex, "kept from the todo list on")
+
+	kinds := make(map[string]string)
+	for _, f := range out.Missing {
+		kinds[f.Kind] = f.Text
+	}
+	assert.Equal(t, "Add retry to charge()", kinds[preemptive.FactOpenTask])
+	assert.Equal(t, "internal/pay/charge.go", kinds[preemptive.FactFile])
+	assert.Equal(t, "FAIL TestChargeRetry", kinds[preemptive.FactToolError])
+	assert.NotContains(t, out.Missing, preemptive.MissingFact{Kind: preemptive.FactOpenTask, Text: "Write changelog", Index: 1}, "completed todos are not required")
+}
+
+func TestVerification_OnlyUnresolvedErrorMissing(t *testing.T) {
+	s := verifyingSummarizer(t, "Adding retry to charge() in internal/pay/charge.go.", true)
+	out, err := summarizeSession(t, s, agentSession())
+	require.NoError(t, err)
+	assert.Equal(t, 5, out.LastSummarizedIndex, "the failing tool call and result are kept")
+	require.Len(t, out.Missing, 1)
+	assert.Equal(t, preemptive.FactToolError, out.Missing[0].Kind)
+	assert.Equal(t, 6, out.Missing[0].Index)
+}
+
+func TestVerification_ResolvedErrorAndKeptFactsSkipped(t *testing.T) {
+	messages := agentSession()
+	// A later successful Bash run resolves the error; the kept message names the file.
+	messages = append(messages[:8],
+		json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t4","name":"Bash","input":{"command":"go test ./internal/pay/"}}]}`),
+		json.RawMessage(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t4","content":"ok"}]}`),
+		makeMessage("user", "charge.go looks good, continue with internal/pay/charge.go"),
+	)
+	s := verifyingSummarizer(t, "Adding retry to charge().", true)
+	out, err := summarizeSession(t, s, messages)
+	require.NoError(t, err)
+	assert.Equal(t, len(messages)-2, out.LastSummarizedIndex)
+	assert.Empty(t, out.Missing)
+}
+
+func TestVerification_OpenAIToolCalls(t *testing.T) {
+	messages := []json.RawMessage{
+		makeMessage("user", "Fix the importer"),
+		json.RawMessage(`{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"write_file","arguments":"{\"path\":\"src/importer.py\"}"}}]}`),
+		json.RawMessage(`{"role":"tool","tool_call_id":"c1","content":"Error: permission denied"}`),
+		makeMessage("assistant", "Retrying with sudo"),
+		makeMessage("user", "ok"),
+	}
+	s := verifyingSummarizer(t, "Working on the importer.", true)
+	out, err := summarizeSession(t, s, messages)
+	require.NoError(t, err)
+	assert.Equal(t, 0, out.LastSummarizedIndex)
+	require.Len(t, out.Missing, 2)
+	assert.Equal(t, "src/importer.py", out.Missing[0].Text)
+	assert.Equal(t, "Error: permission denied", out.Missing[1].Text)
+}
+
+func TestVerification_FailsWhenNothingLeftToSummarize(t *testing.T) {
+	messages := agentSession()
+	messages[0] = makeMessage("user", "Refactor the payment module\n- [ ] Keep the public API stable")
+	s := verifyingSummarizer(t, "Adding retry to charge() in charge.go; Bash tests fail.", true)
+	_, err := summarizeSession(t, s, messages)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "summary verification failed")
+	assert.Contains(t, err.Error(), "Keep the public API stable")
+
+	// A rolling update keeps the previous summary when its new part fails.
+	s = verifyingSummarizer(t, "Refactored.", true)
+	_, err = s.Summarize(context.Background(), preemptive.SummarizeInput{
+		Messages:        agentSession(),
+		KeepRecentCount: 1,
+		PreviousSummary: "Started the refactor.",
+		PreviousIndex:   2,
+	})
+	assert.ErrorContains(t, err, "summary verification failed")
+}
+
+func TestVerification_Disabled(t *testing.T) {
+	s := verifyingSummarizer(t, "Refactored the payments code.", false)
+	out, err := summarizeSession(t, s, agentSession())
+	require.NoError(t, err)
+	assert.Equal(t, 7, out.LastSummarizedIndex)
+	assert.Empty(t, out.Missing)
+}
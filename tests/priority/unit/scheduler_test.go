@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/priority"
+)
+
+func newScheduler(t *testing.T, cfg priority.Config) *priority.Scheduler {
+	t.Helper()
+	cfg.Enabled = true
+	require.NoError(t, cfg.Validate())
+	s := priority.NewScheduler(cfg)
+	require.NotNil(t, s)
+	return s
+}
+
+// acquireAsync starts Acquire in a goroutine and reports the order of admissions on admitted.
+func acquireAsync(s *priority.Scheduler, cl priority.Class, admitted chan<- priority.Class) chan func() {
+	releases := make(chan func(), 1)
+	go func() {
+		release, err := s.Acquire(context.Background(), cl)
+		if err != nil {
+			close(releases)
+			return
+		}
+		admitted <- cl
+		releases <- release
+	}()
+	return releases
+}
+
+func waitForWaiting(t *testing.T, s *priority.Scheduler, cl priority.Class, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return s.Stats().Classes[cl.String()].Waiting == n },
+		2*time.Second, 5*time.Millisecond)
+}
+
+func TestClassify(t *testing.T) {
+	cfg := priority.Config{Enabled: true, DefaultClass: "background"}
+
+	h := http.Header{}
+	assert.Equal(t, priority.Background, cfg.Classify(h, nil), "default class")
+	assert.Equal(t, priority.Batch, cfg.Classify(h, []string{"team-a", "batch"}), "session tag")
+
+	h.Set(priority.HeaderPriority, "Interactive")
+	assert.Equal(t, priority.Interactive, cfg.Classify(h, []string{"batch"}), "header wins over tag")
+
+	h.Set(priority.HeaderPriority, "urgent")
+	assert.Equal(t, priority.Batch, cfg.Classify(h, []string{"batch"}), "unknown header falls through")
+}
+
+func TestScheduler_UnlimitedAdmitsImmediately(t *testing.T) {
+	s := newScheduler(t, priority.Config{})
+	for range 50 {
+		release, err := s.Acquire(context.Background(), priority.Batch)
+		require.NoError(t, err)
+		defer release()
+	}
+	st := s.Stats().Classes["batch"]
+	assert.Equal(t, int64(50), st.Admitted)
+	assert.Equal(t, 50, st.InFlight)
+}
+
+func TestScheduler_InteractiveOvertakesQueuedBatch(t *testing.T) {
+	s := newScheduler(t, priority.Config{MaxConcurrent: 1})
+
+	hold, err := s.Acquire(context.Background(), priority.Batch)
+	require.NoError(t, err)
+
+	admitted := make(chan priority.Class, 3)
+	batch := acquireAsync(s, priority.Batch, admitted)
+	waitForWaiting(t, s, priority.Batch, 1)
+	background := acquireAsync(s, priority.Background, admitted)
+	waitForWaiting(t, s, priority.Background, 1)
+	interactive := acquireAsync(s, priority.Interactive, admitted)
+	waitForWaiting(t, s, priority.Interactive, 1)
+
+	hold()
+	assert.Equal(t, priority.Interactive, <-admitted)
+	(<-interactive)()
+	assert.Equal(t, priority.Background, <-admitted)
+	(<-background)()
+	assert.Equal(t, priority.Batch, <-admitted)
+	(<-batch)()
+
+	st := s.Stats()
+	assert.Equal(t, 0, st.InFlight)
+	assert.Equal(t, int64(1), st.Classes["interactive"].Queued)
+	assert.Equal(t, int64(2), st.Classes["batch"].Admitted)
+}
+
+func TestScheduler_ReservedSlotsOnlyForInteractive(t *testing.T) {
+	s := newScheduler(t, priority.Config{
+		MaxConcurrent:       2,
+		ReservedInteractive: 1,
+		Classes:             map[string]priority.ClassConfig{"background": {QueueTimeout: 50 * time.Millisecond}},
+	})
+
+	release, err := s.Acquire(context.Background(), priority.Background)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = s.Acquire(context.Background(), priority.Background)
+	assert.ErrorIs(t, err, priority.ErrQueueTimeout, "reserved slot must not go to background")
+
+	release2, err := s.Acquire(context.Background(), priority.Interactive)
+	require.NoError(t, err, "interactive uses the reserved slot")
+	release2()
+
+	assert.Equal(t, int64(1), s.Stats().Classes["background"].Rejected[priority.ReasonQueueTimeout])
+}
+
+func TestScheduler_QueueFull(t *testing.T) {
+	s := newScheduler(t, priority.Config{
+		MaxConcurrent: 1,
+		Classes:       map[string]priority.ClassConfig{"batch": {MaxQueued: 1}},
+	})
+	hold, err := s.Acquire(context.Background(), priority.Interactive)
+	require.NoError(t, err)
+
+	admitted := make(chan priority.Class, 1)
+	queued := acquireAsync(s, priority.Batch, admitted)
+	waitForWaiting(t, s, priority.Batch, 1)
+
+	_, err = s.Acquire(context.Background(), priority.Batch)
+	assert.ErrorIs(t, err, priority.ErrQueueFull)
+
+	hold()
+	(<-queued)()
+	assert.Equal(t, int64(1), s.Stats().Classes["batch"].Rejected[priority.ReasonQueueFull])
+}
+
+func TestScheduler_CanceledWhileQueued(t *testing.T) {
+	s := newScheduler(t, priority.Config{MaxConcurrent: 1})
+	hold, err := s.Acquire(context.Background(), priority.Interactive)
+	require.NoError(t, err)
+	defer hold()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(ctx, priority.Background)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	st := s.Stats().Classes["background"]
+	assert.Equal(t, 0, st.Waiting)
+	assert.Equal(t, int64(1), st.Rejected[priority.ReasonCanceled])
+}
+
+func TestScheduler_ShedNearBudgetCap(t *testing.T) {
+	s := newScheduler(t, priority.Config{})
+
+	assert.False(t, s.Shed(priority.Interactive, 0.99), "interactive is never shed by default")
+	assert.False(t, s.Shed(priority.Background, 0.85))
+	assert.True(t, s.Shed(priority.Background, 0.9))
+	assert.True(t, s.Shed(priority.Batch, 0.8))
+	assert.False(t, s.Shed(priority.Batch, 0.5))
+
+	assert.Equal(t, int64(1), s.Stats().Classes["batch"].Rejected[priority.ReasonBudget])
+}
+
+func TestScheduler_DisableOnReloadAdmitsWaiters(t *testing.T) {
+	s := newScheduler(t, priority.Config{MaxConcurrent: 1})
+	hold, err := s.Acquire(context.Background(), priority.Interactive)
+	require.NoError(t, err)
+	defer hold()
+
+	admitted := make(chan priority.Class, 1)
+	waiting := acquireAsync(s, priority.Batch, admitted)
+	waitForWaiting(t, s, priority.Batch, 1)
+
+	s.UpdateConfig(priority.Config{Enabled: false})
+	assert.Equal(t, priority.Batch, <-admitted)
+	(<-waiting)()
+	assert.False(t, s.Shed(priority.Batch, 1))
+}
+
+func TestScheduler_NilIsDisabled(t *testing.T) {
+	assert.Nil(t, priority.NewScheduler(priority.Config{}))
+
+	var s *priority.Scheduler
+	release, err := s.Acquire(context.Background(), priority.Batch)
+	require.NoError(t, err)
+	release()
+	assert.False(t, s.Shed(priority.Batch, 1))
+	assert.Equal(t, priority.Interactive, s.Classify(http.Header{priority.HeaderPriority: {"batch"}}, nil))
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  priority.Config
+		ok   bool
+	}{
+		{"disabled ignores errors", priority.Config{DefaultClass: "nope"}, true},
+		{"valid", priority.Config{Enabled: true, DefaultClass: "batch", MaxConcurrent: 4, ReservedInteractive: 2}, true},
+		{"unknown default", priority.Config{Enabled: true, DefaultClass: "nope"}, false},
+		{"reserved >= max", priority.Config{Enabled: true, MaxConcurrent: 2, ReservedInteractive: 2}, false},
+		{"unknown class", priority.Config{Enabled: true, Classes: map[string]priority.ClassConfig{"urgent": {}}}, false},
+		{"shed out of range", priority.Config{Enabled: true, Classes: map[string]priority.ClassConfig{"batch": {BudgetShedAt: 1.5}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	authtypes "github.com/compresr/context-gateway/internal/auth/types"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/monitoring"
+	"github.com/compresr/context-gateway/internal/replay"
+)
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// logOutput is a tool output large enough for the simple strategy to compress.
+func logOutput() string {
+	var sb strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&sb, "line %d: INFO processed item %d in %dms\n", i, i, i%7)
+	}
+	return sb.String()
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// messages decodes a rebuilt request's messages.
+func messages(t *testing.T, req replay.Request) []map[string]any {
+	t.Helper()
+	var body struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(req.Body, &body))
+	return body.Messages
+}
+
+// trajectory has a user turn, an agent step that ran a tool, and a final agent step.
+func trajectory() *monitoring.Trajectory {
+	t := monitoring.NewTrajectory("sess", "claude-code", "1.0")
+	t.Agent.ModelName = "claude-sonnet-4-5"
+	t.Agent.ToolDefinitions = []monitoring.ToolDefinition{{Type: "function", Function: monitoring.FunctionSchema{Name: "Bash", Description: "Run a command"}}}
+	t.AddStep(monitoring.NewUserStep("why is the build slow?"))
+	agent := monitoring.NewAgentStep("Checking the logs.", "claude-sonnet-4-5")
+	agent.ToolCalls = []monitoring.ToolCall{{ToolCallID: "toolu_1", FunctionName: "Bash", Arguments: map[string]any{"command": "make build"}}}
+	agent.Observation = &monitoring.Observation{Results: []monitoring.ObservationResult{{SourceCallID: "toolu_1", Content: "[compressed]"}}}
+	t.AddStep(agent)
+	final := monitoring.NewAgentStep("The linker is slow.", "claude-sonnet-4-5")
+	final.ProxyInteraction = &monitoring.ProxyInteraction{Compression: &monitoring.ProxyCompressionInfo{
+		ToolCompressions: []monitoring.ToolCompressionEntry{{ToolName: "Bash", ToolCallID: "toolu_1", OriginalContent: logOutput()}},
+	}}
+	t.AddStep(final)
+	return t
+}
+
+func simpleConfig() *config.Config {
+	return &config.Config{Pipes: config.PipesConfig{ToolOutput: config.ToolOutputPipeConfig{
+		Enabled:          true,
+		Strategy:         config.StrategySimple,
+		FallbackStrategy: config.StrategyPassthrough,
+		MinTokens:        50,
+		MaxTokens:        16384,
+		BypassCostCheck:  true,
+	}}}
+}
+
+// =============================================================================
+// LOAD
+// =============================================================================
+
+func TestFromTrajectory_OneRequestPerAgentStep(t *testing.T) {
+	reqs := replay.FromTrajectory(trajectory())
+	require.Len(t, reqs, 2)
+	assert.Equal(t, "step-2", reqs[0].ID)
+	assert.Equal(t, "step-3", reqs[1].ID)
+	assert.Equal(t, replay.AnthropicPath, reqs[1].Path)
+
+	assert.Len(t, messages(t, reqs[0]), 1, "the first request is the user turn")
+
+	msgs := messages(t, reqs[1])
+	require.Len(t, msgs, 3)
+	assert.Equal(t, []any{"user", "assistant", "user"}, []any{msgs[0]["role"], msgs[1]["role"], msgs[2]["role"]})
+	use := msgs[1]["content"].([]any)[1].(map[string]any)
+	assert.Equal(t, "tool_use", use["type"])
+	assert.Equal(t, map[string]any{"command": "make build"}, use["input"])
+	result := msgs[2]["content"].([]any)[0].(map[string]any)
+	assert.Equal(t, "toolu_1", result["tool_use_id"])
+	assert.Equal(t, logOutput(), result["content"], "original content replaces the compressed observation")
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(reqs[1].Body, &body))
+	assert.Equal(t, "claude-sonnet-4-5", body["model"])
+	assert.Len(t, body["tools"], 1)
+}
+
+func TestLoad_Sources(t *testing.T) {
+	dir := t.TempDir()
+
+	// A session directory resolves to its trajectory.
+	data, err := trajectory().ToJSON()
+	require.NoError(t, err)
+	writeFile(t, dir, "trajectory.json", data)
+	reqs, err := replay.Load(dir)
+	require.NoError(t, err)
+	assert.Len(t, reqs, 2)
+
+	// Compression log: grouped by request_id, entries without content skipped.
+	lines := []map[string]any{
+		{"request_id": "req_a", "tool_name": "Bash", "query": "fix it", "original_content": logOutput()},
+		{"request_id": "req_b", "tool_name": "Read", "original_content": "package main"},
+		{"request_id": "req_a", "tool_name": "Grep", "original_content": "main.go:1"},
+		{"request_id": "req_c", "tool_name": "Read", "status": "cache_hit"},
+	}
+	var jsonl []byte
+	for _, l := range lines {
+		b, _ := json.Marshal(l)
+		jsonl = append(append(jsonl, b...), '\n')
+	}
+	reqs, err = replay.Load(writeFile(t, dir, "tool_output_compression.jsonl", jsonl))
+	require.NoError(t, err)
+	require.Len(t, reqs, 2)
+	assert.Equal(t, "req_a", reqs[0].ID)
+	msgs := messages(t, reqs[0])
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "fix it", msgs[0]["content"].([]any)[0].(map[string]any)["text"])
+	assert.Len(t, msgs[1]["content"], 2)
+	assert.Len(t, msgs[2]["content"], 2)
+
+	// A captured body is replayed as is.
+	raw := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	reqs, err = replay.Load(writeFile(t, dir, "openai_chat.json", raw))
+	require.NoError(t, err)
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "openai_chat.json", reqs[0].ID)
+	assert.Equal(t, "/v1/chat/completions", reqs[0].Path, "fixture name selects the adapter")
+	assert.Equal(t, raw, reqs[0].Body)
+
+	// telemetry.jsonl has no bodies.
+	_, err = replay.Load(writeFile(t, dir, "telemetry.jsonl", []byte(`{"request_id":"r1","path":"/v1/messages","request_body_size":100}`+"\n")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "telemetry.jsonl has no request bodies")
+}
+
+// =============================================================================
+// RUN AND DIFF
+// =============================================================================
+
+func TestRun_ComparesStrategies(t *testing.T) {
+	req := replay.FromTrajectory(trajectory())[1]
+	original := replay.Original(req)
+
+	passthrough := simpleConfig()
+	passthrough.Pipes.ToolOutput.Strategy = config.StrategyPassthrough
+	same, err := replay.Run(context.Background(), passthrough, req, authtypes.CapturedAuth{})
+	require.NoError(t, err)
+	assert.Equal(t, original.Tokens, same.Tokens)
+	assert.Zero(t, same.Compressed)
+	assert.Empty(t, replay.Diff(original.Body, same.Body, "original", "passthrough"))
+
+	simple, err := replay.Run(context.Background(), simpleConfig(), req, authtypes.CapturedAuth{})
+	require.NoError(t, err)
+	assert.Less(t, simple.Tokens, original.Tokens)
+	assert.Equal(t, 1, simple.Compressed)
+
+	diff := replay.Diff(original.Body, simple.Body, "original", "simple")
+	assert.True(t, strings.HasPrefix(diff, "--- original\n+++ simple\n@@ -"), diff)
+	assert.Contains(t, diff, "\n-            line 150: INFO processed item 150 in 3ms\n", "tool output lines are diffed one by one")
+	assert.NotContains(t, diff, "-      role:", "unchanged structure is not in the diff")
+}
+
+func TestDiff_Hunks(t *testing.T) {
+	a := []byte(`{"messages":[{"content":"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"}]}`)
+	b := []byte(`{"messages":[{"content":"a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nM\nn"}]}`)
+	diff := replay.Diff(a, b, "a", "b")
+	assert.Equal(t, 2, strings.Count(diff, "@@ -"), "changes far apart get separate hunks:\n"+diff)
+	assert.Contains(t, diff, "@@ -3,7 +3,7 @@\n     {\n       content: |\n         a\n-        b\n+        B\n")
+	assert.Contains(t, diff, "@@ -14,7 +14,7 @@\n")
+	assert.Contains(t, diff, "-        m\n+        M\n")
+}
@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+// storeSweeper adapts a bare Store to sessionstore.Sweeper, as the gateway's
+// store wrappers do.
+type storeSweeper struct {
+	*sessionstore.Store[counter]
+}
+
+func (s storeSweeper) Expire(id string) bool { return s.Delete(id) }
+
+func newSweeper(ttl time.Duration) storeSweeper {
+	return storeSweeper{sessionstore.New(ttl, 0, newCounter)}
+}
+
+func TestCollector_SweepCountsPerStore(t *testing.T) {
+	short, long := newSweeper(20*time.Millisecond), newSweeper(time.Hour)
+	defer short.Stop()
+	defer long.Stop()
+
+	c := sessionstore.NewCollector(0)
+	c.Register("short", short)
+	c.Register("long", long)
+
+	for _, id := range []string{"a", "b"} {
+		short.Update(id, func(*counter) {})
+		long.Update(id, func(*counter) {})
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	assert.Equal(t, 2, c.Sweep(), "only the short-TTL store evicts")
+	assert.Equal(t, 0, c.Sweep())
+
+	stats := c.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "short", stats[0].Name, "stats are in registration order")
+	assert.Equal(t, int64(2), stats[0].Sweeps)
+	assert.Equal(t, int64(2), stats[0].Evicted)
+	assert.Equal(t, 0, stats[0].LastEvicted)
+	assert.Equal(t, 0, stats[0].Sessions)
+	assert.Equal(t, "long", stats[1].Name)
+	assert.Zero(t, stats[1].Evicted)
+	assert.Equal(t, 2, stats[1].Sessions)
+}
+
+func TestCollector_ExpireAcrossStores(t *testing.T) {
+	a, b := newSweeper(time.Hour), newSweeper(time.Hour)
+	defer a.Stop()
+	defer b.Stop()
+
+	c := sessionstore.NewCollector(0)
+	c.Register("a", a)
+	c.Register("b", b)
+
+	a.Update("s1", func(*counter) {})
+	b.Update("s1", func(*counter) {})
+	b.Update("s2", func(*counter) {})
+
+	assert.Equal(t, []string{"a", "b"}, c.Expire("s1"))
+	assert.Equal(t, []string{"b"}, c.Expire("s2"))
+	assert.Empty(t, c.Expire("missing"))
+
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats[0].ForceExpired)
+	assert.Equal(t, int64(2), stats[1].ForceExpired)
+	assert.Zero(t, stats[1].Evicted, "force expiry is not counted as eviction")
+}
+
+func TestCollector_BackgroundLoop(t *testing.T) {
+	s := newSweeper(10 * time.Millisecond)
+	defer s.Stop()
+
+	c := sessionstore.NewCollector(10 * time.Millisecond)
+	c.Register("s", s)
+	c.Start()
+	defer c.Stop()
+
+	s.Update("a", func(*counter) {})
+	require.Eventually(t, func() bool {
+		return c.Stats()[0].Evicted == 1
+	}, time.Second, 5*time.Millisecond)
+
+	c.Stop()
+	c.Stop() // idempotent
+}
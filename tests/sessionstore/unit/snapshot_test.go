@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+type snapshotValue struct {
+	Count int
+	Tags  []string
+}
+
+func TestStore_ExportImportRoundTrip(t *testing.T) {
+	src := sessionstore.New[snapshotValue](time.Hour, 0, nil)
+	src.Update("a", func(v *snapshotValue) { v.Count = 3; v.Tags = []string{"x"} })
+	src.Update("b", func(v *snapshotValue) { v.Count = 7 })
+
+	data, err := src.Export()
+	require.NoError(t, err)
+
+	dst := sessionstore.New[snapshotValue](time.Hour, 0, nil)
+	dst.Update("b", func(v *snapshotValue) { v.Count = 99 })
+	n, err := dst.Import(data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	var got snapshotValue
+	require.True(t, dst.View("a", func(v *snapshotValue) { got = *v }))
+	assert.Equal(t, snapshotValue{Count: 3, Tags: []string{"x"}}, got)
+	require.True(t, dst.View("b", func(v *snapshotValue) { got = *v }))
+	assert.Equal(t, 7, got.Count, "imported sessions replace live ones")
+}
+
+func TestStore_ImportSkipsExpiredRecords(t *testing.T) {
+	records := []sessionstore.Record[snapshotValue]{
+		{ID: "fresh", Value: snapshotValue{Count: 1}, Touched: time.Now().Add(-time.Minute)},
+		{ID: "stale", Value: snapshotValue{Count: 2}, Touched: time.Now().Add(-2 * time.Hour)},
+	}
+	data, err := json.Marshal(records)
+	require.NoError(t, err)
+
+	dst := sessionstore.New[snapshotValue](time.Hour, 0, nil)
+	n, err := dst.Import(data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, dst.View("fresh", nil))
+	assert.False(t, dst.View("stale", nil))
+}
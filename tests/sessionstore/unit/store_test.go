@@ -0,0 +1,234 @@
+package unit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/sessionstore"
+)
+
+type counter struct {
+	ID    string
+	Count int
+	Tags  map[string]bool
+}
+
+func newCounter(id string) counter {
+	return counter{ID: id, Tags: make(map[string]bool)}
+}
+
+func TestStore_UpdateCreatesAndView(t *testing.T) {
+	s := sessionstore.New(time.Hour, 0, newCounter)
+	defer s.Stop()
+
+	assert.False(t, s.View("a", nil), "missing session")
+
+	s.Update("a", func(c *counter) { c.Count++ })
+	s.Update("a", func(c *counter) { c.Count++ })
+
+	var got counter
+	require.True(t, s.View("a", func(c *counter) { got = *c }))
+	assert.Equal(t, "a", got.ID, "newFn receives the session ID")
+	assert.Equal(t, 2, got.Count)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStore_NilNewFnUsesZeroValue(t *testing.T) {
+	s := sessionstore.New[int](time.Hour, 0, nil)
+	defer s.Stop()
+
+	s.Update("a", func(v *int) { *v += 5 })
+	var got int
+	s.View("a", func(v *int) { got = *v })
+	assert.Equal(t, 5, got)
+}
+
+func TestStore_ExpiresAfterTTL(t *testing.T) {
+	s := sessionstore.New(20*time.Millisecond, 0, newCounter)
+	defer s.Stop()
+
+	s.Update("a", func(c *counter) { c.Count = 1 })
+	time.Sleep(40 * time.Millisecond)
+
+	assert.False(t, s.View("a", nil), "expired session must not be visible")
+	assert.Equal(t, 0, s.Len(), "expired session is removed on access")
+
+	s.Update("a", func(c *counter) { c.Count++ })
+	var got int
+	s.View("a", func(c *counter) { got = c.Count })
+	assert.Equal(t, 1, got, "expired state must not carry over")
+}
+
+func TestStore_UpdateDoesNotReviveExpiredEntry(t *testing.T) {
+	s := sessionstore.New(20*time.Millisecond, 0, newCounter) // No sweeper
+	defer s.Stop()
+
+	s.Update("a", func(c *counter) { c.Count = 5; c.Tags["old"] = true })
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, 1, s.Len(), "expired entry is still in the map")
+
+	s.Update("a", func(c *counter) { c.Count++ })
+	var got counter
+	require.True(t, s.View("a", func(c *counter) { got = *c }))
+	assert.Equal(t, 1, got.Count, "stale value must not be revived")
+	assert.Empty(t, got.Tags)
+	assert.Equal(t, "a", got.ID)
+}
+
+func TestStore_ViewDoesNotRefreshTTL(t *testing.T) {
+	s := sessionstore.New(50*time.Millisecond, 0, newCounter)
+	defer s.Stop()
+
+	s.Update("a", func(*counter) {})
+	for i := 0; i < 3; i++ {
+		time.Sleep(20 * time.Millisecond)
+		s.View("a", nil)
+	}
+	assert.False(t, s.View("a", nil))
+}
+
+func TestStore_SweepRangeDeleteReset(t *testing.T) {
+	s := sessionstore.New(30*time.Millisecond, 0, newCounter)
+	defer s.Stop()
+
+	s.Update("old", func(*counter) {})
+	time.Sleep(50 * time.Millisecond)
+	s.Update("new1", func(*counter) {})
+	s.Update("new2", func(*counter) {})
+
+	assert.Equal(t, 1, s.Sweep(), "Sweep reports the sessions it removed")
+	assert.Equal(t, 2, s.Len())
+
+	seen := map[string]bool{}
+	s.Range(func(id string, _ *counter) { seen[id] = true })
+	assert.Equal(t, map[string]bool{"new1": true, "new2": true}, seen)
+
+	assert.True(t, s.Delete("new1"))
+	assert.False(t, s.Delete("new1"), "second delete finds nothing")
+	assert.False(t, s.View("new1", nil))
+
+	s.Reset()
+	assert.Equal(t, 0, s.Len())
+	assert.False(t, s.View("new2", nil))
+}
+
+func TestStore_CleanupLoopSweeps(t *testing.T) {
+	s := sessionstore.New(10*time.Millisecond, 5*time.Millisecond, newCounter)
+	defer s.Stop()
+
+	s.Update("a", func(*counter) {})
+	assert.Eventually(t, func() bool { return s.Len() == 0 }, time.Second, 5*time.Millisecond)
+
+	s.Stop()
+	s.Stop() // idempotent
+}
+
+// TestStore_ConcurrentSessionsIsolated runs many sessions in parallel, each
+// mutating its own state, and checks no update lands in another session.
+// Run with -race.
+func TestStore_ConcurrentSessionsIsolated(t *testing.T) {
+	s := sessionstore.New(time.Hour, 0, newCounter)
+	defer s.Stop()
+
+	const sessions, perSession = 32, 200
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := 0; n < perSession/4; n++ {
+					s.Update(id, func(c *counter) {
+						c.Count++
+						c.Tags[id] = true
+					})
+					s.View(id, func(c *counter) { _ = len(c.Tags) })
+				}
+			}()
+		}
+	}
+	// Readers ranging over all sessions while writers run.
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				s.Range(func(string, *counter) {})
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, sessions, s.Len())
+	s.Range(func(id string, c *counter) {
+		assert.Equal(t, id, c.ID)
+		assert.Equal(t, perSession, c.Count, "session %s lost or gained updates", id)
+		assert.Equal(t, map[string]bool{id: true}, c.Tags, "session %s saw another session's state", id)
+	})
+}
+
+// TestStore_ConcurrentResetAndSweep races Reset and Sweep against updates.
+// Surviving sessions must still hold only their own state.
+func TestStore_ConcurrentResetAndSweep(t *testing.T) {
+	s := sessionstore.New(time.Millisecond, 0, newCounter)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 500; n++ {
+				s.Update(id, func(c *counter) { c.Count++ })
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 200; n++ {
+			if n%2 == 0 {
+				s.Reset()
+			} else {
+				s.Sweep()
+			}
+		}
+	}()
+	wg.Wait()
+
+	assert.LessOrEqual(t, s.Len(), 8)
+	s.Range(func(id string, c *counter) {
+		assert.Equal(t, id, c.ID)
+		assert.Positive(t, c.Count)
+	})
+}
+
+func TestStore_ViewExpiryKeepsConcurrentUpdate(t *testing.T) {
+	s := sessionstore.New(50*time.Millisecond, 0, newCounter)
+	defer s.Stop()
+
+	const n = 2000
+	for i := 0; i < n; i++ {
+		s.Update(fmt.Sprintf("s%d", i), func(c *counter) { c.Count++ })
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// A View that finds a session expired must not delete it once a concurrent
+	// Update has refreshed it (or replaced it with a new entry).
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("s%d", i)
+		wg.Add(2)
+		go func() { defer wg.Done(); s.View(id, nil) }()
+		go func() { defer wg.Done(); s.Update(id, func(c *counter) { c.Count++ }) }()
+	}
+	wg.Wait()
+	assert.Equal(t, n, s.Len(), "every updated session is still stored")
+}
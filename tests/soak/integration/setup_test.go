@@ -0,0 +1,29 @@
+// Soak Tests - Setup
+//
+// Long-running leak detection: drives the gateway with a bounded pool of
+// synthetic sessions and fails when heap, goroutines, or any in-memory store
+// keeps growing after warmup.
+//
+// Run with: make test-soak SOAK_DURATION=2h
+package integration
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+func init() {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	log.Logger = zerolog.New(io.Discard)
+}
+
+func TestMain(m *testing.M) {
+	gateway.EnableLocalHostsForTesting()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,211 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/tests/testkit"
+)
+
+// soakSettings are read from the environment. The soak only runs when
+// SOAK_DURATION is set, as `make test-soak` does, so a plain `go test ./...`
+// does not spend its duration or judge heap growth on a loaded machine.
+type soakSettings struct {
+	Duration    time.Duration // SOAK_DURATION (required; e.g. 30s for a smoke run, 2h for a soak)
+	Interval    time.Duration // SOAK_SAMPLE_INTERVAL (default: Duration/40)
+	Sessions    int           // SOAK_SESSIONS (default: 25)
+	Concurrency int           // SOAK_CONCURRENCY (default: 8)
+}
+
+func loadSoakSettings(t *testing.T) soakSettings {
+	t.Helper()
+	s := soakSettings{Sessions: 25, Concurrency: 8}
+	if v := os.Getenv("SOAK_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		require.NoError(t, err, "SOAK_DURATION")
+		s.Duration = d
+	}
+	if v := os.Getenv("SOAK_SAMPLE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		require.NoError(t, err, "SOAK_SAMPLE_INTERVAL")
+		s.Interval = d
+	}
+	if v := os.Getenv("SOAK_SESSIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		require.NoError(t, err, "SOAK_SESSIONS")
+		s.Sessions = n
+	}
+	if v := os.Getenv("SOAK_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		require.NoError(t, err, "SOAK_CONCURRENCY")
+		s.Concurrency = n
+	}
+	if s.Interval <= 0 {
+		s.Interval = s.Duration / 40
+	}
+	return s
+}
+
+func soakConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{
+			Port:         18080,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 120 * time.Second,
+		},
+		Pipes: config.PipesConfig{
+			ToolOutput: config.ToolOutputPipeConfig{
+				Enabled:                true,
+				Strategy:               "simple",
+				FallbackStrategy:       "passthrough",
+				MinTokens:              25,
+				MaxTokens:              16384,
+				TargetCompressionRatio: 0.1,
+				IncludeExpandHint:      true,
+				EnableExpandContext:    true,
+			},
+			ToolDiscovery: config.ToolDiscoveryPipeConfig{
+				Enabled:              true,
+				Strategy:             "tool-search",
+				FallbackStrategy:     "passthrough",
+				EnableSearchFallback: true,
+				MaxSearchResults:     5,
+			},
+		},
+		Store:      config.StoreConfig{Type: "memory", TTL: 1 * time.Hour},
+		Monitoring: config.MonitoringConfig{LogLevel: "disabled", LogFormat: "json", LogOutput: "discard"},
+	}
+}
+
+// soakRequest builds turn `turn` of a synthetic session. Each session cycles
+// through a fixed number of distinct tool outputs, so the working set is
+// bounded: any store that keeps growing after warmup is leaking.
+func soakRequest(session, turn int) map[string]interface{} {
+	const turnsPerSession = 4
+	variant := turn % turnsPerSession
+	toolID := fmt.Sprintf("toolu_soak_%d_%d", session, variant)
+	output := fmt.Sprintf("session=%d variant=%d\n%s", session, variant, testkit.LargeToolOutput(4000))
+	return map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 256,
+		"tools":      testkit.MakeAnthropicToolDefs(30),
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": fmt.Sprintf("soak session %d: inspect the logs", session)},
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "id": toolID, "name": "read_file", "input": map[string]interface{}{"path": "/var/log/app.log"}},
+			}},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": toolID, "content": output},
+			}},
+		},
+	}
+}
+
+func TestSoak_NoMonotonicGrowth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping soak test in short mode")
+	}
+	if os.Getenv("SOAK_DURATION") == "" {
+		t.Skip("Set SOAK_DURATION (or run `make test-soak`) to run the soak test")
+	}
+	settings := loadSoakSettings(t)
+	t.Logf("soak: duration=%s interval=%s sessions=%d concurrency=%d",
+		settings.Duration, settings.Interval, settings.Sessions, settings.Concurrency)
+
+	upstream := testkit.NewMockLLM(func(_ []byte, _ int) []byte {
+		return testkit.AnthropicTextResponse("ok")
+	})
+	defer upstream.Close()
+
+	gw := testkit.CreateGateway(soakConfig())
+	defer gw.Close()
+
+	stop := make(chan struct{})
+	var sent, failed atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < settings.Concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; ; i += settings.Concurrency {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				body := soakRequest(i%settings.Sessions, i/settings.Sessions)
+				resp, _, err := testkit.SendAnthropicRequest(gw.URL, upstream.URL(), body)
+				sent.Add(1)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					failed.Add(1)
+				}
+			}
+		}(w)
+	}
+
+	var samples []testkit.SoakSample
+	start := time.Now()
+	ticker := time.NewTicker(settings.Interval)
+	for time.Since(start) < settings.Duration {
+		<-ticker.C
+		sample, err := testkit.TakeSoakSample(gw.URL, time.Since(start))
+		require.NoError(t, err)
+		samples = append(samples, sample)
+	}
+	ticker.Stop()
+	close(stop)
+	wg.Wait()
+
+	t.Logf("soak: %d requests sent, %d failed, %d samples", sent.Load(), failed.Load(), len(samples))
+	require.NotZero(t, sent.Load())
+	assert.Zero(t, failed.Load(), "every soak request should succeed")
+
+	// Heap and goroutines jitter, so require a meaningful absolute increase
+	// before calling it a leak. Store counts are exact.
+	policies := map[string]testkit.GrowthPolicy{
+		"heap_alloc": {MinAbsolute: 16 << 20},
+		"goroutines": {MinAbsolute: 20},
+	}
+	for name, series := range testkit.SoakSeries(samples) {
+		assert.NoError(t, testkit.DetectMonotonicGrowth(name, series, policies[name]))
+	}
+}
+
+func TestSoak_DetectMonotonicGrowth(t *testing.T) {
+	ramp := func(n int, f func(i int) float64) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = f(i)
+		}
+		return out
+	}
+
+	t.Run("unbounded growth is flagged", func(t *testing.T) {
+		series := ramp(40, func(i int) float64 { return float64(100 + 10*i) })
+		assert.Error(t, testkit.DetectMonotonicGrowth("leaky", series, testkit.GrowthPolicy{}))
+	})
+
+	t.Run("fill then plateau passes", func(t *testing.T) {
+		series := ramp(40, func(i int) float64 { return float64(min(i, 8) * 50) })
+		assert.NoError(t, testkit.DetectMonotonicGrowth("capped", series, testkit.GrowthPolicy{}))
+	})
+
+	t.Run("noise below absolute floor passes", func(t *testing.T) {
+		series := ramp(40, func(i int) float64 { return float64(1000 + i) })
+		assert.NoError(t, testkit.DetectMonotonicGrowth("jitter", series, testkit.GrowthPolicy{MinAbsolute: 100}))
+	})
+
+	t.Run("too few samples passes", func(t *testing.T) {
+		assert.NoError(t, testkit.DetectMonotonicGrowth("short", []float64{1, 2, 3}, testkit.GrowthPolicy{}))
+	})
+}
@@ -0,0 +1,71 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+func TestSelfMetrics_MissingFileIsZero(t *testing.T) {
+	m, err := statedir.ReadSelfMetrics(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, statedir.SelfMetrics{}, m)
+}
+
+func TestSelfMetrics_AddAccumulates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+
+	first, err := statedir.AddSelfMetrics(dir, statedir.SelfMetrics{Starts: 1})
+	require.NoError(t, err)
+	assert.False(t, first.Since.IsZero())
+
+	total, err := statedir.AddSelfMetrics(dir, statedir.SelfMetrics{Requests: 3, TokensSaved: 1200, CostSavedUSD: 0.25})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total.Requests)
+	assert.EqualValues(t, 1200, total.TokensSaved)
+	assert.InDelta(t, 0.25, total.CostSavedUSD, 1e-9)
+	assert.EqualValues(t, 1, total.Starts)
+	assert.Equal(t, first.Since, total.Since, "since is the first start")
+
+	read, err := statedir.ReadSelfMetrics(dir)
+	require.NoError(t, err)
+	assert.Equal(t, total.Requests, read.Requests)
+	assert.True(t, total.Since.Equal(read.Since))
+}
+
+func TestSelfMetrics_ConcurrentAddsNotLost(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := statedir.AddSelfMetrics(dir, statedir.SelfMetrics{Requests: 1, TokensSaved: 10})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	m, err := statedir.ReadSelfMetrics(dir)
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, m.Requests)
+	assert.EqualValues(t, 200, m.TokensSaved)
+}
+
+func TestSelfMetrics_CorruptFileReplaced(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, statedir.SelfMetricsFile), []byte("{not json"), 0o600))
+
+	_, err := statedir.ReadSelfMetrics(dir)
+	require.Error(t, err)
+
+	m, err := statedir.AddSelfMetrics(dir, statedir.SelfMetrics{Starts: 1})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, m.Starts)
+}
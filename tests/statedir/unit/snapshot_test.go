@@ -0,0 +1,44 @@
+package unit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+func TestSnapshot_SealOpenRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"version":1,"costs":{"global_cost_usd":1.25}}`)
+
+	sealed, err := statedir.SealSnapshot(plaintext, "correct horse")
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("global_cost_usd")), "snapshot must not contain plaintext")
+
+	opened, err := statedir.OpenSnapshot(sealed, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestSnapshot_WrongPassphraseOrTampering(t *testing.T) {
+	sealed, err := statedir.SealSnapshot([]byte("state"), "correct horse")
+	require.NoError(t, err)
+
+	_, err = statedir.OpenSnapshot(sealed, "battery staple")
+	assert.ErrorIs(t, err, statedir.ErrSnapshotDecrypt)
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = statedir.OpenSnapshot(tampered, "correct horse")
+	assert.ErrorIs(t, err, statedir.ErrSnapshotDecrypt)
+
+	_, err = statedir.OpenSnapshot([]byte("not a snapshot"), "correct horse")
+	assert.Error(t, err)
+}
+
+func TestSnapshot_EmptyPassphraseRejected(t *testing.T) {
+	_, err := statedir.SealSnapshot([]byte("state"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/statedir"
+)
+
+func writeManifest(t *testing.T, dir string, m statedir.Manifest) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, statedir.ManifestFile), data, 0o600))
+}
+
+func TestOpen_FreshDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+
+	m, err := statedir.Open(dir, "v1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, statedir.CurrentVersion, m.Version)
+	assert.Equal(t, "v1.2.3", m.WrittenBy)
+	assert.FileExists(t, filepath.Join(dir, statedir.ManifestFile))
+
+	// Reopening is a no-op.
+	m, err = statedir.Open(dir, "v1.2.4")
+	require.NoError(t, err)
+	assert.Equal(t, statedir.CurrentVersion, m.Version)
+}
+
+func TestOpen_MigratesLegacyPromptHistory(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "state")
+	require.NoError(t, os.WriteFile(filepath.Join(root, statedir.PromptHistoryDB), []byte("db"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, statedir.PromptHistoryDB+"-wal"), []byte("wal"), 0o600))
+
+	_, err := statedir.Open(dir, "dev")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, statedir.PromptHistoryDB))
+	require.NoError(t, err)
+	assert.Equal(t, "db", string(data))
+	assert.FileExists(t, filepath.Join(dir, statedir.PromptHistoryDB+"-wal"))
+	assert.NoFileExists(t, filepath.Join(root, statedir.PromptHistoryDB))
+}
+
+func TestOpen_RefusesNewerState(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	writeManifest(t, dir, statedir.Manifest{Version: statedir.CurrentVersion + 1, WrittenBy: "v9.0.0"})
+
+	_, err := statedir.Open(dir, "v1.0.0")
+	require.ErrorIs(t, err, statedir.ErrStateTooNew)
+	assert.Contains(t, err.Error(), "v9.0.0")
+	assert.Contains(t, err.Error(), "--reset-state")
+}
+
+func TestOpen_CorruptManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, statedir.ManifestFile), []byte("{"), 0o600))
+
+	_, err := statedir.Open(dir, "dev")
+	assert.ErrorContains(t, err, "--reset-state")
+}
+
+func TestReset_MovesStateAside(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	writeManifest(t, dir, statedir.Manifest{Version: statedir.CurrentVersion + 1})
+
+	backup, err := statedir.Reset(dir)
+	require.NoError(t, err)
+	assert.NoDirExists(t, dir)
+	assert.FileExists(t, filepath.Join(backup, statedir.ManifestFile))
+
+	m, err := statedir.Open(dir, "dev")
+	require.NoError(t, err)
+	assert.Equal(t, statedir.CurrentVersion, m.Version)
+
+	backup, err = statedir.Reset(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, backup)
+}
@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_GzipRoundTrip(t *testing.T) {
+	s := store.NewMemoryStore(time.Hour)
+	defer s.Close()
+
+	large := strings.Repeat("2024-01-01T00:00:00Z INFO request handled path=/api/v1/users status=200\n", 200)
+	require.NoError(t, s.Set("orig", large))
+	require.NoError(t, s.SetCompressed("orig", large))
+
+	got, ok := s.Get("orig")
+	require.True(t, ok)
+	assert.Equal(t, large, got)
+	got, ok = s.GetCompressed("orig")
+	require.True(t, ok)
+	assert.Equal(t, large, got)
+
+	occ := s.Occupancy()
+	assert.Equal(t, 1, occ.Original.Entries)
+	assert.Equal(t, int64(len(large)), occ.Original.LogicalBytes)
+	assert.Less(t, occ.Original.PhysicalBytes, occ.Original.LogicalBytes/10, "repetitive logs compress well")
+	assert.Equal(t, int64(len(large)), occ.Compressed.LogicalBytes)
+}
+
+func TestMemoryStore_SmallAndIncompressibleStayVerbatim(t *testing.T) {
+	s := store.NewMemoryStore(time.Hour)
+	defer s.Close()
+
+	random := make([]byte, 4096)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	blob := base64.RawStdEncoding.EncodeToString(random)
+
+	require.NoError(t, s.Set("small", "short value"))
+	require.NoError(t, s.Set("blob", blob))
+
+	occ := s.Occupancy()
+	assert.Equal(t, occ.Original.LogicalBytes, occ.Original.PhysicalBytes)
+	got, ok := s.Get("blob")
+	require.True(t, ok)
+	assert.Equal(t, blob, got)
+}
+
+func TestMemoryStore_OccupancyTracksOverwriteDeleteReset(t *testing.T) {
+	s := store.NewMemoryStore(time.Hour)
+	defer s.Close()
+
+	require.NoError(t, s.Set("a", strings.Repeat("x", 100)))
+	require.NoError(t, s.Set("a", strings.Repeat("y", 50)))
+	require.NoError(t, s.Set("b", strings.Repeat("z", 30)))
+	assert.Equal(t, int64(80), s.Occupancy().Original.LogicalBytes, "overwrite replaces the old size")
+
+	require.NoError(t, s.Delete("a"))
+	assert.Equal(t, int64(30), s.Occupancy().Original.LogicalBytes)
+
+	s.Reset()
+	assert.Equal(t, store.Occupancy{}, s.Occupancy())
+}
@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_MaxEntries_EvictsLeastRecentlyUsed(t *testing.T) {
+	s := store.NewMemoryStoreWithDualTTL(time.Hour, time.Hour).WithLimits(3, 0)
+	defer s.Close()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.Set(fmt.Sprintf("shadow_%d", i), "value"))
+	}
+	_, ok := s.Get("shadow_1") // shadow_2 is now the least recently used
+	require.True(t, ok)
+	require.NoError(t, s.Set("shadow_4", "value"))
+
+	assert.Equal(t, 3, s.Sizes().Original)
+	_, ok = s.Get("shadow_2")
+	assert.False(t, ok, "least recently used entry evicted")
+	for _, key := range []string{"shadow_1", "shadow_3", "shadow_4"} {
+		_, ok = s.Get(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, int64(1), s.Metrics.OriginalEvictions.Load())
+}
+
+func TestMemoryStore_MaxBytes(t *testing.T) {
+	value := strings.Repeat("x", 100) // Below MinCompressSize: held verbatim
+	s := store.NewMemoryStoreWithDualTTL(time.Hour, time.Hour).WithLimits(0, 250)
+	defer s.Close()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.Set(fmt.Sprintf("shadow_%d", i), value))
+	}
+	occ := s.Occupancy().Original
+	assert.Equal(t, 2, occ.Entries)
+	assert.LessOrEqual(t, occ.PhysicalBytes, int64(250))
+
+	// An entry larger than the cap is still stored, alone.
+	require.NoError(t, s.Set("shadow_big", strings.Repeat("y", 300)))
+	assert.Equal(t, 1, s.Sizes().Original)
+	_, ok := s.Get("shadow_big")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), s.Metrics.OriginalEvictions.Load())
+}
+
+func TestMemoryStore_RecordExpandMiss(t *testing.T) {
+	s := store.NewMemoryStoreWithDualTTL(20*time.Millisecond, time.Hour).WithLimits(1, 0)
+	defer s.Close()
+
+	require.NoError(t, s.Set("shadow_old", "value"))
+	require.NoError(t, s.Set("shadow_new", "value"))
+	assert.Equal(t, store.MissEvicted, s.RecordExpandMiss("shadow_old"))
+	assert.Equal(t, store.MissUnknown, s.RecordExpandMiss("shadow_never"))
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok := s.Get("shadow_new")
+	require.False(t, ok)
+	assert.Equal(t, store.MissExpired, s.RecordExpandMiss("shadow_new"))
+
+	// Storing a key again clears its eviction record.
+	require.NoError(t, s.Set("shadow_old", "value"))
+	require.NoError(t, s.Delete("shadow_old"))
+	assert.Equal(t, store.MissUnknown, s.RecordExpandMiss("shadow_old"))
+
+	stats := s.EvictionStats()
+	assert.Equal(t, int64(1), stats.MissEvicted)
+	assert.Equal(t, int64(1), stats.MissExpired)
+	assert.Equal(t, int64(2), stats.MissUnknown)
+	assert.Equal(t, int64(2), stats.OriginalEvicted)
+}
+
+func TestMemoryStore_FieldRefEvictionIsRecorded(t *testing.T) {
+	s := store.NewMemoryStoreWithDualTTL(time.Hour, time.Hour)
+	defer s.Close()
+
+	for i := 0; i <= store.MaxFieldRefEntries; i++ {
+		require.NoError(t, s.SetFieldRef(&formats.FieldRef{ID: fmt.Sprintf("field_%d", i), Original: "v"}))
+	}
+	assert.Equal(t, store.MissEvicted, s.RecordExpandMiss("field_0"))
+}
+
+func TestStoreConfig_TTLsAndLimits(t *testing.T) {
+	original, compressed := store.Config{}.TTLs()
+	assert.Equal(t, store.DefaultOriginalTTL, original)
+	assert.Equal(t, store.DefaultCompressedTTL, compressed)
+
+	original, compressed = store.Config{OriginalTTL: time.Hour, CompressedTTL: 2 * time.Hour}.TTLs()
+	assert.Equal(t, time.Hour, original)
+	assert.Equal(t, 2*time.Hour, compressed)
+
+	base := store.Config{Type: store.TypeMemory, TTL: time.Hour}
+	bad := base
+	bad.MaxEntries = -1
+	assert.ErrorContains(t, bad.Validate(), "store.max_entries")
+	bad = base
+	bad.MaxBytes = -1
+	assert.ErrorContains(t, bad.Validate(), "store.max_bytes")
+	bad = base
+	bad.OriginalTTL = -time.Second
+	assert.ErrorContains(t, bad.Validate(), "store.original_ttl")
+
+	cfg := base
+	cfg.MaxEntries = 2
+	st, err := store.New(cfg)
+	require.NoError(t, err)
+	defer st.Close()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, st.Set(fmt.Sprintf("shadow_%d", i), "value"))
+	}
+	assert.Equal(t, 2, st.(*store.MemoryStore).Sizes().Original)
+}
@@ -0,0 +1,292 @@
+package unit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// fakeRedis is an in-process RESP2 server with the commands RedisStore uses.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	data    map[string]string
+	expires map[string]time.Time
+	conns   []net.Conn
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{ln: ln, password: password, data: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, c)
+			f.mu.Unlock()
+			go f.serve(c)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+// stop shuts the server down, dropping open connections.
+func (f *fakeRedis) stop() {
+	_ = f.ln.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		_ = c.Close()
+	}
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			fmt.Fprint(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		f.mu.Lock()
+		switch cmd {
+		case "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				fmt.Fprint(c, "+OK\r\n")
+			} else {
+				fmt.Fprint(c, "-WRONGPASS invalid username-password pair\r\n")
+			}
+		case "PING":
+			fmt.Fprint(c, "+PONG\r\n")
+		case "SELECT":
+			fmt.Fprint(c, "+OK\r\n")
+		case "SET":
+			f.data[args[1]] = args[2]
+			ms, _ := strconv.Atoi(args[4])
+			f.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			fmt.Fprint(c, "+OK\r\n")
+		case "GET":
+			v, ok := f.data[args[1]]
+			if !ok || time.Now().After(f.expires[args[1]]) {
+				fmt.Fprint(c, "$-1\r\n")
+			} else {
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+			}
+		case "DEL":
+			_, ok := f.data[args[1]]
+			delete(f.data, args[1])
+			fmt.Fprintf(c, ":%d\r\n", map[bool]int{true: 1, false: 0}[ok])
+		default:
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeRedis) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// exerciseStore checks every Store method against a persistent backend.
+func exerciseStore(t *testing.T, s store.Store) {
+	t.Helper()
+	large := strings.Repeat("line of a long tool output\n", 200) // Gzipped on write
+
+	require.NoError(t, s.Set("shadow_1", "small original"))
+	require.NoError(t, s.Set("shadow_2", large))
+	require.NoError(t, s.SetCompressed("shadow_1", "compressed"))
+	require.NoError(t, s.SetExpansion("shadow_1", &store.ExpansionRecord{
+		AssistantMessage:  []byte(`{"role":"assistant"}`),
+		ToolResultMessage: []byte(`{"role":"user"}`),
+	}))
+	require.NoError(t, s.SetFieldRefs([]*formats.FieldRef{{ID: "field_1", Field: "description", Original: "full text"}, nil}))
+
+	v, ok := s.Get("shadow_1")
+	require.True(t, ok)
+	assert.Equal(t, "small original", v)
+	v, ok = s.Get("shadow_2")
+	require.True(t, ok)
+	assert.Equal(t, large, v)
+	v, ok = s.GetCompressed("shadow_1")
+	require.True(t, ok)
+	assert.Equal(t, "compressed", v)
+	exp, ok := s.GetExpansion("shadow_1")
+	require.True(t, ok)
+	assert.JSONEq(t, `{"role":"assistant"}`, string(exp.AssistantMessage))
+	ref, ok := s.GetFieldRef("field_1")
+	require.True(t, ok)
+	assert.Equal(t, "full text", ref.Original)
+
+	_, ok = s.Get("missing")
+	assert.False(t, ok)
+
+	require.NoError(t, s.Delete("shadow_1"))
+	_, ok = s.Get("shadow_1")
+	assert.False(t, ok)
+	_, ok = s.GetCompressed("shadow_1")
+	assert.False(t, ok, "Delete also removes the compressed version")
+	require.NoError(t, s.DeleteExpansion("shadow_1"))
+	_, ok = s.GetExpansion("shadow_1")
+	assert.False(t, ok)
+	require.NoError(t, s.DeleteFieldRef("field_1"))
+	_, ok = s.GetFieldRef("field_1")
+	assert.False(t, ok)
+}
+
+func TestSQLiteStore_AllKinds(t *testing.T) {
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "shadow.db"), time.Hour, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	exerciseStore(t, s)
+}
+
+func TestSQLiteStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shadow.db")
+	s, err := store.NewSQLiteStore(path, time.Hour, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, s.Set("shadow_abc", "original tool output"))
+	require.NoError(t, s.Close())
+
+	s, err = store.NewSQLiteStore(path, time.Hour, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	v, ok := s.Get("shadow_abc")
+	require.True(t, ok, "entries written before a restart are still there")
+	assert.Equal(t, "original tool output", v)
+}
+
+func TestSQLiteStore_ExpiryAndSweep(t *testing.T) {
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "shadow.db"), 20*time.Millisecond, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Set("short", "original"))
+	require.NoError(t, s.SetCompressed("short", "compressed"))
+	time.Sleep(40 * time.Millisecond)
+
+	_, ok := s.Get("short")
+	assert.False(t, ok, "original expires after its TTL")
+	_, ok = s.GetCompressed("short")
+	assert.True(t, ok, "compressed keeps its longer TTL")
+
+	n, err := s.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	sizes, err := s.Sizes()
+	require.NoError(t, err)
+	assert.Equal(t, store.Sizes{Compressed: 1}, sizes)
+}
+
+func TestRedisStore_AllKinds(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	s, err := store.NewRedisStore(store.RedisConfig{Addr: srv.addr(), KeyPrefix: "test:"}, time.Hour, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	exerciseStore(t, s)
+
+	require.NoError(t, s.Set("shadow_x", "v"))
+	assert.Contains(t, srv.keys(), "test:original:shadow_x")
+}
+
+func TestRedisStore_Auth(t *testing.T) {
+	srv := newFakeRedis(t, "s3cret")
+
+	_, err := store.NewRedisStore(store.RedisConfig{Addr: srv.addr(), Password: "wrong"}, time.Hour, time.Hour)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WRONGPASS")
+
+	s, err := store.NewRedisStore(store.RedisConfig{Addr: srv.addr(), Password: "s3cret", DB: 2}, time.Hour, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Set("k", "v"))
+	v, ok := s.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, "v", v)
+}
+
+func TestRedisStore_ServerDownIsAMiss(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	s, err := store.NewRedisStore(store.RedisConfig{Addr: srv.addr(), Timeout: 200 * time.Millisecond}, time.Hour, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Set("k", "v"))
+
+	srv.stop()
+	_, ok := s.Get("k")
+	assert.False(t, ok)
+	assert.Error(t, s.Set("k", "v"))
+}
+
+func TestStoreConfig_Validate(t *testing.T) {
+	assert.NoError(t, store.Config{Type: store.TypeSQLite, TTL: time.Hour}.Validate())
+	assert.ErrorContains(t, store.Config{Type: "postgres", TTL: time.Hour}.Validate(), "invalid store.type")
+	assert.ErrorContains(t, store.Config{TTL: time.Hour}.Validate(), "store.type is required")
+	assert.ErrorContains(t, store.Config{Type: store.TypeMemory}.Validate(), "store.ttl is required")
+	assert.ErrorContains(t, store.Config{Type: store.TypeRedis, TTL: time.Hour, Redis: store.RedisConfig{DB: -1}}.Validate(), "store.redis.db")
+}
+
+func TestNew_FallsBackToMemoryType(t *testing.T) {
+	s, err := store.New(store.Config{Type: store.TypeMemory, TTL: time.Hour})
+	require.NoError(t, err)
+	defer s.Close()
+	_, ok := s.(*store.MemoryStore)
+	assert.True(t, ok)
+}
@@ -0,0 +1,199 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/tenancy"
+)
+
+// aliceHash is the SHA-256 of gw-research-alice.
+const aliceHash = "43dc4555e6738470684e96b74b4b7c264f78f5f8dd18d0220c40f4ece7ee111c"
+
+func testTenancy() tenancy.Config {
+	return tenancy.Config{
+		Enabled: true,
+		Tenants: []tenancy.Tenant{
+			{Name: "research", KeyPrefixes: []string{"gw-research-"}, KeyHashes: []string{aliceHash}, ProviderKeys: map[string]string{"anthropic": "sk-ant-research"}},
+			{Name: "research-ml", KeyPrefixes: []string{"gw-research-ml-"}},
+			{Name: "support"},
+		},
+	}
+}
+
+func headers(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestRegistry_Disabled(t *testing.T) {
+	r := tenancy.NewRegistry(tenancy.Config{})
+	assert.Nil(t, r)
+	tenant, err := r.Resolve(headers(tenancy.HeaderTenant, "support"))
+	assert.NoError(t, err)
+	assert.Nil(t, tenant)
+	assert.Nil(t, r.Get("support"))
+	assert.Empty(t, r.Names())
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	r := tenancy.NewRegistry(testTenancy())
+	assert.Equal(t, []string{"research", "research-ml", "support"}, r.Names())
+
+	tests := []struct {
+		name    string
+		h       http.Header
+		want    string
+		wantErr error
+	}{
+		{"key prefix", headers("x-api-key", "gw-research-alice"), "research", nil},
+		{"longest prefix wins", headers("x-api-key", "gw-research-ml-bob"), "research-ml", nil},
+		{"bearer key", headers("Authorization", "Bearer gw-research-alice"), "research", nil},
+		{"key and matching header", headers("x-api-key", "gw-research-alice", tenancy.HeaderTenant, "research"), "research", nil},
+		{"header only", headers(tenancy.HeaderTenant, "support"), "support", nil},
+		{"header with unrelated key", headers("x-api-key", "sk-ant-own", tenancy.HeaderTenant, "support"), "support", nil},
+		{"no tenant", headers("x-api-key", "sk-ant-own"), "", nil},
+		{"key and other header", headers("x-api-key", "gw-research-alice", tenancy.HeaderTenant, "support"), "", tenancy.ErrTenantMismatch},
+		{"prefix without a known key", headers("x-api-key", "gw-research-anything"), "", tenancy.ErrTenantKey},
+		{"bearer prefix without a known key", headers("Authorization", "Bearer gw-research-mallory"), "", tenancy.ErrTenantKey},
+		{"key-only tenant by header", headers(tenancy.HeaderTenant, "research"), "", tenancy.ErrTenantMismatch},
+		{"unknown tenant", headers(tenancy.HeaderTenant, "nope"), "", tenancy.ErrUnknownTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := r.Resolve(tt.h)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				assert.Nil(t, tenant)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tenancy.NameOf(tenant))
+		})
+	}
+}
+
+func TestRegistry_KeyHashes(t *testing.T) {
+	cfg := testTenancy()
+	cfg.Tenants[0].KeyHashes = []string{strings.ToUpper(aliceHash)}
+	require.NoError(t, cfg.Validate())
+	r := tenancy.NewRegistry(cfg)
+
+	tenant, err := r.Resolve(headers("x-api-key", "gw-research-alice"))
+	require.NoError(t, err)
+	assert.Equal(t, "research", tenant.Name, "hashes are compared case-insensitively")
+
+	// Tenants without key hashes are still selected by prefix alone.
+	tenant, err = r.Resolve(headers("x-api-key", "gw-research-ml-anyone"))
+	require.NoError(t, err)
+	assert.Equal(t, "research-ml", tenant.Name)
+}
+
+func TestRegistry_Required(t *testing.T) {
+	cfg := testTenancy()
+	cfg.Required = true
+	r := tenancy.NewRegistry(cfg)
+
+	_, err := r.Resolve(headers("x-api-key", "sk-ant-own"))
+	assert.ErrorIs(t, err, tenancy.ErrTenantRequired)
+
+	tenant, err := r.Resolve(headers(tenancy.HeaderTenant, "support"))
+	require.NoError(t, err)
+	assert.Equal(t, "support", tenant.Name)
+}
+
+func TestTenant_ApplyProviderKey(t *testing.T) {
+	tenant := &tenancy.Tenant{Name: "t", ProviderKeys: map[string]string{
+		"anthropic": "sk-ant-t",
+		"openai":    "sk-openai-t",
+		"gemini":    "gemini-t",
+	}}
+
+	h := headers("x-api-key", "gw-t-alice")
+	require.True(t, tenant.ApplyProviderKey(h, adapters.ProviderAnthropic))
+	assert.Equal(t, "sk-ant-t", h.Get("x-api-key"))
+	assert.Empty(t, h.Get("Authorization"))
+
+	h = headers("Authorization", "Bearer gw-t-alice")
+	require.True(t, tenant.ApplyProviderKey(h, adapters.ProviderAnthropic))
+	assert.Equal(t, "sk-ant-t", h.Get("x-api-key"))
+	assert.Empty(t, h.Get("Authorization"), "client credential is removed")
+
+	h = headers("Authorization", "Bearer gw-t-alice")
+	require.True(t, tenant.ApplyProviderKey(h, adapters.ProviderOpenAI))
+	assert.Equal(t, "Bearer sk-openai-t", h.Get("Authorization"))
+
+	h = headers("api-key", "gw-t-alice")
+	require.True(t, tenant.ApplyProviderKey(h, adapters.ProviderOpenAI))
+	assert.Equal(t, "sk-openai-t", h.Get("api-key"), "Azure clients keep api-key")
+	assert.Empty(t, h.Get("Authorization"))
+
+	h = headers("x-goog-api-key", "gw-t-alice")
+	require.True(t, tenant.ApplyProviderKey(h, adapters.ProviderGemini))
+	assert.Equal(t, "gemini-t", h.Get("x-goog-api-key"))
+
+	// No key for the provider: the client's credential is left alone.
+	h = headers("Authorization", "Bearer client-key")
+	assert.False(t, tenant.ApplyProviderKey(h, adapters.ProviderOllama))
+	assert.Equal(t, "Bearer client-key", h.Get("Authorization"))
+
+	var none *tenancy.Tenant
+	assert.False(t, none.ApplyProviderKey(h, adapters.ProviderAnthropic))
+}
+
+func TestTenant_SessionIDAndContext(t *testing.T) {
+	tenant := &tenancy.Tenant{Name: "research"}
+	assert.Equal(t, "research:abc", tenant.SessionID("abc"))
+	assert.Equal(t, "", tenant.SessionID(""))
+	assert.Equal(t, "research:", tenant.SessionPrefix())
+
+	var none *tenancy.Tenant
+	assert.Equal(t, "abc", none.SessionID("abc"))
+	assert.Equal(t, "", none.SessionPrefix())
+
+	ctx := tenancy.WithTenant(context.Background(), tenant)
+	assert.Same(t, tenant, tenancy.FromContext(ctx))
+	assert.Nil(t, tenancy.FromContext(context.Background()))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, tenancy.Config{}.Validate(), "disabled config is not checked")
+	assert.NoError(t, testTenancy().Validate())
+
+	tests := []struct {
+		name   string
+		mutate func(*tenancy.Config)
+		want   string
+	}{
+		{"no tenants", func(c *tenancy.Config) { c.Tenants = nil }, "at least one tenant"},
+		{"missing name", func(c *tenancy.Config) { c.Tenants[2].Name = "" }, "name is required"},
+		{"invalid name", func(c *tenancy.Config) { c.Tenants[2].Name = "a:b" }, "must be 1-64"},
+		{"duplicate name", func(c *tenancy.Config) { c.Tenants[2].Name = "research" }, "used twice"},
+		{"empty prefix", func(c *tenancy.Config) { c.Tenants[2].KeyPrefixes = []string{" "} }, "empty values"},
+		{"shared prefix", func(c *tenancy.Config) { c.Tenants[2].KeyPrefixes = []string{"gw-research-"} }, `tenant "research"`},
+		{"unknown provider", func(c *tenancy.Config) { c.Tenants[2].ProviderKeys = map[string]string{"bedrock": "k"} }, "unknown provider"},
+		{"empty key", func(c *tenancy.Config) { c.Tenants[2].ProviderKeys = map[string]string{"openai": ""} }, "is empty"},
+		{"negative budget", func(c *tenancy.Config) { c.Tenants[2].Budget = -1 }, "budget must be >= 0"},
+		{"bad key hash", func(c *tenancy.Config) { c.Tenants[0].KeyHashes = []string{"abc"} }, "not a hex SHA-256"},
+		{"provider keys without key hashes", func(c *tenancy.Config) { c.Tenants[0].KeyHashes = nil }, "key_hashes is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testTenancy()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
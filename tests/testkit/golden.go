@@ -0,0 +1,54 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// =============================================================================
+// GOLDEN FILES
+// =============================================================================
+
+// UpdateGolden reports whether golden files should be rewritten instead of
+// compared. Set UPDATE_GOLDEN=1 to regenerate after an intended change:
+//
+//	UPDATE_GOLDEN=1 go test ./tests/golden/...
+func UpdateGolden() bool {
+	return os.Getenv("UPDATE_GOLDEN") != ""
+}
+
+// AssertGoldenJSON compares the JSON document got with the golden file at path.
+// Both are indented before comparing, so golden files stay readable in diffs
+// while key order (which pipes must preserve for the KV-cache) still counts.
+// With UPDATE_GOLDEN set, the golden file is written instead.
+func AssertGoldenJSON(t testing.TB, path string, got []byte) {
+	t.Helper()
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, got, "", "  "); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, got)
+	}
+	indented.WriteByte('\n')
+
+	if UpdateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("create golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, indented.Bytes(), 0600); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path) // #nosec G304 -- test golden path
+	if err != nil {
+		t.Fatalf("read golden file (run with UPDATE_GOLDEN=1 to create it): %v", err)
+	}
+	if !bytes.Equal(want, indented.Bytes()) {
+		t.Errorf("output differs from %s (run with UPDATE_GOLDEN=1 to accept)\n--- want\n%s\n--- got\n%s",
+			path, want, indented.Bytes())
+	}
+}
@@ -0,0 +1,80 @@
+package testkit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// CUSTOM PIPE PLUGIN
+// =============================================================================
+
+// envTestPlugin makes a test binary act as an exec custom pipe plugin.
+const envTestPlugin = "CONTEXT_GATEWAY_TEST_PLUGIN"
+
+// PluginCommand returns a pipes.custom command and env that start the
+// current test binary as a plugin. The test package's TestMain must call
+// ServeTestPluginIfRequested first.
+//
+// The plugin answers each process call by the request body's content:
+//   - "CRASH": exits
+//   - "SLOW": waits 2s, then leaves the body unchanged
+//   - "ignore previous instructions": rejects the request
+//   - otherwise: replaces every options.word with options.replacement and
+//     reports the count in metrics.replaced (unchanged body when zero)
+func PluginCommand() ([]string, map[string]string) {
+	return []string{os.Args[0]}, map[string]string{envTestPlugin: "1"}
+}
+
+// ServeTestPluginIfRequested runs the test plugin and exits when the binary
+// was started by PluginCommand; otherwise it returns immediately.
+func ServeTestPluginIfRequested() {
+	if os.Getenv(envTestPlugin) == "" {
+		return
+	}
+	var opts struct {
+		Word        string `json:"word"`
+		Replacement string `json:"replacement"`
+	}
+	_ = json.Unmarshal([]byte(os.Getenv("CONTEXT_GATEWAY_PIPE_OPTIONS")), &opts)
+
+	out := json.NewEncoder(os.Stdout)
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadBytes('\n')
+		if err != nil {
+			os.Exit(0)
+		}
+		var req struct {
+			ID     int64 `json:"id"`
+			Params struct {
+				Body json.RawMessage `json:"body"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(line, &req) != nil {
+			continue
+		}
+		body := string(req.Params.Body)
+		result := map[string]any{}
+		switch {
+		case strings.Contains(body, "CRASH"):
+			fmt.Fprintln(os.Stderr, "plugin: crashing on request")
+			os.Exit(3)
+		case strings.Contains(body, "SLOW"):
+			time.Sleep(2 * time.Second)
+		case strings.Contains(body, "ignore previous instructions"):
+			result["reject"] = "prompt injection detected"
+		case opts.Word != "":
+			n := strings.Count(body, opts.Word)
+			if n > 0 {
+				result["body"] = json.RawMessage(strings.ReplaceAll(body, opts.Word, opts.Replacement))
+			}
+			result["metrics"] = map[string]float64{"replaced": float64(n)}
+		}
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}
+}
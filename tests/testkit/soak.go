@@ -0,0 +1,150 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/compresr/context-gateway/internal/gateway"
+)
+
+// =============================================================================
+// SOAK HELPERS
+// =============================================================================
+
+// SoakSample is one point-in-time measurement taken during a soak run.
+type SoakSample struct {
+	Elapsed    time.Duration
+	HeapAlloc  uint64 // bytes live after a forced GC
+	Goroutines int
+	Stores     gateway.StoreSizes
+}
+
+// TakeSoakSample forces a GC, reads runtime stats, and fetches store sizes from
+// the gateway's /stats endpoint.
+func TakeSoakSample(gwURL string, elapsed time.Duration) (SoakSample, error) {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	sample := SoakSample{
+		Elapsed:    elapsed,
+		HeapAlloc:  m.HeapAlloc,
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(gwURL + "/stats")
+	if err != nil {
+		return sample, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sample, fmt.Errorf("/stats returned %d", resp.StatusCode)
+	}
+	var stats gateway.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return sample, err
+	}
+	sample.Stores = stats.Stores
+	return sample, nil
+}
+
+// SoakSeries extracts the named metrics from samples, in sample order.
+// Keys: heap_alloc, goroutines, shadow_original, shadow_compressed,
+// shadow_expansions, shadow_field_refs, tool_sessions, auth_fallback_sessions,
+// cost_sessions, preemptive_sessions, passthrough_cache.
+func SoakSeries(samples []SoakSample) map[string][]float64 {
+	series := make(map[string][]float64)
+	add := func(name string, v float64) { series[name] = append(series[name], v) }
+	for _, s := range samples {
+		add("heap_alloc", float64(s.HeapAlloc))
+		add("goroutines", float64(s.Goroutines))
+		add("shadow_original", float64(s.Stores.Shadow.Original))
+		add("shadow_compressed", float64(s.Stores.Shadow.Compressed))
+		add("shadow_expansions", float64(s.Stores.Shadow.Expansions))
+		add("shadow_field_refs", float64(s.Stores.Shadow.FieldRefs))
+		add("tool_sessions", float64(s.Stores.ToolSessions))
+		add("auth_fallback_sessions", float64(s.Stores.AuthFallback))
+		add("cost_sessions", float64(s.Stores.CostSessions))
+		add("preemptive_sessions", float64(s.Stores.PreemptiveSessions))
+		add("passthrough_cache", float64(s.Stores.PassthroughCache))
+	}
+	return series
+}
+
+// GrowthPolicy tunes DetectMonotonicGrowth.
+type GrowthPolicy struct {
+	Warmup      float64 // Fraction of leading samples ignored while caches fill (default: 0.25)
+	Windows     int     // Number of windows the remaining samples are averaged into (default: 4)
+	MinGrowth   float64 // Relative growth first→last window that counts as a leak (default: 0.10)
+	MinAbsolute float64 // Absolute growth below which a series is never flagged (default: 0)
+}
+
+func (p GrowthPolicy) withDefaults() GrowthPolicy {
+	if p.Warmup <= 0 || p.Warmup >= 1 {
+		p.Warmup = 0.25
+	}
+	if p.Windows < 2 {
+		p.Windows = 4
+	}
+	if p.MinGrowth <= 0 {
+		p.MinGrowth = 0.10
+	}
+	return p
+}
+
+// DetectMonotonicGrowth reports an error when series keeps growing after warmup.
+//
+// The post-warmup samples are split into windows and averaged. A series is a
+// leak when every window mean is strictly higher than the previous one AND the
+// last window exceeds the first by more than MinGrowth (relative) and
+// MinAbsolute. Stores that fill up and then plateau at their cap or TTL
+// steady state pass; stores that grow without bound fail.
+func DetectMonotonicGrowth(name string, series []float64, p GrowthPolicy) error {
+	p = p.withDefaults()
+	start := int(float64(len(series)) * p.Warmup)
+	tail := series[start:]
+	if len(tail) < p.Windows {
+		return nil // not enough data to judge
+	}
+
+	means := make([]float64, p.Windows)
+	size := len(tail) / p.Windows
+	for w := 0; w < p.Windows; w++ {
+		end := (w + 1) * size
+		if w == p.Windows-1 {
+			end = len(tail)
+		}
+		sum := 0.0
+		for _, v := range tail[w*size : end] {
+			sum += v
+		}
+		means[w] = sum / float64(end-w*size)
+	}
+
+	for w := 1; w < len(means); w++ {
+		if means[w] <= means[w-1] {
+			return nil
+		}
+	}
+	first, last := means[0], means[len(means)-1]
+	growth := last - first
+	if growth <= p.MinAbsolute {
+		return nil
+	}
+	if first > 0 && growth/first <= p.MinGrowth {
+		return nil
+	}
+	return fmt.Errorf("%s grows monotonically after warmup: window means %v", name, formatMeans(means))
+}
+
+func formatMeans(means []float64) []string {
+	out := make([]string, len(means))
+	for i, m := range means {
+		out[i] = fmt.Sprintf("%.0f", m)
+	}
+	return out
+}
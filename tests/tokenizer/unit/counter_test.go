@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/tokenizer"
+)
+
+const claudeBody = `{"model":"claude-sonnet-4-5","max_tokens":1024,"stream":true,"system":"be brief","messages":[{"role":"user","content":"hello"}]}`
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, tokenizer.Config{}.Validate())
+	assert.NoError(t, tokenizer.Config{Counter: tokenizer.CounterApprox}.Validate())
+	assert.NoError(t, tokenizer.Config{Counter: tokenizer.CounterAnthropicAPI, APIKey: "sk-ant-x"}.Validate())
+	assert.Error(t, tokenizer.Config{Counter: tokenizer.CounterAnthropicAPI}.Validate(), "needs an API key")
+	assert.Error(t, tokenizer.Config{Counter: "bytes"}.Validate())
+	assert.Error(t, tokenizer.Config{Timeout: -1}.Validate())
+}
+
+func TestNew_SelectsCounter(t *testing.T) {
+	c, err := tokenizer.New(tokenizer.Config{})
+	require.NoError(t, err)
+	assert.IsType(t, tokenizer.Tiktoken{}, c)
+
+	c, err = tokenizer.New(tokenizer.Config{Counter: tokenizer.CounterApprox})
+	require.NoError(t, err)
+	assert.IsType(t, tokenizer.Approx{}, c)
+
+	c, err = tokenizer.New(tokenizer.Config{Counter: tokenizer.CounterAnthropicAPI, APIKey: "sk-ant-x"})
+	require.NoError(t, err)
+	assert.IsType(t, &tokenizer.AnthropicCounter{}, c)
+}
+
+func TestApprox_CodeCostsMoreThanProsePerByte(t *testing.T) {
+	prose := strings.Repeat("the quick brown fox jumps over the lazy dog ", 50)
+	code := strings.Repeat(`{"a":[1,2],"b":{"c":null}}; `, 50)
+
+	var a tokenizer.Approx
+	proseRate := float64(a.CountText(prose, "claude-sonnet-4-5")) / float64(len(prose))
+	codeRate := float64(a.CountText(code, "claude-sonnet-4-5")) / float64(len(code))
+	assert.Greater(t, codeRate, proseRate*1.5)
+	assert.Greater(t, codeRate, 0.25, "bytes/4 undercounts punctuation-dense content")
+}
+
+func TestApprox_PerModelRatio(t *testing.T) {
+	text := strings.Repeat("context gateway ", 100)
+	var a tokenizer.Approx
+	assert.Greater(t, a.CountText(text, "claude-sonnet-4-5"), a.CountText(text, "gpt-4o"))
+	assert.Equal(t, a.CountText(text, "gpt-4o"), a.CountText(text, "some-unknown-model"))
+	assert.Equal(t, 0, a.CountText("", "gpt-4o"))
+}
+
+// countServer answers count_tokens with n and records the requests it got.
+func countServer(t *testing.T, status, n int) (*httptest.Server, *atomic.Int32, *map[string]any) {
+	t.Helper()
+	var hits atomic.Int32
+	var last map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		assert.Equal(t, "sk-ant-test", r.Header.Get("x-api-key"))
+		assert.NotEmpty(t, r.Header.Get("anthropic-version"))
+		_ = json.NewDecoder(r.Body).Decode(&last)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]int{"input_tokens": n})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits, &last
+}
+
+func TestAnthropicCounter_CountsAndCaches(t *testing.T) {
+	srv, hits, last := countServer(t, http.StatusOK, 42)
+	c := tokenizer.NewAnthropicCounter(tokenizer.Config{APIKey: "sk-ant-test", Endpoint: srv.URL})
+
+	assert.Equal(t, 42, c.CountRequest([]byte(claudeBody), "claude-sonnet-4-5"))
+	assert.Equal(t, 42, c.CountRequest([]byte(claudeBody), "claude-sonnet-4-5"))
+	assert.Equal(t, int32(1), hits.Load(), "second count served from cache")
+
+	assert.Equal(t, "claude-sonnet-4-5", (*last)["model"])
+	assert.Equal(t, "be brief", (*last)["system"])
+	assert.NotContains(t, *last, "max_tokens", "only countable fields are sent")
+	assert.NotContains(t, *last, "stream")
+}
+
+func TestAnthropicCounter_FallsBackToTiktoken(t *testing.T) {
+	srv, hits, _ := countServer(t, http.StatusInternalServerError, 42)
+	c := tokenizer.NewAnthropicCounter(tokenizer.Config{APIKey: "sk-ant-test", Endpoint: srv.URL})
+	want := tokenizer.CountBytesForModel([]byte(claudeBody), "claude-sonnet-4-5")
+
+	assert.Equal(t, want, c.CountRequest([]byte(claudeBody), "claude-sonnet-4-5"), "endpoint error")
+	assert.Equal(t, want, c.CountRequest([]byte(claudeBody), "claude-sonnet-4-5"), "failures are not cached")
+	assert.Equal(t, int32(2), hits.Load())
+
+	assert.Equal(t, tokenizer.CountBytesForModel([]byte(claudeBody), "gpt-4o"),
+		c.CountRequest([]byte(claudeBody), "gpt-4o"), "non-Claude model")
+	assert.Equal(t, tokenizer.CountTokensForModel("hello world", "claude-sonnet-4-5"),
+		c.CountText("hello world", "claude-sonnet-4-5"), "text is counted locally")
+	assert.Equal(t, int32(2), hits.Load(), "neither calls the endpoint")
+}
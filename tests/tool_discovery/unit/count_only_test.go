@@ -0,0 +1,46 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+)
+
+// =============================================================================
+// COUNT-ONLY REQUESTS (count_tokens)
+// =============================================================================
+
+func TestPipe_Compresr_CountOnlySelectsLocally(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(config.StrategyCompresr, 5, nil)
+	cfg.URLs.Compresr = server.URL
+	cfg.Pipes.ToolDiscovery.Compresr.APIKey = "test-key"
+	pipe := tooldiscovery.New(cfg)
+
+	ctx := newOpenAIPipeContext(openAIRequestWithToolsAndQuery(10, "test query"))
+	ctx.UserQuery = "test query"
+	ctx.CountOnly = true
+	_, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.True(t, ctx.ToolsFiltered, "tools are still filtered, by local relevance")
+	assert.Zero(t, calls.Load(), "a count makes no uncached selection call")
+
+	ctx = newOpenAIPipeContext(openAIRequestWithToolsAndQuery(10, "test query"))
+	ctx.UserQuery = "test query"
+	_, err = pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, calls.Load(), "a request selects via the API")
+}
@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+)
+
+// =============================================================================
+// EMBEDDINGS STRATEGY
+// =============================================================================
+
+func keptToolNames(t *testing.T, result []byte) []string {
+	t.Helper()
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(result, &req))
+	return effectiveToolNames(req["tools"].([]any))
+}
+
+func TestPipe_Process_Embeddings_LocalMatchesWordForms(t *testing.T) {
+	pipe := tooldiscovery.New(testConfig(config.StrategyEmbeddings, 1, nil))
+
+	// "compressing" shares no whole word with "compress", so keyword scoring
+	// ties every tool at zero; trigram embeddings still rank compress_data first.
+	body := []byte(`{
+		"model": "gpt-4o",
+		"messages": [{"role": "user", "content": "compressing these archives"}],
+		"tools": [
+			{"type": "function", "function": {"name": "deploy_app", "description": "Deploy application to production"}},
+			{"type": "function", "function": {"name": "send_email", "description": "Send an email notification"}},
+			{"type": "function", "function": {"name": "run_tests", "description": "Run test suite"}},
+			{"type": "function", "function": {"name": "compress_data", "description": "Compress data using gzip"}}
+		]
+	}`)
+
+	ctx := newOpenAIPipeContext(body)
+	ctx.UserQuery = "compressing these archives"
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+	assert.Equal(t, []string{"compress_data"}, keptToolNames(t, result))
+	assert.Empty(t, ctx.ToolDiscoveryModel, "local embeddings involve no model")
+}
+
+// embeddingsServer returns [1,0] for the query and for texts containing
+// match, [0,1] otherwise, and records the inputs of every call.
+func embeddingsServer(t *testing.T, match string) (*httptest.Server, func() [][]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		assert.Equal(t, "Bearer emb-key", r.Header.Get("Authorization"))
+		mu.Lock()
+		calls = append(calls, req.Input)
+		mu.Unlock()
+
+		data := make([]map[string]any, len(req.Input))
+		for i, text := range req.Input {
+			vec := []float32{0, 1}
+			if i == 0 || strings.Contains(text, match) {
+				vec = []float32{1, 0}
+			}
+			data[i] = map[string]any{"index": i, "embedding": vec}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), calls...)
+	}
+}
+
+func embeddingsEndpointConfig(endpoint string) *config.Config {
+	cfg := testConfig(config.StrategyEmbeddings, 1, nil)
+	cfg.Pipes.ToolDiscovery.Embeddings.Endpoint = endpoint
+	cfg.Pipes.ToolDiscovery.Embeddings.Model = "nomic-embed-text"
+	cfg.Pipes.ToolDiscovery.Embeddings.APIKey = "emb-key"
+	return cfg
+}
+
+func TestPipe_Process_Embeddings_EndpointRanksAndCachesPerSession(t *testing.T) {
+	srv, calls := embeddingsServer(t, "deploy_app")
+	pipe := tooldiscovery.New(embeddingsEndpointConfig(srv.URL + "/v1/embeddings"))
+
+	body := openAIRequestWithToolsAndQuery(12, "ship it")
+	for turn := 0; turn < 2; turn++ {
+		ctx := newOpenAIPipeContext(body)
+		ctx.ToolSessionID = "session-a"
+		ctx.UserQuery = "ship it"
+		result, err := pipe.Process(ctx)
+		require.NoError(t, err)
+		require.True(t, ctx.ToolsFiltered)
+		assert.Equal(t, []string{"deploy_app"}, keptToolNames(t, result))
+		assert.Equal(t, "nomic-embed-text", ctx.ToolDiscoveryModel)
+	}
+
+	got := calls()
+	require.Len(t, got, 2)
+	assert.Len(t, got[0], 13, "first turn embeds the query and every tool")
+	assert.Equal(t, []string{"ship it"}, got[1], "tool vectors are cached for the session")
+
+	// Another session embeds its tools again.
+	ctx := newOpenAIPipeContext(body)
+	ctx.ToolSessionID = "session-b"
+	ctx.UserQuery = "ship it"
+	_, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.Len(t, calls()[2], 13)
+}
+
+func TestPipe_Process_Embeddings_EndpointFailureFallsBackToKeywords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	pipe := tooldiscovery.New(embeddingsEndpointConfig(srv.URL))
+
+	ctx := newOpenAIPipeContext(openAIRequestWithToolsAndQuery(12, "run the test suite"))
+	ctx.UserQuery = "run the test suite"
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+	assert.Equal(t, []string{"run_tests"}, keptToolNames(t, result))
+}
+
+func TestToolDiscoveryConfig_Validate_Embeddings(t *testing.T) {
+	cfg := testConfig(config.StrategyEmbeddings, 1, nil)
+	assert.NoError(t, cfg.Pipes.ToolDiscovery.Validate(), "local embeddings need no settings")
+
+	cfg.Pipes.ToolDiscovery.Embeddings.Endpoint = "localhost:11434/v1/embeddings"
+	cfg.Pipes.ToolDiscovery.Embeddings.Model = "nomic-embed-text"
+	assert.Error(t, cfg.Pipes.ToolDiscovery.Validate())
+
+	cfg.Pipes.ToolDiscovery.Embeddings.Endpoint = "http://localhost:11434/v1/embeddings"
+	assert.NoError(t, cfg.Pipes.ToolDiscovery.Validate())
+
+	cfg.Pipes.ToolDiscovery.Embeddings.Model = ""
+	assert.Error(t, cfg.Pipes.ToolDiscovery.Validate(), "an endpoint needs a model")
+}
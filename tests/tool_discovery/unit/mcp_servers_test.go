@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+)
+
+func TestMCPServer(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+	}{
+		{"mcp__github__create_issue", "github"},
+		{"mcp__google_drive__list_files", "google_drive"},
+		{"mcp__github__repos__get", "github"},
+		{"read_file", ""},
+		{"mcp__github", ""},
+		{"mcp____create_issue", ""},
+		{"mcp__github__", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.server, tooldiscovery.MCPServer(tt.name), tt.name)
+	}
+}
+
+// mcpToolsRequest is an OpenAI request whose tools come from three MCP
+// servers plus one built-in tool.
+func mcpToolsRequest(messages string) []byte {
+	return []byte(`{
+		"model": "gpt-4o",
+		"messages": ` + messages + `,
+		"tools": [
+			{"type": "function", "function": {"name": "mcp__jira__create_issue", "description": "Create an issue in a Jira project"}},
+			{"type": "function", "function": {"name": "mcp__github__create_issue", "description": "Create an issue in a repository"}},
+			{"type": "function", "function": {"name": "mcp__github__list_pulls", "description": "List pull requests of a repository"}},
+			{"type": "function", "function": {"name": "mcp__github__get_file", "description": "Get file contents from a repository"}},
+			{"type": "function", "function": {"name": "mcp__slack__post_message", "description": "Post a message to a channel"}},
+			{"type": "function", "function": {"name": "read_file", "description": "Read a local file"}}
+		]
+	}`)
+}
+
+// mcpTestToolTokens is roughly the token count of one mcpToolsRequest tool.
+const mcpTestToolTokens = 115
+
+func processMCPTools(t *testing.T, cfg *config.Config, body []byte, query string) (*pipes.PipeContext, []string) {
+	t.Helper()
+	pipe := tooldiscovery.New(cfg)
+	ctx := newOpenAIPipeContext(body)
+	ctx.UserQuery = query
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(result, &req))
+	return ctx, effectiveToolNames(req["tools"].([]any))
+}
+
+func TestPipe_Process_MCPServerNameScoresWholeServer(t *testing.T) {
+	query := "open a github issue for the crash"
+	body := mcpToolsRequest(`[{"role": "user", "content": "` + query + `"}]`)
+
+	_, kept := processMCPTools(t, testConfig(config.StrategyRelevance, 0, nil, 3*mcpTestToolTokens), body, query)
+
+	require.GreaterOrEqual(t, len(kept), 2)
+	assert.Contains(t, kept, "mcp__github__create_issue")
+	assert.NotContains(t, kept, "mcp__jira__create_issue", "matching tool name alone loses to the named server")
+	for _, name := range kept {
+		assert.Equal(t, "github", tooldiscovery.MCPServer(name), name)
+	}
+}
+
+func TestPipe_Process_MCPServerRecentlyUsedSibling(t *testing.T) {
+	body := mcpToolsRequest(`[
+		{"role": "user", "content": "check the readme"},
+		{"role": "assistant", "content": null, "tool_calls": [
+			{"id": "call_1", "type": "function", "function": {"name": "mcp__github__get_file", "arguments": "{}"}}
+		]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "# README"},
+		{"role": "user", "content": "what next"}
+	]`)
+
+	_, kept := processMCPTools(t, testConfig(config.StrategyRelevance, 0, nil, 3*mcpTestToolTokens), body, "what next")
+
+	require.GreaterOrEqual(t, len(kept), 2)
+	for _, name := range kept {
+		assert.Equal(t, "github", tooldiscovery.MCPServer(name), name)
+	}
+}
+
+func TestPipe_Process_KeepAndDropServers(t *testing.T) {
+	query := "open a github issue for the crash"
+	body := mcpToolsRequest(`[{"role": "user", "content": "` + query + `"}]`)
+	cfg := testConfig(config.StrategyRelevance, 3, nil)
+	cfg.Pipes.ToolDiscovery.KeepServers = []string{"slack"}
+	cfg.Pipes.ToolDiscovery.DropServers = []string{"github"}
+
+	ctx, kept := processMCPTools(t, cfg, body, query)
+
+	assert.Contains(t, kept, "mcp__slack__post_message")
+	for _, name := range kept {
+		assert.False(t, strings.HasPrefix(name, "mcp__github__"), "dropped server tool %s was kept", name)
+	}
+	var deferred []string
+	for _, d := range ctx.DeferredTools {
+		deferred = append(deferred, d.ToolName)
+	}
+	assert.Subset(t, deferred, []string{"mcp__github__create_issue", "mcp__github__list_pulls", "mcp__github__get_file"})
+
+	stats := ctx.ToolServerStats
+	require.Contains(t, stats, "github")
+	assert.Equal(t, 3, stats["github"].Tools)
+	assert.Equal(t, 0, stats["github"].Kept)
+	assert.Equal(t, 0, stats["github"].KeptTokens)
+	assert.Greater(t, stats["github"].OriginalTokens, 0)
+	assert.Equal(t, 1, stats["slack"].Kept)
+	assert.Equal(t, stats["slack"].OriginalTokens, stats["slack"].KeptTokens)
+	assert.NotContains(t, stats, "", "built-in tools are not counted")
+}
+
+func TestPipe_Process_DropServersKeepsAlwaysKeep(t *testing.T) {
+	query := "open a github issue"
+	body := mcpToolsRequest(`[{"role": "user", "content": "` + query + `"}]`)
+	cfg := testConfig(config.StrategyRelevance, 3, []string{"mcp__github__create_issue"})
+	cfg.Pipes.ToolDiscovery.DropServers = []string{"github"}
+
+	ctx, kept := processMCPTools(t, cfg, body, query)
+
+	assert.Contains(t, kept, "mcp__github__create_issue")
+	assert.NotContains(t, kept, "mcp__github__list_pulls")
+	assert.Equal(t, 1, ctx.ToolServerStats["github"].Kept)
+}
+
+func TestPipe_Process_ToolSearchReportsServerStats(t *testing.T) {
+	body := mcpToolsRequest(`[{"role": "user", "content": "hi"}]`)
+	pipe := tooldiscovery.New(testConfig(config.StrategyToolSearch, 0, nil))
+	ctx := newOpenAIPipeContext(body)
+	ctx.SessionID = "mcp-stats"
+
+	_, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, ctx.ToolServerStats["github"].Tools)
+	assert.Equal(t, 0, ctx.ToolServerStats["github"].Kept)
+
+	// Cache hits report the same stats.
+	ctx2 := newOpenAIPipeContext(body)
+	ctx2.SessionID = "mcp-stats"
+	_, err = pipe.Process(ctx2)
+	require.NoError(t, err)
+	require.True(t, ctx2.CacheHit)
+	assert.Equal(t, ctx.ToolServerStats, ctx2.ToolServerStats)
+}
+
+func TestToolDiscoveryConfig_Validate_ServerInKeepAndDrop(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Pipes.ToolDiscovery.Enabled = true
+	cfg.Pipes.ToolDiscovery.Strategy = config.StrategyRelevance
+	cfg.Pipes.ToolDiscovery.KeepServers = []string{"github"}
+	cfg.Pipes.ToolDiscovery.DropServers = []string{"github"}
+	err := cfg.Pipes.ToolDiscovery.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "github")
+}
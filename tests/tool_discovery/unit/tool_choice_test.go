@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+)
+
+// =============================================================================
+// TOOL_CHOICE PRESERVATION
+// =============================================================================
+
+// withToolChoice adds fields (tool_choice etc.) to a generated request.
+func withToolChoice(t *testing.T, body []byte, fields map[string]any) []byte {
+	t.Helper()
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(body, &req))
+	for k, v := range fields {
+		req[k] = v
+	}
+	out, err := json.Marshal(req)
+	require.NoError(t, err)
+	return out
+}
+
+func TestPipe_ToolChoice_Anthropic_ForcedToolSurvivesRelevance(t *testing.T) {
+	// Budget for 1 tool; query matches search_code, but tool_choice forces deploy_app.
+	pipe := tooldiscovery.New(testConfig(config.StrategyRelevance, 1, nil))
+	body := withToolChoice(t, anthropicRequestWithToolsAndQuery(10, "search for code"), map[string]any{
+		"tool_choice": map[string]any{"type": "tool", "name": "deploy_app", "disable_parallel_tool_use": true},
+	})
+	ctx := newAnthropicPipeContext(body)
+
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(result, &req))
+	assert.Contains(t, effectiveToolNames(req["tools"].([]any)), "deploy_app")
+	for _, d := range ctx.DeferredTools {
+		assert.NotEqual(t, "deploy_app", d.ToolName, "tool_choice target must not be deferred")
+	}
+
+	// tool_choice, including disable_parallel_tool_use, is forwarded unchanged.
+	assert.Equal(t, "tool", gjson.GetBytes(result, "tool_choice.type").String())
+	assert.Equal(t, "deploy_app", gjson.GetBytes(result, "tool_choice.name").String())
+	assert.True(t, gjson.GetBytes(result, "tool_choice.disable_parallel_tool_use").Bool())
+}
+
+func TestPipe_ToolChoice_Anthropic_AnyWithParallelDisabled(t *testing.T) {
+	// {"type":"any"} names no tool: filtering proceeds normally and the flag is preserved.
+	pipe := tooldiscovery.New(testConfig(config.StrategyRelevance, 1, nil))
+	body := withToolChoice(t, anthropicRequestWithToolsAndQuery(10, "search for code"), map[string]any{
+		"tool_choice": map[string]any{"type": "any", "disable_parallel_tool_use": true},
+	})
+	ctx := newAnthropicPipeContext(body)
+
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+	assert.NotEmpty(t, ctx.DeferredTools)
+	assert.Equal(t, "any", gjson.GetBytes(result, "tool_choice.type").String())
+	assert.True(t, gjson.GetBytes(result, "tool_choice.disable_parallel_tool_use").Bool())
+}
+
+func TestPipe_ToolChoice_OpenAI_ForcedFunctionSurvivesRelevance(t *testing.T) {
+	pipe := tooldiscovery.New(testConfig(config.StrategyRelevance, 1, nil))
+	body := withToolChoice(t, openAIRequestWithToolsAndQuery(10, "search for code"), map[string]any{
+		"tool_choice":         map[string]any{"type": "function", "function": map[string]any{"name": "git_commit"}},
+		"parallel_tool_calls": false,
+	})
+	ctx := newOpenAIPipeContext(body)
+
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.True(t, ctx.ToolsFiltered)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(result, &req))
+	assert.Contains(t, effectiveToolNames(req["tools"].([]any)), "git_commit")
+	assert.Equal(t, "git_commit", gjson.GetBytes(result, "tool_choice.function.name").String())
+	assert.False(t, gjson.GetBytes(result, "parallel_tool_calls").Bool())
+	assert.True(t, gjson.GetBytes(result, "parallel_tool_calls").Exists())
+}
+
+func TestPipe_ToolChoice_ToolSearch_KeepsForcedToolOutOfStubs(t *testing.T) {
+	pipe := tooldiscovery.New(testConfig(config.StrategyToolSearch, 10, nil))
+	body := withToolChoice(t, anthropicRequestWithToolsAndQuery(6, "search for code"), map[string]any{
+		"tool_choice": map[string]any{"type": "tool", "name": "list_dir"},
+	})
+	ctx := newAnthropicPipeContext(body)
+	ctx.SessionID = "sess-tool-choice"
+
+	result, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.Len(t, ctx.DeferredTools, 5)
+	assert.Equal(t, 1, ctx.KeptToolCount)
+
+	var req map[string]any
+	require.NoError(t, json.Unmarshal(result, &req))
+	assert.Equal(t, []string{"list_dir"}, effectiveToolNames(req["tools"].([]any)))
+
+	// Same tools, no tool_choice: the cached result must not be reused.
+	ctx2 := newAnthropicPipeContext(anthropicRequestWithToolsAndQuery(6, "search for code"))
+	ctx2.SessionID = "sess-tool-choice"
+	_, err = pipe.Process(ctx2)
+	require.NoError(t, err)
+	assert.False(t, ctx2.CacheHit)
+	assert.Len(t, ctx2.DeferredTools, 6)
+}
+
+func TestPipe_ToolChoice_NamesNoTool(t *testing.T) {
+	// "auto", "none" and "required" leave filtering unchanged.
+	for _, choice := range []any{"auto", "none", "required", map[string]any{"type": "auto"}} {
+		pipe := tooldiscovery.New(testConfig(config.StrategyToolSearch, 10, nil))
+		body := withToolChoice(t, openAIRequestWithToolsAndQuery(6, "search for code"), map[string]any{"tool_choice": choice})
+		ctx := newOpenAIPipeContext(body)
+
+		_, err := pipe.Process(ctx)
+		require.NoError(t, err)
+		assert.Len(t, ctx.DeferredTools, 6, "tool_choice %v", choice)
+	}
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/config"
+	tooldiscovery "github.com/compresr/context-gateway/internal/pipes/tool_discovery"
+)
+
+// =============================================================================
+// TOOL-SEARCH SESSION CACHE
+// =============================================================================
+
+func TestPipe_ToolSearch_CacheHitKeepsCurrentMessages(t *testing.T) {
+	pipe := tooldiscovery.New(testConfig(config.StrategyToolSearch, 10, nil))
+
+	ctx := newAnthropicPipeContext(anthropicRequestWithToolsAndQuery(6, "first turn"))
+	ctx.SessionID = "sess-cache"
+	_, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	require.False(t, ctx.CacheHit)
+
+	// Same tools, later turn: the stubbing is reused, the messages are this request's.
+	ctx2 := newAnthropicPipeContext(anthropicRequestWithToolsAndQuery(6, "second turn"))
+	ctx2.SessionID = "sess-cache"
+	result, err := pipe.Process(ctx2)
+	require.NoError(t, err)
+	assert.True(t, ctx2.CacheHit)
+	assert.Len(t, ctx2.DeferredTools, 6)
+	assert.Equal(t, "second turn", gjson.GetBytes(result, "messages.0.content").String())
+	assert.Equal(t, 6, int(gjson.GetBytes(result, "tools.#").Int()))
+}
+
+func TestPipe_ToolSearch_CacheBounded(t *testing.T) {
+	cache := tooldiscovery.NewSessionCache()
+	pipe := tooldiscovery.NewWithCache(testConfig(config.StrategyToolSearch, 10, nil), cache)
+	body := anthropicRequestWithToolsAndQuery(6, "search for code")
+
+	// Every conversation branch is a session; the cache must not grow with them.
+	for i := 0; i < tooldiscovery.MaxCachedSessions+20; i++ {
+		ctx := newAnthropicPipeContext(body)
+		ctx.SessionID = fmt.Sprintf("sess-%d", i)
+		_, err := pipe.Process(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, tooldiscovery.MaxCachedSessions, cache.Len())
+
+	// The most recent session is still cached.
+	ctx := newAnthropicPipeContext(body)
+	ctx.SessionID = fmt.Sprintf("sess-%d", tooldiscovery.MaxCachedSessions+19)
+	_, err := pipe.Process(ctx)
+	require.NoError(t, err)
+	assert.True(t, ctx.CacheHit)
+}
+
+func TestPipe_ToolSearch_CacheSharedAcrossInstances(t *testing.T) {
+	cfg := testConfig(config.StrategyToolSearch, 10, nil)
+	cache := tooldiscovery.NewSessionCache()
+	body := anthropicRequestWithToolsAndQuery(6, "search for code")
+
+	ctx := newAnthropicPipeContext(body)
+	ctx.SessionID = "sess-shared"
+	_, err := tooldiscovery.NewWithCache(cfg, cache).Process(ctx)
+	require.NoError(t, err)
+
+	// A pooled sibling serves the same session from the shared cache.
+	ctx2 := newAnthropicPipeContext(body)
+	ctx2.SessionID = "sess-shared"
+	_, err = tooldiscovery.NewWithCache(cfg, cache).Process(ctx2)
+	require.NoError(t, err)
+	assert.True(t, ctx2.CacheHit)
+	assert.Equal(t, 1, cache.Len())
+}
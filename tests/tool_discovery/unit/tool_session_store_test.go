@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/gateway"
+	"github.com/compresr/context-gateway/internal/pipes"
+)
+
+func TestToolSessionStore_GetReturnsSnapshot(t *testing.T) {
+	store := gateway.NewToolSessionStore(0)
+	defer store.Stop()
+
+	store.StoreDeferred("s1", []adapters.ExtractedContent{{ToolName: "read_file"}})
+	store.MarkExpanded("s1", []string{"read_file"})
+
+	snap := store.Get("s1")
+	require.NotNil(t, snap)
+	snap.ExpandedTools["injected"] = true
+	snap.DeferredTools[0].ToolName = "mutated"
+
+	assert.Equal(t, map[string]bool{"read_file": true}, store.GetExpanded("s1"))
+	assert.Equal(t, "read_file", store.GetDeferred("s1")[0].ToolName)
+	assert.Nil(t, store.Get("missing"))
+}
+
+// TestToolSessionStore_ConcurrentSessionsNoBleed simulates many agent sessions
+// running tool discovery in parallel and checks each session only ever sees
+// its own expanded tools, rewrites, and counters. Run with -race.
+func TestToolSessionStore_ConcurrentSessionsNoBleed(t *testing.T) {
+	store := gateway.NewToolSessionStore(0)
+	defer store.Stop()
+
+	const sessions, rounds = 24, 50
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		tool := fmt.Sprintf("tool-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.StoreIsMainAgent(id, i%2 == 0)
+			for n := 0; n < rounds; n++ {
+				store.StoreDeferred(id, []adapters.ExtractedContent{{ToolName: tool}})
+				store.MarkExpanded(id, []string{tool})
+				store.IncrementSearchCount(id)
+				store.AddDiscoveredToolNames(id, []string{tool})
+				store.RecordCallRewrite(id, &gateway.ToolCallMapping{
+					ClientToolUseID: fmt.Sprintf("%s-call-%d", id, n),
+					ClientToolName:  tool,
+				})
+
+				for name := range store.GetExpanded(id) {
+					assert.Equal(t, tool, name, "session %s saw another session's tool", id)
+				}
+				for _, m := range store.GetAllRewriteMappings(id) {
+					assert.Equal(t, tool, m.ClientToolName)
+				}
+				if snap := store.Get(id); snap != nil {
+					for _, name := range snap.DiscoveredToolNames {
+						assert.Equal(t, tool, name)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < sessions; i++ {
+		id := fmt.Sprintf("session-%d", i)
+		snap := store.Get(id)
+		require.NotNil(t, snap)
+		assert.Equal(t, rounds, snap.SearchCallCount)
+		assert.Len(t, snap.DiscoveredToolNames, rounds)
+		assert.Len(t, store.GetAllRewriteMappings(id), rounds)
+
+		isMain, ok := store.GetIsMainAgent(id)
+		require.True(t, ok)
+		assert.Equal(t, i%2 == 0, isMain)
+	}
+}
+
+func TestToolSessionStore_ForkKeepsOnlySharedExpansions(t *testing.T) {
+	store := gateway.NewToolSessionStore(0)
+	defer store.Stop()
+
+	store.SetMessageCount("root", 3)
+	store.MarkExpanded("root", []string{"read_file"})
+	store.SetMessageCount("root", 7)
+	store.MarkExpanded("root", []string{"web_fetch"})
+	store.RecordCallRewrite("root", &gateway.ToolCallMapping{ClientToolUseID: "toolu_1", ClientToolName: "read_file"})
+
+	// The client edits message 5: web_fetch was expanded after it, read_file before.
+	store.Fork("root", "root-b1", 5)
+
+	assert.Equal(t, map[string]bool{"read_file": true}, store.GetExpanded("root-b1"))
+	assert.NotNil(t, store.GetRewriteMapping("root-b1", "toolu_1"))
+	assert.Equal(t, map[string]bool{"read_file": true, "web_fetch": true}, store.GetExpanded("root"))
+
+	// Expansions on the branch don't reach the parent.
+	store.MarkExpanded("root-b1", []string{"grep"})
+	assert.False(t, store.GetExpanded("root")["grep"])
+}
+
+func TestToolSessionStore_ServerStats(t *testing.T) {
+	store := gateway.NewToolSessionStore(0)
+	defer store.Stop()
+
+	stats := map[string]pipes.ToolServerStats{"github": {Tools: 3, Kept: 1, OriginalTokens: 300, KeptTokens: 100}}
+	store.StoreServerStats("root", stats)
+
+	snapshot := store.Get("root")
+	require.NotNil(t, snapshot)
+	assert.Equal(t, stats, snapshot.ServerStats)
+	snapshot.ServerStats["github"] = pipes.ToolServerStats{}
+	assert.Equal(t, 3, store.Get("root").ServerStats["github"].Tools, "snapshot is a copy")
+
+	store.Fork("root", "root-b1", 0)
+	assert.Equal(t, stats, store.Get("root-b1").ServerStats)
+}
@@ -0,0 +1,235 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/formats"
+	"github.com/compresr/context-gateway/internal/pipes"
+	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// =============================================================================
+// BLOB DETECTION
+// =============================================================================
+
+func TestFindBlobs_DataURI(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString(testPNG(t, 40, 20))
+	text := `<img src="data:image/png;base64,` + payload + `"> done`
+
+	blobs := formats.FindBlobs(text)
+	require.Len(t, blobs, 1)
+	assert.Equal(t, "image/png", blobs[0].MediaType)
+	assert.Equal(t, strings.Index(text, "data:"), blobs[0].Start)
+	assert.Equal(t, `"> done`, text[blobs[0].End:])
+}
+
+func TestFindBlobs_BareBase64(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString(testPNG(t, 200, 100))
+	require.Greater(t, len(payload), formats.MinBlobLen)
+	text := "Screenshot captured:\n" + payload + "\nEnd of output."
+
+	blobs := formats.FindBlobs(text)
+	require.Len(t, blobs, 1)
+	assert.Equal(t, "image/png", blobs[0].MediaType)
+	assert.Equal(t, payload, text[blobs[0].Start:blobs[0].End])
+}
+
+func TestFindBlobs_WrappedBase64(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString(testPNG(t, 200, 100))
+	var wrapped strings.Builder
+	for i := 0; i < len(payload); i += 76 {
+		wrapped.WriteString(payload[i:min(i+76, len(payload))])
+		wrapped.WriteString("\r\n")
+	}
+	text := "-----BEGIN IMAGE-----\r\n" + wrapped.String() + "Next line of output"
+
+	blobs := formats.FindBlobs(text)
+	require.Len(t, blobs, 1)
+	assert.Equal(t, "image/png", blobs[0].MediaType)
+	assert.Equal(t, strings.TrimSuffix(wrapped.String(), "\r\n"), text[blobs[0].Start:blobs[0].End])
+}
+
+func TestFindBlobs_IgnoresText(t *testing.T) {
+	cases := map[string]string{
+		"sha256":       "commit e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 merged",
+		"long words":   strings.Repeat("lorem ipsum dolor sit amet ", 200),
+		"long digits":  strings.Repeat("0123456789", 200),
+		"wrapped code": strings.Repeat("func handler(w http.ResponseWriter) {}\n", 60),
+	}
+	for name, text := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Empty(t, formats.FindBlobs(text))
+		})
+	}
+}
+
+func TestFindBlobs_RawBinary(t *testing.T) {
+	text := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR" + strings.Repeat("\x00\x01\x02", 100)
+
+	blobs := formats.FindBlobs(text)
+	require.Len(t, blobs, 1)
+	assert.Equal(t, 0, blobs[0].Start)
+	assert.Equal(t, len(text), blobs[0].End)
+	assert.Equal(t, "image/png", blobs[0].MediaType)
+}
+
+// =============================================================================
+// PIPE ROUTING
+// =============================================================================
+
+func TestToolOutput_RoutesBase64ToShadowRef(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+	pipe := newBinaryTestPipe(st, 0)
+
+	payload := base64.StdEncoding.EncodeToString(testPNG(t, 200, 100))
+	body := anthropicToolResult(t, "take_screenshot", "Screenshot of localhost:3000:\n"+payload)
+
+	ctx := pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body)
+	out, err := pipe.Process(ctx)
+	require.NoError(t, err)
+
+	content := gjson.GetBytes(out, "messages.2.content.0.content").String()
+	assert.NotContains(t, content, payload, "base64 must not be forwarded")
+	assert.Contains(t, content, "Screenshot of localhost:3000:")
+	assert.Contains(t, content, "[binary omitted: image/png,")
+
+	require.Len(t, ctx.ShadowRefs, 1)
+	for id, original := range ctx.ShadowRefs {
+		assert.Contains(t, content, `expand_context(id="`+id+`")`)
+		assert.Equal(t, payload, original)
+		stored, ok := st.Get(id)
+		require.True(t, ok)
+		assert.Equal(t, payload, stored)
+	}
+	assert.Equal(t, int64(1), pipe.GetMetrics().BlobsRouted)
+
+	// Same input, same output: the placeholder must be KV-cache stable.
+	again, err := newBinaryTestPipe(st, 0).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+}
+
+func TestToolOutput_NoBlobsLeavesBodyUnchanged(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	body := anthropicToolResult(t, "read_file", "package main\n\nfunc main() {}\n")
+	out, err := newBinaryTestPipe(st, 0).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(body), string(out))
+}
+
+// =============================================================================
+// THUMBNAILS
+// =============================================================================
+
+func TestToolOutput_ThumbnailsToolResultImages(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	original := testPNG(t, 800, 400)
+	body := []byte(`{"model":"claude-sonnet-4-5","messages":[` +
+		`{"role":"user","content":"Check the page"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"screenshot","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[` +
+		`{"type":"text","text":"captured"},` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + base64.StdEncoding.EncodeToString(original) + `"}}]}]}]}`)
+
+	out, err := newBinaryTestPipe(st, 100).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+
+	assert.Equal(t, "captured", gjson.GetBytes(out, "messages.2.content.0.content.0.text").String())
+	data, err := base64.StdEncoding.DecodeString(gjson.GetBytes(out, "messages.2.content.0.content.1.source.data").String())
+	require.NoError(t, err)
+	thumb, err := png.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 100, thumb.Width)
+	assert.Equal(t, 50, thumb.Height)
+	assert.Less(t, len(data), len(original))
+
+	// Cached thumbnail gives byte-identical output on the next turn.
+	again, err := newBinaryTestPipe(st, 100).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+}
+
+func TestToolOutput_ThumbnailKeepsSmallImages(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	small := base64.StdEncoding.EncodeToString(testPNG(t, 64, 32))
+	body := []byte(`{"model":"gpt-5","input":[` +
+		`{"type":"function_call","call_id":"call_1","name":"screenshot","arguments":"{}"},` +
+		`{"type":"function_call_output","call_id":"call_1","output":[{"type":"input_image","image_url":"data:image/png;base64,` + small + `"}]}]}`)
+
+	out, err := newBinaryTestPipe(st, 100).Process(pipes.NewPipeContext(adapters.NewOpenAIAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(body), string(out))
+}
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+func newBinaryTestPipe(st store.Store, imageMaxDimension int) *tooloutput.Pipe {
+	return tooloutput.New(&config.Config{Pipes: config.PipesConfig{
+		ToolOutput: config.ToolOutputPipeConfig{
+			Enabled:             true,
+			Strategy:            config.StrategySimple,
+			MinTokens:           100000, // Never compress text; only routing and thumbnails apply
+			MaxTokens:           200000,
+			EnableExpandContext: true,
+			BypassCostCheck:     true,
+			ImageMaxDimension:   imageMaxDimension,
+		},
+	}}, st)
+}
+
+func anthropicToolResult(t *testing.T, toolName, content string) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"model": "claude-sonnet-4-5",
+		"messages": []any{
+			map[string]any{"role": "user", "content": "Run the tool"},
+			map[string]any{"role": "assistant", "content": []any{
+				map[string]any{"type": "tool_use", "id": "toolu_1", "name": toolName, "input": map[string]any{}},
+			}},
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": content},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	return body
+}
+
+// testPNG renders a w×h gradient with fine detail, so the PNG is large
+// enough to be a blob and shrinks when downscaled.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x * y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// =============================================================================
+// DEDUPLICATION
+// =============================================================================
+
+func TestToolOutput_Dedup_StubsEarlierCopies(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+	pipe := newDedupTestPipe(st, true, 0)
+
+	file := strings.Repeat("func main() { fmt.Println(\"hello\") }\n", 20)
+	body := anthropicToolResults(t, file, "ok: 3 tests passed", file, file)
+
+	ctx := pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body)
+	out, err := pipe.Process(ctx)
+	require.NoError(t, err)
+
+	first := gjson.GetBytes(out, "messages.2.content.0.content").String()
+	second := gjson.GetBytes(out, "messages.6.content.0.content").String()
+	assert.True(t, strings.HasPrefix(first, "[identical to later read_file result toolu_4"), first)
+	assert.True(t, strings.HasPrefix(second, "[identical to later read_file result toolu_4"), second)
+	assert.Equal(t, "ok: 3 tests passed", gjson.GetBytes(out, "messages.4.content.0.content").String())
+	assert.Equal(t, file, gjson.GetBytes(out, "messages.8.content.0.content").String(), "latest copy is kept in full")
+
+	require.Len(t, ctx.ShadowRefs, 1)
+	for id, original := range ctx.ShadowRefs {
+		assert.Contains(t, first, `expand_context(id="`+id+`")`)
+		assert.Equal(t, file, original)
+		stored, ok := st.Get(id)
+		require.True(t, ok)
+		assert.Equal(t, file, stored)
+	}
+	assert.Equal(t, int64(2), pipe.GetMetrics().Deduplicated)
+	assert.True(t, ctx.OutputCompressed)
+
+	var statuses []string
+	for _, c := range ctx.ToolOutputCompressions {
+		if c.MappingStatus == "deduplicated" {
+			statuses = append(statuses, c.ToolCallID)
+		}
+	}
+	assert.Equal(t, []string{"toolu_1", "toolu_3"}, statuses)
+
+	// Same input, same output: stubs are KV-cache stable.
+	again, err := newDedupTestPipe(st, true, 0).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+}
+
+func TestToolOutput_Dedup_WithoutExpandContext(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	file := strings.Repeat("line of a repeated file\n", 20)
+	body := anthropicToolResults(t, file, file)
+
+	ctx := pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body)
+	out, err := newDedupTestPipe(st, false, 0).Process(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[identical to later read_file result toolu_2]", gjson.GetBytes(out, "messages.2.content.0.content").String())
+	assert.Equal(t, file, gjson.GetBytes(out, "messages.4.content.0.content").String())
+	assert.Empty(t, ctx.ShadowRefs)
+}
+
+func TestToolOutput_Dedup_KeepsShortOutputs(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	body := anthropicToolResults(t, "ok", "ok")
+	out, err := newDedupTestPipe(st, true, 0).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(body), string(out))
+
+	// A lower dedup_min_bytes dedups them too.
+	out, err = newDedupTestPipe(st, true, 1).Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Contains(t, gjson.GetBytes(out, "messages.2.content.0.content").String(), "[identical to later")
+}
+
+func TestToolOutput_Dedup_DisabledByDefault(t *testing.T) {
+	st := store.NewMemoryStore(time.Hour)
+	defer st.Close()
+
+	file := strings.Repeat("line of a repeated file\n", 20)
+	body := anthropicToolResults(t, file, file)
+	out, err := newDedupTestPipe(st, false, 0, func(c *config.ToolOutputPipeConfig) { c.Dedup = false }).
+		Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), body))
+	require.NoError(t, err)
+	assert.Equal(t, string(body), string(out))
+}
+
+func TestToolOutputConfig_DedupMinBytesValidation(t *testing.T) {
+	cfg := pipes.ToolOutputConfig{Enabled: true, Strategy: pipes.StrategySimple, DedupMinBytes: -1}
+	assert.ErrorContains(t, cfg.Validate(), "dedup_min_bytes")
+}
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+func newDedupTestPipe(st store.Store, expandContext bool, minBytes int, opts ...func(*config.ToolOutputPipeConfig)) *tooloutput.Pipe {
+	c := config.ToolOutputPipeConfig{
+		Enabled:             true,
+		Strategy:            config.StrategySimple,
+		MinTokens:           100000, // Never compress text; only dedup applies
+		MaxTokens:           200000,
+		EnableExpandContext: expandContext,
+		BypassCostCheck:     true,
+		Dedup:               true,
+		DedupMinBytes:       minBytes,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return tooloutput.New(&config.Config{Pipes: config.PipesConfig{ToolOutput: c}}, st)
+}
+
+// anthropicToolResults builds a conversation with one read_file call per
+// output, in order, with IDs toolu_1, toolu_2, ...
+func anthropicToolResults(t *testing.T, outputs ...string) []byte {
+	t.Helper()
+	messages := []any{map[string]any{"role": "user", "content": "Look at the code"}}
+	for i, output := range outputs {
+		id := fmt.Sprintf("toolu_%d", i+1)
+		messages = append(messages,
+			map[string]any{"role": "assistant", "content": []any{
+				map[string]any{"type": "tool_use", "id": id, "name": "read_file", "input": map[string]any{"path": "main.go"}},
+			}},
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "tool_result", "tool_use_id": id, "content": output},
+			}},
+		)
+	}
+	body, err := json.Marshal(map[string]any{"model": "claude-sonnet-4-5", "messages": messages})
+	require.NoError(t, err)
+	return body
+}
@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/compresr/context-gateway/internal/adapters"
+	"github.com/compresr/context-gateway/internal/config"
+	"github.com/compresr/context-gateway/internal/pipes"
+	tooloutput "github.com/compresr/context-gateway/internal/pipes/tool_output"
+	"github.com/compresr/context-gateway/internal/store"
+)
+
+// =============================================================================
+// LOCAL STRATEGY
+// =============================================================================
+
+func TestLocalCompressor_JSONSummary(t *testing.T) {
+	items := make([]map[string]any, 200)
+	for i := range items {
+		items[i] = map[string]any{"id": i, "name": fmt.Sprintf("item-%d", i), "note": strings.Repeat("x", 300)}
+	}
+	raw, err := json.Marshal(map[string]any{"total": 200, "items": items})
+	require.NoError(t, err)
+
+	got := compressLocally(t, string(raw))
+	require.True(t, json.Valid([]byte(got)), "summary stays valid JSON: %s", got)
+	assert.Equal(t, int64(200), gjson.Get(got, "total").Int())
+	assert.Equal(t, "item-0", gjson.Get(got, "items.0.name").String())
+	assert.Equal(t, "… 197 more items", gjson.Get(got, "items.3").String())
+	assert.Contains(t, gjson.Get(got, "items.0.note").String(), "(300 chars)")
+}
+
+func TestLocalCompressor_DiffHunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n")
+	for h := 0; h < 40; h++ {
+		fmt.Fprintf(&b, "@@ -%d,12 +%d,12 @@ func f%d()\n", h*20, h*20, h)
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(&b, " \tunchanged line %d in hunk %d\n", i, h)
+		}
+		fmt.Fprintf(&b, "-\told := %d\n+\tnew := %d\n", h, h)
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(&b, " \ttrailing line %d in hunk %d\n", i, h)
+		}
+	}
+
+	got := compressLocally(t, b.String())
+	assert.True(t, strings.HasPrefix(got, "diff --git a/main.go b/main.go\n"), "file header kept")
+	assert.Contains(t, got, "@@ -0,12 +0,12 @@ func f0()\n \tunchanged line 0 in hunk 0\n … 3 unchanged lines\n \tunchanged line 4 in hunk 0\n-\told := 0\n+\tnew := 0\n")
+	assert.NotContains(t, got, "trailing line 1 in hunk 0", "context is reduced to one line")
+	assert.Contains(t, got, "hunks omitted")
+	assert.Contains(t, got, "@@ -780,12 +780,12 @@ func f39() (+1/-1)", "dropped hunks are listed with counts")
+}
+
+func TestLocalCompressor_LogDedupAndHeadTail(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("build started\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&b, "2026-01-02T10:00:%02d worker %d processed job %08x in %dms\n", i%60, i%8, i*7919, i%97)
+	}
+	for i := 0; i < 4000; i++ {
+		fmt.Fprintf(&b, "step %s: %s\n", strings.Repeat("a", i%13+1), strings.Repeat("log output ", i%5+3))
+	}
+	b.WriteString("build failed: exit status 2")
+
+	got := compressLocally(t, b.String())
+	assert.True(t, strings.HasPrefix(got, "build started\n2026-01-02T10:00:00 worker 0 processed job 00000000 in 0ms [×500 similar lines]\n"), got[:200])
+	assert.True(t, strings.HasSuffix(got, "build failed: exit status 2"), "tail is kept")
+	assert.Regexp(t, `\[… \d+ of \d+ lines omitted …\]`, got)
+}
+
+func TestLocalCompressor_ValidatesAsLocalStrategy(t *testing.T) {
+	cfg := config.ToolOutputPipeConfig{Enabled: true, Strategy: config.StrategyLocal}
+	assert.NoError(t, cfg.Validate())
+}
+
+// =============================================================================
+// HELPERS
+// =============================================================================
+
+// compressLocally runs content through the tool output pipe with the local
+// strategy and returns the tool result the model would see.
+func compressLocally(t *testing.T, content string) string {
+	t.Helper()
+	st := store.NewMemoryStore(time.Hour)
+	t.Cleanup(func() { st.Close() })
+
+	pipe := tooloutput.New(&config.Config{Pipes: config.PipesConfig{
+		ToolOutput: config.ToolOutputPipeConfig{
+			Enabled:         true,
+			Strategy:        config.StrategyLocal,
+			MinTokens:       10,
+			MaxTokens:       1000000,
+			BypassCostCheck: true,
+		},
+	}}, st)
+	out, err := pipe.Process(pipes.NewPipeContext(adapters.NewAnthropicAdapter(), anthropicToolResult(t, "run", content)))
+	require.NoError(t, err)
+	got := gjson.GetBytes(out, "messages.2.content.0.content").String()
+	require.NotEqual(t, content, got, "content was compressed")
+	assert.Less(t, len(got), len(content))
+	return got
+}
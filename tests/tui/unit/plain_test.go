@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/compresr/context-gateway/internal/tui"
+)
+
+// withIO feeds input to os.Stdin and returns everything written to os.Stdout.
+func withIO(t *testing.T, input string, fn func()) string {
+	t.Helper()
+	inR, inW, err := os.Pipe()
+	require.NoError(t, err)
+	outR, outW, err := os.Pipe()
+	require.NoError(t, err)
+
+	oldIn, oldOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = inR, outW
+	t.Cleanup(func() { os.Stdin, os.Stdout = oldIn, oldOut })
+
+	_, err = inW.WriteString(input)
+	require.NoError(t, err)
+	require.NoError(t, inW.Close())
+
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(outR)
+		done <- string(b)
+	}()
+	fn()
+	require.NoError(t, outW.Close())
+	os.Stdin, os.Stdout = oldIn, oldOut
+	return <-done
+}
+
+func plainMode(t *testing.T) {
+	t.Helper()
+	tui.SetPlain(true)
+	t.Cleanup(func() { tui.SetPlain(false) })
+}
+
+func TestDetectPlain(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	assert.True(t, tui.DetectPlain())
+	t.Setenv("TERM", "")
+	assert.True(t, tui.DetectPlain())
+	t.Setenv("TERM", "xterm-256color")
+	assert.False(t, tui.DetectPlain())
+}
+
+func TestSetPlain_ClearsAndRestoresColors(t *testing.T) {
+	tui.SetPlain(true)
+	assert.True(t, tui.IsPlain())
+	assert.Empty(t, tui.ColorGreen)
+	assert.Empty(t, tui.ColorReset)
+
+	tui.SetPlain(false)
+	assert.False(t, tui.IsPlain())
+	assert.Equal(t, "\033[0;32m", tui.ColorGreen)
+	assert.Equal(t, "\033[0m", tui.ColorReset)
+}
+
+func TestSelectMenu_PlainUsesNumberedPrompts(t *testing.T) {
+	plainMode(t)
+	items := []tui.MenuItem{
+		{Label: "Compact", Value: "compact"},
+		{Label: "Pro feature", Locked: true, LockedReason: "Requires Pro"},
+		{Label: "Save", Value: "save"},
+	}
+
+	var idx int
+	var err error
+	out := withIO(t, "9\n2\n3\n", func() { idx, err = tui.SelectMenu("Create Configuration", items) })
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, idx)
+	assert.NotContains(t, out, "\033", "plain mode must not emit ANSI control")
+	assert.Contains(t, out, "[1] Compact")
+	assert.Contains(t, out, "Invalid choice")
+	assert.Contains(t, out, "Pro feature is locked")
+}
+
+func TestSelectMenu_PlainEditsEditableItem(t *testing.T) {
+	plainMode(t)
+	items := []tui.MenuItem{
+		{Label: "Config Name", Description: "default", Value: "edit_name", Editable: true},
+		{Label: "Save", Value: "save"},
+	}
+
+	var idx int
+	out := withIO(t, "1\nmy_config\n2\n", func() { idx, _ = tui.SelectMenu("Create Configuration", items) })
+
+	assert.Equal(t, 1, idx)
+	assert.Equal(t, "my_config", items[0].Description)
+	assert.Equal(t, 2, strings.Count(out, "Create Configuration"), "menu is shown again after editing")
+}
+
+func TestSelectMenu_PlainCancelsOnEOF(t *testing.T) {
+	plainMode(t)
+	var err error
+	withIO(t, "", func() { _, err = tui.SelectMenu("Pick", []tui.MenuItem{{Label: "A"}}) })
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/compresr/context-gateway/internal/userdir"
+)
+
+func TestResolve(t *testing.T) {
+	home := filepath.Join("home", "ada")
+	appData := filepath.Join("Users", "ada", "AppData", "Roaming")
+	legacy := filepath.Join(home, ".config", userdir.AppName)
+	roaming := filepath.Join(appData, userdir.AppName)
+
+	existing := func(paths ...string) func(string) bool {
+		return func(p string) bool {
+			for _, e := range paths {
+				if p == e {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name    string
+		goos    string
+		appData string
+		exists  func(string) bool
+		want    string
+	}{
+		{"linux", "linux", "", existing(), legacy},
+		{"darwin ignores APPDATA", "darwin", appData, existing(), legacy},
+		{"windows fresh install", "windows", appData, existing(), roaming},
+		{"windows keeps legacy dir", "windows", appData, existing(legacy), legacy},
+		{"windows prefers APPDATA once it exists", "windows", appData, existing(legacy, roaming), roaming},
+		{"windows without APPDATA", "windows", "", existing(), legacy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, userdir.Resolve(tt.goos, home, tt.appData, tt.exists))
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir, err := userdir.Dir()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "configs", "fast_setup.yaml"), userdir.Path("configs", "fast_setup.yaml"))
+}
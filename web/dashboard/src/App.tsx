@@ -5,6 +5,8 @@ import SavingsTab from './components/SavingsTab'
 import PromptHistoryTab from './components/PromptHistoryTab'
 import MonitorTab from './components/MonitorTab'
 import SettingsTab from './components/SettingsTab'
+import CompressionDiffTab from './components/CompressionDiffTab'
+import SessionsTab from './components/SessionsTab'
 
 // Error boundary to catch render errors
 class ErrorBoundary extends Component<{ children: ReactNode }, { error: string | null }> {
@@ -30,12 +32,14 @@ class ErrorBoundary extends Component<{ children: ReactNode }, { error: string |
 function Dashboard() {
   const [data, setData] = useState<DashboardData | null>(null)
   const [error, setError] = useState<string | null>(null)
-  const [activeTab, setActiveTabState] = useState<'savings' | 'history' | 'monitor' | 'settings'>(() => {
+  const [activeTab, setActiveTabState] = useState<'savings' | 'history' | 'monitor' | 'diff' | 'sessions' | 'settings'>(() => {
     // Check URL hash for direct navigation (e.g., #/settings, #/monitor)
     if (window.location.hash === '#/settings') return 'settings'
     if (window.location.hash === '#/history') return 'history'
     if (window.location.hash === '#/savings') return 'savings'
     if (window.location.hash === '#/monitor') return 'monitor'
+    if (window.location.hash === '#/diff') return 'diff'
+    if (window.location.hash === '#/sessions') return 'sessions'
     return 'savings'
   })
   const [selectedSession, setSelectedSession] = useState('all')
@@ -187,6 +191,12 @@ function Dashboard() {
         {activeTab === 'monitor' && (
           <MonitorTab dashboardData={data} />
         )}
+        {activeTab === 'diff' && (
+          <CompressionDiffTab />
+        )}
+        {activeTab === 'sessions' && (
+          <SessionsTab />
+        )}
         {activeTab === 'settings' && (
           <SettingsTab />
         )}
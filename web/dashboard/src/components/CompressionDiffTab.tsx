@@ -0,0 +1,229 @@
+import { useState, useEffect } from 'react'
+import { ChevronDown, ChevronRight, GitCompare } from 'lucide-react'
+import type { MonitorData, ComparisonSample, ComparisonSampleSummary, CompressionComparison } from '../types'
+
+const mono = "'JetBrains Mono', monospace"
+const sans = "'Inter', system-ui, -apple-system, sans-serif"
+
+// Above this many line pairs the LCS table gets too large; show the panes unhighlighted.
+const MAX_DIFF_CELLS = 4_000_000
+
+function formatTokens(n: number): string {
+  if (n >= 1_000_000) return `${(n / 1_000_000).toFixed(1)}M`
+  if (n >= 1_000) return `${(n / 1_000).toFixed(1)}K`
+  return String(n)
+}
+
+function timeAgo(dateStr: string): string {
+  const then = new Date(dateStr).getTime()
+  if (isNaN(then)) return ''
+  const diffSec = Math.floor((Date.now() - then) / 1000)
+  if (diffSec < 60) return `${diffSec}s ago`
+  const diffMin = Math.floor(diffSec / 60)
+  if (diffMin < 60) return `${diffMin}m ago`
+  return `${Math.floor(diffMin / 60)}h ago`
+}
+
+type DiffLine = { text: string; changed: boolean }
+
+// Line-level LCS diff: marks original lines that were dropped and compressed lines that were introduced.
+function diffLines(original: string, compressed: string): { left: DiffLine[]; right: DiffLine[] } {
+  const a = original.split('\n')
+  const b = compressed.split('\n')
+  if (a.length * b.length > MAX_DIFF_CELLS) {
+    return {
+      left: a.map(text => ({ text, changed: false })),
+      right: b.map(text => ({ text, changed: false })),
+    }
+  }
+  // lcs[i][j] = LCS length of a[i:] and b[j:]
+  const lcs: Uint32Array[] = Array.from({ length: a.length + 1 }, () => new Uint32Array(b.length + 1))
+  for (let i = a.length - 1; i >= 0; i--) {
+    for (let j = b.length - 1; j >= 0; j--) {
+      lcs[i][j] = a[i] === b[j] ? lcs[i + 1][j + 1] + 1 : Math.max(lcs[i + 1][j], lcs[i][j + 1])
+    }
+  }
+  const left: DiffLine[] = a.map(text => ({ text, changed: true }))
+  const right: DiffLine[] = b.map(text => ({ text, changed: true }))
+  let i = 0
+  let j = 0
+  while (i < a.length && j < b.length) {
+    if (a[i] === b[j]) {
+      left[i].changed = false
+      right[j].changed = false
+      i++
+      j++
+    } else if (lcs[i + 1][j] >= lcs[i][j + 1]) {
+      i++
+    } else {
+      j++
+    }
+  }
+  return { left, right }
+}
+
+function DiffPane({ title, lines, tokens, color }: { title: string; lines: DiffLine[]; tokens?: number; color: string }) {
+  return (
+    <div style={{ flex: 1, minWidth: 0, display: 'flex', flexDirection: 'column' }}>
+      <div style={{ fontSize: 10, fontWeight: 600, color: '#6b7280', textTransform: 'uppercase', letterSpacing: '0.08em', marginBottom: 6, fontFamily: sans }}>
+        {title}{tokens !== undefined && ` · ${formatTokens(tokens)} tokens`}
+      </div>
+      <pre style={{
+        margin: 0, maxHeight: 420, overflow: 'auto', background: 'rgba(0,0,0,0.35)',
+        border: '1px solid rgba(255,255,255,0.05)', borderRadius: 8, padding: '8px 0',
+        fontFamily: mono, fontSize: 11, lineHeight: 1.55, color: '#d1d5db',
+      }}>
+        {lines.map((l, idx) => (
+          <div key={idx} style={{ padding: '0 10px', whiteSpace: 'pre-wrap', wordBreak: 'break-all', background: l.changed ? `${color}1a` : 'transparent', borderLeft: `2px solid ${l.changed ? color : 'transparent'}` }}>
+            {l.text || ' '}
+          </div>
+        ))}
+      </pre>
+    </div>
+  )
+}
+
+function ComparisonCard({ c }: { c: CompressionComparison }) {
+  const [open, setOpen] = useState(false)
+  const orig = c.original_tokens ?? 0
+  const comp = c.compressed_tokens ?? 0
+  const removedPct = orig > 0 ? Math.round((1 - comp / orig) * 100) : 0
+  const diff = open ? diffLines(c.original_content ?? '', c.compressed_content ?? '') : null
+
+  return (
+    <div style={{ background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 12, overflow: 'hidden' }}>
+      <button
+        onClick={() => setOpen(v => !v)}
+        style={{
+          width: '100%', display: 'flex', alignItems: 'center', gap: 10, padding: '12px 16px',
+          background: 'none', border: 'none', cursor: 'pointer', color: '#e5e7eb', fontFamily: sans, fontSize: 13,
+        }}
+      >
+        {open ? <ChevronDown size={14} style={{ color: '#6b7280' }} /> : <ChevronRight size={14} style={{ color: '#6b7280' }} />}
+        <span style={{ fontFamily: mono, fontWeight: 600 }}>{c.tool_name || 'tool output'}</span>
+        <span style={{
+          fontSize: 10, padding: '2px 8px', borderRadius: 10, fontFamily: mono,
+          color: c.cache_hit ? '#60a5fa' : '#22c55e',
+          background: c.cache_hit ? 'rgba(96,165,250,0.1)' : 'rgba(34,197,94,0.1)',
+        }}>
+          {c.status}
+        </span>
+        {c.shadow_id && <span style={{ fontSize: 11, color: '#4b5563', fontFamily: mono }}>{c.shadow_id}</span>}
+        <span style={{ marginLeft: 'auto', fontSize: 12, color: '#9ca3af', fontFamily: mono }}>
+          {formatTokens(orig)} → {formatTokens(comp)} <span style={{ color: '#a78bfa' }}>(−{removedPct}%)</span>
+        </span>
+      </button>
+      {open && diff && (
+        <div style={{ padding: '0 16px 16px', display: 'flex', flexDirection: 'column', gap: 10 }}>
+          {c.query && (
+            <div style={{ fontSize: 11, color: '#6b7280', fontFamily: sans }}>
+              Query: <span style={{ color: '#d1d5db', fontFamily: mono }}>{c.query}</span>
+            </div>
+          )}
+          <div style={{ display: 'flex', gap: 12 }}>
+            <DiffPane title="Original" lines={diff.left} tokens={c.original_tokens} color="#ef4444" />
+            <DiffPane title="Forwarded" lines={diff.right} tokens={c.compressed_tokens} color="#22c55e" />
+          </div>
+        </div>
+      )}
+    </div>
+  )
+}
+
+function CompressionDiffTab() {
+  const [ports, setPorts] = useState<number[]>([])
+  const [port, setPort] = useState<number | null>(null)
+  const [samples, setSamples] = useState<ComparisonSampleSummary[]>([])
+  const [selectedID, setSelectedID] = useState<string | null>(null)
+  const [sample, setSample] = useState<ComparisonSample | null>(null)
+  const [error, setError] = useState<string | null>(null)
+
+  // Discover gateway instances
+  useEffect(() => {
+    fetch('/api/monitor')
+      .then(res => res.json())
+      .then((data: MonitorData) => {
+        const found = (data.instances ?? []).map(i => i.port).sort((a, b) => a - b)
+        setPorts(found)
+        setPort(prev => prev ?? found[0] ?? null)
+      })
+      .catch(e => setError(String(e)))
+  }, [])
+
+  // Poll the sampled requests for the selected instance
+  useEffect(() => {
+    if (port === null) return
+    const fetchList = async () => {
+      try {
+        const res = await fetch(`/api/instance/compressions?port=${port}`)
+        if (!res.ok) { setError(`API returned ${res.status}`); return }
+        const data: { requests: ComparisonSampleSummary[] } = await res.json()
+        setSamples(data.requests ?? [])
+        setSelectedID(prev => prev ?? data.requests?.[0]?.request_id ?? null)
+        setError(null)
+      } catch (e) {
+        setError(String(e))
+      }
+    }
+    fetchList()
+    const interval = setInterval(fetchList, 5000)
+    return () => clearInterval(interval)
+  }, [port])
+
+  // Load the selected sample
+  useEffect(() => {
+    if (port === null || !selectedID) { setSample(null); return }
+    fetch(`/api/instance/compressions?port=${port}&request_id=${encodeURIComponent(selectedID)}`)
+      .then(res => (res.ok ? res.json() : null))
+      .then((data: ComparisonSample | null) => setSample(data))
+      .catch(e => setError(String(e)))
+  }, [port, selectedID])
+
+  const selectStyle = {
+    background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 8,
+    padding: '7px 10px', color: '#e5e7eb', fontSize: 12, fontFamily: mono, outline: 'none',
+  }
+
+  return (
+    <div style={{ display: 'flex', flexDirection: 'column', gap: 16 }}>
+      <div style={{ display: 'flex', alignItems: 'center', gap: 10 }}>
+        <GitCompare size={16} style={{ color: '#22c55e' }} />
+        <span style={{ fontSize: 13, color: '#e5e7eb', fontWeight: 500, fontFamily: sans }}>Original vs forwarded</span>
+        <span style={{ fontSize: 11, color: '#6b7280', fontFamily: sans }}>compressed tool outputs of recent requests</span>
+        <div style={{ flex: 1 }} />
+        {ports.length > 1 && (
+          <select value={port ?? ''} onChange={e => { setPort(Number(e.target.value)); setSelectedID(null); setSamples([]) }} style={selectStyle}>
+            {ports.map(p => <option key={p} value={p}>:{p}</option>)}
+          </select>
+        )}
+        {samples.length > 0 && (
+          <select value={selectedID ?? ''} onChange={e => setSelectedID(e.target.value)} style={{ ...selectStyle, maxWidth: 420 }}>
+            {samples.map(s => (
+              <option key={s.request_id} value={s.request_id}>
+                {timeAgo(s.timestamp)} · {s.outputs} output{s.outputs !== 1 ? 's' : ''} · {formatTokens(s.original_tokens)} → {formatTokens(s.compressed_tokens)} · {s.request_id}
+              </option>
+            ))}
+          </select>
+        )}
+      </div>
+
+      {error && (
+        <div style={{ fontSize: 12, color: '#eab308', padding: '8px 14px', background: 'rgba(234,179,8,0.06)', border: '1px solid rgba(234,179,8,0.2)', borderRadius: 10, fontFamily: sans }}>
+          {error}
+        </div>
+      )}
+
+      {sample && sample.comparisons.map((c, idx) => (
+        <ComparisonCard key={`${sample.request_id}-${c.shadow_id ?? idx}`} c={c} />
+      ))}
+
+      {!error && samples.length === 0 && (
+        <div style={{ color: '#4b5563', textAlign: 'center', padding: 48, fontSize: 14, fontFamily: sans }}>
+          No compressed requests yet. Tool outputs compressed by the gateway show up here.
+        </div>
+      )}
+    </div>
+  )
+}
+
+export default CompressionDiffTab
@@ -1,6 +1,6 @@
 import { useState } from 'react'
-import { DollarSign, Layers, Activity, Radio, Search, X, Trash2, TrendingDown, ChevronDown, ChevronUp, ChevronRight } from 'lucide-react'
-import type { DashboardData, Savings, Session } from '../types'
+import { DollarSign, Layers, Activity, Radio, Search, X, Trash2, TrendingDown, ChevronDown, ChevronUp, ChevronRight, Wrench, Users } from 'lucide-react'
+import type { BudgetScopeUsage, DashboardData, LifetimeMetrics, Savings, Session, ToolSavings } from '../types'
 
 interface SavingsTabProps {
   data: DashboardData | null
@@ -31,6 +31,21 @@ function timeAgo(dateStr: string): string {
   return `${Math.floor(diffHr / 24)}d ago`
 }
 
+// One-line lifetime totals (persisted across gateway restarts)
+function LifetimeLine({ lifetime }: { lifetime: LifetimeMetrics }) {
+  const since = lifetime.since ? new Date(lifetime.since).toLocaleDateString() : ''
+  return (
+    <div style={{ display: 'flex', alignItems: 'center', gap: 8, padding: '0 4px', fontSize: 12, color: '#6b7280', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }}>
+      <TrendingDown size={12} style={{ color: '#34d399' }} />
+      <span>
+        Lifetime: <span style={{ color: '#d1d5db' }}>${formatCost(lifetime.cost_saved_usd)}</span> saved
+        · <span style={{ color: '#d1d5db' }}>{formatTokens(lifetime.tokens_saved)}</span> tokens
+        · {lifetime.requests} requests{since && ` since ${since}`}
+      </span>
+    </div>
+  )
+}
+
 // Summary cards row showing totals or per-session data
 function SummaryCards({ savings, totalCost, isScoped, sessionCount }: { savings?: Savings; totalCost: number; isScoped: boolean; sessionCount: number }) {
   const totalSpend = isScoped ? totalCost : (savings?.billed_spend_usd ?? totalCost)
@@ -126,6 +141,103 @@ function SummaryCards({ savings, totalCost, isScoped, sessionCount }: { savings?
   )
 }
 
+// Per-tool leaderboard: which tools benefit most from compression
+function ToolLeaderboard({ tools }: { tools: ToolSavings[] }) {
+  const maxSaved = Math.max(...tools.map(t => t.net_tokens_saved), 1)
+  const headStyle: React.CSSProperties = { fontSize: 10, fontWeight: 600, color: '#6b7280', textTransform: 'uppercase', letterSpacing: '0.08em', textAlign: 'right', padding: '0 0 8px 12px', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }
+  const cellStyle: React.CSSProperties = { fontSize: 12, color: '#e5e7eb', textAlign: 'right', padding: '7px 0 7px 12px', borderTop: '1px solid rgba(255,255,255,0.04)', fontFamily: "'JetBrains Mono', monospace" }
+
+  return (
+    <div style={{ background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 16, padding: 20 }}>
+      <div style={{ display: 'flex', alignItems: 'center', gap: 8, marginBottom: 14 }}>
+        <Wrench size={14} style={{ color: '#a78bfa' }} />
+        <span style={{ fontSize: 13, fontWeight: 500, color: '#e5e7eb', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }}>Savings by tool</span>
+        <span style={{ fontSize: 11, color: '#6b7280', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }}>this gateway, since last reset</span>
+      </div>
+      <table style={{ width: '100%', borderCollapse: 'collapse' }}>
+        <thead>
+          <tr>
+            <th style={{ ...headStyle, textAlign: 'left', paddingLeft: 0 }}>Tool</th>
+            <th style={headStyle}>Net saved</th>
+            <th style={headStyle}>Saved %</th>
+            <th style={headStyle}>Outputs</th>
+            <th style={headStyle}>Expand rate</th>
+            <th style={headStyle}>Regrets</th>
+          </tr>
+        </thead>
+        <tbody>
+          {tools.map((t) => (
+            <tr key={t.tool_name}>
+              <td style={{ ...cellStyle, textAlign: 'left', paddingLeft: 0 }}>
+                <div>{t.tool_name}</div>
+                <div style={{ marginTop: 4, height: 3, borderRadius: 2, background: 'rgba(255,255,255,0.04)' }}>
+                  <div style={{ height: 3, borderRadius: 2, width: `${Math.max(0, (t.net_tokens_saved / maxSaved) * 100)}%`, background: 'linear-gradient(90deg, #a78bfa, #22c55e)' }} />
+                </div>
+              </td>
+              <td style={cellStyle}>{formatTokens(t.net_tokens_saved)}</td>
+              <td style={cellStyle}>{t.saved_pct.toFixed(0)}%</td>
+              <td style={cellStyle}>{t.compressed_sent}/{t.outputs_sent}</td>
+              <td style={cellStyle}>{(t.expand_rate * 100).toFixed(0)}%</td>
+              <td style={{ ...cellStyle, color: t.regrets > 0 ? '#eab308' : '#6b7280' }}>{t.regrets}</td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    </div>
+  )
+}
+
+// Spend per budget scope value (API key, team, project) in the current window
+function BudgetScopes({ scopes }: { scopes: BudgetScopeUsage[] }) {
+  const headStyle: React.CSSProperties = { fontSize: 10, fontWeight: 600, color: '#6b7280', textTransform: 'uppercase', letterSpacing: '0.08em', textAlign: 'right', padding: '0 0 8px 12px', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }
+  const cellStyle: React.CSSProperties = { fontSize: 12, color: '#e5e7eb', textAlign: 'right', padding: '7px 0 7px 12px', borderTop: '1px solid rgba(255,255,255,0.04)', fontFamily: "'JetBrains Mono', monospace" }
+
+  return (
+    <div style={{ background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 16, padding: 20 }}>
+      <div style={{ display: 'flex', alignItems: 'center', gap: 8, marginBottom: 14 }}>
+        <Users size={14} style={{ color: '#60a5fa' }} />
+        <span style={{ fontSize: 13, fontWeight: 500, color: '#e5e7eb', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }}>Spend by budget scope</span>
+        <span style={{ fontSize: 11, color: '#6b7280', fontFamily: "'Inter', system-ui, -apple-system, sans-serif" }}>current window</span>
+      </div>
+      <table style={{ width: '100%', borderCollapse: 'collapse' }}>
+        <thead>
+          <tr>
+            <th style={{ ...headStyle, textAlign: 'left', paddingLeft: 0 }}>Scope</th>
+            <th style={{ ...headStyle, textAlign: 'left' }}>Value</th>
+            <th style={headStyle}>Window</th>
+            <th style={headStyle}>Spend</th>
+            <th style={headStyle}>Cap</th>
+            <th style={headStyle}>Requests</th>
+          </tr>
+        </thead>
+        <tbody>
+          {scopes.map((s) => {
+            const pct = s.cap_usd > 0 ? Math.min(100, (s.cost_usd / s.cap_usd) * 100) : 0
+            const over = s.cap_usd > 0 && s.cost_usd >= s.cap_usd
+            return (
+              <tr key={`${s.scope}/${s.value}`}>
+                <td style={{ ...cellStyle, textAlign: 'left', paddingLeft: 0, color: '#9ca3af' }}>{s.scope}</td>
+                <td style={{ ...cellStyle, textAlign: 'left' }}>
+                  <div>{s.value}</div>
+                  {s.cap_usd > 0 && (
+                    <div style={{ marginTop: 4, height: 3, borderRadius: 2, background: 'rgba(255,255,255,0.04)' }}>
+                      <div style={{ height: 3, borderRadius: 2, width: `${pct}%`, background: over ? '#ef4444' : pct >= 80 ? '#eab308' : '#22c55e' }} />
+                    </div>
+                  )}
+                </td>
+                <td style={cellStyle}>{s.period}</td>
+                <td style={{ ...cellStyle, color: over ? '#ef4444' : '#e5e7eb' }}>${formatCost(s.cost_usd)}</td>
+                <td style={cellStyle}>{s.cap_usd > 0 ? `$${formatCost(s.cap_usd)}` : '—'}</td>
+                <td style={cellStyle}>{s.requests}</td>
+              </tr>
+            )
+          })}
+        </tbody>
+      </table>
+    </div>
+  )
+}
+
 // Savings detail row used inside expanded session card
 function SavingsDetailRow({ label, value, sub }: { label: string; value: string; sub?: string }) {
   return (
@@ -490,6 +602,15 @@ function SavingsTab({ data, error, onSessionChange, selectedSession }: SavingsTa
         sessionCount={allSessions.length}
       />
 
+      {/* Lifetime totals across restarts */}
+      {data.lifetime && <LifetimeLine lifetime={data.lifetime} />}
+
+      {/* Per-tool savings leaderboard */}
+      {(data.tool_savings?.length ?? 0) > 0 && <ToolLeaderboard tools={data.tool_savings!} />}
+
+      {/* Budget scope breakdown (cost_control.scopes) */}
+      {(data.budget_scopes?.length ?? 0) > 0 && <BudgetScopes scopes={data.budget_scopes!} />}
+
       {/* Active gateways */}
       {activePorts.length > 0 && (
         <div style={{ display: 'flex', alignItems: 'center', gap: 8, padding: '0 4px' }}>
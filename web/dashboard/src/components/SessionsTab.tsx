@@ -0,0 +1,177 @@
+import { useState, useEffect } from 'react'
+import { Users } from 'lucide-react'
+import type { MonitorData, SessionInfo } from '../types'
+
+const mono = "'JetBrains Mono', monospace"
+const sans = "'Inter', system-ui, -apple-system, sans-serif"
+
+function formatTokens(n: number): string {
+  if (n >= 1_000_000) return `${(n / 1_000_000).toFixed(1)}M`
+  if (n >= 1_000) return `${(n / 1_000).toFixed(1)}K`
+  return String(n)
+}
+
+function timeAgo(dateStr?: string): string {
+  if (!dateStr) return ''
+  const then = new Date(dateStr).getTime()
+  if (isNaN(then)) return ''
+  const diffSec = Math.floor((Date.now() - then) / 1000)
+  if (diffSec < 60) return `${diffSec}s ago`
+  const diffMin = Math.floor(diffSec / 60)
+  if (diffMin < 60) return `${diffMin}m ago`
+  return `${Math.floor(diffMin / 60)}h ago`
+}
+
+function Field({ label, children }: { label: string; children: React.ReactNode }) {
+  return (
+    <div style={{ display: 'flex', gap: 12, fontSize: 12, fontFamily: sans, padding: '3px 0' }}>
+      <span style={{ width: 130, flexShrink: 0, color: '#6b7280' }}>{label}</span>
+      <span style={{ color: '#e5e7eb', fontFamily: mono, wordBreak: 'break-all' }}>{children}</span>
+    </div>
+  )
+}
+
+function Section({ title, children }: { title: string; children: React.ReactNode }) {
+  return (
+    <div style={{ background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 12, padding: '12px 16px' }}>
+      <div style={{ fontSize: 10, fontWeight: 600, color: '#6b7280', textTransform: 'uppercase', letterSpacing: '0.08em', marginBottom: 8, fontFamily: sans }}>
+        {title}
+      </div>
+      {children}
+    </div>
+  )
+}
+
+function SessionDetail({ s }: { s: SessionInfo }) {
+  const apiKey = s.auth.mode === 'api_key'
+  return (
+    <div style={{ display: 'flex', flexDirection: 'column', gap: 12 }}>
+      <Section title="Auth">
+        <Field label="Mode">
+          <span style={{ color: apiKey ? '#eab308' : '#22c55e' }}>{s.auth.mode || 'unknown'}</span>
+          {s.auth.sticky && <span style={{ color: '#6b7280' }}> (sticky since {timeAgo(s.auth.since)})</span>}
+        </Field>
+        {s.auth.reason && <Field label="Fallback reason">{s.auth.reason}</Field>}
+      </Section>
+
+      <Section title="Cost">
+        {s.cost ? (
+          <>
+            <Field label="Spent">${s.cost.usd.toFixed(4)}{s.cost.cap_usd ? ` of $${s.cost.cap_usd.toFixed(2)}` : ''}</Field>
+            <Field label="Tokens">{formatTokens(s.cost.input_tokens)} in · {formatTokens(s.cost.output_tokens)} out</Field>
+          </>
+        ) : <Field label="Spent">not tracked</Field>}
+        <Field label="Requests">{s.requests}</Field>
+        <Field label="Shadow refs">{s.shadow_refs}</Field>
+      </Section>
+
+      <Section title="Tools">
+        {s.tools ? (
+          <>
+            <Field label="Expanded">{s.tools.expanded.length ? s.tools.expanded.join(', ') : '—'}</Field>
+            <Field label="Deferred">{s.tools.deferred.length ? `${s.tools.deferred.length}: ${s.tools.deferred.join(', ')}` : '—'}</Field>
+          </>
+        ) : <Field label="Discovery">no tool session</Field>}
+      </Section>
+
+      <Section title="Summary">
+        {s.summary ? (
+          <>
+            <Field label="Status">{s.summary.status}{s.summary.tokens ? ` · ${formatTokens(s.summary.tokens)} tokens` : ''}</Field>
+            <Field label="Context usage">{s.summary.usage_percent.toFixed(1)}%</Field>
+            <Field label="Last compaction">{s.summary.last_compaction ? timeAgo(s.summary.last_compaction) : 'never'}</Field>
+            <Field label="Compactions">{s.summary.compactions}</Field>
+          </>
+        ) : <Field label="Status">no summary session</Field>}
+      </Section>
+    </div>
+  )
+}
+
+function SessionsTab() {
+  const [ports, setPorts] = useState<number[]>([])
+  const [port, setPort] = useState<number | null>(null)
+  const [sessions, setSessions] = useState<SessionInfo[]>([])
+  const [selectedID, setSelectedID] = useState<string | null>(null)
+  const [error, setError] = useState<string | null>(null)
+
+  // Discover gateway instances
+  useEffect(() => {
+    fetch('/api/monitor')
+      .then(res => res.json())
+      .then((data: MonitorData) => {
+        const found = (data.instances ?? []).map(i => i.port).sort((a, b) => a - b)
+        setPorts(found)
+        setPort(prev => prev ?? found[0] ?? null)
+      })
+      .catch(e => setError(String(e)))
+  }, [])
+
+  // Poll the sessions of the selected instance
+  useEffect(() => {
+    if (port === null) return
+    const fetchList = async () => {
+      try {
+        const res = await fetch(`/api/instance/sessions?port=${port}`)
+        if (!res.ok) { setError(`API returned ${res.status}`); return }
+        const data: { sessions: SessionInfo[] } = await res.json()
+        setSessions(data.sessions ?? [])
+        setSelectedID(prev => prev ?? data.sessions?.[0]?.id ?? null)
+        setError(null)
+      } catch (e) {
+        setError(String(e))
+      }
+    }
+    fetchList()
+    const interval = setInterval(fetchList, 5000)
+    return () => clearInterval(interval)
+  }, [port])
+
+  const selected = sessions.find(s => s.id === selectedID) ?? null
+
+  const selectStyle = {
+    background: 'rgba(17,17,17,0.9)', border: '1px solid rgba(255,255,255,0.08)', borderRadius: 8,
+    padding: '7px 10px', color: '#e5e7eb', fontSize: 12, fontFamily: mono, outline: 'none',
+  }
+
+  return (
+    <div style={{ display: 'flex', flexDirection: 'column', gap: 16 }}>
+      <div style={{ display: 'flex', alignItems: 'center', gap: 10 }}>
+        <Users size={16} style={{ color: '#22c55e' }} />
+        <span style={{ fontSize: 13, color: '#e5e7eb', fontWeight: 500, fontFamily: sans }}>Session inspector</span>
+        <span style={{ fontSize: 11, color: '#6b7280', fontFamily: sans }}>cost, auth mode, tools and summary state per session</span>
+        <div style={{ flex: 1 }} />
+        {ports.length > 1 && (
+          <select value={port ?? ''} onChange={e => { setPort(Number(e.target.value)); setSelectedID(null); setSessions([]) }} style={selectStyle}>
+            {ports.map(p => <option key={p} value={p}>:{p}</option>)}
+          </select>
+        )}
+        {sessions.length > 0 && (
+          <select value={selectedID ?? ''} onChange={e => setSelectedID(e.target.value)} style={{ ...selectStyle, maxWidth: 420 }}>
+            {sessions.map(s => (
+              <option key={s.id} value={s.id}>
+                {timeAgo(s.last_seen)} · {s.model || 'unknown model'} · {s.auth.mode || 'unknown'} · {s.id}
+              </option>
+            ))}
+          </select>
+        )}
+      </div>
+
+      {error && (
+        <div style={{ fontSize: 12, color: '#eab308', padding: '8px 14px', background: 'rgba(234,179,8,0.06)', border: '1px solid rgba(234,179,8,0.2)', borderRadius: 10, fontFamily: sans }}>
+          {error}
+        </div>
+      )}
+
+      {selected && <SessionDetail s={selected} />}
+
+      {!error && sessions.length === 0 && (
+        <div style={{ color: '#4b5563', textAlign: 'center', padding: 48, fontSize: 14, fontFamily: sans }}>
+          No sessions yet. Sessions show up here after their first request.
+        </div>
+      )}
+    </div>
+  )
+}
+
+export default SessionsTab
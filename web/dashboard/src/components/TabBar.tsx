@@ -1,7 +1,7 @@
 import { useState } from 'react'
-import { DollarSign, History, Monitor, Settings } from 'lucide-react'
+import { DollarSign, GitCompare, History, Monitor, Settings, Users } from 'lucide-react'
 
-type TabKey = 'savings' | 'history' | 'monitor' | 'settings'
+type TabKey = 'savings' | 'history' | 'monitor' | 'diff' | 'sessions' | 'settings'
 
 interface TabBarProps {
   activeTab: TabKey
@@ -51,6 +51,32 @@ function TabBar({ activeTab, onTabChange }: TabBarProps) {
         />
       ),
     },
+    {
+      key: 'diff',
+      label: 'Compression Diff',
+      icon: (active: boolean) => (
+        <GitCompare
+          size={16}
+          style={{
+            transition: 'color 0.25s ease',
+            color: active ? '#22c55e' : '#6b7280',
+          }}
+        />
+      ),
+    },
+    {
+      key: 'sessions',
+      label: 'Sessions',
+      icon: (active: boolean) => (
+        <Users
+          size={16}
+          style={{
+            transition: 'color 0.25s ease',
+            color: active ? '#22c55e' : '#6b7280',
+          }}
+        />
+      ),
+    },
     {
       key: 'settings',
       label: 'Global Config',
@@ -49,6 +49,95 @@ export interface ExpandContext {
   recent?: ExpandEntry[]
 }
 
+export interface ToolSavings {
+  tool_name: string
+  outputs_sent: number
+  compressed_sent: number
+  compressed_outputs: number
+  cache_hits: number
+  original_bytes: number
+  compressed_bytes: number
+  original_tokens: number
+  compressed_tokens: number
+  tokens_saved: number
+  saved_pct: number
+  expands: number
+  expand_rate: number
+  expanded_tokens: number
+  regrets: number
+  net_tokens_saved: number
+}
+
+// Compression diff view (/api/instance/compressions)
+export interface CompressionComparison {
+  request_id: string
+  tool_name?: string
+  shadow_id?: string
+  original_tokens?: number
+  compressed_tokens?: number
+  compression_ratio: number
+  cache_hit: boolean
+  status: string
+  compression_model?: string
+  query?: string
+  original_content?: string
+  compressed_content?: string
+}
+
+export interface ComparisonSampleSummary {
+  request_id: string
+  session_id?: string
+  model?: string
+  timestamp: string
+  outputs: number
+  original_tokens: number
+  compressed_tokens: number
+}
+
+export interface ComparisonSample {
+  request_id: string
+  session_id?: string
+  model?: string
+  timestamp: string
+  comparisons: CompressionComparison[]
+}
+
+// Session inspector (/api/instance/sessions)
+export interface SessionInfo {
+  id: string
+  provider?: string
+  model?: string
+  requests: number
+  first_seen?: string
+  last_seen?: string
+  auth: {
+    mode?: string
+    sticky: boolean
+    since?: string
+    reason?: string
+  }
+  cost?: {
+    usd: number
+    cap_usd?: number
+    input_tokens: number
+    output_tokens: number
+  }
+  tools?: {
+    session_id: string
+    expanded: string[]
+    deferred: string[]
+  }
+  summary?: {
+    session_id: string
+    status: string
+    tokens?: number
+    usage_percent: number
+    last_compaction?: string
+    compactions: number
+  }
+  shadow_refs: number
+}
+
 export interface SearchEntry {
   timestamp: string
   request_id: string
@@ -122,7 +211,32 @@ export interface DashboardData {
   expand?: ExpandContext
   search?: SearchContext
   gateway?: GatewayStats
+  tool_savings?: ToolSavings[]
   active_ports?: number[]
+  lifetime?: LifetimeMetrics
+  budget_scopes?: BudgetScopeUsage[]
+}
+
+// Spend of one budget scope value (cost_control.scopes) in its current window
+export interface BudgetScopeUsage {
+  scope: string
+  value: string
+  window: 'daily' | 'weekly' | 'total'
+  period: string
+  resets_at?: string
+  cost_usd: number
+  cap_usd: number
+  requests: number
+}
+
+// Counters persisted in the state directory, summed across restarts
+export interface LifetimeMetrics {
+  requests: number
+  tokens_saved: number
+  cost_saved_usd: number
+  starts: number
+  since: string
+  updated_at: string
 }
 
 export interface AccountData {